@@ -1,20 +1,52 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/nimsforest/morpheus/pkg/cloudcreds"
 	"github.com/nimsforest/morpheus/pkg/config"
 	"github.com/nimsforest/morpheus/pkg/guard"
+	"github.com/nimsforest/morpheus/pkg/guard/aws"
 	"github.com/nimsforest/morpheus/pkg/guard/azure"
+	"github.com/nimsforest/morpheus/pkg/guard/gcp"
+	"github.com/nimsforest/morpheus/pkg/guard/hetzner"
+	"github.com/nimsforest/morpheus/pkg/httputil"
+	"github.com/nimsforest/morpheus/pkg/secretstore"
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+	"github.com/nimsforest/morpheus/pkg/storage"
 )
 
 var version = "dev"
 
+// providerFlag is the cloud selected with --provider, overriding
+// guard.provider from the config
+var providerFlag string
+
 func main() {
+	for i := 1; i < len(os.Args); i++ {
+		if os.Args[i] != "--provider" {
+			continue
+		}
+		if i+1 >= len(os.Args) {
+			fmt.Fprintln(os.Stderr, "❌ --provider requires azure, gcp, aws or hetzner")
+			os.Exit(1)
+		}
+		providerFlag = os.Args[i+1]
+		os.Args = append(os.Args[:i:i], os.Args[i+2:]...)
+		break
+	}
+
 	if len(os.Args) < 2 {
 		printHelp()
 		os.Exit(1)
@@ -31,6 +63,22 @@ func main() {
 		handleTeardown()
 	case "peer":
 		handlePeer()
+	case "unpeer":
+		handleUnpeer()
+	case "routes":
+		handleRoutes()
+	case "configs":
+		handleConfigs()
+	case "rotate":
+		handleRotate()
+	case "test":
+		handleTest()
+	case "check":
+		handleCheck()
+	case "failover":
+		handleFailover()
+	case "nsg":
+		handleNSG()
 	case "version":
 		fmt.Printf("morpheus-azureguard version %s\n", version)
 	case "help", "--help", "-h":
@@ -46,21 +94,78 @@ func printHelp() {
 	fmt.Println("🛡️  morpheus-azureguard — WireGuard Gateway VM Manager")
 	fmt.Println()
 	fmt.Println("Usage:")
-	fmt.Println("  morpheus-azureguard <command> [arguments]")
+	fmt.Println("  morpheus-azureguard [--provider azure|gcp|aws|hetzner] <command> [arguments]")
+	fmt.Println()
+	fmt.Println("  --provider               Cloud to manage guards in (default: guard.provider")
+	fmt.Println("                           in the config, else azure). On GCP, locations are")
+	fmt.Println("                           zones; on AWS, availability zones of machine.aws.region.")
+	fmt.Println("                           On both, VNets are VPCs. On Hetzner, locations are")
+	fmt.Println("                           machine.hetzner locations and VNets are networks")
 	fmt.Println()
 	fmt.Println("Commands:")
 	fmt.Println("  create                   Create a new guard VM")
 	fmt.Println("    --config <path|->      WireGuard config file (required)")
 	fmt.Println("    --mesh-cidrs <cidrs>   Comma-separated mesh CIDRs")
-	fmt.Println("    --location <loc>       Azure location (default: from config)")
+	fmt.Println("    --location <loc>       Azure location or GCP, AWS or Hetzner zone (default: from config)")
+	fmt.Println("    --locations <locs>     Comma-separated locations: one guard in each, as a")
+	fmt.Println("                           group, each with its own WireGuard key")
+	fmt.Println("    --ha                   Create an HA pair: a primary and a standby guard in")
+	fmt.Println("                           two availability zones of the location (Azure only)")
+	fmt.Println("    --zones <z1,z2>        Zones of the primary and standby (default: 1,2)")
+	fmt.Println("    --ssh-allow-cidr <cidr>")
+	fmt.Println("                           Address allowed to SSH to the guard, repeatable")
+	fmt.Println("                           (default: your public IP; '*' for any)")
+	fmt.Println("    --tag <key=value>      Tag all resources of the guard, e.g. cost-center=42;")
+	fmt.Println("                           repeatable (Azure only)")
 	fmt.Println()
 	fmt.Println("  status <guard-id>        Show guard details")
-	fmt.Println("  list                     List all guards")
+	fmt.Println("  list                     List the guards in the registry")
+	fmt.Println("    --discover             Scan the cloud for guards and update the registry")
+	fmt.Println("    --tag <key=value>      Only guards with the tag, repeatable")
 	fmt.Println("  teardown <guard-id>      Delete a guard and all resources")
+	fmt.Println("    --group <group-id>     Delete all guards of a group instead")
 	fmt.Println()
 	fmt.Println("  peer <guard-id>          Peer a workload VNet to the guard VNet")
+	fmt.Println("    --vnet <resource-id>   Remote VNet resource ID, GCP network, AWS VPC ID or")
+	fmt.Println("                           Hetzner network (required)")
+	fmt.Println("    --subnet <resource-id> Remote subnet for route table (optional; on GCP the")
+	fmt.Println("                           mesh routes reach the whole network, on AWS the")
+	fmt.Println("                           VPC's main route table is used without it; Hetzner")
+	fmt.Println("                           routes always apply to the whole network)")
+	fmt.Println("    --all-subnets          Route all subnets of the VNet with one route table (Azure)")
+	fmt.Println("  unpeer <guard-id>        Remove a peering and its route table")
 	fmt.Println("    --vnet <resource-id>   Remote VNet resource ID (required)")
-	fmt.Println("    --subnet <resource-id> Remote subnet for route table (optional)")
+	fmt.Println()
+	fmt.Println("  failover <pair-id>       Point the route tables of an HA pair at its standby")
+	fmt.Println("    --auto                 Only if the active guard is unhealthy, e.g. from cron")
+	fmt.Println("    --client <path>        Client config to check health with a handshake")
+	fmt.Println()
+	fmt.Println("  rotate <guard-id>        Replace a guard's WireGuard config, rolling back if")
+	fmt.Println("                           no handshake succeeds with the new one")
+	fmt.Println("    --config <path|->      New WireGuard config (required)")
+	fmt.Println("    --client <path>        Client config to verify the handshake with (default:")
+	fmt.Println("                           wait for any peer's handshake on the guard)")
+	fmt.Println("    --ssh                  Connect with SSH instead of Azure Run Command (always")
+	fmt.Println("                           used on GCP, AWS and Hetzner)")
+	fmt.Println("    --timeout <duration>   How long to wait for the handshake (default: 30s)")
+	fmt.Println()
+	fmt.Println("  configs <guard-id>       List the WireGuard configs a guard was given")
+	fmt.Println("    --show <n>             Print config n (private key redacted)")
+	fmt.Println()
+	fmt.Println("  routes list [guard-id]   List the route tables created for peerings")
+	fmt.Println("    --json                 Output as JSON")
+	fmt.Println()
+	fmt.Println("  test <guard-id>          Test the guard's connectivity")
+	fmt.Println("    --config <path|->      WireGuard client config to handshake with")
+	fmt.Println("    --probe <resource-id>  VM in a peered VNet to check mesh routes from")
+	fmt.Println("    --ping <ips>           Comma-separated mesh IPs the probe VM pings")
+	fmt.Println("  check <guard-id>         Diagnose the guard from the cloud: VM, NSG, IP")
+	fmt.Println("                           forwarding, peerings, handshakes and route tables")
+	fmt.Println("    --ssh                  Read handshakes with SSH instead of Azure Run Command")
+	fmt.Println()
+	fmt.Println("  nsg <guard-id>           List the addresses allowed to SSH to the guard (Azure)")
+	fmt.Println("    --allow [cidr]         Allow a CIDR or IP, repeatable (default: your public IP)")
+	fmt.Println("    --revoke <cidr>        Stop allowing a CIDR or IP, repeatable")
 	fmt.Println()
 	fmt.Println("  version                  Show version")
 	fmt.Println("  help                     Show this help")
@@ -68,10 +173,24 @@ func printHelp() {
 	fmt.Println("Examples:")
 	fmt.Println("  morpheus-azureguard create --config /path/to/wg0.conf --mesh-cidrs 10.200.0.0/16")
 	fmt.Println("  hydraguard venue config azure-westeu | morpheus-azureguard create --config -")
+	fmt.Println("  morpheus-azureguard create --config wg0.conf.tmpl --locations westeurope,northeurope")
 	fmt.Println("  morpheus-azureguard peer guard-1738123456 --vnet /subscriptions/.../virtualNetworks/workload-vnet")
 	fmt.Println("  morpheus-azureguard status guard-1738123456")
-	fmt.Println("  morpheus-azureguard list")
+	fmt.Println("  morpheus-azureguard test guard-1738123456 --config client.conf --probe /subscriptions/.../virtualMachines/probe-vm")
+	fmt.Println("  morpheus-azureguard check guard-1738123456")
+	fmt.Println("  morpheus-azureguard nsg guard-1738123456 --allow --revoke 203.0.113.7/32")
+	fmt.Println("  morpheus-azureguard create --config wg0.conf.tmpl --location westeurope --ha")
+	fmt.Println("  morpheus-azureguard failover guard-1738123456 --auto --client client.conf")
+	fmt.Println("  morpheus-azureguard rotate guard-1738123456 --config wg0-new.conf --client client.conf")
+	fmt.Println("  morpheus-azureguard routes list guard-1738123456")
+	fmt.Println("  morpheus-azureguard list --discover")
+	fmt.Println("  morpheus-azureguard create --config wg0.conf --tag team=platform --tag env=prod")
+	fmt.Println("  morpheus-azureguard list --tag team=platform")
 	fmt.Println("  morpheus-azureguard teardown guard-1738123456")
+	fmt.Println("  morpheus-azureguard --provider gcp create --config wg0.conf --location europe-west1-b")
+	fmt.Println("  morpheus-azureguard --provider gcp peer guard-1738123456 --vnet projects/my-project/global/networks/workload")
+	fmt.Println("  morpheus-azureguard --provider aws peer guard-1738123456 --vnet vpc-0abc123 --subnet subnet-0def456")
+	fmt.Println("  morpheus-azureguard --provider hetzner peer guard-1738123456 --vnet workload-net")
 }
 
 func loadConfig() *config.Config {
@@ -80,6 +199,9 @@ func loadConfig() *config.Config {
 		fmt.Fprintf(os.Stderr, "❌ Failed to load config: %s\n", err)
 		os.Exit(1)
 	}
+	if providerFlag != "" {
+		cfg.Guard.Provider = providerFlag
+	}
 	if err := cfg.ValidateGuard(); err != nil {
 		fmt.Fprintf(os.Stderr, "❌ Invalid config: %s\n", err)
 		os.Exit(1)
@@ -88,41 +210,198 @@ func loadConfig() *config.Config {
 }
 
 func loadConfigFromPaths() (*config.Config, error) {
-	paths := []string{
-		"./config.yaml",
+	path := config.FindConfigPath()
+	if path == "" {
+		return nil, fmt.Errorf("no config file found (tried: %v)", config.ConfigSearchPaths())
+	}
+	return config.LoadConfig(path)
+}
+
+func createProvider(cfg *config.Config) guard.GuardProvider {
+	switch cfg.GetGuardProvider() {
+	case "gcp":
+		return createGCPProvider(cfg)
+	case "aws":
+		return createAWSProvider(cfg)
+	case "hetzner":
+		return createHetznerProvider(cfg)
+	}
+	// machine.azure.auth selects the credential: a service principal, the
+	// Azure CLI login, the environment or a managed identity
+	prov, err := azure.NewConfiguredProvider(cfg.Machine.Azure)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to create Azure provider: %s\n", err)
+		os.Exit(1)
+	}
+	return prov
+}
+
+// createGCPProvider creates a GCP guard provider for the gcloud
+// configuration and project in machine.gcp
+func createGCPProvider(cfg *config.Config) *gcp.Provider {
+	gc := cfg.Machine.GCP
+
+	creds, err := cloudcreds.LoadGCPConfiguration(gc.Configuration)
+	if err != nil && gc.Project != "" {
+		// No gcloud configuration is needed with an explicit project
+		creds, err = &cloudcreds.GCPCredentials{Configuration: gc.Configuration, Project: gc.Project}, nil
+	}
+	var prov *gcp.Provider
+	if err == nil {
+		project := gc.Project
+		if project == "" {
+			project = creds.Project
+		}
+		var tokens gcp.TokenSource
+		tokens, err = gcp.NewTokenSource(creds)
+		if err == nil {
+			prov, err = gcp.NewProvider(project, gc.Zone, gc.MachineType, gc.Image, tokens)
+		}
 	}
-	home := os.Getenv("HOME")
-	if home != "" {
-		paths = append(paths, home+"/.morpheus/config.yaml")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to create GCP provider: %s\n", err)
+		os.Exit(1)
 	}
-	paths = append(paths, "/etc/morpheus/config.yaml")
+	return prov
+}
+
+// createAWSProvider creates an AWS guard provider for the profile and
+// region in machine.aws, or for AWS_ACCESS_KEY_ID and
+// AWS_SECRET_ACCESS_KEY if they are set
+func createAWSProvider(cfg *config.Config) *aws.Provider {
+	ac := cfg.Machine.AWS
 
-	for _, path := range paths {
-		if _, err := os.Stat(path); err == nil {
-			return config.LoadConfig(path)
+	creds := &cloudcreds.AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Region:          os.Getenv("AWS_REGION"),
+	}
+	var err error
+	if creds.AccessKeyID == "" || ac.Profile != "" {
+		creds, err = cloudcreds.LoadAWSProfile(ac.Profile)
+	}
+	var prov *aws.Provider
+	if err == nil {
+		region := ac.Region
+		if region == "" {
+			region = creds.Region
 		}
+		prov, err = aws.NewProvider(region, ac.InstanceType, ac.Image, aws.Credentials{
+			AccessKeyID:     creds.AccessKeyID,
+			SecretAccessKey: creds.SecretAccessKey,
+			SessionToken:    creds.SessionToken,
+		})
 	}
-	return nil, fmt.Errorf("no config file found (tried: %v)", paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to create AWS provider: %s\n", err)
+		os.Exit(1)
+	}
+	return prov
 }
 
-func createProvider(cfg *config.Config) *azure.Provider {
-	az := cfg.Machine.Azure
-	prov, err := azure.NewProvider(
-		az.SubscriptionID, az.TenantID, az.ClientID, az.ClientSecret,
-		az.ResourceGroup, az.Location, az.VMSize, az.Image,
-	)
+// createHetznerProvider creates a Hetzner guard provider for the API token
+// and location in machine.hetzner
+func createHetznerProvider(cfg *config.Config) *hetzner.Provider {
+	hz := cfg.Machine.Hetzner
+
+	token, err := cfg.GetHetznerToken("")
+	var prov *hetzner.Provider
+	if err == nil {
+		prov, err = hetzner.NewProvider(token, hz.Location, hz.ServerType, hz.Image)
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "❌ Failed to create Azure provider: %s\n", err)
+		fmt.Fprintf(os.Stderr, "❌ Failed to create Hetzner provider: %s\n", err)
 		os.Exit(1)
 	}
 	return prov
 }
 
+// newProvisioner creates a guard provisioner keeping generated WireGuard
+// keys in the secret store, if one is configured
+func newProvisioner(prov guard.GuardProvider, cfg *config.Config) *guard.Provisioner {
+	provisioner := guard.NewProvisioner(prov, cfg)
+	secrets, err := secretstore.FromConfig(cfg, "morpheus-azureguard "+strings.Join(os.Args[1:], " "))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to open the secret store: %s\n", err)
+		os.Exit(1)
+	}
+	if secrets != nil {
+		provisioner.SetSecrets(secrets)
+	}
+	if reg, err := openRegistry(); err == nil {
+		provisioner.SetRegistry(reg, operator(cfg))
+	} else {
+		fmt.Fprintf(os.Stderr, "⚠️  Warning: guards are not recorded in the registry: %s\n", err)
+	}
+	return provisioner
+}
+
+// commandName is how to run this command again for the same cloud, for
+// hints
+func commandName() string {
+	if providerFlag != "" {
+		return "morpheus-azureguard --provider " + providerFlag
+	}
+	return "morpheus-azureguard"
+}
+
+// recordGuardEvent records a guard change and who made it in the morpheus
+// registry, where "morpheus history" shows it. Failing to record it does
+// not fail the command.
+func recordGuardEvent(cfg *config.Config, action, guardID, detail string) {
+	reg, err := openRegistry()
+	if err == nil {
+		audited := storage.NewAuditedRegistry(reg, operator(cfg), "morpheus-azureguard "+strings.Join(os.Args[1:], " "))
+		err = audited.AppendEvent(storage.Event{Action: action, Guard: guardID, Detail: detail})
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Warning: guard change not recorded in history: %s\n", err)
+	}
+}
+
+// openRegistry opens the morpheus registry, which also records guards
+func openRegistry() (*storage.LocalRegistry, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewLocalRegistry(filepath.Join(home, ".morpheus", "registry.json"))
+}
+
+// operator returns who is running the command, as recorded in the registry
+func operator(cfg *config.Config) string {
+	return storage.DetectOperator(cfg.GetOperator())
+}
+
+// updateGuardRecord applies fn to a guard's registry record, registering
+// the guard as seen in the cloud first if it is not recorded yet. Failing
+// to update the registry does not fail the command.
+func updateGuardRecord(cfg *config.Config, g *guard.Guard, fn func(rec *storage.Guard)) {
+	reg, err := openRegistry()
+	if err == nil {
+		var rec *storage.Guard
+		if rec, err = reg.GetGuard(g.ID); err == nil {
+			rec = guard.Merge(rec, g)
+			fn(rec)
+			err = reg.UpdateGuard(rec)
+		} else if errors.Is(err, storage.ErrGuardNotFound) {
+			rec = guard.Record(g)
+			fn(rec)
+			err = reg.RegisterGuard(rec)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Warning: guard registry not updated: %s\n", err)
+	}
+}
+
 // ── create ──────────────────────────────────────────────────────────────────
 
 func handleCreate() {
 	var configPath, location string
-	var meshCIDRs []string
+	var meshCIDRs, locations, zones, sshSources, tagArgs []string
+	ha := false
 
 	for i := 2; i < len(os.Args); i++ {
 		switch os.Args[i] {
@@ -147,8 +426,53 @@ func handleCreate() {
 			}
 			i++
 			location = os.Args[i]
+		case "--locations":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --locations requires comma-separated locations")
+				os.Exit(1)
+			}
+			i++
+			locations = strings.Split(os.Args[i], ",")
+		case "--ha":
+			ha = true
+		case "--zones":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --zones requires two comma-separated zones")
+				os.Exit(1)
+			}
+			i++
+			zones = strings.Split(os.Args[i], ",")
+		case "--ssh-allow-cidr":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --ssh-allow-cidr requires a CIDR or IP address")
+				os.Exit(1)
+			}
+			i++
+			sshSources = append(sshSources, os.Args[i])
+		case "--tag":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --tag requires key=value")
+				os.Exit(1)
+			}
+			i++
+			tagArgs = append(tagArgs, os.Args[i])
 		case "--help", "-h":
-			fmt.Println("Usage: morpheus-azureguard create --config <path|-> [--mesh-cidrs <cidrs>] [--location <loc>]")
+			fmt.Println("Usage: morpheus-azureguard create --config <path|-> [--mesh-cidrs <cidrs>] [--location <loc> [--ha [--zones <z1,z2>]] | --locations <locs>] [--ssh-allow-cidr <cidr>]... [--tag <key=value>]...")
+			fmt.Println()
+			fmt.Println("With --locations, the config is a template for the guards in all locations:")
+			fmt.Println("{{.Location}}, {{.GuardID}} and {{.Group}} are replaced for each, and each")
+			fmt.Println("gets a new [Interface] PrivateKey. Their public keys are printed, to add")
+			fmt.Println("them as peers.")
+			fmt.Println()
+			fmt.Println("With --ha, the config is such a template for the two guards of an HA pair.")
+			fmt.Println("The standby's VNet is the range after guard.vnet_cidr.")
+			fmt.Println()
+			fmt.Println("SSH to the guards is allowed from your public IP, unless --ssh-allow-cidr")
+			fmt.Println("gives the addresses to allow instead ('*' for any). Change them later with")
+			fmt.Println("the nsg command.")
+			fmt.Println()
+			fmt.Println("Tags, such as team, cost-center or env, are set on all Azure resources of")
+			fmt.Println("the guards, and on the route tables of their peerings.")
 			os.Exit(0)
 		default:
 			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
@@ -184,21 +508,81 @@ func handleCreate() {
 		fmt.Fprintln(os.Stderr, "❌ WireGuard config is empty")
 		os.Exit(1)
 	}
+	if location != "" && len(locations) > 0 {
+		fmt.Fprintln(os.Stderr, "❌ --location and --locations cannot be combined")
+		os.Exit(1)
+	}
+	if ha && len(locations) > 0 {
+		fmt.Fprintln(os.Stderr, "❌ --ha and --locations cannot be combined")
+		os.Exit(1)
+	}
+	if len(zones) > 0 && !ha {
+		fmt.Fprintln(os.Stderr, "❌ --zones requires --ha")
+		os.Exit(1)
+	}
+	sshSources, err := guard.ParseSSHSources(sshSources)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	tags, err := guard.ParseTags(tagArgs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
 
 	cfg := loadConfig()
+	if len(tags) > 0 && cfg.GetGuardProvider() != "azure" {
+		fmt.Fprintf(os.Stderr, "❌ --tag is not supported on %s\n", cfg.GetGuardProvider())
+		os.Exit(1)
+	}
 	prov := createProvider(cfg)
-	provisioner := guard.NewProvisioner(prov, cfg)
+	provisioner := newProvisioner(prov, cfg)
 
 	ctx := context.Background()
+	if len(sshSources) == 0 {
+		sshSources = defaultSSHSources(ctx)
+	}
+	if ha {
+		if _, ok := prov.(guard.RouteSwitcher); !ok {
+			fmt.Fprintf(os.Stderr, "❌ HA pairs are not supported on %s\n", cfg.GetGuardProvider())
+			os.Exit(1)
+		}
+		if location == "" {
+			location = cfg.Machine.Azure.Location
+		}
+		createPair(ctx, cfg, provisioner, guard.CreatePairRequest{
+			Location:       location,
+			Zones:          zones,
+			ConfigTemplate: wgConf,
+			MeshCIDRs:      meshCIDRs,
+			SSHSources:     sshSources,
+			Tags:           tags,
+		})
+		return
+	}
+	if len(locations) > 0 {
+		createGroup(ctx, cfg, provisioner, guard.CreateGroupRequest{
+			Locations:      locations,
+			ConfigTemplate: wgConf,
+			MeshCIDRs:      meshCIDRs,
+			SSHSources:     sshSources,
+			Tags:           tags,
+		})
+		return
+	}
 	g, err := provisioner.Provision(ctx, guard.CreateGuardRequest{
 		Location:      location,
 		WireGuardConf: wgConf,
 		MeshCIDRs:     meshCIDRs,
+		SSHSources:    sshSources,
+		Tags:          tags,
 	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "\n❌ Create failed: %s\n", err)
 		os.Exit(1)
 	}
+	recordGuardEvent(cfg, "create-guard", g.ID, g.Location)
 
 	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
 	fmt.Printf("✅ Guard created successfully!\n")
@@ -208,13 +592,104 @@ func handleCreate() {
 	fmt.Printf("   Private IP:  %s\n", g.PrivateIP)
 	fmt.Printf("   VNet:        %s\n", g.VNetID)
 	fmt.Printf("   Location:    %s\n", g.Location)
+	if len(g.Tags) > 0 {
+		fmt.Printf("   Tags:        %s\n", guard.FormatTags(g.Tags))
+	}
 	fmt.Println()
 	fmt.Printf("🔗 Peer a workload VNet:\n")
-	fmt.Printf("   morpheus-azureguard peer %s --vnet <workload-vnet-resource-id>\n\n", g.ID)
+	fmt.Printf("   %s peer %s --vnet <workload-vnet-resource-id>\n\n", commandName(), g.ID)
 	fmt.Printf("🔍 Check status:\n")
-	fmt.Printf("   morpheus-azureguard status %s\n\n", g.ID)
+	fmt.Printf("   %s status %s\n\n", commandName(), g.ID)
 	fmt.Printf("🗑️  Teardown:\n")
-	fmt.Printf("   morpheus-azureguard teardown %s\n", g.ID)
+	fmt.Printf("   %s teardown %s\n", commandName(), g.ID)
+}
+
+// defaultSSHSources returns the operator's public IP as the SSH source of
+// new guards, or none (any address) if it cannot be detected
+func defaultSSHSources(ctx context.Context) []string {
+	source, err := operatorSource(ctx)
+	if err != nil {
+		fmt.Printf("⚠️  %s; SSH to the guard will be allowed from any address\n", err)
+		fmt.Printf("   Restrict it with --ssh-allow-cidr, or later with: %s nsg <guard-id>\n", commandName())
+		return nil
+	}
+	return []string{source}
+}
+
+// operatorSource returns the public IPv4 address of this machine, as an
+// SSH source
+func operatorSource(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	result := httputil.CheckIPv4Connectivity(ctx)
+	if !result.Available {
+		return "", fmt.Errorf("could not detect your public IP: %v", result.Error)
+	}
+	return guard.ParseSSHSource(strings.TrimSpace(result.Address))
+}
+
+// createGroup creates guards in several locations as a group
+func createGroup(ctx context.Context, cfg *config.Config, provisioner *guard.Provisioner, req guard.CreateGroupRequest) {
+	group, guards, err := provisioner.ProvisionGroup(ctx, req)
+	for _, g := range guards {
+		recordGuardEvent(cfg, "create-guard", g.ID, g.Location+", group "+group)
+	}
+
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	if err != nil {
+		fmt.Printf("⚠️  Created %d of %d guards in group %s\n", len(guards), len(req.Locations), group)
+	} else {
+		fmt.Printf("✅ Guard group %s created successfully!\n", group)
+	}
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+	for _, g := range guards {
+		fmt.Printf("   %-35s  %-15s  %s\n", g.ID, g.PublicIP, g.Location)
+		fmt.Printf("     PublicKey = %s\n", g.PublicKey)
+	}
+	fmt.Println()
+	if len(guards) > 0 {
+		fmt.Printf("🗑️  Teardown:\n")
+		fmt.Printf("   %s teardown --group %s\n", commandName(), group)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n❌ Create failed: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// createPair creates the two guards of an HA pair
+func createPair(ctx context.Context, cfg *config.Config, provisioner *guard.Provisioner, req guard.CreatePairRequest) {
+	pair, guards, err := provisioner.ProvisionPair(ctx, req)
+	for _, g := range guards {
+		recordGuardEvent(cfg, "create-guard", g.ID, fmt.Sprintf("%s, %s of pair %s", g.Location, g.Role, pair))
+	}
+
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	if err != nil {
+		fmt.Printf("⚠️  Created %d of 2 guards of HA pair %s\n", len(guards), pair)
+	} else {
+		fmt.Printf("✅ HA pair %s created successfully!\n", pair)
+	}
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+	for _, g := range guards {
+		fmt.Printf("   %-35s  %-8s  %-15s  %s\n", g.ID, g.Role, g.PublicIP, g.PrivateIP)
+		fmt.Printf("     PublicKey = %s\n", g.PublicKey)
+	}
+	fmt.Println()
+	if len(guards) == 2 {
+		fmt.Printf("🔗 Peer a workload VNet with both guards (routes point at the primary):\n")
+		fmt.Printf("   %s peer %s --vnet <workload-vnet-resource-id> --subnet <subnet-resource-id>\n\n", commandName(), guards[0].ID)
+		fmt.Printf("🔀 Fail over when the primary is down:\n")
+		fmt.Printf("   %s failover %s --auto\n\n", commandName(), pair)
+	}
+	if len(guards) > 0 {
+		fmt.Printf("🗑️  Teardown:\n")
+		fmt.Printf("   %s teardown --group %s\n", commandName(), pair)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n❌ Create failed: %s\n", err)
+		os.Exit(1)
+	}
 }
 
 // ── status ──────────────────────────────────────────────────────────────────
@@ -235,6 +710,8 @@ func handleStatus() {
 		fmt.Fprintf(os.Stderr, "❌ Failed to get guard: %s\n", err)
 		os.Exit(1)
 	}
+	var rec *storage.Guard
+	updateGuardRecord(cfg, g, func(r *storage.Guard) { rec = r })
 
 	fmt.Printf("\n🛡️  Guard: %s\n", g.ID)
 	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
@@ -246,28 +723,123 @@ func handleStatus() {
 	if len(g.MeshCIDRs) > 0 {
 		fmt.Printf("   Mesh CIDRs:  %s\n", strings.Join(g.MeshCIDRs, ", "))
 	}
+	if g.PublicKey != "" {
+		fmt.Printf("   Public Key:  %s\n", g.PublicKey)
+	}
+	if g.Group != "" {
+		fmt.Printf("   Group:       %s\n", g.Group)
+	}
+	if g.Role != "" {
+		fmt.Printf("   HA Role:     %s\n", g.Role)
+	}
+	if len(g.Tags) > 0 {
+		fmt.Printf("   Tags:        %s\n", guard.FormatTags(g.Tags))
+	}
+	if g.Metadata[guard.MetaPriority] == guard.PrioritySpot {
+		price := "on-demand"
+		if p := g.Metadata[guard.MetaMaxPrice]; p != "" && p != "-1" {
+			price = "$" + p + "/h"
+		}
+		fmt.Printf("   Spot VM:     evicted VMs are %sd, max price %s\n", g.Metadata[guard.MetaEvictionPolicy], price)
+	}
 	fmt.Printf("   VNet:        %s\n", g.VNetID)
 	fmt.Printf("   RG:          %s\n", g.ResourceGroup)
-	if len(g.Peerings) > 0 {
+	if rec != nil && rec.CreatedBy != "" {
+		fmt.Printf("   Created:     %s by %s\n", rec.CreatedAt.Local().Format("2006-01-02 15:04"), rec.CreatedBy)
+	}
+	peerings := guard.Record(g).Peerings
+	if rec != nil {
+		peerings = rec.Peerings
+	}
+	if len(peerings) > 0 {
 		fmt.Printf("\n   Peerings:\n")
-		for _, p := range g.Peerings {
+		for _, p := range peerings {
 			fmt.Printf("     • %s -> %s\n", p.Name, p.RemoteVNetID)
+			if p.RouteTableID != "" {
+				fmt.Printf("       route table: %s\n", p.RouteTableID)
+			}
+			if p.CreatedBy != "" {
+				fmt.Printf("       peered %s by %s\n", p.CreatedAt.Local().Format("2006-01-02 15:04"), p.CreatedBy)
+			}
 		}
 	}
+	if rec != nil && len(rec.Configs) > 0 {
+		latest := rec.Configs[len(rec.Configs)-1]
+		fmt.Printf("\n   WireGuard config: %d recorded, latest %s (sha256 %s)\n",
+			len(rec.Configs), latest.Time.Local().Format("2006-01-02 15:04"), latest.SHA256[:12])
+		fmt.Printf("   💡 Show it with: %s configs %s --show %d\n", commandName(), g.ID, len(rec.Configs))
+	}
 	fmt.Println()
 }
 
 // ── list ────────────────────────────────────────────────────────────────────
 
 func handleList() {
+	discover := false
+	var tagArgs []string
+	for i := 2; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--discover":
+			discover = true
+		case "--tag":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --tag requires key=value")
+				os.Exit(1)
+			}
+			i++
+			tagArgs = append(tagArgs, os.Args[i])
+		case "--help", "-h":
+			fmt.Println("Usage: morpheus-azureguard list [--discover] [--tag <key=value>]...")
+			fmt.Println()
+			fmt.Println("Guards are listed from the registry. With --discover, or if the registry")
+			fmt.Println("has none, the cloud is scanned for guards and the registry updated.")
+			fmt.Println("With --tag, only guards with all the tags given are listed.")
+			os.Exit(0)
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+			os.Exit(1)
+		}
+	}
+	filter, err := guard.ParseTags(tagArgs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
 	cfg := loadConfig()
-	prov := createProvider(cfg)
+	provider := cfg.GetGuardProvider()
 
-	ctx := context.Background()
-	guards, err := prov.ListGuards(ctx)
+	var guards []*guard.Guard
+	reg, err := openRegistry()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "❌ Failed to list guards: %s\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "⚠️  Warning: cannot open the guard registry: %s\n", err)
+		discover = true
+	} else {
+		for _, rec := range reg.ListGuards() {
+			if rec.Provider == provider {
+				guards = append(guards, guard.FromRecord(rec))
+			}
+		}
+	}
+
+	if discover || len(guards) == 0 {
+		prov := createProvider(cfg)
+		guards, err = prov.ListGuards(context.Background())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Failed to list guards: %s\n", err)
+			os.Exit(1)
+		}
+		if reg != nil {
+			result, err := guard.SyncRegistry(reg, provider, guards)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "⚠️  Warning: guard registry not fully updated: %s\n", err)
+			}
+			if result.Added > 0 || result.Removed > 0 {
+				fmt.Printf("\n📒 Registry: %d guards added, %d no longer in %s removed\n", result.Added, result.Removed, provider)
+			}
+		}
+	} else {
+		fmt.Printf("\n💡 From the registry; rescan %s with: %s list --discover\n", provider, commandName())
 	}
 
 	if len(guards) == 0 {
@@ -275,11 +847,45 @@ func handleList() {
 		fmt.Println("Create one with: morpheus-azureguard create --config <wg0.conf>")
 		return
 	}
+	if len(filter) > 0 {
+		guards = slices.DeleteFunc(guards, func(g *guard.Guard) bool { return !guard.MatchTags(g, filter) })
+		if len(guards) == 0 {
+			fmt.Printf("\nNo guards tagged %s.\n", guard.FormatTags(filter))
+			return
+		}
+	}
 
 	fmt.Printf("\n🛡️  Guards (%d)\n", len(guards))
 	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	var groups []string
 	for _, g := range guards {
-		fmt.Printf("  %-25s  %-12s  %-15s  %s\n", g.ID, g.Status, g.PublicIP, g.Location)
+		if g.Group == "" {
+			fmt.Printf("  %-25s  %-12s  %-15s  %s", g.ID, g.Status, g.PublicIP, g.Location)
+			if len(g.Tags) > 0 {
+				fmt.Printf("  %s", guard.FormatTags(g.Tags))
+			}
+			fmt.Println()
+		} else if !slices.Contains(groups, g.Group) {
+			groups = append(groups, g.Group)
+		}
+	}
+	for _, group := range groups {
+		members := guard.GroupMembers(guards, group)
+		if members[0].Role != "" {
+			fmt.Printf("\n  HA pair %s:\n", group)
+		} else {
+			fmt.Printf("\n  Group %s:\n", group)
+		}
+		for _, g := range members {
+			fmt.Printf("    %-35s  %-12s  %-15s  %s", g.ID, g.Status, g.PublicIP, g.Location)
+			if g.Role != "" {
+				fmt.Printf("  %s", g.Role)
+			}
+			if len(g.Tags) > 0 {
+				fmt.Printf("  %s", guard.FormatTags(g.Tags))
+			}
+			fmt.Println()
+		}
 	}
 	fmt.Println()
 }
@@ -288,9 +894,17 @@ func handleList() {
 
 func handleTeardown() {
 	if len(os.Args) < 3 {
-		fmt.Fprintln(os.Stderr, "Usage: morpheus-azureguard teardown <guard-id>")
+		fmt.Fprintln(os.Stderr, "Usage: morpheus-azureguard teardown <guard-id> | --group <group-id>")
 		os.Exit(1)
 	}
+	if os.Args[2] == "--group" {
+		if len(os.Args) != 4 {
+			fmt.Fprintln(os.Stderr, "Usage: morpheus-azureguard teardown --group <group-id>")
+			os.Exit(1)
+		}
+		handleTeardownGroup(os.Args[3])
+		return
+	}
 
 	guardID := os.Args[2]
 	cfg := loadConfig()
@@ -310,6 +924,11 @@ func handleTeardown() {
 	fmt.Printf("   Location:  %s\n", g.Location)
 	fmt.Printf("   Public IP: %s\n", g.PublicIP)
 	fmt.Printf("   RG:        %s\n", g.ResourceGroup)
+	if tables, err := prov.ListRouteTables(ctx, guardID); err == nil {
+		for _, rt := range tables {
+			fmt.Printf("   Routes:    %s (%s)\n", rt.Name, rt.ResourceGroup)
+		}
+	}
 	fmt.Println()
 	fmt.Print("Type 'yes' to confirm deletion: ")
 
@@ -320,11 +939,12 @@ func handleTeardown() {
 		return
 	}
 
-	provisioner := guard.NewProvisioner(prov, cfg)
+	provisioner := newProvisioner(prov, cfg)
 	if err := provisioner.Teardown(ctx, guardID); err != nil {
 		fmt.Fprintf(os.Stderr, "\n❌ Teardown failed: %s\n", err)
 		os.Exit(1)
 	}
+	recordGuardEvent(cfg, "teardown-guard", guardID, "")
 
 	fmt.Println()
 	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
@@ -332,6 +952,52 @@ func handleTeardown() {
 	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
 }
 
+// handleTeardownGroup deletes all guards of a group
+func handleTeardownGroup(group string) {
+	cfg := loadConfig()
+	prov := createProvider(cfg)
+	ctx := context.Background()
+
+	guards, err := prov.ListGuards(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to list guards: %s\n", err)
+		os.Exit(1)
+	}
+	members := guard.GroupMembers(guards, group)
+	if len(members) == 0 {
+		fmt.Fprintf(os.Stderr, "❌ No guards in group %s\n", group)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\n⚠️  About to permanently delete %d guards of group %s:\n", len(members), group)
+	for _, g := range members {
+		fmt.Printf("   %-35s  %-15s  %s (RG %s)\n", g.ID, g.PublicIP, g.Location, g.ResourceGroup)
+	}
+	fmt.Println()
+	fmt.Print("Type 'yes' to confirm deletion: ")
+
+	var response string
+	fmt.Scanln(&response)
+	if response != "yes" {
+		fmt.Println("\n✅ Teardown cancelled.")
+		return
+	}
+
+	provisioner := newProvisioner(prov, cfg)
+	if err := provisioner.TeardownGroup(ctx, group); err != nil {
+		fmt.Fprintf(os.Stderr, "\n❌ Teardown failed: %s\n", err)
+		os.Exit(1)
+	}
+	for _, g := range members {
+		recordGuardEvent(cfg, "teardown-guard", g.ID, "group "+group)
+	}
+
+	fmt.Println()
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Printf("✅ Guard group %s deleted successfully!\n", group)
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+}
+
 // ── peer ────────────────────────────────────────────────────────────────────
 
 func handlePeer() {
@@ -342,6 +1008,7 @@ func handlePeer() {
 
 	guardID := os.Args[2]
 	var remoteVNetID, remoteSubnetID string
+	allSubnets := false
 
 	for i := 3; i < len(os.Args); i++ {
 		switch os.Args[i] {
@@ -359,8 +1026,14 @@ func handlePeer() {
 			}
 			i++
 			remoteSubnetID = os.Args[i]
+		case "--all-subnets":
+			allSubnets = true
 		case "--help", "-h":
-			fmt.Println("Usage: morpheus-azureguard peer <guard-id> --vnet <resource-id> [--subnet <resource-id>]")
+			fmt.Println("Usage: morpheus-azureguard peer <guard-id> --vnet <resource-id> [--subnet <resource-id> | --all-subnets]")
+			fmt.Println()
+			fmt.Println("With --all-subnets, one route table for the mesh CIDRs is associated with")
+			fmt.Println("every subnet of the VNet. Subnets of Azure services and subnets that have")
+			fmt.Println("another route table are left alone; run it again to route new subnets.")
 			os.Exit(0)
 		default:
 			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
@@ -372,11 +1045,21 @@ func handlePeer() {
 		fmt.Fprintln(os.Stderr, "❌ --vnet is required")
 		os.Exit(1)
 	}
+	if allSubnets && remoteSubnetID != "" {
+		fmt.Fprintln(os.Stderr, "❌ --subnet and --all-subnets cannot be combined")
+		os.Exit(1)
+	}
 
 	cfg := loadConfig()
 	prov := createProvider(cfg)
 	ctx := context.Background()
 
+	router, ok := prov.(guard.SubnetRouter)
+	if allSubnets && !ok {
+		fmt.Fprintf(os.Stderr, "❌ --all-subnets is not supported on %s\n", cfg.GetGuardProvider())
+		os.Exit(1)
+	}
+
 	// Get guard info from Azure
 	g, err := prov.GetGuard(ctx, guardID)
 	if err != nil {
@@ -384,26 +1067,114 @@ func handlePeer() {
 		os.Exit(1)
 	}
 
-	fmt.Printf("\n🔗 Peering guard %s to workload VNet\n", guardID)
-	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	// Both guards of an HA pair are peered; the routes point at the active one
+	var standby *guard.Guard
+	if g.Role != "" {
+		guards, err := prov.ListGuards(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Failed to list guards: %s\n", err)
+			os.Exit(1)
+		}
+		if g, standby, err = guard.PairMembers(ctx, prov, guards, g.Group); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	peerGuard(ctx, cfg, prov, g, remoteVNetID, remoteSubnetID)
+	if allSubnets {
+		routeAllSubnets(ctx, cfg, router, g, remoteVNetID)
+	}
+	if standby != nil {
+		peerGuard(ctx, cfg, prov, standby, remoteVNetID, "")
+	}
+}
+
+// routeAllSubnets routes the mesh CIDRs of every subnet of a peered VNet
+// through a guard, and reports what it touched
+func routeAllSubnets(ctx context.Context, cfg *config.Config, router guard.SubnetRouter, g *guard.Guard, remoteVNetID string) {
+	fmt.Printf("🧭 Routing mesh CIDRs of all subnets through %s\n", g.ID)
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	report, err := router.RouteAllSubnets(ctx, guard.PeerRequest{
+		GuardID:        g.ID,
+		GuardVNetID:    g.VNetID,
+		RemoteVNetID:   remoteVNetID,
+		PeeringName:    fmt.Sprintf("%s-peer", g.ID),
+		GuardPrivateIP: g.PrivateIP,
+		MeshCIDRs:      g.MeshCIDRs,
+		Tags:           g.Tags,
+	})
+	if report == nil {
+		fmt.Fprintf(os.Stderr, "\n❌ Routing failed: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println()
+	action := "Created"
+	if report.RouteTableExisted {
+		action = "Updated"
+	}
+	fmt.Printf("   %s route table %s: %s\n", action, report.RouteTableName, strings.Join(g.MeshCIDRs, ", "))
+	for _, s := range report.Subnets {
+		icon := "✅"
+		switch s.Action {
+		case guard.SubnetUnchanged:
+			icon = "➖"
+		case guard.SubnetSkipped:
+			icon = "⚠️ "
+		case guard.SubnetFailed:
+			icon = "❌"
+		}
+		fmt.Printf("   %s %-24s %-10s %s\n", icon, s.Name, s.Action, s.Detail)
+	}
+	fmt.Println()
+	routed := report.Count(guard.SubnetAssociated) + report.Count(guard.SubnetUnchanged)
+	recordGuardEvent(cfg, "route-subnets", g.ID, fmt.Sprintf("%s, %d of %d subnets", remoteVNetID, routed, len(report.Subnets)))
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Routing failed: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("   ✅ %d of %d subnets routed through the guard\n\n", routed, len(report.Subnets))
+}
+
+// peerGuard peers a guard with a workload VNet and, with a subnet, routes
+// the subnet's mesh traffic through the guard
+func peerGuard(ctx context.Context, cfg *config.Config, prov guard.GuardProvider, g *guard.Guard, remoteVNetID, remoteSubnetID string) {
+	fmt.Printf("\n🔗 Peering guard %s to workload VNet\n", g.ID)
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
 	fmt.Printf("   Guard VNet:  %s\n", g.VNetID)
 	fmt.Printf("   Remote VNet: %s\n", remoteVNetID)
+	if g.Role != "" {
+		fmt.Printf("   HA Role:     %s\n", g.Role)
+	}
 	fmt.Println()
 
-	peeringName := fmt.Sprintf("%s-peer", guardID)
-	err = prov.PeerNetwork(ctx, guard.PeerRequest{
-		GuardID:        guardID,
+	peeringName := fmt.Sprintf("%s-peer", g.ID)
+	err := prov.PeerNetwork(ctx, guard.PeerRequest{
+		GuardID:        g.ID,
 		GuardVNetID:    g.VNetID,
 		RemoteVNetID:   remoteVNetID,
 		PeeringName:    peeringName,
 		GuardPrivateIP: g.PrivateIP,
 		MeshCIDRs:      g.MeshCIDRs,
 		SubnetID:       remoteSubnetID,
+		Tags:           g.Tags,
 	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "\n❌ Peering failed: %s\n", err)
 		os.Exit(1)
 	}
+	recordGuardEvent(cfg, "peer", g.ID, remoteVNetID)
+	updateGuardRecord(cfg, g, func(rec *storage.Guard) {
+		rec.SetPeering(storage.GuardPeering{
+			Name:         peeringName,
+			RemoteVNetID: remoteVNetID,
+			SubnetID:     remoteSubnetID,
+			CreatedBy:    operator(cfg),
+			CreatedAt:    time.Now().UTC(),
+		})
+	})
 
 	fmt.Printf("   ✅ Peering established\n")
 	if len(g.MeshCIDRs) > 0 && remoteSubnetID != "" {
@@ -411,3 +1182,760 @@ func handlePeer() {
 	}
 	fmt.Println()
 }
+
+// ── unpeer ──────────────────────────────────────────────────────────────────
+
+func handleUnpeer() {
+	if len(os.Args) < 3 || strings.HasPrefix(os.Args[2], "-") {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus-azureguard unpeer <guard-id> --vnet <resource-id>")
+		os.Exit(1)
+	}
+
+	guardID := os.Args[2]
+	var remoteVNetID string
+
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--vnet":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --vnet requires a resource ID")
+				os.Exit(1)
+			}
+			i++
+			remoteVNetID = os.Args[i]
+		case "--help", "-h":
+			fmt.Println("Usage: morpheus-azureguard unpeer <guard-id> --vnet <resource-id>")
+			os.Exit(0)
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+			os.Exit(1)
+		}
+	}
+
+	if remoteVNetID == "" {
+		fmt.Fprintln(os.Stderr, "❌ --vnet is required")
+		os.Exit(1)
+	}
+
+	cfg := loadConfig()
+	prov := createProvider(cfg)
+	ctx := context.Background()
+
+	g, err := prov.GetGuard(ctx, guardID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Guard not found: %s\n", err)
+		os.Exit(1)
+	}
+
+	var peeringName string
+	for _, p := range g.Peerings {
+		if strings.EqualFold(p.RemoteVNetID, remoteVNetID) {
+			peeringName = p.Name
+		}
+	}
+	if peeringName == "" {
+		fmt.Fprintf(os.Stderr, "❌ Guard %s is not peered with %s\n", guardID, remoteVNetID)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\n✂️  Removing peering %s\n", peeringName)
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	if err := prov.UnpeerNetwork(ctx, guardID, peeringName); err != nil {
+		fmt.Fprintf(os.Stderr, "\n❌ Unpeering failed: %s\n", err)
+		os.Exit(1)
+	}
+	recordGuardEvent(cfg, "unpeer", guardID, remoteVNetID)
+	updateGuardRecord(cfg, g, func(rec *storage.Guard) { rec.RemovePeering(remoteVNetID) })
+	fmt.Printf("   ✅ Peering removed\n")
+	fmt.Println()
+}
+
+// ── failover ────────────────────────────────────────────────────────────────
+
+func handleFailover() {
+	usage := "Usage: morpheus-azureguard failover <pair-id> [--auto] [--client <path>]"
+	if len(os.Args) < 3 || strings.HasPrefix(os.Args[2], "-") {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	pair := os.Args[2]
+	var opts guard.FailoverOptions
+
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--auto":
+			opts.Auto = true
+		case "--client":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --client requires a path")
+				os.Exit(1)
+			}
+			i++
+			data, err := os.ReadFile(os.Args[i])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "❌ Failed to read client config: %s\n", err)
+				os.Exit(1)
+			}
+			if opts.Client, err = guard.ParseClientConfig(string(data)); err != nil {
+				fmt.Fprintf(os.Stderr, "❌ Invalid client config: %s\n", err)
+				os.Exit(1)
+			}
+		case "--help", "-h":
+			fmt.Println(usage)
+			fmt.Println()
+			fmt.Println("The route tables of the active guard are pointed at the standby, if the")
+			fmt.Println("standby is healthy: its VM runs and, with --client, a handshake succeeds.")
+			fmt.Println("With --auto, nothing changes while the active guard is healthy.")
+			os.Exit(0)
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+			os.Exit(1)
+		}
+	}
+
+	cfg := loadConfig()
+	prov := createProvider(cfg)
+	ctx := context.Background()
+
+	guards, err := prov.ListGuards(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to list guards: %s\n", err)
+		os.Exit(1)
+	}
+	active, standby, err := guard.PairMembers(ctx, prov, guards, pair)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	// ListGuards does not read peerings, which Failover checks
+	if g, err := prov.GetGuard(ctx, active.ID); err == nil {
+		active = g
+	}
+	if g, err := prov.GetGuard(ctx, standby.ID); err == nil {
+		standby = g
+	}
+
+	fmt.Printf("\n🔀 Failover of HA pair %s\n", pair)
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Printf("   Active:   %-35s  %s (%s)\n", active.ID, active.PrivateIP, active.Role)
+	fmt.Printf("   Standby:  %-35s  %s (%s)\n", standby.ID, standby.PrivateIP, standby.Role)
+	fmt.Println()
+
+	result, err := guard.Failover(ctx, prov, active, standby, opts)
+	if result != nil {
+		for _, t := range result.Tables {
+			fmt.Printf("   ✅ %s now routes via %s\n", t.Name, standby.PrivateIP)
+		}
+	}
+	if err != nil {
+		if result != nil && len(result.Tables) > 0 {
+			recordGuardEvent(cfg, "failover-partial", standby.ID, fmt.Sprintf("from %s: %s", active.ID, err))
+		}
+		fmt.Fprintf(os.Stderr, "\n❌ Failover failed: %s\n", err)
+		os.Exit(1)
+	}
+	if result.Skipped != "" {
+		fmt.Printf("   ➖ Nothing to do: %s\n\n", result.Skipped)
+		return
+	}
+	recordGuardEvent(cfg, "failover", standby.ID, fmt.Sprintf("from %s, %d route tables", active.ID, len(result.Tables)))
+
+	fmt.Println()
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Printf("✅ %s is now the active guard of %s\n", standby.ID, pair)
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Printf("💡 Fail back once %s is healthy again with: %s failover %s\n", active.ID, commandName(), pair)
+}
+
+// ── rotate ──────────────────────────────────────────────────────────────────
+
+func handleRotate() {
+	usage := "Usage: morpheus-azureguard rotate <guard-id> --config <path|-> [--client <path>] [--ssh] [--timeout <duration>]"
+	if len(os.Args) < 3 || strings.HasPrefix(os.Args[2], "-") {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	guardID := os.Args[2]
+	var configPath, clientPath string
+	useSSH := false
+	var opts guard.RotateOptions
+
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--config":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --config requires a path or '-' for stdin")
+				os.Exit(1)
+			}
+			i++
+			configPath = os.Args[i]
+		case "--client":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --client requires a path")
+				os.Exit(1)
+			}
+			i++
+			clientPath = os.Args[i]
+		case "--ssh":
+			useSSH = true
+		case "--timeout":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --timeout requires a duration, e.g. 1m")
+				os.Exit(1)
+			}
+			i++
+			d, err := time.ParseDuration(os.Args[i])
+			if err != nil || d <= 0 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid timeout: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			opts.Timeout = d
+		case "--help", "-h":
+			fmt.Println(usage)
+			fmt.Println()
+			fmt.Println("The current config is kept as /etc/wireguard/wg0.conf.prev and restored")
+			fmt.Println("if wg-quick fails to start or no handshake succeeds within the timeout.")
+			os.Exit(0)
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+			os.Exit(1)
+		}
+	}
+
+	if configPath == "" {
+		fmt.Fprintln(os.Stderr, "❌ --config is required")
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+	var data []byte
+	var err error
+	if configPath == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(configPath)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to read config: %s\n", err)
+		os.Exit(1)
+	}
+	wgConf := string(data)
+	if strings.TrimSpace(wgConf) == "" {
+		fmt.Fprintln(os.Stderr, "❌ WireGuard config is empty")
+		os.Exit(1)
+	}
+	if clientPath != "" {
+		data, err := os.ReadFile(clientPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Failed to read client config: %s\n", err)
+			os.Exit(1)
+		}
+		if opts.Client, err = guard.ParseClientConfig(string(data)); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Invalid client config: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	cfg := loadConfig()
+	prov := createProvider(cfg)
+	ctx := context.Background()
+
+	g, err := prov.GetGuard(ctx, guardID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Guard not found: %s\n", err)
+		os.Exit(1)
+	}
+
+	run, method := guardRunner(cfg, prov, g, useSSH)
+
+	fmt.Printf("\n🔄 Rotating the WireGuard config of %s\n", guardID)
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Printf("   Guard:  %s (%s)\n", g.PublicIP, g.Location)
+	fmt.Printf("   Via:    %s\n", method)
+	fmt.Println()
+
+	if err := guard.Rotate(ctx, run, g, wgConf, opts); err != nil {
+		recordGuardEvent(cfg, "rotate-failed", guardID, strings.SplitN(err.Error(), "\n", 2)[0])
+		fmt.Fprintf(os.Stderr, "❌ Rotation failed: %s\n", err)
+		os.Exit(1)
+	}
+
+	record := guard.ConfigRecord(wgConf, operator(cfg))
+	recordGuardEvent(cfg, "rotate", guardID, "sha256 "+record.SHA256[:12])
+	updateGuardRecord(cfg, g, func(rec *storage.Guard) {
+		rec.AddConfig(record)
+		if key := guard.InterfacePublicKey(wgConf); key != "" {
+			rec.PublicKey = key
+		}
+	})
+
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Printf("✅ Guard %s is running the new config\n", guardID)
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	if key := guard.InterfacePublicKey(wgConf); key != "" && key != g.PublicKey {
+		fmt.Printf("\n   PublicKey = %s\n", key)
+		fmt.Printf("💡 The guard has a new key: update it in its peers' configs\n")
+	}
+}
+
+// guardRunner returns how to run scripts on a guard, and a description of
+// it: Azure Run Command, or SSH if asked for or on other clouds
+func guardRunner(cfg *config.Config, prov guard.GuardProvider, g *guard.Guard, useSSH bool) (guard.RunFunc, string) {
+	provider := cfg.GetGuardProvider()
+	if useSSH || provider != "azure" {
+		user := guard.SSHUser(provider)
+		return sshRunner(cfg, g, user), fmt.Sprintf("SSH as %s@%s", user, g.PublicIP)
+	}
+	run := func(ctx context.Context, script string) (string, error) {
+		return prov.RunCommand(ctx, g.ServerID, script)
+	}
+	return run, "Azure Run Command"
+}
+
+// sshRunner runs scripts on a guard over SSH as user, with root rights
+// through sudo
+func sshRunner(cfg *config.Config, g *guard.Guard, user string) guard.RunFunc {
+	identity := sshutil.GetSSHPrivateKeyForPublicKey(cfg.GetSSHKeyPath())
+	if identity == "" {
+		identity = sshutil.DetectSSHPrivateKeyPath()
+	}
+	return func(ctx context.Context, script string) (string, error) {
+		var out bytes.Buffer
+		command := "sh -s"
+		if user != "root" {
+			command = "sudo sh -s"
+		}
+		results := sshutil.RunParallel(ctx, []sshutil.Target{{Name: g.ID, Addr: g.PublicIP}}, command, sshutil.ExecOptions{
+			User:         user,
+			IdentityFile: identity,
+			Timeout:      5 * time.Minute,
+			Stdout:       &out,
+			Stderr:       &out,
+			Stdin:        []byte(script),
+		})
+		// RunParallel prefixes each line with the target's name
+		output := strings.ReplaceAll(out.String(), "["+g.ID+"] ", "")
+		if err := results[0].Err; err != nil {
+			return output, fmt.Errorf("%w: %s", err, strings.TrimSpace(output))
+		}
+		return output, nil
+	}
+}
+
+// ── configs ─────────────────────────────────────────────────────────────────
+
+func handleConfigs() {
+	if len(os.Args) < 3 || strings.HasPrefix(os.Args[2], "-") {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus-azureguard configs <guard-id> [--show <n>]")
+		os.Exit(1)
+	}
+
+	guardID := os.Args[2]
+	show := 0
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--show":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --show requires a config number")
+				os.Exit(1)
+			}
+			i++
+			n, err := strconv.Atoi(os.Args[i])
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid config number: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			show = n
+		case "--help", "-h":
+			fmt.Println("Usage: morpheus-azureguard configs <guard-id> [--show <n>]")
+			os.Exit(0)
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+			os.Exit(1)
+		}
+	}
+
+	reg, err := openRegistry()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to open the guard registry: %s\n", err)
+		os.Exit(1)
+	}
+	rec, err := reg.GetGuard(guardID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Guard %s is not in the registry\n", guardID)
+		fmt.Fprintf(os.Stderr, "💡 Guards created elsewhere are added by: %s list --discover\n", commandName())
+		os.Exit(1)
+	}
+
+	if show > 0 {
+		if show > len(rec.Configs) {
+			fmt.Fprintf(os.Stderr, "❌ Guard %s has %d recorded configs\n", guardID, len(rec.Configs))
+			os.Exit(1)
+		}
+		fmt.Print(rec.Configs[show-1].Config)
+		return
+	}
+
+	if len(rec.Configs) == 0 {
+		fmt.Printf("\nNo WireGuard configs recorded for %s.\n", guardID)
+		return
+	}
+	fmt.Printf("\n📜 WireGuard configs of %s (%d)\n", guardID, len(rec.Configs))
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	for i, c := range rec.Configs {
+		fmt.Printf("  %2d  %s  %s  %s\n", i+1, c.Time.Local().Format("2006-01-02 15:04:05"), c.SHA256[:12], c.Operator)
+	}
+	fmt.Println()
+}
+
+// ── routes ──────────────────────────────────────────────────────────────────
+
+func handleRoutes() {
+	if len(os.Args) < 3 || os.Args[2] != "list" {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus-azureguard routes list [guard-id] [--json]")
+		os.Exit(1)
+	}
+
+	var guardID string
+	jsonOutput := false
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--json":
+			jsonOutput = true
+		case "--help", "-h":
+			fmt.Println("Usage: morpheus-azureguard routes list [guard-id] [--json]")
+			os.Exit(0)
+		default:
+			if strings.HasPrefix(os.Args[i], "-") || guardID != "" {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			guardID = os.Args[i]
+		}
+	}
+
+	cfg := loadConfig()
+	prov := createProvider(cfg)
+	ctx := context.Background()
+
+	tables, err := prov.ListRouteTables(ctx, guardID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to list route tables: %s\n", err)
+		os.Exit(1)
+	}
+
+	if jsonOutput {
+		if tables == nil {
+			tables = []guard.RouteTable{}
+		}
+		data, _ := json.MarshalIndent(tables, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	if len(tables) == 0 {
+		fmt.Println("\nNo route tables found.")
+		return
+	}
+
+	fmt.Printf("\n🧭 Route tables (%d)\n", len(tables))
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	for _, rt := range tables {
+		fmt.Printf("  %-30s  %-25s  %s\n", rt.Name, rt.GuardID, rt.ResourceGroup)
+		if len(rt.Routes) > 0 {
+			fmt.Printf("    routes:  %s\n", strings.Join(rt.Routes, ", "))
+		}
+		if len(rt.Subnets) == 0 {
+			fmt.Printf("    subnets: none (not associated)\n")
+		}
+		for _, subnet := range rt.Subnets {
+			fmt.Printf("    subnet:  %s\n", subnet)
+		}
+	}
+	fmt.Println()
+}
+
+// ── test ────────────────────────────────────────────────────────────────────
+
+func handleTest() {
+	if len(os.Args) < 3 || strings.HasPrefix(os.Args[2], "-") {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus-azureguard test <guard-id> [--config <path|->] [--probe <resource-id>] [--ping <ips>]")
+		os.Exit(1)
+	}
+
+	guardID := os.Args[2]
+	var configPath string
+	var opts guard.TestOptions
+
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--config":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --config requires a path or '-' for stdin")
+				os.Exit(1)
+			}
+			i++
+			configPath = os.Args[i]
+		case "--probe":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --probe requires a VM resource ID")
+				os.Exit(1)
+			}
+			i++
+			opts.ProbeVM = os.Args[i]
+		case "--ping":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --ping requires comma-separated IPs")
+				os.Exit(1)
+			}
+			i++
+			opts.Ping = strings.Split(os.Args[i], ",")
+		case "--help", "-h":
+			fmt.Println("Usage: morpheus-azureguard test <guard-id> [--config <path|->] [--probe <resource-id>] [--ping <ips>]")
+			os.Exit(0)
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+			os.Exit(1)
+		}
+	}
+
+	if len(opts.Ping) > 0 && opts.ProbeVM == "" {
+		fmt.Fprintln(os.Stderr, "❌ --ping requires --probe")
+		os.Exit(1)
+	}
+
+	if configPath != "" {
+		var data []byte
+		var err error
+		if configPath == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(configPath)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Failed to read client config: %s\n", err)
+			os.Exit(1)
+		}
+		opts.Client, err = guard.ParseClientConfig(string(data))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Invalid client config: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	cfg := loadConfig()
+	prov := createProvider(cfg)
+	ctx := context.Background()
+
+	g, err := prov.GetGuard(ctx, guardID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Guard not found: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\n🧪 Testing guard %s\n", guardID)
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	results := guard.Test(ctx, prov, g, opts)
+	for _, r := range results {
+		icon := "✅"
+		switch r.Status {
+		case guard.TestFail:
+			icon = "❌"
+		case guard.TestWarn:
+			icon = "⚠️ "
+		case guard.TestSkip:
+			icon = "➖"
+		}
+		fmt.Printf("   %s %-22s %s\n", icon, r.Name, r.Detail)
+	}
+	fmt.Println()
+
+	if guard.Failed(results) {
+		fmt.Fprintln(os.Stderr, "❌ Guard connectivity test failed")
+		os.Exit(1)
+	}
+	fmt.Println("✅ Guard connectivity OK")
+}
+
+// ── check ───────────────────────────────────────────────────────────────────
+
+func handleCheck() {
+	usage := "Usage: morpheus-azureguard check <guard-id> [--ssh]"
+	if len(os.Args) < 3 || strings.HasPrefix(os.Args[2], "-") {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	guardID := os.Args[2]
+	useSSH := false
+
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--ssh":
+			useSSH = true
+		case "--help", "-h":
+			fmt.Println(usage)
+			os.Exit(0)
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+			os.Exit(1)
+		}
+	}
+
+	cfg := loadConfig()
+	prov := createProvider(cfg)
+	ctx := context.Background()
+
+	g, err := prov.GetGuard(ctx, guardID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Guard not found: %s\n", err)
+		os.Exit(1)
+	}
+	run, method := guardRunner(cfg, prov, g, useSSH)
+
+	fmt.Printf("\n🩺 Checking guard %s\n", guardID)
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Printf("   Handshakes via %s\n\n", method)
+	results := guard.Check(ctx, prov, g, run)
+	for _, r := range results {
+		icon := "✅"
+		switch r.Status {
+		case guard.TestFail:
+			icon = "❌"
+		case guard.TestWarn:
+			icon = "⚠️ "
+		case guard.TestSkip:
+			icon = "➖"
+		}
+		fmt.Printf("   %s %-22s %s\n", icon, r.Name, r.Detail)
+	}
+	fmt.Println()
+
+	if guard.Failed(results) {
+		fmt.Fprintln(os.Stderr, "❌ Guard check failed")
+		os.Exit(1)
+	}
+	fmt.Println("✅ Guard checks OK")
+}
+
+// ── nsg ─────────────────────────────────────────────────────────────────────
+
+func handleNSG() {
+	usage := "Usage: morpheus-azureguard nsg <guard-id> [--allow [cidr]]... [--revoke <cidr>]..."
+	if len(os.Args) < 3 || strings.HasPrefix(os.Args[2], "-") {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	guardID := os.Args[2]
+	var allow, revoke []string
+	allowMine := false
+
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--allow":
+			// Without a CIDR, the operator's public IP
+			if i+1 < len(os.Args) && !strings.HasPrefix(os.Args[i+1], "-") {
+				i++
+				allow = append(allow, os.Args[i])
+			} else {
+				allowMine = true
+			}
+		case "--revoke":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --revoke requires a CIDR or IP address")
+				os.Exit(1)
+			}
+			i++
+			revoke = append(revoke, os.Args[i])
+		case "--help", "-h":
+			fmt.Println(usage)
+			fmt.Println()
+			fmt.Println("Lists the addresses SSH to the guard is allowed from, or changes them.")
+			fmt.Println("--allow without a CIDR allows your public IP; '*' is any address.")
+			os.Exit(0)
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+			os.Exit(1)
+		}
+	}
+	allow, err := guard.ParseSSHSources(allow)
+	if err == nil {
+		revoke, err = guard.ParseSSHSources(revoke)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	cfg := loadConfig()
+	prov := createProvider(cfg)
+	ctx := context.Background()
+
+	access, ok := prov.(guard.SSHAccess)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "❌ Changing SSH sources is not supported on %s\n", cfg.GetGuardProvider())
+		os.Exit(1)
+	}
+	g, err := prov.GetGuard(ctx, guardID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Guard not found: %s\n", err)
+		os.Exit(1)
+	}
+	if allowMine {
+		source, err := operatorSource(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			fmt.Fprintln(os.Stderr, "💡 Pass the address to allow: --allow <cidr>")
+			os.Exit(1)
+		}
+		allow = append(allow, source)
+	}
+
+	current, err := access.SSHSources(ctx, g)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to get SSH sources: %s\n", err)
+		os.Exit(1)
+	}
+	sources := current
+	if len(allow) > 0 || len(revoke) > 0 {
+		sources = nil
+		for _, s := range current {
+			if !slices.Contains(revoke, s) {
+				sources = append(sources, s)
+			}
+		}
+		for _, s := range allow {
+			if !slices.Contains(sources, s) {
+				sources = append(sources, s)
+			}
+		}
+		if len(sources) == 0 {
+			fmt.Fprintln(os.Stderr, "❌ No SSH sources would be left, blocking SSH to the guard")
+			fmt.Fprintln(os.Stderr, "💡 Allow another address in the same command: --allow [cidr]")
+			os.Exit(1)
+		}
+		if !slices.Equal(sources, current) {
+			if err := access.SetSSHSources(ctx, g, sources); err != nil {
+				fmt.Fprintf(os.Stderr, "❌ Failed to update SSH sources: %s\n", err)
+				os.Exit(1)
+			}
+			recordGuardEvent(cfg, "ssh-sources", g.ID, strings.Join(sources, ","))
+			fmt.Printf("✅ SSH sources of %s updated\n\n", g.ID)
+		}
+	}
+
+	fmt.Printf("🔒 SSH to %s is allowed from:\n", g.ID)
+	for _, s := range sources {
+		if s == guard.AnySource {
+			s += " (any address)"
+		}
+		fmt.Printf("   %s\n", s)
+	}
+	if slices.Contains(sources, guard.AnySource) {
+		fmt.Println()
+		fmt.Printf("💡 Restrict it to your public IP:\n")
+		fmt.Printf("   %s nsg %s --allow --revoke '*'\n", commandName(), g.ID)
+	}
+}
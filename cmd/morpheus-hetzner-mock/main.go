@@ -0,0 +1,42 @@
+// Command morpheus-hetzner-mock serves the in-memory Hetzner Cloud API mock,
+// for running morpheus without a real API token
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/nimsforest/morpheus/internal/hetznermock"
+)
+
+func main() {
+	listen := flag.String("listen", "127.0.0.1:8765", "address to listen on")
+	token := flag.String("token", "", "only accept this API token (default: any)")
+	zone := flag.String("zone", "", "create a DNS zone on startup")
+	chaos := flag.Bool("chaos", false, "randomly inject latency, rate limits and failures")
+	chaosSettings := flag.String("chaos-settings", "", "chaos settings, e.g. latency=500ms,rate-limit=0.2,failure=0.1,seed=42 (implies -chaos)")
+	flag.Parse()
+
+	api := hetznermock.NewAPI()
+	api.Token = *token
+	if *zone != "" {
+		api.AddZone(*zone)
+	}
+	if *chaos || *chaosSettings != "" {
+		c, err := hetznermock.ParseChaos(*chaosSettings)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			os.Exit(2)
+		}
+		api.SetChaos(c)
+		// Printed with the seed picked, so a failing CI run can be replayed
+		fmt.Fprintf(os.Stderr, "🌪️  Chaos on: %s\n", api.Chaos())
+	}
+
+	fmt.Fprintf(os.Stderr, "🧪 Hetzner Cloud API mock listening on %s\n", *listen)
+	fmt.Fprintf(os.Stderr, "💡 export HCLOUD_ENDPOINT=http://%s/v1\n", *listen)
+	log.Fatal(http.ListenAndServe(*listen, api))
+}
@@ -4,8 +4,10 @@ package cli
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/nimsforest/morpheus/internal/commands"
+	"github.com/nimsforest/morpheus/pkg/config"
 )
 
 // Version is set at build time via -ldflags
@@ -13,6 +15,7 @@ var Version = "dev"
 
 // Run executes the CLI with the given arguments.
 func Run() {
+	parseGlobalFlags()
 	if len(os.Args) < 2 {
 		PrintHelp()
 		os.Exit(1)
@@ -22,17 +25,83 @@ func Run() {
 
 	switch command {
 	case "plant":
-		commands.HandlePlant()
+		commands.HandlePlant(Version)
 	case "list":
 		commands.HandleList()
 	case "status":
 		commands.HandleStatus()
 	case "teardown":
 		commands.HandleTeardown()
+	case "history":
+		commands.HandleHistory()
+	case "diff":
+		commands.HandleDiff()
+	case "refresh":
+		commands.HandleRefresh()
+	case "gc":
+		commands.HandleGC()
+	case "import":
+		commands.HandleImport()
+	case "billing":
+		commands.HandleBilling()
+	case "cost":
+		commands.HandleCost()
+	case "snapshot":
+		commands.HandleSnapshot()
+	case "restore":
+		commands.HandleRestore()
 	case "grow":
 		commands.HandleGrow()
+	case "scale":
+		commands.HandleScale()
+	case "failover":
+		commands.HandleFailover()
+	case "health":
+		commands.HandleHealth()
+	case "wait":
+		commands.HandleWait()
+	case "watch":
+		commands.HandleWatch()
+	case "metadata":
+		commands.HandleMetadata()
+	case "node":
+		commands.HandleNode()
+	case "replace-node":
+		commands.HandleReplaceNode()
+	case "resize":
+		commands.HandleResize()
+	case "pause":
+		commands.HandlePause()
+	case "resume":
+		commands.HandleResume()
+	case "provenance":
+		commands.HandleProvenance()
+	case "exec":
+		commands.HandleExec()
+	case "cp":
+		commands.HandleCp()
+	case "nats":
+		commands.HandleNATS()
+	case "mesh":
+		commands.HandleMesh()
+	case "keys":
+		commands.HandleKeys()
+	case "project":
+		commands.HandleProject()
+	case "worker":
+		commands.HandleWorker()
+	case "blueprint":
+		commands.HandleBlueprint()
+	case "cloudinit":
+		commands.HandleCloudInit()
+	case "providers":
+		commands.HandleProviders()
 	case "mode":
 		commands.HandleMode()
+	case "secrets":
+		commands.HandleSecrets()
+	case "cert":
+		commands.HandleCert()
 	case "config":
 		commands.HandleConfig()
 	case "version":
@@ -62,31 +131,114 @@ func Run() {
 	}
 }
 
+// parseGlobalFlags handles the options given before the command and
+// removes them from os.Args, so commands see their own arguments at the
+// usual positions. --config is passed on as MORPHEUS_CONFIG, which also
+// reaches child processes such as billing hooks.
+func parseGlobalFlags() {
+	for len(os.Args) > 1 {
+		arg := os.Args[1]
+		switch {
+		case arg == "--config":
+			if len(os.Args) < 3 || strings.HasPrefix(os.Args[2], "-") {
+				fmt.Fprintln(os.Stderr, "❌ --config requires a path")
+				os.Exit(1)
+			}
+			os.Setenv(config.ConfigEnv, os.Args[2])
+			os.Args = append(os.Args[:1], os.Args[3:]...)
+		case strings.HasPrefix(arg, "--config="):
+			os.Setenv(config.ConfigEnv, strings.TrimPrefix(arg, "--config="))
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		case arg == "--verbose" || arg == "-v":
+			commands.Verbose = true
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		default:
+			return
+		}
+	}
+}
+
 // PrintHelp prints the main help message.
 func PrintHelp() {
 	fmt.Println("🌲 Morpheus - Infrastructure Provisioning")
 	fmt.Println()
 	fmt.Println("Usage:")
-	fmt.Println("  morpheus <command> [arguments]")
+	fmt.Println("  morpheus [global options] <command> [arguments]")
+	fmt.Println()
+	fmt.Println("Global options:")
+	fmt.Println("  --config PATH            Use this config file (also: MORPHEUS_CONFIG)")
+	fmt.Println("  --verbose, -v            Show the active config file")
 	fmt.Println()
 	fmt.Println("Commands:")
 	fmt.Println("  plant [options]          Create a new forest")
 	fmt.Println("    --nodes, -n N          Number of nodes (default: 2)")
+	fmt.Println("    --volume-size GB       Attach a persistent volume to each node")
+	fmt.Println("    --lb                   Put a load balancer in front of the nodes")
+	fmt.Println("    --floating-ip          Allocate a floating IP for failover")
+	fmt.Println("    --project NAME         Hetzner project to plant in")
 	fmt.Println()
 	fmt.Println("  grow <forest-id> [options]  Add nodes or check health")
 	fmt.Println("    --nodes, -n N          Add N nodes to the forest")
 	fmt.Println("    --auto                 Auto-expand if needed")
 	fmt.Println("    --threshold N          CPU threshold (default: 80)")
 	fmt.Println()
+	fmt.Println("  scale <forest-id> --to N  Scale a forest to N nodes (for autoscalers)")
+	fmt.Println("    --cooldown D           Minimum time between scale operations (default: 5m)")
+	fmt.Println("    --force                Ignore the cooldown")
+	fmt.Println("    --json                 Output result as JSON")
+	fmt.Println()
+	fmt.Println("  failover <forest-id> --to <node>  Move the floating IP to another node")
+	fmt.Println("  health <forest-id>             Check SSH, cloud-init, NATS, disk and load of all nodes")
+	fmt.Println("  wait <forest-id> [options]     Block until all nodes are ready (for pipelines)")
+	fmt.Println("  watch [forest-id]              Live view of node states, IPs and health")
+	fmt.Println("  exec <forest-id> -- <command>  Run a command on all nodes over SSH")
+	fmt.Println("  cp <src> <dst>                 Copy files to or from nodes (resumable)")
+	fmt.Println("  metadata <forest-id>           Show or set the nodes' metadata (also: refresh)")
+	fmt.Println("  node reboot <forest-id> <node> Reboot a node gracefully (also: poweroff, poweron)")
+	fmt.Println("  replace-node <forest-id> <node> Rebuild a node on a new server")
+	fmt.Println("  resize <forest-id> --server-type T Change the server type of the nodes")
+	fmt.Println("  pause <forest-id>              Power off all nodes, keeping disks and IPs")
+	fmt.Println("  resume <forest-id>             Power a paused forest on and check its health")
+	fmt.Println("  nats bootstrap <forest-id>     Install and configure a NATS cluster on the nodes")
+	fmt.Println("  mesh join <forest-id> --guard G Join the nodes into the WireGuard mesh of a guard")
+	fmt.Println("  mesh status <forest-id>        Show handshakes and transfer of every mesh peer")
+	fmt.Println("  keys rotate                    Rotate the SSH key used to reach nodes")
+	fmt.Println("  project [list|use <name>]  Switch between Hetzner projects")
+	fmt.Println("  worker --queue <dir|subject>  Process plant/teardown jobs from a queue")
+	fmt.Println("  blueprint push|pull <ref>  Share blueprints via an OCI registry")
+	fmt.Println("  cloudinit render|validate  Preview and check cloud-init templates")
+	fmt.Println()
 	fmt.Println("  list                     List all forests")
 	fmt.Println("  status <forest-id>       Show forest details")
 	fmt.Println("  teardown <forest-id>     Delete a forest")
+	fmt.Println("    --keep-volumes         Keep the nodes' persistent volumes")
+	fmt.Println("  snapshot <forest-id>     Snapshot the disks of all nodes (also: list, delete)")
+	fmt.Println("  restore <forest-id>      Rebuild a forest from a snapshot into new servers")
+	fmt.Println("    --snapshot ID          Snapshot to restore (default: the latest)")
+	fmt.Println("    --into NEW-ID          Restore into a new forest instead of replacing")
+	fmt.Println()
+	fmt.Println("  diff [forest-id]         Compare registry with provider state")
+	fmt.Println("  refresh [forest-id]      Report drift; with --write, update the registry")
+	fmt.Println("  import <forest-id>       Adopt existing servers into a forest")
+	fmt.Println("    --server ID[,ID]       Import servers by ID")
+	fmt.Println("    --selector k=v[,k=v]   Import servers matching labels")
+	fmt.Println("  gc [--dry-run]           Delete orphaned servers, keys, firewalls and DNS records")
+	fmt.Println("  provenance show|verify <forest-id>  Signed record of what a forest was built from")
+	fmt.Println("  history [forest-id]      Show who changed which forest, node or guard")
+	fmt.Println()
+	fmt.Println("  billing check [forest-id]  Compare actual with expected monthly spend")
+	fmt.Println("  billing expect <forest-id> <amount>  Set a forest's expected spend")
+	fmt.Println("  cost estimate [options]    Price a forest before planting it")
+	fmt.Println("  cost <forest-id>           Show a forest's current hourly and monthly cost")
 	fmt.Println()
 	fmt.Println("  config <subcommand>      Manage configuration")
 	fmt.Println("    set <key> <value>      Set a config value (persists to file)")
 	fmt.Println("    get <key>              Get a config value")
 	fmt.Println("    list                   List all configurable keys")
 	fmt.Println("    path                   Show config file location")
+	fmt.Println("  secrets rotate hetzner|azure  Replace an API token or client secret")
+	fmt.Println("  secrets list|get|put|rm|log   Use the encrypted secret store")
+	fmt.Println("  cert issue|renew|list|export|deploy  Wildcard TLS certificates via DNS-01")
 	fmt.Println()
 	fmt.Println("  mode <subcommand>        VR node boot mode management")
 	fmt.Println("    list                   List available modes")
@@ -98,10 +250,14 @@ func PrintHelp() {
 	fmt.Println("  check config             Check config file and env variables")
 	fmt.Println("  check ipv6               Check IPv6 connectivity")
 	fmt.Println("  check ssh                Check SSH key setup")
+	fmt.Println("  check dns                Check DNS token and visible zones")
+	fmt.Println("  providers [--no-check]   List providers, their status and capabilities")
 	fmt.Println()
 	fmt.Println("  customer <subcommand>    Customer onboarding management")
 	fmt.Println("    init <id> --domain <d> Initialize a new customer")
-	fmt.Println("    list                   List all customers")
+	fmt.Println("    add <id> --domain <d>  Add a customer and check its DNS token")
+	fmt.Println("    list [--check]         List all customers and their ventures")
+	fmt.Println("    remove <id>            Remove a customer")
 	fmt.Println("    verify <id>            Verify NS delegation")
 	fmt.Println()
 	fmt.Println("  dns <subcommand>         DNS management via Hetzner")
@@ -124,6 +280,7 @@ func PrintHelp() {
 	fmt.Println("  morpheus plant              # Create 2-node cluster")
 	fmt.Println("  morpheus plant --nodes 3    # Create 3-node forest")
 	fmt.Println("  morpheus grow forest-123 --nodes 2  # Add 2 nodes")
+	fmt.Println("  morpheus scale forest-123 --to 4    # Scale to exactly 4 nodes")
 	fmt.Println("  morpheus list               # View all forests")
 	fmt.Println("  morpheus teardown forest-123  # Delete forest")
 	fmt.Println()
@@ -145,10 +302,14 @@ func PrintHelp() {
 	fmt.Println("  morpheus venture disable acme experiencenet")
 	fmt.Println()
 	fmt.Println("Configuration:")
-	fmt.Println("  Morpheus looks for config.yaml in:")
+	fmt.Println("  Morpheus uses the first config file it finds in:")
 	fmt.Println("    - ./config.yaml")
+	fmt.Println("    - morpheus.yaml or .morpheus/config.yaml in the current directory")
+	fmt.Println("      or any parent, so projects in a monorepo can have their own")
 	fmt.Println("    - ~/.morpheus/config.yaml")
 	fmt.Println("    - /etc/morpheus/config.yaml")
+	fmt.Println("  MORPHEUS_CONFIG or --config PATH use PATH instead; 'morpheus config path'")
+	fmt.Println("  and --verbose show which file is active.")
 	fmt.Println()
 	fmt.Println("  Use 'morpheus config set' to persist settings to config file.")
 	fmt.Println()
@@ -0,0 +1,360 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/billing"
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/lockfile"
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// HandleBilling handles the billing command and its subcommands
+func HandleBilling() {
+	if len(os.Args) < 3 {
+		printBillingHelp()
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "check":
+		handleBillingCheck()
+	case "expect":
+		handleBillingExpect()
+	case "help", "--help", "-h":
+		printBillingHelp()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown billing subcommand: %s\n\n", os.Args[2])
+		printBillingHelp()
+		os.Exit(1)
+	}
+}
+
+func printBillingHelp() {
+	fmt.Println("Usage: morpheus billing <subcommand> [options]")
+	fmt.Println()
+	fmt.Println("Compare expected forest spend with what the provider bills.")
+	fmt.Println()
+	fmt.Println("Subcommands:")
+	fmt.Println("  check [forest-id]              Report spend per forest and guard, and orphaned resources")
+	fmt.Println("    --threshold N                Allowed deviation in percent (default: 20)")
+	fmt.Println("    --hook CMD                   Alert hook, run with the report as JSON on stdin")
+	fmt.Println("    --json                       Output the report as JSON")
+	fmt.Println("  expect <forest-id> <amount>    Set the expected monthly spend of a forest")
+	fmt.Println()
+	fmt.Println("The expected spend is recorded when a forest is planted or a guard")
+	fmt.Println("created. check exits with status 1 when a forest or guard deviates by")
+	fmt.Println("more than the threshold or unattached volumes/IPs are billed; run it")
+	fmt.Println("from cron or a systemd timer.")
+	fmt.Println("The alert hook (billing.alert_hook or --hook) only runs in that case.")
+	fmt.Println()
+	fmt.Println("Guards in the machine provider's project (Hetzner guards) are listed")
+	fmt.Println("with their resources and compared with the spend expected when they")
+	fmt.Println("were created. Guards on Azure, GCP or AWS are billed by those clouds")
+	fmt.Println("and are not included.")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus billing check")
+	fmt.Println("  morpheus billing check --threshold 10 --hook 'mail -s morpheus ops@example.com'")
+	fmt.Println("  morpheus billing expect forest-123 25")
+}
+
+func handleBillingCheck() {
+	forestID := ""
+	jsonOutput := false
+	threshold := 0.0
+	hook := ""
+
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--threshold":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --threshold requires a percentage")
+				os.Exit(1)
+			}
+			i++
+			t, err := strconv.ParseFloat(os.Args[i], 64)
+			if err != nil || t <= 0 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid threshold: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			threshold = t
+		case "--hook":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --hook requires a command")
+				os.Exit(1)
+			}
+			i++
+			hook = os.Args[i]
+		case "--json":
+			jsonOutput = true
+		default:
+			if forestID != "" || startsWithDash(os.Args[i]) {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			forestID = os.Args[i]
+		}
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+	if threshold == 0 {
+		threshold = cfg.Billing.ThresholdPercent
+	}
+	if hook == "" {
+		hook = cfg.Billing.AlertHook
+	}
+
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load storage: %s\n", err)
+		os.Exit(1)
+	}
+
+	var forests []*storage.Forest
+	if forestID != "" {
+		f, err := reg.GetForest(forestID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Forest not found: %s\n", forestID)
+			os.Exit(1)
+		}
+		forests = []*storage.Forest{f}
+		UseForestProject(cfg, reg, forestID)
+	} else {
+		// Costs are billed per Hetzner project; check the active one
+		forests = forestsInActiveProject(cfg, reg.ListForests())
+	}
+	var guards []*storage.Guard
+	if forestID == "" {
+		guards = guardsInActiveProject(cfg)
+	}
+
+	machineProv, providerName, err := CreateMachineProvider(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	reporter, ok := machineProv.(machine.CostReporter)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "❌ Provider %s does not report costs\n", providerName)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	report, err := billing.Check(ctx, reporter, forests, guards, threshold)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Billing check failed: %s\n", err)
+		os.Exit(1)
+	}
+	// With a single forest selected, other forests' resources are not orphans
+	if forestID != "" {
+		report.Orphans, report.OrphanCost = nil, 0
+		report.Guards, report.GuardCost = nil, 0
+	}
+
+	if jsonOutput {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		printBillingReport(report)
+	}
+
+	if !report.HasAnomalies() {
+		return
+	}
+	if hook != "" {
+		if err := billing.RunHook(ctx, hook, report); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  %s\n", err)
+		}
+	}
+	os.Exit(1)
+}
+
+func printBillingReport(report *billing.Report) {
+	fmt.Printf("\n💰 Monthly spend (threshold ±%.0f%%)\n", report.Threshold)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+	if len(report.Forests) == 0 {
+		fmt.Println("No forests registered.")
+	}
+	for _, f := range report.Forests {
+		status := "✅"
+		expected := "unknown"
+		if f.Expected > 0 {
+			expected = fmt.Sprintf("€%.2f", f.Expected)
+			if f.Anomaly {
+				status = "⚠️ "
+			}
+		}
+		fmt.Printf("%s %-24s actual €%7.2f   expected %-9s", status, f.ForestID, f.Actual, expected)
+		if f.Expected > 0 {
+			fmt.Printf("  (%+.0f%%)", f.Deviation)
+		}
+		fmt.Println()
+		if f.Anomaly {
+			for _, item := range f.Items {
+				fmt.Printf("      %-12s %-28s €%.2f\n", item.Kind, item.Name, item.MonthlyCost)
+			}
+		}
+	}
+
+	if len(report.Guards) > 0 {
+		fmt.Println()
+		fmt.Printf("🛡️  %d guard%s (€%.2f/month):\n", len(report.Guards), ui.Plural(len(report.Guards)), report.GuardCost)
+		for _, g := range report.Guards {
+			status := "✅"
+			expected := "unknown"
+			if g.Expected > 0 {
+				expected = fmt.Sprintf("€%.2f", g.Expected)
+				if g.Anomaly {
+					status = "⚠️ "
+				}
+			}
+			fmt.Printf("%s %-24s actual €%7.2f   expected %-9s", status, g.GuardID, g.Actual, expected)
+			if g.Expected > 0 {
+				fmt.Printf("  (%+.0f%%)", g.Deviation)
+			}
+			fmt.Println()
+			for _, item := range g.Items {
+				fmt.Printf("      %-12s %-28s €%.2f\n", item.Kind, item.Name, item.MonthlyCost)
+			}
+		}
+	}
+
+	if len(report.Orphans) > 0 {
+		fmt.Println()
+		fmt.Printf("⚠️  %d orphaned resource%s (€%.2f/month):\n", len(report.Orphans), ui.Plural(len(report.Orphans)), report.OrphanCost)
+		for _, item := range report.Orphans {
+			state := "attached"
+			if !item.Attached {
+				state = "unattached"
+			}
+			fmt.Printf("   %-12s %-28s %-10s €%.2f\n", item.Kind, item.Name, state, item.MonthlyCost)
+		}
+	}
+
+	fmt.Println()
+	if report.HasAnomalies() {
+		fmt.Println("⚠️  Spend deviates from expectations.")
+	} else {
+		fmt.Println("✅ Spend matches expectations.")
+	}
+}
+
+func handleBillingExpect() {
+	if len(os.Args) < 5 {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus billing expect <forest-id> <monthly-amount>")
+		os.Exit(1)
+	}
+	forestID := os.Args[3]
+	amount, err := strconv.ParseFloat(os.Args[4], 64)
+	if err != nil || amount < 0 {
+		fmt.Fprintf(os.Stderr, "❌ Invalid amount: %s\n", os.Args[4])
+		os.Exit(1)
+	}
+
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load storage: %s\n", err)
+		os.Exit(1)
+	}
+
+	lock, err := AcquireForestLock(forestID, "billing expect", lockfile.DefaultTTL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	defer lock.Release()
+
+	f, err := reg.GetForest(forestID)
+	if err != nil {
+		lock.Release()
+		fmt.Fprintf(os.Stderr, "Forest not found: %s\n", forestID)
+		os.Exit(1)
+	}
+	if f.NodeCount == 0 {
+		lock.Release()
+		fmt.Fprintf(os.Stderr, "❌ Forest %s has no nodes\n", forestID)
+		os.Exit(1)
+	}
+
+	// Stored per node so the expectation follows scaling
+	updated := *f
+	updated.ExpectedNodeCost = amount / float64(f.NodeCount)
+	updated.ExpectedExtraCost = 0
+	if err := reg.UpdateForest(&updated); err != nil {
+		lock.Release()
+		fmt.Fprintf(os.Stderr, "❌ Failed to update forest: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✅ Expected spend for %s set to €%.2f/month (€%.2f per node)\n", forestID, amount, updated.ExpectedNodeCost)
+}
+
+// guardsInActiveProject returns the registered Hetzner guards of the active
+// project. morpheus-azureguard records guards in the local registry.
+func guardsInActiveProject(cfg *config.Config) []*storage.Guard {
+	reg, err := storage.NewLocalRegistry(GetRegistryPath())
+	if err != nil {
+		return nil
+	}
+	var result []*storage.Guard
+	for _, g := range reg.ListGuards() {
+		if g.Provider == "hetzner" && g.Project == cfg.GetHetznerProject() {
+			result = append(result, g)
+		}
+	}
+	return result
+}
+
+// printBudgetHint tells how to proceed if err refused a change over the
+// budget
+func printBudgetHint(err error) {
+	if errors.Is(err, forest.ErrOverBudget) {
+		fmt.Fprintln(os.Stderr, "   💡 Tear down unused forests, raise limits.max_monthly_cost, or rerun with --override-budget")
+	}
+}
+
+// budgetAllows checks that a change adding the given monthly spend keeps
+// all registered forests within limits.max_monthly_cost. It reports false
+// when the change must be refused; override lets it through with a warning.
+func budgetAllows(cfg *config.Config, reg storage.Registry, added float64, override bool) bool {
+	limit := cfg.Limits.MaxMonthlyCost
+	if limit <= 0 || added <= 0 {
+		return true
+	}
+
+	budget := billing.ProjectBudget(reg.ListForests(), added, limit)
+	if n := len(budget.Unpriced); n > 0 {
+		fmt.Printf("⚠️  %d forest%s without an expected spend not counted against the budget: %s\n",
+			n, ui.Plural(n), strings.Join(budget.Unpriced, ", "))
+		fmt.Println("   💡 Set one with: morpheus billing expect <forest-id> <amount>")
+	}
+	if err := budget.Err(); err != nil {
+		if override {
+			fmt.Printf("⚠️  %s (--override-budget)\n\n", err)
+			return true
+		}
+		fmt.Fprintf(os.Stderr, "\n❌ %s\n", err)
+		fmt.Fprintln(os.Stderr, "   💡 Tear down unused forests, raise limits.max_monthly_cost, or rerun with --override-budget")
+		return false
+	}
+	fmt.Printf("💰 Budget: €%.2f of €%.2f/month after this change\n\n", budget.Projected, budget.Limit)
+	return true
+}
@@ -0,0 +1,161 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/oci"
+)
+
+const (
+	// blueprintArtifactType marks OCI artifacts pushed by morpheus
+	blueprintArtifactType = "application/vnd.nimsforest.morpheus.blueprint.v1"
+	// blueprintFileMediaType is the media type of each blueprint file
+	blueprintFileMediaType = "application/vnd.nimsforest.morpheus.blueprint.file.v1"
+)
+
+// HandleBlueprint handles the blueprint command.
+func HandleBlueprint() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printBlueprintHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	switch os.Args[2] {
+	case "push":
+		handleBlueprintPush()
+	case "pull":
+		handleBlueprintPull()
+	default:
+		fmt.Fprintf(os.Stderr, "❌ Unknown blueprint command: %s\n", os.Args[2])
+		printBlueprintHelp()
+		os.Exit(1)
+	}
+}
+
+func handleBlueprintPush() {
+	if len(os.Args) < 4 || len(os.Args) > 5 {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus blueprint push <reference> [dir]")
+		os.Exit(1)
+	}
+	ref, err := oci.ParseReference(os.Args[3])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Invalid reference: %s\n", err)
+		os.Exit(1)
+	}
+	dir := "."
+	if len(os.Args) == 5 {
+		dir = os.Args[4]
+	}
+
+	files, err := oci.ReadDir(dir, blueprintFileMediaType)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to read %s: %s\n", dir, err)
+		os.Exit(1)
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(os.Stderr, "❌ No files in %s\n", dir)
+		os.Exit(1)
+	}
+
+	fmt.Printf("📤 Pushing %d file%s to %s\n", len(files), ui.Plural(len(files)), ref)
+	for _, f := range files {
+		fmt.Printf("   • %s (%d bytes)\n", f.Name, len(f.Data))
+	}
+
+	digest, err := newRegistryClient().Push(context.Background(), ref, blueprintArtifactType, files)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n❌ Push failed: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("\n✅ Pushed %s\n", ref)
+	fmt.Printf("   Digest: %s\n", digest)
+}
+
+func handleBlueprintPull() {
+	if len(os.Args) < 4 {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus blueprint pull <reference> [--output dir]")
+		os.Exit(1)
+	}
+	ref, err := oci.ParseReference(os.Args[3])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Invalid reference: %s\n", err)
+		os.Exit(1)
+	}
+
+	dir := "."
+	for i := 4; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--output", "-o":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --output requires a directory")
+				os.Exit(1)
+			}
+			i++
+			dir = os.Args[i]
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("📥 Pulling %s\n", ref)
+	manifest, files, err := newRegistryClient().Pull(context.Background(), ref)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n❌ Pull failed: %s\n", err)
+		os.Exit(1)
+	}
+	if manifest.ArtifactType != blueprintArtifactType {
+		fmt.Printf("   ⚠️  Not a morpheus blueprint (artifact type %q), writing its files anyway\n", manifest.ArtifactType)
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "❌ Artifact contains no files")
+		os.Exit(1)
+	}
+
+	if err := oci.WriteFiles(dir, files); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to write files: %s\n", err)
+		os.Exit(1)
+	}
+	for _, f := range files {
+		fmt.Printf("   • %s (%d bytes)\n", f.Name, len(f.Data))
+	}
+	fmt.Printf("\n✅ Pulled %d file%s into %s\n", len(files), ui.Plural(len(files)), dir)
+}
+
+// newRegistryClient creates an OCI client, with credentials from the
+// environment or else from docker login
+func newRegistryClient() *oci.Client {
+	client := oci.NewClient()
+	client.Username = os.Getenv("MORPHEUS_REGISTRY_USERNAME")
+	client.Password = os.Getenv("MORPHEUS_REGISTRY_PASSWORD")
+	return client
+}
+
+func printBlueprintHelp() {
+	fmt.Println("Usage: morpheus blueprint <push|pull> <reference> [options]")
+	fmt.Println()
+	fmt.Println("Share blueprints (forest topology files, cloud-init templates) as")
+	fmt.Println("versioned OCI artifacts in a container registry.")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  push <reference> [dir]          Push all files in dir (default: .)")
+	fmt.Println("  pull <reference> [--output dir] Pull the files into dir (default: .)")
+	fmt.Println()
+	fmt.Println("References are registry/repository:tag or registry/repository@sha256:...")
+	fmt.Println("Hidden files and directories are not pushed.")
+	fmt.Println()
+	fmt.Println("Authentication:")
+	fmt.Println("  MORPHEUS_REGISTRY_USERNAME / MORPHEUS_REGISTRY_PASSWORD, or the")
+	fmt.Println("  credentials stored by 'docker login' (~/.docker/config.json).")
+	fmt.Println("  For ghcr.io, use a GitHub token with write:packages as password.")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus blueprint push ghcr.io/org/blueprints/analytics:v2 ./analytics")
+	fmt.Println("  morpheus blueprint pull ghcr.io/org/blueprints/analytics:v2 --output ./analytics")
+}
@@ -0,0 +1,507 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/certs"
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+)
+
+// certDeployDir is where "cert deploy" puts certificates on forest nodes
+const certDeployDir = "/etc/ssl/morpheus"
+
+// HandleCert handles "morpheus cert <subcommand>"
+func HandleCert() {
+	if len(os.Args) < 3 {
+		printCertHelp()
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "issue":
+		handleCertIssue(os.Args[3:])
+	case "renew":
+		handleCertRenew(os.Args[3:])
+	case "list":
+		handleCertList()
+	case "export":
+		handleCertExport(os.Args[3:])
+	case "deploy":
+		handleCertDeploy(os.Args[3:])
+	case "help", "--help", "-h":
+		printCertHelp()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown cert subcommand: %s\n\n", os.Args[2])
+		printCertHelp()
+		os.Exit(1)
+	}
+}
+
+func printCertHelp() {
+	fmt.Println("🔒 Morpheus Certificates - Let's Encrypt via DNS-01")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  morpheus cert issue <domain> [--no-wildcard] [--name NAME]... [--staging] [--customer ID]")
+	fmt.Println("  morpheus cert renew [domain] [--days N] [--staging] [--customer ID]")
+	fmt.Println("  morpheus cert list")
+	fmt.Println("  morpheus cert export <domain> [--out DIR]")
+	fmt.Println("  morpheus cert deploy <domain> --forest ID [--dir DIR] [--reload CMD]")
+	fmt.Println()
+	fmt.Println("Certificates are issued here, answering the CA's DNS-01 challenges with")
+	fmt.Println("TXT records through the DNS provider, and stored in the secret store.")
+	fmt.Println("Nodes get the certificate with 'cert deploy' and never see a DNS token.")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  issue     Issue a certificate for <domain> and *.<domain>")
+	fmt.Println("  renew     Reissue stored certificates expiring within N days (default: 30)")
+	fmt.Println("  list      Show stored certificates and when they expire")
+	fmt.Println("  export    Write fullchain.pem and privkey.pem to DIR (default: .)")
+	fmt.Printf("  deploy    Copy the certificate to the nodes of a forest (default: %s/<domain>)\n", certDeployDir)
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --no-wildcard    Only include <domain> itself")
+	fmt.Println("  --name NAME      Add a name to the certificate (repeatable)")
+	fmt.Println("  --staging        Use the Let's Encrypt staging CA (untrusted, for testing)")
+	fmt.Println("  --email ADDR     Contact for expiry notices (default: acme.email)")
+	fmt.Println("  --customer ID    Use customer-specific DNS token")
+	fmt.Println("  --reload CMD     Run CMD on each node after deploying, e.g. 'systemctl reload caddy'")
+	fmt.Println()
+	fmt.Println("Issuing a certificate accepts the CA's terms of service.")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus cert issue nimsforest.com")
+	fmt.Println("  morpheus cert issue experiencenet.customer.com --customer acme")
+	fmt.Println("  morpheus cert deploy nimsforest.com --forest forest-123 --reload 'systemctl reload caddy'")
+	fmt.Println("  morpheus cert renew    # e.g. daily from cron")
+}
+
+// certIssueOptions are the options shared by issue and renew
+type certIssueOptions struct {
+	staging    bool
+	email      string
+	customerID string
+}
+
+// parseCertIssueFlag parses an option shared by issue and renew at
+// args[i] and returns the index of its last argument, or -1 if it is not
+// one of them
+func parseCertIssueFlag(args []string, i int, opts *certIssueOptions) int {
+	value := func() string {
+		if i+1 >= len(args) {
+			fmt.Fprintf(os.Stderr, "❌ %s requires a value\n", args[i])
+			os.Exit(1)
+		}
+		i++
+		return args[i]
+	}
+	switch args[i] {
+	case "--staging":
+		opts.staging = true
+	case "--email":
+		opts.email = value()
+	case "--customer":
+		opts.customerID = value()
+	default:
+		return -1
+	}
+	return i
+}
+
+// newCertIssuer opens the secret store and the DNS provider and registers
+// the ACME account, or exits
+func newCertIssuer(ctx context.Context, opts certIssueOptions) *certs.Issuer {
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+	store := openSecretStore()
+	provider, err := getDNSProvider(opts.customerID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	issuerOpts := certs.Options{
+		DirectoryURL: cfg.ACME.Directory,
+		Email:        cfg.ACME.Email,
+		Progress:     func(line string) { fmt.Printf("   %s\n", line) },
+	}
+	if opts.staging {
+		issuerOpts.DirectoryURL = certs.LetsEncryptStagingURL
+	}
+	if opts.email != "" {
+		issuerOpts.Email = opts.email
+	}
+	issuer, err := certs.NewIssuer(ctx, provider, store, issuerOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	return issuer
+}
+
+func handleCertIssue(args []string) {
+	var domain string
+	var extra []string
+	var opts certIssueOptions
+	wildcard := true
+	for i := 0; i < len(args); i++ {
+		if next := parseCertIssueFlag(args, i, &opts); next >= 0 {
+			i = next
+			continue
+		}
+		switch args[i] {
+		case "--no-wildcard":
+			wildcard = false
+		case "--name":
+			if i+1 >= len(args) {
+				fmt.Fprintln(os.Stderr, "❌ --name requires a value")
+				os.Exit(1)
+			}
+			i++
+			extra = append(extra, args[i])
+		case "--help", "-h":
+			printCertHelp()
+			os.Exit(0)
+		default:
+			if startsWithDash(args[i]) || domain != "" {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", args[i])
+				os.Exit(1)
+			}
+			domain = strings.TrimSuffix(strings.ToLower(args[i]), ".")
+		}
+	}
+	if domain == "" {
+		printCertHelp()
+		os.Exit(1)
+	}
+	names := append(certs.Names(domain, wildcard), extra...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+	defer cancel()
+
+	fmt.Printf("\n🔒 Issuing a certificate for %s\n", strings.Join(names, ", "))
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+	issuer := newCertIssuer(ctx, opts)
+	cert, err := issuer.Issue(ctx, domain, names)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println()
+	fmt.Printf("✅ Certificate issued, valid until %s\n", cert.NotAfter.Local().Format("2006-01-02"))
+	if opts.staging {
+		fmt.Println("   ⚠️  From the staging CA: browsers do not trust it")
+	}
+	fmt.Println()
+	fmt.Println("Next steps:")
+	fmt.Printf("   morpheus cert deploy %s --forest <forest-id>\n", domain)
+	fmt.Printf("   morpheus cert export %s --out <dir>\n", domain)
+	fmt.Println("   Renew with 'morpheus cert renew', e.g. daily from cron")
+}
+
+func handleCertRenew(args []string) {
+	var domain string
+	var opts certIssueOptions
+	days := 30
+	for i := 0; i < len(args); i++ {
+		if next := parseCertIssueFlag(args, i, &opts); next >= 0 {
+			i = next
+			continue
+		}
+		switch args[i] {
+		case "--days":
+			if i+1 >= len(args) {
+				fmt.Fprintln(os.Stderr, "❌ --days requires a number")
+				os.Exit(1)
+			}
+			i++
+			n, err := strconv.Atoi(args[i])
+			if err != nil || n < 0 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid number of days: %s\n", args[i])
+				os.Exit(1)
+			}
+			days = n
+		case "--help", "-h":
+			printCertHelp()
+			os.Exit(0)
+		default:
+			if startsWithDash(args[i]) || domain != "" {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", args[i])
+				os.Exit(1)
+			}
+			domain = args[i]
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	store := openSecretStore()
+	var stored []*certs.Certificate
+	if domain != "" {
+		cert, err := certs.LoadCertificate(ctx, store, domain)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			os.Exit(1)
+		}
+		stored = append(stored, cert)
+	} else {
+		var err error
+		if stored, err = certs.ListCertificates(ctx, store); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Failed to list certificates: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var due []*certs.Certificate
+	for _, cert := range stored {
+		if cert.ExpiresWithin(time.Duration(days)*24*time.Hour, time.Now()) {
+			due = append(due, cert)
+		} else {
+			fmt.Printf("✓ %s valid until %s\n", cert.Domain, cert.NotAfter.Local().Format("2006-01-02"))
+		}
+	}
+	if len(due) == 0 {
+		fmt.Printf("No certificates expire within %d day%s\n", days, ui.Plural(days))
+		return
+	}
+
+	issuer := newCertIssuer(ctx, opts)
+	failed := 0
+	for _, cert := range due {
+		fmt.Printf("\n🔄 Renewing %s (expires %s)\n", cert.Domain, cert.NotAfter.Local().Format("2006-01-02"))
+		renewed, err := issuer.Issue(ctx, cert.Domain, cert.Names)
+		if err != nil {
+			fmt.Printf("   ❌ %s\n", err)
+			failed++
+			continue
+		}
+		fmt.Printf("   ✅ Valid until %s\n", renewed.NotAfter.Local().Format("2006-01-02"))
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "\n❌ %d of %d renewal%s failed\n", failed, len(due), ui.Plural(len(due)))
+		os.Exit(1)
+	}
+	fmt.Println()
+	fmt.Println("💡 Deploy renewed certificates with: morpheus cert deploy <domain> --forest <forest-id>")
+}
+
+func handleCertList() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	stored, err := certs.ListCertificates(ctx, openSecretStore())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to list certificates: %s\n", err)
+		os.Exit(1)
+	}
+	if len(stored) == 0 {
+		fmt.Println("No certificates stored")
+		fmt.Println()
+		fmt.Println("💡 Issue one with: morpheus cert issue <domain>")
+		return
+	}
+
+	fmt.Printf("\n🔒 Certificates (%d)\n", len(stored))
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	for _, cert := range stored {
+		left := time.Until(cert.NotAfter)
+		status := "✓"
+		switch {
+		case left <= 0:
+			status = "❌"
+		case left < 30*24*time.Hour:
+			status = "⚠️ "
+		}
+		fmt.Printf("%s %-30s expires %s (%d days)\n", status, cert.Domain, cert.NotAfter.Local().Format("2006-01-02"), int(left.Hours()/24))
+		fmt.Printf("     %s\n", strings.Join(cert.Names, ", "))
+	}
+	fmt.Println()
+}
+
+// loadCertOrExit loads a stored certificate or exits
+func loadCertOrExit(ctx context.Context, domain string) *certs.Certificate {
+	cert, err := certs.LoadCertificate(ctx, openSecretStore(), domain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		fmt.Fprintf(os.Stderr, "   Issue it with: morpheus cert issue %s\n", domain)
+		os.Exit(1)
+	}
+	return cert
+}
+
+func handleCertExport(args []string) {
+	var domain string
+	outDir := "."
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--out":
+			if i+1 >= len(args) {
+				fmt.Fprintln(os.Stderr, "❌ --out requires a directory")
+				os.Exit(1)
+			}
+			i++
+			outDir = args[i]
+		default:
+			if startsWithDash(args[i]) || domain != "" {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", args[i])
+				os.Exit(1)
+			}
+			domain = args[i]
+		}
+	}
+	if domain == "" {
+		printCertHelp()
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	cert := loadCertOrExit(ctx, domain)
+
+	if err := os.MkdirAll(outDir, 0700); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	chainFile := filepath.Join(outDir, "fullchain.pem")
+	keyFile := filepath.Join(outDir, "privkey.pem")
+	if err := os.WriteFile(chainFile, cert.ChainPEM, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(keyFile, cert.KeyPEM, 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Wrote %s and %s\n", chainFile, keyFile)
+}
+
+func handleCertDeploy(args []string) {
+	var domain, forestID, reload string
+	dir := ""
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--forest", "--dir", "--reload":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "❌ %s requires a value\n", args[i])
+				os.Exit(1)
+			}
+			switch args[i] {
+			case "--forest":
+				forestID = args[i+1]
+			case "--dir":
+				dir = args[i+1]
+			case "--reload":
+				reload = args[i+1]
+			}
+			i++
+		default:
+			if startsWithDash(args[i]) || domain != "" {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", args[i])
+				os.Exit(1)
+			}
+			domain = args[i]
+		}
+	}
+	if domain == "" || forestID == "" {
+		printCertHelp()
+		os.Exit(1)
+	}
+	if dir == "" {
+		dir = certDeployDir + "/" + domain
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	cert := loadCertOrExit(ctx, domain)
+
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to load storage: %s\n", err)
+		os.Exit(1)
+	}
+	f, err := reg.GetForest(forestID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Forest not found: %s\n", forestID)
+		os.Exit(1)
+	}
+	nodes, err := reg.GetNodes(forestID)
+	if err != nil || len(nodes) == 0 {
+		fmt.Fprintf(os.Stderr, "❌ Forest %s has no nodes\n", forestID)
+		os.Exit(1)
+	}
+
+	cfg, _ := LoadConfig()
+	targets := nodeTargets(f, nodes)
+	fmt.Printf("📤 Copying the certificate of %s to %d node%s...\n", domain, len(nodes), ui.Plural(len(nodes)))
+	files := []struct {
+		name string
+		mode string
+		data []byte
+	}{
+		{"fullchain.pem", "644", cert.ChainPEM},
+		{"privkey.pem", "600", cert.KeyPEM},
+	}
+	for _, file := range files {
+		// Write to a temporary file and rename it, so that a server
+		// reloading meanwhile never reads a partial file
+		path := dir + "/" + file.name
+		command := fmt.Sprintf("mkdir -p %s && umask 077 && cat > %s.tmp && chmod %s %s.tmp && mv %s.tmp %s",
+			shellQuote(dir), shellQuote(path), file.mode, shellQuote(path), shellQuote(path), shellQuote(path))
+		if !runOnNodes(ctx, cfg, targets, command, file.data) {
+			os.Exit(1)
+		}
+	}
+	for _, t := range targets {
+		fmt.Printf("   ✓ %s\n", t.Name)
+	}
+
+	if reload != "" {
+		fmt.Printf("🔄 Running %q...\n", reload)
+		if !runOnNodes(ctx, cfg, targets, reload, nil) {
+			os.Exit(1)
+		}
+	}
+	fmt.Println()
+	fmt.Printf("✅ Deployed to %s on %s\n", dir, forestID)
+}
+
+// runOnNodes runs a command on nodes, reporting failures; it returns
+// whether it succeeded everywhere
+func runOnNodes(ctx context.Context, cfg *config.Config, targets []sshutil.Target, command string, stdin []byte) bool {
+	var stderr bytes.Buffer
+	results := sshutil.RunParallel(ctx, targets, command, sshutil.ExecOptions{
+		IdentityFile: sshIdentityFile(cfg),
+		Timeout:      time.Minute,
+		Stdin:        stdin,
+		Stderr:       &stderr,
+	})
+	ok := true
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(os.Stderr, "   ❌ %s: %s\n", r.Target.Name, r.Err)
+			ok = false
+		}
+	}
+	if !ok && stderr.Len() > 0 {
+		fmt.Fprint(os.Stderr, stderr.String())
+	}
+	return ok
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
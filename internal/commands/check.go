@@ -9,10 +9,13 @@ import (
 	"strings"
 	"time"
 
+	"github.com/nimsforest/morpheus/internal/ui"
 	"github.com/nimsforest/morpheus/pkg/config"
+	dnshetzner "github.com/nimsforest/morpheus/pkg/dns/hetzner"
 	"github.com/nimsforest/morpheus/pkg/httputil"
 	"github.com/nimsforest/morpheus/pkg/machine/hetzner"
 	"github.com/nimsforest/morpheus/pkg/sshutil"
+	"github.com/nimsforest/morpheus/pkg/storage"
 )
 
 // HandleCheckIPv6 handles the check-ipv6 command.
@@ -71,6 +74,8 @@ func HandleCheck() {
 		runSSHCheck(true)
 	case "config":
 		runConfigCheck(true)
+	case "dns":
+		runDNSCheck(true)
 	case "":
 		// Run all checks
 		fmt.Println("🔍 Running Morpheus Diagnostics")
@@ -82,6 +87,9 @@ func HandleCheck() {
 		ipv6Ok, ipv4Ok := runNetworkCheck(false)
 		fmt.Println()
 		sshOk := runSSHCheck(false)
+		fmt.Println()
+		dnsOk := runDNSCheck(false)
+		configOk = configOk && dnsOk
 
 		fmt.Println()
 		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
@@ -103,13 +111,14 @@ func HandleCheck() {
 		}
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown check: %s\n\n", subcommand)
-		fmt.Fprintln(os.Stderr, "Usage: morpheus check [config|ipv6|ipv4|network|ssh]")
+		fmt.Fprintln(os.Stderr, "Usage: morpheus check [config|ipv6|ipv4|network|ssh|dns]")
 		fmt.Fprintln(os.Stderr, "  morpheus check         Run all checks")
 		fmt.Fprintln(os.Stderr, "  morpheus check config  Check config file and env variables")
 		fmt.Fprintln(os.Stderr, "  morpheus check ipv6    Check IPv6 connectivity")
 		fmt.Fprintln(os.Stderr, "  morpheus check ipv4    Check IPv4 connectivity")
 		fmt.Fprintln(os.Stderr, "  morpheus check network Check both IPv6 and IPv4")
 		fmt.Fprintln(os.Stderr, "  morpheus check ssh     Check SSH key setup")
+		fmt.Fprintln(os.Stderr, "  morpheus check dns     Check the DNS token against the zones API")
 		os.Exit(1)
 	}
 }
@@ -346,7 +355,7 @@ func runSSHCheck(exitOnResult bool) bool {
 		fmt.Println()
 		fmt.Println("   ⚠️  No config file found (can't check Hetzner SSH key status)")
 		fmt.Println("   Create config.yaml to enable full SSH key validation")
-	} else if cfg.Secrets.HetznerAPIToken == "" {
+	} else if token, tokenErr := cfg.GetHetznerToken(""); tokenErr != nil {
 		fmt.Println()
 		fmt.Println("   ⚠️  No Hetzner API token configured (can't verify cloud SSH key)")
 	} else {
@@ -357,7 +366,7 @@ func runSSHCheck(exitOnResult bool) bool {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		hetznerProv, err := hetzner.NewProvider(cfg.Secrets.HetznerAPIToken)
+		hetznerProv, err := hetzner.NewProvider(token)
 		if err != nil {
 			fmt.Printf("   ⚠️  Could not connect to Hetzner: %s\n", err)
 		} else {
@@ -406,16 +415,12 @@ func runSSHCheck(exitOnResult bool) bool {
 	reg, err := CreateStorage()
 	if err == nil {
 		forests := reg.ListForests()
-		var activeNodes []*struct {
-			IP string
-		}
+		var activeNodes []*storage.Node
 		for _, f := range forests {
 			if f.Status == "active" {
 				nodes, err := reg.GetNodes(f.ID)
 				if err == nil {
-					for _, n := range nodes {
-						activeNodes = append(activeNodes, &struct{ IP string }{IP: n.IP})
-					}
+					activeNodes = append(activeNodes, nodes...)
 				}
 			}
 		}
@@ -442,6 +447,9 @@ func runSSHCheck(exitOnResult bool) bool {
 			} else {
 				conn.Close()
 				fmt.Printf("   ✅ Server %s is reachable on port %d\n", node.IP, sshPort)
+				if !checkHostKey(node, sshPort) {
+					allOk = false
+				}
 			}
 		}
 	}
@@ -456,6 +464,119 @@ func runSSHCheck(exitOnResult bool) bool {
 	return allOk
 }
 
+// checkHostKey compares the host key a node presents with the one pinned
+// when it was provisioned
+func checkHostKey(node *storage.Node, sshPort int) bool {
+	if node.HostKey == "" {
+		fmt.Println("   ⚠️  No pinned host key (node predates pinning or was imported)")
+		fmt.Println("      Its host key is trusted on first use")
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	presented, err := sshutil.ScanHostKey(ctx, node.IP, sshPort)
+	if err != nil {
+		fmt.Printf("   ⚠️  Could not fetch host key: %s\n", err)
+		return true
+	}
+	if !sshutil.SameHostKey(presented, node.HostKey) {
+		fmt.Println("   ❌ HOST KEY MISMATCH!")
+		fmt.Printf("      Pinned:    %s\n", node.HostKey)
+		fmt.Printf("      Presented: %s\n", presented)
+		fmt.Println("      The server is not the one morpheus provisioned, or its keys were")
+		fmt.Println("      regenerated. Do not connect until you know why.")
+		return false
+	}
+	fmt.Println("   ✅ Host key matches the pinned key")
+	return true
+}
+
+// runDNSCheck verifies that the DNS token is accepted by the zones API
+// and reports which zones it can see
+func runDNSCheck(exitOnResult bool) bool {
+	fmt.Println("🌐 DNS API Token")
+
+	allOk := true
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Println("   ⚠️  No config file found (can't check DNS token)")
+		if exitOnResult {
+			os.Exit(1)
+		}
+		return true
+	}
+
+	dnsRequired := cfg.DNS.Domain != "" || cfg.DNS.Provider == "hetzner"
+	token := cfg.GetDNSToken()
+	if token == "" {
+		if dnsRequired {
+			fmt.Println("   ❌ No DNS token configured, but DNS is enabled")
+			fmt.Println("      Set with: morpheus config set hetzner_dns_token <value>")
+			allOk = false
+		} else {
+			fmt.Println("   ○  No DNS token configured (DNS features disabled)")
+		}
+		if exitOnResult {
+			if allOk {
+				os.Exit(0)
+			}
+			os.Exit(1)
+		}
+		return allOk
+	}
+
+	if cfg.IsDNSTokenFallback() {
+		fmt.Println("   ⚠️  HETZNER_DNS_TOKEN not set - using HETZNER_API_TOKEN for DNS")
+		fmt.Println("      The main Cloud token has full project access. To scope DNS")
+		fmt.Println("      separately, set: morpheus config set hetzner_dns_token <value>")
+	} else {
+		fmt.Printf("   ✅ Using HETZNER_DNS_TOKEN: %s\n", config.MaskToken(token))
+	}
+
+	fmt.Println("   Checking token against the zones API...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	dnsProv, err := newHetznerDNSProvider(cfg, token)
+	if err != nil {
+		fmt.Printf("   ❌ Could not create DNS client: %s\n", err)
+		allOk = false
+	} else if zones, err := dnsProv.ListZones(ctx); err != nil {
+		fmt.Printf("   ❌ Token was rejected by the zones API: %s\n", err)
+		if cfg.IsDNSTokenFallback() {
+			fmt.Println("      The main API token may lack DNS permissions; set a dedicated hetzner_dns_token")
+		}
+		allOk = false
+	} else {
+		fmt.Printf("   ✅ Token works - %d zone%s visible\n", len(zones), ui.Plural(len(zones)))
+		if _, legacy := dnsProv.(*dnshetzner.LegacyProvider); legacy {
+			fmt.Println("   ℹ️  Using the legacy DNS API (dns.hetzner.com): the account is not")
+			fmt.Println("      migrated to the Cloud API yet (override with dns.api)")
+		}
+		domainFound := cfg.DNS.Domain == ""
+		for _, z := range zones {
+			fmt.Printf("      • %s\n", z.Name)
+			if cfg.DNS.Domain != "" && (cfg.DNS.Domain == z.Name || strings.HasSuffix(cfg.DNS.Domain, "."+z.Name)) {
+				domainFound = true
+			}
+		}
+		if !domainFound {
+			fmt.Printf("   ❌ Configured DNS domain %s is not in any visible zone\n", cfg.DNS.Domain)
+			allOk = false
+		}
+	}
+
+	if exitOnResult {
+		if allOk {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	}
+	return allOk
+}
+
 // runConfigCheck checks if config file exists and all required env variables are set
 func runConfigCheck(exitOnResult bool) bool {
 	fmt.Println("📋 Configuration")
@@ -519,11 +640,21 @@ func runConfigCheck(exitOnResult bool) bool {
 			description: "Hetzner Cloud API token (used for both Cloud and DNS)",
 			required:    cfg == nil || cfg.GetMachineProvider() == "hetzner" || cfg.GetMachineProvider() == "",
 		},
+		{
+			name:        "HETZNER_DNS_TOKEN",
+			description: "Separate Hetzner token for DNS zones (falls back to HETZNER_API_TOKEN)",
+			required:    false,
+		},
 		{
 			name:        "STORAGEBOX_PASSWORD",
 			description: "Hetzner StorageBox password (for shared registry)",
 			required:    cfg != nil && cfg.GetStorageProvider() == "storagebox",
 		},
+		{
+			name:        "NETBOX_TOKEN",
+			description: "NetBox API token (for inventory registration)",
+			required:    cfg != nil && cfg.Integration.NetBox.IsEnabled(),
+		},
 		{
 			name:        "PROXMOX_HOST",
 			description: "Proxmox VE host (for VR mode management)",
@@ -572,6 +703,28 @@ func runConfigCheck(exitOnResult bool) bool {
 				break
 			}
 		}
+		// HETZNER_DNS_TOKEN
+		for i := range vars {
+			if vars[i].name == "HETZNER_DNS_TOKEN" && vars[i].source == "" {
+				if cfg.Secrets.HetznerDNSToken != "" {
+					vars[i].hasValue = true
+					vars[i].masked = maskValue(cfg.Secrets.HetznerDNSToken)
+					vars[i].source = "config"
+				}
+				break
+			}
+		}
+		// NETBOX_TOKEN
+		for i := range vars {
+			if vars[i].name == "NETBOX_TOKEN" && vars[i].source == "" {
+				if cfg.Integration.NetBox.Token != "" {
+					vars[i].hasValue = true
+					vars[i].masked = maskValue(cfg.Integration.NetBox.Token)
+					vars[i].source = "config"
+				}
+				break
+			}
+		}
 		// STORAGEBOX_PASSWORD
 		for i := range vars {
 			if vars[i].name == "STORAGEBOX_PASSWORD" && vars[i].source == "" {
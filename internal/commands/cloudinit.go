@@ -0,0 +1,225 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/nimsforest/morpheus/pkg/cloudinit"
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// HandleCloudInit handles the cloudinit command.
+func HandleCloudInit() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printCloudInitHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	switch os.Args[2] {
+	case "render":
+		handleCloudInitRender()
+	case "validate":
+		handleCloudInitValidate()
+	default:
+		fmt.Fprintf(os.Stderr, "❌ Unknown cloudinit command: %s\n", os.Args[2])
+		printCloudInitHelp()
+		os.Exit(1)
+	}
+}
+
+func handleCloudInitRender() {
+	if len(os.Args) < 4 || startsWithDash(os.Args[3]) {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus cloudinit render <forest-id> [--node N] [--role R] [--template FILE]")
+		os.Exit(1)
+	}
+	forestID := os.Args[3]
+	nodeNum := 0
+	var role, templateFile string
+
+	for i := 4; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		if startsWithDash(arg) && i+1 >= len(os.Args) {
+			fmt.Fprintf(os.Stderr, "❌ %s requires a value\n", arg)
+			os.Exit(1)
+		}
+		switch arg {
+		case "--node":
+			i++
+			n, err := strconv.Atoi(os.Args[i])
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid node number: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			nodeNum = n
+		case "--role":
+			i++
+			role = os.Args[i]
+		case "--template":
+			i++
+			templateFile = os.Args[i]
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+			os.Exit(1)
+		}
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		cfg = &config.Config{} // Render with defaults when there is no config
+	}
+
+	// The forest's registered nodes are the peers; by default the node
+	// rendered is the next one it would get
+	var peerIPs []string
+	nodeName := func(index int) string {
+		return cfg.Naming.ServerName(config.NameData{ForestID: forestID, Index: index + 1, Role: role})
+	}
+	var registered *storage.Forest
+	if reg, err := CreateStorage(); err == nil {
+		if f, err := reg.GetForest(forestID); err == nil {
+			nodeName = forest.ForestNames(f).Node
+			registered = f
+		}
+		if nodes, err := reg.GetNodes(forestID); err == nil {
+			if nodeNum == 0 {
+				nodeNum = len(nodes) + 1
+			}
+			for i, node := range nodes {
+				if i != nodeNum-1 {
+					peerIPs = append(peerIPs, node.IP)
+				}
+			}
+		}
+	}
+	if nodeNum == 0 {
+		nodeNum = 1
+	}
+	nodeCount := max(nodeNum, len(peerIPs)+1)
+
+	// Registered forests' nodes have the role of their index, unless
+	// another is asked for
+	req := forest.ProvisionRequest{ForestID: forestID, Role: role}
+	if registered != nil && role == "" {
+		req = forest.RecordedRequest(registered)
+	}
+	data := forest.NodeCloudInitData(cfg, req, nodeName(nodeNum-1), nodeNum-1, nodeCount)
+	data.PeerIPs = peerIPs
+	if registered != nil {
+		data.Metadata = forest.NodeMetadata(cfg, registered, nodeNum-1, nodeCount, registered.Location, peerIPs).JSON()
+	}
+
+	var userData, source string
+	if templateFile != "" {
+		text, err := os.ReadFile(templateFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Failed to read template: %s\n", err)
+			os.Exit(1)
+		}
+		source = templateFile
+		userData, err = cloudinit.Render(string(text), data)
+		if err == nil {
+			err = cloudinit.ValidateUserData(userData)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s: %s\n", templateFile, err)
+			os.Exit(1)
+		}
+	} else {
+		userData, source, err = forest.RenderCloudInit(cfg, req, data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			os.Exit(1)
+		}
+		if source == "" {
+			source = "built-in"
+		}
+	}
+
+	// Keep stdout to the user data, so it can be piped
+	fmt.Fprintf(os.Stderr, "📄 %s (template: %s, %d bytes)\n", data.NodeID, source, len(userData))
+	fmt.Print(userData)
+}
+
+func handleCloudInitValidate() {
+	files := os.Args[3:]
+	if len(files) == 0 {
+		cfg, err := LoadConfig()
+		if err != nil {
+			cfg = &config.Config{}
+		}
+		dir := cfg.Provisioning.GetCloudInitDir()
+		files = findCloudInitTemplates(dir)
+		if len(files) == 0 {
+			fmt.Printf("No templates in %s, nodes use the built-in template\n", dir)
+			return
+		}
+	}
+
+	failed := 0
+	for _, file := range files {
+		text, err := os.ReadFile(file)
+		if err == nil {
+			err = cloudinit.Validate(string(text))
+		}
+		if err != nil {
+			fmt.Printf("❌ %s: %s\n", file, err)
+			failed++
+			continue
+		}
+		fmt.Printf("✅ %s\n", file)
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "\n❌ %d of %d templates invalid\n", failed, len(files))
+		os.Exit(1)
+	}
+}
+
+// findCloudInitTemplates returns the role templates in dir and its forest
+// subdirectories
+func findCloudInitTemplates(dir string) []string {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	forestFiles, _ := filepath.Glob(filepath.Join(dir, "*", "*.yaml"))
+	return append(files, forestFiles...)
+}
+
+func printCloudInitHelp() {
+	fmt.Println("Usage: morpheus cloudinit <render|validate> [options]")
+	fmt.Println()
+	fmt.Println("Nodes are configured with cloud-init. Instead of the built-in template,")
+	fmt.Println("you can supply Go templates in the cloud-init directory")
+	fmt.Println("(provisioning.cloudinit_dir, default ~/.morpheus/cloudinit):")
+	fmt.Println()
+	fmt.Println("  <forest-id>/<role>.yaml   For one forest's nodes")
+	fmt.Println("  <role>.yaml               For all nodes (role: node)")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  render <forest-id>       Print a node's user data")
+	fmt.Println("    --node N               Node number (default: the next node)")
+	fmt.Println("    --role R               Node role (default: the node's role in the forest)")
+	fmt.Println("    --template FILE        Render FILE instead of the forest's template")
+	fmt.Println("  validate [file...]       Check templates (default: all in the directory)")
+	fmt.Println()
+	fmt.Println("Template variables:")
+	fmt.Println("  .ForestID .NodeID .NodeIndex .NodeCount .Role")
+	fmt.Println("  .PeerIPs                IPs of the forest's other nodes")
+	fmt.Println("  .NATSSeeds              NATS cluster routes of the peers (nats://[ip]:6222)")
+	fmt.Println("  .Metadata               The node's metadata file as JSON (see 'morpheus metadata')")
+	fmt.Println("  .SSHKeys .StorageBoxHost .VolumeDevice .FloatingIP ...")
+	fmt.Println("Functions: indent N s, join sep list, quote s")
+	fmt.Println()
+	fmt.Println("The node's SSH host key is generated when it is provisioned, so it is")
+	fmt.Println("not part of rendered output.")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus cloudinit validate")
+	fmt.Println("  morpheus cloudinit render forest-123 --node 2")
+	fmt.Println("  morpheus cloudinit render forest-123 --template ./node.yaml")
+}
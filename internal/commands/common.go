@@ -2,37 +2,55 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/nimsforest/morpheus/pkg/config"
 	"github.com/nimsforest/morpheus/pkg/dns"
 	dnshetzner "github.com/nimsforest/morpheus/pkg/dns/hetzner"
+	dnshistory "github.com/nimsforest/morpheus/pkg/dns/history"
 	dnsnone "github.com/nimsforest/morpheus/pkg/dns/none"
+	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/lockfile"
 	"github.com/nimsforest/morpheus/pkg/machine"
 	"github.com/nimsforest/morpheus/pkg/machine/hetzner"
+	"github.com/nimsforest/morpheus/pkg/machine/proxmox"
+	"github.com/nimsforest/morpheus/pkg/netbox"
+	"github.com/nimsforest/morpheus/pkg/secretstore"
+	"github.com/nimsforest/morpheus/pkg/sshutil"
 	"github.com/nimsforest/morpheus/pkg/storage"
 )
 
-// LoadConfig loads the configuration from the default locations.
-func LoadConfig() (*config.Config, error) {
-	// Try multiple config locations
-	configPaths := []string{
-		"./config.yaml",
-		filepath.Join(os.Getenv("HOME"), ".morpheus", "config.yaml"),
-		"/etc/morpheus/config.yaml",
-	}
+// Verbose is set by the global --verbose flag
+var Verbose bool
 
-	for _, path := range configPaths {
-		if _, err := os.Stat(path); err == nil {
-			return config.LoadConfig(path)
-		}
+var configShown bool
+
+// FindConfigPath returns the active config file (see
+// config.ConfigSearchPaths), or "" if there is none. In verbose mode it is
+// printed, once.
+func FindConfigPath() string {
+	path := config.FindConfigPath()
+	if Verbose && path != "" && !configShown {
+		fmt.Fprintf(os.Stderr, "🔧 Config: %s\n", path)
+		configShown = true
 	}
+	return path
+}
 
-	return nil, fmt.Errorf("no config file found (tried: %v)", configPaths)
+// LoadConfig loads the configuration from the active config file.
+func LoadConfig() (*config.Config, error) {
+	path := FindConfigPath()
+	if path == "" {
+		return nil, fmt.Errorf("no config file found (tried: %v)", config.ConfigSearchPaths())
+	}
+	return config.LoadConfig(path)
 }
 
 // GetRegistryPath returns the path to the registry file.
@@ -48,19 +66,52 @@ func GetRegistryPath() string {
 	return filepath.Join(registryDir, "registry.json")
 }
 
+// GetDNSHistoryDir returns the directory holding per-zone DNS change history
+func GetDNSHistoryDir() string {
+	return filepath.Join(filepath.Dir(GetRegistryPath()), "dns-history")
+}
+
+// recordDNSChanges wraps a DNS provider so every record change is saved in
+// the zone's change history, tagged with the current command line and
+// operator.
+func recordDNSChanges(provider dns.Provider) *dnshistory.Provider {
+	p := dnshistory.NewProvider(provider, dnshistory.NewJournal(GetDNSHistoryDir()), commandLine())
+	p.SetOperator(Operator())
+	return p
+}
+
+// AcquireForestLock takes the per-forest operation lock in ~/.morpheus/locks.
+// It prevents two morpheus processes on this machine from changing the same
+// forest at once (e.g. overlapping scale operations).
+func AcquireForestLock(forestID, operation string, ttl time.Duration) (*lockfile.Lock, error) {
+	lockDir := filepath.Join(filepath.Dir(GetRegistryPath()), "locks")
+	return lockfile.Acquire(filepath.Join(lockDir, forestID+".lock"), operation, ttl)
+}
+
 // CreateMachineProvider creates a machine provider based on the configuration.
 func CreateMachineProvider(cfg *config.Config) (machine.Provider, string, error) {
 	var machineProv machine.Provider
-	var err error
 	var providerName string
 
 	switch cfg.GetMachineProvider() {
 	case "hetzner":
-		machineProv, err = hetzner.NewProvider(cfg.Secrets.HetznerAPIToken)
+		token, tokenErr := cfg.GetHetznerToken("")
+		if tokenErr != nil {
+			return nil, "", tokenErr
+		}
+		hetznerProv, err := newHetznerProvider(cfg, token)
 		if err != nil {
 			return nil, "", fmt.Errorf("failed to create provider: %w", err)
 		}
+		machineProv = hetznerProv
 		providerName = "hetzner"
+	case "proxmox":
+		proxmoxProv, err := proxmox.NewProvider(proxmoxProviderConfig(cfg))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create provider: %w", err)
+		}
+		machineProv = proxmoxProv
+		providerName = "proxmox"
 	default:
 		return nil, "", fmt.Errorf("unsupported provider: %s", cfg.GetMachineProvider())
 	}
@@ -68,6 +119,113 @@ func CreateMachineProvider(cfg *config.Config) (machine.Provider, string, error)
 	return machineProv, providerName, nil
 }
 
+// newHetznerProvider creates a Hetzner provider that retries API calls as
+// configured in machine.retry
+func newHetznerProvider(cfg *config.Config, token string) (*hetzner.Provider, error) {
+	p, err := hetzner.NewProvider(token)
+	if err != nil {
+		return nil, err
+	}
+	retry := cfg.Machine.Retry
+	p.SetRetryPolicy(hetzner.RetryPolicy{
+		MaxAttempts: retry.GetMaxAttempts(),
+		BaseDelay:   retry.GetBaseDelay(),
+		MaxDelay:    retry.GetMaxDelay(),
+	})
+	return p, nil
+}
+
+// proxmoxProviderConfig returns the settings of machine.proxmox, with the
+// environment as fallback. New VMs authorize the key morpheus connects with.
+func proxmoxProviderConfig(cfg *config.Config) proxmox.ProviderConfig {
+	px := cfg.Machine.Proxmox
+	config := proxmoxConfigFromEnv()
+	config.Host = px.GetHost()
+	config.Node = px.GetNode()
+	config.APITokenID = px.GetAPITokenID()
+	config.APITokenSecret = px.GetAPITokenSecret()
+	config.VerifySSL = px.VerifySSL
+	if px.Port != 0 {
+		config.Port = px.Port
+	}
+	config.Type = px.Type
+	config.Template = px.Template
+	config.Bridge = px.Bridge
+	config.Storage = px.Storage
+	config.LinkedClone = px.LinkedClone
+	config.SnippetStorage = px.SnippetStorage
+	config.SnippetDir = px.SnippetDir
+	config.SSHUser = px.SSHUser
+	config.Sizes = make(map[string]proxmox.Size, len(px.Sizes))
+	for name, size := range px.Sizes {
+		config.Sizes[name] = proxmox.Size{Cores: size.Cores, MemoryMB: size.MemoryMB, DiskGB: size.DiskGB}
+	}
+
+	if identity := expandHome(sshIdentityFile(cfg)); identity != "" {
+		config.SSHIdentity = identity
+		if data, err := os.ReadFile(identity + ".pub"); err == nil && IsValidSSHKey(string(data)) {
+			config.SSHPublicKeys = []string{strings.TrimSpace(string(data))}
+		}
+	}
+	return config
+}
+
+// expandHome expands a leading ~/ to the home directory
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// UseForestProject makes the Hetzner project a forest was planted in the
+// active project, so the machine and DNS providers use its credentials.
+// Forests planted without a project use hetzner_api_token.
+func UseForestProject(cfg *config.Config, reg storage.Registry, forestID string) {
+	if f, err := reg.GetForest(forestID); err == nil {
+		cfg.Secrets.HetznerProject = f.Project
+	}
+}
+
+// forestsInActiveProject returns the forests that live in the active
+// Hetzner project, for commands that query the provider for many forests.
+func forestsInActiveProject(cfg *config.Config, forests []*storage.Forest) []*storage.Forest {
+	var result []*storage.Forest
+	for _, f := range forests {
+		if f.Project == cfg.GetHetznerProject() {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// sshIdentityFile returns the private key morpheus connects to nodes with:
+// the one matching the configured public key, else the first standard key
+func sshIdentityFile(cfg *config.Config) string {
+	if cfg != nil {
+		if path := sshutil.GetSSHPrivateKeyForPublicKey(cfg.GetSSHKeyPath()); path != "" {
+			return path
+		}
+	}
+	return sshutil.DetectSSHPrivateKeyPath()
+}
+
+// newHetznerDNSProvider creates a Hetzner DNS provider for the API selected
+// by dns.api, auto-detecting the legacy DNS API by default. A nil cfg is
+// loaded from the config file.
+func newHetznerDNSProvider(cfg *config.Config, token string) (dns.Provider, error) {
+	if cfg == nil {
+		loaded, err := LoadConfig()
+		if err != nil {
+			loaded = &config.Config{}
+		}
+		cfg = loaded
+	}
+	return dnshetzner.New(context.Background(), token, cfg.GetDNSAPI())
+}
+
 // CreateDNSProvider creates a DNS provider based on the configuration.
 // Auto-detects Hetzner if dns_domain and hetzner_api_token are set.
 func CreateDNSProvider(cfg *config.Config) dns.Provider {
@@ -79,12 +237,12 @@ func CreateDNSProvider(cfg *config.Config) dns.Provider {
 	// If token is available, use Hetzner DNS
 	dnsToken := cfg.GetDNSToken()
 	if dnsToken != "" {
-		dnsProv, err := dnshetzner.NewProvider(dnsToken)
+		dnsProv, err := newHetznerDNSProvider(cfg, dnsToken)
 		if err != nil {
 			fmt.Printf("⚠️  Warning: DNS provider not available: %s\n", err)
 			return nil
 		}
-		return dnsProv
+		return recordDNSChanges(dnsProv)
 	}
 
 	// Explicit provider config (legacy)
@@ -102,10 +260,67 @@ func CreateDNSProvider(cfg *config.Config) dns.Provider {
 	return nil
 }
 
+// configureInventory connects the provisioner to NetBox if the integration
+// is configured. A misconfigured integration is reported but not fatal.
+func configureInventory(p *forest.Provisioner, cfg *config.Config) {
+	if !cfg.Integration.NetBox.IsEnabled() {
+		return
+	}
+	client, err := netbox.NewClient(cfg.Integration.NetBox)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: NetBox integration not available: %s\n", err)
+		return
+	}
+	p.SetInventory(client)
+}
+
+// CreateSecretStore opens the configured secret store, logging accesses
+// with the current command line. It returns nil if none is configured.
+func CreateSecretStore(cfg *config.Config) (secretstore.Store, error) {
+	return secretstore.FromConfig(cfg, "morpheus "+strings.Join(os.Args[1:], " "))
+}
+
+// configureSecrets keeps the secrets a provisioner generates in the
+// configured secret store. Without one they stay in plain files.
+func configureSecrets(p *forest.Provisioner, cfg *config.Config) {
+	secrets, err := CreateSecretStore(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to open the secret store: %s\n", err)
+		os.Exit(1)
+	}
+	if secrets != nil {
+		p.SetSecrets(secrets)
+	}
+}
+
 // CreateStorage creates a local registry storage.
 func CreateStorage() (storage.Registry, error) {
 	registryPath := GetRegistryPath()
-	return storage.NewLocalRegistry(registryPath)
+	reg, err := storage.NewLocalRegistry(registryPath)
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewAuditedRegistry(reg, Operator(), commandLine()), nil
+}
+
+var operator string
+
+// Operator returns who is running morpheus, as recorded with registry and
+// DNS changes (storage.operator, else derived from git or the login)
+func Operator() string {
+	if operator == "" {
+		configured := os.Getenv("MORPHEUS_OPERATOR")
+		if cfg, err := LoadConfig(); err == nil {
+			configured = cfg.GetOperator()
+		}
+		operator = storage.DetectOperator(configured)
+	}
+	return operator
+}
+
+// commandLine returns the current command line, as recorded with changes
+func commandLine() string {
+	return "morpheus " + strings.Join(os.Args[1:], " ")
 }
 
 // GetEnvOrDefault returns the environment variable value or a default.
@@ -163,6 +378,26 @@ func JoinLocations(locations []string) string {
 	return strings.Join(locations, ", ")
 }
 
+// shortLocation returns a forest's location for tables, e.g. "fsn1 +2"
+// for a forest spread across three locations
+func shortLocation(f *storage.Forest) string {
+	if n := len(forest.UniqueLocations(f.Locations)); n > 1 {
+		return fmt.Sprintf("%s +%d", f.Location, n-1)
+	}
+	return f.Location
+}
+
+// missingLocations returns the locations that are not among available
+func missingLocations(locations, available []string) []string {
+	var missing []string
+	for _, loc := range forest.UniqueLocations(locations) {
+		if !slices.Contains(available, loc) {
+			missing = append(missing, loc)
+		}
+	}
+	return missing
+}
+
 // ContainsLocationError checks if an error message indicates a location availability issue.
 func ContainsLocationError(errMsg string) bool {
 	locationErrorPhrases := []string{
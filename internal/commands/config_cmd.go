@@ -56,7 +56,11 @@ func printConfigHelp() {
 	fmt.Println()
 	fmt.Println("Common Keys:")
 	fmt.Println("  hetzner_api_token    Hetzner API token (used for Cloud and DNS)")
-	fmt.Println("  machine_provider     Machine provider (hetzner, local, none)")
+	fmt.Println("  hetzner_dns_token    Optional separate token for DNS zones")
+	fmt.Println("  dns_api              Hetzner DNS API (auto, cloud, legacy)")
+	fmt.Println("  hetzner_projects.<name>  API token of a named Hetzner project")
+	fmt.Println("  hetzner_project      Active Hetzner project (see 'morpheus project')")
+	fmt.Println("  machine_provider     Machine provider (hetzner, proxmox, local, none)")
 	fmt.Println("  ipv4_enabled         Enable IPv4 (true/false)")
 	fmt.Println("  server_type          Server type (e.g., cx22)")
 	fmt.Println("  location             Datacenter location (e.g., fsn1)")
@@ -82,7 +86,7 @@ func handleConfigSet() {
 	value := os.Args[4]
 
 	// Find or create config path
-	configPath := config.FindConfigPath()
+	configPath := FindConfigPath()
 	if configPath == "" {
 		// Create default config path
 		if err := config.EnsureConfigDir(); err != nil {
@@ -148,7 +152,7 @@ func handleConfigGet() {
 	} else {
 		// Mask tokens and passwords
 		displayValue := value
-		if strings.Contains(strings.ToLower(key), "token") || strings.Contains(strings.ToLower(key), "password") {
+		if isSecretKey(key) {
 			displayValue = config.MaskToken(value)
 		}
 
@@ -176,7 +180,7 @@ func handleConfigList() {
 
 	for _, key := range keys {
 		switch {
-		case strings.Contains(key, "token") || strings.Contains(key, "password"):
+		case isSecretKey(key):
 			secretKeys = append(secretKeys, key)
 		case strings.Contains(key, "machine") || strings.Contains(key, "ssh") || strings.Contains(key, "ipv4") || strings.Contains(key, "server") || strings.Contains(key, "location") || strings.Contains(key, "image"):
 			machineKeys = append(machineKeys, key)
@@ -221,6 +225,12 @@ func handleConfigList() {
 	fmt.Println("💡 Set a value: morpheus config set <key> <value>")
 }
 
+// isSecretKey reports whether a config key holds a token or password
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	return strings.Contains(key, "token") || strings.Contains(key, "password") || strings.HasPrefix(key, "hetzner_projects.")
+}
+
 func printConfigKeyValue(cfg *config.Config, key string) {
 	if cfg == nil {
 		fmt.Printf("   %-22s (not configured)\n", key)
@@ -233,7 +243,7 @@ func printConfigKeyValue(cfg *config.Config, key string) {
 	} else {
 		// Mask tokens and passwords
 		displayValue := value
-		if strings.Contains(strings.ToLower(key), "token") || strings.Contains(strings.ToLower(key), "password") {
+		if isSecretKey(key) {
 			displayValue = config.MaskToken(value)
 		}
 
@@ -246,16 +256,16 @@ func printConfigKeyValue(cfg *config.Config, key string) {
 }
 
 func handleConfigPath() {
-	configPath := config.FindConfigPath()
+	configPath := FindConfigPath()
 	if configPath != "" {
 		fmt.Printf("Config file: %s\n", configPath)
 	} else {
 		fmt.Println("No config file found.")
 		fmt.Println()
 		fmt.Println("Searched locations:")
-		fmt.Println("  • ./config.yaml")
-		fmt.Printf("  • %s\n", config.GetDefaultConfigPath())
-		fmt.Println("  • /etc/morpheus/config.yaml")
+		for _, path := range config.ConfigSearchPaths() {
+			fmt.Printf("  • %s\n", path)
+		}
 		fmt.Println()
 		fmt.Println("Create a config file with:")
 		fmt.Println("  morpheus config set hetzner_api_token YOUR_TOKEN_HERE")
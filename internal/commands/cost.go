@@ -0,0 +1,263 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/billing"
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// HandleCost handles "morpheus cost estimate" and "morpheus cost <forest-id>"
+func HandleCost() {
+	if len(os.Args) < 3 {
+		printCostHelp()
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "estimate":
+		handleCostEstimate()
+	case "help", "--help", "-h":
+		printCostHelp()
+	default:
+		if startsWithDash(os.Args[2]) {
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n\n", os.Args[2])
+			printCostHelp()
+			os.Exit(1)
+		}
+		handleForestCost(os.Args[2])
+	}
+}
+
+func printCostHelp() {
+	fmt.Println("Usage: morpheus cost <forest-id> [--json]")
+	fmt.Println("       morpheus cost estimate [options]")
+	fmt.Println()
+	fmt.Println("Price forests with the provider's current price list (net).")
+	fmt.Println()
+	fmt.Println("  <forest-id>              What a forest's servers, IPs, volumes and")
+	fmt.Println("                           load balancer are billed now")
+	fmt.Println("  estimate                 What a forest would cost before planting it")
+	fmt.Println("    --nodes, -n N          Number of nodes (default: 2)")
+	fmt.Println("    --server-type TYPE     Server type (default: from config)")
+	fmt.Println("    --location LOC         Location (default: cheapest offering the type)")
+	fmt.Println("    --ipv4                 Add a primary IPv4 per node (default: from config)")
+	fmt.Println("    --volume-size GB       Add a volume per node")
+	fmt.Println("    --lb                   Add a load balancer")
+	fmt.Println("    --lb-type TYPE         Load balancer type (default: lb11)")
+	fmt.Println("    --floating-ip          Add a floating IP")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --json                   Output in JSON format")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus cost estimate --nodes 3 --server-type cx32")
+	fmt.Println("  morpheus cost estimate -n 5 --server-type cpx31 --volume-size 100 --lb")
+	fmt.Println("  morpheus cost forest-123")
+}
+
+func handleCostEstimate() {
+	req := machine.CostEstimateRequest{Nodes: 2}
+	lb := false
+	lbType := ""
+	floatingIP := false
+	ipv4 := false
+	jsonOutput := false
+
+	for i := 3; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		switch arg {
+		case "--nodes", "-n", "--server-type", "--location", "--volume-size", "--lb-type":
+			if i+1 >= len(os.Args) || startsWithDash(os.Args[i+1]) {
+				fmt.Fprintf(os.Stderr, "❌ %s requires a value\n", arg)
+				os.Exit(1)
+			}
+			i++
+			switch arg {
+			case "--nodes", "-n", "--volume-size":
+				n, err := strconv.Atoi(os.Args[i])
+				if err != nil || n < 1 {
+					fmt.Fprintf(os.Stderr, "❌ Invalid %s: %s\n", arg, os.Args[i])
+					os.Exit(1)
+				}
+				if arg == "--volume-size" {
+					req.VolumeSizeGB = n
+				} else {
+					req.Nodes = n
+				}
+			case "--server-type":
+				req.ServerType = os.Args[i]
+			case "--location":
+				req.Location = os.Args[i]
+			case "--lb-type":
+				lb, lbType = true, os.Args[i]
+			}
+		case "--ipv4":
+			ipv4 = true
+		case "--lb":
+			lb = true
+		case "--floating-ip":
+			floatingIP = true
+		case "--json":
+			jsonOutput = true
+		case "--help", "-h":
+			printCostHelp()
+			os.Exit(0)
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+			os.Exit(1)
+		}
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+	if req.ServerType == "" {
+		req.ServerType = cfg.GetServerType()
+	}
+	req.IPv4 = ipv4 || cfg.IsIPv4Enabled()
+	if lb {
+		if lbType == "" {
+			lbType = "lb11"
+		}
+		req.LoadBalancer = lbType
+	}
+	if floatingIP {
+		// As plant does: IPv4 if the nodes have IPv4, else an IPv6 network
+		req.FloatingIP = "ipv6"
+		if req.IPv4 {
+			req.FloatingIP = "ipv4"
+		}
+	}
+
+	estimator := costEstimator(cfg)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	est, err := estimator.EstimateCost(ctx, req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	if jsonOutput {
+		data, _ := json.MarshalIndent(est, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Printf("\n💰 Estimated cost in %s\n", est.Location)
+	printCostEstimate(est)
+	fmt.Println("💡 Prices are net, from the provider's current price list.")
+}
+
+func handleForestCost(forestID string) {
+	jsonOutput := false
+	for _, arg := range os.Args[3:] {
+		switch arg {
+		case "--json":
+			jsonOutput = true
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+			os.Exit(1)
+		}
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load storage: %s\n", err)
+		os.Exit(1)
+	}
+	f, err := reg.GetForest(forestID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Forest not found: %s\n", forestID)
+		os.Exit(1)
+	}
+	UseForestProject(cfg, reg, forestID)
+
+	machineProv, providerName, err := CreateMachineProvider(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	reporter, ok := machineProv.(machine.CostReporter)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "❌ Provider %s does not report costs\n", providerName)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	report, err := billing.Check(ctx, reporter, []*storage.Forest{f}, nil, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to price forest: %s\n", err)
+		os.Exit(1)
+	}
+	forest := report.Forests[0]
+
+	est := &machine.CostEstimate{Location: f.Location}
+	for _, item := range forest.Items {
+		est.Add(fmt.Sprintf("%s %s", item.Kind, item.Name), 1, item.MonthlyCost/machine.HoursPerMonth, item.MonthlyCost)
+	}
+
+	if jsonOutput {
+		output := map[string]interface{}{
+			"forest_id": forestID,
+			"items":     forest.Items,
+			"hourly":    est.Hourly,
+			"monthly":   est.Monthly,
+			"expected":  forest.Expected,
+		}
+		data, _ := json.MarshalIndent(output, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Printf("\n💰 Cost of %s\n", forestID)
+	printCostEstimate(est)
+	if forest.Expected > 0 {
+		fmt.Printf("Expected at plant: €%.2f/month (%+.0f%%)\n", forest.Expected, forest.Deviation)
+	}
+	fmt.Println("💡 Hourly figures are the monthly prices spread over 730 hours.")
+}
+
+// costEstimator returns the configured provider as a cost estimator,
+// exiting if it cannot price resources
+func costEstimator(cfg *config.Config) machine.CostEstimator {
+	machineProv, providerName, err := CreateMachineProvider(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	estimator, ok := machineProv.(machine.CostEstimator)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "❌ Provider %s cannot estimate costs\n", providerName)
+		os.Exit(1)
+	}
+	return estimator
+}
+
+func printCostEstimate(est *machine.CostEstimate) {
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	for _, line := range est.Lines {
+		item := line.Item
+		if line.Quantity > 1 {
+			item = fmt.Sprintf("%d × %s", line.Quantity, item)
+		}
+		fmt.Printf("   %-32s €%8.4f/h   €%7.2f/month\n", item, line.Hourly, line.Monthly)
+	}
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("   %-32s €%8.4f/h   €%7.2f/month\n\n", "Total", est.Hourly, est.Monthly)
+}
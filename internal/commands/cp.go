@@ -0,0 +1,234 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"syscall"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+	"github.com/nimsforest/morpheus/pkg/storage"
+	"github.com/nimsforest/morpheus/pkg/transfer"
+)
+
+// remotePathPattern matches <forest-id>:<path> and <forest-id>/<n>:<path>
+var remotePathPattern = regexp.MustCompile(`^([A-Za-z0-9._-]+)(?:/([0-9]+))?:(.+)$`)
+
+// remotePath is the node side of a copy
+type remotePath struct {
+	forestID string
+	node     int // 1-based; 0 means every node
+	path     string
+}
+
+func parseRemotePath(s string) (remotePath, bool) {
+	m := remotePathPattern.FindStringSubmatch(s)
+	if m == nil {
+		return remotePath{}, false
+	}
+	r := remotePath{forestID: m[1], path: m[3]}
+	if m[2] != "" {
+		r.node, _ = strconv.Atoi(m[2])
+	}
+	return r, true
+}
+
+// HandleCp handles the cp command.
+func HandleCp() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printCpHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	var opts transfer.Options
+	var paths []string
+	for i := 2; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		if !startsWithDash(arg) {
+			paths = append(paths, arg)
+			continue
+		}
+		if i+1 >= len(os.Args) {
+			fmt.Fprintf(os.Stderr, "❌ %s requires a value\n", arg)
+			os.Exit(1)
+		}
+		switch arg {
+		case "--limit":
+			i++
+			n, err := transfer.ParseSize(os.Args[i])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "❌ Invalid limit: %s\n", err)
+				os.Exit(1)
+			}
+			opts.RateLimit = n
+		case "--chunk":
+			i++
+			n, err := transfer.ParseSize(os.Args[i])
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid chunk size: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			opts.ChunkSize = n
+		case "--retries":
+			i++
+			n, err := strconv.Atoi(os.Args[i])
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid retries: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			opts.Retries = n
+		case "--user":
+			i++
+			opts.User = os.Args[i]
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+			fmt.Fprintln(os.Stderr, "Use 'morpheus cp --help' for usage")
+			os.Exit(1)
+		}
+	}
+
+	if len(paths) != 2 {
+		fmt.Fprintln(os.Stderr, "❌ Expected a source and a destination")
+		fmt.Fprintln(os.Stderr, "Usage: morpheus cp [options] <src> <dst>")
+		os.Exit(1)
+	}
+	src, srcRemote := parseRemotePath(paths[0])
+	dst, dstRemote := parseRemotePath(paths[1])
+	if srcRemote == dstRemote {
+		fmt.Fprintln(os.Stderr, "❌ Exactly one of source and destination must be on a forest (<forest-id>:<path>)")
+		os.Exit(1)
+	}
+	remote := dst
+	if srcRemote {
+		remote = src
+		if remote.node == 0 {
+			fmt.Fprintln(os.Stderr, "❌ Downloads need a node: <forest-id>/<n>:<path>")
+			os.Exit(1)
+		}
+	}
+
+	targets := forestTargets(remote.forestID)
+	if remote.node > len(targets) {
+		fmt.Fprintf(os.Stderr, "❌ Forest %s has %d node%s\n", remote.forestID, len(targets), ui.Plural(len(targets)))
+		os.Exit(1)
+	}
+	if remote.node > 0 {
+		targets = targets[remote.node-1 : remote.node]
+	}
+
+	cfg, _ := LoadConfig()
+	opts.IdentityFile = sshIdentityFile(cfg)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var failed int
+	for _, target := range targets {
+		opts.Progress = func(done, total int64) {
+			pct := 100
+			if total > 0 {
+				pct = int(done * 100 / total)
+			}
+			fmt.Fprintf(os.Stderr, "\r   %s: %s / %s (%d%%)   ", target.Name,
+				transfer.FormatSize(done), transfer.FormatSize(total), pct)
+		}
+
+		var err error
+		if srcRemote {
+			fmt.Fprintf(os.Stderr, "⬇️  %s:%s → %s\n", target.Name, src.path, paths[1])
+			err = transfer.Download(ctx, target, src.path, paths[1], opts)
+		} else {
+			fmt.Fprintf(os.Stderr, "⬆️  %s → %s:%s\n", paths[0], target.Name, dst.path)
+			err = transfer.Upload(ctx, target, paths[0], dst.path, opts)
+		}
+		fmt.Fprintln(os.Stderr)
+
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "   ❌ %s\n", err)
+			if ctx.Err() != nil {
+				fmt.Fprintln(os.Stderr, "💡 Run the same command again to resume")
+				os.Exit(1)
+			}
+			continue
+		}
+		fmt.Fprintln(os.Stderr, "   ✓ Verified")
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "\n❌ Failed on %d of %d node%s\n", failed, len(targets), ui.Plural(len(targets)))
+		fmt.Fprintln(os.Stderr, "💡 Run the same command again to resume")
+		os.Exit(1)
+	}
+}
+
+// forestTargets returns the SSH targets of a forest's nodes, exiting if
+// the forest has none
+func forestTargets(forestID string) []sshutil.Target {
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load storage: %s\n", err)
+		os.Exit(1)
+	}
+	f, err := reg.GetForest(forestID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Forest not found: %s\n", forestID)
+		os.Exit(1)
+	}
+	nodes, err := reg.GetNodes(forestID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to get nodes: %s\n", err)
+		os.Exit(1)
+	}
+	if len(nodes) == 0 {
+		fmt.Fprintf(os.Stderr, "❌ Forest %s has no nodes\n", forestID)
+		os.Exit(1)
+	}
+
+	return nodeTargets(f, nodes)
+}
+
+// nodeTargets returns the SSH targets of a forest's nodes. Nodes are named by
+// registration order, as their servers are.
+func nodeTargets(f *storage.Forest, nodes []*storage.Node) []sshutil.Target {
+	names := forest.ForestNames(f)
+	var targets []sshutil.Target
+	for i, node := range nodes {
+		targets = append(targets, sshutil.Target{
+			Name:    names.Node(i),
+			Addr:    node.IP,
+			HostKey: node.HostKey,
+		})
+	}
+	return targets
+}
+
+func printCpHelp() {
+	fmt.Println("Usage: morpheus cp [options] <src> <dst>")
+	fmt.Println()
+	fmt.Println("Copy a file to or from forest nodes over SSH. Files are sent in chunks")
+	fmt.Println("and verified by checksum; an interrupted copy resumes where it stopped")
+	fmt.Println("when run again.")
+	fmt.Println()
+	fmt.Println("The node side is written as:")
+	fmt.Println("  <forest-id>:<path>      Every node of the forest (uploads only)")
+	fmt.Println("  <forest-id>/<n>:<path>  Node n, counting from 1")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --limit SIZE           Bandwidth limit per second, e.g. 10M (default: none)")
+	fmt.Println("  --chunk SIZE           Bytes per SSH session (default: 16M)")
+	fmt.Println("  --retries N            Attempts per chunk (default: 5)")
+	fmt.Println("  --user NAME            Remote user (default: root)")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus cp image.qcow2 forest-123:/var/lib/images/image.qcow2")
+	fmt.Println("  morpheus cp --limit 20M forest-123/2:/var/backups/db.tar.gz db.tar.gz")
+}
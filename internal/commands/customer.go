@@ -2,12 +2,16 @@ package commands
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/nimsforest/morpheus/internal/ui"
 	"github.com/nimsforest/morpheus/pkg/customer"
 	"github.com/nimsforest/morpheus/pkg/dns"
+	"github.com/nimsforest/morpheus/pkg/venture"
 )
 
 // HandleCustomer handles the customer command.
@@ -22,8 +26,12 @@ func HandleCustomer() {
 	switch subcommand {
 	case "init":
 		handleCustomerInit()
+	case "add":
+		handleCustomerAdd()
 	case "list":
 		handleCustomerList()
+	case "remove":
+		handleCustomerRemove()
 	case "verify":
 		handleCustomerVerify()
 	case "help", "--help", "-h":
@@ -46,14 +54,29 @@ func printCustomerHelp() {
 	fmt.Println("    --domain <domain>      Customer's domain (required)")
 	fmt.Println("    --name <name>          Customer display name (optional)")
 	fmt.Println()
-	fmt.Println("  list                     List all configured customers")
+	fmt.Println("  add <customer-id>        Add a customer without prompting, checking its token")
+	fmt.Println("    --domain <domain>      Customer's domain (required)")
+	fmt.Println("    --name <name>          Customer display name (optional)")
+	fmt.Println("    --token-env <VAR>      Read the DNS token from $VAR when used (stored as ${VAR})")
+	fmt.Println("    --token-stdin          Read the DNS token from stdin and store it")
+	fmt.Println("    --project <id>         Hetzner project ID (optional)")
+	fmt.Println("    --no-validate          Save without checking the token")
+	fmt.Println()
+	fmt.Println("  list                     List all configured customers and their ventures")
+	fmt.Println("    --check                Also check each token and venture zone")
+	fmt.Println()
+	fmt.Println("  remove <customer-id>     Remove a customer from customers.yaml")
+	fmt.Println("    --force                Remove even with ventures enabled")
+	fmt.Println("    --yes, -y              Skip confirmation")
 	fmt.Println()
 	fmt.Println("  verify <customer-id>     Verify NS delegation for a customer")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  morpheus customer init acme --domain acme.example.com")
 	fmt.Println("  morpheus customer init acme --domain acme.example.com --name \"ACME Corp\"")
-	fmt.Println("  morpheus customer list")
+	fmt.Println("  morpheus customer add acme --domain acme.example.com --token-env ACME_DNS_TOKEN")
+	fmt.Println("  morpheus customer list --check")
+	fmt.Println("  morpheus customer remove acme")
 	fmt.Println("  morpheus customer verify acme")
 	fmt.Println()
 	fmt.Println("Configuration:")
@@ -172,6 +195,17 @@ func handleCustomerInit() {
 }
 
 func handleCustomerList() {
+	check := false
+	for _, arg := range os.Args[3:] {
+		switch arg {
+		case "--check":
+			check = true
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+			os.Exit(1)
+		}
+	}
+
 	fmt.Println("👥 Configured Customers")
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	fmt.Println()
@@ -198,16 +232,244 @@ func handleCustomerList() {
 		os.Exit(0)
 	}
 
+	problems := 0
 	for i, cust := range cfg.Customers {
 		if i > 0 {
 			fmt.Println()
 		}
 		fmt.Print(customer.FormatCustomerInfo(&cust))
+		if check && !checkCustomer(&cust) {
+			problems++
+		}
 	}
 
 	fmt.Println()
 	fmt.Printf("Total: %d customer(s)\n", len(cfg.Customers))
 	fmt.Printf("Config file: %s\n", configPath)
+	if problems > 0 {
+		fmt.Fprintf(os.Stderr, "\n❌ %d customer%s with problems\n", problems, ui.Plural(problems))
+		os.Exit(1)
+	}
+}
+
+// checkCustomer prints whether a customer's DNS token works and which of
+// its ventures have a zone, and returns false if anything is wrong
+func checkCustomer(cust *customer.Customer) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	provider, err := createDNSProviderForCustomer(cust)
+	if err == nil {
+		_, err = provider.ListZones(ctx)
+	}
+	if err != nil {
+		fmt.Printf("  ❌ DNS token: %s\n", err)
+		return false
+	}
+	fmt.Println("  ✓ DNS token works")
+
+	ok := true
+	for _, name := range cust.Ventures {
+		ventureDomain := venture.GetVentureDomain(cust.Domain, name)
+		zone, err := provider.GetZone(ctx, ventureDomain)
+		switch {
+		case err != nil:
+			fmt.Printf("  ❌ %s: %s\n", name, err)
+			ok = false
+		case zone == nil:
+			fmt.Printf("  ⚠️  %s: enabled, but there is no zone %s\n", name, ventureDomain)
+			ok = false
+		default:
+			fmt.Printf("  ✓ %s: zone %s\n", name, ventureDomain)
+		}
+	}
+	return ok
+}
+
+func handleCustomerAdd() {
+	if len(os.Args) < 4 || startsWithDash(os.Args[3]) {
+		fmt.Fprintln(os.Stderr, "Error: customer-id is required")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Usage: morpheus customer add <customer-id> --domain <domain> [--token-env VAR | --token-stdin]")
+		os.Exit(1)
+	}
+
+	cust := customer.Customer{ID: os.Args[3]}
+	tokenStdin := false
+	validate := true
+	args := os.Args[4:]
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--domain", "-d", "--name", "-n", "--token-env", "--project":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: %s requires a value\n", args[i])
+				os.Exit(1)
+			}
+			i++
+			switch args[i-1] {
+			case "--domain", "-d":
+				cust.Domain = strings.TrimSuffix(strings.ToLower(args[i]), ".")
+			case "--name", "-n":
+				cust.Name = args[i]
+			case "--token-env":
+				cust.Hetzner.APIToken = "${" + strings.TrimSuffix(strings.TrimPrefix(args[i], "${"), "}") + "}"
+			case "--project":
+				cust.Hetzner.ProjectID = args[i]
+			}
+		case "--token-stdin":
+			tokenStdin = true
+		case "--no-validate":
+			validate = false
+		default:
+			fmt.Fprintf(os.Stderr, "Error: unknown option: %s\n", args[i])
+			os.Exit(1)
+		}
+	}
+
+	if cust.Domain == "" {
+		fmt.Fprintln(os.Stderr, "Error: --domain is required")
+		os.Exit(1)
+	}
+	if err := customer.ValidateID(cust.ID); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	if err := dns.ValidateRecordName(cust.Domain); err != nil || dns.IsWildcard(cust.Domain) {
+		fmt.Fprintf(os.Stderr, "❌ Invalid domain: %s\n", cust.Domain)
+		os.Exit(1)
+	}
+	if tokenStdin {
+		if cust.Hetzner.APIToken != "" {
+			fmt.Fprintln(os.Stderr, "❌ Use either --token-env or --token-stdin")
+			os.Exit(1)
+		}
+		token, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && token == "" {
+			fmt.Fprintf(os.Stderr, "❌ Failed to read the token from stdin: %s\n", err)
+			os.Exit(1)
+		}
+		cust.Hetzner.APIToken = strings.TrimSpace(token)
+	}
+
+	configPath := customer.GetDefaultConfigPath()
+	if cfg, err := customer.LoadCustomerConfig(configPath); err == nil {
+		if _, err := customer.GetCustomer(cfg, cust.ID); err == nil {
+			fmt.Fprintf(os.Stderr, "❌ Customer %s already exists\n", cust.ID)
+			fmt.Fprintf(os.Stderr, "💡 Remove it first with: morpheus customer remove %s\n", cust.ID)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("👥 Adding customer %s (%s)\n", cust.ID, cust.Domain)
+	fmt.Println()
+
+	switch {
+	case cust.Hetzner.APIToken == "":
+		fmt.Println("  ⚠️  No DNS token: venture and --customer DNS commands will fail until")
+		fmt.Printf("     one is set in %s\n", configPath)
+	case customer.ResolveToken(cust.Hetzner.APIToken) == "":
+		fmt.Printf("  ⚠️  %s is not set in this shell; the token could not be checked\n", cust.Hetzner.APIToken)
+	case validate:
+		if !checkCustomerToken(&cust) {
+			fmt.Fprintln(os.Stderr, "💡 Fix the token, or save anyway with --no-validate")
+			os.Exit(1)
+		}
+	}
+
+	if err := customer.AddCustomer(configPath, cust); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to save customer: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println()
+	fmt.Printf("✅ Customer saved to: %s\n", configPath)
+	fmt.Println()
+	fmt.Println(customer.GenerateNSInstructions(cust.Domain))
+}
+
+// checkCustomerToken checks that a new customer's token can list zones and
+// says whether the customer's zone exists yet
+func checkCustomerToken(cust *customer.Customer) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	provider, err := createDNSProviderForCustomer(cust)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		return false
+	}
+	zones, err := provider.ListZones(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ The DNS token was rejected: %s\n", err)
+		return false
+	}
+	fmt.Printf("  ✓ DNS token works (%d zone%s)\n", len(zones), ui.Plural(len(zones)))
+	for _, z := range zones {
+		if z.Name == cust.Domain {
+			fmt.Printf("  ✓ Zone %s exists\n", cust.Domain)
+			return true
+		}
+	}
+	fmt.Printf("  💡 No zone %s yet: morpheus dns zone create %s --customer %s\n", cust.Domain, cust.Domain, cust.ID)
+	return true
+}
+
+func handleCustomerRemove() {
+	var customerID string
+	force, yes := false, false
+	for _, arg := range os.Args[3:] {
+		switch arg {
+		case "--force":
+			force = true
+		case "--yes", "-y":
+			yes = true
+		default:
+			if customerID != "" || startsWithDash(arg) {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+				os.Exit(1)
+			}
+			customerID = arg
+		}
+	}
+	if customerID == "" {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus customer remove <customer-id> [--force] [--yes]")
+		os.Exit(1)
+	}
+
+	cust, err := loadCustomer(customerID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	if len(cust.Ventures) > 0 && !force {
+		fmt.Fprintf(os.Stderr, "❌ %s still has ventures enabled: %s\n", customerID, strings.Join(cust.Ventures, ", "))
+		fmt.Fprintln(os.Stderr, "   Their DNS zones would be left without a customer to manage them.")
+		for _, name := range cust.Ventures {
+			fmt.Fprintf(os.Stderr, "💡 morpheus venture disable %s %s --delete-zone\n", customerID, name)
+		}
+		fmt.Fprintln(os.Stderr, "   Or remove the customer anyway with --force.")
+		os.Exit(1)
+	}
+
+	fmt.Printf("🗑️  Removing customer %s (%s)\n", cust.ID, cust.Domain)
+	fmt.Println("   DNS zones and records are not touched.")
+	fmt.Println()
+	if !yes {
+		fmt.Print("Type 'yes' to remove: ")
+		var response string
+		fmt.Scanln(&response)
+		if response != "yes" {
+			fmt.Println("\nRemoval cancelled.")
+			return
+		}
+	}
+
+	if err := customer.DeleteCustomer(customer.GetDefaultConfigPath(), customerID); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Customer %s removed\n", customerID)
 }
 
 func handleCustomerVerify() {
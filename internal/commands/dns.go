@@ -23,6 +23,26 @@ func HandleDNS() {
 		HandleDNSStatus()
 	case "verify":
 		HandleDNSVerify()
+	case "propagation":
+		HandleDNSPropagation()
+	case "audit-email":
+		HandleDNSAuditEmail()
+	case "history":
+		HandleDNSHistory()
+	case "rollback":
+		HandleDNSRollback()
+	case "ttl":
+		HandleDNSTTL()
+	case "sync":
+		HandleDNSSync()
+	case "reconcile":
+		HandleDNSReconcile()
+	case "export":
+		HandleDNSExport()
+	case "import":
+		HandleDNSImport()
+	case "dnssec":
+		HandleDNSSEC()
 
 	// Advanced commands
 	case "zone":
@@ -48,19 +68,34 @@ func printDNSHelp() {
 	fmt.Println("Commands:")
 	fmt.Println("  add apex <domain>        Create zone for domain you own")
 	fmt.Println("  add subdomain <domain>   Create zone delegated from parent")
+	fmt.Println("  add email <domain> --provider P  Add a hosted email provider's records")
 	fmt.Println("  add gmail-mx <domain>    Add Gmail/Google Workspace MX records")
-	fmt.Println("  verify <domain>          Check NS delegation and MX records")
+	fmt.Println("  add mta-sts <domain>     Add MTA-STS and TLS-RPT records")
+	fmt.Println("  add bimi <domain>        Add a BIMI logo record")
+	fmt.Println("  verify <domain>          Check NS delegation, MX records and DNSSEC (--wait)")
+	fmt.Println("  propagation <name>       Show which resolvers see a record (cached vs wrong)")
+	fmt.Println("  audit-email <domain>     Score SPF, DKIM, DMARC, MTA-STS and rDNS")
 	fmt.Println("  status [domain]          Show zones or zone details")
 	fmt.Println("  remove <domain>          Delete zone and all records")
+	fmt.Println("  history <domain>         Show record changes made by morpheus")
+	fmt.Println("  rollback <domain> --to N Restore records to change N")
+	fmt.Println("  ttl <domain> --set N     Bulk-set TTLs (--restore to undo)")
+	fmt.Println("  sync <forest-id>         Reconcile the A/AAAA records of a forest's nodes")
+	fmt.Println("  reconcile                Check all zones against the registry (--fix)")
+	fmt.Println("  export <domain>          Write the zone as an RFC 1035 zone file")
+	fmt.Println("  import <domain> <file>   Create RRSets from a zone file")
+	fmt.Println("  dnssec <cmd> <domain>    Sign a zone and show its DS records (enable/disable/status)")
 	fmt.Println()
 	fmt.Println("Advanced:")
 	fmt.Println("  zone <cmd>               Zone management (create/list/get/delete)")
-	fmt.Println("  record <cmd>             Record management (create/list/delete)")
+	fmt.Println("  record <cmd>             Record management (create/list/delete/caa)")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  morpheus dns add apex nimsforest.com")
 	fmt.Println("  morpheus dns add gmail-mx nimsforest.com")
+	fmt.Println("  morpheus dns add email nimsforest.com --provider migadu")
 	fmt.Println("  morpheus dns verify nimsforest.com")
+	fmt.Println("  morpheus dns audit-email nimsforest.com")
 	fmt.Println("  morpheus dns status nimsforest.com")
 	fmt.Println()
 	fmt.Println("Use 'morpheus dns <command> --help' for more info.")
@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/dns"
+)
+
+// handleAddBIMI handles "morpheus dns add bimi <domain>": it checks that
+// DMARC is at enforcement and the logo and certificate are acceptable,
+// then publishes the BIMI record
+func handleAddBIMI(domain string, args []string) {
+	var logoURL, vmcURL, customerID string
+	selector := "default"
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--logo", "--vmc", "--selector", "--customer":
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+			os.Exit(1)
+		}
+		if i+1 >= len(args) || startsWithDash(args[i+1]) {
+			fmt.Fprintf(os.Stderr, "❌ %s requires a value\n", arg)
+			os.Exit(1)
+		}
+		i++
+		switch arg {
+		case "--logo":
+			logoURL = args[i]
+		case "--vmc":
+			vmcURL = args[i]
+		case "--selector":
+			selector = args[i]
+		case "--customer":
+			customerID = args[i]
+		}
+	}
+	if logoURL == "" {
+		fmt.Fprintln(os.Stderr, "❌ --logo is required")
+		fmt.Fprintf(os.Stderr, "   Usage: morpheus dns add bimi %s --logo https://.../logo.svg [--vmc https://.../vmc.pem]\n", domain)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	fmt.Printf("\n🏷️  Setting up BIMI for %s\n", domain)
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+
+	fmt.Printf("📊 Checking DMARC policy...")
+	if err := dns.CheckDMARCEnforcement(ctx, domain, dns.SystemResolver); err != nil {
+		fmt.Printf(" ❌\n")
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		fmt.Fprintln(os.Stderr, "   Mailbox providers only show BIMI logos for domains with DMARC at enforcement.")
+		fmt.Fprintf(os.Stderr, "   💡 Check the current policy with: morpheus dns audit-email %s\n", domain)
+		os.Exit(1)
+	}
+	fmt.Printf(" ✓ at enforcement\n")
+
+	fmt.Printf("🖼️  Checking logo and certificate...")
+	if err := dns.ValidateBIMI(ctx, domain, logoURL, vmcURL); err != nil {
+		fmt.Printf(" ❌\n")
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf(" ✓\n\n")
+
+	provider, err := getDNSProvider(customerID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	zone, err := provider.GetZone(ctx, domain)
+	if err != nil || zone == nil {
+		fmt.Fprintf(os.Stderr, "❌ Zone not found: %s\n", domain)
+		fmt.Fprintf(os.Stderr, "   Create the zone first with: morpheus dns add apex %s\n", domain)
+		os.Exit(1)
+	}
+
+	req := dns.CreateRecordRequest{
+		Domain: domain,
+		Name:   selector + "._bimi",
+		Type:   dns.RecordTypeTXT,
+		Value:  quoteTXT(dns.BIMIRecord(logoURL, vmcURL)),
+		TTL:    3600,
+	}
+	fmt.Printf("🌐 Publishing record:\n")
+	fmt.Printf("   TXT %s %s...", req.Name, req.Value)
+	if _, err := provider.UpsertRecord(ctx, req); err != nil {
+		fmt.Printf(" ❌ %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf(" ✓\n\n")
+
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Printf("✅ BIMI record published!\n")
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+	if vmcURL == "" {
+		fmt.Println("⚠️  Without a Verified Mark Certificate (--vmc), Gmail and Apple Mail")
+		fmt.Println("   do not show the logo; other providers may.")
+		fmt.Println()
+	}
+	if selector != "default" {
+		fmt.Printf("💡 Senders must add the header \"BIMI-Selector: v=BIMI1; s=%s\" to use this record.\n\n", selector)
+	}
+}
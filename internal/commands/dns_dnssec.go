@@ -0,0 +1,212 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/dns"
+)
+
+// HandleDNSSEC handles "morpheus dns dnssec enable|disable|status <domain>"
+func HandleDNSSEC() {
+	var action, domain, customerID string
+	for i := 3; i < len(os.Args); i++ {
+		switch arg := os.Args[i]; arg {
+		case "--customer":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --customer requires a customer ID")
+				os.Exit(1)
+			}
+			i++
+			customerID = os.Args[i]
+		case "--help", "-h":
+			printDNSSECHelp()
+			os.Exit(0)
+		default:
+			if startsWithDash(arg) || domain != "" {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+				os.Exit(1)
+			}
+			if action == "" {
+				action = arg
+			} else {
+				domain = arg
+			}
+		}
+	}
+	if action != "enable" && action != "disable" && action != "status" || domain == "" {
+		printDNSSECHelp()
+		os.Exit(1)
+	}
+
+	provider, err := getDNSProvider(customerID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	manager, ok := provider.Provider.(dns.DNSSECManager)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "❌ The DNS provider does not support DNSSEC")
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	switch action {
+	case "enable":
+		fmt.Printf("\n🔏 Enabling DNSSEC for %s\n", domain)
+		fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+		status, err := manager.EnableDNSSEC(ctx, domain)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("✅ Zone is signed")
+		fmt.Println()
+		printDSInstructions(domain, status)
+	case "disable":
+		result := dns.VerifyDNSSEC(ctx, domain, dns.LookupDNSSEC)
+		if result.Error != nil {
+			fmt.Printf("⚠️  Could not check the DS records at the parent: %s\n", result.Error)
+		} else if len(result.DS) > 0 {
+			fmt.Fprintf(os.Stderr, "❌ %s still has %d DS record%s at its parent:\n", domain, len(result.DS), ui.Plural(len(result.DS)))
+			for _, ds := range result.DS {
+				fmt.Fprintf(os.Stderr, "   %s\n", ds)
+			}
+			fmt.Fprintln(os.Stderr, "   Remove them at your registrar and wait for their TTL to expire first,")
+			fmt.Fprintln(os.Stderr, "   or validating resolvers will fail to resolve the domain.")
+			os.Exit(1)
+		}
+		if err := manager.DisableDNSSEC(ctx, domain); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ DNSSEC disabled for %s\n", domain)
+	case "status":
+		status, err := manager.GetDNSSEC(ctx, domain)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\n🔏 DNSSEC for %s\n", domain)
+		fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+		if !status.Enabled {
+			fmt.Println("Not signed")
+			fmt.Println()
+			fmt.Printf("💡 Enable it with: morpheus dns dnssec enable %s\n", domain)
+			return
+		}
+		fmt.Println("✅ Zone is signed")
+		fmt.Println()
+		printDSInstructions(domain, status)
+	}
+}
+
+// printDSInstructions prints the DS records to publish at the registrar
+func printDSInstructions(domain string, status *dns.DNSSECStatus) {
+	if len(status.DS) == 0 {
+		fmt.Println("⏳ The zone's keys are still being generated.")
+		fmt.Printf("   Show the DS records later with: morpheus dns dnssec status %s\n", domain)
+		return
+	}
+	fmt.Println("📋 Add this DS record at your registrar (or the parent zone):")
+	fmt.Println()
+	for _, ds := range status.DS {
+		fmt.Printf("   Key tag:     %d\n", ds.KeyTag)
+		fmt.Printf("   Algorithm:   %d\n", ds.Algorithm)
+		fmt.Printf("   Digest type: %d\n", ds.DigestType)
+		fmt.Printf("   Digest:      %s\n", ds.Digest)
+		fmt.Println()
+		fmt.Printf("   %s. IN DS %s\n", domain, ds)
+		fmt.Println()
+	}
+	fmt.Println("Some registrars ask for the DNSKEY instead:")
+	for _, k := range status.DNSKEYs {
+		fmt.Printf("   %s\n", k)
+	}
+	fmt.Println()
+	fmt.Printf("Then check the chain of trust with: morpheus dns verify %s\n", domain)
+}
+
+func printDNSSECHelp() {
+	fmt.Println("Usage: morpheus dns dnssec <enable|disable|status> <domain> [--customer ID]")
+	fmt.Println()
+	fmt.Println("Sign a zone with DNSSEC and show the DS records to publish at the registrar.")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  enable    Sign the zone and print its DS records")
+	fmt.Println("  status    Show whether the zone is signed, and its DS records")
+	fmt.Println("  disable   Stop signing the zone (remove the DS records first)")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus dns dnssec enable nimsforest.com")
+	fmt.Println("  morpheus dns dnssec status nimsforest.com")
+}
+
+// checkDNSSEC verifies the DNSSEC chain of trust of a domain and reports
+// whether it resolves for validating resolvers
+func checkDNSSEC(domain string) bool {
+	fmt.Println("🔏 Checking DNSSEC...")
+	fmt.Println()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	result := dns.VerifyDNSSEC(ctx, domain, dns.LookupDNSSEC)
+	if result.Error != nil {
+		fmt.Printf("   ⚠️  DNSSEC check failed: %s\n\n", result.Error)
+		return true
+	}
+
+	for _, k := range result.DNSKEYs {
+		role := "ZSK"
+		if k.IsKSK() {
+			role = "KSK"
+		}
+		fmt.Printf("   DNSKEY %s  key tag %d, algorithm %d\n", role, k.KeyTag(), k.Algorithm)
+	}
+	for _, ds := range result.MatchingDS {
+		fmt.Printf("   ✓ DS %s\n", ds)
+	}
+	for _, ds := range result.StaleDS {
+		fmt.Printf("   ✗ DS %s (matches no key)\n", ds)
+	}
+	if len(result.DNSKEYs)+len(result.DS) > 0 {
+		fmt.Println()
+	}
+
+	switch result.State {
+	case dns.DNSSECUnsigned:
+		fmt.Println("   ℹ️  Not signed")
+		fmt.Printf("   To enable DNSSEC: morpheus dns dnssec enable %s\n\n", domain)
+	case dns.DNSSECNoDS:
+		fmt.Println("   ⚠️  Signed, but no DS record at the registrar yet")
+		fmt.Printf("   Show the DS record to add: morpheus dns dnssec status %s\n\n", domain)
+	case dns.DNSSECSecure:
+		fmt.Println("   ✅ DNSSEC chain of trust verified!")
+		if len(result.StaleDS) > 0 {
+			fmt.Println("   Remove the DS records that match no key at your registrar.")
+		}
+		fmt.Println()
+	case dns.DNSSECUnvalidated:
+		fmt.Println("   ⚠️  DS record matches, but resolvers do not validate the zone")
+		fmt.Println("   The parent zone may not be signed.")
+		fmt.Println()
+	case dns.DNSSECBogus:
+		fmt.Println("   ❌ DNSSEC validation FAILS")
+		fmt.Println()
+		fmt.Println("   Validating resolvers cannot resolve the domain at all.")
+		if len(result.MatchingDS) == 0 {
+			fmt.Println("   The DS records at the registrar match none of the zone's keys:")
+			fmt.Printf("   replace them with the ones from: morpheus dns dnssec status %s\n\n", domain)
+		} else {
+			fmt.Println("   The zone's signatures do not validate; check with:")
+			fmt.Printf("     dig +dnssec SOA %s\n\n", domain)
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/dns"
+)
+
+// HandleDNSAuditEmail handles "morpheus dns audit-email <domain>"
+func HandleDNSAuditEmail() {
+	var domain, forestID string
+	var opts dns.EmailAuditOptions
+	jsonOutput := false
+
+	for i := 3; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		switch arg {
+		case "--dkim-selector", "--forest", "--ip", "--resolver":
+			if i+1 >= len(os.Args) || startsWithDash(os.Args[i+1]) {
+				fmt.Fprintf(os.Stderr, "❌ %s requires a value\n", arg)
+				os.Exit(1)
+			}
+			i++
+			switch arg {
+			case "--dkim-selector":
+				for _, s := range strings.Split(os.Args[i], ",") {
+					if s = strings.TrimSpace(s); s != "" {
+						opts.DKIMSelectors = append(opts.DKIMSelectors, s)
+					}
+				}
+			case "--forest":
+				forestID = os.Args[i]
+			case "--ip":
+				if net.ParseIP(os.Args[i]) == nil {
+					fmt.Fprintf(os.Stderr, "❌ Invalid IP address: %s\n", os.Args[i])
+					os.Exit(1)
+				}
+				opts.SendingIPs = append(opts.SendingIPs, os.Args[i])
+			case "--resolver":
+				opts.Resolver = os.Args[i]
+			}
+		case "--json":
+			jsonOutput = true
+		case "--help", "-h":
+			printDNSAuditEmailHelp()
+			os.Exit(0)
+		default:
+			if domain != "" || startsWithDash(arg) {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+				os.Exit(1)
+			}
+			domain = arg
+		}
+	}
+
+	if domain == "" {
+		printDNSAuditEmailHelp()
+		os.Exit(1)
+	}
+
+	if forestID != "" {
+		for _, target := range forestTargets(forestID) {
+			opts.SendingIPs = append(opts.SendingIPs, target.Addr)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	audit := dns.AuditEmail(ctx, domain, opts)
+
+	if jsonOutput {
+		jsonData, _ := json.MarshalIndent(audit, "", "  ")
+		fmt.Println(string(jsonData))
+		if audit.Failed() {
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Printf("\n📧 Email deliverability audit for %s\n", audit.Domain)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	for _, c := range audit.Checks {
+		score := fmt.Sprintf("%d/%d", c.Score, c.Max)
+		if c.Status == dns.EmailSkip {
+			score = "-"
+		}
+		fmt.Printf("%s %-8s %5s\n", emailStatusIcon(c.Status), c.Name, score)
+		for _, d := range c.Details {
+			fmt.Printf("      %s\n", d)
+		}
+		if c.Fix != "" {
+			fmt.Printf("      💡 %s\n", c.Fix)
+		}
+	}
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("Score: %d/100 (grade %s)\n\n", audit.Score, audit.Grade)
+
+	if audit.Failed() {
+		os.Exit(1)
+	}
+}
+
+func emailStatusIcon(status string) string {
+	switch status {
+	case dns.EmailPass:
+		return "✅"
+	case dns.EmailWarn:
+		return "⚠️ "
+	case dns.EmailFail:
+		return "❌"
+	}
+	return "➖"
+}
+
+func printDNSAuditEmailHelp() {
+	fmt.Println("Usage: morpheus dns audit-email <domain> [options]")
+	fmt.Println()
+	fmt.Println("Audit the records that decide whether mail for a domain is delivered")
+	fmt.Println("and produce a scored report. Checks MX, SPF syntax and DNS lookup count,")
+	fmt.Println("DMARC policy, DKIM keys, MTA-STS and TLS-RPT records, and the reverse DNS")
+	fmt.Println("of sending nodes. Exits with status 1 if any check fails.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --dkim-selector NAME   DKIM selector to check (repeatable or comma-separated)")
+	fmt.Println("  --forest ID            Check reverse DNS of the forest's nodes")
+	fmt.Println("  --ip ADDR              Check reverse DNS of a sending address (repeatable)")
+	fmt.Println("  --resolver HOST:PORT   Query this resolver (default: system resolver)")
+	fmt.Println("  --json                 Output the report as JSON")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus dns audit-email nimsforest.com --dkim-selector google")
+	fmt.Println("  morpheus dns audit-email nimsforest.com --forest forest-123")
+	fmt.Println("  morpheus dns audit-email nimsforest.com --resolver 1.1.1.1:53 --json")
+}
@@ -0,0 +1,203 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	dnshistory "github.com/nimsforest/morpheus/pkg/dns/history"
+)
+
+// HandleDNSHistory handles "morpheus dns history <domain>"
+func HandleDNSHistory() {
+	var domain string
+	jsonOutput := false
+
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--json":
+			jsonOutput = true
+		case "--help", "-h":
+			printDNSHistoryHelp()
+			os.Exit(0)
+		default:
+			if domain != "" || startsWithDash(os.Args[i]) {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			domain = os.Args[i]
+		}
+	}
+
+	if domain == "" {
+		printDNSHistoryHelp()
+		os.Exit(1)
+	}
+
+	changes, err := dnshistory.NewJournal(GetDNSHistoryDir()).List(domain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	if jsonOutput {
+		jsonData, _ := json.MarshalIndent(changes, "", "  ")
+		fmt.Println(string(jsonData))
+		return
+	}
+
+	if len(changes) == 0 {
+		fmt.Printf("No recorded DNS changes for %s\n", domain)
+		return
+	}
+
+	fmt.Printf("\n📜 DNS change history for %s (%d change%s)\n", domain, len(changes), ui.Plural(len(changes)))
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	for _, c := range changes {
+		fmt.Printf("#%-4d %s  %s %s\n", c.ID, c.Timestamp.Format("2006-01-02 15:04:05"), formatFQDN(c.Name, domain), c.Type)
+		fmt.Printf("      old: %s\n", c.Old)
+		fmt.Printf("      new: %s\n", c.New)
+		if c.Operator != "" {
+			fmt.Printf("      by:  %s\n", c.Operator)
+		}
+		if c.Command != "" {
+			fmt.Printf("      cmd: %s\n", c.Command)
+		}
+	}
+	fmt.Println()
+	fmt.Printf("💡 Restore an earlier state with: morpheus dns rollback %s --to <change-id>\n", domain)
+}
+
+// HandleDNSRollback handles "morpheus dns rollback <domain> --to <change-id>"
+func HandleDNSRollback() {
+	var domain, customerID string
+	toID := -1
+	dryRun := false
+	yes := false
+
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--to":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --to requires a change ID")
+				os.Exit(1)
+			}
+			i++
+			id, err := strconv.Atoi(os.Args[i])
+			if err != nil || id < 0 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid change ID: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			toID = id
+		case "--customer":
+			if i+1 < len(os.Args) {
+				i++
+				customerID = os.Args[i]
+			}
+		case "--dry-run":
+			dryRun = true
+		case "--yes", "-y":
+			yes = true
+		case "--help", "-h":
+			printDNSRollbackHelp()
+			os.Exit(0)
+		default:
+			if domain != "" || startsWithDash(os.Args[i]) {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			domain = os.Args[i]
+		}
+	}
+
+	if domain == "" || toID < 0 {
+		printDNSRollbackHelp()
+		os.Exit(1)
+	}
+
+	journal := dnshistory.NewJournal(GetDNSHistoryDir())
+	changes, err := journal.List(domain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	plan, err := dnshistory.PlanRollback(changes, toID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		fmt.Fprintf(os.Stderr, "   List changes with: morpheus dns history %s\n", domain)
+		os.Exit(1)
+	}
+
+	if len(plan) == 0 {
+		fmt.Printf("✅ Nothing to roll back: %s already matches change #%d\n", domain, toID)
+		return
+	}
+
+	fmt.Printf("\n⏪ Rolling back %s to change #%d\n", domain, toID)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	for _, r := range plan {
+		fmt.Printf("   %s %s\n", formatFQDN(r.Name, domain), r.Type)
+		fmt.Printf("      %s → %s\n", r.From, r.To)
+	}
+	fmt.Println()
+
+	if dryRun {
+		fmt.Printf("Dry run: %d RRSet%s would be restored.\n", len(plan), ui.Plural(len(plan)))
+		return
+	}
+
+	if !yes {
+		fmt.Print("Type 'yes' to apply: ")
+		var response string
+		fmt.Scanln(&response)
+		if response != "yes" {
+			fmt.Println("\nRollback cancelled.")
+			return
+		}
+	}
+
+	provider, err := getDNSProvider(customerID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	applied, err := dnshistory.Rollback(ctx, provider, domain, toID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Rollback stopped after %d of %d RRSets: %s\n", len(applied), len(plan), err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✅ Restored %d RRSet%s. The rollback is recorded in the history and can itself be rolled back.\n", len(applied), ui.Plural(len(applied)))
+}
+
+func printDNSHistoryHelp() {
+	fmt.Println("Usage: morpheus dns history <domain> [--json]")
+	fmt.Println()
+	fmt.Println("Show the record changes morpheus has made in a zone, with the")
+	fmt.Println("previous and new values of each RRSet.")
+}
+
+func printDNSRollbackHelp() {
+	fmt.Println("Usage: morpheus dns rollback <domain> --to <change-id> [options]")
+	fmt.Println()
+	fmt.Println("Restore every RRSet changed after <change-id> to its state at that")
+	fmt.Println("change. Use --to 0 to undo all recorded changes.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --dry-run            Show what would be restored")
+	fmt.Println("  --yes, -y            Skip confirmation")
+	fmt.Println("  --customer <id>      Use customer-specific DNS token from customers.yaml")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus dns history example.com")
+	fmt.Println("  morpheus dns rollback example.com --to 12 --dry-run")
+}
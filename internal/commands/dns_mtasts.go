@@ -0,0 +1,260 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/dns"
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// mtaSTSWebRoot is where forest nodes serve mta-sts.<domain> from
+const mtaSTSWebRoot = "/var/www/mta-sts"
+
+// handleAddMTASTS handles "morpheus dns add mta-sts <domain>": it publishes
+// the _mta-sts and TLS-RPT records, and optionally the policy file
+func handleAddMTASTS(domain string, args []string) {
+	policy := &dns.MTASTSPolicy{Mode: dns.MTASTSTesting}
+	var rua []string
+	var customerID, forestID, cname, outFile string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--mode", "--mx", "--max-age", "--rua", "--customer", "--forest", "--cname", "--out":
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+			os.Exit(1)
+		}
+		if i+1 >= len(args) || startsWithDash(args[i+1]) {
+			fmt.Fprintf(os.Stderr, "❌ %s requires a value\n", arg)
+			os.Exit(1)
+		}
+		i++
+		switch arg {
+		case "--mode":
+			policy.Mode = args[i]
+		case "--mx":
+			for _, mx := range strings.Split(args[i], ",") {
+				if mx = strings.TrimSpace(mx); mx != "" {
+					policy.MX = append(policy.MX, strings.ToLower(mx))
+				}
+			}
+		case "--max-age":
+			n, err := strconv.Atoi(args[i])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "❌ Invalid --max-age: %s\n", args[i])
+				os.Exit(1)
+			}
+			policy.MaxAge = n
+		case "--rua":
+			rua = append(rua, args[i])
+		case "--customer":
+			customerID = args[i]
+		case "--forest":
+			forestID = args[i]
+		case "--cname":
+			cname = strings.TrimSuffix(args[i], ".")
+		case "--out":
+			outFile = args[i]
+		}
+	}
+	if forestID != "" && cname != "" {
+		fmt.Fprintln(os.Stderr, "❌ Use either --forest or --cname to serve the policy")
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	if len(policy.MX) == 0 && policy.Mode != dns.MTASTSNone {
+		hosts, err := dns.LookupMXHosts(ctx, domain, dns.SystemResolver)
+		if err != nil || len(hosts) == 0 {
+			fmt.Fprintf(os.Stderr, "❌ Could not find MX hosts of %s; list them with --mx\n", domain)
+			os.Exit(1)
+		}
+		policy.MX = hosts
+	}
+	if err := policy.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Invalid policy: %s\n", err)
+		os.Exit(1)
+	}
+	if len(rua) == 0 {
+		rua = []string{"tls-reports@" + domain}
+	}
+	tlsrpt, err := dns.TLSRPTRecord(rua)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	provider, err := getDNSProvider(customerID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	zone, err := provider.GetZone(ctx, domain)
+	if err != nil || zone == nil {
+		fmt.Fprintf(os.Stderr, "❌ Zone not found: %s\n", domain)
+		fmt.Fprintf(os.Stderr, "   Create the zone first with: morpheus dns add apex %s\n", domain)
+		os.Exit(1)
+	}
+
+	fmt.Printf("\n🔒 Setting up MTA-STS and TLS-RPT for %s\n", domain)
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+
+	fmt.Printf("📜 Policy (mode %s, %d mx host%s):\n", policy.Mode, len(policy.MX), ui.Plural(len(policy.MX)))
+	for _, line := range strings.Split(strings.TrimSpace(policy.String()), "\r\n") {
+		fmt.Printf("   %s\n", line)
+	}
+	fmt.Println()
+
+	records := []dns.CreateRecordRequest{
+		{Domain: domain, Name: "_mta-sts", Type: dns.RecordTypeTXT, Value: quoteTXT(dns.MTASTSRecord(policy)), TTL: 3600},
+		{Domain: domain, Name: "_smtp._tls", Type: dns.RecordTypeTXT, Value: quoteTXT(tlsrpt), TTL: 3600},
+	}
+
+	served := false
+	switch {
+	case forestID != "":
+		addrs, err := uploadMTASTSPolicy(ctx, forestID, policy)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			os.Exit(1)
+		}
+		for _, addr := range addrs {
+			recordType := dns.RecordTypeA
+			if sshutil.IsIPv6(addr) {
+				recordType = dns.RecordTypeAAAA
+			}
+			records = append(records, dns.CreateRecordRequest{Domain: domain, Name: "mta-sts", Type: recordType, Value: addr, TTL: 3600})
+		}
+		served = true
+	case cname != "":
+		records = append(records, dns.CreateRecordRequest{Domain: domain, Name: "mta-sts", Type: dns.RecordTypeCNAME, Value: cname + ".", TTL: 3600})
+	}
+
+	fmt.Printf("🌐 Publishing records:\n")
+	failed := 0
+	for _, req := range records {
+		fmt.Printf("   %s %s %s...", req.Type, req.Name, req.Value)
+		if _, err := provider.UpsertRecord(ctx, req); err != nil {
+			fmt.Printf(" ❌ %s\n", err)
+			failed++
+		} else {
+			fmt.Printf(" ✓\n")
+		}
+	}
+
+	if outFile != "" {
+		if err := os.WriteFile(outFile, []byte(policy.String()), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Failed to write policy: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\n💾 Policy written to %s\n", outFile)
+	}
+
+	fmt.Println()
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	if failed > 0 {
+		fmt.Printf("❌ %d of %d record%s failed\n", failed, len(records), ui.Plural(len(records)))
+		fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+		os.Exit(1)
+	}
+	fmt.Printf("✅ All %d records published!\n", len(records))
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+
+	fmt.Printf("Senders fetch the policy from:\n")
+	fmt.Printf("   https://mta-sts.%s/.well-known/mta-sts.txt\n\n", domain)
+	switch {
+	case served:
+		fmt.Printf("The policy was copied to %s/.well-known/ on the nodes of %s.\n", mtaSTSWebRoot, forestID)
+		fmt.Printf("Serve that directory over HTTPS for mta-sts.%s with a valid certificate.\n", domain)
+	case cname != "":
+		fmt.Printf("Upload the policy to %s as .well-known/mta-sts.txt (text/plain),\n", cname)
+		fmt.Printf("served over HTTPS with a certificate valid for mta-sts.%s.\n", domain)
+	default:
+		fmt.Printf("Serve the policy there over HTTPS with a valid certificate,\n")
+		fmt.Printf("or rerun with --forest ID or --cname HOST.\n")
+	}
+	fmt.Println()
+	if policy.Mode == dns.MTASTSTesting {
+		fmt.Printf("💡 Once TLS reports look clean, switch to enforcement with: morpheus dns add mta-sts %s --mode enforce\n", domain)
+	}
+	fmt.Printf("💡 Check the result with: morpheus dns audit-email %s\n\n", domain)
+}
+
+// uploadMTASTSPolicy copies the policy file to every node of a forest and
+// returns the addresses mta-sts.<domain> should point at: the forest's
+// floating IP or load balancer if it has one, else its first node
+func uploadMTASTSPolicy(ctx context.Context, forestID string, policy *dns.MTASTSPolicy) ([]string, error) {
+	reg, err := CreateStorage()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load storage: %w", err)
+	}
+	f, err := reg.GetForest(forestID)
+	if err != nil {
+		return nil, fmt.Errorf("forest not found: %s", forestID)
+	}
+	nodes, err := reg.GetNodes(forestID)
+	if err != nil || len(nodes) == 0 {
+		return nil, fmt.Errorf("forest %s has no nodes", forestID)
+	}
+
+	cfg, _ := LoadConfig()
+	fmt.Printf("📤 Copying policy to %d node%s...\n", len(nodes), ui.Plural(len(nodes)))
+	var stderr bytes.Buffer
+	dir := mtaSTSWebRoot + "/.well-known"
+	command := fmt.Sprintf("mkdir -p %s && cat > %s/mta-sts.txt", dir, dir)
+	results := sshutil.RunParallel(ctx, nodeTargets(f, nodes), command, sshutil.ExecOptions{
+		IdentityFile: sshIdentityFile(cfg),
+		Timeout:      30 * time.Second,
+		Stdin:        []byte(policy.String()),
+		Stderr:       &stderr,
+	})
+	for _, r := range results {
+		if r.Err != nil {
+			return nil, fmt.Errorf("failed to copy policy to %s: %w\n%s", r.Target.Name, r.Err, stderr.String())
+		}
+		fmt.Printf("   ✓ %s\n", r.Target.Name)
+	}
+	fmt.Println()
+
+	return mtaSTSAddrs(f, nodes[0]), nil
+}
+
+func mtaSTSAddrs(f *storage.Forest, first *storage.Node) []string {
+	var addrs []string
+	switch {
+	case f.FloatingIP != "":
+		addrs = append(addrs, f.FloatingIP)
+	case f.LoadBalancerIPv4 != "" || f.LoadBalancerIPv6 != "":
+		addrs = append(addrs, f.LoadBalancerIPv4, f.LoadBalancerIPv6)
+	default:
+		addrs = append(addrs, first.IPv4, machine.HostIPv6(first.IPv6))
+		if first.IPv4 == "" && first.IPv6 == "" {
+			addrs = append(addrs, first.IP)
+		}
+	}
+	var result []string
+	for _, addr := range addrs {
+		if net.ParseIP(addr) != nil {
+			result = append(result, addr)
+		}
+	}
+	return result
+}
+
+// quoteTXT quotes a TXT record value for the provider
+func quoteTXT(value string) string {
+	return `"` + value + `"`
+}
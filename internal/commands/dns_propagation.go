@@ -0,0 +1,150 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/customer"
+	"github.com/nimsforest/morpheus/pkg/dns"
+)
+
+// HandleDNSPropagation handles "morpheus dns propagation <name>"
+func HandleDNSPropagation() {
+	var name string
+	recordType := dns.RecordTypeA
+	var values, nameservers []string
+
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--type", "--value", "--ns":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintf(os.Stderr, "❌ %s requires a value\n", os.Args[i])
+				os.Exit(1)
+			}
+			i++
+			switch os.Args[i-1] {
+			case "--type":
+				recordType = dns.RecordType(strings.ToUpper(os.Args[i]))
+			case "--value":
+				values = append(values, os.Args[i])
+			case "--ns":
+				nameservers = append(nameservers, os.Args[i])
+			}
+		case "--help", "-h":
+			printDNSPropagationHelp()
+			os.Exit(0)
+		default:
+			if name != "" || startsWithDash(os.Args[i]) {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			name = strings.TrimSuffix(os.Args[i], ".")
+		}
+	}
+	if name == "" {
+		printDNSPropagationHelp()
+		os.Exit(1)
+	}
+	if len(nameservers) == 0 {
+		nameservers = customer.HetznerNameservers
+	}
+	if recordType == "NS" && len(values) == 0 {
+		values = customer.HetznerNameservers
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	fmt.Printf("\n🌍 Propagation of %s %s\n", name, recordType)
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+	report := dns.CheckPropagation(ctx, dns.PropagationResolvers(nameservers), []dns.PropagationCheck{
+		{Name: name, Type: recordType, Values: values},
+	})
+	printPropagationReport(report)
+
+	if !report.Complete() {
+		os.Exit(1)
+	}
+}
+
+// printNSPropagation shows which resolvers see the Hetzner nameservers
+// for domain
+func printNSPropagation(domain string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	report := dns.CheckPropagation(ctx, dns.PropagationResolvers(customer.HetznerNameservers), []dns.PropagationCheck{
+		{Name: domain, Type: "NS", Values: customer.HetznerNameservers},
+	})
+	printPropagationReport(report)
+}
+
+// printPropagationReport prints, for each check, what every resolver
+// returned and what that means
+func printPropagationReport(report *dns.PropagationReport) {
+	for i := range report.Rows {
+		row := &report.Rows[i]
+		if len(row.Check.Values) > 0 {
+			fmt.Printf("%s %s, expecting %s:\n", row.Check.Type, row.Check.Name, strings.Join(row.Check.Values, ", "))
+		} else {
+			fmt.Printf("%s %s:\n", row.Check.Type, row.Check.Name)
+		}
+		for _, a := range row.Answers {
+			label := a.Resolver.Name
+			if a.Resolver.Authoritative {
+				label += " (auth)"
+			} else if a.Resolver.Addr != dns.SystemResolver {
+				label += " " + strings.TrimSuffix(a.Resolver.Addr, ":53")
+			}
+			status, answer := "✓", strings.Join(a.Answers, ", ")
+			switch {
+			case a.Error != "":
+				status, answer = "✗", a.Error
+			case !a.Matched:
+				status = "✗"
+				if answer == "" {
+					answer = "no answer"
+				}
+			}
+			fmt.Printf("   %s %-32s %s\n", status, label, answer)
+		}
+
+		switch row.Diagnosis() {
+		case dns.PropagationComplete:
+			fmt.Println("   ✅ Every resolver sees the expected records")
+		case dns.PropagationCaching:
+			fmt.Println("   ⏳ The authoritative nameservers are correct; the others still have old")
+			fmt.Println("      answers cached (or the delegation is not live yet). Wait for the TTL.")
+		case dns.PropagationMisconfigured:
+			fmt.Println("   ❌ An authoritative nameserver does not serve the expected records:")
+			fmt.Println("      fix the zone, waiting will not help")
+		case dns.PropagationPartial:
+			fmt.Println("   ⚠️  Some resolvers do not see the expected records yet")
+		}
+		fmt.Println()
+	}
+}
+
+func printDNSPropagationHelp() {
+	fmt.Println("Usage: morpheus dns propagation <name> [--type TYPE] [--value V]... [--ns HOST]...")
+	fmt.Println()
+	fmt.Println("Ask the system resolver, Google, Cloudflare, Quad9 and the zone's")
+	fmt.Println("authoritative nameservers for a record, and show which of them see the")
+	fmt.Println("expected values. If the authoritative nameservers do and the others do")
+	fmt.Println("not, the old answers are cached; if they do not, the zone is wrong.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --type TYPE   A, AAAA, CNAME, TXT, MX or NS (default: A)")
+	fmt.Println("  --value V     Expected value (repeatable; default: any answer,")
+	fmt.Println("                or the Hetzner nameservers for NS)")
+	fmt.Println("  --ns HOST     Authoritative nameserver (repeatable; default: Hetzner's)")
+	fmt.Println()
+	fmt.Println("Exits with status 1 unless every resolver sees the expected values.")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus dns propagation www.example.com --value 203.0.113.10")
+	fmt.Println("  morpheus dns propagation example.com --type NS")
+	fmt.Println("  morpheus dns propagation example.com --type MX --value '1 smtp.google.com.'")
+}
@@ -0,0 +1,228 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/lockfile"
+)
+
+// HandleDNSReconcile handles "morpheus dns reconcile"
+func HandleDNSReconcile() {
+	var zones []string
+	allZones := false
+	fix := false
+	prune := false
+	jsonOutput := false
+
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--zone":
+			if i+1 >= len(os.Args) || startsWithDash(os.Args[i+1]) {
+				fmt.Fprintln(os.Stderr, "❌ --zone requires a zone name")
+				os.Exit(1)
+			}
+			i++
+			zones = append(zones, os.Args[i])
+		case "--all-zones":
+			allZones = true
+		case "--fix":
+			fix = true
+		case "--prune":
+			prune = true
+		case "--json":
+			jsonOutput = true
+		case "--help", "-h":
+			printDNSReconcileHelp()
+			os.Exit(0)
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+			os.Exit(1)
+		}
+	}
+	if prune && !fix {
+		fmt.Fprintln(os.Stderr, "❌ --prune requires --fix")
+		os.Exit(1)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load storage: %s\n", err)
+		os.Exit(1)
+	}
+	dnsProv := CreateDNSProvider(cfg)
+	if dnsProv == nil {
+		fmt.Fprintln(os.Stderr, "❌ No DNS configured (set dns.domain and a Hetzner DNS token)")
+		os.Exit(1)
+	}
+
+	// Only the registry and the zones are read, so no machine provider is needed
+	provisioner := forest.NewProvisionerWithDNS(nil, reg, dnsProv, cfg)
+	provisioner.SetReporter(forest.ReporterFunc(func(forest.Event) {}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if allZones {
+		list, err := dnsProv.ListZones(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Failed to list zones: %s\n", err)
+			os.Exit(1)
+		}
+		zones = nil
+		for _, z := range list {
+			zones = append(zones, z.Name)
+		}
+	}
+
+	issues, err := provisioner.ReconcileDNS(ctx, zones)
+	if err != nil && issues == nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	failed := err != nil
+
+	var fixed []forest.DNSChange
+	if fix {
+		var fixErr error
+		fixed, fixErr = fixDNSIssues(ctx, provisioner, issues, prune)
+		if fixErr != nil {
+			err = fixErr
+			failed = true
+		}
+	}
+
+	if jsonOutput {
+		output := map[string]interface{}{
+			"issues": issues,
+			"fixed":  fix,
+		}
+		if fix {
+			output["changes"] = fixed
+		}
+		if err != nil {
+			output["error"] = err.Error()
+		}
+		jsonData, _ := json.MarshalIndent(output, "", "  ")
+		fmt.Println(string(jsonData))
+	} else {
+		printDNSReconcileReport(issues, fixed, fix, prune)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		}
+	}
+
+	if failed || (!fix && len(issues) > 0) {
+		os.Exit(1)
+	}
+}
+
+// fixDNSIssues syncs the node records of each forest with issues, holding
+// the forest lock, and with prune removes records pointing at unknown
+// addresses. It returns the node record changes made.
+func fixDNSIssues(ctx context.Context, provisioner *forest.Provisioner, issues []forest.DNSIssue, prune bool) ([]forest.DNSChange, error) {
+	var forestIDs []string
+	for _, issue := range issues {
+		if issue.ForestID != "" && (len(forestIDs) == 0 || forestIDs[len(forestIDs)-1] != issue.ForestID) {
+			forestIDs = append(forestIDs, issue.ForestID)
+		}
+	}
+
+	var changes []forest.DNSChange
+	for _, id := range forestIDs {
+		lock, err := AcquireForestLock(id, "dns reconcile", lockfile.DefaultTTL)
+		if err != nil {
+			return changes, err
+		}
+		synced, err := provisioner.SyncDNS(ctx, id, false)
+		lock.Release()
+		changes = append(changes, synced...)
+		if err != nil {
+			return changes, fmt.Errorf("%s: %w", id, err)
+		}
+	}
+
+	if prune {
+		if err := provisioner.PruneDNS(ctx, issues); err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
+
+func printDNSReconcileReport(issues []forest.DNSIssue, fixed []forest.DNSChange, fix, prune bool) {
+	if len(issues) == 0 {
+		fmt.Println("✅ DNS records match the registry")
+		return
+	}
+
+	unknown := 0
+	fmt.Printf("\n🔍 DNS reconcile: %d issue%s\n", len(issues), ui.Plural(len(issues)))
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	for _, issue := range issues {
+		fmt.Printf("   • %s\n", issue)
+		if issue.Kind == forest.DNSUnknownIP {
+			unknown++
+		}
+	}
+	fmt.Println()
+
+	if !fix {
+		fmt.Println("💡 Fix node records with: morpheus dns reconcile --fix")
+		if unknown > 0 {
+			fmt.Println("   Add --prune to also remove records pointing at unknown addresses")
+		}
+		return
+	}
+	if len(fixed) > 0 {
+		fmt.Printf("🌐 Updated %d node record%s:\n", len(fixed), ui.Plural(len(fixed)))
+		for _, c := range fixed {
+			fmt.Printf("   %s\n", c)
+		}
+	}
+	switch {
+	case unknown > 0 && prune:
+		fmt.Printf("🧹 Removed %d record value%s pointing at unknown addresses\n", unknown, ui.Plural(unknown))
+	case unknown > 0:
+		fmt.Printf("⚠️  Kept %d record%s pointing at unknown addresses (use --prune to remove them)\n", unknown, ui.Plural(unknown))
+	}
+}
+
+func printDNSReconcileHelp() {
+	fmt.Println("Usage: morpheus dns reconcile [--zone ZONE]... [--all-zones] [--fix [--prune]] [--json]")
+	fmt.Println()
+	fmt.Println("Cross-reference the A/AAAA records of the DNS zones with the registry")
+	fmt.Println("of all forests and report:")
+	fmt.Println()
+	fmt.Println("  missing      nodes without their record in dns.domain")
+	fmt.Println("  wrong-ip     node records pointing at another address than the node's")
+	fmt.Println("  orphaned     records of nodes that are gone")
+	fmt.Println("  unknown-ip   records pointing at no registered node, floating IP or")
+	fmt.Println("               load balancer")
+	fmt.Println()
+	fmt.Println("Zones other than dns.domain may hold records of other services, so")
+	fmt.Println("records pointing at unknown addresses are only removed with --prune.")
+	fmt.Println("The command exits with status 1 if issues are found and not fixed.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --zone <zone>          Check this zone (repeatable; default: dns.domain)")
+	fmt.Println("  --all-zones            Check every zone of the DNS provider")
+	fmt.Println("  --fix                  Sync the node records of forests with issues")
+	fmt.Println("  --prune                With --fix, also remove unknown-ip records")
+	fmt.Println("  --json                 Output the issues as JSON")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus dns reconcile")
+	fmt.Println("  morpheus dns reconcile --all-zones --json")
+	fmt.Println("  morpheus dns reconcile --fix --prune")
+}
@@ -25,6 +25,8 @@ func handleDNSRecord() {
 		handleDNSRecordList()
 	case "delete":
 		handleDNSRecordDelete()
+	case "caa":
+		handleDNSRecordCAA()
 	case "help", "--help", "-h":
 		printDNSRecordHelp()
 	default:
@@ -41,9 +43,11 @@ func printDNSRecordHelp() {
 	fmt.Println("  morpheus dns record <command> [arguments]")
 	fmt.Println()
 	fmt.Println("Commands:")
-	fmt.Println("  create <fqdn> <type> <value>   Create a DNS record")
+	fmt.Println("  create <fqdn> <type> <value>   Create a DNS record, replacing one of the")
+	fmt.Println("                                 same name and type")
 	fmt.Println("  list <zone>                    List records in a zone")
 	fmt.Println("  delete <fqdn> <type>           Delete a DNS record")
+	fmt.Println("  caa <fqdn> [--issuer <ca>]     Show the CAA records that apply to a name")
 	fmt.Println()
 	fmt.Println("Flags:")
 	fmt.Println("  --ttl <seconds>      TTL for the record (default: 300)")
@@ -55,12 +59,23 @@ func printDNSRecordHelp() {
 	fmt.Println("  CNAME    Canonical name (alias)")
 	fmt.Println("  TXT      Text record")
 	fmt.Println("  SRV      Service record")
+	fmt.Println("  MX       Mail exchanger")
+	fmt.Println("  NS       Name server")
+	fmt.Println("  CAA      Certification authority authorization: [flags] <tag> <value>,")
+	fmt.Println("           tag issue, issuewild or iodef (flags: 0, or 128 for critical)")
+	fmt.Println()
+	fmt.Println("Names may be wildcards: *.app.example.com answers for every name under")
+	fmt.Println("app.example.com that has no records of its own. Quote them in the shell.")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  morpheus dns record create www.example.com A 1.2.3.4")
 	fmt.Println("  morpheus dns record create mail.example.com AAAA 2001:db8::1")
 	fmt.Println("  morpheus dns record create blog.example.com CNAME www.example.com")
 	fmt.Println("  morpheus dns record create www.example.com A 1.2.3.4 --ttl 3600")
+	fmt.Println("  morpheus dns record create '*.app.example.com' A 1.2.3.4")
+	fmt.Println("  morpheus dns record create example.com CAA 0 issue letsencrypt.org")
+	fmt.Println("  morpheus dns record create example.com CAA 'iodef mailto:security@example.com'")
+	fmt.Println("  morpheus dns record caa www.example.com --issuer letsencrypt.org")
 	fmt.Println("  morpheus dns record list example.com")
 	fmt.Println("  morpheus dns record list example.com --customer acme")
 	fmt.Println("  morpheus dns record delete www.example.com A")
@@ -132,12 +147,33 @@ func handleDNSRecordCreate() {
 	ttl, customerID := parseDNSRecordFlags(7)
 
 	// Validate record type
-	validTypes := map[string]bool{"A": true, "AAAA": true, "CNAME": true, "TXT": true, "SRV": true, "MX": true, "NS": true}
+	validTypes := map[string]bool{"A": true, "AAAA": true, "CNAME": true, "TXT": true, "SRV": true, "MX": true, "NS": true, "CAA": true}
 	if !validTypes[recordType] {
 		fmt.Fprintf(os.Stderr, "Invalid record type: %s\n", recordType)
-		fmt.Fprintln(os.Stderr, "Valid types: A, AAAA, CNAME, TXT, SRV, MX, NS")
+		fmt.Fprintln(os.Stderr, "Valid types: A, AAAA, CNAME, TXT, SRV, MX, NS, CAA")
 		os.Exit(1)
 	}
+	if err := dns.ValidateRecordName(fqdn); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	if recordType == string(dns.RecordTypeCAA) {
+		// The value may be given as several arguments: [flags] tag value
+		var parts []string
+		for _, arg := range os.Args[6:] {
+			if startsWithDash(arg) {
+				break
+			}
+			parts = append(parts, arg)
+		}
+		caa, err := dns.ParseCAA(strings.Join(parts, " "))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(1)
+		}
+		value = caa.String()
+	}
 
 	zone, name := parseZoneFromFQDN(fqdn)
 
@@ -153,8 +189,11 @@ func handleDNSRecordCreate() {
 	fmt.Printf("Creating DNS record: %s %s %s\n", fqdn, recordType, value)
 	fmt.Printf("  Zone: %s\n", zone)
 	fmt.Printf("  Name: %s\n", name)
+	if dns.IsWildcard(name) {
+		fmt.Printf("  Wildcard: matches names under %s without records of their own\n", strings.TrimPrefix(fqdn, "*."))
+	}
 
-	record, err := provider.CreateRecord(ctx, dns.CreateRecordRequest{
+	record, err := provider.UpsertRecord(ctx, dns.CreateRecordRequest{
 		Domain: zone,
 		Name:   name,
 		Type:   dns.RecordType(recordType),
@@ -273,6 +312,68 @@ func handleDNSRecordDelete() {
 	fmt.Printf("Record deleted successfully: %s %s\n", fqdn, recordType)
 }
 
+// handleDNSRecordCAA shows the CAA records in effect for a name, found at
+// the name or its closest parent that has any, and whether a CA may issue
+func handleDNSRecordCAA() {
+	if len(os.Args) < 5 || startsWithDash(os.Args[4]) {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus dns record caa <fqdn> [--issuer <ca-domain>]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Example:")
+		fmt.Fprintln(os.Stderr, "  morpheus dns record caa '*.app.example.com' --issuer letsencrypt.org")
+		os.Exit(1)
+	}
+
+	fqdn := strings.TrimSuffix(os.Args[4], ".")
+	var issuer string
+	for i := 5; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--issuer":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "--issuer requires a CA domain, e.g. letsencrypt.org")
+				os.Exit(1)
+			}
+			i++
+			issuer = os.Args[i]
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown argument: %s\n", os.Args[i])
+			os.Exit(1)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	records, at, err := dns.FindCAA(ctx, fqdn, dns.LookupCAA)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	if len(records) == 0 {
+		fmt.Printf("No CAA records for %s or its parents: any CA may issue\n", fqdn)
+	} else {
+		fmt.Printf("CAA records for %s (at %s):\n", fqdn, at)
+		for _, r := range records {
+			fmt.Printf("  %s\n", r)
+		}
+	}
+	if issuer == "" {
+		return
+	}
+
+	fmt.Println()
+	ok, err := dns.CAAPermits(records, issuer, dns.IsWildcard(fqdn))
+	switch {
+	case err != nil:
+		fmt.Printf("❌ %s may not issue for %s: %s\n", issuer, fqdn, err)
+		os.Exit(1)
+	case !ok:
+		fmt.Printf("❌ %s may not issue for %s\n", issuer, fqdn)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ %s may issue for %s\n", issuer, fqdn)
+}
+
 // formatFQDN formats a record name and zone into an FQDN
 func formatFQDN(name, zone string) string {
 	if name == "@" || name == "" {
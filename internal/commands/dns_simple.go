@@ -4,13 +4,15 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"sort"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/nimsforest/morpheus/pkg/config"
 	"github.com/nimsforest/morpheus/pkg/customer"
 	"github.com/nimsforest/morpheus/pkg/dns"
-	"github.com/nimsforest/morpheus/pkg/dns/hetzner"
 )
 
 // HandleDNSAdd handles "morpheus dns add <type> <domain>"
@@ -29,28 +31,50 @@ func HandleDNSAdd() {
 		os.Exit(1)
 	}
 
-	zoneType := os.Args[3] // "apex", "subdomain", or "gmail-mx"
+	zoneType := os.Args[3] // "apex", "subdomain", "email" or "gmail-mx"
 	domain := os.Args[4]
-	var customerID string
+	var customerID, emailProvider string
 
 	// Parse flags first
 	for i := 5; i < len(os.Args); i++ {
 		if os.Args[i] == "--customer" && i+1 < len(os.Args) {
 			i++
 			customerID = os.Args[i]
+		} else if os.Args[i] == "--provider" && i+1 < len(os.Args) {
+			i++
+			emailProvider = os.Args[i]
 		}
 	}
 
-	// Handle gmail-mx as a special case (adds MX records to existing zone)
+	if zoneType == "mta-sts" {
+		handleAddMTASTS(domain, os.Args[5:])
+		return
+	}
+	if zoneType == "bimi" {
+		handleAddBIMI(domain, os.Args[5:])
+		return
+	}
+
+	// Email records are added to an existing zone; gmail-mx is the
+	// original name of "email --provider gmail"
 	if zoneType == "gmail-mx" || zoneType == "gmail" {
-		handleAddGmailMX(domain, customerID)
+		emailProvider = "gmail"
+		zoneType = "email"
+	}
+	if zoneType == "email" {
+		if emailProvider == "" {
+			fmt.Fprintln(os.Stderr, "❌ --provider is required for email records")
+			fmt.Fprintf(os.Stderr, "   Providers: %s\n", strings.Join(dns.ListEmailProviderNames(), ", "))
+			os.Exit(1)
+		}
+		handleAddEmail(domain, emailProvider, customerID)
 		return
 	}
 
 	// Validate zone type
 	if zoneType != "apex" && zoneType != "subdomain" {
 		fmt.Fprintf(os.Stderr, "❌ Unknown zone type: %s\n", zoneType)
-		fmt.Fprintf(os.Stderr, "   Use 'apex', 'subdomain', or 'gmail-mx'\n\n")
+		fmt.Fprintf(os.Stderr, "   Use 'apex', 'subdomain', 'email', 'gmail-mx', 'mta-sts' or 'bimi'\n\n")
 		printDNSAddHelp()
 		os.Exit(1)
 	}
@@ -327,7 +351,7 @@ func startsWithDash(s string) bool {
 
 // saveDomainToConfig saves the DNS domain to config file
 func saveDomainToConfig(domain string) error {
-	configPath := config.FindConfigPath()
+	configPath := FindConfigPath()
 	if configPath == "" {
 		if err := config.EnsureConfigDir(); err != nil {
 			return err
@@ -345,51 +369,79 @@ func printDNSAddHelp() {
 	fmt.Println("Types:")
 	fmt.Println("  apex        You control the domain (update nameservers at registrar)")
 	fmt.Println("  subdomain   Delegated from parent (add NS records to parent)")
-	fmt.Println("  gmail-mx    Complete Gmail/Google Workspace setup (MX, SPF, DMARC)")
+	fmt.Println("  email       Hosted email setup (MX, SPF, DMARC, DKIM, autodiscover)")
+	fmt.Println("  gmail-mx    Same as: email --provider gmail")
+	fmt.Println("  mta-sts     MTA-STS and TLS-RPT records, optionally serving the policy")
+	fmt.Println("  bimi        BIMI logo record (needs DMARC at enforcement)")
 	fmt.Println()
 	fmt.Println("Options:")
 	fmt.Println("  --customer ID    Use customer-specific DNS token")
 	fmt.Println("  --help, -h       Show this help")
 	fmt.Println()
+	fmt.Println("email options:")
+	fmt.Printf("  --provider NAME  Email provider: %s\n", strings.Join(dns.ListEmailProviderNames(), ", "))
+	fmt.Println()
+	fmt.Println("mta-sts options:")
+	fmt.Println("  --mode MODE      Policy mode: testing (default), enforce or none")
+	fmt.Println("  --mx HOST        Allowed MX host or *.pattern (default: the domain's MX hosts)")
+	fmt.Println("  --max-age N      Seconds senders cache the policy (default: 604800)")
+	fmt.Println("  --rua ADDR       TLS report address (default: tls-reports@<domain>)")
+	fmt.Println("  --forest ID      Copy the policy to the forest's nodes and point mta-sts at it")
+	fmt.Println("  --cname HOST     Point mta-sts at object storage or a CDN serving the policy")
+	fmt.Println("  --out FILE       Also write the policy file locally")
+	fmt.Println()
+	fmt.Println("bimi options:")
+	fmt.Println("  --logo URL       HTTPS URL of the SVG Tiny PS logo (required)")
+	fmt.Println("  --vmc URL        HTTPS URL of the Verified Mark Certificate (PEM)")
+	fmt.Println("  --selector NAME  BIMI selector (default: default)")
+	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  morpheus dns add apex nimsforest.com")
 	fmt.Println("  morpheus dns add subdomain experiencenet.customer.com --customer acme")
 	fmt.Println("  morpheus dns add gmail-mx nimsforest.com")
+	fmt.Println("  morpheus dns add email nimsforest.com --provider fastmail")
+	fmt.Println("  morpheus dns add mta-sts nimsforest.com --forest forest-123")
+	fmt.Println("  morpheus dns add mta-sts nimsforest.com --mode enforce --cname policies.example-bucket.com")
+	fmt.Println("  morpheus dns add bimi nimsforest.com --logo https://nimsforest.com/logo.svg --vmc https://nimsforest.com/vmc.pem")
 	fmt.Println()
-	fmt.Println("Note: gmail-mx adds MX records, SPF, and DMARC. DKIM requires")
-	fmt.Println("      additional setup in Google Workspace Admin Console.")
+	fmt.Println("Note: email adds MX records, SPF, DMARC, and the DKIM and autodiscover")
+	fmt.Println("      records that are the same for every account. DKIM keys that are")
+	fmt.Println("      specific to an account need setup at the provider; the steps are")
+	fmt.Println("      printed after the records are added.")
 }
 
-// GmailMXRecords contains the standard Gmail/Google Workspace MX records
-// Note: Trailing dots are required to make these absolute FQDNs
-var GmailMXRecords = []struct {
-	Priority int
-	Server   string
-}{
-	{1, "ASPMX.L.GOOGLE.COM."},
-	{5, "ALT1.ASPMX.L.GOOGLE.COM."},
-	{5, "ALT2.ASPMX.L.GOOGLE.COM."},
-	{10, "ALT3.ASPMX.L.GOOGLE.COM."},
-	{10, "ALT4.ASPMX.L.GOOGLE.COM."},
-}
-
-// createGmailMXRRSet creates an RRSet with all Gmail MX records
-func createGmailMXRRSet(ctx context.Context, provider *hetzner.Provider, domain string) error {
-	// We need to create all MX records in a single RRSet via direct API call
-	// since the Cloud API treats name+type as a unique RRSet
-	records := make([]map[string]interface{}, len(GmailMXRecords))
-	for i, mx := range GmailMXRecords {
-		records[i] = map[string]interface{}{
-			"value": fmt.Sprintf("%d %s", mx.Priority, mx.Server),
+// printTXTResult prints whether the TXT record of a name was created,
+// given the error of the batch it was in, and returns 1 if it failed
+func printTXTResult(err error, name string) int {
+	if err == nil {
+		fmt.Printf(" ✓\n")
+		return 0
+	}
+	failed := dns.RecordSetErrors(err)
+	if len(failed) == 0 {
+		// The zone could not be read: nothing was created
+		fmt.Printf(" ❌ %s\n", err)
+		return 1
+	}
+	for _, e := range failed {
+		if e.Change.Name == name {
+			fmt.Printf(" ❌ %s\n", e.Err)
+			return 1
 		}
 	}
-
-	// Create the RRSet with all MX records
-	return provider.CreateRRSet(ctx, domain, "@", "MX", 3600, records)
+	fmt.Printf(" ✓\n")
+	return 0
 }
 
-// handleAddGmailMX adds Gmail/Google Workspace MX records and email authentication records
-func handleAddGmailMX(domain, customerID string) {
+// handleAddEmail adds the MX, SPF, DMARC, DKIM and autodiscover records of
+// a hosted email provider to an existing zone
+func handleAddEmail(domain, providerName, customerID string) {
+	emailProvider, err := dns.GetEmailProvider(providerName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
 	provider, err := getDNSProvider(customerID)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
@@ -407,7 +459,7 @@ func handleAddGmailMX(domain, customerID string) {
 		os.Exit(1)
 	}
 
-	fmt.Printf("\n📧 Setting up Gmail/Google Workspace for %s\n", domain)
+	fmt.Printf("\n📧 Setting up %s for %s\n", emailProvider.Title, domain)
 	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
 
 	totalRecords := 0
@@ -415,53 +467,49 @@ func handleAddGmailMX(domain, customerID string) {
 
 	// Add MX records - all MX records must be in a single RRSet
 	fmt.Printf("📮 Adding MX records:\n")
-	err = createGmailMXRRSet(ctx, provider, domain)
+	_, err = dns.ApplyRecordSet(ctx, provider, domain, dns.RecordSet{Name: "@", Type: "MX", TTL: 3600, Values: emailProvider.MXValues(domain)})
 	totalRecords++
 	if err != nil {
 		fmt.Printf("   ❌ %s\n", err)
 		failedRecords++
 	} else {
-		for _, mx := range GmailMXRecords {
-			fmt.Printf("   ✓ MX %s (priority %d)\n", mx.Server, mx.Priority)
+		for _, mx := range emailProvider.MX {
+			fmt.Printf("   ✓ MX %s (priority %d)\n", dns.ExpandEmailTemplate(mx.Server, domain), mx.Priority)
 		}
 	}
 
-	// Add SPF record
+	// Add SPF and DMARC records together, next to any TXT records the
+	// names already have (e.g. site verification)
+	spfValue := emailProvider.SPFRecord()
+	dmarcValue := fmt.Sprintf("\"v=DMARC1; p=none; rua=mailto:dmarc@%s\"", domain)
+	_, err = dns.CreateRecords(ctx, provider, []dns.CreateRecordRequest{
+		{Domain: domain, Name: "@", Type: dns.RecordTypeTXT, Value: spfValue, TTL: 3600},
+		{Domain: domain, Name: "_dmarc", Type: dns.RecordTypeTXT, Value: dmarcValue, TTL: 3600},
+	})
+	totalRecords += 2
+
 	fmt.Printf("\n🔐 Adding SPF record:\n")
-	spfValue := "\"v=spf1 include:_spf.google.com ~all\""
 	fmt.Printf("   TXT @ %s...", spfValue)
-	_, err = provider.CreateRecord(ctx, dns.CreateRecordRequest{
-		Domain: domain,
-		Name:   "@",
-		Type:   dns.RecordType("TXT"),
-		Value:  spfValue,
-		TTL:    3600,
-	})
-	totalRecords++
-	if err != nil {
-		fmt.Printf(" ❌ %s\n", err)
-		failedRecords++
-	} else {
-		fmt.Printf(" ✓\n")
-	}
+	failedRecords += printTXTResult(err, "@")
 
-	// Add DMARC record
 	fmt.Printf("\n📊 Adding DMARC record:\n")
-	dmarcValue := fmt.Sprintf("\"v=DMARC1; p=none; rua=mailto:dmarc@%s\"", domain)
 	fmt.Printf("   TXT _dmarc %s...", dmarcValue)
-	_, err = provider.CreateRecord(ctx, dns.CreateRecordRequest{
-		Domain: domain,
-		Name:   "_dmarc",
-		Type:   dns.RecordType("TXT"),
-		Value:  dmarcValue,
-		TTL:    3600,
-	})
-	totalRecords++
-	if err != nil {
-		fmt.Printf(" ❌ %s\n", err)
-		failedRecords++
-	} else {
-		fmt.Printf(" ✓\n")
+	failedRecords += printTXTResult(err, "_dmarc")
+
+	// DKIM and autodiscover records each own their name, so they replace
+	// whatever the name held
+	if reqs := emailProvider.RecordRequests(domain); len(reqs) > 0 {
+		fmt.Printf("\n🔑 Adding DKIM and autodiscover records:\n")
+		for i, req := range reqs {
+			totalRecords++
+			fmt.Printf("   %s %s %s (%s)...", req.Type, req.Name, req.Value, emailProvider.Records[i].Purpose)
+			if _, err := dns.ApplyRecordSet(ctx, provider, domain, dns.RecordSet{Name: req.Name, Type: req.Type, TTL: req.TTL, Values: []string{req.Value}}); err != nil {
+				fmt.Printf(" ❌ %s\n", err)
+				failedRecords++
+			} else {
+				fmt.Printf(" ✓\n")
+			}
+		}
 	}
 
 	// Summary
@@ -478,42 +526,55 @@ func handleAddGmailMX(domain, customerID string) {
 	}
 	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
 
-	// DKIM setup instructions
-	fmt.Println("🔑 DKIM Setup Required:")
-	fmt.Println()
-	fmt.Println("DKIM requires configuration in Google Workspace Admin Console:")
-	fmt.Println()
-	fmt.Println("1. Go to admin.google.com")
-	fmt.Println("2. Navigate to Apps → Google Workspace → Gmail → Authenticate email")
-	fmt.Println("3. Click 'Generate new record' for your domain")
-	fmt.Println("4. Copy the DKIM TXT record values provided by Google")
-	fmt.Println("5. Add the DKIM record using:")
-	fmt.Printf("   morpheus dns record create <selector>._domainkey.%s TXT \"<dkim-value>\"\n", domain)
-	fmt.Println()
-	fmt.Println("   Example:")
-	fmt.Printf("   morpheus dns record create google._domainkey.%s TXT \"v=DKIM1; k=rsa; p=MIGfMA...\"\n", domain)
-	fmt.Println()
-	fmt.Println("6. Return to Google Admin Console and click 'Start authentication'")
-	fmt.Println()
+	if len(emailProvider.Setup) > 0 {
+		fmt.Printf("🛠️  Finish the setup at %s:\n", emailProvider.Title)
+		fmt.Println()
+		step := 0
+		for _, line := range emailProvider.Setup {
+			line = dns.ExpandEmailTemplate(line, domain)
+			if strings.HasPrefix(line, " ") {
+				fmt.Printf("   %s\n", line)
+				continue
+			}
+			step++
+			fmt.Printf("%d. %s\n", step, line)
+		}
+		fmt.Println()
+	}
 
 	// Final instructions
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	fmt.Println("📋 What's been configured:")
 	fmt.Println()
-	fmt.Println("✓ MX records    - Routes email to Gmail servers")
-	fmt.Println("✓ SPF record    - Authorizes Gmail to send email for your domain")
+	fmt.Printf("✓ MX records    - Routes email to %s servers\n", emailProvider.Title)
+	fmt.Printf("✓ SPF record    - Authorizes %s to send email for your domain\n", emailProvider.Title)
 	fmt.Println("✓ DMARC record  - Email authentication policy (set to monitoring mode)")
-	fmt.Println("⚠ DKIM record   - Requires manual setup (see instructions above)")
+	if hasEmailRecord(emailProvider, "DKIM") {
+		fmt.Println("✓ DKIM records  - Point to the keys the provider publishes")
+	} else {
+		fmt.Println("⚠ DKIM record   - Requires manual setup (see instructions above)")
+	}
+	if hasEmailRecord(emailProvider, "Autodiscover") {
+		fmt.Println("✓ Autodiscover  - Lets mail clients configure themselves")
+	}
 	fmt.Println()
 	fmt.Println("📧 Your email will work once DNS propagates (usually within an hour).")
 	fmt.Println()
 	fmt.Println("Verify records with:")
 	fmt.Printf("   morpheus dns status %s\n", domain)
+	fmt.Printf("   morpheus dns audit-email %s\n", domain)
 	fmt.Println()
-	fmt.Println("Test email authentication:")
-	fmt.Println("   dig TXT " + domain + " +short")
-	fmt.Println("   dig TXT _dmarc." + domain + " +short")
-	fmt.Println()
+}
+
+// hasEmailRecord reports whether an email provider defines records for
+// a purpose, e.g. "DKIM"
+func hasEmailRecord(p *dns.EmailProvider, purpose string) bool {
+	for _, r := range p.Records {
+		if r.Purpose == purpose {
+			return true
+		}
+	}
+	return false
 }
 
 // HandleDNSVerify handles "morpheus dns verify <domain>"
@@ -527,16 +588,46 @@ func HandleDNSVerify() {
 		}
 	}
 
-	if len(os.Args) < 4 {
+	var domain string
+	wait := false
+	timeout := 30 * time.Minute
+	args := os.Args[3:]
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--wait":
+			wait = true
+		case "--timeout":
+			if i+1 >= len(args) {
+				fmt.Fprintln(os.Stderr, "❌ --timeout requires a duration, e.g. 30m")
+				os.Exit(1)
+			}
+			i++
+			d, err := time.ParseDuration(args[i])
+			if err != nil || d <= 0 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid --timeout %q: use a duration such as 30m or 2h\n", args[i])
+				os.Exit(1)
+			}
+			timeout = d
+		default:
+			if startsWithDash(args[i]) || domain != "" {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", args[i])
+				os.Exit(1)
+			}
+			domain = args[i]
+		}
+	}
+	if domain == "" {
 		printDNSVerifyHelp()
 		os.Exit(1)
 	}
 
-	domain := os.Args[3]
-
 	fmt.Printf("\n🔍 Verifying DNS delegation for %s\n", domain)
 	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
 
+	if wait && !waitForDelegation(domain, timeout) {
+		os.Exit(1)
+	}
+
 	fmt.Printf("Checking NS records...\n\n")
 
 	result := dns.VerifyNSDelegation(domain, customer.HetznerNameservers)
@@ -584,6 +675,11 @@ func HandleDNSVerify() {
 
 		// Check for Gmail MX records
 		checkGmailMX(domain)
+		fmt.Printf("💡 Full email deliverability check: morpheus dns audit-email %s\n\n", domain)
+
+		if !checkDNSSEC(domain) {
+			os.Exit(1)
+		}
 
 		fmt.Println("You can now create your infrastructure:")
 		fmt.Println("  morpheus plant")
@@ -596,6 +692,7 @@ func HandleDNSVerify() {
 		fmt.Printf("Matching:  %v\n", result.MatchingNS)
 		fmt.Printf("Missing:   %v\n", result.MissingNS)
 		fmt.Println()
+		printNSPropagation(domain)
 		fmt.Println("Some nameservers are configured but not all.")
 		fmt.Println("This may still work, but check your registrar settings.")
 		fmt.Println()
@@ -607,6 +704,7 @@ func HandleDNSVerify() {
 		fmt.Println()
 		fmt.Println("The domain's nameservers don't point to Hetzner.")
 		fmt.Println()
+		printNSPropagation(domain)
 		fmt.Println("For apex domains, update nameservers at your registrar.")
 		fmt.Println("For subdomains, add NS records to the parent domain.")
 		fmt.Println()
@@ -616,16 +714,109 @@ func HandleDNSVerify() {
 	}
 }
 
+// waitForDelegation polls public resolvers until they all return the
+// Hetzner nameservers for domain and, if the zone is reachable with the
+// configured token, its MX records. It prints every resolver whose answer
+// changes and returns false on timeout.
+func waitForDelegation(domain string, timeout time.Duration) bool {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type propagationCheck struct {
+		recordType dns.RecordType
+		values     []string
+	}
+	checks := []propagationCheck{{"NS", customer.HetznerNameservers}}
+	if mx := zoneMXValues(ctx, domain); len(mx) > 0 {
+		checks = append(checks, propagationCheck{"MX", mx})
+	}
+
+	fmt.Printf("⏳ Waiting up to %s for %s to propagate to %d resolvers...\n\n",
+		timeout, domain, len(dns.DefaultResolvers))
+	for _, check := range checks {
+		fmt.Printf("%s records:\n", check.recordType)
+		for _, v := range check.values {
+			fmt.Printf("   %s\n", v)
+		}
+		err := dns.WaitForRecordSet(ctx, domain, check.recordType, check.values, dns.WaitOptions{
+			Timeout:  timeout,
+			Progress: printPropagationProgress(),
+		})
+		if err != nil {
+			fmt.Println()
+			fmt.Fprintf(os.Stderr, "❌ %s records of %s not propagated: %s\n", check.recordType, domain, err)
+			return false
+		}
+		fmt.Printf("   ✓ All resolvers agree\n\n")
+	}
+	return true
+}
+
+// printPropagationProgress returns a WaitOptions.Progress that prints a
+// resolver's state when it changes, so a long wait shows what moved
+func printPropagationProgress() func(dns.WaitProgress) {
+	last := make(map[string]string)
+	return func(p dns.WaitProgress) {
+		state := make(map[string]string)
+		for _, r := range p.Matched {
+			state[r] = "✓ up to date"
+		}
+		for r, answer := range p.Pending {
+			state[r] = "⏳ " + answer
+		}
+		resolvers := make([]string, 0, len(state))
+		for r := range state {
+			resolvers = append(resolvers, r)
+		}
+		sort.Strings(resolvers)
+		for _, r := range resolvers {
+			if last[r] != state[r] {
+				fmt.Printf("   %s %-14s %s\n", time.Now().Format("15:04:05"), r, state[r])
+			}
+		}
+		last = state
+	}
+}
+
+// zoneMXValues returns the MX records of domain's zone at the DNS
+// provider, or nil if there is no token or no such zone
+func zoneMXValues(ctx context.Context, domain string) []string {
+	provider, err := getDNSProvider("")
+	if err != nil {
+		return nil
+	}
+	records, err := provider.ListRecords(ctx, domain)
+	if err != nil {
+		return nil
+	}
+	var values []string
+	for _, r := range records {
+		if r.Type == "MX" && (r.Name == "@" || r.Name == "") {
+			values = append(values, r.Value)
+		}
+	}
+	return values
+}
+
 func printDNSVerifyHelp() {
-	fmt.Println("Usage: morpheus dns verify <domain>")
+	fmt.Println("Usage: morpheus dns verify <domain> [--wait] [--timeout 30m]")
 	fmt.Println()
 	fmt.Println("Verify that NS delegation is configured correctly.")
 	fmt.Println("Checks if the domain's nameservers point to Hetzner DNS.")
-	fmt.Println("Also checks for Gmail/Google Workspace MX records if configured.")
+	fmt.Println("Also checks for Gmail/Google Workspace MX records if configured,")
+	fmt.Println("and that the DS records at the registrar match the zone's DNSSEC keys.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --wait           Poll public resolvers until they all return the Hetzner")
+	fmt.Println("                   nameservers (and the zone's MX records), then verify")
+	fmt.Println("  --timeout D      How long --wait polls (default: 30m)")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  morpheus dns verify nimsforest.com")
 	fmt.Println("  morpheus dns verify experiencenet.customer.com")
+	fmt.Println("  morpheus dns verify nimsforest.com --wait --timeout 2h")
 }
 
 // checkGmailMX verifies Gmail/Google Workspace MX records for a domain
@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/lockfile"
+)
+
+// HandleDNSSync handles "morpheus dns sync <forest-id>"
+func HandleDNSSync() {
+	var forestID string
+	dryRun := false
+	jsonOutput := false
+
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--dry-run":
+			dryRun = true
+		case "--json":
+			jsonOutput = true
+		case "--help", "-h":
+			printDNSSyncHelp()
+			os.Exit(0)
+		default:
+			if forestID != "" || startsWithDash(os.Args[i]) {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			forestID = os.Args[i]
+		}
+	}
+	if forestID == "" {
+		printDNSSyncHelp()
+		os.Exit(1)
+	}
+
+	provisioner, _ := forestProvisioner(forestID)
+	provisioner.SetReporter(forest.ReporterFunc(func(forest.Event) {}))
+
+	lock, err := AcquireForestLock(forestID, "dns sync", lockfile.DefaultTTL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	defer lock.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	changes, err := provisioner.SyncDNS(ctx, forestID, dryRun)
+	if jsonOutput {
+		output := map[string]interface{}{
+			"forest_id": forestID,
+			"dry_run":   dryRun,
+			"changes":   changes,
+		}
+		if err != nil {
+			output["error"] = err.Error()
+		}
+		jsonData, _ := json.MarshalIndent(output, "", "  ")
+		fmt.Println(string(jsonData))
+	} else {
+		printDNSSyncChanges(forestID, changes, dryRun)
+	}
+	if err != nil {
+		lock.Release()
+		if !jsonOutput {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func printDNSSyncChanges(forestID string, changes []forest.DNSChange, dryRun bool) {
+	if len(changes) == 0 {
+		fmt.Printf("✅ DNS records of %s are in sync\n", forestID)
+		return
+	}
+	verb := "Updated"
+	if dryRun {
+		verb = "Would update"
+	}
+	fmt.Printf("🌐 %s %d DNS record%s of %s:\n", verb, len(changes), ui.Plural(len(changes)), forestID)
+	for _, c := range changes {
+		fmt.Printf("   %s\n", c)
+	}
+	if dryRun {
+		fmt.Printf("\n💡 Apply with: morpheus dns sync %s\n", forestID)
+	}
+}
+
+func printDNSSyncHelp() {
+	fmt.Println("Usage: morpheus dns sync <forest-id> [--dry-run] [--json]")
+	fmt.Println()
+	fmt.Println("Reconcile the A/AAAA records of a forest's nodes with the registry.")
+	fmt.Println("Each node has records in dns.domain, named by naming.dns_record (default:")
+	fmt.Println("the server name), pointing at its addresses:")
+	fmt.Println()
+	fmt.Println("  + missing records are created")
+	fmt.Println("  ~ records pointing at old addresses are updated")
+	fmt.Println("  - records of nodes that are gone are deleted")
+	fmt.Println()
+	fmt.Println("Records are created on plant, grow and scale and deleted on teardown;")
+	fmt.Println("'morpheus refresh --write' syncs them after updating changed IPs.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --dry-run              Show the changes without making them")
+	fmt.Println("  --json                 Output the changes as JSON")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus dns sync forest-123 --dry-run")
+	fmt.Println("  morpheus dns sync forest-123")
+}
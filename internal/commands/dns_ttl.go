@@ -0,0 +1,140 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	dnshistory "github.com/nimsforest/morpheus/pkg/dns/history"
+)
+
+// HandleDNSTTL handles "morpheus dns ttl <domain> --set N | --restore"
+func HandleDNSTTL() {
+	var domain, customerID, recordType, name string
+	setTTL := 0
+	restore := false
+	dryRun := false
+
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--set":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --set requires a TTL in seconds")
+				os.Exit(1)
+			}
+			i++
+			ttl, err := strconv.Atoi(os.Args[i])
+			if err != nil || ttl <= 0 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid TTL: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			setTTL = ttl
+		case "--type":
+			if i+1 < len(os.Args) {
+				i++
+				recordType = strings.ToUpper(os.Args[i])
+			}
+		case "--name":
+			if i+1 < len(os.Args) {
+				i++
+				name = os.Args[i]
+			}
+		case "--restore":
+			restore = true
+		case "--dry-run":
+			dryRun = true
+		case "--customer":
+			if i+1 < len(os.Args) {
+				i++
+				customerID = os.Args[i]
+			}
+		case "--help", "-h":
+			printDNSTTLHelp()
+			os.Exit(0)
+		default:
+			if domain != "" || startsWithDash(os.Args[i]) {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			domain = os.Args[i]
+		}
+	}
+
+	if domain == "" || (setTTL == 0) == !restore {
+		printDNSTTLHelp()
+		os.Exit(1)
+	}
+
+	provider, err := getDNSProvider(customerID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	var changes []dnshistory.TTLChange
+	if restore {
+		fmt.Printf("\n⏱️  Restoring original TTLs in %s\n", domain)
+		changes, err = dnshistory.RestoreTTLs(ctx, provider, domain, dryRun)
+	} else {
+		var match func(string, string) bool
+		if recordType != "" || name != "" {
+			match = func(n, t string) bool {
+				return (recordType == "" || t == recordType) && (name == "" || n == name)
+			}
+		}
+		fmt.Printf("\n⏱️  Setting TTL to %ds in %s\n", setTTL, domain)
+		changes, err = dnshistory.SetTTLs(ctx, provider, domain, setTTL, match, dryRun)
+	}
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+	for _, c := range changes {
+		fmt.Printf("   %-30s %-6s %6d → %d\n", formatFQDN(c.Name, domain), c.Type, c.From, c.To)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println()
+	switch {
+	case len(changes) == 0:
+		fmt.Println("✅ Nothing to change.")
+	case dryRun:
+		fmt.Printf("Dry run: %d RRSet%s would be changed.\n", len(changes), ui.Plural(len(changes)))
+	case restore:
+		fmt.Printf("✅ Restored %d RRSet%s.\n", len(changes), ui.Plural(len(changes)))
+	default:
+		fmt.Printf("✅ Changed %d RRSet%s. Original TTLs are saved.\n", len(changes), ui.Plural(len(changes)))
+		fmt.Printf("💡 After the move, restore them with: morpheus dns ttl %s --restore\n", domain)
+	}
+}
+
+func printDNSTTLHelp() {
+	fmt.Println("Usage: morpheus dns ttl <domain> --set <seconds> [options]")
+	fmt.Println("       morpheus dns ttl <domain> --restore")
+	fmt.Println()
+	fmt.Println("Bulk-adjust record TTLs, e.g. lower them before a planned IP move so")
+	fmt.Println("the change propagates quickly. The original TTLs are saved when they")
+	fmt.Println("are first changed and put back with --restore.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --set <seconds>      New TTL for matching RRSets")
+	fmt.Println("  --type <type>        Only change RRSets of this type (e.g., A)")
+	fmt.Println("  --name <name>        Only change RRSets with this name (e.g., www)")
+	fmt.Println("  --restore            Restore the saved original TTLs")
+	fmt.Println("  --dry-run            Show what would change")
+	fmt.Println("  --customer <id>      Use customer-specific DNS token from customers.yaml")
+	fmt.Println()
+	fmt.Println("NS and SOA records are skipped unless selected with --type.")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus dns ttl example.com --set 300 --type A")
+	fmt.Println("  morpheus dns ttl example.com --restore")
+}
@@ -9,7 +9,7 @@ import (
 
 	"github.com/nimsforest/morpheus/pkg/customer"
 	"github.com/nimsforest/morpheus/pkg/dns"
-	"github.com/nimsforest/morpheus/pkg/dns/hetzner"
+	dnshistory "github.com/nimsforest/morpheus/pkg/dns/history"
 )
 
 func handleDNSZone() {
@@ -85,8 +85,9 @@ func parseDNSZoneFlags(startIdx int) (ttl int, customerID string) {
 	return
 }
 
-// getDNSProvider creates a Hetzner DNS provider based on the token source
-func getDNSProvider(customerID string) (*hetzner.Provider, error) {
+// getDNSProvider creates a Hetzner DNS provider based on the token source.
+// Record changes made through it are saved in the zone's change history.
+func getDNSProvider(customerID string) (*dnshistory.Provider, error) {
 	var token string
 
 	if customerID != "" {
@@ -113,6 +114,9 @@ func getDNSProvider(customerID string) (*hetzner.Provider, error) {
 		}
 
 		// Fall back to environment variable if no config or no token in config
+		if token == "" {
+			token = os.Getenv("HETZNER_DNS_TOKEN")
+		}
 		if token == "" {
 			token = os.Getenv("HETZNER_API_TOKEN")
 		}
@@ -122,7 +126,11 @@ func getDNSProvider(customerID string) (*hetzner.Provider, error) {
 		}
 	}
 
-	return hetzner.NewProvider(token)
+	provider, err := newHetznerDNSProvider(nil, token)
+	if err != nil {
+		return nil, err
+	}
+	return recordDNSChanges(provider), nil
 }
 
 func handleDNSZoneCreate() {
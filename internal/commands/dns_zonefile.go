@@ -0,0 +1,242 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/dns"
+	dnshistory "github.com/nimsforest/morpheus/pkg/dns/history"
+)
+
+// HandleDNSExport handles "morpheus dns export <domain> [--output FILE]"
+func HandleDNSExport() {
+	var domain, customerID, output string
+
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--output", "-o":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --output requires a file")
+				os.Exit(1)
+			}
+			i++
+			output = os.Args[i]
+		case "--customer":
+			if i+1 < len(os.Args) {
+				i++
+				customerID = os.Args[i]
+			}
+		case "--help", "-h":
+			printDNSZoneFileHelp()
+			os.Exit(0)
+		default:
+			if domain != "" || startsWithDash(os.Args[i]) {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			domain = os.Args[i]
+		}
+	}
+	if domain == "" {
+		printDNSZoneFileHelp()
+		os.Exit(1)
+	}
+
+	provider, err := getDNSProvider(customerID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	zone, err := provider.GetZone(ctx, domain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to get zone: %s\n", err)
+		os.Exit(1)
+	}
+	records, err := provider.ListRecords(ctx, domain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to list records: %s\n", err)
+		os.Exit(1)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "; Zone %s, exported by morpheus on %s\n", domain, time.Now().UTC().Format(time.RFC3339))
+	if err := dns.WriteZoneFile(&buf, domain, zone.TTL, records); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	if output == "" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to write zone file: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Exported %d record%s of %s to %s\n", len(records), ui.Plural(len(records)), domain, output)
+}
+
+// zoneFileRRSet is an RRSet read from a zone file
+type zoneFileRRSet struct {
+	Name, Type string
+	State      *dnshistory.RRSet
+}
+
+// HandleDNSImport handles "morpheus dns import <domain> <zonefile>"
+func HandleDNSImport() {
+	var domain, file, customerID string
+	dryRun := false
+	yes := false
+
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--customer":
+			if i+1 < len(os.Args) {
+				i++
+				customerID = os.Args[i]
+			}
+		case "--dry-run":
+			dryRun = true
+		case "--yes", "-y":
+			yes = true
+		case "--help", "-h":
+			printDNSZoneFileHelp()
+			os.Exit(0)
+		default:
+			if file != "" || (startsWithDash(os.Args[i]) && os.Args[i] != "-") {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			if domain == "" {
+				domain = os.Args[i]
+			} else {
+				file = os.Args[i]
+			}
+		}
+	}
+	if domain == "" || file == "" {
+		printDNSZoneFileHelp()
+		os.Exit(1)
+	}
+
+	var in io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		in = f
+	}
+	records, err := dns.ParseZoneFile(in, domain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s: %s\n", file, err)
+		os.Exit(1)
+	}
+
+	// Group the records into RRSets. The provider keeps its own SOA and
+	// apex NS records, which point at its name servers.
+	var rrsets []zoneFileRRSet
+	skipped := 0
+	for _, r := range records {
+		if r.Type == "SOA" || (r.Type == "NS" && r.Name == "@") {
+			skipped++
+			continue
+		}
+		var set *zoneFileRRSet
+		for i := range rrsets {
+			if rrsets[i].Name == r.Name && rrsets[i].Type == string(r.Type) {
+				set = &rrsets[i]
+			}
+		}
+		if set == nil {
+			rrsets = append(rrsets, zoneFileRRSet{Name: r.Name, Type: string(r.Type), State: &dnshistory.RRSet{TTL: r.TTL}})
+			set = &rrsets[len(rrsets)-1]
+		}
+		set.State.Values = append(set.State.Values, r.Value)
+	}
+
+	fmt.Printf("\n📥 Importing %s into %s\n", file, domain)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	for _, set := range rrsets {
+		fmt.Printf("   %-30s %-6s %6d  %s\n", formatFQDN(set.Name, domain), set.Type, set.State.TTL, strings.Join(set.State.Values, ", "))
+	}
+	if skipped > 0 {
+		fmt.Printf("\n   Skipped %d SOA/apex NS record%s: the zone keeps its own name servers.\n", skipped, ui.Plural(skipped))
+	}
+	fmt.Println()
+
+	if len(rrsets) == 0 {
+		fmt.Println("✅ Nothing to import.")
+		return
+	}
+	if dryRun {
+		fmt.Printf("Dry run: %d RRSet%s would be set.\n", len(rrsets), ui.Plural(len(rrsets)))
+		return
+	}
+	if !yes {
+		fmt.Println("RRSets with the same name and type are replaced; others are kept.")
+		fmt.Print("Type 'yes' to import: ")
+		var response string
+		fmt.Scanln(&response)
+		if response != "yes" {
+			fmt.Println("\nImport cancelled.")
+			return
+		}
+	}
+
+	provider, err := getDNSProvider(customerID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if _, err := provider.GetZone(ctx, domain); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Zone %s not found: %s\n", domain, err)
+		fmt.Fprintf(os.Stderr, "💡 Create it first with: morpheus dns add apex %s\n", domain)
+		os.Exit(1)
+	}
+	for i, set := range rrsets {
+		if err := provider.SetRRSet(ctx, domain, set.Name, set.Type, set.State); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Import stopped after %d of %d RRSets: %s %s: %s\n", i, len(rrsets), formatFQDN(set.Name, domain), set.Type, err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("✅ Imported %d RRSet%s. The changes are in the history and can be rolled back.\n", len(rrsets), ui.Plural(len(rrsets)))
+}
+
+func printDNSZoneFileHelp() {
+	fmt.Println("Usage: morpheus dns export <domain> [--output FILE]")
+	fmt.Println("       morpheus dns import <domain> <zonefile|-> [options]")
+	fmt.Println()
+	fmt.Println("export writes the zone's records as an RFC 1035 zone file, e.g. for")
+	fmt.Println("backups. import reads a zone file, e.g. exported from another DNS")
+	fmt.Println("provider, and creates its RRSets in the zone. RRSets of the same name")
+	fmt.Println("and type are replaced, others are kept. SOA and apex NS records are")
+	fmt.Println("skipped, as the zone keeps its own name servers.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --output, -o FILE    Write the zone file to FILE (export; default: stdout)")
+	fmt.Println("  --dry-run            Show the RRSets without importing them")
+	fmt.Println("  --yes, -y            Skip confirmation (import)")
+	fmt.Println("  --customer <id>      Use customer-specific DNS token from customers.yaml")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus dns export example.com -o example.com.zone")
+	fmt.Println("  morpheus dns import example.com example.com.zone --dry-run")
+}
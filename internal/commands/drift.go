@@ -0,0 +1,230 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/lockfile"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// HandleDiff handles the diff command (read-only drift report).
+func HandleDiff() {
+	runDrift("diff", false)
+}
+
+// HandleRefresh handles the refresh command.
+// Without --write it only reports drift, like diff.
+func HandleRefresh() {
+	runDrift("refresh", true)
+}
+
+func runDrift(command string, allowWrite bool) {
+	forestID := ""
+	write := false
+	jsonOutput := false
+
+	for i := 2; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--write":
+			if !allowWrite {
+				fmt.Fprintln(os.Stderr, "❌ --write is only supported by 'morpheus refresh'")
+				os.Exit(1)
+			}
+			write = true
+		case "--json":
+			jsonOutput = true
+		case "--help", "-h":
+			printDriftHelp(command)
+			os.Exit(0)
+		default:
+			if forestID != "" || startsWithDash(os.Args[i]) {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+				printDriftHelp(command)
+				os.Exit(1)
+			}
+			forestID = os.Args[i]
+		}
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load storage: %s\n", err)
+		os.Exit(1)
+	}
+
+	var forestIDs []string
+	if forestID != "" {
+		if _, err := reg.GetForest(forestID); err != nil {
+			fmt.Fprintf(os.Stderr, "Forest not found: %s\n", forestID)
+			os.Exit(1)
+		}
+		forestIDs = []string{forestID}
+		UseForestProject(cfg, reg, forestID)
+	} else {
+		// Forests of other Hetzner projects are not visible with this token
+		for _, f := range forestsInActiveProject(cfg, reg.ListForests()) {
+			forestIDs = append(forestIDs, f.ID)
+		}
+		sort.Strings(forestIDs)
+	}
+
+	machineProv, _, err := CreateMachineProvider(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	allDrifts := make(map[string][]forest.Drift)
+	total := 0
+	failed := false
+	for _, id := range forestIDs {
+		drifts, err := forest.DetectDrift(ctx, machineProv, reg, id)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s: %s\n", id, err)
+			failed = true
+			continue
+		}
+		allDrifts[id] = drifts
+		total += len(drifts)
+	}
+
+	// Node records follow the repaired registry
+	dnsChanges := make(map[string][]forest.DNSChange)
+	if write && total > 0 {
+		var provisioner *forest.Provisioner
+		if dnsProv := CreateDNSProvider(cfg); dnsProv != nil {
+			provisioner = forest.NewProvisionerWithDNS(machineProv, reg, dnsProv, cfg)
+			provisioner.SetReporter(forest.ReporterFunc(func(forest.Event) {}))
+		}
+		for _, id := range forestIDs {
+			if len(allDrifts[id]) == 0 {
+				continue
+			}
+			changes, err := repairForest(ctx, provisioner, reg, id, allDrifts[id])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "❌ %s: %s\n", id, err)
+				failed = true
+			}
+			if len(changes) > 0 {
+				dnsChanges[id] = changes
+			}
+		}
+	}
+
+	if jsonOutput {
+		output := map[string]interface{}{
+			"forests":     allDrifts,
+			"drift_count": total,
+			"written":     write && total > 0,
+		}
+		if len(dnsChanges) > 0 {
+			output["dns_changes"] = dnsChanges
+		}
+		jsonData, _ := json.MarshalIndent(output, "", "  ")
+		fmt.Println(string(jsonData))
+	} else {
+		printDriftReport(forestIDs, allDrifts, total, write)
+		for _, id := range forestIDs {
+			if changes := dnsChanges[id]; len(changes) > 0 {
+				fmt.Printf("🌐 %s: updated %d DNS record%s\n", id, len(changes), ui.Plural(len(changes)))
+				for _, c := range changes {
+					fmt.Printf("   %s\n", c)
+				}
+			}
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// repairForest applies drift repairs while holding the forest lock, then
+// syncs the forest's node records with provisioner, if DNS is configured
+// (provisioner is not nil). It returns the DNS changes made.
+func repairForest(ctx context.Context, provisioner *forest.Provisioner, reg storage.Registry, forestID string, drifts []forest.Drift) ([]forest.DNSChange, error) {
+	lock, err := AcquireForestLock(forestID, "refresh --write", lockfile.DefaultTTL)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+
+	if err := forest.RepairDrift(reg, forestID, drifts); err != nil {
+		return nil, fmt.Errorf("failed to update registry: %w", err)
+	}
+	if provisioner == nil {
+		return nil, nil
+	}
+	changes, err := provisioner.SyncDNS(ctx, forestID, false)
+	if err != nil {
+		return changes, fmt.Errorf("failed to sync DNS: %w", err)
+	}
+	return changes, nil
+}
+
+func printDriftReport(forestIDs []string, allDrifts map[string][]forest.Drift, total int, written bool) {
+	fmt.Printf("\n🔍 Drift report (%d forest%s)\n", len(forestIDs), ui.Plural(len(forestIDs)))
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+	for _, id := range forestIDs {
+		drifts, ok := allDrifts[id]
+		if !ok {
+			continue
+		}
+		if len(drifts) == 0 {
+			fmt.Printf("✅ %s: in sync\n", id)
+			continue
+		}
+		fmt.Printf("⚠️  %s: %d difference%s\n", id, len(drifts), ui.Plural(len(drifts)))
+		for _, d := range drifts {
+			fmt.Printf("   • %s\n", d)
+		}
+	}
+
+	fmt.Println()
+	switch {
+	case total == 0:
+		fmt.Println("✅ Registry matches provider state.")
+	case written:
+		fmt.Printf("✅ Registry updated (%d change%s applied).\n", total, ui.Plural(total))
+	default:
+		fmt.Println("💡 Run 'morpheus refresh --write' to update the registry from provider state.")
+	}
+}
+
+func printDriftHelp(command string) {
+	fmt.Printf("Usage: morpheus %s [forest-id] [options]\n", command)
+	fmt.Println()
+	fmt.Println("Compare the registry with actual provider state and report drift:")
+	fmt.Println("servers deleted out-of-band, changed IP addresses, changed labels,")
+	fmt.Println("and servers labelled for a forest but missing from the registry.")
+	fmt.Println()
+	fmt.Println("Options:")
+	if command == "refresh" {
+		fmt.Println("  --write    Update the registry to match the provider")
+	}
+	fmt.Println("  --json     Output in JSON format")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Printf("  morpheus %s                 # Check all forests\n", command)
+	fmt.Printf("  morpheus %s forest-123      # Check one forest\n", command)
+	if command == "refresh" {
+		fmt.Println("  morpheus refresh --write      # Repair the registry")
+	}
+}
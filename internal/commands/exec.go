@@ -0,0 +1,127 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+)
+
+// HandleExec handles the exec command.
+func HandleExec() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printExecHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	forestID := os.Args[2]
+	opts := sshutil.ExecOptions{Stdout: os.Stdout, Stderr: os.Stderr}
+	var command []string
+
+	for i := 3; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		if arg == "--" {
+			command = os.Args[i+1:]
+			break
+		}
+		if startsWithDash(arg) && i+1 >= len(os.Args) {
+			fmt.Fprintf(os.Stderr, "❌ %s requires a value\n", arg)
+			os.Exit(1)
+		}
+		switch arg {
+		case "--concurrency", "-c":
+			i++
+			n, err := strconv.Atoi(os.Args[i])
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid concurrency: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			opts.Concurrency = n
+		case "--timeout":
+			i++
+			d, err := time.ParseDuration(os.Args[i])
+			if err != nil || d <= 0 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid timeout: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			opts.Timeout = d
+		case "--user":
+			i++
+			opts.User = os.Args[i]
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+			fmt.Fprintln(os.Stderr, "Use 'morpheus exec --help' for usage")
+			os.Exit(1)
+		}
+	}
+
+	if len(command) == 0 {
+		fmt.Fprintln(os.Stderr, "❌ No command given")
+		fmt.Fprintln(os.Stderr, "Usage: morpheus exec <forest-id> [options] -- <command>")
+		os.Exit(1)
+	}
+
+	targets := forestTargets(forestID)
+
+	cfg, _ := LoadConfig()
+	opts.IdentityFile = sshIdentityFile(cfg)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	remote := strings.Join(command, " ")
+	fmt.Fprintf(os.Stderr, "🖥️  Running on %d node%s: %s\n", len(targets), ui.Plural(len(targets)), remote)
+	results := sshutil.RunParallel(ctx, targets, remote, opts)
+
+	// Exit with the highest exit code, so scripts see any failure
+	exitCode := 0
+	var failed []sshutil.ExecResult
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+			code := r.ExitCode
+			if code < 0 {
+				code = 255
+			}
+			exitCode = max(exitCode, code)
+		}
+	}
+
+	fmt.Fprintln(os.Stderr)
+	if len(failed) == 0 {
+		fmt.Fprintf(os.Stderr, "✅ Succeeded on all %d node%s\n", len(results), ui.Plural(len(results)))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "❌ Failed on %d of %d node%s:\n", len(failed), len(results), ui.Plural(len(results)))
+	for _, r := range failed {
+		fmt.Fprintf(os.Stderr, "   • %s (%s): %s\n", r.Target.Name, r.Target.Addr, r.Err)
+	}
+	os.Exit(exitCode)
+}
+
+func printExecHelp() {
+	fmt.Println("Usage: morpheus exec <forest-id> [options] -- <command>")
+	fmt.Println()
+	fmt.Println("Run a command over SSH on every node of a forest in parallel.")
+	fmt.Println("Output lines are prefixed with the node name. The exit status is the")
+	fmt.Println("highest exit status of any node (255 if a node could not be reached).")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --concurrency, -c N    Maximum nodes at a time (default: 10)")
+	fmt.Println("  --timeout D            Per-node timeout, e.g. 5m (default: none)")
+	fmt.Println("  --user NAME            Remote user (default: root)")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus exec forest-123 -- systemctl restart nimsforest")
+	fmt.Println("  morpheus exec forest-123 -c 2 -- 'apt-get update && apt-get -y upgrade'")
+}
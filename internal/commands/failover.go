@@ -0,0 +1,139 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/lockfile"
+)
+
+// HandleFailover handles the failover command.
+func HandleFailover() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printFailoverHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	forestID := os.Args[2]
+	target := ""
+	wait := false
+	timeout := 10 * time.Minute
+
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--to":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --to requires a node")
+				os.Exit(1)
+			}
+			i++
+			target = os.Args[i]
+		case "--wait":
+			wait = true
+		case "--timeout":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --timeout requires a duration, e.g. 10m")
+				os.Exit(1)
+			}
+			i++
+			d, err := time.ParseDuration(os.Args[i])
+			if err != nil || d <= 0 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid --timeout %q: use a duration such as 10m or 1h\n", os.Args[i])
+				os.Exit(1)
+			}
+			timeout = d
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+			fmt.Fprintln(os.Stderr, "Use 'morpheus failover --help' for usage")
+			os.Exit(1)
+		}
+	}
+
+	if target == "" {
+		fmt.Fprintln(os.Stderr, "❌ --to is required")
+		fmt.Fprintln(os.Stderr, "Usage: morpheus failover <forest-id> --to <node>")
+		os.Exit(1)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load storage: %s\n", err)
+		os.Exit(1)
+	}
+
+	UseForestProject(cfg, reg, forestID)
+	machineProv, _, err := CreateMachineProvider(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	lock, err := AcquireForestLock(forestID, "failover --to "+target, lockfile.DefaultTTL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	defer lock.Release()
+
+	var provisioner *forest.Provisioner
+	if dnsProv := CreateDNSProvider(cfg); dnsProv != nil {
+		provisioner = forest.NewProvisionerWithDNS(machineProv, reg, dnsProv, cfg)
+	} else {
+		provisioner = forest.NewProvisioner(machineProv, reg, cfg)
+	}
+
+	req := forest.FailoverRequest{ForestID: forestID, Target: target}
+	deadline := 5 * time.Minute
+	if wait {
+		req.Wait = timeout
+		deadline += timeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	defer cancel()
+
+	node, err := provisioner.Failover(ctx, req)
+	if err != nil {
+		lock.Release()
+		fmt.Fprintf(os.Stderr, "\n❌ Failover failed: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println()
+	fmt.Printf("✅ Forest %s now served by %s (%s)\n", forestID, node.ID, node.IP)
+}
+
+func printFailoverHelp() {
+	fmt.Println("Usage: morpheus failover <forest-id> --to <node> [--wait] [--timeout 10m]")
+	fmt.Println()
+	fmt.Println("Move the forest's floating IP to another node and point the")
+	fmt.Println("<forest-id>-primary DNS record at it. The forest needs a floating IP,")
+	fmt.Println("allocated with 'morpheus plant --floating-ip'.")
+	fmt.Println()
+	fmt.Println("The node can be given as server ID, IP address, node name")
+	fmt.Println("(forest-123-node-2) or node number (2).")
+	fmt.Println()
+	fmt.Println("When the node holding the floating IP is removed by scale, the IP")
+	fmt.Println("fails over to the forest's first node automatically.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --to <node>      Node to move the floating IP to (required)")
+	fmt.Println("  --wait           Poll public resolvers until the primary record points")
+	fmt.Println("                   at the node; fails if it does not in time")
+	fmt.Println("  --timeout D      How long --wait polls (default: 10m)")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus failover forest-123 --to 2")
+	fmt.Println("  morpheus failover forest-123 --to forest-123-node-3 --wait --timeout 5m")
+}
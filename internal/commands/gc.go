@@ -0,0 +1,166 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/forest"
+)
+
+// HandleGC handles the gc command: find and delete orphaned resources.
+func HandleGC() {
+	dryRun := false
+	yes := false
+	jsonOutput := false
+
+	for i := 2; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--dry-run":
+			dryRun = true
+		case "--yes", "-y":
+			yes = true
+		case "--json":
+			jsonOutput = true
+		case "--help", "-h":
+			printGCHelp()
+			os.Exit(0)
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+			printGCHelp()
+			os.Exit(1)
+		}
+	}
+	if jsonOutput && !dryRun && !yes {
+		fmt.Fprintln(os.Stderr, "❌ --json needs --dry-run or --yes")
+		os.Exit(1)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load storage: %s\n", err)
+		os.Exit(1)
+	}
+
+	machineProv, _, err := CreateMachineProvider(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	var provisioner *forest.Provisioner
+	if dnsProv := CreateDNSProvider(cfg); dnsProv != nil {
+		provisioner = forest.NewProvisionerWithDNS(machineProv, reg, dnsProv, cfg)
+	} else {
+		provisioner = forest.NewProvisioner(machineProv, reg, cfg)
+	}
+	configureInventory(provisioner, cfg)
+	configureSecrets(provisioner, cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	// Forests of other Hetzner projects are not visible with this token
+	orphans, err := provisioner.FindOrphans(ctx, forestsInActiveProject(cfg, reg.ListForests()))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	deleted := false
+	failed := 0
+	if !jsonOutput {
+		printOrphans(orphans)
+		if len(orphans) == 0 {
+			return
+		}
+		if dryRun {
+			fmt.Println("💡 Run 'morpheus gc' without --dry-run to delete them.")
+			return
+		}
+		if !yes {
+			fmt.Print("Type 'yes' to delete them: ")
+			var response string
+			fmt.Scanln(&response)
+			if response != "yes" {
+				fmt.Println("\n✅ Nothing deleted.")
+				return
+			}
+		}
+		fmt.Println()
+		failed = provisioner.DeleteOrphans(ctx, orphans)
+		deleted = true
+	} else if !dryRun && len(orphans) > 0 {
+		provisioner.SetReporter(forest.ReporterFunc(func(forest.Event) {}))
+		failed = provisioner.DeleteOrphans(ctx, orphans)
+		deleted = true
+	}
+
+	if jsonOutput {
+		output := map[string]interface{}{
+			"orphans": orphans,
+			"deleted": deleted,
+			"failed":  failed,
+		}
+		if orphans == nil {
+			output["orphans"] = []forest.Orphan{}
+		}
+		jsonData, _ := json.MarshalIndent(output, "", "  ")
+		fmt.Println(string(jsonData))
+	} else if failed == 0 {
+		fmt.Println()
+		fmt.Printf("✅ Deleted %d orphan%s.\n", len(orphans), ui.Plural(len(orphans)))
+	}
+
+	if failed > 0 {
+		if !jsonOutput {
+			fmt.Fprintf(os.Stderr, "\n❌ %d of %d orphan%s could not be deleted\n", failed, len(orphans), ui.Plural(len(orphans)))
+		}
+		os.Exit(1)
+	}
+}
+
+func printOrphans(orphans []forest.Orphan) {
+	fmt.Printf("\n🧹 Orphaned resources (%d)\n", len(orphans))
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	if len(orphans) == 0 {
+		fmt.Println("✅ Registry and provider are in sync.")
+		return
+	}
+	for _, o := range orphans {
+		fmt.Printf("   • %s\n", o.Title())
+		fmt.Printf("     %s\n", o.Reason)
+	}
+	fmt.Println()
+}
+
+func printGCHelp() {
+	fmt.Println("Usage: morpheus gc [options]")
+	fmt.Println()
+	fmt.Println("Find resources morpheus created that the registry no longer knows")
+	fmt.Println("about and offer to delete them: servers, volumes, load balancers,")
+	fmt.Println("floating IPs, placement groups, firewalls, SSH keys left over from key")
+	fmt.Println("rotation, and DNS records of forests that are gone. Registry entries")
+	fmt.Println("whose servers no longer exist are removed as well.")
+	fmt.Println()
+	fmt.Println("Forests that are still being planted or scaled are left alone.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --dry-run    Only list the orphans")
+	fmt.Println("  --yes, -y    Delete without asking")
+	fmt.Println("  --json       Output in JSON format (with --dry-run or --yes)")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus gc --dry-run")
+	fmt.Println("  morpheus gc")
+	fmt.Println("  morpheus gc --yes --json")
+}
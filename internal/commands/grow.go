@@ -9,10 +9,13 @@ import (
 	"time"
 
 	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/config"
 	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/lockfile"
 	"github.com/nimsforest/morpheus/pkg/machine/hetzner"
 	"github.com/nimsforest/morpheus/pkg/nats"
 	"github.com/nimsforest/morpheus/pkg/storage"
+	"github.com/nimsforest/morpheus/pkg/verify"
 )
 
 // nodeHealthInfo holds health info for display
@@ -39,6 +42,8 @@ func HandleGrow() {
 		fmt.Fprintln(os.Stderr, "  --auto           Non-interactive mode (auto-expand if needed)")
 		fmt.Fprintln(os.Stderr, "  --threshold N    Resource threshold percentage (default: 80)")
 		fmt.Fprintln(os.Stderr, "  --json           Output in JSON format")
+		fmt.Fprintln(os.Stderr, "  --verify FILE    Also run the checks in a verify suite after adding nodes")
+		fmt.Fprintln(os.Stderr, "  --override-budget  Grow even if limits.max_monthly_cost would be exceeded")
 		fmt.Fprintln(os.Stderr, "")
 		fmt.Fprintln(os.Stderr, "Examples:")
 		fmt.Fprintln(os.Stderr, "  morpheus grow forest-123              # Check health")
@@ -53,6 +58,8 @@ func HandleGrow() {
 	autoMode := false
 	jsonOutput := false
 	threshold := 80.0
+	overrideBudget := false
+	var checks []config.VerifyCheck
 
 	for i := 3; i < len(os.Args); i++ {
 		switch os.Args[i] {
@@ -70,11 +77,23 @@ func HandleGrow() {
 			autoMode = true
 		case "--json":
 			jsonOutput = true
+		case "--override-budget":
+			overrideBudget = true
 		case "--threshold":
 			if i+1 < len(os.Args) {
 				i++
 				fmt.Sscanf(os.Args[i], "%f", &threshold)
 			}
+		case "--verify":
+			if i+1 < len(os.Args) {
+				i++
+				suite, err := verify.LoadSuite(os.Args[i])
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+					os.Exit(1)
+				}
+				checks = append(checks, suite...)
+			}
 		}
 	}
 
@@ -101,7 +120,7 @@ func HandleGrow() {
 
 	// If --nodes specified, add nodes directly
 	if addNodes > 0 {
-		expandCluster(forestID, forestInfo, reg, addNodes)
+		expandCluster(forestID, forestInfo, reg, addNodes, checks, overrideBudget)
 		return
 	}
 
@@ -237,7 +256,7 @@ func HandleGrow() {
 	if autoMode {
 		if needsExpansion {
 			fmt.Println("🌱 Auto-expanding cluster...")
-			expandCluster(forestID, forestInfo, reg, 1)
+			expandCluster(forestID, forestInfo, reg, 1, checks, overrideBudget)
 		} else {
 			fmt.Println("✅ Cluster resources within threshold. No expansion needed.")
 		}
@@ -250,7 +269,7 @@ func HandleGrow() {
 		var response string
 		fmt.Scanln(&response)
 		if response == "y" || response == "Y" || response == "yes" {
-			expandCluster(forestID, forestInfo, reg, 1)
+			expandCluster(forestID, forestInfo, reg, 1, checks, overrideBudget)
 		} else {
 			fmt.Println("\n✅ No changes made.")
 		}
@@ -261,7 +280,7 @@ func HandleGrow() {
 }
 
 // expandCluster adds new nodes to the cluster
-func expandCluster(forestID string, forestInfo *storage.Forest, reg storage.Registry, nodeCount int) {
+func expandCluster(forestID string, forestInfo *storage.Forest, reg storage.Registry, nodeCount int, checks []config.VerifyCheck, overrideBudget bool) {
 	fmt.Println()
 	fmt.Printf("🌱 Adding %d node%s to cluster...\n", nodeCount, ui.Plural(nodeCount))
 
@@ -272,7 +291,8 @@ func expandCluster(forestID string, forestInfo *storage.Forest, reg storage.Regi
 		return
 	}
 
-	// Create provider
+	// Create provider with the credentials of the forest's project
+	UseForestProject(cfg, reg, forestID)
 	machineProv, _, err := CreateMachineProvider(cfg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
@@ -281,14 +301,21 @@ func expandCluster(forestID string, forestInfo *storage.Forest, reg storage.Regi
 
 	// Create provisioner
 	provisioner := forest.NewProvisioner(machineProv, reg, cfg)
-
-	// Determine server type from config
+	configureInventory(provisioner, cfg)
+	configureSecrets(provisioner, cfg)
+
+	// Determine server type: the forest's (it may have been resized), or
+	// the configured one
+	preferredType := forest.RecordedRequest(forestInfo).ServerType
+	if preferredType == "" {
+		preferredType = cfg.GetServerType()
+	}
 	serverType := ""
 	location := forestInfo.Location
 
 	if hetznerProv, ok := machineProv.(*hetzner.Provider); ok {
 		ctx := context.Background()
-		selectedType, availableLocations, err := hetznerProv.SelectBestServerType(ctx, cfg.GetServerType(), cfg.GetServerTypeFallback(), []string{location})
+		selectedType, availableLocations, err := hetznerProv.SelectBestServerType(ctx, preferredType, cfg.GetServerTypeFallback(), []string{location})
 		if err == nil {
 			serverType = selectedType
 			if len(availableLocations) > 0 {
@@ -296,42 +323,61 @@ func expandCluster(forestID string, forestInfo *storage.Forest, reg storage.Regi
 			}
 		}
 	}
+	// Spread forests keep spreading new nodes across their locations
+	if len(forestInfo.Locations) > 0 {
+		location = ""
+	}
 
 	if serverType == "" {
-		serverType = cfg.GetServerType()
+		serverType = preferredType
 	}
 
-	// Get existing nodes to determine new node numbers
-	existingNodes, _ := reg.GetNodes(forestID)
-	startIndex := len(existingNodes)
-
-	// Create provision request for additional nodes
-	req := forest.ProvisionRequest{
-		ForestID:   forestID,
-		NodeCount:  nodeCount,
-		Location:   location,
-		ServerType: serverType,
-		Image:      cfg.GetImage(),
+	nodeCost := forestInfo.ExpectedNodeCost
+	if _, ok := machineProv.(*hetzner.Provider); ok && nodeCost == 0 {
+		nodeCost = hetzner.GetEstimatedNodeCost(serverType, cfg.IsIPv4Enabled(), 0)
 	}
+	// Take the forest lock so grow cannot overlap with a scale operation
+	lock, err := AcquireForestLock(forestID, fmt.Sprintf("grow --nodes %d", nodeCount), lockfile.DefaultTTL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n❌ Cannot grow forest: %s\n", err)
+		return
+	}
+	defer lock.Release()
 
-	// Update the forest's node count
-	forestInfo.NodeCount += nodeCount
-	_ = reg.UpdateForest(forestInfo)
+	existingNodes, err := reg.GetNodes(forestID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get nodes: %s\n", err)
+		return
+	}
 
 	ctx := context.Background()
 
-	// Provision additional nodes (using a modified request that starts at the right index)
-	// Note: The provisioner will handle the node naming based on existing nodes
-	_ = startIndex // Used for future enhancement
-
-	if err := provisioner.Provision(ctx, req); err != nil {
+	// Growing is a scale operation without cooldown
+	_, err = provisioner.Scale(ctx, forest.ScaleRequest{
+		ForestID:    forestID,
+		TargetCount: len(existingNodes) + nodeCount,
+		Location:    location,
+		ServerType:  serverType,
+		Image:       cfg.GetImage(),
+		Verify:      checks,
+
+		NodeCost:       nodeCost,
+		OverrideBudget: overrideBudget,
+	})
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "\n❌ Expansion failed: %s\n", err)
+		printBudgetHint(err)
 		return
 	}
+	refreshMetadata(provisioner, forestID)
 
 	fmt.Println()
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-	fmt.Println("✅ Cluster expanded successfully!")
+	if f, err := reg.GetForest(forestID); err == nil && f.Status == forest.StatusDegraded {
+		fmt.Println("⚠️  Cluster expanded, but verification failed")
+	} else {
+		fmt.Println("✅ Cluster expanded successfully!")
+	}
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	fmt.Println()
 	fmt.Printf("💡 View updated cluster: morpheus status %s\n", forestID)
@@ -0,0 +1,178 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/health"
+)
+
+// HandleHealth handles the health command.
+func HandleHealth() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printHealthHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	forestID := os.Args[2]
+	jsonOutput := false
+	var opts health.Options
+
+	for i := 3; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		if startsWithDash(arg) && arg != "--json" && i+1 >= len(os.Args) {
+			fmt.Fprintf(os.Stderr, "❌ %s requires a value\n", arg)
+			os.Exit(1)
+		}
+		switch arg {
+		case "--json":
+			jsonOutput = true
+		case "--concurrency", "-c":
+			i++
+			n, err := strconv.Atoi(os.Args[i])
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid concurrency: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			opts.Concurrency = n
+		case "--timeout":
+			i++
+			d, err := time.ParseDuration(os.Args[i])
+			if err != nil || d <= 0 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid timeout: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			opts.Timeout = d
+		case "--user":
+			i++
+			opts.User = os.Args[i]
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+			fmt.Fprintln(os.Stderr, "Use 'morpheus health --help' for usage")
+			os.Exit(1)
+		}
+	}
+
+	targets := forestTargets(forestID)
+
+	cfg, _ := LoadConfig()
+	opts.IdentityFile = sshIdentityFile(cfg)
+	if cfg != nil {
+		// Without a NATS server or NimsForest nothing listens on the port
+		opts.ExpectNATS = cfg.Provisioning.NATS.Enabled || cfg.IsNimsForestInstallEnabled()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if !jsonOutput {
+		fmt.Printf("🩺 Checking %d node%s of %s...\n\n", len(targets), ui.Plural(len(targets)), forestID)
+	}
+	nodes := health.Probe(ctx, targets, opts)
+	status := health.Summarize(nodes)
+
+	if jsonOutput {
+		output := map[string]interface{}{
+			"forest_id": forestID,
+			"status":    status,
+			"nodes":     nodes,
+		}
+		jsonData, _ := json.MarshalIndent(output, "", "  ")
+		fmt.Println(string(jsonData))
+	} else {
+		printHealthTable(nodes)
+	}
+
+	if status == health.StatusFail {
+		os.Exit(1)
+	}
+}
+
+func printHealthTable(nodes []health.NodeHealth) {
+	columns := []string{health.CheckSSH, health.CheckCloudInit, health.CheckDisk, health.CheckLoad, health.CheckNATS}
+
+	fmt.Printf("  %-22s %-7s %-12s %-12s %-18s %s\n", "NODE", "SSH", "CLOUD-INIT", "DISK", "LOAD", "NATS")
+	fmt.Println("  ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	widths := []int{5, 10, 10, 16, 0}
+	for _, node := range nodes {
+		fmt.Printf("  %-22s", ui.TruncateID(node.Node, 22))
+		for i, name := range columns {
+			cell := "-"
+			if c := node.Result(name); c != nil {
+				cell = healthIcon(c.Status)
+				if c.Detail != "" && name != health.CheckSSH {
+					cell += " " + c.Detail
+				}
+			}
+			fmt.Printf(" %-*s", widths[i], cell)
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+
+	// The table has no room for why a node could not be reached
+	for _, node := range nodes {
+		if c := node.Result(health.CheckSSH); c != nil && c.Status == health.StatusFail {
+			fmt.Printf("   ❌ %s (%s): %s\n", node.Node, node.Addr, c.Detail)
+		}
+	}
+
+	healthy := 0
+	for _, node := range nodes {
+		if node.Status == health.StatusOK {
+			healthy++
+		}
+	}
+	switch health.Summarize(nodes) {
+	case health.StatusOK:
+		fmt.Printf("✅ All %d node%s healthy\n", len(nodes), ui.Plural(len(nodes)))
+	case health.StatusWarn:
+		fmt.Printf("⚠️  %d of %d node%s healthy, the rest with warnings\n", healthy, len(nodes), ui.Plural(len(nodes)))
+	default:
+		fmt.Printf("❌ %d of %d node%s healthy\n", healthy, len(nodes), ui.Plural(len(nodes)))
+	}
+}
+
+// healthIcon returns the icon shown for a check status
+func healthIcon(status health.Status) string {
+	switch status {
+	case health.StatusOK:
+		return "✅"
+	case health.StatusWarn:
+		return "⚠️"
+	default:
+		return "❌"
+	}
+}
+
+func printHealthHelp() {
+	fmt.Println("Usage: morpheus health <forest-id> [options]")
+	fmt.Println()
+	fmt.Println("Check every node of a forest: SSH reachable, cloud-init finished, NATS")
+	fmt.Println("port open, disk space and load. Exits with status 1 if any check failed,")
+	fmt.Println("so it can be used in scripts and monitoring.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --json                 Output results as JSON")
+	fmt.Println("  --concurrency, -c N    Maximum nodes at a time (default: 10)")
+	fmt.Println("  --timeout D            Per-node timeout, e.g. 1m (default: 30s)")
+	fmt.Println("  --user NAME            Remote user (default: root)")
+	fmt.Println()
+	fmt.Println("Thresholds:")
+	fmt.Println("  disk   warn at 80% used, fail at 90%")
+	fmt.Println("  load   warn at 1.0 per CPU, fail at 2.0")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus health forest-123")
+	fmt.Println("  morpheus health forest-123 --json | jq '.nodes[] | select(.status != \"ok\")'")
+}
@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// HandleHistory handles "morpheus history [forest-id|guard-id]": it shows
+// the changes made to forests and guards, and who made them
+func HandleHistory() {
+	var id, operatorFilter string
+	limit := 50
+	jsonOutput := false
+
+	for i := 2; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--operator":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --operator requires a value")
+				os.Exit(1)
+			}
+			i++
+			operatorFilter = os.Args[i]
+		case "--limit", "-n":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintf(os.Stderr, "❌ %s requires a number\n", os.Args[i])
+				os.Exit(1)
+			}
+			i++
+			n, err := strconv.Atoi(os.Args[i])
+			if err != nil || n < 0 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid limit: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			limit = n
+		case "--json":
+			jsonOutput = true
+		case "--help", "-h":
+			printHistoryHelp()
+			os.Exit(0)
+		default:
+			if id != "" || startsWithDash(os.Args[i]) {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			id = os.Args[i]
+		}
+	}
+
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load registry: %s\n", err)
+		os.Exit(1)
+	}
+	events, err := reg.Events(id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	if operatorFilter != "" {
+		var matching []storage.Event
+		for _, e := range events {
+			if e.Operator == operatorFilter {
+				matching = append(matching, e)
+			}
+		}
+		events = matching
+	}
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+
+	if jsonOutput {
+		if events == nil {
+			events = []storage.Event{}
+		}
+		jsonData, _ := json.MarshalIndent(events, "", "  ")
+		fmt.Println(string(jsonData))
+		return
+	}
+
+	what := "forests and guards"
+	if id != "" {
+		what = id
+	}
+	if len(events) == 0 {
+		fmt.Printf("No recorded changes to %s\n", what)
+		return
+	}
+
+	fmt.Printf("\n📜 Changes to %s (%d change%s)\n", what, len(events), ui.Plural(len(events)))
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	for _, e := range events {
+		fmt.Println(e)
+		if e.Command != "" {
+			fmt.Printf("      cmd: %s\n", e.Command)
+		}
+	}
+	fmt.Println()
+}
+
+func printHistoryHelp() {
+	fmt.Println("Usage: morpheus history [forest-id|guard-id] [options]")
+	fmt.Println()
+	fmt.Println("Show the changes made to forests, nodes and guards, oldest first, with")
+	fmt.Println("the operator who made each one. The operator is storage.operator in the")
+	fmt.Println("config, $MORPHEUS_OPERATOR, git's user.email, or user@host.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --operator NAME   Only show changes made by NAME")
+	fmt.Println("  --limit, -n N     Show the last N changes (default: 50, 0 for all)")
+	fmt.Println("  --json            Output as JSON")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus history")
+	fmt.Println("  morpheus history forest-1738123456")
+	fmt.Println("  morpheus history --operator alice@example.com")
+}
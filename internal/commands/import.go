@@ -0,0 +1,191 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/lockfile"
+)
+
+// HandleImport handles the import command.
+func HandleImport() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printImportHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	req := forest.ImportRequest{
+		ForestID: os.Args[2],
+		Label:    true,
+	}
+	jsonOutput := false
+
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--server":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --server requires a server ID")
+				os.Exit(1)
+			}
+			i++
+			for _, id := range strings.Split(os.Args[i], ",") {
+				if id = strings.TrimSpace(id); id != "" {
+					req.ServerIDs = append(req.ServerIDs, id)
+				}
+			}
+		case "--selector":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --selector requires key=value pairs")
+				os.Exit(1)
+			}
+			i++
+			selector, err := parseLabelSelector(os.Args[i])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+				os.Exit(1)
+			}
+			req.Selector = selector
+		case "--no-label":
+			req.Label = false
+		case "--dns":
+			req.CreateDNS = true
+		case "--json":
+			jsonOutput = true
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+			fmt.Fprintln(os.Stderr, "Use 'morpheus import --help' for usage")
+			os.Exit(1)
+		}
+	}
+
+	if len(req.ServerIDs) == 0 && len(req.Selector) == 0 {
+		fmt.Fprintln(os.Stderr, "❌ Specify servers with --server or --selector")
+		fmt.Fprintln(os.Stderr, "Usage: morpheus import <forest-id> --server ID | --selector key=value")
+		os.Exit(1)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load storage: %s\n", err)
+		os.Exit(1)
+	}
+
+	machineProv, _, err := CreateMachineProvider(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	var provisioner *forest.Provisioner
+	if dnsProv := CreateDNSProvider(cfg); dnsProv != nil && req.CreateDNS {
+		provisioner = forest.NewProvisionerWithDNS(machineProv, reg, dnsProv, cfg)
+	} else {
+		provisioner = forest.NewProvisioner(machineProv, reg, cfg)
+	}
+	configureInventory(provisioner, cfg)
+
+	lock, err := AcquireForestLock(req.ForestID, "import", lockfile.DefaultTTL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Forest %s is busy: %s\n", req.ForestID, err)
+		os.Exit(1)
+	}
+	defer lock.Release()
+
+	// In JSON mode, progress output goes to stderr so stdout stays parseable
+	stdout := os.Stdout
+	if jsonOutput {
+		os.Stdout = os.Stderr
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	result, err := provisioner.Import(ctx, req)
+
+	os.Stdout = stdout
+
+	if err != nil {
+		lock.Release()
+		fmt.Fprintf(os.Stderr, "❌ Import failed: %s\n", err)
+		if result != nil && len(result.Imported) > 0 {
+			fmt.Fprintf(os.Stderr, "   Imported before the failure: %s\n", strings.Join(result.Imported, ", "))
+		}
+		os.Exit(1)
+	}
+
+	if jsonOutput {
+		jsonData, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(jsonData))
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	if result.CreatedForest {
+		fmt.Printf("✅ Created forest %s\n", req.ForestID)
+	}
+	fmt.Printf("✅ Imported %d server%s into %s\n", len(result.Imported), ui.Plural(len(result.Imported)), req.ForestID)
+	for _, id := range result.Imported {
+		fmt.Printf("   • %s\n", id)
+	}
+	if len(result.Skipped) > 0 {
+		fmt.Printf("○  Already in forest: %s\n", strings.Join(result.Skipped, ", "))
+	}
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Println()
+	fmt.Printf("💡 View the forest: morpheus status %s\n", req.ForestID)
+}
+
+// parseLabelSelector parses "key=value,key=value" into a map
+func parseLabelSelector(s string) (map[string]string, error) {
+	selector := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid selector %q (expected key=value)", pair)
+		}
+		selector[key] = value
+	}
+	if len(selector) == 0 {
+		return nil, fmt.Errorf("empty selector")
+	}
+	return selector, nil
+}
+
+func printImportHelp() {
+	fmt.Println("Usage: morpheus import <forest-id> [options]")
+	fmt.Println()
+	fmt.Println("Adopt existing servers into the registry as a forest, so morpheus can")
+	fmt.Println("show their status, manage their DNS and tear them down. The forest is")
+	fmt.Println("created if it does not exist; otherwise the servers are added to it.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --server ID[,ID]     Import servers by ID (repeatable)")
+	fmt.Println("  --selector k=v[,k=v] Import all servers matching the labels")
+	fmt.Println("  --no-label           Don't add managed-by/forest-id labels to the servers")
+	fmt.Println("  --dns                Create DNS records for imported nodes")
+	fmt.Println("  --json               Output result as JSON")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus import legacy-nats --server 4711,4712")
+	fmt.Println("  morpheus import legacy-nats --selector env=prod,role=nats --dns")
+}
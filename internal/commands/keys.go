@@ -0,0 +1,378 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// HandleKeys handles the keys command.
+func HandleKeys() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printKeysHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	switch os.Args[2] {
+	case "rotate":
+		handleKeysRotate()
+	default:
+		fmt.Fprintf(os.Stderr, "❌ Unknown keys command: %s\n", os.Args[2])
+		printKeysHelp()
+		os.Exit(1)
+	}
+}
+
+func handleKeysRotate() {
+	var newPublicKeyPath string
+	var forestIDs []string
+	removeOld := false
+	force := false
+
+	for i := 3; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		switch arg {
+		case "--public-key", "--forest":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintf(os.Stderr, "❌ %s requires a value\n", arg)
+				os.Exit(1)
+			}
+			i++
+			if arg == "--public-key" {
+				newPublicKeyPath = os.Args[i]
+			} else {
+				forestIDs = append(forestIDs, os.Args[i])
+			}
+		case "--remove-old":
+			removeOld = true
+		case "--force":
+			force = true
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+			fmt.Fprintln(os.Stderr, "Use 'morpheus keys --help' for usage")
+			os.Exit(1)
+		}
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load storage: %s\n", err)
+		os.Exit(1)
+	}
+
+	// The current key reaches the nodes; the new one must before anything
+	// else changes
+	oldIdentity := sshIdentityFile(cfg)
+	if oldIdentity == "" {
+		fmt.Fprintln(os.Stderr, "❌ Current SSH private key not found")
+		fmt.Fprintln(os.Stderr, "   Set ssh_key_path in config.yaml to its public key")
+		os.Exit(1)
+	}
+	_, oldPublicKey, err := sshutil.ReadAndCalculateFingerprint(oldIdentity + ".pub")
+	if err != nil && removeOld {
+		fmt.Fprintf(os.Stderr, "❌ Cannot read current public key (needed for --remove-old): %s\n", err)
+		os.Exit(1)
+	}
+
+	if newPublicKeyPath == "" {
+		newPublicKeyPath, err = generateSSHKey()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Failed to generate key: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("🔑 Generated new key: %s\n", newPublicKeyPath)
+	}
+	newFingerprint, newPublicKey, err := sshutil.ReadAndCalculateFingerprint(newPublicKeyPath)
+	if err != nil || !IsValidSSHKey(newPublicKey) {
+		fmt.Fprintf(os.Stderr, "❌ Invalid public key %s: %v\n", newPublicKeyPath, err)
+		os.Exit(1)
+	}
+	newIdentity := sshutil.GetSSHPrivateKeyForPublicKey(newPublicKeyPath)
+	if newIdentity == "" {
+		fmt.Fprintf(os.Stderr, "❌ No private key next to %s (needed to verify the new key)\n", newPublicKeyPath)
+		os.Exit(1)
+	}
+	if oldPublicKey != "" && sshutil.SameHostKey(oldPublicKey, newPublicKey) {
+		fmt.Fprintln(os.Stderr, "❌ The new key is the current key")
+		os.Exit(1)
+	}
+
+	forests, pending, err := keyRotationForests(reg, forestIDs, newFingerprint)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	// ssh_key_path and the Hetzner key are shared by all forests, so they
+	// only switch once every forest with nodes accepts the new key
+	switchKey := len(pending) == 0 || force
+
+	var targets []sshutil.Target
+	for _, f := range forests {
+		nodes, err := reg.GetNodes(f.ID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Failed to get nodes of %s: %s\n", f.ID, err)
+			os.Exit(1)
+		}
+		targets = append(targets, nodeTargets(f, nodes)...)
+	}
+
+	ctx := context.Background()
+	fmt.Printf("🔄 Rotating SSH key on %d node%s in %d forest%s\n", len(targets), ui.Plural(len(targets)), len(forests), ui.Plural(len(forests)))
+	fmt.Printf("   New key: %s\n", newFingerprint)
+	fmt.Println()
+
+	if len(targets) > 0 {
+		fmt.Println("📤 Step 1: Adding the new key to authorized_keys (using the current key)...")
+		if !runKeyStep(ctx, targets, addAuthorizedKeyCommand(newPublicKey), oldIdentity) {
+			fmt.Fprintln(os.Stderr, "\n❌ Not all nodes have the new key; nothing else was changed")
+			os.Exit(1)
+		}
+
+		fmt.Println("🔍 Step 2: Verifying login with the new key...")
+		if !runKeyStep(ctx, targets, "true", newIdentity) {
+			fmt.Fprintln(os.Stderr, "\n❌ The new key does not work on all nodes; the current key still does")
+			os.Exit(1)
+		}
+
+		if removeOld && switchKey {
+			fmt.Println("🗑️  Step 3: Removing the old key from authorized_keys...")
+			if !runKeyStep(ctx, targets, removeAuthorizedKeyCommand(oldPublicKey), newIdentity) {
+				fmt.Fprintln(os.Stderr, "\n⚠️  The old key could not be removed from all nodes")
+			}
+		}
+	}
+
+	now := time.Now()
+	for _, f := range forests {
+		f.SSHKeyFingerprint = newFingerprint
+		f.SSHKeyRotatedAt = now
+		if err := reg.UpdateForest(f); err != nil {
+			fmt.Printf("⚠️  Failed to record rotation for %s: %s\n", f.ID, err)
+		}
+	}
+
+	if !switchKey {
+		fmt.Println()
+		fmt.Fprintf(os.Stderr, "❌ %d forest%s do not have the new key yet:\n", len(pending), ui.Plural(len(pending)))
+		for _, f := range pending {
+			fmt.Fprintf(os.Stderr, "   • %s (%s)\n", f.ID, f.Status)
+		}
+		fmt.Fprintln(os.Stderr, "   The nodes rotated so far accept both keys; ssh_key_path and the")
+		fmt.Fprintln(os.Stderr, "   Hetzner key were left unchanged. Rotate the forests above with")
+		fmt.Fprintln(os.Stderr, "   --forest, or use --force to switch anyway and lose access to them.")
+		if removeOld {
+			fmt.Fprintln(os.Stderr, "   The old key was not removed from any node.")
+		}
+		os.Exit(1)
+	}
+	if len(pending) > 0 {
+		fmt.Printf("⚠️  Switching keys with --force; these forests only accept the old key:\n")
+		for _, f := range pending {
+			fmt.Printf("   • %s (%s)\n", f.ID, f.Status)
+		}
+	}
+
+	// New servers get the key from the cloud provider
+	keyName := cfg.GetSSHKeyName()
+	for _, project := range keyRotationProjects(cfg, forests) {
+		label := project
+		if label == "" {
+			label = "default project"
+		}
+		token, err := cfg.GetHetznerToken(project)
+		if err != nil {
+			fmt.Printf("⚠️  Skipping Hetzner key update in %s: %s\n", label, err)
+			continue
+		}
+		hetznerProv, err := newHetznerProvider(cfg, token)
+		if err == nil {
+			_, err = hetznerProv.ReplaceSSHKey(ctx, keyName, newPublicKey)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Failed to update SSH key '%s' in Hetzner (%s): %s\n", keyName, label, err)
+			fmt.Fprintln(os.Stderr, "   The nodes already accept the new key; re-run to retry")
+			os.Exit(1)
+		}
+		fmt.Printf("☁️  Updated SSH key '%s' in Hetzner (%s)\n", keyName, label)
+	}
+
+	configPath := FindConfigPath()
+	if configPath != "" {
+		if err := config.SetConfigValue(configPath, "ssh_key_path", newPublicKeyPath); err != nil {
+			fmt.Printf("⚠️  Failed to update ssh_key_path in %s: %s\n", configPath, err)
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Println("✅ SSH key rotated")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("   Key: %s\n", newPublicKeyPath)
+	fmt.Printf("   Fingerprint: %s\n", newFingerprint)
+	if !removeOld && len(targets) > 0 {
+		fmt.Println()
+		fmt.Println("💡 The old key still works on the nodes (rotate with --remove-old to remove it)")
+	}
+}
+
+// keyRotationForests returns the forests to rotate the key on: the given
+// ones, or every forest with registered nodes, whatever its status. It
+// also returns the forests with nodes that are left out and do not have
+// the key with fingerprint yet, which would lose access if the configured
+// key were switched.
+func keyRotationForests(reg storage.Registry, ids []string, fingerprint string) ([]*storage.Forest, []*storage.Forest, error) {
+	var withNodes []*storage.Forest
+	for _, f := range reg.ListForests() {
+		nodes, err := reg.GetNodes(f.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get nodes of %s: %w", f.ID, err)
+		}
+		if len(nodes) > 0 {
+			withNodes = append(withNodes, f)
+		}
+	}
+	if len(ids) == 0 {
+		return withNodes, nil, nil
+	}
+
+	var forests []*storage.Forest
+	selected := make(map[string]bool)
+	for _, id := range ids {
+		f, err := reg.GetForest(id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("forest not found: %s", id)
+		}
+		forests = append(forests, f)
+		selected[f.ID] = true
+	}
+	var pending []*storage.Forest
+	for _, f := range withNodes {
+		if !selected[f.ID] && f.SSHKeyFingerprint != fingerprint {
+			pending = append(pending, f)
+		}
+	}
+	return forests, pending, nil
+}
+
+// keyRotationProjects returns the Hetzner projects whose SSH key needs
+// updating: the active one, and those of the forests
+func keyRotationProjects(cfg *config.Config, forests []*storage.Forest) []string {
+	projects := []string{cfg.GetHetznerProject()}
+	for _, f := range forests {
+		found := false
+		for _, p := range projects {
+			found = found || p == f.Project
+		}
+		if !found {
+			projects = append(projects, f.Project)
+		}
+	}
+	return projects
+}
+
+// runKeyStep runs a command on all targets with the given identity and
+// reports whether it succeeded everywhere
+func runKeyStep(ctx context.Context, targets []sshutil.Target, command, identity string) bool {
+	results := sshutil.RunParallel(ctx, targets, command, sshutil.ExecOptions{
+		IdentityFile: identity,
+		Timeout:      time.Minute,
+		Stderr:       os.Stderr,
+	})
+	ok := true
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Printf("   ❌ %s (%s): %s\n", r.Target.Name, r.Target.Addr, r.Err)
+			ok = false
+		}
+	}
+	if ok {
+		fmt.Printf("   ✅ Done on %d node%s\n", len(results), ui.Plural(len(results)))
+	}
+	return ok
+}
+
+// authorizedKey returns the type and key data of a public key, without the
+// comment, which is safe to quote in a shell command
+func authorizedKey(publicKey string) string {
+	fields := strings.Fields(publicKey)
+	return fields[0] + " " + fields[1]
+}
+
+// addAuthorizedKeyCommand returns a shell command that adds a key to
+// root's authorized_keys unless it is already there
+func addAuthorizedKeyCommand(publicKey string) string {
+	key := authorizedKey(publicKey)
+	return fmt.Sprintf("mkdir -p ~/.ssh && chmod 700 ~/.ssh && touch ~/.ssh/authorized_keys && "+
+		"(grep -qF '%s' ~/.ssh/authorized_keys || echo '%s morpheus' >> ~/.ssh/authorized_keys) && "+
+		"chmod 600 ~/.ssh/authorized_keys", key, key)
+}
+
+// removeAuthorizedKeyCommand returns a shell command that removes a key
+// from root's authorized_keys
+func removeAuthorizedKeyCommand(publicKey string) string {
+	key := authorizedKey(publicKey)
+	return fmt.Sprintf("grep -vF '%s' ~/.ssh/authorized_keys > ~/.ssh/authorized_keys.new; "+
+		"chmod 600 ~/.ssh/authorized_keys.new && mv ~/.ssh/authorized_keys.new ~/.ssh/authorized_keys", key)
+}
+
+// generateSSHKey creates a new ed25519 key pair in ~/.ssh with ssh-keygen
+// and returns the public key path
+func generateSSHKey() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Join(homeDir, ".ssh"), 0700); err != nil {
+		return "", err
+	}
+	stamp := time.Now().Format("20060102-150405")
+	path := filepath.Join(homeDir, ".ssh", "morpheus_ed25519_"+stamp)
+	out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", "morpheus-"+stamp, "-f", path).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("ssh-keygen: %s", strings.TrimSpace(string(out)))
+	}
+	return path + ".pub", nil
+}
+
+func printKeysHelp() {
+	fmt.Println("Usage: morpheus keys rotate [options]")
+	fmt.Println()
+	fmt.Println("Rotate the SSH key morpheus uses to reach forest nodes. The new key is")
+	fmt.Println("added to every node's authorized_keys using the current key and verified")
+	fmt.Println("before the key in Hetzner Cloud and ssh_key_path in config.yaml are")
+	fmt.Println("updated. The rotation is recorded on each forest in the registry.")
+	fmt.Println()
+	fmt.Println("The configured key is shared by all forests, so it is only switched once")
+	fmt.Println("every forest with nodes has the new key, whatever its status. Rotating")
+	fmt.Println("some forests with --forest adds the key to them; the switch follows once")
+	fmt.Println("the rest are rotated too.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --public-key PATH  Use this key pair (default: generate one in ~/.ssh)")
+	fmt.Println("  --forest ID        Only rotate on this forest (repeatable; default:")
+	fmt.Println("                     every forest with nodes)")
+	fmt.Println("  --remove-old       Remove the current key from authorized_keys")
+	fmt.Println("  --force            Switch the key even if forests do not have it yet;")
+	fmt.Println("                     they can then no longer be reached")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus keys rotate")
+	fmt.Println("  morpheus keys rotate --public-key ~/.ssh/id_ed25519_2026.pub --remove-old")
+}
@@ -40,7 +40,7 @@ func HandleList() {
 		fmt.Printf("%-20s %-7d %-9s %s %-11s %s\n",
 			f.ID,
 			f.NodeCount,
-			f.Location,
+			shortLocation(f),
 			statusIcon,
 			f.Status,
 			f.CreatedAt.Format("2006-01-02 15:04"),
@@ -48,5 +48,22 @@ func HandleList() {
 	}
 
 	fmt.Println()
+
+	// Warn about forests running images that are (nearly) out of support
+	warned := false
+	for _, f := range forests {
+		nodes, err := storageProv.GetNodes(f.ID)
+		if err != nil {
+			continue
+		}
+		for _, w := range imageWarnings(f, nodes) {
+			fmt.Printf("⚠️  %s: %s\n", f.ID, w.Advice)
+			warned = true
+		}
+	}
+	if warned {
+		fmt.Println()
+	}
+
 	fmt.Println("💡 Tip: Use 'morpheus status <forest-id>' to see detailed information")
 }
@@ -0,0 +1,282 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/lockfile"
+	"github.com/nimsforest/morpheus/pkg/wireguard"
+)
+
+// HandleMesh handles the mesh command.
+func HandleMesh() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printMeshHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	switch os.Args[2] {
+	case "join":
+		handleMeshJoin()
+	case "status":
+		handleMeshStatus()
+	default:
+		fmt.Fprintf(os.Stderr, "❌ Unknown mesh command: %s\n", os.Args[2])
+		printMeshHelp()
+		os.Exit(1)
+	}
+}
+
+func handleMeshJoin() {
+	if len(os.Args) < 4 || startsWithDash(os.Args[3]) {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus mesh join <forest-id> --guard <guard-id|host[:port]> [options]")
+		os.Exit(1)
+	}
+	req := forest.MeshJoinRequest{ForestID: os.Args[3]}
+	for i := 4; i < len(os.Args); i++ {
+		switch arg := os.Args[i]; arg {
+		case "--guard", "--guard-key", "--guard-ip", "--mesh-cidr", "--route":
+			if i+1 >= len(os.Args) || startsWithDash(os.Args[i+1]) {
+				fmt.Fprintf(os.Stderr, "❌ %s requires a value\n", arg)
+				os.Exit(1)
+			}
+			i++
+			value := os.Args[i]
+			switch arg {
+			case "--guard":
+				req.Guard = value
+			case "--guard-key":
+				req.GuardKey = value
+			case "--guard-ip":
+				if _, err := netip.ParseAddr(value); err != nil {
+					fmt.Fprintf(os.Stderr, "❌ Invalid --guard-ip %q: want the guard's mesh IP\n", value)
+					os.Exit(1)
+				}
+				req.GuardMeshIP = value
+			case "--mesh-cidr":
+				if _, err := netip.ParsePrefix(value); err != nil {
+					fmt.Fprintf(os.Stderr, "❌ Invalid --mesh-cidr %q\n", value)
+					os.Exit(1)
+				}
+				req.MeshCIDR = value
+			case "--route":
+				prefix, err := netip.ParsePrefix(value)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "❌ Invalid --route %q: want a CIDR such as 10.10.0.0/16\n", value)
+					os.Exit(1)
+				}
+				req.Routes = append(req.Routes, prefix.Masked().String())
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+			fmt.Fprintln(os.Stderr, "Use 'morpheus mesh --help' for usage")
+			os.Exit(1)
+		}
+	}
+	if req.Guard == "" {
+		fmt.Fprintln(os.Stderr, "❌ --guard is required")
+		fmt.Fprintln(os.Stderr, "💡 List guards with: morpheus-azureguard list")
+		os.Exit(1)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load storage: %s\n", err)
+		os.Exit(1)
+	}
+	if _, err := reg.GetForest(req.ForestID); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Forest not found: %s\n", req.ForestID)
+		os.Exit(1)
+	}
+
+	lock, err := AcquireForestLock(req.ForestID, "mesh join", lockfile.DefaultTTL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	defer lock.Release()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Joining only talks to the nodes and the guard, not the machine provider
+	provisioner := forest.NewProvisioner(nil, reg, cfg)
+	configureSecrets(provisioner, cfg)
+	fmt.Printf("🕸️  Joining %s into the WireGuard mesh through %s\n", req.ForestID, req.Guard)
+	result, err := provisioner.JoinMesh(ctx, req)
+	if result != nil && len(result.GuardPeers) > 0 && !result.GuardUpdated {
+		fmt.Printf("\n⚠️  Add the node%s to the config of guard %s:\n\n", ui.Plural(len(result.GuardPeers)), result.Guard.Name)
+		for _, peer := range result.GuardPeers {
+			fmt.Println(peer.Render())
+		}
+		fmt.Println("💡 Or install the full config with: morpheus-azureguard rotate")
+	}
+	if err != nil {
+		lock.Release()
+		fmt.Fprintf(os.Stderr, "\n❌ %s\n", err)
+		if strings.Contains(err.Error(), "is not known") {
+			fmt.Fprintln(os.Stderr, "💡 For guards the registry does not know, give --guard-key, --guard-ip and --mesh-cidr")
+		}
+		os.Exit(1)
+	}
+	if cfg.Provisioning.NATS.Enabled {
+		fmt.Printf("\n💡 Move the NATS routes onto the mesh with: morpheus nats bootstrap %s\n", req.ForestID)
+	}
+}
+
+func handleMeshStatus() {
+	usage := "Usage: morpheus mesh status <forest-id> [--dead-after <duration>]"
+	if len(os.Args) < 4 || startsWithDash(os.Args[3]) {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+	forestID := os.Args[3]
+	var deadAfter time.Duration
+	for i := 4; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--dead-after":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --dead-after requires a value")
+				os.Exit(1)
+			}
+			i++
+			d, err := time.ParseDuration(os.Args[i])
+			if err != nil || d <= 0 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid --dead-after %q: want a duration such as 5m\n", os.Args[i])
+				os.Exit(1)
+			}
+			deadAfter = d
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(1)
+		}
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load storage: %s\n", err)
+		os.Exit(1)
+	}
+	if _, err := reg.GetForest(forestID); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Forest not found: %s\n", forestID)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	provisioner := forest.NewProvisioner(nil, reg, cfg)
+	status, err := provisioner.MeshStatus(ctx, forestID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		fmt.Fprintf(os.Stderr, "💡 Join the nodes with: morpheus mesh join %s --guard <guard>\n", forestID)
+		os.Exit(1)
+	}
+
+	fmt.Printf("🕸️  Mesh of %s, as %d host%s see it\n", forestID, len(status.Hosts), ui.Plural(len(status.Hosts)))
+	dead, idle, unreachable := 0, 0, 0
+	for _, h := range status.Hosts {
+		fmt.Printf("\n%s %s (%s)\n", h.Kind, h.Name, h.MeshIP)
+		if h.Err != nil {
+			fmt.Printf("  ❌ %s\n", h.Err)
+			unreachable++
+			continue
+		}
+		if len(h.Dump.Peers) == 0 {
+			fmt.Println("  ⚠️  No peers")
+			continue
+		}
+		fmt.Printf("     %-24s %-24s %-12s %-10s %s\n", "PEER", "ENDPOINT", "HANDSHAKE", "RX", "TX")
+		for _, peer := range h.Dump.Peers {
+			icon := "✅"
+			switch peer.Health(status.Time, deadAfter) {
+			case wireguard.PeerDead:
+				icon = "❌"
+				dead++
+			case wireguard.PeerIdle:
+				icon = "💤"
+				idle++
+			}
+			handshake := "never"
+			if !peer.LatestHandshake.IsZero() {
+				handshake = ui.FormatDuration(status.Time.Sub(peer.LatestHandshake)) + " ago"
+			}
+			endpoint := peer.Endpoint
+			if endpoint == "" {
+				endpoint = "-"
+			}
+			fmt.Printf("  %s %-24s %-24s %-12s %-10s %s\n", icon, status.PeerName(peer.PublicKey), endpoint, handshake,
+				ui.FormatBytes(peer.RxBytes), ui.FormatBytes(peer.TxBytes))
+		}
+	}
+
+	fmt.Println("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	if idle > 0 {
+		fmt.Printf("💤 %d idle peer%s: no traffic and no keepalive, so no session to check\n", idle, ui.Plural(idle))
+	}
+	if dead == 0 && unreachable == 0 {
+		fmt.Println("✅ All peers have a live session")
+		return
+	}
+	if unreachable > 0 {
+		fmt.Printf("❌ %d host%s could not be asked\n", unreachable, ui.Plural(unreachable))
+	}
+	if dead > 0 {
+		fmt.Printf("❌ %d dead peer%s: no handshake, or none for longer than keepalives allow\n", dead, ui.Plural(dead))
+	}
+	os.Exit(1)
+}
+
+func printMeshHelp() {
+	fmt.Println("Usage: morpheus mesh join <forest-id> --guard <guard-id|host[:port]> [options]")
+	fmt.Println("       morpheus mesh status <forest-id> [--dead-after <duration>]")
+	fmt.Println()
+	fmt.Println("Join the nodes of a forest into a WireGuard mesh with a guard. Every node")
+	fmt.Println("gets a key, kept in the secret store, a mesh IP, kept in the registry, and a")
+	fmt.Println("config with the guard and the other nodes as peers, installed over SSH.")
+	fmt.Println("Guards in the registry get the nodes as peers over SSH as well; for others,")
+	fmt.Println("the [Peer] sections to add are printed. Run it again after scaling the")
+	fmt.Println("forest; nodes keep their keys and mesh IPs. Once all nodes joined, NATS")
+	fmt.Println("bootstrap routes the cluster over the mesh.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --guard <id|host[:port]>  Guard to join through: an ID from the registry or its endpoint")
+	fmt.Println("  --route <cidr>            Network behind the guard the nodes reach (repeatable)")
+	fmt.Println("  --guard-key <key>         Guard's public key (default: from the registry)")
+	fmt.Println("  --guard-ip <ip>           Guard's mesh IP (default: from its recorded config)")
+	fmt.Println("  --mesh-cidr <cidr>        Mesh CIDR (default: from the guard's mesh IP)")
+	fmt.Println()
+	fmt.Println("status asks every node of the forest in the mesh, and the guards they peer")
+	fmt.Println("with, for 'wg show' over SSH, and shows each peer's last handshake and")
+	fmt.Println("transfer. A peer is dead if it never had a handshake, or sends keepalives")
+	fmt.Println("but had none for --dead-after (default 3m, when WireGuard drops a session).")
+	fmt.Println("It exits non-zero if a peer is dead or a host cannot be asked.")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus mesh join forest-1 --guard guard-weu-1 --route 10.10.0.0/16")
+	fmt.Println("  morpheus mesh join forest-1 --guard 203.0.113.1:51820 --guard-key <key> \\")
+	fmt.Println("      --guard-ip 10.200.0.1 --mesh-cidr 10.200.0.0/16")
+	fmt.Println("  morpheus mesh status forest-1")
+}
@@ -0,0 +1,194 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/lockfile"
+)
+
+// HandleMetadata handles the metadata command.
+func HandleMetadata() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printMetadataHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	forestID := os.Args[2]
+	if startsWithDash(forestID) {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus metadata <forest-id> [set|unset|refresh]")
+		os.Exit(1)
+	}
+	args := os.Args[3:]
+	if len(args) == 0 || args[0] == "--json" {
+		handleMetadataShow(forestID, args)
+		return
+	}
+
+	switch args[0] {
+	case "set", "unset":
+		if len(args) < 2 {
+			fmt.Fprintf(os.Stderr, "Usage: morpheus metadata <forest-id> %s %s...\n", args[0], map[string]string{"set": "KEY=VALUE", "unset": "KEY"}[args[0]])
+			os.Exit(1)
+		}
+		set := make(map[string]string)
+		var unset []string
+		for _, arg := range args[1:] {
+			if args[0] == "unset" {
+				unset = append(unset, arg)
+				continue
+			}
+			key, value, err := parseMetadataValue(arg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+				os.Exit(1)
+			}
+			set[key] = value
+		}
+		handleMetadataUpdate(forestID, set, unset)
+	case "refresh":
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, "Usage: morpheus metadata <forest-id> refresh")
+			os.Exit(1)
+		}
+		handleMetadataUpdate(forestID, nil, nil)
+	default:
+		fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", args[0])
+		fmt.Fprintln(os.Stderr, "Use 'morpheus metadata --help' for usage")
+		os.Exit(1)
+	}
+}
+
+func handleMetadataShow(forestID string, args []string) {
+	if len(args) > 1 {
+		fmt.Fprintf(os.Stderr, "Unknown argument: %s\n", args[1])
+		os.Exit(1)
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load storage: %s\n", err)
+		os.Exit(1)
+	}
+	f, err := reg.GetForest(forestID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get forest info: %s\n", err)
+		os.Exit(1)
+	}
+	metadata := forest.NodeMetadata(cfg, f, 0, f.NodeCount, f.Location, nil)
+
+	if len(args) == 1 {
+		data, _ := json.MarshalIndent(metadata.Values, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	if len(metadata.Values) == 0 {
+		fmt.Printf("No metadata values for %s\n", forestID)
+		fmt.Printf("💡 Set some with: morpheus metadata %s set KEY=VALUE\n", forestID)
+		return
+	}
+	fmt.Printf("🏷️  Metadata of %s (%s on every node):\n\n", forestID, forest.MetadataPath)
+	for _, key := range slices.Sorted(maps.Keys(metadata.Values)) {
+		source := ""
+		if _, ok := f.Metadata[key]; !ok {
+			source = "  (from config)"
+		}
+		fmt.Printf("  %s=%s%s\n", key, metadata.Values[key], source)
+	}
+}
+
+// handleMetadataUpdate records changed values with the forest and rewrites
+// the metadata file on its nodes
+func handleMetadataUpdate(forestID string, set map[string]string, unset []string) {
+	provisioner, reg := forestProvisioner(forestID)
+
+	lock, err := AcquireForestLock(forestID, "metadata", lockfile.DefaultTTL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	defer lock.Release()
+
+	if len(set)+len(unset) > 0 {
+		f, err := reg.GetForest(forestID)
+		if err != nil {
+			lock.Release()
+			fmt.Fprintf(os.Stderr, "Failed to get forest info: %s\n", err)
+			os.Exit(1)
+		}
+		if f.Metadata == nil {
+			f.Metadata = make(map[string]string)
+		}
+		maps.Copy(f.Metadata, set)
+		for _, key := range unset {
+			delete(f.Metadata, key)
+		}
+		if err := reg.UpdateForest(f); err != nil {
+			lock.Release()
+			fmt.Fprintf(os.Stderr, "❌ Failed to update forest: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := provisioner.RefreshMetadata(context.Background(), forestID); err != nil {
+		lock.Release()
+		fmt.Fprintf(os.Stderr, "\n❌ %s\n", err)
+		fmt.Fprintf(os.Stderr, "💡 Retry with: morpheus metadata %s refresh\n", forestID)
+		os.Exit(1)
+	}
+	fmt.Printf("\n✅ Metadata of %s updated on all nodes\n", forestID)
+}
+
+// refreshMetadata tells the nodes of a forest about changed peers after a
+// scale operation. Failures only warn: the forest itself is fine.
+func refreshMetadata(provisioner *forest.Provisioner, forestID string) {
+	if err := provisioner.RefreshMetadata(context.Background(), forestID); err != nil {
+		fmt.Fprintf(os.Stderr, "\n⚠️  %s\n", err)
+		fmt.Fprintf(os.Stderr, "💡 Retry with: morpheus metadata %s refresh\n", forestID)
+	}
+}
+
+// parseMetadataValue parses a KEY=VALUE metadata argument
+func parseMetadataValue(arg string) (key, value string, err error) {
+	key, value, ok := strings.Cut(arg, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return "", "", fmt.Errorf("invalid metadata %q (use KEY=VALUE)", arg)
+	}
+	return strings.TrimSpace(key), value, nil
+}
+
+func printMetadataHelp() {
+	fmt.Println("Usage: morpheus metadata <forest-id> [--json]")
+	fmt.Println("       morpheus metadata <forest-id> set KEY=VALUE...")
+	fmt.Println("       morpheus metadata <forest-id> unset KEY...")
+	fmt.Println("       morpheus metadata <forest-id> refresh")
+	fmt.Println()
+	fmt.Printf("Every node has its forest's metadata in %s, so software\n", forest.MetadataPath)
+	fmt.Println("on the nodes can configure itself: the forest ID, the node's name, index,")
+	fmt.Println("role and location, the IPs of its peers, and user-defined key/values")
+	fmt.Println("(provisioning.metadata in the config, plant --meta and this command).")
+	fmt.Println()
+	fmt.Println("cloud-init writes the file when a node is created. set, unset and")
+	fmt.Println("refresh rewrite it on every node over SSH; scale and grow do so too,")
+	fmt.Println("since the peers changed.")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus metadata forest-123")
+	fmt.Println("  morpheus metadata forest-123 set env=staging team=payments")
+	fmt.Println("  morpheus metadata forest-123 unset team")
+	fmt.Println("  morpheus metadata forest-123 refresh")
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/nimsforest/morpheus/internal/ui"
 	"github.com/nimsforest/morpheus/pkg/bootmode"
+	"github.com/nimsforest/morpheus/pkg/config"
 	"github.com/nimsforest/morpheus/pkg/machine/proxmox"
 )
 
@@ -27,6 +31,12 @@ func HandleMode() {
 		handleModeStatus()
 	case "linux", "windows":
 		handleModeSwitch(subcommand)
+	case "revert":
+		handleModeRevert()
+	case "rollback":
+		handleModeRollback()
+	case "schedule":
+		handleModeSchedule()
 	case "help", "--help", "-h":
 		printModeHelp()
 	default:
@@ -47,11 +57,26 @@ func printModeHelp() {
 	fmt.Println("  status     Show current mode and status")
 	fmt.Println("  linux      Switch to Linux mode (CachyOS + WiVRN)")
 	fmt.Println("  windows    Switch to Windows mode (SteamLink)")
+	fmt.Println("  revert     Roll back the last --snapshot switch")
+	fmt.Println("  rollback   Restore and restart the mode left with --snapshot-current")
+	fmt.Println("  schedule   Show the mode schedule; 'schedule run' follows it")
+	fmt.Println()
+	fmt.Println("Switch options:")
+	fmt.Println("  --snapshot Snapshot the target VM before starting it")
+	fmt.Println("  --snapshot-current")
+	fmt.Println("             Snapshot the current VM before stopping it")
+	fmt.Println("  --dry-run  Show what would happen")
+	fmt.Println("  --force    Stop the current VM immediately")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  morpheus mode status    # Check current mode")
 	fmt.Println("  morpheus mode linux     # Switch to Linux for WiVRN VR")
 	fmt.Println("  morpheus mode windows   # Switch to Windows for SteamVR")
+	fmt.Println("  morpheus mode windows --snapshot   # Switch, keeping a way back")
+	fmt.Println("  morpheus mode revert    # Undo it: roll back and restart Linux")
+	fmt.Println("  morpheus mode windows --snapshot-current")
+	fmt.Println("  morpheus mode rollback  # Windows failed to come up: restore Linux")
+	fmt.Println("  morpheus mode schedule run --catch-up   # Switch on the timetable")
 	fmt.Println()
 	fmt.Println("Prerequisites:")
 	fmt.Println("  Configure Proxmox settings in ~/.morpheus/config.yaml:")
@@ -66,14 +91,28 @@ func printModeHelp() {
 	fmt.Println("      vmid: 101")
 	fmt.Println("    windows:")
 	fmt.Println("      vmid: 102")
+	fmt.Println()
+	fmt.Println("  mode:")
+	fmt.Println("    timezone: Europe/Amsterdam")
+	fmt.Println("    schedule:                  # For 'morpheus mode schedule'")
+	fmt.Println("      - cron: \"0 23 * * *\"      # GPU to Linux workloads for the night")
+	fmt.Println("        mode: linux")
+	fmt.Println("      - cron: \"0 8 * * *\"       # Back to the desktop in the morning")
+	fmt.Println("        mode: windows")
+	fmt.Println("    hooks:                     # Run around switches; output is shown")
+	fmt.Println("      linux:")
+	fmt.Println("        pre_shutdown:          # A failure stops the switch")
+	fmt.Println("          - name: checkpoint")
+	fmt.Println("            ssh: \"nimsforest checkpoint\"")
+	fmt.Println("        post_start:            # Failures are reported only")
+	fmt.Println("          - name: warm-cache")
+	fmt.Println("            run: \"./warm-cache.sh\"  # Local, sees MORPHEUS_VM_IP etc.")
 }
 
-func loadProxmoxManager() (*bootmode.ProxmoxManager, error) {
-	// Try to load config, but it's optional if env vars are set
-	_, _ = LoadConfig()
-
-	// Get Proxmox config from environment
-	proxmoxConfig := proxmox.ProviderConfig{
+// proxmoxConfigFromEnv returns the Proxmox connection settings from the
+// environment; Host and APITokenSecret are empty if it is not configured
+func proxmoxConfigFromEnv() proxmox.ProviderConfig {
+	return proxmox.ProviderConfig{
 		Host:           GetEnvOrDefault("PROXMOX_HOST", ""),
 		Port:           8006,
 		Node:           GetEnvOrDefault("PROXMOX_NODE", "pve"),
@@ -81,6 +120,13 @@ func loadProxmoxManager() (*bootmode.ProxmoxManager, error) {
 		APITokenSecret: GetEnvOrDefault("PROXMOX_API_TOKEN", ""),
 		VerifySSL:      false,
 	}
+}
+
+func loadProxmoxManager() (*bootmode.ProxmoxManager, error) {
+	// Try to load config, but it's optional if env vars are set
+	cfg, _ := LoadConfig()
+
+	proxmoxConfig := proxmoxConfigFromEnv()
 
 	// Check if config is valid
 	if proxmoxConfig.Host == "" || proxmoxConfig.APITokenSecret == "" {
@@ -110,9 +156,69 @@ Optional:
 		GPUPCI: GetEnvOrDefault("PROXMOX_GPU_PCI", "0000:01:00"),
 	}
 
+	if cfg != nil && len(cfg.Mode.Hooks) > 0 {
+		for mode, vm := range map[string]*bootmode.VMConfig{"linux": &vrConfig.Linux, "windows": &vrConfig.Windows} {
+			var err error
+			hooks := cfg.Mode.Hooks[mode]
+			if vm.PreShutdown, err = modeHooks(mode, "pre_shutdown", hooks.PreShutdown); err != nil {
+				return nil, err
+			}
+			if vm.PostStart, err = modeHooks(mode, "post_start", hooks.PostStart); err != nil {
+				return nil, err
+			}
+		}
+		vrConfig.SSHIdentity = expandHome(sshIdentityFile(cfg))
+	}
+
 	return bootmode.NewProxmoxManager(proxmoxConfig, vrConfig)
 }
 
+// modeHooks converts the hooks of a mode in the config
+func modeHooks(mode, stage string, list []config.ModeHook) ([]bootmode.Hook, error) {
+	var hooks []bootmode.Hook
+	for i, h := range list {
+		if (h.Run == "") == (h.SSH == "") {
+			return nil, fmt.Errorf("mode.hooks.%s.%s[%d]: set one of run or ssh", mode, stage, i)
+		}
+		hook := bootmode.Hook{Name: h.Name, Run: h.Run, SSH: h.SSH, User: h.User, ContinueOnError: h.ContinueOnError}
+		if h.Timeout != "" {
+			d, err := time.ParseDuration(h.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("mode.hooks.%s.%s[%d]: invalid timeout %q", mode, stage, i, h.Timeout)
+			}
+			hook.Timeout = d
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, nil
+}
+
+// printHookResults shows the hooks run during a switch, with the output of
+// those that failed
+func printHookResults(hooks []bootmode.HookResult) {
+	if len(hooks) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Println("   Hooks:")
+	for _, h := range hooks {
+		where := "local"
+		if h.Remote {
+			where = "ssh"
+		}
+		if h.Error == "" {
+			fmt.Printf("     ✅ %s %s %s (%s, %s)\n", h.Mode, h.Stage, h.Name, where, h.Duration.Round(time.Millisecond))
+			continue
+		}
+		fmt.Printf("     ❌ %s %s %s (%s): %s\n", h.Mode, h.Stage, h.Name, where, h.Error)
+		for _, line := range strings.Split(strings.TrimRight(h.Output, "\n"), "\n") {
+			if line != "" {
+				fmt.Printf("        │ %s\n", line)
+			}
+		}
+	}
+}
+
 func handleModeList() {
 	manager, err := loadProxmoxManager()
 	if err != nil {
@@ -247,6 +353,10 @@ func handleModeSwitch(targetMode string) {
 			opts.DryRun = true
 		case "--force":
 			opts.Force = true
+		case "--snapshot":
+			opts.Snapshot = true
+		case "--snapshot-current":
+			opts.SnapshotBeforeSwitch = true
 		}
 	}
 
@@ -277,8 +387,21 @@ func handleModeSwitch(targetMode string) {
 		return
 	}
 
+	if gpuErr, ok := err.(*bootmode.GPUPassthroughError); ok {
+		fmt.Fprintf(os.Stderr, "❌ Switch failed: %s\n", gpuErr)
+		fmt.Fprintf(os.Stderr, "💡 %s\n", gpuErr.Hint)
+		os.Exit(1)
+	}
+
 	if err != nil {
+		if result != nil {
+			printHookResults(result.Hooks)
+			fmt.Println()
+		}
 		fmt.Fprintf(os.Stderr, "❌ Switch failed: %s\n", err)
+		if result != nil && result.RollbackSnapshot != "" {
+			fmt.Fprintf(os.Stderr, "💡 Restore %s as it was: morpheus mode rollback\n", result.FromMode)
+		}
 		os.Exit(1)
 	}
 
@@ -296,6 +419,17 @@ func handleModeSwitch(targetMode string) {
 		fmt.Printf("   IP: %s\n", result.IPAddress)
 	}
 	fmt.Printf("   Duration: %s\n", result.Duration.Round(time.Second))
+	if result.Snapshot != "" {
+		fmt.Printf("   Snapshot: %s\n", result.Snapshot)
+		fmt.Println()
+		fmt.Println("   💡 If this mode misbehaves: morpheus mode revert")
+	}
+	if result.RollbackSnapshot != "" {
+		fmt.Printf("   Rollback snapshot of %s: %s\n", result.FromMode, result.RollbackSnapshot)
+		fmt.Println()
+		fmt.Println("   💡 If this mode does not come up: morpheus mode rollback")
+	}
+	printHookResults(result.Hooks)
 
 	if targetMode == "linux" {
 		fmt.Println()
@@ -305,3 +439,296 @@ func handleModeSwitch(targetMode string) {
 		fmt.Println("   🎮 SteamLink is ready for VR streaming")
 	}
 }
+
+func handleModeRevert() {
+	manager, err := loadProxmoxManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	opts := bootmode.DefaultSwitchOptions()
+	for _, arg := range os.Args[3:] {
+		switch arg {
+		case "--dry-run":
+			opts.DryRun = true
+		case "--force":
+			opts.Force = true
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	fmt.Println()
+	if opts.DryRun {
+		fmt.Println("🔍 Dry run - no changes will be made")
+		fmt.Println()
+	}
+
+	result, err := manager.Revert(ctx, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Revert failed: %s\n", err)
+		if gpuErr, ok := err.(*bootmode.GPUPassthroughError); ok {
+			fmt.Fprintf(os.Stderr, "💡 %s\n", gpuErr.Hint)
+		}
+		os.Exit(1)
+	}
+
+	previous := result.ToMode
+	if previous == "" {
+		previous = "no mode running"
+	}
+	if opts.DryRun {
+		fmt.Printf("Would roll %s back to snapshot %s\n", result.FromMode, result.Snapshot)
+		fmt.Printf("and return to: %s\n", previous)
+		return
+	}
+
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("✅ Reverted %s to snapshot %s\n", result.FromMode, result.Snapshot)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Println()
+	fmt.Printf("   Now: %s\n", previous)
+	if result.IPAddress != "" {
+		fmt.Printf("   IP: %s\n", result.IPAddress)
+	}
+	fmt.Printf("   Duration: %s\n", result.Duration.Round(time.Second))
+	printHookResults(result.Hooks)
+}
+
+func handleModeRollback() {
+	manager, err := loadProxmoxManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	opts := bootmode.DefaultSwitchOptions()
+	for _, arg := range os.Args[3:] {
+		switch arg {
+		case "--dry-run":
+			opts.DryRun = true
+		case "--force":
+			opts.Force = true
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	fmt.Println()
+	if opts.DryRun {
+		fmt.Println("🔍 Dry run - no changes will be made")
+		fmt.Println()
+	}
+
+	result, err := manager.Rollback(ctx, opts)
+	if err != nil {
+		if result != nil {
+			printHookResults(result.Hooks)
+			fmt.Println()
+		}
+		fmt.Fprintf(os.Stderr, "❌ Rollback failed: %s\n", err)
+		if gpuErr, ok := err.(*bootmode.GPUPassthroughError); ok {
+			fmt.Fprintf(os.Stderr, "💡 %s\n", gpuErr.Hint)
+		}
+		os.Exit(1)
+	}
+
+	if opts.DryRun {
+		if result.FromMode != "" {
+			fmt.Printf("Would stop %s\n", result.FromMode)
+		}
+		fmt.Printf("Would roll %s back to snapshot %s and start it\n", result.ToMode, result.Snapshot)
+		return
+	}
+
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("✅ Rolled %s back to snapshot %s\n", result.ToMode, result.Snapshot)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Println()
+	fmt.Printf("   Now: %s\n", result.ToMode)
+	if result.IPAddress != "" {
+		fmt.Printf("   IP: %s\n", result.IPAddress)
+	}
+	fmt.Printf("   Duration: %s\n", result.Duration.Round(time.Second))
+	printHookResults(result.Hooks)
+}
+
+// loadModeSchedule returns the schedule in the mode section of the config
+func loadModeSchedule() (*bootmode.Schedule, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if len(cfg.Mode.Schedule) == 0 {
+		return nil, fmt.Errorf("no mode schedule in the config (mode.schedule)")
+	}
+	loc, err := cfg.Mode.GetLocation()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]bootmode.ScheduleEntry, 0, len(cfg.Mode.Schedule))
+	for _, e := range cfg.Mode.Schedule {
+		entries = append(entries, bootmode.ScheduleEntry{Cron: e.Cron, Mode: e.Mode})
+	}
+	return bootmode.NewSchedule(entries, loc)
+}
+
+func handleModeSchedule() {
+	if len(os.Args) > 3 && (os.Args[3] == "--help" || os.Args[3] == "-h") {
+		printModeScheduleHelp()
+		return
+	}
+	if len(os.Args) > 3 && os.Args[3] == "run" {
+		handleModeScheduleRun()
+		return
+	}
+	if len(os.Args) > 3 {
+		fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[3])
+		printModeScheduleHelp()
+		os.Exit(1)
+	}
+
+	schedule, err := loadModeSchedule()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		fmt.Fprintln(os.Stderr, "💡 See: morpheus mode schedule --help")
+		os.Exit(1)
+	}
+
+	now := time.Now().In(schedule.Location)
+	fmt.Printf("🕐 Mode schedule (%s)\n", schedule.Location)
+	fmt.Println()
+	fmt.Printf("   %-20s %-10s %s\n", "CRON", "MODE", "NEXT")
+	for _, e := range schedule.Entries {
+		single := &bootmode.Schedule{Entries: []bootmode.ScheduleEntry{e}, Location: schedule.Location}
+		next := "never"
+		if _, at, ok := single.Next(now); ok {
+			next = at.Format("Mon 2 Jan 15:04") + " (in " + ui.FormatDuration(at.Sub(now)) + ")"
+		}
+		fmt.Printf("   %-20s %-10s %s\n", e.Cron, e.Mode, next)
+	}
+	fmt.Println()
+	if current, at, ok := schedule.Current(now); ok {
+		fmt.Printf("   Due now: %s (since %s)\n", current.Mode, at.Format("Mon 2 Jan 15:04"))
+	}
+	if next, at, ok := schedule.Next(now); ok {
+		fmt.Printf("   Next:    %s at %s\n", next.Mode, at.Format("Mon 2 Jan 15:04"))
+	}
+	fmt.Println()
+	fmt.Println("💡 Follow it with: morpheus mode schedule run --catch-up")
+}
+
+func handleModeScheduleRun() {
+	catchUp := false
+	opts := bootmode.DefaultSwitchOptions()
+	for _, arg := range os.Args[4:] {
+		switch arg {
+		case "--catch-up":
+			catchUp = true
+		case "--dry-run":
+			opts.DryRun = true
+		case "--force":
+			opts.Force = true
+		case "--snapshot":
+			opts.Snapshot = true
+		case "--snapshot-current":
+			opts.SnapshotBeforeSwitch = true
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+			fmt.Fprintln(os.Stderr, "Usage: morpheus mode schedule run [--catch-up] [--dry-run] [--force] [--snapshot] [--snapshot-current]")
+			os.Exit(1)
+		}
+	}
+
+	schedule, err := loadModeSchedule()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		fmt.Fprintln(os.Stderr, "💡 See: morpheus mode schedule --help")
+		os.Exit(1)
+	}
+	manager, err := loadProxmoxManager()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	scheduler := bootmode.NewScheduler(manager, schedule)
+	scheduler.Options = opts
+	scheduler.OnSwitch = func(entry bootmode.ScheduleEntry, result *bootmode.SwitchResult, err error) {
+		stamp := time.Now().In(schedule.Location).Format("2006-01-02 15:04")
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "[%s] ❌ Switch to %s (%s) failed: %s\n", stamp, entry.Mode, entry.Cron, err)
+		case result == nil || result.FromMode == entry.Mode:
+			fmt.Printf("[%s] ✅ Already in %s mode\n", stamp, entry.Mode)
+		case opts.DryRun:
+			fmt.Printf("[%s] 🔍 Would switch to %s mode\n", stamp, entry.Mode)
+		default:
+			fmt.Printf("[%s] ✅ Switched to %s mode in %s\n", stamp, entry.Mode, result.Duration.Round(time.Second))
+		}
+		if result != nil {
+			for _, h := range result.Hooks {
+				if h.Error != "" {
+					fmt.Fprintf(os.Stderr, "[%s] ⚠️  %s hook %s of %s failed: %s\n", stamp, h.Stage, h.Name, h.Mode, h.Error)
+				}
+			}
+		}
+	}
+
+	fmt.Printf("🕐 Following the mode schedule (%s), Ctrl+C to stop\n", schedule.Location)
+	if opts.DryRun {
+		fmt.Println("🔍 Dry run - no changes will be made")
+	}
+	if catchUp {
+		if ok, _ := scheduler.CatchUp(ctx); !ok {
+			fmt.Println("   Nothing due in the last month to catch up on")
+		}
+	}
+	if next, at, ok := schedule.Next(time.Now()); ok {
+		fmt.Printf("   Next: %s at %s\n", next.Mode, at.Format("Mon 2 Jan 15:04"))
+	}
+
+	if err := scheduler.Run(ctx); err != nil && ctx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("\n👋 Stopped following the schedule")
+}
+
+func printModeScheduleHelp() {
+	fmt.Println("Usage: morpheus mode schedule")
+	fmt.Println("       morpheus mode schedule run [--catch-up] [--dry-run] [--force] [--snapshot]")
+	fmt.Println("                                  [--snapshot-current]")
+	fmt.Println()
+	fmt.Println("Switch boot modes on a timetable, e.g. give the GPU to Linux workloads at")
+	fmt.Println("night and back to the Windows desktop in the morning. The schedule is the")
+	fmt.Println("mode section of the config: cron expressions (minute hour day-of-month")
+	fmt.Println("month day-of-week, as in crontab) with the mode to switch to when they fire.")
+	fmt.Println()
+	fmt.Println("Without arguments, it shows the schedule and when each entry fires next.")
+	fmt.Println("run follows it until interrupted; run it as a service. A failed switch is")
+	fmt.Println("reported and does not stop it. Switch options apply to every switch.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --catch-up  First switch to the mode that should be running now")
+	fmt.Println("  --dry-run   Report the switches without making them")
+	fmt.Println("  --force     Stop the current VM immediately")
+	fmt.Println("  --snapshot  Snapshot the target VM before starting it")
+	fmt.Println("  --snapshot-current")
+	fmt.Println("              Snapshot the current VM before stopping it")
+	fmt.Println()
+	fmt.Println("Config:")
+	fmt.Println("  mode:")
+	fmt.Println("    timezone: Europe/Amsterdam    # Default: local time")
+	fmt.Println("    schedule:")
+	fmt.Println("      - cron: \"0 23 * * *\"")
+	fmt.Println("        mode: linux")
+	fmt.Println("      - cron: \"30 7 * * mon-fri\"")
+	fmt.Println("        mode: windows")
+}
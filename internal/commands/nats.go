@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/nimsforest/morpheus/pkg/forest"
+)
+
+// HandleNATS handles the nats command.
+func HandleNATS() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printNATSHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	switch os.Args[2] {
+	case "bootstrap":
+		handleNATSBootstrap()
+	default:
+		fmt.Fprintf(os.Stderr, "❌ Unknown nats command: %s\n", os.Args[2])
+		printNATSHelp()
+		os.Exit(1)
+	}
+}
+
+func handleNATSBootstrap() {
+	if len(os.Args) != 4 || startsWithDash(os.Args[3]) {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus nats bootstrap <forest-id>")
+		os.Exit(1)
+	}
+	forestID := os.Args[3]
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+	if !cfg.Provisioning.NATS.Enabled {
+		fmt.Fprintln(os.Stderr, "❌ NATS bootstrap is not enabled")
+		fmt.Fprintln(os.Stderr, "💡 Set provisioning.nats.enabled: true in your config")
+		os.Exit(1)
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Invalid config: %s\n", err)
+		os.Exit(1)
+	}
+
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load storage: %s\n", err)
+		os.Exit(1)
+	}
+	if _, err := reg.GetForest(forestID); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Forest not found: %s\n", forestID)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Bootstrapping only talks to the nodes, not the machine provider
+	provisioner := forest.NewProvisioner(nil, reg, cfg)
+	configureSecrets(provisioner, cfg)
+	fmt.Printf("🔗 Bootstrapping NATS cluster on %s\n", forestID)
+	if err := provisioner.BootstrapNATS(ctx, forestID); err != nil {
+		fmt.Fprintf(os.Stderr, "\n❌ %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func printNATSHelp() {
+	fmt.Println("Usage: morpheus nats bootstrap <forest-id>")
+	fmt.Println()
+	fmt.Println("Install nats-server on every node of a forest and configure the nodes as")
+	fmt.Println("one cluster, with the routes, accounts and TLS from provisioning.nats.")
+	fmt.Println("This runs automatically after plant and scale when provisioning.nats is")
+	fmt.Println("enabled; run it again to retry or to apply a changed configuration.")
+	fmt.Println("Nodes already running NATS reload their configuration without a restart.")
+}
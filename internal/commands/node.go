@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/lockfile"
+)
+
+// HandleNode handles the node command.
+func HandleNode() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printNodeHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	action := forest.PowerAction(os.Args[2])
+	switch action {
+	case forest.PowerReboot, forest.PowerOff, forest.PowerOn:
+	default:
+		fmt.Fprintf(os.Stderr, "❌ Unknown node command: %s\n", os.Args[2])
+		fmt.Fprintln(os.Stderr, "Use 'morpheus node --help' for usage")
+		os.Exit(1)
+	}
+
+	req := forest.PowerRequest{Action: action}
+	var args []string
+	for i := 3; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		switch arg {
+		case "--force":
+			req.Force = true
+		case "--timeout":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --timeout requires a duration")
+				os.Exit(1)
+			}
+			i++
+			d, err := time.ParseDuration(os.Args[i])
+			if err != nil || d <= 0 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid duration: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			req.ShutdownTimeout = d
+		default:
+			if startsWithDash(arg) {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+				os.Exit(1)
+			}
+			args = append(args, arg)
+		}
+	}
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: morpheus node %s <forest-id> <node>\n", action)
+		os.Exit(1)
+	}
+	req.ForestID, req.Node = args[0], args[1]
+
+	provisioner, _ := forestProvisioner(req.ForestID)
+
+	lock, err := AcquireForestLock(req.ForestID, fmt.Sprintf("node %s %s", action, req.Node), lockfile.DefaultTTL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	defer lock.Release()
+
+	fmt.Println()
+	if err := provisioner.Power(context.Background(), req); err != nil {
+		lock.Release()
+		fmt.Fprintf(os.Stderr, "\n❌ %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("\n💡 View the forest: morpheus status %s\n", req.ForestID)
+}
+
+func printNodeHelp() {
+	fmt.Println("Usage: morpheus node reboot|poweroff|poweron <forest-id> <node> [options]")
+	fmt.Println()
+	fmt.Println("Reboot, power off or power on one node of a forest. The node is given")
+	fmt.Println("by its number (2 or node-2), server name or server ID.")
+	fmt.Println()
+	fmt.Println("reboot and poweroff are graceful: the node is asked over SSH to reboot")
+	fmt.Println("or shut down. If it cannot be reached, or does not shut down in time,")
+	fmt.Println("its server is reset or powered off at the provider. The node's status")
+	fmt.Println("in the registry follows (rebooting, stopped, active).")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --force                Reset or power off at the provider right away")
+	fmt.Println("  --timeout D            How long a graceful shutdown may take (default: 2m)")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus node reboot forest-123 node-2")
+	fmt.Println("  morpheus node poweroff forest-123 3 --timeout 5m")
+	fmt.Println("  morpheus node poweron forest-123 3")
+}
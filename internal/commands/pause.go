@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/health"
+	"github.com/nimsforest/morpheus/pkg/lockfile"
+)
+
+// HandlePause handles the pause command.
+func HandlePause() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printPauseHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	req := forest.PauseRequest{}
+	for i := 2; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		switch arg {
+		case "--force":
+			req.Force = true
+		case "--timeout":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --timeout requires a duration")
+				os.Exit(1)
+			}
+			i++
+			d, err := time.ParseDuration(os.Args[i])
+			if err != nil || d <= 0 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid duration: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			req.ShutdownTimeout = d
+		default:
+			if startsWithDash(arg) || req.ForestID != "" {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+				os.Exit(1)
+			}
+			req.ForestID = arg
+		}
+	}
+	if req.ForestID == "" {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus pause <forest-id>")
+		os.Exit(1)
+	}
+
+	provisioner, _ := forestProvisioner(req.ForestID)
+
+	lock, err := AcquireForestLock(req.ForestID, "pause", lockfile.DefaultTTL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	defer lock.Release()
+
+	fmt.Printf("\n⏸️  Pausing forest %s...\n\n", req.ForestID)
+	if err := provisioner.Pause(context.Background(), req); err != nil {
+		lock.Release()
+		fmt.Fprintf(os.Stderr, "\n❌ %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("\n✅ Forest %s is paused\n", req.ForestID)
+	fmt.Printf("💡 Resume with: morpheus resume %s\n", req.ForestID)
+}
+
+// HandleResume handles the resume command.
+func HandleResume() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printPauseHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if len(os.Args) != 3 || startsWithDash(os.Args[2]) {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus resume <forest-id>")
+		os.Exit(1)
+	}
+	forestID := os.Args[2]
+
+	provisioner, _ := forestProvisioner(forestID)
+
+	lock, err := AcquireForestLock(forestID, "resume", lockfile.DefaultTTL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	defer lock.Release()
+
+	ctx := context.Background()
+	fmt.Printf("\n▶️  Resuming forest %s...\n\n", forestID)
+	if err := provisioner.ResumePaused(ctx, forestID); err != nil {
+		lock.Release()
+		fmt.Fprintf(os.Stderr, "\n❌ %s\n", err)
+		fmt.Fprintf(os.Stderr, "💡 Check the nodes with: morpheus health %s\n", forestID)
+		os.Exit(1)
+	}
+	lock.Release()
+
+	// Check the nodes as 'morpheus health' does
+	targets := forestTargets(forestID)
+	cfg, _ := LoadConfig()
+	var opts health.Options
+	opts.IdentityFile = sshIdentityFile(cfg)
+	if cfg != nil {
+		opts.ExpectNATS = cfg.Provisioning.NATS.Enabled || cfg.IsNimsForestInstallEnabled()
+	}
+	fmt.Printf("\n🩺 Checking %d node%s...\n\n", len(targets), ui.Plural(len(targets)))
+	nodes := health.Probe(ctx, targets, opts)
+	printHealthTable(nodes)
+	if health.Summarize(nodes) == health.StatusFail {
+		os.Exit(1)
+	}
+}
+
+func printPauseHelp() {
+	fmt.Println("Usage: morpheus pause <forest-id> [options]")
+	fmt.Println("       morpheus resume <forest-id>")
+	fmt.Println()
+	fmt.Println("pause powers off all nodes of a forest without tearing it down: the")
+	fmt.Println("servers keep their disks, volumes and IPs. Nodes are shut down over SSH")
+	fmt.Println("first, as with 'morpheus node poweroff'.")
+	fmt.Println()
+	fmt.Println("resume powers the nodes on again, waits until they are reachable, runs")
+	fmt.Println("the forest's verify checks and then checks the nodes' health.")
+	fmt.Println()
+	fmt.Println("Note: Hetzner bills servers while they are powered off. Pausing stops")
+	fmt.Println("the workload, but only teardown stops the charges.")
+	fmt.Println()
+	fmt.Println("Options (pause):")
+	fmt.Println("  --force                Power off at the provider right away")
+	fmt.Println("  --timeout D            How long a graceful shutdown may take (default: 2m)")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus pause forest-123")
+	fmt.Println("  morpheus resume forest-123")
+}
@@ -2,23 +2,42 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/config"
 	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/machine"
 	"github.com/nimsforest/morpheus/pkg/machine/hetzner"
+	"github.com/nimsforest/morpheus/pkg/verify"
 )
 
-// HandlePlant handles the plant command.
-func HandlePlant() {
+// HandlePlant handles the plant command. version is recorded in the
+// forest's provenance.
+func HandlePlant(version string) {
 	// Parse arguments - simplified CLI
 	// morpheus plant             -> 2 nodes (default)
 	// morpheus plant --nodes 3   -> 3 nodes
 
 	nodeCount := 2
+	nodesSet := false
+	var roles []forest.NodeRole
+	var volume *forest.VolumeSpec
+	var lb *forest.LoadBalancerSpec
+	floatingIP := false
+	overrideBudget := false
+	project := ""
+	resumeID := ""
+	spread := false
+	var locations []string
+	var metadata map[string]string
+	var checks []config.VerifyCheck
+	var inputFiles []string // Files the forest is planted from besides the config
 
 	// Parse arguments
 	for i := 2; i < len(os.Args); i++ {
@@ -33,27 +52,187 @@ func HandlePlant() {
 					os.Exit(1)
 				}
 				nodeCount = n
+				nodesSet = true
 			} else {
 				fmt.Fprintln(os.Stderr, "❌ --nodes requires a number")
 				os.Exit(1)
 			}
+		case "--volume-size":
+			if i+1 < len(os.Args) {
+				i++
+				n, err := strconv.Atoi(os.Args[i])
+				if err != nil || n < 10 {
+					fmt.Fprintf(os.Stderr, "❌ Invalid volume size: %s (minimum 10 GB)\n", os.Args[i])
+					os.Exit(1)
+				}
+				if volume == nil {
+					volume = &forest.VolumeSpec{}
+				}
+				volume.SizeGB = n
+			} else {
+				fmt.Fprintln(os.Stderr, "❌ --volume-size requires a size in GB")
+				os.Exit(1)
+			}
+		case "--volume-fs", "--volume-mount":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintf(os.Stderr, "❌ %s requires a value\n", arg)
+				os.Exit(1)
+			}
+			i++
+			if volume == nil {
+				volume = &forest.VolumeSpec{}
+			}
+			if arg == "--volume-fs" {
+				if os.Args[i] != "ext4" && os.Args[i] != "xfs" {
+					fmt.Fprintf(os.Stderr, "❌ Invalid filesystem: %s (use ext4 or xfs)\n", os.Args[i])
+					os.Exit(1)
+				}
+				volume.Filesystem = os.Args[i]
+			} else {
+				if !strings.HasPrefix(os.Args[i], "/") {
+					fmt.Fprintf(os.Stderr, "❌ Mount point must be an absolute path: %s\n", os.Args[i])
+					os.Exit(1)
+				}
+				volume.MountPoint = os.Args[i]
+			}
+		case "--floating-ip":
+			floatingIP = true
+		case "--spread":
+			spread = true
+		case "--locations":
+			if i+1 >= len(os.Args) || startsWithDash(os.Args[i+1]) {
+				fmt.Fprintln(os.Stderr, "❌ --locations requires a comma-separated list")
+				os.Exit(1)
+			}
+			i++
+			for _, loc := range strings.Split(os.Args[i], ",") {
+				if loc = strings.TrimSpace(loc); loc != "" {
+					locations = append(locations, loc)
+				}
+			}
+		case "--roles":
+			if i+1 >= len(os.Args) || startsWithDash(os.Args[i+1]) {
+				fmt.Fprintln(os.Stderr, "❌ --roles requires ROLE=COUNT[,ROLE=COUNT...]")
+				os.Exit(1)
+			}
+			i++
+			r, err := forest.ParseRoles(os.Args[i])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+				os.Exit(1)
+			}
+			roles = r
+		case "--meta":
+			if i+1 >= len(os.Args) || startsWithDash(os.Args[i+1]) {
+				fmt.Fprintln(os.Stderr, "❌ --meta requires KEY=VALUE")
+				os.Exit(1)
+			}
+			i++
+			key, value, err := parseMetadataValue(os.Args[i])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+				os.Exit(1)
+			}
+			if metadata == nil {
+				metadata = make(map[string]string)
+			}
+			metadata[key] = value
+		case "--override-budget":
+			overrideBudget = true
+		case "--project":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --project requires a project name")
+				os.Exit(1)
+			}
+			i++
+			project = os.Args[i]
+		case "--verify":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --verify requires a suite file")
+				os.Exit(1)
+			}
+			i++
+			suite, err := verify.LoadSuite(os.Args[i])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+				os.Exit(1)
+			}
+			checks = append(checks, suite...)
+			inputFiles = append(inputFiles, os.Args[i])
+		case "--resume":
+			if i+1 >= len(os.Args) || startsWithDash(os.Args[i+1]) {
+				fmt.Fprintln(os.Stderr, "❌ --resume requires a forest ID")
+				os.Exit(1)
+			}
+			i++
+			resumeID = os.Args[i]
+		case "--lb":
+			if lb == nil {
+				lb = &forest.LoadBalancerSpec{}
+			}
+		case "--lb-type", "--lb-service":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintf(os.Stderr, "❌ %s requires a value\n", arg)
+				os.Exit(1)
+			}
+			i++
+			if lb == nil {
+				lb = &forest.LoadBalancerSpec{}
+			}
+			if arg == "--lb-type" {
+				lb.Type = os.Args[i]
+			} else {
+				svc, err := parseLoadBalancerService(os.Args[i])
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+					os.Exit(1)
+				}
+				lb.Services = append(lb.Services, svc)
+			}
 		case "--help", "-h":
 			fmt.Println("Usage: morpheus plant [options]")
 			fmt.Println()
 			fmt.Println("Create a new forest with the specified number of nodes.")
 			fmt.Println()
 			fmt.Println("Options:")
-			fmt.Println("  --nodes, -n N   Number of nodes to create (default: 2)")
-			fmt.Println("  --help, -h      Show this help")
+			fmt.Println("  --nodes, -n N         Number of nodes to create (default: 2)")
+			fmt.Println("  --roles ROLE=N,...    Assign the nodes roles in order, e.g. edge=1,core=2")
+			fmt.Println("                        (sets the node count; default: provisioning.roles)")
+			fmt.Println("  --volume-size GB      Attach a persistent volume to each node")
+			fmt.Println("  --volume-fs FS        Volume filesystem: ext4 (default) or xfs")
+			fmt.Println("  --volume-mount PATH   Volume mount point (default: /mnt/data)")
+			fmt.Println("  --lb                  Put a load balancer in front of the nodes")
+			fmt.Println("  --lb-type TYPE        Load balancer type (default: lb11)")
+			fmt.Println("  --lb-service SPEC     Service as proto:listen:dest[:/health-path]")
+			fmt.Println("                        (repeatable, default: tcp:80:8080)")
+			fmt.Println("  --floating-ip         Allocate a floating IP on the first node (see failover)")
+			fmt.Println("  --spread              Spread the nodes across machine.hetzner.locations")
+			fmt.Println("  --locations L1,L2     Spread the nodes across these locations, round-robin;")
+			fmt.Println("                        list one per node to place each node explicitly")
+			fmt.Println("  --meta KEY=VALUE      Add a value to the nodes' metadata (repeatable,")
+			fmt.Println("                        see 'morpheus metadata --help')")
+			fmt.Println("  --project NAME        Hetzner project to plant in (default: active project)")
+			fmt.Println("  --override-budget     Plant even if limits.max_monthly_cost would be exceeded")
+			fmt.Println("  --verify FILE         Also run the checks in a verify suite (e.g. a blueprint's")
+			fmt.Println("                        verify.yaml); failures mark the forest degraded")
+			fmt.Println("  --resume FOREST_ID    Finish an incomplete plant: replace failed nodes and")
+			fmt.Println("                        create the missing ones, as originally requested")
+			fmt.Println("  --help, -h            Show this help")
 			fmt.Println()
 			fmt.Println("Examples:")
 			fmt.Println("  morpheus plant              # Create 2-node cluster")
 			fmt.Println("  morpheus plant --nodes 3    # Create 3-node forest")
+			fmt.Println("  morpheus plant --volume-size 50 --volume-mount /var/lib/nimsforest")
+			fmt.Println("  morpheus plant --lb --lb-service http:80:8080:/healthz")
+			fmt.Println("  morpheus plant --nodes 3 --locations fsn1,nbg1,hel1")
+			fmt.Println("  morpheus plant --roles edge=1,core=2")
+			fmt.Println("  morpheus plant --resume forest-1234567890")
 			os.Exit(0)
 		default:
 			// Support legacy size arguments for backward compatibility
 			if ui.IsValidSize(arg) {
 				nodeCount = ui.GetNodeCount(arg)
+				nodesSet = true
 			} else {
 				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
 				fmt.Fprintln(os.Stderr, "Use 'morpheus plant --help' for usage")
@@ -62,17 +241,69 @@ func HandlePlant() {
 		}
 	}
 
+	if resumeID != "" {
+		handlePlantResume(resumeID, version)
+		return
+	}
+
+	if volume != nil && volume.SizeGB == 0 {
+		fmt.Fprintln(os.Stderr, "❌ --volume-fs and --volume-mount require --volume-size")
+		os.Exit(1)
+	}
+
+	if spread && len(locations) > 0 {
+		fmt.Fprintln(os.Stderr, "❌ Use either --spread or --locations")
+		os.Exit(1)
+	}
+	if lb != nil && len(lb.Services) == 0 {
+		lb.Services = []machine.LoadBalancerService{{Protocol: "tcp", ListenPort: 80, DestinationPort: 8080}}
+	}
+
 	cfg, err := LoadConfig()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
 		os.Exit(1)
 	}
+	if project != "" {
+		cfg.Secrets.HetznerProject = project
+	}
+
+	// A role layout sets the node count: --roles, or else the configured
+	// one (provisioning.roles) unless --nodes is given
+	if len(roles) == 0 && !nodesSet {
+		roles = forest.ConfiguredRoles(cfg)
+	}
+	if len(roles) > 0 {
+		if nodesSet && nodeCount != forest.RoleNodeCount(roles) {
+			fmt.Fprintf(os.Stderr, "❌ --nodes %d does not match the roles %s\n", nodeCount, forest.FormatRoles(roles))
+			os.Exit(1)
+		}
+		nodeCount = forest.RoleNodeCount(roles)
+	}
+	if len(locations) > nodeCount {
+		fmt.Fprintf(os.Stderr, "❌ --locations lists %d locations for %d node%s\n", len(locations), nodeCount, ui.Plural(nodeCount))
+		os.Exit(1)
+	}
+
+	// Load balancers reach their targets over IPv4
+	if lb != nil && !cfg.IsIPv4Enabled() {
+		fmt.Fprintln(os.Stderr, "❌ --lb requires IPv4 to be enabled (machine.ipv4.enabled: true)")
+		os.Exit(1)
+	}
 
 	if err := cfg.Validate(); err != nil {
 		fmt.Fprintf(os.Stderr, "Invalid config: %s\n", err)
 		os.Exit(1)
 	}
 
+	if spread {
+		locations = cfg.GetLocations()
+		if len(locations) < 2 {
+			fmt.Fprintln(os.Stderr, "❌ --spread needs at least two locations in machine.hetzner.locations")
+			os.Exit(1)
+		}
+	}
+
 	// Create machine provider based on configuration
 	machineProv, providerName, err := CreateMachineProvider(cfg)
 	if err != nil {
@@ -97,6 +328,8 @@ func HandlePlant() {
 	} else {
 		provisioner = forest.NewProvisioner(machineProv, storageProv, cfg)
 	}
+	configureInventory(provisioner, cfg)
+	configureSecrets(provisioner, cfg)
 
 	// Generate forest ID
 	forestID := fmt.Sprintf("forest-%d", time.Now().Unix())
@@ -125,20 +358,62 @@ func HandlePlant() {
 		serverType = selectedType
 		location = availableLocations[0] // Use first available location
 		image = cfg.GetImage()
+
+		// Spread nodes need the server type in all of their locations
+		if len(locations) > 0 {
+			if missing := missingLocations(locations, availableLocations); len(missing) > 0 {
+				fmt.Fprintf(os.Stderr, "\n❌ Server type %s is not available in %s\n", serverType, JoinLocations(missing))
+				os.Exit(1)
+			}
+			location = locations[0]
+		}
 	} else {
 		// Non-Hetzner provider
 		serverType = cfg.GetServerType()
 		location = cfg.GetLocation()
 		image = cfg.GetImage()
+		if len(locations) > 0 {
+			location = locations[0]
+		}
 	}
 
 	// Create provision request
 	req := forest.ProvisionRequest{
-		ForestID:   forestID,
-		NodeCount:  nodeCount,
-		Location:   location,
-		ServerType: serverType,
-		Image:      image,
+		ForestID:     forestID,
+		NodeCount:    nodeCount,
+		Location:     location,
+		Locations:    locations,
+		Roles:        roles,
+		Metadata:     metadata,
+		ServerType:   serverType,
+		Image:        image,
+		Volume:       volume,
+		LoadBalancer: lb,
+		Project:      cfg.GetHetznerProject(),
+		Verify:       checks,
+	}
+	if floatingIP {
+		// Same address family as the nodes' primary addresses
+		req.FloatingIP = "ipv6"
+		if cfg.IsIPv4Enabled() {
+			req.FloatingIP = "ipv4"
+		}
+	}
+	if _, ok := machineProv.(*hetzner.Provider); ok {
+		volumeGB := 0
+		if volume != nil {
+			volumeGB = volume.SizeGB
+		}
+		req.ExpectedNodeCost = hetzner.GetEstimatedNodeCost(serverType, cfg.IsIPv4Enabled(), volumeGB)
+		if lb != nil {
+			lb.ExpectedCost = hetzner.EstimatedLoadBalancerCost
+		}
+		switch req.FloatingIP {
+		case "ipv4":
+			req.FloatingIPCost = hetzner.EstimatedFloatingIPv4Cost
+		case "ipv6":
+			req.FloatingIPCost = hetzner.EstimatedFloatingIPv6Cost
+		}
 	}
 
 	// Display friendly provisioning header
@@ -159,9 +434,42 @@ func HandlePlant() {
 	fmt.Printf("📋 Configuration:\n")
 	fmt.Printf("   Forest ID:  %s\n", forestID)
 	fmt.Printf("   Nodes:      %d\n", nodeCount)
+	if len(roles) > 0 {
+		fmt.Printf("   Roles:      %s\n", forest.FormatRoles(roles))
+	}
 	fmt.Printf("   Machine:    %s (with automatic fallback if unavailable)\n", serverType)
-	fmt.Printf("   Location:   %s (with automatic fallback if unavailable)\n", hetzner.GetLocationDescription(location))
+	if len(locations) > 0 {
+		fmt.Printf("   Locations:  %s (nodes round-robin)\n", JoinLocations(locations))
+	} else {
+		fmt.Printf("   Location:   %s (with automatic fallback if unavailable)\n", hetzner.GetLocationDescription(location))
+	}
 	fmt.Printf("   Provider:   %s\n", providerName)
+	if req.Project != "" {
+		fmt.Printf("   Project:    %s\n", req.Project)
+	}
+	if volume != nil {
+		mountPoint := volume.MountPoint
+		if mountPoint == "" {
+			mountPoint = "/mnt/data"
+		}
+		fmt.Printf("   Volume:     %d GB per node at %s\n", volume.SizeGB, mountPoint)
+	}
+	if lb != nil {
+		var services []string
+		for _, svc := range lb.Services {
+			services = append(services, fmt.Sprintf("%s:%d→%d", svc.Protocol, svc.ListenPort, svc.DestinationPort))
+		}
+		fmt.Printf("   Balancer:   %s\n", strings.Join(services, ", "))
+	}
+	if req.FloatingIP != "" {
+		fmt.Printf("   Failover:   %s floating IP\n", req.FloatingIP)
+	}
+	if _, ok := machineProv.(machine.PlacementGroupManager); ok && cfg.UsePlacementGroup(nodeCount) && len(forest.UniqueLocations(locations)) < 2 {
+		fmt.Printf("   Spread:     one node per physical host\n")
+	}
+	if n := len(cfg.Provisioning.Verify) + len(checks); n > 0 {
+		fmt.Printf("   Verify:     %d check%s after provisioning\n", n, ui.Plural(n))
+	}
 	fmt.Printf("   Time:       ~%s\n\n", timeEstimate)
 
 	estimatedCost := hetzner.GetEstimatedCost(serverType) * float64(nodeCount)
@@ -173,7 +481,10 @@ func HandlePlant() {
 		fmt.Printf("   (IPv6-only, billed by minute, can teardown anytime)\n\n")
 	}
 
+	req.OverrideBudget = overrideBudget
+
 	fmt.Println("🚀 Starting provisioning...")
+	started := time.Now()
 
 	// Use the full fallback system for Hetzner
 	if hetznerProv, ok := machineProv.(*hetzner.Provider); ok {
@@ -183,22 +494,47 @@ func HandlePlant() {
 	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "\n❌ Provisioning failed: %s\n", err)
+		printBudgetHint(err)
 		os.Exit(1)
 	}
+	recordProvenance(provisioner, forestID, forest.ProvenanceInputs{
+		ConfigPath: FindConfigPath(),
+		Files:      inputFiles,
+		Version:    version,
+		StartedOn:  started,
+	})
 
 	// Success message with clear next steps
 	fmt.Printf("\n")
 	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
-	fmt.Printf("✨ Success! Your forest is ready!\n")
+	if f, err := storageProv.GetForest(forestID); err == nil && f.Status == forest.StatusDegraded {
+		fmt.Printf("⚠️  Your forest is up, but verification failed\n")
+	} else {
+		fmt.Printf("✨ Success! Your forest is ready!\n")
+	}
 	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
 
 	fmt.Printf("🎯 What's next?\n\n")
 
+	if f, err := storageProv.GetForest(forestID); err == nil && f.LoadBalancerIPv4 != "" {
+		fmt.Printf("⚖️  Load balancer:\n")
+		fmt.Printf("   %s  %s\n\n", f.LoadBalancerIPv4, f.LoadBalancerIPv6)
+	}
+
+	if f, err := storageProv.GetForest(forestID); err == nil && f.FloatingIP != "" {
+		fmt.Printf("🔀 Floating IP %s (move with: morpheus failover %s --to <node>)\n\n", f.FloatingIP, forestID)
+	}
+
 	fmt.Printf("📊 Check your forest status:\n")
 	fmt.Printf("   morpheus status %s\n\n", forestID)
 
-	fmt.Printf("🌐 Your machines are ready for NATS deployment\n")
-	fmt.Printf("   Infrastructure is configured and waiting\n\n")
+	if cfg.Provisioning.NATS.Enabled {
+		fmt.Printf("🔗 NATS cluster on nats://<node>:4222\n")
+		fmt.Printf("   Reconfigure with: morpheus nats bootstrap %s\n\n", forestID)
+	} else {
+		fmt.Printf("🌐 Your machines are ready for NATS deployment\n")
+		fmt.Printf("   Infrastructure is configured and waiting\n\n")
+	}
 
 	fmt.Printf("📋 View all your forests:\n")
 	fmt.Printf("   morpheus list\n\n")
@@ -210,6 +546,69 @@ func HandlePlant() {
 	fmt.Printf("   morpheus teardown %s\n\n", forestID)
 }
 
+// handlePlantResume handles "morpheus plant --resume <forest-id>"
+func handlePlantResume(forestID, version string) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create storage: %s\n", err)
+		os.Exit(1)
+	}
+	f, err := reg.GetForest(forestID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Forest not found: %s\n", forestID)
+		os.Exit(1)
+	}
+
+	// Create provider with the credentials of the forest's project
+	UseForestProject(cfg, reg, forestID)
+	machineProv, _, err := CreateMachineProvider(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	var provisioner *forest.Provisioner
+	if dnsProv := CreateDNSProvider(cfg); dnsProv != nil {
+		provisioner = forest.NewProvisionerWithDNS(machineProv, reg, dnsProv, cfg)
+	} else {
+		provisioner = forest.NewProvisioner(machineProv, reg, cfg)
+	}
+	configureInventory(provisioner, cfg)
+	configureSecrets(provisioner, cfg)
+
+	fmt.Printf("\n🌲 Resuming forest %s...\n", forestID)
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Printf("   Status:     %s\n", f.Status)
+	fmt.Printf("   Nodes:      %d requested\n", f.NodeCount)
+
+	started := time.Now()
+	if err := provisioner.Resume(context.Background(), forestID); err != nil {
+		fmt.Fprintf(os.Stderr, "\n❌ Resume failed: %s\n", err)
+		os.Exit(1)
+	}
+	recordProvenance(provisioner, forestID, forest.ProvenanceInputs{
+		ConfigPath: FindConfigPath(),
+		Version:    version,
+		StartedOn:  started,
+	})
+
+	fmt.Printf("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	if f, err := reg.GetForest(forestID); err == nil && f.Status == forest.StatusDegraded {
+		fmt.Printf("⚠️  Your forest is up, but verification failed\n")
+	} else {
+		fmt.Printf("✨ Success! Your forest is ready!\n")
+	}
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+	fmt.Printf("📊 Check your forest status:\n")
+	fmt.Printf("   morpheus status %s\n\n", forestID)
+}
+
 // provisionWithFallback tries to provision a forest, automatically falling back
 // to alternative server types and locations if the primary ones are unavailable.
 func provisionWithFallback(ctx context.Context, provisioner *forest.Provisioner, hetznerProv *hetzner.Provider, req forest.ProvisionRequest, serverType string, fallbacks []string) error {
@@ -258,6 +657,15 @@ func provisionWithFallback(ctx context.Context, provisioner *forest.Provisioner,
 		// Reorder available locations to match preferred order
 		orderedLocations := OrderLocationsByPreference(availableLocations, preferredLocations)
 
+		// Spread forests stay in their locations; only the server type falls back
+		if len(req.Locations) > 0 {
+			if missing := missingLocations(req.Locations, availableLocations); len(missing) > 0 {
+				fmt.Printf("   ⚠️  Server type %s is not available in %s, skipping\n", st, JoinLocations(missing))
+				continue
+			}
+			orderedLocations = req.Locations[:1]
+		}
+
 		// Show info when switching to fallback server type
 		if serverTypeIdx > 0 && len(attemptedCombos) > 0 {
 			fmt.Printf("\n📦 Trying alternative server type: %s (~€%.2f/mo)\n",
@@ -285,6 +693,11 @@ func provisionWithFallback(ctx context.Context, provisioner *forest.Provisioner,
 
 			lastErr = err
 
+			// Nodes are up in this location; finish with --resume instead
+			if errors.Is(err, forest.ErrIncomplete) {
+				return err
+			}
+
 			// Check if the error is a location/server type availability error
 			errStr := err.Error()
 			if ContainsLocationError(errStr) {
@@ -313,3 +726,34 @@ func provisionWithFallback(ctx context.Context, provisioner *forest.Provisioner,
 
 	return fmt.Errorf("no server type available")
 }
+
+// parseLoadBalancerService parses a --lb-service value of the form
+// proto:listen:dest[:/health-path], e.g. "tcp:443:8443" or "http:80:8080:/healthz"
+func parseLoadBalancerService(spec string) (machine.LoadBalancerService, error) {
+	parts := strings.SplitN(spec, ":", 4)
+	if len(parts) < 3 {
+		return machine.LoadBalancerService{}, fmt.Errorf("invalid load balancer service %q (use proto:listen:dest[:/health-path])", spec)
+	}
+
+	svc := machine.LoadBalancerService{Protocol: strings.ToLower(parts[0])}
+	if svc.Protocol != "tcp" && svc.Protocol != "http" {
+		return svc, fmt.Errorf("invalid load balancer protocol %q (use tcp or http)", parts[0])
+	}
+	listen, err := strconv.Atoi(parts[1])
+	if err != nil || listen < 1 || listen > 65535 {
+		return svc, fmt.Errorf("invalid listen port in %q", spec)
+	}
+	dest, err := strconv.Atoi(parts[2])
+	if err != nil || dest < 1 || dest > 65535 {
+		return svc, fmt.Errorf("invalid destination port in %q", spec)
+	}
+	svc.ListenPort, svc.DestinationPort = listen, dest
+
+	if len(parts) == 4 {
+		if !strings.HasPrefix(parts[3], "/") {
+			return svc, fmt.Errorf("health check path must start with /: %q", parts[3])
+		}
+		svc.HealthCheckPath = parts[3]
+	}
+	return svc, nil
+}
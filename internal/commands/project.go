@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/config"
+)
+
+// HandleProject handles the project command and its subcommands
+func HandleProject() {
+	if len(os.Args) < 3 {
+		handleProjectList()
+		return
+	}
+
+	switch os.Args[2] {
+	case "list", "ls":
+		handleProjectList()
+	case "use":
+		handleProjectUse()
+	case "help", "--help", "-h":
+		printProjectHelp()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown project subcommand: %s\n\n", os.Args[2])
+		printProjectHelp()
+		os.Exit(1)
+	}
+}
+
+func printProjectHelp() {
+	fmt.Println("Usage: morpheus project <subcommand>")
+	fmt.Println()
+	fmt.Println("Switch between Hetzner projects. Each project has its own API token,")
+	fmt.Println("configured under secrets.hetzner_projects. Forests record the project")
+	fmt.Println("they were planted in; commands on a forest always use its project's")
+	fmt.Println("token, whichever project is active.")
+	fmt.Println()
+	fmt.Println("Subcommands:")
+	fmt.Println("  list               List configured projects and their forests")
+	fmt.Println("  use <name>         Make a project the active one (persists to config)")
+	fmt.Println()
+	fmt.Println("The HETZNER_PROJECT environment variable overrides the active project.")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus config set hetzner_projects.staging YOUR_TOKEN_HERE")
+	fmt.Println("  morpheus project use staging")
+	fmt.Println("  morpheus plant --project production")
+}
+
+func handleProjectList() {
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Count forests per project; "" is the default hetzner_api_token
+	counts := make(map[string]int)
+	if reg, err := CreateStorage(); err == nil {
+		for _, f := range reg.ListForests() {
+			counts[f.Project]++
+		}
+	}
+
+	names := make([]string, 0, len(cfg.Secrets.HetznerProjects))
+	for name := range cfg.Secrets.HetznerProjects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println("☁️  Hetzner Projects")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+	active := cfg.GetHetznerProject()
+	if cfg.Secrets.HetznerAPIToken != "" || len(names) == 0 {
+		marker := " "
+		if active == "" {
+			marker = "*"
+		}
+		fmt.Printf("%s %-20s %-14s %d forest%s\n", marker, "(default)",
+			config.MaskToken(cfg.Secrets.HetznerAPIToken), counts[""], ui.Plural(counts[""]))
+	}
+	for _, name := range names {
+		marker := " "
+		if name == active {
+			marker = "*"
+		}
+		fmt.Printf("%s %-20s %-14s %d forest%s\n", marker, name,
+			config.MaskToken(cfg.Secrets.HetznerProjects[name]), counts[name], ui.Plural(counts[name]))
+	}
+
+	if _, ok := cfg.Secrets.HetznerProjects[active]; active != "" && !ok {
+		fmt.Println()
+		fmt.Printf("⚠️  Active project %q is not configured\n", active)
+	}
+}
+
+func handleProjectUse() {
+	if len(os.Args) < 4 {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus project use <name>")
+		os.Exit(1)
+	}
+	name := os.Args[3]
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+	if _, err := cfg.GetHetznerToken(name); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	configPath := FindConfigPath()
+	if configPath == "" {
+		fmt.Fprintln(os.Stderr, "❌ No config file found")
+		os.Exit(1)
+	}
+	if err := config.SetConfigValue(configPath, "hetzner_project", name); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to save config: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✅ Active Hetzner project: %s\n", name)
+	if env := os.Getenv("HETZNER_PROJECT"); env != "" && env != name {
+		fmt.Printf("⚠️  HETZNER_PROJECT=%s overrides this setting in the current shell\n", env)
+	}
+}
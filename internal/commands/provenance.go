@@ -0,0 +1,307 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/provenance"
+)
+
+// ProvenanceKeyEnv overrides the key manifests are signed with
+const ProvenanceKeyEnv = "MORPHEUS_PROVENANCE_KEY"
+
+// GetProvenanceDir returns the directory holding the forests' signed
+// provenance manifests, next to the registry
+func GetProvenanceDir() string {
+	return filepath.Join(filepath.Dir(GetRegistryPath()), "provenance")
+}
+
+// provenanceKeyPath returns the path of the signing key (its public key is
+// next to it, with .pub appended)
+func provenanceKeyPath() string {
+	if path := os.Getenv(ProvenanceKeyEnv); path != "" {
+		return path
+	}
+	return filepath.Join(filepath.Dir(GetRegistryPath()), "provenance.key")
+}
+
+// recordProvenance signs and stores the provenance of a forest that was
+// just planted. Failures are reported but do not fail the plant.
+func recordProvenance(provisioner *forest.Provisioner, forestID string, in forest.ProvenanceInputs) {
+	s, err := provisioner.Provenance(context.Background(), forestID, in)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Failed to record provenance: %s\n", err)
+		return
+	}
+	keyPath := provenanceKeyPath()
+	key, created, err := provenance.LoadOrCreateKey(keyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Failed to load provenance signing key: %s\n", err)
+		return
+	}
+	if created {
+		fmt.Printf("🔑 Created provenance signing key %s\n", keyPath)
+	}
+	envelope, err := provenance.Sign(s, key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Failed to sign provenance: %s\n", err)
+		return
+	}
+	path, err := provenance.NewStore(GetProvenanceDir()).Save(forestID, envelope, s.Predicate.RunDetails.Metadata.FinishedOn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Failed to save provenance: %s\n", err)
+		return
+	}
+	fmt.Printf("🔏 Signed provenance: %s\n", path)
+}
+
+// HandleProvenance handles the provenance command.
+func HandleProvenance() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printProvenanceHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	switch os.Args[2] {
+	case "list":
+		if len(os.Args) != 4 {
+			fmt.Fprintln(os.Stderr, "Usage: morpheus provenance list <forest-id>")
+			os.Exit(1)
+		}
+		handleProvenanceList(os.Args[3])
+	case "show", "verify":
+		handleProvenanceShow(os.Args[2], os.Args[3:])
+	default:
+		fmt.Fprintf(os.Stderr, "❌ Unknown provenance command: %s\n", os.Args[2])
+		fmt.Fprintln(os.Stderr, "Use 'morpheus provenance --help' for usage")
+		os.Exit(1)
+	}
+}
+
+func handleProvenanceList(forestID string) {
+	paths, err := provenance.NewStore(GetProvenanceDir()).List(forestID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	if len(paths) == 0 {
+		fmt.Printf("No provenance recorded for forest %s\n", forestID)
+		return
+	}
+	for _, path := range paths {
+		fmt.Println(path)
+	}
+}
+
+// handleProvenanceShow shows or verifies a forest's latest manifest, or
+// the one given with --file
+func handleProvenanceShow(action string, args []string) {
+	var forestID, file, keyPath string
+	asJSON := false
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "--file", "--key":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "❌ %s requires a path\n", arg)
+				os.Exit(1)
+			}
+			i++
+			if arg == "--file" {
+				file = args[i]
+			} else {
+				keyPath = args[i]
+			}
+		case "--json":
+			asJSON = true
+		default:
+			if startsWithDash(arg) || forestID != "" {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+				os.Exit(1)
+			}
+			forestID = arg
+		}
+	}
+	if forestID == "" && file == "" {
+		fmt.Fprintf(os.Stderr, "Usage: morpheus provenance %s <forest-id> [--file PATH]\n", action)
+		os.Exit(1)
+	}
+
+	var envelope *provenance.Envelope
+	var err error
+	if file != "" {
+		envelope, err = provenance.Load(file)
+	} else {
+		envelope, file, err = provenance.NewStore(GetProvenanceDir()).Latest(forestID)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	if action == "show" {
+		s, err := envelope.Statement()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s: %s\n", file, err)
+			os.Exit(1)
+		}
+		if asJSON {
+			data, _ := json.MarshalIndent(s, "", "  ")
+			fmt.Println(string(data))
+			return
+		}
+		printStatement(file, s)
+		return
+	}
+
+	if keyPath == "" {
+		keyPath = provenanceKeyPath() + ".pub"
+	}
+	pub, err := provenance.LoadPublicKey(keyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to load public key: %s\n", err)
+		os.Exit(1)
+	}
+	s, err := provenance.Verify(envelope, pub)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s: %s\n", file, err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Signature valid (key %s)\n", shortDigest(provenance.KeyID(pub)))
+	if forestID == "" {
+		if id, ok := s.Predicate.BuildDefinition.ExternalParameters["forestId"].(string); ok {
+			forestID = id
+		}
+	}
+	if !verifyProvenanceSubjects(forestID, s) {
+		os.Exit(1)
+	}
+}
+
+// verifyProvenanceSubjects compares the servers a manifest describes with
+// the forest's current nodes. It reports whether they all still match.
+func verifyProvenanceSubjects(forestID string, s *provenance.Statement) bool {
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to create storage: %s\n", err)
+		os.Exit(1)
+	}
+	f, err := reg.GetForest(forestID)
+	if err != nil {
+		fmt.Printf("💡 Forest %s is not in the registry, so its servers were not compared\n", forestID)
+		return true
+	}
+	nodes, err := reg.GetNodes(forestID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to get nodes: %s\n", err)
+		os.Exit(1)
+	}
+
+	recorded := make(map[string]provenance.ResourceDescriptor)
+	for _, subject := range s.Subject {
+		recorded[subject.URI] = subject
+	}
+	ok := true
+	for i, node := range nodes {
+		current, err := forest.NodeSubject(f, node, i)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			os.Exit(1)
+		}
+		subject, found := recorded[current.URI]
+		delete(recorded, current.URI)
+		switch {
+		case !found:
+			fmt.Printf("   ✗ %s (%s) is not in the manifest\n", current.Name, node.ID)
+			ok = false
+		case subject.Digest["sha256"] != current.Digest["sha256"]:
+			fmt.Printf("   ✗ %s (%s) has changed since the manifest was made\n", current.Name, node.ID)
+			ok = false
+		default:
+			fmt.Printf("   ✓ %s (%s)\n", current.Name, node.ID)
+		}
+	}
+	for _, subject := range recorded {
+		fmt.Printf("   ✗ %s (%s) is gone\n", subject.Name, subject.URI)
+		ok = false
+	}
+	if ok {
+		fmt.Printf("✓ All %d servers match the manifest\n", len(nodes))
+	} else {
+		fmt.Println("⚠️  The forest no longer matches its manifest")
+	}
+	return ok
+}
+
+// printStatement prints a summary of a manifest
+func printStatement(path string, s *provenance.Statement) {
+	meta := s.Predicate.RunDetails.Metadata
+	fmt.Printf("📜 %s\n", path)
+	fmt.Printf("   Built:      %s (%s)\n", meta.FinishedOn.Local().Format(time.RFC3339), meta.FinishedOn.Sub(meta.StartedOn).Round(time.Second))
+	if v := s.Predicate.RunDetails.Builder.Version["morpheus"]; v != "" {
+		fmt.Printf("   Morpheus:   %s\n", v)
+	}
+	fmt.Println("\nInputs:")
+	for _, dep := range s.Predicate.BuildDefinition.ResolvedDependencies {
+		if digest := dep.Digest["sha256"]; digest != "" {
+			fmt.Printf("   %-20s %s  sha256:%s\n", dep.Name, dep.URI, shortDigest(digest))
+		} else {
+			fmt.Printf("   %-20s %s\n", dep.Name, dep.URI)
+		}
+	}
+	fmt.Println("\nServers:")
+	for _, subject := range s.Subject {
+		fmt.Printf("   %-20s %s  sha256:%s\n", subject.Name, subject.URI, shortDigest(subject.Digest["sha256"]))
+	}
+	if len(s.Predicate.RunDetails.Byproducts) > 0 {
+		fmt.Println("\nOther resources:")
+		for _, r := range s.Predicate.RunDetails.Byproducts {
+			fmt.Printf("   %-20s %s\n", r.Name, r.URI)
+		}
+	}
+}
+
+// shortDigest abbreviates a hex digest for display
+func shortDigest(digest string) string {
+	if len(digest) > 12 {
+		return digest[:12]
+	}
+	return digest
+}
+
+func printProvenanceHelp() {
+	fmt.Println("Usage: morpheus provenance <list|show|verify> <forest-id> [options]")
+	fmt.Println()
+	fmt.Println("Every plant records signed provenance for the forest: an in-toto")
+	fmt.Println("statement (SLSA provenance) of the exact inputs - config file, plant")
+	fmt.Println("request, cloud-init templates, images and each server's cloud-init")
+	fmt.Println("hash - and the resources that were created. Manifests are signed with")
+	fmt.Println("an ed25519 key (DSSE) and kept in ~/.morpheus/provenance/<forest-id>/.")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  list <forest-id>       List the forest's manifests, oldest first")
+	fmt.Println("  show <forest-id>       Show the latest manifest")
+	fmt.Println("  verify <forest-id>     Check the latest manifest's signature, and that the")
+	fmt.Println("                         forest's servers are still the ones it describes")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --file PATH            Use this manifest instead of the latest")
+	fmt.Println("  --key PATH             Public key to verify with (default:")
+	fmt.Println("                         ~/.morpheus/provenance.key.pub)")
+	fmt.Println("  --json                 Print the statement as JSON (show)")
+	fmt.Println()
+	fmt.Println("The signing key is created on first use at ~/.morpheus/provenance.key;")
+	fmt.Printf("set %s to sign with another key.\n", ProvenanceKeyEnv)
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus provenance show forest-123")
+	fmt.Println("  morpheus provenance verify forest-123 --key ci-signer.pub")
+	fmt.Println("  morpheus provenance verify --file forest-123.intoto.json")
+}
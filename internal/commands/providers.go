@@ -0,0 +1,394 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/cloudcreds"
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/dns"
+	dnshetzner "github.com/nimsforest/morpheus/pkg/dns/hetzner"
+	dnsnone "github.com/nimsforest/morpheus/pkg/dns/none"
+	guardaws "github.com/nimsforest/morpheus/pkg/guard/aws"
+	"github.com/nimsforest/morpheus/pkg/guard/azure"
+	"github.com/nimsforest/morpheus/pkg/guard/gcp"
+	guardhetzner "github.com/nimsforest/morpheus/pkg/guard/hetzner"
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/machine/hetzner"
+	machinenone "github.com/nimsforest/morpheus/pkg/machine/none"
+	"github.com/nimsforest/morpheus/pkg/machine/proxmox"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// Provider statuses reported by the providers command
+const (
+	providerNotConfigured = "not configured"
+	providerConfigured    = "configured"
+	providerValid         = "credentials valid"
+	providerInvalid       = "credentials invalid"
+	providerUnreachable   = "unreachable"
+	providerBuiltIn       = "built-in"
+)
+
+var providerKindTitles = map[string]string{
+	"machine": "Machine",
+	"dns":     "DNS",
+	"guard":   "Guard",
+	"storage": "Storage",
+}
+
+// providerInfo describes one compiled-in provider
+type providerInfo struct {
+	Kind         string   `json:"kind"`
+	Name         string   `json:"name"`
+	Active       bool     `json:"active"` // Selected by the configuration
+	Status       string   `json:"status"`
+	Detail       string   `json:"detail,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// HandleProviders handles the providers command.
+func HandleProviders() {
+	jsonOutput := false
+	check := true
+	for _, arg := range os.Args[2:] {
+		switch arg {
+		case "--json":
+			jsonOutput = true
+		case "--no-check":
+			check = false
+		case "--help", "-h":
+			printProvidersHelp()
+			return
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+			fmt.Fprintln(os.Stderr, "Use 'morpheus providers --help' for usage")
+			os.Exit(1)
+		}
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		cfg = &config.Config{} // Still show what is compiled in
+	}
+
+	probes := providerProbes(cfg)
+	infos := make([]providerInfo, len(probes))
+	checked := false
+	for i, p := range probes {
+		infos[i] = p.info
+		if !check || p.check == nil || p.info.Status != providerConfigured {
+			continue
+		}
+		if !jsonOutput {
+			fmt.Fprintf(os.Stderr, "\r⏳ Checking %s %s...          ", p.info.Name, p.info.Kind)
+			checked = true
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		infos[i].Status, infos[i].Detail = probeStatus(p.check(ctx))
+		cancel()
+	}
+
+	if jsonOutput {
+		jsonData, _ := json.MarshalIndent(infos, "", "  ")
+		fmt.Println(string(jsonData))
+		return
+	}
+	if checked {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+
+	fmt.Println("🔌 Providers")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	kind := ""
+	for _, info := range infos {
+		if info.Kind != kind {
+			kind = info.Kind
+			fmt.Printf("\n%s\n", providerKindTitles[kind])
+		}
+		marker := " "
+		if info.Active {
+			marker = "*"
+		}
+		fmt.Printf("  %s %-11s %s %s", marker, info.Name, providerStatusIcon(info.Status), info.Status)
+		if info.Detail != "" {
+			fmt.Printf(" (%s)", info.Detail)
+		}
+		fmt.Println()
+		if len(info.Capabilities) > 0 {
+			fmt.Printf("      %s\n", strings.Join(info.Capabilities, ", "))
+		}
+	}
+	fmt.Println()
+	fmt.Println("* = used by your configuration")
+	if !check {
+		fmt.Println("💡 Run without --no-check to verify credentials")
+	}
+}
+
+// providerProbe is a provider with a function that verifies its
+// credentials with a cheap read-only call
+type providerProbe struct {
+	info  providerInfo
+	check func(ctx context.Context) error
+}
+
+// providerProbes lists every compiled-in provider with its configuration
+// status from cfg
+func providerProbes(cfg *config.Config) []providerProbe {
+	var probes []providerProbe
+	add := func(kind, name string, active bool, status string, capabilities []string, check func(ctx context.Context) error) {
+		probes = append(probes, providerProbe{
+			info:  providerInfo{Kind: kind, Name: name, Active: active, Status: status, Capabilities: capabilities},
+			check: check,
+		})
+	}
+	configured := func(ok bool) string {
+		if ok {
+			return providerConfigured
+		}
+		return providerNotConfigured
+	}
+
+	// Machine providers
+	token, tokenErr := cfg.GetHetznerToken("")
+	add("machine", "hetzner", cfg.GetMachineProvider() == "hetzner", configured(tokenErr == nil && token != ""),
+		machineCapabilities((*hetzner.Provider)(nil)),
+		func(ctx context.Context) error {
+			p, err := hetzner.NewProvider(token)
+			if err != nil {
+				return err
+			}
+			_, err = p.ListServers(ctx, nil)
+			return err
+		})
+
+	proxmoxConfig := proxmoxProviderConfig(cfg)
+	add("machine", "proxmox", cfg.GetMachineProvider() == "proxmox", configured(proxmoxConfig.Host != "" && proxmoxConfig.APITokenSecret != ""),
+		append(machineCapabilities((*proxmox.Provider)(nil)), "boot-modes"),
+		func(ctx context.Context) error {
+			client, err := proxmox.NewClient(proxmoxConfig)
+			if err != nil {
+				return err
+			}
+			_, err = client.ListVMs(ctx)
+			return err
+		})
+
+	add("machine", "none", cfg.GetMachineProvider() == "none", providerBuiltIn,
+		machineCapabilities((*machinenone.Provider)(nil)), nil)
+
+	// DNS providers
+	dnsToken := cfg.GetDNSToken()
+	add("dns", "hetzner", cfg.DNS.Domain != "" && dnsToken != "", configured(dnsToken != ""),
+		dnsCapabilities((*dnshetzner.Provider)(nil)),
+		func(ctx context.Context) error {
+			p, err := newHetznerDNSProvider(cfg, dnsToken)
+			if err != nil {
+				return err
+			}
+			_, err = p.ListZones(ctx)
+			return err
+		})
+	add("dns", "none", cfg.DNS.Provider == "none", providerBuiltIn,
+		dnsCapabilities((*dnsnone.Provider)(nil)), nil)
+
+	// Guard providers, as morpheus-azureguard --provider selects them
+	guardProvider := cfg.GetGuardProvider()
+	az := cfg.Machine.Azure
+	azureConfigured := az.Profile != "" || (az.SubscriptionID != "" && (az.GetAuth() != "client_secret" || az.ClientID != "" && az.ClientSecret != ""))
+	add("guard", "azure", guardProvider == "azure" && azureConfigured, configured(azureConfigured),
+		guardCapabilities["azure"],
+		func(ctx context.Context) error {
+			p, err := azure.NewConfiguredProvider(az)
+			if err != nil {
+				return err
+			}
+			_, err = p.ListGuards(ctx)
+			return err
+		})
+
+	gc := cfg.Machine.GCP
+	gcpCreds, gcpErr := cloudcreds.LoadGCPConfiguration(gc.Configuration)
+	gcpConfigured := gcpErr == nil || gc.Project != ""
+	add("guard", "gcp", guardProvider == "gcp" && gcpConfigured, configured(gcpConfigured),
+		guardCapabilities["gcp"],
+		func(ctx context.Context) error {
+			if gcpErr != nil {
+				// No gcloud configuration is needed with an explicit project
+				gcpCreds = &cloudcreds.GCPCredentials{Configuration: gc.Configuration, Project: gc.Project}
+			}
+			project := gc.Project
+			if project == "" {
+				project = gcpCreds.Project
+			}
+			tokens, err := gcp.NewTokenSource(gcpCreds)
+			if err != nil {
+				return err
+			}
+			p, err := gcp.NewProvider(project, gc.Zone, gc.MachineType, gc.Image, tokens)
+			if err != nil {
+				return err
+			}
+			_, err = p.ListGuards(ctx)
+			return err
+		})
+
+	// AWS_ACCESS_KEY_ID is used unless machine.aws.profile names a profile
+	ac := cfg.Machine.AWS
+	awsCreds := &cloudcreds.AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Region:          os.Getenv("AWS_REGION"),
+	}
+	var awsErr error
+	if awsCreds.AccessKeyID == "" || ac.Profile != "" {
+		awsCreds, awsErr = cloudcreds.LoadAWSProfile(ac.Profile)
+	}
+	add("guard", "aws", guardProvider == "aws" && awsErr == nil, configured(awsErr == nil),
+		guardCapabilities["aws"],
+		func(ctx context.Context) error {
+			region := ac.Region
+			if region == "" {
+				region = awsCreds.Region
+			}
+			p, err := guardaws.NewProvider(region, ac.InstanceType, ac.Image, guardaws.Credentials{
+				AccessKeyID:     awsCreds.AccessKeyID,
+				SecretAccessKey: awsCreds.SecretAccessKey,
+				SessionToken:    awsCreds.SessionToken,
+			})
+			if err != nil {
+				return err
+			}
+			_, err = p.ListGuards(ctx)
+			return err
+		})
+
+	// The Hetzner guard uses the machine provider's token
+	hz := cfg.Machine.Hetzner
+	add("guard", "hetzner", guardProvider == "hetzner" && tokenErr == nil && token != "", configured(tokenErr == nil && token != ""),
+		guardCapabilities["hetzner"],
+		func(ctx context.Context) error {
+			p, err := guardhetzner.NewProvider(token, hz.Location, hz.ServerType, hz.Image)
+			if err != nil {
+				return err
+			}
+			_, err = p.ListGuards(ctx)
+			return err
+		})
+
+	// Storage providers
+	add("storage", "local", cfg.GetStorageProvider() == "local", providerBuiltIn, nil, nil)
+	probes[len(probes)-1].info.Detail = GetRegistryPath()
+
+	sb := cfg.Storage.StorageBox
+	sbURL := cfg.Registry.URL
+	if sbURL == "" && sb.Host != "" {
+		sbURL = "https://" + sb.Host + "/"
+	}
+	add("storage", "storagebox", cfg.IsRemoteRegistry(), configured(sbURL != "" && sb.Username != ""), nil,
+		func(ctx context.Context) error {
+			return storage.NewStorageBoxRegistry(sbURL, sb.Username, sb.Password).Ping()
+		})
+
+	return probes
+}
+
+// machineCapabilities returns the optional operations a machine provider
+// implements
+func machineCapabilities(p machine.Provider) []string {
+	var caps []string
+	if _, ok := p.(machine.LocationAwareProvider); ok {
+		caps = append(caps, "location-check")
+	}
+	if _, ok := p.(machine.LabelUpdater); ok {
+		caps = append(caps, "labels")
+	}
+	if _, ok := p.(machine.VolumeManager); ok {
+		caps = append(caps, "volumes")
+	}
+	if _, ok := p.(machine.LoadBalancerManager); ok {
+		caps = append(caps, "load-balancers")
+	}
+	if _, ok := p.(machine.FloatingIPManager); ok {
+		caps = append(caps, "floating-ips")
+	}
+	if _, ok := p.(machine.PlacementGroupManager); ok {
+		caps = append(caps, "placement-groups")
+	}
+	if _, ok := p.(machine.SnapshotManager); ok {
+		caps = append(caps, "snapshots")
+	}
+	if _, ok := p.(machine.CostReporter); ok {
+		caps = append(caps, "costs")
+	}
+	return caps
+}
+
+// dnsCapabilities returns the optional operations a DNS provider implements
+func dnsCapabilities(p dns.Provider) []string {
+	caps := []string{"records", "zones"}
+	if _, ok := p.(dns.RRSetCreator); ok {
+		caps = append(caps, "record-sets")
+	}
+	if _, ok := p.(dns.TTLChanger); ok {
+		caps = append(caps, "ttl-change")
+	}
+	return caps
+}
+
+// guardCapabilities are the operations of each guard provider, named as
+// the cloud calls them. Every guard provider implements all of
+// guard.GuardProvider, but not every cloud can run commands on a VM.
+var guardCapabilities = map[string][]string{
+	"azure": {"networks", "nsg-rules", "peering", "route-tables", "run-command", "discovery"},
+	"gcp":   {"networks", "firewall-rules", "peering", "routes", "discovery"},
+	"aws":   {"networks", "security-groups", "peering", "route-tables", "discovery"},
+
+	// Hetzner has no peering; guards are attached to workload networks
+	"hetzner": {"networks", "firewalls", "network-attach", "routes", "discovery"},
+}
+
+// probeStatus turns the result of a credential check into a status
+func probeStatus(err error) (string, string) {
+	if err == nil {
+		return providerValid, ""
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"unauthorized", "401", "403", "forbidden", "authentication", "invalid token", "credential"} {
+		if strings.Contains(msg, s) {
+			return providerInvalid, err.Error()
+		}
+	}
+	return providerUnreachable, ClassifyNetError(err)
+}
+
+func providerStatusIcon(status string) string {
+	switch status {
+	case providerValid, providerBuiltIn:
+		return "✅"
+	case providerConfigured:
+		return "🔧"
+	case providerNotConfigured:
+		return "⚪"
+	default:
+		return "❌"
+	}
+}
+
+func printProvidersHelp() {
+	fmt.Println("Usage: morpheus providers [--no-check] [--json]")
+	fmt.Println()
+	fmt.Println("List the machine, DNS, guard and storage providers built into morpheus,")
+	fmt.Println("whether they are configured, and what they support. The credentials of")
+	fmt.Println("configured providers are verified with a read-only API call.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --no-check             Don't contact the providers")
+	fmt.Println("  --json                 Output as JSON")
+}
@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/lockfile"
+)
+
+// HandleReplaceNode handles the replace-node command.
+func HandleReplaceNode() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printReplaceNodeHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	var req forest.ReplaceRequest
+	var args []string
+	for i := 2; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		switch arg {
+		case "--server-type", "--image":
+			if i+1 >= len(os.Args) || startsWithDash(os.Args[i+1]) {
+				fmt.Fprintf(os.Stderr, "❌ %s requires a value\n", arg)
+				os.Exit(1)
+			}
+			i++
+			if arg == "--server-type" {
+				req.ServerType = os.Args[i]
+			} else {
+				req.Image = os.Args[i]
+			}
+		default:
+			if startsWithDash(arg) {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+				os.Exit(1)
+			}
+			args = append(args, arg)
+		}
+	}
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus replace-node <forest-id> <node> [--server-type T] [--image I]")
+		os.Exit(1)
+	}
+	req.ForestID, req.Node = args[0], args[1]
+
+	provisioner, _ := forestProvisioner(req.ForestID)
+
+	lock, err := AcquireForestLock(req.ForestID, "replace-node "+req.Node, lockfile.DefaultTTL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	defer lock.Release()
+
+	fmt.Println()
+	server, err := provisioner.ReplaceNode(context.Background(), req)
+	if err != nil {
+		lock.Release()
+		fmt.Fprintf(os.Stderr, "\n❌ %s\n", err)
+		os.Exit(1)
+	}
+	refreshMetadata(provisioner, req.ForestID)
+
+	fmt.Printf("\n✅ Node %s of %s replaced by server %s\n", req.Node, req.ForestID, server.ID)
+	fmt.Printf("💡 View the forest: morpheus status %s\n", req.ForestID)
+}
+
+func printReplaceNodeHelp() {
+	fmt.Println("Usage: morpheus replace-node <forest-id> <node> [options]")
+	fmt.Println()
+	fmt.Println("Replace one node of a forest with a new server. The node is given by")
+	fmt.Println("its number (2 or node-2), server name or server ID.")
+	fmt.Println()
+	fmt.Println("The new server gets the node's role, location and labels. Once it is")
+	fmt.Println("ready it takes the node's place: its number, DNS records and, if the")
+	fmt.Println("node was primary, the floating IP. Then the old server and its volume")
+	fmt.Println("are deleted; data on the volume is not copied. If the new server does")
+	fmt.Println("not come up, it is deleted and the old node is kept.")
+	fmt.Println()
+	fmt.Println("Replace nodes one at a time to rebuild a forest onto a new image or a")
+	fmt.Println("bigger server type without taking it down.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --server-type T        Server type of the new server (default: the forest's)")
+	fmt.Println("  --image I              Image of the new server (default: the forest's)")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus replace-node forest-123 node-2")
+	fmt.Println("  morpheus replace-node forest-123 1 --server-type cx32")
+	fmt.Println("  morpheus replace-node forest-123 3 --image ubuntu-24.04")
+}
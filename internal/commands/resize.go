@@ -0,0 +1,148 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/lockfile"
+)
+
+// HandleResize handles the resize command.
+func HandleResize() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printResizeHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	var req forest.ResizeRequest
+	var args []string
+	overrideBudget := false
+	for i := 2; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		switch arg {
+		case "--server-type", "--node", "--timeout":
+			if i+1 >= len(os.Args) || startsWithDash(os.Args[i+1]) {
+				fmt.Fprintf(os.Stderr, "❌ %s requires a value\n", arg)
+				os.Exit(1)
+			}
+			i++
+			switch arg {
+			case "--server-type":
+				req.ServerType = os.Args[i]
+			case "--node":
+				req.Node = os.Args[i]
+			case "--timeout":
+				d, err := time.ParseDuration(os.Args[i])
+				if err != nil || d <= 0 {
+					fmt.Fprintf(os.Stderr, "❌ Invalid duration: %s\n", os.Args[i])
+					os.Exit(1)
+				}
+				req.ShutdownTimeout = d
+			}
+		case "--upgrade-disk":
+			req.UpgradeDisk = true
+		case "--force":
+			req.Force = true
+		case "--override-budget":
+			overrideBudget = true
+		default:
+			if startsWithDash(arg) {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+				os.Exit(1)
+			}
+			args = append(args, arg)
+		}
+	}
+	if len(args) != 1 || req.ServerType == "" {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus resize <forest-id> --server-type TYPE [--node N]")
+		os.Exit(1)
+	}
+	req.ForestID = args[0]
+
+	provisioner, reg := forestProvisioner(req.ForestID)
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+
+	target := "all nodes"
+	if req.Node != "" {
+		target = "node " + req.Node
+	} else if nodes, err := reg.GetNodes(req.ForestID); err == nil {
+		target = fmt.Sprintf("all %d node%s, one at a time", len(nodes), ui.Plural(len(nodes)))
+	}
+	fmt.Printf("\n📐 Resizing %s of %s to %s\n", target, req.ForestID, req.ServerType)
+
+	ctx := context.Background()
+	delta, priced, err := provisioner.ResizeCost(ctx, req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n❌ %s\n", err)
+		os.Exit(1)
+	}
+	if priced {
+		fmt.Printf("💰 Monthly cost change: %+.2f\n", delta)
+		if !budgetAllows(cfg, reg, delta, overrideBudget) {
+			os.Exit(1)
+		}
+	}
+
+	lock, err := AcquireForestLock(req.ForestID, "resize "+req.ServerType, lockfile.DefaultTTL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	defer lock.Release()
+
+	fmt.Println()
+	resized, err := provisioner.Resize(ctx, req)
+	if err != nil {
+		lock.Release()
+		fmt.Fprintf(os.Stderr, "\n❌ %s\n", err)
+		if len(resized) > 0 {
+			fmt.Fprintf(os.Stderr, "   %d node%s resized before the failure\n", len(resized), ui.Plural(len(resized)))
+		}
+		fmt.Fprintf(os.Stderr, "💡 Check the forest with: morpheus status %s\n", req.ForestID)
+		os.Exit(1)
+	}
+	if len(resized) == 0 {
+		fmt.Printf("\n✅ Nothing to do: already %s\n", req.ServerType)
+		return
+	}
+	fmt.Printf("\n✅ Resized %d node%s of %s to %s\n", len(resized), ui.Plural(len(resized)), req.ForestID, req.ServerType)
+	fmt.Printf("💡 View the forest: morpheus status %s\n", req.ForestID)
+}
+
+func printResizeHelp() {
+	fmt.Println("Usage: morpheus resize <forest-id> --server-type TYPE [options]")
+	fmt.Println()
+	fmt.Println("Change the server type of a forest's nodes, e.g. to give them more CPU")
+	fmt.Println("and memory. Each node is powered off (gracefully, as with 'morpheus node")
+	fmt.Println("poweroff'), resized, powered on and waited for before the next one, so")
+	fmt.Println("the rest of the forest keeps running. Nodes keep their IPs and disks.")
+	fmt.Println()
+	fmt.Println("Resizing all nodes also makes nodes added later use the new type. The")
+	fmt.Println("forest's expected spend follows the new type, and increases are checked")
+	fmt.Println("against limits.max_monthly_cost.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --server-type TYPE     New server type (required)")
+	fmt.Println("  --node N               Resize only this node (number, node-N, name or ID)")
+	fmt.Println("  --upgrade-disk         Grow the disks to the new type's; the nodes can then")
+	fmt.Println("                         not be resized to a type with a smaller disk")
+	fmt.Println("  --force                Cut the power right away instead of shutting down")
+	fmt.Println("  --timeout D            How long a graceful shutdown may take (default: 2m)")
+	fmt.Println("  --override-budget      Resize even if it exceeds limits.max_monthly_cost")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus resize forest-123 --server-type cx42")
+	fmt.Println("  morpheus resize forest-123 --server-type cx42 --node 2")
+	fmt.Println("  morpheus resize forest-123 --server-type cpx31 --upgrade-disk")
+}
@@ -0,0 +1,231 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/lockfile"
+)
+
+// Exit codes for scale, so external autoscalers can tell a refused
+// operation apart from a failed one.
+const (
+	scaleExitFailed  = 1
+	scaleExitSkipped = 2 // Another scale is running or the forest is in cooldown
+)
+
+// HandleScale handles the scale command.
+func HandleScale() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printScaleHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	forestID := os.Args[2]
+	target := -1
+	cooldown := forest.DefaultScaleCooldown
+	force := false
+	overrideBudget := false
+	jsonOutput := false
+
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--to":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --to requires a node count")
+				os.Exit(1)
+			}
+			i++
+			n, err := strconv.Atoi(os.Args[i])
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid node count: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			target = n
+		case "--cooldown":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --cooldown requires a duration (e.g. 5m)")
+				os.Exit(1)
+			}
+			i++
+			d, err := time.ParseDuration(os.Args[i])
+			if err != nil || d < 0 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid cooldown: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			cooldown = d
+		case "--force":
+			force = true
+		case "--override-budget":
+			overrideBudget = true
+		case "--json":
+			jsonOutput = true
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+			fmt.Fprintln(os.Stderr, "Use 'morpheus scale --help' for usage")
+			os.Exit(1)
+		}
+	}
+
+	if target < 1 {
+		fmt.Fprintln(os.Stderr, "❌ --to is required")
+		fmt.Fprintln(os.Stderr, "Usage: morpheus scale <forest-id> --to N")
+		os.Exit(1)
+	}
+	if force {
+		cooldown = 0
+	}
+
+	// In JSON mode, progress output goes to stderr so stdout stays parseable
+	stdout := os.Stdout
+	if jsonOutput {
+		os.Stdout = os.Stderr
+	}
+
+	result, err := runScale(forestID, target, cooldown, overrideBudget)
+
+	os.Stdout = stdout
+
+	if jsonOutput {
+		output := map[string]interface{}{
+			"forest_id": forestID,
+			"target":    target,
+		}
+		if result != nil {
+			output["previous_count"] = result.PreviousCount
+			output["current_count"] = result.CurrentCount
+			output["action"] = result.Action
+			output["added_nodes"] = result.AddedNodes
+			output["removed_nodes"] = result.RemovedNodes
+		}
+		if err != nil {
+			output["error"] = err.Error()
+			output["skipped"] = isScaleSkipped(err)
+		}
+		jsonData, _ := json.MarshalIndent(output, "", "  ")
+		fmt.Println(string(jsonData))
+	}
+
+	if err != nil {
+		if isScaleSkipped(err) {
+			if !jsonOutput {
+				fmt.Fprintf(os.Stderr, "⏸️  Scale skipped: %s\n", err)
+			}
+			os.Exit(scaleExitSkipped)
+		}
+		if !jsonOutput {
+			fmt.Fprintf(os.Stderr, "\n❌ Scale failed: %s\n", err)
+			printBudgetHint(err)
+		}
+		os.Exit(scaleExitFailed)
+	}
+
+	if jsonOutput {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	switch result.Action {
+	case "none":
+		fmt.Printf("✅ Forest %s already has %d node%s\n", forestID, result.CurrentCount, ui.Plural(result.CurrentCount))
+	default:
+		fmt.Printf("✅ Forest %s scaled from %d to %d node%s\n", forestID, result.PreviousCount, result.CurrentCount, ui.Plural(result.CurrentCount))
+	}
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Println()
+	fmt.Printf("💡 View updated cluster: morpheus status %s\n", forestID)
+}
+
+// runScale takes the forest lock and performs the scale operation.
+func runScale(forestID string, target int, cooldown time.Duration, overrideBudget bool) (*forest.ScaleResult, error) {
+	lock, err := AcquireForestLock(forestID, fmt.Sprintf("scale --to %d", target), lockfile.DefaultTTL)
+	if err != nil {
+		if lockfile.IsLocked(err) {
+			return nil, fmt.Errorf("%w: %s", forest.ErrScaleInProgress, err)
+		}
+		return nil, err
+	}
+	defer lock.Release()
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	reg, err := CreateStorage()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load storage: %w", err)
+	}
+
+	UseForestProject(cfg, reg, forestID)
+	machineProv, _, err := CreateMachineProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var provisioner *forest.Provisioner
+	if dnsProv := CreateDNSProvider(cfg); dnsProv != nil {
+		provisioner = forest.NewProvisionerWithDNS(machineProv, reg, dnsProv, cfg)
+	} else {
+		provisioner = forest.NewProvisioner(machineProv, reg, cfg)
+	}
+	configureInventory(provisioner, cfg)
+	configureSecrets(provisioner, cfg)
+
+	fmt.Printf("\n⚖️  Scaling forest %s to %d node%s\n", forestID, target, ui.Plural(target))
+
+	result, err := provisioner.Scale(context.Background(), forest.ScaleRequest{
+		ForestID:    forestID,
+		TargetCount: target,
+		Cooldown:    cooldown,
+		Image:       cfg.GetImage(),
+
+		OverrideBudget: overrideBudget,
+	})
+	if err == nil && result.Action != "none" {
+		refreshMetadata(provisioner, forestID)
+	}
+	return result, err
+}
+
+// isScaleSkipped reports whether a scale error means the request was refused
+// (concurrent operation or cooldown) rather than failed.
+func isScaleSkipped(err error) bool {
+	var cooldownErr *forest.CooldownError
+	return errors.Is(err, forest.ErrScaleInProgress) || errors.As(err, &cooldownErr)
+}
+
+func printScaleHelp() {
+	fmt.Println("Usage: morpheus scale <forest-id> --to N [options]")
+	fmt.Println()
+	fmt.Println("Scale a forest to exactly N nodes. Intended to be called by external")
+	fmt.Println("autoscalers (CPU, queue depth, ...). Overlapping scale operations are")
+	fmt.Println("refused, and a cooldown is enforced between operations.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --to N             Target node count (required, minimum 1)")
+	fmt.Printf("  --cooldown D       Minimum time since last scale (default: %s)\n", forest.DefaultScaleCooldown)
+	fmt.Println("  --force            Ignore the cooldown")
+	fmt.Println("  --override-budget  Add nodes even if limits.max_monthly_cost would be exceeded")
+	fmt.Println("  --json             Output result as JSON (progress goes to stderr)")
+	fmt.Println()
+	fmt.Println("Exit codes:")
+	fmt.Println("  0  Scaled (or already at target size)")
+	fmt.Println("  1  Scale failed")
+	fmt.Println("  2  Skipped: another scale is running or the forest is in cooldown")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus scale forest-123 --to 5")
+	fmt.Println("  morpheus scale forest-123 --to 2 --cooldown 10m --json")
+}
@@ -0,0 +1,510 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/customer"
+	"github.com/nimsforest/morpheus/pkg/guard/azure"
+)
+
+// stdin is shared by the prompts of a rotation, so input typed ahead is
+// not lost between them
+var stdin = bufio.NewReader(os.Stdin)
+
+// HandleSecrets handles the secrets command
+func HandleSecrets() {
+	if len(os.Args) < 3 {
+		printSecretsHelp()
+		os.Exit(1)
+	}
+
+	switch os.Args[2] {
+	case "rotate":
+		if len(os.Args) < 4 {
+			printSecretsHelp()
+			os.Exit(1)
+		}
+		switch os.Args[3] {
+		case "hetzner":
+			handleRotateHetzner(os.Args[4:])
+		case "azure":
+			handleRotateAzure(os.Args[4:])
+		case "help", "--help", "-h":
+			printSecretsHelp()
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown credential: %s (expected hetzner or azure)\n", os.Args[3])
+			os.Exit(1)
+		}
+	case "keygen":
+		handleSecretsKeygen(os.Args[3:])
+	case "list", "get", "put", "rm":
+		handleSecretsStore(os.Args[2], os.Args[3:])
+	case "log":
+		handleSecretsLog(os.Args[3:])
+	case "help", "--help", "-h":
+		printSecretsHelp()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown secrets subcommand: %s\n\n", os.Args[2])
+		printSecretsHelp()
+		os.Exit(1)
+	}
+}
+
+func printSecretsHelp() {
+	fmt.Println("🔐 Morpheus Secrets - Credentials and the Secret Store")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  morpheus secrets rotate hetzner [--project NAME] [--revoke-old]")
+	fmt.Println("  morpheus secrets rotate azure [--revoke-old]")
+	fmt.Println("  morpheus secrets keygen")
+	fmt.Println("  " + secretsUsage["list"])
+	fmt.Println("  " + secretsUsage["get"])
+	fmt.Println("  " + secretsUsage["put"])
+	fmt.Println("  " + secretsUsage["rm"])
+	fmt.Println("  morpheus secrets log [--limit N]")
+	fmt.Println()
+	fmt.Println("rotate walks through creating a new credential, checks it against the live API,")
+	fmt.Println("switches every config entry holding the old one in a single write, then")
+	fmt.Println("checks that existing forests and guards are still reachable.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --project NAME   Hetzner project to rotate (default: active project)")
+	fmt.Println("  --revoke-old     Revoke the old credential once the new one is in use")
+	fmt.Println()
+	fmt.Println("Nothing is changed unless the new credential works and sees the same")
+	fmt.Println("resources as the old one.")
+	fmt.Println()
+	fmt.Println("The secret store (secrets.store in the config) keeps generated keys,")
+	fmt.Println("certificates and credentials, such as NATS CAs and guard WireGuard keys,")
+	fmt.Println("encrypted with age in a directory, on the StorageBox or in Vault:")
+	fmt.Println()
+	fmt.Println("  keygen   Create the age key secrets are encrypted with")
+	fmt.Println("  list     List the keys of stored secrets")
+	fmt.Println("  get      Print a secret")
+	fmt.Println("  put      Store a secret from a file or stdin")
+	fmt.Println("  rm       Remove a secret")
+	fmt.Println("  log      Show who accessed which secret (default: last 50)")
+}
+
+// parseRotateFlags parses the options shared by the rotate subcommands
+func parseRotateFlags(args []string, allowProject bool) (project string, revoke bool) {
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--revoke-old":
+			revoke = true
+		case "--project":
+			if !allowProject {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", args[i])
+				os.Exit(1)
+			}
+			if i+1 >= len(args) || startsWithDash(args[i+1]) {
+				fmt.Fprintln(os.Stderr, "❌ --project requires a value")
+				os.Exit(1)
+			}
+			i++
+			project = args[i]
+		case "--help", "-h":
+			printSecretsHelp()
+			os.Exit(0)
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", args[i])
+			os.Exit(1)
+		}
+	}
+	return project, revoke
+}
+
+func handleRotateHetzner(args []string) {
+	project, revoke := parseRotateFlags(args, true)
+
+	cfg, configPath := loadConfigForRotation()
+	if project == "" {
+		project = cfg.GetHetznerProject()
+	}
+	if project == "" && os.Getenv("HETZNER_API_TOKEN") != "" {
+		fmt.Fprintln(os.Stderr, "❌ The Hetzner token comes from the HETZNER_API_TOKEN environment variable")
+		fmt.Fprintln(os.Stderr, "   Update it where it is set, or unset it to rotate the token in the config file.")
+		os.Exit(1)
+	}
+	oldToken, err := cfg.GetHetznerToken(project)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	label := "hetzner_api_token"
+	if project != "" {
+		label = "Hetzner project " + project
+	}
+
+	fmt.Printf("\n🔐 Rotating %s\n", label)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Println()
+	fmt.Println("1. Open https://console.hetzner.cloud and select the project")
+	fmt.Println("2. Go to Security → API tokens → Generate API token")
+	fmt.Printf("3. Name it e.g. morpheus-%s and give it Read & Write permission\n", time.Now().Format("2006-01-02"))
+	fmt.Println()
+	newToken := promptNewSecret("New API token: ", oldToken)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	// Validate before touching the config: the new token must work and see
+	// the same project as the old one
+	fmt.Printf("\n🔍 Checking new token...")
+	newProv, err := newHetznerProvider(cfg, newToken)
+	if err != nil {
+		fmt.Printf(" ❌\n")
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	newServers, err := newProv.ListServers(ctx, nil)
+	if err != nil {
+		fmt.Printf(" ❌\n")
+		fmt.Fprintf(os.Stderr, "❌ New token rejected: %s\n", err)
+		fmt.Fprintln(os.Stderr, "   Nothing was changed.")
+		os.Exit(1)
+	}
+	fmt.Printf(" ✓ sees %d server%s\n", len(newServers), ui.Plural(len(newServers)))
+
+	oldProv, err := newHetznerProvider(cfg, oldToken)
+	if err == nil {
+		if oldServers, err := oldProv.ListServers(ctx, nil); err == nil {
+			newIDs := map[string]bool{}
+			for _, s := range newServers {
+				newIDs[s.ID] = true
+			}
+			for _, s := range oldServers {
+				if !newIDs[s.ID] {
+					fmt.Fprintf(os.Stderr, "❌ New token cannot see server %s (%s): is it from another project?\n", s.Name, s.ID)
+					fmt.Fprintln(os.Stderr, "   Nothing was changed.")
+					os.Exit(1)
+				}
+			}
+			fmt.Println("   ✓ Same project as the current token")
+		} else {
+			fmt.Printf("   ⚠️  Current token no longer works (%s); cannot compare projects\n", err)
+		}
+	}
+
+	switchSecret(configPath, oldToken, newToken)
+
+	// Verify with the config as written, the way every other command reads it
+	fmt.Printf("🔍 Checking forests with the new config...")
+	cfg, _ = loadConfigForRotation()
+	token, err := cfg.GetHetznerToken(project)
+	if err == nil && token != newToken {
+		err = fmt.Errorf("config still resolves to the old token")
+	}
+	if err == nil {
+		newProv, err = newHetznerProvider(cfg, token)
+	}
+	if err != nil {
+		fmt.Printf(" ❌\n")
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	missing, checked := 0, 0
+	if reg, err := CreateStorage(); err == nil {
+		for _, f := range reg.ListForests() {
+			if f.Project != project || f.Provider != "hetzner" {
+				continue
+			}
+			nodes, _ := reg.GetNodes(f.ID)
+			for _, n := range nodes {
+				checked++
+				if _, err := newProv.GetServer(ctx, n.ID); err != nil {
+					if missing == 0 {
+						fmt.Println()
+					}
+					missing++
+					fmt.Printf("   ⚠️  %s/%s: %s\n", f.ID, n.ID, err)
+				}
+			}
+		}
+	}
+	if missing == 0 {
+		fmt.Printf(" ✓ %d node%s reachable\n", checked, ui.Plural(checked))
+	} else {
+		fmt.Printf("   %d of %d nodes not reachable; check them with 'morpheus drift'\n", missing, checked)
+	}
+
+	if !revoke {
+		fmt.Println()
+		fmt.Println("✅ Token rotated")
+		fmt.Println("💡 Delete the old token in the Hetzner Console (Security → API tokens)")
+		fmt.Println("   once nothing else uses it.")
+		return
+	}
+
+	// Hetzner has no API to revoke tokens; wait for the user, then make
+	// sure the old token really stopped working
+	fmt.Println()
+	fmt.Printf("🗑️  Delete the old token (%s) in the Hetzner Console\n", config.MaskToken(oldToken))
+	fmt.Println("   under Security → API tokens, then press Enter.")
+	stdin.ReadString('\n')
+	if oldProv != nil {
+		if _, err := oldProv.ListServers(ctx, nil); err == nil {
+			fmt.Fprintln(os.Stderr, "⚠️  The old token still works; it has not been revoked.")
+			os.Exit(1)
+		}
+	}
+	fmt.Println("✅ Token rotated and the old token revoked")
+}
+
+func handleRotateAzure(args []string) {
+	_, revoke := parseRotateFlags(args, false)
+
+	cfg, configPath := loadConfigForRotation()
+	az := cfg.Machine.Azure
+	switch {
+	case az.Profile != "":
+		fmt.Fprintln(os.Stderr, "❌ Azure uses the Azure CLI login (machine.azure.profile); there is no secret to rotate")
+		fmt.Fprintln(os.Stderr, "   💡 Run 'az login' to refresh it.")
+		os.Exit(1)
+	case az.ClientID == "" || az.ClientSecret == "":
+		fmt.Fprintln(os.Stderr, "❌ No Azure service principal configured (machine.azure.client_id and client_secret)")
+		os.Exit(1)
+	case os.Getenv("AZURE_CLIENT_SECRET") != "":
+		fmt.Fprintln(os.Stderr, "❌ The Azure client secret comes from the AZURE_CLIENT_SECRET environment variable")
+		fmt.Fprintln(os.Stderr, "   Update it where it is set, or unset it to rotate the secret in the config file.")
+		os.Exit(1)
+	}
+	oldSecret := az.ClientSecret
+
+	fmt.Printf("\n🔐 Rotating Azure client secret of %s\n", az.ClientID)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Println()
+	fmt.Println("Create a second secret for the service principal, keeping the current one:")
+	fmt.Println()
+	fmt.Printf("   az ad app credential reset --id %s --append \\\n", az.ClientID)
+	fmt.Printf("     --display-name morpheus-%s --years 1 --query password -o tsv\n", time.Now().Format("2006-01-02"))
+	fmt.Println()
+	fmt.Println("(or Entra ID → App registrations → Certificates & secrets → New client secret)")
+	fmt.Println()
+	newSecret := promptNewSecret("New client secret: ", oldSecret)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	fmt.Printf("\n🔍 Checking new secret...")
+	newGuards, err := azureGuardIDs(ctx, az, newSecret, true)
+	if err != nil {
+		fmt.Printf(" ❌\n")
+		fmt.Fprintf(os.Stderr, "❌ New secret rejected: %s\n", err)
+		fmt.Fprintln(os.Stderr, "   Nothing was changed.")
+		os.Exit(1)
+	}
+	fmt.Printf(" ✓ sees %d guard%s\n", len(newGuards), ui.Plural(len(newGuards)))
+	if oldGuards, err := azureGuardIDs(ctx, az, oldSecret, false); err == nil {
+		for _, id := range oldGuards {
+			if !slices.Contains(newGuards, id) {
+				fmt.Fprintf(os.Stderr, "❌ New secret cannot see guard %s: is it for another service principal?\n", id)
+				fmt.Fprintln(os.Stderr, "   Nothing was changed.")
+				os.Exit(1)
+			}
+		}
+		fmt.Println("   ✓ Same guards as the current secret")
+	} else {
+		fmt.Printf("   ⚠️  Current secret no longer works (%s); cannot compare guards\n", err)
+	}
+
+	switchSecret(configPath, oldSecret, newSecret)
+
+	fmt.Printf("🔍 Checking guards with the new config...")
+	cfg, _ = loadConfigForRotation()
+	guards, err := azureGuardIDs(ctx, cfg.Machine.Azure, cfg.Machine.Azure.ClientSecret, false)
+	if err != nil {
+		fmt.Printf(" ❌\n")
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf(" ✓ %d guard%s reachable\n", len(guards), ui.Plural(len(guards)))
+
+	if !revoke {
+		fmt.Println()
+		fmt.Println("✅ Client secret rotated")
+		fmt.Println("💡 Remove the old secret once nothing else uses it:")
+		fmt.Printf("   az ad app credential list --id %s\n", az.ClientID)
+		fmt.Printf("   az ad app credential delete --id %s --key-id <key-id>\n", az.ClientID)
+		return
+	}
+
+	fmt.Printf("🗑️  Revoking old secret...")
+	keyID, err := revokeAzureSecret(ctx, az.ClientID, oldSecret, newSecret)
+	if err != nil {
+		fmt.Printf(" ❌\n")
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		fmt.Fprintln(os.Stderr, "   The new secret is in use; remove the old one with:")
+		fmt.Fprintf(os.Stderr, "   az ad app credential delete --id %s --key-id <key-id>\n", az.ClientID)
+		os.Exit(1)
+	}
+	fmt.Printf(" ✓ %s\n", keyID)
+	fmt.Println()
+	fmt.Println("✅ Client secret rotated and the old secret revoked")
+	fmt.Println("💡 Tokens already issued for the old secret stay valid for up to an hour.")
+}
+
+// loadConfigForRotation loads the config and the path of the file holding it
+func loadConfigForRotation() (*config.Config, string) {
+	configPath := FindConfigPath()
+	if configPath == "" {
+		fmt.Fprintln(os.Stderr, "❌ No config file found")
+		fmt.Fprintln(os.Stderr, "   💡 Run 'morpheus config path' to see where morpheus looks.")
+		os.Exit(1)
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+	return cfg, configPath
+}
+
+// promptNewSecret reads the new credential, which must differ from the old
+func promptNewSecret(prompt, old string) string {
+	secret, err := readSecret(prompt)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nError reading input: %s\n", err)
+		os.Exit(1)
+	}
+	switch secret {
+	case "":
+		fmt.Fprintln(os.Stderr, "❌ No credential entered; nothing was changed")
+		os.Exit(1)
+	case old:
+		fmt.Fprintln(os.Stderr, "❌ That is the current credential; create a new one first")
+		os.Exit(1)
+	}
+	return secret
+}
+
+// readSecret prompts for a line of input without echoing it when stdin is
+// a terminal
+func readSecret(prompt string) (string, error) {
+	fmt.Print(prompt)
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		stty := func(arg string) {
+			cmd := exec.Command("stty", arg)
+			cmd.Stdin = os.Stdin
+			cmd.Run()
+		}
+		stty("-echo")
+		defer func() {
+			stty("echo")
+			fmt.Println()
+		}()
+	}
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// switchSecret replaces the old credential with the new one in the config
+// file and in customer entries that hold it directly
+func switchSecret(configPath, oldValue, newValue string) {
+	fmt.Printf("💾 Switching config...")
+	n, err := config.ReplaceSecret(configPath, oldValue, newValue)
+	if err == nil && n == 0 {
+		err = fmt.Errorf("the current credential is not in %s (is it an ${ENV} reference?)", configPath)
+	}
+	if err != nil {
+		fmt.Printf(" ❌\n")
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		fmt.Fprintln(os.Stderr, "   Nothing was changed; the new credential is valid but not in use.")
+		os.Exit(1)
+	}
+	fmt.Printf(" ✓ %d value%s in %s\n", n, ui.Plural(n), configPath)
+
+	customersPath := customer.GetDefaultConfigPath()
+	if _, err := os.Stat(customersPath); err == nil {
+		n, err := config.ReplaceSecret(customersPath, oldValue, newValue)
+		if err != nil {
+			fmt.Printf("   ⚠️  %s\n", err)
+		} else if n > 0 {
+			fmt.Printf("   ✓ %d value%s in %s\n", n, ui.Plural(n), customersPath)
+		}
+	}
+}
+
+// azureGuardIDs lists the guards visible with a client secret. New secrets
+// take a while to reach every Entra ID region, so wait retries sign-in
+// failures for up to a minute.
+func azureGuardIDs(ctx context.Context, az config.AzureConfig, secret string, wait bool) ([]string, error) {
+	prov, err := azure.NewProvider(az.SubscriptionID, az.TenantID, az.ClientID, secret,
+		az.ResourceGroup, az.Location, az.VMSize, az.Image)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(time.Minute)
+	for {
+		guards, err := prov.ListGuards(ctx)
+		if err == nil {
+			ids := make([]string, 0, len(guards))
+			for _, g := range guards {
+				ids = append(ids, g.ID)
+			}
+			sort.Strings(ids)
+			return ids, nil
+		}
+		if !wait || time.Now().After(deadline) {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
+
+// revokeAzureSecret deletes the old client secret with the Azure CLI. Entra
+// ID only reveals the first three characters of each secret (its hint), so
+// the old secret is the one whose hint matches it and not the new secret.
+func revokeAzureSecret(ctx context.Context, clientID, oldSecret, newSecret string) (string, error) {
+	if _, err := exec.LookPath("az"); err != nil {
+		return "", fmt.Errorf("the Azure CLI (az) is required to revoke the old secret")
+	}
+	out, err := exec.CommandContext(ctx, "az", "ad", "app", "credential", "list", "--id", clientID, "-o", "json").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to list client secrets: %w", err)
+	}
+	var creds []struct {
+		KeyID       string `json:"keyId"`
+		Hint        string `json:"hint"`
+		DisplayName string `json:"displayName"`
+	}
+	if err := json.Unmarshal(out, &creds); err != nil {
+		return "", fmt.Errorf("failed to parse client secrets: %w", err)
+	}
+
+	hint := func(s string) string { return s[:min(3, len(s))] }
+	if hint(oldSecret) == hint(newSecret) {
+		return "", fmt.Errorf("old and new secrets share the hint %q; cannot tell them apart", hint(oldSecret))
+	}
+	var matches []string
+	for _, c := range creds {
+		if c.Hint == hint(oldSecret) {
+			matches = append(matches, c.KeyID)
+		}
+	}
+	if len(matches) != 1 {
+		return "", fmt.Errorf("%d client secrets match the old secret's hint %q", len(matches), hint(oldSecret))
+	}
+	if out, err := exec.CommandContext(ctx, "az", "ad", "app", "credential", "delete", "--id", clientID, "--key-id", matches[0]).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to delete client secret %s: %s", matches[0], strings.TrimSpace(string(out)))
+	}
+	return matches[0], nil
+}
@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/secretstore"
+)
+
+// handleSecretsKeygen handles "morpheus secrets keygen": it creates the age
+// key the secret store is encrypted with
+func handleSecretsKeygen(args []string) {
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", args[0])
+		os.Exit(1)
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+	file := cfg.Secrets.Store.GetIdentity()
+	if _, err := os.Stat(file); err == nil {
+		fmt.Fprintf(os.Stderr, "❌ %s already exists\n", file)
+		fmt.Fprintln(os.Stderr, "   Secrets encrypted with it could not be read with a new key.")
+		os.Exit(1)
+	}
+
+	id, err := secretstore.GenerateIdentity()
+	if err == nil {
+		err = secretstore.WriteIdentityFile(file, id)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to create %s: %s\n", file, err)
+		os.Exit(1)
+	}
+	fmt.Printf("🔑 Created %s\n", file)
+	fmt.Printf("   Public key: %s\n", id.Recipient())
+	fmt.Println()
+	fmt.Println("💡 Back up this file: secrets in the store cannot be read without it.")
+	fmt.Println("   Other operators can add their public key to secrets.store.recipients.")
+}
+
+// openSecretStore opens the configured secret store or exits
+func openSecretStore() secretstore.Store {
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+	store, err := CreateSecretStore(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to open the secret store: %s\n", err)
+		os.Exit(1)
+	}
+	if store == nil {
+		fmt.Fprintln(os.Stderr, "❌ No secret store configured (set secrets.store.backend)")
+		os.Exit(1)
+	}
+	return store
+}
+
+// handleSecretsStore handles the list, get, put and rm subcommands
+func handleSecretsStore(op string, args []string) {
+	for _, arg := range args {
+		if arg == "--help" || arg == "-h" {
+			printSecretsHelp()
+			os.Exit(0)
+		}
+		if startsWithDash(arg) && arg != "-" {
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+			os.Exit(1)
+		}
+	}
+	want := map[string][2]int{"list": {0, 1}, "get": {1, 1}, "put": {1, 2}, "rm": {1, 1}}[op]
+	if len(args) < want[0] || len(args) > want[1] {
+		fmt.Fprintf(os.Stderr, "❌ Usage: %s\n", secretsUsage[op])
+		os.Exit(1)
+	}
+	store := openSecretStore()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	switch op {
+	case "list":
+		prefix := ""
+		if len(args) == 1 {
+			prefix = args[0]
+		}
+		keys, err := store.List(ctx, prefix)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			os.Exit(1)
+		}
+		for _, key := range keys {
+			fmt.Println(key)
+		}
+	case "get":
+		data, err := store.Get(ctx, args[0])
+		if errors.Is(err, secretstore.ErrNotFound) {
+			fmt.Fprintf(os.Stderr, "❌ No secret %s\n", args[0])
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(data)
+	case "put":
+		var data []byte
+		var err error
+		if len(args) == 1 || args[1] == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(args[1])
+		}
+		if err == nil {
+			err = store.Put(ctx, args[0], data)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "✅ Stored %s (%d byte%s)\n", args[0], len(data), ui.Plural(len(data)))
+	case "rm":
+		if err := store.Delete(ctx, args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "✅ Removed %s\n", args[0])
+	}
+}
+
+var secretsUsage = map[string]string{
+	"list": "morpheus secrets list [PREFIX]",
+	"get":  "morpheus secrets get KEY",
+	"put":  "morpheus secrets put KEY [FILE|-]",
+	"rm":   "morpheus secrets rm KEY",
+}
+
+// handleSecretsLog handles "morpheus secrets log": it shows the latest
+// accesses to the secret store
+func handleSecretsLog(args []string) {
+	limit := 50
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--limit", "-n":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "❌ %s requires a number\n", args[i])
+				os.Exit(1)
+			}
+			i++
+			n, err := strconv.Atoi(args[i])
+			if err != nil || n < 0 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid limit: %s\n", args[i])
+				os.Exit(1)
+			}
+			limit = n
+		case "--help", "-h":
+			printSecretsHelp()
+			os.Exit(0)
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", args[i])
+			os.Exit(1)
+		}
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+	file := cfg.Secrets.Store.GetAccessLog()
+	entries, err := secretstore.ReadAccessLog(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No secret accesses logged in %s\n", file)
+		return
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	for _, e := range entries {
+		fmt.Println(e)
+	}
+}
@@ -0,0 +1,342 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/lockfile"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// HandleSnapshot handles the snapshot command.
+func HandleSnapshot() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printSnapshotHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	switch os.Args[2] {
+	case "list":
+		handleSnapshotList(os.Args[3:])
+	case "delete":
+		if len(os.Args) != 5 {
+			fmt.Fprintln(os.Stderr, "Usage: morpheus snapshot delete <forest-id> <snapshot-id>")
+			os.Exit(1)
+		}
+		handleSnapshotDelete(os.Args[3], os.Args[4])
+	default:
+		if len(os.Args) != 3 || startsWithDash(os.Args[2]) {
+			fmt.Fprintln(os.Stderr, "Usage: morpheus snapshot <forest-id>")
+			os.Exit(1)
+		}
+		handleSnapshotCreate(os.Args[2])
+	}
+}
+
+func handleSnapshotCreate(forestID string) {
+	provisioner, _ := forestProvisioner(forestID)
+
+	lock, err := AcquireForestLock(forestID, "snapshot", lockfile.DefaultTTL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	defer lock.Release()
+
+	snap, err := provisioner.Snapshot(context.Background(), forestID)
+	if err != nil {
+		lock.Release()
+		fmt.Fprintf(os.Stderr, "\n❌ Snapshot failed: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println()
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("✅ Snapshot %s of %s taken (%d node%s, %.1f GB)\n",
+		snap.ID, forestID, len(snap.Nodes), ui.Plural(len(snap.Nodes)), snap.SizeGB())
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Println()
+	fmt.Println("💰 Snapshots are billed per GB until deleted")
+	fmt.Printf("💡 Restore with: morpheus restore %s --snapshot %s\n", forestID, snap.ID)
+}
+
+func handleSnapshotList(args []string) {
+	var forestID string
+	jsonOutput := false
+	for _, arg := range args {
+		switch {
+		case arg == "--json":
+			jsonOutput = true
+		case forestID == "" && !startsWithDash(arg):
+			forestID = arg
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown argument: %s\n", arg)
+			os.Exit(1)
+		}
+	}
+	if forestID == "" {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus snapshot list <forest-id> [--json]")
+		os.Exit(1)
+	}
+
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load storage: %s\n", err)
+		os.Exit(1)
+	}
+	f, err := reg.GetForest(forestID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get forest info: %s\n", err)
+		os.Exit(1)
+	}
+
+	if jsonOutput {
+		snapshots := f.Snapshots
+		if snapshots == nil {
+			snapshots = []storage.ForestSnapshot{}
+		}
+		data, _ := json.MarshalIndent(snapshots, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	if len(f.Snapshots) == 0 {
+		fmt.Printf("No snapshots of %s\n", forestID)
+		fmt.Printf("💡 Take one with: morpheus snapshot %s\n", forestID)
+		return
+	}
+	fmt.Printf("📸 Snapshots of %s:\n\n", forestID)
+	for i := len(f.Snapshots) - 1; i >= 0; i-- {
+		s := f.Snapshots[i]
+		latest := ""
+		if i == len(f.Snapshots)-1 {
+			latest = " (latest)"
+		}
+		fmt.Printf("  %s%s\n", s.ID, latest)
+		fmt.Printf("     Taken:    %s\n", s.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+		fmt.Printf("     Location: %s\n", s.Location)
+		fmt.Printf("     Nodes:    %d (%.1f GB)\n", len(s.Nodes), s.SizeGB())
+		for _, n := range s.Nodes {
+			fmt.Printf("        • %s → snapshot %s\n", n.Name, n.SnapshotID)
+		}
+	}
+}
+
+func handleSnapshotDelete(forestID, snapshotID string) {
+	provisioner, _ := forestProvisioner(forestID)
+
+	lock, err := AcquireForestLock(forestID, "snapshot delete", lockfile.DefaultTTL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	defer lock.Release()
+
+	if err := provisioner.DeleteSnapshot(context.Background(), forestID, snapshotID); err != nil {
+		lock.Release()
+		fmt.Fprintf(os.Stderr, "\n❌ %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("\n✅ Snapshot %s of %s deleted\n", snapshotID, forestID)
+}
+
+// HandleRestore handles the restore command.
+func HandleRestore() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printRestoreHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	forestID := os.Args[2]
+	var opts forest.RestoreOptions
+	yes := false
+	for i := 3; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--snapshot", "--into":
+			if i+1 >= len(os.Args) || startsWithDash(os.Args[i+1]) {
+				fmt.Fprintf(os.Stderr, "❌ %s requires a value\n", os.Args[i])
+				os.Exit(1)
+			}
+			if os.Args[i] == "--snapshot" {
+				opts.SnapshotID = os.Args[i+1]
+			} else {
+				opts.Into = os.Args[i+1]
+			}
+			i++
+		case "--yes", "-y":
+			yes = true
+		case "--override-budget":
+			opts.OverrideBudget = true
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+			fmt.Fprintln(os.Stderr, "Use 'morpheus restore --help' for usage")
+			os.Exit(1)
+		}
+	}
+	if opts.Into == forestID {
+		opts.Into = ""
+	}
+
+	provisioner, reg := forestProvisioner(forestID)
+	f, err := reg.GetForest(forestID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get forest info: %s\n", err)
+		os.Exit(1)
+	}
+	snap := f.FindSnapshot(opts.SnapshotID)
+	if snap == nil && opts.SnapshotID != "" {
+		fmt.Fprintf(os.Stderr, "❌ Forest %s has no snapshot %s\n", forestID, opts.SnapshotID)
+		fmt.Fprintf(os.Stderr, "💡 List them with: morpheus snapshot list %s\n", forestID)
+		os.Exit(1)
+	}
+	if snap == nil {
+		fmt.Fprintf(os.Stderr, "❌ Forest %s has no snapshots\n", forestID)
+		fmt.Fprintf(os.Stderr, "💡 Take one with: morpheus snapshot %s\n", forestID)
+		os.Exit(1)
+	}
+	opts.SnapshotID = snap.ID
+
+	fmt.Printf("\n📸 Restoring snapshot %s of %s (%d node%s, taken %s)\n",
+		snap.ID, forestID, len(snap.Nodes), ui.Plural(len(snap.Nodes)), snap.CreatedAt.Local().Format("2006-01-02 15:04"))
+	if opts.Into != "" {
+		fmt.Printf("   Into new forest: %s\n", opts.Into)
+	} else {
+		nodes, _ := reg.GetNodes(forestID)
+		fmt.Printf("\n⚠️  The forest's %d current machine%s will be deleted and replaced.\n", len(nodes), ui.Plural(len(nodes)))
+		fmt.Println("   Changes since the snapshot and the nodes' volumes will be lost.")
+		if !yes {
+			fmt.Print("\nType 'yes' to confirm: ")
+			var response string
+			fmt.Scanln(&response)
+			if response != "yes" {
+				fmt.Println("\n✅ Restore cancelled - your forest is unchanged")
+				return
+			}
+		}
+	}
+
+	lock, err := AcquireForestLock(forestID, "restore "+snap.ID, lockfile.DefaultTTL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	defer lock.Release()
+
+	// The new forest is locked too, so nothing else plants or restores it
+	// meanwhile
+	var intoLock *lockfile.Lock
+	if opts.Into != "" {
+		intoLock, err = AcquireForestLock(opts.Into, "restore "+snap.ID+" of "+forestID, lockfile.DefaultTTL)
+		if err != nil {
+			lock.Release()
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			os.Exit(1)
+		}
+		defer intoLock.Release()
+	}
+
+	if err := provisioner.Restore(context.Background(), forestID, opts); err != nil {
+		intoLock.Release()
+		lock.Release()
+		fmt.Fprintf(os.Stderr, "\n❌ Restore failed: %s\n", err)
+		printBudgetHint(err)
+		os.Exit(1)
+	}
+
+	restored := forestID
+	if opts.Into != "" {
+		restored = opts.Into
+	}
+	fmt.Println()
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("✅ Forest %s restored from snapshot %s\n", restored, snap.ID)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Println()
+	fmt.Printf("💡 View it with: morpheus status %s\n", restored)
+}
+
+// forestProvisioner creates the provisioner for an operation on an existing
+// forest, with the credentials of its project, exiting on errors
+func forestProvisioner(forestID string) (*forest.Provisioner, storage.Registry) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load storage: %s\n", err)
+		os.Exit(1)
+	}
+	if _, err := reg.GetForest(forestID); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get forest info: %s\n", err)
+		os.Exit(1)
+	}
+
+	UseForestProject(cfg, reg, forestID)
+	machineProv, _, err := CreateMachineProvider(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	var provisioner *forest.Provisioner
+	if dnsProv := CreateDNSProvider(cfg); dnsProv != nil {
+		provisioner = forest.NewProvisionerWithDNS(machineProv, reg, dnsProv, cfg)
+	} else {
+		provisioner = forest.NewProvisioner(machineProv, reg, cfg)
+	}
+	configureInventory(provisioner, cfg)
+	return provisioner, reg
+}
+
+func printSnapshotHelp() {
+	fmt.Println("Usage: morpheus snapshot <forest-id>")
+	fmt.Println("       morpheus snapshot list <forest-id> [--json]")
+	fmt.Println("       morpheus snapshot delete <forest-id> <snapshot-id>")
+	fmt.Println()
+	fmt.Println("Take a provider snapshot of the disk of every node of a forest and")
+	fmt.Println("record the set with the forest, to rebuild it with 'morpheus restore'.")
+	fmt.Println()
+	fmt.Println("The nodes keep running, so each snapshot is crash-consistent. Volumes")
+	fmt.Println("are not included. Snapshots are billed per GB until they are deleted;")
+	fmt.Println("tearing down the forest deletes them too.")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus snapshot forest-123")
+	fmt.Println("  morpheus snapshot list forest-123")
+	fmt.Println("  morpheus snapshot delete forest-123 20260101-120000")
+}
+
+func printRestoreHelp() {
+	fmt.Println("Usage: morpheus restore <forest-id> [options]")
+	fmt.Println()
+	fmt.Println("Rebuild a forest from one of its snapshots into new servers, created")
+	fmt.Println("like the forest's nodes were planted: same role, DNS records, load")
+	fmt.Println("balancer and floating IP, in the location the snapshot was taken in.")
+	fmt.Println()
+	fmt.Println("By default the forest's current machines are deleted and replaced, so")
+	fmt.Println("the new ones take over their names and DNS records. With --into, a")
+	fmt.Println("new forest is created instead and the forest is left as it is.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --snapshot ID     Snapshot to restore (default: the latest)")
+	fmt.Println("  --into NEW-ID     Restore into a new forest")
+	fmt.Println("  --yes, -y         Replace the machines without asking")
+	fmt.Println("  --override-budget Restore even if limits.max_monthly_cost would be exceeded")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus restore forest-123")
+	fmt.Println("  morpheus restore forest-123 --snapshot 20260101-120000")
+	fmt.Println("  morpheus restore forest-123 --into forest-123-copy")
+}
@@ -3,9 +3,17 @@ package commands
 import (
 	"fmt"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/cloudinit"
+	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/osrelease"
 	"github.com/nimsforest/morpheus/pkg/sshutil"
+	"github.com/nimsforest/morpheus/pkg/storage"
 )
 
 // HandleStatus handles the status command.
@@ -49,31 +57,88 @@ func HandleStatus() {
 	fmt.Printf("📊 Overview:\n")
 	fmt.Printf("   Status:   %s %s\n", statusIcon, forestInfo.Status)
 	fmt.Printf("   Nodes:    %d\n", forestInfo.NodeCount)
-	fmt.Printf("   Location: %s\n", forestInfo.Location)
+	if locations := forest.UniqueLocations(forestInfo.Locations); len(locations) > 1 {
+		fmt.Printf("   Location: %s (nodes spread round-robin)\n", JoinLocations(locations))
+	} else {
+		fmt.Printf("   Location: %s\n", forestInfo.Location)
+	}
 	fmt.Printf("   Provider: %s\n", forestInfo.Provider)
+	if forestInfo.Project != "" {
+		fmt.Printf("   Project:  %s\n", forestInfo.Project)
+	}
 	fmt.Printf("   Created:  %s\n", forestInfo.CreatedAt.Format("2006-01-02 15:04:05"))
+	if forestInfo.LoadBalancerID != "" {
+		fmt.Printf("   Balancer: %s %s\n", forestInfo.LoadBalancerIPv4, forestInfo.LoadBalancerIPv6)
+	}
+	if forestInfo.FloatingIP != "" {
+		fmt.Printf("   Floating: %s\n", forestInfo.FloatingIP)
+	}
+	if forestInfo.PlacementGroupID != "" {
+		fmt.Printf("   Spread:   across hosts (placement group %s)\n", forestInfo.PlacementGroupID)
+	}
+	if images := slices.Compact(slices.Sorted(slices.Values(forest.NodeImages(forestInfo, nodes)))); len(images) == 1 && images[0] != "" {
+		fmt.Printf("   Image:    %s\n", images[0])
+	}
 
 	if len(nodes) > 0 {
 		fmt.Printf("\n🖥️  Machines (%d):\n", len(nodes))
 		fmt.Println()
-		fmt.Println("   ID                IP ADDRESS               LOCATION  STATUS")
-		fmt.Println("   ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		// Nodes that phone home also show whether cloud-init has finished
+		showReadiness := false
 		for _, node := range nodes {
+			showReadiness = showReadiness || node.Readiness != ""
+		}
+
+		// Forests with roles besides the default one show each node's role
+		roles := forest.NodeRoles(forestInfo, nodes)
+		showRoles := slices.ContainsFunc(roles, func(role string) bool { return role != cloudinit.DefaultRole })
+
+		header := "ID                IP ADDRESS               LOCATION  "
+		if showRoles {
+			header += "ROLE       "
+		}
+		header += "STATUS"
+		if showReadiness {
+			header += "           CLOUD-INIT"
+		}
+		fmt.Println("   " + header)
+		fmt.Println("   " + strings.Repeat("━", len(header)))
+		for i, node := range nodes {
 			nodeStatusIcon := "✅"
 			if node.Status != "active" {
 				nodeStatusIcon = "⏳"
 			}
+			status := node.Status
+			if showReadiness {
+				status = fmt.Sprintf("%-13s %s", node.Status, readinessLabel(node))
+			}
+			location := node.Location
+			if showRoles {
+				location = fmt.Sprintf("%-9s %-10s", node.Location, roles[i])
+			}
 			fmt.Printf("   %-17s %-24s %-9s %s %s\n",
 				node.ID,
 				ui.TruncateIP(node.IP, 24),
-				node.Location,
+				location,
 				nodeStatusIcon,
-				node.Status,
+				status,
 			)
 		}
 
 		fmt.Println()
 
+		if warnings := imageWarnings(forestInfo, nodes); len(warnings) > 0 {
+			fmt.Printf("⚠️  Images:\n")
+			for _, w := range warnings {
+				fmt.Printf("   %s (node%s %s)\n", w.Advice, ui.Plural(len(w.Nodes)), strings.Join(w.Nodes, ", "))
+			}
+			if w := warnings[0]; w.Advice.Newer != "" {
+				fmt.Printf("💡 Rebuild the nodes onto a newer image one at a time:\n")
+				fmt.Printf("   morpheus replace-node %s %s --image %s\n", forestInfo.ID, w.Nodes[0], w.Advice.Newer)
+			}
+			fmt.Println()
+		}
+
 		// Detect SSH private key for better guidance
 		sshKeyPath := sshutil.DetectSSHPrivateKeyPath()
 
@@ -102,3 +167,49 @@ func HandleStatus() {
 	fmt.Printf("🌱 Add nodes: morpheus grow %s --nodes 2\n", forestInfo.ID)
 	fmt.Printf("🗑️  Teardown: morpheus teardown %s\n", forestInfo.ID)
 }
+
+// imageWarning is advice on an image that some nodes of a forest run
+type imageWarning struct {
+	Advice osrelease.Advice
+	Nodes  []string // Node numbers
+}
+
+// imageWarnings returns the images of a forest's nodes that are (nearly)
+// out of support or have a newer long-term release
+func imageWarnings(f *storage.Forest, nodes []*storage.Node) []imageWarning {
+	var warnings []imageWarning
+	now := time.Now()
+	for i, image := range forest.NodeImages(f, nodes) {
+		if image == "" {
+			continue
+		}
+		idx := slices.IndexFunc(warnings, func(w imageWarning) bool { return w.Advice.Image == image })
+		if idx < 0 {
+			advice := osrelease.Check(image, now)
+			if !advice.Warn() {
+				continue
+			}
+			warnings = append(warnings, imageWarning{Advice: advice})
+			idx = len(warnings) - 1
+		}
+		warnings[idx].Nodes = append(warnings[idx].Nodes, strconv.Itoa(i+1))
+	}
+	return warnings
+}
+
+// readinessLabel describes whether cloud-init has finished on a node
+func readinessLabel(node *storage.Node) string {
+	switch node.Readiness {
+	case "":
+		return "-"
+	case "ready":
+		if !node.ReadyAt.IsZero() {
+			return "✅ ready " + node.ReadyAt.Local().Format("2006-01-02 15:04")
+		}
+		return "✅ ready"
+	case "waiting":
+		return "⏳ waiting"
+	default:
+		return "⚠️  " + node.Readiness
+	}
+}
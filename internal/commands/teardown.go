@@ -10,12 +10,25 @@ import (
 
 // HandleTeardown handles the teardown command.
 func HandleTeardown() {
-	if len(os.Args) < 3 {
-		fmt.Fprintln(os.Stderr, "Usage: morpheus teardown <forest-id>")
-		os.Exit(1)
+	var forestID string
+	keepVolumes := false
+	for i := 2; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--keep-volumes":
+			keepVolumes = true
+		default:
+			if forestID != "" || startsWithDash(os.Args[i]) {
+				fmt.Fprintf(os.Stderr, "Unknown argument: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			forestID = os.Args[i]
+		}
 	}
 
-	forestID := os.Args[2]
+	if forestID == "" {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus teardown <forest-id> [--keep-volumes]")
+		os.Exit(1)
+	}
 
 	// First, get the forest info to determine the provider
 	storageProv, err := CreateStorage()
@@ -25,7 +38,7 @@ func HandleTeardown() {
 	}
 
 	// Verify forest exists
-	_, err = storageProv.GetForest(forestID)
+	forestInfo, err := storageProv.GetForest(forestID)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to get forest info: %s\n", err)
 		os.Exit(1)
@@ -37,7 +50,8 @@ func HandleTeardown() {
 		os.Exit(1)
 	}
 
-	// Create provider
+	// Create provider with the credentials of the forest's project
+	UseForestProject(cfg, storageProv, forestID)
 	machineProv, _, err := CreateMachineProvider(cfg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
@@ -54,6 +68,8 @@ func HandleTeardown() {
 	} else {
 		provisioner = forest.NewProvisioner(machineProv, storageProv, cfg)
 	}
+	configureInventory(provisioner, cfg)
+	configureSecrets(provisioner, cfg)
 
 	// Show what will be deleted
 	nodes, _ := storageProv.GetNodes(forestID)
@@ -63,10 +79,21 @@ func HandleTeardown() {
 	fmt.Printf("   Nodes:  %d\n", len(nodes))
 	if len(nodes) > 0 {
 		fmt.Printf("   Machines:\n")
+		spread := len(forest.UniqueLocations(forestInfo.Locations)) > 1
 		for _, node := range nodes {
-			fmt.Printf("      • %s (%s)\n", node.ID, node.IP)
+			if spread {
+				fmt.Printf("      • %s (%s, %s)\n", node.ID, node.IP, node.Location)
+			} else {
+				fmt.Printf("      • %s (%s)\n", node.ID, node.IP)
+			}
 		}
 	}
+	if len(forestInfo.Snapshots) > 0 {
+		fmt.Printf("   Snapshots: %d\n", len(forestInfo.Snapshots))
+	}
+	if keepVolumes {
+		fmt.Printf("   Volumes will be kept (--keep-volumes)\n")
+	}
 	fmt.Println()
 	fmt.Printf("💰 This will stop billing for these resources\n")
 	fmt.Println()
@@ -83,7 +110,7 @@ func HandleTeardown() {
 	// Teardown
 	fmt.Println()
 	ctx := context.Background()
-	if err := provisioner.Teardown(ctx, forestID); err != nil {
+	if err := provisioner.TeardownWithOptions(ctx, forestID, forest.TeardownOptions{KeepVolumes: keepVolumes}); err != nil {
 		fmt.Fprintf(os.Stderr, "\n❌ Teardown failed: %s\n", err)
 		os.Exit(1)
 	}
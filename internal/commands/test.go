@@ -59,14 +59,15 @@ func handleTestE2E() {
 		os.Exit(1)
 	}
 
-	if cfg.Secrets.HetznerAPIToken == "" {
-		fmt.Fprintln(os.Stderr, "❌ Hetzner API token not configured")
+	token, err := cfg.GetHetznerToken("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Hetzner API token not configured: %s\n", err)
 		fmt.Fprintln(os.Stderr, "   Set HETZNER_API_TOKEN environment variable or add to config.yaml")
 		os.Exit(1)
 	}
 
 	// Create Hetzner provider for direct API operations
-	hetznerProv, err := hetzner.NewProvider(cfg.Secrets.HetznerAPIToken)
+	hetznerProv, err := hetzner.NewProvider(token)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "❌ Failed to create Hetzner provider: %s\n", err)
 		os.Exit(1)
@@ -77,15 +78,17 @@ func handleTestE2E() {
 	testsPassed := 0
 	testsFailed := 0
 
-	// Helper to run SSH commands on a node
-	runSSHToNode := func(nodeIP, command string) (string, error) {
-		sshArgs := []string{
-			"-o", "StrictHostKeyChecking=no",
-			"-o", "UserKnownHostsFile=/dev/null",
-			"-o", "ConnectTimeout=15",
-			fmt.Sprintf("root@%s", nodeIP),
-			command,
+	// Helper to run SSH commands on a node, verifying its pinned host key
+	runSSHToNode := func(nodeIP, hostKey, command string) (string, error) {
+		knownHosts, err := sshutil.TempKnownHosts([]sshutil.Target{{Addr: nodeIP, HostKey: hostKey}})
+		if err != nil {
+			return "", err
+		}
+		if knownHosts != "" {
+			defer os.Remove(knownHosts)
 		}
+		sshArgs := append(sshutil.HostKeyArgs(hostKey, knownHosts), "-o", "ConnectTimeout=15")
+		sshArgs = append(sshArgs, fmt.Sprintf("root@%s", nodeIP), command)
 		cmd := exec.Command("ssh", sshArgs...)
 		output, err := cmd.CombinedOutput()
 		return string(output), err
@@ -283,7 +286,7 @@ func handleTestE2E() {
 	// Wait a bit for cloud-init
 	time.Sleep(30 * time.Second)
 
-	output, err := runSSHToNode(nodeIP, "cloud-init status --wait 2>/dev/null || echo 'done'")
+	output, err := runSSHToNode(nodeIP, node.HostKey, "cloud-init status --wait 2>/dev/null || echo 'done'")
 	if err == nil && (strings.Contains(output, "done") || strings.Contains(output, "status: done")) {
 		fmt.Println("   ✅ Cloud-init completed")
 		testsPassed++
@@ -9,7 +9,6 @@ import (
 
 	"github.com/nimsforest/morpheus/pkg/customer"
 	"github.com/nimsforest/morpheus/pkg/dns"
-	dnshetzner "github.com/nimsforest/morpheus/pkg/dns/hetzner"
 	"github.com/nimsforest/morpheus/pkg/venture"
 )
 
@@ -22,15 +21,23 @@ func HandleVenture() {
 
 	subcommand := os.Args[2]
 
+	if err := venture.LoadUserTemplates(venture.DefaultTemplateDir()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: skipping invalid venture templates:\n%v\n\n", err)
+	}
+
 	switch subcommand {
 	case "list":
 		handleVentureList()
 	case "enable":
 		handleVentureEnable()
+	case "enable-all":
+		handleVentureEnableAll()
 	case "disable":
 		handleVentureDisable()
 	case "status":
 		handleVentureStatus()
+	case "reconcile":
+		handleVentureReconcile()
 	case "help", "--help", "-h":
 		printVentureHelp()
 	default:
@@ -50,15 +57,28 @@ func printVentureHelp() {
 	fmt.Println("  list                              List available venture templates")
 	fmt.Println("  enable <customer-id> <venture>    Enable a venture for a customer")
 	fmt.Println("    --server-ip IP                  Server IP address for DNS records")
+	fmt.Println("    --var NAME=VALUE                Set a template variable (repeatable)")
+	fmt.Println("    --wait [--wait-timeout D]       Wait until the records resolve publicly")
+	fmt.Println("  enable-all <venture>              Enable a venture for several customers")
+	fmt.Println("    --customers a,b | --all         Which customers")
+	fmt.Println("    --concurrency, -c N             Customers at a time (default: 4)")
+	fmt.Println("    --server-ip, --var              As for enable")
 	fmt.Println("  disable <customer-id> <venture>   Disable a venture for a customer")
 	fmt.Println("    --delete-zone                   Also delete the DNS zone")
 	fmt.Println("  status <customer-id> <venture>    Show venture DNS status")
+	fmt.Println("  reconcile <customer-id> <venture> Compare the zone with the template")
+	fmt.Println("    --server-ip, --var              As for enable")
+	fmt.Println("    --apply                         Create and update the differing records")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  morpheus venture list")
 	fmt.Println("  morpheus venture enable acme experiencenet --server-ip 1.2.3.4")
+	fmt.Println("  morpheus venture enable-all experiencenet --customers acme,globex --server-ip 1.2.3.4")
 	fmt.Println("  morpheus venture disable acme experiencenet")
 	fmt.Println("  morpheus venture status acme experiencenet")
+	fmt.Println("  morpheus venture reconcile acme experiencenet --server-ip 1.2.3.4 --apply")
+	fmt.Println()
+	fmt.Printf("Templates in %s/*.yaml are added to the built-in ones.\n", venture.DefaultTemplateDir())
 }
 
 // handleVentureList lists all available venture templates
@@ -72,6 +92,19 @@ func handleVentureList() {
 	for _, template := range templates {
 		fmt.Printf("Venture: %s\n", template.Name)
 		fmt.Printf("  Description: %s\n", template.Description)
+		if template.Source != "" {
+			fmt.Printf("  Source: %s\n", template.Source)
+		}
+		if len(template.Variables) > 0 {
+			fmt.Printf("  Variables:\n")
+			for _, v := range template.Variables {
+				required := "required"
+				if v.Default != "" {
+					required = "default " + v.Default
+				}
+				fmt.Println(strings.TrimRight(fmt.Sprintf("    - %s (%s) %s", v.Name, required, v.Description), " "))
+			}
+		}
 		fmt.Printf("  DNS Records:\n")
 		for _, record := range template.Records {
 			fmt.Printf("    - %s (%s) -> %s (TTL: %d)\n",
@@ -82,6 +115,8 @@ func handleVentureList() {
 
 	fmt.Println("To enable a venture for a customer:")
 	fmt.Println("  morpheus venture enable <customer-id> <venture-name> --server-ip <IP>")
+	fmt.Println()
+	fmt.Printf("Define your own in %s/<name>.yaml\n", venture.DefaultTemplateDir())
 }
 
 // handleVentureEnable enables a venture for a customer
@@ -97,6 +132,9 @@ func handleVentureEnable() {
 
 	// Parse optional flags
 	var serverIP string
+	vars := make(map[string]string)
+	wait := false
+	waitTimeout := 10 * time.Minute
 	for i := 5; i < len(os.Args); i++ {
 		switch os.Args[i] {
 		case "--server-ip", "-ip":
@@ -107,11 +145,35 @@ func handleVentureEnable() {
 				fmt.Fprintln(os.Stderr, "Error: --server-ip requires a value")
 				os.Exit(1)
 			}
+		case "--var":
+			if i+1 >= len(os.Args) || !strings.Contains(os.Args[i+1], "=") {
+				fmt.Fprintln(os.Stderr, "Error: --var requires NAME=VALUE")
+				os.Exit(1)
+			}
+			name, value, _ := strings.Cut(os.Args[i+1], "=")
+			vars[name] = value
+			i++
+		case "--wait":
+			wait = true
+		case "--wait-timeout":
+			if i+1 < len(os.Args) {
+				d, err := time.ParseDuration(os.Args[i+1])
+				if err != nil || d <= 0 {
+					fmt.Fprintf(os.Stderr, "Error: invalid --wait-timeout: %s\n", os.Args[i+1])
+					os.Exit(1)
+				}
+				waitTimeout = d
+				wait = true
+				i++
+			} else {
+				fmt.Fprintln(os.Stderr, "Error: --wait-timeout requires a duration")
+				os.Exit(1)
+			}
 		}
 	}
 
 	// Validate venture name
-	_, err := venture.GetTemplate(ventureName)
+	template, err := venture.GetTemplate(ventureName)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		fmt.Fprintln(os.Stderr, "\nAvailable ventures:")
@@ -121,6 +183,20 @@ func handleVentureEnable() {
 		os.Exit(1)
 	}
 
+	// Prepare variables for template expansion
+	if serverIP != "" {
+		vars["ServerIP"] = serverIP
+	}
+	if _, err := template.ResolveVariables(vars); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		for _, v := range template.Variables {
+			if v.Default == "" && vars[v.Name] == "" {
+				fmt.Fprintln(os.Stderr, strings.TrimRight(fmt.Sprintf("  --var %s=...  %s", v.Name, v.Description), " "))
+			}
+		}
+		os.Exit(1)
+	}
+
 	// Load customer configuration
 	cust, err := loadCustomer(customerID)
 	if err != nil {
@@ -141,31 +217,10 @@ func handleVentureEnable() {
 	// Build venture domain
 	ventureDomain := venture.GetVentureDomain(cust.Domain, ventureName)
 
-	// Prepare variables for template expansion
-	vars := make(map[string]string)
-	if serverIP != "" {
-		vars["ServerIP"] = serverIP
-	}
-
 	fmt.Printf("Enabling venture %s for customer %s\n", ventureName, customerID)
 	fmt.Printf("Venture domain: %s\n", ventureDomain)
 	fmt.Println()
 
-	// Check if server IP is required but not provided
-	template, _ := venture.GetTemplate(ventureName)
-	needsServerIP := false
-	for _, record := range template.Records {
-		if strings.Contains(record.Value, "{{.ServerIP}}") {
-			needsServerIP = true
-			break
-		}
-	}
-	if needsServerIP && serverIP == "" {
-		fmt.Fprintln(os.Stderr, "Error: --server-ip is required for this venture template")
-		fmt.Fprintln(os.Stderr, "The template contains A records that need a server IP address")
-		os.Exit(1)
-	}
-
 	// Provision DNS records
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
@@ -214,10 +269,65 @@ func handleVentureEnable() {
 		fmt.Println("DNS propagation may take up to 48 hours.")
 	}
 
+	if wait {
+		if !waitForVentureRecords(ventureDomain, result.Records, waitTimeout) {
+			os.Exit(1)
+		}
+	}
+
+	recordVenture(cust, ventureName, true)
+
 	fmt.Println()
 	fmt.Printf("Venture %s enabled successfully for customer %s\n", ventureName, customerID)
 }
 
+// recordVenture updates the ventures listed for a customer in
+// customers.yaml, which customer list and remove rely on
+func recordVenture(cust *customer.Customer, ventureName string, enabled bool) {
+	var changed bool
+	if enabled {
+		changed = cust.AddVenture(ventureName)
+	} else {
+		changed = cust.RemoveVenture(ventureName)
+	}
+	if !changed {
+		return
+	}
+	if err := customer.SaveCustomer(customer.GetDefaultConfigPath(), *cust); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not record the venture in the customer config: %v\n", err)
+	}
+}
+
+// waitForVentureRecords waits until each created record resolves publicly
+// and returns false if any did not within the timeout
+func waitForVentureRecords(ventureDomain string, records []*dns.Record, timeout time.Duration) bool {
+	fmt.Println()
+	fmt.Printf("Waiting for %d record(s) to propagate (timeout %s)...\n", len(records), timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	allOk := true
+	for _, record := range records {
+		fqdn := formatFQDN(record.Name, ventureDomain)
+		err := dns.WaitForRecord(ctx, fqdn, record.Type, record.Value, dns.WaitOptions{
+			Progress: func(p dns.WaitProgress) {
+				if len(p.Pending) > 0 {
+					fmt.Printf("  %s (%s): %d resolver(s) ready, waiting... (%s)\n",
+						fqdn, record.Type, len(p.Matched), p.Elapsed.Round(time.Second))
+				}
+			},
+		})
+		if err != nil {
+			fmt.Printf("  %s (%s): %v\n", fqdn, record.Type, err)
+			allOk = false
+			continue
+		}
+		fmt.Printf("  %s (%s): resolves\n", fqdn, record.Type)
+	}
+	return allOk
+}
+
 // handleVentureDisable disables a venture for a customer
 func handleVentureDisable() {
 	if len(os.Args) < 5 {
@@ -283,6 +393,8 @@ func handleVentureDisable() {
 		os.Exit(1)
 	}
 
+	recordVenture(cust, ventureName, false)
+
 	fmt.Println()
 	if deleteZone {
 		fmt.Printf("Venture %s disabled and zone deleted for customer %s\n", ventureName, customerID)
@@ -403,6 +515,108 @@ func handleVentureStatus() {
 		fmt.Printf("  %s (%s) -> %s (TTL: %d)\n",
 			recordName, record.Type, record.Value, record.TTL)
 	}
+
+	fmt.Println()
+	fmt.Println("To compare the two and fix differences:")
+	fmt.Printf("  morpheus venture reconcile %s %s --server-ip <IP>\n", customerID, ventureName)
+}
+
+// handleVentureReconcile compares a venture's zone with its template and,
+// with --apply, fixes the records that differ
+func handleVentureReconcile() {
+	if len(os.Args) < 5 {
+		fmt.Fprintln(os.Stderr, "Error: missing required arguments")
+		fmt.Fprintln(os.Stderr, "Usage: morpheus venture reconcile <customer-id> <venture-name> [--server-ip IP] [--var NAME=VALUE] [--apply]")
+		os.Exit(1)
+	}
+
+	customerID := os.Args[3]
+	ventureName := os.Args[4]
+
+	vars := make(map[string]string)
+	apply := false
+	for i := 5; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--server-ip", "-ip", "--var":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintf(os.Stderr, "Error: %s requires a value\n", os.Args[i])
+				os.Exit(1)
+			}
+			i++
+			if os.Args[i-1] != "--var" {
+				vars["ServerIP"] = os.Args[i]
+				continue
+			}
+			name, value, ok := strings.Cut(os.Args[i], "=")
+			if !ok {
+				fmt.Fprintln(os.Stderr, "Error: --var requires NAME=VALUE")
+				os.Exit(1)
+			}
+			vars[name] = value
+		case "--apply":
+			apply = true
+		default:
+			fmt.Fprintf(os.Stderr, "Error: unknown option: %s\n", os.Args[i])
+			os.Exit(1)
+		}
+	}
+
+	cust, err := loadCustomer(customerID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading customer: %v\n", err)
+		os.Exit(1)
+	}
+	dnsProvider, err := createDNSProviderForCustomer(cust)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating DNS provider: %v\n", err)
+		os.Exit(1)
+	}
+	provisioner := venture.NewProvisioner(dnsProvider)
+	ventureDomain := venture.GetVentureDomain(cust.Domain, ventureName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	drift, err := provisioner.CheckDrift(ctx, ventureName, ventureDomain, vars)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Reconciling venture %s for customer %s\n", ventureName, customerID)
+	fmt.Printf("Venture domain: %s\n", ventureDomain)
+	fmt.Println()
+
+	if len(drift.Extra) > 0 {
+		fmt.Printf("Records not in the template (left alone):\n")
+		for _, set := range drift.Extra {
+			fmt.Printf("  %s (%s) -> %s\n", formatFQDN(set.Name, ventureDomain), set.Type, strings.Join(set.Values, ", "))
+		}
+		fmt.Println()
+	}
+
+	if len(drift.Changes) == 0 {
+		fmt.Println("✅ The zone matches the template")
+		return
+	}
+
+	fmt.Printf("%d record set(s) differ from the template:\n", len(drift.Changes))
+	for _, c := range drift.Changes {
+		fmt.Printf("  %s\n", c)
+	}
+	fmt.Println()
+
+	if !apply {
+		fmt.Println("Apply these changes with --apply")
+		os.Exit(1)
+	}
+
+	changes, err := provisioner.Reconcile(ctx, ventureName, ventureDomain, vars)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Applied %d of %d changes: %v\n", len(changes), len(drift.Changes), err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Applied %d change(s)\n", len(changes))
 }
 
 // loadCustomer loads a customer by ID from the default config path
@@ -438,5 +652,9 @@ func createDNSProviderForCustomer(cust *customer.Customer) (dns.Provider, error)
 		return nil, fmt.Errorf("no API token configured for customer %s", cust.ID)
 	}
 
-	return dnshetzner.NewProvider(token)
+	provider, err := newHetznerDNSProvider(nil, token)
+	if err != nil {
+		return nil, err
+	}
+	return recordDNSChanges(provider), nil
 }
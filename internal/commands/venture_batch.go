@@ -0,0 +1,180 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/customer"
+	"github.com/nimsforest/morpheus/pkg/dns"
+	"github.com/nimsforest/morpheus/pkg/venture"
+)
+
+// handleVentureEnableAll enables a venture for several customers at once
+func handleVentureEnableAll() {
+	var ventureName string
+	var customerIDs []string
+	all := false
+	concurrency := 4
+	vars := make(map[string]string)
+
+	for i := 3; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		switch arg {
+		case "--customers", "--server-ip", "--var", "--concurrency", "-c":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintf(os.Stderr, "❌ %s requires a value\n", arg)
+				os.Exit(1)
+			}
+			i++
+			value := os.Args[i]
+			switch arg {
+			case "--customers":
+				for _, id := range strings.Split(value, ",") {
+					if id = strings.TrimSpace(id); id != "" {
+						customerIDs = append(customerIDs, id)
+					}
+				}
+			case "--server-ip":
+				vars["ServerIP"] = value
+			case "--var":
+				name, v, ok := strings.Cut(value, "=")
+				if !ok {
+					fmt.Fprintln(os.Stderr, "❌ --var requires NAME=VALUE")
+					os.Exit(1)
+				}
+				vars[name] = v
+			default:
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 1 {
+					fmt.Fprintf(os.Stderr, "❌ Invalid concurrency: %s\n", value)
+					os.Exit(1)
+				}
+				concurrency = n
+			}
+		case "--all":
+			all = true
+		case "--help", "-h":
+			printVentureHelp()
+			os.Exit(0)
+		default:
+			if ventureName != "" || startsWithDash(arg) {
+				fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+				os.Exit(1)
+			}
+			ventureName = arg
+		}
+	}
+
+	if ventureName == "" || all == (len(customerIDs) > 0) {
+		fmt.Fprintln(os.Stderr, "Usage: morpheus venture enable-all <venture> (--customers a,b | --all) [--server-ip IP] [--var NAME=VALUE]")
+		os.Exit(1)
+	}
+
+	template, err := venture.GetTemplate(ventureName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	if _, err := template.ResolveVariables(vars); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+
+	configPath := customer.GetDefaultConfigPath()
+	custConfig, err := customer.LoadCustomerConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	if all {
+		customerIDs = customer.ListCustomers(custConfig)
+	}
+	customers := make(map[string]*customer.Customer, len(customerIDs))
+	var targets []venture.Target
+	for _, id := range customerIDs {
+		cust, err := customer.GetCustomer(custConfig, id)
+		if err == nil {
+			err = customer.ValidateCustomer(cust)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			os.Exit(1)
+		}
+		customers[id] = cust
+		targets = append(targets, venture.Target{CustomerID: id, Domain: venture.GetVentureDomain(cust.Domain, ventureName)})
+	}
+	if len(targets) == 0 {
+		fmt.Println("No customers configured.")
+		return
+	}
+
+	fmt.Printf("🚀 Enabling %s for %d customer%s (%d at a time)\n\n", ventureName, len(targets), ui.Plural(len(targets)), min(concurrency, len(targets)))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	results := venture.ProvisionAll(ctx, ventureName, targets, vars, concurrency, func(t venture.Target) (dns.Provider, error) {
+		return createDNSProviderForCustomer(customers[t.CustomerID])
+	})
+
+	// Recorded one at a time, as every save rewrites customers.yaml
+	failed := 0
+	var delegate []venture.BatchResult
+	for _, r := range results {
+		if r.Err != nil || len(r.Result.Failed) > 0 {
+			failed++
+		}
+		if r.Err == nil {
+			recordVenture(customers[r.Target.CustomerID], ventureName, true)
+			if r.Result.ZoneCreated {
+				delegate = append(delegate, r)
+			}
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("   %-14s %-36s %-8s %-8s %s\n", "CUSTOMER", "DOMAIN", "RECORDS", "ZONE", "RESULT")
+	for _, r := range results {
+		records, zone, result := "-", "-", "❌ "
+		switch {
+		case r.Err != nil:
+			result += r.Err.Error()
+		default:
+			records = fmt.Sprintf("%d/%d", len(r.Result.Records), len(template.Records))
+			zone = "existing"
+			if r.Result.ZoneCreated {
+				zone = "created"
+			}
+			if len(r.Result.Failed) > 0 {
+				result = "⚠️  " + r.Result.Failed[0].Error()
+			} else {
+				result = fmt.Sprintf("✅ %s", r.Duration.Round(100*time.Millisecond))
+			}
+		}
+		fmt.Printf("   %-14s %-36s %-8s %-8s %s\n", r.Target.CustomerID, r.Target.Domain, records, zone, result)
+	}
+	fmt.Println()
+
+	if len(delegate) > 0 {
+		fmt.Println("New zones need NS records in the customer's parent domain:")
+		for _, r := range delegate {
+			fmt.Printf("   %s  NS  %s\n", r.Target.Domain, strings.Join(r.Result.Nameservers, ", "))
+		}
+		fmt.Println()
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "❌ %s failed for %d of %d customer%s\n", ventureName, failed, len(results), ui.Plural(len(results)))
+		os.Exit(1)
+	}
+	fmt.Printf("✅ %s enabled for %d customer%s\n", ventureName, len(results), ui.Plural(len(results)))
+}
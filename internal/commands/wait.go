@@ -0,0 +1,215 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/health"
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+// HandleWait handles the wait command.
+func HandleWait() {
+	if len(os.Args) < 3 || os.Args[2] == "--help" || os.Args[2] == "-h" {
+		printWaitHelp()
+		if len(os.Args) < 3 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	forestID := os.Args[2]
+	jsonOutput := false
+	timeout := 20 * time.Minute
+	opts := health.WaitOptions{For: health.ForReady}
+
+	for i := 3; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		if startsWithDash(arg) && arg != "--json" && i+1 >= len(os.Args) {
+			fmt.Fprintf(os.Stderr, "❌ %s requires a value\n", arg)
+			os.Exit(1)
+		}
+		switch arg {
+		case "--json":
+			jsonOutput = true
+		case "--for":
+			i++
+			if os.Args[i] != health.ForReady && os.Args[i] != health.ForRunning {
+				fmt.Fprintf(os.Stderr, "❌ Invalid --for: %s (use ready or running)\n", os.Args[i])
+				os.Exit(1)
+			}
+			opts.For = os.Args[i]
+		case "--timeout", "--interval":
+			i++
+			d, err := time.ParseDuration(os.Args[i])
+			if err != nil || d <= 0 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid %s: %s\n", arg[2:], os.Args[i])
+				os.Exit(1)
+			}
+			if arg == "--timeout" {
+				timeout = d
+			} else {
+				opts.Interval = d
+			}
+		case "--user":
+			i++
+			opts.User = os.Args[i]
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+			fmt.Fprintln(os.Stderr, "Use 'morpheus wait --help' for usage")
+			os.Exit(1)
+		}
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %s\n", err)
+		os.Exit(1)
+	}
+
+	targets := forestTargets(forestID)
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load storage: %s\n", err)
+		os.Exit(1)
+	}
+	nodes, err := reg.GetNodes(forestID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Failed to get nodes: %s\n", err)
+		os.Exit(1)
+	}
+
+	UseForestProject(cfg, reg, forestID)
+	machineProv, _, err := CreateMachineProvider(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	// Targets are named in node order
+	serverIDs := map[string]string{}
+	for i, target := range targets {
+		serverIDs[target.Name] = nodes[i].ID
+	}
+	opts.ServerState = func(ctx context.Context, node string) (machine.ServerState, error) {
+		server, err := machineProv.GetServer(ctx, serverIDs[node])
+		if err != nil {
+			return machine.ServerStateUnknown, err
+		}
+		return server.State, nil
+	}
+
+	opts.IdentityFile = sshIdentityFile(cfg)
+	opts.ExpectNATS = cfg.Provisioning.NATS.Enabled || cfg.IsNimsForestInstallEnabled()
+	// Records are only created with a DNS token, see CreateDNSProvider
+	if cfg.DNS.Domain != "" && cfg.GetDNSToken() != "" {
+		opts.Domain = cfg.DNS.Domain
+	}
+
+	if !jsonOutput {
+		fmt.Printf("⏳ Waiting up to %s for %d node%s of %s to be %s...\n",
+			timeout, len(targets), ui.Plural(len(targets)), forestID, opts.For)
+		opts.Progress = printWaitProgress
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	results, err := health.Wait(ctx, targets, opts)
+	elapsed := time.Since(start).Round(time.Second)
+
+	if jsonOutput {
+		output := map[string]interface{}{
+			"forest_id": forestID,
+			"for":       opts.For,
+			"ready":     err == nil,
+			"elapsed":   elapsed.String(),
+			"nodes":     results,
+		}
+		if err != nil {
+			output["error"] = err.Error()
+		}
+		jsonData, _ := json.MarshalIndent(output, "", "  ")
+		fmt.Println(string(jsonData))
+	} else if err == nil {
+		fmt.Printf("\n✅ %s is %s (%s)\n", forestID, opts.For, elapsed)
+	} else {
+		fmt.Println()
+		if errors.Is(err, context.DeadlineExceeded) {
+			fmt.Fprintf(os.Stderr, "❌ %s not %s after %s\n", forestID, opts.For, timeout)
+		} else {
+			fmt.Fprintf(os.Stderr, "❌ %s will not become %s: %s\n", forestID, opts.For, err)
+		}
+		for _, node := range results {
+			for _, check := range node.Checks {
+				if check.Status != health.StatusOK {
+					fmt.Fprintf(os.Stderr, "   %s %s: %s %s\n", healthIcon(check.Status), node.Node, check.Name, check.Detail)
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// printWaitProgress prints a line per round: how many nodes are ready and
+// what the first node that is not is waiting for
+func printWaitProgress(nodes []health.NodeHealth) {
+	ready := 0
+	waiting := ""
+	for _, node := range nodes {
+		if node.Status == health.StatusOK {
+			ready++
+			continue
+		}
+		if waiting != "" {
+			continue
+		}
+		for _, check := range node.Checks {
+			if check.Status != health.StatusOK {
+				waiting = fmt.Sprintf(" (%s: %s %s)", node.Node, check.Name, check.Detail)
+				break
+			}
+		}
+	}
+	fmt.Printf("   %s %d/%d ready%s\n", time.Now().Format("15:04:05"), ready, len(nodes), waiting)
+}
+
+func printWaitHelp() {
+	fmt.Println("Usage: morpheus wait <forest-id> [options]")
+	fmt.Println()
+	fmt.Println("Block until every node of a forest is ready, to gate the next stage of")
+	fmt.Println("a pipeline. Exits with status 0 once ready and 1 on timeout or when a")
+	fmt.Println("node cannot become ready (cloud-init failed, server deleted).")
+	fmt.Println()
+	fmt.Println("A node is ready when:")
+	fmt.Println("  - the provider reports its server running")
+	fmt.Println("  - it is reachable over SSH and cloud-init has finished")
+	fmt.Println("  - its DNS name resolves to it (when DNS is configured)")
+	fmt.Println("  - NATS accepts connections (when NATS or NimsForest is installed)")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --for ready|running    What to wait for (default: ready)")
+	fmt.Println("                         running only checks the server state")
+	fmt.Println("  --timeout D            Give up after D, e.g. 30m (default: 20m)")
+	fmt.Println("  --interval D           Time between checks (default: 10s)")
+	fmt.Println("  --user NAME            Remote user (default: root)")
+	fmt.Println("  --json                 Output the final result as JSON")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus wait forest-123 --for ready --timeout 20m")
+	fmt.Println("  morpheus wait forest-123 --for running --timeout 5m")
+	fmt.Println("  morpheus wait forest-123 --json | jq '.nodes[] | select(.status != \"ok\")'")
+}
@@ -0,0 +1,256 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/health"
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// HandleWatch handles the watch command.
+func HandleWatch() {
+	forestID := ""
+	interval := 5 * time.Second
+	once := false
+
+	for i := 2; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		switch {
+		case arg == "--help" || arg == "-h":
+			printWatchHelp()
+			os.Exit(0)
+		case arg == "--interval":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --interval requires a value")
+				os.Exit(1)
+			}
+			i++
+			d, err := time.ParseDuration(os.Args[i])
+			if err != nil || d < time.Second {
+				fmt.Fprintf(os.Stderr, "❌ Invalid interval: %s (at least 1s)\n", os.Args[i])
+				os.Exit(1)
+			}
+			interval = d
+		case arg == "--once":
+			once = true
+		case !startsWithDash(arg) && forestID == "":
+			forestID = arg
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", arg)
+			fmt.Fprintln(os.Stderr, "Use 'morpheus watch --help' for usage")
+			os.Exit(1)
+		}
+	}
+
+	reg, err := CreateStorage()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load storage: %s\n", err)
+		os.Exit(1)
+	}
+	if forestID != "" {
+		if _, err := reg.GetForest(forestID); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Forest not found: %s\n", forestID)
+			os.Exit(1)
+		}
+	}
+
+	// Without a config or provider, server states and health are left out
+	cfg, _ := LoadConfig()
+	var machineProv machine.Provider
+	if cfg != nil && forestID != "" {
+		UseForestProject(cfg, reg, forestID)
+		machineProv, _, _ = CreateMachineProvider(cfg)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Redraw in place on a terminal, append when piped to a file
+	redraw := ui.IsTerminal(os.Stdout) && !once
+	for {
+		var screen func()
+		if forestID == "" {
+			screen = func() { printForestsOverview(reg) }
+		} else {
+			screen = watchForest(ctx, cfg, reg, machineProv, forestID, interval)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		if redraw {
+			ui.ClearScreen()
+			fmt.Printf("Every %s, updated %s. Ctrl-C to exit.\n\n", interval, time.Now().Format("15:04:05"))
+		}
+		screen()
+		if once {
+			return
+		}
+		if !redraw {
+			fmt.Println()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// watchForest collects the state of a forest's nodes and returns a function
+// that prints it, so the screen is only cleared once there is something to
+// show
+func watchForest(ctx context.Context, cfg *config.Config, reg storage.Registry, machineProv machine.Provider, forestID string, interval time.Duration) func() {
+	f, err := reg.GetForest(forestID)
+	if err != nil {
+		return func() { fmt.Printf("❌ %s\n", err) }
+	}
+	nodes, _ := reg.GetNodes(forestID)
+	targets := nodeTargets(f, nodes)
+
+	opts := health.WaitOptions{For: health.ForReady}
+	// A round of checks has to fit between redraws
+	opts.Timeout = interval
+	if opts.Timeout < 10*time.Second {
+		opts.Timeout = 10 * time.Second
+	}
+
+	if machineProv != nil {
+		// One listing for all nodes instead of a request per node
+		states := map[string]machine.ServerState{}
+		servers, err := machineProv.ListServers(ctx, map[string]string{"forest-id": forestID})
+		for _, server := range servers {
+			states[server.ID] = server.State
+		}
+		serverIDs := map[string]string{}
+		for i, target := range targets {
+			serverIDs[target.Name] = nodes[i].ID
+		}
+		opts.ServerState = func(ctx context.Context, node string) (machine.ServerState, error) {
+			if err != nil {
+				return machine.ServerStateUnknown, err
+			}
+			if state, ok := states[serverIDs[node]]; ok {
+				return state, nil
+			}
+			return machine.ServerStateUnknown, fmt.Errorf("server not found")
+		}
+	}
+	if cfg != nil {
+		opts.IdentityFile = sshIdentityFile(cfg)
+		opts.ExpectNATS = cfg.Provisioning.NATS.Enabled || cfg.IsNimsForestInstallEnabled()
+		if cfg.DNS.Domain != "" && cfg.GetDNSToken() != "" {
+			opts.Domain = cfg.DNS.Domain
+		}
+	}
+
+	results := health.Readiness(ctx, targets, opts)
+	return func() { printForestWatch(f, nodes, results, opts) }
+}
+
+func printForestWatch(f *storage.Forest, nodes []*storage.Node, results []health.NodeHealth, opts health.WaitOptions) {
+	ready := 0
+	for _, r := range results {
+		if r.Status == health.StatusOK {
+			ready++
+		}
+	}
+
+	fmt.Printf("🌲 %s  %s  %s  %d/%d node%s registered, %d ready\n",
+		f.ID, shortLocation(f), f.Status, len(nodes), f.NodeCount, ui.Plural(f.NodeCount), ready)
+	fmt.Println()
+	if len(nodes) == 0 {
+		fmt.Println("   ⏳ No nodes registered yet")
+		return
+	}
+
+	columns := []string{health.CheckRunning, health.CheckSSH, health.CheckCloudInit}
+	header := fmt.Sprintf("   %-22s %-24s %-11s %-4s %-10s", "NODE", "IP ADDRESS", "SERVER", "SSH", "CLOUD-INIT")
+	if opts.ExpectNATS {
+		columns = append(columns, health.CheckNATS)
+		header += " NATS"
+	}
+	if opts.Domain != "" {
+		columns = append(columns, health.CheckDNS)
+		header += " DNS"
+	}
+	fmt.Println(header)
+	fmt.Println("   ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+	widths := map[string]int{health.CheckRunning: 10, health.CheckSSH: 3, health.CheckCloudInit: 10, health.CheckNATS: 4}
+	for i, node := range nodes {
+		fmt.Printf("   %-22s %-24s", ui.TruncateID(results[i].Node, 22), ui.TruncateIP(node.IP, 24))
+		for _, name := range columns {
+			cell := "-"
+			if c := results[i].Result(name); c != nil {
+				cell = healthIcon(c.Status)
+				// States and cloud-init progress are short enough to show
+				if (name == health.CheckRunning || name == health.CheckCloudInit) && c.Detail != "" && len(c.Detail) <= 8 {
+					cell += " " + c.Detail
+				}
+			}
+			fmt.Printf(" %-*s", widths[name], cell)
+		}
+		fmt.Println()
+	}
+}
+
+// printForestsOverview prints every forest with its status and node count
+func printForestsOverview(reg storage.Registry) {
+	forests := reg.ListForests()
+	fmt.Printf("🌲 Forests (%d)\n", len(forests))
+	fmt.Println()
+	if len(forests) == 0 {
+		fmt.Println("   No forests yet")
+		return
+	}
+
+	fmt.Println("   FOREST ID            NODES    LOCATION  STATUS          AGE")
+	fmt.Println("   ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	for _, f := range forests {
+		registered := 0
+		if nodes, err := reg.GetNodes(f.ID); err == nil {
+			registered = len(nodes)
+		}
+		statusIcon := "✅"
+		if f.Status == "provisioning" {
+			statusIcon = "⏳"
+		} else if f.Status != "active" {
+			statusIcon = "⚠️ "
+		}
+		fmt.Printf("   %-20s %-8s %-9s %s %-13s %s\n",
+			ui.TruncateID(f.ID, 20),
+			fmt.Sprintf("%d/%d", registered, f.NodeCount),
+			shortLocation(f),
+			statusIcon,
+			f.Status,
+			ui.FormatDuration(time.Since(f.CreatedAt)),
+		)
+	}
+}
+
+func printWatchHelp() {
+	fmt.Println("Usage: morpheus watch [forest-id] [options]")
+	fmt.Println()
+	fmt.Println("Show a live view of a forest's nodes: server state, IP, SSH, cloud-init")
+	fmt.Println("and, when configured, NATS and DNS, refreshed every few seconds. Handy")
+	fmt.Println("while a forest is being planted. Without a forest ID, shows all forests")
+	fmt.Println("with how many of their nodes are registered.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --interval D           Time between refreshes (default: 5s)")
+	fmt.Println("  --once                 Print the view once and exit")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus watch")
+	fmt.Println("  morpheus watch forest-123 --interval 10s")
+}
@@ -0,0 +1,352 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/ui"
+	"github.com/nimsforest/morpheus/pkg/customer"
+	"github.com/nimsforest/morpheus/pkg/forest"
+	"github.com/nimsforest/morpheus/pkg/lockfile"
+	"github.com/nimsforest/morpheus/pkg/machine/hetzner"
+	"github.com/nimsforest/morpheus/pkg/nats"
+	"github.com/nimsforest/morpheus/pkg/storage"
+	"github.com/nimsforest/morpheus/pkg/worker"
+)
+
+// HandleWorker handles the worker command.
+func HandleWorker() {
+	queueArg := ""
+	natsURL := GetEnvOrDefault("NATS_URL", nats.DefaultURL)
+	once := false
+	poll := 5 * time.Second
+	concurrency := 0
+
+	for i := 2; i < len(os.Args); i++ {
+		switch os.Args[i] {
+		case "--queue":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --queue requires a directory or NATS subject")
+				os.Exit(1)
+			}
+			i++
+			queueArg = os.Args[i]
+		case "--nats-url":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --nats-url requires a URL")
+				os.Exit(1)
+			}
+			i++
+			natsURL = os.Args[i]
+		case "--poll":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --poll requires a duration")
+				os.Exit(1)
+			}
+			i++
+			d, err := time.ParseDuration(os.Args[i])
+			if err != nil || d <= 0 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid poll interval: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			poll = d
+		case "--concurrency":
+			if i+1 >= len(os.Args) {
+				fmt.Fprintln(os.Stderr, "❌ --concurrency requires a number")
+				os.Exit(1)
+			}
+			i++
+			n, err := strconv.Atoi(os.Args[i])
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "❌ Invalid concurrency: %s\n", os.Args[i])
+				os.Exit(1)
+			}
+			concurrency = n
+		case "--once":
+			once = true
+		case "--help", "-h":
+			printWorkerHelp()
+			os.Exit(0)
+		default:
+			fmt.Fprintf(os.Stderr, "❌ Unknown argument: %s\n", os.Args[i])
+			fmt.Fprintln(os.Stderr, "Use 'morpheus worker --help' for usage")
+			os.Exit(1)
+		}
+	}
+
+	if queueArg == "" {
+		fmt.Fprintln(os.Stderr, "❌ --queue is required")
+		fmt.Fprintln(os.Stderr, "Usage: morpheus worker --queue <dir|nats-subject>")
+		os.Exit(1)
+	}
+
+	// An existing directory is a directory queue, anything else a subject
+	var queue worker.Queue
+	var source string
+	if info, err := os.Stat(queueArg); err == nil && info.IsDir() {
+		queue, err = worker.NewDirQueue(queueArg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			os.Exit(1)
+		}
+		source = "directory " + queueArg
+	} else {
+		queue, err = worker.NewNATSQueue(natsURL, queueArg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			os.Exit(1)
+		}
+		source = fmt.Sprintf("NATS subject %s on %s", queueArg, natsURL)
+	}
+	defer queue.Close()
+
+	// Finish the current job on Ctrl+C, then stop
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("👷 Morpheus worker\n")
+	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	fmt.Printf("   Queue: %s\n", source)
+	if once {
+		fmt.Printf("   Mode:  process waiting jobs, then exit\n")
+	} else {
+		fmt.Printf("   Mode:  wait for jobs (Ctrl+C to stop)\n")
+	}
+
+	w := worker.New(queue, runJob)
+	w.PollInterval = poll
+	w.Concurrency = concurrency
+	if cfg, err := LoadConfig(); err == nil {
+		if w.Concurrency == 0 {
+			w.Concurrency = cfg.Worker.Concurrency
+		}
+		if len(cfg.Worker.Customers) > 0 {
+			quotas := make(map[string]worker.Quota)
+			for name, q := range cfg.Worker.Customers {
+				quotas[name] = worker.Quota{
+					Token:         customer.ResolveToken(q.Token),
+					MaxForests:    q.MaxForests,
+					MaxNodes:      q.MaxNodes,
+					MaxConcurrent: q.MaxConcurrent,
+					Projects:      q.Projects,
+				}
+			}
+			w.Limiter = worker.NewLimiter(quotas, func() ([]*storage.Forest, error) {
+				reg, err := CreateStorage()
+				if err != nil {
+					return nil, err
+				}
+				return reg.ListForests(), nil
+			})
+			fmt.Printf("   Quotas: %d customer%s\n", len(quotas), ui.Plural(len(quotas)))
+		}
+	}
+	if w.Concurrency > 1 {
+		fmt.Printf("   Jobs:   up to %d at once\n", w.Concurrency)
+	}
+	if err := w.Run(ctx, once); err != nil {
+		fmt.Fprintf(os.Stderr, "\n❌ Worker stopped: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("\n👋 Worker stopped")
+}
+
+// runJob runs one job. It loads the config per job, so config changes
+// apply without restarting the worker.
+func runJob(_ context.Context, job *worker.Job) (string, error) {
+	// Provisioning is not interruptible halfway; use a fresh context
+	ctx := context.Background()
+	switch job.Action {
+	case worker.ActionPlant:
+		return runPlantJob(ctx, job)
+	case worker.ActionTeardown:
+		return job.ForestID, runTeardownJob(ctx, job)
+	}
+	return "", fmt.Errorf("unknown job action %q", job.Action)
+}
+
+func runPlantJob(ctx context.Context, job *worker.Job) (string, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return "", fmt.Errorf("failed to load config: %w", err)
+	}
+	if job.Project != "" {
+		cfg.Secrets.HetznerProject = job.Project
+	}
+	if err := cfg.Validate(); err != nil {
+		return "", fmt.Errorf("invalid config: %w", err)
+	}
+
+	machineProv, _, err := CreateMachineProvider(cfg)
+	if err != nil {
+		return "", err
+	}
+	storageProv, err := CreateStorage()
+	if err != nil {
+		return "", fmt.Errorf("failed to create storage: %w", err)
+	}
+
+	forestID := job.ForestID
+	if forestID == "" {
+		forestID = worker.NewForestID()
+	}
+	if _, err := storageProv.GetForest(forestID); err == nil {
+		return forestID, fmt.Errorf("forest %s already exists", forestID)
+	}
+
+	lock, err := AcquireForestLock(forestID, "worker plant", lockfile.DefaultTTL)
+	if err != nil {
+		return forestID, err
+	}
+	defer lock.Release()
+
+	var provisioner *forest.Provisioner
+	if dnsProv := CreateDNSProvider(cfg); dnsProv != nil {
+		provisioner = forest.NewProvisionerWithDNS(machineProv, storageProv, dnsProv, cfg)
+	} else {
+		provisioner = forest.NewProvisioner(machineProv, storageProv, cfg)
+	}
+	configureInventory(provisioner, cfg)
+
+	nodeCount := job.Nodes
+	if nodeCount == 0 {
+		nodeCount = 2
+	}
+	req := forest.ProvisionRequest{
+		ForestID:   forestID,
+		NodeCount:  nodeCount,
+		Location:   job.Location,
+		ServerType: job.ServerType,
+		Image:      job.Image,
+		Project:    cfg.GetHetznerProject(),
+		Customer:   job.Customer,
+	}
+	if req.Location == "" {
+		req.Location = cfg.GetLocation()
+	}
+	if req.ServerType == "" {
+		req.ServerType = cfg.GetServerType()
+	}
+	if req.Image == "" {
+		req.Image = cfg.GetImage()
+	}
+	if job.VolumeGB > 0 {
+		req.Volume = &forest.VolumeSpec{SizeGB: job.VolumeGB}
+	}
+	if job.FloatingIP {
+		req.FloatingIP = "ipv6"
+		if cfg.IsIPv4Enabled() {
+			req.FloatingIP = "ipv4"
+		}
+	}
+
+	hetznerProv, isHetzner := machineProv.(*hetzner.Provider)
+	if isHetzner {
+		req.ExpectedNodeCost = hetzner.GetEstimatedNodeCost(req.ServerType, cfg.IsIPv4Enabled(), job.VolumeGB)
+		switch req.FloatingIP {
+		case "ipv4":
+			req.FloatingIPCost = hetzner.EstimatedFloatingIPv4Cost
+		case "ipv6":
+			req.FloatingIPCost = hetzner.EstimatedFloatingIPv6Cost
+		}
+	}
+
+	fmt.Printf("🌲 Planting %s (%d node%s, %s)\n", forestID, nodeCount, ui.Plural(nodeCount), req.ServerType)
+
+	// Without an explicit type or location, fall back like plant does
+	if isHetzner && job.ServerType == "" && job.Location == "" {
+		err = provisionWithFallback(ctx, provisioner, hetznerProv, req, cfg.GetServerType(), cfg.GetServerTypeFallback())
+	} else {
+		err = provisioner.Provision(ctx, req)
+	}
+	return forestID, err
+}
+
+func runTeardownJob(ctx context.Context, job *worker.Job) error {
+	storageProv, err := CreateStorage()
+	if err != nil {
+		return fmt.Errorf("failed to create storage: %w", err)
+	}
+	if _, err := storageProv.GetForest(job.ForestID); err != nil {
+		return fmt.Errorf("forest not found: %s", job.ForestID)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	UseForestProject(cfg, storageProv, job.ForestID)
+
+	machineProv, _, err := CreateMachineProvider(cfg)
+	if err != nil {
+		return err
+	}
+
+	lock, err := AcquireForestLock(job.ForestID, "worker teardown", lockfile.DefaultTTL)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	var provisioner *forest.Provisioner
+	if dnsProv := CreateDNSProvider(cfg); dnsProv != nil {
+		provisioner = forest.NewProvisionerWithDNS(machineProv, storageProv, dnsProv, cfg)
+	} else {
+		provisioner = forest.NewProvisioner(machineProv, storageProv, cfg)
+	}
+	configureInventory(provisioner, cfg)
+
+	return provisioner.TeardownWithOptions(ctx, job.ForestID, forest.TeardownOptions{KeepVolumes: job.KeepVolumes})
+}
+
+func printWorkerHelp() {
+	fmt.Println("Usage: morpheus worker --queue <dir|nats-subject> [options]")
+	fmt.Println()
+	fmt.Println("Process plant and teardown jobs from a queue.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --queue <dir|subject>  Job directory, or NATS subject if no such directory exists")
+	fmt.Println("  --nats-url <url>       NATS server (default: $NATS_URL or nats://127.0.0.1:4222)")
+	fmt.Println("  --poll <duration>      How often to check for jobs (default: 5s)")
+	fmt.Println("  --concurrency N        Jobs run at once (default: worker.concurrency, or 1)")
+	fmt.Println("  --once                 Process waiting jobs, then exit")
+	fmt.Println()
+	fmt.Println("Job spec (YAML):")
+	fmt.Println("  action: plant          # plant or teardown")
+	fmt.Println("  forest_id: acme-dev    # Required for teardown, generated for plant")
+	fmt.Println("  nodes: 3               # Plant: node count (default 2)")
+	fmt.Println("  project: staging       # Plant: named Hetzner project")
+	fmt.Println("  server_type, location, image, volume_gb, floating_ip, keep_volumes")
+	fmt.Println("  customer: acme         # Required when worker.customers is configured")
+	fmt.Println("  token: ...             # The customer's worker.customers.<name>.token")
+	fmt.Println()
+	fmt.Println("Quotas:")
+	fmt.Println("  With worker.customers configured, each job must name a customer and carry")
+	fmt.Println("  its token. Plant jobs over the customer's max_forests, max_nodes or")
+	fmt.Println("  max_concurrent fail before they start; customers may only tear down")
+	fmt.Println("  their own forests. Plant jobs may only name one of the customer's")
+	fmt.Println("  projects, and run in the first if they name none; without projects")
+	fmt.Println("  they run in the worker's project. Quotas are read when the worker starts.")
+	fmt.Println()
+	fmt.Println("Directory queues:")
+	fmt.Println("  Jobs are <dir>/*.yaml, taken in name order and moved to running/,")
+	fmt.Println("  then done/ or failed/. Status is written to status/<job>.json.")
+	fmt.Println("  Write specs under another name and rename them into place.")
+	fmt.Println()
+	fmt.Println("NATS queues:")
+	fmt.Println("  Jobs published to the subject are kept in a JetStream work queue stream")
+	fmt.Println("  (MORPHEUS_JOBS for morpheus.jobs), set up by the first worker. Workers")
+	fmt.Println("  share the durable pull consumer 'morpheus-workers' and pull a job only")
+	fmt.Println("  when a slot is free. A job is acknowledged when a worker takes it, so it")
+	fmt.Println("  is not run again if that worker dies. Status updates are published to")
+	fmt.Println("  <subject>.status. The NATS server must have JetStream enabled.")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  morpheus worker --queue /var/spool/morpheus")
+	fmt.Println("  morpheus worker --queue morpheus.jobs --nats-url nats://portal:4222")
+}
@@ -0,0 +1,133 @@
+package hetznermock
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Chaos makes the API unreliable in the ways the real one can be, to check
+// that retries, rollbacks and resumes work
+type Chaos struct {
+	// Latency is the maximum random delay added to each request
+	Latency time.Duration
+	// RateLimit is the probability that a request is answered with 429
+	// before it takes effect
+	RateLimit float64
+	// Failure is the probability that a POST, PUT or DELETE takes effect
+	// but is answered with 503, as if the connection dropped mid-operation
+	Failure float64
+	// Seed makes the injected faults reproducible; 0 picks a random seed
+	Seed int64
+}
+
+// DefaultChaos is what --chaos without settings injects
+var DefaultChaos = Chaos{Latency: 200 * time.Millisecond, RateLimit: 0.1, Failure: 0.05}
+
+// ParseChaos parses chaos settings of the form
+// "latency=200ms,rate-limit=0.1,failure=0.05,seed=42". Settings left out
+// are taken from DefaultChaos.
+func ParseChaos(spec string) (Chaos, error) {
+	c := DefaultChaos
+	for _, setting := range strings.Split(spec, ",") {
+		setting = strings.TrimSpace(setting)
+		if setting == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(setting, "=")
+		if !ok {
+			return c, fmt.Errorf("invalid chaos setting %q (want key=value)", setting)
+		}
+		var err error
+		switch key {
+		case "latency":
+			c.Latency, err = time.ParseDuration(value)
+		case "rate-limit":
+			c.RateLimit, err = parseProbability(value)
+		case "failure":
+			c.Failure, err = parseProbability(value)
+		case "seed":
+			c.Seed, err = strconv.ParseInt(value, 10, 64)
+		default:
+			return c, fmt.Errorf("unknown chaos setting %q (supported: latency, rate-limit, failure, seed)", key)
+		}
+		if err != nil {
+			return c, fmt.Errorf("invalid chaos setting %q: %w", setting, err)
+		}
+	}
+	return c, nil
+}
+
+func parseProbability(s string) (float64, error) {
+	p, err := strconv.ParseFloat(s, 64)
+	if err != nil || p < 0 || p > 1 {
+		return 0, fmt.Errorf("must be between 0 and 1")
+	}
+	return p, nil
+}
+
+// String returns the settings in the form ParseChaos accepts
+func (c Chaos) String() string {
+	return fmt.Sprintf("latency=%s,rate-limit=%g,failure=%g,seed=%d", c.Latency, c.RateLimit, c.Failure, c.Seed)
+}
+
+// SetChaos starts injecting faults; the zero Chaos turns them off
+func (a *API) SetChaos(c Chaos) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if c == (Chaos{}) {
+		a.chaos, a.rand = c, nil
+		return
+	}
+	if c.Seed == 0 {
+		c.Seed = time.Now().UnixNano()
+	}
+	a.chaos = c
+	a.rand = rand.New(rand.NewSource(c.Seed))
+}
+
+// Chaos returns the chaos settings in effect, with the seed picked if none
+// was given
+func (a *API) Chaos() Chaos {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.chaos
+}
+
+// chaosFault is what chaos decided to do to a request
+type chaosFault int
+
+const (
+	faultNone chaosFault = iota
+	faultRateLimit
+	faultFailure
+)
+
+// rollChaos picks the delay and fault for a request. The caller holds a.mu.
+func (a *API) rollChaos(method string) (time.Duration, chaosFault) {
+	if a.rand == nil {
+		return 0, faultNone
+	}
+	var delay time.Duration
+	if a.chaos.Latency > 0 {
+		delay = time.Duration(a.rand.Int63n(int64(a.chaos.Latency)))
+	}
+	switch roll := a.rand.Float64(); {
+	case roll < a.chaos.RateLimit:
+		return delay, faultRateLimit
+	case method != http.MethodGet && roll < a.chaos.RateLimit+a.chaos.Failure:
+		return delay, faultFailure
+	}
+	return delay, faultNone
+}
+
+// loseResponse runs serve so that the request takes effect, then answers
+// with 503 instead of its response
+func loseResponse(w http.ResponseWriter, serve func(http.ResponseWriter)) {
+	serve(httptest.NewRecorder())
+	writeError(w, http.StatusServiceUnavailable, "unavailable", "chaos: connection lost mid-operation")
+}
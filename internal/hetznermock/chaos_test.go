@@ -0,0 +1,61 @@
+package hetznermock
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseChaos(t *testing.T) {
+	c, err := ParseChaos("")
+	if err != nil || c != DefaultChaos {
+		t.Errorf(`ParseChaos("") = %+v, %v, want defaults`, c, err)
+	}
+
+	c, err = ParseChaos("latency=1s, failure=0.5,seed=7")
+	want := Chaos{Latency: time.Second, RateLimit: DefaultChaos.RateLimit, Failure: 0.5, Seed: 7}
+	if err != nil || c != want {
+		t.Errorf("ParseChaos() = %+v, %v, want %+v", c, err, want)
+	}
+	if back, err := ParseChaos(c.String()); err != nil || back != c {
+		t.Errorf("ParseChaos(%q) = %+v, %v", c.String(), back, err)
+	}
+
+	for _, spec := range []string{"failure", "failure=2", "rate-limit=-0.1", "latency=fast", "jitter=1s"} {
+		if _, err := ParseChaos(spec); err == nil {
+			t.Errorf("ParseChaos(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestChaos(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	zone := `{"name": "example.com", "mode": "primary"}`
+
+	// Rate limited requests have no effect
+	s.SetChaos(Chaos{RateLimit: 1, Seed: 1})
+	if resp, _ := do(t, s, "POST", "/zones", "", zone); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("POST /zones = %d, want 429", resp.StatusCode)
+	}
+	if s.findZone("example.com") != nil {
+		t.Fatal("rate limited request created a zone")
+	}
+
+	// Failed requests take effect, but GETs are never failed
+	s.SetChaos(Chaos{Failure: 1, Seed: 1})
+	if resp, _ := do(t, s, "GET", "/zones", "", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /zones = %d, want 200", resp.StatusCode)
+	}
+	if resp, _ := do(t, s, "POST", "/zones", "", zone); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("POST /zones = %d, want 503", resp.StatusCode)
+	}
+	if s.findZone("example.com") == nil {
+		t.Fatal("failed request did not create the zone")
+	}
+
+	s.SetChaos(Chaos{})
+	if resp, _ := do(t, s, "DELETE", "/zones/example.com", "", ""); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("DELETE /zones/example.com with chaos off = %d, want 201", resp.StatusCode)
+	}
+}
@@ -0,0 +1,670 @@
+package hetznermock
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
+)
+
+// seedCatalog fills in the server types, images and locations servers can
+// be created with
+func (a *API) seedCatalog() {
+	a.locations = []schema.Location{
+		{ID: 1, Name: "fsn1", City: "Falkenstein", Country: "DE", NetworkZone: "eu-central"},
+		{ID: 2, Name: "nbg1", City: "Nuremberg", Country: "DE", NetworkZone: "eu-central"},
+		{ID: 3, Name: "hel1", City: "Helsinki", Country: "FI", NetworkZone: "eu-central"},
+		{ID: 4, Name: "ash", City: "Ashburn, VA", Country: "US", NetworkZone: "us-east"},
+		{ID: 5, Name: "hil", City: "Hillsboro, OR", Country: "US", NetworkZone: "us-west"},
+	}
+	eu := []string{"fsn1", "nbg1", "hel1"}
+	all := []string{"fsn1", "nbg1", "hel1", "ash", "hil"}
+	a.serverTypes = []schema.ServerType{
+		serverType(1, "cx22", 2, 4, 40, "shared", "x86", "0.0060", eu),
+		serverType(2, "cpx11", 2, 2, 40, "shared", "x86", "0.0074", all),
+		serverType(3, "cpx31", 4, 8, 160, "shared", "x86", "0.0256", all),
+		serverType(4, "cax11", 2, 4, 40, "shared", "arm", "0.0060", eu),
+		serverType(5, "ccx13", 2, 8, 80, "dedicated", "x86", "0.0232", all),
+	}
+	a.images = []schema.Image{
+		image(1, "ubuntu-24.04", "ubuntu", "24.04"),
+		image(2, "ubuntu-22.04", "ubuntu", "22.04"),
+		image(3, "debian-12", "debian", "12"),
+	}
+}
+
+func serverType(id int64, name string, cores int, memory float32, disk int, cpuType, arch, hourly string, locations []string) schema.ServerType {
+	t := schema.ServerType{
+		ID: id, Name: name, Description: strings.ToUpper(name),
+		Cores: cores, Memory: memory, Disk: disk,
+		StorageType: "local", CPUType: cpuType, Architecture: arch,
+	}
+	h, _ := strconv.ParseFloat(hourly, 64)
+	monthly := fmt.Sprintf("%.4f", h*730)
+	for _, loc := range locations {
+		t.Prices = append(t.Prices, schema.PricingServerTypePrice{
+			Location:     loc,
+			PriceHourly:  schema.Price{Net: hourly, Gross: hourly},
+			PriceMonthly: schema.Price{Net: monthly, Gross: monthly},
+		})
+	}
+	return t
+}
+
+func image(id int64, name, flavor, version string) schema.Image {
+	return schema.Image{
+		ID: id, Name: &name, Description: name, Status: "available", Type: "system",
+		OSFlavor: flavor, OSVersion: &version, Architecture: "x86", DiskSize: 5,
+	}
+}
+
+// serveCatalog answers the read-only /server_types, /locations and
+// /datacenters
+func (a *API) serveCatalog(w http.ResponseWriter, r *request) {
+	if r.method() != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "read-only resource")
+		return
+	}
+	name := r.query("name")
+	switch r.parts[0] {
+	case "server_types":
+		items := []schema.ServerType{}
+		for _, t := range a.serverTypes {
+			if matches(r, t.ID, name, t.Name) {
+				items = append(items, t)
+			}
+		}
+		writeOneOrList(w, r, "server_type", "server_types", items)
+	case "locations":
+		items := []schema.Location{}
+		for _, loc := range a.locations {
+			if matches(r, loc.ID, name, loc.Name) {
+				items = append(items, loc)
+			}
+		}
+		writeOneOrList(w, r, "location", "locations", items)
+	case "datacenters":
+		items := []schema.Datacenter{}
+		for _, dc := range a.datacenters() {
+			if matches(r, dc.ID, name, dc.Name) {
+				items = append(items, dc)
+			}
+		}
+		writeOneOrList(w, r, "datacenter", "datacenters", items)
+	}
+}
+
+// imageName returns the name of a system image, "" for a snapshot
+func imageName(img schema.Image) string {
+	if img.Name == nil {
+		return ""
+	}
+	return *img.Name
+}
+
+// serveImages answers /images and /images/{id}. The system images of the
+// catalog are read-only; snapshots of servers can be deleted.
+func (a *API) serveImages(w http.ResponseWriter, r *request) {
+	switch {
+	case r.method() == http.MethodGet:
+		name, selector, types := r.query("name"), r.query("label_selector"), r.r.URL.Query()["type"]
+		items := []schema.Image{}
+		for _, img := range a.images {
+			if matches(r, img.ID, name, imageName(img)) && matchLabels(img.Labels, selector) && (len(types) == 0 || contains(types, img.Type)) {
+				items = append(items, img)
+			}
+		}
+		writeOneOrList(w, r, "image", "images", items)
+	case r.method() == http.MethodDelete && len(r.parts) == 2:
+		for i, img := range a.images {
+			if strconv.FormatInt(img.ID, 10) != r.parts[1] {
+				continue
+			}
+			if img.Type == "system" {
+				writeError(w, http.StatusForbidden, "forbidden", "system images cannot be deleted")
+				return
+			}
+			a.images = append(a.images[:i], a.images[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeError(w, http.StatusNotFound, "not_found", "image not found")
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "unsupported method")
+	}
+}
+
+// servePricing answers /pricing from the server types of the catalog and
+// fixed prices for everything else
+func (a *API) servePricing(w http.ResponseWriter, r *request) {
+	price := func(s string) schema.Price { return schema.Price{Net: s, Gross: s} }
+	pricing := schema.Pricing{
+		Currency: "EUR",
+		VATRate:  "19.00",
+		Volume:   schema.PricingVolume{PricePerGBPerMonth: price("0.0440")},
+	}
+	for _, t := range a.serverTypes {
+		pricing.ServerTypes = append(pricing.ServerTypes, schema.PricingServerType{ID: t.ID, Name: t.Name, Prices: t.Prices})
+	}
+	lb := schema.PricingLoadBalancerType{ID: 1, Name: "lb11"}
+	ipv4 := schema.PricingPrimaryIP{Type: "ipv4"}
+	floating := map[string]string{"ipv4": "3.0000", "ipv6": "1.0000"}
+	floatingIPs := []schema.PricingFloatingIPType{{Type: "ipv4"}, {Type: "ipv6"}}
+	for _, loc := range a.locations {
+		lb.Prices = append(lb.Prices, schema.PricingLoadBalancerTypePrice{Location: loc.Name, PriceHourly: price("0.0074"), PriceMonthly: price("5.3900")})
+		ipv4.Prices = append(ipv4.Prices, schema.PricingPrimaryIPTypePrice{Location: loc.Name, PriceHourly: price("0.0008"), PriceMonthly: price("0.5000")})
+		for i := range floatingIPs {
+			floatingIPs[i].Prices = append(floatingIPs[i].Prices, schema.PricingFloatingIPTypePrice{Location: loc.Name, PriceMonthly: price(floating[floatingIPs[i].Type])})
+		}
+	}
+	pricing.LoadBalancerTypes = []schema.PricingLoadBalancerType{lb}
+	pricing.PrimaryIPs = []schema.PricingPrimaryIP{ipv4}
+	pricing.FloatingIPs = floatingIPs
+	writeJSON(w, http.StatusOK, schema.PricingGetResponse{Pricing: pricing})
+}
+
+// matches reports whether a catalog entry is selected by the request's
+// /{id} segment or ?name= filter
+func matches(r *request, id int64, name, entryName string) bool {
+	if len(r.parts) > 1 {
+		return r.parts[1] == strconv.FormatInt(id, 10)
+	}
+	return name == "" || name == entryName
+}
+
+// writeOneOrList writes the single item of a /{id} request, or the list
+func writeOneOrList[T any](w http.ResponseWriter, r *request, one, many string, items []T) {
+	if len(r.parts) == 1 {
+		writeList(w, many, items, len(items))
+		return
+	}
+	if len(items) == 0 {
+		writeError(w, http.StatusNotFound, "not_found", one+" not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{one: items[0]})
+}
+
+// Servers returns the servers that currently exist, by ID
+func (a *API) Servers() []schema.Server {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.serverList("")
+}
+
+// serverList returns the servers matching a label selector, by ID. The
+// caller holds a.mu.
+func (a *API) serverList(selector string) []schema.Server {
+	servers := []schema.Server{}
+	for _, s := range a.servers {
+		if matchLabels(s.Labels, selector) {
+			servers = append(servers, *s)
+		}
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].ID < servers[j].ID })
+	return servers
+}
+
+// serveServers answers /servers and /servers/{id}
+func (a *API) serveServers(w http.ResponseWriter, r *request) {
+	if len(r.parts) == 1 {
+		switch r.method() {
+		case http.MethodGet:
+			servers := a.serverList(r.query("label_selector"))
+			if name := r.query("name"); name != "" {
+				named := []schema.Server{}
+				for _, s := range servers {
+					if s.Name == name {
+						named = append(named, s)
+					}
+				}
+				servers = named
+			}
+			writeList(w, "servers", servers, len(servers))
+		case http.MethodPost:
+			a.createServer(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "unsupported method")
+		}
+		return
+	}
+
+	id, _ := strconv.ParseInt(r.parts[1], 10, 64)
+	server, ok := a.servers[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "server not found")
+		return
+	}
+	if len(r.parts) == 4 && r.parts[2] == "actions" && r.method() == http.MethodPost {
+		switch r.parts[3] {
+		case "create_image":
+			a.createImage(w, r, server)
+		case "change_type":
+			a.changeType(w, r, server)
+		case "reset", "reboot", "poweron":
+			server.Status = "running"
+			writeJSON(w, http.StatusCreated, schema.ServerActionResetResponse{Action: a.newAction(r.parts[3], "server", server.ID)})
+		case "poweroff", "shutdown":
+			server.Status = "off"
+			writeJSON(w, http.StatusCreated, schema.ServerActionPoweroffResponse{Action: a.newAction(r.parts[3], "server", server.ID)})
+		case "attach_to_network":
+			var req schema.ServerActionAttachToNetworkRequest
+			if !r.decode(w, &req) {
+				return
+			}
+			network, ok := a.networks[req.Network]
+			if !ok {
+				writeError(w, http.StatusNotFound, "not_found", "network not found")
+				return
+			}
+			ip := ""
+			if req.IP != nil {
+				ip = *req.IP
+			}
+			if msg := a.attachToNetwork(server, network, ip); msg != "" {
+				writeError(w, http.StatusUnprocessableEntity, "invalid_input", msg)
+				return
+			}
+			writeJSON(w, http.StatusCreated, schema.ServerActionAttachToNetworkResponse{Action: a.newAction(r.parts[3], "server", server.ID)})
+		case "detach_from_network":
+			var req schema.ServerActionDetachFromNetworkRequest
+			if !r.decode(w, &req) {
+				return
+			}
+			a.detachFromNetwork(server, req.Network)
+			writeJSON(w, http.StatusCreated, schema.ServerActionDetachFromNetworkResponse{Action: a.newAction(r.parts[3], "server", server.ID)})
+		default:
+			writeError(w, http.StatusNotFound, "not_found", "server action "+r.parts[3]+" is not emulated")
+		}
+		return
+	}
+	switch r.method() {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, schema.ServerGetResponse{Server: *server})
+	case http.MethodPut:
+		var req schema.ServerUpdateRequest
+		if !r.decode(w, &req) {
+			return
+		}
+		if req.Name != "" {
+			server.Name = req.Name
+		}
+		if req.Labels != nil {
+			server.Labels = *req.Labels
+		}
+		writeJSON(w, http.StatusOK, schema.ServerUpdateResponse{Server: *server})
+	case http.MethodDelete:
+		a.releaseServer(server)
+		delete(a.servers, id)
+		writeJSON(w, http.StatusOK, schema.ServerDeleteResponse{Action: a.newAction("delete_server", "server", id)})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "unsupported method")
+	}
+}
+
+func (a *API) createServer(w http.ResponseWriter, r *request) {
+	var req schema.ServerCreateRequest
+	if !r.decode(w, &req) {
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "invalid_input", "name is required")
+		return
+	}
+	for _, s := range a.servers {
+		if s.Name == req.Name {
+			writeError(w, http.StatusConflict, "uniqueness_error", "server name is already used")
+			return
+		}
+	}
+
+	var serverType *schema.ServerType
+	for i, t := range a.serverTypes {
+		if ref(req.ServerType, t.ID, t.Name) {
+			serverType = &a.serverTypes[i]
+		}
+	}
+	var img *schema.Image
+	for i, candidate := range a.images {
+		if ref(req.Image, candidate.ID, imageName(candidate)) {
+			img = &a.images[i]
+		}
+	}
+	if serverType == nil || img == nil {
+		writeError(w, http.StatusBadRequest, "invalid_input", "unknown server type or image")
+		return
+	}
+	locationName := req.Location
+	if locationName == "" {
+		locationName = "fsn1"
+	}
+	var location *schema.Location
+	for i, loc := range a.locations {
+		if loc.Name == locationName || strconv.FormatInt(loc.ID, 10) == locationName {
+			location = &a.locations[i]
+		}
+	}
+	if location == nil {
+		writeError(w, http.StatusBadRequest, "invalid_input", "unknown location")
+		return
+	}
+	available := false
+	for _, price := range serverType.Prices {
+		available = available || price.Location == location.Name
+	}
+	if !available {
+		writeError(w, http.StatusPreconditionFailed, "resource_unavailable", "server type not available in location")
+		return
+	}
+	for _, keyID := range req.SSHKeys {
+		if _, ok := a.sshKeys[keyID]; !ok {
+			writeError(w, http.StatusBadRequest, "invalid_input", fmt.Sprintf("unknown ssh key %d", keyID))
+			return
+		}
+	}
+	var primaryIP *schema.PrimaryIP
+	if req.PublicNet != nil && req.PublicNet.IPv4ID != 0 {
+		primaryIP = a.primaryIPs[req.PublicNet.IPv4ID]
+		if primaryIP == nil || primaryIP.AssigneeID != 0 {
+			writeError(w, http.StatusBadRequest, "invalid_input", fmt.Sprintf("primary IP %d is unknown or assigned", req.PublicNet.IPv4ID))
+			return
+		}
+	}
+	for _, networkID := range req.Networks {
+		if _, ok := a.networks[networkID]; !ok {
+			writeError(w, http.StatusBadRequest, "invalid_input", fmt.Sprintf("unknown network %d", networkID))
+			return
+		}
+	}
+	for _, fw := range req.Firewalls {
+		if _, ok := a.firewalls[fw.Firewall]; !ok {
+			writeError(w, http.StatusBadRequest, "invalid_input", fmt.Sprintf("unknown firewall %d", fw.Firewall))
+			return
+		}
+	}
+
+	id := a.newID()
+	server := &schema.Server{
+		ID:      id,
+		Name:    req.Name,
+		Status:  "running",
+		Created: time.Now().UTC().Truncate(time.Second),
+		PublicNet: schema.ServerPublicNet{
+			IPv6: schema.ServerPublicNetIPv6{ID: id, IP: fmt.Sprintf("2001:db8:%x::/64", id)},
+		},
+		ServerType:      *serverType,
+		Datacenter:      schema.Datacenter{ID: location.ID, Name: location.Name + "-dc1", Location: *location},
+		Image:           img,
+		Labels:          map[string]string{},
+		PrimaryDiskSize: serverType.Disk,
+	}
+	if req.PublicNet == nil || req.PublicNet.EnableIPv4 {
+		server.PublicNet.IPv4 = schema.ServerPublicNetIPv4{ID: id, IP: fmt.Sprintf("203.0.113.%d", id%254+1)}
+	}
+	if req.Labels != nil {
+		server.Labels = *req.Labels
+	}
+	if req.StartAfterCreate != nil && !*req.StartAfterCreate {
+		server.Status = "off"
+	}
+	a.servers[id] = server
+	if primaryIP != nil {
+		a.assignPrimaryIP(primaryIP, server)
+	}
+	for _, networkID := range req.Networks {
+		a.attachToNetwork(server, a.networks[networkID], "")
+	}
+	for _, fw := range req.Firewalls {
+		a.applyFirewall(a.firewalls[fw.Firewall], []schema.FirewallResource{{Type: "server", Server: &schema.FirewallResourceServer{ID: id}}})
+	}
+
+	writeJSON(w, http.StatusCreated, schema.ServerCreateResponse{
+		Server: *server,
+		Action: a.newAction("create_server", "server", id),
+	})
+}
+
+// createImage snapshots a server's disk into a new image. The snapshot is
+// available at once.
+// changeType changes the type of a server that is off. Without
+// upgrade_disk the disk keeps its size, so it can be changed back later.
+func (a *API) changeType(w http.ResponseWriter, r *request, server *schema.Server) {
+	var req schema.ServerActionChangeTypeRequest
+	if !r.decode(w, &req) {
+		return
+	}
+	if server.Status != "off" {
+		writeError(w, http.StatusConflict, "server_not_stopped", "server must be powered off to change its type")
+		return
+	}
+	var serverType *schema.ServerType
+	for i, t := range a.serverTypes {
+		if ref(req.ServerType, t.ID, t.Name) {
+			serverType = &a.serverTypes[i]
+		}
+	}
+	if serverType == nil {
+		writeError(w, http.StatusBadRequest, "invalid_input", "unknown server type")
+		return
+	}
+	if serverType.Disk < server.PrimaryDiskSize {
+		writeError(w, http.StatusBadRequest, "invalid_input", "disk of the server is too large for the server type")
+		return
+	}
+	server.ServerType = *serverType
+	if req.UpgradeDisk {
+		server.PrimaryDiskSize = serverType.Disk
+	}
+	writeJSON(w, http.StatusCreated, schema.ServerActionChangeTypeResponse{Action: a.newAction("change_type", "server", server.ID)})
+}
+
+func (a *API) createImage(w http.ResponseWriter, r *request, server *schema.Server) {
+	var req schema.ServerActionCreateImageRequest
+	if !r.decode(w, &req) {
+		return
+	}
+	if req.Type != nil && *req.Type != "snapshot" {
+		writeError(w, http.StatusBadRequest, "invalid_input", "only snapshots are emulated")
+		return
+	}
+
+	created := time.Now().UTC().Truncate(time.Second)
+	size := float32(server.PrimaryDiskSize) / 10
+	img := schema.Image{
+		ID:           a.newID(),
+		Status:       "available",
+		Type:         "snapshot",
+		ImageSize:    &size,
+		DiskSize:     float32(server.PrimaryDiskSize),
+		Created:      &created,
+		CreatedFrom:  &schema.ImageCreatedFrom{ID: server.ID, Name: server.Name},
+		Architecture: server.ServerType.Architecture,
+		Labels:       map[string]string{},
+	}
+	if server.Image != nil {
+		img.OSFlavor = server.Image.OSFlavor
+		img.OSVersion = server.Image.OSVersion
+	}
+	if req.Description != nil {
+		img.Description = *req.Description
+	}
+	if req.Labels != nil {
+		img.Labels = *req.Labels
+	}
+	a.images = append(a.images, img)
+
+	writeJSON(w, http.StatusCreated, schema.ServerActionCreateImageResponse{
+		Image:  img,
+		Action: a.newAction("create_image", "server", server.ID),
+	})
+}
+
+// ref reports whether a create request's ID-or-name reference points at id
+// or name
+func ref(v interface{}, id int64, name string) bool {
+	switch v := v.(type) {
+	case string:
+		return (name != "" && v == name) || v == strconv.FormatInt(id, 10)
+	case float64:
+		return int64(v) == id
+	}
+	return false
+}
+
+// matchLabels reports whether labels satisfy a label selector. The
+// "key=value", "key!=value", "key" and "!key" forms are supported.
+func matchLabels(labels map[string]string, selector string) bool {
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		switch {
+		case term == "":
+		case strings.Contains(term, "!="):
+			k, v, _ := strings.Cut(term, "!=")
+			if labels[k] == v {
+				return false
+			}
+		case strings.Contains(term, "="):
+			k, v, _ := strings.Cut(term, "=")
+			if got, ok := labels[k]; !ok || got != v {
+				return false
+			}
+		case strings.HasPrefix(term, "!"):
+			if _, ok := labels[term[1:]]; ok {
+				return false
+			}
+		default:
+			if _, ok := labels[term]; !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// serveSSHKeys answers /ssh_keys and /ssh_keys/{id}
+func (a *API) serveSSHKeys(w http.ResponseWriter, r *request) {
+	if len(r.parts) == 1 {
+		switch r.method() {
+		case http.MethodGet:
+			keys := []schema.SSHKey{}
+			for _, k := range a.sshKeys {
+				if name := r.query("name"); name == "" || k.Name == name {
+					keys = append(keys, *k)
+				}
+			}
+			sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+			writeList(w, "ssh_keys", keys, len(keys))
+		case http.MethodPost:
+			var req schema.SSHKeyCreateRequest
+			if !r.decode(w, &req) {
+				return
+			}
+			for _, k := range a.sshKeys {
+				if k.Name == req.Name || k.PublicKey == req.PublicKey {
+					writeError(w, http.StatusConflict, "uniqueness_error", "SSH key with the same name or key already exists")
+					return
+				}
+			}
+			id := a.newID()
+			key := &schema.SSHKey{
+				ID: id, Name: req.Name, PublicKey: req.PublicKey,
+				Fingerprint: fmt.Sprintf("mock:%x", id), Labels: map[string]string{},
+				Created: time.Now().UTC().Truncate(time.Second),
+			}
+			if req.Labels != nil {
+				key.Labels = *req.Labels
+			}
+			a.sshKeys[id] = key
+			writeJSON(w, http.StatusCreated, schema.SSHKeyCreateResponse{SSHKey: *key})
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "unsupported method")
+		}
+		return
+	}
+
+	id, _ := strconv.ParseInt(r.parts[1], 10, 64)
+	key, ok := a.sshKeys[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "SSH key not found")
+		return
+	}
+	switch r.method() {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, schema.SSHKeyGetResponse{SSHKey: *key})
+	case http.MethodPut:
+		var req schema.SSHKeyUpdateRequest
+		if !r.decode(w, &req) {
+			return
+		}
+		if req.Name != "" {
+			key.Name = req.Name
+		}
+		if req.Labels != nil {
+			key.Labels = *req.Labels
+		}
+		writeJSON(w, http.StatusOK, schema.SSHKeyUpdateResponse{SSHKey: *key})
+	case http.MethodDelete:
+		delete(a.sshKeys, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "unsupported method")
+	}
+}
+
+// newAction records an action on a resource that has already finished
+// successfully. The caller holds a.mu.
+func (a *API) newAction(command, resourceType string, resourceID int64) schema.Action {
+	now := time.Now().UTC().Truncate(time.Second)
+	action := &schema.Action{
+		ID:        a.newID(),
+		Status:    "success",
+		Command:   command,
+		Progress:  100,
+		Started:   now,
+		Finished:  &now,
+		Resources: []schema.ActionResourceReference{{ID: resourceID, Type: resourceType}},
+	}
+	a.actions[action.ID] = action
+	return *action
+}
+
+// serveActions answers /actions and /actions/{id}
+func (a *API) serveActions(w http.ResponseWriter, r *request) {
+	if r.method() != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "read-only resource")
+		return
+	}
+	if len(r.parts) > 1 {
+		id, _ := strconv.ParseInt(r.parts[1], 10, 64)
+		action, ok := a.actions[id]
+		if !ok {
+			writeError(w, http.StatusNotFound, "not_found", "action not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, schema.ActionGetResponse{Action: *action})
+		return
+	}
+
+	actions := []schema.Action{}
+	ids := r.r.URL.Query()["id"]
+	for _, action := range a.actions {
+		if len(ids) == 0 || contains(ids, strconv.FormatInt(action.ID, 10)) {
+			actions = append(actions, *action)
+		}
+	}
+	sort.Slice(actions, func(i, j int) bool { return actions[i].ID < actions[j].ID })
+	writeList(w, "actions", actions, len(actions))
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,309 @@
+package hetznermock
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// nameservers are the authoritative nameservers assigned to every zone
+var nameservers = []string{"hydrogen.ns.hetzner.com", "oxygen.ns.hetzner.com", "helium.ns.hetzner.de"}
+
+// Zone is a DNS zone and its record sets
+type Zone struct {
+	ID     int64
+	Name   string
+	TTL    int
+	RRSets []RRSet
+
+	// DNSSECKey is the public key the zone is signed with, empty if it is
+	// not signed
+	DNSSECKey string
+}
+
+// RRSet is a DNS record set as the API represents it
+type RRSet struct {
+	Name    string    `json:"name"`
+	Type    string    `json:"type"`
+	TTL     *int      `json:"ttl"` // nil means the zone default applies
+	Records []RRValue `json:"records"`
+}
+
+// RRValue is a single record value of an RRSet
+type RRValue struct {
+	Value   string `json:"value"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// zoneJSON is a zone in API responses
+type zoneJSON struct {
+	ID                       int64  `json:"id"`
+	Name                     string `json:"name"`
+	TTL                      int    `json:"ttl"`
+	Mode                     string `json:"mode"`
+	Status                   string `json:"status"`
+	AuthoritativeNameservers struct {
+		Assigned []string `json:"assigned"`
+	} `json:"authoritative_nameservers"`
+	DNSSEC dnssecJSON `json:"dnssec"`
+}
+
+// dnssecJSON is the DNSSEC state of a zone in API responses
+type dnssecJSON struct {
+	Enabled bool         `json:"enabled"`
+	DNSKEYs []dnskeyJSON `json:"dnskeys"`
+}
+
+type dnskeyJSON struct {
+	Flags     int    `json:"flags"`
+	Protocol  int    `json:"protocol"`
+	Algorithm int    `json:"algorithm"`
+	PublicKey string `json:"public_key"`
+}
+
+func (z *Zone) json() zoneJSON {
+	j := zoneJSON{ID: z.ID, Name: z.Name, TTL: z.TTL, Mode: "primary", Status: "ok"}
+	j.AuthoritativeNameservers.Assigned = nameservers
+	j.DNSSEC.DNSKEYs = []dnskeyJSON{}
+	if z.DNSSECKey != "" {
+		j.DNSSEC.Enabled = true
+		// A key signing key with ECDSA P-256 (algorithm 13)
+		j.DNSSEC.DNSKEYs = append(j.DNSSEC.DNSKEYs, dnskeyJSON{Flags: 257, Protocol: 3, Algorithm: 13, PublicKey: z.DNSSECKey})
+	}
+	return j
+}
+
+// AddZone creates a zone with the default TTL and returns its ID
+func (a *API) AddZone(name string) int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	zone := &Zone{ID: a.newID(), Name: name, TTL: 3600}
+	a.zones[zone.ID] = zone
+	return zone.ID
+}
+
+// RRSets returns the record sets of a zone, or nil if it does not exist
+func (a *API) RRSets(zoneName string) []RRSet {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, zone := range a.zones {
+		if zone.Name == zoneName {
+			return append([]RRSet{}, zone.RRSets...)
+		}
+	}
+	return nil
+}
+
+// findZone returns a zone by ID or name. The caller holds a.mu.
+func (a *API) findZone(idOrName string) *Zone {
+	for _, zone := range a.zones {
+		if strconv.FormatInt(zone.ID, 10) == idOrName || zone.Name == idOrName {
+			return zone
+		}
+	}
+	return nil
+}
+
+// serveZones answers /zones and everything below it
+func (a *API) serveZones(w http.ResponseWriter, r *request) {
+	if len(r.parts) == 1 {
+		switch r.method() {
+		case http.MethodGet:
+			zones := []zoneJSON{}
+			for _, zone := range a.zones {
+				if name := r.query("name"); name == "" || zone.Name == name {
+					zones = append(zones, zone.json())
+				}
+			}
+			sort.Slice(zones, func(i, j int) bool { return zones[i].Name < zones[j].Name })
+			writeList(w, "zones", zones, len(zones))
+		case http.MethodPost:
+			a.createZone(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "unsupported method")
+		}
+		return
+	}
+
+	zone := a.findZone(r.parts[1])
+	if zone == nil {
+		writeError(w, http.StatusNotFound, "not_found", "zone not found")
+		return
+	}
+	if len(r.parts) > 2 && r.parts[2] == "rrsets" {
+		a.serveRRSets(w, r, zone)
+		return
+	}
+	if len(r.parts) == 4 && r.parts[2] == "actions" && r.method() == http.MethodPost {
+		a.zoneAction(w, zone, r.parts[3])
+		return
+	}
+	if len(r.parts) > 2 {
+		writeError(w, http.StatusNotFound, "not_found", "no such endpoint")
+		return
+	}
+	switch r.method() {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"zone": zone.json()})
+	case http.MethodDelete:
+		delete(a.zones, zone.ID)
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"action": a.newAction("delete_zone", "zone", zone.ID)})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "unsupported method")
+	}
+}
+
+// zoneAction runs the DNSSEC actions of a zone
+func (a *API) zoneAction(w http.ResponseWriter, zone *Zone, action string) {
+	switch action {
+	case "enable_dnssec":
+		if zone.DNSSECKey != "" {
+			writeError(w, http.StatusConflict, "conflict", "dnssec is already enabled")
+			return
+		}
+		key, err := ecdh.P256().GenerateKey(rand.Reader)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+		// DNSKEY records hold the uncompressed point without its 0x04 prefix
+		zone.DNSSECKey = base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()[1:])
+	case "disable_dnssec":
+		if zone.DNSSECKey == "" {
+			writeError(w, http.StatusConflict, "conflict", "dnssec is not enabled")
+			return
+		}
+		zone.DNSSECKey = ""
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "no such action")
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"action": a.newAction(action, "zone", zone.ID)})
+}
+
+func (a *API) createZone(w http.ResponseWriter, r *request) {
+	var req struct {
+		Name string `json:"name"`
+		TTL  int    `json:"ttl"`
+		Mode string `json:"mode"`
+	}
+	if !r.decode(w, &req) {
+		return
+	}
+	if req.Name == "" || req.Mode == "" {
+		writeError(w, http.StatusBadRequest, "invalid_input", "name and mode are required")
+		return
+	}
+	if a.findZone(req.Name) != nil {
+		writeError(w, http.StatusConflict, "uniqueness_error", "zone already exists")
+		return
+	}
+	zone := &Zone{ID: a.newID(), Name: req.Name, TTL: req.TTL}
+	if zone.TTL == 0 {
+		zone.TTL = 3600
+	}
+	a.zones[zone.ID] = zone
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"zone":   zone.json(),
+		"action": a.newAction("create_zone", "zone", zone.ID),
+	})
+}
+
+// serveRRSets answers /zones/{id}/rrsets and the record sets below it,
+// which are addressed as {name}/{type}
+func (a *API) serveRRSets(w http.ResponseWriter, r *request, zone *Zone) {
+	rest := r.parts[3:]
+	if len(rest) == 0 {
+		switch r.method() {
+		case http.MethodGet:
+			writeList(w, "rrsets", zone.RRSets, len(zone.RRSets))
+		case http.MethodPost:
+			var rrset RRSet
+			if !r.decode(w, &rrset) {
+				return
+			}
+			rrset.Type = strings.ToUpper(rrset.Type)
+			if rrset.Name == "" || rrset.Type == "" || len(rrset.Records) == 0 {
+				writeError(w, http.StatusBadRequest, "invalid_input", "name, type and records are required")
+				return
+			}
+			if zone.rrset(rrset.Name, rrset.Type) >= 0 {
+				writeError(w, http.StatusConflict, "uniqueness_error", "rrset already exists")
+				return
+			}
+			zone.RRSets = append(zone.RRSets, rrset)
+			writeJSON(w, http.StatusCreated, map[string]interface{}{
+				"rrset":  rrset,
+				"action": a.newAction("create_rrset", "zone", zone.ID),
+			})
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "unsupported method")
+		}
+		return
+	}
+
+	if len(rest) < 2 {
+		writeError(w, http.StatusNotFound, "not_found", "rrset not found")
+		return
+	}
+	i := zone.rrset(rest[0], strings.ToUpper(rest[1]))
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "not_found", "rrset not found")
+		return
+	}
+	rrset := &zone.RRSets[i]
+
+	if len(rest) == 4 && rest[2] == "actions" && r.method() == http.MethodPost {
+		switch rest[3] {
+		case "change_ttl":
+			var req struct {
+				TTL *int `json:"ttl"`
+			}
+			if !r.decode(w, &req) {
+				return
+			}
+			rrset.TTL = req.TTL
+		case "set_records":
+			var req struct {
+				Records []RRValue `json:"records"`
+			}
+			if !r.decode(w, &req) {
+				return
+			}
+			rrset.Records = req.Records
+		default:
+			writeError(w, http.StatusNotFound, "not_found", "no such action")
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"action": a.newAction(rest[3], "zone", zone.ID)})
+		return
+	}
+	if len(rest) != 2 {
+		writeError(w, http.StatusNotFound, "not_found", "no such endpoint")
+		return
+	}
+
+	switch r.method() {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"rrset": *rrset})
+	case http.MethodDelete:
+		zone.RRSets = append(zone.RRSets[:i], zone.RRSets[i+1:]...)
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"action": a.newAction("delete_rrset", "zone", zone.ID)})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "unsupported method")
+	}
+}
+
+// rrset returns the index of a record set, or -1
+func (z *Zone) rrset(name, recordType string) int {
+	for i, rrset := range z.RRSets {
+		if rrset.Name == name && rrset.Type == recordType {
+			return i
+		}
+	}
+	return -1
+}
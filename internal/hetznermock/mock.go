@@ -0,0 +1,244 @@
+// Package hetznermock emulates the parts of the Hetzner Cloud API morpheus
+// uses (servers, snapshots, SSH keys, networks, firewalls, primary IPs,
+// actions, DNS zones and RRSets) in memory, so provider code can be exercised against real HTTP requests and
+// responses without an API token.
+package hetznermock
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
+)
+
+// API is an in-memory Hetzner Cloud API. It is an http.Handler that accepts
+// paths with or without the /v1 prefix.
+type API struct {
+	// Token, if set, is the only bearer token accepted
+	Token string
+
+	mu          sync.Mutex
+	nextID      int64
+	serverTypes []schema.ServerType
+	images      []schema.Image
+	locations   []schema.Location
+	servers     map[int64]*schema.Server
+	sshKeys     map[int64]*schema.SSHKey
+	networks    map[int64]*schema.Network
+	firewalls   map[int64]*schema.Firewall
+	primaryIPs  map[int64]*schema.PrimaryIP
+	actions     map[int64]*schema.Action
+	zones       map[int64]*Zone
+	requests    []Request
+	failures    []failure
+	chaos       Chaos
+	rand        *rand.Rand // Set while chaos is on
+}
+
+// Request is a request the API received
+type Request struct {
+	Method string
+	Path   string // Without the /v1 prefix, with the query
+	Body   []byte
+}
+
+// failure is an error response injected with FailNext
+type failure struct {
+	method, prefix string
+	status         int
+	code           string
+}
+
+// NewAPI returns an API with no servers, keys, networks or zones and a
+// catalog of server types, images, locations and datacenters
+func NewAPI() *API {
+	a := &API{
+		nextID:     1000,
+		servers:    map[int64]*schema.Server{},
+		sshKeys:    map[int64]*schema.SSHKey{},
+		networks:   map[int64]*schema.Network{},
+		firewalls:  map[int64]*schema.Firewall{},
+		primaryIPs: map[int64]*schema.PrimaryIP{},
+		actions:    map[int64]*schema.Action{},
+		zones:      map[int64]*Zone{},
+	}
+	a.seedCatalog()
+	return a
+}
+
+// Server is an API listening on a local httptest server
+type Server struct {
+	*API
+	URL string // Endpoint to configure clients with, ending in /v1
+
+	server *httptest.Server
+}
+
+// NewServer starts an API on a local port
+func NewServer() *Server {
+	api := NewAPI()
+	s := httptest.NewServer(api)
+	return &Server{API: api, URL: s.URL + "/v1", server: s}
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Requests returns the requests received so far
+func (a *API) Requests() []Request {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Request(nil), a.requests...)
+}
+
+// FailNext makes the next request with method whose path starts with
+// pathPrefix fail with status and the error code
+func (a *API) FailNext(method, pathPrefix string, status int, code string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.failures = append(a.failures, failure{method: method, prefix: pathPrefix, status: status, code: code})
+}
+
+// ServeHTTP implements http.Handler
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/v1")
+	body, _ := io.ReadAll(r.Body)
+
+	a.mu.Lock()
+	delay, fault := a.rollChaos(r.Method)
+	a.mu.Unlock()
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	logged := path
+	if r.URL.RawQuery != "" {
+		logged += "?" + r.URL.RawQuery
+	}
+	a.requests = append(a.requests, Request{Method: r.Method, Path: logged, Body: body})
+
+	if a.Token != "" && r.Header.Get("Authorization") != "Bearer "+a.Token {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "unable to authenticate")
+		return
+	}
+	for i, f := range a.failures {
+		if f.method == r.Method && strings.HasPrefix(path, f.prefix) {
+			a.failures = append(a.failures[:i], a.failures[i+1:]...)
+			writeError(w, f.status, f.code, "injected failure")
+			return
+		}
+	}
+	if fault == faultRateLimit {
+		writeError(w, http.StatusTooManyRequests, "rate_limit_exceeded", "chaos: rate limit exceeded")
+		return
+	}
+
+	req := &request{r: r, parts: strings.Split(strings.Trim(path, "/"), "/"), body: body}
+	if fault == faultFailure {
+		loseResponse(w, func(w http.ResponseWriter) { a.route(w, req) })
+		return
+	}
+	a.route(w, req)
+}
+
+// route dispatches a request to the handler of its resource
+func (a *API) route(w http.ResponseWriter, req *request) {
+	switch req.parts[0] {
+	case "server_types", "locations", "datacenters":
+		a.serveCatalog(w, req)
+	case "images":
+		a.serveImages(w, req)
+	case "pricing":
+		a.servePricing(w, req)
+	case "servers":
+		a.serveServers(w, req)
+	case "ssh_keys":
+		a.serveSSHKeys(w, req)
+	case "networks":
+		a.serveNetworks(w, req)
+	case "firewalls":
+		a.serveFirewalls(w, req)
+	case "primary_ips":
+		a.servePrimaryIPs(w, req)
+	case "actions":
+		a.serveActions(w, req)
+	case "zones":
+		a.serveZones(w, req)
+	case "volumes", "floating_ips", "load_balancers", "placement_groups":
+		// Not emulated, but listing them is part of teardown and rollback
+		if len(req.parts) == 1 && req.method() == http.MethodGet {
+			writeList(w, req.parts[0], []struct{}{}, 0)
+			return
+		}
+		writeError(w, http.StatusNotFound, "not_found", req.parts[0]+" are not emulated")
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "no such endpoint")
+	}
+}
+
+// request is a request being routed
+type request struct {
+	r     *http.Request
+	parts []string // Path segments
+	body  []byte
+}
+
+func (r *request) method() string { return r.r.Method }
+
+func (r *request) query(key string) string { return r.r.URL.Query().Get(key) }
+
+// decode unmarshals the request body into v, answering with an
+// invalid_input error if it is malformed
+func (r *request) decode(w http.ResponseWriter, v interface{}) bool {
+	if err := json.Unmarshal(r.body, v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_input", "invalid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// newID returns the next resource ID. The caller holds a.mu.
+func (a *API) newID() int64 {
+	a.nextID++
+	return a.nextID
+}
+
+// writeJSON writes v as the response body
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(v)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// writeList writes a list response with single-page pagination metadata
+func writeList(w http.ResponseWriter, key string, items interface{}, count int) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		key: items,
+		"meta": schema.Meta{Pagination: &schema.MetaPagination{
+			Page: 1, PerPage: 50, LastPage: 1, TotalEntries: count,
+		}},
+	})
+}
+
+// writeError writes an error in the API's error format
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, schema.ErrorResponse{Error: schema.Error{Code: code, Message: message}})
+}
@@ -0,0 +1,116 @@
+package hetznermock
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func do(t *testing.T, s *Server, method, path, token, body string) (*http.Response, map[string]interface{}) {
+	t.Helper()
+	req, _ := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp, result
+}
+
+func TestToken(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Token = "secret"
+
+	resp, result := do(t, s, "GET", "/servers", "wrong", "")
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("GET with wrong token = %d, want 401", resp.StatusCode)
+	}
+	if code := result["error"].(map[string]interface{})["code"]; code != "unauthorized" {
+		t.Errorf("error code = %v, want unauthorized", code)
+	}
+	if resp, _ := do(t, s, "GET", "/servers", "secret", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("GET with token = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestFailNext(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.FailNext("POST", "/zones", http.StatusTooManyRequests, "rate_limit_exceeded")
+
+	// Other methods are not affected
+	if resp, _ := do(t, s, "GET", "/zones", "", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /zones = %d, want 200", resp.StatusCode)
+	}
+	if resp, _ := do(t, s, "POST", "/zones", "", `{"name": "example.com", "mode": "primary"}`); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("first POST /zones = %d, want 429", resp.StatusCode)
+	}
+	if resp, _ := do(t, s, "POST", "/zones", "", `{"name": "example.com", "mode": "primary"}`); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("second POST /zones = %d, want 201", resp.StatusCode)
+	}
+
+	reqs := s.Requests()
+	if len(reqs) != 3 || reqs[2].Method != "POST" || reqs[2].Path != "/zones" || !strings.Contains(string(reqs[2].Body), "example.com") {
+		t.Errorf("Requests() = %+v", reqs)
+	}
+}
+
+func TestMatchLabels(t *testing.T) {
+	labels := map[string]string{"forest_id": "f1", "managed-by": "morpheus"}
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"", true},
+		{"forest_id=f1", true},
+		{"forest_id=f2", false},
+		{"forest_id=f1,managed-by=morpheus", true},
+		{"forest_id!=f2", true},
+		{"forest_id!=f1", false},
+		{"managed-by", true},
+		{"role", false},
+		{"!role", true},
+		{"!forest_id", false},
+	}
+	for _, tt := range tests {
+		if got := matchLabels(labels, tt.selector); got != tt.want {
+			t.Errorf("matchLabels(%q) = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestRRSets(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.AddZone("example.com")
+
+	rrset := `{"name": "www", "type": "a", "ttl": 300, "records": [{"value": "203.0.113.1"}]}`
+	if resp, _ := do(t, s, "POST", "/zones/example.com/rrsets", "", rrset); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST rrset = %d, want 201", resp.StatusCode)
+	}
+	if resp, _ := do(t, s, "POST", "/zones/example.com/rrsets", "", rrset); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("POST duplicate rrset = %d, want 409", resp.StatusCode)
+	}
+	if resp, _ := do(t, s, "POST", "/zones/example.com/rrsets/www/A/actions/set_records", "", `{"records": [{"value": "203.0.113.2"}]}`); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("set_records = %d, want 201", resp.StatusCode)
+	}
+
+	sets := s.RRSets("example.com")
+	if len(sets) != 1 || sets[0].Type != "A" || sets[0].Records[0].Value != "203.0.113.2" {
+		t.Fatalf("RRSets() = %+v", sets)
+	}
+
+	if resp, _ := do(t, s, "DELETE", "/zones/example.com/rrsets/www/A", "", ""); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("DELETE rrset = %d, want 201", resp.StatusCode)
+	}
+	if resp, _ := do(t, s, "GET", "/zones/example.com/rrsets/www/A", "", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET deleted rrset = %d, want 404", resp.StatusCode)
+	}
+}
@@ -0,0 +1,750 @@
+package hetznermock
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
+)
+
+// maxServerNetworks is how many networks a server can be attached to
+const maxServerNetworks = 3
+
+// datacenters returns the datacenter of each location. Servers are placed
+// in the one datacenter of their location.
+func (a *API) datacenters() []schema.Datacenter {
+	dcs := make([]schema.Datacenter, len(a.locations))
+	for i, loc := range a.locations {
+		dcs[i] = schema.Datacenter{ID: loc.ID, Name: loc.Name + "-dc1", Description: loc.City + " DC 1", Location: loc}
+	}
+	return dcs
+}
+
+// Networks returns the networks that currently exist, by ID
+func (a *API) Networks() []schema.Network {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.networkList("", "")
+}
+
+// networkList returns the networks matching a name and a label selector,
+// by ID. The caller holds a.mu.
+func (a *API) networkList(name, selector string) []schema.Network {
+	networks := []schema.Network{}
+	for _, n := range a.networks {
+		if (name == "" || n.Name == name) && matchLabels(n.Labels, selector) {
+			networks = append(networks, *n)
+		}
+	}
+	sort.Slice(networks, func(i, j int) bool { return networks[i].ID < networks[j].ID })
+	return networks
+}
+
+// serveNetworks answers /networks, /networks/{id} and the network actions
+// for subnets and routes
+func (a *API) serveNetworks(w http.ResponseWriter, r *request) {
+	if len(r.parts) == 1 {
+		switch r.method() {
+		case http.MethodGet:
+			networks := a.networkList(r.query("name"), r.query("label_selector"))
+			writeList(w, "networks", networks, len(networks))
+		case http.MethodPost:
+			a.createNetwork(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "unsupported method")
+		}
+		return
+	}
+
+	id, _ := strconv.ParseInt(r.parts[1], 10, 64)
+	network, ok := a.networks[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "network not found")
+		return
+	}
+	if len(r.parts) == 4 && r.parts[2] == "actions" && r.method() == http.MethodPost {
+		a.networkAction(w, r, network)
+		return
+	}
+	switch r.method() {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, schema.NetworkGetResponse{Network: *network})
+	case http.MethodPut:
+		var req schema.NetworkUpdateRequest
+		if !r.decode(w, &req) {
+			return
+		}
+		if req.Name != "" {
+			network.Name = req.Name
+		}
+		if req.Labels != nil {
+			network.Labels = *req.Labels
+		}
+		writeJSON(w, http.StatusOK, schema.NetworkUpdateResponse{Network: *network})
+	case http.MethodDelete:
+		// Servers are detached from a network deleted under them
+		for _, serverID := range network.Servers {
+			if s, ok := a.servers[serverID]; ok {
+				s.PrivateNet = removePrivateNet(s.PrivateNet, id)
+			}
+		}
+		delete(a.networks, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "unsupported method")
+	}
+}
+
+func (a *API) createNetwork(w http.ResponseWriter, r *request) {
+	var req schema.NetworkCreateRequest
+	if !r.decode(w, &req) {
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "invalid_input", "name is required")
+		return
+	}
+	_, ipRange, err := net.ParseCIDR(req.IPRange)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_input", "invalid ip_range")
+		return
+	}
+	for _, n := range a.networks {
+		if n.Name == req.Name {
+			writeError(w, http.StatusConflict, "uniqueness_error", "network name is already used")
+			return
+		}
+	}
+
+	network := &schema.Network{
+		ID:      a.newID(),
+		Name:    req.Name,
+		Created: time.Now().UTC().Truncate(time.Second),
+		IPRange: ipRange.String(),
+		Subnets: []schema.NetworkSubnet{},
+		Routes:  []schema.NetworkRoute{},
+		Servers: []int64{},
+		Labels:  map[string]string{},
+	}
+	for _, subnet := range req.Subnets {
+		if msg := addSubnet(network, subnet); msg != "" {
+			writeError(w, http.StatusBadRequest, "invalid_input", msg)
+			return
+		}
+	}
+	for _, route := range req.Routes {
+		if msg := addRoute(network, route); msg != "" {
+			writeError(w, http.StatusBadRequest, "invalid_input", msg)
+			return
+		}
+	}
+	if req.Labels != nil {
+		network.Labels = *req.Labels
+	}
+	a.networks[network.ID] = network
+	writeJSON(w, http.StatusCreated, schema.NetworkCreateResponse{Network: *network})
+}
+
+// networkAction runs a /networks/{id}/actions/{action} request
+func (a *API) networkAction(w http.ResponseWriter, r *request, network *schema.Network) {
+	var msg string
+	switch r.parts[3] {
+	case "add_subnet":
+		var req schema.NetworkActionAddSubnetRequest
+		if !r.decode(w, &req) {
+			return
+		}
+		msg = addSubnet(network, schema.NetworkSubnet{Type: req.Type, IPRange: req.IPRange, NetworkZone: req.NetworkZone})
+	case "delete_subnet":
+		var req schema.NetworkActionDeleteSubnetRequest
+		if !r.decode(w, &req) {
+			return
+		}
+		msg = "subnet not found"
+		for i, s := range network.Subnets {
+			if s.IPRange == req.IPRange {
+				network.Subnets = append(network.Subnets[:i], network.Subnets[i+1:]...)
+				msg = ""
+				break
+			}
+		}
+	case "add_route":
+		var req schema.NetworkActionAddRouteRequest
+		if !r.decode(w, &req) {
+			return
+		}
+		msg = addRoute(network, schema.NetworkRoute{Destination: req.Destination, Gateway: req.Gateway})
+	case "delete_route":
+		var req schema.NetworkActionDeleteRouteRequest
+		if !r.decode(w, &req) {
+			return
+		}
+		msg = "route not found"
+		for i, route := range network.Routes {
+			if route.Destination == req.Destination && route.Gateway == req.Gateway {
+				network.Routes = append(network.Routes[:i], network.Routes[i+1:]...)
+				msg = ""
+				break
+			}
+		}
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "network action "+r.parts[3]+" is not emulated")
+		return
+	}
+	if msg != "" {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_input", msg)
+		return
+	}
+	writeJSON(w, http.StatusCreated, schema.NetworkActionAddRouteResponse{Action: a.newAction(r.parts[3], "network", network.ID)})
+}
+
+// addSubnet adds a subnet to a network, returning why it cannot be added
+// if it is invalid. Like for the API's cloud subnets, its gateway is the
+// first address of the network.
+func addSubnet(network *schema.Network, subnet schema.NetworkSubnet) string {
+	_, netRange, _ := net.ParseCIDR(network.IPRange)
+	ip, ipRange, err := net.ParseCIDR(subnet.IPRange)
+	if err != nil || !netRange.Contains(ip) {
+		return "subnet ip_range must be within the network's ip_range"
+	}
+	for _, s := range network.Subnets {
+		if _, other, _ := net.ParseCIDR(s.IPRange); other.Contains(ip) || ipRange.Contains(other.IP) {
+			return "subnet overlaps with another subnet"
+		}
+	}
+	subnet.IPRange = ipRange.String()
+	subnet.Gateway = hostIP(netRange, 1).String()
+	network.Subnets = append(network.Subnets, subnet)
+	return ""
+}
+
+// addRoute adds a route to a network, returning why it cannot be added if
+// it is invalid. Like the API, the gateway must be in the network and the
+// destination must not be.
+func addRoute(network *schema.Network, route schema.NetworkRoute) string {
+	_, netRange, _ := net.ParseCIDR(network.IPRange)
+	_, dest, err := net.ParseCIDR(route.Destination)
+	if err != nil {
+		return "invalid destination"
+	}
+	gateway := net.ParseIP(route.Gateway)
+	if gateway == nil || !netRange.Contains(gateway) {
+		return "gateway must be within the network's ip_range"
+	}
+	if netRange.Contains(dest.IP) || dest.Contains(netRange.IP) {
+		return "destination must not overlap with the network's ip_range"
+	}
+	for _, r := range network.Routes {
+		if r.Destination == dest.String() {
+			return "route with the same destination already exists"
+		}
+	}
+	network.Routes = append(network.Routes, schema.NetworkRoute{Destination: dest.String(), Gateway: gateway.String()})
+	return ""
+}
+
+// hostIP returns the n-th address of a range
+func hostIP(ipRange *net.IPNet, n int) net.IP {
+	ip := make(net.IP, len(ipRange.IP.To4()))
+	copy(ip, ipRange.IP.To4())
+	for i := len(ip) - 1; i >= 0 && n > 0; i-- {
+		sum := int(ip[i]) + n
+		ip[i] = byte(sum)
+		n = sum >> 8
+	}
+	return ip
+}
+
+// attachToNetwork attaches a server to a network with ip, or the next free
+// address of the network's first subnet if ip is empty. It returns why the
+// server cannot be attached, if it cannot. The caller holds a.mu.
+func (a *API) attachToNetwork(server *schema.Server, network *schema.Network, ip string) string {
+	if len(server.PrivateNet) >= maxServerNetworks {
+		return fmt.Sprintf("server is attached to %d networks already", maxServerNetworks)
+	}
+	used := map[string]bool{}
+	for _, pn := range server.PrivateNet {
+		if pn.Network == network.ID {
+			return "server is already attached to the network"
+		}
+	}
+	for _, serverID := range network.Servers {
+		for _, pn := range a.servers[serverID].PrivateNet {
+			if pn.Network == network.ID {
+				used[pn.IP] = true
+			}
+		}
+	}
+	if len(network.Subnets) == 0 {
+		return "network has no subnet"
+	}
+
+	if ip == "" {
+		_, subnet, _ := net.ParseCIDR(network.Subnets[0].IPRange)
+		for n := 2; ; n++ {
+			candidate := hostIP(subnet, n)
+			if !subnet.Contains(candidate) {
+				return "no free IP in the network's subnet"
+			}
+			if !used[candidate.String()] {
+				ip = candidate.String()
+				break
+			}
+		}
+	} else {
+		parsed := net.ParseIP(ip)
+		inSubnet := false
+		for _, s := range network.Subnets {
+			_, subnet, _ := net.ParseCIDR(s.IPRange)
+			inSubnet = inSubnet || (parsed != nil && subnet.Contains(parsed) && !parsed.Equal(net.ParseIP(s.Gateway)))
+		}
+		if !inSubnet {
+			return "ip is not in a subnet of the network"
+		}
+		if used[parsed.String()] {
+			return "ip is already in use"
+		}
+		ip = parsed.String()
+	}
+
+	server.PrivateNet = append(server.PrivateNet, schema.ServerPrivateNet{
+		Network:    network.ID,
+		IP:         ip,
+		AliasIPs:   []string{},
+		MACAddress: fmt.Sprintf("86:00:00:%02x:%02x:%02x", byte(server.ID>>16), byte(server.ID>>8), byte(server.ID)),
+	})
+	network.Servers = append(network.Servers, server.ID)
+	return ""
+}
+
+// detachFromNetwork detaches a server from a network. The caller holds
+// a.mu.
+func (a *API) detachFromNetwork(server *schema.Server, networkID int64) {
+	server.PrivateNet = removePrivateNet(server.PrivateNet, networkID)
+	if network, ok := a.networks[networkID]; ok {
+		for i, id := range network.Servers {
+			if id == server.ID {
+				network.Servers = append(network.Servers[:i], network.Servers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// removePrivateNet returns nets without the attachment to a network
+func removePrivateNet(nets []schema.ServerPrivateNet, networkID int64) []schema.ServerPrivateNet {
+	kept := []schema.ServerPrivateNet{}
+	for _, pn := range nets {
+		if pn.Network != networkID {
+			kept = append(kept, pn)
+		}
+	}
+	return kept
+}
+
+// Firewalls returns the firewalls that currently exist, by ID
+func (a *API) Firewalls() []schema.Firewall {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.firewallList("", "")
+}
+
+// firewallList returns the firewalls matching a name and a label selector,
+// by ID. The caller holds a.mu.
+func (a *API) firewallList(name, selector string) []schema.Firewall {
+	firewalls := []schema.Firewall{}
+	for _, fw := range a.firewalls {
+		if (name == "" || fw.Name == name) && matchLabels(fw.Labels, selector) {
+			firewalls = append(firewalls, *fw)
+		}
+	}
+	sort.Slice(firewalls, func(i, j int) bool { return firewalls[i].ID < firewalls[j].ID })
+	return firewalls
+}
+
+// serveFirewalls answers /firewalls, /firewalls/{id} and the firewall
+// actions for rules and resources. Only servers can be resources.
+func (a *API) serveFirewalls(w http.ResponseWriter, r *request) {
+	if len(r.parts) == 1 {
+		switch r.method() {
+		case http.MethodGet:
+			firewalls := a.firewallList(r.query("name"), r.query("label_selector"))
+			writeList(w, "firewalls", firewalls, len(firewalls))
+		case http.MethodPost:
+			var req schema.FirewallCreateRequest
+			if !r.decode(w, &req) {
+				return
+			}
+			if req.Name == "" {
+				writeError(w, http.StatusBadRequest, "invalid_input", "name is required")
+				return
+			}
+			for _, fw := range a.firewalls {
+				if fw.Name == req.Name {
+					writeError(w, http.StatusConflict, "uniqueness_error", "firewall name is already used")
+					return
+				}
+			}
+			fw := &schema.Firewall{
+				ID:        a.newID(),
+				Name:      req.Name,
+				Labels:    map[string]string{},
+				Created:   time.Now().UTC().Truncate(time.Second),
+				Rules:     req.Rules,
+				AppliedTo: []schema.FirewallResource{},
+			}
+			if fw.Rules == nil {
+				fw.Rules = []schema.FirewallRule{}
+			}
+			if req.Labels != nil {
+				fw.Labels = *req.Labels
+			}
+			a.firewalls[fw.ID] = fw
+			actions := []schema.Action{}
+			if len(req.ApplyTo) > 0 {
+				if msg := a.applyFirewall(fw, req.ApplyTo); msg != "" {
+					delete(a.firewalls, fw.ID)
+					writeError(w, http.StatusBadRequest, "invalid_input", msg)
+					return
+				}
+				actions = append(actions, a.newAction("apply_firewall", "firewall", fw.ID))
+			}
+			writeJSON(w, http.StatusCreated, schema.FirewallCreateResponse{Firewall: *fw, Actions: actions})
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "unsupported method")
+		}
+		return
+	}
+
+	id, _ := strconv.ParseInt(r.parts[1], 10, 64)
+	fw, ok := a.firewalls[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "firewall not found")
+		return
+	}
+	if len(r.parts) == 4 && r.parts[2] == "actions" && r.method() == http.MethodPost {
+		switch r.parts[3] {
+		case "set_rules":
+			var req schema.FirewallActionSetRulesRequest
+			if !r.decode(w, &req) {
+				return
+			}
+			fw.Rules = req.Rules
+			if fw.Rules == nil {
+				fw.Rules = []schema.FirewallRule{}
+			}
+			writeJSON(w, http.StatusCreated, schema.FirewallActionSetRulesResponse{Actions: []schema.Action{a.newAction("set_firewall_rules", "firewall", fw.ID)}})
+		case "apply_to_resources":
+			var req schema.FirewallActionApplyToResourcesRequest
+			if !r.decode(w, &req) {
+				return
+			}
+			if msg := a.applyFirewall(fw, req.ApplyTo); msg != "" {
+				writeError(w, http.StatusUnprocessableEntity, "invalid_input", msg)
+				return
+			}
+			writeJSON(w, http.StatusCreated, schema.FirewallActionApplyToResourcesResponse{Actions: []schema.Action{a.newAction("apply_firewall", "firewall", fw.ID)}})
+		case "remove_from_resources":
+			var req schema.FirewallActionRemoveFromResourcesRequest
+			if !r.decode(w, &req) {
+				return
+			}
+			for _, res := range req.RemoveFrom {
+				if res.Server != nil {
+					a.removeFirewall(fw, res.Server.ID)
+				}
+			}
+			writeJSON(w, http.StatusCreated, schema.FirewallActionRemoveFromResourcesResponse{Actions: []schema.Action{a.newAction("remove_firewall", "firewall", fw.ID)}})
+		default:
+			writeError(w, http.StatusNotFound, "not_found", "firewall action "+r.parts[3]+" is not emulated")
+		}
+		return
+	}
+	switch r.method() {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, schema.FirewallGetResponse{Firewall: *fw})
+	case http.MethodPut:
+		var req schema.FirewallUpdateRequest
+		if !r.decode(w, &req) {
+			return
+		}
+		if req.Name != nil {
+			fw.Name = *req.Name
+		}
+		if req.Labels != nil {
+			fw.Labels = *req.Labels
+		}
+		writeJSON(w, http.StatusOK, schema.FirewallUpdateResponse{Firewall: *fw})
+	case http.MethodDelete:
+		if len(fw.AppliedTo) > 0 {
+			writeError(w, http.StatusLocked, "resource_in_use", "firewall is still applied to resources")
+			return
+		}
+		delete(a.firewalls, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "unsupported method")
+	}
+}
+
+// applyFirewall applies a firewall to servers, returning why it cannot be
+// if a resource is invalid. The caller holds a.mu.
+func (a *API) applyFirewall(fw *schema.Firewall, resources []schema.FirewallResource) string {
+	for _, res := range resources {
+		if res.Type != "server" || res.Server == nil {
+			return "only servers are emulated as firewall resources"
+		}
+		if _, ok := a.servers[res.Server.ID]; !ok {
+			return fmt.Sprintf("unknown server %d", res.Server.ID)
+		}
+	}
+	for _, res := range resources {
+		server := a.servers[res.Server.ID]
+		applied := false
+		for _, f := range server.PublicNet.Firewalls {
+			applied = applied || f.ID == fw.ID
+		}
+		if applied {
+			continue
+		}
+		server.PublicNet.Firewalls = append(server.PublicNet.Firewalls, schema.ServerFirewall{ID: fw.ID, Status: "applied"})
+		fw.AppliedTo = append(fw.AppliedTo, schema.FirewallResource{Type: "server", Server: &schema.FirewallResourceServer{ID: server.ID}})
+	}
+	return ""
+}
+
+// removeFirewall removes a firewall from a server. The caller holds a.mu.
+func (a *API) removeFirewall(fw *schema.Firewall, serverID int64) {
+	for i, res := range fw.AppliedTo {
+		if res.Server != nil && res.Server.ID == serverID {
+			fw.AppliedTo = append(fw.AppliedTo[:i], fw.AppliedTo[i+1:]...)
+			break
+		}
+	}
+	if server, ok := a.servers[serverID]; ok {
+		for i, f := range server.PublicNet.Firewalls {
+			if f.ID == fw.ID {
+				server.PublicNet.Firewalls = append(server.PublicNet.Firewalls[:i], server.PublicNet.Firewalls[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// PrimaryIPs returns the primary IPs that currently exist, by ID
+func (a *API) PrimaryIPs() []schema.PrimaryIP {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.primaryIPList("", "")
+}
+
+// primaryIPList returns the primary IPs matching a name and a label
+// selector, by ID. The caller holds a.mu.
+func (a *API) primaryIPList(name, selector string) []schema.PrimaryIP {
+	ips := []schema.PrimaryIP{}
+	for _, ip := range a.primaryIPs {
+		if (name == "" || ip.Name == name) && matchLabels(ip.Labels, selector) {
+			ips = append(ips, *ip)
+		}
+	}
+	sort.Slice(ips, func(i, j int) bool { return ips[i].ID < ips[j].ID })
+	return ips
+}
+
+// primaryIPCreateRequest is the body of a request creating a primary IP
+type primaryIPCreateRequest struct {
+	AssigneeID   *int64            `json:"assignee_id"`
+	AssigneeType string            `json:"assignee_type"`
+	AutoDelete   *bool             `json:"auto_delete"`
+	Datacenter   string            `json:"datacenter"`
+	Labels       map[string]string `json:"labels"`
+	Name         string            `json:"name"`
+	Type         string            `json:"type"`
+}
+
+// primaryIPAssignRequest is the body of a request assigning a primary IP
+type primaryIPAssignRequest struct {
+	AssigneeID   int64  `json:"assignee_id"`
+	AssigneeType string `json:"assignee_type"`
+}
+
+// servePrimaryIPs answers /primary_ips, /primary_ips/{id} and the assign
+// and unassign actions. Only IPv4 addresses are emulated.
+func (a *API) servePrimaryIPs(w http.ResponseWriter, r *request) {
+	if len(r.parts) == 1 {
+		switch r.method() {
+		case http.MethodGet:
+			ips := a.primaryIPList(r.query("name"), r.query("label_selector"))
+			if addr := r.query("ip"); addr != "" {
+				matching := []schema.PrimaryIP{}
+				for _, ip := range ips {
+					if ip.IP == addr {
+						matching = append(matching, ip)
+					}
+				}
+				ips = matching
+			}
+			writeList(w, "primary_ips", ips, len(ips))
+		case http.MethodPost:
+			a.createPrimaryIP(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "unsupported method")
+		}
+		return
+	}
+
+	id, _ := strconv.ParseInt(r.parts[1], 10, 64)
+	ip, ok := a.primaryIPs[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "primary IP not found")
+		return
+	}
+	if len(r.parts) == 4 && r.parts[2] == "actions" && r.method() == http.MethodPost {
+		switch r.parts[3] {
+		case "assign":
+			var req primaryIPAssignRequest
+			if !r.decode(w, &req) {
+				return
+			}
+			server, ok := a.servers[req.AssigneeID]
+			if !ok {
+				writeError(w, http.StatusUnprocessableEntity, "invalid_input", "unknown server")
+				return
+			}
+			if ip.AssigneeID != 0 {
+				writeError(w, http.StatusConflict, "primary_ip_assigned", "primary IP is already assigned")
+				return
+			}
+			a.assignPrimaryIP(ip, server)
+		case "unassign":
+			a.unassignPrimaryIP(ip)
+		default:
+			writeError(w, http.StatusNotFound, "not_found", "primary IP action "+r.parts[3]+" is not emulated")
+			return
+		}
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"action": a.newAction(r.parts[3]+"_primary_ip", "primary_ip", ip.ID)})
+		return
+	}
+	switch r.method() {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, schema.PrimaryIPGetResult{PrimaryIP: *ip})
+	case http.MethodDelete:
+		if ip.AssigneeID != 0 {
+			writeError(w, http.StatusConflict, "must_be_unassigned", "primary IP must be unassigned to be deleted")
+			return
+		}
+		delete(a.primaryIPs, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "unsupported method")
+	}
+}
+
+func (a *API) createPrimaryIP(w http.ResponseWriter, r *request) {
+	var req primaryIPCreateRequest
+	if !r.decode(w, &req) {
+		return
+	}
+	if req.Name == "" || req.Type != "ipv4" {
+		writeError(w, http.StatusBadRequest, "invalid_input", "name and type ipv4 are required")
+		return
+	}
+	for _, ip := range a.primaryIPs {
+		if ip.Name == req.Name {
+			writeError(w, http.StatusConflict, "uniqueness_error", "primary IP name is already used")
+			return
+		}
+	}
+
+	var server *schema.Server
+	var dc *schema.Datacenter
+	if req.AssigneeID != nil {
+		server = a.servers[*req.AssigneeID]
+		if server == nil {
+			writeError(w, http.StatusBadRequest, "invalid_input", "unknown server")
+			return
+		}
+		dc = &server.Datacenter
+	} else {
+		for _, candidate := range a.datacenters() {
+			if candidate.Name == req.Datacenter || strconv.FormatInt(candidate.ID, 10) == req.Datacenter {
+				dc = &candidate
+			}
+		}
+		if dc == nil {
+			writeError(w, http.StatusBadRequest, "invalid_input", "a datacenter or an assignee is required")
+			return
+		}
+	}
+
+	id := a.newID()
+	ip := &schema.PrimaryIP{
+		ID:           id,
+		IP:           fmt.Sprintf("198.51.100.%d", id%254+1),
+		Labels:       map[string]string{},
+		Name:         req.Name,
+		Type:         req.Type,
+		DNSPtr:       []schema.PrimaryIPDNSPTR{},
+		AssigneeType: "server",
+		AutoDelete:   req.AutoDelete != nil && *req.AutoDelete,
+		Created:      time.Now().UTC().Truncate(time.Second),
+		Datacenter:   *dc,
+	}
+	if req.Labels != nil {
+		ip.Labels = req.Labels
+	}
+	a.primaryIPs[id] = ip
+	resp := schema.PrimaryIPCreateResponse{PrimaryIP: *ip}
+	if server != nil {
+		a.assignPrimaryIP(ip, server)
+		action := a.newAction("assign_primary_ip", "primary_ip", id)
+		resp.PrimaryIP, resp.Action = *ip, &action
+	}
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// assignPrimaryIP makes a primary IP the public IPv4 address of a server.
+// The caller holds a.mu.
+func (a *API) assignPrimaryIP(ip *schema.PrimaryIP, server *schema.Server) {
+	if old, ok := a.primaryIPs[server.PublicNet.IPv4.ID]; ok && old != ip {
+		old.AssigneeID = 0
+	}
+	ip.AssigneeID = server.ID
+	server.PublicNet.IPv4 = schema.ServerPublicNetIPv4{ID: ip.ID, IP: ip.IP}
+}
+
+// unassignPrimaryIP takes a primary IP off its server. The caller holds
+// a.mu.
+func (a *API) unassignPrimaryIP(ip *schema.PrimaryIP) {
+	if server, ok := a.servers[ip.AssigneeID]; ok && server.PublicNet.IPv4.ID == ip.ID {
+		server.PublicNet.IPv4 = schema.ServerPublicNetIPv4{}
+	}
+	ip.AssigneeID = 0
+}
+
+// releaseServer detaches a deleted server from its networks, firewalls and
+// primary IPs, deleting the primary IPs that are deleted with their
+// server. The caller holds a.mu.
+func (a *API) releaseServer(server *schema.Server) {
+	for _, pn := range server.PrivateNet {
+		a.detachFromNetwork(server, pn.Network)
+	}
+	for _, f := range append([]schema.ServerFirewall(nil), server.PublicNet.Firewalls...) {
+		if fw, ok := a.firewalls[f.ID]; ok {
+			a.removeFirewall(fw, server.ID)
+		}
+	}
+	for id, ip := range a.primaryIPs {
+		if ip.AssigneeID != server.ID {
+			continue
+		}
+		ip.AssigneeID = 0
+		if ip.AutoDelete {
+			delete(a.primaryIPs, id)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package ui
 
 import (
 	"fmt"
+	"os"
 	"time"
 )
 
@@ -77,6 +78,31 @@ func FormatDuration(d time.Duration) string {
 	return fmt.Sprintf("%ds", s)
 }
 
+// FormatBytes formats a byte count with binary units, e.g. 1.5 MiB.
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 4; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTP"[exp])
+}
+
+// IsTerminal reports whether f is a terminal rather than a file or pipe.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// ClearScreen clears the terminal and moves the cursor to the top left.
+func ClearScreen() {
+	fmt.Print("\033[H\033[2J")
+}
+
 // GetNodeCount returns the number of nodes for a given forest size (legacy support).
 func GetNodeCount(size string) int {
 	switch size {
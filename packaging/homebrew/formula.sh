@@ -0,0 +1,69 @@
+#!/bin/sh
+# Writes the Homebrew formula of a release to stdout, with the checksums of
+# its binaries taken from dist/SHA256SUMS. The release workflow publishes
+# it as morpheus.rb, to be copied into the Homebrew tap.
+#
+# Usage: packaging/homebrew/formula.sh v1.2.0 [dist/SHA256SUMS]
+
+set -e
+
+VERSION="$1"
+SUMS="${2:-dist/SHA256SUMS}"
+if [ -z "$VERSION" ]; then
+    echo "Usage: $0 <version> [SHA256SUMS]" >&2
+    exit 1
+fi
+
+sum() {
+    sha=$(awk -v f="$1" '$2 == f || $2 == "*" f { print $1 }' "$SUMS")
+    if [ -z "$sha" ]; then
+        echo "No checksum for $1 in $SUMS" >&2
+        exit 1
+    fi
+    echo "$sha"
+}
+
+URL="https://github.com/nimsforest/morpheus/releases/download/${VERSION}"
+DARWIN_ARM64=$(sum morpheus-darwin-arm64)
+DARWIN_AMD64=$(sum morpheus-darwin-amd64)
+LINUX_ARM64=$(sum morpheus-linux-arm64)
+LINUX_AMD64=$(sum morpheus-linux-amd64)
+
+cat <<RUBY
+class Morpheus < Formula
+  desc "Provisioning tool for NimsForest infrastructure"
+  homepage "https://github.com/nimsforest/morpheus"
+  version "${VERSION#v}"
+  license "MIT"
+
+  on_macos do
+    on_arm do
+      url "${URL}/morpheus-darwin-arm64"
+      sha256 "${DARWIN_ARM64}"
+    end
+    on_intel do
+      url "${URL}/morpheus-darwin-amd64"
+      sha256 "${DARWIN_AMD64}"
+    end
+  end
+
+  on_linux do
+    on_arm do
+      url "${URL}/morpheus-linux-arm64"
+      sha256 "${LINUX_ARM64}"
+    end
+    on_intel do
+      url "${URL}/morpheus-linux-amd64"
+      sha256 "${LINUX_AMD64}"
+    end
+  end
+
+  def install
+    bin.install Dir["morpheus-*"].first => "morpheus"
+  end
+
+  test do
+    assert_match version.to_s, shell_output("#{bin}/morpheus version")
+  end
+end
+RUBY
@@ -0,0 +1,175 @@
+// Package billing compares the expected monthly spend of forests with what
+// the provider actually bills, to catch forgotten or orphaned resources.
+package billing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"sort"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// DefaultThreshold is the deviation (in percent) above which spend is an anomaly
+const DefaultThreshold = 20.0
+
+// ForestReport compares one forest's expected and actual monthly spend
+type ForestReport struct {
+	ForestID  string             `json:"forest_id"`
+	Expected  float64            `json:"expected"`  // 0 when no expectation is recorded
+	Actual    float64            `json:"actual"`    // Sum of the forest's billed resources
+	Deviation float64            `json:"deviation"` // Percent above (+) or below (-) expected
+	Anomaly   bool               `json:"anomaly"`
+	Items     []machine.CostItem `json:"items"`
+}
+
+// GuardReport compares one guard's expected and actual monthly spend
+type GuardReport struct {
+	GuardID   string             `json:"guard_id"`
+	Expected  float64            `json:"expected"`  // 0 when no expectation is recorded
+	Actual    float64            `json:"actual"`    // Sum of the guard's billed resources
+	Deviation float64            `json:"deviation"` // Percent above (+) or below (-) expected
+	Anomaly   bool               `json:"anomaly"`
+	Items     []machine.CostItem `json:"items"`
+}
+
+// Report is the result of a billing check
+type Report struct {
+	GeneratedAt time.Time      `json:"generated_at"`
+	Threshold   float64        `json:"threshold"`
+	Forests     []ForestReport `json:"forests"`
+
+	// Guards are the registered guards billed by the provider, i.e.
+	// those running in the same account as the forests, and any other
+	// guards with billed resources
+	Guards    []GuardReport `json:"guards,omitempty"`
+	GuardCost float64       `json:"guard_cost,omitempty"`
+
+	// Orphans are billed resources managed by morpheus that belong to no
+	// registered forest, and volumes or IPs not attached to any server
+	Orphans    []machine.CostItem `json:"orphans"`
+	OrphanCost float64            `json:"orphan_cost"`
+}
+
+// HasAnomalies reports whether any forest or guard deviates or orphans are
+// billed
+func (r *Report) HasAnomalies() bool {
+	for _, f := range r.Forests {
+		if f.Anomaly {
+			return true
+		}
+	}
+	for _, g := range r.Guards {
+		if g.Anomaly {
+			return true
+		}
+	}
+	return len(r.Orphans) > 0
+}
+
+// Check prices the provider's resources and compares the spend of each
+// forest and guard with its expectation. Resources are attributed to
+// forests by their forest-id label, and to guards by their guard-id label.
+// threshold is in percent (0 uses DefaultThreshold).
+func Check(ctx context.Context, reporter machine.CostReporter, forests []*storage.Forest, guards []*storage.Guard, threshold float64) (*Report, error) {
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+
+	items, err := reporter.ListCosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{GeneratedAt: time.Now(), Threshold: threshold}
+
+	byForest := make(map[string]*ForestReport)
+	for _, f := range forests {
+		byForest[f.ID] = &ForestReport{ForestID: f.ID, Expected: f.ExpectedMonthlyCost()}
+	}
+
+	byGuard := make(map[string]*GuardReport)
+	for _, g := range guards {
+		byGuard[g.ID] = &GuardReport{GuardID: g.ID, Expected: g.ExpectedCost}
+	}
+	for _, item := range items {
+		forestID := item.Labels["forest-id"]
+		fr, known := byForest[forestID]
+		guardID := item.Labels["guard-id"]
+		switch {
+		case !item.Attached:
+			// Unattached volumes and IPs cost money without doing anything
+			report.Orphans = append(report.Orphans, item)
+			report.OrphanCost += item.MonthlyCost
+		case known:
+			fr.Items = append(fr.Items, item)
+			fr.Actual += item.MonthlyCost
+		case guardID != "" && forestID == "":
+			gr, ok := byGuard[guardID]
+			if !ok {
+				gr = &GuardReport{GuardID: guardID}
+				byGuard[guardID] = gr
+			}
+			gr.Items = append(gr.Items, item)
+			gr.Actual += item.MonthlyCost
+			report.GuardCost += item.MonthlyCost
+		case item.Labels["managed-by"] == "morpheus":
+			report.Orphans = append(report.Orphans, item)
+			report.OrphanCost += item.MonthlyCost
+		}
+	}
+
+	for _, f := range forests {
+		fr := byForest[f.ID]
+		if fr.Expected > 0 {
+			fr.Deviation = (fr.Actual - fr.Expected) / fr.Expected * 100
+			fr.Anomaly = math.Abs(fr.Deviation) > threshold
+		}
+		report.Forests = append(report.Forests, *fr)
+	}
+	sort.Slice(report.Forests, func(i, j int) bool {
+		return report.Forests[i].ForestID < report.Forests[j].ForestID
+	})
+	for _, gr := range byGuard {
+		if gr.Expected == 0 && len(gr.Items) == 0 {
+			// Registered elsewhere, or before expectations were recorded
+			continue
+		}
+		if gr.Expected > 0 {
+			gr.Deviation = (gr.Actual - gr.Expected) / gr.Expected * 100
+			gr.Anomaly = math.Abs(gr.Deviation) > threshold
+		}
+		report.Guards = append(report.Guards, *gr)
+	}
+	sort.Slice(report.Guards, func(i, j int) bool {
+		return report.Guards[i].GuardID < report.Guards[j].GuardID
+	})
+
+	return report, nil
+}
+
+// RunHook runs an alert hook command through the shell with the report as
+// JSON on stdin. MORPHEUS_BILLING_ANOMALY is set to "true" or "false".
+func RunHook(ctx context.Context, command string, report *Report) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), fmt.Sprintf("MORPHEUS_BILLING_ANOMALY=%t", report.HasAnomalies()))
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("alert hook failed: %w", err)
+	}
+	return nil
+}
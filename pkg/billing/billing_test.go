@@ -0,0 +1,118 @@
+package billing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+type fakeReporter []machine.CostItem
+
+func (f fakeReporter) ListCosts(ctx context.Context) ([]machine.CostItem, error) {
+	return f, nil
+}
+
+func forestLabels(id string) map[string]string {
+	return map[string]string{"managed-by": "morpheus", "forest-id": id}
+}
+
+func guardLabels(id string) map[string]string {
+	return map[string]string{"managed-by": "morpheus-hetznerguard", "guard-id": id}
+}
+
+func TestCheck(t *testing.T) {
+	reporter := fakeReporter{
+		{Kind: "server", ID: "1", Labels: forestLabels("a"), MonthlyCost: 4.5, Attached: true},
+		{Kind: "server", ID: "2", Labels: forestLabels("a"), MonthlyCost: 4.5, Attached: true},
+		{Kind: "server", ID: "3", Labels: forestLabels("b"), MonthlyCost: 4.5, Attached: true},
+		{Kind: "primary_ip", ID: "4", Labels: forestLabels("b"), MonthlyCost: 0.5, Attached: true},
+		{Kind: "primary_ip", ID: "5", Labels: forestLabels("b"), MonthlyCost: 0.5, Attached: true},
+		{Kind: "volume", ID: "6", Labels: forestLabels("gone"), MonthlyCost: 2.2, Attached: false},
+		{Kind: "server", ID: "7", Labels: forestLabels("gone"), MonthlyCost: 4.5, Attached: true},
+		{Kind: "server", ID: "8", Labels: map[string]string{"team": "web"}, MonthlyCost: 30, Attached: true},
+		{Kind: "server", ID: "9", Labels: guardLabels("guard-1"), MonthlyCost: 3.8, Attached: true},
+		{Kind: "primary_ip", ID: "10", Labels: guardLabels("guard-1"), MonthlyCost: 0.5, Attached: true},
+	}
+	forests := []*storage.Forest{
+		{ID: "b", NodeCount: 1, ExpectedNodeCost: 4.5},
+		{ID: "a", NodeCount: 2, ExpectedNodeCost: 4.5},
+		{ID: "c", NodeCount: 1},
+	}
+
+	guards := []*storage.Guard{
+		{ID: "guard-1", ExpectedCost: 4.3},
+		{ID: "guard-2", ExpectedCost: 4.3}, // Gone, but still billed as expected
+		{ID: "guard-3"},                    // Neither expected nor billed
+	}
+
+	report, err := Check(context.Background(), reporter, forests, guards, 10)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	if len(report.Forests) != 3 || report.Forests[0].ForestID != "a" {
+		t.Fatalf("Unexpected forests: %+v", report.Forests)
+	}
+	a, b, c := report.Forests[0], report.Forests[1], report.Forests[2]
+	if a.Actual != 9 || a.Anomaly {
+		t.Errorf("Forest a: %+v", a)
+	}
+	if b.Actual != 5.5 || !b.Anomaly || b.Deviation < 22 || b.Deviation > 23 {
+		t.Errorf("Forest b should be flagged: %+v", b)
+	}
+	if c.Anomaly || c.Expected != 0 {
+		t.Errorf("Forest c has no expectation and should not be flagged: %+v", c)
+	}
+
+	// The unattached volume and the server of the deleted forest are
+	// orphans; the unmanaged server is ignored
+	if len(report.Orphans) != 2 || report.OrphanCost != 6.7 {
+		t.Errorf("Unexpected orphans: %+v (%.2f)", report.Orphans, report.OrphanCost)
+	}
+	if !report.HasAnomalies() {
+		t.Error("Expected HasAnomalies() to be true")
+	}
+
+	if len(report.Guards) != 2 || report.Guards[0].GuardID != "guard-1" || len(report.Guards[0].Items) != 2 || report.GuardCost != 4.3 {
+		t.Fatalf("Unexpected guards: %+v (%.2f)", report.Guards, report.GuardCost)
+	}
+	if g := report.Guards[0]; g.Expected != 4.3 || g.Anomaly {
+		t.Errorf("Guard guard-1 matches its expectation: %+v", g)
+	}
+	if g := report.Guards[1]; g.GuardID != "guard-2" || !g.Anomaly || g.Deviation != -100 {
+		t.Errorf("Guard guard-2 should be flagged: %+v", g)
+	}
+
+	// A guard deviating is an anomaly on its own
+	report, err = Check(context.Background(), reporter[8:], nil, guards[1:2], 10)
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if !report.HasAnomalies() {
+		t.Errorf("Expected HasAnomalies() for an unbilled guard: %+v", report.Guards)
+	}
+}
+
+func TestProjectBudget(t *testing.T) {
+	forests := []*storage.Forest{
+		{ID: "a", NodeCount: 2, ExpectedNodeCost: 5, ExpectedExtraCost: 5.39},
+		{ID: "b", NodeCount: 1},
+	}
+
+	b := ProjectBudget(forests, 10, 30)
+	if b.Current != 15.39 || b.Projected != 25.39 || b.Exceeded() || b.Err() != nil {
+		t.Errorf("Expected 25.39 within the limit: %+v", b)
+	}
+	if len(b.Unpriced) != 1 || b.Unpriced[0] != "b" {
+		t.Errorf("Expected forest b unpriced: %v", b.Unpriced)
+	}
+
+	if b := ProjectBudget(forests, 20, 30); !b.Exceeded() || b.Err() == nil {
+		t.Errorf("Expected 35.39 to exceed the limit: %+v", b)
+	}
+	if b := ProjectBudget(forests, 1000, 0); b.Exceeded() {
+		t.Errorf("A limit of 0 should never be exceeded: %+v", b)
+	}
+}
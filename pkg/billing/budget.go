@@ -0,0 +1,50 @@
+package billing
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// Budget projects monthly spend against a configured limit
+type Budget struct {
+	Limit     float64 `json:"limit"`
+	Current   float64 `json:"current"`   // Expected spend of the registered forests
+	Added     float64 `json:"added"`     // Expected spend of the change being made
+	Projected float64 `json:"projected"` // Current + Added
+
+	// Unpriced are registered forests without a recorded expected spend,
+	// which the projection cannot include
+	Unpriced []string `json:"unpriced,omitempty"`
+}
+
+// ProjectBudget adds the expected monthly spend of a change to that of all
+// registered forests. A limit of 0 means no limit.
+func ProjectBudget(forests []*storage.Forest, added, limit float64) *Budget {
+	b := &Budget{Limit: limit, Added: added}
+	for _, f := range forests {
+		cost := f.ExpectedMonthlyCost()
+		if cost == 0 && f.NodeCount > 0 {
+			b.Unpriced = append(b.Unpriced, f.ID)
+		}
+		b.Current += cost
+	}
+	sort.Strings(b.Unpriced)
+	b.Projected = b.Current + b.Added
+	return b
+}
+
+// Exceeded reports whether the projected spend is above the limit
+func (b *Budget) Exceeded() bool {
+	return b.Limit > 0 && b.Projected > b.Limit
+}
+
+// Err returns an error describing the overrun, or nil within the limit
+func (b *Budget) Err() error {
+	if !b.Exceeded() {
+		return nil
+	}
+	return fmt.Errorf("projected spend €%.2f/month (€%.2f now + €%.2f) exceeds the limit of €%.2f/month",
+		b.Projected, b.Current, b.Added, b.Limit)
+}
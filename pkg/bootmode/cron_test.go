@@ -0,0 +1,75 @@
+package bootmode
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"0 22 * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * smarch *",
+		"@reboot",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) should fail", expr)
+		}
+	}
+}
+
+func TestCron_Next(t *testing.T) {
+	// Wednesday
+	from := time.Date(2026, 3, 4, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"0 22 * * *", time.Date(2026, 3, 4, 22, 0, 0, 0, time.UTC)},
+		{"0 7 * * *", time.Date(2026, 3, 5, 7, 0, 0, 0, time.UTC)},
+		{"30 12 * * *", time.Date(2026, 3, 5, 12, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 4, 12, 45, 0, 0, time.UTC)},
+		{"30 7 * * mon-fri", time.Date(2026, 3, 5, 7, 30, 0, 0, time.UTC)},
+		{"0 9 * * sat,sun", time.Date(2026, 3, 7, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2026, 3, 8, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either matches
+		{"0 0 10 * fri", time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+		}
+		if got := c.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: Next = %s, want %s", tt.expr, got, tt.want)
+		}
+		if !tt.want.IsZero() && !c.Matches(tt.want) {
+			t.Errorf("%q should match %s", tt.expr, tt.want)
+		}
+	}
+}
+
+func TestCron_NextDaylightSaving(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	// 02:30 does not exist on 29 March 2026; the next is a day later
+	c, _ := ParseCron("30 2 * * *")
+	got := c.Next(time.Date(2026, 3, 28, 12, 0, 0, 0, loc))
+	if want := time.Date(2026, 3, 30, 2, 30, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("Next = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+package bootmode
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nimsforest/morpheus/pkg/machine/proxmox"
+)
+
+// GPUPassthroughError is returned when a GPU passed through to a mode's VM
+// is not usable, so the VM would boot without it
+type GPUPassthroughError struct {
+	Device string
+	Reason string
+	Hint   string // What the user can do about it
+}
+
+func (e *GPUPassthroughError) Error() string {
+	return fmt.Sprintf("GPU passthrough of %s: %s", e.Device, e.Reason)
+}
+
+// checkGPUAvailable verifies that the PCI devices passed through to vmid
+// exist on the host, have IOMMU enabled and are not held by another running
+// VM. The VM in exceptVMID (the mode being switched away from) is ignored.
+// It returns the passthrough devices, none for VMs without passthrough.
+func (m *ProxmoxManager) checkGPUAvailable(ctx context.Context, vmid, exceptVMID int) ([]string, error) {
+	config, err := m.client.GetVMConfig(ctx, vmid)
+	if err != nil {
+		return nil, fmt.Errorf("get VM %d config: %w", vmid, err)
+	}
+	devices := config.PassthroughDevices()
+	if len(devices) == 0 {
+		return nil, nil
+	}
+
+	hostDevices, err := m.client.ListPCIDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list PCI devices: %w", err)
+	}
+	for _, device := range devices {
+		if err := checkHostDevice(device, hostDevices); err != nil {
+			return nil, err
+		}
+	}
+
+	vms, err := m.client.ListVMs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list VMs: %w", err)
+	}
+	for _, vm := range vms {
+		if vm.VMID == vmid || vm.VMID == exceptVMID || vm.Status != proxmox.VMStatusRunning {
+			continue
+		}
+		other, err := m.client.GetVMConfig(ctx, vm.VMID)
+		if err != nil {
+			return nil, fmt.Errorf("get VM %d config: %w", vm.VMID, err)
+		}
+		for _, device := range devices {
+			for _, used := range other.PassthroughDevices() {
+				if proxmox.SamePCIDevice(device, used) {
+					return nil, &GPUPassthroughError{
+						Device: device,
+						Reason: fmt.Sprintf("in use by running VM %d (%s)", vm.VMID, vm.Name),
+						Hint:   fmt.Sprintf("Shut down VM %d first: qm shutdown %d", vm.VMID, vm.VMID),
+					}
+				}
+			}
+		}
+	}
+
+	return devices, nil
+}
+
+// checkHostDevice verifies that a passthrough device exists on the host and
+// is in an IOMMU group
+func checkHostDevice(device string, hostDevices []*proxmox.PCIDevice) error {
+	found := false
+	for _, d := range hostDevices {
+		if !proxmox.SamePCIDevice(device, d.ID) {
+			continue
+		}
+		found = true
+		if d.IOMMUGroup < 0 {
+			return &GPUPassthroughError{
+				Device: device,
+				Reason: "IOMMU is not enabled on the host",
+				Hint:   "Add intel_iommu=on (or amd_iommu=on) iommu=pt to the kernel command line and reboot the host",
+			}
+		}
+	}
+	if !found {
+		return &GPUPassthroughError{
+			Device: device,
+			Reason: "device not found on the host",
+			Hint:   "Check the address with lspci and update the VM's hostpci setting",
+		}
+	}
+	return nil
+}
+
+// vfioErrors returns the lines of a VM start log that report a failure to
+// pass through a PCI device
+func vfioErrors(log []string) []string {
+	var errs []string
+	for _, line := range log {
+		lower := strings.ToLower(line)
+		if !strings.Contains(lower, "vfio") {
+			continue
+		}
+		for _, word := range []string{"error", "fail", "cannot", "unable", "busy"} {
+			if strings.Contains(lower, word) {
+				errs = append(errs, strings.TrimSpace(line))
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// checkStartTask verifies that a VM start task succeeded and bound the
+// passthrough devices to vfio
+func (m *ProxmoxManager) checkStartTask(ctx context.Context, vmid int, status *proxmox.TaskStatus, devices []string) error {
+	// Warnings still start the VM, possibly without its GPU
+	failed := !status.IsSuccessful() && !strings.HasPrefix(status.ExitStatus, "WARNINGS")
+
+	var errs []string
+	if len(devices) > 0 {
+		log, _ := m.client.GetTaskLog(ctx, status.UPID)
+		errs = vfioErrors(log)
+	}
+	if len(errs) > 0 {
+		if !failed {
+			// Don't leave a VM running without its GPU
+			if upid, err := m.client.StopVM(ctx, vmid); err == nil {
+				m.client.WaitForTask(ctx, upid, m.pollInterval)
+			}
+		}
+		device := strings.Join(devices, ", ")
+		return &GPUPassthroughError{
+			Device: device,
+			Reason: "vfio binding failed: " + strings.Join(errs, "; "),
+			Hint:   fmt.Sprintf("Check that the device uses the vfio-pci driver (lspci -nnk -s %s) and that no host driver claims it", devices[0]),
+		}
+	}
+	if failed {
+		return fmt.Errorf("start task failed: %s", status.ExitStatus)
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package bootmode
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/machine/proxmox"
+)
+
+type fakeVM struct {
+	name    string
+	status  string
+	hostpci string
+	ip      string // Reported by the guest agent while running
+}
+
+// fakeProxmox serves the parts of the Proxmox API used by ProxmoxManager
+type fakeProxmox struct {
+	mu       sync.Mutex
+	vms      map[int]*fakeVM
+	pci      []map[string]interface{}
+	startLog []string
+	stopped  []int
+
+	snapshots map[int][]string
+	actions   []string
+}
+
+func newFakeProxmoxManager(t *testing.T, fake *fakeProxmox) *ProxmoxManager {
+	t.Helper()
+	server := httptest.NewTLSServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(server.Close)
+
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "https://"))
+	portNum, _ := strconv.Atoi(port)
+	m, err := NewProxmoxManager(proxmox.ProviderConfig{
+		Host:           host,
+		Port:           portNum,
+		Node:           "pve",
+		APITokenID:     "test@pam!token",
+		APITokenSecret: "secret",
+	}, VRNodeConfig{
+		Linux:   VMConfig{VMID: 101},
+		Windows: VMConfig{VMID: 102},
+		GPUPCI:  "0000:01:00",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.pollInterval = 10 * time.Millisecond
+	return m
+}
+
+func (f *fakeProxmox) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	reply := func(data interface{}) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/api2/json/nodes/pve")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case path == "/qemu":
+		var list []map[string]interface{}
+		for id, vm := range f.vms {
+			list = append(list, map[string]interface{}{"vmid": id, "name": vm.name, "status": vm.status})
+		}
+		reply(list)
+	case path == "/hardware/pci":
+		reply(f.pci)
+	case parts[0] == "tasks" && len(parts) == 3:
+		upid := parts[1]
+		if parts[2] == "log" {
+			var log []map[string]interface{}
+			if strings.Contains(upid, ":qmstart:") {
+				for i, line := range f.startLog {
+					log = append(log, map[string]interface{}{"n": i + 1, "t": line})
+				}
+			}
+			reply(log)
+			return
+		}
+		reply(map[string]interface{}{"status": "stopped", "exitstatus": "OK"})
+	case parts[0] == "qemu" && len(parts) >= 3:
+		id, _ := strconv.Atoi(parts[1])
+		vm, ok := f.vms[id]
+		if !ok {
+			http.Error(w, "no such VM", http.StatusInternalServerError)
+			return
+		}
+		switch strings.Join(parts[2:], "/") {
+		case "config":
+			config := map[string]interface{}{"name": vm.name}
+			if vm.hostpci != "" {
+				config["hostpci0"] = vm.hostpci
+			}
+			reply(config)
+		case "agent/network-get-interfaces":
+			if vm.ip == "" || vm.status != "running" {
+				http.Error(w, "agent not running", http.StatusInternalServerError)
+				return
+			}
+			reply(map[string]interface{}{"result": []map[string]interface{}{{
+				"name":         "eth0",
+				"ip-addresses": []map[string]interface{}{{"ip-address": vm.ip, "ip-address-type": "ipv4"}},
+			}}})
+		case "status/current":
+			reply(map[string]interface{}{"name": vm.name, "status": vm.status})
+		case "status/start":
+			vm.status = "running"
+			reply("UPID:pve:qmstart:" + parts[1])
+		case "status/stop", "status/shutdown":
+			vm.status = "stopped"
+			f.stopped = append(f.stopped, id)
+			f.actions = append(f.actions, "stop "+parts[1])
+			reply("UPID:pve:qmstop:" + parts[1])
+		case "snapshot":
+			if r.Method == http.MethodPost {
+				r.ParseForm()
+				f.snapshots[id] = append(f.snapshots[id], r.PostForm.Get("snapname"))
+				f.actions = append(f.actions, "snapshot "+parts[1])
+				reply("UPID:pve:qmsnapshot:" + parts[1])
+				return
+			}
+			list := []map[string]interface{}{{"name": "current"}}
+			for i, name := range f.snapshots[id] {
+				list = append(list, map[string]interface{}{"name": name, "snaptime": i + 1})
+			}
+			reply(list)
+		default:
+			if len(parts) >= 4 && parts[2] == "snapshot" {
+				name := parts[3]
+				if len(parts) == 5 && parts[4] == "rollback" {
+					f.actions = append(f.actions, "rollback "+parts[1]+" "+name)
+					reply("UPID:pve:qmrollback:" + parts[1])
+					return
+				}
+				var kept []string
+				for _, n := range f.snapshots[id] {
+					if n != name {
+						kept = append(kept, n)
+					}
+				}
+				f.snapshots[id] = kept
+				f.actions = append(f.actions, "delsnapshot "+parts[1]+" "+name)
+				reply("UPID:pve:qmdelsnapshot:" + parts[1])
+				return
+			}
+			http.Error(w, "agent not running", http.StatusInternalServerError)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func gpuHost(iommuGroup int) []map[string]interface{} {
+	return []map[string]interface{}{
+		{"id": "0000:01:00.0", "class": "0x030000", "device_name": "AD102", "iommugroup": iommuGroup},
+		{"id": "0000:01:00.1", "class": "0x040300", "iommugroup": iommuGroup},
+	}
+}
+
+func TestSwitch_GPUInUseByOtherVM(t *testing.T) {
+	fake := &fakeProxmox{
+		vms: map[int]*fakeVM{
+			101: {name: "vr-linux", status: "stopped", hostpci: "0000:01:00,pcie=1"},
+			102: {name: "vr-windows", status: "stopped", hostpci: "0000:01:00,pcie=1,x-vga=1"},
+			105: {name: "gaming", status: "running", hostpci: "01:00.0,pcie=1"},
+		},
+		pci: gpuHost(12),
+	}
+	m := newFakeProxmoxManager(t, fake)
+
+	_, err := m.Switch(context.Background(), "windows", DefaultSwitchOptions())
+	gpuErr, ok := err.(*GPUPassthroughError)
+	if !ok {
+		t.Fatalf("expected GPUPassthroughError, got %v", err)
+	}
+	if !strings.Contains(gpuErr.Reason, "VM 105") || !strings.Contains(gpuErr.Hint, "qm shutdown 105") {
+		t.Errorf("unexpected error: %v (hint: %s)", gpuErr, gpuErr.Hint)
+	}
+	if fake.vms[102].status != "stopped" {
+		t.Error("expected target VM not to be started")
+	}
+}
+
+func TestSwitch_GPUHeldByCurrentMode(t *testing.T) {
+	// The current mode's VM holds the GPU but is stopped by the switch
+	fake := &fakeProxmox{
+		vms: map[int]*fakeVM{
+			101: {name: "vr-linux", status: "running", hostpci: "0000:01:00,pcie=1"},
+			102: {name: "vr-windows", status: "stopped", hostpci: "0000:01:00,pcie=1,x-vga=1"},
+		},
+		pci: gpuHost(12),
+	}
+	m := newFakeProxmoxManager(t, fake)
+
+	opts := DefaultSwitchOptions()
+	opts.DryRun = true
+	if _, err := m.Switch(context.Background(), "windows", opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestSwitch_IOMMUDisabled(t *testing.T) {
+	fake := &fakeProxmox{
+		vms: map[int]*fakeVM{
+			101: {name: "vr-linux", status: "stopped", hostpci: "0000:01:00,pcie=1"},
+			102: {name: "vr-windows", status: "stopped", hostpci: "0000:01:00,pcie=1"},
+		},
+		pci: gpuHost(-1),
+	}
+	m := newFakeProxmoxManager(t, fake)
+
+	opts := DefaultSwitchOptions()
+	opts.DryRun = true
+	_, err := m.Switch(context.Background(), "linux", opts)
+	gpuErr, ok := err.(*GPUPassthroughError)
+	if !ok || !strings.Contains(gpuErr.Reason, "IOMMU") {
+		t.Fatalf("expected IOMMU error, got %v", err)
+	}
+}
+
+func TestSwitch_VFIOBindFailure(t *testing.T) {
+	fake := &fakeProxmox{
+		vms: map[int]*fakeVM{
+			101: {name: "vr-linux", status: "stopped", hostpci: "0000:01:00,pcie=1"},
+			102: {name: "vr-windows", status: "stopped", hostpci: "0000:01:00,pcie=1"},
+		},
+		pci: gpuHost(12),
+		startLog: []string{
+			"kvm: -device vfio-pci,host=0000:01:00.0: vfio 0000:01:00.0: failed to open /dev/vfio/12: Device or resource busy",
+			"TASK OK",
+		},
+	}
+	m := newFakeProxmoxManager(t, fake)
+
+	_, err := m.Switch(context.Background(), "linux", DefaultSwitchOptions())
+	gpuErr, ok := err.(*GPUPassthroughError)
+	if !ok || !strings.Contains(gpuErr.Reason, "vfio binding failed") {
+		t.Fatalf("expected vfio error, got %v", err)
+	}
+	if len(fake.stopped) != 1 || fake.stopped[0] != 101 {
+		t.Errorf("expected VM 101 to be stopped again, stopped %v", fake.stopped)
+	}
+}
+
+func TestVFIOErrors(t *testing.T) {
+	log := []string{
+		"generating cloud-init ISO",
+		"kvm: vfio: Cannot reset device 0000:01:00.1, depends on group 12 which is not owned.",
+		"vfio-pci 0000:01:00.0: enabling device",
+		"TASK OK",
+	}
+
+	errs := vfioErrors(log)
+	if len(errs) != 1 || !strings.Contains(errs[0], "Cannot reset") {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
@@ -0,0 +1,262 @@
+package bootmode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+)
+
+const (
+	// defaultHookTimeout bounds hooks without a timeout of their own
+	defaultHookTimeout = 5 * time.Minute
+
+	// maxHookOutput is how much of a hook's output is kept, from the end
+	maxHookOutput = 64 << 10
+)
+
+// HookStage is the point of a switch a hook runs at
+type HookStage string
+
+const (
+	// HookPreShutdown hooks run before the VM of the current mode is
+	// stopped, e.g. to checkpoint workloads
+	HookPreShutdown HookStage = "pre_shutdown"
+
+	// HookPostStart hooks run once the VM of the new mode is up, e.g. to
+	// warm caches
+	HookPostStart HookStage = "post_start"
+)
+
+// Hook is a command run when a mode is switched away from or to. Either
+// Run or SSH is set.
+type Hook struct {
+	Name string `yaml:"name"`
+
+	// Run is a command run on this machine with sh -c. It sees the switch
+	// in MORPHEUS_HOOK, MORPHEUS_MODE, MORPHEUS_FROM_MODE, MORPHEUS_TO_MODE,
+	// MORPHEUS_VMID and MORPHEUS_VM_IP.
+	Run string `yaml:"run"`
+
+	// SSH is a command run in the mode's VM over SSH, as User (default
+	// root), at the IP the guest agent reports
+	SSH  string `yaml:"ssh"`
+	User string `yaml:"user"`
+
+	// Timeout bounds the hook (default 5m)
+	Timeout time.Duration `yaml:"timeout"`
+
+	// ContinueOnError carries on with the switch if the hook fails
+	ContinueOnError bool `yaml:"continue_on_error"`
+}
+
+// command returns the command of the hook and whether it runs in the VM
+func (h Hook) command() (string, bool) {
+	if h.SSH != "" {
+		return h.SSH, true
+	}
+	return h.Run, false
+}
+
+// HookResult is the outcome of a hook run during a switch
+type HookResult struct {
+	Stage    HookStage     `json:"stage"`
+	Mode     string        `json:"mode"`
+	Name     string        `json:"name,omitempty"`
+	Command  string        `json:"command"`
+	Remote   bool          `json:"remote,omitempty"` // Run in the VM over SSH
+	Output   string        `json:"output,omitempty"` // Standard output and error, combined
+	ExitCode int           `json:"exit_code"`        // -1 if the command could not be run
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// HookError is returned when a pre-shutdown hook fails and stops a switch
+type HookError struct {
+	Stage HookStage
+	Mode  string
+	Hook  string
+	Err   error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%s hook %s of %s failed: %v", e.Stage, e.Hook, e.Mode, e.Err)
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// hooks returns the hooks of a mode for a stage
+func (m *ProxmoxManager) hooks(mode string, stage HookStage) []Hook {
+	var vm VMConfig
+	switch mode {
+	case "linux":
+		vm = m.config.Linux
+	case "windows":
+		vm = m.config.Windows
+	}
+	if stage == HookPreShutdown {
+		return vm.PreShutdown
+	}
+	return vm.PostStart
+}
+
+// runHooks runs the hooks of a mode for a stage in order, adding their
+// results to result. It stops at the first hook that fails without
+// ContinueOnError, and returns its error.
+func (m *ProxmoxManager) runHooks(ctx context.Context, stage HookStage, mode string, vmid int, result *SwitchResult) error {
+	hooks := m.hooks(mode, stage)
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	ip := ""
+	for _, h := range hooks {
+		if h.SSH != "" {
+			ip = m.hookIP(ctx, vmid, stage)
+			break
+		}
+	}
+	if ip == "" {
+		ip = result.IPAddress
+	}
+
+	for i, h := range hooks {
+		name := h.Name
+		if name == "" {
+			name = "#" + strconv.Itoa(i+1)
+		}
+		hr := m.runHook(ctx, stage, mode, vmid, ip, h, result)
+		hr.Name = name
+		result.Hooks = append(result.Hooks, hr)
+		if hr.Error != "" && !h.ContinueOnError {
+			return &HookError{Stage: stage, Mode: mode, Hook: name, Err: errors.New(hr.Error)}
+		}
+	}
+	return nil
+}
+
+// hookIP returns the IP of a VM to SSH into. After a start, the guest
+// agent may take a while to report one, so it is waited for.
+func (m *ProxmoxManager) hookIP(ctx context.Context, vmid int, stage HookStage) string {
+	ctx, cancel := context.WithTimeout(ctx, defaultHookTimeout)
+	defer cancel()
+	for {
+		if ips, _ := m.client.GetVMIPs(ctx, vmid); len(ips) > 0 {
+			return ips[0]
+		}
+		if stage != HookPostStart {
+			return ""
+		}
+		select {
+		case <-ctx.Done():
+			return ""
+		case <-time.After(m.pollInterval):
+		}
+	}
+}
+
+// runHook runs a hook, retrying SSH connections to a VM that has just
+// started until its SSH server is up
+func (m *ProxmoxManager) runHook(ctx context.Context, stage HookStage, mode string, vmid int, ip string, h Hook, result *SwitchResult) HookResult {
+	command, remote := h.command()
+	hr := HookResult{Stage: stage, Mode: mode, Command: command, Remote: remote}
+	if remote && ip == "" {
+		hr.ExitCode = -1
+		hr.Error = "no IP address for the VM (is the guest agent running?)"
+		return hr
+	}
+
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	for {
+		var cmd *exec.Cmd
+		if remote {
+			cmd = m.sshCommand(ctx, ip, h.User, command)
+		} else {
+			cmd = exec.CommandContext(ctx, "sh", "-c", command)
+			cmd.Env = append(os.Environ(),
+				"MORPHEUS_HOOK="+string(stage),
+				"MORPHEUS_MODE="+mode,
+				"MORPHEUS_FROM_MODE="+result.FromMode,
+				"MORPHEUS_TO_MODE="+result.ToMode,
+				"MORPHEUS_VMID="+strconv.Itoa(vmid),
+				"MORPHEUS_VM_IP="+ip,
+			)
+		}
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		cmd.WaitDelay = time.Second
+		err := cmd.Run()
+
+		hr.Output = tail(output.String(), maxHookOutput)
+		hr.Duration = time.Since(start)
+		hr.ExitCode, hr.Error = 0, ""
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil:
+			return hr
+		case ctx.Err() == context.DeadlineExceeded:
+			hr.ExitCode = -1
+			hr.Error = fmt.Sprintf("timed out after %s", timeout)
+			return hr
+		case errors.As(err, &exitErr):
+			hr.ExitCode = exitErr.ExitCode()
+			hr.Error = fmt.Sprintf("exit status %d", hr.ExitCode)
+		default:
+			hr.ExitCode = -1
+			hr.Error = err.Error()
+			return hr
+		}
+
+		// ssh exits with 255 on its own errors, e.g. while sshd starts
+		if !remote || stage != HookPostStart || hr.ExitCode != 255 {
+			return hr
+		}
+		select {
+		case <-ctx.Done():
+			hr.Error = fmt.Sprintf("ssh failed until timed out after %s", timeout)
+			return hr
+		case <-time.After(5 * m.pollInterval):
+		}
+	}
+}
+
+// sshCommand returns the ssh command that runs command in a VM
+func (m *ProxmoxManager) sshCommand(ctx context.Context, ip, user, command string) *exec.Cmd {
+	if user == "" {
+		user = "root"
+	}
+	binary := m.sshBinary
+	if binary == "" {
+		binary = "ssh"
+	}
+	args := append([]string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=15"}, sshutil.HostKeyArgs("", "")...)
+	if m.config.SSHIdentity != "" {
+		args = append(args, "-i", m.config.SSHIdentity)
+	}
+	args = append(args, user+"@"+ip, command)
+	return exec.CommandContext(ctx, binary, args...)
+}
+
+// tail returns the last max bytes of s
+func tail(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return "…" + s[len(s)-max:]
+}
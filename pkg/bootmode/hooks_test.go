@@ -0,0 +1,131 @@
+package bootmode
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSwitch_RunsHooks(t *testing.T) {
+	fake := &fakeProxmox{
+		vms: map[int]*fakeVM{
+			101: {name: "vr-linux", status: "running"},
+			102: {name: "vr-windows", status: "stopped", ip: "192.168.1.152"},
+		},
+	}
+	m := newFakeProxmoxManager(t, fake)
+	m.config.Linux.PreShutdown = []Hook{
+		{Name: "checkpoint", Run: `echo "$MORPHEUS_HOOK $MORPHEUS_MODE $MORPHEUS_FROM_MODE->$MORPHEUS_TO_MODE $MORPHEUS_VMID"`},
+	}
+	m.config.Windows.PostStart = []Hook{
+		{Name: "warm-cache", Run: `echo "warming $MORPHEUS_VM_IP"; echo oops >&2; exit 3`, ContinueOnError: true},
+		{Run: "echo done"},
+	}
+
+	result, err := m.Switch(context.Background(), "windows", DefaultSwitchOptions())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Hooks) != 3 {
+		t.Fatalf("expected 3 hook results, got %+v", result.Hooks)
+	}
+
+	pre := result.Hooks[0]
+	if pre.Stage != HookPreShutdown || pre.Name != "checkpoint" || pre.Output != "pre_shutdown linux linux->windows 101\n" {
+		t.Errorf("unexpected pre-shutdown result: %+v", pre)
+	}
+	warm := result.Hooks[1]
+	if warm.Stage != HookPostStart || warm.ExitCode != 3 || warm.Error == "" {
+		t.Errorf("expected the warm-cache hook to fail, got %+v", warm)
+	}
+	if !strings.Contains(warm.Output, "warming 192.168.1.152") || !strings.Contains(warm.Output, "oops") {
+		t.Errorf("expected stdout and stderr to be captured, got %q", warm.Output)
+	}
+	if last := result.Hooks[2]; last.Name != "#2" || last.Error != "" {
+		t.Errorf("expected the hook after a ContinueOnError failure to run, got %+v", last)
+	}
+	if !result.Success || fake.vms[102].status != "running" {
+		t.Error("expected a failed post-start hook not to fail the switch")
+	}
+}
+
+func TestSwitch_PreShutdownHookFailure(t *testing.T) {
+	fake := &fakeProxmox{
+		vms: map[int]*fakeVM{
+			101: {name: "vr-linux", status: "running"},
+			102: {name: "vr-windows", status: "stopped"},
+		},
+	}
+	m := newFakeProxmoxManager(t, fake)
+	m.config.Linux.PreShutdown = []Hook{
+		{Name: "checkpoint", Run: "echo 'checkpoint failed'; exit 1"},
+		{Name: "never", Run: "echo should not run"},
+	}
+
+	result, err := m.Switch(context.Background(), "windows", DefaultSwitchOptions())
+	var switchErr *SwitchError
+	if !errors.As(err, &switchErr) || !strings.Contains(err.Error(), "checkpoint") {
+		t.Fatalf("expected the switch to fail on the hook, got %v", err)
+	}
+	if len(result.Hooks) != 1 || result.Hooks[0].Output != "checkpoint failed\n" {
+		t.Errorf("unexpected hook results: %+v", result.Hooks)
+	}
+	if fake.vms[101].status != "running" || fake.vms[102].status != "stopped" {
+		t.Error("expected no VM to be stopped or started")
+	}
+}
+
+func TestSwitch_SSHHook(t *testing.T) {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	ssh := filepath.Join(dir, "ssh")
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\necho cache warm\n"
+	if err := os.WriteFile(ssh, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := &fakeProxmox{
+		vms: map[int]*fakeVM{
+			101: {name: "vr-linux", status: "stopped", ip: "192.168.1.150"},
+			102: {name: "vr-windows", status: "stopped"},
+		},
+	}
+	m := newFakeProxmoxManager(t, fake)
+	m.sshBinary = ssh
+	m.config.SSHIdentity = "/keys/id_ed25519"
+	m.config.Linux.PostStart = []Hook{{Name: "warm", SSH: "nimsforest warm-cache", User: "forest"}}
+
+	result, err := m.Switch(context.Background(), "linux", DefaultSwitchOptions())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Hooks) != 1 || !result.Hooks[0].Remote || result.Hooks[0].Output != "cache warm\n" {
+		t.Fatalf("unexpected hook results: %+v", result.Hooks)
+	}
+	args, _ := os.ReadFile(argsFile)
+	if !strings.Contains(string(args), "-i /keys/id_ed25519 forest@192.168.1.150 nimsforest warm-cache") {
+		t.Errorf("unexpected ssh arguments: %s", args)
+	}
+}
+
+func TestSwitch_SSHHookWithoutIP(t *testing.T) {
+	fake := &fakeProxmox{
+		vms: map[int]*fakeVM{
+			101: {name: "vr-linux", status: "running"},
+			102: {name: "vr-windows", status: "stopped"},
+		},
+	}
+	m := newFakeProxmoxManager(t, fake)
+	m.config.Linux.PreShutdown = []Hook{{SSH: "nimsforest checkpoint", ContinueOnError: true}}
+
+	result, err := m.Switch(context.Background(), "windows", DefaultSwitchOptions())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Hooks) != 1 || result.Hooks[0].ExitCode != -1 || !strings.Contains(result.Hooks[0].Error, "guest agent") {
+		t.Errorf("unexpected hook results: %+v", result.Hooks)
+	}
+}
@@ -19,6 +19,15 @@ type Manager interface {
 	// Switch changes from the current mode to the target mode
 	Switch(ctx context.Context, targetMode string, opts SwitchOptions) (*SwitchResult, error)
 
+	// Revert rolls the mode switched to with a snapshot back to its
+	// pre-switch snapshot and starts the previous mode again
+	Revert(ctx context.Context, opts SwitchOptions) (*SwitchResult, error)
+
+	// Rollback restores the mode switched away from with
+	// SnapshotBeforeSwitch to its snapshot and starts it again, e.g. when
+	// the new mode failed to come up
+	Rollback(ctx context.Context, opts SwitchOptions) (*SwitchResult, error)
+
 	// GetModeInfo returns detailed information about a mode
 	GetModeInfo(ctx context.Context, name string) (*ModeInfo, error)
 
@@ -44,6 +53,19 @@ func (e *AlreadyActiveError) Error() string {
 	return fmt.Sprintf("already in %s mode", e.Mode)
 }
 
+// NoSnapshotError is returned by Revert and Rollback when there is no
+// snapshot to go back to
+type NoSnapshotError struct {
+	Rollback bool // Returned by Rollback
+}
+
+func (e *NoSnapshotError) Error() string {
+	if e.Rollback {
+		return "no pre-stop snapshot found (switch with --snapshot-current to take one)"
+	}
+	return "no pre-switch snapshot found (switch with --snapshot to take one)"
+}
+
 // SwitchError is returned when mode switching fails
 type SwitchError struct {
 	FromMode string
@@ -13,6 +13,9 @@ type ProxmoxManager struct {
 	client *proxmox.Client
 	config VRNodeConfig
 	node   string
+
+	pollInterval time.Duration // How often to poll tasks and VM status
+	sshBinary    string        // ssh client for hooks (default "ssh")
 }
 
 // NewProxmoxManager creates a new Proxmox boot mode manager
@@ -23,9 +26,10 @@ func NewProxmoxManager(proxmoxConfig proxmox.ProviderConfig, vrConfig VRNodeConf
 	}
 
 	return &ProxmoxManager{
-		client: client,
-		config: vrConfig,
-		node:   proxmoxConfig.Node,
+		client:       client,
+		config:       vrConfig,
+		node:         proxmoxConfig.Node,
+		pollInterval: time.Second,
 	}, nil
 }
 
@@ -106,6 +110,17 @@ func (m *ProxmoxManager) Switch(ctx context.Context, targetMode string, opts Swi
 		}
 	}
 
+	// Make sure the GPU can be passed through before stopping anything
+	currentVMID := 0
+	if current != nil {
+		currentVMID, _ = m.getVMID(current.Name)
+	}
+	devices, err := m.checkGPUAvailable(ctx, targetVMID, currentVMID)
+	if err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+
 	// Dry run - just report what would happen
 	if opts.DryRun {
 		result.Success = true
@@ -113,9 +128,30 @@ func (m *ProxmoxManager) Switch(ctx context.Context, targetMode string, opts Swi
 		return result, nil
 	}
 
-	// Stop current mode if running
+	// Snapshot the target while it is stopped, before anything changes
+	if opts.Snapshot {
+		name, err := m.takeSnapshot(ctx, targetVMID, preSwitch, result.FromMode)
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to snapshot %s: %v", targetMode, err)
+			return result, &SwitchError{FromMode: result.FromMode, ToMode: targetMode, Reason: "snapshot failed: " + err.Error()}
+		}
+		result.Snapshot = name
+	}
+
+	// Stop current mode if running, once its hooks are done
 	if current != nil {
-		currentVMID, _ := m.getVMID(current.Name)
+		if err := m.runHooks(ctx, HookPreShutdown, current.Name, currentVMID, result); err != nil {
+			result.Error = err.Error()
+			return result, &SwitchError{FromMode: current.Name, ToMode: targetMode, Reason: err.Error()}
+		}
+		if opts.SnapshotBeforeSwitch {
+			name, err := m.takeSnapshot(ctx, currentVMID, preStop, targetMode)
+			if err != nil {
+				result.Error = fmt.Sprintf("failed to snapshot %s: %v", current.Name, err)
+				return result, &SwitchError{FromMode: current.Name, ToMode: targetMode, Reason: "snapshot failed: " + err.Error()}
+			}
+			result.RollbackSnapshot = name
+		}
 		if err := m.stopVM(ctx, currentVMID, opts); err != nil {
 			result.Error = fmt.Sprintf("failed to stop %s: %v", current.Name, err)
 			return result, &SwitchError{FromMode: current.Name, ToMode: targetMode, Reason: err.Error()}
@@ -130,16 +166,24 @@ func (m *ProxmoxManager) Switch(ctx context.Context, targetMode string, opts Swi
 	}
 
 	// Wait for start task to complete
-	if _, err := m.client.WaitForTask(ctx, upid, time.Second); err != nil {
+	status, err := m.client.WaitForTask(ctx, upid, m.pollInterval)
+	if err != nil {
 		result.Error = fmt.Sprintf("start task failed: %v", err)
 		return result, &SwitchError{FromMode: result.FromMode, ToMode: targetMode, Reason: err.Error()}
 	}
+	if err := m.checkStartTask(ctx, targetVMID, status, devices); err != nil {
+		result.Error = err.Error()
+		if _, ok := err.(*GPUPassthroughError); ok {
+			return result, err
+		}
+		return result, &SwitchError{FromMode: result.FromMode, ToMode: targetMode, Reason: err.Error()}
+	}
 
 	// Wait for VM to be running
 	waitCtx, cancel := context.WithTimeout(ctx, opts.StartupTimeout)
 	defer cancel()
 
-	if err := m.client.WaitForVMStatus(waitCtx, targetVMID, proxmox.VMStatusRunning, time.Second); err != nil {
+	if err := m.client.WaitForVMStatus(waitCtx, targetVMID, proxmox.VMStatusRunning, m.pollInterval); err != nil {
 		result.Error = fmt.Sprintf("timeout waiting for %s to start: %v", targetMode, err)
 		return result, &SwitchError{FromMode: result.FromMode, ToMode: targetMode, Reason: err.Error()}
 	}
@@ -152,6 +196,9 @@ func (m *ProxmoxManager) Switch(ctx context.Context, targetMode string, opts Swi
 		}
 	}
 
+	// The switch stands if a post-start hook fails; the results tell
+	_ = m.runHooks(ctx, HookPostStart, targetMode, targetVMID, result)
+
 	result.Success = true
 	result.Duration = time.Since(startTime)
 	return result, nil
@@ -275,7 +322,7 @@ func (m *ProxmoxManager) stopVM(ctx context.Context, vmid int, opts SwitchOption
 	}
 
 	// Wait for shutdown task
-	status, err := m.client.WaitForTask(stopCtx, upid, time.Second)
+	status, err := m.client.WaitForTask(stopCtx, upid, m.pollInterval)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,182 @@
+package bootmode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// scheduleLookback is how far back Current looks for the last firing
+const scheduleLookback = 31 * 24 * time.Hour
+
+// ScheduleEntry switches to a mode when its cron expression fires
+type ScheduleEntry struct {
+	Cron string `yaml:"cron"` // e.g. "0 22 * * *"
+	Mode string `yaml:"mode"`
+
+	cron *Cron
+}
+
+// Schedule is a timetable of modes, e.g. windows in the evening and linux
+// for the night
+type Schedule struct {
+	Entries  []ScheduleEntry
+	Location *time.Location // Cron expressions are read in it
+}
+
+// NewSchedule parses the cron expressions of entries, read in loc (local
+// time if nil)
+func NewSchedule(entries []ScheduleEntry, loc *time.Location) (*Schedule, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("schedule has no entries")
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	s := &Schedule{Location: loc}
+	for i, e := range entries {
+		if e.Mode == "" {
+			return nil, fmt.Errorf("schedule entry %d (%s) has no mode", i+1, e.Cron)
+		}
+		cron, err := ParseCron(e.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("schedule entry %d: %w", i+1, err)
+		}
+		e.cron = cron
+		s.Entries = append(s.Entries, e)
+	}
+	return s, nil
+}
+
+// Next returns the entry that fires first after t, and when. Of entries
+// firing at the same time, the last listed wins.
+func (s *Schedule) Next(t time.Time) (ScheduleEntry, time.Time, bool) {
+	var next ScheduleEntry
+	var at time.Time
+	for _, e := range s.Entries {
+		fires := e.cron.Next(t.In(s.Location))
+		if !fires.IsZero() && (at.IsZero() || !fires.After(at)) {
+			next, at = e, fires
+		}
+	}
+	return next, at, !at.IsZero()
+}
+
+// Current returns the entry that fired last up to t, and when: the mode
+// the schedule says should be running. It looks back a month at most.
+func (s *Schedule) Current(t time.Time) (ScheduleEntry, time.Time, bool) {
+	var current ScheduleEntry
+	var at time.Time
+	for _, e := range s.Entries {
+		// Step through the firings in the lookback, keeping the last
+		var last time.Time
+		for fires := e.cron.Next(t.Add(-scheduleLookback).In(s.Location)); !fires.IsZero() && !fires.After(t); fires = e.cron.Next(fires) {
+			last = fires
+		}
+		if !last.IsZero() && (at.IsZero() || !last.Before(at)) {
+			current, at = e, last
+		}
+	}
+	return current, at, !at.IsZero()
+}
+
+// Scheduler switches modes as a schedule says
+type Scheduler struct {
+	manager  Manager
+	schedule *Schedule
+
+	// Options are used for every switch
+	Options SwitchOptions
+
+	// SwitchTimeout bounds each switch (default 10m)
+	SwitchTimeout time.Duration
+
+	// OnSwitch, if set, is called after every switch the scheduler makes,
+	// with the entry that fired. Switches to the running mode are not
+	// errors; their result has Success set.
+	OnSwitch func(entry ScheduleEntry, result *SwitchResult, err error)
+
+	now func() time.Time
+}
+
+// NewScheduler returns a scheduler that switches the modes of manager
+func NewScheduler(manager Manager, schedule *Schedule) *Scheduler {
+	return &Scheduler{
+		manager:       manager,
+		schedule:      schedule,
+		Options:       DefaultSwitchOptions(),
+		SwitchTimeout: 10 * time.Minute,
+		now:           time.Now,
+	}
+}
+
+// CatchUp switches to the mode the schedule says should be running now,
+// e.g. after the scheduler was down when an entry fired. It returns false
+// if no entry fired within the last month.
+func (s *Scheduler) CatchUp(ctx context.Context) (bool, error) {
+	entry, _, ok := s.schedule.Current(s.now())
+	if !ok {
+		return false, nil
+	}
+	return true, s.switchTo(ctx, entry)
+}
+
+// Run switches modes as entries fire until ctx is cancelled. A failed
+// switch is reported to OnSwitch and does not stop it; the next entry
+// tries again.
+func (s *Scheduler) Run(ctx context.Context) error {
+	last := s.now()
+	for {
+		entry, at, ok := s.schedule.Next(last)
+		if !ok {
+			return fmt.Errorf("schedule never fires")
+		}
+		if err := s.sleepUntil(ctx, at); err != nil {
+			return err
+		}
+		_ = s.switchTo(ctx, entry)
+		last = at
+	}
+}
+
+// sleepUntil waits until t or ctx is cancelled. It checks the clock at
+// least every minute, so that it is not thrown off by suspend or clock
+// changes.
+func (s *Scheduler) sleepUntil(ctx context.Context, t time.Time) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		wait := t.Sub(s.now())
+		if wait <= 0 {
+			return nil
+		}
+		if wait > time.Minute {
+			wait = time.Minute
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// switchTo switches to the mode of an entry and reports the result
+func (s *Scheduler) switchTo(ctx context.Context, entry ScheduleEntry) error {
+	switchCtx, cancel := context.WithTimeout(ctx, s.SwitchTimeout)
+	defer cancel()
+
+	result, err := s.manager.Switch(switchCtx, entry.Mode, s.Options)
+	var active *AlreadyActiveError
+	if errors.As(err, &active) {
+		err = nil
+	}
+	if s.OnSwitch != nil {
+		s.OnSwitch(entry, result, err)
+	}
+	return err
+}
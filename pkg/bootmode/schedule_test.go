@@ -0,0 +1,139 @@
+package bootmode
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// switchRecorder is a Manager that records the switches asked of it
+type switchRecorder struct {
+	Manager
+	current  string
+	switches []string
+	err      error
+}
+
+func (r *switchRecorder) Switch(ctx context.Context, targetMode string, opts SwitchOptions) (*SwitchResult, error) {
+	result := &SwitchResult{FromMode: r.current, ToMode: targetMode}
+	if r.current == targetMode {
+		return result, &AlreadyActiveError{Mode: targetMode}
+	}
+	r.switches = append(r.switches, targetMode)
+	if r.err != nil {
+		return result, r.err
+	}
+	r.current = targetMode
+	result.Success = true
+	return result, nil
+}
+
+func nightSchedule(t *testing.T) *Schedule {
+	t.Helper()
+	s, err := NewSchedule([]ScheduleEntry{
+		{Cron: "0 23 * * *", Mode: "linux"},
+		{Cron: "0 8 * * mon-fri", Mode: "windows"},
+	}, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestNewSchedule_Invalid(t *testing.T) {
+	for _, entries := range [][]ScheduleEntry{
+		nil,
+		{{Cron: "0 23 * * *"}},
+		{{Cron: "0 25 * * *", Mode: "linux"}},
+	} {
+		if _, err := NewSchedule(entries, nil); err == nil {
+			t.Errorf("NewSchedule(%v) should fail", entries)
+		}
+	}
+}
+
+func TestSchedule_NextAndCurrent(t *testing.T) {
+	s := nightSchedule(t)
+
+	// Saturday morning: no windows entry at the weekend
+	sat := time.Date(2026, 3, 7, 10, 0, 0, 0, time.UTC)
+	entry, at, ok := s.Next(sat)
+	if !ok || entry.Mode != "linux" || !at.Equal(time.Date(2026, 3, 7, 23, 0, 0, 0, time.UTC)) {
+		t.Errorf("Next = %s at %s, %v", entry.Mode, at, ok)
+	}
+	entry, at, ok = s.Current(sat)
+	if !ok || entry.Mode != "linux" || !at.Equal(time.Date(2026, 3, 6, 23, 0, 0, 0, time.UTC)) {
+		t.Errorf("Current = %s at %s, %v", entry.Mode, at, ok)
+	}
+
+	// Sunday night until Monday morning
+	entry, at, ok = s.Next(time.Date(2026, 3, 8, 23, 0, 0, 0, time.UTC))
+	if !ok || entry.Mode != "windows" || !at.Equal(time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Next = %s at %s, %v", entry.Mode, at, ok)
+	}
+	if entry, _, _ := s.Current(time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC)); entry.Mode != "windows" {
+		t.Errorf("Current at 8:00 = %s, want windows", entry.Mode)
+	}
+}
+
+func TestScheduler_CatchUp(t *testing.T) {
+	manager := &switchRecorder{current: "windows"}
+	s := NewScheduler(manager, nightSchedule(t))
+	s.now = func() time.Time { return time.Date(2026, 3, 4, 2, 0, 0, 0, time.UTC) }
+	var reported []string
+	s.OnSwitch = func(entry ScheduleEntry, result *SwitchResult, err error) {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		reported = append(reported, entry.Mode)
+	}
+
+	for i := 0; i < 2; i++ {
+		ok, err := s.CatchUp(context.Background())
+		if !ok || err != nil {
+			t.Fatalf("CatchUp = %v, %v", ok, err)
+		}
+	}
+	// The second catch-up finds linux running and is no error
+	if len(manager.switches) != 1 || manager.switches[0] != "linux" {
+		t.Errorf("switches = %v, want [linux]", manager.switches)
+	}
+	if len(reported) != 2 {
+		t.Errorf("reported %d switches, want 2", len(reported))
+	}
+}
+
+func TestScheduler_Run(t *testing.T) {
+	s, err := NewSchedule([]ScheduleEntry{{Cron: "* * * * *", Mode: "linux"}}, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := &switchRecorder{current: "windows", err: errors.New("VM did not start")}
+	scheduler := NewScheduler(manager, s)
+
+	// The clock jumps a minute every time it is read, so every entry is due
+	clock := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)
+	scheduler.now = func() time.Time {
+		clock = clock.Add(time.Minute)
+		return clock
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	failures := 0
+	scheduler.OnSwitch = func(entry ScheduleEntry, result *SwitchResult, err error) {
+		if err == nil {
+			t.Error("expected the switch to fail")
+		}
+		if failures++; failures == 3 {
+			cancel()
+		}
+	}
+
+	if err := scheduler.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run = %v, want context.Canceled", err)
+	}
+	// A failed switch does not stop the scheduler
+	if len(manager.switches) < 3 {
+		t.Errorf("switches = %v, want at least 3", manager.switches)
+	}
+}
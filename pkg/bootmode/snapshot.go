@@ -0,0 +1,235 @@
+package bootmode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/machine/proxmox"
+)
+
+// snapshotKind is a kind of snapshot a switch takes, told apart by the
+// prefix of its name. The name also records a mode: <prefix><mode>-<time>.
+type snapshotKind struct {
+	prefix      string
+	description string
+}
+
+var (
+	// preSwitch snapshots are taken of the target VM by Switch with
+	// Snapshot, recording the mode that was running; Revert restores them
+	preSwitch = snapshotKind{prefix: "preswitch-", description: "Taken by morpheus before switching modes"}
+
+	// preStop snapshots are taken of the current VM by Switch with
+	// SnapshotBeforeSwitch, recording the mode switched to; Rollback
+	// restores them
+	preStop = snapshotKind{prefix: "prestop-", description: "Taken by morpheus before stopping the VM for a mode switch"}
+)
+
+// noMode is recorded when no mode was running before the switch
+const noMode = "none"
+
+func (k snapshotKind) name(mode string, t time.Time) string {
+	if mode == "" {
+		mode = noMode
+	}
+	return k.prefix + mode + "-" + t.UTC().Format("20060102-150405")
+}
+
+// parse returns the mode recorded in a snapshot name of the kind
+func (k snapshotKind) parse(name string) (mode string, ok bool) {
+	rest, ok := strings.CutPrefix(name, k.prefix)
+	if !ok {
+		return "", false
+	}
+	mode, _, ok = strings.Cut(rest, "-")
+	if !ok || mode == "" {
+		return "", false
+	}
+	if mode == noMode {
+		mode = ""
+	}
+	return mode, true
+}
+
+// takeSnapshot snapshots a VM for a switch. Older snapshots of the same
+// kind of the VM are deleted, so only the latest can be restored.
+func (m *ProxmoxManager) takeSnapshot(ctx context.Context, vmid int, kind snapshotKind, mode string) (string, error) {
+	snapshots, err := m.client.ListSnapshots(ctx, vmid)
+	if err != nil {
+		return "", err
+	}
+	for _, snap := range snapshots {
+		if _, ok := kind.parse(snap.Name); ok {
+			if err := m.runTask(ctx, func() (string, error) { return m.client.DeleteSnapshot(ctx, vmid, snap.Name) }); err != nil {
+				return "", fmt.Errorf("delete old snapshot %s: %w", snap.Name, err)
+			}
+		}
+	}
+
+	name := kind.name(mode, time.Now())
+	if err := m.runTask(ctx, func() (string, error) { return m.client.CreateSnapshot(ctx, vmid, name, kind.description) }); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// latestSnapshot finds the most recent snapshot of a kind of either mode
+func (m *ProxmoxManager) latestSnapshot(ctx context.Context, kind snapshotKind) (mode string, snap *proxmox.Snapshot, err error) {
+	for _, name := range []string{"linux", "windows"} {
+		vmid, _ := m.getVMID(name)
+		snapshots, err := m.client.ListSnapshots(ctx, vmid)
+		if err != nil {
+			return "", nil, fmt.Errorf("list %s snapshots: %w", name, err)
+		}
+		for _, s := range snapshots {
+			if _, ok := kind.parse(s.Name); ok && (snap == nil || s.SnapTime > snap.SnapTime) {
+				mode, snap = name, s
+			}
+		}
+	}
+	if snap == nil {
+		return "", nil, &NoSnapshotError{Rollback: kind == preStop}
+	}
+	return mode, snap, nil
+}
+
+// Revert rolls the mode switched to with a snapshot back to its pre-switch
+// snapshot and starts the previous mode again. The snapshot is deleted
+// afterwards.
+func (m *ProxmoxManager) Revert(ctx context.Context, opts SwitchOptions) (*SwitchResult, error) {
+	startTime := time.Now()
+
+	mode, snap, err := m.latestSnapshot(ctx, preSwitch)
+	if err != nil {
+		return &SwitchResult{Error: err.Error()}, err
+	}
+	fromMode, _ := preSwitch.parse(snap.Name)
+	result := &SwitchResult{FromMode: mode, ToMode: fromMode, Snapshot: snap.Name}
+
+	if opts.DryRun {
+		result.Success = true
+		result.Duration = time.Since(startTime)
+		return result, nil
+	}
+
+	fail := func(err error) (*SwitchResult, error) {
+		result.Error = err.Error()
+		return result, &SwitchError{FromMode: mode, ToMode: fromMode, Reason: err.Error()}
+	}
+
+	vmid, _ := m.getVMID(mode)
+	vm, err := m.client.GetVM(ctx, vmid)
+	if err != nil {
+		return fail(err)
+	}
+	if vm.Status == proxmox.VMStatusRunning {
+		if err := m.stopVM(ctx, vmid, opts); err != nil {
+			return fail(fmt.Errorf("failed to stop %s: %w", mode, err))
+		}
+	}
+
+	if err := m.runTask(ctx, func() (string, error) { return m.client.RollbackSnapshot(ctx, vmid, snap.Name) }); err != nil {
+		return fail(fmt.Errorf("failed to roll back %s: %w", mode, err))
+	}
+	// Best effort: a leftover snapshot is replaced by the next switch
+	m.runTask(ctx, func() (string, error) { return m.client.DeleteSnapshot(ctx, vmid, snap.Name) })
+
+	if fromMode == "" {
+		// Nothing was running before the switch
+		result.Success = true
+		result.Duration = time.Since(startTime)
+		return result, nil
+	}
+
+	opts.Snapshot = false
+	switched, err := m.Switch(ctx, fromMode, opts)
+	var active *AlreadyActiveError
+	if err != nil && !errors.As(err, &active) {
+		return result, err
+	}
+	result.IPAddress = switched.IPAddress
+	result.Hooks = switched.Hooks
+	result.Success = true
+	result.Duration = time.Since(startTime)
+	return result, nil
+}
+
+// Rollback restores the mode switched away from with SnapshotBeforeSwitch
+// to its pre-stop snapshot and starts it again. The mode switched to is
+// stopped first, without its pre-shutdown hooks: it may be what failed.
+// The snapshot is deleted afterwards.
+func (m *ProxmoxManager) Rollback(ctx context.Context, opts SwitchOptions) (*SwitchResult, error) {
+	startTime := time.Now()
+
+	mode, snap, err := m.latestSnapshot(ctx, preStop)
+	if err != nil {
+		return &SwitchResult{Error: err.Error()}, err
+	}
+	result := &SwitchResult{ToMode: mode, Snapshot: snap.Name}
+	current, err := m.GetCurrentMode(ctx)
+	if err != nil {
+		result.Error = err.Error()
+		return result, err
+	}
+	if current != nil {
+		result.FromMode = current.Name
+	}
+
+	if opts.DryRun {
+		result.Success = true
+		result.Duration = time.Since(startTime)
+		return result, nil
+	}
+
+	fail := func(err error) (*SwitchResult, error) {
+		result.Error = err.Error()
+		return result, &SwitchError{FromMode: result.FromMode, ToMode: mode, Reason: err.Error()}
+	}
+
+	if current != nil {
+		currentVMID, _ := m.getVMID(current.Name)
+		if err := m.stopVM(ctx, currentVMID, opts); err != nil {
+			return fail(fmt.Errorf("failed to stop %s: %w", current.Name, err))
+		}
+	}
+
+	vmid, _ := m.getVMID(mode)
+	if err := m.runTask(ctx, func() (string, error) { return m.client.RollbackSnapshot(ctx, vmid, snap.Name) }); err != nil {
+		return fail(fmt.Errorf("failed to roll back %s: %w", mode, err))
+	}
+	// Best effort: a leftover snapshot is replaced by the next switch
+	m.runTask(ctx, func() (string, error) { return m.client.DeleteSnapshot(ctx, vmid, snap.Name) })
+
+	opts.Snapshot = false
+	opts.SnapshotBeforeSwitch = false
+	started, err := m.Switch(ctx, mode, opts)
+	if started != nil {
+		result.IPAddress = started.IPAddress
+		result.Hooks = started.Hooks
+	}
+	if err != nil {
+		return result, err
+	}
+	result.Success = true
+	result.Duration = time.Since(startTime)
+	return result, nil
+}
+
+// runTask starts an async task and waits for it to succeed
+func (m *ProxmoxManager) runTask(ctx context.Context, start func() (string, error)) error {
+	upid, err := start()
+	if err != nil {
+		return err
+	}
+	status, err := m.client.WaitForTask(ctx, upid, m.pollInterval)
+	if err != nil {
+		return err
+	}
+	if !status.IsSuccessful() {
+		return fmt.Errorf("task failed: %s", status.ExitStatus)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package bootmode
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotName(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	name := preSwitch.name("linux", at)
+	if name != "preswitch-linux-20260301-123000" {
+		t.Errorf("unexpected name: %s", name)
+	}
+	if from, ok := preSwitch.parse(name); !ok || from != "linux" {
+		t.Errorf("parse(%q) = %q, %v", name, from, ok)
+	}
+
+	if from, ok := preSwitch.parse(preSwitch.name("", at)); !ok || from != "" {
+		t.Errorf("expected no mode, got %q, %v", from, ok)
+	}
+
+	for _, name := range []string{"before-upgrade", "preswitch-", "preswitch-linux"} {
+		if _, ok := preSwitch.parse(name); ok {
+			t.Errorf("parse(%q) should not match", name)
+		}
+	}
+}
+
+func TestRevert_NoSnapshot(t *testing.T) {
+	fake := &fakeProxmox{
+		vms: map[int]*fakeVM{
+			101: {name: "vr-linux", status: "running"},
+			102: {name: "vr-windows", status: "stopped"},
+		},
+		snapshots: map[int][]string{101: {"before-upgrade"}},
+	}
+	m := newFakeProxmoxManager(t, fake)
+
+	_, err := m.Revert(context.Background(), DefaultSwitchOptions())
+	if _, ok := err.(*NoSnapshotError); !ok {
+		t.Fatalf("expected NoSnapshotError, got %v", err)
+	}
+}
+
+func TestRevert(t *testing.T) {
+	// State after "mode windows --snapshot" from linux
+	fake := &fakeProxmox{
+		vms: map[int]*fakeVM{
+			101: {name: "vr-linux", status: "stopped"},
+			102: {name: "vr-windows", status: "running"},
+		},
+		snapshots: map[int][]string{102: {"preswitch-linux-20260301-123000"}},
+	}
+	m := newFakeProxmoxManager(t, fake)
+
+	result, err := m.Revert(context.Background(), DefaultSwitchOptions())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.FromMode != "windows" || result.ToMode != "linux" || !result.Success {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	want := "stop 102,rollback 102 preswitch-linux-20260301-123000,delsnapshot 102 preswitch-linux-20260301-123000"
+	if got := strings.Join(fake.actions, ","); got != want {
+		t.Errorf("actions = %s, want %s", got, want)
+	}
+	if fake.vms[101].status != "running" {
+		t.Error("expected linux to be running again")
+	}
+}
+
+func TestTakeSnapshot_ReplacesOlder(t *testing.T) {
+	fake := &fakeProxmox{
+		vms: map[int]*fakeVM{
+			102: {name: "vr-windows", status: "stopped"},
+		},
+		snapshots: map[int][]string{102: {"before-upgrade", "preswitch-linux-20260301-123000"}},
+	}
+	m := newFakeProxmoxManager(t, fake)
+
+	name, err := m.takeSnapshot(context.Background(), 102, preSwitch, "linux")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := fake.snapshots[102]
+	if len(got) != 2 || got[0] != "before-upgrade" || got[1] != name {
+		t.Errorf("snapshots = %v", got)
+	}
+}
+
+func TestSwitch_SnapshotBeforeSwitch(t *testing.T) {
+	fake := &fakeProxmox{
+		vms: map[int]*fakeVM{
+			101: {name: "vr-linux", status: "running"},
+			102: {name: "vr-windows", status: "stopped"},
+		},
+		snapshots: map[int][]string{},
+	}
+	m := newFakeProxmoxManager(t, fake)
+
+	opts := DefaultSwitchOptions()
+	opts.SnapshotBeforeSwitch = true
+	result, err := m.Switch(context.Background(), "windows", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mode, ok := preStop.parse(result.RollbackSnapshot); !ok || mode != "windows" {
+		t.Errorf("unexpected rollback snapshot %q", result.RollbackSnapshot)
+	}
+	// The running VM is snapshotted before it is stopped
+	if got := strings.Join(fake.actions, ","); got != "snapshot 101,stop 101" {
+		t.Errorf("actions = %s", got)
+	}
+}
+
+func TestRollback_NoSnapshot(t *testing.T) {
+	fake := &fakeProxmox{
+		vms: map[int]*fakeVM{
+			101: {name: "vr-linux", status: "stopped"},
+			102: {name: "vr-windows", status: "running"},
+		},
+		snapshots: map[int][]string{102: {"preswitch-linux-20260301-123000"}},
+	}
+	m := newFakeProxmoxManager(t, fake)
+
+	_, err := m.Rollback(context.Background(), DefaultSwitchOptions())
+	if noSnap, ok := err.(*NoSnapshotError); !ok || !noSnap.Rollback {
+		t.Fatalf("expected NoSnapshotError for rollback, got %v", err)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	// State after "mode windows --snapshot-current" from linux, with
+	// windows failing to come up
+	fake := &fakeProxmox{
+		vms: map[int]*fakeVM{
+			101: {name: "vr-linux", status: "stopped"},
+			102: {name: "vr-windows", status: "running"},
+		},
+		snapshots: map[int][]string{101: {"prestop-windows-20260301-123000"}},
+	}
+	m := newFakeProxmoxManager(t, fake)
+	m.config.Windows.PreShutdown = []Hook{{Run: "exit 1"}}
+
+	result, err := m.Rollback(context.Background(), DefaultSwitchOptions())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.FromMode != "windows" || result.ToMode != "linux" || !result.Success {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	// The failed mode is stopped without its hooks
+	want := "stop 102,rollback 101 prestop-windows-20260301-123000,delsnapshot 101 prestop-windows-20260301-123000"
+	if got := strings.Join(fake.actions, ","); got != want {
+		t.Errorf("actions = %s, want %s", got, want)
+	}
+	if fake.vms[101].status != "running" || fake.vms[102].status != "stopped" {
+		t.Error("expected linux to be running again")
+	}
+}
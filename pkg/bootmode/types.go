@@ -45,12 +45,15 @@ const (
 
 // SwitchResult contains the result of a mode switch operation
 type SwitchResult struct {
-	FromMode  string        `json:"from_mode,omitempty"`
-	ToMode    string        `json:"to_mode"`
-	Success   bool          `json:"success"`
-	Duration  time.Duration `json:"duration"`
-	IPAddress string        `json:"ip_address,omitempty"`
-	Error     string        `json:"error,omitempty"`
+	FromMode         string        `json:"from_mode,omitempty"`
+	ToMode           string        `json:"to_mode"`
+	Success          bool          `json:"success"`
+	Duration         time.Duration `json:"duration"`
+	IPAddress        string        `json:"ip_address,omitempty"`
+	Snapshot         string        `json:"snapshot,omitempty"`          // Pre-switch snapshot taken, or snapshot reverted or rolled back to
+	RollbackSnapshot string        `json:"rollback_snapshot,omitempty"` // Snapshot of the previous mode to roll back to
+	Hooks            []HookResult  `json:"hooks,omitempty"`             // Hooks run, in order
+	Error            string        `json:"error,omitempty"`
 }
 
 // SwitchOptions configures the mode switch behavior
@@ -69,6 +72,15 @@ type SwitchOptions struct {
 
 	// DryRun only shows what would happen without making changes
 	DryRun bool
+
+	// Snapshot takes a snapshot of the target VM before starting it, so the
+	// switch can be undone with Revert
+	Snapshot bool
+
+	// SnapshotBeforeSwitch takes a snapshot of the current mode's VM before
+	// stopping it, so it can be restored with Rollback if the new mode
+	// fails to come up
+	SnapshotBeforeSwitch bool
 }
 
 // DefaultSwitchOptions returns sensible default switch options
@@ -92,6 +104,9 @@ type VRNodeConfig struct {
 
 	// GPU PCI address for passthrough (e.g., "0000:01:00")
 	GPUPCI string `yaml:"gpu_pci"`
+
+	// SSHIdentity is the private key SSH hooks log into the VMs with
+	SSHIdentity string `yaml:"ssh_identity"`
 }
 
 // VMConfig holds configuration for a single VM
@@ -101,6 +116,12 @@ type VMConfig struct {
 	Memory   int    `yaml:"memory"` // MB
 	Cores    int    `yaml:"cores"`
 	DiskSize int    `yaml:"disk_size"` // GB
+
+	// PreShutdown hooks run before the VM is stopped by a switch; one
+	// failing stops the switch. PostStart hooks run once it is up; their
+	// failures are reported but the switch stands.
+	PreShutdown []Hook `yaml:"pre_shutdown"`
+	PostStart   []Hook `yaml:"post_start"`
 }
 
 // ModeInfo contains detailed information about a mode
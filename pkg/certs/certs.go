@@ -0,0 +1,143 @@
+// Package certs issues TLS certificates from an ACME CA such as Let's
+// Encrypt. Challenges are answered with DNS-01 TXT records created through
+// a dns.Provider, so wildcard certificates can be issued centrally and
+// copied to nodes without giving the nodes a DNS token. Certificates and
+// the ACME account key are kept in a secret store under "certs/".
+package certs
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/secretstore"
+)
+
+// Secret store keys
+const (
+	domainsPrefix  = "certs/domains/"
+	accountsPrefix = "certs/accounts/"
+	chainFile      = "fullchain.pem"
+	keyFile        = "privkey.pem"
+)
+
+// Certificate is an issued certificate with its private key
+type Certificate struct {
+	Domain   string    // The domain it is stored under, e.g. "example.com"
+	Names    []string  // DNS names it is valid for, e.g. "example.com", "*.example.com"
+	NotAfter time.Time // When it expires
+	ChainPEM []byte    // The certificate followed by the intermediates
+	KeyPEM   []byte
+}
+
+// Names returns the names to request for a domain: the domain and, with
+// wildcard, every name directly below it
+func Names(domain string, wildcard bool) []string {
+	if wildcard {
+		return []string{domain, "*." + domain}
+	}
+	return []string{domain}
+}
+
+// ParseCertificate parses a PEM certificate chain and private key
+func ParseCertificate(domain string, chainPEM, keyPEM []byte) (*Certificate, error) {
+	block, _ := pem.Decode(chainPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM certificate for %s", domain)
+	}
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate for %s: %w", domain, err)
+	}
+	if key, _ := pem.Decode(keyPEM); key == nil {
+		return nil, fmt.Errorf("no PEM private key for %s", domain)
+	}
+	return &Certificate{
+		Domain:   domain,
+		Names:    leaf.DNSNames,
+		NotAfter: leaf.NotAfter,
+		ChainPEM: chainPEM,
+		KeyPEM:   keyPEM,
+	}, nil
+}
+
+// Wildcard reports whether the certificate covers names below its domain
+func (c *Certificate) Wildcard() bool {
+	for _, name := range c.Names {
+		if strings.HasPrefix(name, "*.") {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpiresWithin reports whether the certificate expires within d of now
+func (c *Certificate) ExpiresWithin(d time.Duration, now time.Time) bool {
+	return c.NotAfter.Sub(now) < d
+}
+
+// SaveCertificate stores a certificate under its domain
+func SaveCertificate(ctx context.Context, store secretstore.Store, c *Certificate) error {
+	if err := secretstore.ValidateKey(domainsPrefix + c.Domain); err != nil {
+		return err
+	}
+	if err := store.Put(ctx, domainsPrefix+c.Domain+"/"+keyFile, c.KeyPEM); err != nil {
+		return fmt.Errorf("failed to store the key of %s: %w", c.Domain, err)
+	}
+	if err := store.Put(ctx, domainsPrefix+c.Domain+"/"+chainFile, c.ChainPEM); err != nil {
+		return fmt.Errorf("failed to store the certificate of %s: %w", c.Domain, err)
+	}
+	return nil
+}
+
+// LoadCertificate returns the certificate stored under a domain; the error
+// wraps secretstore.ErrNotFound if there is none
+func LoadCertificate(ctx context.Context, store secretstore.Store, domain string) (*Certificate, error) {
+	if err := secretstore.ValidateKey(domainsPrefix + domain); err != nil {
+		return nil, err
+	}
+	chainPEM, err := store.Get(ctx, domainsPrefix+domain+"/"+chainFile)
+	if err != nil {
+		return nil, fmt.Errorf("certificate of %s: %w", domain, err)
+	}
+	keyPEM, err := store.Get(ctx, domainsPrefix+domain+"/"+keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("key of %s: %w", domain, err)
+	}
+	return ParseCertificate(domain, chainPEM, keyPEM)
+}
+
+// DeleteCertificate removes the certificate stored under a domain
+func DeleteCertificate(ctx context.Context, store secretstore.Store, domain string) error {
+	if err := secretstore.ValidateKey(domainsPrefix + domain); err != nil {
+		return err
+	}
+	return secretstore.DeletePrefix(ctx, store, domainsPrefix+domain+"/")
+}
+
+// ListCertificates returns the stored certificates, sorted by domain
+func ListCertificates(ctx context.Context, store secretstore.Store) ([]*Certificate, error) {
+	keys, err := store.List(ctx, domainsPrefix)
+	if err != nil {
+		return nil, err
+	}
+	var result []*Certificate
+	for _, key := range keys {
+		domain, ok := strings.CutSuffix(strings.TrimPrefix(key, domainsPrefix), "/"+chainFile)
+		if !ok {
+			continue
+		}
+		c, err := LoadCertificate(ctx, store, domain)
+		if err != nil && !errors.Is(err, secretstore.ErrNotFound) {
+			return nil, err
+		}
+		if c != nil {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,158 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/nimsforest/morpheus/internal/hetznermock"
+	"github.com/nimsforest/morpheus/pkg/dns"
+	dnshetzner "github.com/nimsforest/morpheus/pkg/dns/hetzner"
+	"github.com/nimsforest/morpheus/pkg/secretstore"
+)
+
+// selfSigned returns a PEM certificate and key for names
+func selfSigned(t *testing.T, names []string, notAfter time.Time) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestCertificateStore(t *testing.T) {
+	ctx := context.Background()
+	store := secretstore.NewDirStore(t.TempDir())
+	notAfter := time.Now().Add(20 * 24 * time.Hour).Truncate(time.Second)
+
+	chainPEM, keyPEM := selfSigned(t, Names("example.com", true), notAfter)
+	cert, err := ParseCertificate("example.com", chainPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+	if !cert.Wildcard() || !cert.NotAfter.Equal(notAfter) {
+		t.Errorf("ParseCertificate() = names %v, expiry %s", cert.Names, cert.NotAfter)
+	}
+	if !cert.ExpiresWithin(30*24*time.Hour, time.Now()) || cert.ExpiresWithin(7*24*time.Hour, time.Now()) {
+		t.Error("ExpiresWithin() does not compare with the expiry")
+	}
+	if _, err := ParseCertificate("example.com", keyPEM, keyPEM); err == nil {
+		t.Error("ParseCertificate() of a key as certificate: expected error")
+	}
+
+	if err := SaveCertificate(ctx, store, cert); err != nil {
+		t.Fatalf("SaveCertificate() error = %v", err)
+	}
+	chainPEM, keyPEM = selfSigned(t, Names("app.example.org", false), notAfter)
+	other, _ := ParseCertificate("app.example.org", chainPEM, keyPEM)
+	if err := SaveCertificate(ctx, store, other); err != nil {
+		t.Fatalf("SaveCertificate() error = %v", err)
+	}
+
+	certs, err := ListCertificates(ctx, store)
+	if err != nil || len(certs) != 2 || certs[0].Domain != "app.example.org" || certs[1].Domain != "example.com" {
+		t.Fatalf("ListCertificates() = %v, %v", certs, err)
+	}
+	if certs[0].Wildcard() {
+		t.Error("Wildcard() of a certificate for one name = true")
+	}
+
+	if err := DeleteCertificate(ctx, store, "example.com"); err != nil {
+		t.Fatalf("DeleteCertificate() error = %v", err)
+	}
+	if _, err := LoadCertificate(ctx, store, "example.com"); !errors.Is(err, secretstore.ErrNotFound) {
+		t.Errorf("LoadCertificate() after delete error = %v, want ErrNotFound", err)
+	}
+	if _, err := LoadCertificate(ctx, store, "../x"); err == nil {
+		t.Error("LoadCertificate() of an invalid domain: expected error")
+	}
+}
+
+func TestChallengeRecords(t *testing.T) {
+	mock := hetznermock.NewServer()
+	t.Cleanup(mock.Close)
+	mock.AddZone("example.com")
+	mock.AddZone("app.example.com")
+	provider, err := dnshetzner.NewProviderWithEndpoint("test-token", mock.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// A value left by someone else must survive the cleanup
+	if _, err := provider.CreateRecord(ctx, dns.CreateRecordRequest{Domain: "example.com", Name: "_acme-challenge", Type: dns.RecordTypeTXT, Value: `"other"`}); err != nil {
+		t.Fatal(err)
+	}
+
+	pending := []*challenge{
+		{fqdn: "_acme-challenge.example.com", value: "apex"},
+		{fqdn: "_acme-challenge.example.com", value: "wildcard"},
+		{fqdn: "_acme-challenge.www.app.example.com", value: "www"},
+	}
+	records, err := newChallengeRecords(ctx, provider, pending)
+	if err != nil {
+		t.Fatalf("newChallengeRecords() error = %v", err)
+	}
+	if set := records.sets["_acme-challenge.www.app.example.com"]; set.zone.Name != "app.example.com" || set.name != "_acme-challenge.www" {
+		t.Errorf("challenge set = zone %s name %s, want the longest matching zone", set.zone.Name, set.name)
+	}
+	if err := records.publish(ctx); err != nil {
+		t.Fatalf("publish() error = %v", err)
+	}
+
+	values := func(zone, name string) []string {
+		list, err := provider.ListRecords(ctx, zone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result []string
+		for _, r := range list {
+			if r.Name == name && r.Type == dns.RecordTypeTXT {
+				result = append(result, r.Value)
+			}
+		}
+		return result
+	}
+	if got := values("example.com", "_acme-challenge"); len(got) != 3 {
+		t.Errorf("published values = %v, want other, apex and wildcard", got)
+	}
+	if got := values("app.example.com", "_acme-challenge.www"); len(got) != 1 || got[0] != `"www"` {
+		t.Errorf("published values = %v, want www", got)
+	}
+
+	if err := records.cleanup(ctx); err != nil {
+		t.Fatalf("cleanup() error = %v", err)
+	}
+	if got := values("example.com", "_acme-challenge"); len(got) != 1 || got[0] != `"other"` {
+		t.Errorf("values after cleanup = %v, want the one there before", got)
+	}
+	if got := values("app.example.com", "_acme-challenge.www"); len(got) != 0 {
+		t.Errorf("values after cleanup = %v, want none", got)
+	}
+
+	if _, err := newChallengeRecords(ctx, provider, []*challenge{{fqdn: "_acme-challenge.example.net"}}); err == nil {
+		t.Error("newChallengeRecords() outside the zones: expected error")
+	}
+}
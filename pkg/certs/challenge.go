@@ -0,0 +1,119 @@
+package certs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/acme"
+
+	"github.com/nimsforest/morpheus/pkg/dns"
+)
+
+// challenge is a pending DNS-01 challenge
+type challenge struct {
+	authzURL string
+	chal     *acme.Challenge
+	fqdn     string // e.g. "_acme-challenge.example.com"
+	value    string // TXT value, unquoted
+}
+
+// challengeSet is the TXT RRSet holding the challenge values of one name.
+// A domain and its wildcard share the name, so a set can hold several.
+type challengeSet struct {
+	zone     *dns.Zone
+	name     string   // Relative to the zone, e.g. "_acme-challenge.www"
+	existing []string // Values the RRSet held before, restored on cleanup
+	values   []string // Quoted challenge values
+}
+
+// challengeRecords publishes and removes the TXT records of challenges
+type challengeRecords struct {
+	provider dns.Provider
+	sets     map[string]*challengeSet // fqdn -> set
+}
+
+// newChallengeRecords finds the zone of every challenge name and the TXT
+// values the names already hold
+func newChallengeRecords(ctx context.Context, provider dns.Provider, pending []*challenge) (*challengeRecords, error) {
+	zones, err := provider.ListZones(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list zones: %w", err)
+	}
+
+	r := &challengeRecords{provider: provider, sets: make(map[string]*challengeSet)}
+	listed := make(map[string][]*dns.Record)
+	for _, c := range pending {
+		set, ok := r.sets[c.fqdn]
+		if !ok {
+			zone := FindZone(zones, c.fqdn)
+			if zone == nil {
+				return nil, fmt.Errorf("no zone found for %s", c.fqdn)
+			}
+			records, ok := listed[zone.Name]
+			if !ok {
+				if records, err = provider.ListRecords(ctx, zone.Name); err != nil {
+					return nil, fmt.Errorf("failed to list records of %s: %w", zone.Name, err)
+				}
+				listed[zone.Name] = records
+			}
+
+			set = &challengeSet{zone: zone, name: strings.TrimSuffix(c.fqdn, "."+zone.Name)}
+			for _, rec := range records {
+				if rec.Name == set.name && rec.Type == dns.RecordTypeTXT {
+					set.existing = append(set.existing, rec.Value)
+				}
+			}
+			r.sets[c.fqdn] = set
+		}
+		set.values = append(set.values, `"`+c.value+`"`)
+	}
+	return r, nil
+}
+
+// publish adds the challenge values to their RRSets
+func (r *challengeRecords) publish(ctx context.Context) error {
+	for fqdn, set := range r.sets {
+		values := append(append([]string(nil), set.existing...), set.values...)
+		if _, err := dns.ApplyRecordSet(ctx, r.provider, set.zone.Name, dns.RecordSet{Name: set.name, Type: dns.RecordTypeTXT, TTL: challengeTTL, Values: values}); err != nil {
+			return fmt.Errorf("failed to publish %s: %w", fqdn, err)
+		}
+	}
+	return nil
+}
+
+// cleanup restores the RRSets to what they held before
+func (r *challengeRecords) cleanup(ctx context.Context) error {
+	var errs []error
+	for fqdn, set := range r.sets {
+		var err error
+		if len(set.existing) == 0 {
+			err = r.provider.DeleteRecord(ctx, set.zone.Name, set.name, string(dns.RecordTypeTXT))
+		} else {
+			_, err = dns.ApplyRecordSet(ctx, r.provider, set.zone.Name, dns.RecordSet{Name: set.name, Type: dns.RecordTypeTXT, Values: set.existing})
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", fqdn, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// nameservers returns the nameservers of a challenge's zone
+func (r *challengeRecords) nameservers(c *challenge) []string {
+	return r.sets[c.fqdn].zone.Nameservers
+}
+
+// FindZone returns the zone a name belongs to: the one with the longest
+// name that is the name or a parent of it, or nil
+func FindZone(zones []*dns.Zone, name string) *dns.Zone {
+	name = strings.TrimSuffix(name, ".")
+	var best *dns.Zone
+	for _, z := range zones {
+		if (name == z.Name || strings.HasSuffix(name, "."+z.Name)) && (best == nil || len(z.Name) > len(best.Name)) {
+			best = z
+		}
+	}
+	return best
+}
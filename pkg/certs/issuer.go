@@ -0,0 +1,276 @@
+package certs
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme"
+
+	"github.com/nimsforest/morpheus/pkg/dns"
+	"github.com/nimsforest/morpheus/pkg/secretstore"
+)
+
+// ACME directories
+const (
+	LetsEncryptURL        = acme.LetsEncryptURL
+	LetsEncryptStagingURL = "https://acme-staging-v02.api.letsencrypt.org/directory"
+)
+
+// challengeTTL is the TTL of the challenge TXT records, short so that a
+// retry is not answered from a cache
+const challengeTTL = 60
+
+// Options configures an Issuer
+type Options struct {
+	// DirectoryURL of the ACME CA (default: Let's Encrypt)
+	DirectoryURL string
+
+	// Email is the account's contact for expiry and policy notices
+	Email string
+
+	// Wait blocks until a challenge record is visible to the CA. It gets
+	// the nameservers of the record's zone, which the CA queries. The
+	// default polls those nameservers with dns.WaitForRecord.
+	Wait func(ctx context.Context, fqdn, value string, nameservers []string) error
+
+	// Progress, if set, is called with a line describing each step
+	Progress func(string)
+}
+
+// Issuer issues certificates with an ACME account whose key is kept in
+// the secret store
+type Issuer struct {
+	client *acme.Client
+	dns    dns.Provider
+	store  secretstore.Store
+	opts   Options
+}
+
+// NewIssuer registers (or looks up) the ACME account and returns an Issuer
+// answering challenges through provider. The CA's terms of service are
+// accepted on the caller's behalf.
+func NewIssuer(ctx context.Context, provider dns.Provider, store secretstore.Store, opts Options) (*Issuer, error) {
+	if opts.DirectoryURL == "" {
+		opts.DirectoryURL = LetsEncryptURL
+	}
+	if opts.Wait == nil {
+		opts.Wait = waitForChallenge
+	}
+
+	key, err := accountKey(ctx, store, opts.DirectoryURL)
+	if err != nil {
+		return nil, err
+	}
+	client := &acme.Client{Key: key, DirectoryURL: opts.DirectoryURL, UserAgent: "morpheus"}
+
+	account := &acme.Account{}
+	if opts.Email != "" {
+		account.Contact = []string{"mailto:" + opts.Email}
+	}
+	if _, err := client.Register(ctx, account, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return nil, fmt.Errorf("failed to register ACME account: %w", err)
+	}
+	return &Issuer{client: client, dns: provider, store: store, opts: opts}, nil
+}
+
+// accountKey loads the account key for a CA from the store, creating it
+// on first use. Keys are per CA so that staging and production accounts
+// do not share one.
+func accountKey(ctx context.Context, store secretstore.Store, directoryURL string) (crypto.Signer, error) {
+	u, err := url.Parse(directoryURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid ACME directory URL: %s", directoryURL)
+	}
+	key := accountsPrefix + strings.ReplaceAll(u.Host, ":", "_") + ".pem"
+
+	data, err := store.Get(ctx, key)
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("invalid ACME account key %s", key)
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	if !errors.Is(err, secretstore.ErrNotFound) {
+		return nil, fmt.Errorf("failed to load ACME account key: %w", err)
+	}
+
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(signer)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Put(ctx, key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})); err != nil {
+		return nil, fmt.Errorf("failed to store ACME account key: %w", err)
+	}
+	return signer, nil
+}
+
+func (i *Issuer) progress(format string, args ...interface{}) {
+	if i.opts.Progress != nil {
+		i.opts.Progress(fmt.Sprintf(format, args...))
+	}
+}
+
+// Issue requests a certificate for names, answers its DNS-01 challenges,
+// and stores it under domain. The challenge records are removed again,
+// also when issuing fails.
+func (i *Issuer) Issue(ctx context.Context, domain string, names []string) (*Certificate, error) {
+	if err := dns.ValidateRecordName(domain); err != nil || domain == "@" || dns.IsWildcard(domain) {
+		return nil, fmt.Errorf("invalid domain %q", domain)
+	}
+	for _, name := range names {
+		if err := dns.ValidateRecordName(name); err != nil {
+			return nil, err
+		}
+	}
+
+	order, err := i.client.AuthorizeOrder(ctx, acme.DomainIDs(names...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create order: %w", err)
+	}
+
+	var pending []*challenge
+	for _, authzURL := range order.AuthzURLs {
+		authz, err := i.client.GetAuthorization(ctx, authzURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get authorization: %w", err)
+		}
+		if authz.Status == acme.StatusValid {
+			continue
+		}
+		var chal *acme.Challenge
+		for _, c := range authz.Challenges {
+			if c.Type == "dns-01" {
+				chal = c
+			}
+		}
+		if chal == nil {
+			return nil, fmt.Errorf("the CA offers no dns-01 challenge for %s", authz.Identifier.Value)
+		}
+		value, err := i.client.DNS01ChallengeRecord(chal.Token)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, &challenge{
+			authzURL: authzURL,
+			chal:     chal,
+			fqdn:     "_acme-challenge." + authz.Identifier.Value,
+			value:    value,
+		})
+	}
+
+	if len(pending) > 0 {
+		if err := i.answer(ctx, pending); err != nil {
+			return nil, err
+		}
+	}
+
+	if order, err = i.client.WaitOrder(ctx, order.URI); err != nil {
+		return nil, fmt.Errorf("order not ready: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: names[0]},
+		DNSNames: names,
+	}, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CSR: %w", err)
+	}
+	i.progress("Finalizing the order")
+	der, _, err := i.client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to finalize order: %w", err)
+	}
+
+	var chainPEM []byte
+	for _, c := range der {
+		chainPEM = append(chainPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c})...)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := ParseCertificate(domain, chainPEM, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	if err != nil {
+		return nil, err
+	}
+	if err := SaveCertificate(ctx, i.store, cert); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// answer publishes the challenge records, waits until the CA can see them,
+// and has the CA validate them
+func (i *Issuer) answer(ctx context.Context, pending []*challenge) error {
+	present, err := newChallengeRecords(ctx, i.dns, pending)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Clean up even if ctx was cancelled
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := present.cleanup(cleanupCtx); err != nil {
+			i.progress("⚠️  Failed to remove challenge records: %s", err)
+		}
+	}()
+
+	for _, c := range pending {
+		i.progress("Publishing TXT %s", c.fqdn)
+	}
+	if err := present.publish(ctx); err != nil {
+		return err
+	}
+	for _, c := range pending {
+		i.progress("Waiting for %s to be visible", c.fqdn)
+		if err := i.opts.Wait(ctx, c.fqdn, c.value, present.nameservers(c)); err != nil {
+			return err
+		}
+	}
+	for _, c := range pending {
+		if _, err := i.client.Accept(ctx, c.chal); err != nil {
+			return fmt.Errorf("failed to accept challenge for %s: %w", c.fqdn, err)
+		}
+	}
+	for _, c := range pending {
+		i.progress("Waiting for the CA to validate %s", c.fqdn)
+		if _, err := i.client.WaitAuthorization(ctx, c.authzURL); err != nil {
+			return fmt.Errorf("validation of %s failed: %w", c.fqdn, err)
+		}
+	}
+	return nil
+}
+
+// waitForChallenge polls the zone's nameservers, or the default resolvers
+// if they are not known, until they all return the challenge value
+func waitForChallenge(ctx context.Context, fqdn, value string, nameservers []string) error {
+	var resolvers []string
+	for _, ns := range nameservers {
+		resolvers = append(resolvers, strings.TrimSuffix(ns, ".")+":53")
+	}
+	return dns.WaitForRecord(ctx, fqdn, dns.RecordTypeTXT, value, dns.WaitOptions{
+		Resolvers: resolvers,
+		Timeout:   5 * time.Minute,
+		Interval:  5 * time.Second,
+	})
+}
@@ -0,0 +1,91 @@
+package cloudcreds
+
+import (
+	"fmt"
+)
+
+// AWSCredentials are static credentials resolved from an AWS profile
+type AWSCredentials struct {
+	Profile         string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // Set for temporary credentials
+	Region          string
+}
+
+// LoadAWSProfile resolves a profile from the AWS shared credentials and
+// config files (~/.aws/credentials, ~/.aws/config, or AWS_SHARED_CREDENTIALS_FILE
+// and AWS_CONFIG_FILE). An empty name uses AWS_PROFILE, then "default".
+// Profiles with source_profile are followed; SSO and credential_process
+// profiles are not supported.
+func LoadAWSProfile(name string) (*AWSCredentials, error) {
+	if name == "" {
+		name = envOr("AWS_PROFILE", "default")
+	}
+
+	credsFile, err := readINI(envOr("AWS_SHARED_CREDENTIALS_FILE", homePath(".aws", "credentials")))
+	if err != nil {
+		return nil, err
+	}
+	configFile, err := readINI(envOr("AWS_CONFIG_FILE", homePath(".aws", "config")))
+	if err != nil {
+		return nil, err
+	}
+
+	creds := &AWSCredentials{Profile: name}
+	seen := map[string]bool{}
+	for profile := name; profile != ""; {
+		if seen[profile] {
+			return nil, fmt.Errorf("AWS profile %s: source_profile loop", name)
+		}
+		seen[profile] = true
+
+		// The config file prefixes non-default profiles with "profile "
+		cfgSection := configFile["profile "+profile]
+		if profile == "default" || cfgSection == nil {
+			cfgSection = mergeSections(configFile[profile], cfgSection)
+		}
+		section := mergeSections(cfgSection, credsFile[profile])
+		if section == nil {
+			return nil, fmt.Errorf("AWS profile %s not found", profile)
+		}
+
+		if creds.Region == "" {
+			creds.Region = section["region"]
+		}
+		if section["aws_access_key_id"] != "" {
+			creds.AccessKeyID = section["aws_access_key_id"]
+			creds.SecretAccessKey = section["aws_secret_access_key"]
+			creds.SessionToken = section["aws_session_token"]
+			break
+		}
+		if section["sso_start_url"] != "" || section["sso_session"] != "" || section["credential_process"] != "" {
+			return nil, fmt.Errorf("AWS profile %s uses SSO or credential_process, which is not supported; export static credentials or use another profile", profile)
+		}
+		profile = section["source_profile"]
+	}
+
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("AWS profile %s has no access keys", name)
+	}
+	if creds.Region == "" {
+		creds.Region = envOr("AWS_REGION", envOr("AWS_DEFAULT_REGION", ""))
+	}
+	return creds, nil
+}
+
+// mergeSections returns the keys of both sections, b taking precedence.
+// It returns nil when both are missing.
+func mergeSections(a, b map[string]string) map[string]string {
+	if a == nil && b == nil {
+		return nil
+	}
+	result := make(map[string]string, len(a)+len(b))
+	for k, v := range a {
+		result[k] = v
+	}
+	for k, v := range b {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,65 @@
+package cloudcreds
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AzureSubscription is a subscription known to the Azure CLI
+type AzureSubscription struct {
+	ID       string
+	Name     string
+	TenantID string
+	User     string
+}
+
+// LoadAzureProfile resolves a subscription from the Azure CLI profile
+// (~/.azure/azureProfile.json, or AZURE_CONFIG_DIR), by name or ID. An
+// empty name or "default" selects the CLI's default subscription
+// ('az account set').
+func LoadAzureProfile(name string) (*AzureSubscription, error) {
+	if name == "default" {
+		name = ""
+	}
+	path := filepath.Join(envOr("AZURE_CONFIG_DIR", homePath(".azure")), "azureProfile.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Azure CLI profile not found (run 'az login'): %w", err)
+	}
+	// The CLI writes the file with a UTF-8 byte order mark
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var profile struct {
+		Subscriptions []struct {
+			ID        string `json:"id"`
+			Name      string `json:"name"`
+			TenantID  string `json:"tenantId"`
+			IsDefault bool   `json:"isDefault"`
+			User      struct {
+				Name string `json:"name"`
+			} `json:"user"`
+		} `json:"subscriptions"`
+	}
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, fmt.Errorf("invalid Azure CLI profile %s: %w", path, err)
+	}
+
+	for _, s := range profile.Subscriptions {
+		match := s.IsDefault
+		if name != "" {
+			match = strings.EqualFold(s.ID, name) || s.Name == name
+		}
+		if match {
+			return &AzureSubscription{ID: s.ID, Name: s.Name, TenantID: s.TenantID, User: s.User.Name}, nil
+		}
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("no default subscription in Azure CLI profile (run 'az account set')")
+	}
+	return nil, fmt.Errorf("subscription %s not found in Azure CLI profile", name)
+}
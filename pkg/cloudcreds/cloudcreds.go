@@ -0,0 +1,72 @@
+// Package cloudcreds resolves cloud credentials from each provider's native
+// tooling (AWS shared config profiles, gcloud configurations and application
+// default credentials, Azure CLI profiles), so config.yaml only has to name a
+// profile instead of holding raw keys.
+package cloudcreds
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// iniFile holds the sections of an INI-style file (AWS and gcloud config
+// files), keyed by section name, then by key
+type iniFile map[string]map[string]string
+
+// readINI parses a simple INI file. A missing file is returned as empty.
+func readINI(path string) (iniFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return iniFile{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	result := iniFile{}
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if result[section] == nil {
+				result[section] = make(map[string]string)
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section == "" {
+			continue
+		}
+		result[section][strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return result, nil
+}
+
+// homePath returns a path below the user's home directory
+func homePath(elem ...string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = os.Getenv("HOME")
+	}
+	return filepath.Join(append([]string{home}, elem...)...)
+}
+
+// envOr returns the value of an environment variable, or def when unset
+func envOr(name, def string) string {
+	if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+		return v
+	}
+	return def
+}
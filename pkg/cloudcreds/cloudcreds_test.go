@@ -0,0 +1,185 @@
+package cloudcreds
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setupAWS(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+
+	writeFile(t, filepath.Join(dir, "credentials"), `
+[default]
+aws_access_key_id = AKIADEFAULT
+aws_secret_access_key = secret-default
+
+[prod]
+aws_access_key_id = AKIAPROD
+aws_secret_access_key = secret-prod
+aws_session_token = token-prod
+`)
+	writeFile(t, filepath.Join(dir, "config"), `
+[default]
+region = eu-central-1
+
+[profile prod]
+region = eu-west-1
+
+[profile deploy]
+source_profile = prod
+
+[profile sso]
+sso_start_url = https://example.awsapps.com/start
+`)
+}
+
+func TestLoadAWSProfile(t *testing.T) {
+	setupAWS(t)
+
+	tests := []struct {
+		name       string
+		profile    string
+		wantKey    string
+		wantRegion string
+		wantToken  string
+	}{
+		{"default", "", "AKIADEFAULT", "eu-central-1", ""},
+		{"named", "prod", "AKIAPROD", "eu-west-1", "token-prod"},
+		{"source profile", "deploy", "AKIAPROD", "eu-west-1", "token-prod"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds, err := LoadAWSProfile(tt.profile)
+			if err != nil {
+				t.Fatalf("LoadAWSProfile() error = %v", err)
+			}
+			if creds.AccessKeyID != tt.wantKey {
+				t.Errorf("AccessKeyID = %q, want %q", creds.AccessKeyID, tt.wantKey)
+			}
+			if creds.Region != tt.wantRegion {
+				t.Errorf("Region = %q, want %q", creds.Region, tt.wantRegion)
+			}
+			if creds.SessionToken != tt.wantToken {
+				t.Errorf("SessionToken = %q, want %q", creds.SessionToken, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestLoadAWSProfileErrors(t *testing.T) {
+	setupAWS(t)
+
+	if _, err := LoadAWSProfile("missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("LoadAWSProfile(missing) error = %v, want not found", err)
+	}
+	if _, err := LoadAWSProfile("sso"); err == nil || !strings.Contains(err.Error(), "SSO") {
+		t.Errorf("LoadAWSProfile(sso) error = %v, want SSO error", err)
+	}
+}
+
+func TestLoadGCPConfiguration(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CLOUDSDK_CONFIG", dir)
+	t.Setenv("CLOUDSDK_ACTIVE_CONFIG_NAME", "")
+	t.Setenv("CLOUDSDK_CORE_PROJECT", "")
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+
+	writeFile(t, filepath.Join(dir, "active_config"), "work\n")
+	writeFile(t, filepath.Join(dir, "configurations", "config_work"), `
+[core]
+account = ops@example.com
+project = forest-prod
+`)
+	writeFile(t, filepath.Join(dir, "configurations", "config_empty"), "[core]\n")
+	writeFile(t, filepath.Join(dir, "application_default_credentials.json"),
+		`{"type": "authorized_user", "client_id": "x"}`)
+
+	creds, err := LoadGCPConfiguration("")
+	if err != nil {
+		t.Fatalf("LoadGCPConfiguration() error = %v", err)
+	}
+	if creds.Configuration != "work" {
+		t.Errorf("Configuration = %q, want %q", creds.Configuration, "work")
+	}
+	if creds.Project != "forest-prod" {
+		t.Errorf("Project = %q, want %q", creds.Project, "forest-prod")
+	}
+	if creds.Account != "ops@example.com" {
+		t.Errorf("Account = %q, want %q", creds.Account, "ops@example.com")
+	}
+	if creds.CredentialsType != "authorized_user" {
+		t.Errorf("CredentialsType = %q, want %q", creds.CredentialsType, "authorized_user")
+	}
+
+	// A service account key supplies the project when the configuration has none
+	keyFile := filepath.Join(t.TempDir(), "key.json")
+	writeFile(t, keyFile, `{"type": "service_account", "project_id": "from-key"}`)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", keyFile)
+
+	creds, err = LoadGCPConfiguration("empty")
+	if err != nil {
+		t.Fatalf("LoadGCPConfiguration(empty) error = %v", err)
+	}
+	if creds.Project != "from-key" || creds.CredentialsFile != keyFile {
+		t.Errorf("got project %q, file %q; want from-key, %s", creds.Project, creds.CredentialsFile, keyFile)
+	}
+
+	if _, err := LoadGCPConfiguration("missing"); err == nil {
+		t.Error("Expected error for missing configuration")
+	}
+}
+
+func TestLoadAzureProfile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AZURE_CONFIG_DIR", dir)
+
+	writeFile(t, filepath.Join(dir, "azureProfile.json"), "\xef\xbb\xbf"+`{
+  "subscriptions": [
+    {"id": "11111111-aaaa", "name": "Dev", "tenantId": "tenant-1", "isDefault": false, "user": {"name": "dev@example.com"}},
+    {"id": "22222222-bbbb", "name": "Prod", "tenantId": "tenant-2", "isDefault": true, "user": {"name": "ops@example.com"}}
+  ]
+}`)
+
+	tests := []struct {
+		name   string
+		want   string
+		wantID string
+	}{
+		{"", "Prod", "22222222-bbbb"},
+		{"default", "Prod", "22222222-bbbb"},
+		{"Dev", "Dev", "11111111-aaaa"},
+		{"11111111-AAAA", "Dev", "11111111-aaaa"},
+	}
+
+	for _, tt := range tests {
+		sub, err := LoadAzureProfile(tt.name)
+		if err != nil {
+			t.Fatalf("LoadAzureProfile(%q) error = %v", tt.name, err)
+		}
+		if sub.Name != tt.want || sub.ID != tt.wantID {
+			t.Errorf("LoadAzureProfile(%q) = %s (%s), want %s (%s)", tt.name, sub.Name, sub.ID, tt.want, tt.wantID)
+		}
+	}
+
+	if _, err := LoadAzureProfile("Staging"); err == nil {
+		t.Error("Expected error for unknown subscription")
+	}
+}
@@ -0,0 +1,77 @@
+package cloudcreds
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GCPCredentials describe a gcloud configuration and the application
+// default credentials (ADC) that Google client libraries will use
+type GCPCredentials struct {
+	Configuration   string // gcloud configuration name
+	Project         string
+	Account         string
+	CredentialsFile string // ADC file; empty when none was found
+	CredentialsType string // "authorized_user", "service_account", ...
+}
+
+// LoadGCPConfiguration resolves a named gcloud configuration (empty for the
+// active one) and locates the application default credentials, from
+// GOOGLE_APPLICATION_CREDENTIALS or 'gcloud auth application-default login'.
+func LoadGCPConfiguration(name string) (*GCPCredentials, error) {
+	dir := envOr("CLOUDSDK_CONFIG", homePath(".config", "gcloud"))
+
+	if name == "" {
+		name = os.Getenv("CLOUDSDK_ACTIVE_CONFIG_NAME")
+	}
+	if name == "" {
+		if data, err := os.ReadFile(filepath.Join(dir, "active_config")); err == nil {
+			name = strings.TrimSpace(string(data))
+		}
+	}
+	if name == "" {
+		name = "default"
+	}
+
+	configPath := filepath.Join(dir, "configurations", "config_"+name)
+	if _, err := os.Stat(configPath); err != nil {
+		return nil, fmt.Errorf("gcloud configuration %s not found (%s)", name, configPath)
+	}
+	cfg, err := readINI(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	creds := &GCPCredentials{
+		Configuration: name,
+		Project:       envOr("CLOUDSDK_CORE_PROJECT", cfg["core"]["project"]),
+		Account:       cfg["core"]["account"],
+	}
+
+	adc := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if adc == "" {
+		adc = filepath.Join(dir, "application_default_credentials.json")
+	}
+	if data, err := os.ReadFile(adc); err == nil {
+		var file struct {
+			Type      string `json:"type"`
+			ProjectID string `json:"project_id"`
+		}
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("invalid application default credentials %s: %w", adc, err)
+		}
+		creds.CredentialsFile = adc
+		creds.CredentialsType = file.Type
+		if creds.Project == "" {
+			creds.Project = file.ProjectID
+		}
+	}
+
+	if creds.Project == "" {
+		return nil, fmt.Errorf("gcloud configuration %s has no project (gcloud config set project ...)", name)
+	}
+	return creds, nil
+}
@@ -21,11 +21,36 @@ type TemplateData struct {
 	NodeID    string // Unique node ID (e.g., "myforest-node-1")
 	NodeIndex int    // Node index (0-based) in the forest
 	NodeCount int    // Total number of nodes in the forest (1=standalone, 3+=cluster)
+	Role      string // Node role, selects the user template (default "node")
+
+	// Peers already in the forest (for user templates)
+	PeerIPs   []string // IPs of the forest's other nodes
+	NATSSeeds []string // NATS cluster routes of the peers; set by Render from PeerIPs
 
 	// StorageBox mount for shared registry (enables NATS peer discovery)
 	StorageBoxHost     string // CIFS host: uXXXXX.your-storagebox.de
 	StorageBoxUser     string // StorageBox username: uXXXXX
 	StorageBoxPassword string // StorageBox password
+
+	// Persistent volume attached at creation (optional)
+	VolumeDevice     string // Device path: /dev/disk/by-id/scsi-0HC_Volume_12345
+	VolumeFilesystem string // Filesystem the volume was formatted with (default ext4)
+	VolumeMountPoint string // Where to mount the volume (default /mnt/data)
+
+	// SSH host key generated by morpheus, so it can be pinned (optional)
+	HostKeyPrivate string
+	HostKeyPublic  string
+
+	// Readiness callback once cloud-init has finished (optional)
+	PhoneHomeURL string // URL to post to with cloud-init's phone_home module
+	ReadyMarker  string // Marker file to write on the StorageBox mount
+
+	// Forest metadata for software on the node, as JSON (optional)
+	Metadata string // Written to /etc/morpheus/metadata.json
+
+	// Floating IP the node may be assigned on failover (optional)
+	FloatingIP       string
+	FloatingIPPrefix int // Set by Generate: 32 for IPv4, 64 for IPv6
 }
 
 // NodeTemplate is the cloud-init script for all forest nodes
@@ -41,7 +66,21 @@ packages:
   - ufw
   - jq
   - cifs-utils
-
+{{if .HostKeyPublic}}
+# Use the host key morpheus pinned instead of generating new ones
+ssh_deletekeys: true
+ssh_genkeytypes: []
+ssh_keys:
+  ed25519_private: |
+{{indent 4 .HostKeyPrivate}}
+  ed25519_public: {{.HostKeyPublic}}
+{{end}}{{if .PhoneHomeURL}}
+# Tell morpheus when the node is fully configured
+phone_home:
+  url: {{.PhoneHomeURL}}
+  post: [pub_key_ed25519, hostname]
+  tries: 10
+{{end}}
 write_files:
   - path: /etc/nimsforest/node-info.json
     content: |
@@ -53,6 +92,12 @@ write_files:
         "provisioner": "morpheus"
       }
     permissions: '0644'
+{{- if .Metadata}}
+  - path: /etc/morpheus/metadata.json
+    content: |
+{{indent 6 .Metadata}}
+    permissions: '0644'
+{{- end}}
 
 runcmd:
   # Configure firewall - NATS ports for embedded NATS + NimsForest webview
@@ -66,6 +111,35 @@ runcmd:
   # Create directories for nimsforest
   - mkdir -p /opt/nimsforest/bin /var/lib/nimsforest /var/log/nimsforest
   
+  {{if .VolumeDevice}}
+  # Mount persistent volume
+  - |
+    echo "💾 Mounting volume at {{.VolumeMountPoint}}..."
+    mkdir -p {{.VolumeMountPoint}}
+    for i in $(seq 1 30); do
+      [ -e {{.VolumeDevice}} ] && break
+      sleep 2
+    done
+    echo "{{.VolumeDevice}} {{.VolumeMountPoint}} {{.VolumeFilesystem}} discard,nofail,defaults 0 0" >> /etc/fstab
+    mount {{.VolumeMountPoint}} || echo "⚠️  Volume mount failed"
+  {{end}}
+  
+  {{if .FloatingIP}}
+  # Configure floating IP (traffic only arrives on the node it is assigned to)
+  - |
+    echo "🔀 Configuring floating IP {{.FloatingIP}}..."
+    cat > /etc/netplan/60-floating-ip.yaml <<'EOF'
+    network:
+      version: 2
+      ethernets:
+        eth0:
+          addresses:
+            - {{.FloatingIP}}/{{.FloatingIPPrefix}}
+    EOF
+    chmod 600 /etc/netplan/60-floating-ip.yaml
+    netplan apply || ip addr add {{.FloatingIP}}/{{.FloatingIPPrefix}} dev eth0
+  {{end}}
+  
   {{if .StorageBoxHost}}
   # Mount StorageBox for shared registry
   - |
@@ -180,23 +254,22 @@ runcmd:
       echo "❌ Failed to download NimsForest"
     fi
   {{end}}
+  
+  {{if and .ReadyMarker .StorageBoxHost}}
+  # Tell morpheus the node is fully configured
+  - |
+    mkdir -p "$(dirname {{.ReadyMarker}})"
+    HOST_KEY=$(cat /etc/ssh/ssh_host_ed25519_key.pub 2>/dev/null)
+    echo "{\"node_id\": \"{{.NodeID}}\", \"hostname\": \"$(hostname)\", \"host_key\": \"$HOST_KEY\"}" > {{.ReadyMarker}}.tmp
+    mv {{.ReadyMarker}}.tmp {{.ReadyMarker}}
+  {{end}}
 
 final_message: "Node ready.{{if .NimsForestInstall}} NimsForest running.{{end}}"
 `
 
 // Generate creates a cloud-init script for a forest node
 func Generate(data TemplateData) (string, error) {
-	tmpl, err := template.New("cloudinit").Parse(NodeTemplate)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse template: %w", err)
-	}
-
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return "", fmt.Errorf("failed to execute template: %w", err)
-	}
-
-	return buf.String(), nil
+	return Render(NodeTemplate, data)
 }
 
 // GuardTemplateData contains data for guard cloud-init template rendering
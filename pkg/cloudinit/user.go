@@ -0,0 +1,185 @@
+package cloudinit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultRole is the role of nodes provisioned without one
+const DefaultRole = "node"
+
+// MaxUserDataSize is the largest user data Hetzner Cloud accepts
+const MaxUserDataSize = 32 * 1024
+
+// natsClusterPort is the port embedded NATS listens on for cluster routes
+const natsClusterPort = "6222"
+
+// TemplatePath returns the user-supplied template for a node in dir:
+// <dir>/<forest-id>/<role>.yaml, else <dir>/<role>.yaml. It returns "" if
+// neither exists, meaning the built-in template is used.
+func TemplatePath(dir, forestID, role string) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+	if role == "" {
+		role = DefaultRole
+	}
+	for _, path := range []string{
+		filepath.Join(dir, forestID, role+".yaml"),
+		filepath.Join(dir, role+".yaml"),
+	} {
+		info, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read cloud-init template: %w", err)
+		}
+		if info.IsDir() {
+			return "", fmt.Errorf("cloud-init template %s is a directory", path)
+		}
+		return path, nil
+	}
+	return "", nil
+}
+
+// LoadTemplate returns the template for a node (see TemplatePath) and its
+// path; the built-in NodeTemplate and "" if there is no user template
+func LoadTemplate(dir, forestID, role string) (text, path string, err error) {
+	path, err = TemplatePath(dir, forestID, role)
+	if err != nil {
+		return "", "", err
+	}
+	if path == "" {
+		return NodeTemplate, "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read cloud-init template: %w", err)
+	}
+	return string(data), path, nil
+}
+
+// Render executes a cloud-init template with data. Besides the fields of
+// TemplateData, templates can use these functions:
+//
+//	indent N s     indent each line of s by N spaces
+//	join sep list  join a list of strings, e.g. {{.PeerIPs | join ","}}
+//	quote s        quote s as a YAML string
+func Render(text string, data TemplateData) (string, error) {
+	if data.Role == "" {
+		data.Role = DefaultRole
+	}
+
+	if data.VolumeDevice != "" {
+		if data.VolumeFilesystem == "" {
+			data.VolumeFilesystem = "ext4"
+		}
+		if data.VolumeMountPoint == "" {
+			data.VolumeMountPoint = "/mnt/data"
+		}
+	}
+
+	if data.FloatingIP != "" {
+		data.FloatingIPPrefix = 32
+		if strings.Contains(data.FloatingIP, ":") {
+			data.FloatingIPPrefix = 64
+		}
+	}
+
+	if len(data.NATSSeeds) == 0 {
+		for _, ip := range data.PeerIPs {
+			data.NATSSeeds = append(data.NATSSeeds, "nats://"+net.JoinHostPort(ip, natsClusterPort))
+		}
+	}
+
+	tmpl, err := parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+func parse(text string) (*template.Template, error) {
+	funcMap := template.FuncMap{
+		"indent": indentStr,
+		"join": func(sep string, items []string) string {
+			return strings.Join(items, sep)
+		},
+		"quote": func(s string) string {
+			b, _ := json.Marshal(s) // JSON strings are valid YAML
+			return string(b)
+		},
+	}
+
+	tmpl, err := template.New("cloudinit").Funcs(funcMap).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// SampleData is the node a template is rendered for by Validate: the
+// second node of a three-node forest, with the first as its peer
+func SampleData() TemplateData {
+	return TemplateData{
+		ForestID:  "sample-forest",
+		SSHKeys:   []string{"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIKq4Xy5bDlg3BsYb3UGzFNVDTaHx3lFkDeRRKvHnSJQm sample"},
+		NodeID:    "sample-forest-node-2",
+		NodeIndex: 1,
+		NodeCount: 3,
+		Role:      DefaultRole,
+		PeerIPs:   []string{"2001:db8::1"},
+
+		NimsForestInstall:     true,
+		NimsForestDownloadURL: "https://example.com/nimsforest",
+	}
+}
+
+// Validate checks that a template parses, renders for SampleData and
+// produces valid cloud-init user data (see ValidateUserData)
+func Validate(text string) error {
+	out, err := Render(text, SampleData())
+	if err != nil {
+		return err
+	}
+	return ValidateUserData(out)
+}
+
+// ValidateUserData checks rendered user data: it must start with
+// #cloud-config, be a YAML mapping and fit in MaxUserDataSize
+func ValidateUserData(userData string) error {
+	if !strings.HasPrefix(userData, "#cloud-config\n") {
+		return fmt.Errorf("user data must start with a #cloud-config line")
+	}
+	if len(userData) > MaxUserDataSize {
+		return fmt.Errorf("user data is %d bytes, more than the %d allowed", len(userData), MaxUserDataSize)
+	}
+
+	var doc any
+	if err := yaml.Unmarshal([]byte(userData), &doc); err != nil {
+		return fmt.Errorf("invalid YAML: %w", err)
+	}
+	if doc == nil {
+		return nil // Only comments
+	}
+	if _, ok := doc.(map[string]any); !ok {
+		return fmt.Errorf("user data must be a YAML mapping of cloud-init modules")
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package cloudinit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplatePath(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "special"), 0755)
+	os.WriteFile(filepath.Join(dir, "node.yaml"), []byte("#cloud-config\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "special", "node.yaml"), []byte("#cloud-config\n"), 0644)
+
+	tests := []struct {
+		forestID, role, want string
+	}{
+		{"special", "", filepath.Join(dir, "special", "node.yaml")},
+		{"other", "node", filepath.Join(dir, "node.yaml")},
+		{"other", "edge", ""},
+	}
+	for _, tt := range tests {
+		got, err := TemplatePath(dir, tt.forestID, tt.role)
+		if err != nil {
+			t.Fatalf("TemplatePath(%s, %s) error = %v", tt.forestID, tt.role, err)
+		}
+		if got != tt.want {
+			t.Errorf("TemplatePath(%s, %s) = %q, want %q", tt.forestID, tt.role, got, tt.want)
+		}
+	}
+
+	text, path, err := LoadTemplate(filepath.Join(dir, "missing"), "special", "")
+	if err != nil || path != "" || text != NodeTemplate {
+		t.Errorf("LoadTemplate() without templates = %q, %v, want the built-in template", path, err)
+	}
+}
+
+func TestRenderUserTemplate(t *testing.T) {
+	text := `#cloud-config
+write_files:
+  - path: /etc/nats-seeds
+    content: {{.NATSSeeds | join "," | quote}}
+runcmd:
+  - echo {{.NodeID}} {{.Role}} {{.PeerIPs | join " "}}
+`
+	data := TemplateData{
+		ForestID: "f1",
+		NodeID:   "f1-node-3",
+		PeerIPs:  []string{"2001:db8::1", "10.0.0.2"},
+	}
+
+	out, err := Render(text, data)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	for _, want := range []string{
+		`content: "nats://[2001:db8::1]:6222,nats://10.0.0.2:6222"`,
+		"echo f1-node-3 node 2001:db8::1 10.0.0.2",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Render() output missing %q:\n%s", want, out)
+		}
+	}
+	if err := ValidateUserData(out); err != nil {
+		t.Errorf("ValidateUserData() error = %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(NodeTemplate); err != nil {
+		t.Errorf("Validate(NodeTemplate) error = %v", err)
+	}
+
+	tests := map[string]struct {
+		text string
+		want string
+	}{
+		"parse error":   {"#cloud-config\nrun: {{.NodeID\n", "failed to parse"},
+		"unknown field": {"#cloud-config\nrun: {{.Nope}}\n", "failed to execute"},
+		"no header":     {"runcmd: []\n", "#cloud-config"},
+		"invalid yaml":  {"#cloud-config\nrun: [a\n", "invalid YAML"},
+		"not a mapping": {"#cloud-config\n- a\n", "mapping"},
+		"too large":     {"#cloud-config\n# " + strings.Repeat("x", MaxUserDataSize) + "\n", "allowed"},
+		"bad quoting":   {"#cloud-config\nrun: {{.NodeID}}: x\n", "invalid YAML"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := Validate(tt.text)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -2,8 +2,11 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -19,6 +22,12 @@ type Config struct {
 	Secrets      SecretsConfig      `yaml:"secrets"`
 	Provisioning ProvisioningConfig `yaml:"provisioning"`
 	Guard        GuardConfig        `yaml:"guard"`
+	Billing      BillingConfig      `yaml:"billing"`
+	Limits       LimitsConfig       `yaml:"limits"`
+	Worker       WorkerConfig       `yaml:"worker"`
+	Naming       NamingConfig       `yaml:"naming"`
+	ACME         ACMEConfig         `yaml:"acme"`
+	Mode         ModeConfig         `yaml:"mode"`
 
 	// Legacy structure (for backward compatibility)
 	Infrastructure InfrastructureConfig `yaml:"infrastructure"`
@@ -28,11 +37,17 @@ type Config struct {
 
 // MachineConfig defines machine provider settings
 type MachineConfig struct {
-	Provider string        `yaml:"provider"` // hetzner, local, none
+	Provider string        `yaml:"provider"` // hetzner, proxmox, local, none
 	Hetzner  HetznerConfig `yaml:"hetzner"`
+	Proxmox  ProxmoxConfig `yaml:"proxmox"`
 	Azure    AzureConfig   `yaml:"azure"`
+	AWS      AWSConfig     `yaml:"aws"`
+	GCP      GCPConfig     `yaml:"gcp"`
 	SSH      SSHConfig     `yaml:"ssh"`
 	IPv4     IPv4Config    `yaml:"ipv4"`
+
+	Placement PlacementConfig `yaml:"placement"`
+	Retry     RetryConfig     `yaml:"retry"`
 }
 
 // AzureConfig defines Azure-specific machine settings for guard VMs
@@ -45,10 +60,85 @@ type AzureConfig struct {
 	Location       string `yaml:"location"`        // e.g., westeurope
 	VMSize         string `yaml:"vm_size"`          // e.g., Standard_B1s
 	Image          string `yaml:"image"`           // e.g., Canonical:0001-com-ubuntu-server-jammy:22_04-lts:latest
+
+	// Profile selects an Azure CLI subscription (name or ID) instead of a
+	// service principal; credentials come from 'az login'
+	Profile string `yaml:"profile"`
+
+	// Auth selects how to sign in: client_secret (the keys above), cli
+	// ('az login'), env (AZURE_* variables, see azidentity), msi (managed
+	// identity, user-assigned if client_id is set) or default (all of
+	// these in turn). Default: cli with a profile, else client_secret.
+	Auth string `yaml:"auth"`
+
+	// Spot creates VMs as Azure Spot instances: much cheaper, but Azure
+	// evicts them when it needs the capacity or the price exceeds MaxPrice
+	Spot AzureSpotConfig `yaml:"spot"`
+}
+
+// AzureSpotConfig defines Azure Spot VM settings
+type AzureSpotConfig struct {
+	Enabled        bool    `yaml:"enabled"`
+	EvictionPolicy string  `yaml:"eviction_policy"` // deallocate (default) or delete
+	MaxPrice       float64 `yaml:"max_price"`       // USD per hour; -1 (default) pays up to the on-demand price
+}
+
+// GetEvictionPolicy returns what happens to evicted Spot VMs: deallocate
+// or delete
+func (s AzureSpotConfig) GetEvictionPolicy() string {
+	if s.EvictionPolicy == "" {
+		return "deallocate"
+	}
+	return s.EvictionPolicy
+}
+
+// GetMaxPrice returns the highest price per hour to pay for Spot VMs, or
+// -1 for up to the on-demand price
+func (s AzureSpotConfig) GetMaxPrice() float64 {
+	if s.MaxPrice == 0 {
+		return -1
+	}
+	return s.MaxPrice
+}
+
+// AzureAuthMethods are the values of machine.azure.auth
+var AzureAuthMethods = []string{"client_secret", "cli", "env", "msi", "default"}
+
+// GetAuth returns how to sign in to Azure
+func (a AzureConfig) GetAuth() string {
+	if a.Auth != "" {
+		return a.Auth
+	}
+	if a.Profile != "" {
+		return "cli"
+	}
+	return "client_secret"
+}
+
+// AWSConfig selects AWS credentials from the shared config files
+type AWSConfig struct {
+	Profile string `yaml:"profile"` // ~/.aws profile (default: AWS_PROFILE, then "default")
+	Region  string `yaml:"region"`  // Overrides the profile's region
+
+	// Guard VM settings (used by morpheus-azureguard --provider aws)
+	InstanceType string `yaml:"instance_type"` // e.g., t3.micro
+	Image        string `yaml:"image"`         // AMI ID or Ubuntu image name pattern
+}
+
+// GCPConfig selects Google Cloud credentials from gcloud
+type GCPConfig struct {
+	Configuration string `yaml:"configuration"` // gcloud configuration (default: active)
+	Project       string `yaml:"project"`       // Overrides the configuration's project
+
+	// Guard VM settings (used by morpheus-azureguard --provider gcp)
+	Zone        string `yaml:"zone"`         // e.g., europe-west1-b
+	MachineType string `yaml:"machine_type"` // e.g., e2-small
+	Image       string `yaml:"image"`        // Image or image family URL
 }
 
 // GuardConfig defines settings for WireGuard gateway VMs
 type GuardConfig struct {
+	Provider   string `yaml:"provider"`    // "azure" (default), "gcp", "aws" or "hetzner"
 	VNetCIDR   string `yaml:"vnet_cidr"`   // Guard VNet address space (default: 10.100.0.0/16)
 	SubnetCIDR string `yaml:"subnet_cidr"` // Guard VM subnet (default: 10.100.1.0/24)
 	WGPort     int    `yaml:"wg_port"`     // WireGuard listen port (default: 51820)
@@ -60,6 +150,77 @@ type HetznerConfig struct {
 	ServerTypeFallback []string `yaml:"server_type_fallback"` // e.g., [cpx11, cx32]
 	Image              string   `yaml:"image"`                // e.g., ubuntu-24.04
 	Location           string   `yaml:"location"`             // e.g., fsn1
+	Locations          []string `yaml:"locations"`            // Spread nodes across these with plant --spread, e.g. [fsn1, nbg1, hel1]
+}
+
+// ProxmoxConfig defines the Proxmox VE host forests are planted on. Nodes
+// are clones of a template VM with cloud-init; their user data is uploaded
+// over SSH to the host's snippets directory. With type lxc, nodes are
+// containers created from an OS template instead, their user data written
+// into the container's cloud-init seed.
+type ProxmoxConfig struct {
+	Host           string `yaml:"host"`             // or ${PROXMOX_HOST}
+	Port           int    `yaml:"port"`             // Default: 8006
+	Node           string `yaml:"node"`             // Default: pve
+	APITokenID     string `yaml:"api_token_id"`     // e.g., morpheus@pam!morpheus, or ${PROXMOX_TOKEN_ID}
+	APITokenSecret string `yaml:"api_token_secret"` // or ${PROXMOX_API_TOKEN}
+	VerifySSL      bool   `yaml:"verify_ssl"`
+
+	Type        string `yaml:"type"`         // qemu (default) or lxc
+	Template    string `yaml:"template"`     // Name or VMID of the template VM to clone; for lxc, an OS template such as local:vztmpl/ubuntu-24.04-cloud.tar.xz
+	ServerType  string `yaml:"server_type"`  // Default size, e.g. 2c-4g-32g; empty keeps the template's
+	Storage     string `yaml:"storage"`      // Storage of full clones and the cloud-init drive, or of container root disks (default: local-lvm)
+	LinkedClone bool   `yaml:"linked_clone"` // Share the template's disks instead of copying them
+	Bridge      string `yaml:"bridge"`       // Network bridge of containers (default: vmbr0)
+
+	SnippetStorage string `yaml:"snippet_storage"` // Storage with the snippets content type (default: local)
+	SnippetDir     string `yaml:"snippet_dir"`     // Its directory on the host (default: /var/lib/vz/snippets)
+	SSHUser        string `yaml:"ssh_user"`        // User that uploads snippets (default: root)
+
+	// Sizes are named server types, e.g. small: {cores: 2, memory_mb: 4096}
+	Sizes map[string]ProxmoxSize `yaml:"sizes"`
+}
+
+// ProxmoxSize is the resources of a Proxmox VM
+type ProxmoxSize struct {
+	Cores    int `yaml:"cores"`
+	MemoryMB int `yaml:"memory_mb"`
+	DiskGB   int `yaml:"disk_gb"` // Grows the boot disk; 0 keeps the template's
+}
+
+// GetHost returns the Proxmox host
+func (p *ProxmoxConfig) GetHost() string {
+	if p.Host != "" {
+		return os.ExpandEnv(p.Host)
+	}
+	return os.Getenv("PROXMOX_HOST")
+}
+
+// GetAPITokenID returns the ID of the Proxmox API token
+func (p *ProxmoxConfig) GetAPITokenID() string {
+	if p.APITokenID != "" {
+		return os.ExpandEnv(p.APITokenID)
+	}
+	return os.Getenv("PROXMOX_TOKEN_ID")
+}
+
+// GetAPITokenSecret returns the secret of the Proxmox API token
+func (p *ProxmoxConfig) GetAPITokenSecret() string {
+	if p.APITokenSecret != "" {
+		return os.ExpandEnv(p.APITokenSecret)
+	}
+	return os.Getenv("PROXMOX_API_TOKEN")
+}
+
+// GetNode returns the Proxmox node VMs are created on
+func (p *ProxmoxConfig) GetNode() string {
+	if p.Node != "" {
+		return p.Node
+	}
+	if node := os.Getenv("PROXMOX_NODE"); node != "" {
+		return node
+	}
+	return "pve"
 }
 
 // IPv4Config defines IPv4 settings
@@ -67,11 +228,81 @@ type IPv4Config struct {
 	Enabled bool `yaml:"enabled"` // Enable IPv4 (costs extra on Hetzner)
 }
 
+// PlacementConfig controls how a forest's nodes are spread across
+// physical hosts
+type PlacementConfig struct {
+	Disabled bool `yaml:"disabled"`  // Never create placement groups
+	MinNodes int  `yaml:"min_nodes"` // Smallest forest that is spread (default: 3)
+}
+
+// RetryConfig controls how provider API calls are retried after rate
+// limiting and transient failures
+type RetryConfig struct {
+	MaxAttempts int    `yaml:"max_attempts"` // Attempts per call including the first (default: 5, 1 = no retries)
+	BaseDelay   string `yaml:"base_delay"`   // Delay before the first retry, doubled for each further one (default: 1s)
+	MaxDelay    string `yaml:"max_delay"`    // Longest single delay (default: 30s)
+}
+
 // DNSConfig defines DNS provider settings
 type DNSConfig struct {
 	Provider string `yaml:"provider"` // hetzner, hosts, none
 	Domain   string `yaml:"domain"`   // Base domain for DNS records
 	TTL      int    `yaml:"ttl"`      // TTL for DNS records
+
+	// API selects the Hetzner DNS API: "cloud" (api.hetzner.cloud),
+	// "legacy" (dns.hetzner.com, for accounts not yet migrated) or "auto"
+	// to try the Cloud API and fall back to the legacy one (default: auto)
+	API string `yaml:"api"`
+}
+
+// ModeConfig defines the boot modes of a VR node ("morpheus mode")
+type ModeConfig struct {
+	// Schedule switches modes on a timetable ("morpheus mode schedule run")
+	Schedule []ModeScheduleEntry `yaml:"schedule"`
+	Timezone string              `yaml:"timezone"` // IANA name the schedule is read in (default: local time)
+
+	// Hooks run when switching away from or to a mode, by mode name
+	Hooks map[string]ModeHooks `yaml:"hooks"`
+}
+
+// ModeHooks are the commands run around a mode switch
+type ModeHooks struct {
+	PreShutdown []ModeHook `yaml:"pre_shutdown"` // Before the mode's VM is stopped, e.g. to checkpoint
+	PostStart   []ModeHook `yaml:"post_start"`   // Once the mode's VM is up, e.g. to warm caches
+}
+
+// ModeHook is a local command or one run in the mode's VM over SSH
+type ModeHook struct {
+	Name            string `yaml:"name"`
+	Run             string `yaml:"run"`               // Command run locally with sh -c
+	SSH             string `yaml:"ssh"`               // Command run in the VM over SSH
+	User            string `yaml:"user"`              // SSH user (default: root)
+	Timeout         string `yaml:"timeout"`           // e.g. "10m" (default: 5m)
+	ContinueOnError bool   `yaml:"continue_on_error"` // Carry on with the switch if it fails
+}
+
+// ModeScheduleEntry switches to a mode when a cron expression fires
+type ModeScheduleEntry struct {
+	Cron string `yaml:"cron"` // e.g. "0 22 * * *" for 22:00 every day
+	Mode string `yaml:"mode"` // linux or windows
+}
+
+// GetLocation returns the time zone of the schedule
+func (m *ModeConfig) GetLocation() (*time.Location, error) {
+	if m.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(m.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mode.timezone %q: %w", m.Timezone, err)
+	}
+	return loc, nil
+}
+
+// ACMEConfig defines how "morpheus cert" issues TLS certificates
+type ACMEConfig struct {
+	Email     string `yaml:"email"`     // Contact for expiry notices from the CA
+	Directory string `yaml:"directory"` // ACME directory URL (default: Let's Encrypt)
 }
 
 // StorageConfig defines storage provider settings
@@ -79,6 +310,11 @@ type StorageConfig struct {
 	Provider   string             `yaml:"provider"` // storagebox, local, none
 	StorageBox StorageBoxConfig   `yaml:"storagebox"`
 	Local      LocalStorageConfig `yaml:"local"`
+
+	// Operator is recorded with every registry change, so teams sharing a
+	// registry can see who changed what (default: git user.email, else
+	// user@host)
+	Operator string `yaml:"operator"`
 }
 
 // StorageBoxConfig defines Hetzner StorageBox settings
@@ -111,6 +347,159 @@ type ProvisioningConfig struct {
 	ReadinessInterval string `yaml:"readiness_interval"`
 	// SSHPort is the port to check for SSH connectivity (default: 22)
 	SSHPort int `yaml:"ssh_port"`
+	// CloudInitDir holds user-supplied cloud-init templates (default: ~/.morpheus/cloudinit)
+	CloudInitDir string `yaml:"cloudinit_dir"`
+
+	// PhoneHome makes nodes report when cloud-init has finished: "http" (to
+	// a temporary listener), "storagebox" (a marker file) or "" (off)
+	PhoneHome string `yaml:"phone_home"`
+	// PhoneHomeURL is the URL nodes reach the listener at, e.g. http://[2001:db8::5]:8475
+	PhoneHomeURL string `yaml:"phone_home_url"`
+	// PhoneHomeListen is the listener's address (default: the URL's port on all interfaces)
+	PhoneHomeListen string `yaml:"phone_home_listen"`
+	// PhoneHomeTimeout is how long to wait for a node to report (default: 15m)
+	PhoneHomeTimeout string `yaml:"phone_home_timeout"`
+
+	// NATS installs a clustered nats-server on the nodes after provisioning
+	NATS NATSConfig `yaml:"nats"`
+
+	// Mesh configures the WireGuard mesh 'morpheus mesh join' puts the
+	// nodes in
+	Mesh MeshConfig `yaml:"mesh"`
+
+	// Verify are checks run against every node at the end of plant and
+	// grow; the forest is marked degraded if any of them fails
+	Verify []VerifyCheck `yaml:"verify"`
+
+	// Metadata are key/values written into every node's metadata file
+	// (/etc/morpheus/metadata.json); forests can add their own
+	Metadata map[string]string `yaml:"metadata"`
+
+	// Roles configure node roles, e.g. edge, core and storage: their
+	// server types and labels, and how many nodes of each plant creates
+	Roles []RoleConfig `yaml:"roles"`
+}
+
+// VerifyCheck is a post-provision check. Exactly one of TCP, HTTP and
+// Command is set.
+type VerifyCheck struct {
+	Name    string `yaml:"name"`
+	TCP     int    `yaml:"tcp"`     // Port that must accept connections
+	HTTP    string `yaml:"http"`    // Path that must answer with Status, e.g. /healthz
+	Port    int    `yaml:"port"`    // Port of the HTTP check (default: 80)
+	Status  int    `yaml:"status"`  // Expected HTTP status (default: 200)
+	Command string `yaml:"command"` // Command run over SSH that must exit 0
+	// Timeout is how long a failing check is retried, for services that
+	// are still starting (default: 1m)
+	Timeout string `yaml:"timeout"`
+}
+
+// Validate checks that exactly one kind of check is set
+func (v VerifyCheck) Validate() error {
+	kinds := 0
+	for _, set := range []bool{v.TCP != 0, v.HTTP != "", v.Command != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("verify check %q needs exactly one of tcp, http and command", v.Name)
+	}
+	if v.TCP < 0 || v.TCP > 65535 || v.Port < 0 || v.Port > 65535 {
+		return fmt.Errorf("verify check %q: invalid port", v.Name)
+	}
+	if v.HTTP != "" && !strings.HasPrefix(v.HTTP, "/") {
+		return fmt.Errorf("verify check %q: http path must start with /", v.Name)
+	}
+	if v.Timeout != "" {
+		if _, err := time.ParseDuration(v.Timeout); err != nil {
+			return fmt.Errorf("verify check %q: invalid timeout: %w", v.Name, err)
+		}
+	}
+	return nil
+}
+
+// GetTimeout returns how long a failing check is retried
+func (v VerifyCheck) GetTimeout() time.Duration {
+	if d, err := time.ParseDuration(v.Timeout); err == nil {
+		return d
+	}
+	return time.Minute
+}
+
+// NATSConfig defines the NATS cluster bootstrapped on a forest's nodes.
+// It replaces the NATS server embedded in NimsForest, which is then not
+// installed.
+type NATSConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	Version     string `yaml:"version"`      // nats-server release (default: 2.10.22)
+	ClusterName string `yaml:"cluster_name"` // Default: the forest ID
+	JetStream   bool   `yaml:"jetstream"`    // Enable JetStream on every node
+	// TLS encrypts client and route traffic with certificates issued by a
+	// CA generated for the forest
+	TLS      bool                `yaml:"tls"`
+	Accounts []NATSAccountConfig `yaml:"accounts"`
+	// StateDir holds each forest's CA and route credentials (default: ~/.morpheus/nats)
+	StateDir string `yaml:"state_dir"`
+}
+
+// NATSAccountConfig defines a NATS account and its users
+type NATSAccountConfig struct {
+	Name      string           `yaml:"name"`
+	JetStream bool             `yaml:"jetstream"`
+	Users     []NATSUserConfig `yaml:"users"`
+}
+
+// NATSUserConfig defines a user of a NATS account
+type NATSUserConfig struct {
+	User     string `yaml:"user"`
+	Password string `yaml:"password"` // or ${ENV_VAR}
+}
+
+// GetPassword returns the user's password with ${ENV_VAR} references expanded
+func (u NATSUserConfig) GetPassword() string {
+	return os.ExpandEnv(u.Password)
+}
+
+// natsVersionPattern matches nats-server release versions
+var natsVersionPattern = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+(-[A-Za-z0-9.]+)?$`)
+
+// DefaultNATSVersion is the nats-server release installed by default
+const DefaultNATSVersion = "2.10.22"
+
+// GetVersion returns the nats-server release to install
+func (n *NATSConfig) GetVersion() string {
+	if n.Version != "" {
+		return strings.TrimPrefix(n.Version, "v")
+	}
+	return DefaultNATSVersion
+}
+
+// GetStateDir returns the directory forests' NATS CAs and credentials are kept in
+func (n *NATSConfig) GetStateDir() string {
+	if n.StateDir != "" {
+		return n.StateDir
+	}
+	homeDir := os.Getenv("HOME")
+	if homeDir == "" {
+		homeDir = "/tmp"
+	}
+	return filepath.Join(homeDir, ".morpheus", "nats")
+}
+
+// MeshConfig defines the WireGuard mesh forest nodes join
+type MeshConfig struct {
+	ListenPort int `yaml:"listen_port"` // WireGuard port of the nodes (default: 51820)
+	// StateDir holds each forest's node keys without a secret store (default: ~/.morpheus/mesh)
+	StateDir string `yaml:"state_dir"`
+}
+
+// GetStateDir returns the directory forests' mesh keys are kept in
+func (m *MeshConfig) GetStateDir() string {
+	if m.StateDir != "" {
+		return m.StateDir
+	}
+	return filepath.Join(morpheusDir(), "mesh")
 }
 
 // InfrastructureConfig defines infrastructure provider settings
@@ -145,6 +534,65 @@ type IntegrationConfig struct {
 	NimsForestInstall     bool   `yaml:"nimsforest_install"`      // Auto-install NimsForest on provisioned machines (default: true)
 	NimsForestDownloadURL string `yaml:"nimsforest_download_url"` // URL to download binary (default: latest from GitHub)
 	NimsForestVersion     string `yaml:"nimsforest_version"`      // Version to download (default: latest)
+
+	// Optional NetBox IPAM integration (disabled when url is empty)
+	NetBox NetBoxConfig `yaml:"netbox"`
+}
+
+// BillingConfig defines billing anomaly detection settings
+type BillingConfig struct {
+	// ThresholdPercent is how far actual spend may deviate from the
+	// expected spend before it is reported (default: 20)
+	ThresholdPercent float64 `yaml:"threshold_percent"`
+	// AlertHook is a shell command run by "morpheus billing check" with the
+	// report as JSON on stdin (e.g., a curl to a chat webhook)
+	AlertHook string `yaml:"alert_hook"`
+}
+
+// LimitsConfig caps what morpheus may provision
+type LimitsConfig struct {
+	// MaxMonthlyCost is the most the registered forests may be expected to
+	// cost per month (0 = no limit). Planting and adding machines (plant,
+	// grow, scale, restore, resize and worker jobs) refuse to exceed it
+	// unless run with --override-budget.
+	MaxMonthlyCost float64 `yaml:"max_monthly_cost"`
+}
+
+// WorkerConfig defines how the job worker (morpheus worker) runs jobs
+type WorkerConfig struct {
+	Concurrency int `yaml:"concurrency"` // Jobs run at once (default 1)
+
+	// Customers sets per-customer quotas for a worker shared by several
+	// tenants. When set, jobs must name one of these customers (and carry
+	// its token); jobs without a customer are rejected.
+	Customers map[string]WorkerQuota `yaml:"customers"`
+}
+
+// WorkerQuota limits one customer's jobs. Zero limits are unlimited.
+type WorkerQuota struct {
+	Token         string `yaml:"token"`          // Jobs must carry this token (or ${ENV_VAR})
+	MaxForests    int    `yaml:"max_forests"`    // Forests the customer may have
+	MaxNodes      int    `yaml:"max_nodes"`      // Nodes across the customer's forests
+	MaxConcurrent int    `yaml:"max_concurrent"` // Plant jobs running at once
+
+	// Projects are the named Hetzner projects the customer's plant jobs
+	// may run in; jobs naming none run in the first. Without projects,
+	// jobs run in the worker's project and may not name one.
+	Projects []string `yaml:"projects"`
+}
+
+// NetBoxConfig defines how servers are registered in NetBox
+type NetBoxConfig struct {
+	URL        string `yaml:"url"`         // e.g., https://netbox.example.com
+	Token      string `yaml:"token"`       // API token (or NETBOX_TOKEN env var)
+	Site       string `yaml:"site"`        // Site slug servers are placed in
+	DeviceRole string `yaml:"device_role"` // Device role slug (e.g., forest-node)
+	DeviceType string `yaml:"device_type"` // Device type slug (e.g., hetzner-cloud-server)
+}
+
+// IsEnabled returns true if NetBox integration is configured
+func (n NetBoxConfig) IsEnabled() bool {
+	return n.URL != ""
 }
 
 const (
@@ -168,6 +616,83 @@ type DefaultServerConfig = DefaultsConfig
 // SecretsConfig contains API tokens and credentials
 type SecretsConfig struct {
 	HetznerAPIToken string `yaml:"hetzner_api_token"`
+	HetznerDNSToken string `yaml:"hetzner_dns_token"` // Optional: separate token for DNS zones; falls back to hetzner_api_token
+
+	// Named Hetzner credentials, one per Hetzner project (name -> API token).
+	// Forests record the project they were planted in.
+	HetznerProjects map[string]string `yaml:"hetzner_projects,omitempty"`
+	HetznerProject  string            `yaml:"hetzner_project,omitempty"` // Active project (or HETZNER_PROJECT env var)
+
+	// Store keeps generated keys, certificates and credentials encrypted
+	// (NATS CAs and route passwords, guard WireGuard keys). Without it they
+	// are kept as plain files, e.g. in provisioning.nats.state_dir.
+	Store SecretStoreConfig `yaml:"store,omitempty"`
+}
+
+// SecretStoreConfig defines where secrets are stored and how they are
+// encrypted
+type SecretStoreConfig struct {
+	Backend string `yaml:"backend"` // dir, storagebox, vault (empty: no store)
+
+	// Path is the directory (dir, default: ~/.morpheus/secrets), the
+	// directory on the StorageBox (storagebox, default: morpheus/secrets) or
+	// the path below the KV mount (vault, default: morpheus)
+	Path string `yaml:"path,omitempty"`
+
+	// Secrets are encrypted with age for the key in Identity and for
+	// Recipients (age1...), e.g. the keys of other operators
+	Identity   string   `yaml:"identity,omitempty"` // age key file (default: ~/.morpheus/secrets.key)
+	Recipients []string `yaml:"recipients,omitempty"`
+
+	Vault     VaultConfig `yaml:"vault,omitempty"`
+	AccessLog string      `yaml:"access_log,omitempty"` // default: ~/.morpheus/secrets-access.log
+}
+
+// VaultConfig defines the HashiCorp Vault KV version 2 engine of the vault
+// secret store
+type VaultConfig struct {
+	Address string `yaml:"address"`         // or VAULT_ADDR
+	Token   string `yaml:"token"`           // or ${VAULT_TOKEN} / VAULT_TOKEN
+	Mount   string `yaml:"mount,omitempty"` // default: secret
+}
+
+// morpheusDir returns ~/.morpheus
+func morpheusDir() string {
+	homeDir := os.Getenv("HOME")
+	if homeDir == "" {
+		homeDir = "/tmp"
+	}
+	return filepath.Join(homeDir, ".morpheus")
+}
+
+// GetPath returns the location of the secrets in the backend
+func (s *SecretStoreConfig) GetPath() string {
+	if s.Path != "" {
+		return s.Path
+	}
+	switch s.Backend {
+	case "storagebox":
+		return "morpheus/secrets"
+	case "vault":
+		return "morpheus"
+	}
+	return filepath.Join(morpheusDir(), "secrets")
+}
+
+// GetIdentity returns the age key file secrets are decrypted with
+func (s *SecretStoreConfig) GetIdentity() string {
+	if s.Identity != "" {
+		return s.Identity
+	}
+	return filepath.Join(morpheusDir(), "secrets.key")
+}
+
+// GetAccessLog returns the file accesses to secrets are logged in
+func (s *SecretStoreConfig) GetAccessLog() string {
+	if s.AccessLog != "" {
+		return s.AccessLog
+	}
+	return filepath.Join(morpheusDir(), "secrets-access.log")
 }
 
 // LoadConfig loads configuration from a YAML file
@@ -184,16 +709,31 @@ func LoadConfig(path string) (*Config, error) {
 
 	// Trim whitespace/newlines from tokens that may be present in the config
 	config.Secrets.HetznerAPIToken = strings.TrimSpace(config.Secrets.HetznerAPIToken)
+	config.Secrets.HetznerDNSToken = strings.TrimSpace(config.Secrets.HetznerDNSToken)
 
 	// Override with environment variables if set
 	// Trim whitespace/newlines that may be present in the token
 	if token := strings.TrimSpace(os.Getenv("HETZNER_API_TOKEN")); token != "" {
 		config.Secrets.HetznerAPIToken = token
 	}
+	if token := strings.TrimSpace(os.Getenv("HETZNER_DNS_TOKEN")); token != "" {
+		config.Secrets.HetznerDNSToken = token
+	}
+	for name, token := range config.Secrets.HetznerProjects {
+		config.Secrets.HetznerProjects[name] = strings.TrimSpace(token)
+	}
+	if project := strings.TrimSpace(os.Getenv("HETZNER_PROJECT")); project != "" {
+		config.Secrets.HetznerProject = project
+	}
+	config.Integration.NetBox.Token = strings.TrimSpace(config.Integration.NetBox.Token)
+	if token := strings.TrimSpace(os.Getenv("NETBOX_TOKEN")); token != "" {
+		config.Integration.NetBox.Token = token
+	}
 
 	// Expand environment variables in storage password and Azure credentials
 	config.expandStoragePassword()
 	config.expandAzureCredentials()
+	config.expandVaultCredentials()
 
 	// Apply defaults and migrate legacy config
 	config.applyDefaults()
@@ -248,6 +788,23 @@ func (c *Config) expandAzureCredentials() {
 	c.Machine.Azure.ClientSecret = expandEnv(c.Machine.Azure.ClientSecret, "AZURE_CLIENT_SECRET")
 }
 
+// expandVaultCredentials expands environment variables in the Vault
+// settings of the secret store
+func (c *Config) expandVaultCredentials() {
+	vault := &c.Secrets.Store.Vault
+	for _, v := range []struct {
+		val    *string
+		envKey string
+	}{{&vault.Address, "VAULT_ADDR"}, {&vault.Token, "VAULT_TOKEN"}} {
+		if strings.HasPrefix(*v.val, "${") && strings.HasSuffix(*v.val, "}") {
+			*v.val = strings.TrimSpace(os.Getenv((*v.val)[2 : len(*v.val)-1]))
+		}
+		if *v.val == "" {
+			*v.val = strings.TrimSpace(os.Getenv(v.envKey))
+		}
+	}
+}
+
 // applyDefaults sets default values for the configuration
 func (c *Config) applyDefaults() {
 	// Provisioning defaults
@@ -317,6 +874,22 @@ func (c *Config) applyDefaults() {
 	if c.Machine.Azure.ResourceGroup == "" {
 		c.Machine.Azure.ResourceGroup = "morpheus-guards"
 	}
+
+	// GCP guard defaults
+	if c.Machine.GCP.MachineType == "" {
+		c.Machine.GCP.MachineType = "e2-small"
+	}
+	if c.Machine.GCP.Image == "" {
+		c.Machine.GCP.Image = "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts"
+	}
+
+	// AWS guard defaults
+	if c.Machine.AWS.InstanceType == "" {
+		c.Machine.AWS.InstanceType = "t3.micro"
+	}
+	if c.Machine.AWS.Image == "" {
+		c.Machine.AWS.Image = "ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-*"
+	}
 }
 
 // migrateLegacyConfig migrates from the old config format to the new one
@@ -411,6 +984,41 @@ func (p *ProvisioningConfig) GetReadinessInterval() time.Duration {
 	return d
 }
 
+// GetPhoneHomeTimeout returns how long to wait for a node's phone-home
+func (p *ProvisioningConfig) GetPhoneHomeTimeout() time.Duration {
+	d, err := time.ParseDuration(p.PhoneHomeTimeout)
+	if err != nil {
+		return 15 * time.Minute // default
+	}
+	return d
+}
+
+// GetPhoneHomeListen returns the phone-home listener's address
+func (p *ProvisioningConfig) GetPhoneHomeListen() string {
+	if p.PhoneHomeListen != "" {
+		return p.PhoneHomeListen
+	}
+	if u, err := url.Parse(p.PhoneHomeURL); err == nil && u.Port() != "" {
+		return ":" + u.Port()
+	}
+	return ":8475"
+}
+
+// GetCloudInitDir returns the directory of user-supplied cloud-init templates
+func (p *ProvisioningConfig) GetCloudInitDir() string {
+	if p.CloudInitDir != "" {
+		if strings.HasPrefix(p.CloudInitDir, "~/") {
+			return filepath.Join(os.Getenv("HOME"), p.CloudInitDir[2:])
+		}
+		return p.CloudInitDir
+	}
+	homeDir := os.Getenv("HOME")
+	if homeDir == "" {
+		homeDir = "/tmp"
+	}
+	return filepath.Join(homeDir, ".morpheus", "cloudinit")
+}
+
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
 	provider := c.GetMachineProvider()
@@ -420,23 +1028,40 @@ func (c *Config) Validate() error {
 
 	switch provider {
 	case "hetzner":
-		if c.Secrets.HetznerAPIToken == "" {
-			return fmt.Errorf("hetzner_api_token is required (set via config or HETZNER_API_TOKEN env var)")
+		if _, err := c.GetHetznerToken(""); err != nil {
+			return err
+		}
+	case "proxmox":
+		px := c.Machine.Proxmox
+		if px.GetHost() == "" || px.GetAPITokenID() == "" || px.GetAPITokenSecret() == "" {
+			return fmt.Errorf("machine.proxmox needs host, api_token_id and api_token_secret (or PROXMOX_HOST, PROXMOX_TOKEN_ID and PROXMOX_API_TOKEN)")
+		}
+		switch px.Type {
+		case "", "qemu":
+			if px.Template == "" {
+				return fmt.Errorf("machine.proxmox.template is required: the name or VMID of a cloud-init template VM")
+			}
+		case "lxc":
+			if px.Template == "" {
+				return fmt.Errorf("machine.proxmox.template is required: an OS template such as local:vztmpl/ubuntu-24.04-cloud.tar.xz")
+			}
+		default:
+			return fmt.Errorf("invalid machine.proxmox.type %q (supported: qemu, lxc)", px.Type)
 		}
 	case "local":
 		// Local provider has minimal requirements - Docker is checked at runtime
 	case "none":
 		// No-op provider has no requirements
 	default:
-		return fmt.Errorf("unsupported provider: %s (supported: hetzner, local, none)", provider)
+		return fmt.Errorf("unsupported provider: %s (supported: hetzner, proxmox, local, none)", provider)
 	}
 
 	// Validate DNS provider if specified
 	if c.DNS.Provider != "" && c.DNS.Provider != "none" {
 		switch c.DNS.Provider {
 		case "hetzner":
-			if c.Secrets.HetznerAPIToken == "" {
-				return fmt.Errorf("hetzner_api_token is required for Hetzner DNS (set via config or HETZNER_API_TOKEN env var)")
+			if c.GetDNSToken() == "" {
+				return fmt.Errorf("hetzner_dns_token or hetzner_api_token is required for Hetzner DNS (set via config or HETZNER_DNS_TOKEN / HETZNER_API_TOKEN env var)")
 			}
 		case "hosts":
 			// hosts provider uses /etc/hosts, no credentials needed
@@ -444,16 +1069,180 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("unsupported DNS provider: %s (supported: hetzner, hosts, none)", c.DNS.Provider)
 		}
 	}
+	switch c.GetDNSAPI() {
+	case "auto", "cloud", "legacy":
+	default:
+		return fmt.Errorf("unsupported dns.api: %s (supported: auto, cloud, legacy)", c.GetDNSAPI())
+	}
+
+	switch c.Provisioning.PhoneHome {
+	case "":
+	case "storagebox":
+		if c.Storage.StorageBox.Host == "" {
+			return fmt.Errorf("storage.storagebox is required for phone_home: storagebox")
+		}
+	case "http":
+		if c.Provisioning.PhoneHomeURL == "" {
+			return fmt.Errorf("provisioning.phone_home_url is required for phone_home: http (the URL nodes reach morpheus at)")
+		}
+	default:
+		return fmt.Errorf("unsupported provisioning.phone_home: %s (supported: http, storagebox)", c.Provisioning.PhoneHome)
+	}
+
+	switch store := c.Secrets.Store; store.Backend {
+	case "", "dir":
+	case "storagebox":
+		if c.Storage.StorageBox.Host == "" {
+			return fmt.Errorf("storage.storagebox is required for secrets.store.backend: storagebox")
+		}
+	case "vault":
+		if store.Vault.Address == "" || store.Vault.Token == "" {
+			return fmt.Errorf("secrets.store.vault needs an address and a token (or set VAULT_ADDR and VAULT_TOKEN)")
+		}
+	default:
+		return fmt.Errorf("unsupported secrets.store.backend: %s (supported: dir, storagebox, vault)", store.Backend)
+	}
+
+	if nats := c.Provisioning.NATS; nats.Enabled {
+		if !natsVersionPattern.MatchString(nats.GetVersion()) {
+			return fmt.Errorf("invalid provisioning.nats.version: %s", nats.Version)
+		}
+		for _, account := range nats.Accounts {
+			if account.Name == "" {
+				return fmt.Errorf("provisioning.nats.accounts: every account needs a name")
+			}
+			for _, user := range account.Users {
+				if user.User == "" || user.Password == "" {
+					return fmt.Errorf("provisioning.nats.accounts: users of account %s need a user and password", account.Name)
+				}
+			}
+		}
+	}
+
+	if err := c.Provisioning.validateRoles(); err != nil {
+		return err
+	}
+
+	for _, check := range c.Provisioning.Verify {
+		if err := check.Validate(); err != nil {
+			return fmt.Errorf("provisioning.verify: %w", err)
+		}
+	}
+
+	retry := c.Machine.Retry
+	for key, value := range map[string]string{"base_delay": retry.BaseDelay, "max_delay": retry.MaxDelay} {
+		if _, err := time.ParseDuration(value); value != "" && err != nil {
+			return fmt.Errorf("invalid machine.retry.%s: %s", key, value)
+		}
+	}
+	if retry.MaxAttempts < 0 {
+		return fmt.Errorf("machine.retry.max_attempts must not be negative")
+	}
+	if c.Limits.MaxMonthlyCost < 0 {
+		return fmt.Errorf("limits.max_monthly_cost must not be negative")
+	}
+	if c.Worker.Concurrency < 0 {
+		return fmt.Errorf("worker.concurrency must not be negative")
+	}
+	for name, q := range c.Worker.Customers {
+		if q.MaxForests < 0 || q.MaxNodes < 0 || q.MaxConcurrent < 0 {
+			return fmt.Errorf("worker.customers.%s: limits must not be negative", name)
+		}
+		if slices.Contains(q.Projects, "") {
+			return fmt.Errorf("worker.customers.%s: projects must not be empty", name)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, loc := range c.GetLocations() {
+		if loc == "" || seen[loc] {
+			return fmt.Errorf("machine.hetzner.locations must list distinct, non-empty locations")
+		}
+		seen[loc] = true
+	}
+	if err := c.Naming.Validate(); err != nil {
+		return err
+	}
+	if _, err := c.Mode.GetLocation(); err != nil {
+		return err
+	}
+	for i, e := range c.Mode.Schedule {
+		switch {
+		case e.Cron == "":
+			return fmt.Errorf("mode.schedule[%d]: cron is required", i)
+		case e.Mode != "linux" && e.Mode != "windows":
+			return fmt.Errorf("mode.schedule[%d]: invalid mode %q (valid modes: linux, windows)", i, e.Mode)
+		}
+	}
+	for mode, hooks := range c.Mode.Hooks {
+		if mode != "linux" && mode != "windows" {
+			return fmt.Errorf("mode.hooks: invalid mode %q (valid modes: linux, windows)", mode)
+		}
+		for stage, list := range map[string][]ModeHook{"pre_shutdown": hooks.PreShutdown, "post_start": hooks.PostStart} {
+			for i, h := range list {
+				if (h.Run == "") == (h.SSH == "") {
+					return fmt.Errorf("mode.hooks.%s.%s[%d]: set one of run or ssh", mode, stage, i)
+				}
+				if _, err := time.ParseDuration(h.Timeout); h.Timeout != "" && err != nil {
+					return fmt.Errorf("mode.hooks.%s.%s[%d]: invalid timeout %q", mode, stage, i, h.Timeout)
+				}
+			}
+		}
+	}
+
+	// Validate NetBox integration if enabled
+	if nb := c.Integration.NetBox; nb.IsEnabled() {
+		switch {
+		case nb.Token == "":
+			return fmt.Errorf("integration.netbox.token is required (set via config or NETBOX_TOKEN env var)")
+		case nb.Site == "", nb.DeviceRole == "", nb.DeviceType == "":
+			return fmt.Errorf("integration.netbox requires site, device_role and device_type")
+		}
+	}
 
 	return nil
 }
 
 // ValidateGuard checks if the configuration is valid for guard operations
 func (c *Config) ValidateGuard() error {
+	switch c.GetGuardProvider() {
+	case "azure":
+	case "gcp":
+		// Project and credentials come from the gcloud configuration
+		return nil
+	case "aws":
+		// Region and credentials come from the AWS profile
+		return nil
+	case "hetzner":
+		_, err := c.GetHetznerToken("")
+		return err
+	default:
+		return fmt.Errorf("unknown guard.provider %q (use azure, gcp, aws or hetzner)", c.Guard.Provider)
+	}
+
 	azure := c.Machine.Azure
+	auth := azure.GetAuth()
+	if !slices.Contains(AzureAuthMethods, auth) {
+		return fmt.Errorf("unknown machine.azure.auth %q (use %s)", auth, strings.Join(AzureAuthMethods, ", "))
+	}
+	if spot := azure.Spot; spot.Enabled {
+		if policy := spot.GetEvictionPolicy(); policy != "deallocate" && policy != "delete" {
+			return fmt.Errorf("unknown machine.azure.spot.eviction_policy %q (use deallocate or delete)", policy)
+		}
+		if spot.GetMaxPrice() < 0 && spot.GetMaxPrice() != -1 {
+			return fmt.Errorf("machine.azure.spot.max_price must be positive, or -1 for up to the on-demand price")
+		}
+	}
+	if azure.Profile != "" {
+		// Subscription and tenant come from the Azure CLI profile
+		return nil
+	}
 	if azure.SubscriptionID == "" {
 		return fmt.Errorf("machine.azure.subscription_id is required (or set AZURE_SUBSCRIPTION_ID)")
 	}
+	if auth != "client_secret" {
+		// The credential comes from the CLI, the environment or the VM
+		return nil
+	}
 	if azure.TenantID == "" {
 		return fmt.Errorf("machine.azure.tenant_id is required (or set AZURE_TENANT_ID)")
 	}
@@ -466,6 +1255,14 @@ func (c *Config) ValidateGuard() error {
 	return nil
 }
 
+// GetGuardProvider returns the cloud guards are created in (default: azure)
+func (c *Config) GetGuardProvider() string {
+	if c.Guard.Provider != "" {
+		return c.Guard.Provider
+	}
+	return "azure"
+}
+
 // GetMachineProvider returns the machine provider (with legacy fallback)
 func (c *Config) GetMachineProvider() string {
 	if c.Machine.Provider != "" {
@@ -504,6 +1301,9 @@ func (c *Config) GetSSHKeyPath() string {
 
 // GetServerType returns the server type (with legacy fallback)
 func (c *Config) GetServerType() string {
+	if c.GetMachineProvider() == "proxmox" {
+		return c.Machine.Proxmox.ServerType
+	}
 	if c.Machine.Hetzner.ServerType != "" {
 		return c.Machine.Hetzner.ServerType
 	}
@@ -520,6 +1320,9 @@ func (c *Config) GetServerTypeFallback() []string {
 
 // GetImage returns the image (with legacy fallback)
 func (c *Config) GetImage() string {
+	if c.GetMachineProvider() == "proxmox" {
+		return c.Machine.Proxmox.Template
+	}
 	if c.Machine.Hetzner.Image != "" {
 		return c.Machine.Hetzner.Image
 	}
@@ -531,6 +1334,9 @@ func (c *Config) GetImage() string {
 
 // GetLocation returns the location (with legacy fallback)
 func (c *Config) GetLocation() string {
+	if c.GetMachineProvider() == "proxmox" {
+		return c.Machine.Proxmox.GetNode()
+	}
 	if c.Machine.Hetzner.Location != "" {
 		return c.Machine.Hetzner.Location
 	}
@@ -540,11 +1346,59 @@ func (c *Config) GetLocation() string {
 	return "fsn1"
 }
 
+// GetLocations returns the locations plant --spread distributes nodes
+// across (with legacy fallback)
+func (c *Config) GetLocations() []string {
+	if len(c.Machine.Hetzner.Locations) > 0 {
+		return c.Machine.Hetzner.Locations
+	}
+	return c.Infrastructure.Locations
+}
+
 // IsIPv4Enabled returns whether IPv4 is enabled
 func (c *Config) IsIPv4Enabled() bool {
 	return c.Machine.IPv4.Enabled || c.Infrastructure.EnableIPv4Fallback
 }
 
+// GetMaxAttempts returns the number of attempts per API call
+func (r *RetryConfig) GetMaxAttempts() int {
+	if r.MaxAttempts <= 0 {
+		return 5 // default
+	}
+	return r.MaxAttempts
+}
+
+// GetBaseDelay returns the delay before the first retry
+func (r *RetryConfig) GetBaseDelay() time.Duration {
+	d, err := time.ParseDuration(r.BaseDelay)
+	if err != nil || d <= 0 {
+		return time.Second // default
+	}
+	return d
+}
+
+// GetMaxDelay returns the longest delay between retries
+func (r *RetryConfig) GetMaxDelay() time.Duration {
+	d, err := time.ParseDuration(r.MaxDelay)
+	if err != nil || d <= 0 {
+		return 30 * time.Second // default
+	}
+	return d
+}
+
+// UsePlacementGroup returns whether a forest of nodeCount nodes should be
+// spread across physical hosts
+func (c *Config) UsePlacementGroup(nodeCount int) bool {
+	if c.Machine.Placement.Disabled {
+		return false
+	}
+	minNodes := c.Machine.Placement.MinNodes
+	if minNodes <= 0 {
+		minNodes = 3
+	}
+	return nodeCount >= minNodes
+}
+
 // GetStorageProvider returns the storage provider
 func (c *Config) GetStorageProvider() string {
 	if c.Storage.Provider != "" {
@@ -556,6 +1410,15 @@ func (c *Config) GetStorageProvider() string {
 	return "local"
 }
 
+// GetOperator returns the configured operator identity, or
+// $MORPHEUS_OPERATOR; "" means it is derived from git or the login
+func (c *Config) GetOperator() string {
+	if c.Storage.Operator != "" {
+		return os.ExpandEnv(c.Storage.Operator)
+	}
+	return os.Getenv("MORPHEUS_OPERATOR")
+}
+
 // IsRemoteRegistry returns true if the registry is configured to use remote storage
 func (c *Config) IsRemoteRegistry() bool {
 	provider := c.GetStorageProvider()
@@ -568,16 +1431,70 @@ func (c *Config) GetRegistryType() string {
 	return c.GetStorageProvider()
 }
 
+// GetHetznerProject returns the active Hetzner project name, or "" when
+// the single hetzner_api_token is used
+func (c *Config) GetHetznerProject() string {
+	return c.Secrets.HetznerProject
+}
+
+// GetHetznerToken returns the Hetzner API token of a named project. An
+// empty project resolves to the active project, or hetzner_api_token when
+// no project is active.
+func (c *Config) GetHetznerToken(project string) (string, error) {
+	if project == "" {
+		project = c.Secrets.HetznerProject
+	}
+	if project == "" {
+		if c.Secrets.HetznerAPIToken == "" {
+			return "", fmt.Errorf("hetzner_api_token is required (set via config or HETZNER_API_TOKEN env var)")
+		}
+		return c.Secrets.HetznerAPIToken, nil
+	}
+
+	token, ok := c.Secrets.HetznerProjects[project]
+	if !ok {
+		return "", fmt.Errorf("unknown Hetzner project %q (add it under secrets.hetzner_projects)", project)
+	}
+	if token == "" {
+		return "", fmt.Errorf("Hetzner project %q has no API token", project)
+	}
+	return token, nil
+}
+
 // GetDNSToken returns the API token for DNS operations
-// Hetzner Cloud API token works for both Cloud and DNS APIs
+// Uses hetzner_dns_token when set, otherwise falls back to the Hetzner Cloud
+// API token (which works for both Cloud and DNS APIs)
 func (c *Config) GetDNSToken() string {
-	return c.Secrets.HetznerAPIToken
+	if c.Secrets.HetznerDNSToken != "" {
+		return c.Secrets.HetznerDNSToken
+	}
+	token, _ := c.GetHetznerToken("")
+	return token
+}
+
+// GetDNSAPI returns which Hetzner DNS API to use: $HETZNER_DNS_API, else
+// dns.api, else "auto"
+func (c *Config) GetDNSAPI() string {
+	if api := strings.TrimSpace(os.Getenv("HETZNER_DNS_API")); api != "" {
+		return api
+	}
+	if c.DNS.API != "" {
+		return c.DNS.API
+	}
+	return "auto"
+}
+
+// IsDNSTokenFallback returns true when DNS operations use the main API token
+// because no dedicated DNS token is configured
+func (c *Config) IsDNSTokenFallback() bool {
+	return c.Secrets.HetznerDNSToken == "" && c.GetDNSToken() != ""
 }
 
 // IsNimsForestInstallEnabled returns whether NimsForest should be installed
-// By default, NimsForest is installed unless explicitly disabled via config
+// By default, NimsForest is installed unless explicitly disabled via config,
+// or a NATS cluster is bootstrapped in place of its embedded server
 func (c *Config) IsNimsForestInstallEnabled() bool {
-	return c.Integration.NimsForestInstall
+	return c.Integration.NimsForestInstall && !c.Provisioning.NATS.Enabled
 }
 
 // GetNimsForestDownloadURL returns the NimsForest download URL
@@ -615,17 +1532,53 @@ func GetDefaultConfigPath() string {
 	return filepath.Join(homeDir, ".morpheus", "config.yaml")
 }
 
-// FindConfigPath searches for an existing config file
-// Returns empty string if no config file is found
-func FindConfigPath() string {
-	configPaths := []string{
-		"./config.yaml",
-		filepath.Join(os.Getenv("HOME"), ".morpheus", "config.yaml"),
-		"/etc/morpheus/config.yaml",
+// ConfigEnv names the environment variable that overrides config discovery
+// (the global --config flag sets it)
+const ConfigEnv = "MORPHEUS_CONFIG"
+
+// ProjectConfigNames are the per-project config files searched for in the
+// working directory and its parents, so each project of a monorepo can have
+// its own config
+var ProjectConfigNames = []string{"morpheus.yaml", filepath.Join(".morpheus", "config.yaml")}
+
+// ConfigSearchPaths returns where config files are looked for, in order:
+// ./config.yaml, the project config files in the working directory and
+// each of its parents (like git), ~/.morpheus/config.yaml and
+// /etc/morpheus/config.yaml. MORPHEUS_CONFIG replaces the search.
+func ConfigSearchPaths() []string {
+	if path := os.Getenv(ConfigEnv); path != "" {
+		return []string{path}
+	}
+
+	paths := []string{"./config.yaml"}
+	if dir, err := os.Getwd(); err == nil {
+		for {
+			for _, name := range ProjectConfigNames {
+				paths = append(paths, filepath.Join(dir, name))
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+	home := GetDefaultConfigPath()
+	if !slices.Contains(paths, home) {
+		paths = append(paths, home)
 	}
+	return append(paths, "/etc/morpheus/config.yaml")
+}
 
-	for _, path := range configPaths {
-		if _, err := os.Stat(path); err == nil {
+// FindConfigPath searches for an existing config file (see
+// ConfigSearchPaths). It returns MORPHEUS_CONFIG if set, even if the file
+// does not exist yet, and else an empty string if no config file is found.
+func FindConfigPath() string {
+	if path := os.Getenv(ConfigEnv); path != "" {
+		return path
+	}
+	for _, path := range ConfigSearchPaths() {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
 			return path
 		}
 	}
@@ -665,8 +1618,9 @@ func SaveConfig(path string, config *Config) error {
 }
 
 // SetConfigValue sets a specific configuration value and saves to file
-// Supported keys: hetzner_api_token, storagebox_password,
-// machine_provider, ssh_key_name, ipv4_enabled, dns_provider, dns_domain
+// Supported keys: hetzner_api_token, hetzner_dns_token, hetzner_project,
+// hetzner_projects.<name>, storagebox_password, machine_provider,
+// ssh_key_name, ipv4_enabled, dns_provider, dns_domain
 func SetConfigValue(configPath, key, value string) error {
 	var config *Config
 	var err error
@@ -682,10 +1636,26 @@ func SetConfigValue(configPath, key, value string) error {
 		config.applyDefaults()
 	}
 
+	// Named project tokens: hetzner_projects.<name>
+	if name, ok := strings.CutPrefix(key, "hetzner_projects."); ok {
+		if name == "" {
+			return fmt.Errorf("project name is required: hetzner_projects.<name>")
+		}
+		if config.Secrets.HetznerProjects == nil {
+			config.Secrets.HetznerProjects = make(map[string]string)
+		}
+		config.Secrets.HetznerProjects[name] = strings.TrimSpace(value)
+		return SaveConfig(configPath, config)
+	}
+
 	// Set the value based on key
 	switch key {
 	case "hetzner_api_token", "hetzner-api-token":
 		config.Secrets.HetznerAPIToken = strings.TrimSpace(value)
+	case "hetzner_project", "hetzner-project":
+		config.Secrets.HetznerProject = strings.TrimSpace(value)
+	case "hetzner_dns_token", "hetzner-dns-token":
+		config.Secrets.HetznerDNSToken = strings.TrimSpace(value)
 	case "storagebox_password", "storagebox-password":
 		config.Storage.StorageBox.Password = strings.TrimSpace(value)
 	case "machine_provider", "machine-provider":
@@ -700,6 +1670,8 @@ func SetConfigValue(configPath, key, value string) error {
 		config.DNS.Provider = strings.TrimSpace(value)
 	case "dns_domain", "dns-domain":
 		config.DNS.Domain = strings.TrimSpace(value)
+	case "dns_api", "dns-api":
+		config.DNS.API = strings.TrimSpace(value)
 	case "server_type", "server-type":
 		config.Machine.Hetzner.ServerType = strings.TrimSpace(value)
 	case "location":
@@ -716,6 +1688,10 @@ func SetConfigValue(configPath, key, value string) error {
 // GetConfigValue gets a specific configuration value
 // Returns the value and whether it came from environment variable
 func GetConfigValue(config *Config, key string) (value string, fromEnv bool) {
+	if name, ok := strings.CutPrefix(key, "hetzner_projects."); ok {
+		return config.Secrets.HetznerProjects[name], false
+	}
+
 	switch key {
 	case "hetzner_api_token", "hetzner-api-token":
 		// Check if it came from env
@@ -723,6 +1699,16 @@ func GetConfigValue(config *Config, key string) (value string, fromEnv bool) {
 			return config.Secrets.HetznerAPIToken, true
 		}
 		return config.Secrets.HetznerAPIToken, false
+	case "hetzner_dns_token", "hetzner-dns-token":
+		if envVal := strings.TrimSpace(os.Getenv("HETZNER_DNS_TOKEN")); envVal != "" && envVal == config.Secrets.HetznerDNSToken {
+			return config.Secrets.HetznerDNSToken, true
+		}
+		return config.Secrets.HetznerDNSToken, false
+	case "hetzner_project", "hetzner-project":
+		if envVal := strings.TrimSpace(os.Getenv("HETZNER_PROJECT")); envVal != "" && envVal == config.Secrets.HetznerProject {
+			return config.Secrets.HetznerProject, true
+		}
+		return config.Secrets.HetznerProject, false
 	case "storagebox_password", "storagebox-password":
 		if envVal := strings.TrimSpace(os.Getenv("STORAGEBOX_PASSWORD")); envVal != "" && envVal == config.Storage.StorageBox.Password {
 			return config.Storage.StorageBox.Password, true
@@ -740,6 +1726,8 @@ func GetConfigValue(config *Config, key string) (value string, fromEnv bool) {
 		return config.DNS.Provider, false
 	case "dns_domain", "dns-domain":
 		return config.DNS.Domain, false
+	case "dns_api", "dns-api":
+		return config.GetDNSAPI(), false
 	case "server_type", "server-type":
 		return config.GetServerType(), false
 	case "location":
@@ -766,6 +1754,8 @@ func MaskToken(token string) string {
 func ListConfigKeys() []string {
 	return []string{
 		"hetzner_api_token",
+		"hetzner_dns_token",
+		"hetzner_project",
 		"storagebox_password",
 		"machine_provider",
 		"ssh_key_name",
@@ -773,6 +1763,7 @@ func ListConfigKeys() []string {
 		"ipv4_enabled",
 		"dns_provider",
 		"dns_domain",
+		"dns_api",
 		"server_type",
 		"location",
 		"image",
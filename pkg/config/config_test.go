@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -173,6 +174,134 @@ secrets:
 	}
 }
 
+func TestGetDNSToken(t *testing.T) {
+	os.Unsetenv("HETZNER_API_TOKEN")
+	os.Unsetenv("HETZNER_DNS_TOKEN")
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	configContent := `
+machine:
+  provider: hetzner
+
+secrets:
+  hetzner_api_token: main-token
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if got := cfg.GetDNSToken(); got != "main-token" {
+		t.Errorf("Expected fallback to main token, got '%s'", got)
+	}
+	if !cfg.IsDNSTokenFallback() {
+		t.Error("Expected IsDNSTokenFallback() to be true without a DNS token")
+	}
+
+	os.Setenv("HETZNER_DNS_TOKEN", " dns-token\n")
+	defer os.Unsetenv("HETZNER_DNS_TOKEN")
+
+	cfg, err = LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if got := cfg.GetDNSToken(); got != "dns-token" {
+		t.Errorf("Expected DNS token from env 'dns-token', got '%s'", got)
+	}
+	if cfg.IsDNSTokenFallback() {
+		t.Error("Expected IsDNSTokenFallback() to be false with a DNS token")
+	}
+}
+
+func TestGetDNSAPI(t *testing.T) {
+	t.Setenv("HETZNER_DNS_API", "")
+
+	cfg := &Config{}
+	if got := cfg.GetDNSAPI(); got != "auto" {
+		t.Errorf("Expected default DNS API 'auto', got '%s'", got)
+	}
+	cfg.DNS.API = "legacy"
+	if got := cfg.GetDNSAPI(); got != "legacy" {
+		t.Errorf("Expected DNS API 'legacy' from config, got '%s'", got)
+	}
+	t.Setenv("HETZNER_DNS_API", "cloud")
+	if got := cfg.GetDNSAPI(); got != "cloud" {
+		t.Errorf("Expected DNS API 'cloud' from env, got '%s'", got)
+	}
+}
+
+func TestGetHetznerToken(t *testing.T) {
+	os.Unsetenv("HETZNER_API_TOKEN")
+	os.Unsetenv("HETZNER_PROJECT")
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	configContent := `
+machine:
+  provider: hetzner
+
+secrets:
+  hetzner_api_token: main-token
+  hetzner_projects:
+    staging: " staging-token\n"
+    production: prod-token
+  hetzner_project: staging
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	tests := []struct {
+		project string
+		want    string
+		wantErr bool
+	}{
+		{"", "staging-token", false},
+		{"production", "prod-token", false},
+		{"missing", "", true},
+	}
+	for _, tt := range tests {
+		got, err := cfg.GetHetznerToken(tt.project)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("GetHetznerToken(%q) = %q, %v; want %q", tt.project, got, err, tt.want)
+		}
+	}
+	if got := cfg.GetDNSToken(); got != "staging-token" {
+		t.Errorf("Expected DNS token to fall back to the active project, got '%s'", got)
+	}
+
+	os.Setenv("HETZNER_PROJECT", "production")
+	defer os.Unsetenv("HETZNER_PROJECT")
+
+	cfg, err = LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if got, _ := cfg.GetHetznerToken(""); got != "prod-token" {
+		t.Errorf("Expected HETZNER_PROJECT to select 'prod-token', got '%s'", got)
+	}
+
+	cfg.Secrets.HetznerProject = "missing"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected Validate() to fail for an unknown active project")
+	}
+}
+
 func TestLoadConfigFileNotFound(t *testing.T) {
 	_, err := LoadConfig("/nonexistent/config.yaml")
 	if err == nil {
@@ -262,6 +391,157 @@ func TestValidate(t *testing.T) {
 			},
 			expectErr: false,
 		},
+		{
+			name: "valid proxmox config",
+			config: Config{
+				Machine: MachineConfig{Provider: "proxmox", Proxmox: ProxmoxConfig{
+					Host: "192.168.1.100", APITokenID: "morpheus@pam!t", APITokenSecret: "secret", Template: "9000",
+				}},
+			},
+			expectErr: false,
+		},
+		{
+			name: "unknown proxmox type",
+			config: Config{
+				Machine: MachineConfig{Provider: "proxmox", Proxmox: ProxmoxConfig{
+					Host: "192.168.1.100", APITokenID: "morpheus@pam!t", APITokenSecret: "secret", Template: "9000", Type: "kvm",
+				}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "proxmox without template",
+			config: Config{
+				Machine: MachineConfig{Provider: "proxmox", Proxmox: ProxmoxConfig{
+					Host: "192.168.1.100", APITokenID: "morpheus@pam!t", APITokenSecret: "secret",
+				}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "valid mode schedule",
+			config: Config{
+				Machine: MachineConfig{Provider: "none"},
+				Mode: ModeConfig{Timezone: "Europe/Amsterdam", Schedule: []ModeScheduleEntry{
+					{Cron: "0 22 * * *", Mode: "linux"}, {Cron: "0 7 * * *", Mode: "windows"},
+				}},
+			},
+			expectErr: false,
+		},
+		{
+			name: "mode schedule with unknown mode",
+			config: Config{
+				Machine: MachineConfig{Provider: "none"},
+				Mode:    ModeConfig{Schedule: []ModeScheduleEntry{{Cron: "0 22 * * *", Mode: "nimsforestgpu"}}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "mode hook with both run and ssh",
+			config: Config{
+				Machine: MachineConfig{Provider: "none"},
+				Mode: ModeConfig{Hooks: map[string]ModeHooks{
+					"linux": {PreShutdown: []ModeHook{{Run: "./checkpoint.sh", SSH: "nimsforest checkpoint"}}},
+				}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "valid mode hooks",
+			config: Config{
+				Machine: MachineConfig{Provider: "none"},
+				Mode: ModeConfig{Hooks: map[string]ModeHooks{
+					"linux": {
+						PreShutdown: []ModeHook{{SSH: "nimsforest checkpoint", Timeout: "10m"}},
+						PostStart:   []ModeHook{{Run: "./warm-cache.sh", ContinueOnError: true}},
+					},
+				}},
+			},
+			expectErr: false,
+		},
+		{
+			name: "mode schedule with unknown timezone",
+			config: Config{
+				Machine: MachineConfig{Provider: "none"},
+				Mode:    ModeConfig{Timezone: "Mars/Olympus"},
+			},
+			expectErr: true,
+		},
+		{
+			name: "netbox without token",
+			config: Config{
+				Machine: MachineConfig{Provider: "none"},
+				Integration: IntegrationConfig{
+					NetBox: NetBoxConfig{URL: "https://netbox.example.com", Site: "fsn1", DeviceRole: "node", DeviceType: "cloud"},
+				},
+			},
+			expectErr: true,
+		},
+		{
+			name: "valid netbox config",
+			config: Config{
+				Machine: MachineConfig{Provider: "none"},
+				Integration: IntegrationConfig{
+					NetBox: NetBoxConfig{URL: "https://netbox.example.com", Token: "t", Site: "fsn1", DeviceRole: "node", DeviceType: "cloud"},
+				},
+			},
+			expectErr: false,
+		},
+		{
+			name: "nats with invalid version",
+			config: Config{
+				Machine:      MachineConfig{Provider: "none"},
+				Provisioning: ProvisioningConfig{NATS: NATSConfig{Enabled: true, Version: "latest; rm -rf /"}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "nats user without password",
+			config: Config{
+				Machine: MachineConfig{Provider: "none"},
+				Provisioning: ProvisioningConfig{NATS: NATSConfig{Enabled: true, Accounts: []NATSAccountConfig{
+					{Name: "APP", Users: []NATSUserConfig{{User: "app"}}},
+				}}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "valid nats config",
+			config: Config{
+				Machine: MachineConfig{Provider: "none"},
+				Provisioning: ProvisioningConfig{NATS: NATSConfig{Enabled: true, Version: "v2.11.0", Accounts: []NATSAccountConfig{
+					{Name: "APP", Users: []NATSUserConfig{{User: "app", Password: "pw"}}},
+				}}},
+			},
+			expectErr: false,
+		},
+		{
+			name: "unknown dns api",
+			config: Config{
+				Machine: MachineConfig{Provider: "none"},
+				DNS:     DNSConfig{API: "v2"},
+			},
+			expectErr: true,
+		},
+		{
+			name: "verify check with two kinds",
+			config: Config{
+				Machine:      MachineConfig{Provider: "none"},
+				Provisioning: ProvisioningConfig{Verify: []VerifyCheck{{Name: "web", TCP: 80, HTTP: "/"}}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "valid verify checks",
+			config: Config{
+				Machine: MachineConfig{Provider: "none"},
+				Provisioning: ProvisioningConfig{Verify: []VerifyCheck{
+					{Name: "web", HTTP: "/healthz", Port: 8080, Timeout: "2m"},
+					{Name: "app", Command: "systemctl is-active app"},
+				}},
+			},
+			expectErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -929,3 +1209,243 @@ func TestGetDefaultConfigPath(t *testing.T) {
 		t.Error("GetDefaultConfigPath() returned empty string")
 	}
 }
+
+func TestReplaceSecret(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	original := `# Morpheus config
+secrets:
+  hetzner_api_token: "old-token" # main project
+  hetzner_dns_token: dns-token
+  hetzner_projects:
+    prod: old-token
+    staging: other-token
+`
+	if err := os.WriteFile(configPath, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := ReplaceSecret(configPath, "old-token", "new-token")
+	if err != nil {
+		t.Fatalf("ReplaceSecret failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 replacements, got %d", n)
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "# main project") {
+		t.Errorf("Expected comments to be kept, got:\n%s", data)
+	}
+	if info, _ := os.Stat(configPath); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected file permissions 0600, got %v", info.Mode().Perm())
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Secrets.HetznerAPIToken != "new-token" || cfg.Secrets.HetznerProjects["prod"] != "new-token" {
+		t.Errorf("Expected old-token replaced, got %+v", cfg.Secrets)
+	}
+	if cfg.Secrets.HetznerDNSToken != "dns-token" || cfg.Secrets.HetznerProjects["staging"] != "other-token" {
+		t.Errorf("Expected other secrets unchanged, got %+v", cfg.Secrets)
+	}
+
+	if n, err := ReplaceSecret(configPath, "missing", "x"); err != nil || n != 0 {
+		t.Errorf("ReplaceSecret(missing) = %d, %v; want 0, nil", n, err)
+	}
+}
+
+func TestNamingConfig(t *testing.T) {
+	data := NameData{ForestID: "forest-1", Index: 2, Customer: "acme"}
+
+	var defaults NamingConfig
+	if got := defaults.ServerName(data); got != "forest-1-node-2" {
+		t.Errorf("default ServerName() = %q", got)
+	}
+	if got := defaults.DNSRecordName(data); got != "forest-1-node-2" {
+		t.Errorf("default DNSRecordName() = %q", got)
+	}
+	if got := defaults.PrimaryRecordName(data); got != "forest-1-primary" {
+		t.Errorf("default PrimaryRecordName() = %q", got)
+	}
+	if got := defaults.FloatingIPName(data); got != "forest-1-ip" {
+		t.Errorf("default FloatingIPName() = %q", got)
+	}
+	if got := defaults.ForestRecordName(data); got != "forest-1" {
+		t.Errorf("default ForestRecordName() = %q", got)
+	}
+	if got := defaults.GuardID(NameData{Timestamp: 42}); got != "guard-42" {
+		t.Errorf("default GuardID() = %q", got)
+	}
+
+	naming := NamingConfig{
+		Server:    `{{.Customer | upper}}-{{.Role}}-{{printf "%03d" .Index}}`,
+		DNSRecord: "{{.ForestID}}-{{.Index}}",
+	}
+	if err := naming.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if got := naming.ServerName(data); got != "ACME-node-002" {
+		t.Errorf("ServerName() = %q", got)
+	}
+	if got := naming.DNSRecordName(data); got != "forest-1-2" {
+		t.Errorf("DNSRecordName() = %q", got)
+	}
+	// Record names may span several labels, e.g. nodeN.forest-id.domain
+	dotted := NamingConfig{DNSRecord: "node{{.Index}}.{{.ForestID}}"}
+	if err := dotted.Validate(); err != nil {
+		t.Errorf("Validate() of dotted record name error = %v", err)
+	}
+	if got := dotted.DNSRecordName(data); got != "node2.forest-1" {
+		t.Errorf("dotted DNSRecordName() = %q", got)
+	}
+	// Names that come out invalid fall back to the default
+	if got := naming.ServerName(NameData{ForestID: "forest-1", Index: 2}); got != "forest-1-node-2" {
+		t.Errorf("ServerName() without customer = %q", got)
+	}
+
+	for name, invalid := range map[string]NamingConfig{
+		"parse error":    {Server: "{{.ForestID"},
+		"unknown field":  {Server: "{{.Zone}}-{{.Index}}"},
+		"invalid name":   {DNSRecord: "{{.ForestID}}_{{.Index}}"},
+		"not unique":     {Server: "{{.ForestID}}"},
+		"dotted server":  {Server: "node{{.Index}}.{{.ForestID}}"},
+		"empty label":    {DNSRecord: "node{{.Index}}..{{.ForestID}}"},
+		"guard per node": {Guard: "guard-{{.Index}}"},
+		"dotted ip":      {FloatingIP: "ip.{{.ForestID}}"},
+		"invalid record": {ForestRecord: "{{.ForestID}}_vip"},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("%s: Validate() succeeded", name)
+		}
+	}
+}
+
+func TestGetLocations(t *testing.T) {
+	cfg := &Config{Infrastructure: InfrastructureConfig{Locations: []string{"hel1", "nbg1"}}}
+	if got := cfg.GetLocations(); len(got) != 2 || got[0] != "hel1" {
+		t.Errorf("GetLocations() with legacy config = %v", got)
+	}
+	cfg.Machine.Hetzner.Locations = []string{"fsn1", "nbg1", "hel1"}
+	if got := cfg.GetLocations(); len(got) != 3 || got[0] != "fsn1" {
+		t.Errorf("GetLocations() = %v", got)
+	}
+
+	cfg.Infrastructure.Provider = "hetzner"
+	cfg.Infrastructure.SSH.KeyName = "main"
+	cfg.Secrets.HetznerAPIToken = "token"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	cfg.Machine.Hetzner.Locations = []string{"fsn1", "fsn1"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() accepted duplicate locations")
+	}
+}
+
+func TestValidateRoles(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		roles []RoleConfig
+		ok    bool
+	}{
+		{"layout", []RoleConfig{{Name: "edge", Count: 1}, {Name: "core", Count: 2, ServerType: "cx32"}}, true},
+		{"without counts", []RoleConfig{{Name: "storage", Labels: map[string]string{"disk": "ssd"}}}, true},
+		{"invalid name", []RoleConfig{{Name: "Edge Nodes"}}, false},
+		{"duplicate", []RoleConfig{{Name: "edge"}, {Name: "edge"}}, false},
+		{"negative count", []RoleConfig{{Name: "edge", Count: -1}}, false},
+		{"reserved label", []RoleConfig{{Name: "edge", Labels: map[string]string{"forest-id": "x"}}}, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p := ProvisioningConfig{Roles: tt.roles}
+			if err := p.validateRoles(); (err == nil) != tt.ok {
+				t.Errorf("validateRoles() error = %v, want ok = %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestValidateGuardAzureAuth(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		azure    AzureConfig
+		wantAuth string
+		ok       bool
+	}{
+		{"client secret", AzureConfig{SubscriptionID: "sub", TenantID: "t", ClientID: "c", ClientSecret: "s"}, "client_secret", true},
+		{"client secret missing", AzureConfig{SubscriptionID: "sub"}, "client_secret", false},
+		{"profile", AzureConfig{Profile: "default"}, "cli", true},
+		{"cli", AzureConfig{Auth: "cli", SubscriptionID: "sub"}, "cli", true},
+		{"msi", AzureConfig{Auth: "msi", SubscriptionID: "sub"}, "msi", true},
+		{"env without subscription", AzureConfig{Auth: "env"}, "env", false},
+		{"unknown", AzureConfig{Auth: "password", SubscriptionID: "sub"}, "password", false},
+		{"spot", AzureConfig{Profile: "default", Spot: AzureSpotConfig{Enabled: true, EvictionPolicy: "delete", MaxPrice: 0.02}}, "cli", true},
+		{"spot eviction policy", AzureConfig{Profile: "default", Spot: AzureSpotConfig{Enabled: true, EvictionPolicy: "stop"}}, "cli", false},
+		{"spot max price", AzureConfig{Profile: "default", Spot: AzureSpotConfig{Enabled: true, MaxPrice: -2}}, "cli", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Machine: MachineConfig{Azure: tt.azure}}
+			if got := cfg.Machine.Azure.GetAuth(); got != tt.wantAuth {
+				t.Errorf("GetAuth() = %q, want %q", got, tt.wantAuth)
+			}
+			if err := cfg.ValidateGuard(); (err == nil) != tt.ok {
+				t.Errorf("ValidateGuard() error = %v, want ok = %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestAzureSpotConfigDefaults(t *testing.T) {
+	var spot AzureSpotConfig
+	if spot.GetEvictionPolicy() != "deallocate" || spot.GetMaxPrice() != -1 {
+		t.Errorf("defaults = %s, %g, want deallocate, -1", spot.GetEvictionPolicy(), spot.GetMaxPrice())
+	}
+	spot = AzureSpotConfig{EvictionPolicy: "delete", MaxPrice: 0.015}
+	if spot.GetEvictionPolicy() != "delete" || spot.GetMaxPrice() != 0.015 {
+		t.Errorf("got %s, %g, want delete, 0.015", spot.GetEvictionPolicy(), spot.GetMaxPrice())
+	}
+}
+
+func TestFindConfigPathSearchesParents(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", filepath.Join(root, "home"))
+	t.Setenv(ConfigEnv, "")
+
+	project := filepath.Join(root, "repo", "services", "api")
+	if err := os.MkdirAll(filepath.Join(project, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	projectConfig := filepath.Join(root, "repo", "services", "morpheus.yaml")
+	if err := os.WriteFile(projectConfig, []byte("machine: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(filepath.Join(project, "src"))
+
+	if got := FindConfigPath(); got != projectConfig {
+		t.Errorf("FindConfigPath() = %q, want %q", got, projectConfig)
+	}
+
+	// A closer project config wins
+	closer := filepath.Join(project, ".morpheus", "config.yaml")
+	os.MkdirAll(filepath.Dir(closer), 0755)
+	if err := os.WriteFile(closer, []byte("machine: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := FindConfigPath(); got != closer {
+		t.Errorf("FindConfigPath() = %q, want %q", got, closer)
+	}
+
+	// MORPHEUS_CONFIG replaces the search, even before the file exists
+	explicit := filepath.Join(root, "explicit.yaml")
+	t.Setenv(ConfigEnv, explicit)
+	if got := FindConfigPath(); got != explicit {
+		t.Errorf("FindConfigPath() with %s = %q, want %q", ConfigEnv, got, explicit)
+	}
+	if paths := ConfigSearchPaths(); len(paths) != 1 || paths[0] != explicit {
+		t.Errorf("ConfigSearchPaths() with %s = %v", ConfigEnv, paths)
+	}
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// Default naming templates, the names morpheus has always used
+const (
+	DefaultServerNameTemplate    = "{{.ForestID}}-node-{{.Index}}"
+	DefaultPrimaryRecordTemplate = "{{.ForestID}}-primary"
+	DefaultFloatingIPTemplate    = "{{.ForestID}}-ip"
+	DefaultForestRecordTemplate  = "{{.ForestID}}"
+	DefaultGuardIDTemplate       = "guard-{{.Timestamp}}"
+)
+
+// NamingConfig holds the Go templates resource names are generated from,
+// for organisations that enforce naming standards. Templates see the
+// fields of NameData plus the lower and upper functions, e.g.
+// "{{.Customer}}-{{.Role}}-{{printf \"%02d\" .Index}}".
+type NamingConfig struct {
+	Server        string `yaml:"server" json:"server,omitempty"`                 // Server (node) names
+	DNSRecord     string `yaml:"dns_record" json:"dns_record,omitempty"`         // A/AAAA record of each node (default: the server name); may have dots, e.g. node{{.Index}}.{{.ForestID}}
+	PrimaryRecord string `yaml:"primary_record" json:"primary_record,omitempty"` // CNAME following a forest's floating IP
+	FloatingIP    string `yaml:"floating_ip" json:"floating_ip,omitempty"`       // A forest's floating IP
+	ForestRecord  string `yaml:"forest_record" json:"forest_record,omitempty"`   // A/AAAA record of a forest's floating IP
+	Guard         string `yaml:"guard" json:"guard,omitempty"`                   // Guard IDs, which prefix all of a guard's resources
+}
+
+// NameData is what naming templates are rendered with
+type NameData struct {
+	ForestID  string
+	Index     int    // 1-based node number
+	Role      string // Node role (default "node")
+	Customer  string // Tenant the forest belongs to, if any
+	Timestamp int64  // Unix time of creation (guards)
+}
+
+// namePattern is what rendered names must look like to be valid server
+// names and DNS labels
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+var nameFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// validName reports whether name is a valid server name and DNS label
+func validName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// validRecordName reports whether name is a valid record name within a
+// zone: one or more DNS labels separated by dots
+func validRecordName(name string) bool {
+	for _, label := range strings.Split(name, ".") {
+		if !namePattern.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
+
+// ServerName returns the name of a node's server
+func (n NamingConfig) ServerName(data NameData) string {
+	return renderName(n.Server, DefaultServerNameTemplate, data, validName)
+}
+
+// DNSRecordName returns the name of a node's A/AAAA records
+func (n NamingConfig) DNSRecordName(data NameData) string {
+	if n.DNSRecord == "" {
+		return n.ServerName(data)
+	}
+	return renderName(n.DNSRecord, DefaultServerNameTemplate, data, validRecordName)
+}
+
+// PrimaryRecordName returns the name of the CNAME following a forest's
+// floating IP
+func (n NamingConfig) PrimaryRecordName(data NameData) string {
+	return renderName(n.PrimaryRecord, DefaultPrimaryRecordTemplate, data, validRecordName)
+}
+
+// FloatingIPName returns the name of a forest's floating IP
+func (n NamingConfig) FloatingIPName(data NameData) string {
+	return renderName(n.FloatingIP, DefaultFloatingIPTemplate, data, validName)
+}
+
+// ForestRecordName returns the name of the A/AAAA record of a forest's
+// floating IP
+func (n NamingConfig) ForestRecordName(data NameData) string {
+	return renderName(n.ForestRecord, DefaultForestRecordTemplate, data, validRecordName)
+}
+
+// GuardID returns the ID of a new guard
+func (n NamingConfig) GuardID(data NameData) string {
+	return renderName(n.Guard, DefaultGuardIDTemplate, data, validName)
+}
+
+// renderName renders a naming template, falling back to the default one if
+// it is unset or does not render a valid name, e.g. for a forest without a
+// customer
+func renderName(text, fallback string, data NameData, valid func(string) bool) string {
+	if data.Role == "" {
+		data.Role = "node"
+	}
+	if text != "" {
+		if name, err := executeName(text, data); err == nil && valid(name) {
+			return name
+		}
+	}
+	name, _ := executeName(fallback, data)
+	return name
+}
+
+func executeName(text string, data NameData) (string, error) {
+	tmpl, err := template.New("name").Funcs(nameFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Validate checks that every template renders valid names that tell nodes
+// (or guards) apart
+func (n NamingConfig) Validate() error {
+	sample := NameData{ForestID: "forest-1700000000", Index: 1, Role: "node", Customer: "acme", Timestamp: 1700000000}
+	nextNode, nextGuard := sample, sample
+	nextNode.Index++
+	nextGuard.Timestamp++
+
+	for _, t := range []struct {
+		key, text string
+		next      *NameData // Data that must render a different name
+		of        string
+		record    bool // A record name, which may have several labels
+	}{
+		{"server", n.Server, &nextNode, "nodes", false},
+		{"dns_record", n.DNSRecord, &nextNode, "nodes", true},
+		{"primary_record", n.PrimaryRecord, nil, "", true},
+		{"floating_ip", n.FloatingIP, nil, "", false},
+		{"forest_record", n.ForestRecord, nil, "", true},
+		{"guard", n.Guard, &nextGuard, "guards", false},
+	} {
+		if t.text == "" {
+			continue
+		}
+		name, err := executeName(t.text, sample)
+		if err != nil {
+			return fmt.Errorf("invalid naming.%s: %w", t.key, err)
+		}
+		if t.record && !validRecordName(name) {
+			return fmt.Errorf("invalid naming.%s: %q is not a valid record name (dot-separated labels of letters, digits and hyphens, at most 63 each)", t.key, name)
+		}
+		if !t.record && !validName(name) {
+			return fmt.Errorf("invalid naming.%s: %q is not a valid name (letters, digits and hyphens, at most 63)", t.key, name)
+		}
+		if t.next != nil {
+			if next, _ := executeName(t.text, *t.next); next == name {
+				return fmt.Errorf("invalid naming.%s: %q does not tell %s apart", t.key, name, t.of)
+			}
+		}
+	}
+	return nil
+}
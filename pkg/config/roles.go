@@ -0,0 +1,50 @@
+package config
+
+import "fmt"
+
+// RoleConfig configures the nodes of one role, e.g. edge, core or storage.
+// A role also selects the nodes' cloud-init template (<role>.yaml in
+// provisioning.cloudinit_dir).
+type RoleConfig struct {
+	Name       string            `yaml:"name"`
+	Count      int               `yaml:"count"`       // Nodes of this role in a planted forest (0: only on request)
+	ServerType string            `yaml:"server_type"` // Server type of the role's nodes (default: the configured one)
+	Labels     map[string]string `yaml:"labels"`      // Extra server labels of the role's nodes
+}
+
+// Role returns the configuration of the named role, or nil if there is none
+func (p ProvisioningConfig) Role(name string) *RoleConfig {
+	for i := range p.Roles {
+		if p.Roles[i].Name == name {
+			return &p.Roles[i]
+		}
+	}
+	return nil
+}
+
+// ValidRoleName reports whether name can be used as a node role: it ends
+// up in server labels, template file names and naming templates
+func ValidRoleName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+func (p ProvisioningConfig) validateRoles() error {
+	seen := make(map[string]bool)
+	for _, role := range p.Roles {
+		switch {
+		case !ValidRoleName(role.Name):
+			return fmt.Errorf("provisioning.roles: invalid role name %q", role.Name)
+		case seen[role.Name]:
+			return fmt.Errorf("provisioning.roles: role %s is listed twice", role.Name)
+		case role.Count < 0:
+			return fmt.Errorf("provisioning.roles.%s: count must not be negative", role.Name)
+		}
+		seen[role.Name] = true
+		for key := range role.Labels {
+			if key == "managed-by" || key == "forest-id" || key == "role" {
+				return fmt.Errorf("provisioning.roles.%s: label %s is set by morpheus", role.Name, key)
+			}
+		}
+	}
+	return nil
+}
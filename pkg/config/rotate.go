@@ -0,0 +1,100 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ReplaceSecret replaces every value in the YAML file at path that equals
+// oldValue with newValue, and returns how many were replaced. Only the
+// matching values change; keys, comments and layout are kept. The file is
+// replaced atomically, so readers see either the old or the new secrets,
+// never a mix.
+func ReplaceSecret(path, oldValue, newValue string) (int, error) {
+	if oldValue == "" {
+		return 0, fmt.Errorf("no current value to replace")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	replaced := replaceScalars(&doc, oldValue, newValue)
+	if replaced == 0 {
+		return 0, nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return 0, fmt.Errorf("failed to marshal %s: %w", path, err)
+	}
+	if err := enc.Close(); err != nil {
+		return 0, fmt.Errorf("failed to marshal %s: %w", path, err)
+	}
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return replaced, nil
+}
+
+// replaceScalars replaces the mapping values and sequence items equal to
+// oldValue below node (never mapping keys)
+func replaceScalars(node *yaml.Node, oldValue, newValue string) int {
+	count := 0
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Value == oldValue {
+			node.Value = newValue
+			node.Style = 0
+			count++
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			count += replaceScalars(node.Content[i], oldValue, newValue)
+		}
+	default:
+		for _, child := range node.Content {
+			count += replaceScalars(child, oldValue, newValue)
+		}
+	}
+	return count
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it over path, keeping the file private since it holds secrets
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
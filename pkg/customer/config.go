@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -123,3 +124,45 @@ func ValidateCustomerConfig(cfg *CustomerConfig) error {
 
 	return nil
 }
+
+// idPattern matches customer IDs: a DNS label, so an ID can name zones,
+// projects and files
+var idPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// ValidateID checks that a new customer ID is a lowercase DNS label
+func ValidateID(id string) error {
+	if !idPattern.MatchString(id) {
+		return fmt.Errorf("invalid customer ID %q: use lowercase letters, digits and hyphens", id)
+	}
+	return nil
+}
+
+// HasVenture reports whether a venture is enabled for the customer
+func (c *Customer) HasVenture(name string) bool {
+	for _, v := range c.Ventures {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AddVenture records a venture as enabled; it returns false if it already was
+func (c *Customer) AddVenture(name string) bool {
+	if c.HasVenture(name) {
+		return false
+	}
+	c.Ventures = append(c.Ventures, name)
+	return true
+}
+
+// RemoveVenture records a venture as disabled; it returns false if it was not enabled
+func (c *Customer) RemoveVenture(name string) bool {
+	for i, v := range c.Ventures {
+		if v == name {
+			c.Ventures = append(c.Ventures[:i], c.Ventures[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -368,3 +368,55 @@ func TestValidateCustomerConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateID(t *testing.T) {
+	for _, id := range []string{"acme", "acme-2", "a"} {
+		if err := ValidateID(id); err != nil {
+			t.Errorf("ValidateID(%q) unexpected error: %v", id, err)
+		}
+	}
+	for _, id := range []string{"", "ACME", "-acme", "acme-", "acme corp", "../acme"} {
+		if err := ValidateID(id); err == nil {
+			t.Errorf("ValidateID(%q) expected error", id)
+		}
+	}
+}
+
+func TestCustomerVentures(t *testing.T) {
+	cust := &Customer{ID: "acme", Ventures: []string{"retail"}}
+
+	if !cust.AddVenture("wholesale") || cust.AddVenture("wholesale") {
+		t.Error("AddVenture() should only add a venture once")
+	}
+	if !cust.HasVenture("wholesale") {
+		t.Error("HasVenture() = false after AddVenture()")
+	}
+	if !cust.RemoveVenture("retail") || cust.RemoveVenture("retail") {
+		t.Error("RemoveVenture() should only remove an enabled venture")
+	}
+	if len(cust.Ventures) != 1 || cust.Ventures[0] != "wholesale" {
+		t.Errorf("Ventures = %v, want [wholesale]", cust.Ventures)
+	}
+}
+
+func TestAddCustomer(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "morpheus", "customers.yaml")
+
+	if err := AddCustomer(configPath, Customer{ID: "acme", Domain: "acme.example.com"}); err != nil {
+		t.Fatalf("AddCustomer() error = %v", err)
+	}
+	if err := AddCustomer(configPath, Customer{ID: "acme", Domain: "other.example.com"}); err == nil {
+		t.Error("AddCustomer() with a taken ID: expected error")
+	}
+	if err := AddCustomer(configPath, Customer{ID: "Bad ID", Domain: "example.com"}); err == nil {
+		t.Error("AddCustomer() with an invalid ID: expected error")
+	}
+
+	cfg, err := LoadCustomerConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cust, err := GetCustomer(cfg, "acme"); err != nil || cust.Domain != "acme.example.com" {
+		t.Errorf("GetCustomer() = %+v, %v; the first customer must be kept", cust, err)
+	}
+}
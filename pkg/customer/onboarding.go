@@ -1,6 +1,7 @@
 package customer
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -83,6 +84,22 @@ func SaveCustomer(configPath string, cust Customer) error {
 	return nil
 }
 
+// AddCustomer adds a new customer to the config file; unlike SaveCustomer
+// it fails if the ID is taken
+func AddCustomer(configPath string, cust Customer) error {
+	if err := ValidateID(cust.ID); err != nil {
+		return err
+	}
+	if cfg, err := LoadCustomerConfig(configPath); err == nil {
+		if _, err := GetCustomer(cfg, cust.ID); err == nil {
+			return fmt.Errorf("customer %q already exists", cust.ID)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return SaveCustomer(configPath, cust)
+}
+
 // DeleteCustomer removes a customer from the config file
 func DeleteCustomer(configPath string, customerID string) error {
 	data, err := os.ReadFile(configPath)
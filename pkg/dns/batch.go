@@ -0,0 +1,294 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RecordSet is the desired state of an RRSet: all records of one name and
+// type. A record set without values is deleted.
+type RecordSet struct {
+	Name   string
+	Type   RecordType
+	TTL    int // 0 keeps the current TTL, or uses the provider default
+	Values []string
+}
+
+// RecordSetChange is a change made (or to be made) to an RRSet
+type RecordSetChange struct {
+	Name   string
+	Type   RecordType
+	Action string     // "create", "update", "ttl" or "delete"
+	Old    *RecordSet // nil for create
+	New    *RecordSet // nil for delete
+}
+
+func (c RecordSetChange) String() string {
+	switch c.Action {
+	case "create":
+		return fmt.Sprintf("+ %s %s %s", c.Name, c.Type, strings.Join(c.New.Values, ", "))
+	case "delete":
+		return fmt.Sprintf("- %s %s %s", c.Name, c.Type, strings.Join(c.Old.Values, ", "))
+	case "ttl":
+		return fmt.Sprintf("~ %s %s TTL %d -> %d", c.Name, c.Type, c.Old.TTL, c.New.TTL)
+	}
+	return fmt.Sprintf("~ %s %s %s -> %s", c.Name, c.Type, strings.Join(c.Old.Values, ", "), strings.Join(c.New.Values, ", "))
+}
+
+// RecordSetError is the error of a change to one RRSet
+type RecordSetError struct {
+	Change RecordSetChange
+	Err    error
+}
+
+func (e *RecordSetError) Error() string {
+	return fmt.Sprintf("failed to %s %s record %s: %s", e.Change.Action, e.Change.Type, e.Change.Name, e.Err)
+}
+
+func (e *RecordSetError) Unwrap() error {
+	return e.Err
+}
+
+// RecordSetErrors returns the RRSet changes that failed in an error of
+// ApplyRecordSets or CreateRecords
+func RecordSetErrors(err error) []*RecordSetError {
+	var errs []*RecordSetError
+	if e, ok := err.(*RecordSetError); ok {
+		return append(errs, e)
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, RecordSetErrors(e)...)
+		}
+	}
+	return errs
+}
+
+// GroupRecordSets groups records into RRSets, in the order they are listed
+func GroupRecordSets(records []*Record) []*RecordSet {
+	index := make(map[string]int)
+	var sets []*RecordSet
+	for _, r := range records {
+		key := r.Name + "/" + string(r.Type)
+		i, ok := index[key]
+		if !ok {
+			i = len(sets)
+			index[key] = i
+			sets = append(sets, &RecordSet{Name: r.Name, Type: r.Type, TTL: r.TTL})
+		}
+		sets[i].Values = append(sets[i].Values, r.Value)
+	}
+	return sets
+}
+
+// DiffRecordSets returns the changes that turn the current records of a
+// zone into the desired record sets. RRSets that are not in desired are
+// left alone; values are compared as DNS compares them, in any order.
+func DiffRecordSets(current []*Record, desired []RecordSet) []RecordSetChange {
+	existing := make(map[string]*RecordSet)
+	for _, set := range GroupRecordSets(current) {
+		existing[set.Name+"/"+string(set.Type)] = set
+	}
+
+	var changes []RecordSetChange
+	for _, want := range desired {
+		have := existing[want.Name+"/"+string(want.Type)]
+		change := RecordSetChange{Name: want.Name, Type: want.Type, Old: have, New: &want}
+		switch {
+		case len(want.Values) == 0 && have == nil:
+			continue
+		case len(want.Values) == 0:
+			change.Action = "delete"
+			change.New = nil
+		case have == nil:
+			change.Action = "create"
+		case !sameRecordValues(want.Type, have.Values, want.Values):
+			change.Action = "update"
+			if want.TTL == 0 {
+				want.TTL = have.TTL
+			}
+		case want.TTL != 0 && want.TTL != have.TTL:
+			change.Action = "ttl"
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// ApplyRecordSet makes an RRSet of a zone match set, in as few API calls
+// as the provider allows. It returns nil if the RRSet already matches.
+func ApplyRecordSet(ctx context.Context, p Provider, domain string, set RecordSet) (*RecordSetChange, error) {
+	changes, err := ApplyRecordSets(ctx, p, domain, []RecordSet{set})
+	if err != nil || len(changes) == 0 {
+		return nil, err
+	}
+	return &changes[0], nil
+}
+
+// ApplyRecordSets makes the RRSets of a zone match the desired record
+// sets. The zone is listed once, and only RRSets that differ are changed,
+// each with the fewest calls the provider supports: TTL changes in place,
+// value changes with RecordSetter, multi-value RRSets with RRSetCreator.
+// It returns the changes that were made; failed ones are in the error.
+func ApplyRecordSets(ctx context.Context, p Provider, domain string, desired []RecordSet) ([]RecordSetChange, error) {
+	current, err := p.ListRecords(ctx, domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list records of %s: %w", domain, err)
+	}
+
+	applied, errs := applyRecordSetChanges(ctx, p, domain, DiffRecordSets(current, desired))
+	return applied, errors.Join(errs...)
+}
+
+// CreateRecords creates records, adding them to the RRSets of their name
+// and type. Records are grouped by zone and RRSet, each zone is listed
+// once, and records that already exist are not created again.
+func CreateRecords(ctx context.Context, p Provider, reqs []CreateRecordRequest) ([]RecordSetChange, error) {
+	var domains []string
+	byDomain := make(map[string][]CreateRecordRequest)
+	for _, req := range reqs {
+		if _, ok := byDomain[req.Domain]; !ok {
+			domains = append(domains, req.Domain)
+		}
+		byDomain[req.Domain] = append(byDomain[req.Domain], req)
+	}
+
+	var applied []RecordSetChange
+	var errs []error
+	for _, domain := range domains {
+		current, err := p.ListRecords(ctx, domain)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list records of %s: %w", domain, err))
+			continue
+		}
+
+		// Start each RRSet from its current values and add the new ones
+		existing := make(map[string]*RecordSet)
+		for _, set := range GroupRecordSets(current) {
+			existing[set.Name+"/"+string(set.Type)] = set
+		}
+		index := make(map[string]int)
+		var desired []RecordSet
+		for _, req := range byDomain[domain] {
+			key := req.Name + "/" + string(req.Type)
+			i, ok := index[key]
+			if !ok {
+				i = len(desired)
+				index[key] = i
+				set := RecordSet{Name: req.Name, Type: req.Type, TTL: req.TTL}
+				if have := existing[key]; have != nil {
+					set.Values = append(set.Values, have.Values...)
+				}
+				desired = append(desired, set)
+			}
+			if !containsRecordValue(req.Type, desired[i].Values, req.Value) {
+				desired[i].Values = append(desired[i].Values, req.Value)
+			}
+			if req.TTL != 0 {
+				desired[i].TTL = req.TTL
+			}
+		}
+
+		changes, changeErrs := applyRecordSetChanges(ctx, p, domain, DiffRecordSets(current, desired))
+		applied = append(applied, changes...)
+		errs = append(errs, changeErrs...)
+	}
+	return applied, errors.Join(errs...)
+}
+
+// applyRecordSetChanges makes changes to a zone, returning those that
+// were made and the errors of those that failed
+func applyRecordSetChanges(ctx context.Context, p Provider, domain string, changes []RecordSetChange) ([]RecordSetChange, []error) {
+	var applied []RecordSetChange
+	var errs []error
+	for _, c := range changes {
+		if err := applyRecordSetChange(ctx, p, domain, c); err != nil {
+			errs = append(errs, &RecordSetError{Change: c, Err: err})
+			continue
+		}
+		applied = append(applied, c)
+	}
+	return applied, errs
+}
+
+// applyRecordSetChange makes one change with the fewest calls the
+// provider supports
+func applyRecordSetChange(ctx context.Context, p Provider, domain string, c RecordSetChange) error {
+	name, recordType := c.Name, string(c.Type)
+	switch c.Action {
+	case "delete":
+		return p.DeleteRecord(ctx, domain, name, recordType)
+	case "ttl":
+		if changer, ok := p.(TTLChanger); ok {
+			return changer.ChangeTTL(ctx, domain, name, recordType, c.New.TTL)
+		}
+	case "update":
+		if setter, ok := p.(RecordSetter); ok && c.New.TTL == c.Old.TTL {
+			return setter.SetRecords(ctx, domain, name, recordType, c.New.Values)
+		}
+	}
+
+	if c.Old != nil {
+		if err := p.DeleteRecord(ctx, domain, name, recordType); err != nil {
+			return err
+		}
+	}
+	if creator, ok := p.(RRSetCreator); ok && len(c.New.Values) > 1 {
+		records := make([]map[string]interface{}, len(c.New.Values))
+		for i, v := range c.New.Values {
+			records[i] = map[string]interface{}{"value": v}
+		}
+		return creator.CreateRRSet(ctx, domain, name, recordType, c.New.TTL, records)
+	}
+	for _, v := range c.New.Values {
+		if _, err := p.CreateRecord(ctx, CreateRecordRequest{
+			Domain: domain,
+			Name:   name,
+			Type:   c.Type,
+			Value:  v,
+			TTL:    c.New.TTL,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameRecordValues reports whether two RRSets have the same values, in
+// any order and however they are written
+func sameRecordValues(recordType RecordType, a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	normalize := func(values []string) []string {
+		out := make([]string, len(values))
+		for i, v := range values {
+			out[i] = normalizeValue(recordType, v)
+		}
+		sort.Strings(out)
+		return out
+	}
+	na, nb := normalize(a), normalize(b)
+	for i := range na {
+		if na[i] != nb[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// containsRecordValue reports whether values has value, however it is
+// written
+func containsRecordValue(recordType RecordType, values []string, value string) bool {
+	for _, v := range values {
+		if normalizeValue(recordType, v) == normalizeValue(recordType, value) {
+			return true
+		}
+	}
+	return false
+}
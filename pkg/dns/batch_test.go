@@ -0,0 +1,250 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// batchDNS is an in-memory Provider with RRSet support that counts calls
+type batchDNS struct {
+	rrsets map[string]*RecordSet
+	calls  []string
+	fail   string // "name/type" whose changes fail
+}
+
+func newBatchDNS(records ...*Record) *batchDNS {
+	f := &batchDNS{rrsets: make(map[string]*RecordSet)}
+	for _, set := range GroupRecordSets(records) {
+		f.rrsets[set.Name+"/"+string(set.Type)] = set
+	}
+	return f
+}
+
+func (f *batchDNS) call(op, name, recordType string) error {
+	f.calls = append(f.calls, op+" "+name+"/"+recordType)
+	if f.fail == name+"/"+recordType {
+		return fmt.Errorf("api error")
+	}
+	return nil
+}
+
+func (f *batchDNS) CreateRecord(ctx context.Context, req CreateRecordRequest) (*Record, error) {
+	if err := f.call("create", req.Name, string(req.Type)); err != nil {
+		return nil, err
+	}
+	key := req.Name + "/" + string(req.Type)
+	if _, exists := f.rrsets[key]; exists {
+		return nil, fmt.Errorf("rrset already exists: %s", key)
+	}
+	f.rrsets[key] = &RecordSet{Name: req.Name, Type: req.Type, TTL: req.TTL, Values: []string{req.Value}}
+	return &Record{Domain: req.Domain, Name: req.Name, Type: req.Type, Value: req.Value, TTL: req.TTL}, nil
+}
+
+func (f *batchDNS) UpdateRecord(ctx context.Context, req CreateRecordRequest) (*Record, error) {
+	return nil, fmt.Errorf("not used")
+}
+
+func (f *batchDNS) UpsertRecord(ctx context.Context, req CreateRecordRequest) (*Record, error) {
+	return nil, fmt.Errorf("not used")
+}
+
+func (f *batchDNS) CreateRRSet(ctx context.Context, domain, name, recordType string, ttl int, records []map[string]interface{}) error {
+	if err := f.call("create-rrset", name, recordType); err != nil {
+		return err
+	}
+	set := &RecordSet{Name: name, Type: RecordType(recordType), TTL: ttl}
+	for _, r := range records {
+		set.Values = append(set.Values, r["value"].(string))
+	}
+	f.rrsets[name+"/"+recordType] = set
+	return nil
+}
+
+func (f *batchDNS) SetRecords(ctx context.Context, domain, name, recordType string, values []string) error {
+	if err := f.call("set", name, recordType); err != nil {
+		return err
+	}
+	f.rrsets[name+"/"+recordType].Values = values
+	return nil
+}
+
+func (f *batchDNS) ChangeTTL(ctx context.Context, domain, name, recordType string, ttl int) error {
+	if err := f.call("ttl", name, recordType); err != nil {
+		return err
+	}
+	f.rrsets[name+"/"+recordType].TTL = ttl
+	return nil
+}
+
+func (f *batchDNS) DeleteRecord(ctx context.Context, domain, name, recordType string) error {
+	if err := f.call("delete", name, recordType); err != nil {
+		return err
+	}
+	delete(f.rrsets, name+"/"+recordType)
+	return nil
+}
+
+func (f *batchDNS) ListRecords(ctx context.Context, domain string) ([]*Record, error) {
+	f.calls = append(f.calls, "list")
+	var keys []string
+	for key := range f.rrsets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var records []*Record
+	for _, key := range keys {
+		set := f.rrsets[key]
+		for _, v := range set.Values {
+			records = append(records, &Record{Domain: domain, Name: set.Name, Type: set.Type, Value: v, TTL: set.TTL})
+		}
+	}
+	return records, nil
+}
+
+func (f *batchDNS) GetRecord(ctx context.Context, domain, name, recordType string) (*Record, error) {
+	return nil, nil
+}
+
+func (f *batchDNS) CreateZone(ctx context.Context, req CreateZoneRequest) (*Zone, error) {
+	return &Zone{Name: req.Name}, nil
+}
+
+func (f *batchDNS) DeleteZone(ctx context.Context, zoneName string) error { return nil }
+
+func (f *batchDNS) GetZone(ctx context.Context, zoneName string) (*Zone, error) {
+	return &Zone{Name: zoneName}, nil
+}
+
+func (f *batchDNS) ListZones(ctx context.Context) ([]*Zone, error) { return nil, nil }
+
+func TestDiffRecordSets(t *testing.T) {
+	current := []*Record{
+		{Name: "www", Type: RecordTypeA, Value: "192.0.2.1", TTL: 300},
+		{Name: "@", Type: "MX", Value: "20 mx2.example.com.", TTL: 3600},
+		{Name: "@", Type: "MX", Value: "10 mx1.example.com.", TTL: 3600},
+		{Name: "@", Type: RecordTypeTXT, Value: `"v=spf1 -all"`, TTL: 3600},
+		{Name: "old", Type: RecordTypeAAAA, Value: "2001:db8::1", TTL: 300},
+		{Name: "slow", Type: RecordTypeA, Value: "192.0.2.9", TTL: 3600},
+	}
+	desired := []RecordSet{
+		{Name: "www", Type: RecordTypeA, Values: []string{"192.0.2.2"}},
+		{Name: "@", Type: "MX", TTL: 3600, Values: []string{"10 mx1.example.com.", "20 MX2.example.com"}},
+		{Name: "@", Type: RecordTypeTXT, Values: []string{"v=spf1 -all"}},
+		{Name: "old", Type: RecordTypeAAAA},
+		{Name: "gone", Type: RecordTypeAAAA},
+		{Name: "slow", Type: RecordTypeA, TTL: 60, Values: []string{"192.0.2.9"}},
+		{Name: "new", Type: RecordTypeA, TTL: 60, Values: []string{"192.0.2.3"}},
+	}
+
+	var got []string
+	for _, c := range DiffRecordSets(current, desired) {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"~ www A 192.0.2.1 -> 192.0.2.2",
+		"- old AAAA 2001:db8::1",
+		"~ slow A TTL 3600 -> 60",
+		"+ new A 192.0.2.3",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("DiffRecordSets() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestApplyRecordSets(t *testing.T) {
+	ctx := context.Background()
+	f := newBatchDNS(
+		&Record{Name: "www", Type: RecordTypeA, Value: "192.0.2.1", TTL: 300},
+		&Record{Name: "slow", Type: RecordTypeA, Value: "192.0.2.9", TTL: 3600},
+		&Record{Name: "old", Type: RecordTypeA, Value: "192.0.2.8", TTL: 300},
+		&Record{Name: "same", Type: RecordTypeA, Value: "192.0.2.7", TTL: 300},
+	)
+
+	changes, err := ApplyRecordSets(ctx, f, "example.com", []RecordSet{
+		{Name: "www", Type: RecordTypeA, Values: []string{"192.0.2.2"}},
+		{Name: "slow", Type: RecordTypeA, TTL: 60, Values: []string{"192.0.2.9"}},
+		{Name: "old", Type: RecordTypeA},
+		{Name: "same", Type: RecordTypeA, TTL: 300, Values: []string{"192.0.2.7"}},
+		{Name: "@", Type: "MX", TTL: 3600, Values: []string{"10 mx1.example.com.", "20 mx2.example.com."}},
+		{Name: "api", Type: RecordTypeA, Values: []string{"192.0.2.3"}},
+	})
+	if err != nil {
+		t.Fatalf("ApplyRecordSets() error = %v", err)
+	}
+	if len(changes) != 5 {
+		t.Errorf("ApplyRecordSets() made %d changes, want 5: %v", len(changes), changes)
+	}
+
+	// One list, then one call per changed RRSet
+	want := "list, set www/A, ttl slow/A, delete old/A, create-rrset @/MX, create api/A"
+	if got := strings.Join(f.calls, ", "); got != want {
+		t.Errorf("calls = %s, want %s", got, want)
+	}
+	if www := f.rrsets["www/A"]; www.TTL != 300 || www.Values[0] != "192.0.2.2" {
+		t.Errorf("www = %+v", www)
+	}
+
+	// Applying again changes nothing
+	f.calls = nil
+	change, err := ApplyRecordSet(ctx, f, "example.com", RecordSet{Name: "www", Type: RecordTypeA, Values: []string{"192.0.2.2"}})
+	if change != nil || err != nil || len(f.calls) != 1 {
+		t.Errorf("ApplyRecordSet() = %v, %v with calls %v, want no change", change, err, f.calls)
+	}
+}
+
+func TestApplyRecordSetsErrors(t *testing.T) {
+	f := newBatchDNS()
+	f.fail = "bad/A"
+
+	changes, err := ApplyRecordSets(context.Background(), f, "example.com", []RecordSet{
+		{Name: "bad", Type: RecordTypeA, Values: []string{"192.0.2.1"}},
+		{Name: "good", Type: RecordTypeA, Values: []string{"192.0.2.2"}},
+	})
+	if len(changes) != 1 || changes[0].Name != "good" {
+		t.Errorf("ApplyRecordSets() changes = %v, want only good", changes)
+	}
+	failed := RecordSetErrors(err)
+	if len(failed) != 1 || failed[0].Change.Name != "bad" || failed[0].Change.Action != "create" {
+		t.Fatalf("RecordSetErrors() = %v", failed)
+	}
+	if !strings.Contains(err.Error(), "failed to create A record bad: api error") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestCreateRecords(t *testing.T) {
+	ctx := context.Background()
+	f := newBatchDNS(
+		&Record{Name: "@", Type: RecordTypeTXT, Value: `"google-site-verification=abc"`, TTL: 3600},
+		&Record{Name: "_dmarc", Type: RecordTypeTXT, Value: `"v=DMARC1; p=none"`, TTL: 3600},
+	)
+
+	changes, err := CreateRecords(ctx, f, []CreateRecordRequest{
+		{Domain: "example.com", Name: "@", Type: RecordTypeTXT, Value: `"v=spf1 -all"`, TTL: 3600},
+		{Domain: "example.com", Name: "_dmarc", Type: RecordTypeTXT, Value: `"v=DMARC1; p=none"`, TTL: 3600},
+		{Domain: "example.com", Name: "node1", Type: RecordTypeA, Value: "192.0.2.1"},
+		{Domain: "example.com", Name: "node1", Type: RecordTypeA, Value: "192.0.2.2"},
+	})
+	if err != nil {
+		t.Fatalf("CreateRecords() error = %v", err)
+	}
+	if len(changes) != 2 {
+		t.Errorf("CreateRecords() made %d changes, want 2: %v", len(changes), changes)
+	}
+
+	// The SPF record is added to the verification record; the existing
+	// DMARC record is not created again
+	want := "list, set @/TXT, create-rrset node1/A"
+	if got := strings.Join(f.calls, ", "); got != want {
+		t.Errorf("calls = %s, want %s", got, want)
+	}
+	if txt := f.rrsets["@/TXT"]; len(txt.Values) != 2 {
+		t.Errorf("@ TXT = %+v, want both values", txt)
+	}
+	if a := f.rrsets["node1/A"]; len(a.Values) != 2 {
+		t.Errorf("node1 A = %+v, want both values", a)
+	}
+}
@@ -0,0 +1,226 @@
+package dns
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxBIMILogoSize is the largest logo mailbox providers accept: 32 KB
+const maxBIMILogoSize = 32 * 1024
+
+// maxVMCSize bounds the certificate chain download
+const maxVMCSize = 64 * 1024
+
+// oidBIMI is the extended key usage of Verified Mark Certificates
+var oidBIMI = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 31}
+
+// BIMIRecord returns the value of the TXT record at <selector>._bimi.<domain>.
+// vmcURL is optional; without it most mailbox providers do not show the logo.
+func BIMIRecord(logoURL, vmcURL string) string {
+	return fmt.Sprintf("v=BIMI1; l=%s; a=%s", logoURL, vmcURL)
+}
+
+// CheckDMARCEnforcement returns an error unless the domain's DMARC policy is
+// at enforcement (quarantine or reject, for all mail and subdomains), which
+// mailbox providers require before they show a BIMI logo
+func CheckDMARCEnforcement(ctx context.Context, domain, resolver string) error {
+	return dmarcEnforced(ctx, newResolver(resolver), domain)
+}
+
+func dmarcEnforced(ctx context.Context, r emailResolver, domain string) error {
+	records, err := txtWithPrefix(ctx, r, "_dmarc."+domain, "v=DMARC1")
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to look up DMARC record: %w", err)
+	case len(records) == 0:
+		return fmt.Errorf("no DMARC record at _dmarc.%s", domain)
+	case len(records) > 1:
+		return fmt.Errorf("%d DMARC records at _dmarc.%s", len(records), domain)
+	}
+
+	tags := parseTags(records[0])
+	if p := strings.ToLower(tags["p"]); p != "quarantine" && p != "reject" {
+		return fmt.Errorf("DMARC policy is p=%s; BIMI needs p=quarantine or p=reject", tags["p"])
+	}
+	if pct, ok := tags["pct"]; ok && pct != "100" {
+		return fmt.Errorf("DMARC policy applies to %s%% of mail; BIMI needs pct=100", pct)
+	}
+	if strings.EqualFold(tags["sp"], "none") {
+		return fmt.Errorf("DMARC subdomain policy is sp=none; BIMI needs enforcement for subdomains too")
+	}
+	return nil
+}
+
+// ValidateBIMIURL checks that a logo or certificate location is an HTTPS URL
+// with the given file extension
+func ValidateBIMIURL(location, ext string) error {
+	u, err := url.Parse(location)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid URL: %s", location)
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("%s must be served over HTTPS", location)
+	}
+	if !strings.HasSuffix(strings.ToLower(u.Path), ext) {
+		return fmt.Errorf("%s must point to a %s file", location, ext)
+	}
+	return nil
+}
+
+// ValidateBIMILogo checks that a logo is an SVG Tiny Portable/Secure image
+// as BIMI requires: an svg root with baseProfile="tiny-ps" and version 1.2,
+// a title, no scripts, no raster images or external references, and at
+// most 32 KB
+func ValidateBIMILogo(data []byte) error {
+	if len(data) > maxBIMILogoSize {
+		return fmt.Errorf("logo is %d bytes; the limit is %d", len(data), maxBIMILogoSize)
+	}
+
+	dec := xml.NewDecoder(strings.NewReader(string(data)))
+	root := true
+	title := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("logo is not valid XML: %w", err)
+		}
+		el, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if root {
+			if el.Name.Local != "svg" {
+				return fmt.Errorf("logo root element is <%s>, not <svg>", el.Name.Local)
+			}
+			if attr(el, "baseProfile") != "tiny-ps" {
+				return errors.New(`logo must be SVG Tiny PS (baseProfile="tiny-ps")`)
+			}
+			if attr(el, "version") != "1.2" {
+				return errors.New(`logo must be SVG version="1.2"`)
+			}
+			if attr(el, "x") != "" || attr(el, "y") != "" {
+				return errors.New("logo root must not have x or y attributes")
+			}
+			root = false
+			continue
+		}
+		switch el.Name.Local {
+		case "title":
+			title = true
+		case "script", "image", "foreignObject":
+			return fmt.Errorf("logo must not contain <%s> elements", el.Name.Local)
+		}
+		for _, a := range el.Attr {
+			if a.Name.Local == "href" && !strings.HasPrefix(a.Value, "#") {
+				return fmt.Errorf("logo must not reference external resources (%s)", a.Value)
+			}
+			if strings.HasPrefix(strings.ToLower(a.Name.Local), "on") {
+				return fmt.Errorf("logo must not have event handlers (%s)", a.Name.Local)
+			}
+		}
+	}
+	if root {
+		return errors.New("logo has no <svg> element")
+	}
+	if !title {
+		return errors.New("logo must have a <title>")
+	}
+	return nil
+}
+
+func attr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// ValidateVMC checks a Verified Mark Certificate chain in PEM form: the
+// first certificate must be valid at now, be issued for the domain and
+// carry the BIMI extended key usage
+func ValidateVMC(data []byte, domain string, now time.Time) error {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return errors.New("certificate is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("invalid certificate: %w", err)
+	}
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return fmt.Errorf("certificate is valid from %s to %s", cert.NotBefore.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02"))
+	}
+	if err := cert.VerifyHostname(domain); err != nil {
+		return fmt.Errorf("certificate is not issued for %s", domain)
+	}
+	for _, oid := range cert.UnknownExtKeyUsage {
+		if oid.Equal(oidBIMI) {
+			return nil
+		}
+	}
+	return errors.New("certificate is not a Verified Mark Certificate (no BIMI key usage)")
+}
+
+// FetchBIMIAsset downloads a logo or certificate, up to limit bytes
+func FetchBIMIAsset(ctx context.Context, location string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", location, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", location, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", location, err)
+	}
+	return data, nil
+}
+
+// ValidateBIMI fetches and checks the logo and, if given, the certificate
+// of a BIMI record for domain
+func ValidateBIMI(ctx context.Context, domain, logoURL, vmcURL string) error {
+	if err := ValidateBIMIURL(logoURL, ".svg"); err != nil {
+		return err
+	}
+	logo, err := FetchBIMIAsset(ctx, logoURL, maxBIMILogoSize)
+	if err != nil {
+		return err
+	}
+	if err := ValidateBIMILogo(logo); err != nil {
+		return err
+	}
+
+	if vmcURL == "" {
+		return nil
+	}
+	if err := ValidateBIMIURL(vmcURL, ".pem"); err != nil {
+		return err
+	}
+	vmc, err := FetchBIMIAsset(ctx, vmcURL, maxVMCSize)
+	if err != nil {
+		return err
+	}
+	return ValidateVMC(vmc, domain, time.Now())
+}
@@ -0,0 +1,118 @@
+package dns
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDMARCEnforced(t *testing.T) {
+	tests := []struct {
+		record  string
+		wantErr bool
+	}{
+		{"v=DMARC1; p=reject; rua=mailto:d@example.com", false},
+		{"v=DMARC1; p=quarantine; pct=100", false},
+		{"v=DMARC1; p=none", true},
+		{"v=DMARC1; p=quarantine; pct=50", true},
+		{"v=DMARC1; p=reject; sp=none", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		r := &fakeEmailResolver{txt: map[string][]string{}}
+		if tt.record != "" {
+			r.txt["_dmarc.example.com"] = []string{tt.record}
+		}
+		err := dmarcEnforced(context.Background(), r, "example.com")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("dmarcEnforced(%q) error = %v, wantErr %v", tt.record, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateBIMIURL(t *testing.T) {
+	if err := ValidateBIMIURL("https://example.com/logo.svg", ".svg"); err != nil {
+		t.Errorf("ValidateBIMIURL() error = %v", err)
+	}
+	for _, u := range []string{"http://example.com/logo.svg", "https://example.com/logo.png", "logo.svg"} {
+		if err := ValidateBIMIURL(u, ".svg"); err == nil {
+			t.Errorf("ValidateBIMIURL(%q): expected error", u)
+		}
+	}
+}
+
+func TestValidateBIMILogo(t *testing.T) {
+	logo := func(root, body string) []byte {
+		return []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" ` + root + `>` + body + `</svg>`)
+	}
+	valid := `version="1.2" baseProfile="tiny-ps" viewBox="0 0 100 100"`
+	if err := ValidateBIMILogo(logo(valid, `<title>Example</title><circle cx="50" cy="50" r="40"/>`)); err != nil {
+		t.Errorf("ValidateBIMILogo() error = %v", err)
+	}
+
+	invalid := map[string][]byte{
+		"no tiny-ps":    logo(`version="1.2"`, `<title>Example</title>`),
+		"no title":      logo(valid, `<circle r="40"/>`),
+		"script":        logo(valid, `<title>Example</title><script>alert(1)</script>`),
+		"raster":        logo(valid, `<title>Example</title><image href="logo.png"/>`),
+		"external href": logo(valid, `<title>Example</title><use href="https://example.com/a.svg#x"/>`),
+		"root x":        logo(valid+` x="0"`, `<title>Example</title>`),
+		"not svg":       []byte(`<html><title>Example</title></html>`),
+		"too large":     logo(valid, `<title>Example</title><desc>`+strings.Repeat("x", maxBIMILogoSize)+`</desc>`),
+	}
+	for name, data := range invalid {
+		if err := ValidateBIMILogo(data); err == nil {
+			t.Errorf("ValidateBIMILogo(%s): expected error", name)
+		}
+	}
+}
+
+func vmcPEM(t *testing.T, domain string, eku []asn1.ObjectIdentifier, notAfter time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:       big.NewInt(1),
+		Subject:            pkix.Name{CommonName: domain},
+		DNSNames:           []string{domain},
+		NotBefore:          notAfter.AddDate(-1, 0, 0),
+		NotAfter:           notAfter,
+		UnknownExtKeyUsage: eku,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestValidateVMC(t *testing.T) {
+	now := time.Now()
+	bimi := []asn1.ObjectIdentifier{oidBIMI}
+
+	if err := ValidateVMC(vmcPEM(t, "example.com", bimi, now.AddDate(0, 6, 0)), "example.com", now); err != nil {
+		t.Errorf("ValidateVMC() error = %v", err)
+	}
+	invalid := map[string][]byte{
+		"expired":      vmcPEM(t, "example.com", bimi, now.AddDate(0, -1, 0)),
+		"other domain": vmcPEM(t, "example.org", bimi, now.AddDate(0, 6, 0)),
+		"no BIMI EKU":  vmcPEM(t, "example.com", nil, now.AddDate(0, 6, 0)),
+		"not PEM":      []byte("not a certificate"),
+	}
+	for name, data := range invalid {
+		if err := ValidateVMC(data, "example.com", now); err == nil {
+			t.Errorf("ValidateVMC(%s): expected error", name)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package dns
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RecordTypeCAA is a Certification Authority Authorization record (RFC 8659)
+const RecordTypeCAA RecordType = "CAA"
+
+// CAA flags and property tags
+const (
+	CAAFlagCritical = 128
+
+	CAATagIssue     = "issue"
+	CAATagIssueWild = "issuewild"
+	CAATagIODEF     = "iodef"
+)
+
+// CAA is the value of a CAA record: flags, a property tag and its value
+type CAA struct {
+	Flags uint8
+	Tag   string
+	Value string
+}
+
+var caaTagPattern = regexp.MustCompile(`^[a-zA-Z0-9]{1,15}$`)
+
+// ParseCAA parses a CAA value as written in zone files, e.g.
+// `0 issue "letsencrypt.org"`. The flags may be left out (0), and the
+// value need not be quoted. Values in the generic form of RFC 3597
+// (`\# 22 00 05 ...`), as some resolvers return them, are decoded too.
+func ParseCAA(s string) (*CAA, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, `\#`) {
+		return parseGenericCAA(s)
+	}
+
+	fields := strings.SplitN(s, " ", 2)
+	c := &CAA{}
+	if flags, err := strconv.ParseUint(fields[0], 10, 8); err == nil {
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid CAA record %q: no tag", s)
+		}
+		c.Flags = uint8(flags)
+		s = strings.TrimSpace(fields[1])
+	}
+
+	tag, value, _ := strings.Cut(s, " ")
+	c.Tag = strings.ToLower(tag)
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CAA value %s: %w", value, err)
+		}
+		value = unquoted
+	}
+	c.Value = value
+	return c, c.Validate()
+}
+
+// parseGenericCAA decodes a CAA record in RFC 3597 form: \# length hex
+func parseGenericCAA(s string) (*CAA, error) {
+	fields := strings.Fields(strings.TrimPrefix(s, `\#`))
+	if len(fields) < 1 {
+		return nil, fmt.Errorf("invalid CAA record %q", s)
+	}
+	data, err := hex.DecodeString(strings.Join(fields[1:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CAA record %q: %w", s, err)
+	}
+	if n, err := strconv.Atoi(fields[0]); err != nil || n != len(data) || len(data) < 2 || len(data) < 2+int(data[1]) {
+		return nil, fmt.Errorf("invalid CAA record %q", s)
+	}
+	c := &CAA{
+		Flags: data[0],
+		Tag:   strings.ToLower(string(data[2 : 2+data[1]])),
+		Value: string(data[2+data[1]:]),
+	}
+	return c, c.Validate()
+}
+
+// Validate checks the tag, and the value of the tags CAs act on
+func (c *CAA) Validate() error {
+	if !caaTagPattern.MatchString(c.Tag) {
+		return fmt.Errorf("invalid CAA tag %q (1-15 letters and digits)", c.Tag)
+	}
+	switch c.Tag {
+	case CAATagIssue, CAATagIssueWild:
+		// An issuer domain, parameters, or both; ";" alone forbids issuance
+		issuer, _, _ := strings.Cut(c.Value, ";")
+		if issuer = strings.TrimSpace(issuer); issuer != "" && !validHostname(issuer) {
+			return fmt.Errorf("invalid CAA %s value %q: not a domain name", c.Tag, c.Value)
+		}
+	case CAATagIODEF:
+		u, err := url.Parse(c.Value)
+		if err != nil || (u.Scheme != "mailto" && u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid CAA iodef value %q: must be a mailto:, http: or https: URL", c.Value)
+		}
+	}
+	return nil
+}
+
+// String returns the record value in zone file form, e.g.
+// `0 issue "letsencrypt.org"`
+func (c CAA) String() string {
+	return fmt.Sprintf("%d %s %s", c.Flags, c.Tag, strconv.Quote(c.Value))
+}
+
+// Issuer returns the issuer domain of an issue or issuewild value, or ""
+// if the record forbids issuance
+func (c CAA) Issuer() string {
+	issuer, _, _ := strings.Cut(c.Value, ";")
+	return strings.ToLower(strings.TrimSpace(issuer))
+}
+
+// CAAPermits reports whether a CA may issue a certificate for a name,
+// given the CAA records that apply to it (RFC 8659 section 4): with no
+// records any CA may; for wildcard names issuewild records take precedence
+// over issue records. It returns an error if a critical flag is set on a
+// tag the check does not understand, as a CA would then refuse to issue.
+func CAAPermits(records []CAA, issuer string, wildcard bool) (bool, error) {
+	issuer = strings.ToLower(strings.TrimSuffix(issuer, "."))
+	var issue, issueWild []CAA
+	for _, r := range records {
+		switch r.Tag {
+		case CAATagIssue:
+			issue = append(issue, r)
+		case CAATagIssueWild:
+			issueWild = append(issueWild, r)
+		case CAATagIODEF:
+		default:
+			if r.Flags&CAAFlagCritical != 0 {
+				return false, fmt.Errorf("unknown critical CAA tag %q", r.Tag)
+			}
+		}
+	}
+	relevant := issue
+	if wildcard && len(issueWild) > 0 {
+		relevant = issueWild
+	}
+	if len(relevant) == 0 {
+		// No issue restrictions (e.g. only iodef records)
+		return true, nil
+	}
+	for _, r := range relevant {
+		if r.Issuer() == issuer {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CAALookup returns the CAA records at exactly one name
+type CAALookup func(ctx context.Context, name string) ([]CAA, error)
+
+// FindCAA returns the CAA record set that applies to a name: the one at
+// the name itself or, if it has none, at the closest parent that has one
+// (RFC 8659 section 3). It also returns the name the records were found
+// at; both are empty if no name up to the top-level domain has any.
+// A leading "*." of a wildcard name is ignored.
+func FindCAA(ctx context.Context, name string, lookup CAALookup) ([]CAA, string, error) {
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "*."), ".")
+	for name != "" && strings.Contains(name, ".") {
+		records, err := lookup(ctx, name)
+		if err != nil {
+			return nil, "", fmt.Errorf("CAA lookup of %s failed: %w", name, err)
+		}
+		if len(records) > 0 {
+			return records, name, nil
+		}
+		_, name, _ = strings.Cut(name, ".")
+	}
+	return nil, "", nil
+}
+
+// LookupCAA returns the CAA records at a name using DNS-over-HTTPS, as the
+// standard library resolver does not support CAA queries
+func LookupCAA(ctx context.Context, name string) ([]CAA, error) {
+	providers := []string{
+		"https://dns.google/resolve?type=CAA&name=",
+		"https://cloudflare-dns.com/dns-query?type=CAA&name=",
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	var lastErr error
+	for _, provider := range providers {
+		req, err := http.NewRequestWithContext(ctx, "GET", provider+url.QueryEscape(name), nil)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		req.Header.Set("Accept", "application/dns-json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		var dohResp dohResponse
+		err = json.NewDecoder(resp.Body).Decode(&dohResp)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			lastErr = fmt.Errorf("DoH provider returned status %d", resp.StatusCode)
+			continue
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		// NXDOMAIN (3) just means there are no records
+		if dohResp.Status != 0 && dohResp.Status != 3 {
+			lastErr = fmt.Errorf("DoH response status: %d", dohResp.Status)
+			continue
+		}
+
+		var records []CAA
+		for _, answer := range dohResp.Answer {
+			if answer.Type != 257 { // CAA; CNAMEs are followed by the resolver
+				continue
+			}
+			c, err := ParseCAA(answer.Data)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, *c)
+		}
+		return records, nil
+	}
+	return nil, fmt.Errorf("all DoH providers failed: %w", lastErr)
+}
+
+// IsWildcard reports whether a record name is a wildcard, e.g. "*.app"
+func IsWildcard(name string) bool {
+	return name == "*" || strings.HasPrefix(name, "*.")
+}
+
+// ValidateRecordName checks a record name or FQDN: labels of letters,
+// digits, hyphens and underscores (as in _dmarc), and "*" only as the
+// leftmost label of a wildcard name
+func ValidateRecordName(name string) error {
+	name = strings.TrimSuffix(name, ".")
+	if name == "@" {
+		return nil
+	}
+	for i, label := range strings.Split(name, ".") {
+		switch {
+		case label == "*" && i == 0:
+		case strings.Contains(label, "*"):
+			return fmt.Errorf("invalid name %q: * is only allowed as the leftmost label, e.g. *.app.example.com", name)
+		case !recordLabelPattern.MatchString(label):
+			return fmt.Errorf("invalid name %q: label %q must be 1-63 letters, digits, hyphens or underscores", name, label)
+		}
+	}
+	return nil
+}
+
+var recordLabelPattern = regexp.MustCompile(`^[a-zA-Z0-9_]([a-zA-Z0-9_-]{0,61}[a-zA-Z0-9_])?$`)
+
+// validHostname reports whether s is a domain name
+func validHostname(s string) bool {
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if !recordLabelPattern.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package dns
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseCAA(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{`0 issue "letsencrypt.org"`, `0 issue "letsencrypt.org"`, false},
+		{`issue letsencrypt.org`, `0 issue "letsencrypt.org"`, false},
+		{`128 ISSUEWILD ";"`, `128 issuewild ";"`, false},
+		{`0 issue "sectigo.com; validationmethods=dns-01"`, `0 issue "sectigo.com; validationmethods=dns-01"`, false},
+		{`0 iodef "mailto:security@example.com"`, `0 iodef "mailto:security@example.com"`, false},
+		{`\# 22 00 05 69 73 73 75 65 6c 65 74 73 65 6e 63 72 79 70 74 2e 6f 72 67`, `0 issue "letsencrypt.org"`, false},
+		{`0 iodef "ftp://example.com"`, "", true},
+		{`0 issue "not a domain"`, "", true},
+		{`0 is-sue "letsencrypt.org"`, "", true},
+		{`0`, "", true},
+	}
+	for _, tt := range tests {
+		c, err := ParseCAA(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCAA(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if err == nil && c.String() != tt.want {
+			t.Errorf("ParseCAA(%q) = %s, want %s", tt.value, c, tt.want)
+		}
+	}
+}
+
+func TestCAAPermits(t *testing.T) {
+	records := []CAA{
+		{Tag: CAATagIssue, Value: "letsencrypt.org"},
+		{Tag: CAATagIssueWild, Value: ";"},
+		{Tag: CAATagIODEF, Value: "mailto:security@example.com"},
+	}
+	tests := []struct {
+		records  []CAA
+		issuer   string
+		wildcard bool
+		want     bool
+		wantErr  bool
+	}{
+		{records, "letsencrypt.org", false, true, false},
+		{records, "LetsEncrypt.org.", false, true, false},
+		{records, "sectigo.com", false, false, false},
+		{records, "letsencrypt.org", true, false, false},
+		{records[:1], "letsencrypt.org", true, true, false},
+		{records[2:], "sectigo.com", false, true, false},
+		{nil, "sectigo.com", false, true, false},
+		{[]CAA{{Flags: CAAFlagCritical, Tag: "future", Value: "x"}}, "letsencrypt.org", false, false, true},
+	}
+	for i, tt := range tests {
+		got, err := CAAPermits(tt.records, tt.issuer, tt.wildcard)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("%d: CAAPermits(%s, wildcard=%v) = %v, %v; want %v", i, tt.issuer, tt.wildcard, got, err, tt.want)
+		}
+	}
+}
+
+func TestFindCAA(t *testing.T) {
+	var asked []string
+	lookup := func(ctx context.Context, name string) ([]CAA, error) {
+		asked = append(asked, name)
+		if name == "example.com" {
+			return []CAA{{Tag: CAATagIssue, Value: "letsencrypt.org"}}, nil
+		}
+		return nil, nil
+	}
+	records, at, err := FindCAA(context.Background(), "*.app.example.com", lookup)
+	if err != nil || at != "example.com" || len(records) != 1 {
+		t.Fatalf("FindCAA() = %v, %q, %v", records, at, err)
+	}
+	if len(asked) != 2 || asked[0] != "app.example.com" {
+		t.Errorf("FindCAA() looked up %v", asked)
+	}
+
+	asked = nil
+	if records, at, _ := FindCAA(context.Background(), "www.example.org", lookup); records != nil || at != "" || len(asked) != 2 {
+		t.Errorf("FindCAA() without records = %v, %q (looked up %v)", records, at, asked)
+	}
+}
+
+func TestValidateRecordName(t *testing.T) {
+	for _, name := range []string{"www.example.com", "*.app.example.com", "*", "_dmarc", "@", "example.com."} {
+		if err := ValidateRecordName(name); err != nil {
+			t.Errorf("ValidateRecordName(%q) error = %v", name, err)
+		}
+	}
+	for _, name := range []string{"app.*.example.com", "*app.example.com", "www..example.com", "-www.example.com"} {
+		if err := ValidateRecordName(name); err == nil {
+			t.Errorf("ValidateRecordName(%q): expected error", name)
+		}
+	}
+	if !IsWildcard("*.app") || IsWildcard("app") {
+		t.Error("IsWildcard() is wrong")
+	}
+}
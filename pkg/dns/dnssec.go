@@ -0,0 +1,397 @@
+package dns
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DNSSEC record types
+const (
+	RecordTypeDS     RecordType = "DS"
+	RecordTypeDNSKEY RecordType = "DNSKEY"
+)
+
+// DS digest types (RFC 4034, RFC 4509, RFC 6605)
+const (
+	DigestSHA1   = 1
+	DigestSHA256 = 2
+	DigestSHA384 = 4
+)
+
+// dnskeyFlagSEP marks a key signing key (RFC 4034 section 2.1.1)
+const dnskeyFlagSEP = 1
+
+// DNSKEY is the value of a DNSKEY record: a public key the zone is signed
+// with
+type DNSKEY struct {
+	Flags     uint16
+	Protocol  uint8
+	Algorithm uint8
+	PublicKey string // Base64, as in zone files
+}
+
+// ParseDNSKEY parses a DNSKEY value as written in zone files, e.g.
+// "257 3 13 mdsswUyr3DPW...". The key may be split by spaces.
+func ParseDNSKEY(s string) (*DNSKEY, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("invalid DNSKEY record %q", s)
+	}
+	flags, err1 := strconv.ParseUint(fields[0], 10, 16)
+	protocol, err2 := strconv.ParseUint(fields[1], 10, 8)
+	algorithm, err3 := strconv.ParseUint(fields[2], 10, 8)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return nil, fmt.Errorf("invalid DNSKEY record %q", s)
+	}
+	k := &DNSKEY{Flags: uint16(flags), Protocol: uint8(protocol), Algorithm: uint8(algorithm), PublicKey: strings.Join(fields[3:], "")}
+	if _, err := base64.StdEncoding.DecodeString(k.PublicKey); err != nil {
+		return nil, fmt.Errorf("invalid DNSKEY public key: %w", err)
+	}
+	return k, nil
+}
+
+// String returns the record value in zone file form
+func (k DNSKEY) String() string {
+	return fmt.Sprintf("%d %d %d %s", k.Flags, k.Protocol, k.Algorithm, k.PublicKey)
+}
+
+// IsKSK reports whether the key is a key signing key, the one the DS
+// record at the parent points to
+func (k DNSKEY) IsKSK() bool {
+	return k.Flags&dnskeyFlagSEP != 0
+}
+
+// rdata returns the key in wire format
+func (k DNSKEY) rdata() ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(k.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DNSKEY public key: %w", err)
+	}
+	b := binary.BigEndian.AppendUint16(nil, k.Flags)
+	b = append(b, k.Protocol, k.Algorithm)
+	return append(b, key...), nil
+}
+
+// KeyTag returns the key tag DS records and signatures refer to the key by
+// (RFC 4034 appendix B)
+func (k DNSKEY) KeyTag() uint16 {
+	rdata, err := k.rdata()
+	if err != nil {
+		return 0
+	}
+	var ac uint32
+	for i, b := range rdata {
+		if i&1 == 0 {
+			ac += uint32(b) << 8
+		} else {
+			ac += uint32(b)
+		}
+	}
+	ac += ac >> 16 & 0xffff
+	return uint16(ac & 0xffff)
+}
+
+// ToDS returns the DS record that delegates zone to the key, with a digest
+// of digestType
+func (k DNSKEY) ToDS(zone string, digestType uint8) (*DS, error) {
+	var h hash.Hash
+	switch digestType {
+	case DigestSHA1:
+		h = sha1.New()
+	case DigestSHA256:
+		h = sha256.New()
+	case DigestSHA384:
+		h = sha512.New384()
+	default:
+		return nil, fmt.Errorf("unsupported DS digest type %d", digestType)
+	}
+	owner, err := wireName(zone)
+	if err != nil {
+		return nil, err
+	}
+	rdata, err := k.rdata()
+	if err != nil {
+		return nil, err
+	}
+	h.Write(owner)
+	h.Write(rdata)
+	return &DS{
+		KeyTag:     k.KeyTag(),
+		Algorithm:  k.Algorithm,
+		DigestType: digestType,
+		Digest:     strings.ToUpper(hex.EncodeToString(h.Sum(nil))),
+	}, nil
+}
+
+// wireName returns a domain name in canonical wire format: lowercase
+// length-prefixed labels ending with the root label
+func wireName(name string) ([]byte, error) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	var b []byte
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if label == "" || len(label) > 63 {
+				return nil, fmt.Errorf("invalid domain name %q", name)
+			}
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+	}
+	return append(b, 0), nil
+}
+
+// DS is the value of a DS record: the digest of a child zone's key signing
+// key, published in the parent zone (at the registrar for apex domains)
+type DS struct {
+	KeyTag     uint16
+	Algorithm  uint8
+	DigestType uint8
+	Digest     string // Uppercase hex
+}
+
+// ParseDS parses a DS value as written in zone files, e.g.
+// "2371 13 2 1F987CC6583E9...". The digest may be split by spaces.
+func ParseDS(s string) (*DS, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("invalid DS record %q", s)
+	}
+	keyTag, err1 := strconv.ParseUint(fields[0], 10, 16)
+	algorithm, err2 := strconv.ParseUint(fields[1], 10, 8)
+	digestType, err3 := strconv.ParseUint(fields[2], 10, 8)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return nil, fmt.Errorf("invalid DS record %q", s)
+	}
+	digest := strings.ToUpper(strings.Join(fields[3:], ""))
+	if _, err := hex.DecodeString(digest); err != nil {
+		return nil, fmt.Errorf("invalid DS digest %q", digest)
+	}
+	return &DS{KeyTag: uint16(keyTag), Algorithm: uint8(algorithm), DigestType: uint8(digestType), Digest: digest}, nil
+}
+
+// String returns the record value in zone file form, as registrars ask
+// for it
+func (d DS) String() string {
+	return fmt.Sprintf("%d %d %d %s", d.KeyTag, d.Algorithm, d.DigestType, d.Digest)
+}
+
+// Matches reports whether the DS record delegates zone to key
+func (d DS) Matches(zone string, key DNSKEY) bool {
+	if d.KeyTag != key.KeyTag() || d.Algorithm != key.Algorithm {
+		return false
+	}
+	want, err := key.ToDS(zone, d.DigestType)
+	return err == nil && strings.EqualFold(want.Digest, d.Digest)
+}
+
+// DNSSECStatus is the DNSSEC state of a zone at its DNS provider
+type DNSSECStatus struct {
+	Enabled bool
+	DNSKEYs []DNSKEY // The zone's key signing keys
+	DS      []DS     // The DS records to publish at the registrar
+}
+
+// DNSSECManager is implemented by providers that can sign zones
+type DNSSECManager interface {
+	// GetDNSSEC returns whether a zone is signed, and its keys
+	GetDNSSEC(ctx context.Context, zone string) (*DNSSECStatus, error)
+
+	// EnableDNSSEC signs a zone and returns the DS records to publish;
+	// enabling it on a signed zone returns its current state
+	EnableDNSSEC(ctx context.Context, zone string) (*DNSSECStatus, error)
+
+	// DisableDNSSEC stops signing a zone. The DS records must be removed
+	// at the registrar first, or validating resolvers fail to resolve it.
+	DisableDNSSEC(ctx context.Context, zone string) error
+}
+
+// DNSSECAnswer is the answer of a resolver to a DNSSEC-aware query
+type DNSSECAnswer struct {
+	Status        int      // DNS response code: 0 NOERROR, 2 SERVFAIL, 3 NXDOMAIN
+	Authenticated bool     // The resolver validated the answer (AD flag)
+	Records       []string // Values of the records of the queried type
+}
+
+// DNSSECLookup queries the records of a type at a name. With
+// checkingDisabled the resolver returns records even if they fail
+// validation (CD flag), so broken setups can be inspected.
+type DNSSECLookup func(ctx context.Context, name string, recordType RecordType, checkingDisabled bool) (*DNSSECAnswer, error)
+
+// dnssecTypes are the numbers of the record types DNSSEC checks query
+var dnssecTypes = map[RecordType]int{"SOA": 6, RecordTypeDS: 43, RecordTypeDNSKEY: 48}
+
+// LookupDNSSEC queries a validating resolver using DNS-over-HTTPS, as the
+// standard library resolver neither returns DS and DNSKEY records nor
+// reports validation
+func LookupDNSSEC(ctx context.Context, name string, recordType RecordType, checkingDisabled bool) (*DNSSECAnswer, error) {
+	rrtype, ok := dnssecTypes[recordType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported record type %s", recordType)
+	}
+	providers := []string{
+		"https://dns.google/resolve",
+		"https://cloudflare-dns.com/dns-query",
+	}
+	query := url.Values{"name": {name}, "type": {string(recordType)}, "do": {"1"}}
+	if checkingDisabled {
+		query.Set("cd", "1")
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	var lastErr error
+	for _, provider := range providers {
+		req, err := http.NewRequestWithContext(ctx, "GET", provider+"?"+query.Encode(), nil)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		req.Header.Set("Accept", "application/dns-json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		var dohResp struct {
+			dohResponse
+			AD bool `json:"AD"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&dohResp)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			lastErr = fmt.Errorf("DoH provider returned status %d", resp.StatusCode)
+			continue
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		answer := &DNSSECAnswer{Status: dohResp.Status, Authenticated: dohResp.AD}
+		for _, a := range dohResp.Answer {
+			if a.Type == rrtype {
+				answer.Records = append(answer.Records, a.Data)
+			}
+		}
+		return answer, nil
+	}
+	return nil, fmt.Errorf("all DoH providers failed: %w", lastErr)
+}
+
+// DNSSECState summarizes the DNSSEC setup of a zone as resolvers see it
+type DNSSECState string
+
+const (
+	// DNSSECUnsigned: no keys and no DS record, the zone is not signed
+	DNSSECUnsigned DNSSECState = "unsigned"
+	// DNSSECNoDS: the zone is signed, but the parent has no DS record yet
+	DNSSECNoDS DNSSECState = "no-ds"
+	// DNSSECSecure: a DS record matches a key and resolvers validate the zone
+	DNSSECSecure DNSSECState = "secure"
+	// DNSSECUnvalidated: a DS record matches a key, but the resolver did
+	// not validate the zone (e.g. the parent zone is not signed)
+	DNSSECUnvalidated DNSSECState = "unvalidated"
+	// DNSSECBogus: the DS records match no key or validation fails, so
+	// validating resolvers cannot resolve the zone at all
+	DNSSECBogus DNSSECState = "bogus"
+)
+
+// DNSSECResult is the result of checking the DNSSEC setup of a zone
+type DNSSECResult struct {
+	State      DNSSECState
+	DNSKEYs    []DNSKEY // Keys published in the zone
+	DS         []DS     // DS records published at the parent
+	MatchingDS []DS     // DS records that match a key signing key
+	StaleDS    []DS     // DS records that match no key
+	Error      error
+}
+
+// VerifyDNSSEC checks that the DS records at the parent of a zone match
+// the keys it publishes, and that resolvers validate it
+func VerifyDNSSEC(ctx context.Context, zone string, lookup DNSSECLookup) *DNSSECResult {
+	zone = strings.TrimSuffix(zone, ".")
+	result := &DNSSECResult{}
+
+	keys, err := lookup(ctx, zone, RecordTypeDNSKEY, true)
+	if err != nil {
+		result.Error = fmt.Errorf("DNSKEY lookup of %s failed: %w", zone, err)
+		return result
+	}
+	for _, v := range keys.Records {
+		k, err := ParseDNSKEY(v)
+		if err != nil {
+			result.Error = err
+			return result
+		}
+		result.DNSKEYs = append(result.DNSKEYs, *k)
+	}
+
+	ds, err := lookup(ctx, zone, RecordTypeDS, true)
+	if err != nil {
+		result.Error = fmt.Errorf("DS lookup of %s failed: %w", zone, err)
+		return result
+	}
+	for _, v := range ds.Records {
+		d, err := ParseDS(v)
+		if err != nil {
+			result.Error = err
+			return result
+		}
+		result.DS = append(result.DS, *d)
+	}
+
+	for _, d := range result.DS {
+		matched := false
+		for _, k := range result.DNSKEYs {
+			if k.IsKSK() && d.Matches(zone, k) {
+				matched = true
+				break
+			}
+		}
+		if matched {
+			result.MatchingDS = append(result.MatchingDS, d)
+		} else {
+			result.StaleDS = append(result.StaleDS, d)
+		}
+	}
+
+	switch {
+	case len(result.DS) == 0 && len(result.DNSKEYs) == 0:
+		result.State = DNSSECUnsigned
+		return result
+	case len(result.DS) == 0:
+		result.State = DNSSECNoDS
+		return result
+	case len(result.MatchingDS) == 0:
+		result.State = DNSSECBogus
+		return result
+	}
+
+	validated, err := lookup(ctx, zone, "SOA", false)
+	if err != nil {
+		result.Error = fmt.Errorf("SOA lookup of %s failed: %w", zone, err)
+		return result
+	}
+	switch {
+	case validated.Status == 2: // SERVFAIL: the signatures do not validate
+		result.State = DNSSECBogus
+	case validated.Authenticated:
+		result.State = DNSSECSecure
+	default:
+		result.State = DNSSECUnvalidated
+	}
+	return result
+}
@@ -0,0 +1,102 @@
+package dns
+
+import (
+	"context"
+	"testing"
+)
+
+// The key of RFC 4034 section 5.4, whose DS records are given there and in
+// RFC 4509 section 2.3
+const rfcDNSKEY = "256 3 5 AQOeiiR0GOMYkDshWoSKz9XzfwJr1AYtsmx3TGkJaNXVbfi/ 2pHm822aJ5iI9BMzNXxeYCmZDRD99WYwYqUSdjMmmAphXdvx egXd/M5+X7OrzKBaMbCVdFLUUh6DhweJBjEVv5f2wwjM9Xzc nOf+EPbtG9DMBmADjFDc2w/rljwvFw=="
+
+func TestDNSKEY(t *testing.T) {
+	k, err := ParseDNSKEY(rfcDNSKEY)
+	if err != nil {
+		t.Fatalf("ParseDNSKEY() error = %v", err)
+	}
+	if k.KeyTag() != 60485 {
+		t.Errorf("KeyTag() = %d, want 60485", k.KeyTag())
+	}
+	if k.IsKSK() {
+		t.Error("IsKSK() of a zone signing key = true")
+	}
+
+	for digestType, want := range map[uint8]string{
+		DigestSHA1:   "60485 5 1 2BB183AF5F22588179A53B0A98631FAD1A292118",
+		DigestSHA256: "60485 5 2 D4B7D520E7BB5F0F67674A0CCEB1E3E0614B93C4F9E99B8383F6A1E4469DA50A",
+	} {
+		ds, err := k.ToDS("dskey.example.com.", digestType)
+		if err != nil {
+			t.Fatalf("ToDS(%d) error = %v", digestType, err)
+		}
+		if ds.String() != want {
+			t.Errorf("ToDS(%d) = %s, want %s", digestType, ds, want)
+		}
+		parsed, err := ParseDS(want)
+		if err != nil || !parsed.Matches("DSKEY.example.com", *k) {
+			t.Errorf("ParseDS(%q) = %v, %v; want it to match the key", want, parsed, err)
+		}
+	}
+	if _, err := k.ToDS("dskey.example.com", 3); err == nil {
+		t.Error("ToDS() with an unsupported digest type: expected error")
+	}
+	if ds, _ := ParseDS("60485 5 1 2bb183af5f22588179a53b0a98631fad1a292119"); ds.Matches("dskey.example.com", *k) {
+		t.Error("Matches() with a wrong digest = true")
+	}
+
+	for _, s := range []string{"257 3 13", "257 3 13 not*base64", "x 3 13 AAAA"} {
+		if _, err := ParseDNSKEY(s); err == nil {
+			t.Errorf("ParseDNSKEY(%q): expected error", s)
+		}
+	}
+	for _, s := range []string{"60485 5 1", "60485 5 1 XYZ", "70000 5 1 AB"} {
+		if _, err := ParseDS(s); err == nil {
+			t.Errorf("ParseDS(%q): expected error", s)
+		}
+	}
+}
+
+func TestVerifyDNSSEC(t *testing.T) {
+	ksk, _ := ParseDNSKEY(rfcDNSKEY)
+	ksk.Flags = 257
+	ds, _ := ksk.ToDS("example.com", DigestSHA256)
+	stale := *ds
+	stale.KeyTag++
+
+	tests := []struct {
+		name      string
+		dnskeys   []string
+		ds        []string
+		servfail  bool
+		validated bool
+		want      DNSSECState
+	}{
+		{"unsigned", nil, nil, false, false, DNSSECUnsigned},
+		{"no DS at the registrar", []string{ksk.String()}, nil, false, false, DNSSECNoDS},
+		{"secure", []string{ksk.String()}, []string{ds.String(), stale.String()}, false, true, DNSSECSecure},
+		{"not validated", []string{ksk.String()}, []string{ds.String()}, false, false, DNSSECUnvalidated},
+		{"DS of an old key", []string{ksk.String()}, []string{stale.String()}, false, false, DNSSECBogus},
+		{"DS without keys", nil, []string{ds.String()}, false, false, DNSSECBogus},
+		{"signatures fail", []string{ksk.String()}, []string{ds.String()}, true, false, DNSSECBogus},
+	}
+	for _, tt := range tests {
+		lookup := func(ctx context.Context, name string, recordType RecordType, checkingDisabled bool) (*DNSSECAnswer, error) {
+			switch {
+			case recordType == RecordTypeDNSKEY:
+				return &DNSSECAnswer{Records: tt.dnskeys}, nil
+			case recordType == RecordTypeDS:
+				return &DNSSECAnswer{Records: tt.ds}, nil
+			case tt.servfail && !checkingDisabled:
+				return &DNSSECAnswer{Status: 2}, nil
+			}
+			return &DNSSECAnswer{Authenticated: tt.validated, Records: []string{"soa"}}, nil
+		}
+		result := VerifyDNSSEC(context.Background(), "example.com.", lookup)
+		if result.Error != nil || result.State != tt.want {
+			t.Errorf("%s: VerifyDNSSEC() = %s, %v; want %s", tt.name, result.State, result.Error, tt.want)
+		}
+		if tt.want == DNSSECSecure && (len(result.MatchingDS) != 1 || len(result.StaleDS) != 1) {
+			t.Errorf("%s: matching DS %v, stale DS %v", tt.name, result.MatchingDS, result.StaleDS)
+		}
+	}
+}
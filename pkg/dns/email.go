@@ -0,0 +1,627 @@
+package dns
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Email audit check statuses
+const (
+	EmailPass = "pass"
+	EmailWarn = "warn"
+	EmailFail = "fail"
+	EmailSkip = "skip"
+)
+
+// spfLookupLimit is the number of DNS-querying SPF terms allowed by RFC 7208
+const spfLookupLimit = 10
+
+// EmailAuditOptions configures AuditEmail
+type EmailAuditOptions struct {
+	// DKIMSelectors are the selectors to look up under _domainkey; the DKIM
+	// check is skipped without them since selectors cannot be listed
+	DKIMSelectors []string
+
+	// SendingIPs are the addresses mail is sent from; their reverse DNS
+	// must resolve back to them (the rDNS check is skipped without them)
+	SendingIPs []string
+
+	// Resolver to query ("host:port" or SystemResolver, the default)
+	Resolver string
+}
+
+// EmailCheck is the outcome of one email audit check
+type EmailCheck struct {
+	Name    string   `json:"name"`
+	Status  string   `json:"status"`
+	Score   int      `json:"score"`
+	Max     int      `json:"max"`
+	Details []string `json:"details,omitempty"`
+	Fix     string   `json:"fix,omitempty"`
+}
+
+// EmailAudit is a scored email deliverability report for a domain
+type EmailAudit struct {
+	Domain string       `json:"domain"`
+	Score  int          `json:"score"` // Percent of the points of the checks that ran
+	Grade  string       `json:"grade"`
+	Checks []EmailCheck `json:"checks"`
+}
+
+// Failed reports whether any check failed
+func (a *EmailAudit) Failed() bool {
+	for _, c := range a.Checks {
+		if c.Status == EmailFail {
+			return true
+		}
+	}
+	return false
+}
+
+// emailResolver is the part of net.Resolver used by the audit
+type emailResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+}
+
+// AuditEmail checks the records that decide whether mail from and to domain
+// is delivered: MX, SPF, DMARC, DKIM, MTA-STS, TLS-RPT and the reverse DNS
+// of the sending addresses
+func AuditEmail(ctx context.Context, domain string, opts EmailAuditOptions) *EmailAudit {
+	return auditEmail(ctx, newResolver(opts.Resolver), domain, opts)
+}
+
+func auditEmail(ctx context.Context, r emailResolver, domain string, opts EmailAuditOptions) *EmailAudit {
+	domain = NormalizeNS(domain)
+	audit := &EmailAudit{Domain: domain}
+	audit.Checks = []EmailCheck{
+		checkMX(ctx, r, domain),
+		checkSPF(ctx, r, domain),
+		checkDMARC(ctx, r, domain),
+		checkDKIM(ctx, r, domain, opts.DKIMSelectors),
+		checkMTASTS(ctx, r, domain),
+		checkTLSRPT(ctx, r, domain),
+		checkReverseDNS(ctx, r, opts.SendingIPs),
+	}
+
+	score, max := 0, 0
+	for _, c := range audit.Checks {
+		if c.Status != EmailSkip {
+			score += c.Score
+			max += c.Max
+		}
+	}
+	if max > 0 {
+		audit.Score = score * 100 / max
+	}
+	audit.Grade = emailGrade(audit.Score)
+	return audit
+}
+
+func emailGrade(score int) string {
+	switch {
+	case score >= 90:
+		return "A"
+	case score >= 75:
+		return "B"
+	case score >= 60:
+		return "C"
+	case score >= 40:
+		return "D"
+	}
+	return "F"
+}
+
+// finish sets the status of c to the worst seen and scores it: full points
+// for a pass, half for a warning
+func (c *EmailCheck) finish(status string) EmailCheck {
+	c.Status = status
+	switch status {
+	case EmailPass:
+		c.Score = c.Max
+	case EmailWarn:
+		c.Score = c.Max / 2
+	}
+	return *c
+}
+
+func worse(a, b string) string {
+	rank := map[string]int{EmailSkip: 0, EmailPass: 1, EmailWarn: 2, EmailFail: 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// lookupTXT returns the TXT records of name; a missing name has none
+func lookupTXT(ctx context.Context, r emailResolver, name string) ([]string, error) {
+	txts, err := r.LookupTXT(ctx, name)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil, nil
+	}
+	return txts, err
+}
+
+// txtWithPrefix returns the TXT records of name starting with prefix
+func txtWithPrefix(ctx context.Context, r emailResolver, name, prefix string) ([]string, error) {
+	txts, err := lookupTXT(ctx, r, name)
+	if err != nil {
+		return nil, err
+	}
+	var matching []string
+	for _, txt := range txts {
+		if strings.HasPrefix(strings.ToLower(txt), strings.ToLower(prefix)) {
+			matching = append(matching, txt)
+		}
+	}
+	return matching, nil
+}
+
+// parseTags parses a "k=v; k=v" record (DMARC, DKIM, MTA-STS, TLS-RPT)
+func parseTags(record string) map[string]string {
+	tags := make(map[string]string)
+	for _, part := range strings.Split(record, ";") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		tags[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+	}
+	return tags
+}
+
+func checkMX(ctx context.Context, r emailResolver, domain string) EmailCheck {
+	c := EmailCheck{Name: "MX", Max: 20}
+	mxs, err := r.LookupMX(ctx, domain)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+			c.Details = append(c.Details, err.Error())
+			return c.finish(EmailFail)
+		}
+	}
+	if len(mxs) == 0 {
+		c.Details = append(c.Details, "no MX records")
+		c.Fix = "morpheus dns add gmail-mx " + domain
+		return c.finish(EmailFail)
+	}
+
+	// RFC 7505: a single "." MX says the domain accepts no mail
+	if len(mxs) == 1 && NormalizeNS(mxs[0].Host) == "" {
+		c.Details = append(c.Details, "null MX: domain accepts no mail")
+		return c.finish(EmailPass)
+	}
+
+	status := EmailPass
+	for _, mx := range mxs {
+		host := NormalizeNS(mx.Host)
+		if strings.Contains(host, "google.com."+domain) {
+			c.Details = append(c.Details, fmt.Sprintf("%d %s: domain appended to the host (missing trailing dot)", mx.Pref, host))
+			c.Fix = fmt.Sprintf("morpheus dns record delete %s @ MX, then morpheus dns add gmail-mx %s", domain, domain)
+			status = worse(status, EmailFail)
+			continue
+		}
+		if addrs, err := r.LookupHost(ctx, host); err != nil || len(addrs) == 0 {
+			c.Details = append(c.Details, fmt.Sprintf("%d %s: does not resolve", mx.Pref, host))
+			status = worse(status, EmailWarn)
+			continue
+		}
+		c.Details = append(c.Details, fmt.Sprintf("%d %s", mx.Pref, host))
+	}
+	return c.finish(status)
+}
+
+func checkSPF(ctx context.Context, r emailResolver, domain string) EmailCheck {
+	c := EmailCheck{Name: "SPF", Max: 20}
+	records, err := txtWithPrefix(ctx, r, domain, "v=spf1")
+	switch {
+	case err != nil:
+		c.Details = append(c.Details, err.Error())
+		return c.finish(EmailFail)
+	case len(records) == 0:
+		c.Details = append(c.Details, "no SPF record")
+		c.Fix = fmt.Sprintf("add TXT %s \"v=spf1 mx ~all\" listing your senders", domain)
+		return c.finish(EmailFail)
+	case len(records) > 1:
+		c.Details = append(c.Details, fmt.Sprintf("%d SPF records; receivers reject all of them", len(records)))
+		c.Fix = "merge them into one record"
+		return c.finish(EmailFail)
+	}
+	c.Details = append(c.Details, records[0])
+
+	spf, err := parseSPF(records[0])
+	if err != nil {
+		c.Details = append(c.Details, "syntax: "+err.Error())
+		return c.finish(EmailFail)
+	}
+
+	status := EmailPass
+	lookups, err := spfLookups(ctx, r, spf, map[string]bool{domain: true})
+	switch {
+	case err != nil:
+		c.Details = append(c.Details, err.Error())
+		status = EmailFail
+	case lookups > spfLookupLimit:
+		c.Details = append(c.Details, fmt.Sprintf("%d DNS lookups, over the limit of %d", lookups, spfLookupLimit))
+		c.Fix = "flatten includes into ip4/ip6 terms"
+		status = EmailFail
+	default:
+		c.Details = append(c.Details, fmt.Sprintf("%d/%d DNS lookups", lookups, spfLookupLimit))
+	}
+
+	switch spf.all {
+	case "+":
+		c.Details = append(c.Details, "+all lets anyone send as the domain")
+		status = worse(status, EmailFail)
+	case "?":
+		c.Details = append(c.Details, "?all is neutral; use ~all or -all")
+		status = worse(status, EmailWarn)
+	case "":
+		if spf.redirect == "" {
+			c.Details = append(c.Details, "no all mechanism; unlisted senders are neutral")
+			status = worse(status, EmailWarn)
+		}
+	}
+	return c.finish(status)
+}
+
+// spfRecord is a parsed SPF record
+type spfRecord struct {
+	includes []string // Domains of include mechanisms
+	lookups  int      // Terms other than include and redirect that query DNS
+	all      string   // Qualifier of the all mechanism, if any
+	redirect string
+}
+
+// parseSPF checks the syntax of an SPF record (RFC 7208 section 12)
+func parseSPF(record string) (*spfRecord, error) {
+	terms := strings.Fields(record)
+	if len(terms) == 0 || !strings.EqualFold(terms[0], "v=spf1") {
+		return nil, fmt.Errorf("record must start with v=spf1")
+	}
+
+	spf := &spfRecord{}
+	for _, term := range terms[1:] {
+		// Modifiers are name=value, with no ":" or "/" before the "="
+		if i := strings.IndexAny(term, "=:/"); i > 0 && term[i] == '=' {
+			name, value := strings.ToLower(term[:i]), term[i+1:]
+			switch name {
+			case "redirect":
+				if spf.redirect != "" {
+					return nil, fmt.Errorf("redirect given twice")
+				}
+				if value == "" {
+					return nil, fmt.Errorf("redirect without a domain")
+				}
+				spf.redirect = value
+			case "exp":
+				if value == "" {
+					return nil, fmt.Errorf("exp without a domain")
+				}
+			}
+			continue
+		}
+
+		qualifier := "+"
+		if strings.ContainsRune("+-~?", rune(term[0])) {
+			qualifier, term = term[:1], term[1:]
+		}
+		name, arg, hasArg := strings.Cut(term, ":")
+		if !hasArg {
+			name, _, _ = strings.Cut(term, "/")
+		}
+		switch strings.ToLower(name) {
+		case "all":
+			if term != name {
+				return nil, fmt.Errorf("all takes no argument")
+			}
+			if spf.all != "" {
+				return nil, fmt.Errorf("all given twice")
+			}
+			spf.all = qualifier
+		case "include":
+			if arg == "" {
+				return nil, fmt.Errorf("include without a domain")
+			}
+			spf.includes = append(spf.includes, arg)
+		case "exists":
+			if arg == "" {
+				return nil, fmt.Errorf("exists without a domain")
+			}
+			spf.lookups++
+		case "a", "mx", "ptr":
+			spf.lookups++
+		case "ip4":
+			if !validSPFAddr(arg, true) {
+				return nil, fmt.Errorf("invalid ip4 term: %s", term)
+			}
+		case "ip6":
+			if !validSPFAddr(arg, false) {
+				return nil, fmt.Errorf("invalid ip6 term: %s", term)
+			}
+		default:
+			return nil, fmt.Errorf("unknown mechanism: %s", term)
+		}
+	}
+	return spf, nil
+}
+
+func validSPFAddr(arg string, v4 bool) bool {
+	addr, prefix, hasPrefix := strings.Cut(arg, "/")
+	ip := net.ParseIP(addr)
+	if ip == nil || (ip.To4() != nil) != v4 {
+		return false
+	}
+	if !hasPrefix {
+		return true
+	}
+	bits, err := strconv.Atoi(prefix)
+	max := 128
+	if v4 {
+		max = 32
+	}
+	return err == nil && bits >= 0 && bits <= max
+}
+
+// spfLookups counts the DNS lookups evaluating spf takes, following includes
+// and the redirect. seen holds the domains on the current path.
+func spfLookups(ctx context.Context, r emailResolver, spf *spfRecord, seen map[string]bool) (int, error) {
+	targets := spf.includes
+	// A redirect is only followed when there is no all mechanism
+	if spf.redirect != "" && spf.all == "" {
+		targets = append(targets[:len(targets):len(targets)], spf.redirect)
+	}
+
+	total := spf.lookups + len(targets)
+	for _, target := range targets {
+		// Macros expand per message; count the lookup but do not follow it
+		if strings.Contains(target, "%") {
+			continue
+		}
+		target = NormalizeNS(target)
+		if seen[target] {
+			return 0, fmt.Errorf("SPF loop through %s", target)
+		}
+		records, err := txtWithPrefix(ctx, r, target, "v=spf1")
+		if err != nil {
+			return 0, fmt.Errorf("include %s: %w", target, err)
+		}
+		if len(records) != 1 {
+			return 0, fmt.Errorf("include %s has %d SPF records", target, len(records))
+		}
+		nested, err := parseSPF(records[0])
+		if err != nil {
+			return 0, fmt.Errorf("include %s: %w", target, err)
+		}
+		seen[target] = true
+		n, err := spfLookups(ctx, r, nested, seen)
+		delete(seen, target)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func checkDMARC(ctx context.Context, r emailResolver, domain string) EmailCheck {
+	c := EmailCheck{Name: "DMARC", Max: 20}
+	records, err := txtWithPrefix(ctx, r, "_dmarc."+domain, "v=DMARC1")
+	switch {
+	case err != nil:
+		c.Details = append(c.Details, err.Error())
+		return c.finish(EmailFail)
+	case len(records) == 0:
+		c.Details = append(c.Details, "no DMARC record")
+		c.Fix = fmt.Sprintf("add TXT _dmarc.%s \"v=DMARC1; p=none; rua=mailto:dmarc@%s\" and tighten p once reports are clean", domain, domain)
+		return c.finish(EmailFail)
+	case len(records) > 1:
+		c.Details = append(c.Details, fmt.Sprintf("%d DMARC records; receivers ignore all of them", len(records)))
+		return c.finish(EmailFail)
+	}
+	c.Details = append(c.Details, records[0])
+
+	tags := parseTags(records[0])
+	status := EmailPass
+	switch strings.ToLower(tags["p"]) {
+	case "reject", "quarantine":
+	case "none":
+		c.Details = append(c.Details, "p=none only monitors; failing mail is still delivered")
+		c.Fix = "move to p=quarantine, then p=reject"
+		status = EmailWarn
+	case "":
+		c.Details = append(c.Details, "missing policy (p=)")
+		return c.finish(EmailFail)
+	default:
+		c.Details = append(c.Details, "invalid policy p="+tags["p"])
+		return c.finish(EmailFail)
+	}
+	if pct, ok := tags["pct"]; ok && pct != "100" {
+		c.Details = append(c.Details, fmt.Sprintf("policy applies to %s%% of mail", pct))
+		status = worse(status, EmailWarn)
+	}
+	if strings.EqualFold(tags["sp"], "none") && !strings.EqualFold(tags["p"], "none") {
+		c.Details = append(c.Details, "sp=none leaves subdomains unprotected")
+		status = worse(status, EmailWarn)
+	}
+	if tags["rua"] == "" {
+		c.Details = append(c.Details, "no aggregate reports (rua=)")
+		status = worse(status, EmailWarn)
+	}
+	return c.finish(status)
+}
+
+func checkDKIM(ctx context.Context, r emailResolver, domain string, selectors []string) EmailCheck {
+	c := EmailCheck{Name: "DKIM", Max: 15}
+	if len(selectors) == 0 {
+		c.Details = append(c.Details, "no selectors given (--dkim-selector)")
+		return c.finish(EmailSkip)
+	}
+
+	status := EmailPass
+	for _, selector := range selectors {
+		name := selector + "._domainkey." + domain
+		txts, err := lookupTXT(ctx, r, name)
+		if err != nil {
+			c.Details = append(c.Details, fmt.Sprintf("%s: %s", selector, err))
+			status = worse(status, EmailFail)
+			continue
+		}
+		if len(txts) == 0 {
+			c.Details = append(c.Details, selector+": no key published")
+			status = worse(status, EmailFail)
+			continue
+		}
+		s, detail := checkDKIMKey(strings.Join(txts, ""))
+		c.Details = append(c.Details, selector+": "+detail)
+		status = worse(status, s)
+	}
+	return c.finish(status)
+}
+
+// checkDKIMKey checks a DKIM key record (RFC 6376 section 3.6.1)
+func checkDKIMKey(record string) (string, string) {
+	tags := parseTags(record)
+	if v, ok := tags["v"]; ok && v != "DKIM1" {
+		return EmailFail, "invalid version v=" + v
+	}
+	p, ok := tags["p"]
+	if !ok {
+		return EmailFail, "no public key (p=)"
+	}
+	if p == "" {
+		return EmailFail, "key revoked (empty p=)"
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(p), ""))
+	if err != nil {
+		return EmailFail, "public key is not base64"
+	}
+
+	switch k := strings.ToLower(tags["k"]); k {
+	case "", "rsa":
+		key, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			return EmailFail, "invalid RSA public key"
+		}
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return EmailFail, "public key is not RSA"
+		}
+		bits := rsaKey.N.BitLen()
+		switch {
+		case bits < 1024:
+			return EmailFail, fmt.Sprintf("RSA-%d key is too weak", bits)
+		case bits < 2048:
+			return EmailWarn, fmt.Sprintf("RSA-%d key; use 2048 bits", bits)
+		}
+		return EmailPass, fmt.Sprintf("RSA-%d key", bits)
+	case "ed25519":
+		if len(der) != 32 {
+			return EmailFail, "invalid Ed25519 public key"
+		}
+		return EmailPass, "Ed25519 key"
+	default:
+		return EmailFail, "unknown key type k=" + k
+	}
+}
+
+func checkMTASTS(ctx context.Context, r emailResolver, domain string) EmailCheck {
+	c := EmailCheck{Name: "MTA-STS", Max: 5}
+	records, err := txtWithPrefix(ctx, r, "_mta-sts."+domain, "v=STSv1")
+	switch {
+	case err != nil:
+		c.Details = append(c.Details, err.Error())
+		return c.finish(EmailFail)
+	case len(records) == 0:
+		c.Details = append(c.Details, "no MTA-STS record; senders may deliver without TLS")
+		c.Fix = fmt.Sprintf("publish a policy at https://mta-sts.%s/.well-known/mta-sts.txt and TXT _mta-sts.%s \"v=STSv1; id=<n>\"", domain, domain)
+		return c.finish(EmailWarn)
+	case len(records) > 1:
+		c.Details = append(c.Details, fmt.Sprintf("%d MTA-STS records; senders ignore all of them", len(records)))
+		return c.finish(EmailFail)
+	}
+	c.Details = append(c.Details, records[0])
+	if parseTags(records[0])["id"] == "" {
+		c.Details = append(c.Details, "missing policy id (id=)")
+		return c.finish(EmailFail)
+	}
+	return c.finish(EmailPass)
+}
+
+func checkTLSRPT(ctx context.Context, r emailResolver, domain string) EmailCheck {
+	c := EmailCheck{Name: "TLS-RPT", Max: 5}
+	records, err := txtWithPrefix(ctx, r, "_smtp._tls."+domain, "v=TLSRPTv1")
+	switch {
+	case err != nil:
+		c.Details = append(c.Details, err.Error())
+		return c.finish(EmailFail)
+	case len(records) == 0:
+		c.Details = append(c.Details, "no TLS-RPT record; TLS failures go unreported")
+		c.Fix = fmt.Sprintf("add TXT _smtp._tls.%s \"v=TLSRPTv1; rua=mailto:tls-reports@%s\"", domain, domain)
+		return c.finish(EmailWarn)
+	case len(records) > 1:
+		c.Details = append(c.Details, fmt.Sprintf("%d TLS-RPT records; senders ignore all of them", len(records)))
+		return c.finish(EmailFail)
+	}
+	c.Details = append(c.Details, records[0])
+	if parseTags(records[0])["rua"] == "" {
+		c.Details = append(c.Details, "missing report address (rua=)")
+		return c.finish(EmailFail)
+	}
+	return c.finish(EmailPass)
+}
+
+// checkReverseDNS checks that every sending address has a PTR record whose
+// name resolves back to it (forward-confirmed reverse DNS)
+func checkReverseDNS(ctx context.Context, r emailResolver, ips []string) EmailCheck {
+	c := EmailCheck{Name: "rDNS", Max: 15}
+	if len(ips) == 0 {
+		c.Details = append(c.Details, "no sending addresses given")
+		return c.finish(EmailSkip)
+	}
+
+	status := EmailPass
+	for _, ip := range ips {
+		names, err := r.LookupAddr(ctx, ip)
+		if err != nil || len(names) == 0 {
+			c.Details = append(c.Details, ip+": no PTR record")
+			status = worse(status, EmailFail)
+			continue
+		}
+		name := NormalizeNS(names[0])
+		if !resolvesTo(ctx, r, name, ip) {
+			c.Details = append(c.Details, fmt.Sprintf("%s: PTR %s does not resolve back", ip, name))
+			status = worse(status, EmailFail)
+			continue
+		}
+		c.Details = append(c.Details, fmt.Sprintf("%s: %s", ip, name))
+	}
+	if status == EmailFail {
+		c.Fix = "set the reverse DNS of the servers at the cloud provider to a name resolving to them"
+	}
+	return c.finish(status)
+}
+
+func resolvesTo(ctx context.Context, r emailResolver, name, ip string) bool {
+	want := net.ParseIP(ip)
+	addrs, err := r.LookupHost(ctx, name)
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if got := net.ParseIP(addr); got != nil && got.Equal(want) {
+			return true
+		}
+	}
+	return false
+}
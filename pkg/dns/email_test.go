@@ -0,0 +1,177 @@
+package dns
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeEmailResolver answers from maps; missing names are NXDOMAIN
+type fakeEmailResolver struct {
+	txt  map[string][]string
+	mx   map[string][]*net.MX
+	host map[string][]string
+	ptr  map[string][]string
+}
+
+func notFound(name string) error {
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (f *fakeEmailResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if txts, ok := f.txt[name]; ok {
+		return txts, nil
+	}
+	return nil, notFound(name)
+}
+
+func (f *fakeEmailResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	if mxs, ok := f.mx[name]; ok {
+		return mxs, nil
+	}
+	return nil, notFound(name)
+}
+
+func (f *fakeEmailResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := f.host[host]; ok {
+		return addrs, nil
+	}
+	return nil, notFound(host)
+}
+
+func (f *fakeEmailResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	if names, ok := f.ptr[addr]; ok {
+		return names, nil
+	}
+	return nil, notFound(addr)
+}
+
+func TestParseSPF(t *testing.T) {
+	tests := []struct {
+		record  string
+		lookups int
+		all     string
+		wantErr bool
+	}{
+		{"v=spf1 mx a:mail.example.com ip4:192.0.2.0/24 ip6:2001:db8::/32 include:_spf.google.com ~all", 2, "~", false},
+		{"v=spf1 -all", 0, "-", false},
+		{"v=spf1 redirect=_spf.example.com", 0, "", false},
+		{"v=spf1 exists:%{i}.spf.example.com ptr all", 2, "+", false},
+		{"v=spf2 mx", 0, "", true},
+		{"v=spf1 ip4:192.0.2.1/33", 0, "", true},
+		{"v=spf1 ip4:2001:db8::1", 0, "", true},
+		{"v=spf1 include:", 0, "", true},
+		{"v=spf1 -all ~all", 0, "", true},
+		{"v=spf1 mxx", 0, "", true},
+	}
+	for _, tt := range tests {
+		spf, err := parseSPF(tt.record)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSPF(%q) error = %v, wantErr %v", tt.record, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (spf.lookups != tt.lookups || spf.all != tt.all) {
+			t.Errorf("parseSPF(%q) = lookups %d all %q, want %d %q", tt.record, spf.lookups, spf.all, tt.lookups, tt.all)
+		}
+	}
+}
+
+func TestSPFLookups(t *testing.T) {
+	r := &fakeEmailResolver{txt: map[string][]string{
+		"a.example.com":    {"v=spf1 include:b.example.com mx mx a ~all"},
+		"b.example.com":    {"v=spf1 a a a a a ~all"},
+		"loop.example.com": {"v=spf1 include:example.com ~all"},
+	}}
+	ctx := context.Background()
+
+	spf, _ := parseSPF("v=spf1 include:a.example.com mx ~all")
+	// include a (1) + mx (1) + a's include b (1) + a's 3 terms + b's 5 terms
+	if n, err := spfLookups(ctx, r, spf, map[string]bool{"example.com": true}); err != nil || n != 11 {
+		t.Errorf("spfLookups() = %d, %v, want 11", n, err)
+	}
+
+	spf, _ = parseSPF("v=spf1 include:loop.example.com ~all")
+	if _, err := spfLookups(ctx, r, spf, map[string]bool{"example.com": true}); err == nil {
+		t.Error("spfLookups() through a loop: expected error")
+	}
+}
+
+func rsaKeyRecord(t *testing.T, bits int) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der)
+}
+
+func TestAuditEmail(t *testing.T) {
+	r := &fakeEmailResolver{
+		txt: map[string][]string{
+			"example.com":                   {"google-site-verification=abc", "v=spf1 mx include:_spf.google.com -all"},
+			"_spf.google.com":               {"v=spf1 ip4:192.0.2.0/24 ~all"},
+			"_dmarc.example.com":            {"v=DMARC1; p=reject; rua=mailto:dmarc@example.com"},
+			"google._domainkey.example.com": {rsaKeyRecord(t, 2048)},
+			"_mta-sts.example.com":          {"v=STSv1; id=20260101"},
+			"_smtp._tls.example.com":        {"v=TLSRPTv1; rua=mailto:tls@example.com"},
+		},
+		mx:   map[string][]*net.MX{"example.com": {{Host: "aspmx.l.google.com.", Pref: 1}}},
+		host: map[string][]string{"aspmx.l.google.com": {"192.0.2.25"}, "node-1.example.com": {"2001:db8::1"}},
+		ptr:  map[string][]string{"2001:db8::1": {"node-1.example.com."}},
+	}
+	opts := EmailAuditOptions{DKIMSelectors: []string{"google"}, SendingIPs: []string{"2001:db8::1"}}
+
+	audit := auditEmail(context.Background(), r, "example.com", opts)
+	if audit.Score != 100 || audit.Grade != "A" || audit.Failed() {
+		t.Errorf("auditEmail() = %s", formatChecks(audit))
+	}
+
+	// Weaken every check
+	r.txt["_dmarc.example.com"] = []string{"v=DMARC1; p=none"}
+	r.txt["google._domainkey.example.com"] = []string{"v=DKIM1; p="}
+	delete(r.txt, "_mta-sts.example.com")
+	delete(r.txt, "_smtp._tls.example.com")
+	r.host["node-1.example.com"] = []string{"2001:db8::2"}
+
+	audit = auditEmail(context.Background(), r, "example.com", opts)
+	want := map[string]string{"MX": EmailPass, "SPF": EmailPass, "DMARC": EmailWarn, "DKIM": EmailFail, "MTA-STS": EmailWarn, "TLS-RPT": EmailWarn, "rDNS": EmailFail}
+	for _, c := range audit.Checks {
+		if c.Status != want[c.Name] {
+			t.Errorf("%s = %s, want %s (%v)", c.Name, c.Status, want[c.Name], c.Details)
+		}
+	}
+	// 20 + 20 + 10 + 0 + 2 + 2 + 0 of 100
+	if audit.Score != 54 || !audit.Failed() {
+		t.Errorf("auditEmail() = %s", formatChecks(audit))
+	}
+
+	// Checks without input are skipped and left out of the score
+	audit = auditEmail(context.Background(), &fakeEmailResolver{}, "example.com", EmailAuditOptions{})
+	for _, c := range audit.Checks {
+		if (c.Name == "DKIM" || c.Name == "rDNS") != (c.Status == EmailSkip) {
+			t.Errorf("%s = %s", c.Name, c.Status)
+		}
+	}
+	// Only MTA-STS and TLS-RPT score, as warnings: 2 + 2 of 70
+	if audit.Score != 5 || audit.Grade != "F" {
+		t.Errorf("auditEmail() without records = %s", formatChecks(audit))
+	}
+}
+
+func formatChecks(a *EmailAudit) string {
+	var parts []string
+	for _, c := range a.Checks {
+		parts = append(parts, fmt.Sprintf("%s=%s(%d/%d)", c.Name, c.Status, c.Score, c.Max))
+	}
+	return fmt.Sprintf("score %d grade %s: %s", a.Score, a.Grade, strings.Join(parts, " "))
+}
@@ -0,0 +1,191 @@
+package dns
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EmailProvider defines the DNS records a hosted email service needs
+type EmailProvider struct {
+	Name    string                // e.g., "gmail", "fastmail"
+	Title   string                // Human-readable name, e.g., "Gmail/Google Workspace"
+	MX      []EmailMX             // Mail servers; they form the apex MX RRSet
+	SPF     string                // Domain the SPF record includes, e.g., "_spf.google.com"
+	Records []EmailRecordTemplate // DKIM, autodiscover and other records
+	Setup   []string              // Steps to take at the provider, e.g., to get a DKIM key
+}
+
+// EmailMX is a mail server of an email provider
+type EmailMX struct {
+	Priority int
+	Server   string // Absolute FQDN (with trailing dot); can use placeholders
+}
+
+// EmailRecordTemplate defines a record of an email provider. Name, Value
+// and the provider's setup steps can use these placeholders:
+//   - {{.Domain}} - the domain, e.g. "example.com"
+//   - {{.DashedDomain}} - the domain with dashes for dots, e.g. "example-com"
+type EmailRecordTemplate struct {
+	Name    string     // e.g., "fm1._domainkey", "autodiscover"
+	Type    RecordType // CNAME, SRV, TXT
+	Value   string
+	Purpose string // e.g., "DKIM", "Autodiscover"
+}
+
+// Note: trailing dots are required to make the servers absolute FQDNs
+var emailProviders = map[string]EmailProvider{
+	"gmail": {
+		Name:  "gmail",
+		Title: "Gmail/Google Workspace",
+		MX: []EmailMX{
+			{1, "ASPMX.L.GOOGLE.COM."},
+			{5, "ALT1.ASPMX.L.GOOGLE.COM."},
+			{5, "ALT2.ASPMX.L.GOOGLE.COM."},
+			{10, "ALT3.ASPMX.L.GOOGLE.COM."},
+			{10, "ALT4.ASPMX.L.GOOGLE.COM."},
+		},
+		SPF: "_spf.google.com",
+		Setup: []string{
+			"Go to admin.google.com",
+			"Navigate to Apps → Google Workspace → Gmail → Authenticate email",
+			"Click 'Generate new record' for your domain",
+			"Add the DKIM record: morpheus dns record create google._domainkey.{{.Domain}} TXT \"<dkim-value>\"",
+			"Return to the Admin Console and click 'Start authentication'",
+		},
+	},
+	"outlook365": {
+		Name:  "outlook365",
+		Title: "Microsoft 365 (Outlook)",
+		MX: []EmailMX{
+			{0, "{{.DashedDomain}}.mail.protection.outlook.com."},
+		},
+		SPF: "spf.protection.outlook.com",
+		Records: []EmailRecordTemplate{
+			{"autodiscover", RecordTypeCNAME, "autodiscover.outlook.com.", "Autodiscover"},
+		},
+		Setup: []string{
+			"Add and verify {{.Domain}} in the Microsoft 365 admin center (admin.microsoft.com)",
+			"In the Defender portal, open Email authentication settings → DKIM and select {{.Domain}}",
+			"Add the two CNAME records it shows (their targets contain your tenant name):",
+			"  morpheus dns record create selector1._domainkey.{{.Domain}} CNAME <selector1-target>",
+			"  morpheus dns record create selector2._domainkey.{{.Domain}} CNAME <selector2-target>",
+			"Enable 'Sign messages for this domain with DKIM signatures'",
+		},
+	},
+	"fastmail": {
+		Name:  "fastmail",
+		Title: "Fastmail",
+		MX: []EmailMX{
+			{10, "in1-smtp.messagingengine.com."},
+			{20, "in2-smtp.messagingengine.com."},
+		},
+		SPF: "spf.messagingengine.com",
+		Records: []EmailRecordTemplate{
+			{"fm1._domainkey", RecordTypeCNAME, "fm1.{{.Domain}}.dkim.fmhosted.com.", "DKIM"},
+			{"fm2._domainkey", RecordTypeCNAME, "fm2.{{.Domain}}.dkim.fmhosted.com.", "DKIM"},
+			{"fm3._domainkey", RecordTypeCNAME, "fm3.{{.Domain}}.dkim.fmhosted.com.", "DKIM"},
+			{"_submission._tcp", RecordTypeSRV, "0 1 587 smtp.fastmail.com.", "Autodiscover"},
+			{"_imaps._tcp", RecordTypeSRV, "0 1 993 imap.fastmail.com.", "Autodiscover"},
+			{"_jmap._tcp", RecordTypeSRV, "0 1 443 api.fastmail.com.", "Autodiscover"},
+		},
+		Setup: []string{
+			"Add {{.Domain}} in Fastmail under Settings → Domains",
+		},
+	},
+	"protonmail": {
+		Name:  "protonmail",
+		Title: "Proton Mail",
+		MX: []EmailMX{
+			{10, "mail.protonmail.ch."},
+			{20, "mailsec.protonmail.ch."},
+		},
+		SPF: "_spf.protonmail.ch",
+		Setup: []string{
+			"Add {{.Domain}} in Proton Mail under Settings → Domain names",
+			"Add the verification record it shows:",
+			"  morpheus dns record create {{.Domain}} TXT \"protonmail-verification=<code>\"",
+			"Add the three DKIM CNAME records it shows (their targets are specific to your account):",
+			"  morpheus dns record create protonmail._domainkey.{{.Domain}} CNAME <target>",
+			"  (and protonmail2._domainkey, protonmail3._domainkey)",
+		},
+	},
+	"migadu": {
+		Name:  "migadu",
+		Title: "Migadu",
+		MX: []EmailMX{
+			{10, "aspmx1.migadu.com."},
+			{20, "aspmx2.migadu.com."},
+		},
+		SPF: "spf.migadu.com",
+		Records: []EmailRecordTemplate{
+			{"key1._domainkey", RecordTypeCNAME, "key1.{{.Domain}}._domainkey.migadu.com.", "DKIM"},
+			{"key2._domainkey", RecordTypeCNAME, "key2.{{.Domain}}._domainkey.migadu.com.", "DKIM"},
+			{"key3._domainkey", RecordTypeCNAME, "key3.{{.Domain}}._domainkey.migadu.com.", "DKIM"},
+			{"autoconfig", RecordTypeCNAME, "autoconfig.migadu.com.", "Autodiscover"},
+			{"_autodiscover._tcp", RecordTypeSRV, "0 1 443 autodiscover.migadu.com.", "Autodiscover"},
+		},
+		Setup: []string{
+			"Add {{.Domain}} in the Migadu admin panel",
+			"Add the verification record it shows:",
+			"  morpheus dns record create {{.Domain}} TXT \"hosted-email-verify=<code>\"",
+		},
+	},
+}
+
+// GetEmailProvider returns an email provider by name.
+// Returns an error if the provider is not found.
+func GetEmailProvider(name string) (*EmailProvider, error) {
+	provider, ok := emailProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("email provider %q not found, available providers: %s", name, strings.Join(ListEmailProviderNames(), ", "))
+	}
+	return &provider, nil
+}
+
+// ListEmailProviderNames returns the names of all email providers, sorted
+func ListEmailProviderNames() []string {
+	names := make([]string, 0, len(emailProviders))
+	for name := range emailProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ExpandEmailTemplate replaces the placeholders of an email provider
+// template with the values for domain
+func ExpandEmailTemplate(s, domain string) string {
+	s = strings.ReplaceAll(s, "{{.Domain}}", domain)
+	return strings.ReplaceAll(s, "{{.DashedDomain}}", strings.ReplaceAll(domain, ".", "-"))
+}
+
+// MXValues returns the values of the provider's MX RRSet for domain
+func (p *EmailProvider) MXValues(domain string) []string {
+	values := make([]string, len(p.MX))
+	for i, mx := range p.MX {
+		values[i] = fmt.Sprintf("%d %s", mx.Priority, ExpandEmailTemplate(mx.Server, domain))
+	}
+	return values
+}
+
+// SPFRecord returns the provider's SPF record, quoted as a TXT value
+func (p *EmailProvider) SPFRecord() string {
+	return fmt.Sprintf("\"v=spf1 include:%s ~all\"", p.SPF)
+}
+
+// RecordRequests returns the provider's records other than MX and SPF
+// for domain
+func (p *EmailProvider) RecordRequests(domain string) []CreateRecordRequest {
+	reqs := make([]CreateRecordRequest, len(p.Records))
+	for i, r := range p.Records {
+		reqs[i] = CreateRecordRequest{
+			Domain: domain,
+			Name:   ExpandEmailTemplate(r.Name, domain),
+			Type:   r.Type,
+			Value:  ExpandEmailTemplate(r.Value, domain),
+			TTL:    3600,
+		}
+	}
+	return reqs
+}
@@ -0,0 +1,47 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEmailProviders(t *testing.T) {
+	names := ListEmailProviderNames()
+	if len(names) != 5 || names[0] != "fastmail" {
+		t.Errorf("ListEmailProviderNames() = %v", names)
+	}
+	for _, name := range names {
+		p, err := GetEmailProvider(name)
+		if err != nil {
+			t.Fatalf("GetEmailProvider(%s) error = %v", name, err)
+		}
+		if p.Name != name || len(p.MX) == 0 || p.SPF == "" {
+			t.Errorf("%s: incomplete provider %+v", name, p)
+		}
+		if _, err := parseSPF(strings.Trim(p.SPFRecord(), "\"")); err != nil {
+			t.Errorf("%s: SPFRecord() = %s: %v", name, p.SPFRecord(), err)
+		}
+		for _, v := range p.MXValues("example.com") {
+			if !strings.HasSuffix(v, ".") || strings.Contains(v, "{{") {
+				t.Errorf("%s: MX value %q is not an expanded absolute name", name, v)
+			}
+		}
+		for _, r := range p.RecordRequests("example.com") {
+			if strings.Contains(r.Name+r.Value, "{{") || strings.HasSuffix(r.Name, ".") {
+				t.Errorf("%s: record %s %s %s is not expanded or not relative", name, r.Name, r.Type, r.Value)
+			}
+		}
+	}
+	if _, err := GetEmailProvider("aol"); err == nil {
+		t.Error("GetEmailProvider() of an unknown provider: expected error")
+	}
+
+	outlook, _ := GetEmailProvider("outlook365")
+	if got := outlook.MXValues("my.example.com"); got[0] != "0 my-example-com.mail.protection.outlook.com." {
+		t.Errorf("outlook365 MXValues() = %v", got)
+	}
+	fastmail, _ := GetEmailProvider("fastmail")
+	if got := fastmail.RecordRequests("example.com")[0]; got.Name != "fm1._domainkey" || got.Value != "fm1.example.com.dkim.fmhosted.com." {
+		t.Errorf("fastmail DKIM record = %s %s", got.Name, got.Value)
+	}
+}
@@ -0,0 +1,80 @@
+package hetzner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/dns"
+)
+
+// Hetzner DNS API generations, selected with dns.api in the config
+const (
+	APIAuto   = "auto"   // Try the Cloud API, fall back to the legacy API
+	APICloud  = "cloud"  // api.hetzner.cloud
+	APILegacy = "legacy" // dns.hetzner.com, until an account's zones are migrated
+)
+
+// detectTimeout bounds the probe requests of auto-detection
+const detectTimeout = 10 * time.Second
+
+// New creates a Hetzner DNS provider for the given API generation. With
+// APIAuto the token is tried against the Cloud API first; only when the
+// Cloud API rejects it and the legacy DNS API accepts it is the legacy API
+// used, so network errors never switch an account to the old API.
+func New(ctx context.Context, apiToken, api string) (dns.Provider, error) {
+	switch api {
+	case APICloud:
+		return NewProvider(apiToken)
+	case APILegacy:
+		return NewLegacyProvider(apiToken)
+	case APIAuto, "":
+	default:
+		return nil, fmt.Errorf("unsupported Hetzner DNS API: %s (supported: auto, cloud, legacy)", api)
+	}
+
+	cloud, err := NewProvider(apiToken)
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := NewLegacyProvider(apiToken)
+	if err != nil {
+		return nil, err
+	}
+	if Detect(ctx, cloud, legacy) == APILegacy {
+		return legacy, nil
+	}
+	return cloud, nil
+}
+
+// Detect returns the API generation a token belongs to: APILegacy if the
+// Cloud API rejects it as unauthorized and the legacy API accepts it,
+// APICloud otherwise
+func Detect(ctx context.Context, cloud *Provider, legacy *LegacyProvider) string {
+	ctx, cancel := context.WithTimeout(ctx, detectTimeout)
+	defer cancel()
+
+	if status, err := cloud.probe(ctx); err != nil || (status != http.StatusUnauthorized && status != http.StatusForbidden) {
+		return APICloud
+	}
+	if status, err := legacy.do(ctx, "GET", "/zones?per_page=1", nil, nil); err == nil && status == http.StatusOK {
+		return APILegacy
+	}
+	return APICloud
+}
+
+// probe lists one zone and returns the status code of the Cloud API
+func (p *Provider) probe(ctx context.Context) (int, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.endpoint+"/zones?per_page=1", nil)
+	if err != nil {
+		return 0, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiToken)
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
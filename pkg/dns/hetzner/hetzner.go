@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
@@ -21,13 +22,21 @@ const (
 // Provider implements the DNS Provider interface for Hetzner DNS
 type Provider struct {
 	apiToken string
+	endpoint string
 	client   *http.Client
 	// Cache zone IDs to avoid repeated lookups (zone name -> zone ID)
 	zoneCache map[string]int64
 }
 
-// NewProvider creates a new Hetzner DNS provider
+// NewProvider creates a new Hetzner DNS provider. HCLOUD_ENDPOINT, if set,
+// replaces the Cloud API endpoint, e.g. to point it at a mock.
 func NewProvider(apiToken string) (*Provider, error) {
+	return NewProviderWithEndpoint(apiToken, os.Getenv("HCLOUD_ENDPOINT"))
+}
+
+// NewProviderWithEndpoint creates a Hetzner DNS provider that talks to the
+// Cloud API at endpoint, or the public API if it is empty
+func NewProviderWithEndpoint(apiToken, endpoint string) (*Provider, error) {
 	apiToken = strings.TrimSpace(apiToken)
 	// Strip quotes that may be present from env var
 	apiToken = strings.Trim(apiToken, "\"'")
@@ -35,8 +44,13 @@ func NewProvider(apiToken string) (*Provider, error) {
 		return nil, fmt.Errorf("Hetzner DNS API token is required")
 	}
 
+	if endpoint == "" {
+		endpoint = hetznerCloudAPIURL
+	}
+
 	return &Provider{
 		apiToken:  apiToken,
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
 		client:    &http.Client{Timeout: 30 * time.Second},
 		zoneCache: make(map[string]int64),
 	}, nil
@@ -76,7 +90,7 @@ func (p *Provider) CreateRecord(ctx context.Context, req dns.CreateRecordRequest
 
 	// Create RRSet via POST to /rrsets
 	httpReq, err := http.NewRequestWithContext(ctx, "POST",
-		hetznerCloudAPIURL+"/zones/"+zoneID+"/rrsets",
+		p.endpoint+"/zones/"+zoneID+"/rrsets",
 		bytes.NewReader(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -106,6 +120,103 @@ func (p *Provider) CreateRecord(ctx context.Context, req dns.CreateRecordRequest
 	}, nil
 }
 
+// UpdateRecord replaces the values of an existing RRSet with the record's
+// value, and changes its TTL if one is given and it differs
+func (p *Provider) UpdateRecord(ctx context.Context, req dns.CreateRecordRequest) (*dns.Record, error) {
+	zoneID, err := p.getZoneID(ctx, req.Domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zone: %w", err)
+	}
+	rrset, err := p.getRRSet(ctx, zoneID, req.Name, string(req.Type))
+	if err != nil {
+		return nil, err
+	}
+	if rrset == nil {
+		return nil, fmt.Errorf("record not found: %s %s", req.Name, req.Type)
+	}
+	return p.updateRRSet(ctx, req, rrset)
+}
+
+// UpsertRecord creates a record, or updates the RRSet of its name and type
+// if it exists. Nothing is changed if the RRSet already holds just the
+// record's value with its TTL.
+func (p *Provider) UpsertRecord(ctx context.Context, req dns.CreateRecordRequest) (*dns.Record, error) {
+	zoneID, err := p.getZoneID(ctx, req.Domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zone: %w", err)
+	}
+	rrset, err := p.getRRSet(ctx, zoneID, req.Name, string(req.Type))
+	if err != nil {
+		return nil, err
+	}
+	if rrset == nil {
+		return p.CreateRecord(ctx, req)
+	}
+	return p.updateRRSet(ctx, req, rrset)
+}
+
+// updateRRSet makes an existing RRSet hold just the record of req, with
+// as few calls as needed
+func (p *Provider) updateRRSet(ctx context.Context, req dns.CreateRecordRequest, rrset *hetznerRRSet) (*dns.Record, error) {
+	ttl := 0
+	if rrset.TTL != nil {
+		ttl = *rrset.TTL
+	}
+	if req.TTL != 0 && req.TTL != ttl {
+		if err := p.ChangeTTL(ctx, req.Domain, req.Name, string(req.Type), req.TTL); err != nil {
+			return nil, err
+		}
+		ttl = req.TTL
+	}
+	if len(rrset.Records) != 1 || rrset.Records[0].Value != req.Value {
+		if err := p.SetRecords(ctx, req.Domain, req.Name, string(req.Type), []string{req.Value}); err != nil {
+			return nil, err
+		}
+	}
+
+	return &dns.Record{
+		ID:     fmt.Sprintf("%s-%s", req.Name, req.Type),
+		Domain: req.Domain,
+		Name:   req.Name,
+		Type:   req.Type,
+		Value:  req.Value,
+		TTL:    ttl,
+	}, nil
+}
+
+// getRRSet returns an RRSet of a zone, or nil if it does not exist
+func (p *Provider) getRRSet(ctx context.Context, zoneID, name, recordType string) (*hetznerRRSet, error) {
+	rrsetID := fmt.Sprintf("%s/%s", name, recordType)
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.endpoint+"/zones/"+zoneID+"/rrsets/"+rrsetID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiToken)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rrset: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to get rrset: status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var result struct {
+		RRSet hetznerRRSet `json:"rrset"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse rrset response: %w", err)
+	}
+	return &result.RRSet, nil
+}
+
 // CreateRRSet creates an RRSet with multiple records (e.g., multiple MX records)
 func (p *Provider) CreateRRSet(ctx context.Context, domain, name, recordType string, ttl int, records []map[string]interface{}) error {
 	// Get zone ID for the domain
@@ -129,7 +240,7 @@ func (p *Provider) CreateRRSet(ctx context.Context, domain, name, recordType str
 
 	// Create RRSet via POST to /rrsets
 	httpReq, err := http.NewRequestWithContext(ctx, "POST",
-		hetznerCloudAPIURL+"/zones/"+zoneID+"/rrsets",
+		p.endpoint+"/zones/"+zoneID+"/rrsets",
 		bytes.NewReader(jsonBody))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
@@ -152,6 +263,85 @@ func (p *Provider) CreateRRSet(ctx context.Context, domain, name, recordType str
 	return nil
 }
 
+// ChangeTTL changes the TTL of an existing RRSet in place
+func (p *Provider) ChangeTTL(ctx context.Context, domain, name, recordType string, ttl int) error {
+	zoneID, err := p.getZoneID(ctx, domain)
+	if err != nil {
+		return fmt.Errorf("failed to get zone: %w", err)
+	}
+
+	jsonBody, err := json.Marshal(map[string]interface{}{"ttl": ttl})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	rrsetID := fmt.Sprintf("%s/%s", name, recordType)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST",
+		p.endpoint+"/zones/"+zoneID+"/rrsets/"+rrsetID+"/actions/change_ttl",
+		bytes.NewReader(jsonBody))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiToken)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to change ttl: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to change ttl: status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	return nil
+}
+
+// SetRecords replaces the values of an existing RRSet in one call, keeping
+// its TTL
+func (p *Provider) SetRecords(ctx context.Context, domain, name, recordType string, values []string) error {
+	zoneID, err := p.getZoneID(ctx, domain)
+	if err != nil {
+		return fmt.Errorf("failed to get zone: %w", err)
+	}
+
+	records := make([]map[string]interface{}, len(values))
+	for i, v := range values {
+		records[i] = map[string]interface{}{"value": v}
+	}
+	jsonBody, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	rrsetID := fmt.Sprintf("%s/%s", name, recordType)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST",
+		p.endpoint+"/zones/"+zoneID+"/rrsets/"+rrsetID+"/actions/set_records",
+		bytes.NewReader(jsonBody))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiToken)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to set records: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to set records: status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	return nil
+}
+
 // DeleteRecord removes a DNS record from Hetzner DNS using the Cloud API
 func (p *Provider) DeleteRecord(ctx context.Context, domain, name, recordType string) error {
 	// Get zone ID for the domain
@@ -164,7 +354,7 @@ func (p *Provider) DeleteRecord(ctx context.Context, domain, name, recordType st
 	rrsetID := fmt.Sprintf("%s/%s", name, recordType)
 
 	httpReq, err := http.NewRequestWithContext(ctx, "DELETE",
-		hetznerCloudAPIURL+"/zones/"+zoneID+"/rrsets/"+rrsetID, nil)
+		p.endpoint+"/zones/"+zoneID+"/rrsets/"+rrsetID, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -182,7 +372,8 @@ func (p *Provider) DeleteRecord(ctx context.Context, domain, name, recordType st
 		return nil
 	}
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+	// Cloud API returns 201 with async action, 200/204 for immediate success
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("failed to delete record: status %d: %s", resp.StatusCode, string(bodyBytes))
 	}
@@ -253,7 +444,7 @@ func (p *Provider) CreateZone(ctx context.Context, req dns.CreateZoneRequest) (*
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", hetznerCloudAPIURL+"/zones", bytes.NewReader(jsonBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.endpoint+"/zones", bytes.NewReader(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -302,7 +493,7 @@ func (p *Provider) DeleteZone(ctx context.Context, zoneName string) error {
 		return nil
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "DELETE", hetznerCloudAPIURL+"/zones/"+zone.ID, nil)
+	httpReq, err := http.NewRequestWithContext(ctx, "DELETE", p.endpoint+"/zones/"+zone.ID, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -345,7 +536,7 @@ func (p *Provider) GetZone(ctx context.Context, zoneName string) (*dns.Zone, err
 
 // ListZones lists all DNS zones in Hetzner DNS
 func (p *Provider) ListZones(ctx context.Context) ([]*dns.Zone, error) {
-	httpReq, err := http.NewRequestWithContext(ctx, "GET", hetznerCloudAPIURL+"/zones", nil)
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.endpoint+"/zones", nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -386,6 +577,117 @@ func (p *Provider) ListZones(ctx context.Context) ([]*dns.Zone, error) {
 	return zones, nil
 }
 
+// GetDNSSEC returns whether a zone is signed, its key signing keys and the
+// SHA-256 DS records for them
+func (p *Provider) GetDNSSEC(ctx context.Context, zone string) (*dns.DNSSECStatus, error) {
+	zoneID, err := p.getZoneID(ctx, zone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zone: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.endpoint+"/zones/"+zoneID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiToken)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zone: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to get zone: status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var result struct {
+		Zone hetznerZone `json:"zone"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse zone response: %w", err)
+	}
+	// getZoneID also finds the zone of a subdomain, which is not what is signed
+	if result.Zone.Name != zone {
+		return nil, fmt.Errorf("no zone found for domain: %s (it is part of %s)", zone, result.Zone.Name)
+	}
+
+	status := &dns.DNSSECStatus{Enabled: result.Zone.DNSSEC.Enabled}
+	for _, k := range result.Zone.DNSSEC.DNSKEYs {
+		key := dns.DNSKEY{Flags: k.Flags, Protocol: k.Protocol, Algorithm: k.Algorithm, PublicKey: k.PublicKey}
+		if !key.IsKSK() {
+			continue
+		}
+		ds, err := key.ToDS(result.Zone.Name, dns.DigestSHA256)
+		if err != nil {
+			return nil, err
+		}
+		status.DNSKEYs = append(status.DNSKEYs, key)
+		status.DS = append(status.DS, *ds)
+	}
+	return status, nil
+}
+
+// EnableDNSSEC signs a zone, unless it is signed already, and returns its
+// DS records
+func (p *Provider) EnableDNSSEC(ctx context.Context, zone string) (*dns.DNSSECStatus, error) {
+	status, err := p.GetDNSSEC(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	if status.Enabled {
+		return status, nil
+	}
+	if err := p.zoneAction(ctx, zone, "enable_dnssec"); err != nil {
+		return nil, err
+	}
+	return p.GetDNSSEC(ctx, zone)
+}
+
+// DisableDNSSEC stops signing a zone; disabling it on an unsigned zone is
+// not an error
+func (p *Provider) DisableDNSSEC(ctx context.Context, zone string) error {
+	status, err := p.GetDNSSEC(ctx, zone)
+	if err != nil {
+		return err
+	}
+	if !status.Enabled {
+		return nil
+	}
+	return p.zoneAction(ctx, zone, "disable_dnssec")
+}
+
+// zoneAction runs an action on a zone, e.g. enable_dnssec
+func (p *Provider) zoneAction(ctx context.Context, zone, action string) error {
+	zoneID, err := p.getZoneID(ctx, zone)
+	if err != nil {
+		return fmt.Errorf("failed to get zone: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST",
+		p.endpoint+"/zones/"+zoneID+"/actions/"+action, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiToken)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", strings.ReplaceAll(action, "_", " "), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to %s: status %d: %s", strings.ReplaceAll(action, "_", " "), resp.StatusCode, string(bodyBytes))
+	}
+
+	return nil
+}
+
 // getZoneID returns the zone ID for a domain, using cache if available
 func (p *Provider) getZoneID(ctx context.Context, domain string) (string, error) {
 	// Check cache first
@@ -394,7 +696,7 @@ func (p *Provider) getZoneID(ctx context.Context, domain string) (string, error)
 	}
 
 	// List all zones and find the matching one
-	httpReq, err := http.NewRequestWithContext(ctx, "GET", hetznerCloudAPIURL+"/zones", nil)
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.endpoint+"/zones", nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
@@ -443,7 +745,7 @@ func (p *Provider) getZoneID(ctx context.Context, domain string) (string, error)
 // listRecordsByZone lists all records in a zone using the new Cloud API RRSets endpoint
 func (p *Provider) listRecordsByZone(ctx context.Context, zoneID string) ([]hetznerRecord, error) {
 	// New Cloud API uses /zones/{id}/rrsets for record management
-	httpReq, err := http.NewRequestWithContext(ctx, "GET", hetznerCloudAPIURL+"/zones/"+zoneID+"/rrsets", nil)
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", p.endpoint+"/zones/"+zoneID+"/rrsets", nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -472,13 +774,18 @@ func (p *Provider) listRecordsByZone(ctx context.Context, zoneID string) ([]hetz
 	var records []hetznerRecord
 	for _, rrset := range result.RRSets {
 		for _, rec := range rrset.Records {
+			// TTL is set per RRSet in the Cloud API
+			ttl := rec.TTL
+			if ttl == 0 && rrset.TTL != nil {
+				ttl = *rrset.TTL
+			}
 			records = append(records, hetznerRecord{
 				ID:     fmt.Sprintf("%s-%s", rrset.Name, rrset.Type),
 				ZoneID: zoneID,
 				Name:   rrset.Name,
 				Type:   rrset.Type,
 				Value:  rec.Value,
-				TTL:    rec.TTL,
+				TTL:    ttl,
 			})
 		}
 	}
@@ -494,6 +801,18 @@ type hetznerZone struct {
 	Mode                     string                   `json:"mode"`
 	Status                   string                   `json:"status"`
 	AuthoritativeNameservers authoritativeNameservers `json:"authoritative_nameservers"`
+	DNSSEC                   hetznerDNSSEC            `json:"dnssec"`
+}
+
+// hetznerDNSSEC holds the DNSSEC state of a zone from Cloud API
+type hetznerDNSSEC struct {
+	Enabled bool `json:"enabled"`
+	DNSKEYs []struct {
+		Flags     uint16 `json:"flags"`
+		Protocol  uint8  `json:"protocol"`
+		Algorithm uint8  `json:"algorithm"`
+		PublicKey string `json:"public_key"`
+	} `json:"dnskeys"`
 }
 
 // authoritativeNameservers holds nameserver info from Cloud API
@@ -505,6 +824,7 @@ type authoritativeNameservers struct {
 type hetznerRRSet struct {
 	Name    string           `json:"name"`
 	Type    string           `json:"type"`
+	TTL     *int             `json:"ttl"` // nil means the zone default applies
 	Records []hetznerRRValue `json:"records"`
 }
 
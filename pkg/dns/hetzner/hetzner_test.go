@@ -0,0 +1,208 @@
+package hetzner
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/nimsforest/morpheus/internal/hetznermock"
+	"github.com/nimsforest/morpheus/pkg/dns"
+)
+
+func newTestProvider(t *testing.T) (*Provider, *hetznermock.Server) {
+	t.Helper()
+	mock := hetznermock.NewServer()
+	t.Cleanup(mock.Close)
+	mock.Token = "test-token"
+
+	p, err := NewProviderWithEndpoint("test-token", mock.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, mock
+}
+
+func TestZones(t *testing.T) {
+	p, _ := newTestProvider(t)
+	ctx := context.Background()
+
+	zone, err := p.CreateZone(ctx, dns.CreateZoneRequest{Name: "example.com"})
+	if err != nil {
+		t.Fatalf("CreateZone() error = %v", err)
+	}
+	if zone.Name != "example.com" || zone.TTL != 86400 || len(zone.Nameservers) == 0 {
+		t.Errorf("CreateZone() = %+v", zone)
+	}
+	if _, err := p.CreateZone(ctx, dns.CreateZoneRequest{Name: "example.com"}); err == nil {
+		t.Error("CreateZone() for an existing zone succeeded")
+	}
+
+	got, err := p.GetZone(ctx, "example.com")
+	if err != nil || got == nil || got.ID != zone.ID {
+		t.Fatalf("GetZone() = %+v, %v", got, err)
+	}
+
+	if err := p.DeleteZone(ctx, "example.com"); err != nil {
+		t.Fatalf("DeleteZone() error = %v", err)
+	}
+	if got, _ := p.GetZone(ctx, "example.com"); got != nil {
+		t.Errorf("GetZone() after delete = %+v", got)
+	}
+	// Deleting a missing zone is not an error
+	if err := p.DeleteZone(ctx, "example.com"); err != nil {
+		t.Errorf("DeleteZone() of a missing zone error = %v", err)
+	}
+}
+
+func TestRecords(t *testing.T) {
+	p, mock := newTestProvider(t)
+	ctx := context.Background()
+	mock.AddZone("example.com")
+
+	_, err := p.CreateRecord(ctx, dns.CreateRecordRequest{
+		Domain: "node1.example.com", Name: "node1", Type: dns.RecordTypeAAAA, Value: "2001:db8::1",
+	})
+	if err != nil {
+		t.Fatalf("CreateRecord() error = %v", err)
+	}
+	err = p.CreateRRSet(ctx, "example.com", "@", "MX", 3600, []map[string]interface{}{
+		{"value": "10 mx1.example.com."},
+		{"value": "20 mx2.example.com."},
+	})
+	if err != nil {
+		t.Fatalf("CreateRRSet() error = %v", err)
+	}
+
+	records, err := p.ListRecords(ctx, "example.com")
+	if err != nil {
+		t.Fatalf("ListRecords() error = %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("ListRecords() returned %d records, want 3: %+v", len(records), records)
+	}
+
+	record, err := p.GetRecord(ctx, "example.com", "node1", "AAAA")
+	if err != nil || record == nil || record.Value != "2001:db8::1" || record.TTL != 300 {
+		t.Fatalf("GetRecord() = %+v, %v", record, err)
+	}
+
+	if err := p.ChangeTTL(ctx, "example.com", "node1", "AAAA", 60); err != nil {
+		t.Fatalf("ChangeTTL() error = %v", err)
+	}
+	if record, _ := p.GetRecord(ctx, "example.com", "node1", "AAAA"); record == nil || record.TTL != 60 {
+		t.Errorf("GetRecord() after ChangeTTL = %+v", record)
+	}
+
+	if err := p.SetRecords(ctx, "example.com", "node1", "AAAA", []string{"2001:db8::2"}); err != nil {
+		t.Fatalf("SetRecords() error = %v", err)
+	}
+	if record, _ := p.GetRecord(ctx, "example.com", "node1", "AAAA"); record == nil || record.Value != "2001:db8::2" || record.TTL != 60 {
+		t.Errorf("GetRecord() after SetRecords = %+v", record)
+	}
+
+	if err := p.DeleteRecord(ctx, "example.com", "node1", "AAAA"); err != nil {
+		t.Fatalf("DeleteRecord() error = %v", err)
+	}
+	if record, _ := p.GetRecord(ctx, "example.com", "node1", "AAAA"); record != nil {
+		t.Errorf("GetRecord() after delete = %+v", record)
+	}
+	// Deleting a missing record is not an error
+	if err := p.DeleteRecord(ctx, "example.com", "node1", "AAAA"); err != nil {
+		t.Errorf("DeleteRecord() of a missing record error = %v", err)
+	}
+}
+
+func TestUpsertRecord(t *testing.T) {
+	p, mock := newTestProvider(t)
+	ctx := context.Background()
+	mock.AddZone("example.com")
+
+	req := dns.CreateRecordRequest{Domain: "example.com", Name: "www", Type: dns.RecordTypeA, Value: "192.0.2.1", TTL: 300}
+	if _, err := p.UpdateRecord(ctx, req); err == nil {
+		t.Error("UpdateRecord() of a missing record succeeded")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := p.UpsertRecord(ctx, req); err != nil {
+			t.Fatalf("UpsertRecord() #%d error = %v", i+1, err)
+		}
+	}
+
+	req.Value = "192.0.2.2"
+	req.TTL = 60
+	record, err := p.UpsertRecord(ctx, req)
+	if err != nil || record.Value != "192.0.2.2" || record.TTL != 60 {
+		t.Fatalf("UpsertRecord() = %+v, %v", record, err)
+	}
+	records, _ := p.ListRecords(ctx, "example.com")
+	if len(records) != 1 || records[0].Value != "192.0.2.2" || records[0].TTL != 60 {
+		t.Errorf("records after upsert = %+v", records)
+	}
+
+	// Without a TTL the RRSet keeps its own
+	req.Value = "192.0.2.3"
+	req.TTL = 0
+	if record, err := p.UpdateRecord(ctx, req); err != nil || record.TTL != 60 {
+		t.Errorf("UpdateRecord() = %+v, %v", record, err)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	p, mock := newTestProvider(t)
+	ctx := context.Background()
+
+	if _, err := p.ListRecords(ctx, "example.com"); err == nil {
+		t.Error("ListRecords() without a zone succeeded")
+	}
+
+	mock.AddZone("example.com")
+	mock.FailNext("POST", "/zones/", http.StatusTooManyRequests, "rate_limit_exceeded")
+	_, err := p.CreateRecord(ctx, dns.CreateRecordRequest{Domain: "example.com", Name: "www", Type: dns.RecordTypeA, Value: "203.0.113.1"})
+	if err == nil {
+		t.Error("CreateRecord() succeeded despite a 429")
+	}
+
+	bad, _ := NewProviderWithEndpoint("wrong-token", mock.URL)
+	if _, err := bad.ListZones(ctx); err == nil {
+		t.Error("ListZones() with a wrong token succeeded")
+	}
+}
+
+func TestDNSSEC(t *testing.T) {
+	p, mock := newTestProvider(t)
+	ctx := context.Background()
+	mock.AddZone("example.com")
+
+	status, err := p.GetDNSSEC(ctx, "example.com")
+	if err != nil || status.Enabled {
+		t.Fatalf("GetDNSSEC() of an unsigned zone = %+v, %v", status, err)
+	}
+
+	status, err = p.EnableDNSSEC(ctx, "example.com")
+	if err != nil {
+		t.Fatalf("EnableDNSSEC() error = %v", err)
+	}
+	if !status.Enabled || len(status.DNSKEYs) != 1 || len(status.DS) != 1 {
+		t.Fatalf("EnableDNSSEC() = %+v", status)
+	}
+	ds := status.DS[0]
+	if ds.DigestType != dns.DigestSHA256 || ds.Algorithm != 13 || !ds.Matches("example.com", status.DNSKEYs[0]) {
+		t.Errorf("DS record = %s", ds)
+	}
+	// Enabling it again keeps the key
+	if again, err := p.EnableDNSSEC(ctx, "example.com"); err != nil || again.DS[0] != ds {
+		t.Errorf("EnableDNSSEC() of a signed zone = %+v, %v", again, err)
+	}
+	if _, err := p.GetDNSSEC(ctx, "www.example.com"); err == nil {
+		t.Error("GetDNSSEC() of a name inside a zone: expected error")
+	}
+
+	if err := p.DisableDNSSEC(ctx, "example.com"); err != nil {
+		t.Fatalf("DisableDNSSEC() error = %v", err)
+	}
+	if status, _ := p.GetDNSSEC(ctx, "example.com"); status.Enabled || len(status.DS) != 0 {
+		t.Errorf("GetDNSSEC() after disable = %+v", status)
+	}
+	if err := p.DisableDNSSEC(ctx, "example.com"); err != nil {
+		t.Errorf("DisableDNSSEC() of an unsigned zone error = %v", err)
+	}
+}
@@ -0,0 +1,461 @@
+package hetzner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/dns"
+)
+
+const (
+	// Legacy DNS API URL, for accounts whose zones are not yet migrated to
+	// the Cloud API
+	hetznerLegacyDNSAPIURL = "https://dns.hetzner.com/api/v1"
+)
+
+// LegacyProvider implements the DNS Provider interface for the legacy
+// Hetzner DNS API (dns.hetzner.com). It addresses records one by one, so
+// RRSet operations act on all records of a name and type.
+type LegacyProvider struct {
+	apiToken string
+	endpoint string
+	client   *http.Client
+	// Cache zone IDs to avoid repeated lookups (zone name -> zone ID)
+	zoneCache map[string]string
+}
+
+// Ensure LegacyProvider implements the DNS interfaces
+var (
+	_ dns.Provider     = (*LegacyProvider)(nil)
+	_ dns.RRSetCreator = (*LegacyProvider)(nil)
+	_ dns.TTLChanger   = (*LegacyProvider)(nil)
+	_ dns.RecordSetter = (*LegacyProvider)(nil)
+)
+
+// NewLegacyProvider creates a provider for the legacy DNS API.
+// HETZNER_DNS_ENDPOINT, if set, replaces its endpoint.
+func NewLegacyProvider(apiToken string) (*LegacyProvider, error) {
+	return NewLegacyProviderWithEndpoint(apiToken, os.Getenv("HETZNER_DNS_ENDPOINT"))
+}
+
+// NewLegacyProviderWithEndpoint creates a provider for the legacy DNS API
+// at endpoint, or the public API if it is empty
+func NewLegacyProviderWithEndpoint(apiToken, endpoint string) (*LegacyProvider, error) {
+	apiToken = strings.Trim(strings.TrimSpace(apiToken), "\"'")
+	if apiToken == "" {
+		return nil, fmt.Errorf("Hetzner DNS API token is required")
+	}
+	if endpoint == "" {
+		endpoint = hetznerLegacyDNSAPIURL
+	}
+	return &LegacyProvider{
+		apiToken:  apiToken,
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		client:    &http.Client{Timeout: 30 * time.Second},
+		zoneCache: make(map[string]string),
+	}, nil
+}
+
+// legacyZone is a zone in the legacy DNS API
+type legacyZone struct {
+	ID   string   `json:"id"`
+	Name string   `json:"name"`
+	TTL  int      `json:"ttl"`
+	NS   []string `json:"ns"`
+}
+
+// legacyRecord is a record in the legacy DNS API
+type legacyRecord struct {
+	ID     string `json:"id,omitempty"`
+	ZoneID string `json:"zone_id"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	TTL    int    `json:"ttl,omitempty"` // 0 means the zone default applies
+}
+
+// legacyPagination is the pagination info of legacy list responses
+type legacyPagination struct {
+	Meta struct {
+		Pagination struct {
+			LastPage int `json:"last_page"`
+		} `json:"pagination"`
+	} `json:"meta"`
+}
+
+// do sends a request and decodes the JSON response into out (if not nil).
+// The legacy API authenticates with the Auth-API-Token header.
+func (p *LegacyProvider) do(ctx context.Context, method, path string, body, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(jsonBody)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, p.endpoint+path, reader)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Auth-API-Token", p.apiToken)
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, fmt.Errorf("status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// ListZones lists all zones
+func (p *LegacyProvider) ListZones(ctx context.Context) ([]*dns.Zone, error) {
+	var zones []*dns.Zone
+	for page := 1; ; page++ {
+		var result struct {
+			Zones []legacyZone `json:"zones"`
+			legacyPagination
+		}
+		if _, err := p.do(ctx, "GET", fmt.Sprintf("/zones?per_page=100&page=%d", page), nil, &result); err != nil {
+			return nil, fmt.Errorf("failed to list zones: %w", err)
+		}
+		for _, z := range result.Zones {
+			p.zoneCache[z.Name] = z.ID
+			zones = append(zones, &dns.Zone{ID: z.ID, Name: z.Name, TTL: z.TTL, Nameservers: z.NS})
+		}
+		if page >= result.Meta.Pagination.LastPage {
+			return zones, nil
+		}
+	}
+}
+
+// GetZone retrieves a zone by name, or nil if it does not exist
+func (p *LegacyProvider) GetZone(ctx context.Context, zoneName string) (*dns.Zone, error) {
+	var result struct {
+		Zones []legacyZone `json:"zones"`
+	}
+	status, err := p.do(ctx, "GET", "/zones?name="+url.QueryEscape(zoneName), nil, &result)
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zone: %w", err)
+	}
+	for _, z := range result.Zones {
+		if z.Name == zoneName {
+			p.zoneCache[z.Name] = z.ID
+			return &dns.Zone{ID: z.ID, Name: z.Name, TTL: z.TTL, Nameservers: z.NS}, nil
+		}
+	}
+	return nil, nil
+}
+
+// CreateZone creates a zone
+func (p *LegacyProvider) CreateZone(ctx context.Context, req dns.CreateZoneRequest) (*dns.Zone, error) {
+	ttl := req.TTL
+	if ttl == 0 {
+		ttl = 86400 // 24 hours default
+	}
+	var result struct {
+		Zone legacyZone `json:"zone"`
+	}
+	if _, err := p.do(ctx, "POST", "/zones", map[string]interface{}{"name": req.Name, "ttl": ttl}, &result); err != nil {
+		return nil, fmt.Errorf("failed to create zone: %w", err)
+	}
+	p.zoneCache[result.Zone.Name] = result.Zone.ID
+	return &dns.Zone{ID: result.Zone.ID, Name: result.Zone.Name, TTL: result.Zone.TTL, Nameservers: result.Zone.NS}, nil
+}
+
+// DeleteZone deletes a zone; deleting a missing zone is not an error
+func (p *LegacyProvider) DeleteZone(ctx context.Context, zoneName string) error {
+	zone, err := p.GetZone(ctx, zoneName)
+	if err != nil {
+		return err
+	}
+	if zone == nil {
+		return nil
+	}
+	if status, err := p.do(ctx, "DELETE", "/zones/"+zone.ID, nil, nil); err != nil && status != http.StatusNotFound {
+		return fmt.Errorf("failed to delete zone: %w", err)
+	}
+	delete(p.zoneCache, zoneName)
+	return nil
+}
+
+// getZoneID returns the ID of the zone a domain belongs to
+func (p *LegacyProvider) getZoneID(ctx context.Context, domain string) (string, error) {
+	if id, ok := p.zoneCache[domain]; ok {
+		return id, nil
+	}
+	zones, err := p.ListZones(ctx)
+	if err != nil {
+		return "", err
+	}
+	var best *dns.Zone
+	for _, z := range zones {
+		if (domain == z.Name || strings.HasSuffix(domain, "."+z.Name)) && (best == nil || len(z.Name) > len(best.Name)) {
+			best = z
+		}
+	}
+	if best == nil {
+		return "", fmt.Errorf("no zone found for domain: %s", domain)
+	}
+	p.zoneCache[domain] = best.ID
+	return best.ID, nil
+}
+
+// listRecords returns all records of a zone
+func (p *LegacyProvider) listRecords(ctx context.Context, zoneID string) ([]legacyRecord, error) {
+	var records []legacyRecord
+	for page := 1; ; page++ {
+		var result struct {
+			Records []legacyRecord `json:"records"`
+			legacyPagination
+		}
+		path := fmt.Sprintf("/records?zone_id=%s&per_page=100&page=%d", url.QueryEscape(zoneID), page)
+		if _, err := p.do(ctx, "GET", path, nil, &result); err != nil {
+			return nil, fmt.Errorf("failed to list records: %w", err)
+		}
+		records = append(records, result.Records...)
+		if page >= result.Meta.Pagination.LastPage {
+			return records, nil
+		}
+	}
+}
+
+// rrset returns the records of a name and type
+func (p *LegacyProvider) rrset(ctx context.Context, zoneID, name, recordType string) ([]legacyRecord, error) {
+	records, err := p.listRecords(ctx, zoneID)
+	if err != nil {
+		return nil, err
+	}
+	var result []legacyRecord
+	for _, r := range records {
+		if r.Name == name && r.Type == recordType {
+			result = append(result, r)
+		}
+	}
+	return result, nil
+}
+
+// ListRecords lists all records of a zone
+func (p *LegacyProvider) ListRecords(ctx context.Context, domain string) ([]*dns.Record, error) {
+	zoneID, err := p.getZoneID(ctx, domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zone: %w", err)
+	}
+	records, err := p.listRecords(ctx, zoneID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*dns.Record, len(records))
+	for i, r := range records {
+		result[i] = &dns.Record{ID: r.ID, Domain: domain, Name: r.Name, Type: dns.RecordType(r.Type), Value: r.Value, TTL: r.TTL}
+	}
+	return result, nil
+}
+
+// GetRecord retrieves a record by name and type, or nil if there is none
+func (p *LegacyProvider) GetRecord(ctx context.Context, domain, name, recordType string) (*dns.Record, error) {
+	records, err := p.ListRecords(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		if r.Name == name && string(r.Type) == recordType {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+// CreateRecord creates a record
+func (p *LegacyProvider) CreateRecord(ctx context.Context, req dns.CreateRecordRequest) (*dns.Record, error) {
+	zoneID, err := p.getZoneID(ctx, req.Domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zone: %w", err)
+	}
+	ttl := req.TTL
+	if ttl == 0 {
+		ttl = 300 // 5 minutes default
+	}
+	var result struct {
+		Record legacyRecord `json:"record"`
+	}
+	body := legacyRecord{ZoneID: zoneID, Type: string(req.Type), Name: req.Name, Value: req.Value, TTL: ttl}
+	if _, err := p.do(ctx, "POST", "/records", body, &result); err != nil {
+		return nil, fmt.Errorf("failed to create record: %w", err)
+	}
+	return &dns.Record{ID: result.Record.ID, Domain: req.Domain, Name: req.Name, Type: req.Type, Value: req.Value, TTL: ttl}, nil
+}
+
+// UpdateRecord replaces the records of the name and type, which must
+// exist, with the record's value
+func (p *LegacyProvider) UpdateRecord(ctx context.Context, req dns.CreateRecordRequest) (*dns.Record, error) {
+	zoneID, err := p.getZoneID(ctx, req.Domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zone: %w", err)
+	}
+	existing, err := p.rrset(ctx, zoneID, req.Name, string(req.Type))
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) == 0 {
+		return nil, fmt.Errorf("record not found: %s %s", req.Name, req.Type)
+	}
+	return p.updateRRSet(ctx, req, existing)
+}
+
+// UpsertRecord creates a record, or updates the records of its name and
+// type if there are any
+func (p *LegacyProvider) UpsertRecord(ctx context.Context, req dns.CreateRecordRequest) (*dns.Record, error) {
+	zoneID, err := p.getZoneID(ctx, req.Domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zone: %w", err)
+	}
+	existing, err := p.rrset(ctx, zoneID, req.Name, string(req.Type))
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) == 0 {
+		return p.CreateRecord(ctx, req)
+	}
+	return p.updateRRSet(ctx, req, existing)
+}
+
+// updateRRSet makes existing records of a name and type hold just the
+// record of req: the first is updated in place, the others deleted
+func (p *LegacyProvider) updateRRSet(ctx context.Context, req dns.CreateRecordRequest, existing []legacyRecord) (*dns.Record, error) {
+	first := existing[0]
+	ttl := req.TTL
+	if ttl == 0 {
+		ttl = first.TTL
+	}
+	if first.Value != req.Value || first.TTL != ttl {
+		first.Value = req.Value
+		first.TTL = ttl
+		if _, err := p.do(ctx, "PUT", "/records/"+first.ID, first, nil); err != nil {
+			return nil, fmt.Errorf("failed to update record: %w", err)
+		}
+	}
+	for _, r := range existing[1:] {
+		if err := p.deleteRecordID(ctx, r.ID); err != nil {
+			return nil, err
+		}
+	}
+	return &dns.Record{ID: first.ID, Domain: req.Domain, Name: req.Name, Type: req.Type, Value: req.Value, TTL: ttl}, nil
+}
+
+// DeleteRecord removes all records of a name and type
+func (p *LegacyProvider) DeleteRecord(ctx context.Context, domain, name, recordType string) error {
+	zoneID, err := p.getZoneID(ctx, domain)
+	if err != nil {
+		return fmt.Errorf("failed to get zone: %w", err)
+	}
+	existing, err := p.rrset(ctx, zoneID, name, recordType)
+	if err != nil {
+		return err
+	}
+	for _, r := range existing {
+		if err := p.deleteRecordID(ctx, r.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *LegacyProvider) deleteRecordID(ctx context.Context, id string) error {
+	if status, err := p.do(ctx, "DELETE", "/records/"+id, nil, nil); err != nil && status != http.StatusNotFound {
+		return fmt.Errorf("failed to delete record: %w", err)
+	}
+	return nil
+}
+
+// CreateRRSet creates several records of a name and type in one call
+func (p *LegacyProvider) CreateRRSet(ctx context.Context, domain, name, recordType string, ttl int, records []map[string]interface{}) error {
+	zoneID, err := p.getZoneID(ctx, domain)
+	if err != nil {
+		return fmt.Errorf("failed to get zone: %w", err)
+	}
+	values := make([]string, len(records))
+	for i, r := range records {
+		values[i], _ = r["value"].(string)
+	}
+	return p.createRecords(ctx, zoneID, name, recordType, ttl, values)
+}
+
+func (p *LegacyProvider) createRecords(ctx context.Context, zoneID, name, recordType string, ttl int, values []string) error {
+	bulk := make([]legacyRecord, len(values))
+	for i, v := range values {
+		bulk[i] = legacyRecord{ZoneID: zoneID, Type: recordType, Name: name, Value: v, TTL: ttl}
+	}
+	if _, err := p.do(ctx, "POST", "/records/bulk", map[string]interface{}{"records": bulk}, nil); err != nil {
+		return fmt.Errorf("failed to create records: %w", err)
+	}
+	return nil
+}
+
+// ChangeTTL changes the TTL of all records of a name and type
+func (p *LegacyProvider) ChangeTTL(ctx context.Context, domain, name, recordType string, ttl int) error {
+	zoneID, err := p.getZoneID(ctx, domain)
+	if err != nil {
+		return fmt.Errorf("failed to get zone: %w", err)
+	}
+	existing, err := p.rrset(ctx, zoneID, name, recordType)
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		return fmt.Errorf("record not found: %s %s", name, recordType)
+	}
+	for _, r := range existing {
+		r.TTL = ttl
+		if _, err := p.do(ctx, "PUT", "/records/"+r.ID, r, nil); err != nil {
+			return fmt.Errorf("failed to change ttl: %w", err)
+		}
+	}
+	return nil
+}
+
+// SetRecords replaces the values of the records of a name and type,
+// keeping their TTL
+func (p *LegacyProvider) SetRecords(ctx context.Context, domain, name, recordType string, values []string) error {
+	zoneID, err := p.getZoneID(ctx, domain)
+	if err != nil {
+		return fmt.Errorf("failed to get zone: %w", err)
+	}
+	existing, err := p.rrset(ctx, zoneID, name, recordType)
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		return fmt.Errorf("record not found: %s %s", name, recordType)
+	}
+	for _, r := range existing {
+		if err := p.deleteRecordID(ctx, r.ID); err != nil {
+			return err
+		}
+	}
+	return p.createRecords(ctx, zoneID, name, recordType, existing[0].TTL, values)
+}
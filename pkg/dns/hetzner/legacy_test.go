@@ -0,0 +1,199 @@
+package hetzner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nimsforest/morpheus/internal/hetznermock"
+	"github.com/nimsforest/morpheus/pkg/dns"
+)
+
+// fakeLegacyAPI serves the parts of the legacy DNS API the provider uses
+type fakeLegacyAPI struct {
+	mu      sync.Mutex
+	token   string
+	zones   []legacyZone
+	records []legacyRecord
+	nextID  int
+}
+
+func (f *fakeLegacyAPI) id() string {
+	f.nextID++
+	return fmt.Sprintf("id%d", f.nextID)
+}
+
+func (f *fakeLegacyAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Auth-API-Token") != f.token {
+		http.Error(w, `{"message":"Invalid authentication credentials"}`, http.StatusUnauthorized)
+		return
+	}
+	reply := func(v interface{}) { json.NewEncoder(w).Encode(v) }
+	path := strings.Trim(r.URL.Path, "/")
+
+	switch {
+	case r.Method == "GET" && path == "zones":
+		zones := []legacyZone{}
+		for _, z := range f.zones {
+			if name := r.URL.Query().Get("name"); name == "" || name == z.Name {
+				zones = append(zones, z)
+			}
+		}
+		reply(map[string]interface{}{"zones": zones, "meta": map[string]interface{}{"pagination": map[string]int{"last_page": 1}}})
+	case r.Method == "POST" && path == "zones":
+		var z legacyZone
+		json.NewDecoder(r.Body).Decode(&z)
+		z.ID, z.NS = f.id(), []string{"hydrogen.ns.hetzner.com"}
+		f.zones = append(f.zones, z)
+		reply(map[string]interface{}{"zone": z})
+	case r.Method == "GET" && path == "records":
+		records := []legacyRecord{}
+		for _, rec := range f.records {
+			if rec.ZoneID == r.URL.Query().Get("zone_id") {
+				records = append(records, rec)
+			}
+		}
+		reply(map[string]interface{}{"records": records})
+	case r.Method == "POST" && path == "records":
+		var rec legacyRecord
+		json.NewDecoder(r.Body).Decode(&rec)
+		rec.ID = f.id()
+		f.records = append(f.records, rec)
+		reply(map[string]interface{}{"record": rec})
+	case r.Method == "POST" && path == "records/bulk":
+		var req struct{ Records []legacyRecord }
+		json.NewDecoder(r.Body).Decode(&req)
+		for _, rec := range req.Records {
+			rec.ID = f.id()
+			f.records = append(f.records, rec)
+		}
+		reply(map[string]interface{}{"records": req.Records})
+	case r.Method == "PUT" && strings.HasPrefix(path, "records/"):
+		var rec legacyRecord
+		json.NewDecoder(r.Body).Decode(&rec)
+		for i := range f.records {
+			if f.records[i].ID == strings.TrimPrefix(path, "records/") {
+				rec.ID = f.records[i].ID
+				f.records[i] = rec
+				reply(map[string]interface{}{"record": rec})
+				return
+			}
+		}
+		http.NotFound(w, r)
+	case r.Method == "DELETE" && strings.HasPrefix(path, "records/"):
+		for i := range f.records {
+			if f.records[i].ID == strings.TrimPrefix(path, "records/") {
+				f.records = append(f.records[:i], f.records[i+1:]...)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestLegacyProvider(t *testing.T) (*LegacyProvider, *fakeLegacyAPI, string) {
+	t.Helper()
+	api := &fakeLegacyAPI{token: "legacy-token"}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	p, err := NewLegacyProviderWithEndpoint("legacy-token", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, api, server.URL
+}
+
+func TestLegacyRecords(t *testing.T) {
+	p, api, _ := newTestLegacyProvider(t)
+	ctx := context.Background()
+
+	if _, err := p.CreateZone(ctx, dns.CreateZoneRequest{Name: "example.com"}); err != nil {
+		t.Fatalf("CreateZone() error = %v", err)
+	}
+	zone, err := p.GetZone(ctx, "example.com")
+	if err != nil || zone == nil || zone.TTL != 86400 || len(zone.Nameservers) != 1 {
+		t.Fatalf("GetZone() = %+v, %v", zone, err)
+	}
+	if missing, err := p.GetZone(ctx, "example.org"); missing != nil || err != nil {
+		t.Errorf("GetZone() of a missing zone = %+v, %v", missing, err)
+	}
+
+	if err := p.CreateRRSet(ctx, "www.example.com", "www", "A", 60, []map[string]interface{}{{"value": "192.0.2.1"}, {"value": "192.0.2.2"}}); err != nil {
+		t.Fatalf("CreateRRSet() error = %v", err)
+	}
+	if _, err := p.UpsertRecord(ctx, dns.CreateRecordRequest{Domain: "example.com", Name: "www", Type: dns.RecordTypeA, Value: "192.0.2.9"}); err != nil {
+		t.Fatalf("UpsertRecord() error = %v", err)
+	}
+	if len(api.records) != 1 || api.records[0].Value != "192.0.2.9" || api.records[0].TTL != 60 {
+		t.Errorf("after UpsertRecord() records = %+v, want one 192.0.2.9 with TTL 60", api.records)
+	}
+
+	if err := p.SetRecords(ctx, "example.com", "www", "A", []string{"192.0.2.3", "192.0.2.4"}); err != nil {
+		t.Fatalf("SetRecords() error = %v", err)
+	}
+	if err := p.ChangeTTL(ctx, "example.com", "www", "A", 600); err != nil {
+		t.Fatalf("ChangeTTL() error = %v", err)
+	}
+	records, err := p.ListRecords(ctx, "example.com")
+	if err != nil || len(records) != 2 {
+		t.Fatalf("ListRecords() = %v, %v", records, err)
+	}
+	for _, r := range records {
+		if r.TTL != 600 || !strings.HasPrefix(r.Value, "192.0.2.") || r.Value == "192.0.2.9" {
+			t.Errorf("record %+v, want TTL 600 and the new values", r)
+		}
+	}
+
+	if _, err := p.UpdateRecord(ctx, dns.CreateRecordRequest{Domain: "example.com", Name: "mail", Type: dns.RecordTypeA, Value: "192.0.2.5"}); err == nil {
+		t.Error("UpdateRecord() of a missing record succeeded")
+	}
+	if err := p.DeleteRecord(ctx, "example.com", "www", "A"); err != nil {
+		t.Fatalf("DeleteRecord() error = %v", err)
+	}
+	if r, err := p.GetRecord(ctx, "example.com", "www", "A"); r != nil || err != nil {
+		t.Errorf("GetRecord() after DeleteRecord() = %+v, %v", r, err)
+	}
+}
+
+func TestNewDetectsLegacyAPI(t *testing.T) {
+	_, _, legacyURL := newTestLegacyProvider(t)
+	mock := hetznermock.NewServer()
+	t.Cleanup(mock.Close)
+	mock.Token = "cloud-token"
+	t.Setenv("HCLOUD_ENDPOINT", mock.URL)
+	t.Setenv("HETZNER_DNS_ENDPOINT", legacyURL)
+	ctx := context.Background()
+
+	tests := []struct {
+		token, api string
+		legacy     bool
+	}{
+		{"cloud-token", APIAuto, false},
+		{"legacy-token", APIAuto, true},
+		{"unknown-token", APIAuto, false}, // Rejected by both: report the Cloud API's errors
+		{"legacy-token", APICloud, false},
+		{"cloud-token", APILegacy, true},
+	}
+	for _, tt := range tests {
+		p, err := New(ctx, tt.token, tt.api)
+		if err != nil {
+			t.Fatalf("New(%s, %s) error = %v", tt.token, tt.api, err)
+		}
+		if _, legacy := p.(*LegacyProvider); legacy != tt.legacy {
+			t.Errorf("New(%s, %s) = %T, want legacy %v", tt.token, tt.api, p, tt.legacy)
+		}
+	}
+	if _, err := New(ctx, "cloud-token", "v2"); err == nil {
+		t.Error("New() with an unknown API: expected error")
+	}
+}
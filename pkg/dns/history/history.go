@@ -0,0 +1,173 @@
+// Package history records the DNS changes morpheus makes, per zone, so that
+// a zone can be inspected and rolled back to an earlier state.
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/lockfile"
+)
+
+const (
+	// journalLockWait is how long an append waits for another process
+	journalLockWait = 10 * time.Second
+	// journalLockTTL bounds how long a crashed writer can block others
+	journalLockTTL = time.Minute
+)
+
+// RRSet is the state of one name/type record set
+type RRSet struct {
+	TTL    int      `json:"ttl"`
+	Values []string `json:"values"`
+}
+
+// Equal reports whether two RRSet states are the same (nil means absent)
+func (r *RRSet) Equal(other *RRSet) bool {
+	if r == nil || other == nil {
+		return r == nil && other == nil
+	}
+	return r.TTL == other.TTL && sameValues(r.Values, other.Values)
+}
+
+// sameValues reports whether two value lists hold the same values in any order
+func sameValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// String renders the RRSet as "v1, v2 (TTL n)", or "(none)" when absent
+func (r *RRSet) String() string {
+	if r == nil {
+		return "(none)"
+	}
+	return fmt.Sprintf("%s (TTL %d)", strings.Join(r.Values, ", "), r.TTL)
+}
+
+// Change records one RRSet mutation performed by morpheus
+type Change struct {
+	ID        int       `json:"id"`
+	Zone      string    `json:"zone"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Old       *RRSet    `json:"old,omitempty"` // nil when the RRSet did not exist
+	New       *RRSet    `json:"new,omitempty"` // nil when the RRSet was deleted
+	Timestamp time.Time `json:"timestamp"`
+	Command   string    `json:"command,omitempty"`
+	Operator  string    `json:"operator,omitempty"` // Who ran the command
+}
+
+// Journal stores changes as one JSON file per zone in a directory
+type Journal struct {
+	mu       sync.Mutex
+	dir      string
+	lockWait time.Duration
+}
+
+// NewJournal returns a journal stored in dir (e.g., ~/.morpheus/dns-history)
+func NewJournal(dir string) *Journal {
+	return &Journal{dir: dir, lockWait: journalLockWait}
+}
+
+// Append assigns the next change ID for the zone and saves the change
+func (j *Journal) Append(c *Change) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	c.Zone = normalizeZone(c.Zone)
+	if err := os.MkdirAll(j.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	path := j.path(c.Zone)
+	lock, err := j.acquireLock(path)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	changes, err := readChanges(path)
+	if err != nil {
+		return err
+	}
+
+	c.ID = 1
+	if len(changes) > 0 {
+		c.ID = changes[len(changes)-1].ID + 1
+	}
+	if c.Timestamp.IsZero() {
+		c.Timestamp = time.Now()
+	}
+	changes = append(changes, *c)
+
+	data, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// List returns all recorded changes for a zone, oldest first
+func (j *Journal) List(zone string) ([]Change, error) {
+	return readChanges(j.path(normalizeZone(zone)))
+}
+
+func (j *Journal) path(zone string) string {
+	return filepath.Join(j.dir, zone+".json")
+}
+
+// acquireLock takes the journal lock, waiting up to lockWait for another process
+func (j *Journal) acquireLock(path string) (*lockfile.Lock, error) {
+	deadline := time.Now().Add(j.lockWait)
+	for {
+		lock, err := lockfile.Acquire(path+".lock", "dns history write", journalLockTTL)
+		if err == nil {
+			return lock, nil
+		}
+		if !lockfile.IsLocked(err) || time.Now().After(deadline) {
+			return nil, fmt.Errorf("dns history %s is %w", path, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func readChanges(path string) ([]Change, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	var changes []Change
+	if err := json.Unmarshal(data, &changes); err != nil {
+		return nil, fmt.Errorf("failed to parse history %s: %w", path, err)
+	}
+	return changes, nil
+}
+
+func normalizeZone(zone string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(zone)), ".")
+}
@@ -0,0 +1,232 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/dns"
+)
+
+// fakeDNS is an in-memory dns.Provider keyed by "name/type"
+type fakeDNS struct {
+	rrsets map[string]*RRSet
+}
+
+func newFakeDNS() *fakeDNS {
+	return &fakeDNS{rrsets: make(map[string]*RRSet)}
+}
+
+func (f *fakeDNS) CreateRecord(ctx context.Context, req dns.CreateRecordRequest) (*dns.Record, error) {
+	key := req.Name + "/" + string(req.Type)
+	if _, exists := f.rrsets[key]; exists {
+		return nil, fmt.Errorf("rrset already exists: %s", key)
+	}
+	f.rrsets[key] = &RRSet{TTL: req.TTL, Values: []string{req.Value}}
+	return &dns.Record{Domain: req.Domain, Name: req.Name, Type: req.Type, Value: req.Value, TTL: req.TTL}, nil
+}
+
+func (f *fakeDNS) UpdateRecord(ctx context.Context, req dns.CreateRecordRequest) (*dns.Record, error) {
+	key := req.Name + "/" + string(req.Type)
+	if _, exists := f.rrsets[key]; !exists {
+		return nil, fmt.Errorf("rrset not found: %s", key)
+	}
+	f.rrsets[key] = &RRSet{TTL: req.TTL, Values: []string{req.Value}}
+	return &dns.Record{Domain: req.Domain, Name: req.Name, Type: req.Type, Value: req.Value, TTL: req.TTL}, nil
+}
+
+func (f *fakeDNS) UpsertRecord(ctx context.Context, req dns.CreateRecordRequest) (*dns.Record, error) {
+	f.rrsets[req.Name+"/"+string(req.Type)] = &RRSet{TTL: req.TTL, Values: []string{req.Value}}
+	return &dns.Record{Domain: req.Domain, Name: req.Name, Type: req.Type, Value: req.Value, TTL: req.TTL}, nil
+}
+
+func (f *fakeDNS) CreateRRSet(ctx context.Context, domain, name, recordType string, ttl int, records []map[string]interface{}) error {
+	state := &RRSet{TTL: ttl}
+	for _, r := range records {
+		state.Values = append(state.Values, r["value"].(string))
+	}
+	f.rrsets[name+"/"+recordType] = state
+	return nil
+}
+
+func (f *fakeDNS) DeleteRecord(ctx context.Context, domain, name, recordType string) error {
+	delete(f.rrsets, name+"/"+recordType)
+	return nil
+}
+
+func (f *fakeDNS) ListRecords(ctx context.Context, domain string) ([]*dns.Record, error) {
+	var records []*dns.Record
+	for key, state := range f.rrsets {
+		name, recordType, _ := strings.Cut(key, "/")
+		for _, v := range state.Values {
+			records = append(records, &dns.Record{Domain: domain, Name: name, Type: dns.RecordType(recordType), Value: v, TTL: state.TTL})
+		}
+	}
+	return records, nil
+}
+
+func (f *fakeDNS) GetRecord(ctx context.Context, domain, name, recordType string) (*dns.Record, error) {
+	return nil, nil
+}
+
+func (f *fakeDNS) CreateZone(ctx context.Context, req dns.CreateZoneRequest) (*dns.Zone, error) {
+	return &dns.Zone{Name: req.Name}, nil
+}
+
+func (f *fakeDNS) DeleteZone(ctx context.Context, zoneName string) error { return nil }
+
+func (f *fakeDNS) GetZone(ctx context.Context, zoneName string) (*dns.Zone, error) {
+	return &dns.Zone{Name: zoneName}, nil
+}
+
+func (f *fakeDNS) ListZones(ctx context.Context) ([]*dns.Zone, error) { return nil, nil }
+
+func TestProviderRecordsChanges(t *testing.T) {
+	fake := newFakeDNS()
+	journal := NewJournal(t.TempDir())
+	p := NewProvider(fake, journal, "morpheus test")
+	p.SetOperator("alice@example.com")
+	ctx := context.Background()
+
+	p.CreateRecord(ctx, dns.CreateRecordRequest{Domain: "example.com", Name: "www", Type: dns.RecordTypeA, Value: "192.0.2.1", TTL: 300})
+	p.DeleteRecord(ctx, "example.com", "www", "A")
+	p.DeleteRecord(ctx, "example.com", "missing", "A") // no-op, not recorded
+
+	changes, err := journal.List("Example.com.")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d: %+v", len(changes), changes)
+	}
+	if changes[0].ID != 1 || changes[0].Old != nil || changes[0].New.Values[0] != "192.0.2.1" {
+		t.Errorf("Unexpected create change: %+v", changes[0])
+	}
+	if changes[1].ID != 2 || changes[1].New != nil || changes[1].Old == nil {
+		t.Errorf("Unexpected delete change: %+v", changes[1])
+	}
+	if changes[0].Command != "morpheus test" {
+		t.Errorf("Command = %q, want %q", changes[0].Command, "morpheus test")
+	}
+	if changes[1].Operator != "alice@example.com" {
+		t.Errorf("Operator = %q, want alice@example.com", changes[1].Operator)
+	}
+}
+
+func TestProviderUpsertRecord(t *testing.T) {
+	fake := newFakeDNS()
+	journal := NewJournal(t.TempDir())
+	p := NewProvider(fake, journal, "morpheus test")
+	ctx := context.Background()
+
+	req := dns.CreateRecordRequest{Domain: "example.com", Name: "www", Type: dns.RecordTypeA, Value: "192.0.2.1", TTL: 300}
+	p.UpsertRecord(ctx, req)
+	p.UpsertRecord(ctx, req) // unchanged, not recorded
+	req.Value = "192.0.2.2"
+	req.TTL = 0
+	if record, err := p.UpsertRecord(ctx, req); err != nil || record.TTL != 300 {
+		t.Fatalf("UpsertRecord() = %+v, %v", record, err)
+	}
+	if _, err := p.UpdateRecord(ctx, dns.CreateRecordRequest{Domain: "example.com", Name: "missing", Type: dns.RecordTypeA, Value: "192.0.2.3"}); err == nil {
+		t.Error("UpdateRecord() of a missing record succeeded")
+	}
+
+	changes, _ := journal.List("example.com")
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d: %+v", len(changes), changes)
+	}
+	if changes[1].Old.Values[0] != "192.0.2.1" || changes[1].New.Values[0] != "192.0.2.2" || changes[1].New.TTL != 300 {
+		t.Errorf("Unexpected update change: %+v", changes[1])
+	}
+}
+
+func TestRollback(t *testing.T) {
+	fake := newFakeDNS()
+	journal := NewJournal(t.TempDir())
+	p := NewProvider(fake, journal, "morpheus test")
+	ctx := context.Background()
+
+	// 1: create www, 2: create mail, 3: delete www, 4: replace mail
+	p.CreateRecord(ctx, dns.CreateRecordRequest{Domain: "example.com", Name: "www", Type: dns.RecordTypeA, Value: "192.0.2.1", TTL: 300})
+	p.CreateRecord(ctx, dns.CreateRecordRequest{Domain: "example.com", Name: "mail", Type: dns.RecordTypeA, Value: "192.0.2.2", TTL: 300})
+	p.DeleteRecord(ctx, "example.com", "www", "A")
+	p.SetRRSet(ctx, "example.com", "mail", "A", &RRSet{TTL: 60, Values: []string{"192.0.2.9"}})
+
+	applied, err := Rollback(ctx, p, "example.com", 2)
+	if err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if len(applied) != 2 {
+		t.Errorf("Expected 2 restores, got %d: %+v", len(applied), applied)
+	}
+
+	if www := fake.rrsets["www/A"]; www == nil || www.Values[0] != "192.0.2.1" {
+		t.Errorf("www not restored: %v", www)
+	}
+	if mail := fake.rrsets["mail/A"]; mail == nil || mail.Values[0] != "192.0.2.2" || mail.TTL != 300 {
+		t.Errorf("mail not restored: %v", mail)
+	}
+
+	// The rollback itself is recorded, so rolling back to 4 redoes the changes
+	changes, _ := journal.List("example.com")
+	if len(changes) != 6 {
+		t.Fatalf("Expected 6 changes after rollback, got %d", len(changes))
+	}
+	if _, err := Rollback(ctx, p, "example.com", 4); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if _, ok := fake.rrsets["www/A"]; ok {
+		t.Error("Expected www to be deleted again")
+	}
+	if mail := fake.rrsets["mail/A"]; mail == nil || mail.Values[0] != "192.0.2.9" {
+		t.Errorf("mail not re-applied: %v", mail)
+	}
+}
+
+func TestPlanRollbackUnknownChange(t *testing.T) {
+	if _, err := PlanRollback([]Change{{ID: 1}}, 5); err == nil {
+		t.Error("Expected error for unknown change ID")
+	}
+}
+
+func TestSetAndRestoreTTLs(t *testing.T) {
+	fake := newFakeDNS()
+	journal := NewJournal(t.TempDir())
+	p := NewProvider(fake, journal, "morpheus test")
+	ctx := context.Background()
+
+	fake.rrsets["www/A"] = &RRSet{TTL: 3600, Values: []string{"192.0.2.1"}}
+	fake.rrsets["www/AAAA"] = &RRSet{TTL: 7200, Values: []string{"2001:db8::1"}}
+	fake.rrsets["@/NS"] = &RRSet{TTL: 86400, Values: []string{"ns1.example.com."}}
+
+	onlyA := func(name, recordType string) bool { return recordType == "A" }
+	if _, err := SetTTLs(ctx, p, "example.com", 300, onlyA, false); err != nil {
+		t.Fatalf("SetTTLs() error = %v", err)
+	}
+	if fake.rrsets["www/A"].TTL != 300 || fake.rrsets["www/AAAA"].TTL != 7200 {
+		t.Fatalf("Unexpected TTLs after --type A: A=%d AAAA=%d", fake.rrsets["www/A"].TTL, fake.rrsets["www/AAAA"].TTL)
+	}
+
+	// Lowering again keeps the first originals
+	changes, err := SetTTLs(ctx, p, "example.com", 60, nil, false)
+	if err != nil {
+		t.Fatalf("SetTTLs() error = %v", err)
+	}
+	if len(changes) != 2 {
+		t.Errorf("Expected 2 changes (NS skipped by default), got %v", changes)
+	}
+	if fake.rrsets["@/NS"].TTL != 86400 {
+		t.Error("NS TTL should not change by default")
+	}
+
+	if _, err := RestoreTTLs(ctx, p, "example.com", false); err != nil {
+		t.Fatalf("RestoreTTLs() error = %v", err)
+	}
+	if fake.rrsets["www/A"].TTL != 3600 || fake.rrsets["www/AAAA"].TTL != 7200 {
+		t.Errorf("TTLs not restored: A=%d AAAA=%d", fake.rrsets["www/A"].TTL, fake.rrsets["www/AAAA"].TTL)
+	}
+	if saved, _ := journal.LoadTTLs("example.com"); saved != nil {
+		t.Error("Saved TTLs should be cleared after restore")
+	}
+}
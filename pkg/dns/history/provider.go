@@ -0,0 +1,339 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/nimsforest/morpheus/pkg/dns"
+)
+
+// Provider wraps a dns.Provider and records every RRSet mutation in a Journal.
+// Read and zone operations are passed through unchanged.
+type Provider struct {
+	dns.Provider
+	journal  *Journal
+	command  string
+	operator string
+}
+
+// Ensure Provider implements the DNS interfaces
+var (
+	_ dns.Provider     = (*Provider)(nil)
+	_ dns.RRSetCreator = (*Provider)(nil)
+	_ dns.TTLChanger   = (*Provider)(nil)
+	_ dns.RecordSetter = (*Provider)(nil)
+)
+
+// NewProvider returns a recording wrapper around inner. command is stored
+// with each change (e.g., "morpheus dns record create ...").
+func NewProvider(inner dns.Provider, journal *Journal, command string) *Provider {
+	return &Provider{Provider: inner, journal: journal, command: command}
+}
+
+// SetOperator sets who is recorded with each change (e.g. an email address)
+func (p *Provider) SetOperator(operator string) {
+	p.operator = operator
+}
+
+// Journal returns the journal changes are recorded in
+func (p *Provider) Journal() *Journal {
+	return p.journal
+}
+
+// CreateRecord creates a record and records the RRSet before and after
+func (p *Provider) CreateRecord(ctx context.Context, req dns.CreateRecordRequest) (*dns.Record, error) {
+	old, err := p.Snapshot(ctx, req.Domain, req.Name, string(req.Type))
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := p.Provider.CreateRecord(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := &RRSet{TTL: record.TTL, Values: []string{req.Value}}
+	if old != nil {
+		expected.Values = append(append([]string(nil), old.Values...), req.Value)
+	}
+	p.recordAfter(ctx, req.Domain, req.Name, string(req.Type), old, expected)
+
+	return record, nil
+}
+
+// UpdateRecord replaces an existing RRSet with the record, and records the
+// change
+func (p *Provider) UpdateRecord(ctx context.Context, req dns.CreateRecordRequest) (*dns.Record, error) {
+	old, err := p.Snapshot(ctx, req.Domain, req.Name, string(req.Type))
+	if err != nil {
+		return nil, err
+	}
+	if old == nil {
+		return nil, fmt.Errorf("record not found: %s %s", req.Name, req.Type)
+	}
+	return p.setRecord(ctx, req, old)
+}
+
+// UpsertRecord creates a record, or replaces the RRSet of its name and type
+// if there is one, and records the change
+func (p *Provider) UpsertRecord(ctx context.Context, req dns.CreateRecordRequest) (*dns.Record, error) {
+	old, err := p.Snapshot(ctx, req.Domain, req.Name, string(req.Type))
+	if err != nil {
+		return nil, err
+	}
+	if old == nil {
+		return p.CreateRecord(ctx, req)
+	}
+	return p.setRecord(ctx, req, old)
+}
+
+// setRecord replaces the existing RRSet old with the record of req
+func (p *Provider) setRecord(ctx context.Context, req dns.CreateRecordRequest, old *RRSet) (*dns.Record, error) {
+	ttl := req.TTL
+	if ttl == 0 {
+		ttl = old.TTL
+	}
+	if err := p.SetRRSet(ctx, req.Domain, req.Name, string(req.Type), &RRSet{TTL: ttl, Values: []string{req.Value}}); err != nil {
+		return nil, err
+	}
+	return &dns.Record{
+		ID:     fmt.Sprintf("%s-%s", req.Name, req.Type),
+		Domain: req.Domain,
+		Name:   req.Name,
+		Type:   req.Type,
+		Value:  req.Value,
+		TTL:    ttl,
+	}, nil
+}
+
+// CreateRRSet creates a multi-value RRSet, if the wrapped provider supports it
+func (p *Provider) CreateRRSet(ctx context.Context, domain, name, recordType string, ttl int, records []map[string]interface{}) error {
+	creator, ok := p.Provider.(dns.RRSetCreator)
+	if !ok {
+		return fmt.Errorf("DNS provider does not support creating RRSets")
+	}
+
+	old, err := p.Snapshot(ctx, domain, name, recordType)
+	if err != nil {
+		return err
+	}
+
+	if err := creator.CreateRRSet(ctx, domain, name, recordType, ttl, records); err != nil {
+		return err
+	}
+
+	expected := &RRSet{TTL: ttl}
+	for _, r := range records {
+		if v, ok := r["value"].(string); ok {
+			expected.Values = append(expected.Values, v)
+		}
+	}
+	p.recordAfter(ctx, domain, name, recordType, old, expected)
+
+	return nil
+}
+
+// DeleteRecord deletes an RRSet and records its previous values
+func (p *Provider) DeleteRecord(ctx context.Context, domain, name, recordType string) error {
+	old, err := p.Snapshot(ctx, domain, name, recordType)
+	if err != nil {
+		return err
+	}
+
+	if err := p.Provider.DeleteRecord(ctx, domain, name, recordType); err != nil {
+		return err
+	}
+
+	if old != nil {
+		p.append(&Change{Zone: domain, Name: name, Type: recordType, Old: old})
+	}
+	return nil
+}
+
+// ChangeTTL changes the TTL of an existing RRSet, in place if the wrapped
+// provider supports it and by recreating the RRSet otherwise
+func (p *Provider) ChangeTTL(ctx context.Context, domain, name, recordType string, ttl int) error {
+	old, err := p.Snapshot(ctx, domain, name, recordType)
+	if err != nil {
+		return err
+	}
+	if old == nil {
+		return fmt.Errorf("record not found: %s %s", name, recordType)
+	}
+	if old.TTL == ttl {
+		return nil
+	}
+
+	changer, ok := p.Provider.(dns.TTLChanger)
+	if !ok {
+		return p.SetRRSet(ctx, domain, name, recordType, &RRSet{TTL: ttl, Values: old.Values})
+	}
+	if err := changer.ChangeTTL(ctx, domain, name, recordType, ttl); err != nil {
+		return err
+	}
+
+	p.recordAfter(ctx, domain, name, recordType, old, &RRSet{TTL: ttl, Values: old.Values})
+	return nil
+}
+
+// SetRecords replaces the values of an existing RRSet, in place if the
+// wrapped provider supports it and by recreating the RRSet otherwise
+func (p *Provider) SetRecords(ctx context.Context, domain, name, recordType string, values []string) error {
+	old, err := p.Snapshot(ctx, domain, name, recordType)
+	if err != nil {
+		return err
+	}
+	if old == nil {
+		return fmt.Errorf("record not found: %s %s", name, recordType)
+	}
+
+	setter, ok := p.Provider.(dns.RecordSetter)
+	if !ok {
+		return p.SetRRSet(ctx, domain, name, recordType, &RRSet{TTL: old.TTL, Values: values})
+	}
+	if sameValues(old.Values, values) {
+		return nil
+	}
+	if err := setter.SetRecords(ctx, domain, name, recordType, values); err != nil {
+		return err
+	}
+
+	p.recordAfter(ctx, domain, name, recordType, old, &RRSet{TTL: old.TTL, Values: values})
+	return nil
+}
+
+// SetRRSet replaces an RRSet with the given state (nil deletes it).
+// Nothing is changed or recorded when the RRSet already matches.
+func (p *Provider) SetRRSet(ctx context.Context, domain, name, recordType string, state *RRSet) error {
+	old, err := p.Snapshot(ctx, domain, name, recordType)
+	if err != nil {
+		return err
+	}
+	if old.Equal(state) {
+		return nil
+	}
+
+	// A TTL-only change can be made in place, without removing the RRSet
+	if changer, ok := p.Provider.(dns.TTLChanger); ok && old != nil && state != nil && sameValues(old.Values, state.Values) {
+		if err := changer.ChangeTTL(ctx, domain, name, recordType, state.TTL); err != nil {
+			return err
+		}
+		p.recordAfter(ctx, domain, name, recordType, old, state)
+		return nil
+	}
+
+	// So can a change of values keeping the TTL
+	if setter, ok := p.Provider.(dns.RecordSetter); ok && old != nil && state != nil && len(state.Values) > 0 && old.TTL == state.TTL {
+		if err := setter.SetRecords(ctx, domain, name, recordType, state.Values); err != nil {
+			return err
+		}
+		p.recordAfter(ctx, domain, name, recordType, old, state)
+		return nil
+	}
+
+	if old != nil {
+		if err := p.Provider.DeleteRecord(ctx, domain, name, recordType); err != nil {
+			return err
+		}
+	}
+
+	if state != nil && len(state.Values) > 0 {
+		if creator, ok := p.Provider.(dns.RRSetCreator); ok {
+			records := make([]map[string]interface{}, len(state.Values))
+			for i, v := range state.Values {
+				records[i] = map[string]interface{}{"value": v}
+			}
+			err = creator.CreateRRSet(ctx, domain, name, recordType, state.TTL, records)
+		} else {
+			for _, v := range state.Values {
+				if _, err = p.Provider.CreateRecord(ctx, dns.CreateRecordRequest{
+					Domain: domain,
+					Name:   name,
+					Type:   dns.RecordType(recordType),
+					Value:  v,
+					TTL:    state.TTL,
+				}); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			// Record the deletion so the journal still matches the zone
+			if old != nil {
+				p.append(&Change{Zone: domain, Name: name, Type: recordType, Old: old})
+			}
+			return err
+		}
+	}
+
+	p.recordAfter(ctx, domain, name, recordType, old, state)
+	return nil
+}
+
+// Snapshot returns the current state of an RRSet, or nil if it does not exist
+func (p *Provider) Snapshot(ctx context.Context, domain, name, recordType string) (*RRSet, error) {
+	rrsets, err := p.SnapshotZone(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rrsets {
+		if r.Name == name && r.Type == recordType {
+			return r.State, nil
+		}
+	}
+	return nil, nil
+}
+
+// NamedRRSet is an RRSet together with its name and type
+type NamedRRSet struct {
+	Name  string
+	Type  string
+	State *RRSet
+}
+
+// SnapshotZone returns the current state of all RRSets in a zone, in the
+// order the provider lists them
+func (p *Provider) SnapshotZone(ctx context.Context, domain string) ([]NamedRRSet, error) {
+	records, err := p.Provider.ListRecords(ctx, domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read current records: %w", err)
+	}
+
+	index := make(map[string]int)
+	var rrsets []NamedRRSet
+	for _, r := range records {
+		key := r.Name + "/" + string(r.Type)
+		i, ok := index[key]
+		if !ok {
+			i = len(rrsets)
+			index[key] = i
+			rrsets = append(rrsets, NamedRRSet{Name: r.Name, Type: string(r.Type), State: &RRSet{TTL: r.TTL}})
+		}
+		rrsets[i].State.Values = append(rrsets[i].State.Values, r.Value)
+	}
+	return rrsets, nil
+}
+
+// recordAfter re-reads the RRSet after a mutation and records the change.
+// expected is used if the RRSet cannot be read back.
+func (p *Provider) recordAfter(ctx context.Context, domain, name, recordType string, old, expected *RRSet) {
+	current, err := p.Snapshot(ctx, domain, name, recordType)
+	if err != nil {
+		current = expected
+	}
+	p.append(&Change{Zone: domain, Name: name, Type: recordType, Old: old, New: current})
+}
+
+// append saves a change. The DNS mutation has already happened at this
+// point, so a journal failure is reported but not returned.
+func (p *Provider) append(c *Change) {
+	if p.journal == nil {
+		return
+	}
+	c.Command = p.command
+	c.Operator = p.operator
+	if err := p.journal.Append(c); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Warning: DNS change not recorded in history: %s\n", err)
+	}
+}
@@ -0,0 +1,89 @@
+package history
+
+import (
+	"context"
+	"fmt"
+)
+
+// Restore is one RRSet that a rollback sets back to an earlier state
+type Restore struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	From *RRSet `json:"from,omitempty"` // State recorded by the latest change
+	To   *RRSet `json:"to,omitempty"`   // State to restore (nil deletes the RRSet)
+}
+
+// PlanRollback computes the RRSet states that undo every change after toID.
+// toID 0 undoes all recorded changes.
+func PlanRollback(changes []Change, toID int) ([]Restore, error) {
+	if toID < 0 {
+		return nil, fmt.Errorf("invalid change ID: %d", toID)
+	}
+	if toID > 0 {
+		found := false
+		for _, c := range changes {
+			if c.ID == toID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("change %d not found", toID)
+		}
+	}
+
+	// The state to restore for each RRSet is its Old value in the first
+	// change after toID; the latest change gives its current state.
+	index := make(map[string]int)
+	var plan []Restore
+	for _, c := range changes {
+		if c.ID <= toID {
+			continue
+		}
+		key := c.Name + "/" + c.Type
+		i, ok := index[key]
+		if !ok {
+			i = len(plan)
+			index[key] = i
+			plan = append(plan, Restore{Name: c.Name, Type: c.Type, To: c.Old})
+		}
+		plan[i].From = c.New
+	}
+
+	// Skip RRSets that ended up where they started
+	restores := plan[:0]
+	for _, r := range plan {
+		if !r.From.Equal(r.To) {
+			restores = append(restores, r)
+		}
+	}
+	return restores, nil
+}
+
+// Rollback restores zone to its state right after change toID. The restores
+// are themselves recorded as new changes, so a rollback can be undone.
+// The returned slice holds the restores that were applied.
+func Rollback(ctx context.Context, p *Provider, zone string, toID int) ([]Restore, error) {
+	if p.journal == nil {
+		return nil, fmt.Errorf("no DNS history journal configured")
+	}
+
+	changes, err := p.journal.List(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := PlanRollback(changes, toID)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Restore
+	for _, r := range plan {
+		if err := p.SetRRSet(ctx, zone, r.Name, r.Type, r.To); err != nil {
+			return applied, fmt.Errorf("failed to restore %s %s: %w", r.Name, r.Type, err)
+		}
+		applied = append(applied, r)
+	}
+	return applied, nil
+}
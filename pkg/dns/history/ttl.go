@@ -0,0 +1,168 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SavedTTLs remembers the TTLs RRSets had before a bulk TTL change, so they
+// can be restored after a planned migration
+type SavedTTLs struct {
+	Zone    string         `json:"zone"`
+	SavedAt time.Time      `json:"saved_at"`
+	TTLs    map[string]int `json:"ttls"` // "name/type" -> original TTL
+}
+
+// TTLChange describes one RRSet whose TTL is (or would be) changed
+type TTLChange struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	From int    `json:"from"`
+	To   int    `json:"to"`
+}
+
+// LoadTTLs returns the saved original TTLs for a zone, or nil if none are saved
+func (j *Journal) LoadTTLs(zone string) (*SavedTTLs, error) {
+	data, err := os.ReadFile(j.ttlPath(zone))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read saved TTLs: %w", err)
+	}
+
+	var saved SavedTTLs
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse saved TTLs: %w", err)
+	}
+	return &saved, nil
+}
+
+func (j *Journal) saveTTLs(saved *SavedTTLs) error {
+	if err := os.MkdirAll(j.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(j.ttlPath(saved.Zone), data, 0600)
+}
+
+func (j *Journal) clearTTLs(zone string) error {
+	err := os.Remove(j.ttlPath(zone))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (j *Journal) ttlPath(zone string) string {
+	return filepath.Join(j.dir, normalizeZone(zone)+".ttl.json")
+}
+
+// SetTTLs sets the TTL of every RRSet in zone accepted by match (nil matches
+// all RRSets except SOA and NS). The original TTL of each RRSet is saved
+// before it is changed; originals saved by an earlier call are kept, so
+// lowering twice still restores the values from before the first change.
+// With dryRun, the changes are returned but not applied or saved.
+func SetTTLs(ctx context.Context, p *Provider, zone string, ttl int, match func(name, recordType string) bool, dryRun bool) ([]TTLChange, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("TTL must be positive, got %d", ttl)
+	}
+	if match == nil {
+		match = func(name, recordType string) bool {
+			return recordType != "SOA" && recordType != "NS"
+		}
+	}
+
+	rrsets, err := p.SnapshotZone(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []TTLChange
+	for _, r := range rrsets {
+		if r.Type == "SOA" || !match(r.Name, r.Type) || r.State.TTL == ttl {
+			continue
+		}
+		changes = append(changes, TTLChange{Name: r.Name, Type: r.Type, From: r.State.TTL, To: ttl})
+	}
+	if dryRun || len(changes) == 0 {
+		return changes, nil
+	}
+
+	if p.journal == nil {
+		return nil, fmt.Errorf("no DNS history journal configured")
+	}
+	saved, err := p.journal.LoadTTLs(zone)
+	if err != nil {
+		return nil, err
+	}
+	if saved == nil {
+		saved = &SavedTTLs{Zone: normalizeZone(zone), SavedAt: time.Now(), TTLs: make(map[string]int)}
+	}
+	for _, c := range changes {
+		key := c.Name + "/" + c.Type
+		if _, ok := saved.TTLs[key]; !ok {
+			saved.TTLs[key] = c.From
+		}
+	}
+	if err := p.journal.saveTTLs(saved); err != nil {
+		return nil, fmt.Errorf("failed to save original TTLs: %w", err)
+	}
+
+	for i, c := range changes {
+		if err := p.ChangeTTL(ctx, zone, c.Name, c.Type, c.To); err != nil {
+			return changes[:i], fmt.Errorf("failed to change TTL of %s %s: %w", c.Name, c.Type, err)
+		}
+	}
+	return changes, nil
+}
+
+// RestoreTTLs sets every RRSet back to the TTL saved by SetTTLs and forgets
+// the saved values. RRSets deleted since then are skipped.
+// With dryRun, the changes are returned but not applied.
+func RestoreTTLs(ctx context.Context, p *Provider, zone string, dryRun bool) ([]TTLChange, error) {
+	if p.journal == nil {
+		return nil, fmt.Errorf("no DNS history journal configured")
+	}
+	saved, err := p.journal.LoadTTLs(zone)
+	if err != nil {
+		return nil, err
+	}
+	if saved == nil {
+		return nil, fmt.Errorf("no saved TTLs for %s", zone)
+	}
+
+	rrsets, err := p.SnapshotZone(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []TTLChange
+	for _, r := range rrsets {
+		original, ok := saved.TTLs[r.Name+"/"+r.Type]
+		if !ok || original == r.State.TTL {
+			continue
+		}
+		changes = append(changes, TTLChange{Name: r.Name, Type: r.Type, From: r.State.TTL, To: original})
+	}
+	if dryRun {
+		return changes, nil
+	}
+
+	for i, c := range changes {
+		if err := p.ChangeTTL(ctx, zone, c.Name, c.Type, c.To); err != nil {
+			return changes[:i], fmt.Errorf("failed to restore TTL of %s %s: %w", c.Name, c.Type, err)
+		}
+	}
+	if err := p.journal.clearTTLs(zone); err != nil {
+		return changes, fmt.Errorf("TTLs restored but saved values not cleared: %w", err)
+	}
+	return changes, nil
+}
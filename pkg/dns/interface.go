@@ -9,6 +9,14 @@ type Provider interface {
 	// CreateRecord creates a DNS record
 	CreateRecord(ctx context.Context, req CreateRecordRequest) (*Record, error)
 
+	// UpdateRecord replaces the RRSet of the record's name and type, which
+	// must exist, with the record's value (and TTL, unless 0)
+	UpdateRecord(ctx context.Context, req CreateRecordRequest) (*Record, error)
+
+	// UpsertRecord creates a record, or updates the RRSet of its name and
+	// type if there is one, so that running it again changes nothing
+	UpsertRecord(ctx context.Context, req CreateRecordRequest) (*Record, error)
+
 	// DeleteRecord removes a DNS record
 	DeleteRecord(ctx context.Context, domain, name, recordType string) error
 
@@ -33,6 +41,24 @@ type Provider interface {
 	ListZones(ctx context.Context) ([]*Zone, error)
 }
 
+// RRSetCreator is implemented by providers that can create a record set with
+// several values in one call (e.g., multiple MX records)
+type RRSetCreator interface {
+	CreateRRSet(ctx context.Context, domain, name, recordType string, ttl int, records []map[string]interface{}) error
+}
+
+// TTLChanger is implemented by providers that can change the TTL of an
+// RRSet in place, without recreating it
+type TTLChanger interface {
+	ChangeTTL(ctx context.Context, domain, name, recordType string, ttl int) error
+}
+
+// RecordSetter is implemented by providers that can replace the values of
+// an existing RRSet in one call, keeping its TTL
+type RecordSetter interface {
+	SetRecords(ctx context.Context, domain, name, recordType string, values []string) error
+}
+
 // CreateRecordRequest contains parameters for creating a DNS record
 type CreateRecordRequest struct {
 	Domain string     // The zone/domain (e.g., "example.com")
@@ -0,0 +1,122 @@
+package dns
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MTA-STS policy modes (RFC 8461)
+const (
+	MTASTSEnforce = "enforce"
+	MTASTSTesting = "testing"
+	MTASTSNone    = "none"
+)
+
+// DefaultMTASTSMaxAge is how long senders cache a policy: one week
+const DefaultMTASTSMaxAge = 604800
+
+// maxMTASTSMaxAge is the largest max_age RFC 8461 allows: one year
+const maxMTASTSMaxAge = 31557600
+
+// mxPattern matches an MTA-STS mx pattern: a host name, optionally with a
+// leading wildcard label
+var mxPattern = regexp.MustCompile(`^(\*\.)?([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}$`)
+
+// MTASTSPolicy is the policy file served at
+// https://mta-sts.<domain>/.well-known/mta-sts.txt
+type MTASTSPolicy struct {
+	Mode   string   // enforce, testing or none
+	MX     []string // Host names or patterns ("*.example.com") mail may go to
+	MaxAge int      // Seconds senders cache the policy (0 = DefaultMTASTSMaxAge)
+}
+
+// Validate checks the policy against RFC 8461
+func (p *MTASTSPolicy) Validate() error {
+	switch p.Mode {
+	case MTASTSEnforce, MTASTSTesting, MTASTSNone:
+	default:
+		return fmt.Errorf("invalid mode %q (use %s, %s or %s)", p.Mode, MTASTSEnforce, MTASTSTesting, MTASTSNone)
+	}
+	if len(p.MX) == 0 && p.Mode != MTASTSNone {
+		return fmt.Errorf("a %s policy needs at least one mx host", p.Mode)
+	}
+	for _, mx := range p.MX {
+		if !mxPattern.MatchString(mx) {
+			return fmt.Errorf("invalid mx host: %s", mx)
+		}
+	}
+	if p.MaxAge < 0 || p.MaxAge > maxMTASTSMaxAge {
+		return fmt.Errorf("max age must be between 0 and %d seconds", maxMTASTSMaxAge)
+	}
+	return nil
+}
+
+// String renders the policy file, with the CRLF line endings RFC 8461 uses
+func (p *MTASTSPolicy) String() string {
+	maxAge := p.MaxAge
+	if maxAge == 0 {
+		maxAge = DefaultMTASTSMaxAge
+	}
+	var b strings.Builder
+	b.WriteString("version: STSv1\r\n")
+	fmt.Fprintf(&b, "mode: %s\r\n", p.Mode)
+	for _, mx := range p.MX {
+		fmt.Fprintf(&b, "mx: %s\r\n", mx)
+	}
+	fmt.Fprintf(&b, "max_age: %d\r\n", maxAge)
+	return b.String()
+}
+
+// ID returns the policy id for the _mta-sts record. It is derived from the
+// policy, so it changes exactly when the policy does, as senders require.
+func (p *MTASTSPolicy) ID() string {
+	sum := sha256.Sum256([]byte(p.String()))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// MTASTSRecord returns the value of the TXT record at _mta-sts.<domain>
+func MTASTSRecord(p *MTASTSPolicy) string {
+	return "v=STSv1; id=" + p.ID()
+}
+
+// TLSRPTRecord returns the value of the TXT record at _smtp._tls.<domain>.
+// Report addresses without a scheme are taken as mail addresses.
+func TLSRPTRecord(rua []string) (string, error) {
+	if len(rua) == 0 {
+		return "", fmt.Errorf("a TLS-RPT record needs at least one report address")
+	}
+	uris := make([]string, len(rua))
+	for i, addr := range rua {
+		switch {
+		case strings.HasPrefix(addr, "mailto:"), strings.HasPrefix(addr, "https://"):
+			uris[i] = addr
+		case strings.Contains(addr, "@") && !strings.ContainsAny(addr, ":,; "):
+			uris[i] = "mailto:" + addr
+		default:
+			return "", fmt.Errorf("invalid report address: %s", addr)
+		}
+	}
+	return "v=TLSRPTv1; rua=" + strings.Join(uris, ","), nil
+}
+
+// LookupMXHosts returns the MX hosts of a domain, sorted and without the
+// trailing dot, for use in an MTA-STS policy
+func LookupMXHosts(ctx context.Context, domain, resolver string) ([]string, error) {
+	records, err := newResolver(resolver).LookupMX(ctx, domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up MX records of %s: %w", domain, err)
+	}
+	var hosts []string
+	for _, mx := range records {
+		if host := strings.ToLower(strings.TrimSuffix(mx.Host, ".")); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts, nil
+}
@@ -0,0 +1,58 @@
+package dns
+
+import (
+	"testing"
+)
+
+func TestMTASTSPolicy(t *testing.T) {
+	p := &MTASTSPolicy{Mode: MTASTSEnforce, MX: []string{"mx1.example.com", "*.mail.example.com"}}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	want := "version: STSv1\r\nmode: enforce\r\nmx: mx1.example.com\r\nmx: *.mail.example.com\r\nmax_age: 604800\r\n"
+	if got := p.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	id := p.ID()
+	if len(id) != 16 || id != p.ID() {
+		t.Errorf("ID() = %q, want a stable 16 character id", id)
+	}
+	if got := MTASTSRecord(p); got != "v=STSv1; id="+id {
+		t.Errorf("MTASTSRecord() = %q", got)
+	}
+	p.Mode = MTASTSTesting
+	if p.ID() == id {
+		t.Error("ID() unchanged after the policy changed")
+	}
+
+	invalid := []MTASTSPolicy{
+		{Mode: "strict", MX: []string{"mx.example.com"}},
+		{Mode: MTASTSEnforce},
+		{Mode: MTASTSTesting, MX: []string{"mx.*.example.com"}},
+		{Mode: MTASTSEnforce, MX: []string{"mx.example.com"}, MaxAge: 40000000},
+	}
+	for _, p := range invalid {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Validate(%+v): expected error", p)
+		}
+	}
+	if err := (&MTASTSPolicy{Mode: MTASTSNone}).Validate(); err != nil {
+		t.Errorf("Validate() of a none policy without mx: %v", err)
+	}
+}
+
+func TestTLSRPTRecord(t *testing.T) {
+	got, err := TLSRPTRecord([]string{"tls@example.com", "https://reports.example.com/tlsrpt"})
+	if err != nil {
+		t.Fatalf("TLSRPTRecord() error = %v", err)
+	}
+	if want := "v=TLSRPTv1; rua=mailto:tls@example.com,https://reports.example.com/tlsrpt"; got != want {
+		t.Errorf("TLSRPTRecord() = %q, want %q", got, want)
+	}
+	for _, rua := range [][]string{nil, {"example.com"}, {"a@example.com,b@example.com"}} {
+		if _, err := TLSRPTRecord(rua); err == nil {
+			t.Errorf("TLSRPTRecord(%q): expected error", rua)
+		}
+	}
+}
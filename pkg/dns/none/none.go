@@ -32,6 +32,16 @@ func (p *Provider) CreateRecord(ctx context.Context, req dns.CreateRecordRequest
 	}, nil
 }
 
+// UpdateRecord is a no-op that returns a dummy record
+func (p *Provider) UpdateRecord(ctx context.Context, req dns.CreateRecordRequest) (*dns.Record, error) {
+	return p.CreateRecord(ctx, req)
+}
+
+// UpsertRecord is a no-op that returns a dummy record
+func (p *Provider) UpsertRecord(ctx context.Context, req dns.CreateRecordRequest) (*dns.Record, error) {
+	return p.CreateRecord(ctx, req)
+}
+
 // DeleteRecord is a no-op that always succeeds
 func (p *Provider) DeleteRecord(ctx context.Context, domain, name, recordType string) error {
 	return nil // No-op - always succeeds
@@ -0,0 +1,136 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolver is a DNS server queried for a propagation report
+type Resolver struct {
+	Name          string // e.g. "Google", or the nameserver's host name
+	Addr          string // "host:port" or SystemResolver
+	Authoritative bool   // Serves the zone itself rather than caching it
+}
+
+// PublicResolvers are the caching resolvers of a propagation report
+var PublicResolvers = []Resolver{
+	{Name: "system", Addr: SystemResolver},
+	{Name: "Google", Addr: "8.8.8.8:53"},
+	{Name: "Cloudflare", Addr: "1.1.1.1:53"},
+	{Name: "Quad9", Addr: "9.9.9.9:53"},
+}
+
+// PropagationResolvers returns PublicResolvers followed by the given
+// authoritative nameservers of the zone (e.g. customer.HetznerNameservers)
+func PropagationResolvers(nameservers []string) []Resolver {
+	resolvers := append([]Resolver(nil), PublicResolvers...)
+	for _, ns := range nameservers {
+		host := strings.TrimSuffix(ns, ".")
+		resolvers = append(resolvers, Resolver{Name: host, Addr: host + ":53", Authoritative: true})
+	}
+	return resolvers
+}
+
+// PropagationCheck is a record set expected at a name
+type PropagationCheck struct {
+	Name   string
+	Type   RecordType
+	Values []string // Expected values; empty means any answer
+}
+
+// ResolverAnswer is what one resolver returned for a check
+type ResolverAnswer struct {
+	Resolver Resolver
+	Answers  []string // Empty if the name or type does not exist
+	Error    string   // Set if the resolver could not be asked
+	Matched  bool
+}
+
+// PropagationRow holds the answers of every resolver for one check
+type PropagationRow struct {
+	Check   PropagationCheck
+	Answers []ResolverAnswer // In the order of the resolvers
+}
+
+// PropagationReport is a matrix of checks by resolvers
+type PropagationReport struct {
+	Resolvers []Resolver
+	Rows      []PropagationRow
+}
+
+// Propagation states of a row, see Diagnosis
+const (
+	PropagationComplete      = "complete"      // Every resolver sees the records
+	PropagationCaching       = "caching"       // The authoritative servers do, some caches do not yet
+	PropagationMisconfigured = "misconfigured" // An authoritative server does not serve the records
+	PropagationPartial       = "partial"       // Some resolvers do not; no authoritative server answered
+)
+
+// CheckPropagation queries every resolver for every check, in parallel
+func CheckPropagation(ctx context.Context, resolvers []Resolver, checks []PropagationCheck) *PropagationReport {
+	report := &PropagationReport{Resolvers: resolvers, Rows: make([]PropagationRow, len(checks))}
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		report.Rows[i] = PropagationRow{Check: check, Answers: make([]ResolverAnswer, len(resolvers))}
+		for j, resolver := range resolvers {
+			wg.Add(1)
+			go func(answer *ResolverAnswer, check PropagationCheck, resolver Resolver) {
+				defer wg.Done()
+				answer.Resolver = resolver
+				lookupCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+				defer cancel()
+				answers, err := lookupFunc(lookupCtx, resolver.Addr, check.Name, check.Type)
+				var dnsErr *net.DNSError
+				if err != nil && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+					answer.Error = err.Error()
+					return
+				}
+				answer.Answers = answers
+				answer.Matched = matchesValues(answers, check.Type, check.Values)
+			}(&report.Rows[i].Answers[j], check, resolver)
+		}
+	}
+	wg.Wait()
+	return report
+}
+
+// Diagnosis tells caching apart from misconfiguration: if the
+// authoritative servers return the expected records, resolvers that do not
+// have old answers cached; if an authoritative server answers without
+// them, the zone itself is wrong. Unreachable servers count for neither.
+func (r *PropagationRow) Diagnosis() string {
+	complete := true
+	askedAuthoritative := false
+	for _, a := range r.Answers {
+		if a.Resolver.Authoritative && a.Error == "" {
+			askedAuthoritative = true
+			if !a.Matched {
+				return PropagationMisconfigured
+			}
+		}
+		complete = complete && a.Matched
+	}
+	switch {
+	case complete:
+		return PropagationComplete
+	case askedAuthoritative:
+		return PropagationCaching
+	default:
+		return PropagationPartial
+	}
+}
+
+// Complete reports whether every resolver sees every check's records
+func (r *PropagationReport) Complete() bool {
+	for i := range r.Rows {
+		if r.Rows[i].Diagnosis() != PropagationComplete {
+			return false
+		}
+	}
+	return true
+}
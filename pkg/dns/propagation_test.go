@@ -0,0 +1,51 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestCheckPropagation(t *testing.T) {
+	// The cache of resolver "old" still has the previous address
+	lookupFunc = func(ctx context.Context, resolver, name string, recordType RecordType) ([]string, error) {
+		switch {
+		case resolver == "old:53":
+			return []string{"192.0.2.1"}, nil
+		case name == "broken.example.com":
+			return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		case resolver == "down:53":
+			return nil, errors.New("i/o timeout")
+		}
+		return []string{"192.0.2.9"}, nil
+	}
+	defer func() { lookupFunc = LookupRecord }()
+
+	public := []Resolver{{Name: "new", Addr: "new:53"}, {Name: "old", Addr: "old:53"}}
+	resolvers := append(public, Resolver{Name: "ns1", Addr: "ns1:53", Authoritative: true})
+	report := CheckPropagation(context.Background(), resolvers, []PropagationCheck{
+		{Name: "www.example.com", Type: RecordTypeA, Values: []string{"192.0.2.9"}},
+		{Name: "broken.example.com", Type: RecordTypeA},
+	})
+
+	if got := report.Rows[0].Diagnosis(); got != PropagationCaching {
+		t.Errorf("Diagnosis() with a stale cache = %s, want %s", got, PropagationCaching)
+	}
+	if a := report.Rows[0].Answers[1]; a.Resolver.Name != "old" || a.Matched || a.Answers[0] != "192.0.2.1" {
+		t.Errorf("Unexpected answer of the stale resolver: %+v", a)
+	}
+	if got := report.Rows[1].Diagnosis(); got != PropagationMisconfigured {
+		t.Errorf("Diagnosis() without an authoritative answer = %s, want %s", got, PropagationMisconfigured)
+	}
+	if report.Complete() {
+		t.Error("Complete() = true with unmatched answers")
+	}
+
+	// An unreachable authoritative server proves nothing
+	down := append(public, Resolver{Name: "down", Addr: "down:53", Authoritative: true})
+	partial := CheckPropagation(context.Background(), down, []PropagationCheck{report.Rows[0].Check})
+	if got := partial.Rows[0].Diagnosis(); got != PropagationPartial {
+		t.Errorf("Diagnosis() with the authoritative server down = %s, want %s", got, PropagationPartial)
+	}
+}
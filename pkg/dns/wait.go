@@ -0,0 +1,262 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/httputil"
+)
+
+// SystemResolver selects the operating system's resolver in WaitOptions.Resolvers
+const SystemResolver = "system"
+
+// DefaultResolvers are the public resolvers queried by WaitForRecord
+var DefaultResolvers = []string{"8.8.8.8:53", "1.1.1.1:53", "9.9.9.9:53"}
+
+const (
+	defaultWaitTimeout  = 10 * time.Minute
+	defaultWaitInterval = 10 * time.Second
+)
+
+// WaitOptions configures WaitForRecord
+type WaitOptions struct {
+	// Resolvers to query ("host:port" or SystemResolver).
+	// Defaults to DefaultResolvers, or the system resolver in restricted
+	// environments (Termux/Android) where direct DNS queries are blocked.
+	Resolvers []string
+	// Quorum is how many resolvers must return the value (0 = all)
+	Quorum int
+	// Timeout bounds the whole wait (default 10m)
+	Timeout time.Duration
+	// Interval between polling rounds (default 10s)
+	Interval time.Duration
+	// Progress, if set, is called after every polling round
+	Progress func(WaitProgress)
+}
+
+// WaitProgress reports the state of one polling round
+type WaitProgress struct {
+	Attempt int
+	Elapsed time.Duration
+	Matched []string          // Resolvers that returned the expected value
+	Pending map[string]string // Resolver -> what it returned instead (or the error)
+}
+
+// lookupFunc performs a lookup against one resolver; replaced in tests
+var lookupFunc = LookupRecord
+
+// WaitForRecord polls several resolvers until enough of them return value
+// for name/recordType, the timeout expires, or ctx is cancelled.
+// An empty value matches any answer. Values are compared case-insensitively,
+// ignoring trailing dots and TXT quoting; IP addresses are compared parsed.
+func WaitForRecord(ctx context.Context, name string, recordType RecordType, value string, opts WaitOptions) error {
+	return waitFor(ctx, name, recordType, opts, func(answers []string) bool {
+		return matchesValue(answers, recordType, value)
+	})
+}
+
+// WaitForRecordSet is WaitForRecord for several values, e.g. the NS or MX
+// records of a domain: a resolver matches once it returns all of them.
+// Further answers are allowed, so a set being replaced matches as soon as
+// the new values are visible.
+func WaitForRecordSet(ctx context.Context, name string, recordType RecordType, values []string, opts WaitOptions) error {
+	return waitFor(ctx, name, recordType, opts, func(answers []string) bool {
+		return matchesValues(answers, recordType, values)
+	})
+}
+
+// waitFor polls the resolvers of opts until enough of them return answers
+// for which match is true
+func waitFor(ctx context.Context, name string, recordType RecordType, opts WaitOptions, match func([]string) bool) error {
+	resolvers := opts.Resolvers
+	if len(resolvers) == 0 {
+		if httputil.IsRestrictedEnvironment() {
+			resolvers = []string{SystemResolver}
+		} else {
+			resolvers = DefaultResolvers
+		}
+	}
+	quorum := opts.Quorum
+	if quorum <= 0 || quorum > len(resolvers) {
+		quorum = len(resolvers)
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultWaitTimeout
+	}
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = defaultWaitInterval
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		progress := WaitProgress{Attempt: attempt, Pending: make(map[string]string)}
+		for _, resolver := range resolvers {
+			lookupCtx, lookupCancel := context.WithTimeout(ctx, 5*time.Second)
+			answers, err := lookupFunc(lookupCtx, resolver, name, recordType)
+			lookupCancel()
+
+			switch {
+			case err != nil:
+				progress.Pending[resolver] = err.Error()
+			case match(answers):
+				progress.Matched = append(progress.Matched, resolver)
+			case len(answers) == 0:
+				progress.Pending[resolver] = "no answer"
+			default:
+				progress.Pending[resolver] = strings.Join(answers, ", ")
+			}
+		}
+		progress.Elapsed = time.Since(start)
+
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+		if len(progress.Matched) >= quorum {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for %s %s after %s (%d/%d resolvers matched)",
+				name, recordType, time.Since(start).Round(time.Second), len(progress.Matched), quorum)
+		case <-time.After(interval):
+		}
+	}
+}
+
+// LookupRecord queries one resolver ("host:port" or SystemResolver) for
+// name/recordType and returns the answers as strings.
+// Supported types: A, AAAA, CNAME, TXT, MX ("priority host"), NS and CAA
+// (`flags tag "value"`; looked up over DNS-over-HTTPS, so only with the
+// system resolver).
+func LookupRecord(ctx context.Context, resolverAddr, name string, recordType RecordType) ([]string, error) {
+	resolver := newResolver(resolverAddr)
+
+	var answers []string
+	switch recordType {
+	case RecordTypeA, RecordTypeAAAA:
+		network := "ip4"
+		if recordType == RecordTypeAAAA {
+			network = "ip6"
+		}
+		ips, err := resolver.LookupIP(ctx, network, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			answers = append(answers, ip.String())
+		}
+	case RecordTypeCNAME:
+		cname, err := resolver.LookupCNAME(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, cname)
+	case RecordTypeTXT:
+		txts, err := resolver.LookupTXT(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		answers = txts
+	case "MX":
+		mxs, err := resolver.LookupMX(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range mxs {
+			answers = append(answers, fmt.Sprintf("%d %s", mx.Pref, mx.Host))
+		}
+	case "NS":
+		nss, err := resolver.LookupNS(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range nss {
+			answers = append(answers, ns.Host)
+		}
+	case RecordTypeCAA:
+		if resolverAddr != SystemResolver && resolverAddr != "" {
+			return nil, fmt.Errorf("CAA lookups are not supported with resolver %s", resolverAddr)
+		}
+		records, err := LookupCAA(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range records {
+			answers = append(answers, r.String())
+		}
+	default:
+		return nil, fmt.Errorf("unsupported record type for lookup: %s", recordType)
+	}
+
+	sort.Strings(answers)
+	return answers, nil
+}
+
+// newResolver returns a resolver that queries resolverAddr ("host:port"),
+// or the system resolver for SystemResolver or ""
+func newResolver(resolverAddr string) *net.Resolver {
+	if resolverAddr == SystemResolver || resolverAddr == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			d := net.Dialer{Timeout: 5 * time.Second}
+			return d.DialContext(ctx, "udp", resolverAddr)
+		},
+	}
+}
+
+// matchesValue reports whether any answer equals the expected value
+func matchesValue(answers []string, recordType RecordType, value string) bool {
+	if value == "" {
+		return len(answers) > 0
+	}
+
+	want := normalizeValue(recordType, value)
+	for _, a := range answers {
+		if normalizeValue(recordType, a) == want {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesValues reports whether the answers include every expected value;
+// with no values, whether there is any answer
+func matchesValues(answers []string, recordType RecordType, values []string) bool {
+	for _, v := range values {
+		if !matchesValue(answers, recordType, v) {
+			return false
+		}
+	}
+	return len(answers) > 0
+}
+
+func normalizeValue(recordType RecordType, value string) string {
+	value = strings.TrimSpace(value)
+	switch recordType {
+	case RecordTypeA, RecordTypeAAAA:
+		if ip := net.ParseIP(value); ip != nil {
+			return ip.String()
+		}
+	case RecordTypeTXT:
+		// Providers quote TXT values; resolvers return them unquoted
+		return strings.ReplaceAll(strings.Trim(value, "\""), "\" \"", "")
+	case RecordTypeCAA:
+		if c, err := ParseCAA(value); err == nil {
+			return c.String()
+		}
+	}
+	return strings.TrimSuffix(strings.ToLower(value), ".")
+}
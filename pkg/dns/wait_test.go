@@ -0,0 +1,101 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitForRecord(t *testing.T) {
+	// Resolver b only sees the new value from the second round on
+	rounds := make(map[string]int)
+	lookupFunc = func(ctx context.Context, resolver, name string, recordType RecordType) ([]string, error) {
+		rounds[resolver]++
+		if resolver == "b:53" && rounds[resolver] < 2 {
+			return []string{"192.0.2.1"}, nil
+		}
+		return []string{"192.0.2.9"}, nil
+	}
+	defer func() { lookupFunc = LookupRecord }()
+
+	var progress []WaitProgress
+	err := WaitForRecord(context.Background(), "www.example.com", RecordTypeA, "192.0.2.9", WaitOptions{
+		Resolvers: []string{"a:53", "b:53"},
+		Interval:  time.Millisecond,
+		Progress:  func(p WaitProgress) { progress = append(progress, p) },
+	})
+	if err != nil {
+		t.Fatalf("WaitForRecord() error = %v", err)
+	}
+	if len(progress) != 2 {
+		t.Fatalf("Expected 2 rounds, got %d", len(progress))
+	}
+	if len(progress[0].Matched) != 1 || progress[0].Pending["b:53"] != "192.0.2.1" {
+		t.Errorf("Unexpected first round: %+v", progress[0])
+	}
+}
+
+func TestWaitForRecordSet(t *testing.T) {
+	// Resolver b still has one of the old nameservers cached
+	lookupFunc = func(ctx context.Context, resolver, name string, recordType RecordType) ([]string, error) {
+		if resolver == "b:53" {
+			return []string{"hydrogen.ns.hetzner.com.", "ns1.registrar.example."}, nil
+		}
+		return []string{"helium.ns.hetzner.de.", "hydrogen.ns.hetzner.com.", "oxygen.ns.hetzner.com."}, nil
+	}
+	defer func() { lookupFunc = LookupRecord }()
+
+	var last WaitProgress
+	err := WaitForRecordSet(context.Background(), "example.com", "NS", []string{"hydrogen.ns.hetzner.com", "oxygen.ns.hetzner.com", "helium.ns.hetzner.de"}, WaitOptions{
+		Resolvers: []string{"a:53", "b:53"},
+		Timeout:   20 * time.Millisecond,
+		Interval:  5 * time.Millisecond,
+		Progress:  func(p WaitProgress) { last = p },
+	})
+	if err == nil {
+		t.Fatal("WaitForRecordSet() with a resolver missing values: expected error")
+	}
+	if len(last.Matched) != 1 || last.Matched[0] != "a:53" || last.Pending["b:53"] == "" {
+		t.Errorf("Unexpected last round: %+v", last)
+	}
+}
+
+func TestWaitForRecordTimeout(t *testing.T) {
+	lookupFunc = func(ctx context.Context, resolver, name string, recordType RecordType) ([]string, error) {
+		return nil, errors.New("no such host")
+	}
+	defer func() { lookupFunc = LookupRecord }()
+
+	err := WaitForRecord(context.Background(), "www.example.com", RecordTypeA, "", WaitOptions{
+		Resolvers: []string{"a:53"},
+		Timeout:   20 * time.Millisecond,
+		Interval:  5 * time.Millisecond,
+	})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected timeout error, got %v", err)
+	}
+}
+
+func TestMatchesValue(t *testing.T) {
+	tests := []struct {
+		recordType RecordType
+		answers    []string
+		value      string
+		want       bool
+	}{
+		{RecordTypeAAAA, []string{"2001:db8::1"}, "2001:DB8:0::1", true},
+		{RecordTypeCNAME, []string{"www.example.com."}, "WWW.example.com", true},
+		{RecordTypeTXT, []string{"v=spf1 -all"}, "\"v=spf1 -all\"", true},
+		{RecordTypeA, []string{"192.0.2.1"}, "192.0.2.2", false},
+		{RecordTypeA, []string{"192.0.2.1"}, "", true},
+		{RecordTypeA, nil, "", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchesValue(tt.answers, tt.recordType, tt.value); got != tt.want {
+			t.Errorf("matchesValue(%v, %s, %q) = %v, want %v", tt.answers, tt.recordType, tt.value, got, tt.want)
+		}
+	}
+}
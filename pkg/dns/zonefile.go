@@ -0,0 +1,267 @@
+package dns
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Record types that hold domain names, which zone files may give relative
+// to the origin. The index is that of the name among the value's fields.
+var nameFields = map[RecordType]int{
+	RecordTypeCNAME: 0,
+	"NS":            0,
+	"PTR":           0,
+	"DNAME":         0,
+	"MX":            1,
+	RecordTypeSRV:   3,
+}
+
+// WriteZoneFile writes records of a zone as an RFC 1035 zone file. Names
+// are written relative to the zone ($ORIGIN), values as the provider has
+// them. Records without a TTL of their own get the zone's default TTL.
+func WriteZoneFile(w io.Writer, zone string, defaultTTL int, records []*Record) error {
+	zone = strings.TrimSuffix(zone, ".")
+	sorted := make([]*Record, len(records))
+	copy(sorted, records)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return zoneOrder(sorted[i]) < zoneOrder(sorted[j])
+	})
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "$ORIGIN %s.\n", zone)
+	if defaultTTL > 0 {
+		fmt.Fprintf(bw, "$TTL %d\n", defaultTTL)
+	}
+	fmt.Fprintln(bw)
+	for _, r := range sorted {
+		name := r.Name
+		if name == "" {
+			name = "@"
+		}
+		ttl := r.TTL
+		if ttl <= 0 {
+			ttl = defaultTTL
+		}
+		value := r.Value
+		if r.Type == RecordTypeTXT && !strings.HasPrefix(value, `"`) {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(bw, "%-24s %-6d IN %-6s %s\n", name, ttl, r.Type, value)
+	}
+	return bw.Flush()
+}
+
+// zoneOrder sorts SOA first, then the apex, then the other names
+func zoneOrder(r *Record) string {
+	switch {
+	case r.Type == "SOA":
+		return "0"
+	case r.Name == "@" || r.Name == "":
+		return "1" + string(r.Type)
+	}
+	return "2" + r.Name + " " + string(r.Type)
+}
+
+// ParseZoneFile parses an RFC 1035 zone file for a zone. It understands
+// $ORIGIN and $TTL, comments, parenthesized multi-line records, omitted
+// owners, TTLs and classes, and relative names. Record names are returned
+// relative to the zone ("@" for the apex), and domain names in values
+// (CNAME, NS, MX, SRV targets) as absolute names ending in a dot.
+func ParseZoneFile(r io.Reader, zone string) ([]*Record, error) {
+	zone = strings.ToLower(strings.TrimSuffix(zone, ".")) + "."
+	origin := zone
+	defaultTTL := 0
+	owner := ""
+	var records []*Record
+
+	entries, err := zoneEntries(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		fields := e.fields
+		switch strings.ToUpper(fields[0]) {
+		case "$ORIGIN":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: $ORIGIN needs a domain name", e.line)
+			}
+			origin = absoluteName(fields[1], origin)
+			continue
+		case "$TTL":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: $TTL needs a value", e.line)
+			}
+			if defaultTTL, err = parseTTL(fields[1]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", e.line, err)
+			}
+			continue
+		case "$INCLUDE", "$GENERATE":
+			return nil, fmt.Errorf("line %d: %s is not supported", e.line, fields[0])
+		}
+
+		if !e.continued {
+			owner = absoluteName(fields[0], origin)
+			fields = fields[1:]
+		} else if owner == "" {
+			return nil, fmt.Errorf("line %d: record without an owner name", e.line)
+		}
+
+		// [TTL] [class] type, with TTL and class in either order
+		ttl := defaultTTL
+		for len(fields) > 0 {
+			if t, err := parseTTL(fields[0]); err == nil {
+				ttl = t
+			} else if c := strings.ToUpper(fields[0]); c != "IN" && c != "CH" && c != "HS" {
+				break
+			}
+			fields = fields[1:]
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: incomplete record", e.line)
+		}
+
+		recordType := RecordType(strings.ToUpper(fields[0]))
+		values := fields[1:]
+		if i, ok := nameFields[recordType]; ok && i < len(values) {
+			values[i] = absoluteName(values[i], origin)
+		}
+
+		name, ok := relativeName(owner, zone)
+		if !ok {
+			return nil, fmt.Errorf("line %d: %s is not in zone %s", e.line, owner, strings.TrimSuffix(zone, "."))
+		}
+		records = append(records, &Record{
+			Domain: strings.TrimSuffix(zone, "."),
+			Name:   name,
+			Type:   recordType,
+			Value:  strings.Join(values, " "),
+			TTL:    ttl,
+		})
+	}
+	return records, nil
+}
+
+// zoneEntry is one record or directive of a zone file, split into fields
+type zoneEntry struct {
+	line      int
+	fields    []string
+	continued bool // Starts with whitespace: the owner is the previous one
+}
+
+// zoneEntries splits a zone file into entries, joining parenthesized
+// lines, removing comments and keeping quoted strings (with their quotes)
+// as one field
+func zoneEntries(r io.Reader) ([]zoneEntry, error) {
+	var entries []zoneEntry
+	var cur *zoneEntry
+	depth := 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if depth == 0 {
+			cur = &zoneEntry{line: lineNo, continued: len(line) > 0 && (line[0] == ' ' || line[0] == '\t')}
+		}
+
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			switch {
+			case c == ';':
+				i = len(line)
+			case c == ' ' || c == '\t' || c == '\r':
+			case c == '(':
+				depth++
+			case c == ')':
+				if depth == 0 {
+					return nil, fmt.Errorf("line %d: unbalanced )", lineNo)
+				}
+				depth--
+			case c == '"':
+				j := i + 1
+				for ; j < len(line) && line[j] != '"'; j++ {
+					if line[j] == '\\' {
+						j++
+					}
+				}
+				if j >= len(line) {
+					return nil, fmt.Errorf("line %d: unterminated string", lineNo)
+				}
+				cur.fields = append(cur.fields, line[i:j+1])
+				i = j
+			default:
+				j := i
+				for ; j < len(line) && !strings.ContainsRune(" \t\r;()\"", rune(line[j])); j++ {
+					if line[j] == '\\' {
+						j++
+					}
+				}
+				j = min(j, len(line))
+				cur.fields = append(cur.fields, line[i:j])
+				i = j - 1
+			}
+		}
+		if depth == 0 && len(cur.fields) > 0 {
+			entries = append(entries, *cur)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("line %d: unbalanced (", cur.line)
+	}
+	return entries, nil
+}
+
+// parseTTL parses a TTL in seconds or with units, e.g. 3600 or 1h30m
+func parseTTL(s string) (int, error) {
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+		return n, nil
+	}
+	units := map[byte]int{'s': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 604800}
+	total, n := 0, -1
+	for i := 0; i < len(s); i++ {
+		c := s[i] | 0x20
+		switch {
+		case s[i] >= '0' && s[i] <= '9':
+			n = max(n, 0)*10 + int(s[i]-'0')
+		case units[c] > 0 && n >= 0:
+			total += n * units[c]
+			n = -1
+		default:
+			return 0, fmt.Errorf("invalid TTL %q", s)
+		}
+	}
+	if n >= 0 || s == "" {
+		return 0, fmt.Errorf("invalid TTL %q", s)
+	}
+	return total, nil
+}
+
+// absoluteName makes a name absolute (ending in a dot) relative to origin
+func absoluteName(name, origin string) string {
+	switch {
+	case name == "@":
+		return origin
+	case strings.HasSuffix(name, "."):
+		return strings.ToLower(name)
+	}
+	return strings.ToLower(name) + "." + origin
+}
+
+// relativeName returns an absolute name relative to a zone, "@" for the
+// apex; ok is false if it is not in the zone
+func relativeName(name, zone string) (string, bool) {
+	if name == zone {
+		return "@", true
+	}
+	rel, ok := strings.CutSuffix(name, "."+zone)
+	return rel, ok
+}
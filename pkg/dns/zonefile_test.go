@@ -0,0 +1,107 @@
+package dns
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testZone = `$ORIGIN example.com.
+$TTL 1h
+@       IN  SOA ns1.example.com. hostmaster.example.com. (
+                2024010101 ; serial
+                7200 3600 1209600 300 )
+        IN  NS  ns1.example.com.
+        IN  MX  10 mail          ; relative to the origin
+@   300 IN  TXT "v=spf1 mx -all"
+www     IN 600 A 192.0.2.1
+        AAAA 2001:db8::1
+blog        CNAME www
+*.app   A   192.0.2.2
+_sip._tcp SRV 10 5 5060 sip.example.net.
+long    TXT ( "part one"
+              "part two; not a comment" )
+$ORIGIN sub.example.com.
+host    A   192.0.2.3
+`
+
+func TestParseZoneFile(t *testing.T) {
+	records, err := ParseZoneFile(strings.NewReader(testZone), "example.com")
+	if err != nil {
+		t.Fatalf("ParseZoneFile() error = %v", err)
+	}
+	var got []string
+	for _, r := range records {
+		got = append(got, strings.Join([]string{r.Name, string(r.Type), r.Value}, " ")+" "+strconv.Itoa(r.TTL))
+	}
+	want := []string{
+		"@ SOA ns1.example.com. hostmaster.example.com. 2024010101 7200 3600 1209600 300 3600",
+		"@ NS ns1.example.com. 3600",
+		"@ MX 10 mail.example.com. 3600",
+		`@ TXT "v=spf1 mx -all" 300`,
+		"www A 192.0.2.1 600",
+		"www AAAA 2001:db8::1 3600",
+		"blog CNAME www.example.com. 3600",
+		"*.app A 192.0.2.2 3600",
+		"_sip._tcp SRV 10 5 5060 sip.example.net. 3600",
+		`long TXT "part one" "part two; not a comment" 3600`,
+		"host.sub A 192.0.2.3 3600",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("ParseZoneFile() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	for _, bad := range []string{
+		"www A 192.0.2.1\nother.org. A 192.0.2.1\n",
+		"www ( A 192.0.2.1\n",
+		"$INCLUDE other.zone\n",
+		"www TXT \"unterminated\n",
+		"www 3600\n",
+	} {
+		if _, err := ParseZoneFile(strings.NewReader(bad), "example.com"); err == nil {
+			t.Errorf("ParseZoneFile(%q): expected error", bad)
+		}
+	}
+}
+
+func TestWriteZoneFile(t *testing.T) {
+	records := []*Record{
+		{Name: "www", Type: RecordTypeA, Value: "192.0.2.1", TTL: 600},
+		{Name: "@", Type: RecordTypeTXT, Value: "v=spf1 -all"},
+		{Name: "@", Type: "SOA", Value: "ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300", TTL: 3600},
+	}
+	var buf bytes.Buffer
+	if err := WriteZoneFile(&buf, "example.com", 3600, records); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "$ORIGIN example.com.\n$TTL 3600\n") || strings.Index(out, "SOA") > strings.Index(out, "TXT") {
+		t.Errorf("WriteZoneFile() =\n%s", out)
+	}
+
+	// What is written parses back to the same records
+	parsed, err := ParseZoneFile(&buf, "example.com")
+	if err != nil || len(parsed) != 3 {
+		t.Fatalf("ParseZoneFile() = %v, %v", parsed, err)
+	}
+	if r := parsed[1]; r.Name != "@" || r.Value != `"v=spf1 -all"` || r.TTL != 3600 {
+		t.Errorf("round trip: %+v", r)
+	}
+	if r := parsed[2]; r.Name != "www" || r.Value != "192.0.2.1" || r.TTL != 600 {
+		t.Errorf("round trip: %+v", r)
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+	for s, want := range map[string]int{"300": 300, "1h": 3600, "1h30m": 5400, "1W": 604800, "2d": 172800} {
+		if got, err := parseTTL(s); err != nil || got != want {
+			t.Errorf("parseTTL(%q) = %d, %v; want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "h", "1x", "IN", "10h5"} {
+		if _, err := parseTTL(s); err == nil {
+			t.Errorf("parseTTL(%q): expected error", s)
+		}
+	}
+}
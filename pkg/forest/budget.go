@@ -0,0 +1,59 @@
+package forest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nimsforest/morpheus/pkg/billing"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// ErrOverBudget is returned when machines would take the expected monthly
+// spend of all forests over limits.max_monthly_cost
+var ErrOverBudget = errors.New("over budget")
+
+// checkBudget checks that adding the monthly spend added keeps the expected
+// spend of all forests, other than the one being replaced (if any), within
+// limits.max_monthly_cost. With override, exceeding it is only a warning.
+func (p *Provisioner) checkBudget(added float64, replacing string, override bool) error {
+	limit := p.config.Limits.MaxMonthlyCost
+	if limit <= 0 || added <= 0 {
+		return nil
+	}
+
+	var forests []*storage.Forest
+	for _, f := range p.storage.ListForests() {
+		if f.ID != replacing {
+			forests = append(forests, f)
+		}
+	}
+	budget := billing.ProjectBudget(forests, added, limit)
+	if n := len(budget.Unpriced); n > 0 {
+		p.warn(0, "%d forest%s without an expected spend not counted against the budget: %s",
+			n, plural(n), strings.Join(budget.Unpriced, ", "))
+		p.info(1, "💡 Set one with: morpheus billing expect <forest-id> <amount>")
+	}
+	if err := budget.Err(); err != nil {
+		if !override {
+			return fmt.Errorf("%w: %s", ErrOverBudget, err)
+		}
+		p.warn(0, "%s (budget overridden)", err)
+		return nil
+	}
+	p.info(0, "💰 Budget: €%.2f of €%.2f/month after this change", budget.Projected, budget.Limit)
+	return nil
+}
+
+// provisionCost returns the expected monthly spend of a plant request for
+// nodeCount nodes
+func provisionCost(req ProvisionRequest, nodeCount int) float64 {
+	cost := req.ExpectedNodeCost * float64(nodeCount)
+	if req.FloatingIP != "" {
+		cost += req.FloatingIPCost
+	}
+	if req.LoadBalancer != nil {
+		cost += req.LoadBalancer.ExpectedCost
+	}
+	return cost
+}
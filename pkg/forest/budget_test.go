@@ -0,0 +1,49 @@
+package forest
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestProvisionChecksBudget(t *testing.T) {
+	p, prov, reg := newScaleTestProvisioner(t, 2)
+	p.config.Limits.MaxMonthlyCost = 20
+	f, _ := reg.GetForest("forest-1")
+	f.ExpectedNodeCost = 5
+	reg.UpdateForest(f)
+
+	req := ProvisionRequest{ForestID: "big", NodeCount: 2, Location: "fsn1", ExpectedNodeCost: 6}
+	err := p.Provision(context.Background(), req)
+	if !errors.Is(err, ErrOverBudget) {
+		t.Fatalf("Expected ErrOverBudget, got %v", err)
+	}
+	if len(prov.servers) != 2 {
+		t.Errorf("Expected no servers to be created, got %d", len(prov.servers)-2)
+	}
+
+	req.OverrideBudget = true
+	if err := p.Provision(context.Background(), req); err != nil {
+		t.Fatalf("Provision with override failed: %v", err)
+	}
+}
+
+func TestScaleChecksBudget(t *testing.T) {
+	p, prov, reg := newScaleTestProvisioner(t, 2)
+	p.config.Limits.MaxMonthlyCost = 20
+	f, _ := reg.GetForest("forest-1")
+	f.ExpectedNodeCost = 5
+	reg.UpdateForest(f)
+
+	// Two more nodes at €5 fit the €20 limit, a fifth does not
+	if _, err := p.Scale(context.Background(), ScaleRequest{ForestID: "forest-1", TargetCount: 4}); err != nil {
+		t.Fatalf("Scale within the budget failed: %v", err)
+	}
+	_, err := p.Scale(context.Background(), ScaleRequest{ForestID: "forest-1", TargetCount: 5})
+	if !errors.Is(err, ErrOverBudget) {
+		t.Fatalf("Expected ErrOverBudget, got %v", err)
+	}
+	if len(prov.servers) != 4 {
+		t.Errorf("Expected 4 servers, got %d", len(prov.servers))
+	}
+}
@@ -0,0 +1,183 @@
+package forest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"sort"
+
+	"github.com/nimsforest/morpheus/pkg/dns"
+)
+
+// Kinds of DNSIssue
+const (
+	DNSMissing   = "missing"    // A node has no record
+	DNSWrongIP   = "wrong-ip"   // A node's record points at another address
+	DNSOrphaned  = "orphaned"   // A record of a node that is gone
+	DNSUnknownIP = "unknown-ip" // A record points at no registered address
+)
+
+// DNSIssue is a difference between the registry and a DNS zone found by
+// ReconcileDNS
+type DNSIssue struct {
+	Kind     string `json:"kind"`
+	Zone     string `json:"zone"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Value    string `json:"value,omitempty"`    // In the zone
+	Expected string `json:"expected,omitempty"` // From the registry
+	ForestID string `json:"forest_id,omitempty"`
+}
+
+func (i DNSIssue) String() string {
+	name := i.Name + "." + i.Zone
+	switch i.Kind {
+	case DNSMissing:
+		return fmt.Sprintf("%s: no %s record %s (should point at %s)", i.ForestID, i.Type, name, i.Expected)
+	case DNSWrongIP:
+		return fmt.Sprintf("%s: %s %s points at %s, node has %s", i.ForestID, name, i.Type, i.Value, i.Expected)
+	case DNSOrphaned:
+		return fmt.Sprintf("%s: %s %s -> %s belongs to a node that is gone", i.ForestID, name, i.Type, i.Value)
+	}
+	return fmt.Sprintf("%s %s -> %s: no registered node or forest has this address", name, i.Type, i.Value)
+}
+
+// ReconcileDNS cross-references the A/AAAA records of zones with the
+// registry. The node records of each forest (see SyncDNS) are checked in
+// the configured DNS domain: missing ones, ones pointing at old addresses
+// and ones of nodes that are gone. In all zones (the DNS domain if none
+// are given), records pointing at addresses of no registered node, floating
+// IP or load balancer are reported as unknown. Nothing is changed.
+func (p *Provisioner) ReconcileDNS(ctx context.Context, zones []string) ([]DNSIssue, error) {
+	if p.dns == nil || p.config.DNS.Domain == "" {
+		return nil, fmt.Errorf("no DNS configured (set dns.domain)")
+	}
+	domain := p.config.DNS.Domain
+	if len(zones) == 0 {
+		zones = []string{domain}
+	}
+
+	forests := p.storage.ListForests()
+	sort.Slice(forests, func(i, j int) bool { return forests[i].ID < forests[j].ID })
+
+	var issues []DNSIssue
+	var errs []error
+	known := make(map[netip.Addr]bool)
+	reported := make(map[string]bool)
+	for _, f := range forests {
+		for _, ip := range []string{f.FloatingIP, f.LoadBalancerIPv4, f.LoadBalancerIPv6} {
+			addKnownIP(known, ip)
+		}
+		nodes, err := p.storage.GetNodes(f.ID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: failed to get nodes: %w", f.ID, err))
+			continue
+		}
+		for _, n := range nodes {
+			addKnownIP(known, n.IP)
+			for _, ip := range nodeAddresses(n) {
+				addKnownIP(known, ip)
+			}
+		}
+
+		changes, err := p.planDNS(ctx, f, nodes, len(nodes))
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range changes {
+			issue := DNSIssue{Zone: domain, Name: c.Name, Type: c.Type, Value: c.Old, Expected: c.New, ForestID: f.ID}
+			switch c.Action {
+			case "create":
+				issue.Kind = DNSMissing
+			case "update":
+				issue.Kind = DNSWrongIP
+			default:
+				issue.Kind = DNSOrphaned
+			}
+			issues = append(issues, issue)
+			reported[domain+" "+c.Name+" "+c.Type] = true
+		}
+	}
+
+	for _, zone := range zones {
+		records, err := p.dns.ListRecords(ctx, zone)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list records of %s: %w", zone, err))
+			continue
+		}
+		for _, r := range records {
+			if r.Type != dns.RecordTypeA && r.Type != dns.RecordTypeAAAA {
+				continue
+			}
+			if reported[zone+" "+r.Name+" "+string(r.Type)] {
+				continue
+			}
+			ip, err := netip.ParseAddr(r.Value)
+			if err == nil && known[ip.Unmap()] {
+				continue
+			}
+			issues = append(issues, DNSIssue{Kind: DNSUnknownIP, Zone: zone, Name: r.Name, Type: string(r.Type), Value: r.Value})
+		}
+	}
+	return issues, errors.Join(errs...)
+}
+
+// PruneDNS removes the values of unknown-ip issues from their records,
+// deleting records left without values. Other issues are fixed per forest
+// by SyncDNS.
+func (p *Provisioner) PruneDNS(ctx context.Context, issues []DNSIssue) error {
+	var zones []string
+	unknown := make(map[string]map[string][]string) // zone -> "name/type" -> values
+	for _, i := range issues {
+		if i.Kind != DNSUnknownIP {
+			continue
+		}
+		if unknown[i.Zone] == nil {
+			zones = append(zones, i.Zone)
+			unknown[i.Zone] = make(map[string][]string)
+		}
+		key := i.Name + "/" + i.Type
+		unknown[i.Zone][key] = append(unknown[i.Zone][key], i.Value)
+	}
+
+	var errs []error
+	for _, zone := range zones {
+		records, err := p.dns.ListRecords(ctx, zone)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list records of %s: %w", zone, err))
+			continue
+		}
+		var sets []dns.RecordSet
+		for _, set := range dns.GroupRecordSets(records) {
+			drop, ok := unknown[zone][set.Name+"/"+string(set.Type)]
+			if !ok {
+				continue
+			}
+			keep := dns.RecordSet{Name: set.Name, Type: set.Type, TTL: set.TTL}
+			for _, v := range set.Values {
+				if !slices.Contains(drop, v) {
+					keep.Values = append(keep.Values, v)
+				}
+			}
+			sets = append(sets, keep)
+		}
+
+		applied, err := dns.ApplyRecordSets(ctx, p.dns, zone, sets)
+		for _, c := range applied {
+			p.info(1, "🌐 DNS: %s", c)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// addKnownIP adds an address of the registry to known
+func addKnownIP(known map[netip.Addr]bool, ip string) {
+	if addr, err := netip.ParseAddr(ip); err == nil {
+		known[addr.Unmap()] = true
+	}
+}
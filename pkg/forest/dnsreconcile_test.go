@@ -0,0 +1,70 @@
+package forest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/dns"
+)
+
+func TestReconcileDNS(t *testing.T) {
+	p, _, reg := newScaleTestProvisioner(t, 2)
+	p.SetReporter(ReporterFunc(func(Event) {}))
+	ctx := context.Background()
+	zone := &syncDNS{gcDNS{records: []*dns.Record{
+		{Name: "forest-1-node-1", Type: dns.RecordTypeAAAA, Value: "::1"},
+		{Name: "forest-1-node-3", Type: dns.RecordTypeA, Value: "192.0.2.3"}, // Node that is gone
+		{Name: "www", Type: dns.RecordTypeA, Value: "192.0.2.80"},
+		{Name: "lb", Type: dns.RecordTypeA, Value: "192.0.2.10"},
+		{Name: "mail", Type: "MX", Value: "10 mail.example.com."},
+	}}}
+	p.dns = zone
+
+	if _, err := p.ReconcileDNS(ctx, nil); err == nil {
+		t.Error("ReconcileDNS() without dns.domain: expected error")
+	}
+	p.config.DNS.Domain = "example.com"
+
+	f, _ := reg.GetForest("forest-1")
+	f.LoadBalancerIPv4 = "192.0.2.10"
+	if err := reg.UpdateForest(f); err != nil {
+		t.Fatal(err)
+	}
+
+	issues, err := p.ReconcileDNS(ctx, nil)
+	if err != nil {
+		t.Fatalf("ReconcileDNS() error = %v", err)
+	}
+	want := []string{
+		"forest-1: no AAAA record forest-1-node-2.example.com (should point at ::1)",
+		"forest-1: forest-1-node-3.example.com A -> 192.0.2.3 belongs to a node that is gone",
+		"www.example.com A -> 192.0.2.80: no registered node or forest has this address",
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("issues = %v, want %v", issues, want)
+	}
+	for i, issue := range issues {
+		if issue.String() != want[i] {
+			t.Errorf("issue %d = %q, want %q", i, issue, want[i])
+		}
+	}
+	if len(zone.records) != 5 {
+		t.Errorf("ReconcileDNS() changed the zone: %d records", len(zone.records))
+	}
+
+	// Forest records are fixed by SyncDNS, unknown ones pruned
+	if _, err := p.SyncDNS(ctx, "forest-1", false); err != nil {
+		t.Fatalf("SyncDNS() error = %v", err)
+	}
+	if err := p.PruneDNS(ctx, issues); err != nil {
+		t.Fatalf("PruneDNS() error = %v", err)
+	}
+	if issues, _ := p.ReconcileDNS(ctx, nil); len(issues) != 0 {
+		t.Errorf("issues after fixing = %v, want none", issues)
+	}
+	for _, r := range zone.records {
+		if r.Name == "www" {
+			t.Errorf("www record was not pruned")
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package forest
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/nimsforest/morpheus/pkg/dns"
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// DNSChange is a change of a node record made by SyncDNS
+type DNSChange struct {
+	Action string `json:"action"` // create, update or delete
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Old    string `json:"old,omitempty"` // Values before (update, delete)
+	New    string `json:"new,omitempty"` // Value after (create, update)
+}
+
+func (c DNSChange) String() string {
+	switch c.Action {
+	case "create":
+		return fmt.Sprintf("+ %s %s %s", c.Name, c.Type, c.New)
+	case "delete":
+		return fmt.Sprintf("- %s %s %s", c.Name, c.Type, c.Old)
+	}
+	return fmt.Sprintf("~ %s %s %s -> %s", c.Name, c.Type, c.Old, c.New)
+}
+
+// SyncDNS reconciles the A/AAAA records of a forest's nodes with the
+// registry: each node has records named after it (naming.dns_record)
+// pointing at its current addresses. Missing records are created, records
+// of changed addresses updated and those of nodes that are gone deleted.
+// With dryRun it only returns the changes that would be made.
+func (p *Provisioner) SyncDNS(ctx context.Context, forestID string, dryRun bool) ([]DNSChange, error) {
+	if p.dns == nil || p.config.DNS.Domain == "" {
+		return nil, fmt.Errorf("no DNS configured (set dns.domain)")
+	}
+	f, err := p.storage.GetForest(forestID)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := p.storage.GetNodes(forestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+	changes, err := p.planDNS(ctx, f, nodes, len(nodes))
+	if err != nil || dryRun {
+		return changes, err
+	}
+	return changes, p.applyDNS(ctx, changes)
+}
+
+// planDNS returns the changes that make the zone hold records for the
+// first keep nodes and no others of the forest. Records are the forest's
+// if they are named like one of its nodes, or like node numbers beyond the
+// current ones, left behind by nodes removed out of band. The latter only
+// count if the name contains the forest ID, so records of other forests
+// named by a template without it are never touched.
+func (p *Provisioner) planDNS(ctx context.Context, f *storage.Forest, nodes []*storage.Node, keep int) ([]DNSChange, error) {
+	records, err := p.dns.ListRecords(ctx, p.config.DNS.Domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list DNS records: %w", err)
+	}
+	existing := make(map[string][]string)
+	for _, r := range records {
+		if r.Type == dns.RecordTypeA || r.Type == dns.RecordTypeAAAA {
+			key := r.Name + " " + string(r.Type)
+			existing[key] = append(existing[key], r.Value)
+		}
+	}
+
+	names := ForestNames(f)
+	var owned []string
+	wanted := make(map[string]string)
+	for i := 0; i < max(len(nodes), len(records)); i++ {
+		name := names.Record(i)
+		if i >= len(nodes) && !strings.Contains(name, f.ID) {
+			break
+		}
+		if !slices.Contains(owned, name) {
+			owned = append(owned, name)
+		}
+		if i < keep && i < len(nodes) {
+			for recordType, value := range nodeAddresses(nodes[i]) {
+				wanted[name+" "+string(recordType)] = value
+			}
+		}
+	}
+
+	var changes []DNSChange
+	for _, name := range owned {
+		for _, recordType := range []dns.RecordType{dns.RecordTypeA, dns.RecordTypeAAAA} {
+			key := name + " " + string(recordType)
+			have, want := existing[key], wanted[key]
+			change := DNSChange{Name: name, Type: string(recordType), Old: strings.Join(have, ","), New: want}
+			switch {
+			case want == "" && len(have) == 0:
+				continue
+			case want == "":
+				change.Action = "delete"
+			case len(have) == 0:
+				change.Action = "create"
+			case len(have) == 1 && sameIP(have[0], want):
+				continue
+			default:
+				change.Action = "update"
+			}
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// removeDNSRecords deletes all node records of a forest that is torn
+// down, including those of nodes the registry no longer knows. If the
+// zone cannot be listed, the nodes' records are deleted one by one.
+func (p *Provisioner) removeDNSRecords(ctx context.Context, forestID string, nodes []*storage.Node) {
+	f, err := p.storage.GetForest(forestID)
+	var changes []DNSChange
+	if err == nil {
+		changes, err = p.planDNS(ctx, f, nodes, 0)
+	}
+	if err != nil {
+		p.warn(1, "%s", err)
+		for i, node := range nodes {
+			p.deleteDNSRecords(ctx, forestID, node, i)
+		}
+		return
+	}
+	if err := p.applyDNS(ctx, changes); err != nil {
+		p.warn(1, "%s", err)
+	}
+}
+
+// applyDNS makes DNS changes; updates replace the record set. The
+// changes are applied as one batch, in as few calls as the provider allows.
+func (p *Provisioner) applyDNS(ctx context.Context, changes []DNSChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	sets := make([]dns.RecordSet, len(changes))
+	for i, c := range changes {
+		sets[i] = dns.RecordSet{Name: c.Name, Type: dns.RecordType(c.Type), TTL: p.config.DNS.TTL}
+		if c.Action != "delete" {
+			sets[i].Values = []string{c.New}
+		}
+	}
+
+	applied, err := dns.ApplyRecordSets(ctx, p.dns, p.config.DNS.Domain, sets)
+	done := make(map[string]bool)
+	for _, a := range applied {
+		done[a.Name+"/"+string(a.Type)] = true
+	}
+	for _, c := range changes {
+		if done[c.Name+"/"+c.Type] {
+			p.info(1, "🌐 DNS: %s", c)
+		}
+	}
+	return err
+}
+
+// nodeAddresses returns the A and AAAA values of a node's records. Nodes
+// registered before IPv4 and IPv6 were recorded separately only have IP.
+func nodeAddresses(node *storage.Node) map[dns.RecordType]string {
+	ipv4, ipv6 := node.IPv4, node.IPv6
+	if ipv4 == "" && ipv6 == "" {
+		if ip, err := netip.ParseAddr(node.IP); err == nil && ip.Is4() {
+			ipv4 = node.IP
+		} else {
+			ipv6 = node.IP
+		}
+	}
+	addresses := make(map[dns.RecordType]string)
+	if ipv4 != "" {
+		addresses[dns.RecordTypeA] = ipv4
+	}
+	if ipv6 != "" {
+		addresses[dns.RecordTypeAAAA] = machine.HostIPv6(ipv6)
+	}
+	return addresses
+}
+
+// sameIP reports whether two record values are the same address, however
+// they are written
+func sameIP(a, b string) bool {
+	ipA, errA := netip.ParseAddr(a)
+	ipB, errB := netip.ParseAddr(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return ipA == ipB
+}
@@ -0,0 +1,77 @@
+package forest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/dns"
+)
+
+// syncDNS is a zone that records can also be created in
+type syncDNS struct {
+	gcDNS
+}
+
+func (d *syncDNS) CreateRecord(ctx context.Context, req dns.CreateRecordRequest) (*dns.Record, error) {
+	r := &dns.Record{Name: req.Name, Type: req.Type, Value: req.Value, TTL: req.TTL}
+	d.records = append(d.records, r)
+	return r, nil
+}
+
+func TestSyncDNS(t *testing.T) {
+	p, _, reg := newScaleTestProvisioner(t, 2)
+	p.SetReporter(ReporterFunc(func(Event) {}))
+	ctx := context.Background()
+	zone := &syncDNS{gcDNS{records: []*dns.Record{
+		{Name: "forest-1-node-1", Type: dns.RecordTypeAAAA, Value: "::1"},
+		{Name: "forest-1-node-2", Type: dns.RecordTypeAAAA, Value: "2001:db8::1"}, // Old address
+		{Name: "forest-1-node-3", Type: dns.RecordTypeA, Value: "192.0.2.3"},      // Node that is gone
+		{Name: "www", Type: dns.RecordTypeA, Value: "192.0.2.80"},
+	}}}
+	p.dns = zone
+
+	if _, err := p.SyncDNS(ctx, "forest-1", false); err == nil {
+		t.Error("SyncDNS() without dns.domain: expected error")
+	}
+	p.config.DNS.Domain = "example.com"
+
+	nodes, _ := reg.GetNodes("forest-1")
+	nodes[1].IPv4 = "192.0.2.2"
+	if err := reg.UpdateNode(nodes[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := p.SyncDNS(ctx, "forest-1", true)
+	if err != nil {
+		t.Fatalf("SyncDNS() dry run error = %v", err)
+	}
+	want := []string{
+		"+ forest-1-node-2 A 192.0.2.2",
+		"~ forest-1-node-2 AAAA 2001:db8::1 -> ::1",
+		"- forest-1-node-3 A 192.0.2.3",
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("changes = %v, want %v", changes, want)
+	}
+	for i, c := range changes {
+		if c.String() != want[i] {
+			t.Errorf("change %d = %q, want %q", i, c, want[i])
+		}
+	}
+	if len(zone.records) != 4 {
+		t.Errorf("dry run changed the zone: %d records", len(zone.records))
+	}
+
+	if _, err := p.SyncDNS(ctx, "forest-1", false); err != nil {
+		t.Fatalf("SyncDNS() error = %v", err)
+	}
+	if changes, _ := p.SyncDNS(ctx, "forest-1", true); len(changes) != 0 {
+		t.Errorf("changes after sync = %v, want none", changes)
+	}
+
+	// Teardown removes all of the forest's records, and only those
+	p.removeDNSRecords(ctx, "forest-1", nodes)
+	if len(zone.records) != 1 || zone.records[0].Name != "www" {
+		t.Errorf("records after teardown = %v, want only www", zone.records)
+	}
+}
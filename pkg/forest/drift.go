@@ -0,0 +1,211 @@
+package forest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// DriftKind classifies a difference between the registry and the provider
+type DriftKind string
+
+const (
+	// DriftMissing means a registered node no longer exists at the provider
+	DriftMissing DriftKind = "missing"
+	// DriftIPChanged means a node's IPv4 or IPv6 address differs
+	DriftIPChanged DriftKind = "ip_changed"
+	// DriftLabelsChanged means a server's labels differ from the registered metadata
+	DriftLabelsChanged DriftKind = "labels_changed"
+	// DriftUntracked means a server labelled for the forest is not in the registry
+	DriftUntracked DriftKind = "untracked"
+)
+
+// Drift describes one discrepancy between the registry and actual provider state
+type Drift struct {
+	ForestID string    `json:"forest_id"`
+	NodeID   string    `json:"node_id"`
+	Kind     DriftKind `json:"kind"`
+	Field    string    `json:"field,omitempty"`
+	Expected string    `json:"registry,omitempty"` // Value in the registry
+	Actual   string    `json:"actual,omitempty"`   // Value at the provider
+
+	server *machine.Server // Current server state, used when repairing
+}
+
+// String returns a one-line human-readable description
+func (d Drift) String() string {
+	switch d.Kind {
+	case DriftMissing:
+		return fmt.Sprintf("%s: server no longer exists at provider", d.NodeID)
+	case DriftUntracked:
+		return fmt.Sprintf("%s: server exists at provider but is not in the registry (%s)", d.NodeID, d.Actual)
+	default:
+		return fmt.Sprintf("%s: %s changed: %q -> %q", d.NodeID, d.Field, d.Expected, d.Actual)
+	}
+}
+
+// DetectDrift compares the registry entries of a forest against the provider.
+// Servers are matched by ID; servers carrying the forest's labels but unknown
+// to the registry are reported as untracked.
+func DetectDrift(ctx context.Context, m machine.Provider, s storage.Registry, forestID string) ([]Drift, error) {
+	nodes, err := s.GetNodes(forestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+
+	servers, err := m.ListServers(ctx, map[string]string{
+		"managed-by": "morpheus",
+		"forest-id":  forestID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list servers: %w", err)
+	}
+	byID := make(map[string]*machine.Server, len(servers))
+	for _, srv := range servers {
+		byID[srv.ID] = srv
+	}
+
+	var drifts []Drift
+	tracked := make(map[string]bool, len(nodes))
+
+	for _, node := range nodes {
+		tracked[node.ID] = true
+
+		srv, ok := byID[node.ID]
+		if !ok {
+			// The label filter misses servers whose labels were changed, so look it up directly
+			srv, err = m.GetServer(ctx, node.ID)
+			if err != nil {
+				if errors.Is(err, machine.ErrNotFound) {
+					drifts = append(drifts, Drift{ForestID: forestID, NodeID: node.ID, Kind: DriftMissing})
+					continue
+				}
+				return nil, fmt.Errorf("failed to get server %s: %w", node.ID, err)
+			}
+		}
+
+		if node.IPv6 == "" && node.IPv4 == "" {
+			// Legacy entries only recorded a single IP
+			if node.IP != srv.PublicIPv6 && node.IP != srv.PublicIPv4 {
+				drifts = append(drifts, Drift{ForestID: forestID, NodeID: node.ID, Kind: DriftIPChanged,
+					Field: "ip", Expected: node.IP, Actual: srv.GetPreferredIP(), server: srv})
+			}
+		} else if node.IPv6 != srv.PublicIPv6 {
+			drifts = append(drifts, Drift{ForestID: forestID, NodeID: node.ID, Kind: DriftIPChanged,
+				Field: "ipv6", Expected: node.IPv6, Actual: srv.PublicIPv6, server: srv})
+		}
+		if (node.IPv6 != "" || node.IPv4 != "") && node.IPv4 != srv.PublicIPv4 {
+			drifts = append(drifts, Drift{ForestID: forestID, NodeID: node.ID, Kind: DriftIPChanged,
+				Field: "ipv4", Expected: node.IPv4, Actual: srv.PublicIPv4, server: srv})
+		}
+		if expected, actual := formatLabels(node.Metadata), formatLabels(srv.Labels); expected != actual {
+			drifts = append(drifts, Drift{ForestID: forestID, NodeID: node.ID, Kind: DriftLabelsChanged,
+				Field: "labels", Expected: expected, Actual: actual, server: srv})
+		}
+	}
+
+	for _, srv := range servers {
+		if !tracked[srv.ID] {
+			drifts = append(drifts, Drift{ForestID: forestID, NodeID: srv.ID, Kind: DriftUntracked,
+				Actual: srv.Name, server: srv})
+		}
+	}
+
+	return drifts, nil
+}
+
+// RepairDrift updates the registry so it matches the provider state described
+// by drifts (as returned by DetectDrift). Missing nodes are removed, changed
+// nodes are updated and untracked servers are registered.
+func RepairDrift(s storage.Registry, forestID string, drifts []Drift) error {
+	nodes, err := s.GetNodes(forestID)
+	if err != nil {
+		return fmt.Errorf("failed to get nodes: %w", err)
+	}
+	byID := make(map[string]storage.Node, len(nodes))
+	for _, n := range nodes {
+		byID[n.ID] = *n
+	}
+
+	changed := make(map[string]bool)
+	for _, d := range drifts {
+		switch d.Kind {
+		case DriftMissing:
+			if err := s.DeleteNode(forestID, d.NodeID); err != nil {
+				return fmt.Errorf("failed to remove node %s: %w", d.NodeID, err)
+			}
+		case DriftIPChanged, DriftLabelsChanged:
+			if changed[d.NodeID] || d.server == nil {
+				continue
+			}
+			node, ok := byID[d.NodeID]
+			if !ok {
+				continue
+			}
+			node.IPv6 = d.server.PublicIPv6
+			node.IPv4 = d.server.PublicIPv4
+			node.IP = d.server.GetPreferredIP()
+			node.Metadata = d.server.Labels
+			if err := s.UpdateNode(&node); err != nil {
+				return fmt.Errorf("failed to update node %s: %w", d.NodeID, err)
+			}
+			changed[d.NodeID] = true
+		case DriftUntracked:
+			if d.server == nil {
+				continue
+			}
+			status := "active"
+			if d.server.State != machine.ServerStateRunning {
+				status = string(d.server.State)
+			}
+			if err := s.RegisterNode(&storage.Node{
+				ID:         d.server.ID,
+				ForestID:   forestID,
+				IP:         d.server.GetPreferredIP(),
+				IPv6:       d.server.PublicIPv6,
+				IPv4:       d.server.PublicIPv4,
+				Location:   d.server.Location,
+				Role:       d.server.Labels["role"],
+				ServerType: d.server.ServerType,
+				Status:     status,
+				Metadata:   d.server.Labels,
+			}); err != nil {
+				return fmt.Errorf("failed to register node %s: %w", d.NodeID, err)
+			}
+		}
+	}
+
+	// Keep the forest's node count in line with the repaired node list
+	f, err := s.GetForest(forestID)
+	if err != nil {
+		return err
+	}
+	nodes, err = s.GetNodes(forestID)
+	if err != nil {
+		return err
+	}
+	if f.NodeCount != len(nodes) {
+		updated := *f
+		updated.NodeCount = len(nodes)
+		if err := s.UpdateForest(&updated); err != nil {
+			return fmt.Errorf("failed to update forest: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// formatLabels renders labels as a sorted "k=v,k=v" string for comparison
+func formatLabels(labels map[string]string) string {
+	parts := make([]string, 0, len(labels))
+	for k, v := range labels {
+		parts = append(parts, k+"="+v)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
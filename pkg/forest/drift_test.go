@@ -0,0 +1,82 @@
+package forest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+func TestDetectAndRepairDrift(t *testing.T) {
+	_, prov, reg := newScaleTestProvisioner(t, 3)
+	ctx := context.Background()
+
+	// Out-of-band changes at the provider
+	delete(prov.servers, "server-2")
+	prov.servers["server-1"].PublicIPv4 = "203.0.113.10"
+	prov.servers["server-4"] = &machine.Server{
+		ID:         "server-4",
+		Name:       "forest-1-node-4",
+		PublicIPv6: "2001:db8::4",
+		State:      machine.ServerStateRunning,
+		Labels:     map[string]string{"managed-by": "morpheus", "forest-id": "forest-1"},
+	}
+
+	drifts, err := DetectDrift(ctx, prov, reg, "forest-1")
+	if err != nil {
+		t.Fatalf("DetectDrift() error = %v", err)
+	}
+
+	kinds := make(map[DriftKind]string)
+	for _, d := range drifts {
+		kinds[d.Kind] = d.NodeID
+	}
+	if len(drifts) != 3 {
+		t.Errorf("Expected 3 drifts, got %d: %v", len(drifts), drifts)
+	}
+	if kinds[DriftMissing] != "server-2" {
+		t.Errorf("Expected server-2 to be missing, got %v", drifts)
+	}
+	if kinds[DriftIPChanged] != "server-1" {
+		t.Errorf("Expected server-1 IP change, got %v", drifts)
+	}
+	if kinds[DriftUntracked] != "server-4" {
+		t.Errorf("Expected server-4 to be untracked, got %v", drifts)
+	}
+
+	if err := RepairDrift(reg, "forest-1", drifts); err != nil {
+		t.Fatalf("RepairDrift() error = %v", err)
+	}
+
+	nodes, _ := reg.GetNodes("forest-1")
+	ids := make(map[string]string)
+	for _, n := range nodes {
+		ids[n.ID] = n.IPv4
+	}
+	if len(nodes) != 3 {
+		t.Errorf("Expected 3 nodes after repair, got %d", len(nodes))
+	}
+	if _, ok := ids["server-2"]; ok {
+		t.Error("Missing node server-2 should have been removed")
+	}
+	if ids["server-1"] != "203.0.113.10" {
+		t.Errorf("server-1 IPv4 = %q, want %q", ids["server-1"], "203.0.113.10")
+	}
+	if _, ok := ids["server-4"]; !ok {
+		t.Error("Untracked server-4 should have been registered")
+	}
+
+	f, _ := reg.GetForest("forest-1")
+	if f.NodeCount != 3 {
+		t.Errorf("NodeCount = %d, want 3", f.NodeCount)
+	}
+
+	// A second pass finds nothing
+	drifts, err = DetectDrift(ctx, prov, reg, "forest-1")
+	if err != nil {
+		t.Fatalf("DetectDrift() error = %v", err)
+	}
+	if len(drifts) != 0 {
+		t.Errorf("Expected no drift after repair, got %v", drifts)
+	}
+}
@@ -0,0 +1,275 @@
+package forest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/dns"
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// FailoverRequest selects the node to move a forest's floating IP to
+type FailoverRequest struct {
+	ForestID string
+	// Target is a node ID, IP address, node name or DNS record name
+	// (forest-1-node-2 by default) or 1-based node number
+	Target string
+	// Wait, if positive, is how long to wait for the primary DNS record
+	// to resolve to the node; not resolving in time is an error
+	Wait time.Duration
+}
+
+// waitForRecord polls public resolvers for a record; replaced in tests
+var waitForRecord = dns.WaitForRecord
+
+// Failover moves the forest's floating IP to another node and points the
+// forest's primary DNS record at it.
+func (p *Provisioner) Failover(ctx context.Context, req FailoverRequest) (*storage.Node, error) {
+	forestID := req.ForestID
+	f, err := p.storage.GetForest(forestID)
+	if err != nil {
+		return nil, fmt.Errorf("forest not found: %w", err)
+	}
+	if f.FloatingIPID == "" {
+		return nil, fmt.Errorf("forest %s has no floating IP", forestID)
+	}
+	if _, ok := p.machine.(machine.FloatingIPManager); !ok {
+		return nil, fmt.Errorf("machine provider %s does not support floating IPs", p.config.GetMachineProvider())
+	}
+	if req.Wait > 0 && (p.dns == nil || p.config.DNS.Domain == "") {
+		return nil, fmt.Errorf("cannot wait for the primary record: no DNS provider or domain configured")
+	}
+
+	nodes, err := p.storage.GetNodes(forestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+	index := findNode(ForestNames(f), nodes, req.Target)
+	if index < 0 {
+		return nil, fmt.Errorf("node %s not found in forest %s", req.Target, forestID)
+	}
+	node := nodes[index]
+
+	p.info(0, "🔀 Moving floating IP %s to %s...", f.FloatingIP, node.ID)
+	if err := p.assignFloatingIP(ctx, f, node.ID, index); err != nil {
+		return nil, err
+	}
+
+	if req.Wait > 0 {
+		if err := p.waitForPrimary(ctx, f, index, req.Wait); err != nil {
+			return nil, fmt.Errorf("floating IP moved to %s, but %w", node.ID, err)
+		}
+	}
+	return node, nil
+}
+
+// waitForPrimary waits until the forest's primary record resolves to the
+// record of the node at nodeIndex
+func (p *Provisioner) waitForPrimary(ctx context.Context, f *storage.Forest, nodeIndex int, timeout time.Duration) error {
+	domain := p.config.DNS.Domain
+	names := ForestNames(f)
+	fqdn := fmt.Sprintf("%s.%s", names.Primary(), domain)
+	target := fmt.Sprintf("%s.%s.", names.Record(nodeIndex), domain)
+
+	p.info(1, "⏳ Waiting up to %s for %s to resolve to %s...", timeout, fqdn, target)
+	err := waitForRecord(ctx, fqdn, dns.RecordTypeCNAME, target, dns.WaitOptions{
+		Timeout: timeout,
+		Progress: func(wp dns.WaitProgress) {
+			if len(wp.Pending) > 0 {
+				p.info(2, "%d resolver(s) ready, waiting... (%s)", len(wp.Matched), wp.Elapsed.Round(time.Second))
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("the primary record did not propagate: %w", err)
+	}
+	p.info(1, "🌐 %s resolves to %s", fqdn, target)
+	return nil
+}
+
+// findNode returns the index of the node matching target, or -1
+func findNode(names Names, nodes []*storage.Node, target string) int {
+	for i, node := range nodes {
+		if node.ID == target || node.IP == target || node.IPv4 == target || node.IPv6 == target {
+			return i
+		}
+	}
+	// Nodes are numbered in registration order, as in their names
+	for i := range nodes {
+		if target == names.Node(i) || target == names.Record(i) || target == strconv.Itoa(i+1) {
+			return i
+		}
+	}
+	return -1
+}
+
+// createFloatingIP allocates the forest's floating IP. It is not assigned
+// yet, so every node can be configured with its address at creation.
+func (p *Provisioner) createFloatingIP(ctx context.Context, req ProvisionRequest) (*machine.FloatingIP, error) {
+	fim, ok := p.machine.(machine.FloatingIPManager)
+	if !ok {
+		return nil, fmt.Errorf("machine provider does not support floating IPs")
+	}
+
+	names := requestNames(req)
+	p.report(Event{Type: StepStarted, Step: StepFloatingIP, Message: fmt.Sprintf("Allocating %s floating IP", req.FloatingIP)})
+	fip, err := fim.CreateFloatingIP(ctx, machine.CreateFloatingIPRequest{
+		Name:     names.FloatingIP(),
+		Type:     req.FloatingIP,
+		Location: req.Location,
+		Labels: map[string]string{
+			"managed-by": "morpheus",
+			"forest-id":  req.ForestID,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.report(Event{Type: StepCompleted, Step: StepFloatingIP, Message: "Floating IP: " + fip.IP})
+
+	if p.dns != nil && p.config.DNS.Domain != "" {
+		recordType := dns.RecordTypeA
+		if fip.Type == "ipv6" {
+			recordType = dns.RecordTypeAAAA
+		}
+		_, err := p.dns.UpsertRecord(ctx, dns.CreateRecordRequest{
+			Domain: p.config.DNS.Domain,
+			Name:   names.ForestRecord(),
+			Type:   recordType,
+			Value:  fip.IP,
+			TTL:    p.config.DNS.TTL,
+		})
+		if err != nil {
+			p.warn(1, "failed to create %s record: %s", recordType, err)
+		} else {
+			p.info(1, "🌐 DNS: %s.%s -> %s", names.ForestRecord(), p.config.DNS.Domain, fip.IP)
+		}
+	}
+
+	return fip, nil
+}
+
+// assignFloatingIP routes the forest's floating IP to a node and points the
+// forest's primary CNAME at that node's record
+func (p *Provisioner) assignFloatingIP(ctx context.Context, f *storage.Forest, serverID string, nodeIndex int) error {
+	fim, ok := p.machine.(machine.FloatingIPManager)
+	if !ok {
+		return fmt.Errorf("machine provider does not support floating IPs")
+	}
+
+	if err := fim.AssignFloatingIP(ctx, f.FloatingIPID, serverID); err != nil {
+		return err
+	}
+	p.report(Event{Type: StepCompleted, Step: StepFloatingIP, Message: fmt.Sprintf("Floating IP %s assigned to %s", f.FloatingIP, serverID)})
+
+	if p.dns == nil || p.config.DNS.Domain == "" {
+		return nil
+	}
+
+	domain := p.config.DNS.Domain
+	names := ForestNames(f)
+	recordName := names.Primary()
+	target := fmt.Sprintf("%s.%s.", names.Record(nodeIndex), domain)
+
+	// Replace the previous primary record, if any
+	_, err := p.dns.UpsertRecord(ctx, dns.CreateRecordRequest{
+		Domain: domain,
+		Name:   recordName,
+		Type:   dns.RecordTypeCNAME,
+		Value:  target,
+		TTL:    p.config.DNS.TTL,
+	})
+	if err != nil {
+		p.warn(1, "failed to update primary record: %s", err)
+	} else {
+		p.info(1, "🌐 DNS: %s.%s -> %s", recordName, domain, target)
+	}
+	return nil
+}
+
+// reassignFloatingIP fails the forest's floating IP over to its first node
+// when the node holding it was removed
+func (p *Provisioner) reassignFloatingIP(ctx context.Context, forestID string, removed []string) {
+	f, err := p.storage.GetForest(forestID)
+	if err != nil || f.FloatingIPID == "" {
+		return
+	}
+	fim, ok := p.machine.(machine.FloatingIPManager)
+	if !ok {
+		return
+	}
+
+	ips, err := fim.ListFloatingIPs(ctx, map[string]string{"forest-id": forestID})
+	if err != nil {
+		p.warn(1, "failed to list floating IPs: %s", err)
+		return
+	}
+	holder, found := "", false
+	for _, ip := range ips {
+		if ip.ID == f.FloatingIPID {
+			holder, found = ip.ServerID, true
+		}
+	}
+	if !found {
+		return
+	}
+	if holder != "" {
+		holderRemoved := false
+		for _, id := range removed {
+			holderRemoved = holderRemoved || id == holder
+		}
+		if !holderRemoved {
+			return
+		}
+	}
+
+	nodes, err := p.storage.GetNodes(forestID)
+	if err != nil || len(nodes) == 0 {
+		return
+	}
+	p.info(1, "🔀 Primary node removed, failing over to %s", nodes[0].ID)
+	if err := p.assignFloatingIP(ctx, f, nodes[0].ID, 0); err != nil {
+		p.warn(1, "failed to reassign floating IP: %s", err)
+	}
+}
+
+// deleteFloatingIPs releases the forest's floating IPs and removes their
+// DNS records
+func (p *Provisioner) deleteFloatingIPs(ctx context.Context, forestID string) {
+	fim, ok := p.machine.(machine.FloatingIPManager)
+	if !ok {
+		return
+	}
+	ips, err := fim.ListFloatingIPs(ctx, map[string]string{
+		"managed-by": "morpheus",
+		"forest-id":  forestID,
+	})
+	if err != nil {
+		p.warn(0, "failed to list floating IPs: %s", err)
+		return
+	}
+
+	names := p.names(forestID)
+	for _, ip := range ips {
+		e := p.deleting("", 0, 0, "Releasing floating IP %s", ip.IP)
+		err := fim.DeleteFloatingIP(ctx, ip.ID)
+		p.deleted(e, err)
+		if err != nil {
+			continue
+		}
+
+		if p.dns == nil || p.config.DNS.Domain == "" {
+			continue
+		}
+		recordType := dns.RecordTypeA
+		if ip.Type == "ipv6" {
+			recordType = dns.RecordTypeAAAA
+		}
+		// The records may never have been created; errors are expected
+		p.dns.DeleteRecord(ctx, p.config.DNS.Domain, names.ForestRecord(), string(recordType))
+		p.dns.DeleteRecord(ctx, p.config.DNS.Domain, names.Primary(), string(dns.RecordTypeCNAME))
+	}
+}
@@ -0,0 +1,95 @@
+package forest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/dns"
+)
+
+// failoverDNS is a zone whose records are served to resolvers unless it is
+// stuck, when updates are accepted but resolvers keep the old answers
+type failoverDNS struct {
+	syncDNS
+	stuck bool
+}
+
+func (d *failoverDNS) UpsertRecord(ctx context.Context, req dns.CreateRecordRequest) (*dns.Record, error) {
+	r := &dns.Record{Name: req.Name, Type: req.Type, Value: req.Value, TTL: req.TTL}
+	if d.stuck {
+		return r, nil
+	}
+	for i, old := range d.records {
+		if old.Name == req.Name && old.Type == req.Type {
+			d.records[i] = r
+			return r, nil
+		}
+	}
+	d.records = append(d.records, r)
+	return r, nil
+}
+
+// resolve polls the zone like dns.WaitForRecord polls resolvers
+func (d *failoverDNS) resolve(ctx context.Context, fqdn string, recordType dns.RecordType, value string, opts dns.WaitOptions) error {
+	name := strings.TrimSuffix(fqdn, ".example.com")
+	deadline := time.Now().Add(opts.Timeout)
+	for time.Now().Before(deadline) {
+		for _, r := range d.records {
+			if r.Name == name && r.Type == recordType && r.Value == value {
+				return nil
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fmt.Errorf("timed out waiting for %s %s", fqdn, recordType)
+}
+
+func TestFailoverWait(t *testing.T) {
+	p, prov, st := newScaleTestProvisioner(t, 0)
+	p.SetReporter(ReporterFunc(func(Event) {}))
+	ctx := context.Background()
+
+	if _, err := p.Failover(ctx, FailoverRequest{ForestID: "ha", Target: "2", Wait: time.Second}); err == nil {
+		t.Error("Expected error failing over a missing forest")
+	}
+	if err := p.Provision(ctx, ProvisionRequest{ForestID: "ha", NodeCount: 2, Location: "fsn1", FloatingIP: "ipv4"}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+	if _, err := p.Failover(ctx, FailoverRequest{ForestID: "ha", Target: "2", Wait: time.Second}); err == nil {
+		t.Error("Expected error waiting without a DNS domain")
+	}
+
+	zone := &failoverDNS{}
+	p.dns = zone
+	p.config.DNS.Domain = "example.com"
+	defer func() { waitForRecord = dns.WaitForRecord }()
+	waitForRecord = zone.resolve
+
+	f, _ := st.GetForest("ha")
+	nodes, _ := st.GetNodes("ha")
+	node, err := p.Failover(ctx, FailoverRequest{ForestID: "ha", Target: "2", Wait: time.Second})
+	if err != nil {
+		t.Fatalf("Failover() error = %v", err)
+	}
+	if node.ID != nodes[1].ID {
+		t.Errorf("Failover() picked %q, want %q", node.ID, nodes[1].ID)
+	}
+	primary := ForestNames(f).Primary()
+	if len(zone.records) != 1 || zone.records[0].Name != primary || zone.records[0].Value != "ha-node-2.example.com." {
+		t.Errorf("records = %+v, want %s pointing at ha-node-2", zone.records, primary)
+	}
+
+	// Resolvers that never see the new record time the failover out, with
+	// the floating IP moved all the same
+	zone.stuck = true
+	_, err = p.Failover(ctx, FailoverRequest{ForestID: "ha", Target: "1", Wait: 100 * time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("Failover() error = %v, want a timeout", err)
+	}
+	if fip := prov.fips[f.FloatingIPID]; fip.ServerID != nodes[0].ID {
+		t.Errorf("Floating IP assigned to %q, want %q", fip.ServerID, nodes[0].ID)
+	}
+}
@@ -0,0 +1,297 @@
+package forest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nimsforest/morpheus/pkg/dns"
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// Kinds of orphans found by FindOrphans
+const (
+	OrphanServer         = "server"
+	OrphanVolume         = "volume"
+	OrphanLoadBalancer   = "load_balancer"
+	OrphanFloatingIP     = "floating_ip"
+	OrphanPlacementGroup = "placement_group"
+	OrphanSSHKey         = "ssh_key"
+	OrphanFirewall       = "firewall"
+	OrphanDNSRecord      = "dns_record"
+	OrphanNode           = "node"   // Registry entry of a server that is gone
+	OrphanForest         = "forest" // Registry entry of a forest with no servers left
+)
+
+// Orphan is a provider resource morpheus created that the registry no longer
+// knows about, or a registry entry whose resource is gone
+type Orphan struct {
+	Kind     string `json:"kind"`
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	ForestID string `json:"forest_id,omitempty"`
+	Reason   string `json:"reason"`
+
+	recordType string // For DNS records
+}
+
+// Title names the orphan, e.g. "server web-1 (42)"
+func (o Orphan) Title() string {
+	name := o.ID
+	if o.Name != "" && o.Name != o.ID {
+		name = fmt.Sprintf("%s (%s)", o.Name, o.ID)
+	}
+	return strings.ReplaceAll(o.Kind, "_", " ") + " " + name
+}
+
+// InRegistry reports whether deleting the orphan only changes the registry
+func (o Orphan) InRegistry() bool {
+	return o.Kind == OrphanNode || o.Kind == OrphanForest
+}
+
+// morpheusLabels selects the resources morpheus created
+var morpheusLabels = map[string]string{"managed-by": "morpheus"}
+
+// generatedForestID matches the IDs plant and the worker generate, used to
+// recognize records of forests the provider no longer knows either
+var generatedForestID = regexp.MustCompile(`^forest-\d+(-[0-9a-f]{8})?$`)
+
+// FindOrphans looks for resources labelled as morpheus' whose forest is not
+// in the registry, servers of known forests that were never registered,
+// rotation leftovers among the SSH keys, DNS records of unknown forests, and
+// registry entries of forests (in forests) whose servers are gone.
+// Forests that are still being planted are left alone.
+func (p *Provisioner) FindOrphans(ctx context.Context, forests []*storage.Forest) ([]Orphan, error) {
+	known := make(map[string]bool)
+	busy := make(map[string]bool)
+	for _, f := range p.storage.ListForests() {
+		known[f.ID] = true
+		if f.Status == "provisioning" {
+			busy[f.ID] = true
+		}
+	}
+	labelled := make(map[string]bool) // Forest IDs seen in provider labels
+
+	var orphans []Orphan
+	unknown := func(kind, id, name string, labels map[string]string) {
+		forestID := labels["forest-id"]
+		if forestID == "" {
+			return
+		}
+		labelled[forestID] = true
+		if !known[forestID] {
+			orphans = append(orphans, Orphan{Kind: kind, ID: id, Name: name, ForestID: forestID,
+				Reason: fmt.Sprintf("forest %s is not in the registry", forestID)})
+		}
+	}
+
+	servers, err := p.machine.ListServers(ctx, morpheusLabels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list servers: %w", err)
+	}
+	registered := make(map[string]bool)
+	for _, f := range forests {
+		nodes, err := p.storage.GetNodes(f.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get nodes of %s: %w", f.ID, err)
+		}
+		for _, n := range nodes {
+			registered[n.ID] = true
+		}
+	}
+	serverIDs := make(map[string]bool)
+	for _, s := range servers {
+		serverIDs[s.ID] = true
+		forestID := s.Labels["forest-id"]
+		if known[forestID] && !busy[forestID] && !registered[s.ID] && inForests(forests, forestID) {
+			orphans = append(orphans, Orphan{Kind: OrphanServer, ID: s.ID, Name: s.Name, ForestID: forestID,
+				Reason: "not registered in its forest"})
+			continue
+		}
+		unknown(OrphanServer, s.ID, s.Name, s.Labels)
+	}
+
+	if vm, ok := p.machine.(machine.VolumeManager); ok {
+		volumes, err := vm.ListVolumes(ctx, morpheusLabels)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list volumes: %w", err)
+		}
+		for _, v := range volumes {
+			unknown(OrphanVolume, v.ID, v.Name, v.Labels)
+		}
+	}
+	if lbm, ok := p.machine.(machine.LoadBalancerManager); ok {
+		lbs, err := lbm.ListLoadBalancers(ctx, morpheusLabels)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list load balancers: %w", err)
+		}
+		for _, lb := range lbs {
+			unknown(OrphanLoadBalancer, lb.ID, lb.Name, lb.Labels)
+		}
+	}
+	if fim, ok := p.machine.(machine.FloatingIPManager); ok {
+		fips, err := fim.ListFloatingIPs(ctx, morpheusLabels)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list floating IPs: %w", err)
+		}
+		for _, fip := range fips {
+			unknown(OrphanFloatingIP, fip.ID, fip.Name, fip.Labels)
+		}
+	}
+	if pgm, ok := p.machine.(machine.PlacementGroupManager); ok {
+		groups, err := pgm.ListPlacementGroups(ctx, morpheusLabels)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list placement groups: %w", err)
+		}
+		for _, g := range groups {
+			unknown(OrphanPlacementGroup, g.ID, g.Name, g.Labels)
+		}
+	}
+	if fwm, ok := p.machine.(machine.FirewallManager); ok {
+		firewalls, err := fwm.ListFirewalls(ctx, morpheusLabels)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list firewalls: %w", err)
+		}
+		for _, fw := range firewalls {
+			unknown(OrphanFirewall, fw.ID, fw.Name, fw.Labels)
+		}
+	}
+	if km, ok := p.machine.(machine.SSHKeyManager); ok {
+		keys, err := km.ListSSHKeys(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list SSH keys: %w", err)
+		}
+		// An interrupted "keys rotate" leaves its temporary key behind
+		rotating := regexp.MustCompile(`^` + regexp.QuoteMeta(p.config.GetSSHKeyName()) + `-rotating-\d+$`)
+		for _, k := range keys {
+			if rotating.MatchString(k.Name) {
+				orphans = append(orphans, Orphan{Kind: OrphanSSHKey, ID: k.ID, Name: k.Name,
+					Reason: "left over from an interrupted key rotation"})
+				continue
+			}
+			if k.Labels["managed-by"] == "morpheus" {
+				unknown(OrphanSSHKey, k.ID, k.Name, k.Labels)
+			}
+		}
+	}
+
+	if p.dns != nil && p.config.DNS.Domain != "" {
+		records, err := p.dns.ListRecords(ctx, p.config.DNS.Domain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list DNS records: %w", err)
+		}
+		patterns := recordPatterns(p.config.Naming)
+		for _, r := range records {
+			if r.Type != dns.RecordTypeA && r.Type != dns.RecordTypeAAAA && r.Type != dns.RecordTypeCNAME {
+				continue
+			}
+			forestID := recordForestID(patterns, r.Name)
+			if known[forestID] || !(labelled[forestID] || generatedForestID.MatchString(forestID)) {
+				continue
+			}
+			orphans = append(orphans, Orphan{Kind: OrphanDNSRecord, ID: fmt.Sprintf("%s %s", r.Name, r.Type),
+				Name: r.Value, ForestID: forestID, recordType: string(r.Type),
+				Reason: fmt.Sprintf("forest %s is not in the registry", forestID)})
+		}
+	}
+
+	// The other way round: registry entries whose servers are gone
+	for _, f := range forests {
+		if busy[f.ID] {
+			continue
+		}
+		nodes, _ := p.storage.GetNodes(f.ID)
+		gone := 0
+		for _, n := range nodes {
+			if serverIDs[n.ID] {
+				continue
+			}
+			// The label filter misses servers whose labels were changed
+			if _, err := p.machine.GetServer(ctx, n.ID); !errors.Is(err, machine.ErrNotFound) {
+				continue
+			}
+			gone++
+			orphans = append(orphans, Orphan{Kind: OrphanNode, ID: n.ID, ForestID: f.ID,
+				Reason: "server no longer exists at the provider"})
+		}
+		if gone > 0 && gone == len(nodes) {
+			orphans = append(orphans, Orphan{Kind: OrphanForest, ID: f.ID, ForestID: f.ID,
+				Reason: "none of its servers exist at the provider"})
+		}
+	}
+
+	return orphans, nil
+}
+
+func inForests(forests []*storage.Forest, forestID string) bool {
+	for _, f := range forests {
+		if f.ID == forestID {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteOrphans deletes provider resources and removes registry entries as
+// found by FindOrphans, and returns how many could not be deleted. Servers
+// go first so the volumes, placement groups and firewalls they use are free.
+func (p *Provisioner) DeleteOrphans(ctx context.Context, orphans []Orphan) int {
+	order := map[string]int{OrphanLoadBalancer: 0, OrphanFloatingIP: 1, OrphanServer: 2}
+	sorted := append([]Orphan(nil), orphans...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		oi, ok := order[sorted[i].Kind]
+		if !ok {
+			oi = len(order)
+		}
+		oj, ok := order[sorted[j].Kind]
+		if !ok {
+			oj = len(order)
+		}
+		return oi < oj
+	})
+
+	failed := 0
+	for i, o := range sorted {
+		e := p.deleting(o.ID, i+1, len(sorted), "Deleting %s", o.Title())
+		err := p.deleteOrphan(ctx, o)
+		p.deleted(e, err)
+		if err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+func (p *Provisioner) deleteOrphan(ctx context.Context, o Orphan) error {
+	switch o.Kind {
+	case OrphanServer:
+		p.removeInventory(ctx, o.ID)
+		return p.machine.DeleteServer(ctx, o.ID)
+	case OrphanVolume:
+		return p.machine.(machine.VolumeManager).DeleteVolume(ctx, o.ID)
+	case OrphanLoadBalancer:
+		return p.machine.(machine.LoadBalancerManager).DeleteLoadBalancer(ctx, o.ID)
+	case OrphanFloatingIP:
+		return p.machine.(machine.FloatingIPManager).DeleteFloatingIP(ctx, o.ID)
+	case OrphanPlacementGroup:
+		return p.machine.(machine.PlacementGroupManager).DeletePlacementGroup(ctx, o.ID)
+	case OrphanFirewall:
+		return p.machine.(machine.FirewallManager).DeleteFirewall(ctx, o.ID)
+	case OrphanSSHKey:
+		return p.machine.(machine.SSHKeyManager).DeleteSSHKey(ctx, o.Name)
+	case OrphanDNSRecord:
+		name, _, _ := strings.Cut(o.ID, " ")
+		return p.dns.DeleteRecord(ctx, p.config.DNS.Domain, name, o.recordType)
+	case OrphanNode:
+		return p.storage.DeleteNode(o.ForestID, o.ID)
+	case OrphanForest:
+		p.removeNATSState(ctx, o.ID)
+		p.removeMeshState(ctx, o.ID)
+		return p.storage.DeleteForest(o.ID)
+	}
+	return fmt.Errorf("unknown kind of orphan: %s", o.Kind)
+}
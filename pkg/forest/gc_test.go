@@ -0,0 +1,111 @@
+package forest
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/dns"
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// gcDNS serves and deletes records of one domain
+type gcDNS struct {
+	dns.Provider
+	records []*dns.Record
+}
+
+func (d *gcDNS) ListRecords(ctx context.Context, domain string) ([]*dns.Record, error) {
+	return d.records, nil
+}
+
+func (d *gcDNS) DeleteRecord(ctx context.Context, domain, name, recordType string) error {
+	for i, r := range d.records {
+		if r.Name == name && string(r.Type) == recordType {
+			d.records = append(d.records[:i], d.records[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func TestFindAndDeleteOrphans(t *testing.T) {
+	p, prov, reg := newScaleTestProvisioner(t, 1)
+	p.SetReporter(ReporterFunc(func(Event) {}))
+	records := &gcDNS{records: []*dns.Record{
+		{Name: "forest-1-node-1", Type: dns.RecordTypeA, Value: "1.2.3.4"},
+		{Name: "forest-77-node-1", Type: dns.RecordTypeAAAA, Value: "::1"},
+		{Name: "forest-77", Type: dns.RecordTypeA, Value: "5.6.7.8"},
+		{Name: "forest-1700000000-0a1b2c3d-primary", Type: dns.RecordTypeCNAME, Value: "forest-1700000000-0a1b2c3d-node-1.example.com."},
+		{Name: "www", Type: dns.RecordTypeA, Value: "9.9.9.9"},
+	}}
+	p.dns = records
+	p.config.DNS.Domain = "example.com"
+	ctx := context.Background()
+
+	labels := func(forestID string) map[string]string {
+		return map[string]string{"managed-by": "morpheus", "forest-id": forestID}
+	}
+	prov.CreateServer(ctx, machine.CreateServerRequest{Name: "forest-1-node-2", Labels: labels("forest-1")})
+	prov.CreateServer(ctx, machine.CreateServerRequest{Name: "forest-77-node-1", Labels: labels("forest-77")})
+	prov.CreateServer(ctx, machine.CreateServerRequest{Name: "unmanaged"})
+	prov.CreateVolume(ctx, machine.CreateVolumeRequest{Name: "forest-77-node-1-data", Labels: labels("forest-77")})
+
+	// A forest whose only server was deleted behind morpheus' back
+	reg.RegisterForest(&storage.Forest{ID: "forest-2", NodeCount: 1, Status: "active"})
+	reg.RegisterNode(&storage.Node{ID: "server-99", ForestID: "forest-2", Status: "active"})
+
+	forests := reg.ListForests()
+	orphans, err := p.FindOrphans(ctx, forests)
+	if err != nil {
+		t.Fatalf("FindOrphans() error = %v", err)
+	}
+	var got []string
+	for _, o := range orphans {
+		got = append(got, o.Kind+" "+o.ID)
+	}
+	sort.Strings(got)
+	want := []string{
+		"dns_record forest-1700000000-0a1b2c3d-primary CNAME",
+		"dns_record forest-77 A",
+		"dns_record forest-77-node-1 AAAA",
+		"forest forest-2",
+		"node server-99",
+		"server server-2",
+		"server server-3",
+		"volume volume-1",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("orphans = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("orphans = %v, want %v", got, want)
+		}
+	}
+
+	if failed := p.DeleteOrphans(ctx, orphans); failed != 0 {
+		t.Fatalf("DeleteOrphans() failed %d", failed)
+	}
+	if _, ok := prov.servers["server-1"]; !ok {
+		t.Error("registered server deleted")
+	}
+	if _, ok := prov.servers["server-4"]; !ok {
+		t.Error("unmanaged server deleted")
+	}
+	if len(prov.servers) != 2 || len(prov.volumes) != 0 {
+		t.Errorf("%d servers and %d volumes left, want 2 and 0", len(prov.servers), len(prov.volumes))
+	}
+	if len(records.records) != 2 {
+		t.Errorf("%d DNS records left, want 2", len(records.records))
+	}
+	if _, err := reg.GetForest("forest-2"); err == nil {
+		t.Error("forest without servers still registered")
+	}
+
+	orphans, err = p.FindOrphans(ctx, reg.ListForests())
+	if err != nil || len(orphans) != 0 {
+		t.Errorf("FindOrphans() after cleanup = %v, %v; want none", orphans, err)
+	}
+}
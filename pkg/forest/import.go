@@ -0,0 +1,181 @@
+package forest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// ImportRequest contains parameters for adopting existing servers into a forest
+type ImportRequest struct {
+	ForestID  string
+	ServerIDs []string          // Servers to import by ID
+	Selector  map[string]string // Servers to import by label (combined with ServerIDs)
+	Label     bool              // Add the managed-by/forest-id labels to imported servers
+	CreateDNS bool              // Create DNS records for imported nodes
+}
+
+// ImportResult describes the outcome of an import
+type ImportResult struct {
+	ForestID      string   `json:"forest_id"`
+	CreatedForest bool     `json:"created_forest"`
+	Imported      []string `json:"imported"`
+	Skipped       []string `json:"skipped,omitempty"` // Already registered in this forest
+}
+
+// Import registers servers that were not created by morpheus as nodes of a
+// forest, creating the forest if it does not exist yet. Afterwards the forest
+// can be inspected, scaled and torn down like any other forest.
+// Servers that already belong to a different forest are rejected.
+func (p *Provisioner) Import(ctx context.Context, req ImportRequest) (*ImportResult, error) {
+	if req.ForestID == "" {
+		return nil, fmt.Errorf("forest ID is required")
+	}
+	if len(req.ServerIDs) == 0 && len(req.Selector) == 0 {
+		return nil, fmt.Errorf("specify server IDs or a label selector")
+	}
+
+	servers, err := p.resolveImportServers(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no servers matched")
+	}
+
+	// Map every registered server to its forest
+	owner := make(map[string]string)
+	for _, f := range p.storage.ListForests() {
+		nodes, err := p.storage.GetNodes(f.ID)
+		if err != nil {
+			continue
+		}
+		for _, n := range nodes {
+			owner[n.ID] = f.ID
+		}
+	}
+
+	result := &ImportResult{ForestID: req.ForestID}
+	var toImport []*machine.Server
+	for _, s := range servers {
+		switch owner[s.ID] {
+		case "":
+			toImport = append(toImport, s)
+		case req.ForestID:
+			result.Skipped = append(result.Skipped, s.ID)
+		default:
+			return nil, fmt.Errorf("server %s (%s) already belongs to forest %s", s.ID, s.Name, owner[s.ID])
+		}
+	}
+
+	f, err := p.storage.GetForest(req.ForestID)
+	if err != nil {
+		f = &storage.Forest{
+			ID:        req.ForestID,
+			Provider:  p.config.GetMachineProvider(),
+			Project:   p.config.GetHetznerProject(),
+			Location:  servers[0].Location,
+			Status:    "active",
+			CreatedAt: time.Now(),
+		}
+		if err := p.storage.RegisterForest(f); err != nil {
+			return nil, fmt.Errorf("failed to register forest: %w", err)
+		}
+		result.CreatedForest = true
+	}
+
+	existing, err := p.storage.GetNodes(req.ForestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+
+	labeler, canLabel := p.machine.(machine.LabelUpdater)
+	for i, s := range toImport {
+		if req.Label {
+			if !canLabel {
+				p.warn(1, "provider cannot update labels, %s left unlabelled", s.ID)
+			} else {
+				labels := make(map[string]string, len(s.Labels)+2)
+				for k, v := range s.Labels {
+					labels[k] = v
+				}
+				labels["managed-by"] = "morpheus"
+				labels["forest-id"] = req.ForestID
+				if err := labeler.UpdateLabels(ctx, s.ID, labels); err != nil {
+					return result, fmt.Errorf("failed to label server %s: %w", s.ID, err)
+				}
+				s.Labels = labels
+			}
+		}
+
+		status := "active"
+		if s.State != machine.ServerStateRunning {
+			status = string(s.State)
+		}
+		if err := p.storage.RegisterNode(&storage.Node{
+			ID:         s.ID,
+			ForestID:   req.ForestID,
+			IP:         s.GetPreferredIP(),
+			IPv6:       s.PublicIPv6,
+			IPv4:       s.PublicIPv4,
+			Location:   s.Location,
+			Role:       s.Labels["role"],
+			ServerType: s.ServerType,
+			Status:     status,
+			Metadata:   s.Labels,
+		}); err != nil {
+			return result, fmt.Errorf("failed to register node %s: %w", s.ID, err)
+		}
+		result.Imported = append(result.Imported, s.ID)
+
+		if req.CreateDNS && p.dns != nil && p.config.DNS.Domain != "" {
+			p.createDNSRecords(ctx, req.ForestID, s, len(existing)+i)
+		}
+		p.registerInventory(ctx, req.ForestID, s)
+	}
+
+	updated := *f
+	updated.NodeCount = len(existing) + len(result.Imported)
+	if err := p.storage.UpdateForest(&updated); err != nil {
+		return result, fmt.Errorf("failed to update forest: %w", err)
+	}
+
+	return result, nil
+}
+
+// resolveImportServers looks up the servers named by ID or matched by the
+// selector, without duplicates
+func (p *Provisioner) resolveImportServers(ctx context.Context, req ImportRequest) ([]*machine.Server, error) {
+	seen := make(map[string]bool)
+	var servers []*machine.Server
+
+	for _, id := range req.ServerIDs {
+		if seen[id] {
+			continue
+		}
+		s, err := p.machine.GetServer(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get server %s: %w", id, err)
+		}
+		seen[s.ID] = true
+		servers = append(servers, s)
+	}
+
+	if len(req.Selector) > 0 {
+		matched, err := p.machine.ListServers(ctx, req.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list servers: %w", err)
+		}
+		for _, s := range matched {
+			if !seen[s.ID] {
+				seen[s.ID] = true
+				servers = append(servers, s)
+			}
+		}
+	}
+
+	return servers, nil
+}
@@ -0,0 +1,70 @@
+package forest
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+func TestImport(t *testing.T) {
+	prov := newMockProvider()
+	prov.servers["101"] = &machine.Server{ID: "101", Name: "legacy-a", PublicIPv6: "2001:db8::a", Location: "fsn1", State: machine.ServerStateRunning}
+	prov.servers["102"] = &machine.Server{ID: "102", Name: "legacy-b", PublicIPv6: "2001:db8::b", Location: "fsn1", State: machine.ServerStateRunning,
+		Labels: map[string]string{"role": "nats"}}
+	prov.servers["103"] = &machine.Server{ID: "103", Name: "other", PublicIPv6: "2001:db8::c", Location: "nbg1", State: machine.ServerStateStopped}
+
+	reg, err := storage.NewLocalRegistry(filepath.Join(t.TempDir(), "registry.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewProvisioner(prov, reg, &config.Config{})
+
+	result, err := p.Import(context.Background(), ImportRequest{
+		ForestID:  "legacy",
+		ServerIDs: []string{"101"},
+		Selector:  map[string]string{"role": "nats"},
+		Label:     true,
+	})
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if !result.CreatedForest || len(result.Imported) != 2 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	f, err := reg.GetForest("legacy")
+	if err != nil {
+		t.Fatalf("Forest not registered: %v", err)
+	}
+	if f.NodeCount != 2 || f.Location != "fsn1" {
+		t.Errorf("Unexpected forest: %+v", f)
+	}
+	if prov.servers["102"].Labels["forest-id"] != "legacy" || prov.servers["102"].Labels["role"] != "nats" {
+		t.Errorf("Expected labels to be merged, got %v", prov.servers["102"].Labels)
+	}
+
+	// Importing again skips known servers and adds new ones
+	result, err = p.Import(context.Background(), ImportRequest{ForestID: "legacy", ServerIDs: []string{"101", "103"}})
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if len(result.Skipped) != 1 || len(result.Imported) != 1 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	nodes, _ := reg.GetNodes("legacy")
+	if len(nodes) != 3 || nodes[2].Status != "stopped" {
+		t.Errorf("Unexpected nodes: %v", nodes)
+	}
+
+	// A server cannot belong to two forests
+	reg.RegisterForest(&storage.Forest{ID: "other"})
+	_, err = p.Import(context.Background(), ImportRequest{ForestID: "other", ServerIDs: []string{"101"}})
+	if err == nil || !strings.Contains(err.Error(), "already belongs to forest legacy") {
+		t.Errorf("Expected ownership error, got %v", err)
+	}
+}
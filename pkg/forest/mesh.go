@@ -0,0 +1,441 @@
+package forest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/guard"
+	"github.com/nimsforest/morpheus/pkg/secretstore"
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+	"github.com/nimsforest/morpheus/pkg/storage"
+	"github.com/nimsforest/morpheus/pkg/wireguard"
+)
+
+// meshConfPath is where nodes keep their mesh config
+const meshConfPath = "/etc/wireguard/wg0.conf"
+
+// MeshJoinRequest configures JoinMesh
+type MeshJoinRequest struct {
+	ForestID string
+
+	// Guard is the guard the nodes reach the mesh through: the ID of a
+	// guard in the registry, or its WireGuard endpoint as host[:port]
+	Guard string
+
+	// GuardKey, GuardMeshIP and MeshCIDR default to what the registry
+	// knows of the guard; guards it does not know need all three
+	GuardKey    string
+	GuardMeshIP string
+	MeshCIDR    string
+
+	// Routes are networks behind the guard the nodes reach through it,
+	// such as its peered VNets
+	Routes []string
+}
+
+// MeshJoinResult is the mesh a forest joined
+type MeshJoinResult struct {
+	CIDR  string
+	Guard *wireguard.Node
+	Nodes []*wireguard.Node // The forest's nodes, with their mesh IPs
+
+	// GuardPeers are the [Peer] sections the guard needs for the nodes.
+	// GuardUpdated is whether they were added to a guard in the registry
+	// over SSH; otherwise they must be added to its config by hand.
+	GuardPeers   []wireguard.Peer
+	GuardUpdated bool
+}
+
+// JoinMesh puts the nodes of a forest into a WireGuard mesh with a guard:
+// every node gets a key, kept in the secret store, and a mesh IP, kept in
+// the registry, and a config with the guard and all other nodes as peers,
+// installed over SSH. It is safe to run again after the forest changed
+// size; nodes keep their keys and mesh IPs.
+func (p *Provisioner) JoinMesh(ctx context.Context, req MeshJoinRequest) (*MeshJoinResult, error) {
+	nodes, err := p.storage.GetNodes(req.ForestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("forest %s has no nodes", req.ForestID)
+	}
+
+	rec, guardNode, cidr, err := p.meshGuard(req)
+	if err != nil {
+		return nil, err
+	}
+	mesh, err := wireguard.NewMesh(cidr, p.config.Provisioning.Mesh.ListenPort)
+	if err != nil {
+		return nil, err
+	}
+	result := &MeshJoinResult{CIDR: mesh.CIDR().String()}
+	if result.Guard, err = mesh.Add(guardNode); err != nil {
+		return nil, fmt.Errorf("guard: %w", err)
+	}
+	p.reserveMeshIPs(mesh, req.ForestID, rec, nodes)
+
+	// Nodes that joined before keep their mesh IPs, so they are added
+	// before the others are given new ones
+	store := p.meshSecrets(req.ForestID)
+	names := p.names(req.ForestID)
+	meshNodes := make([]*wireguard.Node, len(nodes))
+	for _, pass := range []bool{true, false} {
+		for i, node := range nodes {
+			meshIP := node.MeshIP
+			if meshIP != "" {
+				if addr, err := netip.ParseAddr(meshIP); err != nil || !mesh.CIDR().Contains(addr) {
+					meshIP = ""
+				}
+			}
+			if (meshIP != "") != pass {
+				continue
+			}
+			key, err := p.meshKey(ctx, store, node.ID)
+			if err != nil {
+				return nil, err
+			}
+			// Guards mostly have IPv4 only
+			host := node.IP
+			if node.IPv4 != "" {
+				host = node.IPv4
+			}
+			meshNodes[i], err = mesh.Add(wireguard.Node{
+				Name:       names.Node(i),
+				PrivateKey: key,
+				MeshIP:     meshIP,
+				Endpoint:   net.JoinHostPort(host, strconv.Itoa(mesh.ListenPort)),
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	result.Nodes = meshNodes
+
+	identity := p.sshIdentity()
+	var failed []string
+	for i, node := range nodes {
+		mn := meshNodes[i]
+		e := Event{Type: StepStarted, Step: StepMesh, Level: 1, Node: mn.Name, Message: fmt.Sprintf("Joining %s as %s", mn.Name, mn.MeshIP)}
+		p.report(e)
+
+		conf, err := mesh.Config(mn.Name)
+		if err != nil {
+			return nil, err
+		}
+		// The config carries the node's private key, so it is sent on
+		// stdin rather than the command line
+		var stderr bytes.Buffer
+		target := sshutil.Target{Name: mn.Name, Addr: node.IP, HostKey: node.HostKey}
+		res := sshutil.RunParallel(ctx, []sshutil.Target{target}, "sh -s", sshutil.ExecOptions{
+			IdentityFile: identity,
+			Timeout:      5 * time.Minute,
+			Stderr:       &stderr,
+			Stdin:        []byte(meshScript(conf.Render(), mesh.ListenPort)),
+			SSHBinary:    p.sshBinary,
+		})[0]
+		if res.Err != nil {
+			e.Type, e.Err = StepFailed, res.Err
+			p.report(e)
+			if msg := lastLine(stderr.String()); msg != "" {
+				p.info(2, "%s", msg)
+			}
+			failed = append(failed, mn.Name)
+			continue
+		}
+
+		node.MeshIP, node.MeshKey = mn.MeshIP, mn.PublicKey
+		if err := p.storage.UpdateNode(node); err != nil {
+			e.Type, e.Err = StepFailed, fmt.Errorf("failed to record mesh IP: %w", err)
+			p.report(e)
+			failed = append(failed, mn.Name)
+			continue
+		}
+		e.Type = StepCompleted
+		p.report(e)
+
+		if p.inventory != nil {
+			if server, err := p.machine.GetServer(ctx, node.ID); err != nil {
+				p.warn(2, "failed to update %s in inventory: %s", mn.Name, err)
+			} else {
+				p.registerInventory(ctx, req.ForestID, server)
+			}
+		}
+	}
+
+	if result.GuardPeers, err = mesh.Peers(result.Guard.Name); err != nil {
+		return nil, err
+	}
+	if rec != nil {
+		result.GuardUpdated = p.addGuardPeers(ctx, rec, result.GuardPeers)
+	}
+
+	if len(failed) > 0 {
+		return result, fmt.Errorf("joining the mesh failed on %s", strings.Join(failed, ", "))
+	}
+	p.info(1, "🕸️  %d node%s joined mesh %s through %s", len(nodes), plural(len(nodes)), result.CIDR, result.Guard.Name)
+	return result, nil
+}
+
+// meshGuard returns the guard a mesh join goes through, as the registry
+// records it if it does, and the mesh CIDR
+func (p *Provisioner) meshGuard(req MeshJoinRequest) (*storage.Guard, wireguard.Node, string, error) {
+	n := wireguard.Node{
+		Name:      req.Guard,
+		Kind:      wireguard.KindGuard,
+		PublicKey: req.GuardKey,
+		MeshIP:    req.GuardMeshIP,
+		Routes:    req.Routes,
+	}
+	cidr := req.MeshCIDR
+
+	host, port, err := net.SplitHostPort(req.Guard)
+	if err != nil {
+		host, port = strings.Trim(req.Guard, "[]"), ""
+	}
+	var rec *storage.Guard
+	if guards, ok := p.storage.(storage.GuardRegistry); ok {
+		for _, g := range guards.ListGuards() {
+			if g.ID == req.Guard || (g.PublicIP != "" && g.PublicIP == host) {
+				rec = g
+				break
+			}
+		}
+	}
+	if rec != nil {
+		n.Name = rec.ID
+		if rec.ID == req.Guard {
+			host = rec.PublicIP
+		}
+		if port == "" && rec.WireGuardPort > 0 {
+			port = strconv.Itoa(rec.WireGuardPort)
+		}
+		if n.PublicKey == "" {
+			n.PublicKey = rec.PublicKey
+		}
+		if prefix, ok := guardMeshAddress(rec); ok {
+			if n.MeshIP == "" {
+				n.MeshIP = prefix.Addr().String()
+			}
+			if cidr == "" && prefix.Bits() < prefix.Addr().BitLen()-1 {
+				cidr = prefix.Masked().String()
+			}
+		}
+		if cidr == "" && len(rec.MeshCIDRs) > 0 {
+			cidr = rec.MeshCIDRs[0]
+		}
+	}
+
+	switch {
+	case host == "":
+		return nil, n, "", fmt.Errorf("guard %s has no public IP", n.Name)
+	case n.PublicKey == "":
+		return nil, n, "", fmt.Errorf("the public key of guard %s is not known", n.Name)
+	case n.MeshIP == "":
+		return nil, n, "", fmt.Errorf("the mesh IP of guard %s is not known", n.Name)
+	case cidr == "":
+		return nil, n, "", fmt.Errorf("the mesh CIDR of guard %s is not known", n.Name)
+	}
+	if port == "" {
+		port = strconv.Itoa(wireguard.DefaultPort)
+	}
+	n.Endpoint = net.JoinHostPort(host, port)
+	return rec, n, cidr, nil
+}
+
+// guardMeshAddress returns the mesh IP and prefix length of a guard, from
+// the last config it was given
+func guardMeshAddress(rec *storage.Guard) (netip.Prefix, bool) {
+	if len(rec.Configs) == 0 {
+		return netip.Prefix{}, false
+	}
+	c, err := wireguard.ParseConfig(rec.Configs[len(rec.Configs)-1].Config)
+	if err != nil || len(c.Interface.Address) == 0 {
+		return netip.Prefix{}, false
+	}
+	prefix, err := netip.ParsePrefix(c.Interface.Address[0])
+	return prefix, err == nil
+}
+
+// reserveMeshIPs keeps the mesh IPs of other forests' nodes and of the
+// guard's other peers from being given to a forest's nodes
+func (p *Provisioner) reserveMeshIPs(mesh *wireguard.Mesh, forestID string, rec *storage.Guard, nodes []*storage.Node) {
+	reserve := func(ip string) {
+		if addr, err := netip.ParseAddr(ip); err == nil && mesh.CIDR().Contains(addr) {
+			mesh.Reserve(ip)
+		}
+	}
+	for _, f := range p.storage.ListForests() {
+		if f.ID == forestID {
+			continue
+		}
+		others, _ := p.storage.GetNodes(f.ID)
+		for _, n := range others {
+			reserve(n.MeshIP)
+		}
+	}
+
+	if rec == nil || len(rec.Configs) == 0 {
+		return
+	}
+	c, err := wireguard.ParseConfig(rec.Configs[len(rec.Configs)-1].Config)
+	if err != nil {
+		return
+	}
+	ours := make(map[string]bool)
+	for _, n := range nodes {
+		ours[n.MeshKey] = n.MeshKey != ""
+	}
+	for _, peer := range c.Peers {
+		if ours[peer.PublicKey] {
+			continue
+		}
+		for _, allowed := range peer.AllowedIPs {
+			if prefix, err := netip.ParsePrefix(allowed); err == nil && prefix.IsSingleIP() {
+				reserve(prefix.Addr().String())
+			}
+		}
+	}
+}
+
+// addGuardPeers adds the nodes to a guard's config over SSH, and to its
+// running interface. Peers already in the config are left as they are.
+func (p *Provisioner) addGuardPeers(ctx context.Context, rec *storage.Guard, peers []wireguard.Peer) bool {
+	e := Event{Type: StepStarted, Step: StepMesh, Level: 1, Node: rec.ID, Message: "Adding the nodes to guard " + rec.ID}
+	p.report(e)
+
+	var stderr bytes.Buffer
+	target := sshutil.Target{Name: rec.ID, Addr: rec.PublicIP}
+	res := sshutil.RunParallel(ctx, []sshutil.Target{target}, "sudo sh -s", sshutil.ExecOptions{
+		User:         guard.SSHUser(rec.Provider),
+		IdentityFile: p.sshIdentity(),
+		Timeout:      2 * time.Minute,
+		Stderr:       &stderr,
+		Stdin:        []byte(guardPeersScript(peers)),
+		SSHBinary:    p.sshBinary,
+	})[0]
+	if res.Err != nil {
+		e.Type, e.Err = StepFailed, res.Err
+		p.report(e)
+		if msg := lastLine(stderr.String()); msg != "" {
+			p.info(2, "%s", msg)
+		}
+		return false
+	}
+	e.Type = StepCompleted
+	p.report(e)
+	return true
+}
+
+// meshScript installs wireguard-tools if needed, opens the mesh port in
+// ufw and installs conf as the node's mesh config. wg-quick is only
+// restarted if the config changed.
+func meshScript(conf string, port int) string {
+	delimiter := heredocDelimiter(conf)
+	return fmt.Sprintf(`set -eu
+if ! command -v wg-quick >/dev/null 2>&1; then
+  export DEBIAN_FRONTEND=noninteractive
+  apt-get update -qq && apt-get install -y -qq wireguard-tools >/dev/null
+fi
+if command -v ufw >/dev/null 2>&1 && ufw status | grep -q 'Status: active'; then
+  ufw allow %[4]d/udp comment 'WireGuard mesh' >/dev/null
+fi
+umask 077
+mkdir -p /etc/wireguard
+cat > %[1]s.new <<'%[2]s'
+%[3]s%[2]s
+if cmp -s %[1]s.new %[1]s && systemctl is-active --quiet wg-quick@wg0; then
+  rm -f %[1]s.new
+  exit 0
+fi
+mv %[1]s.new %[1]s
+systemctl enable --quiet wg-quick@wg0
+systemctl restart wg-quick@wg0
+`, meshConfPath, delimiter, conf, port)
+}
+
+// guardPeersScript appends the peers a guard's config lacks and sets all
+// of them on its running interface
+func guardPeersScript(peers []wireguard.Peer) string {
+	var b strings.Builder
+	b.WriteString("set -eu\n")
+	for _, peer := range peers {
+		section := peer.Render()
+		delimiter := heredocDelimiter(section)
+		fmt.Fprintf(&b, "if ! grep -qF 'PublicKey = %[1]s' %[2]s; then\n  printf '\\n' >> %[2]s\n  cat >> %[2]s <<'%[3]s'\n%[4]s%[3]s\nfi\n", peer.PublicKey, meshConfPath, delimiter, section)
+		args := []string{"wg set wg0 peer", peer.PublicKey, "allowed-ips", strings.Join(peer.AllowedIPs, ",")}
+		if peer.Endpoint != "" {
+			args = append(args, "endpoint", peer.Endpoint)
+		}
+		b.WriteString(strings.Join(args, " ") + "\n")
+	}
+	return b.String()
+}
+
+// heredocDelimiter returns a here-document delimiter that does not occur
+// in text
+func heredocDelimiter(text string) string {
+	delimiter := "MORPHEUS_WG_CONF"
+	for strings.Contains(text, delimiter) {
+		delimiter += "_"
+	}
+	return delimiter
+}
+
+func (p *Provisioner) meshStateDir(forestID string) string {
+	return filepath.Join(p.config.Provisioning.Mesh.GetStateDir(), forestID)
+}
+
+// meshSecrets returns where a forest's mesh keys are kept: below
+// mesh/<forest>/ in the secret store, or as plain files in the mesh state
+// directory without one
+func (p *Provisioner) meshSecrets(forestID string) secretstore.Store {
+	if p.secrets != nil {
+		return secretstore.NewPrefixStore(p.secrets, "mesh/"+forestID)
+	}
+	return secretstore.NewDirStore(p.meshStateDir(forestID))
+}
+
+// meshKey returns the WireGuard private key of a node, generating it on
+// the first join
+func (p *Provisioner) meshKey(ctx context.Context, store secretstore.Store, nodeID string) (string, error) {
+	name := nodeID + ".key"
+	data, err := store.Get(ctx, name)
+	switch {
+	case err == nil:
+		return strings.TrimSpace(string(data)), nil
+	case !errors.Is(err, secretstore.ErrNotFound):
+		return "", fmt.Errorf("failed to read the mesh key of node %s: %w", nodeID, err)
+	}
+	key, _, err := wireguard.GenerateKeyPair()
+	if err != nil {
+		return "", err
+	}
+	if err := store.Put(ctx, name, []byte(key+"\n")); err != nil {
+		return "", fmt.Errorf("failed to save the mesh key of node %s: %w", nodeID, err)
+	}
+	return key, nil
+}
+
+// removeMeshState deletes a torn-down forest's mesh keys
+func (p *Provisioner) removeMeshState(ctx context.Context, forestID string) {
+	if forestID == "" {
+		return
+	}
+	if p.secrets == nil {
+		os.RemoveAll(p.meshStateDir(forestID))
+		return
+	}
+	if err := secretstore.DeletePrefix(ctx, p.secrets, "mesh/"+forestID+"/"); err != nil {
+		p.info(1, "⚠️  Failed to remove mesh keys of %s: %s", forestID, err)
+	}
+}
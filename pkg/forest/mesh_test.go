@@ -0,0 +1,182 @@
+package forest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/storage"
+	"github.com/nimsforest/morpheus/pkg/wireguard"
+)
+
+func TestJoinMesh(t *testing.T) {
+	p, _, reg := newScaleTestProvisioner(t, 2)
+	p.config.Provisioning.Mesh = config.MeshConfig{StateDir: t.TempDir()}
+	scriptDir := t.TempDir()
+	p.sshBinary = natsTestSSH(t, scriptDir)
+
+	_, guardKey, _ := wireguard.GenerateKeyPair()
+	_, laptopKey, _ := wireguard.GenerateKeyPair()
+	guards := reg.(storage.GuardRegistry)
+	if err := guards.RegisterGuard(&storage.Guard{
+		ID:        "guard-1",
+		Provider:  "azure",
+		PublicIP:  "203.0.113.1",
+		PublicKey: guardKey,
+		Configs: []storage.GuardConfig{{Config: "[Interface]\nPrivateKey = (redacted)\nAddress = 10.200.0.1/16\n\n" +
+			"[Peer]\n# laptop\nPublicKey = " + laptopKey + "\nAllowedIPs = 10.200.0.2/32\n"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	req := MeshJoinRequest{ForestID: "forest-1", Guard: "203.0.113.1", Routes: []string{"10.10.0.0/16"}}
+	result, err := p.JoinMesh(ctx, req)
+	if err != nil {
+		t.Fatalf("JoinMesh() error = %v", err)
+	}
+	if result.CIDR != "10.200.0.0/16" || result.Guard.Endpoint != "203.0.113.1:51820" || !result.GuardUpdated {
+		t.Errorf("result = %+v", result)
+	}
+
+	// The laptop keeps its mesh IP
+	nodes, _ := reg.GetNodes("forest-1")
+	for i, want := range []string{"10.200.0.3", "10.200.0.4"} {
+		if nodes[i].MeshIP != want || nodes[i].MeshKey == "" {
+			t.Errorf("node %d mesh IP = %q, key %q; want %s", i+1, nodes[i].MeshIP, nodes[i].MeshKey, want)
+		}
+	}
+
+	scripts := sentScripts(t, scriptDir)
+	if len(scripts) != 3 {
+		t.Fatalf("sent %d scripts, want one per node and one to the guard", len(scripts))
+	}
+	var guardScript string
+	for _, script := range scripts {
+		if strings.Contains(script, "wg set wg0 peer") {
+			guardScript = script
+			continue
+		}
+		for _, want := range []string{
+			"PublicKey = " + guardKey,
+			"Endpoint = 203.0.113.1:51820",
+			"AllowedIPs = 10.200.0.1/32, 10.10.0.0/16",
+			"ufw allow 51820/udp",
+		} {
+			if !strings.Contains(script, want) {
+				t.Errorf("node script does not contain %s:\n%s", want, script)
+			}
+		}
+	}
+	for _, n := range nodes {
+		if !strings.Contains(guardScript, "wg set wg0 peer "+n.MeshKey+" allowed-ips "+n.MeshIP+"/32") {
+			t.Errorf("guard script does not add %s:\n%s", n.MeshIP, guardScript)
+		}
+	}
+
+	// Joining again keeps the keys and mesh IPs
+	if _, err := p.JoinMesh(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	again, _ := reg.GetNodes("forest-1")
+	for i := range nodes {
+		if again[i].MeshIP != nodes[i].MeshIP || again[i].MeshKey != nodes[i].MeshKey {
+			t.Errorf("node %d changed from %s to %s", i+1, nodes[i].MeshIP, again[i].MeshIP)
+		}
+	}
+	sentScripts(t, scriptDir)
+
+	// NATS routes run over the mesh
+	p.config.Provisioning.NATS = config.NATSConfig{Enabled: true, StateDir: t.TempDir()}
+	if err := p.BootstrapNATS(ctx, "forest-1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, script := range sentScripts(t, scriptDir) {
+		if !strings.Contains(script, "@10.200.0.4:6222") || !strings.Contains(script, `listen: "10.200.0.`) {
+			t.Errorf("NATS does not route over the mesh:\n%s", script)
+		}
+	}
+}
+
+func TestJoinMeshUnknownGuard(t *testing.T) {
+	p, _, _ := newScaleTestProvisioner(t, 1)
+	p.config.Provisioning.Mesh = config.MeshConfig{StateDir: t.TempDir()}
+	scriptDir := t.TempDir()
+	p.sshBinary = natsTestSSH(t, scriptDir)
+
+	_, err := p.JoinMesh(context.Background(), MeshJoinRequest{ForestID: "forest-1", Guard: "198.51.100.7:51821"})
+	if err == nil || !strings.Contains(err.Error(), "public key") {
+		t.Errorf("JoinMesh() error = %v, want the guard's key missing", err)
+	}
+
+	_, guardKey, _ := wireguard.GenerateKeyPair()
+	result, err := p.JoinMesh(context.Background(), MeshJoinRequest{
+		ForestID: "forest-1", Guard: "198.51.100.7:51821", GuardKey: guardKey, GuardMeshIP: "10.201.0.1", MeshCIDR: "10.201.0.0/24",
+	})
+	if err != nil {
+		t.Fatalf("JoinMesh() error = %v", err)
+	}
+	// Without a guard in the registry, its peers are left to the operator
+	if result.GuardUpdated || len(result.GuardPeers) != 1 || result.GuardPeers[0].AllowedIPs[0] != "10.201.0.2/32" {
+		t.Errorf("result = %+v", result)
+	}
+	if n := len(sentScripts(t, scriptDir)); n != 1 {
+		t.Errorf("sent %d scripts, want 1", n)
+	}
+}
+
+func TestMeshStatus(t *testing.T) {
+	reg, err := storage.NewLocalRegistry(filepath.Join(t.TempDir(), "registry.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.RegisterForest(&storage.Forest{ID: "forest-1", NodeCount: 2, Status: "active"})
+	reg.RegisterNode(&storage.Node{ID: "1", ForestID: "forest-1", IP: "192.0.2.1", MeshIP: "10.200.0.2", MeshKey: "node1="})
+	reg.RegisterNode(&storage.Node{ID: "2", ForestID: "forest-1", IP: "192.0.2.2", MeshIP: "10.200.0.3", MeshKey: "node2="})
+	reg.RegisterGuard(&storage.Guard{ID: "guard-1", Provider: "azure", PublicIP: "203.0.113.1", PublicKey: "guard="})
+	reg.RegisterGuard(&storage.Guard{ID: "guard-2", Provider: "azure", PublicIP: "203.0.113.2", PublicKey: "other="})
+
+	// Every host answers with its dump; node 2 is down
+	dir := t.TempDir()
+	dumps := map[string]string{
+		"192.0.2.1":   "(hidden)\tnode1=\t51820\toff\nguard=\t(none)\t203.0.113.1:51820\t10.200.0.1/32\t1700000000\t10\t20\toff\nnode2=\t(none)\t(none)\t10.200.0.3/32\t0\t0\t0\toff\n",
+		"203.0.113.1": "(hidden)\tguard=\t51820\toff\nnode1=\t(none)\t192.0.2.1:51820\t10.200.0.2/32\t1700000000\t20\t10\toff\nlaptop12345=\t(none)\t(none)\t10.200.0.9/32\t0\t0\t0\toff\n",
+	}
+	for host, dump := range dumps {
+		os.WriteFile(filepath.Join(dir, host), []byte(dump), 0644)
+	}
+	ssh := filepath.Join(t.TempDir(), "ssh")
+	script := "#!/bin/sh\nfor a; do case $a in *@*) host=${a#*@};; esac; done\n" +
+		"[ -f " + dir + "/$host ] || { echo 'connection refused' >&2; exit 255; }\ncat " + dir + "/$host\n"
+	os.WriteFile(ssh, []byte(script), 0755)
+
+	p := NewProvisioner(nil, reg, &config.Config{})
+	p.sshBinary = ssh
+	status, err := p.MeshStatus(context.Background(), "forest-1")
+	if err != nil {
+		t.Fatalf("MeshStatus() error = %v", err)
+	}
+
+	// guard-2 is no peer of the forest, so it is not asked
+	if len(status.Hosts) != 3 {
+		t.Fatalf("hosts = %d, want 2 nodes and guard-1", len(status.Hosts))
+	}
+	node1, node2, guard1 := status.Hosts[0], status.Hosts[1], status.Hosts[2]
+	if node1.Err != nil || len(node1.Dump.Peers) != 2 || node1.Dump.Peer("guard=").RxBytes != 10 {
+		t.Errorf("node 1 = %+v", node1)
+	}
+	if node2.Err == nil || !strings.Contains(node2.Err.Error(), "connection refused") {
+		t.Errorf("node 2 error = %v, want it unreachable", node2.Err)
+	}
+	if guard1.Name != "guard-1" || guard1.Err != nil || len(guard1.Dump.Peers) != 2 {
+		t.Errorf("guard = %+v", guard1)
+	}
+	for key, want := range map[string]string{"node2=": "forest-1-node-2", "guard=": "guard-1", "laptop12345=": "laptop12…"} {
+		if got := status.PeerName(key); got != want {
+			t.Errorf("PeerName(%s) = %s, want %s", key, got, want)
+		}
+	}
+}
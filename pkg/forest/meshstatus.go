@@ -0,0 +1,154 @@
+package forest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/guard"
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+	"github.com/nimsforest/morpheus/pkg/storage"
+	"github.com/nimsforest/morpheus/pkg/wireguard"
+)
+
+// meshDumpCommand prints the state of wg0 with its private key blanked out
+const meshDumpCommand = `wg show wg0 dump | sed '1s/^[^\t]*/(hidden)/'`
+
+// MeshHost is a node or guard of a forest's mesh, and what it reports of
+// its peers
+type MeshHost struct {
+	Name      string
+	Kind      string // wireguard.KindNode or wireguard.KindGuard
+	MeshIP    string
+	PublicKey string
+	Dump      *wireguard.Dump // nil if Err is set
+	Err       error
+}
+
+// MeshStatus is the state of a forest's mesh, as seen from each of its
+// nodes and guards
+type MeshStatus struct {
+	Hosts []*MeshHost
+	Time  time.Time // When the hosts were asked
+
+	names map[string]string // Public key -> host name
+}
+
+// PeerName returns the name of the host with a public key, or the start
+// of the key for hosts outside the forest's mesh
+func (s *MeshStatus) PeerName(publicKey string) string {
+	if name, ok := s.names[publicKey]; ok {
+		return name
+	}
+	if len(publicKey) > 8 {
+		return publicKey[:8] + "…"
+	}
+	return publicKey
+}
+
+// MeshStatus asks every node of a forest that joined the mesh, and every
+// guard in the registry they have as a peer, for 'wg show wg0 dump' over
+// SSH. Hosts that cannot be asked are returned with Err set.
+func (p *Provisioner) MeshStatus(ctx context.Context, forestID string) (*MeshStatus, error) {
+	nodes, err := p.storage.GetNodes(forestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+	status := &MeshStatus{Time: time.Now(), names: make(map[string]string)}
+	names := p.names(forestID)
+	var targets []sshutil.Target
+	for i, node := range nodes {
+		if node.MeshKey == "" {
+			continue
+		}
+		name := names.Node(i)
+		status.Hosts = append(status.Hosts, &MeshHost{Name: name, Kind: wireguard.KindNode, MeshIP: node.MeshIP, PublicKey: node.MeshKey})
+		status.names[node.MeshKey] = name
+		targets = append(targets, sshutil.Target{Name: name, Addr: node.IP, HostKey: node.HostKey})
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no node of %s joined the mesh", forestID)
+	}
+	p.readMeshDumps(ctx, status, targets, "root", meshDumpCommand)
+
+	// Guards are asked if any node has them as a peer
+	guards, _ := p.storage.(storage.GuardRegistry)
+	if guards == nil {
+		return status, nil
+	}
+	peers := make(map[string]bool)
+	for _, h := range status.Hosts {
+		if h.Dump != nil {
+			for _, peer := range h.Dump.Peers {
+				peers[peer.PublicKey] = true
+			}
+		}
+	}
+	for _, g := range guards.ListGuards() {
+		if g.PublicKey == "" || !peers[g.PublicKey] {
+			continue
+		}
+		h := &MeshHost{Name: g.ID, Kind: wireguard.KindGuard, PublicKey: g.PublicKey}
+		if prefix, ok := guardMeshAddress(g); ok {
+			h.MeshIP = prefix.Addr().String()
+		}
+		status.Hosts = append(status.Hosts, h)
+		status.names[g.PublicKey] = g.ID
+		target := sshutil.Target{Name: g.ID, Addr: g.PublicIP}
+		p.readMeshDumps(ctx, status, []sshutil.Target{target}, guard.SSHUser(g.Provider), "sudo "+meshDumpCommand)
+	}
+	return status, nil
+}
+
+// readMeshDumps runs command on targets and sets the dumps of the hosts
+// with their names
+func (p *Provisioner) readMeshDumps(ctx context.Context, status *MeshStatus, targets []sshutil.Target, user, command string) {
+	var stdout, stderr bytes.Buffer
+	results := sshutil.RunParallel(ctx, targets, command, sshutil.ExecOptions{
+		User:         user,
+		IdentityFile: p.sshIdentity(),
+		Timeout:      time.Minute,
+		Stdout:       &stdout,
+		Stderr:       &stderr,
+		SSHBinary:    p.sshBinary,
+	})
+	outputs := splitPrefixed(stdout.String())
+	stderrs := splitPrefixed(stderr.String())
+
+	for _, res := range results {
+		var h *MeshHost
+		for _, host := range status.Hosts {
+			if host.Name == res.Target.Name {
+				h = host
+			}
+		}
+		if res.Err != nil {
+			h.Err = res.Err
+			if msg := lastLine(stderrs[res.Target.Name]); msg != "" {
+				h.Err = fmt.Errorf("%w: %s", res.Err, msg)
+			}
+			continue
+		}
+		if h.Dump, h.Err = wireguard.ParseDump(outputs[res.Target.Name]); h.Err != nil {
+			h.Err = fmt.Errorf("unexpected wg output: %w", h.Err)
+		}
+	}
+}
+
+// splitPrefixed splits the output of sshutil.RunParallel, whose lines
+// start with "[<target>] ", by target
+func splitPrefixed(output string) map[string]string {
+	byTarget := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, "[") {
+			continue
+		}
+		name, rest, ok := strings.Cut(line[1:], "] ")
+		if ok {
+			byTarget[name] += rest + "\n"
+		}
+	}
+	return byTarget
+}
@@ -0,0 +1,124 @@
+package forest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// MetadataPath is where nodes find their metadata
+const MetadataPath = "/etc/morpheus/metadata.json"
+
+// Metadata describes a node and its forest to software running on it. It
+// is written to MetadataPath by cloud-init and rewritten by
+// RefreshMetadata, e.g. after the forest was scaled.
+type Metadata struct {
+	ForestID  string            `json:"forest_id"`
+	Node      string            `json:"node"`
+	NodeIndex int               `json:"node_index"` // 0-based
+	NodeCount int               `json:"node_count"`
+	Role      string            `json:"role"`
+	Location  string            `json:"location,omitempty"`
+	PeerIPs   []string          `json:"peer_ips"` // IPs of the forest's other nodes
+	Values    map[string]string `json:"values"`   // User-defined key/values
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// JSON returns the metadata as written to nodes
+func (m Metadata) JSON() string {
+	data, _ := json.MarshalIndent(m, "", "  ")
+	return string(data)
+}
+
+// NodeMetadata returns the metadata of the node of f with the given
+// (0-based) index. Configured values are overridden by the forest's own.
+func NodeMetadata(cfg *config.Config, f *storage.Forest, index, nodeCount int, location string, peerIPs []string) Metadata {
+	values := make(map[string]string)
+	maps.Copy(values, cfg.Provisioning.Metadata)
+	maps.Copy(values, f.Metadata)
+	if peerIPs == nil {
+		peerIPs = []string{}
+	}
+	return Metadata{
+		ForestID:  f.ID,
+		Node:      ForestNames(f).Node(index),
+		NodeIndex: index,
+		NodeCount: nodeCount,
+		Role:      RecordedRequest(f).NodeRole(index),
+		Location:  location,
+		PeerIPs:   peerIPs,
+		Values:    values,
+		UpdatedAt: time.Now().UTC(),
+	}
+}
+
+// RefreshMetadata rewrites the metadata file on every node of a forest, so
+// nodes see the current peers and values
+func (p *Provisioner) RefreshMetadata(ctx context.Context, forestID string) error {
+	f, err := p.storage.GetForest(forestID)
+	if err != nil {
+		return err
+	}
+	nodes, err := p.storage.GetNodes(forestID)
+	if err != nil {
+		return fmt.Errorf("failed to get nodes: %w", err)
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("forest %s has no nodes", forestID)
+	}
+
+	p.report(Event{Type: StepStarted, Step: StepMetadata, Message: fmt.Sprintf("Updating metadata on %d node%s", len(nodes), plural(len(nodes)))})
+	names := ForestNames(f)
+	roles := NodeRoles(f, nodes)
+	identity := p.sshIdentity()
+	command := fmt.Sprintf("mkdir -p /etc/morpheus && cat > %[1]s.tmp && mv %[1]s.tmp %[1]s", MetadataPath)
+
+	var failed []string
+	for i, node := range nodes {
+		var peers []string
+		for j, peer := range nodes {
+			if j != i {
+				peers = append(peers, peer.IP)
+			}
+		}
+		metadata := NodeMetadata(p.config, f, i, len(nodes), node.Location, peers)
+		metadata.Role = roles[i]
+
+		name := names.Node(i)
+		e := Event{Type: StepStarted, Step: StepMetadata, Level: 1, Number: i + 1, Total: len(nodes), Node: name, Message: "Updating " + name}
+		p.report(e)
+		var stderr bytes.Buffer
+		target := sshutil.Target{Name: name, Addr: node.IP, HostKey: node.HostKey}
+		result := sshutil.RunParallel(ctx, []sshutil.Target{target}, command, sshutil.ExecOptions{
+			IdentityFile: identity,
+			Timeout:      time.Minute,
+			Stderr:       &stderr,
+			Stdin:        []byte(metadata.JSON() + "\n"),
+			SSHBinary:    p.sshBinary,
+		})[0]
+		if result.Err != nil {
+			e.Type, e.Err = StepFailed, result.Err
+			p.report(e)
+			if msg := lastLine(stderr.String()); msg != "" {
+				p.info(2, "%s", msg)
+			}
+			failed = append(failed, name)
+			continue
+		}
+		e.Type = StepCompleted
+		p.report(e)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("metadata update failed on %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
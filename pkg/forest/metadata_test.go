@@ -0,0 +1,86 @@
+package forest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+func TestRefreshMetadata(t *testing.T) {
+	p, _, reg := newScaleTestProvisioner(t, 3)
+	p.config.Provisioning.Metadata = map[string]string{"env": "production", "team": "core"}
+
+	f, _ := reg.GetForest("forest-1")
+	f.Metadata = map[string]string{"env": "staging"}
+	if err := reg.UpdateForest(f); err != nil {
+		t.Fatal(err)
+	}
+	nodes, _ := reg.GetNodes("forest-1")
+	for i, node := range nodes {
+		node.IP = fmt.Sprintf("2001:db8::%d", i+1)
+		if err := reg.UpdateNode(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scriptDir := t.TempDir()
+	p.sshBinary = natsTestSSH(t, scriptDir)
+	if err := p.RefreshMetadata(context.Background(), "forest-1"); err != nil {
+		t.Fatalf("RefreshMetadata() error = %v", err)
+	}
+
+	scripts := sentScripts(t, scriptDir)
+	if len(scripts) != 3 {
+		t.Fatalf("sent %d files, want one per node", len(scripts))
+	}
+	for _, script := range scripts {
+		var m Metadata
+		if err := json.Unmarshal([]byte(script), &m); err != nil {
+			t.Fatalf("node got invalid JSON: %v\n%s", err, script)
+		}
+		self := fmt.Sprintf("2001:db8::%d", m.NodeIndex+1)
+		if m.ForestID != "forest-1" || m.Node != fmt.Sprintf("forest-1-node-%d", m.NodeIndex+1) || m.NodeCount != 3 || m.Role != "node" {
+			t.Errorf("metadata = %+v", m)
+		}
+		if len(m.PeerIPs) != 2 || slices.Contains(m.PeerIPs, self) {
+			t.Errorf("%s: peer_ips = %v, want the other two nodes", m.Node, m.PeerIPs)
+		}
+		if m.Values["env"] != "staging" || m.Values["team"] != "core" {
+			t.Errorf("%s: values = %v, want forest values over configured ones", m.Node, m.Values)
+		}
+	}
+}
+
+func TestProvisionWritesMetadata(t *testing.T) {
+	p, prov, _ := newScaleTestProvisioner(t, 0)
+
+	var userData []string
+	prov.onCreate = func(req machine.CreateServerRequest) {
+		userData = append(userData, req.UserData)
+	}
+	req := ProvisionRequest{ForestID: "app", NodeCount: 2, Location: "fsn1", Role: "web", Metadata: map[string]string{"env": "staging"}}
+	if err := p.Provision(context.Background(), req); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+
+	if len(userData) != 2 {
+		t.Fatalf("created %d servers, want 2", len(userData))
+	}
+	for i, data := range userData {
+		for _, want := range []string{
+			"path: " + MetadataPath,
+			`"node": "app-node-` + fmt.Sprint(i+1) + `"`,
+			`"role": "web"`,
+			`"env": "staging"`,
+		} {
+			if !strings.Contains(data, want) {
+				t.Errorf("user data of node %d does not contain %s", i+1, want)
+			}
+		}
+	}
+}
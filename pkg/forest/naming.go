@@ -0,0 +1,135 @@
+package forest
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// Names renders the names of a forest's resources. They come from the
+// naming templates recorded with the forest's plant request, so changing
+// naming in the config only affects forests planted afterwards.
+type Names struct {
+	naming config.NamingConfig
+	data   config.NameData
+	roles  []NodeRole
+}
+
+// requestNames returns the names of the forest planted with req
+func requestNames(req ProvisionRequest) Names {
+	n := Names{data: config.NameData{ForestID: req.ForestID, Role: req.Role, Customer: req.Customer}, roles: req.Roles}
+	if req.Naming != nil {
+		n.naming = *req.Naming
+	}
+	return n
+}
+
+// ForestNames returns the names of a registered forest. Forests planted
+// before naming templates were recorded use the default names.
+func ForestNames(f *storage.Forest) Names {
+	req := RecordedRequest(f)
+	req.Customer = f.Customer
+	return requestNames(req)
+}
+
+// Node returns the server name of the node with the given (0-based) index
+func (n Names) Node(index int) string {
+	return n.naming.ServerName(n.at(index))
+}
+
+// Record returns the name of the A/AAAA records of the node with the given
+// (0-based) index
+func (n Names) Record(index int) string {
+	return n.naming.DNSRecordName(n.at(index))
+}
+
+// Primary returns the name of the CNAME following the forest's floating IP
+func (n Names) Primary() string {
+	return n.naming.PrimaryRecordName(n.data)
+}
+
+// FloatingIP returns the name of the forest's floating IP
+func (n Names) FloatingIP() string {
+	return n.naming.FloatingIPName(n.data)
+}
+
+// ForestRecord returns the name of the A/AAAA record of the forest's
+// floating IP
+func (n Names) ForestRecord() string {
+	return n.naming.ForestRecordName(n.data)
+}
+
+func (n Names) at(index int) config.NameData {
+	data := n.data
+	data.Index = index + 1
+	data.Role = layoutRole(n.roles, data.Role, index)
+	return data
+}
+
+// names returns the names of a forest in the registry, or the default ones
+// if it is not registered (anymore)
+func (p *Provisioner) names(forestID string) Names {
+	if f, err := p.storage.GetForest(forestID); err == nil {
+		return ForestNames(f)
+	}
+	return requestNames(ProvisionRequest{ForestID: forestID})
+}
+
+// Placeholders rendered into naming templates to turn them into patterns
+const (
+	forestPlaceholder   = "xforestx"
+	customerPlaceholder = "xcustomerx"
+	rolePlaceholder     = "xrolex"
+	indexPlaceholder    = 987654321
+)
+
+// recordPatterns returns patterns matching the names of the node, primary
+// and floating IP DNS records of forests named with naming or with the
+// default templates (forests planted before naming was set, or without the
+// data it needs, e.g. a customer), with the forest ID as their first group.
+// Floating IP record patterns come last: by default the record is named
+// after the forest alone, which any name matches. Templates that leave out
+// the forest ID tell no forest apart and have no pattern.
+func recordPatterns(naming config.NamingConfig) []*regexp.Regexp {
+	data := config.NameData{ForestID: forestPlaceholder, Index: indexPlaceholder, Role: rolePlaceholder, Customer: customerPlaceholder}
+	var names []string
+	for _, n := range []config.NamingConfig{naming, {}} {
+		names = append(names, n.DNSRecordName(data), n.PrimaryRecordName(data))
+	}
+	for _, n := range []config.NamingConfig{naming, {}} {
+		names = append(names, n.ForestRecordName(data))
+	}
+
+	var patterns []*regexp.Regexp
+	seen := make(map[string]bool)
+	for _, name := range names {
+		// Templates may change case, DNS names ignore it
+		name = strings.ToLower(name)
+		if !strings.Contains(name, forestPlaceholder) || seen[name] {
+			continue
+		}
+		seen[name] = true
+		expr := regexp.QuoteMeta(name)
+		expr = strings.Replace(expr, forestPlaceholder, "(.+?)", 1)
+		expr = strings.ReplaceAll(expr, forestPlaceholder, ".+?")
+		expr = strings.ReplaceAll(expr, customerPlaceholder, ".+?")
+		expr = strings.ReplaceAll(expr, rolePlaceholder, "[a-z0-9]+")
+		expr = strings.ReplaceAll(expr, strconv.Itoa(indexPlaceholder), `\d+`)
+		patterns = append(patterns, regexp.MustCompile("(?i)^"+expr+"$"))
+	}
+	return patterns
+}
+
+// recordForestID returns the ID of the forest a DNS record name belongs to,
+// going by patterns, or the name itself if it matches none
+func recordForestID(patterns []*regexp.Regexp, name string) string {
+	for _, re := range patterns {
+		if m := re.FindStringSubmatch(name); m != nil {
+			return m[1]
+		}
+	}
+	return name
+}
@@ -0,0 +1,115 @@
+package forest
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/dns"
+)
+
+func TestNamingTemplates(t *testing.T) {
+	p, prov, _ := newScaleTestProvisioner(t, 0)
+	p.config.Naming.Server = `{{.Customer}}-{{.Role}}-{{printf "%02d" .Index}}`
+	ctx := context.Background()
+
+	if err := p.Provision(ctx, ProvisionRequest{ForestID: "app", NodeCount: 1, Location: "fsn1", Role: "web", Customer: "acme"}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+
+	// Later config changes leave existing forests' names alone
+	p.config.Naming = config.NamingConfig{}
+	if _, err := p.Scale(ctx, ScaleRequest{ForestID: "app", TargetCount: 2}); err != nil {
+		t.Fatalf("Scale() error = %v", err)
+	}
+
+	servers, _ := prov.ListServers(ctx, map[string]string{"forest-id": "app"})
+	var names []string
+	for _, s := range servers {
+		names = append(names, s.Name)
+	}
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "acme-web-01" || names[1] != "acme-web-02" {
+		t.Errorf("server names = %v, want [acme-web-01 acme-web-02]", names)
+	}
+}
+
+func TestFailoverByTemplatedName(t *testing.T) {
+	p, _, st := newScaleTestProvisioner(t, 0)
+	p.config.Naming.Server = "{{.ForestID}}-{{.Role}}{{.Index}}"
+	ctx := context.Background()
+
+	if err := p.Provision(ctx, ProvisionRequest{ForestID: "ha", NodeCount: 2, Location: "fsn1", FloatingIP: "ipv4", Role: "db"}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+	nodes, _ := st.GetNodes("ha")
+	node, err := p.Failover(ctx, FailoverRequest{ForestID: "ha", Target: "ha-db2"})
+	if err != nil {
+		t.Fatalf("Failover() error = %v", err)
+	}
+	if node.ID != nodes[1].ID {
+		t.Errorf("Failover() picked %q, want %q", node.ID, nodes[1].ID)
+	}
+	if _, err := p.Failover(ctx, FailoverRequest{ForestID: "ha", Target: "ha-node-2"}); err == nil {
+		t.Error("Expected error failing over to a default node name")
+	}
+}
+
+func TestFloatingIPNames(t *testing.T) {
+	p, prov, _ := newScaleTestProvisioner(t, 0)
+	p.SetReporter(ReporterFunc(func(Event) {}))
+	zone := &failoverDNS{}
+	p.dns = zone
+	p.config.DNS.Domain = "example.com"
+	p.config.Naming.FloatingIP = "{{.Customer}}-{{.ForestID}}-vip"
+	p.config.Naming.ForestRecord = "vip.{{.ForestID}}"
+	ctx := context.Background()
+
+	if err := p.Provision(ctx, ProvisionRequest{ForestID: "ha", NodeCount: 1, Location: "fsn1", FloatingIP: "ipv4", Customer: "acme"}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+	for _, fip := range prov.fips {
+		if fip.Name != "acme-ha-vip" {
+			t.Errorf("floating IP name = %q, want acme-ha-vip", fip.Name)
+		}
+	}
+	found := false
+	for _, r := range zone.records {
+		found = found || (r.Name == "vip.ha" && r.Type == dns.RecordTypeA)
+	}
+	if !found {
+		t.Errorf("records = %+v, want an A record vip.ha", zone.records)
+	}
+
+	// Teardown finds the records by the recorded names, not the config's
+	p.config.Naming = config.NamingConfig{}
+	if err := p.Teardown(ctx, "ha"); err != nil {
+		t.Fatalf("Teardown() error = %v", err)
+	}
+	for _, r := range zone.records {
+		t.Errorf("record %s %s left after teardown", r.Name, r.Type)
+	}
+}
+
+func TestRecordForestID(t *testing.T) {
+	patterns := recordPatterns(config.NamingConfig{
+		DNSRecord:     "{{.Role}}{{.Index}}.{{.ForestID | upper}}",
+		PrimaryRecord: "primary.{{.ForestID}}",
+		ForestRecord:  "vip.{{.ForestID}}",
+	})
+	tests := map[string]string{
+		"web3.forest-12":      "forest-12",
+		"primary.forest-12":   "forest-12",
+		"vip.forest-12":       "forest-12", // The floating IP record
+		"forest-12-node-3":    "forest-12", // Default names, e.g. of older forests
+		"forest-12-primary":   "forest-12",
+		"forest-12":           "forest-12",
+		"acme-node-1.unknown": "acme-node-1.unknown",
+	}
+	for name, want := range tests {
+		if got := recordForestID(patterns, name); got != want {
+			t.Errorf("recordForestID(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
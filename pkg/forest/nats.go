@@ -0,0 +1,270 @@
+package forest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/nats"
+	"github.com/nimsforest/morpheus/pkg/secretstore"
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+)
+
+// natsRouteUser is the user cluster routes authenticate as
+const natsRouteUser = "route"
+
+// natsState is what a forest's NATS cluster keeps between bootstraps, so
+// that re-running the bootstrap after scaling keeps its credentials and CA
+type natsState struct {
+	routePass string
+	ca        *nats.CA // nil without TLS
+}
+
+// BootstrapNATS installs nats-server on every node of a forest and
+// configures them as one cluster. Nodes are configured one at a time;
+// nodes already running NATS reload the new configuration, so it is safe to
+// run again after the forest changed size.
+func (p *Provisioner) BootstrapNATS(ctx context.Context, forestID string) error {
+	cfg := p.config.Provisioning.NATS
+	nodes, err := p.storage.GetNodes(forestID)
+	if err != nil {
+		return fmt.Errorf("failed to get nodes: %w", err)
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("forest %s has no nodes", forestID)
+	}
+
+	clusterName := cfg.ClusterName
+	if clusterName == "" {
+		clusterName = forestID
+	}
+	state, err := p.loadNATSState(ctx, forestID, clusterName)
+	if err != nil {
+		return err
+	}
+
+	// Nodes that all joined the mesh route to each other over it, and
+	// only accept routes there
+	mesh := true
+	for _, node := range nodes {
+		mesh = mesh && node.MeshIP != ""
+	}
+	var routes []string
+	for _, node := range nodes {
+		if mesh {
+			routes = append(routes, node.MeshIP)
+		} else {
+			routes = append(routes, node.IP)
+		}
+	}
+	var accounts []nats.Account
+	for _, a := range cfg.Accounts {
+		account := nats.Account{Name: a.Name, JetStream: a.JetStream && cfg.JetStream, Users: map[string]string{}}
+		for _, u := range a.Users {
+			account.Users[u.User] = u.GetPassword()
+		}
+		accounts = append(accounts, account)
+	}
+
+	identity := p.sshIdentity()
+
+	names := p.names(forestID)
+	var failed []string
+	for i, node := range nodes {
+		name := names.Node(i)
+		e := Event{Type: StepStarted, Step: StepNATS, Level: 1, Node: name, Message: "Configuring " + name}
+		p.report(e)
+
+		server := nats.ServerConfig{
+			ServerName:  name,
+			ClusterName: clusterName,
+			Routes:      routes,
+			RouteUser:   natsRouteUser,
+			RoutePass:   state.routePass,
+			JetStream:   cfg.JetStream,
+			Accounts:    accounts,
+		}
+		if mesh {
+			server.ClusterHost = node.MeshIP
+		}
+		if state.ca != nil {
+			hosts := []string{node.IP, node.IPv6, node.IPv4, node.MeshIP, name}
+			if p.config.DNS.Domain != "" {
+				hosts = append(hosts, name+"."+p.config.DNS.Domain)
+			}
+			cert, key, err := state.ca.Issue(name, hosts)
+			if err != nil {
+				return err
+			}
+			server.TLS = &nats.TLSFiles{CA: state.ca.CertPEM, Cert: cert, Key: key}
+		}
+		script, err := nats.BootstrapScript(cfg.GetVersion(), server)
+		if err != nil {
+			return err
+		}
+
+		// The script carries credentials, so it is sent on stdin rather
+		// than the command line
+		var stderr bytes.Buffer
+		target := sshutil.Target{Name: name, Addr: node.IP, HostKey: node.HostKey}
+		result := sshutil.RunParallel(ctx, []sshutil.Target{target}, "sh -s", sshutil.ExecOptions{
+			IdentityFile: identity,
+			Timeout:      10 * time.Minute,
+			Stderr:       &stderr,
+			Stdin:        []byte(script),
+			SSHBinary:    p.sshBinary,
+		})[0]
+		if result.Err != nil {
+			e.Type, e.Err = StepFailed, result.Err
+			p.report(e)
+			if msg := lastLine(stderr.String()); msg != "" {
+				p.info(2, "%s", msg)
+			}
+			failed = append(failed, name)
+			continue
+		}
+		e.Type = StepCompleted
+		p.report(e)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("NATS bootstrap failed on %s", strings.Join(failed, ", "))
+	}
+	p.info(1, "🔗 NATS cluster %s running on %d node%s (nats://<node>:%d)", clusterName, len(nodes), plural(len(nodes)), nats.ClientPort)
+	if mesh {
+		p.info(1, "🕸️  Cluster routes run over the WireGuard mesh")
+	}
+	if state.ca != nil {
+		p.info(1, "🔒 Clients verify the servers with %s", p.natsCALocation(forestID))
+	}
+	return nil
+}
+
+func (p *Provisioner) natsStateDir(forestID string) string {
+	return filepath.Join(p.config.Provisioning.NATS.GetStateDir(), forestID)
+}
+
+// natsSecrets returns where a forest's NATS credentials and CA are kept:
+// below nats/<forest>/ in the secret store, or as plain files in the NATS
+// state directory without one
+func (p *Provisioner) natsSecrets(forestID string) secretstore.Store {
+	if p.secrets != nil {
+		return secretstore.NewPrefixStore(p.secrets, "nats/"+forestID)
+	}
+	return secretstore.NewDirStore(p.natsStateDir(forestID))
+}
+
+// natsCALocation describes where clients find a forest's NATS CA
+func (p *Provisioner) natsCALocation(forestID string) string {
+	if p.secrets != nil {
+		return fmt.Sprintf("nats/%s/ca.pem in the secret store (morpheus secrets get nats/%s/ca.pem)", forestID, forestID)
+	}
+	return filepath.Join(p.natsStateDir(forestID), "ca.pem")
+}
+
+// loadNATSState reads a forest's NATS credentials and CA, generating them
+// on the first bootstrap
+func (p *Provisioner) loadNATSState(ctx context.Context, forestID, clusterName string) (*natsState, error) {
+	store := p.natsSecrets(forestID)
+	if p.secrets != nil {
+		if err := p.migrateNATSState(ctx, forestID, store); err != nil {
+			return nil, err
+		}
+	}
+	state := &natsState{}
+
+	data, err := store.Get(ctx, "route-password")
+	switch {
+	case err == nil:
+		state.routePass = strings.TrimSpace(string(data))
+	case errors.Is(err, secretstore.ErrNotFound):
+		b := make([]byte, 24)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate route password: %w", err)
+		}
+		state.routePass = hex.EncodeToString(b)
+		if err := store.Put(ctx, "route-password", []byte(state.routePass+"\n")); err != nil {
+			return nil, fmt.Errorf("failed to save route password: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("failed to read route password: %w", err)
+	}
+
+	if !p.config.Provisioning.NATS.TLS {
+		return state, nil
+	}
+	certPEM, certErr := store.Get(ctx, "ca.pem")
+	keyPEM, keyErr := store.Get(ctx, "ca-key.pem")
+	if certErr == nil && keyErr == nil {
+		if state.ca, err = nats.LoadCA(certPEM, keyPEM); err != nil {
+			return nil, fmt.Errorf("NATS CA of %s: %w", forestID, err)
+		}
+		return state, nil
+	}
+	if !errors.Is(certErr, secretstore.ErrNotFound) || !errors.Is(keyErr, secretstore.ErrNotFound) {
+		return nil, fmt.Errorf("incomplete NATS CA of %s (need ca.pem and ca-key.pem): %w", forestID, errors.Join(certErr, keyErr))
+	}
+	if state.ca, err = nats.NewCA(clusterName); err != nil {
+		return nil, err
+	}
+	if err := store.Put(ctx, "ca-key.pem", state.ca.KeyPEM); err != nil {
+		return nil, fmt.Errorf("failed to save NATS CA: %w", err)
+	}
+	if err := store.Put(ctx, "ca.pem", state.ca.CertPEM); err != nil {
+		return nil, fmt.Errorf("failed to save NATS CA: %w", err)
+	}
+	return state, nil
+}
+
+// migrateNATSState moves NATS credentials and a CA kept as plain files
+// before a secret store was configured into the store, so the cluster keeps
+// them
+func (p *Provisioner) migrateNATSState(ctx context.Context, forestID string, store secretstore.Store) error {
+	dir := p.natsStateDir(forestID)
+	legacy := secretstore.NewDirStore(dir)
+	keys, err := legacy.List(ctx, "")
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+	if stored, err := store.List(ctx, ""); err != nil || len(stored) > 0 {
+		return err
+	}
+	for _, key := range keys {
+		data, err := legacy.Get(ctx, key)
+		if err == nil {
+			err = store.Put(ctx, key, data)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to move NATS state of %s to the secret store: %w", forestID, err)
+		}
+	}
+	p.info(1, "🔐 Moved the NATS credentials and CA of %s from %s to the secret store", forestID, dir)
+	return os.RemoveAll(dir)
+}
+
+// removeNATSState deletes a torn-down forest's NATS credentials and CA
+func (p *Provisioner) removeNATSState(ctx context.Context, forestID string) {
+	if !p.config.Provisioning.NATS.Enabled || forestID == "" {
+		return
+	}
+	if p.secrets == nil {
+		os.RemoveAll(p.natsStateDir(forestID))
+		return
+	}
+	if err := secretstore.DeletePrefix(ctx, p.secrets, "nats/"+forestID+"/"); err != nil {
+		p.info(1, "⚠️  Failed to remove NATS secrets of %s: %s", forestID, err)
+	}
+}
+
+// lastLine returns the last non-empty line of s
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
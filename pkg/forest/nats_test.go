@@ -0,0 +1,189 @@
+package forest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/secretstore"
+)
+
+// natsTestSSH returns an ssh client that saves the script it is sent to
+// dir, one file per session
+func natsTestSSH(t *testing.T, dir string) string {
+	t.Helper()
+	script := `#!/bin/sh
+cat > "$(mktemp "` + dir + `/script-XXXXXX")"
+`
+	path := filepath.Join(t.TempDir(), "ssh")
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func sentScripts(t *testing.T, dir string) []string {
+	t.Helper()
+	files, _ := filepath.Glob(filepath.Join(dir, "script-*"))
+	var scripts []string
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scripts = append(scripts, string(data))
+		os.Remove(f)
+	}
+	sort.Strings(scripts)
+	return scripts
+}
+
+func TestBootstrapNATS(t *testing.T) {
+	p, _, _ := newScaleTestProvisioner(t, 2)
+	stateDir := t.TempDir()
+	p.config.Provisioning.NATS = config.NATSConfig{
+		Enabled:   true,
+		JetStream: true,
+		TLS:       true,
+		StateDir:  stateDir,
+		Accounts: []config.NATSAccountConfig{
+			{Name: "APP", Users: []config.NATSUserConfig{{User: "app", Password: "${TEST_NATS_PASSWORD}"}}},
+		},
+	}
+	t.Setenv("TEST_NATS_PASSWORD", "app-secret")
+	scriptDir := t.TempDir()
+	p.sshBinary = natsTestSSH(t, scriptDir)
+
+	if err := p.BootstrapNATS(context.Background(), "forest-1"); err != nil {
+		t.Fatalf("BootstrapNATS() error = %v", err)
+	}
+
+	scripts := sentScripts(t, scriptDir)
+	if len(scripts) != 2 {
+		t.Fatalf("sent %d scripts, want one per node", len(scripts))
+	}
+	pass, err := os.ReadFile(filepath.Join(stateDir, "forest-1", "route-password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	route := "nats-route://route:" + strings.TrimSpace(string(pass)) + "@[::1]:6222"
+	for i, script := range scripts {
+		for _, want := range []string{
+			`server_name: "forest-1-node-` + string(rune('1'+i)) + `"`,
+			`name: "forest-1"`,
+			route,
+			`{user: "app", password: "app-secret"}`,
+			"BEGIN CERTIFICATE",
+		} {
+			if !strings.Contains(script, want) {
+				t.Errorf("script %d does not contain %s", i+1, want)
+			}
+		}
+		if strings.Count(script, route) != 2 {
+			t.Errorf("script %d has %d routes, want 2", i+1, strings.Count(script, route))
+		}
+	}
+	for _, f := range []string{"ca.pem", "ca-key.pem"} {
+		if _, err := os.Stat(filepath.Join(stateDir, "forest-1", f)); err != nil {
+			t.Errorf("%s not saved: %v", f, err)
+		}
+	}
+
+	// Later bootstraps keep the credentials and CA
+	caBefore, _ := os.ReadFile(filepath.Join(stateDir, "forest-1", "ca.pem"))
+	if err := p.BootstrapNATS(context.Background(), "forest-1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, script := range sentScripts(t, scriptDir) {
+		if !strings.Contains(script, route) || !strings.Contains(script, string(caBefore)) {
+			t.Error("second bootstrap changed the route password or CA")
+		}
+	}
+
+	// Teardown forgets them
+	p.removeNATSState(context.Background(), "forest-1")
+	if _, err := os.Stat(filepath.Join(stateDir, "forest-1")); !os.IsNotExist(err) {
+		t.Error("NATS state not removed")
+	}
+}
+
+func TestNATSStateInSecretStore(t *testing.T) {
+	p, _, _ := newScaleTestProvisioner(t, 1)
+	stateDir := t.TempDir()
+	p.config.Provisioning.NATS = config.NATSConfig{Enabled: true, TLS: true, StateDir: stateDir}
+	ctx := context.Background()
+
+	// State kept as plain files before the store was configured moves into it
+	legacy := filepath.Join(stateDir, "forest-1")
+	os.MkdirAll(legacy, 0700)
+	os.WriteFile(filepath.Join(legacy, "route-password"), []byte("old-pass\n"), 0600)
+
+	id, err := secretstore.GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := secretstore.NewAgeStore(secretstore.NewDirStore(t.TempDir()), nil, []*secretstore.Identity{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetSecrets(store)
+
+	state, err := p.loadNATSState(ctx, "forest-1", "forest-1")
+	if err != nil {
+		t.Fatalf("loadNATSState() error = %v", err)
+	}
+	if state.routePass != "old-pass" || state.ca == nil {
+		t.Errorf("state = %q, CA %v; want the old password and a new CA", state.routePass, state.ca != nil)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Error("plain NATS state not removed after moving it")
+	}
+	keys, _ := store.List(ctx, "")
+	if strings.Join(keys, " ") != "nats/forest-1/ca-key.pem nats/forest-1/ca.pem nats/forest-1/route-password" {
+		t.Errorf("stored keys = %v", keys)
+	}
+
+	p.removeNATSState(ctx, "forest-1")
+	if keys, _ := store.List(ctx, ""); len(keys) != 0 {
+		t.Errorf("keys after teardown = %v", keys)
+	}
+}
+
+func TestScaleUpReconfiguresNATS(t *testing.T) {
+	p, _, _ := newScaleTestProvisioner(t, 1)
+	p.config.Provisioning.NATS = config.NATSConfig{Enabled: true, StateDir: t.TempDir()}
+	scriptDir := t.TempDir()
+	p.sshBinary = natsTestSSH(t, scriptDir)
+
+	if _, err := p.Scale(context.Background(), ScaleRequest{ForestID: "forest-1", TargetCount: 3}); err != nil {
+		t.Fatalf("Scale() error = %v", err)
+	}
+
+	// Every node, old and new, gets routes to all three
+	scripts := sentScripts(t, scriptDir)
+	if len(scripts) != 3 {
+		t.Fatalf("sent %d scripts, want 3", len(scripts))
+	}
+	for i, script := range scripts {
+		if n := strings.Count(script, "nats-route://"); n != 3 {
+			t.Errorf("script %d has %d routes, want 3", i+1, n)
+		}
+	}
+}
+
+func TestBootstrapNATSReportsFailedNodes(t *testing.T) {
+	p, _, _ := newScaleTestProvisioner(t, 2)
+	p.config.Provisioning.NATS = config.NATSConfig{Enabled: true, StateDir: t.TempDir()}
+	fail := filepath.Join(t.TempDir(), "ssh")
+	os.WriteFile(fail, []byte("#!/bin/sh\necho 'curl: (6) Could not resolve host' >&2\nexit 1\n"), 0755)
+	p.sshBinary = fail
+
+	err := p.BootstrapNATS(context.Background(), "forest-1")
+	if err == nil || !strings.Contains(err.Error(), "forest-1-node-1, forest-1-node-2") {
+		t.Errorf("BootstrapNATS() error = %v, want both nodes reported", err)
+	}
+}
@@ -0,0 +1,151 @@
+package forest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+// StatusPaused marks a forest whose nodes were powered off by Pause
+const StatusPaused = "paused"
+
+// PauseRequest asks for all nodes of a forest to be powered off
+type PauseRequest struct {
+	ForestID string
+
+	// Force and ShutdownTimeout are as in PowerRequest
+	Force           bool
+	ShutdownTimeout time.Duration
+}
+
+// Pause powers off all nodes of a forest, gracefully as with Power, and
+// marks it paused. The servers keep their disks, volumes and IPs, so
+// ResumePaused brings the forest back as it was, without rebuilding it.
+// Nodes that are already stopped are skipped.
+func (p *Provisioner) Pause(ctx context.Context, req PauseRequest) error {
+	pm, ok := p.machine.(machine.PowerManager)
+	if !ok {
+		return fmt.Errorf("machine provider %s does not support power actions", p.config.GetMachineProvider())
+	}
+	f, err := p.storage.GetForest(req.ForestID)
+	if err != nil {
+		return err
+	}
+	if f.Status == StatusPaused {
+		return fmt.Errorf("forest %s is already paused", req.ForestID)
+	}
+	if f.Status != StatusActive && f.Status != StatusDegraded {
+		return fmt.Errorf("forest %s is %s; only active or degraded forests can be paused", req.ForestID, f.Status)
+	}
+	nodes, err := p.storage.GetNodes(req.ForestID)
+	if err != nil {
+		return fmt.Errorf("failed to get nodes: %w", err)
+	}
+	timeout := req.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+
+	names := ForestNames(f)
+	var errs []error
+	for i, node := range nodes {
+		name := names.Node(i)
+		if node.Status == NodeStatusStopped {
+			p.info(1, "%s is already powered off", name)
+			continue
+		}
+		e := Event{Type: StepStarted, Step: StepPower, Number: i + 1, Total: len(nodes), Node: name, Message: "Powering off " + name}
+		p.report(e)
+		if err := p.powerOff(ctx, pm, node, name, req.Force, timeout); err != nil {
+			e.Type, e.Err = StepFailed, err
+			p.report(e)
+			errs = append(errs, err)
+			continue
+		}
+		p.setNodeStatus(req.ForestID, node.ID, NodeStatusStopped)
+		e.Type, e.Message = StepCompleted, "Powered off "+name
+		p.report(e)
+	}
+
+	// A partly paused forest is still marked paused, so resuming it
+	// powers the stopped nodes on again
+	f.Status = StatusPaused
+	if err := p.storage.UpdateForest(f); err != nil {
+		errs = append(errs, fmt.Errorf("failed to update forest: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// ResumePaused powers the stopped nodes of a paused forest on again, waits
+// until all nodes are reachable and then runs the forest's verification
+// checks, marking it active or degraded as after a plant.
+func (p *Provisioner) ResumePaused(ctx context.Context, forestID string) error {
+	pm, ok := p.machine.(machine.PowerManager)
+	if !ok {
+		return fmt.Errorf("machine provider %s does not support power actions", p.config.GetMachineProvider())
+	}
+	f, err := p.storage.GetForest(forestID)
+	if err != nil {
+		return err
+	}
+	if f.Status != StatusPaused {
+		return fmt.Errorf("forest %s is %s, not paused", forestID, f.Status)
+	}
+	nodes, err := p.storage.GetNodes(forestID)
+	if err != nil {
+		return fmt.Errorf("failed to get nodes: %w", err)
+	}
+
+	// Power everything on first, so the nodes boot side by side
+	names := ForestNames(f)
+	var errs []error
+	started := make([]bool, len(nodes))
+	for i, node := range nodes {
+		if node.Status != NodeStatusStopped {
+			started[i] = true
+			continue
+		}
+		p.info(1, "Powering on %s", names.Node(i))
+		if err := pm.PowerOnServer(ctx, node.ID); err != nil {
+			errs = append(errs, fmt.Errorf("failed to power on %s: %w", names.Node(i), err))
+			continue
+		}
+		started[i] = true
+	}
+	for i, node := range nodes {
+		if !started[i] {
+			continue
+		}
+		name := names.Node(i)
+		e := Event{Type: StepStarted, Step: StepPower, Number: i + 1, Total: len(nodes), Node: name, Message: "Waiting for " + name}
+		p.report(e)
+		if err := p.waitForNode(ctx, node, name); err != nil {
+			e.Type, e.Err = StepFailed, err
+			p.report(e)
+			errs = append(errs, err)
+			continue
+		}
+		p.setNodeStatus(forestID, node.ID, "active")
+		e.Type, e.Message = StepCompleted, name+" is up"
+		p.report(e)
+	}
+
+	status := StatusActive
+	if checks := p.verifyChecks(RecordedRequest(f).Verify); len(checks) > 0 {
+		p.report(Event{Type: StepStarted, Step: StepVerify, Message: "Verifying forest"})
+		status = p.verifyForest(ctx, forestID, checks)
+	}
+	if len(errs) > 0 {
+		status = StatusDegraded
+	}
+	if f, err := p.storage.GetForest(forestID); err == nil {
+		f.Status = status
+		if err := p.storage.UpdateForest(f); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update forest: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,61 @@
+package forest
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPauseResume(t *testing.T) {
+	p, prov, reg := newScaleTestProvisioner(t, 2)
+	p.sshBinary = "false" // Unreachable, so the power is cut right away
+	ctx := context.Background()
+	nodes, _ := reg.GetNodes("forest-1")
+
+	if err := p.ResumePaused(ctx, "forest-1"); err == nil {
+		t.Error("ResumePaused() of an active forest: expected error")
+	}
+
+	// The second node is already off and stays untouched
+	if err := reg.UpdateNodeStatus("forest-1", nodes[1].ID, NodeStatusStopped); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pause(ctx, PauseRequest{ForestID: "forest-1", ShutdownTimeout: time.Second}); err != nil {
+		t.Fatalf("Pause() error = %v", err)
+	}
+	if want := []string{"poweroff " + nodes[0].ID}; !slices.Equal(prov.power, want) {
+		t.Errorf("actions = %v, want %v", prov.power, want)
+	}
+	f, _ := reg.GetForest("forest-1")
+	if f.Status != StatusPaused {
+		t.Errorf("forest status = %s, want paused", f.Status)
+	}
+	nodes, _ = reg.GetNodes("forest-1")
+	for _, node := range nodes {
+		if node.Status != NodeStatusStopped {
+			t.Errorf("node %s status = %s, want stopped", node.ID, node.Status)
+		}
+	}
+	if err := p.Pause(ctx, PauseRequest{ForestID: "forest-1"}); err == nil {
+		t.Error("Pause() of a paused forest: expected error")
+	}
+
+	prov.power = nil
+	if err := p.ResumePaused(ctx, "forest-1"); err != nil {
+		t.Fatalf("ResumePaused() error = %v", err)
+	}
+	if want := []string{"poweron " + nodes[0].ID, "poweron " + nodes[1].ID}; !slices.Equal(prov.power, want) {
+		t.Errorf("actions = %v, want %v", prov.power, want)
+	}
+	f, _ = reg.GetForest("forest-1")
+	if f.Status != StatusActive {
+		t.Errorf("forest status = %s, want active", f.Status)
+	}
+	nodes, _ = reg.GetNodes("forest-1")
+	for _, node := range nodes {
+		if node.Status != "active" {
+			t.Errorf("node %s status = %s, want active", node.ID, node.Status)
+		}
+	}
+}
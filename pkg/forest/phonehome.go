@@ -0,0 +1,127 @@
+package forest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/cloudinit"
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/phonehome"
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+)
+
+// storageBoxMount is where the node template mounts the StorageBox
+const storageBoxMount = "/mnt/forest"
+
+// phoneHome waits for new nodes to report that cloud-init has finished
+type phoneHome struct {
+	waiter   phonehome.Waiter
+	listener *phonehome.Listener      // For phone_home: http
+	markers  *phonehome.MarkerWatcher // For phone_home: storagebox
+	timeout  time.Duration
+}
+
+// startPhoneHome sets up readiness callbacks for a forest's new nodes as
+// configured. It returns nil if they are off.
+func (p *Provisioner) startPhoneHome(forestID string) (*phoneHome, error) {
+	cfg := p.config.Provisioning
+	h := &phoneHome{timeout: cfg.GetPhoneHomeTimeout()}
+
+	switch cfg.PhoneHome {
+	case "":
+		return nil, nil
+	case "http":
+		l, err := phonehome.Listen(cfg.GetPhoneHomeListen(), cfg.PhoneHomeURL)
+		if err != nil {
+			return nil, err
+		}
+		h.listener, h.waiter = l, l
+	case "storagebox":
+		sb := p.config.Storage.StorageBox
+		if sb.Host == "" {
+			return nil, fmt.Errorf("phone_home: storagebox needs storage.storagebox to be configured")
+		}
+		m := phonehome.NewMarkerWatcher("https://"+sb.Host, forestID, sb.Username, sb.Password)
+		h.markers, h.waiter = m, m
+	default:
+		return nil, fmt.Errorf("unsupported phone_home: %s", cfg.PhoneHome)
+	}
+	return h, nil
+}
+
+// configure points a node's cloud-init at the callback
+func (h *phoneHome) configure(data *cloudinit.TemplateData) error {
+	if h == nil {
+		return nil
+	}
+	if h.listener != nil {
+		url, err := h.listener.Register(data.NodeID)
+		if err != nil {
+			return err
+		}
+		data.PhoneHomeURL = url
+	}
+	if h.markers != nil {
+		data.ReadyMarker = storageBoxMount + "/" + phonehome.MarkerPath(data.ForestID, data.NodeID)
+	}
+	return nil
+}
+
+// close stops the listener, if any
+func (h *phoneHome) close() {
+	if h != nil && h.listener != nil {
+		h.listener.Close()
+	}
+}
+
+// waitForPhoneHome waits for a node to report that cloud-init has finished
+// and records its readiness. A node that does not report in time is kept,
+// marked as timed out.
+func (p *Provisioner) waitForPhoneHome(ctx context.Context, h *phoneHome, forestID, nodeName string, server *machine.Server) error {
+	p.nodeStep(StepStarted, StepPhoneHome, nodeName, "Waiting for cloud-init to finish")
+	report, err := phonehome.WaitTimeout(ctx, h.waiter, nodeName, h.timeout)
+
+	var readiness string
+	switch {
+	case errors.Is(err, phonehome.ErrTimeout):
+		readiness = "timeout"
+		p.warn(2, "no phone-home after %s, cloud-init may still be running", h.timeout)
+	case err != nil:
+		return fmt.Errorf("waiting for phone-home: %w", err)
+	case report.HostKey != "" && server.HostKey != "" && !sshutil.SameHostKey(report.HostKey, server.HostKey):
+		readiness = "host key mismatch"
+		p.warn(2, "phone-home reported a different host key than the pinned one")
+	default:
+		readiness = "ready"
+		p.nodeStep(StepCompleted, StepPhoneHome, nodeName, "Cloud-init finished")
+	}
+	if h.markers != nil && report != nil {
+		h.markers.Remove(ctx, nodeName)
+	}
+
+	p.setNodeReadiness(forestID, server.ID, readiness, report)
+	return nil
+}
+
+// setNodeReadiness records a node's readiness in storage
+func (p *Provisioner) setNodeReadiness(forestID, serverID, readiness string, report *phonehome.Report) {
+	nodes, err := p.storage.GetNodes(forestID)
+	if err != nil {
+		return
+	}
+	for _, node := range nodes {
+		if node.ID != serverID {
+			continue
+		}
+		node.Readiness = readiness
+		if report != nil && readiness == "ready" {
+			node.ReadyAt = report.Received
+		}
+		if err := p.storage.UpdateNode(node); err != nil {
+			p.warn(2, "failed to record readiness: %s", err)
+		}
+		return
+	}
+}
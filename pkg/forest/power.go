@@ -0,0 +1,230 @@
+package forest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+const (
+	// NodeStatusStopped marks a node that was powered off
+	NodeStatusStopped = "stopped"
+
+	// NodeStatusRebooting marks a node that is being rebooted
+	NodeStatusRebooting = "rebooting"
+)
+
+// PowerAction is a change of a node's power state
+type PowerAction string
+
+const (
+	PowerReboot PowerAction = "reboot"
+	PowerOff    PowerAction = "poweroff"
+	PowerOn     PowerAction = "poweron"
+)
+
+// DefaultShutdownTimeout is how long a graceful shutdown may take before
+// the node's power is cut
+const DefaultShutdownTimeout = 2 * time.Minute
+
+// PowerRequest asks for a power action on one node of a forest
+type PowerRequest struct {
+	ForestID string
+	Node     string // Node number (1-based), node-N, server name or server ID
+	Action   PowerAction
+
+	// Force skips the graceful shutdown over SSH and uses the provider's
+	// hard reset or power off right away
+	Force bool
+
+	// ShutdownTimeout is how long a graceful shutdown may take (default
+	// DefaultShutdownTimeout)
+	ShutdownTimeout time.Duration
+}
+
+// ResolveNode finds a node of a forest by its number (1-based, also as
+// node-N), server name or server ID. It returns the node's index.
+func ResolveNode(f *storage.Forest, nodes []*storage.Node, ref string) (int, error) {
+	names := ForestNames(f)
+	number, err := strconv.Atoi(strings.TrimPrefix(ref, "node-"))
+	if err == nil && number >= 1 && number <= len(nodes) && !isNodeID(nodes, ref) {
+		return number - 1, nil
+	}
+	for i, node := range nodes {
+		if node.ID == ref || names.Node(i) == ref {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("forest %s has no node %s (%d node%s)", f.ID, ref, len(nodes), plural(len(nodes)))
+}
+
+func isNodeID(nodes []*storage.Node, ref string) bool {
+	for _, node := range nodes {
+		if node.ID == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// Power reboots, powers off or powers on a node. Reboots and power offs
+// are graceful first: the node is asked over SSH to reboot or shut down,
+// and only if it cannot be reached or does not go down in time is the
+// provider's hard reset or power off used. The node's status in the
+// registry follows: "rebooting", "stopped" and "active" again.
+func (p *Provisioner) Power(ctx context.Context, req PowerRequest) error {
+	pm, ok := p.machine.(machine.PowerManager)
+	if !ok {
+		return fmt.Errorf("machine provider %s does not support power actions", p.config.GetMachineProvider())
+	}
+	f, err := p.storage.GetForest(req.ForestID)
+	if err != nil {
+		return err
+	}
+	nodes, err := p.storage.GetNodes(req.ForestID)
+	if err != nil {
+		return fmt.Errorf("failed to get nodes: %w", err)
+	}
+	index, err := ResolveNode(f, nodes, req.Node)
+	if err != nil {
+		return err
+	}
+	node := nodes[index]
+	name := ForestNames(f).Node(index)
+	timeout := req.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+
+	switch req.Action {
+	case PowerReboot:
+		p.setNodeStatus(req.ForestID, node.ID, NodeStatusRebooting)
+		p.report(Event{Type: StepStarted, Step: StepPower, Node: name, Message: "Rebooting " + name})
+		if req.Force || !p.shutdownOverSSH(ctx, node, name, "reboot", timeout) {
+			p.info(1, "Resetting %s", name)
+			if err := pm.RebootServer(ctx, node.ID); err != nil {
+				return fmt.Errorf("failed to reboot %s: %w", name, err)
+			}
+		}
+		if err := p.waitForNode(ctx, node, name); err != nil {
+			return err
+		}
+		p.setNodeStatus(req.ForestID, node.ID, "active")
+		p.report(Event{Type: StepCompleted, Step: StepPower, Node: name, Message: "Rebooted " + name})
+
+	case PowerOff:
+		p.report(Event{Type: StepStarted, Step: StepPower, Node: name, Message: "Powering off " + name})
+		if err := p.powerOff(ctx, pm, node, name, req.Force, timeout); err != nil {
+			return err
+		}
+		p.setNodeStatus(req.ForestID, node.ID, NodeStatusStopped)
+		p.report(Event{Type: StepCompleted, Step: StepPower, Node: name, Message: "Powered off " + name})
+
+	case PowerOn:
+		p.report(Event{Type: StepStarted, Step: StepPower, Node: name, Message: "Powering on " + name})
+		if err := pm.PowerOnServer(ctx, node.ID); err != nil {
+			return fmt.Errorf("failed to power on %s: %w", name, err)
+		}
+		if err := p.waitForNode(ctx, node, name); err != nil {
+			return err
+		}
+		p.setNodeStatus(req.ForestID, node.ID, "active")
+		p.report(Event{Type: StepCompleted, Step: StepPower, Node: name, Message: "Powered on " + name})
+
+	default:
+		return fmt.Errorf("unknown power action %q (use reboot, poweroff or poweron)", req.Action)
+	}
+	return nil
+}
+
+// powerOff shuts a node down over SSH, unless force is set, and cuts its
+// power if that fails or takes longer than timeout
+func (p *Provisioner) powerOff(ctx context.Context, pm machine.PowerManager, node *storage.Node, name string, force bool, timeout time.Duration) error {
+	stopped := false
+	if !force && p.shutdownOverSSH(ctx, node, name, "poweroff", timeout) {
+		waitCtx, cancel := context.WithTimeout(ctx, timeout)
+		stopped = p.machine.WaitForServer(waitCtx, node.ID, machine.ServerStateStopped) == nil
+		cancel()
+		if !stopped {
+			p.warn(1, "%s did not shut down within %s", name, timeout)
+		}
+	}
+	if !stopped {
+		p.info(1, "Cutting the power of %s", name)
+		if err := pm.PowerOffServer(ctx, node.ID); err != nil {
+			return fmt.Errorf("failed to power off %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// shutdownOverSSH asks a node to reboot or power off ("reboot" or
+// "poweroff") and, for reboots, waits until its SSH port has gone down. It
+// reports whether the node took the request.
+func (p *Provisioner) shutdownOverSSH(ctx context.Context, node *storage.Node, name, verb string, timeout time.Duration) bool {
+	// Detach, so the command returns before the connection is torn down
+	command := fmt.Sprintf("nohup sh -c 'sleep 1; systemctl %s' >/dev/null 2>&1 &", verb)
+	target := sshutil.Target{Name: name, Addr: node.IP, HostKey: node.HostKey}
+	result := sshutil.RunParallel(ctx, []sshutil.Target{target}, command, sshutil.ExecOptions{
+		IdentityFile: p.sshIdentity(),
+		Timeout:      30 * time.Second,
+		SSHBinary:    p.sshBinary,
+	})[0]
+	if result.Err != nil {
+		p.warn(1, "graceful %s of %s failed: %s", verb, name, result.Err)
+		return false
+	}
+	if verb == "reboot" {
+		p.waitForSSHDown(ctx, node, timeout)
+	}
+	return true
+}
+
+// waitForSSHDown waits until a rebooting node stops accepting SSH
+// connections, so it is not mistaken for being back up. Nodes that reboot
+// faster than the check are fine too.
+func (p *Provisioner) waitForSSHDown(ctx context.Context, node *storage.Node, timeout time.Duration) {
+	addr := sshutil.FormatSSHAddress(node.IP, p.config.Provisioning.SSHPort)
+	interval := p.config.Provisioning.GetReadinessInterval()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		conn, err := net.DialTimeout("tcp", addr, interval)
+		if err != nil {
+			return
+		}
+		conn.Close()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// waitForNode waits until a node's server is running and reachable again
+func (p *Provisioner) waitForNode(ctx context.Context, node *storage.Node, name string) error {
+	if err := p.machine.WaitForServer(ctx, node.ID, machine.ServerStateRunning); err != nil {
+		return fmt.Errorf("%s failed to start: %w", name, err)
+	}
+	server, err := p.machine.GetServer(ctx, node.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get server info: %w", err)
+	}
+	if err := p.waitForInfrastructureReady(ctx, server); err != nil {
+		return fmt.Errorf("%s is not reachable: %w", name, err)
+	}
+	return nil
+}
+
+func (p *Provisioner) setNodeStatus(forestID, nodeID, status string) {
+	if err := p.storage.UpdateNodeStatus(forestID, nodeID, status); err != nil {
+		p.warn(1, "failed to update node status: %s", err)
+	}
+}
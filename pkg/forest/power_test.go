@@ -0,0 +1,66 @@
+package forest
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestResolveNode(t *testing.T) {
+	_, _, reg := newScaleTestProvisioner(t, 3)
+	f, _ := reg.GetForest("forest-1")
+	nodes, _ := reg.GetNodes("forest-1")
+
+	for ref, want := range map[string]int{"2": 1, "node-3": 2, "forest-1-node-1": 0, nodes[1].ID: 1} {
+		if got, err := ResolveNode(f, nodes, ref); err != nil || got != want {
+			t.Errorf("ResolveNode(%q) = %d, %v; want %d", ref, got, err, want)
+		}
+	}
+	for _, ref := range []string{"0", "4", "node-x", "other-node-1"} {
+		if _, err := ResolveNode(f, nodes, ref); err == nil {
+			t.Errorf("ResolveNode(%q) found a node", ref)
+		}
+	}
+}
+
+func TestPower(t *testing.T) {
+	p, prov, reg := newScaleTestProvisioner(t, 2)
+	p.sshBinary = natsTestSSH(t, t.TempDir())
+	ctx := context.Background()
+	nodes, _ := reg.GetNodes("forest-1")
+	id := nodes[1].ID
+
+	status := func() string {
+		nodes, _ := reg.GetNodes("forest-1")
+		return nodes[1].Status
+	}
+
+	// Graceful: the node shuts itself down, no hard action needed
+	if err := p.Power(ctx, PowerRequest{ForestID: "forest-1", Node: "2", Action: PowerOff}); err != nil {
+		t.Fatalf("Power(poweroff) error = %v", err)
+	}
+	if len(prov.power) != 0 || status() != NodeStatusStopped {
+		t.Errorf("graceful power off: actions %v, status %s", prov.power, status())
+	}
+
+	if err := p.Power(ctx, PowerRequest{ForestID: "forest-1", Node: "2", Action: PowerOn}); err != nil {
+		t.Fatalf("Power(poweron) error = %v", err)
+	}
+	if err := p.Power(ctx, PowerRequest{ForestID: "forest-1", Node: "2", Action: PowerReboot, Force: true}); err != nil {
+		t.Fatalf("Power(reboot) error = %v", err)
+	}
+	if want := []string{"poweron " + id, "reboot " + id}; !slices.Equal(prov.power, want) || status() != "active" {
+		t.Errorf("actions = %v, status %s; want %v, active", prov.power, status(), want)
+	}
+
+	// Unreachable nodes get their power cut
+	prov.power = nil
+	p.sshBinary = "false"
+	if err := p.Power(ctx, PowerRequest{ForestID: "forest-1", Node: "2", Action: PowerOff, ShutdownTimeout: time.Second}); err != nil {
+		t.Fatalf("Power(poweroff) error = %v", err)
+	}
+	if want := []string{"poweroff " + id}; !slices.Equal(prov.power, want) {
+		t.Errorf("actions = %v, want %v", prov.power, want)
+	}
+}
@@ -0,0 +1,233 @@
+package forest
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// EventType is the kind of a progress event
+type EventType string
+
+const (
+	// StepStarted is sent when a step begins, e.g. creating a server
+	StepStarted EventType = "step_started"
+	// StepCompleted is sent when a step succeeded
+	StepCompleted EventType = "step_completed"
+	// StepFailed is sent when a step failed; Err says why
+	StepFailed EventType = "step_failed"
+	// Info carries details of the current step, e.g. a DNS record created
+	Info EventType = "info"
+	// Warning reports a problem that did not stop the operation
+	Warning EventType = "warning"
+)
+
+// Step names what an event is about
+type Step string
+
+// Steps of plant, grow, teardown and snapshot
+const (
+	StepMachines     Step = "machines"    // Provisioning all machines of a forest
+	StepMachine      Step = "machine"     // Provisioning one machine
+	StepCloudInit    Step = "cloud-init"  // Rendering a machine's cloud-init
+	StepVolume       Step = "volume"      // Creating a machine's volume
+	StepServer       Step = "server"      // Creating a server
+	StepBoot         Step = "boot"        // Waiting for a server to run
+	StepSSH          Step = "ssh"         // Waiting for SSH
+	StepPhoneHome    Step = "phone-home"  // Waiting for cloud-init to finish
+	StepPlacement    Step = "placement"   // Creating the placement group
+	StepFloatingIP   Step = "floating-ip" // Allocating or assigning the floating IP
+	StepLoadBalancer Step = "load-balancer"
+	StepNATS         Step = "nats" // Bootstrapping the NATS cluster
+	StepMesh         Step = "mesh" // Joining the nodes into the WireGuard mesh
+	StepVerify       Step = "verify"
+	StepFinalize     Step = "finalize" // Recording the forest's status
+	StepRollback     Step = "rollback"
+	StepCleanup      Step = "cleanup" // Removing what an interrupted plant left
+	StepRemove       Step = "remove"  // Removing machines from a forest
+	StepTeardown     Step = "teardown"
+	StepDelete       Step = "delete"   // Deleting one resource
+	StepSnapshot     Step = "snapshot" // Snapshotting a forest's machines, or one of them
+	StepMetadata     Step = "metadata" // Updating the nodes' metadata files
+	StepPower        Step = "power"    // Rebooting, powering off or on a node
+	StepReplace      Step = "replace"  // Replacing a node with a new server
+	StepResize       Step = "resize"   // Changing a node's server type
+)
+
+// Event is a progress event of a provisioner operation
+type Event struct {
+	Type EventType `json:"type"`
+	Step Step      `json:"step,omitempty"`
+
+	// Level is 0 for the steps of an operation, 1 for a machine or other
+	// resource within a step and 2 for the steps on one machine
+	Level int `json:"level"`
+
+	// Number and Total give the position of a step among its siblings,
+	// e.g. step 2 of 4 or machine 1 of 3 (0 if not counted)
+	Number int `json:"number,omitempty"`
+	Total  int `json:"total,omitempty"`
+
+	Node    string `json:"node,omitempty"`
+	Message string `json:"message,omitempty"`
+	Err     error  `json:"-"`
+}
+
+// Reporter receives the progress events of a provisioner, e.g. to render
+// them or pass them on to an API client
+type Reporter interface {
+	Report(Event)
+}
+
+// ReporterFunc adapts a function to a Reporter
+type ReporterFunc func(Event)
+
+// Report calls f(e)
+func (f ReporterFunc) Report(e Event) {
+	f(e)
+}
+
+// stepIcons are shown before the operation steps in text output
+var stepIcons = map[Step]string{
+	StepMachines:     "📦",
+	StepPlacement:    "🧩",
+	StepFloatingIP:   "🔀",
+	StepLoadBalancer: "⚖️ ",
+	StepNATS:         "🔗",
+	StepVerify:       "🔍",
+	StepFinalize:     "📋",
+	StepRollback:     "🔄",
+	StepCleanup:      "🧹",
+	StepRemove:       "🗑️ ",
+	StepTeardown:     "🗑️ ",
+	StepSnapshot:     "📸",
+	StepMetadata:     "🏷️ ",
+	StepPower:        "🔌",
+	StepReplace:      "🔁",
+	StepResize:       "📐",
+}
+
+// TextReporter renders events as the text output of the CLI
+type TextReporter struct {
+	w    io.Writer
+	open *Event // Resource step whose line awaits its result
+}
+
+// NewTextReporter returns a reporter writing to w
+func NewTextReporter(w io.Writer) *TextReporter {
+	return &TextReporter{w: w}
+}
+
+// Report writes an event
+func (r *TextReporter) Report(e Event) {
+	// A resource step is shown on one line: "Deleting x... ✅"
+	if open := r.open; open != nil {
+		r.open = nil
+		if e.Step == open.Step && e.Node == open.Node && e.Level == open.Level {
+			switch e.Type {
+			case StepCompleted:
+				fmt.Fprintln(r.w, " ✅")
+				return
+			case StepFailed:
+				fmt.Fprintf(r.w, " ❌%s\n", errSuffix(" ", e.Err))
+				return
+			case Warning:
+				fmt.Fprintf(r.w, " ⚠️  Warning: %s\n", e.Message)
+				return
+			}
+		}
+		fmt.Fprintln(r.w)
+	}
+
+	indent := strings.Repeat("   ", e.Level)
+	switch e.Type {
+	case StepStarted:
+		switch {
+		case e.Level == 0 && e.Number > 0:
+			fmt.Fprintf(r.w, "\n%s Step %d/%d: %s\n", stepIcons[e.Step], e.Number, e.Total, e.Message)
+		case e.Level == 0:
+			fmt.Fprintf(r.w, "\n%s %s\n", stepIcons[e.Step], e.Message)
+		case e.Step == StepMachine:
+			fmt.Fprintf(r.w, "\n%sMachine %d/%d: %s\n", indent, e.Number, e.Total, e.Node)
+		case e.Level == 1:
+			counter := ""
+			if e.Total > 0 {
+				counter = fmt.Sprintf("[%d/%d] ", e.Number, e.Total)
+			}
+			fmt.Fprintf(r.w, "%s%s%s...", indent, counter, e.Message)
+			r.open = &e
+		default:
+			fmt.Fprintf(r.w, "%s⏳ %s...\n", indent, e.Message)
+		}
+	case StepCompleted:
+		if e.Level >= 2 {
+			fmt.Fprintf(r.w, "%s✓ %s\n", indent, e.Message)
+		} else {
+			fmt.Fprintf(r.w, "   ✅ %s\n", e.Message)
+		}
+	case StepFailed:
+		if e.Level == 0 {
+			fmt.Fprintf(r.w, "\n❌ %s%s\n", e.Message, errSuffix(": ", e.Err))
+		} else {
+			fmt.Fprintf(r.w, "%s❌ %s%s\n", indent, e.Message, errSuffix(": ", e.Err))
+		}
+	case Info:
+		fmt.Fprintf(r.w, "%s%s\n", indent, e.Message)
+	case Warning:
+		fmt.Fprintf(r.w, "%s⚠️  Warning: %s\n", indent, e.Message)
+	}
+}
+
+func errSuffix(sep string, err error) string {
+	if err == nil {
+		return ""
+	}
+	return sep + err.Error()
+}
+
+// SetReporter sends the provisioner's progress to r instead of printing it
+// to stdout
+func (p *Provisioner) SetReporter(r Reporter) {
+	p.reporter = r
+}
+
+// report sends an event to the reporter, by default printing it
+func (p *Provisioner) report(e Event) {
+	if p.reporter == nil {
+		p.reporter = NewTextReporter(os.Stdout)
+	}
+	p.reporter.Report(e)
+}
+
+// info reports details of the current step
+func (p *Provisioner) info(level int, format string, args ...interface{}) {
+	p.report(Event{Type: Info, Level: level, Message: fmt.Sprintf(format, args...)})
+}
+
+// warn reports a problem that does not stop the operation
+func (p *Provisioner) warn(level int, format string, args ...interface{}) {
+	p.report(Event{Type: Warning, Level: level, Message: fmt.Sprintf(format, args...)})
+}
+
+// nodeStep reports a step on one machine
+func (p *Provisioner) nodeStep(t EventType, step Step, node, format string, args ...interface{}) {
+	p.report(Event{Type: t, Step: step, Level: 2, Node: node, Message: fmt.Sprintf(format, args...)})
+}
+
+// deleting reports that a resource is being deleted and returns the event
+// to pass to deleted
+func (p *Provisioner) deleting(node string, number, total int, format string, args ...interface{}) Event {
+	e := Event{Type: StepStarted, Step: StepDelete, Level: 1, Number: number, Total: total, Node: node, Message: fmt.Sprintf(format, args...)}
+	p.report(e)
+	return e
+}
+
+// deleted reports the outcome of a deletion; errors are warnings
+func (p *Provisioner) deleted(e Event, err error) {
+	e.Type = StepCompleted
+	if err != nil {
+		e.Type, e.Message = Warning, err.Error()
+	}
+	p.report(e)
+}
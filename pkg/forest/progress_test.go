@@ -0,0 +1,78 @@
+package forest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestProvisionReportsSteps(t *testing.T) {
+	p, _, _ := newScaleTestProvisioner(t, 0)
+	var events []Event
+	p.SetReporter(ReporterFunc(func(e Event) { events = append(events, e) }))
+
+	if err := p.Provision(context.Background(), ProvisionRequest{ForestID: "f", NodeCount: 2, Location: "fsn1"}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+
+	var steps []string
+	for _, e := range events {
+		if e.Type == StepStarted && e.Level == 0 {
+			steps = append(steps, string(e.Step))
+		}
+	}
+	if got, want := strings.Join(steps, ","), "machines,finalize"; got != want {
+		t.Errorf("operation steps = %s, want %s", got, want)
+	}
+
+	completed := map[string]int{}
+	for _, e := range events {
+		if e.Type == StepCompleted {
+			completed[string(e.Step)+" "+e.Node]++
+		}
+	}
+	for _, node := range []string{"f-node-1", "f-node-2"} {
+		for _, step := range []Step{StepMachine, StepServer, StepBoot, StepSSH} {
+			if completed[string(step)+" "+node] != 1 {
+				t.Errorf("%s of %s completed %d times, want 1", step, node, completed[string(step)+" "+node])
+			}
+		}
+	}
+}
+
+func TestTextReporter(t *testing.T) {
+	var out bytes.Buffer
+	r := NewTextReporter(&out)
+	for _, e := range []Event{
+		{Type: StepStarted, Step: StepMachines, Number: 1, Total: 3, Message: "Provisioning machines"},
+		{Type: StepStarted, Step: StepMachine, Level: 1, Number: 1, Total: 1, Node: "f-node-1"},
+		{Type: StepStarted, Step: StepServer, Level: 2, Node: "f-node-1", Message: "Creating server on cloud provider"},
+		{Type: StepCompleted, Step: StepServer, Level: 2, Node: "f-node-1", Message: "Server created (ID: 1)"},
+		{Type: Warning, Level: 1, Message: "failed to update node status: gone"},
+		{Type: StepStarted, Step: StepDelete, Level: 1, Number: 1, Total: 2, Node: "1", Message: "Deleting 1"},
+		{Type: StepCompleted, Step: StepDelete, Level: 1, Number: 1, Total: 2, Node: "1", Message: "Deleting 1"},
+		{Type: StepStarted, Step: StepDelete, Level: 1, Number: 2, Total: 2, Node: "2", Message: "Deleting 2"},
+		{Type: StepFailed, Step: StepDelete, Level: 1, Node: "2", Err: errors.New("locked")},
+		{Type: StepFailed, Step: StepMachines, Message: "Provisioning failed", Err: errors.New("no capacity")},
+	} {
+		r.Report(e)
+	}
+
+	want := `
+📦 Step 1/3: Provisioning machines
+
+   Machine 1/1: f-node-1
+      ⏳ Creating server on cloud provider...
+      ✓ Server created (ID: 1)
+   ⚠️  Warning: failed to update node status: gone
+   [1/2] Deleting 1... ✅
+   [2/2] Deleting 2... ❌ locked
+
+❌ Provisioning failed: no capacity
+`
+	if out.String() != want {
+		t.Errorf("output =\n%s\nwant\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,234 @@
+package forest
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/cloudinit"
+	"github.com/nimsforest/morpheus/pkg/provenance"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+const (
+	// ProvenanceBuildType identifies manifests of planted forests
+	ProvenanceBuildType = "https://github.com/nimsforest/morpheus/plant/v1"
+	// provenanceBuilderID identifies morpheus as the builder
+	provenanceBuilderID = "https://github.com/nimsforest/morpheus"
+)
+
+// ProvenanceInputs are the inputs of a plant that are not recorded with the
+// forest itself
+type ProvenanceInputs struct {
+	ConfigPath string    // Config file the forest was planted with
+	Files      []string  // Further input files, e.g. a blueprint's verify suite
+	Version    string    // morpheus version
+	StartedOn  time.Time // When the plant started
+}
+
+// Provenance describes how a forest was built as an in-toto statement with
+// a SLSA provenance predicate: the plant request, the config file, cloud-init
+// templates and images it was built from, and the servers (subjects) and
+// other resources (byproducts) that were created. Each server's digest
+// covers its identity and what it was created from (see NodeSubject), so
+// a replaced or rebuilt server no longer matches the manifest.
+func (p *Provisioner) Provenance(ctx context.Context, forestID string, in ProvenanceInputs) (*provenance.Statement, error) {
+	f, err := p.storage.GetForest(forestID)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := p.storage.GetNodes(forestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+
+	s := provenance.NewStatement()
+	for i, node := range nodes {
+		subject, err := NodeSubject(f, node, i)
+		if err != nil {
+			return nil, err
+		}
+		s.Subject = append(s.Subject, subject)
+	}
+
+	build := &s.Predicate.BuildDefinition
+	build.BuildType = ProvenanceBuildType
+	build.ExternalParameters["forestId"] = f.ID
+	if len(f.Request) > 0 {
+		build.ExternalParameters["request"] = f.Request
+	}
+	build.InternalParameters = map[string]any{"provider": f.Provider}
+	if f.Project != "" {
+		build.InternalParameters["project"] = f.Project
+	}
+
+	files := in.Files
+	if in.ConfigPath != "" {
+		files = append([]string{in.ConfigPath}, files...)
+	}
+	for _, path := range files {
+		dep, err := fileDescriptor(path)
+		if err != nil {
+			return nil, err
+		}
+		build.ResolvedDependencies = append(build.ResolvedDependencies, dep)
+	}
+	build.ResolvedDependencies = append(build.ResolvedDependencies, p.templateDescriptors(f, nodes)...)
+	var images []string
+	for _, node := range nodes {
+		if node.Image != "" && !slices.Contains(images, node.Image) {
+			images = append(images, node.Image)
+			build.ResolvedDependencies = append(build.ResolvedDependencies, provenance.ResourceDescriptor{
+				Name: "image", URI: resourceURI(f, "image", node.Image),
+			})
+		}
+	}
+
+	run := &s.Predicate.RunDetails
+	run.Builder = provenance.Builder{ID: provenanceBuilderID}
+	if in.Version != "" {
+		run.Builder.Version = map[string]string{"morpheus": in.Version}
+	}
+	run.Metadata = provenance.Metadata{
+		InvocationID: fmt.Sprintf("%s/%d", f.ID, in.StartedOn.Unix()),
+		StartedOn:    in.StartedOn.UTC(),
+		FinishedOn:   time.Now().UTC(),
+	}
+	run.Byproducts = p.byproducts(ctx, f, nodes)
+	return s, nil
+}
+
+// NodeSubject returns the subject describing a node's server. Its digest
+// is of the server's ID, location, addresses, pinned host key, image and
+// cloud-init user data, which do not change during the server's life.
+func NodeSubject(f *storage.Forest, node *storage.Node, index int) (provenance.ResourceDescriptor, error) {
+	digest, err := provenance.JSONDigest(map[string]string{
+		"id":        node.ID,
+		"location":  node.Location,
+		"ipv4":      node.IPv4,
+		"ipv6":      node.IPv6,
+		"hostKey":   node.HostKey,
+		"image":     node.Image,
+		"userData":  node.UserData,
+		"forestId":  f.ID,
+		"createdBy": provenanceBuilderID,
+	})
+	if err != nil {
+		return provenance.ResourceDescriptor{}, err
+	}
+	annotations := map[string]any{"role": nodeRole(node)}
+	if node.ServerType != "" {
+		annotations["serverType"] = node.ServerType
+	}
+	if node.UserData != "" {
+		annotations["cloudInit"] = map[string]string{"sha256": node.UserData}
+	}
+	return provenance.ResourceDescriptor{
+		Name:        ForestNames(f).Node(index),
+		URI:         resourceURI(f, "server", node.ID),
+		Digest:      digest,
+		Annotations: annotations,
+	}, nil
+}
+
+// templateDescriptors describes the user-supplied cloud-init templates the
+// forest's roles are rendered from
+func (p *Provisioner) templateDescriptors(f *storage.Forest, nodes []*storage.Node) []provenance.ResourceDescriptor {
+	var roles []string
+	for _, node := range nodes {
+		if role := nodeRole(node); !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	var deps []provenance.ResourceDescriptor
+	for _, role := range roles {
+		text, path, err := cloudinit.LoadTemplate(p.config.Provisioning.GetCloudInitDir(), f.ID, role)
+		if err != nil || path == "" {
+			continue
+		}
+		deps = append(deps, provenance.ResourceDescriptor{
+			Name:   "cloud-init/" + role,
+			URI:    fileURI(path),
+			Digest: provenance.Digest([]byte(text)),
+		})
+	}
+	return deps
+}
+
+// byproducts describes the resources created for a forest besides its
+// servers
+func (p *Provisioner) byproducts(ctx context.Context, f *storage.Forest, nodes []*storage.Node) []provenance.ResourceDescriptor {
+	var out []provenance.ResourceDescriptor
+	if f.LoadBalancerID != "" {
+		out = append(out, provenance.ResourceDescriptor{
+			Name: "load-balancer", URI: resourceURI(f, "load-balancer", f.LoadBalancerID),
+			Annotations: map[string]any{"ipv4": f.LoadBalancerIPv4, "ipv6": f.LoadBalancerIPv6},
+		})
+	}
+	if f.FloatingIPID != "" {
+		out = append(out, provenance.ResourceDescriptor{
+			Name: "floating-ip", URI: resourceURI(f, "floating-ip", f.FloatingIPID),
+			Annotations: map[string]any{"ip": f.FloatingIP},
+		})
+	}
+	if f.PlacementGroupID != "" {
+		out = append(out, provenance.ResourceDescriptor{
+			Name: "placement-group", URI: resourceURI(f, "placement-group", f.PlacementGroupID),
+		})
+	}
+	volumes, err := p.forestVolumes(ctx, f.ID)
+	if err != nil {
+		p.warn(1, "failed to list volumes: %s", err)
+	}
+	for _, v := range volumes {
+		out = append(out, provenance.ResourceDescriptor{
+			Name: v.Name, URI: resourceURI(f, "volume", v.ID),
+			Annotations: map[string]any{"sizeGb": v.SizeGB, "server": v.ServerID},
+		})
+	}
+	if p.dns != nil && p.config.DNS.Domain != "" {
+		names := ForestNames(f)
+		for i := range nodes {
+			out = append(out, provenance.ResourceDescriptor{
+				Name: "dns", URI: fmt.Sprintf("dns://%s.%s", names.Record(i), p.config.DNS.Domain),
+			})
+		}
+	}
+	return out
+}
+
+// fileDescriptor describes an input file by its absolute path and digest
+func fileDescriptor(path string) (provenance.ResourceDescriptor, error) {
+	digest, err := provenance.FileDigest(path)
+	if err != nil {
+		return provenance.ResourceDescriptor{}, fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return provenance.ResourceDescriptor{Name: filepath.Base(path), URI: fileURI(path), Digest: digest}, nil
+}
+
+func fileURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return "file://" + filepath.ToSlash(path)
+}
+
+// resourceURI names a provider resource, e.g. hetzner://server/12345
+func resourceURI(f *storage.Forest, kind, id string) string {
+	return fmt.Sprintf("%s://%s/%s", f.Provider, kind, id)
+}
+
+// nodeRole returns a node's role, the default one if it has none
+func nodeRole(node *storage.Node) string {
+	if node.Role == "" {
+		return cloudinit.DefaultRole
+	}
+	return node.Role
+}
+
+// userDataDigest returns the hex SHA-256 of cloud-init user data
+func userDataDigest(userData string) string {
+	return provenance.Digest([]byte(userData))["sha256"]
+}
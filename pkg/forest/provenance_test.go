@@ -0,0 +1,68 @@
+package forest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/provenance"
+)
+
+func TestProvenance(t *testing.T) {
+	p, _, reg := newScaleTestProvisioner(t, 1)
+	ctx := context.Background()
+
+	f, _ := reg.GetForest("forest-1")
+	f.Provider = "hetzner"
+	if err := reg.UpdateForest(f); err != nil {
+		t.Fatal(err)
+	}
+	// A node provisioned by morpheus records its image and cloud-init hash
+	if _, err := p.Scale(ctx, ScaleRequest{ForestID: "forest-1", TargetCount: 2}); err != nil {
+		t.Fatalf("Scale() error = %v", err)
+	}
+	nodes, _ := reg.GetNodes("forest-1")
+	if nodes[1].Image == "" || len(nodes[1].UserData) != 64 {
+		t.Fatalf("new node image = %q, user data = %q; want both recorded", nodes[1].Image, nodes[1].UserData)
+	}
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("machine:\n  provider: hetzner\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now().Add(-time.Minute)
+	s, err := p.Provenance(ctx, "forest-1", ProvenanceInputs{ConfigPath: configPath, Version: "v1.2.3", StartedOn: started})
+	if err != nil {
+		t.Fatalf("Provenance() error = %v", err)
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if len(s.Subject) != 2 || s.Subject[1].URI != "hetzner://server/"+nodes[1].ID || s.Subject[1].Name != "forest-1-node-2" {
+		t.Errorf("subjects = %+v, want both servers", s.Subject)
+	}
+
+	deps := s.Predicate.BuildDefinition.ResolvedDependencies
+	want, _ := provenance.FileDigest(configPath)
+	if len(deps) != 2 || deps[0].Digest["sha256"] != want["sha256"] || deps[1].URI != "hetzner://image/"+nodes[1].Image {
+		t.Errorf("dependencies = %+v, want the config file and the image", deps)
+	}
+	if v := s.Predicate.RunDetails.Builder.Version["morpheus"]; v != "v1.2.3" {
+		t.Errorf("builder version = %q, want v1.2.3", v)
+	}
+
+	// A rebuilt server no longer matches its subject
+	nodes[1].HostKey = "ssh-ed25519 AAAAother"
+	if err := reg.UpdateNode(nodes[1]); err != nil {
+		t.Fatal(err)
+	}
+	current, err := NodeSubject(f, nodes[1], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Digest["sha256"] == s.Subject[1].Digest["sha256"] {
+		t.Error("subject digest did not change with the host key")
+	}
+}
@@ -2,8 +2,10 @@ package forest
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
+	"slices"
 	"strings"
 	"time"
 
@@ -11,16 +13,34 @@ import (
 	"github.com/nimsforest/morpheus/pkg/config"
 	"github.com/nimsforest/morpheus/pkg/dns"
 	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/secretstore"
 	"github.com/nimsforest/morpheus/pkg/sshutil"
 	"github.com/nimsforest/morpheus/pkg/storage"
 )
 
 // Provisioner handles forest provisioning
 type Provisioner struct {
-	machine machine.Provider
-	storage storage.Registry
-	dns     dns.Provider
-	config  *config.Config
+	machine   machine.Provider
+	storage   storage.Registry
+	dns       dns.Provider
+	config    *config.Config
+	inventory Inventory
+	reporter  Reporter          // Receives progress events (default: text on stdout)
+	sshBinary string            // ssh client for post-provision steps (default "ssh")
+	secrets   secretstore.Store // Keeps NATS credentials and CAs (default: plain files in the NATS state dir)
+
+	verifyInterval time.Duration // Time between attempts of failing verify checks (default 5s)
+}
+
+// Inventory is an external inventory (e.g., NetBox) that is kept in sync
+// with the servers a provisioner creates and deletes
+type Inventory interface {
+	// RegisterNode records a server and its mesh IP ("" before it joined
+	// the mesh); it is called again for known servers
+	RegisterNode(ctx context.Context, forestID string, server *machine.Server, meshIP string) error
+
+	// RemoveNode forgets a server; unknown servers are not an error
+	RemoveNode(ctx context.Context, serverID string) error
 }
 
 // NewProvisioner creates a new forest provisioner
@@ -44,238 +64,692 @@ func NewProvisionerWithDNS(m machine.Provider, s storage.Registry, d dns.Provide
 	}
 }
 
-// ProvisionRequest contains parameters for provisioning a forest
+// SetInventory registers servers in inv as they are created and removes
+// them again when they are deleted
+func (p *Provisioner) SetInventory(inv Inventory) {
+	p.inventory = inv
+}
+
+// SetSecrets keeps the secrets the provisioner generates in s
+func (p *Provisioner) SetSecrets(s secretstore.Store) {
+	p.secrets = s
+}
+
+// ProvisionRequest contains parameters for provisioning a forest.
+// It is recorded with the forest so an incomplete plant can be resumed.
 type ProvisionRequest struct {
-	ForestID   string
-	NodeCount  int // Number of nodes to provision
-	Location   string
-	ServerType string // Provider-specific server type
-	Image      string // OS image to use
+	ForestID   string      `json:"forest_id"`
+	NodeCount  int         `json:"node_count"` // Number of nodes to provision
+	Location   string      `json:"location"`
+	ServerType string      `json:"server_type,omitempty"` // Provider-specific server type
+	Image      string      `json:"image,omitempty"`       // OS image to use
+	Volume     *VolumeSpec `json:"volume,omitempty"`      // Optional persistent volume for each node
+
+	// NodeImages overrides Image by node index, e.g. with the snapshots a
+	// forest is restored from. Nodes beyond it use Image.
+	NodeImages []string `json:"node_images,omitempty"`
+
+	// Locations spreads the nodes across locations, round-robin by node
+	// index; listing one location per node maps them explicitly. Location
+	// is then the first of them.
+	Locations []string `json:"locations,omitempty"`
+
+	// ExpectedNodeCost is the expected monthly spend per node, recorded
+	// for billing checks (0 = unknown)
+	ExpectedNodeCost float64 `json:"expected_node_cost,omitempty"`
+
+	// LoadBalancer, if set, puts a load balancer in front of the nodes
+	LoadBalancer *LoadBalancerSpec `json:"load_balancer,omitempty"`
+
+	// FloatingIP allocates a floating IP ("ipv4" or "ipv6") for the forest,
+	// assigned to the first node and movable with Failover
+	FloatingIP string `json:"floating_ip,omitempty"`
+
+	// FloatingIPCost is the expected monthly spend of the floating IP,
+	// recorded for billing checks
+	FloatingIPCost float64 `json:"floating_ip_cost,omitempty"`
+
+	// Project is the named Hetzner project the forest is created in,
+	// recorded so later commands use the same credentials
+	Project string `json:"project,omitempty"`
+
+	// Customer is the tenant the forest is provisioned for, recorded so a
+	// shared worker can enforce per-customer quotas
+	Customer string `json:"customer,omitempty"`
+
+	// Role selects the user-supplied cloud-init template (default "node")
+	Role string `json:"role,omitempty"`
+
+	// Roles assigns the nodes roles in order, e.g. edge=1,core=2; NodeCount
+	// is then their total. A role selects the node's cloud-init template
+	// and, if configured in provisioning.roles, its server type and labels.
+	Roles []NodeRole `json:"roles,omitempty"`
+
+	// Verify are checks run in addition to the configured ones, e.g. from
+	// a blueprint's verify suite
+	Verify []config.VerifyCheck `json:"verify,omitempty"`
+
+	// Metadata are user-defined key/values for the nodes' metadata file
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Naming are the templates the forest's resources are named with
+	// (default: the configured ones), recorded so the names stay stable
+	Naming *config.NamingConfig `json:"naming,omitempty"`
+
+	// OverrideBudget provisions even if the expected spend exceeds
+	// limits.max_monthly_cost; it is not recorded
+	OverrideBudget bool `json:"-"`
+
+	// budgetChecked skips the budget check the caller did already
+	budgetChecked bool
+}
+
+// NodeLocation returns the location of the node with the given (0-based)
+// index
+func (r ProvisionRequest) NodeLocation(index int) string {
+	return spreadLocation(r.Locations, r.Location, index)
+}
+
+// spreadLocation returns the location of a node with the given index in a
+// forest spread across locations, or location if it is not spread
+func spreadLocation(locations []string, location string, index int) string {
+	if len(locations) == 0 {
+		return location
+	}
+	return locations[index%len(locations)]
+}
+
+// UniqueLocations returns locations without repetitions, in order
+func UniqueLocations(locations []string) []string {
+	var unique []string
+	for _, location := range locations {
+		if !slices.Contains(unique, location) {
+			unique = append(unique, location)
+		}
+	}
+	return unique
+}
+
+// LoadBalancerSpec describes a load balancer created in front of a forest.
+// All nodes of the forest are targets; nodes added or removed later are
+// registered and deregistered automatically by their labels.
+type LoadBalancerSpec struct {
+	Type         string                        `json:"type,omitempty"` // Provider-specific type (default lb11)
+	Services     []machine.LoadBalancerService `json:"services"`
+	ExpectedCost float64                       `json:"expected_cost,omitempty"` // Expected monthly spend, recorded for billing checks
+}
+
+// VolumeSpec describes a persistent volume created and mounted on each node
+type VolumeSpec struct {
+	SizeGB     int    `json:"size_gb"`
+	Filesystem string `json:"filesystem,omitempty"`  // ext4 (default) or xfs
+	MountPoint string `json:"mount_point,omitempty"` // Default /mnt/data
 }
 
 // Provision creates a new forest with the specified configuration
 func (p *Provisioner) Provision(ctx context.Context, req ProvisionRequest) error {
 	// Validate node count
 	nodeCount := req.NodeCount
+	if len(req.Roles) > 0 {
+		if err := validateRoles(req.Roles); err != nil {
+			return err
+		}
+		if nodeCount > 0 && nodeCount != RoleNodeCount(req.Roles) {
+			return fmt.Errorf("node count %d does not match roles %s", nodeCount, FormatRoles(req.Roles))
+		}
+		nodeCount = RoleNodeCount(req.Roles)
+	}
 	if nodeCount <= 0 {
 		nodeCount = 1 // Default to single node
 	}
 
+	if req.LoadBalancer != nil {
+		if _, ok := p.machine.(machine.LoadBalancerManager); !ok {
+			return fmt.Errorf("machine provider %s does not support load balancers", p.config.GetMachineProvider())
+		}
+		if len(req.LoadBalancer.Services) == 0 {
+			return fmt.Errorf("load balancer needs at least one service")
+		}
+	}
+
+	if req.FloatingIP != "" {
+		if _, ok := p.machine.(machine.FloatingIPManager); !ok {
+			return fmt.Errorf("machine provider %s does not support floating IPs", p.config.GetMachineProvider())
+		}
+		if req.FloatingIP != "ipv4" && req.FloatingIP != "ipv6" {
+			return fmt.Errorf("invalid floating IP type %q (use ipv4 or ipv6)", req.FloatingIP)
+		}
+	}
+
+	if req.Volume != nil {
+		if _, ok := p.machine.(machine.VolumeManager); !ok {
+			return fmt.Errorf("machine provider %s does not support volumes", p.config.GetMachineProvider())
+		}
+		if req.Volume.SizeGB <= 0 {
+			return fmt.Errorf("volume size must be positive, got %d GB", req.Volume.SizeGB)
+		}
+	}
+
+	for _, location := range req.Locations {
+		if location == "" {
+			return fmt.Errorf("invalid locations %v: locations must not be empty", req.Locations)
+		}
+	}
+	if len(req.Locations) > 0 {
+		req.Location = req.Locations[0]
+	}
+
+	if !req.budgetChecked {
+		if err := p.checkBudget(provisionCost(req, nodeCount), "", req.OverrideBudget); err != nil {
+			return err
+		}
+	}
+
+	ph, err := p.startPhoneHome(req.ForestID)
+	if err != nil {
+		return err
+	}
+	defer ph.close()
+
 	// Register forest
 	forest := &storage.Forest{
 		ID:        req.ForestID,
 		NodeCount: nodeCount,
 		Location:  req.Location,
+		Locations: req.Locations,
+		Metadata:  req.Metadata,
 		Provider:  p.config.GetMachineProvider(),
+		Project:   req.Project,
+		Customer:  req.Customer,
 		Status:    "provisioning",
+
+		ExpectedNodeCost: req.ExpectedNodeCost,
+	}
+	if req.LoadBalancer != nil {
+		forest.ExpectedExtraCost = req.LoadBalancer.ExpectedCost
+	}
+	if req.FloatingIP != "" {
+		forest.ExpectedExtraCost += req.FloatingIPCost
+	}
+	req.NodeCount = nodeCount
+	if req.Naming == nil {
+		naming := p.config.Naming
+		req.Naming = &naming
+	}
+	if forest.Request, err = json.Marshal(req); err != nil {
+		return fmt.Errorf("failed to record request: %w", err)
 	}
 
 	if err := p.storage.RegisterForest(forest); err != nil {
 		return fmt.Errorf("failed to register forest: %w", err)
 	}
 
-	fmt.Printf("\n📦 Step 1/%d: Provisioning machines\n", 2+nodeCount)
-	fmt.Printf("    Creating %d machine%s...\n", nodeCount, plural(nodeCount))
+	// Spread larger forests across physical hosts, unless they already
+	// span several locations
+	if _, ok := p.machine.(machine.PlacementGroupManager); ok && p.config.UsePlacementGroup(nodeCount) && len(UniqueLocations(req.Locations)) < 2 {
+		group, err := p.createPlacementGroup(ctx, req.ForestID)
+		if err != nil {
+			p.storage.DeleteForest(req.ForestID)
+			return fmt.Errorf("failed to create placement group: %w", err)
+		}
+		forest.PlacementGroupID = group.ID
+		if err := p.storage.UpdateForest(forest); err != nil {
+			p.warn(1, "failed to update forest: %s", err)
+		}
+	}
+
+	// Allocate the floating IP first so every node is configured with it
+	if req.FloatingIP != "" {
+		fip, err := p.createFloatingIP(ctx, req)
+		if err != nil {
+			p.deletePlacementGroups(ctx, req.ForestID)
+			p.storage.DeleteForest(req.ForestID)
+			return fmt.Errorf("failed to create floating IP: %w", err)
+		}
+		forest.FloatingIPID = fip.ID
+		forest.FloatingIP = fip.IP
+		if err := p.storage.UpdateForest(forest); err != nil {
+			p.warn(1, "failed to update forest: %s", err)
+		}
+	}
+
+	return p.provisionNodes(ctx, req, forest, ph, 0)
+}
+
+// provisionNodes creates the nodes of a forest from index first on, then
+// the resources in front of them, and finalizes the forest.
+//
+// If a node fails after others are ready, the ready nodes are kept and the
+// forest is marked incomplete, to be finished by Resume. If none are ready,
+// everything is rolled back.
+func (p *Provisioner) provisionNodes(ctx context.Context, req ProvisionRequest, forest *storage.Forest, ph *phoneHome, first int) error {
+	nodeCount := req.NodeCount
+	steps := 2 + nodeCount - first
+	if p.config.Provisioning.NATS.Enabled {
+		steps++
+	}
+	checks := p.verifyChecks(req.Verify)
+	if len(checks) > 0 {
+		steps++
+	}
+	step := 2
+
+	p.report(Event{Type: StepStarted, Step: StepMachines, Number: 1, Total: steps, Message: "Provisioning machines"})
+	if first < nodeCount {
+		p.info(1, "Creating %d machine%s...", nodeCount-first, plural(nodeCount-first))
+	}
 
 	// Provision nodes
-	var provisionedServers []*machine.Server
-	for i := 0; i < nodeCount; i++ {
-		nodeName := fmt.Sprintf("%s-node-%d", req.ForestID, i+1)
-
-		fmt.Printf("\n   Machine %d/%d: %s\n", i+1, nodeCount, nodeName)
-
-		server, err := p.provisionNode(ctx, req, nodeName, i, nodeCount, func(s *machine.Server) {
-			// Register node immediately after server creation (before SSH verification)
-			// This ensures teardown can find and delete it even if interrupted
-			// Store both IPv4 and IPv6 addresses for flexible connectivity
-			node := &storage.Node{
-				ID:       s.ID,
-				ForestID: req.ForestID,
-				IP:       s.GetPreferredIP(), // Primary IP (IPv6 preferred)
-				IPv6:     s.PublicIPv6,
-				IPv4:     s.PublicIPv4,
-				Location: s.Location,
-				Status:   "provisioning", // Will be updated to "active" after SSH verification
-				Metadata: s.Labels,
-			}
-			if err := p.storage.RegisterNode(node); err != nil {
-				fmt.Printf("   ⚠️  Warning: failed to register node in storage: %s\n", err)
-			}
+	names := requestNames(req)
+	for i := first; i < nodeCount; i++ {
+		nodeName := names.Node(i)
+
+		p.report(Event{Type: StepStarted, Step: StepMachine, Level: 1, Number: i + 1, Total: nodeCount, Node: nodeName})
+
+		var created *machine.Server
+		server, err := p.provisionNode(ctx, req, nodeName, i, nodeCount, ph, func(s *machine.Server) {
+			created = s
+			p.registerNode(req.ForestID, s)
 		})
 		if err != nil {
-			// Rollback on failure - nodes are already registered, so teardown will find them
-			fmt.Printf("\n❌ Provisioning failed: %s\n", err)
-			fmt.Printf("🔄 Rolling back %d machine%s...\n", len(provisionedServers)+1, plural(len(provisionedServers)+1))
-			p.rollback(ctx, req.ForestID, provisionedServers)
-			return fmt.Errorf("failed to provision node %s: %w", nodeName, err)
+			p.report(Event{Type: StepFailed, Step: StepMachines, Node: nodeName, Message: "Provisioning failed", Err: err})
+			if created != nil {
+				if err := p.storage.UpdateNodeStatus(req.ForestID, created.ID, NodeStatusFailed); err != nil {
+					p.warn(1, "failed to update node status: %s", err)
+				}
+			}
+			return p.abort(ctx, forest, i, fmt.Errorf("failed to provision node %s: %w", nodeName, err))
 		}
 
-		provisionedServers = append(provisionedServers, server)
-
 		// Update the actual location used (may differ from requested if fallback occurred)
-		forest.Location = server.Location
+		if i == 0 {
+			forest.Location = server.Location
+		}
 
 		// Update node status to active now that SSH verification passed
 		if err := p.storage.UpdateNodeStatus(req.ForestID, server.ID, "active"); err != nil {
-			fmt.Printf("   ⚠️  Warning: failed to update node status: %s\n", err)
+			p.warn(1, "failed to update node status: %s", err)
 		}
 
 		// Display IP address info
+		addrs := fmt.Sprintf("IPv4: %s", server.PublicIPv4)
 		if server.PublicIPv6 != "" && server.PublicIPv4 != "" {
-			fmt.Printf("   ✅ Machine %d ready (IPv6: %s, IPv4: %s)\n", i+1, server.PublicIPv6, server.PublicIPv4)
+			addrs = fmt.Sprintf("IPv6: %s, IPv4: %s", server.PublicIPv6, server.PublicIPv4)
 		} else if server.PublicIPv6 != "" {
-			fmt.Printf("   ✅ Machine %d ready (IPv6: %s)\n", i+1, server.PublicIPv6)
-		} else {
-			fmt.Printf("   ✅ Machine %d ready (IPv4: %s)\n", i+1, server.PublicIPv4)
+			addrs = fmt.Sprintf("IPv6: %s", server.PublicIPv6)
 		}
+		p.report(Event{Type: StepCompleted, Step: StepMachine, Level: 1, Number: i + 1, Total: nodeCount, Node: nodeName,
+			Message: fmt.Sprintf("Machine %d ready (%s)", i+1, addrs)})
 
 		// Create DNS records if DNS provider is configured
 		if p.dns != nil && p.config.DNS.Domain != "" {
 			p.createDNSRecords(ctx, req.ForestID, server, i)
 		}
+		p.registerInventory(ctx, req.ForestID, server)
+	}
+
+	// The first node starts out as primary
+	if forest.FloatingIPID != "" {
+		nodes, err := p.storage.GetNodes(req.ForestID)
+		if err == nil && len(nodes) == 0 {
+			err = fmt.Errorf("forest has no nodes")
+		}
+		if err == nil {
+			err = p.assignFloatingIP(ctx, forest, nodes[0].ID, 0)
+		}
+		if err != nil {
+			p.report(Event{Type: StepFailed, Step: StepFloatingIP, Message: "Floating IP assignment failed", Err: err})
+			return p.abort(ctx, forest, nodeCount, fmt.Errorf("failed to assign floating IP: %w", err))
+		}
+	}
+
+	// Put a load balancer in front of the nodes
+	if req.LoadBalancer != nil && forest.LoadBalancerID == "" {
+		lb, err := p.createLoadBalancer(ctx, req, forest.Location)
+		if err != nil {
+			p.report(Event{Type: StepFailed, Step: StepLoadBalancer, Message: "Load balancer creation failed", Err: err})
+			return p.abort(ctx, forest, nodeCount, fmt.Errorf("failed to create load balancer: %w", err))
+		}
+		forest.LoadBalancerID = lb.ID
+		forest.LoadBalancerIPv4 = lb.PublicIPv4
+		forest.LoadBalancerIPv6 = lb.PublicIPv6
+	}
+
+	// A failed bootstrap leaves the machines in place, to be retried
+	if p.config.Provisioning.NATS.Enabled {
+		p.report(Event{Type: StepStarted, Step: StepNATS, Number: step, Total: steps, Message: "Bootstrapping NATS cluster"})
+		step++
+		if err := p.BootstrapNATS(ctx, req.ForestID); err != nil {
+			p.warn(1, "%s", err)
+			p.info(1, "💡 Retry with: morpheus nats bootstrap %s", req.ForestID)
+		}
+	}
+
+	// A failed check leaves the forest in place, marked degraded
+	status := StatusActive
+	if len(checks) > 0 {
+		p.report(Event{Type: StepStarted, Step: StepVerify, Number: step, Total: steps, Message: "Verifying forest"})
+		step++
+		status = p.verifyForest(ctx, req.ForestID, checks)
 	}
 
 	// Update forest status and location
-	fmt.Printf("\n📋 Step %d/%d: Finalizing registration\n", 2+nodeCount, 2+nodeCount)
+	p.report(Event{Type: StepStarted, Step: StepFinalize, Number: step, Total: steps, Message: "Finalizing registration"})
 	if err := p.storage.UpdateForest(forest); err != nil {
-		fmt.Printf("   ⚠️  Warning: failed to update forest: %s\n", err)
+		p.warn(1, "failed to update forest: %s", err)
 	}
-	if err := p.storage.UpdateForestStatus(req.ForestID, "active"); err != nil {
-		fmt.Printf("   ⚠️  Warning: failed to update forest status: %s\n", err)
+	if err := p.storage.UpdateForestStatus(req.ForestID, status); err != nil {
+		p.warn(1, "failed to update forest status: %s", err)
+	}
+	if status == StatusDegraded {
+		p.warn(1, "forest registered as degraded: verification failed")
+	} else {
+		p.report(Event{Type: StepCompleted, Step: StepFinalize, Message: "Forest registered and ready"})
 	}
-	fmt.Printf("   ✅ Forest registered and ready\n")
 
 	return nil
 }
 
-// createDNSRecords creates DNS records for a provisioned server
+// registerNode records a freshly created server in storage.
+// It is called immediately after server creation (before SSH verification)
+// so teardown can find and delete the server even if provisioning is interrupted.
+// Both IPv4 and IPv6 addresses are stored for flexible connectivity.
+func (p *Provisioner) registerNode(forestID string, s *machine.Server) {
+	node := &storage.Node{
+		ID:         s.ID,
+		ForestID:   forestID,
+		IP:         s.GetPreferredIP(), // Primary IP (IPv6 preferred)
+		IPv6:       s.PublicIPv6,
+		IPv4:       s.PublicIPv4,
+		Location:   s.Location,
+		Role:       s.Labels["role"],
+		ServerType: s.ServerType,
+		Status:     "provisioning", // Will be updated to "active" after SSH verification
+		Metadata:   s.Labels,
+		HostKey:    s.HostKey,
+		Image:      s.Image,
+		UserData:   s.UserData,
+	}
+	if p.config.Provisioning.PhoneHome != "" {
+		node.Readiness = "waiting"
+	}
+	if err := p.storage.RegisterNode(node); err != nil {
+		p.warn(1, "failed to register node in storage: %s", err)
+	}
+}
+
+// createDNSRecords creates DNS records for a provisioned server. Its A and
+// AAAA records are set together, replacing those of an earlier node of the
+// same name.
 func (p *Provisioner) createDNSRecords(ctx context.Context, forestID string, server *machine.Server, nodeIndex int) {
 	domain := p.config.DNS.Domain
 	ttl := p.config.DNS.TTL
 
-	// Create A record if IPv4 is available
+	recordName := p.names(forestID).Record(nodeIndex)
+
+	var sets []dns.RecordSet
 	if server.PublicIPv4 != "" {
-		recordName := fmt.Sprintf("%s-node-%d", forestID, nodeIndex+1)
-		_, err := p.dns.CreateRecord(ctx, dns.CreateRecordRequest{
-			Domain: domain,
-			Name:   recordName,
-			Type:   dns.RecordTypeA,
-			Value:  server.PublicIPv4,
-			TTL:    ttl,
-		})
-		if err != nil {
-			fmt.Printf("   ⚠️  Warning: failed to create A record: %s\n", err)
-		} else {
-			fmt.Printf("   🌐 DNS: %s.%s -> %s\n", recordName, domain, server.PublicIPv4)
+		sets = append(sets, dns.RecordSet{Name: recordName, Type: dns.RecordTypeA, TTL: ttl, Values: []string{server.PublicIPv4}})
+	}
+	if server.PublicIPv6 != "" {
+		sets = append(sets, dns.RecordSet{Name: recordName, Type: dns.RecordTypeAAAA, TTL: ttl, Values: []string{machine.HostIPv6(server.PublicIPv6)}})
+	}
+	if len(sets) == 0 {
+		return
+	}
+
+	_, err := dns.ApplyRecordSets(ctx, p.dns, domain, sets)
+	failed := make(map[dns.RecordType]bool)
+	for _, e := range dns.RecordSetErrors(err) {
+		failed[e.Change.Type] = true
+		p.warn(1, "failed to create %s record: %s", e.Change.Type, e.Err)
+	}
+	if err != nil && len(failed) == 0 {
+		p.warn(1, "failed to create DNS records: %s", err)
+		return
+	}
+	for _, set := range sets {
+		if !failed[set.Type] {
+			p.info(1, "🌐 DNS: %s.%s -> %s", recordName, domain, set.Values[0])
 		}
 	}
+}
 
-	// Create AAAA record if IPv6 is available
-	if server.PublicIPv6 != "" {
-		recordName := fmt.Sprintf("%s-node-%d", forestID, nodeIndex+1)
-		_, err := p.dns.CreateRecord(ctx, dns.CreateRecordRequest{
-			Domain: domain,
-			Name:   recordName,
-			Type:   dns.RecordTypeAAAA,
-			Value:  server.PublicIPv6,
-			TTL:    ttl,
-		})
-		if err != nil {
-			fmt.Printf("   ⚠️  Warning: failed to create AAAA record: %s\n", err)
-		} else {
-			fmt.Printf("   🌐 DNS: %s.%s -> %s\n", recordName, domain, server.PublicIPv6)
+// registerInventory records a server in the external inventory, if any,
+// with the mesh IP recorded for its node
+func (p *Provisioner) registerInventory(ctx context.Context, forestID string, server *machine.Server) {
+	if p.inventory == nil {
+		return
+	}
+	meshIP := ""
+	if nodes, err := p.storage.GetNodes(forestID); err == nil {
+		for _, node := range nodes {
+			if node.ID == server.ID {
+				meshIP = node.MeshIP
+			}
 		}
 	}
+	if err := p.inventory.RegisterNode(ctx, forestID, server, meshIP); err != nil {
+		p.warn(1, "failed to register %s in inventory: %s", server.Name, err)
+	}
 }
 
-// provisionNode provisions a single node
-// The onCreated callback is called immediately after the server is created (before SSH verification)
-// to allow early registration for cleanup purposes
-func (p *Provisioner) provisionNode(ctx context.Context, req ProvisionRequest, nodeName string, index int, nodeCount int, onCreated func(*machine.Server)) (*machine.Server, error) {
-	// Generate unique node ID for this node
-	nodeID := nodeName // e.g., "myforest-node-1"
+// removeInventory removes a server from the external inventory, if any
+func (p *Provisioner) removeInventory(ctx context.Context, serverID string) {
+	if p.inventory == nil {
+		return
+	}
+	if err := p.inventory.RemoveNode(ctx, serverID); err != nil {
+		p.warn(1, "failed to remove %s from inventory: %s", serverID, err)
+	}
+}
 
-	// Generate cloud-init script
-	fmt.Printf("      ⏳ Configuring cloud-init...\n")
-	cloudInitData := cloudinit.TemplateData{
+// deleteDNSRecords removes the A/AAAA records created for a node
+func (p *Provisioner) deleteDNSRecords(ctx context.Context, forestID string, node *storage.Node, nodeIndex int) {
+	recordName := p.names(forestID).Record(nodeIndex)
+
+	// Delete A record
+	if node.IPv4 != "" {
+		if err := p.dns.DeleteRecord(ctx, p.config.DNS.Domain, recordName, string(dns.RecordTypeA)); err != nil {
+			p.warn(1, "failed to delete A record: %s", err)
+		}
+	}
+
+	// Delete AAAA record
+	if node.IPv6 != "" {
+		if err := p.dns.DeleteRecord(ctx, p.config.DNS.Domain, recordName, string(dns.RecordTypeAAAA)); err != nil {
+			p.warn(1, "failed to delete AAAA record: %s", err)
+		}
+	}
+}
+
+// NodeCloudInitData returns the cloud-init template data of a node that
+// comes from the configuration; the provisioner adds the forest's state
+func NodeCloudInitData(cfg *config.Config, req ProvisionRequest, nodeName string, index, nodeCount int) cloudinit.TemplateData {
+	data := cloudinit.TemplateData{
 		ForestID:              req.ForestID,
-		RegistryURL:           p.config.Integration.RegistryURL,
-		CallbackURL:           p.config.Integration.NimsForestURL,
-		NimsForestInstall:     p.config.Integration.NimsForestInstall,
-		NimsForestDownloadURL: p.config.Integration.NimsForestDownloadURL,
+		RegistryURL:           cfg.Integration.RegistryURL,
+		CallbackURL:           cfg.Integration.NimsForestURL,
+		NimsForestInstall:     cfg.IsNimsForestInstallEnabled(),
+		NimsForestDownloadURL: cfg.Integration.NimsForestDownloadURL,
 
 		// Node identification (for embedded NATS peer discovery)
-		NodeID:    nodeID,
+		NodeID:    nodeName, // e.g., "myforest-node-1"
 		NodeIndex: index,
 		NodeCount: nodeCount,
+		Role:      req.NodeRole(index),
 
 		// StorageBox mount for shared registry (enables NATS peer discovery)
-		StorageBoxHost:     p.config.Storage.StorageBox.Host,
-		StorageBoxUser:     p.config.Storage.StorageBox.Username,
-		StorageBoxPassword: p.config.Storage.StorageBox.Password,
+		StorageBoxHost:     cfg.Storage.StorageBox.Host,
+		StorageBoxUser:     cfg.Storage.StorageBox.Username,
+		StorageBoxPassword: cfg.Storage.StorageBox.Password,
 	}
 
 	// Fall back to legacy config if new config is empty
-	if cloudInitData.StorageBoxHost == "" {
-		cloudInitData.StorageBoxHost = p.config.Registry.StorageBoxHost
+	if data.StorageBoxHost == "" {
+		data.StorageBoxHost = cfg.Registry.StorageBoxHost
 	}
-	if cloudInitData.StorageBoxUser == "" {
-		cloudInitData.StorageBoxUser = p.config.Registry.Username
+	if data.StorageBoxUser == "" {
+		data.StorageBoxUser = cfg.Registry.Username
 	}
-	if cloudInitData.StorageBoxPassword == "" {
-		cloudInitData.StorageBoxPassword = p.config.Registry.Password
+	if data.StorageBoxPassword == "" {
+		data.StorageBoxPassword = cfg.Registry.Password
+	}
+	return data
+}
+
+// RenderCloudInit renders a node's cloud-init from the user-supplied
+// template for its forest and role (data.Role), or else the built-in one.
+// It returns the template's path, "" for the built-in one.
+func RenderCloudInit(cfg *config.Config, req ProvisionRequest, data cloudinit.TemplateData) (userData, path string, err error) {
+	text, path, err := cloudinit.LoadTemplate(cfg.Provisioning.GetCloudInitDir(), req.ForestID, data.Role)
+	if err != nil {
+		return "", "", err
+	}
+	if path == "" {
+		userData, err = cloudinit.Generate(data)
+		return userData, "", err
 	}
 
-	userData, err := cloudinit.Generate(cloudInitData)
+	userData, err = cloudinit.Render(text, data)
 	if err != nil {
+		return "", path, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cloudinit.ValidateUserData(userData); err != nil {
+		return "", path, fmt.Errorf("%s: %w", path, err)
+	}
+	return userData, path, nil
+}
+
+// provisionNode provisions a single node
+// The onCreated callback is called immediately after the server is created (before SSH verification)
+// to allow early registration for cleanup purposes
+func (p *Provisioner) provisionNode(ctx context.Context, req ProvisionRequest, nodeName string, index int, nodeCount int, ph *phoneHome, onCreated func(*machine.Server)) (*machine.Server, error) {
+	// Generate cloud-init script
+	p.nodeStep(StepStarted, StepCloudInit, nodeName, "Configuring cloud-init")
+	cloudInitData := NodeCloudInitData(p.config, req, nodeName, index, nodeCount)
+
+	// Every node configures the forest's floating IP so it can take it over,
+	// and joins the forest's placement group
+	var placementGroup string
+	if f, err := p.storage.GetForest(req.ForestID); err == nil {
+		cloudInitData.FloatingIP = f.FloatingIP
+		placementGroup = f.PlacementGroupID
+	}
+
+	// Give the node a host key we know, so SSH to it can be verified
+	hostKey, err := sshutil.GenerateHostKey()
+	if err != nil {
+		return nil, err
+	}
+	cloudInitData.HostKeyPrivate = hostKey.PrivateKey
+	cloudInitData.HostKeyPublic = hostKey.PublicKey
+
+	// Have the node report when cloud-init has finished
+	if err := ph.configure(&cloudInitData); err != nil {
+		return nil, err
+	}
+
+	// Create the volume first so cloud-init knows its device path
+	var volume *machine.Volume
+	if req.Volume != nil {
+		v, err := p.createNodeVolume(ctx, req, nodeName, req.NodeLocation(index))
+		if err != nil {
+			return nil, err
+		}
+		volume = v
+		cloudInitData.VolumeDevice = volume.Device
+		cloudInitData.VolumeFilesystem = req.Volume.Filesystem
+		cloudInitData.VolumeMountPoint = req.Volume.MountPoint
+	}
+
+	// Nodes registered before this one are its peers
+	if nodes, err := p.storage.GetNodes(req.ForestID); err == nil {
+		for _, n := range nodes {
+			cloudInitData.PeerIPs = append(cloudInitData.PeerIPs, n.IP)
+		}
+	}
+	if f, err := p.storage.GetForest(req.ForestID); err == nil {
+		cloudInitData.Metadata = NodeMetadata(p.config, f, index, nodeCount, req.NodeLocation(index), cloudInitData.PeerIPs).JSON()
+	}
+
+	userData, templatePath, err := RenderCloudInit(p.config, req, cloudInitData)
+	if templatePath != "" {
+		p.info(2, "Template: %s", templatePath)
+	}
+	if err != nil {
+		p.deleteVolume(ctx, volume)
 		return nil, fmt.Errorf("failed to generate cloud-init: %w", err)
 	}
 
-	// Determine server type and image
+	// Determine server type and image; the node's role may have its own
+	role := p.config.Provisioning.Role(cloudInitData.Role)
 	serverType := req.ServerType
+	if role != nil && role.ServerType != "" {
+		serverType = role.ServerType
+	}
 	if serverType == "" {
 		serverType = p.config.GetServerType()
 	}
 
 	image := req.Image
+	if index < len(req.NodeImages) {
+		image = req.NodeImages[index]
+	}
 	if image == "" {
 		image = p.config.GetImage()
 	}
 
 	// Create server
 	sshKeyName := p.config.GetSSHKeyName()
-	fmt.Printf("      ⏳ Creating server on cloud provider...\n")
-	fmt.Printf("      SSH key: %s\n", sshKeyName)
+	p.nodeStep(StepStarted, StepServer, nodeName, "Creating server on cloud provider")
+	p.info(2, "SSH key: %s", sshKeyName)
 	createReq := machine.CreateServerRequest{
 		Name:       nodeName,
 		ServerType: serverType,
 		Image:      image,
-		Location:   req.Location,
+		Location:   req.NodeLocation(index),
 		SSHKeys:    []string{sshKeyName},
 		UserData:   userData,
 		Labels: map[string]string{
 			"managed-by": "morpheus",
 			"forest-id":  req.ForestID,
+			"role":       cloudInitData.Role,
 		},
-		EnableIPv4: p.config.IsIPv4Enabled(),
+		EnableIPv4:     p.config.IsIPv4Enabled(),
+		PlacementGroup: placementGroup,
+	}
+	if role != nil {
+		for k, v := range role.Labels {
+			createReq.Labels[k] = v
+		}
+	}
+	if volume != nil {
+		createReq.Volumes = []string{volume.ID}
 	}
 
 	server, err := p.machine.CreateServer(ctx, createReq)
 	if err != nil {
+		// The volume is not attached to anything yet, so nothing else cleans it up
+		p.deleteVolume(ctx, volume)
 		return nil, err
 	}
 
-	fmt.Printf("      ✓ Server created (ID: %s)\n", server.ID)
+	p.nodeStep(StepCompleted, StepServer, nodeName, "Server created (ID: %s)", server.ID)
 
-	// Store the location immediately
-	server.Location = req.Location
+	// Store the location and type immediately
+	server.Location = req.NodeLocation(index)
+	server.ServerType = serverType
+	server.HostKey = hostKey.PublicKey
+	server.Image = image
+	server.UserData = userDataDigest(userData)
 
 	// Register node immediately so teardown can find it even if interrupted
 	if onCreated != nil {
 		onCreated(server)
 	}
 
-	fmt.Printf("      ⏳ Waiting for server to boot...\n")
+	p.nodeStep(StepStarted, StepBoot, nodeName, "Waiting for server to boot")
 
 	// Wait for server to be running
 	if err := p.machine.WaitForServer(ctx, server.ID, machine.ServerStateRunning); err != nil {
@@ -287,20 +761,187 @@ func (p *Provisioner) provisionNode(ctx context.Context, req ProvisionRequest, n
 	if err != nil {
 		return nil, fmt.Errorf("failed to get server info: %w", err)
 	}
+	server.HostKey = hostKey.PublicKey
 
-	fmt.Printf("      ✓ Server running\n")
-	fmt.Printf("      ⏳ Verifying SSH connectivity...\n")
+	p.nodeStep(StepCompleted, StepBoot, nodeName, "Server running")
+	p.nodeStep(StepStarted, StepSSH, nodeName, "Verifying SSH connectivity")
 
 	// Wait for infrastructure to be ready (SSH accessible, cloud-init complete)
 	if err := p.waitForInfrastructureReady(ctx, server); err != nil {
 		return nil, fmt.Errorf("infrastructure readiness check failed: %w", err)
 	}
 
-	fmt.Printf("      ✓ SSH accessible\n")
+	p.nodeStep(StepCompleted, StepSSH, nodeName, "SSH accessible")
+
+	if ph != nil {
+		if err := p.waitForPhoneHome(ctx, ph, req.ForestID, nodeName, server); err != nil {
+			return nil, err
+		}
+	}
 
 	return server, nil
 }
 
+// createNodeVolume creates the persistent volume for a node, labelled so
+// teardown can find it
+func (p *Provisioner) createNodeVolume(ctx context.Context, req ProvisionRequest, nodeName, location string) (*machine.Volume, error) {
+	vm, ok := p.machine.(machine.VolumeManager)
+	if !ok {
+		return nil, fmt.Errorf("machine provider does not support volumes")
+	}
+
+	filesystem := req.Volume.Filesystem
+	if filesystem == "" {
+		filesystem = "ext4"
+	}
+
+	p.nodeStep(StepStarted, StepVolume, nodeName, "Creating %d GB volume", req.Volume.SizeGB)
+	volume, err := vm.CreateVolume(ctx, machine.CreateVolumeRequest{
+		Name:     nodeName + "-data",
+		SizeGB:   req.Volume.SizeGB,
+		Format:   filesystem,
+		Location: location,
+		Labels: map[string]string{
+			"managed-by": "morpheus",
+			"forest-id":  req.ForestID,
+			"node":       nodeName,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create volume: %w", err)
+	}
+	p.nodeStep(StepCompleted, StepVolume, nodeName, "Volume created (ID: %s)", volume.ID)
+
+	return volume, nil
+}
+
+// deleteVolume removes a volume created for a node that failed to provision
+func (p *Provisioner) deleteVolume(ctx context.Context, volume *machine.Volume) {
+	if volume == nil {
+		return
+	}
+	vm, ok := p.machine.(machine.VolumeManager)
+	if !ok {
+		return
+	}
+	if err := vm.DeleteVolume(ctx, volume.ID); err != nil {
+		p.warn(2, "failed to delete volume %s: %s", volume.ID, err)
+	}
+}
+
+// forestVolumes returns the volumes created for a forest, or nil if the
+// provider does not support volumes
+func (p *Provisioner) forestVolumes(ctx context.Context, forestID string) ([]*machine.Volume, error) {
+	vm, ok := p.machine.(machine.VolumeManager)
+	if !ok {
+		return nil, nil
+	}
+	return vm.ListVolumes(ctx, map[string]string{
+		"managed-by": "morpheus",
+		"forest-id":  forestID,
+	})
+}
+
+// createLoadBalancer creates the forest's load balancer, targeting all of
+// its nodes by label
+func (p *Provisioner) createLoadBalancer(ctx context.Context, req ProvisionRequest, location string) (*machine.LoadBalancer, error) {
+	lbm, ok := p.machine.(machine.LoadBalancerManager)
+	if !ok {
+		return nil, fmt.Errorf("machine provider does not support load balancers")
+	}
+
+	p.report(Event{Type: StepStarted, Step: StepLoadBalancer, Message: "Creating load balancer"})
+	labels := map[string]string{
+		"managed-by": "morpheus",
+		"forest-id":  req.ForestID,
+	}
+	lb, err := lbm.CreateLoadBalancer(ctx, machine.CreateLoadBalancerRequest{
+		Name:           req.ForestID + "-lb",
+		Type:           req.LoadBalancer.Type,
+		Location:       location,
+		Labels:         labels,
+		TargetSelector: labels,
+		Services:       req.LoadBalancer.Services,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, svc := range req.LoadBalancer.Services {
+		p.info(1, "%s :%d → :%d", svc.Protocol, svc.ListenPort, svc.DestinationPort)
+	}
+	ready := fmt.Sprintf("Load balancer ready (ID: %s)", lb.ID)
+	if lb.PublicIPv4 != "" {
+		ready = fmt.Sprintf("Load balancer ready (IPv4: %s, IPv6: %s)", lb.PublicIPv4, lb.PublicIPv6)
+	}
+	p.report(Event{Type: StepCompleted, Step: StepLoadBalancer, Message: ready})
+	return lb, nil
+}
+
+// deleteLoadBalancers removes the load balancers created for a forest
+func (p *Provisioner) deleteLoadBalancers(ctx context.Context, forestID string) {
+	lbm, ok := p.machine.(machine.LoadBalancerManager)
+	if !ok {
+		return
+	}
+	lbs, err := lbm.ListLoadBalancers(ctx, map[string]string{
+		"managed-by": "morpheus",
+		"forest-id":  forestID,
+	})
+	if err != nil {
+		p.warn(0, "failed to list load balancers: %s", err)
+		return
+	}
+	for _, lb := range lbs {
+		e := p.deleting("", 0, 0, "Deleting load balancer %s", lb.Name)
+		p.deleted(e, lbm.DeleteLoadBalancer(ctx, lb.ID))
+	}
+}
+
+// createPlacementGroup creates the spread placement group that keeps a
+// forest's nodes on different physical hosts
+func (p *Provisioner) createPlacementGroup(ctx context.Context, forestID string) (*machine.PlacementGroup, error) {
+	pgm, ok := p.machine.(machine.PlacementGroupManager)
+	if !ok {
+		return nil, fmt.Errorf("machine provider does not support placement groups")
+	}
+
+	p.report(Event{Type: StepStarted, Step: StepPlacement, Message: "Spreading nodes across hosts"})
+	group, err := pgm.CreatePlacementGroup(ctx, machine.CreatePlacementGroupRequest{
+		Name: forestID + "-spread",
+		Labels: map[string]string{
+			"managed-by": "morpheus",
+			"forest-id":  forestID,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	p.report(Event{Type: StepCompleted, Step: StepPlacement, Message: fmt.Sprintf("Placement group %s", group.Name)})
+	return group, nil
+}
+
+// deletePlacementGroups removes the placement groups created for a forest.
+// Call it after the forest's servers are deleted.
+func (p *Provisioner) deletePlacementGroups(ctx context.Context, forestID string) {
+	pgm, ok := p.machine.(machine.PlacementGroupManager)
+	if !ok {
+		return
+	}
+	groups, err := pgm.ListPlacementGroups(ctx, map[string]string{
+		"managed-by": "morpheus",
+		"forest-id":  forestID,
+	})
+	if err != nil {
+		p.warn(0, "failed to list placement groups: %s", err)
+		return
+	}
+	for _, g := range groups {
+		e := p.deleting("", 0, 0, "Deleting placement group %s", g.Name)
+		p.deleted(e, pgm.DeletePlacementGroup(ctx, g.ID))
+	}
+}
+
 // waitForInfrastructureReady waits until the server's infrastructure is ready
 // This checks SSH connectivity as an indicator that cloud-init has progressed
 // far enough for the server to be usable
@@ -355,9 +996,8 @@ func (p *Provisioner) waitForInfrastructureReady(ctx context.Context, server *ma
 
 		status, err := p.checkSSHConnectivityWithStatus(addr)
 		if err == nil {
-			fmt.Printf("\n")
 			if usingFallback {
-				fmt.Printf("      ⚠️  Connected via IPv4 fallback\n")
+				p.warn(2, "connected via IPv4 fallback")
 			}
 			return nil
 		}
@@ -368,13 +1008,12 @@ func (p *Provisioner) waitForInfrastructureReady(ctx context.Context, server *ma
 				// Quick check if IPv4 is reachable
 				fallbackStatus, fallbackErr := p.checkSSHConnectivityWithStatus(fallbackAddr)
 				if fallbackErr == nil {
-					fmt.Printf("\n")
-					fmt.Printf("      ⚠️  IPv6 unreachable, using IPv4 fallback\n")
+					p.warn(2, "IPv6 unreachable, using IPv4 fallback")
 					return nil
 				}
 				// If IPv4 seems more promising (port closed = server exists), switch to it
 				if fallbackStatus == "port closed" || fallbackStatus == "connecting" {
-					fmt.Printf("      ⚠️  IPv6 %s, trying IPv4 fallback...\n", status)
+					p.warn(2, "IPv6 %s, trying IPv4 fallback...", status)
 					usingFallback = true
 				}
 			}
@@ -386,7 +1025,7 @@ func (p *Provisioner) waitForInfrastructureReady(ctx context.Context, server *ma
 			if usingFallback {
 				ipLabel = "IPv4"
 			}
-			fmt.Printf("      SSH check attempt %d (%s): %s\n", attempts, ipLabel, status)
+			p.info(2, "SSH check attempt %d (%s): %s", attempts, ipLabel, status)
 			lastStatus = status
 		}
 
@@ -441,9 +1080,24 @@ func classifySSHError(err error) string {
 	}
 }
 
+// TeardownOptions controls what Teardown removes
+type TeardownOptions struct {
+	// KeepVolumes leaves the forest's persistent volumes in place (detached)
+	KeepVolumes bool
+
+	// KeepSnapshots leaves the forest's snapshots in place, e.g. to
+	// restore the forest from them
+	KeepSnapshots bool
+}
+
 // Teardown removes a forest and all its resources
 func (p *Provisioner) Teardown(ctx context.Context, forestID string) error {
-	fmt.Printf("🗑️  Tearing down forest: %s\n\n", forestID)
+	return p.TeardownWithOptions(ctx, forestID, TeardownOptions{})
+}
+
+// TeardownWithOptions removes a forest and its resources as configured by opts
+func (p *Provisioner) TeardownWithOptions(ctx context.Context, forestID string, opts TeardownOptions) error {
+	p.report(Event{Type: StepStarted, Step: StepTeardown, Message: "Tearing down forest: " + forestID})
 
 	// Get all nodes for this forest
 	nodes, err := p.storage.GetNodes(forestID)
@@ -453,72 +1107,124 @@ func (p *Provisioner) Teardown(ctx context.Context, forestID string) error {
 
 	// Delete DNS records if DNS provider is configured
 	if p.dns != nil && p.config.DNS.Domain != "" {
-		fmt.Printf("Deleting DNS records...\n")
-		for i, node := range nodes {
-			recordName := fmt.Sprintf("%s-node-%d", forestID, i+1)
+		p.info(0, "Deleting DNS records...")
+		p.removeDNSRecords(ctx, forestID, nodes)
+	}
 
-			// Delete A record
-			if node.IPv4 != "" {
-				if err := p.dns.DeleteRecord(ctx, p.config.DNS.Domain, recordName, string(dns.RecordTypeA)); err != nil {
-					fmt.Printf("   ⚠️  Warning: failed to delete A record: %s\n", err)
-				}
-			}
+	// Remove the load balancer and floating IP before their targets
+	p.deleteLoadBalancers(ctx, forestID)
+	p.deleteFloatingIPs(ctx, forestID)
 
-			// Delete AAAA record
-			if node.IPv6 != "" {
-				if err := p.dns.DeleteRecord(ctx, p.config.DNS.Domain, recordName, string(dns.RecordTypeAAAA)); err != nil {
-					fmt.Printf("   ⚠️  Warning: failed to delete AAAA record: %s\n", err)
-				}
-			}
+	// Remove servers from the external inventory
+	if p.inventory != nil && len(nodes) > 0 {
+		p.info(0, "Removing %d machine%s from inventory...", len(nodes), plural(len(nodes)))
+		for _, node := range nodes {
+			p.removeInventory(ctx, node.ID)
 		}
 	}
 
 	// Delete all servers
 	if len(nodes) > 0 {
-		fmt.Printf("Deleting %d machine%s...\n", len(nodes), plural(len(nodes)))
+		p.info(0, "Deleting %d machine%s...", len(nodes), plural(len(nodes)))
 		for i, node := range nodes {
-			fmt.Printf("   [%d/%d] Deleting %s...", i+1, len(nodes), node.ID)
+			e := p.deleting(node.ID, i+1, len(nodes), "Deleting %s", node.ID)
+			p.deleted(e, p.machine.DeleteServer(ctx, node.ID))
+		}
+	}
+
+	p.deletePlacementGroups(ctx, forestID)
 
-			if err := p.machine.DeleteServer(ctx, node.ID); err != nil {
-				fmt.Printf(" ⚠️  Warning: %s\n", err)
-			} else {
-				fmt.Printf(" ✅\n")
+	// Delete (or keep) persistent volumes
+	volumes, err := p.forestVolumes(ctx, forestID)
+	if err != nil {
+		p.warn(0, "failed to list volumes: %s", err)
+	}
+	if len(volumes) > 0 {
+		if opts.KeepVolumes {
+			p.info(0, "Keeping %d volume%s:", len(volumes), plural(len(volumes)))
+			for _, v := range volumes {
+				p.info(1, "• %s (ID: %s, %d GB)", v.Name, v.ID, v.SizeGB)
+			}
+		} else {
+			vm := p.machine.(machine.VolumeManager)
+			p.info(0, "Deleting %d volume%s...", len(volumes), plural(len(volumes)))
+			for i, v := range volumes {
+				e := p.deleting("", i+1, len(volumes), "Deleting %s", v.Name)
+				p.deleted(e, vm.DeleteVolume(ctx, v.ID))
 			}
 		}
 	}
 
-	// Remove from storage
-	fmt.Printf("\nCleaning up storage...")
-	if err := p.storage.DeleteForest(forestID); err != nil {
-		fmt.Printf(" ⚠️  Warning: %s\n", err)
-	} else {
-		fmt.Printf(" ✅\n")
+	if !opts.KeepSnapshots {
+		p.deleteForestSnapshots(ctx, forestID)
 	}
 
+	p.removeNATSState(ctx, forestID)
+	p.removeMeshState(ctx, forestID)
+
+	// Remove from storage
+	e := p.deleting("", 0, 0, "Cleaning up storage")
+	p.deleted(e, p.storage.DeleteForest(forestID))
+
 	return nil
 }
 
 // rollback removes all provisioned servers on failure
-func (p *Provisioner) rollback(ctx context.Context, forestID string, _ []*machine.Server) {
+func (p *Provisioner) rollback(ctx context.Context, forestID string) {
 	// Get all registered nodes from storage (includes nodes registered before SSH verification)
 	nodes, err := p.storage.GetNodes(forestID)
 	if err != nil {
-		fmt.Printf("   ⚠️  Warning: failed to get nodes from storage: %s\n", err)
+		p.warn(1, "failed to get nodes from storage: %s", err)
 	}
 
+	p.deleteLoadBalancers(ctx, forestID)
+	p.deleteFloatingIPs(ctx, forestID)
+
 	// Delete all servers that were registered
+	registered := make(map[string]bool)
 	for i, node := range nodes {
-		fmt.Printf("   🗑️  Deleting machine %d/%d (%s)...\n", i+1, len(nodes), node.ID)
-		if err := p.machine.DeleteServer(ctx, node.ID); err != nil {
-			fmt.Printf("   ⚠️  Warning: failed to delete server %s: %s\n", node.ID, err)
-		} else {
-			fmt.Printf("   ✅ Machine deleted\n")
-		}
+		registered[node.ID] = true
+		p.removeInventory(ctx, node.ID)
+		e := p.deleting(node.ID, i+1, len(nodes), "Deleting machine %s", node.ID)
+		p.deleted(e, p.machine.DeleteServer(ctx, node.ID))
+	}
+
+	p.deleteUnregisteredServers(ctx, forestID, registered)
+
+	p.deletePlacementGroups(ctx, forestID)
+
+	// Delete volumes created for the forest
+	volumes, err := p.forestVolumes(ctx, forestID)
+	if err != nil {
+		p.warn(1, "failed to list volumes: %s", err)
+	}
+	for _, v := range volumes {
+		p.deleteVolume(ctx, v)
 	}
 
 	// Remove from storage
 	p.storage.DeleteForest(forestID)
-	fmt.Printf("   ✅ Rollback complete\n")
+	p.report(Event{Type: StepCompleted, Step: StepRollback, Message: "Rollback complete"})
+}
+
+// deleteUnregisteredServers deletes the forest's servers that are not in
+// registered. A server whose create response was lost was never
+// registered, so those are found by their labels.
+func (p *Provisioner) deleteUnregisteredServers(ctx context.Context, forestID string, registered map[string]bool) {
+	servers, err := p.machine.ListServers(ctx, map[string]string{
+		"managed-by": "morpheus",
+		"forest-id":  forestID,
+	})
+	if err != nil {
+		p.warn(1, "failed to list servers: %s", err)
+	}
+	for _, server := range servers {
+		if registered[server.ID] {
+			continue
+		}
+		e := p.deleting(server.ID, 0, 0, "Deleting unregistered machine %s (%s)", server.Name, server.ID)
+		p.deleted(e, p.machine.DeleteServer(ctx, server.ID))
+	}
 }
 
 // plural returns "s" if count is not 1, empty string otherwise
@@ -5,34 +5,72 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/nimsforest/morpheus/internal/hetznermock"
 	"github.com/nimsforest/morpheus/pkg/config"
 	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/machine/hetzner"
+	"github.com/nimsforest/morpheus/pkg/storage"
 )
 
 // mockProvider implements machine.Provider for testing
 type mockProvider struct {
 	servers map[string]*machine.Server
+	volumes map[string]*machine.Volume
+	lbs     map[string]*machine.LoadBalancer
+	fips    map[string]*machine.FloatingIP
+	groups  map[string]*machine.PlacementGroup
+	snaps   map[string]*machine.Snapshot
+	images  map[string]string // Image each server was created from, by server ID
+
+	onCreate func(req machine.CreateServerRequest) // Optional, e.g. to play cloud-init
+	power    []string                              // Power actions, as "action server-id"
 }
 
 func newMockProvider() *mockProvider {
 	return &mockProvider{
 		servers: make(map[string]*machine.Server),
+		volumes: make(map[string]*machine.Volume),
+		lbs:     make(map[string]*machine.LoadBalancer),
+		fips:    make(map[string]*machine.FloatingIP),
+		groups:  make(map[string]*machine.PlacementGroup),
+		snaps:   make(map[string]*machine.Snapshot),
+		images:  make(map[string]string),
 	}
 }
 
 func (m *mockProvider) CreateServer(ctx context.Context, req machine.CreateServerRequest) (*machine.Server, error) {
 	server := &machine.Server{
-		ID:         fmt.Sprintf("server-%d", len(m.servers)+1),
+		ID:         fmt.Sprintf("server-%d", len(m.images)+1), // Never reused
 		Name:       req.Name,
 		PublicIPv6: "::1",
 		Location:   req.Location,
+		ServerType: req.ServerType,
 		State:      machine.ServerStateStarting,
 		Labels:     req.Labels,
 	}
 	m.servers[server.ID] = server
+	m.images[server.ID] = req.Image
+	if m.onCreate != nil {
+		m.onCreate(req)
+	}
+	for _, id := range req.Volumes {
+		volume, ok := m.volumes[id]
+		if !ok {
+			return nil, fmt.Errorf("volume not found: %s", id)
+		}
+		volume.ServerID = server.ID
+	}
+	if req.PlacementGroup != "" {
+		group, ok := m.groups[req.PlacementGroup]
+		if !ok {
+			return nil, fmt.Errorf("placement group not found: %s", req.PlacementGroup)
+		}
+		group.Servers = append(group.Servers, server.ID)
+	}
 	return server, nil
 }
 
@@ -40,11 +78,24 @@ func (m *mockProvider) GetServer(ctx context.Context, serverID string) (*machine
 	if server, ok := m.servers[serverID]; ok {
 		return server, nil
 	}
-	return nil, fmt.Errorf("server not found: %s", serverID)
+	return nil, fmt.Errorf("server %s %w", serverID, machine.ErrNotFound)
 }
 
 func (m *mockProvider) DeleteServer(ctx context.Context, serverID string) error {
 	delete(m.servers, serverID)
+	for _, v := range m.volumes {
+		if v.ServerID == serverID {
+			v.ServerID = ""
+		}
+	}
+	for _, g := range m.groups {
+		for i, id := range g.Servers {
+			if id == serverID {
+				g.Servers = append(g.Servers[:i], g.Servers[i+1:]...)
+				break
+			}
+		}
+	}
 	return nil
 }
 
@@ -59,11 +110,471 @@ func (m *mockProvider) WaitForServer(ctx context.Context, serverID string, state
 func (m *mockProvider) ListServers(ctx context.Context, filters map[string]string) ([]*machine.Server, error) {
 	var result []*machine.Server
 	for _, s := range m.servers {
-		result = append(result, s)
+		matches := true
+		for k, v := range filters {
+			if s.Labels[k] != v {
+				matches = false
+			}
+		}
+		if matches {
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockProvider) UpdateLabels(ctx context.Context, serverID string, labels map[string]string) error {
+	server, ok := m.servers[serverID]
+	if !ok {
+		return fmt.Errorf("server not found: %s", serverID)
+	}
+	server.Labels = labels
+	return nil
+}
+
+func (m *mockProvider) CreateVolume(ctx context.Context, req machine.CreateVolumeRequest) (*machine.Volume, error) {
+	id := fmt.Sprintf("volume-%d", len(m.volumes)+1)
+	volume := &machine.Volume{
+		ID:       id,
+		Name:     req.Name,
+		SizeGB:   req.SizeGB,
+		Location: req.Location,
+		Device:   "/dev/disk/by-id/scsi-0HC_Volume_" + id,
+		Labels:   req.Labels,
+	}
+	m.volumes[id] = volume
+	return volume, nil
+}
+
+func (m *mockProvider) ListVolumes(ctx context.Context, filters map[string]string) ([]*machine.Volume, error) {
+	var result []*machine.Volume
+	for _, v := range m.volumes {
+		matches := true
+		for k, val := range filters {
+			if v.Labels[k] != val {
+				matches = false
+			}
+		}
+		if matches {
+			result = append(result, v)
+		}
 	}
 	return result, nil
 }
 
+func (m *mockProvider) DeleteVolume(ctx context.Context, volumeID string) error {
+	delete(m.volumes, volumeID)
+	return nil
+}
+
+func (m *mockProvider) CreateLoadBalancer(ctx context.Context, req machine.CreateLoadBalancerRequest) (*machine.LoadBalancer, error) {
+	lb := &machine.LoadBalancer{
+		ID:         fmt.Sprintf("lb-%d", len(m.lbs)+1),
+		Name:       req.Name,
+		PublicIPv4: "203.0.113.100",
+		Location:   req.Location,
+		Labels:     req.Labels,
+	}
+	m.lbs[lb.ID] = lb
+	return lb, nil
+}
+
+func (m *mockProvider) ListLoadBalancers(ctx context.Context, filters map[string]string) ([]*machine.LoadBalancer, error) {
+	var result []*machine.LoadBalancer
+	for _, lb := range m.lbs {
+		matches := true
+		for k, v := range filters {
+			if lb.Labels[k] != v {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			result = append(result, lb)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockProvider) DeleteLoadBalancer(ctx context.Context, id string) error {
+	delete(m.lbs, id)
+	return nil
+}
+
+func (m *mockProvider) CreateFloatingIP(ctx context.Context, req machine.CreateFloatingIPRequest) (*machine.FloatingIP, error) {
+	fip := &machine.FloatingIP{
+		ID:       fmt.Sprintf("fip-%d", len(m.fips)+1),
+		Name:     req.Name,
+		Type:     req.Type,
+		IP:       "203.0.113.200",
+		ServerID: req.ServerID,
+		Labels:   req.Labels,
+	}
+	m.fips[fip.ID] = fip
+	return fip, nil
+}
+
+func (m *mockProvider) ListFloatingIPs(ctx context.Context, filters map[string]string) ([]*machine.FloatingIP, error) {
+	var result []*machine.FloatingIP
+	for _, fip := range m.fips {
+		matches := true
+		for k, v := range filters {
+			if fip.Labels[k] != v {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			result = append(result, fip)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockProvider) AssignFloatingIP(ctx context.Context, floatingIPID, serverID string) error {
+	fip, ok := m.fips[floatingIPID]
+	if !ok {
+		return fmt.Errorf("floating IP not found: %s", floatingIPID)
+	}
+	fip.ServerID = serverID
+	return nil
+}
+
+func (m *mockProvider) DeleteFloatingIP(ctx context.Context, floatingIPID string) error {
+	delete(m.fips, floatingIPID)
+	return nil
+}
+
+func (m *mockProvider) CreatePlacementGroup(ctx context.Context, req machine.CreatePlacementGroupRequest) (*machine.PlacementGroup, error) {
+	group := &machine.PlacementGroup{
+		ID:     fmt.Sprintf("pg-%d", len(m.groups)+1),
+		Name:   req.Name,
+		Labels: req.Labels,
+	}
+	m.groups[group.ID] = group
+	return group, nil
+}
+
+func (m *mockProvider) ListPlacementGroups(ctx context.Context, filters map[string]string) ([]*machine.PlacementGroup, error) {
+	var result []*machine.PlacementGroup
+	for _, g := range m.groups {
+		matches := true
+		for k, v := range filters {
+			if g.Labels[k] != v {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			result = append(result, g)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockProvider) DeletePlacementGroup(ctx context.Context, placementGroupID string) error {
+	group, ok := m.groups[placementGroupID]
+	if !ok {
+		return fmt.Errorf("placement group not found: %s", placementGroupID)
+	}
+	if len(group.Servers) > 0 {
+		return fmt.Errorf("placement group %s is not empty", placementGroupID)
+	}
+	delete(m.groups, placementGroupID)
+	return nil
+}
+
+func (m *mockProvider) CreateSnapshot(ctx context.Context, req machine.CreateSnapshotRequest) (*machine.Snapshot, error) {
+	if _, ok := m.servers[req.ServerID]; !ok {
+		return nil, fmt.Errorf("server not found: %s", req.ServerID)
+	}
+	snap := &machine.Snapshot{
+		ID:          fmt.Sprintf("snapshot-%d", len(m.snaps)+1),
+		Description: req.Description,
+		ServerID:    req.ServerID,
+		SizeGB:      1.5,
+		Labels:      req.Labels,
+	}
+	m.snaps[snap.ID] = snap
+	return snap, nil
+}
+
+func (m *mockProvider) ListSnapshots(ctx context.Context, filters map[string]string) ([]*machine.Snapshot, error) {
+	var result []*machine.Snapshot
+	for _, s := range m.snaps {
+		matches := true
+		for k, v := range filters {
+			if s.Labels[k] != v {
+				matches = false
+			}
+		}
+		if matches {
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockProvider) DeleteSnapshot(ctx context.Context, snapshotID string) error {
+	if _, ok := m.snaps[snapshotID]; !ok {
+		return fmt.Errorf("snapshot not found: %s", snapshotID)
+	}
+	delete(m.snaps, snapshotID)
+	return nil
+}
+
+func (m *mockProvider) RebootServer(ctx context.Context, serverID string) error {
+	return m.powerAction("reboot", serverID, machine.ServerStateRunning)
+}
+
+func (m *mockProvider) PowerOffServer(ctx context.Context, serverID string) error {
+	return m.powerAction("poweroff", serverID, machine.ServerStateStopped)
+}
+
+func (m *mockProvider) PowerOnServer(ctx context.Context, serverID string) error {
+	return m.powerAction("poweron", serverID, machine.ServerStateRunning)
+}
+
+func (m *mockProvider) powerAction(action, serverID string, state machine.ServerState) error {
+	server, ok := m.servers[serverID]
+	if !ok {
+		return fmt.Errorf("server not found: %s", serverID)
+	}
+	server.State = state
+	m.power = append(m.power, action+" "+serverID)
+	return nil
+}
+
+func (m *mockProvider) ResizeServer(ctx context.Context, serverID, serverType string, upgradeDisk bool) error {
+	server, ok := m.servers[serverID]
+	if !ok {
+		return fmt.Errorf("server not found: %s", serverID)
+	}
+	if server.State != machine.ServerStateStopped {
+		return fmt.Errorf("server %s is not powered off", serverID)
+	}
+	server.ServerType = serverType
+	m.power = append(m.power, "resize "+serverID)
+	return nil
+}
+
+// Monthly prices of the server types the mock knows
+var mockPrices = map[string]float64{"cx22": 4, "cpx31": 15}
+
+func (m *mockProvider) EstimateCost(ctx context.Context, req machine.CostEstimateRequest) (*machine.CostEstimate, error) {
+	price, ok := mockPrices[req.ServerType]
+	if !ok {
+		return nil, fmt.Errorf("unknown server type: %s", req.ServerType)
+	}
+	est := &machine.CostEstimate{Location: req.Location, Currency: "EUR"}
+	est.Add("server "+req.ServerType, req.Nodes, price/machine.HoursPerMonth, price)
+	return est, nil
+}
+
+func TestProvisionSpreadsLargeForests(t *testing.T) {
+	p, prov, st := newScaleTestProvisioner(t, 0)
+	ctx := context.Background()
+
+	// Two nodes stay below the default threshold
+	if err := p.Provision(ctx, ProvisionRequest{ForestID: "small", NodeCount: 2, Location: "fsn1"}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+	if len(prov.groups) != 0 {
+		t.Errorf("Expected no placement group for 2 nodes, got %d", len(prov.groups))
+	}
+
+	if err := p.Provision(ctx, ProvisionRequest{ForestID: "big", NodeCount: 3, Location: "fsn1"}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+	f, err := st.GetForest("big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := prov.groups[f.PlacementGroupID]
+	if group == nil {
+		t.Fatalf("Placement group not recorded on forest: %+v", f)
+	}
+	if len(group.Servers) != 3 {
+		t.Errorf("Placement group has %d servers, want 3", len(group.Servers))
+	}
+
+	// Nodes added later join the group
+	if _, err := p.AddNodes(ctx, ScaleRequest{ForestID: "big"}, 1); err != nil {
+		t.Fatalf("AddNodes() error = %v", err)
+	}
+	if len(group.Servers) != 4 {
+		t.Errorf("Placement group has %d servers after scaling, want 4", len(group.Servers))
+	}
+
+	if err := p.Teardown(ctx, "big"); err != nil {
+		t.Fatalf("Teardown() error = %v", err)
+	}
+	if len(prov.groups) != 0 {
+		t.Errorf("Expected placement group to be deleted, %d remain", len(prov.groups))
+	}
+
+	// Placement can be turned off
+	p.config.Machine.Placement.Disabled = true
+	if err := p.Provision(ctx, ProvisionRequest{ForestID: "flat", NodeCount: 3, Location: "fsn1"}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+	if len(prov.groups) != 0 {
+		t.Errorf("Expected no placement group when disabled, got %d", len(prov.groups))
+	}
+}
+
+func TestProvisionWithFloatingIPAndFailover(t *testing.T) {
+	p, prov, st := newScaleTestProvisioner(t, 0)
+	ctx := context.Background()
+
+	if err := p.Provision(ctx, ProvisionRequest{ForestID: "ha", NodeCount: 2, Location: "fsn1", FloatingIP: "ipv4"}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+
+	f, err := st.GetForest("ha")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fip := prov.fips[f.FloatingIPID]
+	if fip == nil || f.FloatingIP != "203.0.113.200" {
+		t.Fatalf("Floating IP not recorded on forest: %+v", f)
+	}
+	nodes, _ := st.GetNodes("ha")
+	if fip.ServerID != nodes[0].ID {
+		t.Errorf("Floating IP assigned to %q, want first node %q", fip.ServerID, nodes[0].ID)
+	}
+
+	node, err := p.Failover(ctx, FailoverRequest{ForestID: "ha", Target: "ha-node-2"})
+	if err != nil {
+		t.Fatalf("Failover() error = %v", err)
+	}
+	if node.ID != nodes[1].ID || fip.ServerID != nodes[1].ID {
+		t.Errorf("Floating IP assigned to %q after failover, want %q", fip.ServerID, nodes[1].ID)
+	}
+	if _, err := p.Failover(ctx, FailoverRequest{ForestID: "ha", Target: "3"}); err == nil {
+		t.Error("Expected error failing over to a missing node")
+	}
+
+	// Removing the primary fails over to the remaining node
+	if _, err := p.RemoveNodes(ctx, "ha", 1); err != nil {
+		t.Fatalf("RemoveNodes() error = %v", err)
+	}
+	if fip.ServerID != nodes[0].ID {
+		t.Errorf("Floating IP assigned to %q after removing primary, want %q", fip.ServerID, nodes[0].ID)
+	}
+
+	if err := p.Teardown(ctx, "ha"); err != nil {
+		t.Fatalf("Teardown() error = %v", err)
+	}
+	if len(prov.fips) != 0 {
+		t.Errorf("Expected floating IP to be released, %d remain", len(prov.fips))
+	}
+}
+
+func TestProvisionWithLoadBalancer(t *testing.T) {
+	p, prov, st := newScaleTestProvisioner(t, 0)
+
+	err := p.Provision(context.Background(), ProvisionRequest{
+		ForestID:  "edge",
+		NodeCount: 2,
+		Location:  "fsn1",
+		LoadBalancer: &LoadBalancerSpec{
+			Services: []machine.LoadBalancerService{{Protocol: "tcp", ListenPort: 80, DestinationPort: 8080}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+
+	if len(prov.lbs) != 1 {
+		t.Fatalf("Expected 1 load balancer, got %d", len(prov.lbs))
+	}
+	f, err := st.GetForest("edge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.LoadBalancerID != "lb-1" || f.LoadBalancerIPv4 != "203.0.113.100" {
+		t.Errorf("Load balancer not recorded on forest: %+v", f)
+	}
+
+	if err := p.Teardown(context.Background(), "edge"); err != nil {
+		t.Fatalf("Teardown() error = %v", err)
+	}
+	if len(prov.lbs) != 0 {
+		t.Errorf("Expected load balancer to be deleted, %d remain", len(prov.lbs))
+	}
+}
+
+func TestProvisionWithVolumes(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		p, prov, _ := newScaleTestProvisioner(t, 0)
+
+		err := p.Provision(context.Background(), ProvisionRequest{
+			ForestID:  "data",
+			NodeCount: 2,
+			Location:  "fsn1",
+			Volume:    &VolumeSpec{SizeGB: 20, MountPoint: "/srv"},
+		})
+		if err != nil {
+			t.Fatalf("Provision() error = %v", err)
+		}
+
+		if len(prov.volumes) != 2 {
+			t.Fatalf("Expected 2 volumes, got %d", len(prov.volumes))
+		}
+		for _, v := range prov.volumes {
+			if v.ServerID == "" || v.SizeGB != 20 || v.Labels["forest-id"] != "data" {
+				t.Errorf("Unexpected volume: %+v", v)
+			}
+		}
+
+		if err := p.TeardownWithOptions(context.Background(), "data", TeardownOptions{KeepVolumes: keep}); err != nil {
+			t.Fatalf("Teardown() error = %v", err)
+		}
+		if keep && len(prov.volumes) != 2 {
+			t.Errorf("KeepVolumes: expected 2 volumes to remain, got %d", len(prov.volumes))
+		}
+		if !keep && len(prov.volumes) != 0 {
+			t.Errorf("Expected volumes to be deleted, %d remain", len(prov.volumes))
+		}
+	}
+}
+
+// mockInventory records registered server IDs
+type mockInventory struct {
+	servers map[string]string // server ID -> forest ID
+}
+
+func (m *mockInventory) RegisterNode(ctx context.Context, forestID string, server *machine.Server, meshIP string) error {
+	m.servers[server.ID] = forestID
+	return nil
+}
+
+func (m *mockInventory) RemoveNode(ctx context.Context, serverID string) error {
+	delete(m.servers, serverID)
+	return nil
+}
+
+func TestProvisionUpdatesInventory(t *testing.T) {
+	p, _, _ := newScaleTestProvisioner(t, 0)
+	inv := &mockInventory{servers: make(map[string]string)}
+	p.SetInventory(inv)
+
+	if err := p.Provision(context.Background(), ProvisionRequest{ForestID: "inv", NodeCount: 2, Location: "fsn1"}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+	if len(inv.servers) != 2 {
+		t.Fatalf("Expected 2 servers in inventory, got %v", inv.servers)
+	}
+
+	if err := p.Teardown(context.Background(), "inv"); err != nil {
+		t.Fatalf("Teardown() error = %v", err)
+	}
+	if len(inv.servers) != 0 {
+		t.Errorf("Expected inventory to be empty after teardown, got %v", inv.servers)
+	}
+}
+
 func TestCheckSSHConnectivity(t *testing.T) {
 	// Start a test TCP server to simulate SSH
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
@@ -276,3 +787,34 @@ func TestWaitForInfrastructureReady_ContextCancelled(t *testing.T) {
 		t.Errorf("Expected context.Canceled error, got: %v", err)
 	}
 }
+
+func TestRollbackDeletesUnregisteredServers(t *testing.T) {
+	mock := hetznermock.NewServer()
+	defer mock.Close()
+	ctx := context.Background()
+
+	prov, err := hetzner.NewProviderWithEndpoint("test-token", mock.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := prov.ReplaceSSHKey(ctx, "morpheus", "ssh-ed25519 AAAAtest"); err != nil {
+		t.Fatal(err)
+	}
+	reg, err := storage.NewLocalRegistry(filepath.Join(t.TempDir(), "registry.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The server is created, but the response to the create request is
+	// lost, so it never makes it into the registry
+	mock.SetChaos(hetznermock.Chaos{Failure: 1, Seed: 1})
+	p := NewProvisioner(prov, reg, &config.Config{})
+	err = p.Provision(ctx, ProvisionRequest{ForestID: "chaos", NodeCount: 1, Location: "fsn1", ServerType: "cx22", Image: "ubuntu-24.04"})
+	if err == nil {
+		t.Fatal("Provision() succeeded although creating the server failed")
+	}
+
+	if servers := mock.Servers(); len(servers) != 0 {
+		t.Errorf("%d server(s) left after rollback, want 0", len(servers))
+	}
+}
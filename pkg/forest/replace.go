@@ -0,0 +1,160 @@
+package forest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// ReplaceRequest asks for one node of a forest to be replaced by a new
+// server
+type ReplaceRequest struct {
+	ForestID string
+	Node     string // Node number (1-based), node-N, server name or server ID
+
+	// ServerType and Image override the forest's for the new server, e.g.
+	// to rebuild a forest onto a bigger type or a newer image node by node
+	ServerType string
+	Image      string
+}
+
+// ReplaceNode provisions a new server for a node of a forest, with the
+// node's role, location and labels, waits until it is ready, and then
+// swaps it in: the new server takes the node's place in the registry, its
+// DNS records and, if the node was primary, the floating IP. Only then is
+// the old server deleted, together with its volume. If the new server does
+// not come up, it is deleted and the old node is left as it was.
+//
+// The new server is named after the node with a timestamp suffix, since
+// the old server still holds the node's name while both exist.
+func (p *Provisioner) ReplaceNode(ctx context.Context, req ReplaceRequest) (*machine.Server, error) {
+	f, err := p.storage.GetForest(req.ForestID)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := p.storage.GetNodes(req.ForestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+	index, err := ResolveNode(f, nodes, req.Node)
+	if err != nil {
+		return nil, err
+	}
+	old := nodes[index]
+	name := ForestNames(f).Node(index)
+
+	// The replacement is configured like the node it replaces
+	recorded := RecordedRequest(f)
+	provReq := ProvisionRequest{
+		ForestID:   req.ForestID,
+		NodeCount:  len(nodes),
+		Location:   old.Location,
+		ServerType: recorded.ServerType,
+		Image:      recorded.Image,
+		Volume:     recorded.Volume,
+		Role:       NodeRoles(f, nodes)[index],
+	}
+	if provReq.Location == "" {
+		provReq.Location = recorded.NodeLocation(index)
+	}
+	if req.ServerType != "" {
+		provReq.ServerType = req.ServerType
+	}
+	if req.Image != "" {
+		provReq.Image = req.Image
+	}
+
+	ph, err := p.startPhoneHome(req.ForestID)
+	if err != nil {
+		return nil, err
+	}
+	defer ph.close()
+
+	p.report(Event{Type: StepStarted, Step: StepReplace, Node: name, Message: "Replacing " + name})
+
+	serverName := fmt.Sprintf("%s-%s", name, time.Now().UTC().Format("20060102150405"))
+	var created *machine.Server
+	server, err := p.provisionNode(ctx, provReq, serverName, index, len(nodes), ph, func(s *machine.Server) {
+		created = s
+		p.registerNode(req.ForestID, s)
+	})
+	if err != nil {
+		p.report(Event{Type: StepFailed, Step: StepReplace, Node: name, Message: "Provisioning failed", Err: err})
+		if created != nil {
+			p.report(Event{Type: StepStarted, Step: StepRollback, Node: serverName, Message: "Rolling back " + serverName})
+			if delErr := p.machine.DeleteServer(ctx, created.ID); delErr != nil {
+				p.warn(1, "failed to delete server %s: %s", created.ID, delErr)
+			}
+			if delErr := p.storage.DeleteNode(req.ForestID, created.ID); delErr != nil {
+				p.warn(1, "failed to remove node from storage: %s", delErr)
+			}
+		}
+		return nil, fmt.Errorf("failed to provision replacement for %s: %w", name, err)
+	}
+	if err := p.storage.UpdateNodeStatus(req.ForestID, server.ID, "active"); err != nil {
+		p.warn(1, "failed to update node status: %s", err)
+	}
+	p.report(Event{Type: StepCompleted, Step: StepMachine, Level: 1, Node: serverName,
+		Message: fmt.Sprintf("Replacement ready (%s)", server.GetPreferredIP())})
+
+	// Swap the new server in before the old one goes away
+	if err := p.storage.ReplaceNode(req.ForestID, old.ID, server.ID); err != nil {
+		return server, fmt.Errorf("failed to replace %s in storage: %w", name, err)
+	}
+	if p.dns != nil && p.config.DNS.Domain != "" {
+		p.deleteDNSRecords(ctx, req.ForestID, old, index)
+		p.createDNSRecords(ctx, req.ForestID, server, index)
+	}
+	p.registerInventory(ctx, req.ForestID, server)
+	p.removeInventory(ctx, old.ID)
+	p.moveFloatingIP(ctx, f, old.ID, server.ID, index)
+
+	// Volumes are matched to their server before it is deleted, since
+	// deleting the server detaches them
+	volumes, err := p.forestVolumes(ctx, req.ForestID)
+	if err != nil {
+		p.warn(1, "failed to list volumes: %s", err)
+	}
+	e := p.deleting(old.ID, 0, 0, "Deleting old server %s", old.ID)
+	if err := p.machine.DeleteServer(ctx, old.ID); err != nil {
+		e.Type, e.Err = StepFailed, err
+		p.report(e)
+		return server, fmt.Errorf("failed to delete old server %s: %w", old.ID, err)
+	}
+	for _, v := range volumes {
+		if v.ServerID == old.ID {
+			p.deleteVolume(ctx, v)
+		}
+	}
+	p.deleted(e, nil)
+
+	p.report(Event{Type: StepCompleted, Step: StepReplace, Node: name, Message: fmt.Sprintf("Replaced %s with %s", name, server.ID)})
+	return server, nil
+}
+
+// moveFloatingIP moves the forest's floating IP from a replaced node's
+// server to its replacement, if the replaced node held it
+func (p *Provisioner) moveFloatingIP(ctx context.Context, f *storage.Forest, oldID, newID string, nodeIndex int) {
+	if f.FloatingIPID == "" {
+		return
+	}
+	fim, ok := p.machine.(machine.FloatingIPManager)
+	if !ok {
+		return
+	}
+	ips, err := fim.ListFloatingIPs(ctx, map[string]string{"forest-id": f.ID})
+	if err != nil {
+		p.warn(1, "failed to list floating IPs: %s", err)
+		return
+	}
+	for _, ip := range ips {
+		if ip.ID == f.FloatingIPID && ip.ServerID == oldID {
+			if err := p.assignFloatingIP(ctx, f, newID, nodeIndex); err != nil {
+				p.warn(1, "failed to move floating IP: %s", err)
+			}
+		}
+	}
+}
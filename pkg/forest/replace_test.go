@@ -0,0 +1,69 @@
+package forest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+func TestReplaceNode(t *testing.T) {
+	p, prov, reg := newScaleTestProvisioner(t, 3)
+	ctx := context.Background()
+
+	f, _ := reg.GetForest("forest-1")
+	f.Request, _ = json.Marshal(ProvisionRequest{ServerType: "cx22", Image: "ubuntu-22.04", Role: "web"})
+	if err := reg.UpdateForest(f); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := reg.GetNodes("forest-1")
+
+	var created []machine.CreateServerRequest
+	prov.onCreate = func(req machine.CreateServerRequest) {
+		created = append(created, req)
+	}
+	server, err := p.ReplaceNode(ctx, ReplaceRequest{ForestID: "forest-1", Node: "2", Image: "ubuntu-24.04"})
+	if err != nil {
+		t.Fatalf("ReplaceNode() error = %v", err)
+	}
+
+	if len(created) != 1 {
+		t.Fatalf("created %d servers, want 1", len(created))
+	}
+	req := created[0]
+	if req.ServerType != "cx22" || req.Image != "ubuntu-24.04" || req.Labels["role"] != "web" {
+		t.Errorf("replacement created with type %s, image %s, role %s", req.ServerType, req.Image, req.Labels["role"])
+	}
+
+	// The replacement takes the node's place; the old server is gone
+	after, _ := reg.GetNodes("forest-1")
+	if len(after) != 3 || after[0].ID != before[0].ID || after[1].ID != server.ID || after[2].ID != before[2].ID {
+		t.Errorf("nodes after replacement = %v, %v, %v", after[0].ID, after[1].ID, after[2].ID)
+	}
+	if after[1].Status != "active" {
+		t.Errorf("replacement status = %s, want active", after[1].Status)
+	}
+	if _, ok := prov.servers[before[1].ID]; ok {
+		t.Error("old server was not deleted")
+	}
+}
+
+func TestReplaceNodeFailureKeepsNode(t *testing.T) {
+	p, prov, reg := newScaleTestProvisioner(t, 2)
+	p.config.Provisioning.SSHPort = 1 // Never reachable
+	p.config.Provisioning.ReadinessTimeout = "300ms"
+	before, _ := reg.GetNodes("forest-1")
+
+	if _, err := p.ReplaceNode(context.Background(), ReplaceRequest{ForestID: "forest-1", Node: "1"}); err == nil {
+		t.Fatal("ReplaceNode() succeeded with an unreachable replacement")
+	}
+
+	after, _ := reg.GetNodes("forest-1")
+	if len(after) != 2 || after[0].ID != before[0].ID || after[1].ID != before[1].ID {
+		t.Errorf("nodes changed by a failed replacement: %v", after)
+	}
+	if len(prov.servers) != 2 {
+		t.Errorf("%d servers left, want the original 2", len(prov.servers))
+	}
+}
@@ -0,0 +1,247 @@
+package forest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// NodeStatusResizing marks a node whose server type is being changed
+const NodeStatusResizing = "resizing"
+
+// ResizeRequest asks for the server type of one or all nodes of a forest
+// to be changed
+type ResizeRequest struct {
+	ForestID   string
+	Node       string // Node number (1-based), node-N, server name or server ID; empty for all nodes
+	ServerType string
+
+	// UpgradeDisk also grows the nodes' disks to the new type's. Resizing
+	// to a type with a smaller disk is then no longer possible.
+	UpgradeDisk bool
+
+	// Force and ShutdownTimeout are as in PowerRequest, for the power off
+	// a resize needs
+	Force           bool
+	ShutdownTimeout time.Duration
+}
+
+// Resize changes the server type of a node, or of all nodes one after the
+// other so the rest of the forest keeps running. Each node is powered off
+// (gracefully, as with Power), resized, powered on again and waited for.
+// Nodes that already have the type are skipped. Resizing all nodes also
+// records the type for nodes added later, and the forest's expected spend
+// follows the new type if the provider can price it. It returns the IDs of
+// the nodes that were resized.
+func (p *Provisioner) Resize(ctx context.Context, req ResizeRequest) ([]string, error) {
+	rs, ok := p.machine.(machine.ServerResizer)
+	pm, canPower := p.machine.(machine.PowerManager)
+	if !ok || !canPower {
+		return nil, fmt.Errorf("machine provider %s does not support resizing servers", p.config.GetMachineProvider())
+	}
+	if req.ServerType == "" {
+		return nil, fmt.Errorf("no server type given")
+	}
+	f, err := p.storage.GetForest(req.ForestID)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := p.storage.GetNodes(req.ForestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+	targets, err := resizeTargets(f, nodes, req.Node)
+	if err != nil {
+		return nil, err
+	}
+	timeout := req.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+
+	names := ForestNames(f)
+	delta := 0.0
+	var resized []string
+	for n, i := range targets {
+		node, name := nodes[i], names.Node(i)
+		from := p.nodeServerType(f, node)
+		if from == req.ServerType {
+			p.info(1, "%s already is a %s", name, req.ServerType)
+			continue
+		}
+
+		e := Event{Type: StepStarted, Step: StepResize, Number: n + 1, Total: len(targets), Node: name,
+			Message: fmt.Sprintf("Resizing %s from %s to %s", name, from, req.ServerType)}
+		p.report(e)
+		p.setNodeStatus(req.ForestID, node.ID, NodeStatusResizing)
+		if err := p.resizeNode(ctx, rs, pm, node, name, req, timeout); err != nil {
+			e.Type, e.Err = StepFailed, err
+			p.report(e)
+			p.setNodeStatus(req.ForestID, node.ID, NodeStatusFailed)
+			p.updateResizeCost(req.ForestID, delta)
+			return resized, err
+		}
+
+		updated := *node
+		updated.ServerType = req.ServerType
+		updated.Status = "active"
+		if err := p.storage.UpdateNode(&updated); err != nil {
+			p.warn(1, "failed to update node: %s", err)
+		}
+		if d, ok := p.typeCostDelta(ctx, node.Location, from, req.ServerType); ok {
+			delta += d
+		}
+		resized = append(resized, node.ID)
+		e.Type, e.Message = StepCompleted, fmt.Sprintf("%s is a %s now", name, req.ServerType)
+		p.report(e)
+	}
+
+	// Nodes added later get the new type too
+	if req.Node == "" {
+		p.recordServerType(req.ForestID, req.ServerType)
+	}
+	p.updateResizeCost(req.ForestID, delta)
+	return resized, nil
+}
+
+// ResizeCost returns the change of a forest's expected monthly spend if
+// nodes are resized as requested. ok is false if the provider cannot price
+// server types.
+func (p *Provisioner) ResizeCost(ctx context.Context, req ResizeRequest) (delta float64, ok bool, err error) {
+	f, err := p.storage.GetForest(req.ForestID)
+	if err != nil {
+		return 0, false, err
+	}
+	nodes, err := p.storage.GetNodes(req.ForestID)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get nodes: %w", err)
+	}
+	targets, err := resizeTargets(f, nodes, req.Node)
+	if err != nil {
+		return 0, false, err
+	}
+	for _, i := range targets {
+		from := p.nodeServerType(f, nodes[i])
+		d, priced := p.typeCostDelta(ctx, nodes[i].Location, from, req.ServerType)
+		if !priced {
+			return 0, false, nil
+		}
+		delta += d
+	}
+	return delta, true, nil
+}
+
+// resizeTargets returns the indexes of the nodes to resize: the one ref
+// names, or all of them if it is empty
+func resizeTargets(f *storage.Forest, nodes []*storage.Node, ref string) ([]int, error) {
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("forest %s has no nodes", f.ID)
+	}
+	if ref != "" {
+		index, err := ResolveNode(f, nodes, ref)
+		if err != nil {
+			return nil, err
+		}
+		return []int{index}, nil
+	}
+	targets := make([]int, len(nodes))
+	for i := range nodes {
+		targets[i] = i
+	}
+	return targets, nil
+}
+
+// resizeNode powers a node off, changes its server type and powers it on
+// again. If the type cannot be changed, the node is powered on with its
+// old type.
+func (p *Provisioner) resizeNode(ctx context.Context, rs machine.ServerResizer, pm machine.PowerManager, node *storage.Node, name string, req ResizeRequest, timeout time.Duration) error {
+	if err := p.powerOff(ctx, pm, node, name, req.Force, timeout); err != nil {
+		return err
+	}
+	p.info(1, "Changing the server type of %s", name)
+	if err := rs.ResizeServer(ctx, node.ID, req.ServerType, req.UpgradeDisk); err != nil {
+		if onErr := pm.PowerOnServer(ctx, node.ID); onErr != nil {
+			p.warn(1, "failed to power %s on again: %s", name, onErr)
+		}
+		return fmt.Errorf("failed to resize %s: %w", name, err)
+	}
+	if err := pm.PowerOnServer(ctx, node.ID); err != nil {
+		return fmt.Errorf("failed to power on %s: %w", name, err)
+	}
+	return p.waitForNode(ctx, node, name)
+}
+
+// nodeServerType returns the server type of a node: the one recorded with
+// it, or for nodes created before types were recorded, the forest's
+func (p *Provisioner) nodeServerType(f *storage.Forest, node *storage.Node) string {
+	if node.ServerType != "" {
+		return node.ServerType
+	}
+	if role := p.config.Provisioning.Role(node.Role); role != nil && role.ServerType != "" {
+		return role.ServerType
+	}
+	if req := RecordedRequest(f); req.ServerType != "" {
+		return req.ServerType
+	}
+	return p.config.GetServerType()
+}
+
+// typeCostDelta returns how much more a server of type to costs per month
+// than one of type from, if the provider can price them
+func (p *Provisioner) typeCostDelta(ctx context.Context, location, from, to string) (float64, bool) {
+	ce, ok := p.machine.(machine.CostEstimator)
+	if !ok {
+		return 0, false
+	}
+	price := func(serverType string) (float64, bool) {
+		est, err := ce.EstimateCost(ctx, machine.CostEstimateRequest{Nodes: 1, ServerType: serverType, Location: location})
+		if err != nil {
+			return 0, false
+		}
+		return est.Monthly, true
+	}
+	before, okBefore := price(from)
+	after, okAfter := price(to)
+	return after - before, okBefore && okAfter
+}
+
+// updateResizeCost adjusts a forest's expected spend by the cost change of
+// resized nodes. Forests without an expected spend are left alone.
+func (p *Provisioner) updateResizeCost(forestID string, delta float64) {
+	if delta == 0 {
+		return
+	}
+	f, err := p.storage.GetForest(forestID)
+	if err != nil || f.ExpectedNodeCost == 0 || f.NodeCount == 0 {
+		return
+	}
+	f.ExpectedNodeCost += delta / float64(f.NodeCount)
+	if err := p.storage.UpdateForest(f); err != nil {
+		p.warn(1, "failed to update expected spend: %s", err)
+		return
+	}
+	p.info(1, "💰 Expected spend: €%.2f/month (%+.2f)", f.ExpectedMonthlyCost(), delta)
+}
+
+// recordServerType records the server type in the forest's request, so
+// nodes added later get it
+func (p *Provisioner) recordServerType(forestID, serverType string) {
+	f, err := p.storage.GetForest(forestID)
+	if err != nil {
+		return
+	}
+	req := RecordedRequest(f)
+	req.ServerType = serverType
+	data, err := json.Marshal(req)
+	if err != nil {
+		return
+	}
+	f.Request = data
+	if err := p.storage.UpdateForest(f); err != nil {
+		p.warn(1, "failed to record server type: %s", err)
+	}
+}
@@ -0,0 +1,65 @@
+package forest
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestResize(t *testing.T) {
+	p, prov, reg := newScaleTestProvisioner(t, 2)
+	p.sshBinary = "false" // Unreachable, so the power is cut right away
+	ctx := context.Background()
+
+	f, _ := reg.GetForest("forest-1")
+	f.Request, _ = json.Marshal(ProvisionRequest{ServerType: "cx22"})
+	f.ExpectedNodeCost = 5
+	if err := reg.UpdateForest(f); err != nil {
+		t.Fatal(err)
+	}
+	nodes, _ := reg.GetNodes("forest-1")
+
+	req := ResizeRequest{ForestID: "forest-1", Node: "2", ServerType: "cpx31", ShutdownTimeout: time.Second}
+	if delta, ok, err := p.ResizeCost(ctx, req); err != nil || !ok || delta != 11 {
+		t.Errorf("ResizeCost() = %v, %v, %v; want 11", delta, ok, err)
+	}
+	resized, err := p.Resize(ctx, req)
+	if err != nil {
+		t.Fatalf("Resize() error = %v", err)
+	}
+	if !slices.Equal(resized, []string{nodes[1].ID}) {
+		t.Errorf("resized %v, want node 2", resized)
+	}
+	id := nodes[1].ID
+	if want := []string{"poweroff " + id, "resize " + id, "poweron " + id}; !slices.Equal(prov.power, want) {
+		t.Errorf("actions = %v, want %v", prov.power, want)
+	}
+	nodes, _ = reg.GetNodes("forest-1")
+	if nodes[1].ServerType != "cpx31" || nodes[1].Status != "active" {
+		t.Errorf("node 2 = %s, %s; want cpx31, active", nodes[1].ServerType, nodes[1].Status)
+	}
+
+	// The expected spend follows, spread over the nodes
+	f, _ = reg.GetForest("forest-1")
+	if math.Abs(f.ExpectedNodeCost-10.5) > 0.001 {
+		t.Errorf("ExpectedNodeCost = %v, want 10.5", f.ExpectedNodeCost)
+	}
+
+	// Resizing all nodes skips those that already have the type and
+	// records it for new nodes
+	prov.power = nil
+	resized, err = p.Resize(ctx, ResizeRequest{ForestID: "forest-1", ServerType: "cpx31", ShutdownTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("Resize() error = %v", err)
+	}
+	if !slices.Equal(resized, []string{nodes[0].ID}) {
+		t.Errorf("resized %v, want only node 1", resized)
+	}
+	f, _ = reg.GetForest("forest-1")
+	if got := RecordedRequest(f).ServerType; got != "cpx31" {
+		t.Errorf("recorded server type = %s, want cpx31", got)
+	}
+}
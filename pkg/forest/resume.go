@@ -0,0 +1,141 @@
+package forest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+const (
+	// StatusIncomplete marks a forest whose plant stopped part way; its
+	// ready nodes are kept for Resume
+	StatusIncomplete = "incomplete"
+
+	// NodeStatusFailed marks a node that was created but never became ready
+	NodeStatusFailed = "failed"
+)
+
+// ErrIncomplete is returned by Provision and Resume when they stopped after
+// some nodes were ready. The forest is kept and can be resumed.
+var ErrIncomplete = errors.New("forest incomplete")
+
+// abort handles a failure of provisionNodes at node index failed (the
+// node count for failures after all nodes): with no node ready, the forest
+// is rolled back; otherwise it is kept and marked incomplete.
+func (p *Provisioner) abort(ctx context.Context, forest *storage.Forest, failed int, err error) error {
+	ready := 0
+	if nodes, nerr := p.storage.GetNodes(forest.ID); nerr == nil {
+		for _, n := range nodes {
+			if n.Status == "active" {
+				ready++
+			}
+		}
+	}
+
+	if ready == 0 {
+		p.report(Event{Type: StepStarted, Step: StepRollback, Message: fmt.Sprintf("Rolling back %d machine%s", failed+1, plural(failed+1))})
+		p.rollback(ctx, forest.ID)
+		return err
+	}
+
+	forest.Status = StatusIncomplete
+	if uerr := p.storage.UpdateForest(forest); uerr != nil {
+		p.warn(0, "failed to update forest: %s", uerr)
+	}
+	p.warn(0, "keeping %d ready machine%s; forest marked incomplete", ready, plural(ready))
+	p.info(0, "💡 Resume with: morpheus plant --resume %s", forest.ID)
+	return fmt.Errorf("%w: %w", ErrIncomplete, err)
+}
+
+// Resume finishes a plant that stopped part way, as recorded with the
+// forest: it removes the nodes that failed or never became ready, then
+// provisions the missing nodes and the steps after them
+func (p *Provisioner) Resume(ctx context.Context, forestID string) error {
+	f, err := p.storage.GetForest(forestID)
+	if err != nil {
+		return err
+	}
+	if f.Status != StatusIncomplete && f.Status != "provisioning" {
+		return fmt.Errorf("forest %s is %s; only incomplete forests can be resumed", forestID, f.Status)
+	}
+	if len(f.Request) == 0 {
+		return fmt.Errorf("forest %s has no recorded plant request", forestID)
+	}
+	var req ProvisionRequest
+	if err := json.Unmarshal(f.Request, &req); err != nil {
+		return fmt.Errorf("invalid plant request of forest %s: %w", forestID, err)
+	}
+	// New nodes join the existing ones, wherever location fallback put them
+	if f.Location != "" {
+		req.Location = f.Location
+	}
+
+	ph, err := p.startPhoneHome(forestID)
+	if err != nil {
+		return err
+	}
+	defer ph.close()
+
+	ready, err := p.removeUnreadyNodes(ctx, forestID)
+	if err != nil {
+		return err
+	}
+
+	f.Status = "provisioning"
+	if err := p.storage.UpdateForest(f); err != nil {
+		p.warn(0, "failed to update forest: %s", err)
+	}
+	return p.provisionNodes(ctx, req, f, ph, ready)
+}
+
+// removeUnreadyNodes deletes a forest's nodes that are not active, its
+// servers that were never registered and the volumes of removed nodes.
+// It returns the number of nodes kept.
+func (p *Provisioner) removeUnreadyNodes(ctx context.Context, forestID string) (int, error) {
+	nodes, err := p.storage.GetNodes(forestID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get nodes: %w", err)
+	}
+
+	p.report(Event{Type: StepStarted, Step: StepCleanup, Message: "Removing unfinished machines"})
+
+	// Nodes are named by registration order, and only the last ones fail
+	names := p.names(forestID)
+	registered := make(map[string]bool)
+	kept := make(map[string]bool)
+	var remove []*storage.Node
+	for i, node := range nodes {
+		registered[node.ID] = true
+		if node.Status == "active" {
+			kept[names.Node(i)] = true
+		} else {
+			remove = append(remove, node)
+		}
+	}
+
+	for i, node := range remove {
+		p.removeInventory(ctx, node.ID)
+		e := p.deleting(node.ID, i+1, len(remove), "Deleting %s machine %s", node.Status, node.ID)
+		p.deleted(e, p.machine.DeleteServer(ctx, node.ID))
+		if err := p.storage.DeleteNode(forestID, node.ID); err != nil {
+			return 0, fmt.Errorf("failed to remove node %s: %w", node.ID, err)
+		}
+	}
+	p.deleteUnregisteredServers(ctx, forestID, registered)
+
+	volumes, err := p.forestVolumes(ctx, forestID)
+	if err != nil {
+		p.warn(1, "failed to list volumes: %s", err)
+	}
+	for _, v := range volumes {
+		if !kept[v.Labels["node"]] {
+			p.deleteVolume(ctx, v)
+		}
+	}
+
+	p.report(Event{Type: StepCompleted, Step: StepCleanup, Message: fmt.Sprintf("Keeping %d ready machine%s", len(kept), plural(len(kept)))})
+	return len(kept), nil
+}
@@ -0,0 +1,89 @@
+package forest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+func TestProvisionKeepsReadyNodesAndResumes(t *testing.T) {
+	p, prov, reg := newScaleTestProvisioner(t, 0)
+	p.SetReporter(ReporterFunc(func(Event) {}))
+	ctx := context.Background()
+
+	// The second node never gets an address, so SSH never comes up
+	failing := "f-node-2"
+	prov.onCreate = func(req machine.CreateServerRequest) {
+		for _, s := range prov.servers {
+			if s.Name == failing {
+				s.PublicIPv6 = ""
+			}
+		}
+	}
+
+	err := p.Provision(ctx, ProvisionRequest{ForestID: "f", NodeCount: 3, Location: "fsn1"})
+	if !errors.Is(err, ErrIncomplete) {
+		t.Fatalf("Provision() error = %v, want %v", err, ErrIncomplete)
+	}
+	f, err := reg.GetForest("f")
+	if err != nil {
+		t.Fatalf("forest removed after a partial failure: %v", err)
+	}
+	if f.Status != StatusIncomplete {
+		t.Errorf("forest status = %s, want %s", f.Status, StatusIncomplete)
+	}
+	nodes, _ := reg.GetNodes("f")
+	if len(nodes) != 2 || nodes[0].Status != "active" || nodes[1].Status != NodeStatusFailed {
+		t.Fatalf("nodes after failure = %+v, want one active and one failed", nodes)
+	}
+	firstID := nodes[0].ID
+
+	failing = ""
+	if err := p.Resume(ctx, "f"); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+
+	f, _ = reg.GetForest("f")
+	if f.Status != StatusActive {
+		t.Errorf("forest status after resume = %s, want %s", f.Status, StatusActive)
+	}
+	nodes, _ = reg.GetNodes("f")
+	if len(nodes) != 3 || nodes[0].ID != firstID {
+		t.Fatalf("nodes after resume = %+v, want the first node kept and 2 new ones", nodes)
+	}
+	for _, n := range nodes {
+		if n.Status != "active" {
+			t.Errorf("node %s is %s after resume", n.ID, n.Status)
+		}
+	}
+	if len(prov.servers) != 3 {
+		t.Errorf("%d servers after resume, want 3 (failed server deleted)", len(prov.servers))
+	}
+
+	if err := p.Resume(ctx, "f"); err == nil {
+		t.Error("Resume() of an active forest: expected error")
+	}
+}
+
+func TestProvisionRollsBackWithoutReadyNodes(t *testing.T) {
+	p, prov, reg := newScaleTestProvisioner(t, 0)
+	p.SetReporter(ReporterFunc(func(Event) {}))
+	prov.onCreate = func(req machine.CreateServerRequest) {
+		for _, s := range prov.servers {
+			s.PublicIPv6 = ""
+		}
+	}
+
+	err := p.Provision(context.Background(), ProvisionRequest{ForestID: "f", NodeCount: 2, Location: "fsn1"})
+	if err == nil || errors.Is(err, ErrIncomplete) {
+		t.Fatalf("Provision() error = %v, want a failure without %v", err, ErrIncomplete)
+	}
+	if _, err := reg.GetForest("f"); err == nil {
+		t.Error("forest kept although no node was ready")
+	}
+	if len(prov.servers) != 0 {
+		t.Errorf("%d servers left after rollback, want 0", len(prov.servers))
+	}
+}
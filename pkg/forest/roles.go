@@ -0,0 +1,152 @@
+package forest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nimsforest/morpheus/pkg/cloudinit"
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// NodeRole is one entry of a forest's role layout: a role and how many of
+// the forest's nodes have it
+type NodeRole struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// ParseRoles parses a role layout such as "edge=1,core=2"
+func ParseRoles(s string) ([]NodeRole, error) {
+	var roles []NodeRole
+	for _, part := range strings.Split(s, ",") {
+		name, count, ok := strings.Cut(strings.TrimSpace(part), "=")
+		n, err := strconv.Atoi(count)
+		if !ok || err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid role %q (use ROLE=COUNT, e.g. edge=1,core=2)", part)
+		}
+		roles = append(roles, NodeRole{Name: name, Count: n})
+	}
+	if err := validateRoles(roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// ConfiguredRoles returns the role layout in provisioning.roles, or nil if
+// not every configured role has a count
+func ConfiguredRoles(cfg *config.Config) []NodeRole {
+	var roles []NodeRole
+	for _, role := range cfg.Provisioning.Roles {
+		if role.Count < 1 {
+			return nil
+		}
+		roles = append(roles, NodeRole{Name: role.Name, Count: role.Count})
+	}
+	return roles
+}
+
+// RoleNodeCount returns the number of nodes of a role layout
+func RoleNodeCount(roles []NodeRole) int {
+	n := 0
+	for _, role := range roles {
+		n += role.Count
+	}
+	return n
+}
+
+// FormatRoles formats a role layout the way ParseRoles reads it
+func FormatRoles(roles []NodeRole) string {
+	parts := make([]string, len(roles))
+	for i, role := range roles {
+		parts[i] = fmt.Sprintf("%s=%d", role.Name, role.Count)
+	}
+	return strings.Join(parts, ",")
+}
+
+func validateRoles(roles []NodeRole) error {
+	seen := make(map[string]bool)
+	for _, role := range roles {
+		switch {
+		case !config.ValidRoleName(role.Name):
+			return fmt.Errorf("invalid role name %q", role.Name)
+		case seen[role.Name]:
+			return fmt.Errorf("role %s is listed twice", role.Name)
+		case role.Count < 1:
+			return fmt.Errorf("role %s needs at least one node", role.Name)
+		}
+		seen[role.Name] = true
+	}
+	return nil
+}
+
+// NodeRole returns the role of the node with the given (0-based) index.
+// Nodes are assigned the roles of the layout in order; nodes beyond it,
+// e.g. added by scale, get its last role.
+func (r ProvisionRequest) NodeRole(index int) string {
+	if role := layoutRole(r.Roles, r.Role, index); role != "" {
+		return role
+	}
+	return cloudinit.DefaultRole
+}
+
+// layoutRole returns the role of the node with the given index in a role
+// layout, or role if there is no layout
+func layoutRole(roles []NodeRole, role string, index int) string {
+	for _, r := range roles {
+		if index < r.Count {
+			return r.Name
+		}
+		index -= r.Count
+	}
+	if len(roles) > 0 {
+		return roles[len(roles)-1].Name
+	}
+	return role
+}
+
+// RecordedRequest returns the request a forest was planted with, as far as
+// it was recorded
+func RecordedRequest(f *storage.Forest) ProvisionRequest {
+	var req ProvisionRequest
+	if len(f.Request) > 0 {
+		json.Unmarshal(f.Request, &req)
+	}
+	req.ForestID = f.ID
+	return req
+}
+
+// NodeRoles returns the roles of a forest's nodes: the one recorded with
+// each node, or for nodes created before roles were recorded, the one its
+// index has in the forest's request
+func NodeRoles(f *storage.Forest, nodes []*storage.Node) []string {
+	req := RecordedRequest(f)
+	roles := make([]string, len(nodes))
+	for i, node := range nodes {
+		roles[i] = node.Role
+		if roles[i] == "" {
+			roles[i] = req.NodeRole(i)
+		}
+	}
+	return roles
+}
+
+// NodeImages returns the images of a forest's nodes: the one recorded with
+// each node, or for nodes created before images were recorded, the one the
+// forest's request gave for its index. It is "" where neither is known.
+func NodeImages(f *storage.Forest, nodes []*storage.Node) []string {
+	req := RecordedRequest(f)
+	images := make([]string, len(nodes))
+	for i, node := range nodes {
+		images[i] = node.Image
+		if images[i] == "" && i < len(req.NodeImages) {
+			images[i] = req.NodeImages[i]
+		}
+		if images[i] == "" {
+			images[i] = req.Image
+		}
+	}
+	return images
+}
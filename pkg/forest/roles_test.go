@@ -0,0 +1,77 @@
+package forest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+func TestParseRoles(t *testing.T) {
+	roles, err := ParseRoles("edge=1, core=2")
+	if err != nil {
+		t.Fatalf("ParseRoles() error = %v", err)
+	}
+	if FormatRoles(roles) != "edge=1,core=2" || RoleNodeCount(roles) != 3 {
+		t.Errorf("ParseRoles() = %v", roles)
+	}
+	for _, s := range []string{"edge", "edge=0", "edge=x", "=1", "edge=1,edge=2"} {
+		if _, err := ParseRoles(s); err == nil {
+			t.Errorf("ParseRoles(%q) accepted an invalid layout", s)
+		}
+	}
+}
+
+func TestProvisionRoles(t *testing.T) {
+	p, prov, reg := newScaleTestProvisioner(t, 0)
+	p.config.Naming.Server = "{{.ForestID}}-{{.Role}}-{{.Index}}"
+	p.config.Provisioning.Roles = []config.RoleConfig{
+		{Name: "core", ServerType: "cx32", Labels: map[string]string{"tier": "core"}},
+	}
+	ctx := context.Background()
+
+	types := make(map[string]string) // Server type by server name
+	prov.onCreate = func(req machine.CreateServerRequest) {
+		types[req.Name] = req.ServerType
+	}
+
+	req := ProvisionRequest{ForestID: "app", Location: "fsn1", ServerType: "cx22", Roles: []NodeRole{{"edge", 1}, {"core", 2}}}
+	if err := p.Provision(ctx, req); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+	// Added nodes get the layout's last role
+	if _, err := p.Scale(ctx, ScaleRequest{ForestID: "app", TargetCount: 4}); err != nil {
+		t.Fatalf("Scale() error = %v", err)
+	}
+
+	f, _ := reg.GetForest("app")
+	nodes, _ := reg.GetNodes("app")
+	if len(nodes) != 4 {
+		t.Fatalf("forest has %d nodes, want 4", len(nodes))
+	}
+	want := []string{"edge", "core", "core", "core"}
+	for i, role := range NodeRoles(f, nodes) {
+		server, _ := prov.GetServer(ctx, nodes[i].ID)
+		if role != want[i] || nodes[i].Role != want[i] {
+			t.Errorf("node %d role = %s, want %s", i+1, role, want[i])
+		}
+		if server.Labels["role"] != want[i] || server.Name != ForestNames(f).Node(i) {
+			t.Errorf("server %s has labels %v", server.Name, server.Labels)
+		}
+		wantType, tier := "cx22", ""
+		if want[i] == "core" {
+			wantType, tier = "cx32", "core"
+		}
+		if types[server.Name] != wantType || server.Labels["tier"] != tier {
+			t.Errorf("server %s: type %s, tier label %q; want %s, %q", server.Name, types[server.Name], server.Labels["tier"], wantType, tier)
+		}
+	}
+	if name := ForestNames(f).Node(0); name != "app-edge-1" {
+		t.Errorf("first node name = %s, want app-edge-1", name)
+	}
+
+	if err := p.Provision(ctx, ProvisionRequest{ForestID: "bad", NodeCount: 2, Roles: []NodeRole{{"edge", 1}}}); err == nil {
+		t.Error("Provision() accepted a node count that does not match the roles")
+	}
+}
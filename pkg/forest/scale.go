@@ -0,0 +1,296 @@
+package forest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+// DefaultScaleCooldown is the minimum time between two scale operations on a forest
+const DefaultScaleCooldown = 5 * time.Minute
+
+// ErrScaleInProgress is returned when another scale operation holds the forest lock
+var ErrScaleInProgress = errors.New("scale operation already in progress")
+
+// CooldownError is returned when a forest was scaled too recently
+type CooldownError struct {
+	ForestID  string
+	Remaining time.Duration
+}
+
+func (e *CooldownError) Error() string {
+	return fmt.Sprintf("forest %s is in cooldown for another %s", e.ForestID, e.Remaining.Round(time.Second))
+}
+
+// ScaleRequest contains parameters for scaling a forest to a target size
+type ScaleRequest struct {
+	ForestID    string
+	TargetCount int           // Desired number of nodes (minimum 1)
+	Cooldown    time.Duration // Minimum time since the last scale operation (0 disables)
+	Location    string        // Location for new nodes (defaults to the forest's locations)
+	ServerType  string        // Server type for new nodes (default: the forest's)
+	Image       string        // OS image for new nodes
+
+	// Verify are checks run after adding nodes, in addition to the
+	// configured ones
+	Verify []config.VerifyCheck
+
+	// NodeCost is the expected monthly spend of each added node, checked
+	// against limits.max_monthly_cost (default: the forest's)
+	NodeCost float64
+
+	// OverrideBudget adds nodes even if that exceeds the limit
+	OverrideBudget bool
+}
+
+// ScaleResult describes the outcome of a scale operation
+type ScaleResult struct {
+	ForestID      string   `json:"forest_id"`
+	PreviousCount int      `json:"previous_count"`
+	CurrentCount  int      `json:"current_count"`
+	Action        string   `json:"action"` // "up", "down" or "none"
+	AddedNodes    []string `json:"added_nodes,omitempty"`
+	RemovedNodes  []string `json:"removed_nodes,omitempty"`
+}
+
+// Scale grows or shrinks an existing forest to the requested node count.
+// Scale-down removes the most recently added nodes first.
+// Callers serialize calls with the per-forest lock; nothing is recorded in
+// the registry while scaling, so an interrupted scale leaves no state behind.
+func (p *Provisioner) Scale(ctx context.Context, req ScaleRequest) (*ScaleResult, error) {
+	if req.TargetCount < 1 {
+		return nil, fmt.Errorf("target node count must be at least 1, got %d", req.TargetCount)
+	}
+
+	f, err := p.storage.GetForest(req.ForestID)
+	if err != nil {
+		return nil, fmt.Errorf("forest not found: %w", err)
+	}
+
+	nodes, err := p.storage.GetNodes(req.ForestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+
+	result := &ScaleResult{
+		ForestID:      req.ForestID,
+		PreviousCount: len(nodes),
+		CurrentCount:  len(nodes),
+		Action:        "none",
+	}
+
+	if req.TargetCount == len(nodes) {
+		return result, nil
+	}
+
+	if req.Cooldown > 0 && !f.LastScaled.IsZero() {
+		if elapsed := time.Since(f.LastScaled); elapsed < req.Cooldown {
+			return nil, &CooldownError{ForestID: req.ForestID, Remaining: req.Cooldown - elapsed}
+		}
+	}
+
+	var scaleErr error
+	if req.TargetCount > len(nodes) {
+		result.Action = "up"
+		result.AddedNodes, scaleErr = p.AddNodes(ctx, req, req.TargetCount-len(nodes))
+	} else {
+		result.Action = "down"
+		result.RemovedNodes, scaleErr = p.RemoveNodes(ctx, req.ForestID, len(nodes)-req.TargetCount)
+	}
+
+	// Record the new size, even on partial failure
+	if nodes, err := p.storage.GetNodes(req.ForestID); err == nil {
+		result.CurrentCount = len(nodes)
+	}
+	if f, err := p.storage.GetForest(req.ForestID); err == nil {
+		updated := *f
+		updated.NodeCount = result.CurrentCount
+		if updated.Status == "scaling" {
+			// Left behind by an interrupted scale of an older version
+			updated.Status = "active"
+		}
+		updated.LastScaled = time.Now()
+		if result.Action == "up" {
+			updated.LastExpansion = updated.LastScaled
+		}
+		if err := p.storage.UpdateForest(&updated); err != nil {
+			p.warn(1, "failed to update forest: %s", err)
+		}
+	}
+
+	// Routes changed, so every node gets the new cluster configuration
+	if p.config.Provisioning.NATS.Enabled && len(result.AddedNodes)+len(result.RemovedNodes) > 0 {
+		p.report(Event{Type: StepStarted, Step: StepNATS, Message: "Reconfiguring NATS cluster"})
+		if err := p.BootstrapNATS(ctx, req.ForestID); err != nil {
+			p.warn(1, "%s", err)
+			p.info(1, "💡 Retry with: morpheus nats bootstrap %s", req.ForestID)
+		}
+	}
+
+	// Grown forests are verified again; the result decides their status
+	if checks := p.verifyChecks(req.Verify); len(checks) > 0 && len(result.AddedNodes) > 0 {
+		p.report(Event{Type: StepStarted, Step: StepVerify, Message: "Verifying forest"})
+		status := p.verifyForest(ctx, req.ForestID, checks)
+		if err := p.storage.UpdateForestStatus(req.ForestID, status); err != nil {
+			p.warn(1, "failed to update forest status: %s", err)
+		}
+	}
+
+	if scaleErr != nil {
+		return result, scaleErr
+	}
+	return result, nil
+}
+
+// AddNodes provisions count additional nodes for an existing forest and
+// returns the IDs of the nodes that were added.
+// If a node fails to provision, only that node is rolled back.
+func (p *Provisioner) AddNodes(ctx context.Context, req ScaleRequest, count int) ([]string, error) {
+	f, err := p.storage.GetForest(req.ForestID)
+	if err != nil {
+		return nil, fmt.Errorf("forest not found: %w", err)
+	}
+	existing, err := p.storage.GetNodes(req.ForestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+
+	// New nodes of spread forests continue the round-robin, and get their
+	// index's role in the forest's layout and a volume like the others
+	recorded := RecordedRequest(f)
+	provReq := ProvisionRequest{
+		ForestID:   req.ForestID,
+		NodeCount:  len(existing) + count,
+		Location:   req.Location,
+		ServerType: req.ServerType,
+		Image:      req.Image,
+		Role:       recorded.Role,
+		Roles:      recorded.Roles,
+		Volume:     recorded.Volume,
+	}
+	if provReq.Location == "" {
+		provReq.Location, provReq.Locations = f.Location, f.Locations
+	}
+	if provReq.ServerType == "" {
+		provReq.ServerType = recorded.ServerType
+	}
+
+	nodeCost := req.NodeCost
+	if nodeCost == 0 {
+		nodeCost = f.ExpectedNodeCost
+	}
+	if err := p.checkBudget(nodeCost*float64(count), "", req.OverrideBudget); err != nil {
+		return nil, err
+	}
+
+	ph, err := p.startPhoneHome(req.ForestID)
+	if err != nil {
+		return nil, err
+	}
+	defer ph.close()
+
+	p.report(Event{Type: StepStarted, Step: StepMachines, Message: fmt.Sprintf("Adding %d machine%s to %s", count, plural(count), req.ForestID)})
+
+	names := ForestNames(f)
+	var added []string
+	for i := 0; i < count; i++ {
+		index := len(existing) + i
+		nodeName := names.Node(index)
+
+		p.report(Event{Type: StepStarted, Step: StepMachine, Level: 1, Number: i + 1, Total: count, Node: nodeName})
+
+		var created *machine.Server
+		server, err := p.provisionNode(ctx, provReq, nodeName, index, provReq.NodeCount, ph, func(s *machine.Server) {
+			created = s
+			p.registerNode(req.ForestID, s)
+		})
+		if err != nil {
+			p.report(Event{Type: StepFailed, Step: StepMachines, Node: nodeName, Message: "Provisioning failed", Err: err})
+			if created != nil {
+				p.report(Event{Type: StepStarted, Step: StepRollback, Node: nodeName, Message: "Rolling back " + nodeName})
+				// The node's volume is attached to the server, so it is
+				// matched before the server is deleted
+				volumes, _ := p.forestVolumes(ctx, req.ForestID)
+				if delErr := p.machine.DeleteServer(ctx, created.ID); delErr != nil {
+					p.warn(1, "failed to delete server %s: %s", created.ID, delErr)
+				}
+				for _, v := range volumes {
+					if v.ServerID == created.ID {
+						p.deleteVolume(ctx, v)
+					}
+				}
+				if delErr := p.storage.DeleteNode(req.ForestID, created.ID); delErr != nil {
+					p.warn(1, "failed to remove node from storage: %s", delErr)
+				}
+			}
+			return added, fmt.Errorf("failed to provision node %s: %w", nodeName, err)
+		}
+
+		if err := p.storage.UpdateNodeStatus(req.ForestID, server.ID, "active"); err != nil {
+			p.warn(1, "failed to update node status: %s", err)
+		}
+		p.report(Event{Type: StepCompleted, Step: StepMachine, Level: 1, Number: i + 1, Total: count, Node: nodeName,
+			Message: fmt.Sprintf("Machine %d ready (%s)", i+1, server.GetPreferredIP())})
+
+		if p.dns != nil && p.config.DNS.Domain != "" {
+			p.createDNSRecords(ctx, req.ForestID, server, index)
+		}
+		p.registerInventory(ctx, req.ForestID, server)
+
+		added = append(added, server.ID)
+	}
+
+	return added, nil
+}
+
+// RemoveNodes deletes the count most recently added nodes of a forest and
+// returns the IDs of the nodes that were removed.
+func (p *Provisioner) RemoveNodes(ctx context.Context, forestID string, count int) ([]string, error) {
+	nodes, err := p.storage.GetNodes(forestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+	if count >= len(nodes) {
+		return nil, fmt.Errorf("cannot remove %d of %d nodes; use teardown to delete the forest", count, len(nodes))
+	}
+
+	p.report(Event{Type: StepStarted, Step: StepRemove, Message: fmt.Sprintf("Removing %d machine%s from %s", count, plural(count), forestID)})
+
+	// Volumes are matched to their server before it is deleted, since
+	// deleting the server detaches them
+	volumes, err := p.forestVolumes(ctx, forestID)
+	if err != nil {
+		p.warn(1, "failed to list volumes: %s", err)
+	}
+
+	var removed []string
+	for i := len(nodes) - 1; i >= len(nodes)-count; i-- {
+		node := nodes[i]
+		if p.dns != nil && p.config.DNS.Domain != "" {
+			p.deleteDNSRecords(ctx, forestID, node, i)
+		}
+		p.removeInventory(ctx, node.ID)
+
+		e := p.deleting(node.ID, 0, 0, "Deleting %s", node.ID)
+		if err := p.machine.DeleteServer(ctx, node.ID); err != nil {
+			e.Type, e.Err = StepFailed, err
+			p.report(e)
+			return removed, fmt.Errorf("failed to delete server %s: %w", node.ID, err)
+		}
+		for _, v := range volumes {
+			if v.ServerID == node.ID {
+				p.deleteVolume(ctx, v)
+			}
+		}
+		p.deleted(e, p.storage.DeleteNode(forestID, node.ID))
+		removed = append(removed, node.ID)
+	}
+
+	p.reassignFloatingIP(ctx, forestID, removed)
+
+	return removed, nil
+}
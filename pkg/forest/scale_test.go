@@ -0,0 +1,269 @@
+package forest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// newScaleTestProvisioner returns a provisioner backed by a mock provider and a
+// temporary local registry containing a forest with nodeCount nodes.
+// SSH readiness checks are answered by a local IPv6 listener.
+func newScaleTestProvisioner(t *testing.T, nodeCount int) (*Provisioner, *mockProvider, storage.Registry) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback not available: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, portStr, _ := net.SplitHostPort(listener.Addr().String())
+	var port int
+	fmt.Sscanf(portStr, "%d", &port)
+
+	cfg := &config.Config{
+		Provisioning: config.ProvisioningConfig{
+			ReadinessTimeout:  "5s",
+			ReadinessInterval: "100ms",
+			SSHPort:           port,
+		},
+	}
+
+	reg, err := storage.NewLocalRegistry(filepath.Join(t.TempDir(), "registry.json"))
+	if err != nil {
+		t.Fatalf("Failed to create registry: %v", err)
+	}
+	if err := reg.RegisterForest(&storage.Forest{ID: "forest-1", NodeCount: nodeCount, Location: "fsn1", Status: "active"}); err != nil {
+		t.Fatal(err)
+	}
+
+	prov := newMockProvider()
+	for i := 0; i < nodeCount; i++ {
+		server, _ := prov.CreateServer(context.Background(), machine.CreateServerRequest{Name: fmt.Sprintf("forest-1-node-%d", i+1)})
+		if err := reg.RegisterNode(&storage.Node{ID: server.ID, ForestID: "forest-1", IP: server.PublicIPv6, IPv6: server.PublicIPv6, Status: "active"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return NewProvisioner(prov, reg, cfg), prov, reg
+}
+
+func TestScaleUp(t *testing.T) {
+	p, prov, reg := newScaleTestProvisioner(t, 2)
+
+	result, err := p.Scale(context.Background(), ScaleRequest{ForestID: "forest-1", TargetCount: 3})
+	if err != nil {
+		t.Fatalf("Scale() error = %v", err)
+	}
+
+	if result.Action != "up" || result.PreviousCount != 2 || result.CurrentCount != 3 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if len(prov.servers) != 3 {
+		t.Errorf("Expected 3 servers, got %d", len(prov.servers))
+	}
+
+	f, _ := reg.GetForest("forest-1")
+	if f.NodeCount != 3 {
+		t.Errorf("NodeCount = %d, want 3", f.NodeCount)
+	}
+	if f.Status != "active" {
+		t.Errorf("Status = %q, want %q", f.Status, "active")
+	}
+	if f.LastScaled.IsZero() {
+		t.Error("Expected LastScaled to be set")
+	}
+
+	// The new node's generated host key is pinned
+	nodes, _ := reg.GetNodes("forest-1")
+	if len(nodes) != 3 || !strings.HasPrefix(nodes[2].HostKey, "ssh-ed25519 ") {
+		t.Errorf("Expected new node to have a pinned host key, got %+v", nodes[len(nodes)-1])
+	}
+}
+
+func TestScaleUpWaitsForPhoneHome(t *testing.T) {
+	p, prov, reg := newScaleTestProvisioner(t, 1)
+	p.config.Provisioning.PhoneHome = "http"
+	p.config.Provisioning.PhoneHomeListen = "127.0.0.1:0"
+	p.config.Provisioning.PhoneHomeTimeout = "5s"
+
+	// Play cloud-init: post the node's host key to its phone-home URL
+	urlRe := regexp.MustCompile(`(?m)^  url: (\S+)$`)
+	keyRe := regexp.MustCompile(`(?m)^  ed25519_public: (.+)$`)
+	prov.onCreate = func(req machine.CreateServerRequest) {
+		u := urlRe.FindStringSubmatch(req.UserData)
+		key := keyRe.FindStringSubmatch(req.UserData)
+		if u == nil || key == nil {
+			t.Errorf("user data has no phone_home URL or host key:\n%s", req.UserData)
+			return
+		}
+		go func() {
+			resp, err := http.PostForm(u[1], url.Values{"pub_key_ed25519": {key[1]}, "hostname": {req.Name}})
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+	}
+
+	if _, err := p.Scale(context.Background(), ScaleRequest{ForestID: "forest-1", TargetCount: 2}); err != nil {
+		t.Fatalf("Scale() error = %v", err)
+	}
+
+	nodes, _ := reg.GetNodes("forest-1")
+	if len(nodes) != 2 || nodes[1].Readiness != "ready" || nodes[1].ReadyAt.IsZero() {
+		t.Errorf("Expected new node to be ready, got %+v", nodes[len(nodes)-1])
+	}
+}
+
+func TestScaleDownRemovesNewestNodes(t *testing.T) {
+	p, prov, reg := newScaleTestProvisioner(t, 3)
+
+	result, err := p.Scale(context.Background(), ScaleRequest{ForestID: "forest-1", TargetCount: 1})
+	if err != nil {
+		t.Fatalf("Scale() error = %v", err)
+	}
+
+	if result.Action != "down" || result.CurrentCount != 1 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if len(result.RemovedNodes) != 2 || result.RemovedNodes[0] != "server-3" || result.RemovedNodes[1] != "server-2" {
+		t.Errorf("RemovedNodes = %v, want [server-3 server-2]", result.RemovedNodes)
+	}
+
+	nodes, _ := reg.GetNodes("forest-1")
+	if len(nodes) != 1 || nodes[0].ID != "server-1" {
+		t.Errorf("Expected only server-1 to remain, got %v", nodes)
+	}
+	if _, ok := prov.servers["server-1"]; !ok || len(prov.servers) != 1 {
+		t.Errorf("Expected only server-1 at the provider, got %d servers", len(prov.servers))
+	}
+}
+
+func TestScaleNoop(t *testing.T) {
+	p, _, _ := newScaleTestProvisioner(t, 2)
+
+	result, err := p.Scale(context.Background(), ScaleRequest{ForestID: "forest-1", TargetCount: 2})
+	if err != nil {
+		t.Fatalf("Scale() error = %v", err)
+	}
+	if result.Action != "none" {
+		t.Errorf("Action = %q, want %q", result.Action, "none")
+	}
+}
+
+func TestScaleCooldown(t *testing.T) {
+	p, _, reg := newScaleTestProvisioner(t, 2)
+
+	f, _ := reg.GetForest("forest-1")
+	updated := *f
+	updated.LastScaled = time.Now().Add(-time.Minute)
+	reg.UpdateForest(&updated)
+
+	_, err := p.Scale(context.Background(), ScaleRequest{ForestID: "forest-1", TargetCount: 3, Cooldown: 5 * time.Minute})
+	var cooldownErr *CooldownError
+	if !errors.As(err, &cooldownErr) {
+		t.Fatalf("Expected CooldownError, got %v", err)
+	}
+	if cooldownErr.Remaining <= 0 || cooldownErr.Remaining > 4*time.Minute {
+		t.Errorf("Remaining = %s, want about 4m", cooldownErr.Remaining)
+	}
+}
+
+func TestScaleRecoversInterruptedScale(t *testing.T) {
+	p, _, reg := newScaleTestProvisioner(t, 2)
+	reg.UpdateForestStatus("forest-1", "scaling")
+
+	if _, err := p.Scale(context.Background(), ScaleRequest{ForestID: "forest-1", TargetCount: 3}); err != nil {
+		t.Fatalf("Scale failed: %v", err)
+	}
+	f, _ := reg.GetForest("forest-1")
+	if f.Status != "active" || f.NodeCount != 3 {
+		t.Errorf("Forest = %s with %d nodes, want active with 3", f.Status, f.NodeCount)
+	}
+}
+
+func TestScaleAddsVolumes(t *testing.T) {
+	p, prov, _ := newScaleTestProvisioner(t, 0)
+	ctx := context.Background()
+
+	err := p.Provision(ctx, ProvisionRequest{
+		ForestID:  "data",
+		NodeCount: 1,
+		Location:  "fsn1",
+		Volume:    &VolumeSpec{SizeGB: 20, MountPoint: "/srv"},
+	})
+	if err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+	result, err := p.Scale(ctx, ScaleRequest{ForestID: "data", TargetCount: 2})
+	if err != nil {
+		t.Fatalf("Scale failed: %v", err)
+	}
+
+	if len(prov.volumes) != 2 {
+		t.Fatalf("Expected 2 volumes, got %d", len(prov.volumes))
+	}
+	attached := false
+	for _, v := range prov.volumes {
+		if v.ServerID == result.AddedNodes[0] {
+			attached = v.SizeGB == 20
+		}
+	}
+	if !attached {
+		t.Errorf("Expected a 20 GB volume on the added node, got %+v", prov.volumes)
+	}
+}
+
+func TestScaleInvalidTarget(t *testing.T) {
+	p, _, _ := newScaleTestProvisioner(t, 2)
+
+	if _, err := p.Scale(context.Background(), ScaleRequest{ForestID: "forest-1", TargetCount: 0}); err == nil {
+		t.Error("Expected error for target count 0")
+	}
+}
+
+func TestSpreadLocations(t *testing.T) {
+	p, _, reg := newScaleTestProvisioner(t, 0)
+	ctx := context.Background()
+
+	if err := p.Provision(ctx, ProvisionRequest{ForestID: "spread", NodeCount: 3, Locations: []string{"fsn1", "nbg1"}}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+	if _, err := p.Scale(ctx, ScaleRequest{ForestID: "spread", TargetCount: 4}); err != nil {
+		t.Fatalf("Scale() error = %v", err)
+	}
+
+	nodes, _ := reg.GetNodes("spread")
+	var got []string
+	for _, n := range nodes {
+		got = append(got, n.Location)
+	}
+	if want := []string{"fsn1", "nbg1", "fsn1", "nbg1"}; !slices.Equal(got, want) {
+		t.Errorf("node locations = %v, want %v", got, want)
+	}
+	if f, _ := reg.GetForest("spread"); f.Location != "fsn1" || len(f.Locations) != 2 {
+		t.Errorf("forest location = %q, locations = %v", f.Location, f.Locations)
+	}
+}
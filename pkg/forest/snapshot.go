@@ -0,0 +1,270 @@
+package forest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// StatusRestoreFailed marks a forest whose servers were removed by an in-place
+// restore that then failed. It only holds the forest's snapshots, so the
+// restore can be retried.
+const StatusRestoreFailed = "restore-failed"
+
+// Snapshot takes a disk snapshot of every node of a forest and records the
+// set with the forest. The nodes keep running, so each snapshot is
+// crash-consistent; volumes are not included. If a node fails, the
+// snapshots already taken are deleted again.
+func (p *Provisioner) Snapshot(ctx context.Context, forestID string) (*storage.ForestSnapshot, error) {
+	sm, ok := p.machine.(machine.SnapshotManager)
+	if !ok {
+		return nil, fmt.Errorf("machine provider %s does not support snapshots", p.config.GetMachineProvider())
+	}
+	f, err := p.storage.GetForest(forestID)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := p.storage.GetNodes(forestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("forest %s has no nodes", forestID)
+	}
+
+	snap := storage.ForestSnapshot{
+		ID:        newSnapshotID(f, time.Now()),
+		CreatedAt: time.Now().UTC(),
+		Location:  f.Location,
+	}
+	p.report(Event{Type: StepStarted, Step: StepSnapshot, Message: fmt.Sprintf("Snapshotting %d machine%s (%s)", len(nodes), plural(len(nodes)), snap.ID)})
+	names := ForestNames(f)
+	for i, node := range nodes {
+		name := names.Node(i)
+		e := Event{Type: StepStarted, Step: StepSnapshot, Level: 1, Number: i + 1, Total: len(nodes), Node: name, Message: "Snapshotting " + name}
+		p.report(e)
+
+		s, err := sm.CreateSnapshot(ctx, machine.CreateSnapshotRequest{
+			ServerID:    node.ID,
+			Description: fmt.Sprintf("%s %s", name, snap.ID),
+			Labels: map[string]string{
+				"managed-by": "morpheus",
+				"forest-id":  forestID,
+				"snapshot":   snap.ID,
+				"node":       name,
+			},
+		})
+		if err != nil {
+			e.Type, e.Err = StepFailed, err
+			p.report(e)
+			p.deleteSnapshots(ctx, &snap)
+			return nil, fmt.Errorf("failed to snapshot %s: %w", name, err)
+		}
+		e.Type = StepCompleted
+		p.report(e)
+		snap.Nodes = append(snap.Nodes, storage.NodeSnapshot{Name: name, NodeID: node.ID, SnapshotID: s.ID, SizeGB: s.SizeGB})
+	}
+
+	// Re-read the forest, which may have changed while the snapshots ran
+	if f, err = p.storage.GetForest(forestID); err == nil {
+		f.Snapshots = append(f.Snapshots, snap)
+		err = p.storage.UpdateForest(f)
+	}
+	if err != nil {
+		p.deleteSnapshots(ctx, &snap)
+		return nil, fmt.Errorf("failed to record snapshot: %w", err)
+	}
+	p.report(Event{Type: StepCompleted, Step: StepSnapshot, Message: fmt.Sprintf("Snapshot %s recorded", snap.ID)})
+	return &snap, nil
+}
+
+// newSnapshotID returns an ID for a snapshot of f taken at t, unique among
+// the forest's snapshots
+func newSnapshotID(f *storage.Forest, t time.Time) string {
+	base := t.UTC().Format("20060102-150405")
+	id := base
+	for n := 2; f.FindSnapshot(id) != nil; n++ {
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	return id
+}
+
+// DeleteSnapshot deletes a recorded snapshot of a forest at the provider
+// and removes it from the forest
+func (p *Provisioner) DeleteSnapshot(ctx context.Context, forestID, snapshotID string) error {
+	if _, ok := p.machine.(machine.SnapshotManager); !ok {
+		return fmt.Errorf("machine provider %s does not support snapshots", p.config.GetMachineProvider())
+	}
+	f, err := p.storage.GetForest(forestID)
+	if err != nil {
+		return err
+	}
+	snap := f.FindSnapshot(snapshotID)
+	if snapshotID == "" || snap == nil {
+		return fmt.Errorf("forest %s has no snapshot %q", forestID, snapshotID)
+	}
+
+	p.report(Event{Type: StepStarted, Step: StepSnapshot, Message: "Deleting snapshot " + snapshotID})
+	p.deleteSnapshots(ctx, snap)
+
+	kept := f.Snapshots[:0]
+	for _, s := range f.Snapshots {
+		if s.ID != snapshotID {
+			kept = append(kept, s)
+		}
+	}
+	f.Snapshots = kept
+	if err := p.storage.UpdateForest(f); err != nil {
+		return fmt.Errorf("failed to update forest: %w", err)
+	}
+	return nil
+}
+
+// deleteSnapshots deletes the provider snapshots of a snapshot set
+func (p *Provisioner) deleteSnapshots(ctx context.Context, snap *storage.ForestSnapshot) {
+	sm := p.machine.(machine.SnapshotManager)
+	for i, n := range snap.Nodes {
+		e := p.deleting(n.Name, i+1, len(snap.Nodes), "Deleting snapshot of %s", n.Name)
+		p.deleted(e, sm.DeleteSnapshot(ctx, n.SnapshotID))
+	}
+}
+
+// deleteForestSnapshots deletes all snapshots of a forest, found by their
+// labels, so those no longer recorded are removed too
+func (p *Provisioner) deleteForestSnapshots(ctx context.Context, forestID string) {
+	sm, ok := p.machine.(machine.SnapshotManager)
+	if !ok {
+		return
+	}
+	snapshots, err := sm.ListSnapshots(ctx, map[string]string{
+		"managed-by": "morpheus",
+		"forest-id":  forestID,
+	})
+	if err != nil {
+		p.warn(0, "failed to list snapshots: %s", err)
+		return
+	}
+	if len(snapshots) == 0 {
+		return
+	}
+
+	p.info(0, "Deleting %d snapshot%s...", len(snapshots), plural(len(snapshots)))
+	for i, s := range snapshots {
+		e := p.deleting("", i+1, len(snapshots), "Deleting %s", s.Description)
+		p.deleted(e, sm.DeleteSnapshot(ctx, s.ID))
+	}
+}
+
+// RestoreOptions controls what Restore rebuilds
+type RestoreOptions struct {
+	// SnapshotID selects the snapshot to restore (default: the latest)
+	SnapshotID string
+
+	// Into is the ID of a new forest to restore into, leaving the forest
+	// as it is. By default the forest's servers are replaced.
+	Into string
+
+	// OverrideBudget restores even if the restored forest's expected spend
+	// exceeds limits.max_monthly_cost
+	OverrideBudget bool
+}
+
+// Restore rebuilds a forest from one of its snapshots into new servers,
+// created with the forest's recorded plant request, so they get the same
+// role, DNS records, load balancer and floating IP as the original nodes.
+//
+// In place, the forest's current servers are torn down first, since the
+// new ones take their names. Its snapshots are kept, even if provisioning
+// fails, so the restore can be retried.
+//
+// Callers hold the per-forest lock of the forest and, with Into, of the
+// new forest.
+func (p *Provisioner) Restore(ctx context.Context, forestID string, opts RestoreOptions) error {
+	if _, ok := p.machine.(machine.SnapshotManager); !ok {
+		return fmt.Errorf("machine provider %s does not support snapshots", p.config.GetMachineProvider())
+	}
+	f, err := p.storage.GetForest(forestID)
+	if err != nil {
+		return err
+	}
+	snap := f.FindSnapshot(opts.SnapshotID)
+	if snap == nil {
+		if opts.SnapshotID != "" {
+			return fmt.Errorf("forest %s has no snapshot %q", forestID, opts.SnapshotID)
+		}
+		return fmt.Errorf("forest %s has no snapshots", forestID)
+	}
+	if len(f.Request) == 0 {
+		return fmt.Errorf("forest %s has no recorded plant request", forestID)
+	}
+	var req ProvisionRequest
+	if err := json.Unmarshal(f.Request, &req); err != nil {
+		return fmt.Errorf("invalid plant request of forest %s: %w", forestID, err)
+	}
+
+	req.ForestID = forestID
+	if opts.Into != "" {
+		req.ForestID = opts.Into
+	}
+	req.NodeCount = len(snap.Nodes)
+	req.NodeImages = nil
+	for _, n := range snap.Nodes {
+		req.NodeImages = append(req.NodeImages, n.SnapshotID)
+	}
+	// Snapshots can only be restored in the location they were taken in
+	if snap.Location != "" {
+		req.Location = snap.Location
+	}
+	req.OverrideBudget = opts.OverrideBudget
+
+	if req.ForestID != forestID {
+		if _, err := p.storage.GetForest(req.ForestID); err == nil {
+			return fmt.Errorf("forest %s already exists", req.ForestID)
+		}
+		return p.Provision(ctx, req)
+	}
+
+	// The budget is checked before the forest is torn down, counting the
+	// restored forest in place of the current one
+	if err := p.checkBudget(provisionCost(req, req.NodeCount), forestID, req.OverrideBudget); err != nil {
+		return err
+	}
+	req.budgetChecked = true
+
+	if err := p.TeardownWithOptions(ctx, forestID, TeardownOptions{KeepSnapshots: true}); err != nil {
+		return err
+	}
+	err = p.Provision(ctx, req)
+	p.keepSnapshots(f, req.Location)
+	return err
+}
+
+// keepSnapshots records the snapshots of f with the forest restored in its
+// place, or with a placeholder if the restore was rolled back
+func (p *Provisioner) keepSnapshots(f *storage.Forest, location string) {
+	restored, err := p.storage.GetForest(f.ID)
+	if err != nil {
+		restored = &storage.Forest{
+			ID:       f.ID,
+			Provider: f.Provider,
+			Project:  f.Project,
+			Customer: f.Customer,
+			Location: location,
+			Status:   StatusRestoreFailed,
+			Request:  f.Request,
+		}
+		if err := p.storage.RegisterForest(restored); err != nil {
+			p.warn(0, "failed to keep the snapshots of %s: %s", f.ID, err)
+			return
+		}
+		p.info(0, "💡 Retry with: morpheus restore %s", f.ID)
+	}
+	restored.Snapshots = f.Snapshots
+	if err := p.storage.UpdateForest(restored); err != nil {
+		p.warn(0, "failed to keep the snapshots of %s: %s", f.ID, err)
+	}
+}
@@ -0,0 +1,86 @@
+package forest
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSnapshotAndRestore(t *testing.T) {
+	p, prov, st := newScaleTestProvisioner(t, 0)
+	ctx := context.Background()
+
+	if err := p.Provision(ctx, ProvisionRequest{ForestID: "app", NodeCount: 2, Location: "fsn1", Role: "web"}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+
+	snap, err := p.Snapshot(ctx, "app")
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+	if len(snap.Nodes) != 2 || len(prov.snaps) != 2 || snap.SizeGB() != 3 {
+		t.Fatalf("Snapshot() = %+v, %d provider snapshots", snap, len(prov.snaps))
+	}
+	if s := prov.snaps[snap.Nodes[1].SnapshotID]; s.Labels["forest-id"] != "app" || s.Labels["node"] != "app-node-2" {
+		t.Errorf("snapshot labels = %v", s.Labels)
+	}
+
+	// A copy leaves the forest alone
+	if err := p.Restore(ctx, "app", RestoreOptions{Into: "app-copy"}); err != nil {
+		t.Fatalf("Restore(Into) error = %v", err)
+	}
+	copies, _ := prov.ListServers(ctx, map[string]string{"forest-id": "app-copy"})
+	originals, _ := prov.ListServers(ctx, map[string]string{"forest-id": "app"})
+	if len(copies) != 2 || len(originals) != 2 {
+		t.Fatalf("after copy: %d copies, %d originals", len(copies), len(originals))
+	}
+	for _, s := range copies {
+		want := snap.Nodes[0].SnapshotID
+		if s.Name == "app-copy-node-2" {
+			want = snap.Nodes[1].SnapshotID
+		}
+		if prov.images[s.ID] != want {
+			t.Errorf("%s created from %q, want %q", s.Name, prov.images[s.ID], want)
+		}
+	}
+	if err := p.Restore(ctx, "app", RestoreOptions{Into: "app-copy"}); err == nil {
+		t.Error("Restore() into an existing forest succeeded")
+	}
+
+	// In place, the servers are replaced and the snapshots kept
+	if err := p.Restore(ctx, "app", RestoreOptions{SnapshotID: snap.ID}); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	nodes, _ := st.GetNodes("app")
+	if len(nodes) != 2 || prov.images[nodes[0].ID] != snap.Nodes[0].SnapshotID {
+		t.Fatalf("restored nodes = %+v", nodes)
+	}
+	f, _ := st.GetForest("app")
+	if f.Status != StatusActive || f.FindSnapshot("") == nil || len(prov.snaps) != 2 {
+		t.Errorf("restored forest = %+v, %d provider snapshots", f, len(prov.snaps))
+	}
+
+	if err := p.DeleteSnapshot(ctx, "app", snap.ID); err != nil {
+		t.Fatalf("DeleteSnapshot() error = %v", err)
+	}
+	if f, _ := st.GetForest("app"); len(f.Snapshots) != 0 || len(prov.snaps) != 0 {
+		t.Errorf("after DeleteSnapshot: %d recorded, %d provider snapshots", len(f.Snapshots), len(prov.snaps))
+	}
+}
+
+func TestTeardownDeletesSnapshots(t *testing.T) {
+	p, prov, _ := newScaleTestProvisioner(t, 0)
+	ctx := context.Background()
+
+	if err := p.Provision(ctx, ProvisionRequest{ForestID: "app", NodeCount: 1, Location: "fsn1"}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+	if _, err := p.Snapshot(ctx, "app"); err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+	if err := p.Teardown(ctx, "app"); err != nil {
+		t.Fatalf("Teardown() error = %v", err)
+	}
+	if len(prov.snaps) != 0 {
+		t.Errorf("Expected snapshots to be deleted, %d remain", len(prov.snaps))
+	}
+}
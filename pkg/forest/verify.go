@@ -0,0 +1,81 @@
+package forest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+	"github.com/nimsforest/morpheus/pkg/verify"
+)
+
+// Forest statuses set by verification
+const (
+	StatusActive   = "active"
+	StatusDegraded = "degraded"
+)
+
+// verifyChecks returns the configured verification checks followed by
+// those of the request
+func (p *Provisioner) verifyChecks(extra []config.VerifyCheck) []config.VerifyCheck {
+	return append(append([]config.VerifyCheck(nil), p.config.Provisioning.Verify...), extra...)
+}
+
+// verifyForest runs checks against every node of a forest and returns the
+// status the forest is marked with: active, or degraded if a check failed
+func (p *Provisioner) verifyForest(ctx context.Context, forestID string, checks []config.VerifyCheck) string {
+	nodes, err := p.storage.GetNodes(forestID)
+	if err != nil {
+		p.warn(1, "failed to get nodes: %s", err)
+		return StatusDegraded
+	}
+	names := p.names(forestID)
+	var targets []sshutil.Target
+	for i, node := range nodes {
+		targets = append(targets, sshutil.Target{
+			Name:    names.Node(i),
+			Addr:    node.IP,
+			HostKey: node.HostKey,
+		})
+	}
+
+	results := verify.Run(ctx, checks, targets, verify.Options{
+		ExecOptions: sshutil.ExecOptions{IdentityFile: p.sshIdentity(), SSHBinary: p.sshBinary},
+		Interval:    p.verifyInterval,
+	})
+
+	status := StatusActive
+	for _, check := range checks {
+		name := verify.Name(check)
+		passed := 0
+		var failed []verify.Result
+		for _, r := range results {
+			if r.Check != name {
+				continue
+			}
+			if r.Passed {
+				passed++
+			} else {
+				failed = append(failed, r)
+			}
+		}
+		if len(failed) == 0 {
+			p.report(Event{Type: StepCompleted, Step: StepVerify, Level: 1, Message: fmt.Sprintf("%s (%d/%d nodes)", name, passed, len(targets))})
+			continue
+		}
+		status = StatusDegraded
+		p.report(Event{Type: StepFailed, Step: StepVerify, Level: 1, Message: fmt.Sprintf("%s (%d/%d nodes)", name, passed, len(targets))})
+		for _, r := range failed {
+			p.info(2, "%s: %s", r.Node, r.Detail)
+		}
+	}
+	return status
+}
+
+// sshIdentity returns the private key post-provision steps connect with
+func (p *Provisioner) sshIdentity() string {
+	if identity := sshutil.GetSSHPrivateKeyForPublicKey(p.config.GetSSHKeyPath()); identity != "" {
+		return identity
+	}
+	return sshutil.DetectSSHPrivateKeyPath()
+}
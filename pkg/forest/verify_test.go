@@ -0,0 +1,50 @@
+package forest
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/config"
+)
+
+func TestProvisionVerifiesForest(t *testing.T) {
+	p, _, st := newScaleTestProvisioner(t, 0)
+	p.verifyInterval = 10 * time.Millisecond
+	ctx := context.Background()
+
+	// The test SSH listener is the only open port on the nodes
+	p.config.Provisioning.Verify = []config.VerifyCheck{{Name: "ssh", TCP: p.config.Provisioning.SSHPort}}
+	if err := p.Provision(ctx, ProvisionRequest{ForestID: "good", NodeCount: 2, Location: "fsn1"}); err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+	if f, _ := st.GetForest("good"); f.Status != StatusActive {
+		t.Errorf("forest status = %s, want %s", f.Status, StatusActive)
+	}
+
+	closed, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	// A failed check keeps the forest, marked degraded
+	err = p.Provision(ctx, ProvisionRequest{
+		ForestID:  "bad",
+		NodeCount: 1,
+		Location:  "fsn1",
+		Verify:    []config.VerifyCheck{{Name: "app", TCP: port, Timeout: "50ms"}},
+	})
+	if err != nil {
+		t.Fatalf("Provision() error = %v", err)
+	}
+	f, _ := st.GetForest("bad")
+	if f.Status != StatusDegraded {
+		t.Errorf("forest status = %s, want %s", f.Status, StatusDegraded)
+	}
+	if nodes, _ := st.GetNodes("bad"); len(nodes) != 1 {
+		t.Errorf("degraded forest has %d nodes, want 1", len(nodes))
+	}
+}
@@ -0,0 +1,448 @@
+package aws
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/guard"
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+// canonicalOwner is the AWS account Ubuntu images are published from
+const canonicalOwner = "099720109477"
+
+// Provider implements guard.GuardProvider for AWS. A guard is a VPC with
+// one subnet, an internet gateway, a security group, an Elastic IP and
+// an elastic network interface with the source/destination check
+// disabled, attached to an EC2 instance; all of them are tagged with the
+// guard ID. Workload VPCs are peered with the guard's VPC.
+type Provider struct {
+	region       string
+	instanceType string
+	image        string
+	c            *client
+}
+
+// Ensure Provider satisfies guard.GuardProvider
+var _ guard.GuardProvider = (*Provider)(nil)
+
+// NewProvider creates a new AWS guard provider for a region.
+func NewProvider(region, instanceType, image string, creds Credentials) (*Provider, error) {
+	return NewProviderWithEndpoint(fmt.Sprintf("https://ec2.%s.amazonaws.com", region), region, instanceType, image, creds)
+}
+
+// NewProviderWithEndpoint creates an AWS guard provider using another EC2
+// API endpoint (for testing).
+func NewProviderWithEndpoint(endpoint, region, instanceType, image string, creds Credentials) (*Provider, error) {
+	if region == "" {
+		return nil, fmt.Errorf("an AWS region is required (machine.aws.region or the profile's region)")
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("AWS access keys are required")
+	}
+	return &Provider{
+		region:       region,
+		instanceType: instanceType,
+		image:        image,
+		c: &client{
+			endpoint:   strings.TrimSuffix(endpoint, "/"),
+			region:     region,
+			creds:      creds,
+			httpClient: &http.Client{Timeout: time.Minute},
+		},
+	}, nil
+}
+
+// zoneOf returns the availability zone a location names, or "" if it
+// names the provider's region. Other regions are an error, as the EC2
+// API is regional.
+func (p *Provider) zoneOf(location string) (string, error) {
+	if location == "" || location == p.region {
+		return "", nil
+	}
+	if strings.HasPrefix(location, p.region) && len(location) == len(p.region)+1 {
+		return location, nil
+	}
+	return "", fmt.Errorf("location %s is not in region %s (machine.aws.region)", location, p.region)
+}
+
+// CreateServer creates the guard instance. The network interface must
+// already exist (see EnsureNetwork); it is found by the guard-id label.
+// EC2 instances take a single key pair, so only the first SSH key is
+// used.
+func (p *Provider) CreateServer(ctx context.Context, req machine.CreateServerRequest) (*machine.Server, error) {
+	guardID := req.Labels[TagGuardID]
+	if guardID == "" {
+		return nil, fmt.Errorf("guard-id label is required for AWS instance creation")
+	}
+	names := newResourceNames(guardID)
+	if _, err := p.zoneOf(req.Location); err != nil {
+		return nil, err
+	}
+	instanceType := req.ServerType
+	if instanceType == "" {
+		instanceType = p.instanceType
+	}
+	image := req.Image
+	if image == "" {
+		image = p.image
+	}
+	imageID, err := p.resolveImage(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+
+	nic, err := findTagged[networkInterface](ctx, p.c, "DescribeNetworkInterfaces", "networkInterfaceSet", guardID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network interface: %w", err)
+	}
+	if nic == nil {
+		return nil, fmt.Errorf("network interface %s not found", names.NIC)
+	}
+
+	tags := map[string]string{TagName: req.Name}
+	for k, v := range req.Labels {
+		if v != "" && !providerLabels[k] {
+			tags[k] = v
+		}
+	}
+	tags[TagManagedBy] = TagManagedByValue
+
+	v := url.Values{}
+	v.Set("ImageId", imageID)
+	v.Set("InstanceType", instanceType)
+	v.Set("MinCount", "1")
+	v.Set("MaxCount", "1")
+	v.Set("UserData", base64.StdEncoding.EncodeToString([]byte(req.UserData)))
+	v.Set("NetworkInterface.1.NetworkInterfaceId", nic.NetworkInterfaceID)
+	v.Set("NetworkInterface.1.DeviceIndex", "0")
+	if len(req.SSHKeys) > 0 {
+		if err := p.importKeyPair(ctx, guardID, req.SSHKeys[0]); err != nil {
+			return nil, err
+		}
+		v.Set("KeyName", names.KeyPair)
+	}
+	addTags(v, tags, "instance", "volume")
+
+	var resp struct {
+		Instances []instance `xml:"instancesSet>item"`
+	}
+	if err := p.c.call(ctx, "RunInstances", v, &resp); err != nil {
+		return nil, fmt.Errorf("failed to create instance: %w", err)
+	}
+	if len(resp.Instances) == 0 {
+		return nil, fmt.Errorf("failed to create instance: no instance returned")
+	}
+
+	server := instanceToServer(&resp.Instances[0])
+	server.State = machine.ServerStateStarting
+	return server, nil
+}
+
+// resolveImage returns the AMI ID of image: an AMI ID, or a name pattern
+// of Canonical's Ubuntu images, of which the newest is used
+func (p *Provider) resolveImage(ctx context.Context, name string) (string, error) {
+	if strings.HasPrefix(name, "ami-") {
+		return name, nil
+	}
+	v := filterParams(filter{Name: "name", Values: []string{name}}, filter{Name: "state", Values: []string{"available"}})
+	v.Set("Owner.1", canonicalOwner)
+	images, err := describe[image](ctx, p.c, "DescribeImages", "imagesSet", v)
+	if err != nil {
+		return "", fmt.Errorf("failed to find image %s: %w", name, err)
+	}
+	var newest *image
+	for i := range images {
+		if newest == nil || images[i].CreationDate > newest.CreationDate {
+			newest = &images[i]
+		}
+	}
+	if newest == nil {
+		return "", fmt.Errorf("no Ubuntu image matches %s in %s", name, p.region)
+	}
+	return newest.ImageID, nil
+}
+
+// importKeyPair imports the guard's SSH key, unless it exists
+func (p *Provider) importKeyPair(ctx context.Context, guardID, publicKey string) error {
+	names := newResourceNames(guardID)
+	v := url.Values{}
+	v.Set("KeyName", names.KeyPair)
+	v.Set("PublicKeyMaterial", base64.StdEncoding.EncodeToString([]byte(publicKey)))
+	addTags(v, guardTags(guardID, names.KeyPair), "key-pair")
+	if err := p.c.call(ctx, "ImportKeyPair", v, nil); err != nil && !isDuplicate(err) {
+		return fmt.Errorf("failed to import SSH key: %w", err)
+	}
+	return nil
+}
+
+// GetServer retrieves server information by instance ID.
+func (p *Provider) GetServer(ctx context.Context, serverID string) (*machine.Server, error) {
+	v := url.Values{}
+	v.Set("InstanceId.1", serverID)
+	instances, err := p.describeInstances(ctx, v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance: %w", err)
+	}
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("instance %s not found", serverID)
+	}
+	return instanceToServer(&instances[0]), nil
+}
+
+// DeleteServer terminates an instance. Its network interface and Elastic
+// IP are kept, so a new instance can take them over.
+func (p *Provider) DeleteServer(ctx context.Context, serverID string) error {
+	v := url.Values{}
+	v.Set("InstanceId.1", serverID)
+	if err := p.c.call(ctx, "TerminateInstances", v, nil); err != nil {
+		return fmt.Errorf("failed to terminate instance: %w", err)
+	}
+	return nil
+}
+
+// WaitForServer waits until the server is in the specified state.
+func (p *Provider) WaitForServer(ctx context.Context, serverID string, state machine.ServerState) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		server, err := p.GetServer(ctx, serverID)
+		if err != nil {
+			return err
+		}
+
+		if server.State == state {
+			return nil
+		}
+
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// ListServers lists the guard instances of the region with optional
+// filters on their tags.
+func (p *Provider) ListServers(ctx context.Context, filters map[string]string) ([]*machine.Server, error) {
+	instances, err := p.guardInstances(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var servers []*machine.Server
+	for i := range instances {
+		server := instanceToServer(&instances[i])
+		match := true
+		for k, v := range filters {
+			if server.Labels[k] != v {
+				match = false
+				break
+			}
+		}
+		if match {
+			servers = append(servers, server)
+		}
+	}
+	return servers, nil
+}
+
+// describeInstances lists instances, flattening their reservations
+func (p *Provider) describeInstances(ctx context.Context, v url.Values) ([]instance, error) {
+	reservations, err := describe[reservation](ctx, p.c, "DescribeInstances", "reservationSet", v)
+	if err != nil {
+		return nil, err
+	}
+	var instances []instance
+	for _, r := range reservations {
+		instances = append(instances, r.Instances...)
+	}
+	return instances, nil
+}
+
+// guardInstances lists the instances tagged managed-by=morpheus-awsguard
+// that are not terminated, of one guard or of all if guardID is empty
+func (p *Provider) guardInstances(ctx context.Context, guardID string) ([]instance, error) {
+	filters := []filter{
+		tagFilter(TagManagedBy, TagManagedByValue),
+		{Name: "instance-state-name", Values: []string{"pending", "running", "stopping", "stopped", "shutting-down"}},
+	}
+	if guardID != "" {
+		filters = append(filters, tagFilter(TagGuardID, guardID))
+	}
+	instances, err := p.describeInstances(ctx, filterParams(filters...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+	return instances, nil
+}
+
+// instanceToServer converts an instance; its tags become the server's
+// labels
+func instanceToServer(inst *instance) *machine.Server {
+	labels := tagMap(inst.Tags)
+	name := labels[TagName]
+	delete(labels, TagName)
+
+	state := machine.ServerStateUnknown
+	switch inst.State.Name {
+	case "running":
+		state = machine.ServerStateRunning
+	case "pending":
+		state = machine.ServerStateStarting
+	case "stopping", "stopped":
+		state = machine.ServerStateStopped
+	case "shutting-down", "terminated":
+		state = machine.ServerStateDeleting
+	}
+
+	return &machine.Server{
+		ID:         inst.InstanceID,
+		Name:       name,
+		PublicIPv4: inst.PublicIPAddress,
+		Location:   inst.Placement.AvailabilityZone,
+		State:      state,
+		Labels:     labels,
+		CreatedAt:  inst.LaunchTime,
+		ServerType: inst.InstanceType,
+		Image:      inst.ImageID,
+	}
+}
+
+// GetGuard reconstructs guard info from the guard's AWS resources.
+func (p *Provider) GetGuard(ctx context.Context, guardID string) (*guard.Guard, error) {
+	v, err := findTagged[vpc](ctx, p.c, "DescribeVpcs", "vpcSet", guardID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VPC: %w", err)
+	}
+	if v == nil {
+		return nil, fmt.Errorf("guard not found: no VPC tagged %s=%s", TagGuardID, guardID)
+	}
+	instances, err := p.guardInstances(ctx, guardID)
+	if err != nil {
+		return nil, err
+	}
+	var inst *instance
+	if len(instances) > 0 {
+		inst = &instances[0]
+	}
+	g := p.guardFromVPC(v, inst)
+
+	if s, err := findTagged[subnet](ctx, p.c, "DescribeSubnets", "subnetSet", guardID); err == nil && s != nil {
+		g.SubnetID = s.SubnetID
+		if g.Location == "" {
+			g.Location = s.AvailabilityZone
+		}
+	}
+	if sg, err := findTagged[securityGroup](ctx, p.c, "DescribeSecurityGroups", "securityGroupInfo", guardID); err == nil && sg != nil {
+		g.NSGID = sg.GroupID
+	}
+	if nic, err := findTagged[networkInterface](ctx, p.c, "DescribeNetworkInterfaces", "networkInterfaceSet", guardID); err == nil && nic != nil {
+		g.NICID = nic.NetworkInterfaceID
+		g.PrivateIP = nic.PrivateIPAddress
+	}
+	if addr, err := findTagged[address](ctx, p.c, "DescribeAddresses", "addressesSet", guardID); err == nil && addr != nil {
+		g.PublicIPID = addr.AllocationID
+		g.PublicIP = addr.PublicIP
+	}
+
+	peerings, err := p.guardPeerings(ctx, guardID)
+	if err != nil {
+		return nil, err
+	}
+	for _, pcx := range peerings {
+		g.Peerings = append(g.Peerings, guard.PeeringInfo{
+			Name:         tagValue(pcx.Tags, TagName),
+			RemoteVNetID: pcx.AccepterVpcInfo.VpcID,
+		})
+	}
+	return g, nil
+}
+
+// ListGuards discovers all guards from the VPCs tagged
+// managed-by=morpheus-awsguard.
+func (p *Provider) ListGuards(ctx context.Context) ([]*guard.Guard, error) {
+	vpcs, err := describe[vpc](ctx, p.c, "DescribeVpcs", "vpcSet", filterParams(tagFilter(TagManagedBy, TagManagedByValue)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VPCs: %w", err)
+	}
+	instances, err := p.guardInstances(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := describe[address](ctx, p.c, "DescribeAddresses", "addressesSet", filterParams(tagFilter(TagManagedBy, TagManagedByValue)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Elastic IPs: %w", err)
+	}
+
+	var guards []*guard.Guard
+	for i := range vpcs {
+		guardID := tagValue(vpcs[i].Tags, TagGuardID)
+		if guardID == "" {
+			continue
+		}
+		var inst *instance
+		for j := range instances {
+			if tagValue(instances[j].Tags, TagGuardID) == guardID {
+				inst = &instances[j]
+			}
+		}
+		g := p.guardFromVPC(&vpcs[i], inst)
+		for _, addr := range addresses {
+			if tagValue(addr.Tags, TagGuardID) == guardID {
+				g.PublicIPID = addr.AllocationID
+				g.PublicIP = addr.PublicIP
+			}
+		}
+		guards = append(guards, g)
+	}
+	return guards, nil
+}
+
+// guardFromVPC builds a guard from its VPC and instance (nil if the
+// instance does not exist)
+func (p *Provider) guardFromVPC(v *vpc, inst *instance) *guard.Guard {
+	tags := tagMap(v.Tags)
+	g := &guard.Guard{
+		ID:            tags[TagGuardID],
+		Provider:      "aws",
+		Status:        "unknown",
+		VNetID:        v.VpcID,
+		ResourceGroup: p.region,
+		Group:         tags[TagGroup],
+	}
+	if port, err := strconv.Atoi(tags[TagWGPort]); err == nil {
+		g.WireGuardPort = port
+	}
+	if inst == nil {
+		return g
+	}
+
+	server := instanceToServer(inst)
+	g.ServerID = server.ID
+	g.Location = server.Location
+	g.Status = inst.State.Name
+	g.PublicIP = server.PublicIPv4
+	g.PrivateIP = inst.PrivateIPAddress
+	if t, err := time.Parse(time.RFC3339, inst.LaunchTime); err == nil {
+		g.CreatedAt = t
+	}
+	g.PublicKey = server.Labels[TagWGPublicKey]
+	if group := server.Labels[TagGroup]; group != "" {
+		g.Group = group
+	}
+	if cidrs := server.Labels[TagMeshCIDRs]; cidrs != "" {
+		g.MeshCIDRs = strings.Split(cidrs, ",")
+	}
+	if port, err := strconv.Atoi(server.Labels[TagWGPort]); err == nil {
+		g.WireGuardPort = port
+	}
+	return g
+}
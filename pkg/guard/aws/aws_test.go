@@ -0,0 +1,584 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/guard"
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+// fakeEC2 is an in-memory EC2 Query API for the actions the provider uses
+type fakeEC2 struct {
+	mu        sync.Mutex
+	next      int
+	vpcs      []*vpc
+	subnets   []*subnet
+	gateways  []*internetGateway
+	groups    []*securityGroup
+	rules     map[string][]string // Security group ID -> rules
+	addresses []*address
+	nics      []*networkInterface
+	instances []*instance
+	userData  map[string]string // Instance ID -> user data
+	tables    []*routeTable
+	peerings  []*vpcPeeringConnection
+	keys      map[string]string // Key name -> public key
+}
+
+func newFakeEC2(t *testing.T) (*fakeEC2, *httptest.Server) {
+	f := &fakeEC2{rules: make(map[string][]string), userData: make(map[string]string), keys: make(map[string]string)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeEC2) id(prefix string) string {
+	f.next++
+	return fmt.Sprintf("%s-%04d", prefix, f.next)
+}
+
+// addVPC creates a VPC with its main route table
+func (f *fakeEC2) addVPC(cidr string, tags []tag) *vpc {
+	v := &vpc{VpcID: f.id("vpc"), CidrBlock: cidr, State: "available", Tags: tags}
+	f.vpcs = append(f.vpcs, v)
+	f.tables = append(f.tables, &routeTable{
+		RouteTableID: f.id("rtb"),
+		VpcID:        v.VpcID,
+		Routes:       []route{{DestinationCidrBlock: cidr, GatewayID: "local", State: "active"}},
+		Associations: []routeTableAssociation{{Main: true}},
+	})
+	return v
+}
+
+// items wraps a slice as the items of a response set
+type items struct {
+	Item any `xml:"item"`
+}
+
+type fakeError struct {
+	status int
+	code   string
+}
+
+func (f *fakeEC2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDTEST/") {
+		writeError(w, fakeError{http.StatusUnauthorized, "AuthFailure"})
+		return
+	}
+	r.ParseForm()
+	form := r.PostForm
+	action := form.Get("Action")
+	fields, ferr := f.handle(action, form)
+	if ferr != nil {
+		writeError(w, *ferr)
+		return
+	}
+
+	var buf bytes.Buffer
+	enc := xml.NewEncoder(&buf)
+	start := xml.StartElement{Name: xml.Name{Local: action + "Response"}}
+	enc.EncodeToken(start)
+	for i := 0; i < len(fields); i += 2 {
+		enc.EncodeElement(fields[i+1], xml.StartElement{Name: xml.Name{Local: fields[i].(string)}})
+	}
+	enc.EncodeToken(start.End())
+	enc.Flush()
+	w.Write(buf.Bytes())
+}
+
+func writeError(w http.ResponseWriter, e fakeError) {
+	w.WriteHeader(e.status)
+	fmt.Fprintf(w, "<Response><Errors><Error><Code>%s</Code><Message>fake</Message></Error></Errors></Response>", e.code)
+}
+
+func notFound(code string) *fakeError {
+	return &fakeError{http.StatusBadRequest, code}
+}
+
+// handle runs an action and returns the response fields as name, value
+// pairs
+func (f *fakeEC2) handle(action string, form url.Values) ([]any, *fakeError) {
+	filters := parseFilters(form)
+	tags := parseTags(form)
+
+	switch action {
+	case "CreateVpc":
+		v := f.addVPC(form.Get("CidrBlock"), tags)
+		return []any{"vpc", v}, nil
+	case "DescribeVpcs":
+		var found []*vpc
+		for _, v := range f.vpcs {
+			if (form.Get("VpcId.1") == "" || form.Get("VpcId.1") == v.VpcID) && matches(filters, v.Tags, nil) {
+				found = append(found, v)
+			}
+		}
+		if form.Get("VpcId.1") != "" && len(found) == 0 {
+			return nil, notFound("InvalidVpcID.NotFound")
+		}
+		return []any{"vpcSet", items{found}}, nil
+	case "DeleteVpc":
+		f.vpcs = slices.DeleteFunc(f.vpcs, func(v *vpc) bool { return v.VpcID == form.Get("VpcId") })
+		f.tables = slices.DeleteFunc(f.tables, func(t *routeTable) bool { return t.VpcID == form.Get("VpcId") })
+
+	case "CreateSubnet":
+		s := &subnet{SubnetID: f.id("subnet"), VpcID: form.Get("VpcId"), CidrBlock: form.Get("CidrBlock"), AvailabilityZone: "eu-west-1a", Tags: tags}
+		f.subnets = append(f.subnets, s)
+		return []any{"subnet", s}, nil
+	case "DescribeSubnets":
+		var found []*subnet
+		for _, s := range f.subnets {
+			if matches(filters, s.Tags, map[string]string{"vpc-id": s.VpcID}) {
+				found = append(found, s)
+			}
+		}
+		return []any{"subnetSet", items{found}}, nil
+	case "DeleteSubnet":
+		f.subnets = slices.DeleteFunc(f.subnets, func(s *subnet) bool { return s.SubnetID == form.Get("SubnetId") })
+
+	case "CreateInternetGateway":
+		igw := &internetGateway{InternetGatewayID: f.id("igw"), Tags: tags}
+		f.gateways = append(f.gateways, igw)
+		return []any{"internetGateway", igw}, nil
+	case "AttachInternetGateway", "DetachInternetGateway":
+		for _, igw := range f.gateways {
+			if igw.InternetGatewayID == form.Get("InternetGatewayId") {
+				igw.Attachments = nil
+				if action == "AttachInternetGateway" {
+					igw.Attachments = append(igw.Attachments, struct {
+						VpcID string `xml:"vpcId"`
+					}{form.Get("VpcId")})
+				}
+			}
+		}
+	case "DescribeInternetGateways":
+		var found []*internetGateway
+		for _, igw := range f.gateways {
+			if matches(filters, igw.Tags, nil) {
+				found = append(found, igw)
+			}
+		}
+		return []any{"internetGatewaySet", items{found}}, nil
+	case "DeleteInternetGateway":
+		f.gateways = slices.DeleteFunc(f.gateways, func(igw *internetGateway) bool { return igw.InternetGatewayID == form.Get("InternetGatewayId") })
+
+	case "CreateSecurityGroup":
+		sg := &securityGroup{GroupID: f.id("sg"), GroupName: form.Get("GroupName"), VpcID: form.Get("VpcId"), Tags: tags}
+		f.groups = append(f.groups, sg)
+		return []any{"groupId", sg.GroupID}, nil
+	case "DescribeSecurityGroups":
+		var found []*securityGroup
+		for _, sg := range f.groups {
+			if matches(filters, sg.Tags, nil) {
+				found = append(found, sg)
+			}
+		}
+		return []any{"securityGroupInfo", items{found}}, nil
+	case "AuthorizeSecurityGroupIngress", "AuthorizeSecurityGroupEgress", "RevokeSecurityGroupIngress":
+		id := form.Get("GroupId")
+		rule := strings.Join([]string{form.Get("IpPermissions.1.IpProtocol"), form.Get("IpPermissions.1.FromPort"),
+			form.Get("IpPermissions.1.ToPort"), form.Get("IpPermissions.1.IpRanges.1.CidrIp")}, " ")
+		i := slices.Index(f.rules[id], rule)
+		switch {
+		case strings.HasPrefix(action, "Authorize") && i >= 0:
+			return nil, &fakeError{http.StatusBadRequest, "InvalidPermission.Duplicate"}
+		case strings.HasPrefix(action, "Authorize"):
+			f.rules[id] = append(f.rules[id], rule)
+		case i < 0:
+			return nil, notFound("InvalidPermission.NotFound")
+		default:
+			f.rules[id] = slices.Delete(f.rules[id], i, i+1)
+		}
+	case "DeleteSecurityGroup":
+		f.groups = slices.DeleteFunc(f.groups, func(sg *securityGroup) bool { return sg.GroupID == form.Get("GroupId") })
+
+	case "CreateNetworkInterface":
+		var s *subnet
+		for _, candidate := range f.subnets {
+			if candidate.SubnetID == form.Get("SubnetId") {
+				s = candidate
+			}
+		}
+		prefix := s.CidrBlock[:strings.LastIndex(s.CidrBlock, ".")]
+		nic := &networkInterface{NetworkInterfaceID: f.id("eni"), SubnetID: s.SubnetID, VpcID: s.VpcID,
+			PrivateIPAddress: prefix + ".4", SourceDestCheck: true, Status: "available", Tags: tags}
+		f.nics = append(f.nics, nic)
+		return []any{"networkInterface", nic}, nil
+	case "ModifyNetworkInterfaceAttribute":
+		for _, nic := range f.nics {
+			if nic.NetworkInterfaceID == form.Get("NetworkInterfaceId") {
+				nic.SourceDestCheck = form.Get("SourceDestCheck.Value") == "true"
+			}
+		}
+	case "DescribeNetworkInterfaces":
+		var found []*networkInterface
+		for _, nic := range f.nics {
+			if form.Get("NetworkInterfaceId.1") != "" && !slices.Contains(indexed(form, "NetworkInterfaceId"), nic.NetworkInterfaceID) {
+				continue
+			}
+			if matches(filters, nic.Tags, nil) {
+				found = append(found, nic)
+			}
+		}
+		return []any{"networkInterfaceSet", items{found}}, nil
+	case "DeleteNetworkInterface":
+		f.nics = slices.DeleteFunc(f.nics, func(nic *networkInterface) bool { return nic.NetworkInterfaceID == form.Get("NetworkInterfaceId") })
+
+	case "AllocateAddress":
+		addr := &address{AllocationID: f.id("eipalloc"), PublicIP: "203.0.113.10", Tags: tags}
+		f.addresses = append(f.addresses, addr)
+		return []any{"publicIp", addr.PublicIP, "allocationId", addr.AllocationID}, nil
+	case "AssociateAddress":
+		for _, addr := range f.addresses {
+			if addr.AllocationID == form.Get("AllocationId") {
+				addr.NetworkInterfaceID = form.Get("NetworkInterfaceId")
+				addr.AssociationID = f.id("eipassoc")
+				return []any{"associationId", addr.AssociationID}, nil
+			}
+		}
+		return nil, notFound("InvalidAllocationID.NotFound")
+	case "DisassociateAddress":
+		for _, addr := range f.addresses {
+			if addr.AssociationID == form.Get("AssociationId") {
+				addr.AssociationID, addr.NetworkInterfaceID = "", ""
+			}
+		}
+	case "DescribeAddresses":
+		var found []*address
+		for _, addr := range f.addresses {
+			if matches(filters, addr.Tags, nil) {
+				found = append(found, addr)
+			}
+		}
+		return []any{"addressesSet", items{found}}, nil
+	case "ReleaseAddress":
+		f.addresses = slices.DeleteFunc(f.addresses, func(addr *address) bool { return addr.AllocationID == form.Get("AllocationId") })
+
+	case "ImportKeyPair":
+		if _, ok := f.keys[form.Get("KeyName")]; ok {
+			return nil, &fakeError{http.StatusBadRequest, "InvalidKeyPair.Duplicate"}
+		}
+		key, _ := base64.StdEncoding.DecodeString(form.Get("PublicKeyMaterial"))
+		f.keys[form.Get("KeyName")] = string(key)
+	case "DeleteKeyPair":
+		delete(f.keys, form.Get("KeyName"))
+
+	case "DescribeImages":
+		return []any{"imagesSet", items{[]image{
+			{ImageID: "ami-old", Name: "ubuntu-jammy-20240101", CreationDate: "2024-01-01T00:00:00.000Z"},
+			{ImageID: "ami-new", Name: "ubuntu-jammy-20250101", CreationDate: "2025-01-01T00:00:00.000Z"},
+		}}}, nil
+	case "RunInstances":
+		inst := &instance{InstanceID: f.id("i"), ImageID: form.Get("ImageId"), InstanceType: form.Get("InstanceType"),
+			LaunchTime: "2026-01-01T00:00:00Z", Tags: tags}
+		inst.State.Name = "running"
+		for _, nic := range f.nics {
+			if nic.NetworkInterfaceID == form.Get("NetworkInterface.1.NetworkInterfaceId") {
+				nic.Status = "in-use"
+				inst.SubnetID, inst.VpcID, inst.PrivateIPAddress = nic.SubnetID, nic.VpcID, nic.PrivateIPAddress
+				inst.Placement.AvailabilityZone = "eu-west-1a"
+			}
+		}
+		for _, addr := range f.addresses {
+			if addr.NetworkInterfaceID != "" && addr.NetworkInterfaceID == form.Get("NetworkInterface.1.NetworkInterfaceId") {
+				inst.PublicIPAddress = addr.PublicIP
+			}
+		}
+		userData, _ := base64.StdEncoding.DecodeString(form.Get("UserData"))
+		f.userData[inst.InstanceID] = string(userData)
+		f.instances = append(f.instances, inst)
+		return []any{"instancesSet", items{[]*instance{inst}}}, nil
+	case "DescribeInstances":
+		var found []*instance
+		for _, inst := range f.instances {
+			if form.Get("InstanceId.1") != "" && form.Get("InstanceId.1") != inst.InstanceID {
+				continue
+			}
+			if matches(filters, inst.Tags, map[string]string{"instance-state-name": inst.State.Name}) {
+				found = append(found, inst)
+			}
+		}
+		return []any{"reservationSet", items{[]reservation{{Instances: derefAll(found)}}}}, nil
+	case "TerminateInstances":
+		for _, inst := range f.instances {
+			if inst.InstanceID == form.Get("InstanceId.1") {
+				inst.State.Name = "terminated"
+			}
+		}
+
+	case "DescribeRouteTables":
+		var found []*routeTable
+		for _, t := range f.tables {
+			attrs := map[string]string{"vpc-id": t.VpcID, "route-table-id": t.RouteTableID}
+			for _, assoc := range t.Associations {
+				if assoc.Main {
+					attrs["association.main"] = "true"
+				}
+				if assoc.SubnetID != "" {
+					attrs["association.subnet-id"] = assoc.SubnetID
+				}
+			}
+			match := matches(filters, t.Tags, attrs)
+			if want := filters["route.vpc-peering-connection-id"]; len(want) > 0 {
+				match = match && slices.ContainsFunc(t.Routes, func(r route) bool { return slices.Contains(want, r.VpcPeeringConnectionID) })
+			}
+			if match {
+				found = append(found, t)
+			}
+		}
+		return []any{"routeTableSet", items{found}}, nil
+	case "CreateRoute", "ReplaceRoute", "DeleteRoute":
+		for _, t := range f.tables {
+			if t.RouteTableID != form.Get("RouteTableId") {
+				continue
+			}
+			i := slices.IndexFunc(t.Routes, func(r route) bool { return r.DestinationCidrBlock == form.Get("DestinationCidrBlock") })
+			r := route{DestinationCidrBlock: form.Get("DestinationCidrBlock"), GatewayID: form.Get("GatewayId"),
+				NetworkInterfaceID: form.Get("NetworkInterfaceId"), VpcPeeringConnectionID: form.Get("VpcPeeringConnectionId"), State: "active"}
+			switch {
+			case action == "CreateRoute" && i >= 0:
+				return nil, &fakeError{http.StatusBadRequest, "RouteAlreadyExists"}
+			case action == "CreateRoute":
+				t.Routes = append(t.Routes, r)
+			case i < 0:
+				return nil, notFound("InvalidRoute.NotFound")
+			case action == "ReplaceRoute":
+				t.Routes[i] = r
+			default:
+				t.Routes = slices.Delete(t.Routes, i, i+1)
+			}
+			return nil, nil
+		}
+		return nil, notFound("InvalidRouteTableID.NotFound")
+
+	case "CreateVpcPeeringConnection":
+		pcx := &vpcPeeringConnection{VpcPeeringConnectionID: f.id("pcx"), Tags: tags}
+		for _, v := range f.vpcs {
+			if v.VpcID == form.Get("VpcId") {
+				pcx.RequesterVpcInfo = vpcInfo{VpcID: v.VpcID, CidrBlock: v.CidrBlock}
+			}
+			if v.VpcID == form.Get("PeerVpcId") {
+				pcx.AccepterVpcInfo = vpcInfo{VpcID: v.VpcID, CidrBlock: v.CidrBlock}
+			}
+		}
+		pcx.Status.Code = "pending-acceptance"
+		f.peerings = append(f.peerings, pcx)
+		return []any{"vpcPeeringConnection", pcx}, nil
+	case "AcceptVpcPeeringConnection", "DeleteVpcPeeringConnection":
+		for _, pcx := range f.peerings {
+			if pcx.VpcPeeringConnectionID == form.Get("VpcPeeringConnectionId") {
+				pcx.Status.Code = map[string]string{"AcceptVpcPeeringConnection": "active", "DeleteVpcPeeringConnection": "deleted"}[action]
+			}
+		}
+	case "DescribeVpcPeeringConnections":
+		var found []*vpcPeeringConnection
+		for _, pcx := range f.peerings {
+			if matches(filters, pcx.Tags, map[string]string{"status-code": pcx.Status.Code}) {
+				found = append(found, pcx)
+			}
+		}
+		return []any{"vpcPeeringConnectionSet", items{found}}, nil
+
+	default:
+		return nil, &fakeError{http.StatusBadRequest, "InvalidAction"}
+	}
+	return []any{"return", true}, nil
+}
+
+func derefAll[T any](ptrs []*T) []T {
+	values := make([]T, len(ptrs))
+	for i, p := range ptrs {
+		values[i] = *p
+	}
+	return values
+}
+
+// parseFilters reads the Filter.N parameters of a request
+func parseFilters(form url.Values) map[string][]string {
+	filters := make(map[string][]string)
+	for i := 1; form.Get(fmt.Sprintf("Filter.%d.Name", i)) != ""; i++ {
+		name := form.Get(fmt.Sprintf("Filter.%d.Name", i))
+		filters[name] = indexed(form, fmt.Sprintf("Filter.%d.Value", i))
+	}
+	return filters
+}
+
+// parseTags reads the tags of the first TagSpecification of a request
+func parseTags(form url.Values) []tag {
+	var tags []tag
+	for i := 1; form.Get(fmt.Sprintf("TagSpecification.1.Tag.%d.Key", i)) != ""; i++ {
+		tags = append(tags, tag{
+			Key:   form.Get(fmt.Sprintf("TagSpecification.1.Tag.%d.Key", i)),
+			Value: form.Get(fmt.Sprintf("TagSpecification.1.Tag.%d.Value", i)),
+		})
+	}
+	return tags
+}
+
+// indexed returns the values of prefix.1, prefix.2, ...
+func indexed(form url.Values, prefix string) []string {
+	var values []string
+	for i := 1; form.Get(fmt.Sprintf("%s.%d", prefix, i)) != ""; i++ {
+		values = append(values, form.Get(fmt.Sprintf("%s.%d", prefix, i)))
+	}
+	return values
+}
+
+// matches reports whether a resource with tags and attributes passes the
+// filters; filters on other attributes are ignored
+func matches(filters map[string][]string, tags []tag, attrs map[string]string) bool {
+	for name, values := range filters {
+		if key, ok := strings.CutPrefix(name, "tag:"); ok {
+			if !slices.Contains(values, tagValue(tags, key)) {
+				return false
+			}
+		} else if value, ok := attrs[name]; ok && !slices.Contains(values, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestProviderLifecycle(t *testing.T) {
+	fake, srv := newFakeEC2(t)
+	prov, err := NewProviderWithEndpoint(srv.URL, "eu-west-1", "t3.micro", "ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-*",
+		Credentials{AccessKeyID: "AKIDTEST", SecretAccessKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// A workload VPC with one subnet and an instance to probe routes from
+	workload := fake.addVPC("10.0.0.0/16", nil)
+	fake.subnets = append(fake.subnets, &subnet{SubnetID: "subnet-work", VpcID: workload.VpcID, CidrBlock: "10.0.1.0/24"})
+	probe := &instance{InstanceID: "i-probe", SubnetID: "subnet-work", VpcID: workload.VpcID}
+	probe.State.Name = "running"
+	fake.instances = append(fake.instances, probe)
+
+	req := guard.NetworkRequest{GuardID: "guard-1", VNetCIDR: "10.100.0.0/16", SubnetCIDR: "10.100.1.0/24", WireGuardPort: 51820, Group: "guard-0"}
+	info, err := prov.EnsureNetwork(ctx, req)
+	if err != nil {
+		t.Fatalf("EnsureNetwork() error = %v", err)
+	}
+	if info.PublicIP != "203.0.113.10" || info.PrivateIP != "10.100.1.4" || info.VNetID == "" || info.NICID == "" {
+		t.Errorf("EnsureNetwork() = %+v", info)
+	}
+	// Ensuring again keeps the existing resources
+	if _, err := prov.EnsureNetwork(ctx, req); err != nil {
+		t.Fatalf("second EnsureNetwork() error = %v", err)
+	}
+	if len(fake.vpcs) != 2 || len(fake.nics) != 1 || len(fake.addresses) != 1 || len(fake.rules[info.NSGID]) != 3 {
+		t.Errorf("second EnsureNetwork() duplicated resources: %d VPCs, %d NICs, %d addresses, rules %v",
+			len(fake.vpcs), len(fake.nics), len(fake.addresses), fake.rules[info.NSGID])
+	}
+	if fake.nics[0].SourceDestCheck {
+		t.Error("EnsureNetwork() left the source/destination check on")
+	}
+
+	server, err := prov.CreateServer(ctx, machine.CreateServerRequest{
+		Name:     "guard-1-vm",
+		UserData: "#cloud-config\n",
+		SSHKeys:  []string{"ssh-ed25519 AAAA test"},
+		Labels: map[string]string{
+			"managed-by": "morpheus-azureguard", "guard-id": "guard-1", "guard-group": "guard-0",
+			"mesh-cidrs": "10.200.0.0/16", "wg-port": "51820", "wg-public-key": "cHVia2V5",
+			"nic-id": info.NICID, "resource-group": "",
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateServer() error = %v", err)
+	}
+	inst := fake.instances[len(fake.instances)-1]
+	if inst.ImageID != "ami-new" || tagValue(inst.Tags, TagManagedBy) != TagManagedByValue || tagValue(inst.Tags, TagName) != "guard-1-vm" {
+		t.Errorf("instance = %+v, want the newest image and the AWS managed-by tag", inst)
+	}
+	if fake.userData[server.ID] != "#cloud-config\n" || fake.keys["guard-1-key"] != "ssh-ed25519 AAAA test" {
+		t.Errorf("instance user data = %q, keys = %v", fake.userData[server.ID], fake.keys)
+	}
+
+	g, err := prov.GetGuard(ctx, "guard-1")
+	if err != nil {
+		t.Fatalf("GetGuard() error = %v", err)
+	}
+	if g.Status != "running" || g.PrivateIP != "10.100.1.4" || g.PublicIP != "203.0.113.10" || g.Group != "guard-0" ||
+		g.WireGuardPort != 51820 || g.PublicKey != "cHVia2V5" || len(g.MeshCIDRs) != 1 || g.Location != "eu-west-1a" ||
+		g.CreatedAt.IsZero() {
+		t.Errorf("GetGuard() = %+v", g)
+	}
+
+	err = prov.PeerNetwork(ctx, guard.PeerRequest{
+		GuardID:        "guard-1",
+		RemoteVNetID:   workload.VpcID,
+		GuardPrivateIP: g.PrivateIP,
+		MeshCIDRs:      g.MeshCIDRs,
+	})
+	if err != nil {
+		t.Fatalf("PeerNetwork() error = %v", err)
+	}
+	if !slices.Contains(fake.rules[info.NSGID], "-1   10.0.0.0/16") {
+		t.Errorf("PeerNetwork() did not allow traffic from the workload VPC: %v", fake.rules[info.NSGID])
+	}
+
+	tables, err := prov.ListRouteTables(ctx, "")
+	if err != nil || len(tables) != 1 || tables[0].GuardID != "guard-1" || tables[0].RemoteVNetID != workload.VpcID ||
+		!slices.Equal(tables[0].Routes, []string{"10.100.0.0/16", "10.200.0.0/16"}) {
+		t.Fatalf("ListRouteTables() = %+v, %v", tables, err)
+	}
+
+	routes, err := prov.EffectiveRoutes(ctx, "i-probe")
+	if err != nil {
+		t.Fatalf("EffectiveRoutes() error = %v", err)
+	}
+	if len(routes) != 3 || routes[2].Prefixes[0] != "10.200.0.0/16" || routes[2].NextHopType != "VNetPeering" {
+		t.Errorf("EffectiveRoutes() = %+v, want the mesh route over the peering", routes)
+	}
+
+	guards, err := prov.ListGuards(ctx)
+	if err != nil || len(guards) != 1 || guards[0].ID != "guard-1" || guards[0].PublicIP != g.PublicIP {
+		t.Fatalf("ListGuards() = %+v, %v", guards, err)
+	}
+
+	if err := prov.CleanupNetwork(ctx, "guard-1"); err != nil {
+		t.Fatalf("CleanupNetwork() error = %v", err)
+	}
+	if len(fake.vpcs) != 1 || len(fake.subnets) != 1 || len(fake.gateways) != 0 || len(fake.groups) != 0 ||
+		len(fake.addresses) != 0 || len(fake.nics) != 0 || len(fake.keys) != 0 {
+		t.Errorf("CleanupNetwork() left resources: %d VPCs, %d subnets, %d gateways, %d groups, %d addresses, %d NICs, %d keys",
+			len(fake.vpcs), len(fake.subnets), len(fake.gateways), len(fake.groups), len(fake.addresses), len(fake.nics), len(fake.keys))
+	}
+	if routes := fake.tables[0].Routes; len(routes) != 1 {
+		t.Errorf("CleanupNetwork() left workload routes: %+v", routes)
+	}
+	if state := fake.instances[1].State.Name; state != "terminated" {
+		t.Errorf("CleanupNetwork() left the instance %s", state)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// The example request of the Signature Version 4 documentation
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	creds := Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	sign(req, nil, creds, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %s\nwant %s", got, want)
+	}
+}
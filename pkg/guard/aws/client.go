@@ -0,0 +1,374 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiVersion is the EC2 Query API version the requests are written for
+const apiVersion = "2016-11-15"
+
+// Credentials are the AWS access keys requests are signed with
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // Set for temporary credentials
+}
+
+// client is a minimal EC2 Query API client
+type client struct {
+	endpoint   string
+	region     string
+	creds      Credentials
+	httpClient *http.Client
+}
+
+// apiError is an error response of the EC2 API
+type apiError struct {
+	Status  int
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s: %s (HTTP %d)", e.Code, e.Message, e.Status)
+}
+
+// errorCode returns the EC2 error code of err, or ""
+func errorCode(err error) string {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ""
+}
+
+// isNotFound reports whether err says a resource does not exist
+func isNotFound(err error) bool {
+	return strings.HasSuffix(errorCode(err), "NotFound")
+}
+
+// isDuplicate reports whether err says a resource or rule already exists
+func isDuplicate(err error) bool {
+	code := errorCode(err)
+	return strings.HasSuffix(code, ".Duplicate") || code == "RouteAlreadyExists"
+}
+
+// call sends an EC2 API action and decodes the response into out (if not
+// nil)
+func (c *client) call(ctx context.Context, action string, params url.Values, out any) error {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("Action", action)
+	params.Set("Version", apiVersion)
+	body := []byte(params.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	sign(req, body, c.creds, c.region, "ec2", time.Now().UTC())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		var wrapper struct {
+			Errors []apiError `xml:"Errors>Error"`
+		}
+		if xml.Unmarshal(data, &wrapper) == nil && len(wrapper.Errors) > 0 {
+			apiErr := wrapper.Errors[0]
+			apiErr.Status = resp.StatusCode
+			return &apiErr
+		}
+		return &apiError{Status: resp.StatusCode, Code: http.StatusText(resp.StatusCode), Message: strings.TrimSpace(string(data))}
+	}
+	if out != nil {
+		return xml.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// describe calls a Describe action and returns the items of the response
+// set named set (e.g. vpcSet), following next tokens
+func describe[T any](ctx context.Context, c *client, action, set string, params url.Values) ([]T, error) {
+	if params == nil {
+		params = url.Values{}
+	}
+	var items []T
+	for {
+		var page struct {
+			Sets []struct {
+				XMLName xml.Name
+				Items   []T `xml:"item"`
+			} `xml:",any"`
+			NextToken string `xml:"nextToken"`
+		}
+		if err := c.call(ctx, action, params, &page); err != nil {
+			return nil, err
+		}
+		for _, s := range page.Sets {
+			if s.XMLName.Local == set {
+				items = append(items, s.Items...)
+			}
+		}
+		if page.NextToken == "" {
+			return items, nil
+		}
+		params.Set("NextToken", page.NextToken)
+	}
+}
+
+// filter is a Describe filter, e.g. tag:guard-id or vpc-id
+type filter struct {
+	Name   string
+	Values []string
+}
+
+// filterParams builds the parameters of a Describe call from filters
+func filterParams(filters ...filter) url.Values {
+	v := url.Values{}
+	for i, f := range filters {
+		prefix := "Filter." + strconv.Itoa(i+1)
+		v.Set(prefix+".Name", f.Name)
+		for j, value := range f.Values {
+			v.Set(prefix+".Value."+strconv.Itoa(j+1), value)
+		}
+	}
+	return v
+}
+
+// tagFilter matches resources with a tag
+func tagFilter(key string, values ...string) filter {
+	return filter{Name: "tag:" + key, Values: values}
+}
+
+// addTags adds tag specifications for new resources of one or more types
+// (e.g. instance and volume) to the parameters of a Create call
+func addTags(v url.Values, tags map[string]string, resourceTypes ...string) {
+	for i, resourceType := range resourceTypes {
+		prefix := "TagSpecification." + strconv.Itoa(i+1)
+		v.Set(prefix+".ResourceType", resourceType)
+		for j, k := range sortedKeys(tags) {
+			v.Set(fmt.Sprintf("%s.Tag.%d.Key", prefix, j+1), k)
+			v.Set(fmt.Sprintf("%s.Tag.%d.Value", prefix, j+1), tags[k])
+		}
+	}
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to req, whose
+// body is body
+func sign(req *http.Request, body []byte, creds Credentials, region, service string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	names := sortedKeys(headers)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	bodyHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes a query string the way Signature Version 4
+// expects: sorted, with spaces as %20
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for k, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// EC2 API resources, with the fields used here
+
+type vpc struct {
+	VpcID     string `xml:"vpcId"`
+	CidrBlock string `xml:"cidrBlock"`
+	State     string `xml:"state,omitempty"`
+	Tags      []tag  `xml:"tagSet>item"`
+}
+
+type subnet struct {
+	SubnetID         string `xml:"subnetId"`
+	VpcID            string `xml:"vpcId"`
+	CidrBlock        string `xml:"cidrBlock"`
+	AvailabilityZone string `xml:"availabilityZone,omitempty"`
+	Tags             []tag  `xml:"tagSet>item"`
+}
+
+type internetGateway struct {
+	InternetGatewayID string `xml:"internetGatewayId"`
+	Attachments       []struct {
+		VpcID string `xml:"vpcId"`
+	} `xml:"attachmentSet>item"`
+	Tags []tag `xml:"tagSet>item"`
+}
+
+type securityGroup struct {
+	GroupID   string `xml:"groupId"`
+	GroupName string `xml:"groupName"`
+	VpcID     string `xml:"vpcId"`
+	Tags      []tag  `xml:"tagSet>item"`
+}
+
+type address struct {
+	AllocationID       string `xml:"allocationId"`
+	PublicIP           string `xml:"publicIp"`
+	AssociationID      string `xml:"associationId,omitempty"`
+	NetworkInterfaceID string `xml:"networkInterfaceId,omitempty"`
+	Tags               []tag  `xml:"tagSet>item"`
+}
+
+type networkInterface struct {
+	NetworkInterfaceID string `xml:"networkInterfaceId"`
+	SubnetID           string `xml:"subnetId"`
+	VpcID              string `xml:"vpcId"`
+	PrivateIPAddress   string `xml:"privateIpAddress"`
+	SourceDestCheck    bool   `xml:"sourceDestCheck"`
+	Status             string `xml:"status,omitempty"` // available or in-use
+	Association        *struct {
+		PublicIP string `xml:"publicIp"`
+	} `xml:"association,omitempty"`
+	Tags []tag `xml:"tagSet>item"`
+}
+
+type reservation struct {
+	Instances []instance `xml:"instancesSet>item"`
+}
+
+type instance struct {
+	InstanceID   string `xml:"instanceId"`
+	ImageID      string `xml:"imageId"`
+	InstanceType string `xml:"instanceType"`
+	State        struct {
+		Name string `xml:"name"` // pending, running, stopping, stopped, shutting-down or terminated
+	} `xml:"instanceState"`
+	PrivateIPAddress string `xml:"privateIpAddress,omitempty"`
+	PublicIPAddress  string `xml:"ipAddress,omitempty"`
+	SubnetID         string `xml:"subnetId,omitempty"`
+	VpcID            string `xml:"vpcId,omitempty"`
+	LaunchTime       string `xml:"launchTime,omitempty"`
+	Placement        struct {
+		AvailabilityZone string `xml:"availabilityZone"`
+	} `xml:"placement"`
+	Tags []tag `xml:"tagSet>item"`
+}
+
+type routeTable struct {
+	RouteTableID string                  `xml:"routeTableId"`
+	VpcID        string                  `xml:"vpcId"`
+	Routes       []route                 `xml:"routeSet>item"`
+	Associations []routeTableAssociation `xml:"associationSet>item"`
+	Tags         []tag                   `xml:"tagSet>item"`
+}
+
+type route struct {
+	DestinationCidrBlock   string `xml:"destinationCidrBlock"`
+	GatewayID              string `xml:"gatewayId,omitempty"` // "local" or an internet gateway
+	InstanceID             string `xml:"instanceId,omitempty"`
+	NetworkInterfaceID     string `xml:"networkInterfaceId,omitempty"`
+	VpcPeeringConnectionID string `xml:"vpcPeeringConnectionId,omitempty"`
+	NatGatewayID           string `xml:"natGatewayId,omitempty"`
+	TransitGatewayID       string `xml:"transitGatewayId,omitempty"`
+	State                  string `xml:"state"` // active or blackhole
+}
+
+type routeTableAssociation struct {
+	SubnetID string `xml:"subnetId,omitempty"`
+	Main     bool   `xml:"main"`
+}
+
+type vpcPeeringConnection struct {
+	VpcPeeringConnectionID string  `xml:"vpcPeeringConnectionId"`
+	RequesterVpcInfo       vpcInfo `xml:"requesterVpcInfo"`
+	AccepterVpcInfo        vpcInfo `xml:"accepterVpcInfo"`
+	Status                 struct {
+		Code string `xml:"code"` // e.g. pending-acceptance, active, deleted
+	} `xml:"status"`
+	Tags []tag `xml:"tagSet>item"`
+}
+
+type vpcInfo struct {
+	VpcID     string `xml:"vpcId"`
+	CidrBlock string `xml:"cidrBlock"`
+}
+
+type image struct {
+	ImageID      string `xml:"imageId"`
+	Name         string `xml:"name"`
+	CreationDate string `xml:"creationDate"`
+}
@@ -0,0 +1,694 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/guard"
+)
+
+// findTagged returns the first resource of a guard that a Describe action
+// returns, or nil if there is none
+func findTagged[T any](ctx context.Context, c *client, action, set, guardID string) (*T, error) {
+	items, err := describe[T](ctx, c, action, set, filterParams(tagFilter(TagManagedBy, TagManagedByValue), tagFilter(TagGuardID, guardID)))
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+	return &items[0], nil
+}
+
+// EnsureNetwork creates the full networking stack for a guard: a VPC with
+// one subnet and an internet gateway, a security group for SSH, WireGuard
+// and the VPC's own range, a network interface with the source/destination
+// check disabled, and an Elastic IP on it. Resources that already exist
+// (found by their guard-id tag) are kept.
+func (p *Provider) EnsureNetwork(ctx context.Context, req guard.NetworkRequest) (*guard.NetworkInfo, error) {
+	names := newResourceNames(req.GuardID)
+	zone, err := p.zoneOf(req.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. VPC
+	v, err := findTagged[vpc](ctx, p.c, "DescribeVpcs", "vpcSet", req.GuardID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VPC: %w", err)
+	}
+	if v == nil {
+		fmt.Printf("      Creating VPC %s (%s)...\n", names.VPC, req.VNetCIDR)
+		tags := guardTags(req.GuardID, names.VPC)
+		tags[TagWGPort] = strconv.Itoa(req.WireGuardPort)
+		if req.Group != "" {
+			tags[TagGroup] = req.Group
+		}
+		params := url.Values{}
+		params.Set("CidrBlock", req.VNetCIDR)
+		addTags(params, tags, "vpc")
+		var resp struct {
+			VPC vpc `xml:"vpc"`
+		}
+		if err := p.c.call(ctx, "CreateVpc", params, &resp); err != nil {
+			return nil, fmt.Errorf("failed to create VPC: %w", err)
+		}
+		v = &resp.VPC
+	}
+
+	// 2. Subnet
+	s, err := findTagged[subnet](ctx, p.c, "DescribeSubnets", "subnetSet", req.GuardID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subnet: %w", err)
+	}
+	if s == nil {
+		fmt.Printf("      Creating subnet %s (%s)...\n", names.Subnet, req.SubnetCIDR)
+		params := url.Values{}
+		params.Set("VpcId", v.VpcID)
+		params.Set("CidrBlock", req.SubnetCIDR)
+		if zone != "" {
+			params.Set("AvailabilityZone", zone)
+		}
+		addTags(params, guardTags(req.GuardID, names.Subnet), "subnet")
+		var resp struct {
+			Subnet subnet `xml:"subnet"`
+		}
+		if err := p.c.call(ctx, "CreateSubnet", params, &resp); err != nil {
+			return nil, fmt.Errorf("failed to create subnet: %w", err)
+		}
+		s = &resp.Subnet
+	}
+
+	// 3. Internet gateway, and the VPC's default route through it
+	if err := p.ensureGateway(ctx, req.GuardID, v.VpcID); err != nil {
+		return nil, err
+	}
+
+	// 4. Security group
+	sg, err := findTagged[securityGroup](ctx, p.c, "DescribeSecurityGroups", "securityGroupInfo", req.GuardID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get security group: %w", err)
+	}
+	if sg == nil {
+		fmt.Printf("      Creating security group %s...\n", names.SecurityGroup)
+		params := url.Values{}
+		params.Set("GroupName", names.SecurityGroup)
+		params.Set("GroupDescription", "WireGuard gateway "+req.GuardID)
+		params.Set("VpcId", v.VpcID)
+		addTags(params, guardTags(req.GuardID, names.SecurityGroup), "security-group")
+		var resp struct {
+			GroupID string `xml:"groupId"`
+		}
+		if err := p.c.call(ctx, "CreateSecurityGroup", params, &resp); err != nil {
+			return nil, fmt.Errorf("failed to create security group: %w", err)
+		}
+		sg = &securityGroup{GroupID: resp.GroupID, GroupName: names.SecurityGroup, VpcID: v.VpcID}
+	}
+	var rules []permission
+	for _, cidr := range guard.SSHSourceCIDRs(req.SSHSources) {
+		rules = append(rules, permission{Protocol: "tcp", FromPort: 22, ToPort: 22, CIDR: cidr, Description: "SSH"})
+	}
+	rules = append(rules,
+		permission{Protocol: "udp", FromPort: req.WireGuardPort, ToPort: req.WireGuardPort, CIDR: "0.0.0.0/0", Description: "WireGuard"},
+		permission{Protocol: "-1", CIDR: req.VNetCIDR, Description: "Guard VPC"},
+	)
+	for _, rule := range rules {
+		if err := p.authorize(ctx, sg.GroupID, "AuthorizeSecurityGroupIngress", rule); err != nil {
+			return nil, fmt.Errorf("failed to create security group rule %s: %w", rule.Description, err)
+		}
+	}
+
+	// 5. Network interface, forwarding traffic for the mesh
+	nic, err := findTagged[networkInterface](ctx, p.c, "DescribeNetworkInterfaces", "networkInterfaceSet", req.GuardID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network interface: %w", err)
+	}
+	if nic == nil {
+		fmt.Printf("      Creating network interface %s...\n", names.NIC)
+		params := url.Values{}
+		params.Set("SubnetId", s.SubnetID)
+		params.Set("SecurityGroupId.1", sg.GroupID)
+		params.Set("Description", "WireGuard gateway "+req.GuardID)
+		addTags(params, guardTags(req.GuardID, names.NIC), "network-interface")
+		var resp struct {
+			NIC networkInterface `xml:"networkInterface"`
+		}
+		if err := p.c.call(ctx, "CreateNetworkInterface", params, &resp); err != nil {
+			return nil, fmt.Errorf("failed to create network interface: %w", err)
+		}
+		nic = &resp.NIC
+	}
+	if nic.SourceDestCheck {
+		if err := p.ConfigureNICForwarding(ctx, nic.NetworkInterfaceID); err != nil {
+			return nil, err
+		}
+	}
+
+	// 6. Elastic IP, so the guard keeps its address if the instance is
+	// replaced
+	addr, err := findTagged[address](ctx, p.c, "DescribeAddresses", "addressesSet", req.GuardID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Elastic IP: %w", err)
+	}
+	if addr == nil {
+		fmt.Printf("      Allocating Elastic IP %s...\n", names.Address)
+		params := url.Values{}
+		params.Set("Domain", "vpc")
+		addTags(params, guardTags(req.GuardID, names.Address), "elastic-ip")
+		addr = &address{}
+		if err := p.c.call(ctx, "AllocateAddress", params, addr); err != nil {
+			return nil, fmt.Errorf("failed to allocate Elastic IP: %w", err)
+		}
+	}
+	if addr.NetworkInterfaceID != nic.NetworkInterfaceID {
+		params := url.Values{}
+		params.Set("AllocationId", addr.AllocationID)
+		params.Set("NetworkInterfaceId", nic.NetworkInterfaceID)
+		if err := p.c.call(ctx, "AssociateAddress", params, nil); err != nil {
+			return nil, fmt.Errorf("failed to associate Elastic IP: %w", err)
+		}
+	}
+
+	return &guard.NetworkInfo{
+		ResourceGroup: p.region,
+		VNetID:        v.VpcID,
+		SubnetID:      s.SubnetID,
+		NSGID:         sg.GroupID,
+		NICID:         nic.NetworkInterfaceID,
+		PublicIPID:    addr.AllocationID,
+		PublicIP:      addr.PublicIP,
+		PrivateIP:     nic.PrivateIPAddress,
+	}, nil
+}
+
+// ensureGateway creates and attaches the guard's internet gateway, and
+// routes the VPC's default route through it
+func (p *Provider) ensureGateway(ctx context.Context, guardID, vpcID string) error {
+	names := newResourceNames(guardID)
+	igw, err := findTagged[internetGateway](ctx, p.c, "DescribeInternetGateways", "internetGatewaySet", guardID)
+	if err != nil {
+		return fmt.Errorf("failed to get internet gateway: %w", err)
+	}
+	if igw == nil {
+		fmt.Printf("      Creating internet gateway %s...\n", names.Gateway)
+		params := url.Values{}
+		addTags(params, guardTags(guardID, names.Gateway), "internet-gateway")
+		var resp struct {
+			Gateway internetGateway `xml:"internetGateway"`
+		}
+		if err := p.c.call(ctx, "CreateInternetGateway", params, &resp); err != nil {
+			return fmt.Errorf("failed to create internet gateway: %w", err)
+		}
+		igw = &resp.Gateway
+	}
+	if len(igw.Attachments) == 0 {
+		params := url.Values{}
+		params.Set("InternetGatewayId", igw.InternetGatewayID)
+		params.Set("VpcId", vpcID)
+		if err := p.c.call(ctx, "AttachInternetGateway", params, nil); err != nil {
+			return fmt.Errorf("failed to attach internet gateway: %w", err)
+		}
+	}
+
+	table, err := p.mainRouteTable(ctx, vpcID)
+	if err != nil {
+		return err
+	}
+	if err := p.ensureRoute(ctx, table.RouteTableID, "0.0.0.0/0", "GatewayId", igw.InternetGatewayID); err != nil {
+		return fmt.Errorf("failed to create default route: %w", err)
+	}
+	return nil
+}
+
+// mainRouteTable returns the main route table of a VPC
+func (p *Provider) mainRouteTable(ctx context.Context, vpcID string) (*routeTable, error) {
+	tables, err := describe[routeTable](ctx, p.c, "DescribeRouteTables", "routeTableSet", filterParams(
+		filter{Name: "vpc-id", Values: []string{vpcID}},
+		filter{Name: "association.main", Values: []string{"true"}},
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get route table: %w", err)
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("VPC %s has no main route table", vpcID)
+	}
+	return &tables[0], nil
+}
+
+// ensureRoute creates a route, or points an existing route for the same
+// destination at the new target. targetParam is the parameter naming the
+// target, e.g. GatewayId or VpcPeeringConnectionId.
+func (p *Provider) ensureRoute(ctx context.Context, tableID, destination, targetParam, target string) error {
+	params := url.Values{}
+	params.Set("RouteTableId", tableID)
+	params.Set("DestinationCidrBlock", destination)
+	params.Set(targetParam, target)
+	err := p.c.call(ctx, "CreateRoute", params, nil)
+	if isDuplicate(err) {
+		err = p.c.call(ctx, "ReplaceRoute", params, nil)
+	}
+	return err
+}
+
+// deleteRoute deletes a route; it is not an error if it does not exist
+func (p *Provider) deleteRoute(ctx context.Context, tableID, destination string) error {
+	params := url.Values{}
+	params.Set("RouteTableId", tableID)
+	params.Set("DestinationCidrBlock", destination)
+	if err := p.c.call(ctx, "DeleteRoute", params, nil); err != nil && !isNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// permission is a security group rule for one address range
+type permission struct {
+	Protocol    string // tcp, udp or -1 (all)
+	FromPort    int
+	ToPort      int
+	CIDR        string
+	Description string
+}
+
+// authorize adds a rule to a security group with action
+// (AuthorizeSecurityGroupIngress or AuthorizeSecurityGroupEgress, or the
+// Revoke actions to remove it); existing rules are not an error
+func (p *Provider) authorize(ctx context.Context, groupID, action string, rule permission) error {
+	params := url.Values{}
+	params.Set("GroupId", groupID)
+	params.Set("IpPermissions.1.IpProtocol", rule.Protocol)
+	if rule.Protocol != "-1" {
+		params.Set("IpPermissions.1.FromPort", strconv.Itoa(rule.FromPort))
+		params.Set("IpPermissions.1.ToPort", strconv.Itoa(rule.ToPort))
+	}
+	params.Set("IpPermissions.1.IpRanges.1.CidrIp", rule.CIDR)
+	if rule.Description != "" && strings.HasPrefix(action, "Authorize") {
+		params.Set("IpPermissions.1.IpRanges.1.Description", rule.Description)
+	}
+	err := p.c.call(ctx, action, params, nil)
+	if isDuplicate(err) || (isNotFound(err) && strings.HasPrefix(action, "Revoke")) {
+		return nil
+	}
+	return err
+}
+
+// CleanupNetwork removes all of a guard's resources: the instance, its
+// peerings and their routes, the Elastic IP, network interface, key pair,
+// internet gateway, subnet, security group and VPC.
+func (p *Provider) CleanupNetwork(ctx context.Context, guardID string) error {
+	names := newResourceNames(guardID)
+
+	instances, err := p.guardInstances(ctx, guardID)
+	if err != nil {
+		return err
+	}
+	for _, inst := range instances {
+		fmt.Printf("   Terminating instance %s...\n", inst.InstanceID)
+		if err := p.DeleteServer(ctx, inst.InstanceID); err != nil {
+			return err
+		}
+		// The network interface stays in use until the instance is gone
+		if err := p.waitTerminated(ctx, inst.InstanceID); err != nil {
+			return err
+		}
+	}
+
+	peerings, err := p.guardPeerings(ctx, guardID)
+	if err != nil {
+		return err
+	}
+	for _, pcx := range peerings {
+		if err := p.UnpeerNetwork(ctx, guardID, tagValue(pcx.Tags, TagName)); err != nil {
+			return err
+		}
+	}
+
+	addr, err := findTagged[address](ctx, p.c, "DescribeAddresses", "addressesSet", guardID)
+	if err != nil {
+		return fmt.Errorf("failed to get Elastic IP: %w", err)
+	}
+	if addr != nil {
+		fmt.Printf("   Releasing Elastic IP %s...\n", addr.PublicIP)
+		if addr.AssociationID != "" {
+			params := url.Values{}
+			params.Set("AssociationId", addr.AssociationID)
+			if err := p.c.call(ctx, "DisassociateAddress", params, nil); err != nil && !isNotFound(err) {
+				return fmt.Errorf("failed to disassociate Elastic IP: %w", err)
+			}
+		}
+		params := url.Values{}
+		params.Set("AllocationId", addr.AllocationID)
+		if err := p.c.call(ctx, "ReleaseAddress", params, nil); err != nil && !isNotFound(err) {
+			return fmt.Errorf("failed to release Elastic IP: %w", err)
+		}
+	}
+
+	nic, err := findTagged[networkInterface](ctx, p.c, "DescribeNetworkInterfaces", "networkInterfaceSet", guardID)
+	if err != nil {
+		return fmt.Errorf("failed to get network interface: %w", err)
+	}
+	if nic != nil {
+		fmt.Printf("   Deleting network interface %s...\n", nic.NetworkInterfaceID)
+		params := url.Values{}
+		params.Set("NetworkInterfaceId", nic.NetworkInterfaceID)
+		if err := p.c.call(ctx, "DeleteNetworkInterface", params, nil); err != nil && !isNotFound(err) {
+			return fmt.Errorf("failed to delete network interface: %w", err)
+		}
+	}
+
+	params := url.Values{}
+	params.Set("KeyName", names.KeyPair)
+	if err := p.c.call(ctx, "DeleteKeyPair", params, nil); err != nil && !isNotFound(err) {
+		return fmt.Errorf("failed to delete key pair: %w", err)
+	}
+
+	v, err := findTagged[vpc](ctx, p.c, "DescribeVpcs", "vpcSet", guardID)
+	if err != nil {
+		return fmt.Errorf("failed to get VPC: %w", err)
+	}
+	if v == nil {
+		return nil
+	}
+
+	igw, err := findTagged[internetGateway](ctx, p.c, "DescribeInternetGateways", "internetGatewaySet", guardID)
+	if err != nil {
+		return fmt.Errorf("failed to get internet gateway: %w", err)
+	}
+	if igw != nil {
+		fmt.Printf("   Deleting internet gateway %s...\n", igw.InternetGatewayID)
+		params := url.Values{}
+		params.Set("InternetGatewayId", igw.InternetGatewayID)
+		params.Set("VpcId", v.VpcID)
+		if err := p.c.call(ctx, "DetachInternetGateway", params, nil); err != nil && !isNotFound(err) && errorCode(err) != "Gateway.NotAttached" {
+			return fmt.Errorf("failed to detach internet gateway: %w", err)
+		}
+		params.Del("VpcId")
+		if err := p.c.call(ctx, "DeleteInternetGateway", params, nil); err != nil && !isNotFound(err) {
+			return fmt.Errorf("failed to delete internet gateway: %w", err)
+		}
+	}
+
+	subnets, err := describe[subnet](ctx, p.c, "DescribeSubnets", "subnetSet", filterParams(filter{Name: "vpc-id", Values: []string{v.VpcID}}))
+	if err != nil {
+		return fmt.Errorf("failed to list subnets: %w", err)
+	}
+	for _, s := range subnets {
+		fmt.Printf("   Deleting subnet %s...\n", s.SubnetID)
+		params := url.Values{}
+		params.Set("SubnetId", s.SubnetID)
+		if err := p.c.call(ctx, "DeleteSubnet", params, nil); err != nil && !isNotFound(err) {
+			return fmt.Errorf("failed to delete subnet: %w", err)
+		}
+	}
+
+	sg, err := findTagged[securityGroup](ctx, p.c, "DescribeSecurityGroups", "securityGroupInfo", guardID)
+	if err != nil {
+		return fmt.Errorf("failed to get security group: %w", err)
+	}
+	if sg != nil {
+		fmt.Printf("   Deleting security group %s...\n", sg.GroupID)
+		params := url.Values{}
+		params.Set("GroupId", sg.GroupID)
+		if err := p.c.call(ctx, "DeleteSecurityGroup", params, nil); err != nil && !isNotFound(err) {
+			return fmt.Errorf("failed to delete security group: %w", err)
+		}
+	}
+
+	fmt.Printf("   Deleting VPC %s...\n", v.VpcID)
+	params = url.Values{}
+	params.Set("VpcId", v.VpcID)
+	if err := p.c.call(ctx, "DeleteVpc", params, nil); err != nil && !isNotFound(err) {
+		return fmt.Errorf("failed to delete VPC: %w", err)
+	}
+	return nil
+}
+
+// waitTerminated waits until an instance is terminated
+func (p *Provider) waitTerminated(ctx context.Context, instanceID string) error {
+	params := url.Values{}
+	params.Set("InstanceId.1", instanceID)
+	for {
+		instances, err := p.describeInstances(ctx, params)
+		if err != nil && !isNotFound(err) {
+			return fmt.Errorf("failed to get instance: %w", err)
+		}
+		if len(instances) == 0 || instances[0].State.Name == "terminated" {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// ConfigureNICForwarding disables the source/destination check of a
+// network interface, so it can forward traffic for the mesh.
+func (p *Provider) ConfigureNICForwarding(ctx context.Context, nicID string) error {
+	params := url.Values{}
+	params.Set("NetworkInterfaceId", nicID)
+	params.Set("SourceDestCheck.Value", "false")
+	if err := p.c.call(ctx, "ModifyNetworkInterfaceAttribute", params, nil); err != nil {
+		return fmt.Errorf("failed to disable the source/destination check: %w", err)
+	}
+	return nil
+}
+
+// EnsureNSGRule adds a rule to the guard's security group. Security
+// groups only allow traffic, so the rule's name and priority are not
+// used; the name becomes its description.
+func (p *Provider) EnsureNSGRule(ctx context.Context, req guard.NSGRuleRequest) error {
+	sg, err := findTagged[securityGroup](ctx, p.c, "DescribeSecurityGroups", "securityGroupInfo", req.GuardID)
+	if err != nil {
+		return fmt.Errorf("failed to get security group: %w", err)
+	}
+	if sg == nil {
+		return fmt.Errorf("security group %s not found", newResourceNames(req.GuardID).SecurityGroup)
+	}
+
+	rule := permission{Protocol: strings.ToLower(req.Protocol), FromPort: 0, ToPort: 65535, CIDR: "0.0.0.0/0", Description: req.RuleName}
+	if req.Protocol == "*" {
+		rule.Protocol = "-1"
+	}
+	if req.DestPort != "" && req.DestPort != "*" {
+		from, to, _ := strings.Cut(req.DestPort, "-")
+		if to == "" {
+			to = from
+		}
+		if rule.FromPort, err = strconv.Atoi(from); err != nil {
+			return fmt.Errorf("invalid port %s", req.DestPort)
+		}
+		if rule.ToPort, err = strconv.Atoi(to); err != nil {
+			return fmt.Errorf("invalid port %s", req.DestPort)
+		}
+	}
+	action := "AuthorizeSecurityGroupIngress"
+	if req.Direction == "Outbound" {
+		action = "AuthorizeSecurityGroupEgress"
+	}
+	if err := p.authorize(ctx, sg.GroupID, action, rule); err != nil {
+		return fmt.Errorf("failed to create security group rule: %w", err)
+	}
+	return nil
+}
+
+// PeerNetwork peers the guard's VPC with a workload VPC in the same
+// account and region, and routes each side's traffic over the peering:
+// the workload VPC's route table (that of req.SubnetID, or the main one)
+// gets routes for the guard's VPC and the mesh CIDRs, and the guard's VPC
+// a route back and the mesh CIDRs via the guard's network interface. The
+// workload's range is allowed into the guard's security group.
+//
+// Unlike Azure and GCP, AWS does not forward peered traffic addressed
+// outside the peer VPC; mesh CIDRs beyond the guard's VPC need a transit
+// gateway instead, which is not managed here.
+func (p *Provider) PeerNetwork(ctx context.Context, req guard.PeerRequest) error {
+	guardVPC, err := findTagged[vpc](ctx, p.c, "DescribeVpcs", "vpcSet", req.GuardID)
+	if err != nil {
+		return fmt.Errorf("failed to get VPC: %w", err)
+	}
+	if guardVPC == nil {
+		return fmt.Errorf("VPC %s not found", newResourceNames(req.GuardID).VPC)
+	}
+	params := url.Values{}
+	params.Set("VpcId.1", req.RemoteVNetID)
+	remotes, err := describe[vpc](ctx, p.c, "DescribeVpcs", "vpcSet", params)
+	if err != nil || len(remotes) == 0 {
+		return fmt.Errorf("remote VPC %s not found: %v", req.RemoteVNetID, err)
+	}
+	remote := remotes[0]
+
+	// 1. Peering, requested by the guard's VPC and accepted at once
+	name := peeringName(req.GuardID, remote.VpcID)
+	pcx, err := p.findPeering(ctx, req.GuardID, name)
+	if err != nil {
+		return err
+	}
+	if pcx == nil {
+		fmt.Printf("   Creating peering %s...\n", name)
+		params := url.Values{}
+		params.Set("VpcId", guardVPC.VpcID)
+		params.Set("PeerVpcId", remote.VpcID)
+		addTags(params, guardTags(req.GuardID, name), "vpc-peering-connection")
+		var resp struct {
+			Peering vpcPeeringConnection `xml:"vpcPeeringConnection"`
+		}
+		if err := p.c.call(ctx, "CreateVpcPeeringConnection", params, &resp); err != nil {
+			return fmt.Errorf("failed to create peering: %w", err)
+		}
+		pcx = &resp.Peering
+	}
+	if pcx.Status.Code != "active" {
+		params := url.Values{}
+		params.Set("VpcPeeringConnectionId", pcx.VpcPeeringConnectionID)
+		if err := p.c.call(ctx, "AcceptVpcPeeringConnection", params, nil); err != nil {
+			return fmt.Errorf("failed to accept peering: %w", err)
+		}
+	}
+
+	// 2. Routes in the workload VPC
+	var table *routeTable
+	if req.SubnetID != "" {
+		tables, err := describe[routeTable](ctx, p.c, "DescribeRouteTables", "routeTableSet",
+			filterParams(filter{Name: "association.subnet-id", Values: []string{req.SubnetID}}))
+		if err != nil {
+			return fmt.Errorf("failed to get route table of %s: %w", req.SubnetID, err)
+		}
+		if len(tables) > 0 {
+			table = &tables[0]
+		}
+	}
+	if table == nil {
+		if table, err = p.mainRouteTable(ctx, remote.VpcID); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("   Routing mesh CIDRs from route table %s...\n", table.RouteTableID)
+	for _, cidr := range append([]string{guardVPC.CidrBlock}, req.MeshCIDRs...) {
+		if err := p.ensureRoute(ctx, table.RouteTableID, cidr, "VpcPeeringConnectionId", pcx.VpcPeeringConnectionID); err != nil {
+			return fmt.Errorf("failed to create route for %s: %w", cidr, err)
+		}
+	}
+
+	// 3. Routes in the guard's VPC
+	guardTable, err := p.mainRouteTable(ctx, guardVPC.VpcID)
+	if err != nil {
+		return err
+	}
+	if err := p.ensureRoute(ctx, guardTable.RouteTableID, remote.CidrBlock, "VpcPeeringConnectionId", pcx.VpcPeeringConnectionID); err != nil {
+		return fmt.Errorf("failed to create route for %s: %w", remote.CidrBlock, err)
+	}
+	if len(req.MeshCIDRs) > 0 {
+		nic, err := findTagged[networkInterface](ctx, p.c, "DescribeNetworkInterfaces", "networkInterfaceSet", req.GuardID)
+		if err != nil || nic == nil {
+			return fmt.Errorf("network interface %s not found: %v", newResourceNames(req.GuardID).NIC, err)
+		}
+		for _, cidr := range req.MeshCIDRs {
+			if err := p.ensureRoute(ctx, guardTable.RouteTableID, cidr, "NetworkInterfaceId", nic.NetworkInterfaceID); err != nil {
+				return fmt.Errorf("failed to create route for %s: %w", cidr, err)
+			}
+		}
+	}
+
+	// 4. Allow the workload VPC to send traffic through the guard
+	sg, err := findTagged[securityGroup](ctx, p.c, "DescribeSecurityGroups", "securityGroupInfo", req.GuardID)
+	if err != nil {
+		return fmt.Errorf("failed to get security group: %w", err)
+	}
+	if sg != nil {
+		fmt.Printf("   Allowing traffic from %s...\n", remote.CidrBlock)
+		if err := p.authorize(ctx, sg.GroupID, "AuthorizeSecurityGroupIngress", permission{Protocol: "-1", CIDR: remote.CidrBlock, Description: name}); err != nil {
+			return fmt.Errorf("failed to create security group rule: %w", err)
+		}
+	}
+	return nil
+}
+
+// guardPeerings lists the peerings of a guard that are not deleted, or
+// those of all guards if guardID is empty
+func (p *Provider) guardPeerings(ctx context.Context, guardID string) ([]vpcPeeringConnection, error) {
+	filters := []filter{
+		tagFilter(TagManagedBy, TagManagedByValue),
+		{Name: "status-code", Values: []string{"initiating-request", "pending-acceptance", "provisioning", "active"}},
+	}
+	if guardID != "" {
+		filters = append(filters, tagFilter(TagGuardID, guardID))
+	}
+	peerings, err := describe[vpcPeeringConnection](ctx, p.c, "DescribeVpcPeeringConnections", "vpcPeeringConnectionSet", filterParams(filters...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list peerings: %w", err)
+	}
+	return peerings, nil
+}
+
+// findPeering returns a guard's peering by name, or nil
+func (p *Provider) findPeering(ctx context.Context, guardID, name string) (*vpcPeeringConnection, error) {
+	peerings, err := p.guardPeerings(ctx, guardID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range peerings {
+		if tagValue(peerings[i].Tags, TagName) == name {
+			return &peerings[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// UnpeerNetwork removes a peering of the guard's VPC, the routes over it
+// on both sides and the security group rule for the workload's range.
+func (p *Provider) UnpeerNetwork(ctx context.Context, guardID, peeringName string) error {
+	pcx, err := p.findPeering(ctx, guardID, peeringName)
+	if err != nil {
+		return err
+	}
+	if pcx == nil {
+		return fmt.Errorf("guard %s has no peering %s", guardID, peeringName)
+	}
+
+	if err := p.deletePeeringRoutes(ctx, pcx.VpcPeeringConnectionID, ""); err != nil {
+		return err
+	}
+
+	sg, err := findTagged[securityGroup](ctx, p.c, "DescribeSecurityGroups", "securityGroupInfo", guardID)
+	if err == nil && sg != nil && pcx.AccepterVpcInfo.CidrBlock != "" {
+		if err := p.authorize(ctx, sg.GroupID, "RevokeSecurityGroupIngress", permission{Protocol: "-1", CIDR: pcx.AccepterVpcInfo.CidrBlock}); err != nil {
+			return fmt.Errorf("failed to delete security group rule: %w", err)
+		}
+	}
+
+	fmt.Printf("   Removing peering %s...\n", peeringName)
+	params := url.Values{}
+	params.Set("VpcPeeringConnectionId", pcx.VpcPeeringConnectionID)
+	if err := p.c.call(ctx, "DeleteVpcPeeringConnection", params, nil); err != nil && !isNotFound(err) {
+		return fmt.Errorf("failed to delete peering: %w", err)
+	}
+	return nil
+}
+
+// deletePeeringRoutes deletes the routes over a peering, from one route
+// table or from all if tableID is empty
+func (p *Provider) deletePeeringRoutes(ctx context.Context, pcxID, tableID string) error {
+	filters := []filter{{Name: "route.vpc-peering-connection-id", Values: []string{pcxID}}}
+	if tableID != "" {
+		filters = append(filters, filter{Name: "route-table-id", Values: []string{tableID}})
+	}
+	tables, err := describe[routeTable](ctx, p.c, "DescribeRouteTables", "routeTableSet", filterParams(filters...))
+	if err != nil {
+		return fmt.Errorf("failed to list route tables: %w", err)
+	}
+	for _, table := range tables {
+		for _, r := range table.Routes {
+			if r.VpcPeeringConnectionID != pcxID {
+				continue
+			}
+			fmt.Printf("   Deleting route %s from %s...\n", r.DestinationCidrBlock, table.RouteTableID)
+			if err := p.deleteRoute(ctx, table.RouteTableID, r.DestinationCidrBlock); err != nil {
+				return fmt.Errorf("failed to delete route %s: %w", r.DestinationCidrBlock, err)
+			}
+		}
+	}
+	return nil
+}
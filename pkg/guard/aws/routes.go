@@ -0,0 +1,173 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/nimsforest/morpheus/pkg/guard"
+)
+
+// ListRouteTables returns the workload route tables PeerNetwork added
+// routes to, for a guard or for all guards if guardID is empty. Their
+// routes are those over the guard's peerings.
+func (p *Provider) ListRouteTables(ctx context.Context, guardID string) ([]guard.RouteTable, error) {
+	peerings, err := p.guardPeerings(ctx, guardID)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []guard.RouteTable
+	for _, pcx := range peerings {
+		remoteVPC := pcx.AccepterVpcInfo.VpcID
+		found, err := describe[routeTable](ctx, p.c, "DescribeRouteTables", "routeTableSet", filterParams(
+			filter{Name: "vpc-id", Values: []string{remoteVPC}},
+			filter{Name: "route.vpc-peering-connection-id", Values: []string{pcx.VpcPeeringConnectionID}},
+		))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list route tables: %w", err)
+		}
+		for _, rt := range found {
+			t := guard.RouteTable{
+				ID:            rt.RouteTableID,
+				Name:          tagValue(rt.Tags, TagName),
+				ResourceGroup: p.region,
+				GuardID:       tagValue(pcx.Tags, TagGuardID),
+				RemoteVNetID:  remoteVPC,
+			}
+			if t.Name == "" {
+				t.Name = rt.RouteTableID
+			}
+			for _, r := range rt.Routes {
+				if r.VpcPeeringConnectionID == pcx.VpcPeeringConnectionID {
+					t.Routes = append(t.Routes, r.DestinationCidrBlock)
+				}
+			}
+			for _, assoc := range rt.Associations {
+				if assoc.SubnetID != "" {
+					t.Subnets = append(t.Subnets, assoc.SubnetID)
+				}
+			}
+			tables = append(tables, t)
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].GuardID != tables[j].GuardID {
+			return tables[i].GuardID < tables[j].GuardID
+		}
+		return tables[i].ID < tables[j].ID
+	})
+	return tables, nil
+}
+
+// DeleteRouteTable removes the routes over guard peerings from a route
+// table returned by ListRouteTables. The table itself belongs to the
+// workload VPC and is kept.
+func (p *Provider) DeleteRouteTable(ctx context.Context, routeTableID string) error {
+	peerings, err := p.guardPeerings(ctx, "")
+	if err != nil {
+		return err
+	}
+	for _, pcx := range peerings {
+		if err := p.deletePeeringRoutes(ctx, pcx.VpcPeeringConnectionID, routeTableID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EffectiveRoutes returns the routes of the route table of an instance's
+// subnet (its own, or the VPC's main table). Routes to network interfaces
+// name the interface's private IP as the next hop, like Azure does.
+func (p *Provider) EffectiveRoutes(ctx context.Context, vmID string) ([]guard.Route, error) {
+	params := url.Values{}
+	params.Set("InstanceId.1", vmID)
+	instances, err := p.describeInstances(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance: %w", err)
+	}
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("instance %s not found", vmID)
+	}
+	inst := instances[0]
+
+	tables, err := describe[routeTable](ctx, p.c, "DescribeRouteTables", "routeTableSet",
+		filterParams(filter{Name: "association.subnet-id", Values: []string{inst.SubnetID}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get route table: %w", err)
+	}
+	var table *routeTable
+	if len(tables) > 0 {
+		table = &tables[0]
+	} else if table, err = p.mainRouteTable(ctx, inst.VpcID); err != nil {
+		return nil, err
+	}
+
+	// Private IPs of the network interfaces routes point at
+	nicIPs := make(map[string]string)
+	var nicIDs []string
+	for _, r := range table.Routes {
+		if r.NetworkInterfaceID != "" {
+			nicIDs = append(nicIDs, r.NetworkInterfaceID)
+		}
+	}
+	if len(nicIDs) > 0 {
+		params := url.Values{}
+		for i, id := range nicIDs {
+			params.Set(fmt.Sprintf("NetworkInterfaceId.%d", i+1), id)
+		}
+		nics, err := describe[networkInterface](ctx, p.c, "DescribeNetworkInterfaces", "networkInterfaceSet", params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get network interfaces: %w", err)
+		}
+		for _, nic := range nics {
+			nicIPs[nic.NetworkInterfaceID] = nic.PrivateIPAddress
+		}
+	}
+
+	var routes []guard.Route
+	for _, r := range table.Routes {
+		routes = append(routes, toGuardRoute(r, nicIPs))
+	}
+	return routes, nil
+}
+
+// toGuardRoute converts a route, naming its next hop like Azure does
+func toGuardRoute(r route, nicIPs map[string]string) guard.Route {
+	gr := guard.Route{Prefixes: []string{r.DestinationCidrBlock}, Active: r.State != "blackhole"}
+	switch {
+	case r.NetworkInterfaceID != "":
+		gr.NextHopType = guard.NextHopVirtualAppliance
+		if ip := nicIPs[r.NetworkInterfaceID]; ip != "" {
+			gr.NextHops = []string{ip}
+		} else {
+			gr.NextHops = []string{r.NetworkInterfaceID}
+		}
+	case r.InstanceID != "":
+		gr.NextHopType = guard.NextHopVirtualAppliance
+		gr.NextHops = []string{r.InstanceID}
+	case r.VpcPeeringConnectionID != "":
+		gr.NextHopType = "VNetPeering"
+		gr.NextHops = []string{r.VpcPeeringConnectionID}
+	case r.GatewayID == "local":
+		gr.NextHopType = "VnetLocal"
+	case strings.HasPrefix(r.GatewayID, "igw-"):
+		gr.NextHopType = "Internet"
+	case r.NatGatewayID != "":
+		gr.NextHopType = "NatGateway"
+	case r.TransitGatewayID != "":
+		gr.NextHopType = "TransitGateway"
+		gr.NextHops = []string{r.TransitGatewayID}
+	default:
+		gr.NextHopType = "None"
+	}
+	return gr
+}
+
+// RunCommand is not available without the SSM agent, which guard
+// instances do not run; use SSH to the instance instead.
+func (p *Provider) RunCommand(ctx context.Context, vmID, script string) (string, error) {
+	return "", fmt.Errorf("running commands on instances is not supported on AWS; use SSH")
+}
@@ -0,0 +1,104 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// TagManagedBy identifies resources managed by the AWS guard provider
+	TagManagedBy = "managed-by"
+	// TagManagedByValue is the tag value for guard-managed resources
+	TagManagedByValue = "morpheus-awsguard"
+	// TagGuardID identifies the guard a resource belongs to
+	TagGuardID = "guard-id"
+	// TagGroup identifies the group of guards created together
+	TagGroup = "guard-group"
+	// TagMeshCIDRs stores the mesh CIDRs as a comma-separated string
+	TagMeshCIDRs = "mesh-cidrs"
+	// TagWGPort stores the WireGuard port
+	TagWGPort = "wg-port"
+	// TagWGPublicKey stores the guard's WireGuard public key
+	TagWGPublicKey = "wg-public-key"
+	// TagName is the tag the AWS console shows as a resource's name
+	TagName = "Name"
+)
+
+// providerLabels are labels the guard provisioner sets for Azure only
+var providerLabels = map[string]bool{
+	"resource-group": true,
+}
+
+// resourceNames generates consistent AWS resource names from a guard ID.
+// AWS generates resource IDs, so the names are kept in Name tags and a
+// guard's resources are found by their guard-id tag.
+type resourceNames struct {
+	GuardID       string
+	VPC           string
+	Subnet        string
+	Gateway       string // Internet gateway
+	SecurityGroup string
+	Address       string // Elastic IP
+	NIC           string // Elastic network interface
+	Instance      string
+	KeyPair       string
+}
+
+func newResourceNames(guardID string) resourceNames {
+	return resourceNames{
+		GuardID:       guardID,
+		VPC:           fmt.Sprintf("%s-vpc", guardID),
+		Subnet:        fmt.Sprintf("%s-subnet", guardID),
+		Gateway:       fmt.Sprintf("%s-igw", guardID),
+		SecurityGroup: fmt.Sprintf("%s-sg", guardID),
+		Address:       fmt.Sprintf("%s-ip", guardID),
+		NIC:           fmt.Sprintf("%s-nic", guardID),
+		Instance:      fmt.Sprintf("%s-vm", guardID),
+		KeyPair:       fmt.Sprintf("%s-key", guardID),
+	}
+}
+
+// peeringName is the name of the peering between a guard and a VPC
+func peeringName(guardID, remoteVPC string) string {
+	return fmt.Sprintf("%s-to-%s", guardID, remoteVPC)
+}
+
+// guardTags returns the tags of a guard's resource with the given name
+func guardTags(guardID, name string) map[string]string {
+	return map[string]string{TagManagedBy: TagManagedByValue, TagGuardID: guardID, TagName: name}
+}
+
+// tag is an EC2 resource tag
+type tag struct {
+	Key   string `xml:"key"`
+	Value string `xml:"value"`
+}
+
+// tagValue returns the value of a tag, or ""
+func tagValue(tags []tag, key string) string {
+	for _, t := range tags {
+		if t.Key == key {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+// tagMap converts tags into a map
+func tagMap(tags []tag) map[string]string {
+	m := make(map[string]string, len(tags))
+	for _, t := range tags {
+		m[t.Key] = t.Value
+	}
+	return m
+}
+
+// sortedKeys returns the keys of m in order, for stable request parameters
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
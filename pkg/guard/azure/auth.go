@@ -0,0 +1,66 @@
+package azure
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/nimsforest/morpheus/pkg/cloudcreds"
+	"github.com/nimsforest/morpheus/pkg/config"
+)
+
+// NewCredential returns the Azure credential for an auth method of
+// machine.azure.auth. The tenant is optional except for client_secret;
+// for msi, clientID selects a user-assigned identity.
+func NewCredential(auth, tenantID, clientID, clientSecret string) (azcore.TokenCredential, error) {
+	var cred azcore.TokenCredential
+	var err error
+	switch auth {
+	case "client_secret", "":
+		cred, err = azidentity.NewClientSecretCredential(tenantID, clientID, clientSecret, nil)
+	case "cli":
+		cred, err = azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{TenantID: tenantID})
+	case "env":
+		cred, err = azidentity.NewEnvironmentCredential(nil)
+	case "msi":
+		opts := &azidentity.ManagedIdentityCredentialOptions{}
+		if clientID != "" {
+			opts.ID = azidentity.ClientID(clientID)
+		}
+		cred, err = azidentity.NewManagedIdentityCredential(opts)
+	case "default":
+		cred, err = azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{TenantID: tenantID})
+	default:
+		return nil, fmt.Errorf("unknown Azure auth method %q", auth)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure %s credentials: %w", auth, err)
+	}
+	return cred, nil
+}
+
+// NewConfiguredProvider creates an Azure guard provider as machine.azure
+// says: in the subscription of its profile or subscription_id, signed in
+// with its auth method, creating Spot VMs if spot is enabled.
+func NewConfiguredProvider(az config.AzureConfig) (*Provider, error) {
+	subscriptionID, tenantID := az.SubscriptionID, az.TenantID
+	if az.Profile != "" {
+		sub, err := cloudcreds.LoadAzureProfile(az.Profile)
+		if err != nil {
+			return nil, err
+		}
+		subscriptionID, tenantID = sub.ID, sub.TenantID
+	}
+	cred, err := NewCredential(az.GetAuth(), tenantID, az.ClientID, az.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	prov, err := NewProviderWithCredential(subscriptionID, cred, az.ResourceGroup, az.Location, az.VMSize, az.Image)
+	if err != nil {
+		return nil, err
+	}
+	if az.Spot.Enabled {
+		prov.SetSpot(&SpotOptions{EvictionPolicy: az.Spot.GetEvictionPolicy(), MaxPrice: az.Spot.GetMaxPrice()})
+	}
+	return prov, nil
+}
@@ -2,11 +2,13 @@ package azure
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
@@ -23,6 +25,7 @@ type Provider struct {
 	location       string
 	vmSize         string
 	image          string
+	spot           *SpotOptions // Create Spot VMs, if set
 
 	// Azure SDK clients
 	rgClient      *armresources.ResourceGroupsClient
@@ -40,13 +43,40 @@ type Provider struct {
 // Ensure Provider satisfies guard.GuardProvider
 var _ guard.GuardProvider = (*Provider)(nil)
 
+// SpotOptions configures the Azure Spot VMs a provider creates
+type SpotOptions struct {
+	EvictionPolicy string  // deallocate or delete
+	MaxPrice       float64 // USD per hour, -1 for up to the on-demand price
+}
+
+// SetSpot makes the provider create VMs as Spot instances, or regular ones
+// again if opts is nil
+func (p *Provider) SetSpot(opts *SpotOptions) {
+	p.spot = opts
+}
+
 // NewProvider creates a new Azure guard provider.
 func NewProvider(subscriptionID, tenantID, clientID, clientSecret, resourceGroup, location, vmSize, image string) (*Provider, error) {
 	cred, err := azidentity.NewClientSecretCredential(tenantID, clientID, clientSecret, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Azure credentials: %w", err)
 	}
+	return NewProviderWithCredential(subscriptionID, cred, resourceGroup, location, vmSize, image)
+}
+
+// NewCLIProvider creates an Azure guard provider that authenticates with
+// the Azure CLI's login ('az login') for the given subscription.
+func NewCLIProvider(subscriptionID, tenantID, resourceGroup, location, vmSize, image string) (*Provider, error) {
+	cred, err := azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{TenantID: tenantID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure CLI credentials: %w", err)
+	}
+	return NewProviderWithCredential(subscriptionID, cred, resourceGroup, location, vmSize, image)
+}
 
+// NewProviderWithCredential creates an Azure guard provider from any Azure
+// token credential.
+func NewProviderWithCredential(subscriptionID string, cred azcore.TokenCredential, resourceGroup, location, vmSize, image string) (*Provider, error) {
 	rgClient, err := armresources.NewResourceGroupsClient(subscriptionID, cred, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create resource groups client: %w", err)
@@ -160,7 +190,7 @@ func (p *Provider) CreateServer(ctx context.Context, req machine.CreateServerReq
 			OSProfile: &armcompute.OSProfile{
 				ComputerName:  to.Ptr(req.Name),
 				AdminUsername: to.Ptr("azureuser"),
-				CustomData:    to.Ptr(req.UserData),
+				CustomData:    to.Ptr(base64.StdEncoding.EncodeToString([]byte(req.UserData))), // Azure requires base64
 				LinuxConfiguration: &armcompute.LinuxConfiguration{
 					DisablePasswordAuthentication: to.Ptr(true),
 					SSH: &armcompute.SSHConfiguration{
@@ -181,6 +211,20 @@ func (p *Provider) CreateServer(ctx context.Context, req machine.CreateServerReq
 		},
 	}
 
+	if p.spot != nil {
+		vmParams.Properties.Priority = to.Ptr(armcompute.VirtualMachinePriorityTypesSpot)
+		vmParams.Properties.EvictionPolicy = to.Ptr(armcompute.VirtualMachineEvictionPolicyTypesDeallocate)
+		if p.spot.EvictionPolicy == "delete" {
+			vmParams.Properties.EvictionPolicy = to.Ptr(armcompute.VirtualMachineEvictionPolicyTypesDelete)
+		}
+		vmParams.Properties.BillingProfile = &armcompute.BillingProfile{MaxPrice: to.Ptr(p.spot.MaxPrice)}
+	}
+
+	// Guards of an HA pair are placed in different availability zones
+	if zone := req.Labels["availability-zone"]; zone != "" {
+		vmParams.Zones = []*string{to.Ptr(zone)}
+	}
+
 	rg := extractLabelOrDefault(req.Labels, "resource-group", p.resourceGroup)
 
 	poller, err := p.vmClient.BeginCreateOrUpdate(ctx, rg, req.Name, vmParams, nil)
@@ -359,7 +403,7 @@ func (p *Provider) ListServers(ctx context.Context, filters map[string]string) (
 
 // GetGuard reconstructs guard info from Azure resources by guard ID.
 func (p *Provider) GetGuard(ctx context.Context, guardID string) (*guard.Guard, error) {
-	names := newResourceNames(guardID, p.resourceGroup)
+	names := p.guardNames(ctx, guardID)
 
 	// Check resource group exists and has our tags
 	rgResp, err := p.rgClient.Get(ctx, names.ResourceGroup, nil)
@@ -392,6 +436,7 @@ func (p *Provider) GetGuard(ctx context.Context, guardID string) (*guard.Guard,
 			g.WireGuardPort = port
 		}
 	}
+	g.Tags = userTags(rgResp.Tags)
 
 	// Get VM info
 	vmResp, err := p.vmClient.Get(ctx, names.ResourceGroup, names.VM, &armcompute.VirtualMachinesClientGetOptions{
@@ -401,6 +446,10 @@ func (p *Provider) GetGuard(ctx context.Context, guardID string) (*guard.Guard,
 		if vmResp.ID != nil {
 			g.ServerID = *vmResp.ID
 		}
+		g.Group = tagValue(vmResp.Tags, TagGroup)
+		g.Role = tagValue(vmResp.Tags, TagRole)
+		g.PublicKey = tagValue(vmResp.Tags, TagWGPublicKey)
+		g.Metadata = spotMetadata(vmResp.Properties)
 		g.Status = "running"
 		if vmResp.Properties != nil && vmResp.Properties.InstanceView != nil {
 			for _, status := range vmResp.Properties.InstanceView.Statuses {
@@ -437,7 +486,8 @@ func (p *Provider) GetGuard(ctx context.Context, guardID string) (*guard.Guard,
 	if err == nil && vnetResp.ID != nil {
 		g.VNetID = *vnetResp.ID
 
-		// Check peerings
+		// Check peerings, and the route tables created for them (best effort)
+		tables, _ := p.ListRouteTables(ctx, guardID)
 		if vnetResp.Properties != nil && vnetResp.Properties.VirtualNetworkPeerings != nil {
 			for _, peering := range vnetResp.Properties.VirtualNetworkPeerings {
 				if peering.Name != nil && peering.Properties != nil && peering.Properties.RemoteVirtualNetwork != nil {
@@ -446,6 +496,10 @@ func (p *Provider) GetGuard(ctx context.Context, guardID string) (*guard.Guard,
 					}
 					if peering.Properties.RemoteVirtualNetwork.ID != nil {
 						pi.RemoteVNetID = *peering.Properties.RemoteVirtualNetwork.ID
+						pi.RouteTableID = routeTableForVNet(tables, pi.RemoteVNetID)
+					}
+					if peering.Properties.PeeringState != nil {
+						pi.State = string(*peering.Properties.PeeringState)
 					}
 					g.Peerings = append(g.Peerings, pi)
 				}
@@ -499,6 +553,7 @@ func (p *Provider) ListGuards(ctx context.Context) ([]*guard.Guard, error) {
 					g.WireGuardPort = port
 				}
 			}
+			g.Tags = userTags(rg.Tags)
 
 			// Quick VM status check
 			vmName := fmt.Sprintf("%s-vm", guardID)
@@ -506,6 +561,10 @@ func (p *Provider) ListGuards(ctx context.Context) ([]*guard.Guard, error) {
 				Expand: to.Ptr(armcompute.InstanceViewTypesInstanceView),
 			})
 			if err == nil {
+				g.Group = tagValue(vmResp.Tags, TagGroup)
+				g.Role = tagValue(vmResp.Tags, TagRole)
+				g.PublicKey = tagValue(vmResp.Tags, TagWGPublicKey)
+				g.Metadata = spotMetadata(vmResp.Properties)
 				g.Status = "running"
 				if vmResp.Properties != nil && vmResp.Properties.InstanceView != nil {
 					for _, status := range vmResp.Properties.InstanceView.Statuses {
@@ -551,3 +610,19 @@ func extractLabelOrDefault(labels map[string]string, key, defaultVal string) str
 	}
 	return defaultVal
 }
+
+// spotMetadata returns the guard metadata of a Spot VM: its priority,
+// eviction policy and max price
+func spotMetadata(props *armcompute.VirtualMachineProperties) map[string]string {
+	if props == nil || props.Priority == nil || *props.Priority != armcompute.VirtualMachinePriorityTypesSpot {
+		return nil
+	}
+	meta := map[string]string{guard.MetaPriority: guard.PrioritySpot}
+	if props.EvictionPolicy != nil {
+		meta[guard.MetaEvictionPolicy] = strings.ToLower(string(*props.EvictionPolicy))
+	}
+	if props.BillingProfile != nil && props.BillingProfile.MaxPrice != nil {
+		meta[guard.MetaMaxPrice] = strconv.FormatFloat(*props.BillingProfile.MaxPrice, 'f', -1, 64)
+	}
+	return meta
+}
@@ -0,0 +1,77 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v5"
+	"github.com/nimsforest/morpheus/pkg/guard"
+)
+
+var _ guard.Inspector = (*Provider)(nil)
+
+// InspectGuard reports the inbound rules of a guard's NSG and whether IP
+// forwarding is enabled on its NIC.
+func (p *Provider) InspectGuard(ctx context.Context, g *guard.Guard) (*guard.Inspection, error) {
+	names := p.guardNames(ctx, g.ID)
+	insp := &guard.Inspection{}
+
+	nsg, err := p.nsgClient.Get(ctx, names.ResourceGroup, names.NSG, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get NSG %s: %w", names.NSG, err)
+	}
+	if nsg.Properties != nil {
+		for _, rule := range nsg.Properties.SecurityRules {
+			if rule.Properties == nil || rule.Properties.Direction == nil || *rule.Properties.Direction != armnetwork.SecurityRuleDirectionInbound {
+				continue
+			}
+			insp.InboundRules = append(insp.InboundRules, firewallRule(rule))
+		}
+	}
+	sort.SliceStable(insp.InboundRules, func(i, j int) bool {
+		return insp.InboundRules[i].Priority < insp.InboundRules[j].Priority
+	})
+
+	nic, err := p.nicClient.Get(ctx, names.ResourceGroup, names.NIC, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get NIC %s: %w", names.NIC, err)
+	}
+	insp.IPForwarding = nic.Properties != nil && nic.Properties.EnableIPForwarding != nil && *nic.Properties.EnableIPForwarding
+
+	return insp, nil
+}
+
+// firewallRule converts an NSG security rule
+func firewallRule(rule *armnetwork.SecurityRule) guard.FirewallRule {
+	props := rule.Properties
+	r := guard.FirewallRule{
+		Allow: props.Access != nil && *props.Access == armnetwork.SecurityRuleAccessAllow,
+	}
+	if rule.Name != nil {
+		r.Name = *rule.Name
+	}
+	if props.Protocol != nil {
+		r.Protocol = string(*props.Protocol)
+	}
+	if props.Priority != nil {
+		r.Priority = int(*props.Priority)
+	}
+	if props.DestinationPortRange != nil {
+		r.Ports = append(r.Ports, *props.DestinationPortRange)
+	}
+	for _, port := range props.DestinationPortRanges {
+		if port != nil {
+			r.Ports = append(r.Ports, *port)
+		}
+	}
+	if props.SourceAddressPrefix != nil {
+		r.Sources = append(r.Sources, *props.SourceAddressPrefix)
+	}
+	for _, prefix := range props.SourceAddressPrefixes {
+		if prefix != nil {
+			r.Sources = append(r.Sources, *prefix)
+		}
+	}
+	return r
+}
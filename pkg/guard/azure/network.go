@@ -3,6 +3,7 @@ package azure
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v5"
@@ -13,7 +14,7 @@ import (
 // EnsureNetwork creates the full networking stack for a guard.
 func (p *Provider) EnsureNetwork(ctx context.Context, req guard.NetworkRequest) (*guard.NetworkInfo, error) {
 	names := newResourceNames(req.GuardID, req.ResourceGroup)
-	tags := guardTags(req.GuardID, nil, req.WireGuardPort)
+	tags := guardTags(req.GuardID, nil, req.WireGuardPort, req.Group, req.Tags)
 
 	// 1. Ensure resource group
 	fmt.Printf("      Creating resource group %s...\n", names.ResourceGroup)
@@ -27,24 +28,25 @@ func (p *Provider) EnsureNetwork(ctx context.Context, req guard.NetworkRequest)
 
 	// 2. Create NSG with SSH + WireGuard rules
 	fmt.Printf("      Creating NSG %s...\n", names.NSG)
+	sshRule := &armnetwork.SecurityRule{
+		Name: to.Ptr(sshRuleName),
+		Properties: &armnetwork.SecurityRulePropertiesFormat{
+			Priority:                 to.Ptr[int32](100),
+			Protocol:                 to.Ptr(armnetwork.SecurityRuleProtocolTCP),
+			Access:                   to.Ptr(armnetwork.SecurityRuleAccessAllow),
+			Direction:                to.Ptr(armnetwork.SecurityRuleDirectionInbound),
+			SourcePortRange:          to.Ptr("*"),
+			DestinationAddressPrefix: to.Ptr("*"),
+			DestinationPortRange:     to.Ptr("22"),
+		},
+	}
+	setSourcePrefixes(sshRule.Properties, req.SSHSources)
 	nsgPoller, err := p.nsgClient.BeginCreateOrUpdate(ctx, names.ResourceGroup, names.NSG, armnetwork.SecurityGroup{
 		Location: to.Ptr(req.Location),
 		Tags:     tags,
 		Properties: &armnetwork.SecurityGroupPropertiesFormat{
 			SecurityRules: []*armnetwork.SecurityRule{
-				{
-					Name: to.Ptr("AllowSSH"),
-					Properties: &armnetwork.SecurityRulePropertiesFormat{
-						Priority:                 to.Ptr[int32](100),
-						Protocol:                 to.Ptr(armnetwork.SecurityRuleProtocolTCP),
-						Access:                   to.Ptr(armnetwork.SecurityRuleAccessAllow),
-						Direction:                to.Ptr(armnetwork.SecurityRuleDirectionInbound),
-						SourceAddressPrefix:      to.Ptr("*"),
-						SourcePortRange:          to.Ptr("*"),
-						DestinationAddressPrefix: to.Ptr("*"),
-						DestinationPortRange:     to.Ptr("22"),
-					},
-				},
+				sshRule,
 				{
 					Name: to.Ptr("AllowWireGuard"),
 					Properties: &armnetwork.SecurityRulePropertiesFormat{
@@ -182,9 +184,21 @@ func (p *Provider) EnsureNetwork(ctx context.Context, req guard.NetworkRequest)
 	}, nil
 }
 
-// CleanupNetwork removes all guard resources by deleting the resource group.
+// CleanupNetwork removes all guard resources by deleting the resource group,
+// after the route tables created by PeerNetwork in other resource groups.
 func (p *Provider) CleanupNetwork(ctx context.Context, guardID string) error {
-	names := newResourceNames(guardID, p.resourceGroup)
+	names := p.guardNames(ctx, guardID)
+
+	tables, err := p.ListRouteTables(ctx, guardID)
+	if err != nil {
+		return err
+	}
+	for _, rt := range tables {
+		fmt.Printf("   Deleting route table %s...\n", rt.Name)
+		if err := p.DeleteRouteTable(ctx, rt.ID); err != nil {
+			return err
+		}
+	}
 
 	fmt.Printf("   Deleting resource group %s...\n", names.ResourceGroup)
 	poller, err := p.rgClient.BeginDelete(ctx, names.ResourceGroup, nil)
@@ -244,7 +258,7 @@ func (p *Provider) EnsureNSGRule(ctx context.Context, req guard.NSGRuleRequest)
 
 // PeerNetwork creates bidirectional VNet peering and a route table.
 func (p *Provider) PeerNetwork(ctx context.Context, req guard.PeerRequest) error {
-	names := newResourceNames(req.GuardID, p.resourceGroup)
+	names := p.guardNames(ctx, req.GuardID)
 
 	// Extract VNet names from resource IDs
 	guardVNetName := names.VNet
@@ -295,64 +309,31 @@ func (p *Provider) PeerNetwork(ctx context.Context, req guard.PeerRequest) error
 	// 3. Create route table on remote subnet for mesh CIDRs
 	if len(req.MeshCIDRs) > 0 && req.SubnetID != "" {
 		fmt.Printf("   Creating route table for mesh CIDRs...\n")
-		rtName := fmt.Sprintf("%s-routes", req.PeeringName)
-		var routes []*armnetwork.Route
-		for i, cidr := range req.MeshCIDRs {
-			routes = append(routes, &armnetwork.Route{
-				Name: to.Ptr(fmt.Sprintf("mesh-route-%d", i)),
-				Properties: &armnetwork.RoutePropertiesFormat{
-					AddressPrefix:    to.Ptr(cidr),
-					NextHopType:      to.Ptr(armnetwork.RouteNextHopTypeVirtualAppliance),
-					NextHopIPAddress: to.Ptr(req.GuardPrivateIP),
-				},
-			})
-		}
-
-		rtPoller, err := p.rtClient.BeginCreateOrUpdate(ctx, remoteRG, rtName, armnetwork.RouteTable{
-			Location: to.Ptr(p.location),
-			Properties: &armnetwork.RouteTablePropertiesFormat{
-				Routes: routes,
-			},
-		}, nil)
+		rtID, _, err := p.ensureMeshRouteTable(ctx, req)
 		if err != nil {
-			return fmt.Errorf("failed to begin route table creation: %w", err)
+			return err
 		}
-		rtResp, err := rtPoller.PollUntilDone(ctx, nil)
-		if err != nil {
-			return fmt.Errorf("failed to create route table: %w", err)
-		}
-
-		// Associate route table with remote subnet
-		subnetName := extractResourceName(req.SubnetID)
-		remoteVNetForSubnet := extractParentResourceName(req.SubnetID)
-		subnetRG := extractResourceGroup(req.SubnetID)
-
-		// Get current subnet config
-		subnetResp, err := p.subnetClient.Get(ctx, subnetRG, remoteVNetForSubnet, subnetName, nil)
-		if err != nil {
-			return fmt.Errorf("failed to get remote subnet: %w", err)
-		}
-
-		subnetResp.Properties.RouteTable = &armnetwork.RouteTable{
-			ID: rtResp.ID,
-		}
-
-		subPoller, err := p.subnetClient.BeginCreateOrUpdate(ctx, subnetRG, remoteVNetForSubnet, subnetName, subnetResp.Subnet, nil)
-		if err != nil {
-			return fmt.Errorf("failed to begin subnet update: %w", err)
-		}
-		_, err = subPoller.PollUntilDone(ctx, nil)
-		if err != nil {
-			return fmt.Errorf("failed to update subnet with route table: %w", err)
+		if err := p.associateRouteTable(ctx, req.SubnetID, rtID); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-// UnpeerNetwork removes VNet peering.
+// UnpeerNetwork removes VNet peering and the route tables created for the
+// remote VNet.
 func (p *Provider) UnpeerNetwork(ctx context.Context, guardID, peeringName string) error {
-	names := newResourceNames(guardID, p.resourceGroup)
+	names := p.guardNames(ctx, guardID)
+
+	peering, err := p.peeringClient.Get(ctx, names.ResourceGroup, names.VNet, peeringName, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get peering: %w", err)
+	}
+	var remoteVNetID string
+	if peering.Properties != nil && peering.Properties.RemoteVirtualNetwork != nil && peering.Properties.RemoteVirtualNetwork.ID != nil {
+		remoteVNetID = *peering.Properties.RemoteVirtualNetwork.ID
+	}
 
 	poller, err := p.peeringClient.BeginDelete(ctx, names.ResourceGroup, names.VNet, peeringName, nil)
 	if err != nil {
@@ -362,6 +343,23 @@ func (p *Provider) UnpeerNetwork(ctx context.Context, guardID, peeringName strin
 	if err != nil {
 		return fmt.Errorf("failed to delete peering: %w", err)
 	}
+
+	if remoteVNetID == "" {
+		return nil
+	}
+	tables, err := p.ListRouteTables(ctx, guardID)
+	if err != nil {
+		return err
+	}
+	for _, rt := range tables {
+		if !strings.EqualFold(rt.RemoteVNetID, remoteVNetID) {
+			continue
+		}
+		fmt.Printf("   Deleting route table %s...\n", rt.Name)
+		if err := p.DeleteRouteTable(ctx, rt.ID); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
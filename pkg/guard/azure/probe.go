@@ -0,0 +1,91 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v5"
+	"github.com/nimsforest/morpheus/pkg/guard"
+)
+
+// EffectiveRoutes returns the routes in effect on a VM's primary NIC.
+func (p *Provider) EffectiveRoutes(ctx context.Context, vmID string) ([]guard.Route, error) {
+	vm, err := p.vmClient.Get(ctx, extractResourceGroup(vmID), extractResourceName(vmID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VM: %w", err)
+	}
+	nicID := ""
+	if vm.Properties != nil && vm.Properties.NetworkProfile != nil {
+		for _, ref := range vm.Properties.NetworkProfile.NetworkInterfaces {
+			if ref.ID == nil {
+				continue
+			}
+			primary := ref.Properties != nil && ref.Properties.Primary != nil && *ref.Properties.Primary
+			if nicID == "" || primary {
+				nicID = *ref.ID
+			}
+		}
+	}
+	if nicID == "" {
+		return nil, fmt.Errorf("VM %s has no network interface", extractResourceName(vmID))
+	}
+
+	// The VM must be running for Azure to compute its routes
+	poller, err := p.nicClient.BeginGetEffectiveRouteTable(ctx, extractResourceGroup(nicID), extractResourceName(nicID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin effective route lookup: %w", err)
+	}
+	resp, err := poller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get effective routes: %w", err)
+	}
+
+	var routes []guard.Route
+	for _, r := range resp.Value {
+		route := guard.Route{
+			Active: r.State != nil && *r.State == armnetwork.EffectiveRouteStateActive,
+		}
+		if r.NextHopType != nil {
+			route.NextHopType = string(*r.NextHopType)
+		}
+		for _, prefix := range r.AddressPrefix {
+			if prefix != nil {
+				route.Prefixes = append(route.Prefixes, *prefix)
+			}
+		}
+		for _, ip := range r.NextHopIPAddress {
+			if ip != nil {
+				route.NextHops = append(route.NextHops, *ip)
+			}
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// RunCommand runs a shell script on a VM with Azure Run Command and returns
+// its output.
+func (p *Provider) RunCommand(ctx context.Context, vmID, script string) (string, error) {
+	poller, err := p.vmClient.BeginRunCommand(ctx, extractResourceGroup(vmID), extractResourceName(vmID), armcompute.RunCommandInput{
+		CommandID: to.Ptr("RunShellScript"),
+		Script:    []*string{to.Ptr(script)},
+	}, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin run command: %w", err)
+	}
+	resp, err := poller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("run command failed: %w", err)
+	}
+
+	var out strings.Builder
+	for _, status := range resp.Value {
+		if status.Message != nil {
+			out.WriteString(*status.Message)
+		}
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,248 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v5"
+	"github.com/nimsforest/morpheus/pkg/guard"
+)
+
+// ListRouteTables returns the route tables created by PeerNetwork for a
+// guard, or for all guards if guardID is empty. They live in the remote
+// VNets' resource groups, so the whole subscription is searched for tables
+// tagged managed-by=morpheus-azureguard.
+func (p *Provider) ListRouteTables(ctx context.Context, guardID string) ([]guard.RouteTable, error) {
+	var tables []guard.RouteTable
+
+	pager := p.rtClient.NewListAllPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list route tables: %w", err)
+		}
+		for _, rt := range page.Value {
+			if rt.ID == nil || rt.Tags == nil || rt.Tags[TagManagedBy] == nil || *rt.Tags[TagManagedBy] != TagManagedByValue {
+				continue
+			}
+			if rt.Tags[TagGuardID] == nil || (guardID != "" && *rt.Tags[TagGuardID] != guardID) {
+				continue
+			}
+
+			t := guard.RouteTable{
+				ID:            *rt.ID,
+				Name:          extractResourceName(*rt.ID),
+				ResourceGroup: extractResourceGroup(*rt.ID),
+				GuardID:       *rt.Tags[TagGuardID],
+			}
+			if rt.Tags[TagRemoteVNet] != nil {
+				t.RemoteVNetID = *rt.Tags[TagRemoteVNet]
+			}
+			if rt.Properties != nil {
+				for _, route := range rt.Properties.Routes {
+					if route.Properties == nil {
+						continue
+					}
+					if route.Properties.AddressPrefix != nil {
+						t.Routes = append(t.Routes, *route.Properties.AddressPrefix)
+					}
+					if route.Properties.NextHopIPAddress != nil && !slices.Contains(t.NextHops, *route.Properties.NextHopIPAddress) {
+						t.NextHops = append(t.NextHops, *route.Properties.NextHopIPAddress)
+					}
+				}
+				for _, subnet := range rt.Properties.Subnets {
+					if subnet.ID != nil {
+						t.Subnets = append(t.Subnets, *subnet.ID)
+					}
+				}
+			}
+			tables = append(tables, t)
+		}
+	}
+
+	return tables, nil
+}
+
+var _ guard.RouteSwitcher = (*Provider)(nil)
+
+// SwitchRouteTable points every route of a route table at nextHop and tags
+// it with guardID, for the failover of an HA pair. Subnets keep the table,
+// so their traffic moves to the other guard at once.
+func (p *Provider) SwitchRouteTable(ctx context.Context, routeTableID, guardID, nextHop string) error {
+	rgName := extractResourceGroup(routeTableID)
+	rtName := extractResourceName(routeTableID)
+
+	rtResp, err := p.rtClient.Get(ctx, rgName, rtName, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get route table %s: %w", rtName, err)
+	}
+	rt := rtResp.RouteTable
+	if rt.Properties != nil {
+		for _, route := range rt.Properties.Routes {
+			if route.Properties == nil {
+				continue
+			}
+			route.Properties.NextHopType = to.Ptr(armnetwork.RouteNextHopTypeVirtualAppliance)
+			route.Properties.NextHopIPAddress = to.Ptr(nextHop)
+		}
+	}
+	if rt.Tags == nil {
+		rt.Tags = make(map[string]*string)
+	}
+	rt.Tags[TagGuardID] = to.Ptr(guardID)
+
+	poller, err := p.rtClient.BeginCreateOrUpdate(ctx, rgName, rtName, rt, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin route table update: %w", err)
+	}
+	if _, err := poller.PollUntilDone(ctx, nil); err != nil {
+		return fmt.Errorf("failed to update route table %s: %w", rtName, err)
+	}
+	return nil
+}
+
+// ensureMeshRouteTable creates or updates the route table of a peering in
+// the remote VNet's resource group, routing the mesh CIDRs to the guard.
+// It returns the table's ID and whether it existed before.
+func (p *Provider) ensureMeshRouteTable(ctx context.Context, req guard.PeerRequest) (string, bool, error) {
+	remoteRG := extractResourceGroup(req.RemoteVNetID)
+	rtName := fmt.Sprintf("%s-routes", req.PeeringName)
+	_, err := p.rtClient.Get(ctx, remoteRG, rtName, nil)
+	existed := err == nil
+
+	var routes []*armnetwork.Route
+	for i, cidr := range req.MeshCIDRs {
+		routes = append(routes, &armnetwork.Route{
+			Name: to.Ptr(fmt.Sprintf("mesh-route-%d", i)),
+			Properties: &armnetwork.RoutePropertiesFormat{
+				AddressPrefix:    to.Ptr(cidr),
+				NextHopType:      to.Ptr(armnetwork.RouteNextHopTypeVirtualAppliance),
+				NextHopIPAddress: to.Ptr(req.GuardPrivateIP),
+			},
+		})
+	}
+
+	rtPoller, err := p.rtClient.BeginCreateOrUpdate(ctx, remoteRG, rtName, armnetwork.RouteTable{
+		Location: to.Ptr(p.location),
+		Tags:     routeTableTags(req.GuardID, req.RemoteVNetID, req.Tags),
+		Properties: &armnetwork.RouteTablePropertiesFormat{
+			Routes: routes,
+		},
+	}, nil)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to begin route table creation: %w", err)
+	}
+	rtResp, err := rtPoller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create route table: %w", err)
+	}
+	return *rtResp.ID, existed, nil
+}
+
+// associateRouteTable gives a subnet a route table, replacing the one it
+// has, if any.
+func (p *Provider) associateRouteTable(ctx context.Context, subnetID, routeTableID string) error {
+	subnetName := extractResourceName(subnetID)
+	vnetName := extractParentResourceName(subnetID)
+	subnetRG := extractResourceGroup(subnetID)
+
+	subnetResp, err := p.subnetClient.Get(ctx, subnetRG, vnetName, subnetName, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get remote subnet: %w", err)
+	}
+	return p.setSubnetRouteTable(ctx, subnetRG, vnetName, subnetResp.Subnet, routeTableID)
+}
+
+// setSubnetRouteTable updates a subnet as read from Azure with a route
+// table.
+func (p *Provider) setSubnetRouteTable(ctx context.Context, subnetRG, vnetName string, subnet armnetwork.Subnet, routeTableID string) error {
+	if subnet.Name == nil || subnet.Properties == nil {
+		return fmt.Errorf("subnet has no properties")
+	}
+	subnet.Properties.RouteTable = &armnetwork.RouteTable{
+		ID: to.Ptr(routeTableID),
+	}
+	poller, err := p.subnetClient.BeginCreateOrUpdate(ctx, subnetRG, vnetName, *subnet.Name, subnet, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin subnet update: %w", err)
+	}
+	if _, err := poller.PollUntilDone(ctx, nil); err != nil {
+		return fmt.Errorf("failed to update subnet with route table: %w", err)
+	}
+	return nil
+}
+
+// DeleteRouteTable disassociates a route table from its subnets and
+// deletes it. Azure refuses to delete a table that is still associated.
+func (p *Provider) DeleteRouteTable(ctx context.Context, routeTableID string) error {
+	rgName := extractResourceGroup(routeTableID)
+	rtName := extractResourceName(routeTableID)
+
+	rtResp, err := p.rtClient.Get(ctx, rgName, rtName, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get route table %s: %w", rtName, err)
+	}
+
+	if rtResp.Properties != nil {
+		for _, subnet := range rtResp.Properties.Subnets {
+			if subnet.ID == nil {
+				continue
+			}
+			if err := p.disassociateRouteTable(ctx, *subnet.ID, routeTableID); err != nil {
+				return err
+			}
+		}
+	}
+
+	poller, err := p.rtClient.BeginDelete(ctx, rgName, rtName, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin route table deletion: %w", err)
+	}
+	_, err = poller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete route table %s: %w", rtName, err)
+	}
+	return nil
+}
+
+// disassociateRouteTable removes a route table from a subnet, unless the
+// subnet has meanwhile been given another one.
+func (p *Provider) disassociateRouteTable(ctx context.Context, subnetID, routeTableID string) error {
+	subnetName := extractResourceName(subnetID)
+	vnetName := extractParentResourceName(subnetID)
+	subnetRG := extractResourceGroup(subnetID)
+
+	subnetResp, err := p.subnetClient.Get(ctx, subnetRG, vnetName, subnetName, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get subnet %s: %w", subnetName, err)
+	}
+	props := subnetResp.Properties
+	if props == nil || props.RouteTable == nil || props.RouteTable.ID == nil || !strings.EqualFold(*props.RouteTable.ID, routeTableID) {
+		return nil
+	}
+
+	props.RouteTable = nil
+	poller, err := p.subnetClient.BeginCreateOrUpdate(ctx, subnetRG, vnetName, subnetName, subnetResp.Subnet, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin subnet update: %w", err)
+	}
+	_, err = poller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to remove route table from subnet %s: %w", subnetName, err)
+	}
+	return nil
+}
+
+// routeTableForVNet returns the ID of the route table created for a
+// remote VNet, if any
+func routeTableForVNet(tables []guard.RouteTable, remoteVNetID string) string {
+	for _, rt := range tables {
+		if strings.EqualFold(rt.RemoteVNetID, remoteVNetID) {
+			return rt.ID
+		}
+	}
+	return ""
+}
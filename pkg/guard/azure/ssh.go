@@ -0,0 +1,71 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v5"
+	"github.com/nimsforest/morpheus/pkg/guard"
+)
+
+var _ guard.SSHAccess = (*Provider)(nil)
+
+// sshRuleName is the NSG rule allowing SSH to a guard
+const sshRuleName = "AllowSSH"
+
+// SSHSources returns the source prefixes of the AllowSSH rule of a
+// guard's NSG.
+func (p *Provider) SSHSources(ctx context.Context, g *guard.Guard) ([]string, error) {
+	names := p.guardNames(ctx, g.ID)
+	rule, err := p.secRuleClient.Get(ctx, names.ResourceGroup, names.NSG, sshRuleName, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get NSG rule %s: %w", sshRuleName, err)
+	}
+	if rule.Properties == nil {
+		return nil, nil
+	}
+	return firewallRule(&rule.SecurityRule).Sources, nil
+}
+
+// SetSSHSources replaces the source prefixes of the AllowSSH rule of a
+// guard's NSG, keeping the rest of the rule.
+func (p *Provider) SetSSHSources(ctx context.Context, g *guard.Guard, sources []string) error {
+	if len(sources) == 0 {
+		return fmt.Errorf("an SSH rule needs at least one source")
+	}
+	names := p.guardNames(ctx, g.ID)
+	resp, err := p.secRuleClient.Get(ctx, names.ResourceGroup, names.NSG, sshRuleName, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get NSG rule %s: %w", sshRuleName, err)
+	}
+	rule := resp.SecurityRule
+	if rule.Properties == nil {
+		return fmt.Errorf("NSG rule %s has no properties", sshRuleName)
+	}
+	setSourcePrefixes(rule.Properties, sources)
+
+	poller, err := p.secRuleClient.BeginCreateOrUpdate(ctx, names.ResourceGroup, names.NSG, sshRuleName, rule, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin NSG rule update: %w", err)
+	}
+	if _, err := poller.PollUntilDone(ctx, nil); err != nil {
+		return fmt.Errorf("failed to update NSG rule %s: %w", sshRuleName, err)
+	}
+	return nil
+}
+
+// setSourcePrefixes sets the sources of a security rule: one goes in
+// SourceAddressPrefix, several in SourceAddressPrefixes, as Azure accepts
+// only one of them. No sources is any address.
+func setSourcePrefixes(props *armnetwork.SecurityRulePropertiesFormat, sources []string) {
+	props.SourceAddressPrefix, props.SourceAddressPrefixes = nil, nil
+	switch len(sources) {
+	case 0:
+		props.SourceAddressPrefix = to.Ptr(guard.AnySource)
+	case 1:
+		props.SourceAddressPrefix = to.Ptr(sources[0])
+	default:
+		props.SourceAddressPrefixes = to.SliceOfPtrs(sources...)
+	}
+}
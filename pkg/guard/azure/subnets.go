@@ -0,0 +1,98 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nimsforest/morpheus/pkg/guard"
+)
+
+var _ guard.SubnetRouter = (*Provider)(nil)
+
+// reservedSubnets are subnets of Azure services that must not get the
+// mesh route table: gateways and firewalls route themselves, and Bastion
+// refuses route tables.
+var reservedSubnets = []string{
+	"GatewaySubnet",
+	"AzureFirewallSubnet",
+	"AzureFirewallManagementSubnet",
+	"AzureBastionSubnet",
+	"RouteServerSubnet",
+}
+
+// RouteAllSubnets creates or updates the mesh route table of a peering
+// and associates it with every subnet of the remote VNet, except the
+// reserved subnets of Azure services and subnets with another route
+// table, which is not replaced.
+func (p *Provider) RouteAllSubnets(ctx context.Context, req guard.PeerRequest) (*guard.SubnetRouteReport, error) {
+	if len(req.MeshCIDRs) == 0 {
+		return nil, fmt.Errorf("guard %s has no mesh CIDRs to route", req.GuardID)
+	}
+	remoteRG := extractResourceGroup(req.RemoteVNetID)
+	remoteVNetName := extractResourceName(req.RemoteVNetID)
+
+	fmt.Printf("   Creating route table for mesh CIDRs...\n")
+	rtID, existed, err := p.ensureMeshRouteTable(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	report := &guard.SubnetRouteReport{
+		RouteTableID:      rtID,
+		RouteTableName:    extractResourceName(rtID),
+		RouteTableExisted: existed,
+	}
+
+	var errs []error
+	pager := p.subnetClient.NewListPager(remoteRG, remoteVNetName, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return report, fmt.Errorf("failed to list subnets of %s: %w", remoteVNetName, err)
+		}
+		for _, subnet := range page.Value {
+			if subnet == nil || subnet.Name == nil {
+				continue
+			}
+			s := guard.SubnetRoute{Name: *subnet.Name}
+			if subnet.ID != nil {
+				s.ID = *subnet.ID
+			}
+			var current string
+			if subnet.Properties != nil && subnet.Properties.RouteTable != nil && subnet.Properties.RouteTable.ID != nil {
+				current = *subnet.Properties.RouteTable.ID
+			}
+
+			switch {
+			case isReservedSubnet(s.Name):
+				s.Action, s.Detail = guard.SubnetSkipped, "reserved for an Azure service"
+			case strings.EqualFold(current, rtID):
+				s.Action = guard.SubnetUnchanged
+			case current != "":
+				s.Action = guard.SubnetSkipped
+				s.Detail = fmt.Sprintf("has route table %s; add the mesh routes to it, or peer with --subnet to replace it", extractResourceName(current))
+			default:
+				fmt.Printf("   Associating route table with subnet %s...\n", s.Name)
+				if err := p.setSubnetRouteTable(ctx, remoteRG, remoteVNetName, *subnet, rtID); err != nil {
+					s.Action, s.Detail = guard.SubnetFailed, err.Error()
+					errs = append(errs, fmt.Errorf("%s: %w", s.Name, err))
+				} else {
+					s.Action = guard.SubnetAssociated
+				}
+			}
+			report.Subnets = append(report.Subnets, s)
+		}
+	}
+	return report, errors.Join(errs...)
+}
+
+// isReservedSubnet reports whether a subnet belongs to an Azure service
+func isReservedSubnet(name string) bool {
+	for _, r := range reservedSubnets {
+		if strings.EqualFold(name, r) {
+			return true
+		}
+	}
+	return false
+}
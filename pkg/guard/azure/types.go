@@ -1,8 +1,13 @@
 package azure
 
 import (
+	"context"
 	"fmt"
 	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/nimsforest/morpheus/pkg/guard"
 )
 
 const (
@@ -16,6 +21,14 @@ const (
 	TagMeshCIDRs = "mesh-cidrs"
 	// TagWGPort stores the WireGuard port
 	TagWGPort = "wg-port"
+	// TagGroup identifies the group of guards created together
+	TagGroup = "guard-group"
+	// TagRole stores the role of a guard of an HA pair: primary or standby
+	TagRole = "guard-role"
+	// TagWGPublicKey stores the guard's WireGuard public key
+	TagWGPublicKey = "wg-public-key"
+	// TagRemoteVNet stores the VNet a route table was created for
+	TagRemoteVNet = "remote-vnet"
 )
 
 // resourceNames generates consistent Azure resource names from a guard ID.
@@ -47,18 +60,80 @@ func newResourceNames(guardID, rgPrefix string) resourceNames {
 	}
 }
 
-// guardTags returns the standard tags for a guard resource.
-func guardTags(guardID string, meshCIDRs []string, wgPort int) map[string]*string {
+// guardNames returns the resource names of a guard. Its resource group is
+// the configured one, unless the guard was created in another, as guards
+// of a multi-location group are; that one is found by its guard-id tag.
+func (p *Provider) guardNames(ctx context.Context, guardID string) resourceNames {
+	names := newResourceNames(guardID, p.resourceGroup)
+	if rg, err := p.rgClient.Get(ctx, names.ResourceGroup, nil); err == nil && tagValue(rg.Tags, TagGuardID) == guardID {
+		return names
+	}
+	pager := p.rgClient.NewListPager(&armresources.ResourceGroupsClientListOptions{
+		Filter: to.Ptr(fmt.Sprintf("tagName eq '%s' and tagValue eq '%s'", TagGuardID, guardID)),
+	})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			break
+		}
+		for _, rg := range page.Value {
+			if rg.Name != nil && tagValue(rg.Tags, TagManagedBy) == TagManagedByValue {
+				return newResourceNames(guardID, *rg.Name)
+			}
+		}
+	}
+	return names
+}
+
+// guardTags returns the standard tags for a guard resource, with the
+// user's tags.
+func guardTags(guardID string, meshCIDRs []string, wgPort int, group string, user map[string]string) map[string]*string {
 	managed := TagManagedByValue
 	gid := guardID
 	cidrs := strings.Join(meshCIDRs, ",")
 	port := fmt.Sprintf("%d", wgPort)
-	return map[string]*string{
-		TagManagedBy: &managed,
-		TagGuardID:   &gid,
-		TagMeshCIDRs: &cidrs,
-		TagWGPort:    &port,
+	tags := tagPtrs(user)
+	tags[TagManagedBy] = &managed
+	tags[TagGuardID] = &gid
+	tags[TagMeshCIDRs] = &cidrs
+	tags[TagWGPort] = &port
+	if group != "" {
+		tags[TagGroup] = &group
+	}
+	return tags
+}
+
+// tagPtrs converts tags to the form of the Azure SDK
+func tagPtrs(tags map[string]string) map[string]*string {
+	ptrs := make(map[string]*string, len(tags))
+	for k, v := range tags {
+		ptrs[k] = to.Ptr(v)
+	}
+	return ptrs
+}
+
+// userTags returns the tags of a guard resource that were not set by
+// morpheus-azureguard
+func userTags(tags map[string]*string) map[string]string {
+	var user map[string]string
+	for k, v := range tags {
+		if v == nil || guard.IsReservedTag(k) {
+			continue
+		}
+		if user == nil {
+			user = make(map[string]string)
+		}
+		user[k] = *v
+	}
+	return user
+}
+
+// tagValue returns the value of a tag, or "" if it is not set
+func tagValue(tags map[string]*string, key string) string {
+	if v := tags[key]; v != nil {
+		return *v
 	}
+	return ""
 }
 
 // parseImageReference parses "Publisher:Offer:SKU:Version" into components.
@@ -69,3 +144,16 @@ func parseImageReference(image string) (publisher, offer, sku, version string, e
 	}
 	return parts[0], parts[1], parts[2], parts[3], nil
 }
+
+// routeTableTags returns the tags of a route table created for a peering,
+// by which it is found again when the peering or guard is removed.
+func routeTableTags(guardID, remoteVNetID string, user map[string]string) map[string]*string {
+	managed := TagManagedByValue
+	gid := guardID
+	vnet := remoteVNetID
+	tags := tagPtrs(user)
+	tags[TagManagedBy] = &managed
+	tags[TagGuardID] = &gid
+	tags[TagRemoteVNet] = &vnet
+	return tags
+}
@@ -0,0 +1,251 @@
+package guard
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+// Inspector is implemented by guard providers that can report the
+// firewall and NIC settings of a guard
+type Inspector interface {
+	InspectGuard(ctx context.Context, g *Guard) (*Inspection, error)
+}
+
+// Inspection is the network configuration of a guard as its cloud sees it
+type Inspection struct {
+	InboundRules []FirewallRule // In the order they are evaluated
+	IPForwarding bool           // Enabled on the guard's NIC
+}
+
+// FirewallRule is an inbound rule of a guard's firewall, e.g. an NSG rule
+type FirewallRule struct {
+	Name     string
+	Protocol string   // Tcp, Udp or *
+	Ports    []string // e.g. 51820, 1000-2000 or *
+	Sources  []string // Address prefixes or tags, e.g. * or Internet
+	Allow    bool
+	Priority int // Lower numbers are evaluated first
+}
+
+// handshakeFresh is how old a WireGuard handshake may be for check to
+// pass; peers that are talking renew it every two minutes
+const handshakeFresh = 3 * time.Minute
+
+// markerNow is printed with the guard's clock before its handshakes
+const markerNow = "WG_NOW"
+
+// Check diagnoses a guard from the cloud's side: its VM is running, its
+// firewall lets WireGuard in, IP forwarding is enabled, its peerings are
+// connected, its peers completed recent handshakes and the route tables
+// of its peerings point at it. Handshakes are read on the guard with run;
+// without it, that check is skipped.
+func Check(ctx context.Context, prov GuardProvider, g *Guard, run RunFunc) []TestResult {
+	var results []TestResult
+	add := func(name, status, detail string) {
+		results = append(results, TestResult{Name: name, Status: status, Detail: detail})
+	}
+
+	if g.ServerID == "" {
+		add("vm", TestFail, "guard has no VM")
+	} else if server, err := prov.GetServer(ctx, g.ServerID); err != nil {
+		add("vm", TestFail, err.Error())
+	} else if server.State != machine.ServerStateRunning && g.Metadata[MetaPriority] == PrioritySpot {
+		add("vm", TestFail, fmt.Sprintf("%s is %s; as a Spot VM it may have been evicted", server.Name, server.State))
+	} else if server.State != machine.ServerStateRunning {
+		add("vm", TestFail, fmt.Sprintf("%s is %s", server.Name, server.State))
+	} else {
+		add("vm", TestPass, server.Name+" is running")
+	}
+
+	if inspector, ok := prov.(Inspector); !ok {
+		add("firewall", TestSkip, "not supported by "+g.Provider)
+		add("ip forwarding", TestSkip, "not supported by "+g.Provider)
+	} else if insp, err := inspector.InspectGuard(ctx, g); err != nil {
+		add("firewall", TestFail, err.Error())
+		add("ip forwarding", TestFail, err.Error())
+	} else {
+		status, detail := checkFirewall(insp.InboundRules, g.WireGuardPort)
+		add("firewall", status, detail)
+		if insp.IPForwarding {
+			add("ip forwarding", TestPass, "enabled on the NIC")
+		} else {
+			add("ip forwarding", TestFail, "disabled on the NIC; the guard cannot route mesh traffic")
+		}
+	}
+
+	if len(g.Peerings) == 0 {
+		add("peering", TestSkip, "guard has no peerings")
+	}
+	for _, p := range g.Peerings {
+		switch {
+		case p.State == "":
+			add("peering "+p.Name, TestSkip, "state not reported by "+g.Provider)
+		case strings.EqualFold(p.State, "Connected"):
+			add("peering "+p.Name, TestPass, "connected")
+		default:
+			add("peering "+p.Name, TestFail, fmt.Sprintf("%s; re-create it with unpeer and peer", p.State))
+		}
+	}
+
+	if run == nil {
+		add("handshakes", TestSkip, "cannot run commands on the guard")
+	} else if out, err := run(ctx, fmt.Sprintf("echo %s \"$(date +%%s)\"; wg show wg0 dump", markerNow)); err != nil {
+		add("handshakes", TestFail, err.Error())
+	} else {
+		for _, r := range checkHandshakes(out) {
+			add(r.Name, r.Status, r.Detail)
+		}
+	}
+
+	tables, err := prov.ListRouteTables(ctx, g.ID)
+	switch {
+	case err != nil:
+		add("route tables", TestFail, err.Error())
+	case len(tables) == 0 && len(g.Peerings) > 0:
+		add("route tables", TestWarn, "none found; peered networks do not route the mesh through the guard")
+	case len(tables) == 0:
+		add("route tables", TestSkip, "guard has no peerings")
+	}
+	for _, t := range tables {
+		status, detail := checkRouteTable(t, g)
+		add("route table "+t.Name, status, detail)
+	}
+
+	return results
+}
+
+// checkFirewall evaluates inbound rules, by priority, for WireGuard
+// traffic from anywhere to port
+func checkFirewall(rules []FirewallRule, port int) (string, string) {
+	for _, r := range rules {
+		if !matchesProtocol(r.Protocol, "udp") || !matchesPort(r.Ports, port) || !matchesAnySource(r.Sources) {
+			continue
+		}
+		if !r.Allow {
+			return TestFail, fmt.Sprintf("rule %s (priority %d) denies udp/%d", r.Name, r.Priority, port)
+		}
+		return TestPass, fmt.Sprintf("rule %s (priority %d) allows udp/%d", r.Name, r.Priority, port)
+	}
+	return TestFail, fmt.Sprintf("no inbound rule allows udp/%d", port)
+}
+
+func matchesProtocol(protocol, want string) bool {
+	return protocol == "*" || strings.EqualFold(protocol, "all") || strings.EqualFold(protocol, want)
+}
+
+// matchesPort reports whether port is in any of ports, which are single
+// ports, ranges such as 1000-2000, or *
+func matchesPort(ports []string, port int) bool {
+	for _, p := range ports {
+		if p == "*" {
+			return true
+		}
+		low, high, isRange := strings.Cut(p, "-")
+		if !isRange {
+			high = low
+		}
+		lo, err1 := strconv.Atoi(strings.TrimSpace(low))
+		hi, err2 := strconv.Atoi(strings.TrimSpace(high))
+		if err1 == nil && err2 == nil && lo <= port && port <= hi {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAnySource reports whether sources include clients anywhere on the
+// internet
+func matchesAnySource(sources []string) bool {
+	for _, s := range sources {
+		switch strings.ToLower(s) {
+		case "*", "any", "internet", "0.0.0.0/0":
+			return true
+		}
+	}
+	return false
+}
+
+// checkHandshakes reports the latest handshake of each peer in the output
+// of `wg show wg0 dump`, after the guard's time printed with markerNow
+func checkHandshakes(out string) []TestResult {
+	var now int64
+	var results []TestResult
+	for _, line := range strings.Split(out, "\n") {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), markerNow+" "); ok {
+			now, _ = strconv.ParseInt(strings.TrimSpace(rest), 10, 64)
+			continue
+		}
+		// Peer lines: public-key preshared-key endpoint allowed-ips
+		// latest-handshake transfer-rx transfer-tx persistent-keepalive
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) != 8 {
+			continue
+		}
+		latest, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			continue
+		}
+		name := "handshake " + shortKey(fields[0])
+		peer := fields[3]
+		if fields[2] != "(none)" {
+			peer = fields[2] + " " + peer
+		}
+		switch age := time.Duration(now-latest) * time.Second; {
+		case latest == 0:
+			results = append(results, TestResult{Name: name, Status: TestWarn, Detail: peer + ": never"})
+		case now == 0:
+			results = append(results, TestResult{Name: name, Status: TestWarn, Detail: peer + ": guard time unknown"})
+		case age > handshakeFresh:
+			results = append(results, TestResult{Name: name, Status: TestWarn, Detail: fmt.Sprintf("%s: %s ago", peer, age)})
+		default:
+			results = append(results, TestResult{Name: name, Status: TestPass, Detail: fmt.Sprintf("%s: %s ago", peer, max(age, 0))})
+		}
+	}
+	if len(results) == 0 {
+		if !strings.Contains(out, "\t") {
+			return []TestResult{{Name: "handshakes", Status: TestFail, Detail: "wg0 is not up: " + strings.TrimSpace(out)}}
+		}
+		return []TestResult{{Name: "handshakes", Status: TestWarn, Detail: "wg0 has no peers"}}
+	}
+	return results
+}
+
+// shortKey abbreviates a WireGuard public key for display
+func shortKey(key string) string {
+	if len(key) > 8 {
+		return key[:8] + "…"
+	}
+	return key
+}
+
+// checkRouteTable checks that a route table of a guard's peering routes
+// all its mesh CIDRs, through it, for at least one subnet
+func checkRouteTable(t RouteTable, g *Guard) (string, string) {
+	var missing []string
+	for _, cidr := range g.MeshCIDRs {
+		if !slices.Contains(t.Routes, cidr) {
+			missing = append(missing, cidr)
+		}
+	}
+	var wrong []string
+	for _, hop := range t.NextHops {
+		if hop != g.PrivateIP {
+			wrong = append(wrong, hop)
+		}
+	}
+	switch {
+	case len(missing) > 0:
+		return TestFail, "no route for " + strings.Join(missing, ", ")
+	case len(wrong) > 0:
+		return TestFail, fmt.Sprintf("routes point at %s, not the guard (%s)", strings.Join(wrong, ", "), g.PrivateIP)
+	case len(t.Subnets) == 0:
+		return TestWarn, "not associated with any subnet"
+	}
+	return TestPass, fmt.Sprintf("%d routes via the guard, %d subnets", len(t.Routes), len(t.Subnets))
+}
@@ -0,0 +1,86 @@
+package guard
+
+import "testing"
+
+func TestCheckFirewall(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []FirewallRule
+		want  string
+	}{
+		{"allowed", []FirewallRule{
+			{Name: "ssh", Protocol: "Tcp", Ports: []string{"22"}, Sources: []string{"*"}, Allow: true, Priority: 100},
+			{Name: "wg", Protocol: "Udp", Ports: []string{"51820"}, Sources: []string{"*"}, Allow: true, Priority: 110},
+		}, TestPass},
+		{"range", []FirewallRule{
+			{Name: "wg", Protocol: "*", Ports: []string{"51000-52000"}, Sources: []string{"Internet"}, Allow: true, Priority: 100},
+		}, TestPass},
+		{"denied first", []FirewallRule{
+			{Name: "deny", Protocol: "*", Ports: []string{"*"}, Sources: []string{"*"}, Allow: false, Priority: 100},
+			{Name: "wg", Protocol: "Udp", Ports: []string{"51820"}, Sources: []string{"*"}, Allow: true, Priority: 110},
+		}, TestFail},
+		{"other port", []FirewallRule{
+			{Name: "wg", Protocol: "Udp", Ports: []string{"51821"}, Sources: []string{"*"}, Allow: true, Priority: 100},
+		}, TestFail},
+		{"vnet only", []FirewallRule{
+			{Name: "wg", Protocol: "Udp", Ports: []string{"51820"}, Sources: []string{"VirtualNetwork"}, Allow: true, Priority: 100},
+		}, TestFail},
+		{"no rules", nil, TestFail},
+	}
+	for _, tt := range tests {
+		if got, detail := checkFirewall(tt.rules, 51820); got != tt.want {
+			t.Errorf("%s: checkFirewall() = %s (%s), want %s", tt.name, got, detail, tt.want)
+		}
+	}
+}
+
+func TestCheckHandshakes(t *testing.T) {
+	// As returned by Azure Run Command
+	out := "Enable succeeded: \n[stdout]\nWG_NOW 1700000300\n" +
+		"cHJpdmF0ZQ==\tcHVibGlj\t51820\toff\n" +
+		"cGVlcjE=\t(none)\t203.0.113.1:51820\t10.200.0.0/16\t1700000250\t100\t200\t25\n" +
+		"cGVlcjI=\t(none)\t(none)\t10.201.0.0/16\t0\t0\t0\toff\n" +
+		"cGVlcjM=\t(none)\t203.0.113.3:51820\t10.202.0.0/16\t1699990000\t100\t200\toff\n" +
+		"\n[stderr]\n"
+
+	results := checkHandshakes(out)
+	want := []string{TestPass, TestWarn, TestWarn}
+	if len(results) != len(want) {
+		t.Fatalf("checkHandshakes() = %+v, want %d results", results, len(want))
+	}
+	for i, r := range results {
+		if r.Status != want[i] {
+			t.Errorf("checkHandshakes()[%d] = %+v, want %s", i, r, want[i])
+		}
+	}
+	if results[0].Detail != "203.0.113.1:51820 10.200.0.0/16: 50s ago" {
+		t.Errorf("checkHandshakes()[0].Detail = %q", results[0].Detail)
+	}
+
+	if r := checkHandshakes("WG_NOW 1700000300\ncHJpdmF0ZQ==\tcHVibGlj\t51820\toff\n"); len(r) != 1 || r[0].Status != TestWarn {
+		t.Errorf("checkHandshakes() without peers = %+v, want a warning", r)
+	}
+	if r := checkHandshakes("Unable to access interface: No such device\n"); len(r) != 1 || r[0].Status != TestFail {
+		t.Errorf("checkHandshakes() without wg0 = %+v, want a failure", r)
+	}
+}
+
+func TestCheckRouteTable(t *testing.T) {
+	g := &Guard{PrivateIP: "10.100.1.4", MeshCIDRs: []string{"10.200.0.0/16", "100.64.0.0/10"}}
+	tests := []struct {
+		name  string
+		table RouteTable
+		want  string
+	}{
+		{"ok", RouteTable{Routes: []string{"10.200.0.0/16", "100.64.0.0/10"}, NextHops: []string{"10.100.1.4"}, Subnets: []string{"s1"}}, TestPass},
+		{"hops unknown", RouteTable{Routes: []string{"10.200.0.0/16", "100.64.0.0/10"}, Subnets: []string{"s1"}}, TestPass},
+		{"missing route", RouteTable{Routes: []string{"10.200.0.0/16"}, NextHops: []string{"10.100.1.4"}, Subnets: []string{"s1"}}, TestFail},
+		{"stale next hop", RouteTable{Routes: []string{"10.200.0.0/16", "100.64.0.0/10"}, NextHops: []string{"10.100.1.5"}, Subnets: []string{"s1"}}, TestFail},
+		{"unassociated", RouteTable{Routes: []string{"10.200.0.0/16", "100.64.0.0/10"}, NextHops: []string{"10.100.1.4"}}, TestWarn},
+	}
+	for _, tt := range tests {
+		if got, detail := checkRouteTable(tt.table, g); got != tt.want {
+			t.Errorf("%s: checkRouteTable() = %s (%s), want %s", tt.name, got, detail, tt.want)
+		}
+	}
+}
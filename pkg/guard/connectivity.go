@@ -0,0 +1,179 @@
+package guard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Test result statuses
+const (
+	TestPass = "pass"
+	TestFail = "fail"
+	TestWarn = "warn"
+	TestSkip = "skip"
+)
+
+// TestResult is the outcome of one connectivity test of a guard
+type TestResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Route is a route in effect on a network interface
+type Route struct {
+	Prefixes    []string `json:"prefixes"`
+	NextHopType string   `json:"next_hop_type"` // e.g. VirtualAppliance, VnetLocal
+	NextHops    []string `json:"next_hops,omitempty"`
+	Active      bool     `json:"active"`
+}
+
+// NextHopVirtualAppliance is the next hop type of routes via the guard
+const NextHopVirtualAppliance = "VirtualAppliance"
+
+// TestOptions configures Test
+type TestOptions struct {
+	// Client is a WireGuard client config the guard accepts; the handshake
+	// test is skipped without it
+	Client *ClientConfig
+
+	// ProbeVM is the resource ID of a VM in a peered VNet; the route tests
+	// are skipped without it
+	ProbeVM string
+
+	// Ping are mesh addresses the probe VM pings through the guard
+	Ping []string
+
+	// Timeout bounds the UDP and handshake tests (default 5s)
+	Timeout time.Duration
+}
+
+// Test checks that a guard works from the operator's side: its WireGuard
+// port is reachable, a client can complete a handshake, and VMs in a peered
+// VNet route the mesh CIDRs through it.
+func Test(ctx context.Context, prov GuardProvider, g *Guard, opts TestOptions) []TestResult {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	endpoint := net.JoinHostPort(g.PublicIP, strconv.Itoa(g.WireGuardPort))
+
+	var results []TestResult
+	add := func(name, status, detail string) {
+		results = append(results, TestResult{Name: name, Status: status, Detail: detail})
+	}
+
+	// UDP cannot tell open from filtered; the handshake can
+	if err := ProbeUDP(ctx, endpoint, opts.Timeout); err != nil {
+		add("udp", TestFail, fmt.Sprintf("%s: %s", endpoint, err))
+	} else {
+		add("udp", TestPass, endpoint+" open or filtered")
+	}
+
+	switch {
+	case opts.Client == nil:
+		add("handshake", TestSkip, "no client config")
+	default:
+		target := endpoint
+		if opts.Client.Endpoint != "" {
+			target = opts.Client.Endpoint
+		}
+		rtt, err := Handshake(ctx, target, opts.Client, opts.Timeout)
+		switch {
+		case errors.Is(err, ErrUnderLoad):
+			add("handshake", TestWarn, err.Error())
+		case err != nil:
+			add("handshake", TestFail, fmt.Sprintf("%s: %s", target, err))
+		default:
+			add("handshake", TestPass, fmt.Sprintf("%s in %s", target, rtt.Round(time.Millisecond)))
+		}
+	}
+
+	if opts.ProbeVM == "" {
+		add("routes", TestSkip, "no probe VM")
+		return results
+	}
+	if len(g.MeshCIDRs) == 0 {
+		add("routes", TestSkip, "guard has no mesh CIDRs")
+	} else if routes, err := prov.EffectiveRoutes(ctx, opts.ProbeVM); err != nil {
+		add("routes", TestFail, err.Error())
+	} else {
+		for _, cidr := range g.MeshCIDRs {
+			status, detail := checkRoute(routes, cidr, g.PrivateIP)
+			add("route "+cidr, status, detail)
+		}
+	}
+
+	for _, addr := range opts.Ping {
+		// Run Command returns the output of the script, not its status
+		out, err := prov.RunCommand(ctx, opts.ProbeVM, fmt.Sprintf("ping -c 3 -W 2 %s >/dev/null 2>&1 && echo PING_OK || echo PING_FAILED", addr))
+		switch {
+		case err != nil:
+			add("ping "+addr, TestFail, err.Error())
+		case strings.Contains(out, "PING_OK"):
+			add("ping "+addr, TestPass, "reachable from probe VM")
+		default:
+			add("ping "+addr, TestFail, "no reply from probe VM")
+		}
+	}
+	return results
+}
+
+// checkRoute checks that the most specific active route covering cidr
+// goes through the guard
+func checkRoute(routes []Route, cidr, guardIP string) (string, string) {
+	_, want, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return TestFail, err.Error()
+	}
+	wantBits, _ := want.Mask.Size()
+
+	var best *Route
+	bestBits := -1
+	for i, r := range routes {
+		if !r.Active {
+			continue
+		}
+		for _, prefix := range r.Prefixes {
+			_, n, err := net.ParseCIDR(prefix)
+			if err != nil {
+				continue
+			}
+			bits, _ := n.Mask.Size()
+			if bits <= wantBits && bits > bestBits && n.Contains(want.IP) {
+				best, bestBits = &routes[i], bits
+			}
+		}
+	}
+
+	if best == nil {
+		return TestFail, "no route"
+	}
+	hop := best.NextHopType
+	if len(best.NextHops) > 0 {
+		hop += " " + strings.Join(best.NextHops, ",")
+	}
+	if best.NextHopType != NextHopVirtualAppliance {
+		return TestFail, "routed via " + hop
+	}
+	for _, ip := range best.NextHops {
+		if ip == guardIP {
+			return TestPass, "via guard " + guardIP
+		}
+	}
+	return TestFail, fmt.Sprintf("routed via %s, not the guard (%s)", hop, guardIP)
+}
+
+// Failed reports whether any test failed
+func Failed(results []TestResult) bool {
+	for _, r := range results {
+		if r.Status == TestFail {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package guard
+
+import "testing"
+
+func TestCheckRoute(t *testing.T) {
+	routes := []Route{
+		{Prefixes: []string{"10.0.0.0/16"}, NextHopType: "VnetLocal", Active: true},
+		{Prefixes: []string{"0.0.0.0/0"}, NextHopType: "Internet", Active: true},
+		{Prefixes: []string{"10.200.0.0/16"}, NextHopType: NextHopVirtualAppliance, NextHops: []string{"10.100.1.4"}, Active: true},
+		{Prefixes: []string{"10.201.0.0/16"}, NextHopType: NextHopVirtualAppliance, NextHops: []string{"10.100.1.4"}, Active: false},
+		{Prefixes: []string{"10.202.0.0/16"}, NextHopType: NextHopVirtualAppliance, NextHops: []string{"10.9.9.9"}, Active: true},
+	}
+
+	tests := []struct {
+		cidr string
+		want string
+	}{
+		{"10.200.0.0/16", TestPass},
+		{"10.200.5.0/24", TestPass}, // Covered by the mesh route
+		{"10.201.0.0/16", TestFail}, // Only the default route is active
+		{"10.202.0.0/16", TestFail}, // Another appliance
+		{"10.200.0.0/15", TestFail}, // Wider than the mesh route
+		{"not-a-cidr", TestFail},
+	}
+	for _, tt := range tests {
+		if got, detail := checkRoute(routes, tt.cidr, "10.100.1.4"); got != tt.want {
+			t.Errorf("checkRoute(%s) = %s (%s), want %s", tt.cidr, got, detail, tt.want)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/cloudcreds"
+)
+
+const (
+	defaultTokenURI = "https://oauth2.googleapis.com/token"
+	computeScope    = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// TokenSource provides OAuth2 access tokens for the Compute API
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken is an access token obtained elsewhere, e.g. from
+// 'gcloud auth print-access-token'
+type StaticToken string
+
+// Token returns the token itself
+func (t StaticToken) Token(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+// NewTokenSource returns a token source for a gcloud configuration:
+// GOOGLE_OAUTH_ACCESS_TOKEN if set, else the application default
+// credentials if they are a service account key or user credentials, else
+// 'gcloud auth print-access-token'.
+func NewTokenSource(creds *cloudcreds.GCPCredentials) (TokenSource, error) {
+	if token := strings.TrimSpace(os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")); token != "" {
+		return StaticToken(token), nil
+	}
+
+	var src tokenFetcher
+	switch creds.CredentialsType {
+	case "service_account", "authorized_user":
+		data, err := os.ReadFile(creds.CredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		src, err = parseCredentialsFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", creds.CredentialsFile, err)
+		}
+	default:
+		if _, err := exec.LookPath("gcloud"); err != nil {
+			return nil, fmt.Errorf("no application default credentials and no gcloud CLI; run 'gcloud auth application-default login'")
+		}
+		src = gcloudToken{configuration: creds.Configuration}
+	}
+	return &cachedToken{src: src}, nil
+}
+
+// tokenFetcher fetches a new access token and when it expires
+type tokenFetcher interface {
+	fetch(ctx context.Context) (string, time.Time, error)
+}
+
+// cachedToken reuses a token until shortly before it expires
+type cachedToken struct {
+	src    tokenFetcher
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func (c *cachedToken) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Until(c.expiry) > time.Minute {
+		return c.token, nil
+	}
+	token, expiry, err := c.src.fetch(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get a Google access token: %w", err)
+	}
+	c.token, c.expiry = token, expiry
+	return token, nil
+}
+
+// credentialsFile is an application default credentials file
+type credentialsFile struct {
+	Type string `json:"type"`
+
+	// service_account
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+
+	// authorized_user
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func parseCredentialsFile(data []byte) (tokenFetcher, error) {
+	var f credentialsFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	if f.TokenURI == "" {
+		f.TokenURI = defaultTokenURI
+	}
+
+	switch f.Type {
+	case "service_account":
+		block, _ := pem.Decode([]byte(f.PrivateKey))
+		if block == nil {
+			return nil, fmt.Errorf("service account private_key is not PEM encoded")
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid service account private_key: %w", err)
+		}
+		key, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("service account private_key is not an RSA key")
+		}
+		return &serviceAccount{email: f.ClientEmail, key: key, tokenURI: f.TokenURI}, nil
+	case "authorized_user":
+		return &authorizedUser{f: f}, nil
+	default:
+		return nil, fmt.Errorf("unsupported credentials type %q", f.Type)
+	}
+}
+
+// serviceAccount exchanges a signed JWT for an access token
+type serviceAccount struct {
+	email    string
+	key      *rsa.PrivateKey
+	tokenURI string
+}
+
+func (s *serviceAccount) fetch(ctx context.Context) (string, time.Time, error) {
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   s.email,
+		"scope": computeScope,
+		"aud":   s.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return exchangeToken(ctx, s.tokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + enc.EncodeToString(sig)},
+	})
+}
+
+// authorizedUser refreshes the token of 'gcloud auth application-default login'
+type authorizedUser struct {
+	f credentialsFile
+}
+
+func (a *authorizedUser) fetch(ctx context.Context) (string, time.Time, error) {
+	return exchangeToken(ctx, a.f.TokenURI, url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {a.f.ClientID},
+		"client_secret": {a.f.ClientSecret},
+		"refresh_token": {a.f.RefreshToken},
+	})
+}
+
+// exchangeToken posts a token request to Google's OAuth2 endpoint
+func exchangeToken(ctx context.Context, tokenURI string, form url.Values) (string, time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid token response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("token request failed (HTTP %d): %s %s", resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+	return body.AccessToken, time.Now().Add(time.Duration(body.ExpiresIn) * time.Second), nil
+}
+
+// gcloudToken asks the gcloud CLI for a token of its logged-in account
+type gcloudToken struct {
+	configuration string
+}
+
+func (g gcloudToken) fetch(ctx context.Context) (string, time.Time, error) {
+	args := []string{"auth", "print-access-token"}
+	if g.configuration != "" {
+		args = append(args, "--configuration", g.configuration)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "gcloud", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("gcloud auth print-access-token: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	// gcloud tokens are valid for an hour; refresh well before
+	return strings.TrimSpace(string(out)), time.Now().Add(30 * time.Minute), nil
+}
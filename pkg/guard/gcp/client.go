@@ -0,0 +1,362 @@
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultEndpoint is the root of the Compute Engine API
+const DefaultEndpoint = "https://compute.googleapis.com/compute/v1"
+
+// client is a minimal Compute Engine REST client
+type client struct {
+	endpoint   string
+	tokens     TokenSource
+	httpClient *http.Client
+}
+
+// apiError is an error response of the Compute API
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s (HTTP %d)", e.Message, e.Code)
+}
+
+// isNotFound reports whether err is a 404 from the API
+func isNotFound(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+// isConflict reports whether err says the resource already exists
+func isConflict(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusConflict
+}
+
+// do sends a request to path, a self link or a path relative to the API
+// root, and decodes the response into out (if not nil)
+func (c *client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	u := c.endpoint + "/" + resourcePath("", path)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return err
+	}
+	token, err := c.tokens.Token(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		var wrapper struct {
+			Error *apiError `json:"error"`
+		}
+		if json.Unmarshal(data, &wrapper) == nil && wrapper.Error != nil {
+			wrapper.Error.Code = resp.StatusCode
+			return wrapper.Error
+		}
+		return &apiError{Code: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+	if out != nil {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// operation is a long-running Compute API operation
+type operation struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"` // PENDING, RUNNING or DONE
+	SelfLink   string `json:"selfLink"`
+	TargetLink string `json:"targetLink"`
+	Error      *struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	} `json:"error,omitempty"`
+}
+
+// mutate sends a request that starts an operation, and waits for it
+func (c *client) mutate(ctx context.Context, method, path string, body any) error {
+	var op operation
+	if err := c.do(ctx, method, path, nil, body, &op); err != nil {
+		return err
+	}
+	for op.Status != "DONE" {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		// Returns when the operation is done, or after about two minutes
+		if err := c.do(ctx, http.MethodPost, op.SelfLink+"/wait", nil, nil, &op); err != nil {
+			return fmt.Errorf("failed to wait for operation %s: %w", op.Name, err)
+		}
+		if op.Status != "DONE" {
+			time.Sleep(time.Second)
+		}
+	}
+	if op.Error != nil && len(op.Error.Errors) > 0 {
+		var msgs []string
+		for _, e := range op.Error.Errors {
+			msgs = append(msgs, e.Message)
+		}
+		return fmt.Errorf("%s", strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// insert creates a resource in a collection; it is not an error if a
+// resource of that name already exists
+func (c *client) insert(ctx context.Context, collection string, body any) error {
+	err := c.mutate(ctx, http.MethodPost, collection, body)
+	if isConflict(err) {
+		return nil
+	}
+	return err
+}
+
+// remove deletes a resource; it is not an error if it does not exist
+func (c *client) remove(ctx context.Context, path string) error {
+	err := c.mutate(ctx, http.MethodDelete, path, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// list returns all items of a collection, following page tokens
+func list[T any](ctx context.Context, c *client, collection, filter string) ([]T, error) {
+	var items []T
+	query := url.Values{}
+	if filter != "" {
+		query.Set("filter", filter)
+	}
+	for {
+		var page struct {
+			Items         []T    `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := c.do(ctx, http.MethodGet, collection, query, nil, &page); err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+		if page.NextPageToken == "" {
+			return items, nil
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
+
+// aggregatedInstances lists the instances of all zones of a project
+func (c *client) aggregatedInstances(ctx context.Context, project, filter string) ([]instance, error) {
+	var instances []instance
+	query := url.Values{}
+	if filter != "" {
+		query.Set("filter", filter)
+	}
+	for {
+		var page struct {
+			Items map[string]struct {
+				Instances []instance `json:"instances"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := c.do(ctx, http.MethodGet, fmt.Sprintf("projects/%s/aggregated/instances", project), query, nil, &page); err != nil {
+			return nil, err
+		}
+		for _, scoped := range page.Items {
+			instances = append(instances, scoped.Instances...)
+		}
+		if page.NextPageToken == "" {
+			return instances, nil
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
+
+// Compute API resources, with the fields used here
+
+type network struct {
+	Name                  string           `json:"name"`
+	SelfLink              string           `json:"selfLink,omitempty"`
+	Description           string           `json:"description,omitempty"`
+	AutoCreateSubnetworks bool             `json:"autoCreateSubnetworks"`
+	Subnetworks           []string         `json:"subnetworks,omitempty"`
+	Peerings              []networkPeering `json:"peerings,omitempty"`
+	RoutingConfig         *routingConfig   `json:"routingConfig,omitempty"`
+}
+
+type routingConfig struct {
+	RoutingMode string `json:"routingMode"`
+}
+
+type networkPeering struct {
+	Name                 string `json:"name"`
+	Network              string `json:"network"`
+	State                string `json:"state,omitempty"` // ACTIVE or INACTIVE
+	ExchangeSubnetRoutes bool   `json:"exchangeSubnetRoutes"`
+	ExportCustomRoutes   bool   `json:"exportCustomRoutes"`
+	ImportCustomRoutes   bool   `json:"importCustomRoutes"`
+}
+
+type subnetwork struct {
+	Name        string `json:"name"`
+	SelfLink    string `json:"selfLink,omitempty"`
+	Network     string `json:"network"`
+	IPCidrRange string `json:"ipCidrRange"`
+	Region      string `json:"region,omitempty"`
+}
+
+type firewall struct {
+	Name              string         `json:"name"`
+	SelfLink          string         `json:"selfLink,omitempty"`
+	Network           string         `json:"network"`
+	Description       string         `json:"description,omitempty"`
+	Direction         string         `json:"direction"` // INGRESS or EGRESS
+	Priority          int            `json:"priority"`
+	SourceRanges      []string       `json:"sourceRanges,omitempty"`
+	DestinationRanges []string       `json:"destinationRanges,omitempty"`
+	TargetTags        []string       `json:"targetTags,omitempty"`
+	Allowed           []firewallRule `json:"allowed"`
+}
+
+type firewallRule struct {
+	IPProtocol string   `json:"IPProtocol"`
+	Ports      []string `json:"ports,omitempty"`
+}
+
+type address struct {
+	Name        string            `json:"name"`
+	SelfLink    string            `json:"selfLink,omitempty"`
+	Address     string            `json:"address,omitempty"`
+	AddressType string            `json:"addressType,omitempty"` // EXTERNAL or INTERNAL
+	Subnetwork  string            `json:"subnetwork,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+type route struct {
+	Name        string   `json:"name"`
+	SelfLink    string   `json:"selfLink,omitempty"`
+	Network     string   `json:"network"`
+	Description string   `json:"description,omitempty"`
+	DestRange   string   `json:"destRange"`
+	Priority    int      `json:"priority"`
+	Tags        []string `json:"tags,omitempty"`
+
+	NextHopIP       string `json:"nextHopIp,omitempty"`
+	NextHopInstance string `json:"nextHopInstance,omitempty"`
+	NextHopGateway  string `json:"nextHopGateway,omitempty"`
+	NextHopNetwork  string `json:"nextHopNetwork,omitempty"`
+	NextHopPeering  string `json:"nextHopPeering,omitempty"`
+}
+
+type instance struct {
+	Name              string             `json:"name"`
+	SelfLink          string             `json:"selfLink,omitempty"`
+	Zone              string             `json:"zone,omitempty"`
+	MachineType       string             `json:"machineType"`
+	Status            string             `json:"status,omitempty"`
+	CanIPForward      bool               `json:"canIpForward"`
+	Labels            map[string]string  `json:"labels,omitempty"`
+	Tags              *instanceTags      `json:"tags,omitempty"`
+	Metadata          *metadata          `json:"metadata,omitempty"`
+	Disks             []attachedDisk     `json:"disks,omitempty"`
+	NetworkInterfaces []networkInterface `json:"networkInterfaces"`
+	CreationTimestamp string             `json:"creationTimestamp,omitempty"`
+}
+
+type instanceTags struct {
+	Items []string `json:"items"`
+}
+
+type metadata struct {
+	Items []metadataItem `json:"items"`
+}
+
+type metadataItem struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type attachedDisk struct {
+	Boot             bool              `json:"boot"`
+	AutoDelete       bool              `json:"autoDelete"`
+	InitializeParams *initializeParams `json:"initializeParams,omitempty"`
+}
+
+type initializeParams struct {
+	SourceImage string `json:"sourceImage"`
+}
+
+type networkInterface struct {
+	Network       string         `json:"network,omitempty"`
+	Subnetwork    string         `json:"subnetwork"`
+	NetworkIP     string         `json:"networkIP,omitempty"`
+	AccessConfigs []accessConfig `json:"accessConfigs,omitempty"`
+}
+
+type accessConfig struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	NatIP string `json:"natIP,omitempty"`
+}
+
+// metadataValue returns the value of an instance metadata key, or ""
+func (i *instance) metadataValue(key string) string {
+	if i.Metadata == nil {
+		return ""
+	}
+	for _, item := range i.Metadata.Items {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return ""
+}
+
+// primaryNIC returns the first network interface, or nil
+func (i *instance) primaryNIC() *networkInterface {
+	if len(i.NetworkInterfaces) == 0 {
+		return nil
+	}
+	return &i.NetworkInterfaces[0]
+}
@@ -0,0 +1,410 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/guard"
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+// Provider implements guard.GuardProvider for Google Cloud. A guard is a
+// VPC network with one subnet, firewall rules, static external and
+// internal addresses and a VM with IP forwarding; all of them are named
+// after the guard ID. Workload VPCs are peered with the guard's network,
+// which exports routes for the mesh CIDRs via the guard VM.
+type Provider struct {
+	project     string
+	zone        string
+	machineType string
+	image       string
+	c           *client
+}
+
+// Ensure Provider satisfies guard.GuardProvider
+var _ guard.GuardProvider = (*Provider)(nil)
+
+// NewProvider creates a new GCP guard provider for a project.
+func NewProvider(project, zone, machineType, image string, tokens TokenSource) (*Provider, error) {
+	return NewProviderWithEndpoint(DefaultEndpoint, project, zone, machineType, image, tokens)
+}
+
+// NewProviderWithEndpoint creates a GCP guard provider using another
+// Compute API endpoint (for testing).
+func NewProviderWithEndpoint(endpoint, project, zone, machineType, image string, tokens TokenSource) (*Provider, error) {
+	if project == "" {
+		return nil, fmt.Errorf("a GCP project is required")
+	}
+	return &Provider{
+		project:     project,
+		zone:        zone,
+		machineType: machineType,
+		image:       image,
+		c: &client{
+			endpoint:   strings.TrimSuffix(endpoint, "/"),
+			tokens:     tokens,
+			httpClient: &http.Client{Timeout: 3 * time.Minute},
+		},
+	}, nil
+}
+
+// global returns the path of a global resource collection of the project
+func (p *Provider) global(collection string) string {
+	return fmt.Sprintf("projects/%s/global/%s", p.project, collection)
+}
+
+// regional returns the path of a regional resource collection
+func (p *Provider) regional(region, collection string) string {
+	return fmt.Sprintf("projects/%s/regions/%s/%s", p.project, region, collection)
+}
+
+// CreateServer creates the guard VM. The network, subnet and addresses
+// must already exist (see EnsureNetwork); they are found by the guard-id
+// label. Labels that GCP labels cannot hold are kept in the instance
+// metadata, along with the cloud-init user data and SSH keys.
+func (p *Provider) CreateServer(ctx context.Context, req machine.CreateServerRequest) (*machine.Server, error) {
+	guardID := req.Labels[TagGuardID]
+	if guardID == "" {
+		return nil, fmt.Errorf("guard-id label is required for GCP VM creation")
+	}
+	names := newResourceNames(guardID)
+	zone := req.Location
+	if zone == "" {
+		zone = p.zone
+	}
+	if zone == "" {
+		return nil, fmt.Errorf("a zone is required (machine.gcp.zone or --location)")
+	}
+	region := regionOf(zone)
+	image := req.Image
+	if image == "" {
+		image = p.image
+	}
+	machineType := req.ServerType
+	if machineType == "" {
+		machineType = p.machineType
+	}
+
+	var external, internal address
+	if err := p.c.do(ctx, http.MethodGet, p.regional(region, "addresses/"+names.Address), nil, nil, &external); err != nil {
+		return nil, fmt.Errorf("failed to get external address: %w", err)
+	}
+	if err := p.c.do(ctx, http.MethodGet, p.regional(region, "addresses/"+names.InternalAddress), nil, nil, &internal); err != nil {
+		return nil, fmt.Errorf("failed to get internal address: %w", err)
+	}
+
+	labels := map[string]string{TagManagedBy: TagManagedByValue}
+	items := []metadataItem{{Key: "user-data", Value: req.UserData}}
+	if len(req.SSHKeys) > 0 {
+		var keys []string
+		for _, key := range req.SSHKeys {
+			keys = append(keys, "morpheus:"+key)
+		}
+		items = append(items, metadataItem{Key: "ssh-keys", Value: strings.Join(keys, "\n")})
+	}
+	keys := make([]string, 0, len(req.Labels))
+	for k := range req.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := req.Labels[k]
+		switch {
+		case k == TagManagedBy || providerLabels[k] || v == "":
+		case instanceLabels[k]:
+			labels[k] = strings.ToLower(v)
+		default:
+			items = append(items, metadataItem{Key: k, Value: v})
+		}
+	}
+
+	inst := instance{
+		Name:         req.Name,
+		MachineType:  fmt.Sprintf("zones/%s/machineTypes/%s", zone, machineType),
+		CanIPForward: true,
+		Labels:       labels,
+		Tags:         &instanceTags{Items: []string{names.Tag}},
+		Metadata:     &metadata{Items: items},
+		Disks: []attachedDisk{{
+			Boot:             true,
+			AutoDelete:       true,
+			InitializeParams: &initializeParams{SourceImage: image},
+		}},
+		NetworkInterfaces: []networkInterface{{
+			Subnetwork: p.regional(region, "subnetworks/"+names.Subnet),
+			NetworkIP:  internal.Address,
+			AccessConfigs: []accessConfig{{
+				Type:  "ONE_TO_ONE_NAT",
+				Name:  "External NAT",
+				NatIP: external.Address,
+			}},
+		}},
+	}
+	path := fmt.Sprintf("projects/%s/zones/%s/instances", p.project, zone)
+	if err := p.c.mutate(ctx, http.MethodPost, path, inst); err != nil {
+		return nil, fmt.Errorf("failed to create VM: %w", err)
+	}
+
+	return &machine.Server{
+		ID:       path + "/" + req.Name,
+		Name:     req.Name,
+		Location: zone,
+		State:    machine.ServerStateStarting,
+		Labels:   req.Labels,
+	}, nil
+}
+
+// GetServer retrieves server information by ID, the instance's path
+// (projects/<project>/zones/<zone>/instances/<name>) or self link.
+func (p *Provider) GetServer(ctx context.Context, serverID string) (*machine.Server, error) {
+	var inst instance
+	if err := p.c.do(ctx, http.MethodGet, serverID, nil, nil, &inst); err != nil {
+		return nil, fmt.Errorf("failed to get VM: %w", err)
+	}
+	return instanceToServer(&inst), nil
+}
+
+// DeleteServer removes a VM.
+func (p *Provider) DeleteServer(ctx context.Context, serverID string) error {
+	if err := p.c.mutate(ctx, http.MethodDelete, serverID, nil); err != nil {
+		return fmt.Errorf("failed to delete VM: %w", err)
+	}
+	return nil
+}
+
+// WaitForServer waits until the server is in the specified state.
+func (p *Provider) WaitForServer(ctx context.Context, serverID string, state machine.ServerState) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		server, err := p.GetServer(ctx, serverID)
+		if err != nil {
+			return err
+		}
+
+		if server.State == state {
+			return nil
+		}
+
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// ListServers lists the guard VMs of the project with optional filters on
+// their labels and metadata.
+func (p *Provider) ListServers(ctx context.Context, filters map[string]string) ([]*machine.Server, error) {
+	instances, err := p.guardInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var servers []*machine.Server
+	for i := range instances {
+		server := instanceToServer(&instances[i])
+		match := true
+		for k, v := range filters {
+			if server.Labels[k] != v {
+				match = false
+				break
+			}
+		}
+		if match {
+			servers = append(servers, server)
+		}
+	}
+	return servers, nil
+}
+
+// guardInstances lists the instances labelled managed-by=morpheus-gcpguard
+func (p *Provider) guardInstances(ctx context.Context) ([]instance, error) {
+	all, err := p.c.aggregatedInstances(ctx, p.project, fmt.Sprintf("labels.%s = %q", TagManagedBy, TagManagedByValue))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VMs: %w", err)
+	}
+	var instances []instance
+	for _, inst := range all {
+		if inst.Labels[TagManagedBy] == TagManagedByValue {
+			instances = append(instances, inst)
+		}
+	}
+	return instances, nil
+}
+
+// instanceToServer converts an instance; its labels and guard metadata
+// become the server's labels
+func instanceToServer(inst *instance) *machine.Server {
+	labels := make(map[string]string)
+	for k, v := range inst.Labels {
+		labels[k] = v
+	}
+	if inst.Metadata != nil {
+		for _, item := range inst.Metadata.Items {
+			if item.Key != "user-data" && item.Key != "ssh-keys" {
+				labels[item.Key] = item.Value
+			}
+		}
+	}
+
+	state := machine.ServerStateUnknown
+	switch inst.Status {
+	case "RUNNING":
+		state = machine.ServerStateRunning
+	case "PROVISIONING", "STAGING":
+		state = machine.ServerStateStarting
+	case "STOPPING", "SUSPENDING", "SUSPENDED", "TERMINATED":
+		state = machine.ServerStateStopped
+	}
+
+	server := &machine.Server{
+		ID:         resourcePath("", inst.SelfLink),
+		Name:       inst.Name,
+		Location:   lastSegment(inst.Zone),
+		State:      state,
+		Labels:     labels,
+		CreatedAt:  inst.CreationTimestamp,
+		ServerType: lastSegment(inst.MachineType),
+	}
+	if nic := inst.primaryNIC(); nic != nil && len(nic.AccessConfigs) > 0 {
+		server.PublicIPv4 = nic.AccessConfigs[0].NatIP
+	}
+	return server
+}
+
+// GetGuard reconstructs guard info from the guard's GCP resources.
+func (p *Provider) GetGuard(ctx context.Context, guardID string) (*guard.Guard, error) {
+	names := newResourceNames(guardID)
+
+	var net network
+	if err := p.c.do(ctx, http.MethodGet, p.global("networks/"+names.Network), nil, nil, &net); err != nil {
+		return nil, fmt.Errorf("guard not found: %w", err)
+	}
+	if !isManaged(net.Description) {
+		return nil, fmt.Errorf("network %s is not managed by %s", names.Network, TagManagedByValue)
+	}
+
+	instances, err := p.guardInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var inst *instance
+	for i := range instances {
+		if instances[i].Name == names.Instance {
+			inst = &instances[i]
+		}
+	}
+
+	g := p.guardFromNetwork(&net, inst)
+	tables, _ := p.ListRouteTables(ctx, guardID) // Best effort
+	for _, peering := range net.Peerings {
+		pi := guard.PeeringInfo{Name: peering.Name, RemoteVNetID: resourcePath(p.project, peering.Network)}
+		if len(tables) > 0 {
+			pi.RouteTableID = tables[0].ID
+		}
+		g.Peerings = append(g.Peerings, pi)
+	}
+
+	if len(net.Subnetworks) > 0 {
+		g.SubnetID = resourcePath(p.project, net.Subnetworks[0])
+		region := segmentAfter(g.SubnetID, "regions")
+		var external address
+		if err := p.c.do(ctx, http.MethodGet, p.regional(region, "addresses/"+names.Address), nil, nil, &external); err == nil {
+			g.PublicIPID = resourcePath(p.project, external.SelfLink)
+			if g.PublicIP == "" {
+				g.PublicIP = external.Address
+			}
+		}
+		if g.Location == "" {
+			g.Location = region
+		}
+	}
+	var fw firewall
+	if err := p.c.do(ctx, http.MethodGet, p.global("firewalls/"+names.FirewallWG), nil, nil, &fw); err == nil {
+		g.NSGID = resourcePath(p.project, fw.SelfLink)
+	}
+	return g, nil
+}
+
+// ListGuards discovers all guards from the networks marked
+// managed-by=morpheus-gcpguard in their description.
+func (p *Provider) ListGuards(ctx context.Context) ([]*guard.Guard, error) {
+	networks, err := list[network](ctx, p.c, p.global("networks"), "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks: %w", err)
+	}
+	instances, err := p.guardInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var guards []*guard.Guard
+	for i := range networks {
+		tags := parseDescription(networks[i].Description)
+		if tags[TagManagedBy] != TagManagedByValue || tags[TagGuardID] == "" {
+			continue
+		}
+		var inst *instance
+		for j := range instances {
+			if instances[j].Labels[TagGuardID] == tags[TagGuardID] {
+				inst = &instances[j]
+			}
+		}
+		g := p.guardFromNetwork(&networks[i], inst)
+		if g.Location == "" && len(networks[i].Subnetworks) > 0 {
+			g.Location = segmentAfter(networks[i].Subnetworks[0], "regions")
+		}
+		guards = append(guards, g)
+	}
+	return guards, nil
+}
+
+// guardFromNetwork builds a guard from its network and VM (nil if the VM
+// does not exist)
+func (p *Provider) guardFromNetwork(net *network, inst *instance) *guard.Guard {
+	tags := parseDescription(net.Description)
+	g := &guard.Guard{
+		ID:            tags[TagGuardID],
+		Provider:      "gcp",
+		Status:        "unknown",
+		VNetID:        resourcePath(p.project, net.SelfLink),
+		ResourceGroup: p.project,
+		Group:         tags[TagGroup],
+	}
+	if port, err := strconv.Atoi(tags[TagWGPort]); err == nil {
+		g.WireGuardPort = port
+	}
+	if inst == nil {
+		return g
+	}
+
+	server := instanceToServer(inst)
+	g.ServerID = server.ID
+	g.Location = server.Location
+	g.Status = strings.ToLower(inst.Status)
+	g.PublicIP = server.PublicIPv4
+	if nic := inst.primaryNIC(); nic != nil {
+		g.PrivateIP = nic.NetworkIP
+	}
+	if t, err := time.Parse(time.RFC3339, inst.CreationTimestamp); err == nil {
+		g.CreatedAt = t
+	}
+	g.PublicKey = server.Labels[TagWGPublicKey]
+	if group := server.Labels[TagGroup]; group != "" {
+		g.Group = group
+	}
+	if cidrs := server.Labels[TagMeshCIDRs]; cidrs != "" {
+		g.MeshCIDRs = strings.Split(cidrs, ",")
+	}
+	if port, err := strconv.Atoi(server.Labels[TagWGPort]); err == nil {
+		g.WireGuardPort = port
+	}
+	return g
+}
@@ -0,0 +1,265 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/guard"
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+// fakeCompute is an in-memory Compute API: resources are JSON objects
+// keyed by their path, and every operation completes at once
+type fakeCompute struct {
+	mu        sync.Mutex
+	resources map[string]map[string]any
+	nextIP    int
+}
+
+const selfLinkPrefix = "https://www.googleapis.com/compute/v1/"
+
+func newFakeCompute(t *testing.T) (*fakeCompute, *httptest.Server) {
+	f := &fakeCompute{resources: make(map[string]map[string]any), nextIP: 2}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeCompute) put(key string, obj map[string]any) {
+	obj["selfLink"] = selfLinkPrefix + key
+	f.resources[key] = obj
+}
+
+func (f *fakeCompute) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/compute/v1/")
+	reply := func(status int, v any) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+	}
+	fail := func(status int, msg string) {
+		reply(status, map[string]any{"error": map[string]any{"code": status, "message": msg}})
+	}
+	done := map[string]any{"name": "op", "status": "DONE"}
+	var body map[string]any
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+
+	switch {
+	case r.Method == http.MethodPost && (strings.HasSuffix(key, "/addPeering") || strings.HasSuffix(key, "/removePeering")):
+		netKey, action := path.Split(key)
+		net := f.resources[strings.TrimSuffix(netKey, "/")]
+		if net == nil {
+			fail(http.StatusNotFound, "network not found")
+			return
+		}
+		peerings, _ := net["peerings"].([]any)
+		if action == "addPeering" {
+			peering := body["networkPeering"].(map[string]any)
+			peering["state"] = "ACTIVE"
+			net["peerings"] = append(peerings, peering)
+		} else {
+			var kept []any
+			for _, p := range peerings {
+				if p.(map[string]any)["name"] != body["name"] {
+					kept = append(kept, p)
+				}
+			}
+			net["peerings"] = kept
+		}
+		reply(http.StatusOK, done)
+
+	case r.Method == http.MethodPost:
+		name, _ := body["name"].(string)
+		itemKey := key + "/" + name
+		if f.resources[itemKey] != nil {
+			fail(http.StatusConflict, "already exists")
+			return
+		}
+		switch path.Base(key) {
+		case "subnetworks":
+			net := f.resources[resourcePath("", body["network"].(string))]
+			subnets, _ := net["subnetworks"].([]any)
+			net["subnetworks"] = append(subnets, selfLinkPrefix+itemKey)
+		case "addresses":
+			if body["addressType"] == "INTERNAL" {
+				body["address"] = "10.100.1." + strconv.Itoa(f.nextIP)
+				f.nextIP++
+			} else {
+				body["address"] = "203.0.113.10"
+			}
+		case "instances":
+			body["status"] = "RUNNING"
+			body["zone"] = selfLinkPrefix + path.Dir(key)
+			nics := body["networkInterfaces"].([]any)
+			nic := nics[0].(map[string]any)
+			subnet := f.resources[resourcePath("", nic["subnetwork"].(string))]
+			nic["network"] = subnet["network"]
+		}
+		f.put(itemKey, body)
+		reply(http.StatusOK, done)
+
+	case r.Method == http.MethodDelete:
+		if f.resources[key] == nil {
+			fail(http.StatusNotFound, "not found")
+			return
+		}
+		delete(f.resources, key)
+		reply(http.StatusOK, done)
+
+	case r.Method == http.MethodGet && strings.Contains(key, "/aggregated/instances"):
+		var items []any
+		for k, v := range f.resources {
+			if strings.Contains(k, "/zones/") && strings.Contains(k, "/instances/") {
+				items = append(items, v)
+			}
+		}
+		reply(http.StatusOK, map[string]any{"items": map[string]any{"zones/all": map[string]any{"instances": items}}})
+
+	case r.Method == http.MethodGet && f.resources[key] != nil:
+		reply(http.StatusOK, f.resources[key])
+
+	case r.Method == http.MethodGet:
+		items := []any{}
+		for k, v := range f.resources {
+			if strings.HasPrefix(k, key+"/") && !strings.Contains(k[len(key)+1:], "/") {
+				items = append(items, v)
+			}
+		}
+		reply(http.StatusOK, map[string]any{"items": items})
+
+	default:
+		fail(http.StatusNotFound, "not found")
+	}
+}
+
+func TestProviderLifecycle(t *testing.T) {
+	fake, srv := newFakeCompute(t)
+	prov, err := NewProviderWithEndpoint(srv.URL+"/compute/v1", "proj", "europe-west1-b", "e2-small", "projects/ubuntu-os-cloud/global/images/family/ubuntu-2204-lts", StaticToken("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// A workload network with one subnet and a VM to probe routes from
+	fake.put("projects/proj/global/networks/workload", map[string]any{"name": "workload",
+		"subnetworks": []any{selfLinkPrefix + "projects/proj/regions/europe-west1/subnetworks/workload-a"}})
+	fake.put("projects/proj/regions/europe-west1/subnetworks/workload-a", map[string]any{"name": "workload-a",
+		"network": selfLinkPrefix + "projects/proj/global/networks/workload", "ipCidrRange": "10.0.1.0/24"})
+	fake.put("projects/proj/zones/europe-west1-b/instances/probe", map[string]any{"name": "probe",
+		"networkInterfaces": []any{map[string]any{"network": selfLinkPrefix + "projects/proj/global/networks/workload"}}})
+
+	info, err := prov.EnsureNetwork(ctx, guard.NetworkRequest{
+		GuardID: "guard-1", SubnetCIDR: "10.100.1.0/24", WireGuardPort: 51820, Group: "guard-0",
+	})
+	if err != nil {
+		t.Fatalf("EnsureNetwork() error = %v", err)
+	}
+	if info.PublicIP != "203.0.113.10" || info.PrivateIP != "10.100.1.2" || info.VNetID != "projects/proj/global/networks/guard-1-vpc" {
+		t.Errorf("EnsureNetwork() = %+v", info)
+	}
+	// Ensuring again keeps the existing resources
+	if _, err := prov.EnsureNetwork(ctx, guard.NetworkRequest{GuardID: "guard-1", SubnetCIDR: "10.100.1.0/24", WireGuardPort: 51820}); err != nil {
+		t.Fatalf("second EnsureNetwork() error = %v", err)
+	}
+
+	server, err := prov.CreateServer(ctx, machine.CreateServerRequest{
+		Name:     "guard-1-vm",
+		UserData: "#cloud-config\n",
+		SSHKeys:  []string{"ssh-ed25519 AAAA test"},
+		Labels: map[string]string{
+			"managed-by": "morpheus-azureguard", "guard-id": "guard-1", "guard-group": "guard-0",
+			"mesh-cidrs": "10.200.0.0/16", "wg-port": "51820", "wg-public-key": "cHVia2V5",
+			"nic-id": "", "resource-group": "proj",
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateServer() error = %v", err)
+	}
+	inst := fake.resources[server.ID]
+	if inst["canIpForward"] != true || inst["labels"].(map[string]any)["managed-by"] != TagManagedByValue {
+		t.Errorf("instance = %v, want IP forwarding and the GCP managed-by label", inst)
+	}
+
+	g, err := prov.GetGuard(ctx, "guard-1")
+	if err != nil {
+		t.Fatalf("GetGuard() error = %v", err)
+	}
+	if g.Status != "running" || g.PrivateIP != "10.100.1.2" || g.PublicIP != "203.0.113.10" || g.Group != "guard-0" ||
+		g.WireGuardPort != 51820 || g.PublicKey != "cHVia2V5" || len(g.MeshCIDRs) != 1 || g.Location != "europe-west1-b" {
+		t.Errorf("GetGuard() = %+v", g)
+	}
+
+	err = prov.PeerNetwork(ctx, guard.PeerRequest{
+		GuardID:        "guard-1",
+		RemoteVNetID:   selfLinkPrefix + "projects/proj/global/networks/workload",
+		GuardPrivateIP: g.PrivateIP,
+		MeshCIDRs:      g.MeshCIDRs,
+	})
+	if err != nil {
+		t.Fatalf("PeerNetwork() error = %v", err)
+	}
+	if fake.resources["projects/proj/global/firewalls/guard-1-from-workload"] == nil {
+		t.Error("PeerNetwork() did not allow traffic from the workload subnets")
+	}
+
+	tables, err := prov.ListRouteTables(ctx, "")
+	if err != nil || len(tables) != 1 || tables[0].Routes[0] != "10.200.0.0/16" || tables[0].Subnets[0] != "projects/proj/global/networks/workload" {
+		t.Fatalf("ListRouteTables() = %+v, %v", tables, err)
+	}
+
+	routes, err := prov.EffectiveRoutes(ctx, "projects/proj/zones/europe-west1-b/instances/probe")
+	if err != nil {
+		t.Fatalf("EffectiveRoutes() error = %v", err)
+	}
+	if len(routes) != 1 || !routes[0].Active || routes[0].NextHops[0] != g.PrivateIP {
+		t.Errorf("EffectiveRoutes() = %+v, want the imported mesh route", routes)
+	}
+
+	guards, err := prov.ListGuards(ctx)
+	if err != nil || len(guards) != 1 || guards[0].ID != "guard-1" || guards[0].PublicIP != g.PublicIP {
+		t.Fatalf("ListGuards() = %+v, %v", guards, err)
+	}
+
+	if err := prov.CleanupNetwork(ctx, "guard-1"); err != nil {
+		t.Fatalf("CleanupNetwork() error = %v", err)
+	}
+	for key := range fake.resources {
+		if strings.Contains(key, "guard-1") {
+			t.Errorf("CleanupNetwork() left %s", key)
+		}
+	}
+	if peerings := fake.resources["projects/proj/global/networks/workload"]["peerings"]; len(peerings.([]any)) != 0 {
+		t.Errorf("CleanupNetwork() left the workload peering: %v", peerings)
+	}
+}
+
+func TestResourceHelpers(t *testing.T) {
+	if got := regionOf("europe-west1-b"); got != "europe-west1" {
+		t.Errorf("regionOf() = %s", got)
+	}
+	if got := resourcePath("proj", "workload"); got != "projects/proj/global/networks/workload" {
+		t.Errorf("resourcePath(name) = %s", got)
+	}
+	if got := resourcePath("proj", selfLinkPrefix+"projects/other/global/networks/n"); got != "projects/other/global/networks/n" {
+		t.Errorf("resourcePath(self link) = %s", got)
+	}
+	if got := resourceName(strings.Repeat("a", 62), "-x"); len(got) > 63 || strings.HasSuffix(got, "-") {
+		t.Errorf("resourceName() = %s", got)
+	}
+
+	tags := parseDescription(describe(map[string]string{TagManagedBy: TagManagedByValue, TagGuardID: "guard-1", TagGroup: ""}))
+	if len(tags) != 2 || tags[TagGuardID] != "guard-1" {
+		t.Errorf("parseDescription(describe()) = %v", tags)
+	}
+}
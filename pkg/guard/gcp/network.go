@@ -0,0 +1,378 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nimsforest/morpheus/pkg/guard"
+)
+
+// Priority of the exported mesh routes; below the default 1000, so that
+// they win over other routes to the same ranges
+const meshRoutePriority = 900
+
+// EnsureNetwork creates the full networking stack for a guard: a VPC
+// network with one subnet, firewall rules for SSH, WireGuard and the
+// guard's own range, and static external and internal addresses for the
+// VM. Resources that already exist are kept.
+func (p *Provider) EnsureNetwork(ctx context.Context, req guard.NetworkRequest) (*guard.NetworkInfo, error) {
+	names := newResourceNames(req.GuardID)
+	zone := req.Location
+	if zone == "" {
+		zone = p.zone
+	}
+	if zone == "" {
+		return nil, fmt.Errorf("a zone is required (machine.gcp.zone or --location)")
+	}
+	region := regionOf(zone)
+
+	tags := managedTags(req.GuardID)
+	tags[TagWGPort] = strconv.Itoa(req.WireGuardPort)
+	tags[TagGroup] = req.Group
+
+	// 1. VPC network, without automatic subnets
+	fmt.Printf("      Creating network %s...\n", names.Network)
+	networkPath := p.global("networks/" + names.Network)
+	err := p.c.insert(ctx, p.global("networks"), network{
+		Name:                  names.Network,
+		Description:           describe(tags),
+		AutoCreateSubnetworks: false,
+		RoutingConfig:         &routingConfig{RoutingMode: "REGIONAL"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create network: %w", err)
+	}
+
+	// 2. Subnet in the zone's region. GCP networks have no address space
+	// of their own, so only the subnet CIDR is used.
+	fmt.Printf("      Creating subnet %s (%s)...\n", names.Subnet, req.SubnetCIDR)
+	err = p.c.insert(ctx, p.regional(region, "subnetworks"), subnetwork{
+		Name:        names.Subnet,
+		Network:     networkPath,
+		IPCidrRange: req.SubnetCIDR,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subnet: %w", err)
+	}
+
+	// 3. Firewall rules, for the VM's network tag
+	fmt.Printf("      Creating firewall rules...\n")
+	rules := []firewall{
+		{Name: names.FirewallSSH, SourceRanges: guard.SSHSourceCIDRs(req.SSHSources), Allowed: []firewallRule{{IPProtocol: "tcp", Ports: []string{"22"}}}},
+		{Name: names.FirewallWG, SourceRanges: []string{"0.0.0.0/0"}, Allowed: []firewallRule{{IPProtocol: "udp", Ports: []string{strconv.Itoa(req.WireGuardPort)}}}},
+		{Name: names.FirewallInternal, SourceRanges: []string{req.SubnetCIDR}, Allowed: []firewallRule{{IPProtocol: "all"}}},
+	}
+	for _, rule := range rules {
+		rule.Network = networkPath
+		rule.Description = describe(managedTags(req.GuardID))
+		rule.Direction = "INGRESS"
+		rule.Priority = 1000
+		rule.TargetTags = []string{names.Tag}
+		if err := p.c.insert(ctx, p.global("firewalls"), rule); err != nil {
+			return nil, fmt.Errorf("failed to create firewall rule %s: %w", rule.Name, err)
+		}
+	}
+
+	// 4. Static addresses, so the guard keeps its IPs if the VM is replaced
+	fmt.Printf("      Creating addresses %s and %s...\n", names.Address, names.InternalAddress)
+	labels := map[string]string{TagManagedBy: TagManagedByValue, TagGuardID: strings.ToLower(req.GuardID)}
+	if err := p.c.insert(ctx, p.regional(region, "addresses"), address{Name: names.Address, Labels: labels}); err != nil {
+		return nil, fmt.Errorf("failed to create external address: %w", err)
+	}
+	err = p.c.insert(ctx, p.regional(region, "addresses"), address{
+		Name:        names.InternalAddress,
+		AddressType: "INTERNAL",
+		Subnetwork:  p.regional(region, "subnetworks/"+names.Subnet),
+		Labels:      labels,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create internal address: %w", err)
+	}
+
+	var external, internal address
+	if err := p.c.do(ctx, http.MethodGet, p.regional(region, "addresses/"+names.Address), nil, nil, &external); err != nil {
+		return nil, fmt.Errorf("failed to get external address: %w", err)
+	}
+	if err := p.c.do(ctx, http.MethodGet, p.regional(region, "addresses/"+names.InternalAddress), nil, nil, &internal); err != nil {
+		return nil, fmt.Errorf("failed to get internal address: %w", err)
+	}
+
+	return &guard.NetworkInfo{
+		ResourceGroup: p.project,
+		VNetID:        networkPath,
+		SubnetID:      p.regional(region, "subnetworks/"+names.Subnet),
+		NSGID:         p.global("firewalls/" + names.FirewallWG),
+		PublicIPID:    p.regional(region, "addresses/"+names.Address),
+		PublicIP:      external.Address,
+		PrivateIP:     internal.Address,
+	}, nil
+}
+
+// CleanupNetwork removes all of a guard's resources: the VM, its routes,
+// peerings (on both sides), firewall rules, addresses, subnet and network.
+func (p *Provider) CleanupNetwork(ctx context.Context, guardID string) error {
+	names := newResourceNames(guardID)
+
+	instances, err := p.guardInstances(ctx)
+	if err != nil {
+		return err
+	}
+	for _, inst := range instances {
+		if inst.Name == names.Instance {
+			fmt.Printf("   Deleting VM %s...\n", inst.Name)
+			if err := p.c.remove(ctx, inst.SelfLink); err != nil {
+				return fmt.Errorf("failed to delete VM: %w", err)
+			}
+		}
+	}
+
+	var net network
+	err = p.c.do(ctx, http.MethodGet, p.global("networks/"+names.Network), nil, nil, &net)
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get network: %w", err)
+	}
+
+	// Removing the last peering removes the mesh routes too
+	for _, peering := range net.Peerings {
+		if err := p.UnpeerNetwork(ctx, guardID, peering.Name); err != nil {
+			return err
+		}
+	}
+	if err := p.DeleteRouteTable(ctx, p.global("routes/"+names.Routes)); err != nil {
+		return err
+	}
+
+	firewalls, err := list[firewall](ctx, p.c, p.global("firewalls"), "")
+	if err != nil {
+		return fmt.Errorf("failed to list firewall rules: %w", err)
+	}
+	for _, fw := range firewalls {
+		if lastSegment(fw.Network) != names.Network {
+			continue
+		}
+		fmt.Printf("   Deleting firewall rule %s...\n", fw.Name)
+		if err := p.c.remove(ctx, p.global("firewalls/"+fw.Name)); err != nil {
+			return fmt.Errorf("failed to delete firewall rule %s: %w", fw.Name, err)
+		}
+	}
+
+	for _, subnet := range net.Subnetworks {
+		region := segmentAfter(resourcePath(p.project, subnet), "regions")
+		for _, name := range []string{names.Address, names.InternalAddress} {
+			fmt.Printf("   Deleting address %s...\n", name)
+			if err := p.c.remove(ctx, p.regional(region, "addresses/"+name)); err != nil {
+				return fmt.Errorf("failed to delete address %s: %w", name, err)
+			}
+		}
+		fmt.Printf("   Deleting subnet %s...\n", lastSegment(subnet))
+		if err := p.c.remove(ctx, subnet); err != nil {
+			return fmt.Errorf("failed to delete subnet: %w", err)
+		}
+	}
+
+	fmt.Printf("   Deleting network %s...\n", names.Network)
+	if err := p.c.remove(ctx, p.global("networks/"+names.Network)); err != nil {
+		return fmt.Errorf("failed to delete network: %w", err)
+	}
+	return nil
+}
+
+// ConfigureNICForwarding enables IP forwarding on a NIC.
+func (p *Provider) ConfigureNICForwarding(ctx context.Context, nicID string) error {
+	// IP forwarding (canIpForward) is set on the VM at creation time in
+	// CreateServer; GCP has no separate network interface resource.
+	return nil
+}
+
+// EnsureNSGRule creates or updates a firewall rule in the guard's network,
+// applying to the guard VM.
+func (p *Provider) EnsureNSGRule(ctx context.Context, req guard.NSGRuleRequest) error {
+	names := newResourceNames(req.GuardID)
+
+	rule := firewallRule{IPProtocol: strings.ToLower(req.Protocol)}
+	if req.Protocol == "*" {
+		rule.IPProtocol = "all"
+	} else if req.DestPort != "" && req.DestPort != "*" {
+		rule.Ports = []string{req.DestPort}
+	}
+	fw := firewall{
+		Name:        resourceName(req.RuleName),
+		Network:     p.global("networks/" + names.Network),
+		Description: describe(managedTags(req.GuardID)),
+		Direction:   "INGRESS",
+		Priority:    req.Priority,
+		TargetTags:  []string{names.Tag},
+		Allowed:     []firewallRule{rule},
+	}
+	if req.Direction == "Outbound" {
+		fw.Direction = "EGRESS"
+		fw.DestinationRanges = []string{"0.0.0.0/0"}
+	} else {
+		fw.SourceRanges = []string{"0.0.0.0/0"}
+	}
+
+	err := p.c.mutate(ctx, http.MethodPost, p.global("firewalls"), fw)
+	if isConflict(err) {
+		err = p.c.mutate(ctx, http.MethodPut, p.global("firewalls/"+fw.Name), fw)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create firewall rule: %w", err)
+	}
+	return nil
+}
+
+// PeerNetwork peers the guard's network with a workload VPC in both
+// directions, allows traffic from the workload subnets to the guard, and
+// creates routes for the mesh CIDRs via the guard in the guard's network.
+// The guard's side exports them over the peering and the workload's side
+// imports them, so they apply to the whole workload VPC; req.SubnetID is
+// not needed.
+func (p *Provider) PeerNetwork(ctx context.Context, req guard.PeerRequest) error {
+	names := newResourceNames(req.GuardID)
+	guardNetwork := p.global("networks/" + names.Network)
+	remoteNetwork := resourcePath(p.project, req.RemoteVNetID)
+	remoteName := lastSegment(remoteNetwork)
+
+	// 1. Guard network -> remote network, exporting the mesh routes
+	fmt.Printf("   Creating peering: guard -> remote...\n")
+	err := p.addPeering(ctx, guardNetwork, networkPeering{
+		Name:                 resourceName(names.Network, "to", remoteName),
+		Network:              remoteNetwork,
+		ExchangeSubnetRoutes: true,
+		ExportCustomRoutes:   true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create forward peering: %w", err)
+	}
+
+	// 2. Remote network -> guard network, importing them
+	fmt.Printf("   Creating peering: remote -> guard...\n")
+	err = p.addPeering(ctx, remoteNetwork, networkPeering{
+		Name:                 resourceName(remoteName, "to", names.Network),
+		Network:              guardNetwork,
+		ExchangeSubnetRoutes: true,
+		ImportCustomRoutes:   true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create reverse peering: %w", err)
+	}
+
+	// 3. Allow the workload subnets to send traffic through the guard
+	var remote network
+	if err := p.c.do(ctx, http.MethodGet, remoteNetwork, nil, nil, &remote); err != nil {
+		return fmt.Errorf("failed to get remote network: %w", err)
+	}
+	var ranges []string
+	for _, link := range remote.Subnetworks {
+		var subnet subnetwork
+		if err := p.c.do(ctx, http.MethodGet, link, nil, nil, &subnet); err != nil {
+			return fmt.Errorf("failed to get remote subnet: %w", err)
+		}
+		ranges = append(ranges, subnet.IPCidrRange)
+	}
+	if len(ranges) > 0 {
+		fmt.Printf("   Allowing traffic from %s...\n", strings.Join(ranges, ", "))
+		tags := managedTags(req.GuardID)
+		err := p.c.insert(ctx, p.global("firewalls"), firewall{
+			Name:         resourceName(req.GuardID, "from", remoteName),
+			Network:      guardNetwork,
+			Description:  describe(tags),
+			Direction:    "INGRESS",
+			Priority:     1000,
+			SourceRanges: ranges,
+			TargetTags:   []string{names.Tag},
+			Allowed:      []firewallRule{{IPProtocol: "all"}},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create firewall rule: %w", err)
+		}
+	}
+
+	// 4. Mesh routes via the guard, shared by all of its peerings
+	if len(req.MeshCIDRs) > 0 {
+		fmt.Printf("   Creating routes for mesh CIDRs...\n")
+		for i, cidr := range req.MeshCIDRs {
+			err := p.c.insert(ctx, p.global("routes"), route{
+				Name:        resourceName(names.Routes, strconv.Itoa(i)),
+				Network:     guardNetwork,
+				Description: describe(managedTags(req.GuardID)),
+				DestRange:   cidr,
+				Priority:    meshRoutePriority,
+				NextHopIP:   req.GuardPrivateIP,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create route for %s: %w", cidr, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// addPeering adds a peering to a network, unless one of that name exists
+func (p *Provider) addPeering(ctx context.Context, networkPath string, peering networkPeering) error {
+	err := p.c.mutate(ctx, http.MethodPost, networkPath+"/addPeering", map[string]any{"networkPeering": peering})
+	if isConflict(err) {
+		return nil
+	}
+	return err
+}
+
+// UnpeerNetwork removes a peering of the guard's network, the matching
+// peering of the remote network and the firewall rule for its subnets.
+// The mesh routes are removed with the last peering.
+func (p *Provider) UnpeerNetwork(ctx context.Context, guardID, peeringName string) error {
+	names := newResourceNames(guardID)
+	guardNetwork := p.global("networks/" + names.Network)
+
+	var net network
+	if err := p.c.do(ctx, http.MethodGet, guardNetwork, nil, nil, &net); err != nil {
+		return fmt.Errorf("failed to get network: %w", err)
+	}
+	var remoteNetwork string
+	remaining := 0
+	for _, peering := range net.Peerings {
+		if peering.Name == peeringName {
+			remoteNetwork = resourcePath(p.project, peering.Network)
+		} else {
+			remaining++
+		}
+	}
+	if remoteNetwork == "" {
+		return fmt.Errorf("network %s has no peering %s", names.Network, peeringName)
+	}
+
+	fmt.Printf("   Removing peering %s...\n", peeringName)
+	if err := p.c.mutate(ctx, http.MethodPost, guardNetwork+"/removePeering", map[string]string{"name": peeringName}); err != nil {
+		return fmt.Errorf("failed to delete peering: %w", err)
+	}
+
+	// The remote side may be gone already, or in a project we cannot see
+	var remote network
+	if err := p.c.do(ctx, http.MethodGet, remoteNetwork, nil, nil, &remote); err == nil {
+		for _, peering := range remote.Peerings {
+			if resourcePath(p.project, peering.Network) != guardNetwork {
+				continue
+			}
+			fmt.Printf("   Removing peering %s...\n", peering.Name)
+			if err := p.c.mutate(ctx, http.MethodPost, remoteNetwork+"/removePeering", map[string]string{"name": peering.Name}); err != nil {
+				return fmt.Errorf("failed to delete reverse peering: %w", err)
+			}
+		}
+	}
+
+	if err := p.c.remove(ctx, p.global("firewalls/"+resourceName(guardID, "from", lastSegment(remoteNetwork)))); err != nil {
+		return fmt.Errorf("failed to delete firewall rule: %w", err)
+	}
+	if remaining == 0 {
+		fmt.Printf("   Deleting routes %s-*...\n", names.Routes)
+		return p.DeleteRouteTable(ctx, p.global("routes/"+names.Routes))
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/nimsforest/morpheus/pkg/guard"
+)
+
+// ListRouteTables returns the mesh routes created by PeerNetwork, as one
+// route table per guard, or for all guards if guardID is empty. GCP has
+// no route tables: the routes live in the guard's network and reach the
+// peered networks (listed as the table's subnets) by export.
+func (p *Provider) ListRouteTables(ctx context.Context, guardID string) ([]guard.RouteTable, error) {
+	routes, err := list[route](ctx, p.c, p.global("routes"), "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list routes: %w", err)
+	}
+
+	byGuard := make(map[string]*guard.RouteTable)
+	for _, r := range routes {
+		tags := parseDescription(r.Description)
+		id := tags[TagGuardID]
+		if tags[TagManagedBy] != TagManagedByValue || id == "" || (guardID != "" && id != guardID) {
+			continue
+		}
+		t := byGuard[id]
+		if t == nil {
+			names := newResourceNames(id)
+			t = &guard.RouteTable{
+				ID:            p.global("routes/" + names.Routes),
+				Name:          names.Routes,
+				ResourceGroup: p.project,
+				GuardID:       id,
+			}
+			byGuard[id] = t
+		}
+		t.Routes = append(t.Routes, r.DestRange)
+	}
+
+	var tables []guard.RouteTable
+	for id, t := range byGuard {
+		var net network
+		if err := p.c.do(ctx, http.MethodGet, p.global("networks/"+newResourceNames(id).Network), nil, nil, &net); err == nil {
+			for _, peering := range net.Peerings {
+				t.Subnets = append(t.Subnets, resourcePath(p.project, peering.Network))
+			}
+		}
+		tables = append(tables, *t)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].GuardID < tables[j].GuardID })
+	return tables, nil
+}
+
+// DeleteRouteTable deletes the mesh routes of a route table returned by
+// ListRouteTables.
+func (p *Provider) DeleteRouteTable(ctx context.Context, routeTableID string) error {
+	prefix := lastSegment(routeTableID) + "-"
+	routes, err := list[route](ctx, p.c, p.global("routes"), "")
+	if err != nil {
+		return fmt.Errorf("failed to list routes: %w", err)
+	}
+	for _, r := range routes {
+		if !strings.HasPrefix(r.Name, prefix) || !isManaged(r.Description) {
+			continue
+		}
+		if err := p.c.remove(ctx, p.global("routes/"+r.Name)); err != nil {
+			return fmt.Errorf("failed to delete route %s: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+// EffectiveRoutes returns the routes that apply to a VM: those of its
+// network, and the custom routes it imports from peered networks. GCP
+// keeps no per-interface route table, so they are collected here.
+func (p *Provider) EffectiveRoutes(ctx context.Context, vmID string) ([]guard.Route, error) {
+	var inst instance
+	if err := p.c.do(ctx, http.MethodGet, vmID, nil, nil, &inst); err != nil {
+		return nil, fmt.Errorf("failed to get VM: %w", err)
+	}
+	nic := inst.primaryNIC()
+	if nic == nil {
+		return nil, fmt.Errorf("VM %s has no network interface", inst.Name)
+	}
+	var vmTags []string
+	if inst.Tags != nil {
+		vmTags = inst.Tags.Items
+	}
+
+	vmNetwork := resourcePath(p.project, nic.Network)
+	var net network
+	if err := p.c.do(ctx, http.MethodGet, vmNetwork, nil, nil, &net); err != nil {
+		return nil, fmt.Errorf("failed to get network: %w", err)
+	}
+
+	var routes []guard.Route
+	own, err := p.networkRoutes(ctx, vmNetwork)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range own {
+		routes = append(routes, toGuardRoute(r, appliesTo(r, vmTags)))
+	}
+
+	// Exported static routes of peers; they carry no tags
+	for _, peering := range net.Peerings {
+		if !peering.ImportCustomRoutes {
+			continue
+		}
+		peerRoutes, err := p.networkRoutes(ctx, resourcePath(p.project, peering.Network))
+		if err != nil {
+			continue // The peer may be in a project we cannot see
+		}
+		for _, r := range peerRoutes {
+			if len(r.Tags) == 0 && (r.NextHopIP != "" || r.NextHopInstance != "") {
+				routes = append(routes, toGuardRoute(r, peering.State == "ACTIVE"))
+			}
+		}
+	}
+	return routes, nil
+}
+
+// networkRoutes lists the routes of one network
+func (p *Provider) networkRoutes(ctx context.Context, networkPath string) ([]route, error) {
+	project := segmentAfter(networkPath, "projects")
+	all, err := list[route](ctx, p.c, fmt.Sprintf("projects/%s/global/routes", project), "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list routes: %w", err)
+	}
+	var routes []route
+	for _, r := range all {
+		if resourcePath(project, r.Network) == networkPath {
+			routes = append(routes, r)
+		}
+	}
+	return routes, nil
+}
+
+// appliesTo reports whether a route applies to a VM with the given tags
+func appliesTo(r route, vmTags []string) bool {
+	if len(r.Tags) == 0 {
+		return true
+	}
+	for _, tag := range r.Tags {
+		if slices.Contains(vmTags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// toGuardRoute converts a route, naming its next hop like Azure does
+func toGuardRoute(r route, active bool) guard.Route {
+	gr := guard.Route{Prefixes: []string{r.DestRange}, Active: active}
+	switch {
+	case r.NextHopIP != "":
+		gr.NextHopType = guard.NextHopVirtualAppliance
+		gr.NextHops = []string{r.NextHopIP}
+	case r.NextHopInstance != "":
+		gr.NextHopType = guard.NextHopVirtualAppliance
+		gr.NextHops = []string{lastSegment(r.NextHopInstance)}
+	case r.NextHopGateway != "":
+		gr.NextHopType = "Internet"
+	case r.NextHopNetwork != "":
+		gr.NextHopType = "VnetLocal"
+	case r.NextHopPeering != "":
+		gr.NextHopType = "VNetPeering"
+	default:
+		gr.NextHopType = "None"
+	}
+	return gr
+}
+
+// RunCommand is not available on GCP, which has no equivalent of Azure
+// Run Command; use SSH to the VM instead.
+func (p *Provider) RunCommand(ctx context.Context, vmID, script string) (string, error) {
+	return "", fmt.Errorf("running commands on VMs is not supported on GCP; use SSH")
+}
@@ -0,0 +1,158 @@
+package gcp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// TagManagedBy identifies resources managed by the GCP guard provider
+	TagManagedBy = "managed-by"
+	// TagManagedByValue is the label value for guard-managed resources
+	TagManagedByValue = "morpheus-gcpguard"
+	// TagGuardID identifies the guard a resource belongs to
+	TagGuardID = "guard-id"
+	// TagGroup identifies the group of guards created together
+	TagGroup = "guard-group"
+	// TagMeshCIDRs stores the mesh CIDRs as a comma-separated string
+	TagMeshCIDRs = "mesh-cidrs"
+	// TagWGPort stores the WireGuard port
+	TagWGPort = "wg-port"
+	// TagWGPublicKey stores the guard's WireGuard public key
+	TagWGPublicKey = "wg-public-key"
+)
+
+// instanceLabels are the guard labels kept as GCP labels on the instance.
+// The others hold values labels cannot (CIDRs, keys) and are kept in the
+// instance metadata instead.
+var instanceLabels = map[string]bool{
+	TagManagedBy: true,
+	TagGuardID:   true,
+	TagGroup:     true,
+}
+
+// providerLabels are labels the guard provisioner sets for Azure only
+var providerLabels = map[string]bool{
+	"nic-id":         true,
+	"resource-group": true,
+}
+
+// resourceNames generates consistent GCP resource names from a guard ID.
+// GCP has no resource groups; a guard's resources are found by name.
+type resourceNames struct {
+	GuardID          string
+	Network          string
+	Subnet           string
+	Address          string // Static external IP
+	InternalAddress  string // Reserved private IP of the VM
+	Instance         string
+	FirewallSSH      string
+	FirewallWG       string
+	FirewallInternal string
+	Routes           string // Prefix of the mesh routes
+	Tag              string // Network tag the firewall rules target
+}
+
+func newResourceNames(guardID string) resourceNames {
+	return resourceNames{
+		GuardID:          guardID,
+		Network:          resourceName(guardID, "vpc"),
+		Subnet:           resourceName(guardID, "subnet"),
+		Address:          resourceName(guardID, "ip"),
+		InternalAddress:  resourceName(guardID, "internal-ip"),
+		Instance:         resourceName(guardID, "vm"),
+		FirewallSSH:      resourceName(guardID, "allow-ssh"),
+		FirewallWG:       resourceName(guardID, "allow-wireguard"),
+		FirewallInternal: resourceName(guardID, "allow-internal"),
+		Routes:           resourceName(guardID, "mesh"),
+		Tag:              resourceName(guardID),
+	}
+}
+
+// resourceName joins parts with hyphens into a valid GCP resource name:
+// lowercase, at most 63 characters, not ending in a hyphen
+func resourceName(parts ...string) string {
+	name := strings.ToLower(strings.Join(parts, "-"))
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.TrimRight(name, "-")
+}
+
+// describe encodes guard tags into a resource description, for resources
+// that cannot carry labels (networks, firewall rules, routes)
+func describe(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	fields := make([]string, len(keys))
+	for i, k := range keys {
+		fields[i] = k + "=" + tags[k]
+	}
+	return strings.Join(fields, " ")
+}
+
+// parseDescription decodes the tags written by describe
+func parseDescription(description string) map[string]string {
+	tags := make(map[string]string)
+	for _, field := range strings.Fields(description) {
+		if k, v, ok := strings.Cut(field, "="); ok {
+			tags[k] = v
+		}
+	}
+	return tags
+}
+
+// managedTags returns the description tags of a guard's resource
+func managedTags(guardID string) map[string]string {
+	return map[string]string{TagManagedBy: TagManagedByValue, TagGuardID: guardID}
+}
+
+// isManaged reports whether a description marks a resource as ours
+func isManaged(description string) bool {
+	return parseDescription(description)[TagManagedBy] == TagManagedByValue
+}
+
+// regionOf returns the region of a zone, e.g. europe-west1 for europe-west1-b
+func regionOf(zone string) string {
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		return zone[:i]
+	}
+	return zone
+}
+
+// resourcePath returns the path of a resource relative to the Compute API
+// root, e.g. projects/p/global/networks/n, from a self link, a path, or a
+// bare name (taken as a network of project)
+func resourcePath(project, link string) string {
+	if _, rest, ok := strings.Cut(link, "/compute/v1/"); ok {
+		return rest
+	}
+	link = strings.TrimPrefix(link, "/")
+	if !strings.Contains(link, "/") {
+		return fmt.Sprintf("projects/%s/global/networks/%s", project, link)
+	}
+	return link
+}
+
+// lastSegment returns the name at the end of a self link or path
+func lastSegment(link string) string {
+	return link[strings.LastIndex(link, "/")+1:]
+}
+
+// segmentAfter returns the path segment following key, e.g. the project of
+// a resource for "projects"
+func segmentAfter(link, key string) string {
+	parts := strings.Split(link, "/")
+	for i, part := range parts {
+		if part == key && i+1 < len(parts) {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
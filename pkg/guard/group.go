@@ -0,0 +1,160 @@
+package guard
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/wireguard"
+)
+
+// CreateGroupRequest asks for identical guards in several locations, made
+// from one WireGuard config template
+type CreateGroupRequest struct {
+	Locations      []string
+	ConfigTemplate string // wg0.conf, may use {{.Location}} and {{.GuardID}}
+	MeshCIDRs      []string
+	SSHSources     []string
+	Tags           map[string]string
+}
+
+// ConfigData is what a guard config template is rendered with
+type ConfigData struct {
+	GuardID  string
+	Group    string
+	Location string
+}
+
+// ProvisionGroup creates a guard in each location of req. The guards share
+// a group ID, which is also the prefix of their IDs, e.g. guard-1738123456
+// with guard-1738123456-westeurope and guard-1738123456-northeurope. Each
+// guard has its own WireGuard key and, on Azure, a resource group of its
+// own, named after the configured one and its location. The key is set as
+// the [Interface] PrivateKey of its config and kept in the secret store if
+// one is set.
+// Guards that fail are reported in the error; the others are returned.
+func (p *Provisioner) ProvisionGroup(ctx context.Context, req CreateGroupRequest) (string, []*Guard, error) {
+	if len(req.Locations) == 0 {
+		return "", nil, fmt.Errorf("no locations given")
+	}
+	group := p.config.Naming.GuardID(config.NameData{Role: "guard", Timestamp: time.Now().Unix()})
+
+	var guards []*Guard
+	var errs []error
+	for _, location := range req.Locations {
+		guardID := fmt.Sprintf("%s-%s", group, location)
+		conf, err := RenderConfig(req.ConfigTemplate, ConfigData{GuardID: guardID, Group: group, Location: location})
+		if err != nil {
+			return group, nil, err
+		}
+		privateKey, publicKey, err := wireguard.GenerateKeyPair()
+		if err != nil {
+			return group, guards, err
+		}
+		resourceGroup := machineSettings(p.config).ResourceGroup
+		if resourceGroup != "" {
+			resourceGroup = fmt.Sprintf("%s-%s", resourceGroup, location)
+		}
+		g, err := p.Provision(ctx, CreateGuardRequest{
+			GuardID:       guardID,
+			Group:         group,
+			Location:      location,
+			ResourceGroup: resourceGroup,
+			WireGuardConf: SetPrivateKey(conf, privateKey),
+			PublicKey:     publicKey,
+			MeshCIDRs:     req.MeshCIDRs,
+			SSHSources:    req.SSHSources,
+			Tags:          req.Tags,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", location, err))
+			continue
+		}
+		if p.secrets != nil {
+			if err := p.secrets.Put(ctx, "guard/"+guardID+"/wg-private-key", []byte(privateKey+"\n")); err != nil {
+				errs = append(errs, fmt.Errorf("%s: failed to save WireGuard key: %w", location, err))
+			}
+		}
+		guards = append(guards, g)
+	}
+	return group, guards, errors.Join(errs...)
+}
+
+// TeardownGroup removes all guards of a group
+func (p *Provisioner) TeardownGroup(ctx context.Context, group string) error {
+	guards, err := p.provider.ListGuards(ctx)
+	if err != nil {
+		return err
+	}
+	members := GroupMembers(guards, group)
+	if len(members) == 0 {
+		return fmt.Errorf("no guards in group %s", group)
+	}
+	var errs []error
+	for _, g := range members {
+		if err := p.Teardown(ctx, g.ID); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", g.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// GroupMembers returns the guards of a group
+func GroupMembers(guards []*Guard, group string) []*Guard {
+	var members []*Guard
+	for _, g := range guards {
+		if g.Group != "" && g.Group == group {
+			members = append(members, g)
+		}
+	}
+	return members
+}
+
+// RenderConfig renders a WireGuard config template for one guard
+func RenderConfig(text string, data ConfigData) (string, error) {
+	tmpl, err := template.New("wg0.conf").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid config template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("invalid config template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// SetPrivateKey sets the [Interface] PrivateKey of a WireGuard config,
+// replacing the one it has or adding it
+func SetPrivateKey(conf, privateKey string) string {
+	var out []string
+	section := ""
+	set := false
+	scanner := bufio.NewScanner(strings.NewReader(conf))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			section = strings.ToLower(strings.Trim(trimmed, "[]"))
+			out = append(out, line)
+			if section == "interface" && !set {
+				out = append(out, "PrivateKey = "+privateKey)
+				set = true
+			}
+			continue
+		}
+		if key, _, ok := strings.Cut(trimmed, "="); ok && section == "interface" && strings.EqualFold(strings.TrimSpace(key), "privatekey") {
+			continue
+		}
+		out = append(out, line)
+	}
+	if !set {
+		out = append([]string{"[Interface]", "PrivateKey = " + privateKey, ""}, out...)
+	}
+	return strings.Join(out, "\n") + "\n"
+}
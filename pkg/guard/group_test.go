@@ -0,0 +1,59 @@
+package guard
+
+import (
+	"crypto/ecdh"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/wireguard"
+)
+
+func TestGroupConfig(t *testing.T) {
+	conf, err := RenderConfig(`[Interface]
+PrivateKey = template-key
+Address = 10.200.0.1/32 # {{.Location}}
+
+[Peer]
+PublicKey = AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=
+`, ConfigData{GuardID: "guard-1-westeurope", Group: "guard-1", Location: "westeurope"})
+	if err != nil {
+		t.Fatalf("RenderConfig() error = %v", err)
+	}
+	if !strings.Contains(conf, "# westeurope") {
+		t.Errorf("RenderConfig() = %q", conf)
+	}
+	if _, err := RenderConfig("{{.Region}}", ConfigData{}); err == nil {
+		t.Error("RenderConfig() with an unknown field: expected error")
+	}
+
+	privateKey, publicKey, err := wireguard.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("wireguard.GenerateKeyPair() error = %v", err)
+	}
+	conf = SetPrivateKey(conf, privateKey)
+	if strings.Contains(conf, "template-key") || strings.Count(conf, "PrivateKey") != 1 {
+		t.Errorf("SetPrivateKey() = %q", conf)
+	}
+	cfg, err := ParseClientConfig(conf)
+	if err != nil {
+		t.Fatalf("ParseClientConfig() error = %v", err)
+	}
+	key, err := ecdh.X25519().NewPrivateKey(cfg.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()); got != publicKey {
+		t.Errorf("public key = %s, want %s", got, publicKey)
+	}
+
+	// A config without [Interface] gets one
+	if conf := SetPrivateKey("[Peer]\nPublicKey = x\n", privateKey); !strings.HasPrefix(conf, "[Interface]\nPrivateKey = "+privateKey) {
+		t.Errorf("SetPrivateKey() without [Interface] = %q", conf)
+	}
+
+	guards := []*Guard{{ID: "a", Group: "g1"}, {ID: "b"}, {ID: "c", Group: "g1"}, {ID: "d", Group: "g2"}}
+	if members := GroupMembers(guards, "g1"); len(members) != 2 || members[1].ID != "c" {
+		t.Errorf("GroupMembers() = %v", members)
+	}
+}
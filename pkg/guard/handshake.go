@@ -0,0 +1,355 @@
+package guard
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"github.com/nimsforest/morpheus/pkg/wireguard"
+)
+
+// ClientConfig is the part of a WireGuard client config (wg0.conf) needed
+// to handshake with a guard
+type ClientConfig struct {
+	PrivateKey    []byte // Client's private key
+	PeerPublicKey []byte // Guard's public key
+	PresharedKey  []byte // Optional
+	Endpoint      string // host:port of the guard, if set
+}
+
+// ParseClientConfig reads the [Interface] private key and the first
+// [Peer] of a WireGuard config
+func ParseClientConfig(text string) (*ClientConfig, error) {
+	cfg := &ClientConfig{}
+	section := ""
+	peers := 0
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			section = strings.ToLower(strings.Trim(line, "[]"))
+			if section == "peer" {
+				peers++
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+
+		var err error
+		switch {
+		case section == "interface" && key == "privatekey":
+			cfg.PrivateKey, err = wireguard.DecodeKey(value)
+		case section == "peer" && peers == 1 && key == "publickey":
+			cfg.PeerPublicKey, err = wireguard.DecodeKey(value)
+		case section == "peer" && peers == 1 && key == "presharedkey":
+			cfg.PresharedKey, err = wireguard.DecodeKey(value)
+		case section == "peer" && peers == 1 && key == "endpoint":
+			cfg.Endpoint = value
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+	if cfg.PrivateKey == nil {
+		return nil, fmt.Errorf("no PrivateKey in [Interface]")
+	}
+	if cfg.PeerPublicKey == nil {
+		return nil, fmt.Errorf("no [Peer] with a PublicKey")
+	}
+	return cfg, nil
+}
+
+// ErrPortClosed means the host answered a UDP packet with ICMP port
+// unreachable: nothing listens on the port
+var ErrPortClosed = errors.New("port closed (ICMP port unreachable)")
+
+// ProbeUDP sends a packet to addr and waits for an ICMP error. WireGuard
+// silently drops packets it does not understand, so a nil error means the
+// port is open or filtered.
+func ProbeUDP(ctx context.Context, addr string, timeout time.Duration) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// ICMP errors are reported on a later read or write of the socket
+	buf := make([]byte, 64)
+	for i := 0; i < 2; i++ {
+		conn.SetDeadline(time.Now().Add(timeout / 2))
+		if _, err := conn.Write([]byte{0}); err != nil {
+			return udpError(err)
+		}
+		if _, err := conn.Read(buf); err != nil {
+			var nerr net.Error
+			if errors.As(err, &nerr) && nerr.Timeout() {
+				continue
+			}
+			return udpError(err)
+		}
+	}
+	return nil
+}
+
+func udpError(err error) error {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrPortClosed
+	}
+	return err
+}
+
+// WireGuard message types and sizes
+const (
+	msgInitiation     = 1
+	msgResponse       = 2
+	msgCookieReply    = 3
+	initiationSize    = 148
+	responseSize      = 92
+	noiseConstruction = "Noise_IKpsk2_25519_ChaChaPoly_BLAKE2s"
+	noiseIdentifier   = "WireGuard v1 zx2c4 Jason@zx2c4.com"
+	labelMAC1         = "mac1----"
+)
+
+// ErrUnderLoad means the guard answered with a cookie: it is reachable
+// but rate limits handshakes
+var ErrUnderLoad = errors.New("guard is under load (cookie reply)")
+
+// Handshake performs a WireGuard handshake with the peer at endpoint as the
+// client of cfg and returns the round-trip time. It succeeds only if the
+// peer accepts the client's key and answers with a valid response.
+func Handshake(ctx context.Context, endpoint string, cfg *ClientConfig, timeout time.Duration) (time.Duration, error) {
+	priv, err := ecdh.X25519().NewPrivateKey(cfg.PrivateKey)
+	if err != nil {
+		return 0, err
+	}
+	peer, err := ecdh.X25519().NewPublicKey(cfg.PeerPublicKey)
+	if err != nil {
+		return 0, err
+	}
+
+	init, state, err := newInitiation(priv, peer, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", endpoint)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(timeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	conn.SetDeadline(deadline)
+
+	start := time.Now()
+	if _, err := conn.Write(init); err != nil {
+		return 0, udpError(err)
+	}
+	buf := make([]byte, 256)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			var nerr net.Error
+			if errors.As(err, &nerr) && nerr.Timeout() {
+				return 0, fmt.Errorf("no handshake response after %s", timeout)
+			}
+			return 0, udpError(err)
+		}
+		msg := buf[:n]
+		switch {
+		case n == responseSize && msg[0] == msgResponse:
+			if err := state.consumeResponse(msg, cfg.PresharedKey); err != nil {
+				return 0, err
+			}
+			return time.Since(start), nil
+		case n > 0 && msg[0] == msgCookieReply:
+			return 0, ErrUnderLoad
+		}
+		// Anything else is not for us
+	}
+}
+
+// handshakeState is the initiator's side of a Noise IK handshake
+type handshakeState struct {
+	chain     [32]byte
+	hash      [32]byte
+	sender    uint32
+	static    *ecdh.PrivateKey
+	ephemeral *ecdh.PrivateKey
+}
+
+func (s *handshakeState) mixHash(data []byte) {
+	s.hash = blake2s.Sum256(append(s.hash[:], data...))
+}
+
+// newInitiation builds a handshake initiation message
+func newInitiation(static *ecdh.PrivateKey, peer *ecdh.PublicKey, now time.Time) ([]byte, *handshakeState, error) {
+	s := &handshakeState{static: static}
+	s.chain = blake2s.Sum256([]byte(noiseConstruction))
+	s.hash = blake2s.Sum256(append(s.chain[:], noiseIdentifier...))
+	s.mixHash(peer.Bytes())
+
+	var err error
+	if s.ephemeral, err = ecdh.X25519().GenerateKey(rand.Reader); err != nil {
+		return nil, nil, err
+	}
+	var idx [4]byte
+	if _, err := rand.Read(idx[:]); err != nil {
+		return nil, nil, err
+	}
+	s.sender = binary.LittleEndian.Uint32(idx[:])
+
+	msg := make([]byte, initiationSize)
+	msg[0] = msgInitiation
+	binary.LittleEndian.PutUint32(msg[4:8], s.sender)
+
+	ephemeral := s.ephemeral.PublicKey().Bytes()
+	copy(msg[8:40], ephemeral)
+	s.chain = kdf1(s.chain[:], ephemeral)
+	s.mixHash(ephemeral)
+
+	shared, err := s.ephemeral.ECDH(peer)
+	if err != nil {
+		return nil, nil, err
+	}
+	var key [32]byte
+	s.chain, key = kdf2(s.chain[:], shared)
+	encStatic := seal(key, static.PublicKey().Bytes(), s.hash[:])
+	copy(msg[40:88], encStatic)
+	s.mixHash(encStatic)
+
+	if shared, err = static.ECDH(peer); err != nil {
+		return nil, nil, err
+	}
+	s.chain, key = kdf2(s.chain[:], shared)
+	encTimestamp := seal(key, tai64n(now), s.hash[:])
+	copy(msg[88:116], encTimestamp)
+	s.mixHash(encTimestamp)
+
+	copy(msg[116:132], mac1(peer.Bytes(), msg[:116]))
+	return msg, s, nil
+}
+
+// consumeResponse checks a handshake response to the initiation
+func (s *handshakeState) consumeResponse(msg, presharedKey []byte) error {
+	if binary.LittleEndian.Uint32(msg[8:12]) != s.sender {
+		return fmt.Errorf("handshake response for another initiation")
+	}
+	if !hmac.Equal(msg[60:76], mac1(s.static.PublicKey().Bytes(), msg[:60])) {
+		return fmt.Errorf("handshake response has an invalid MAC")
+	}
+
+	ephemeral, err := ecdh.X25519().NewPublicKey(msg[12:44])
+	if err != nil {
+		return err
+	}
+	s.chain = kdf1(s.chain[:], ephemeral.Bytes())
+	s.mixHash(ephemeral.Bytes())
+	shared, err := s.ephemeral.ECDH(ephemeral)
+	if err != nil {
+		return err
+	}
+	s.chain = kdf1(s.chain[:], shared)
+	if shared, err = s.static.ECDH(ephemeral); err != nil {
+		return err
+	}
+	s.chain = kdf1(s.chain[:], shared)
+
+	psk := make([]byte, 32)
+	copy(psk, presharedKey)
+	var tau, key [32]byte
+	s.chain, tau, key = kdf3(s.chain[:], psk)
+	s.mixHash(tau[:])
+	if _, err := open(key, msg[44:60], s.hash[:]); err != nil {
+		return fmt.Errorf("handshake response rejected: wrong keys or preshared key")
+	}
+	return nil
+}
+
+func newBlake2s() hash.Hash {
+	h, _ := blake2s.New256(nil)
+	return h
+}
+
+func hmacSum(key []byte, data ...[]byte) [32]byte {
+	mac := hmac.New(newBlake2s, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	var sum [32]byte
+	copy(sum[:], mac.Sum(nil))
+	return sum
+}
+
+func kdf1(key, input []byte) [32]byte {
+	t0 := hmacSum(key, input)
+	return hmacSum(t0[:], []byte{1})
+}
+
+func kdf2(key, input []byte) (t1, t2 [32]byte) {
+	t0 := hmacSum(key, input)
+	t1 = hmacSum(t0[:], []byte{1})
+	t2 = hmacSum(t0[:], t1[:], []byte{2})
+	return t1, t2
+}
+
+func kdf3(key, input []byte) (t1, t2, t3 [32]byte) {
+	t0 := hmacSum(key, input)
+	t1 = hmacSum(t0[:], []byte{1})
+	t2 = hmacSum(t0[:], t1[:], []byte{2})
+	t3 = hmacSum(t0[:], t2[:], []byte{3})
+	return t1, t2, t3
+}
+
+// seal encrypts with a zero nonce; every handshake key is used once
+func seal(key [32]byte, plaintext, ad []byte) []byte {
+	aead, _ := chacha20poly1305.New(key[:])
+	return aead.Seal(nil, make([]byte, chacha20poly1305.NonceSize), plaintext, ad)
+}
+
+func open(key [32]byte, ciphertext, ad []byte) ([]byte, error) {
+	aead, _ := chacha20poly1305.New(key[:])
+	return aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), ciphertext, ad)
+}
+
+// mac1 authenticates a message to the holder of publicKey
+func mac1(publicKey, msg []byte) []byte {
+	key := blake2s.Sum256(append([]byte(labelMAC1), publicKey...))
+	h, _ := blake2s.New128(key[:])
+	h.Write(msg)
+	return h.Sum(nil)
+}
+
+func tai64n(t time.Time) []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint64(b, 0x400000000000000a+uint64(t.Unix()))
+	binary.BigEndian.PutUint32(b[8:], uint32(t.Nanosecond()))
+	return b
+}
@@ -0,0 +1,187 @@
+package guard
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/blake2s"
+)
+
+func TestParseClientConfig(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	cfg, err := ParseClientConfig(`[Interface]
+PrivateKey = ` + key + `
+Address = 10.200.0.2/32
+
+[Peer]
+PublicKey = ` + key + ` # the guard
+Endpoint = 203.0.113.10:51820
+AllowedIPs = 10.200.0.0/16
+
+[Peer]
+PublicKey = invalid
+`)
+	if err != nil {
+		t.Fatalf("ParseClientConfig() error = %v", err)
+	}
+	if cfg.Endpoint != "203.0.113.10:51820" || len(cfg.PeerPublicKey) != 32 {
+		t.Errorf("ParseClientConfig() = %+v", cfg)
+	}
+
+	if _, err := ParseClientConfig("[Interface]\nPrivateKey = " + key + "\n"); err == nil {
+		t.Error("ParseClientConfig() without a peer: expected error")
+	}
+	if _, err := ParseClientConfig("[Interface]\nPrivateKey = c2hvcnQ=\n"); err == nil {
+		t.Error("ParseClientConfig() with a short key: expected error")
+	}
+}
+
+// testResponder answers WireGuard handshake initiations from known clients
+// as described in the WireGuard paper, section 5.4
+type testResponder struct {
+	static  *ecdh.PrivateKey
+	clients map[string]bool // Accepted client public keys
+	psk     []byte
+}
+
+func (r *testResponder) respond(init []byte) ([]byte, error) {
+	if len(init) != initiationSize || init[0] != msgInitiation {
+		return nil, errors.New("not an initiation")
+	}
+	pub := r.static.PublicKey().Bytes()
+	if !bytesEqual(init[116:132], mac1(pub, init[:116])) {
+		return nil, errors.New("bad mac1")
+	}
+
+	chain := blake2s.Sum256([]byte(noiseConstruction))
+	h := blake2s.Sum256(append(chain[:], noiseIdentifier...))
+	h = blake2s.Sum256(append(h[:], pub...))
+
+	ei, err := ecdh.X25519().NewPublicKey(init[8:40])
+	if err != nil {
+		return nil, err
+	}
+	chain = kdf1(chain[:], ei.Bytes())
+	h = blake2s.Sum256(append(h[:], ei.Bytes()...))
+	shared, _ := r.static.ECDH(ei)
+	var key [32]byte
+	chain, key = kdf2(chain[:], shared)
+	clientStatic, err := open(key, init[40:88], h[:])
+	if err != nil {
+		return nil, err
+	}
+	if !r.clients[string(clientStatic)] {
+		return nil, errors.New("unknown client")
+	}
+	h = blake2s.Sum256(append(h[:], init[40:88]...))
+	si, _ := ecdh.X25519().NewPublicKey(clientStatic)
+	shared, _ = r.static.ECDH(si)
+	chain, key = kdf2(chain[:], shared)
+	if _, err := open(key, init[88:116], h[:]); err != nil {
+		return nil, err
+	}
+	h = blake2s.Sum256(append(h[:], init[88:116]...))
+
+	er, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	resp := make([]byte, responseSize)
+	resp[0] = msgResponse
+	binary.LittleEndian.PutUint32(resp[4:8], 7)
+	copy(resp[8:12], init[4:8])
+	copy(resp[12:44], er.PublicKey().Bytes())
+	chain = kdf1(chain[:], er.PublicKey().Bytes())
+	h = blake2s.Sum256(append(h[:], er.PublicKey().Bytes()...))
+	shared, _ = er.ECDH(ei)
+	chain = kdf1(chain[:], shared)
+	shared, _ = er.ECDH(si)
+	chain = kdf1(chain[:], shared)
+	psk := make([]byte, 32)
+	copy(psk, r.psk)
+	_, tau, key := kdf3(chain[:], psk)
+	h = blake2s.Sum256(append(h[:], tau[:]...))
+	copy(resp[44:60], seal(key, nil, h[:]))
+	copy(resp[60:76], mac1(clientStatic, resp[:60]))
+	return resp, nil
+}
+
+func bytesEqual(a, b []byte) bool {
+	return string(a) == string(b)
+}
+
+// serveResponder answers handshakes on a local UDP port until the test ends
+func serveResponder(t *testing.T, r *testResponder) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			// Unknown clients get no answer, as from WireGuard
+			if resp, err := r.respond(buf[:n]); err == nil {
+				conn.WriteTo(resp, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestHandshake(t *testing.T) {
+	server, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	client, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	psk := make([]byte, 32)
+	rand.Read(psk)
+	r := &testResponder{static: server, clients: map[string]bool{string(client.PublicKey().Bytes()): true}, psk: psk}
+	endpoint := serveResponder(t, r)
+	ctx := context.Background()
+
+	cfg := &ClientConfig{PrivateKey: client.Bytes(), PeerPublicKey: server.PublicKey().Bytes(), PresharedKey: psk}
+	if _, err := Handshake(ctx, endpoint, cfg, 2*time.Second); err != nil {
+		t.Fatalf("Handshake() error = %v", err)
+	}
+
+	// A wrong preshared key fails to decrypt the response
+	wrongPSK := *cfg
+	wrongPSK.PresharedKey = nil
+	if _, err := Handshake(ctx, endpoint, &wrongPSK, 2*time.Second); err == nil {
+		t.Error("Handshake() with the wrong preshared key: expected error")
+	}
+
+	// An unknown client gets no response
+	stranger, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	unknown := *cfg
+	unknown.PrivateKey = stranger.Bytes()
+	if _, err := Handshake(ctx, endpoint, &unknown, 200*time.Millisecond); err == nil {
+		t.Error("Handshake() as an unknown client: expected error")
+	}
+}
+
+func TestProbeUDPClosedPort(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := conn.LocalAddr().String()
+	conn.Close()
+
+	if err := ProbeUDP(context.Background(), addr, time.Second); !errors.Is(err, ErrPortClosed) {
+		t.Errorf("ProbeUDP() on a closed port = %v, want %v", err, ErrPortClosed)
+	}
+
+	open := serveResponder(t, &testResponder{})
+	if err := ProbeUDP(context.Background(), open, 200*time.Millisecond); err != nil {
+		t.Errorf("ProbeUDP() on an open port = %v", err)
+	}
+}
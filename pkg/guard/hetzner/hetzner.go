@@ -0,0 +1,522 @@
+package hetzner
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/nimsforest/morpheus/pkg/guard"
+	"github.com/nimsforest/morpheus/pkg/httputil"
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+// Provider implements guard.GuardProvider for Hetzner Cloud. A guard is a
+// private network with one cloud subnet, a firewall for SSH and WireGuard,
+// a primary IPv4 address and a server attached to the network at a fixed
+// address; all of them are labeled with the guard ID. Hetzner has no
+// network peering: the guard server is attached to workload networks
+// instead, which route the mesh CIDRs to it.
+type Provider struct {
+	location   string
+	serverType string
+	image      string
+	client     *hcloud.Client
+}
+
+// Ensure Provider satisfies guard.GuardProvider
+var _ guard.GuardProvider = (*Provider)(nil)
+
+// NewProvider creates a new Hetzner guard provider.
+func NewProvider(apiToken, location, serverType, image string) (*Provider, error) {
+	return NewProviderWithEndpoint(apiToken, "", location, serverType, image)
+}
+
+// NewProviderWithEndpoint creates a Hetzner guard provider that talks to
+// the API at endpoint, or the public API if it is empty (for testing).
+func NewProviderWithEndpoint(apiToken, endpoint, location, serverType, image string) (*Provider, error) {
+	apiToken = strings.TrimSpace(apiToken)
+	if apiToken == "" {
+		return nil, fmt.Errorf("a Hetzner API token is required (secrets.hetzner_api_token or HETZNER_API_TOKEN)")
+	}
+
+	opts := []hcloud.ClientOption{
+		hcloud.WithToken(apiToken),
+		hcloud.WithHTTPClient(httputil.CreateHTTPClient(30 * time.Second)),
+	}
+	if endpoint != "" {
+		opts = append(opts, hcloud.WithEndpoint(strings.TrimSuffix(endpoint, "/")))
+	}
+	return &Provider{
+		location:   location,
+		serverType: serverType,
+		image:      image,
+		client:     hcloud.NewClient(opts...),
+	}, nil
+}
+
+// wrapError wraps an API error, pointing at the token if it was refused
+func wrapError(err error, operation string) error {
+	if hcloud.IsError(err, hcloud.ErrorCodeUnauthorized) {
+		return fmt.Errorf("%s: %w (check the Hetzner API token)", operation, err)
+	}
+	return fmt.Errorf("%s: %w", operation, err)
+}
+
+// waitForAction waits until an action has finished. Actions that finished
+// already need no polling.
+func (p *Provider) waitForAction(ctx context.Context, action *hcloud.Action) error {
+	if action == nil || action.Status == hcloud.ActionStatusSuccess {
+		return nil
+	}
+	_, errCh := p.client.Action.WatchProgress(ctx, action)
+	return <-errCh
+}
+
+// CreateServer creates the guard server, with the guard's primary IP and
+// firewall, attached to the guard's network at the address EnsureNetwork
+// returned. The network must already exist; it is found by the guard-id
+// label. The server is started once it is attached, so cloud-init sees
+// the private interface.
+func (p *Provider) CreateServer(ctx context.Context, req machine.CreateServerRequest) (*machine.Server, error) {
+	guardID := req.Labels[TagGuardID]
+	if guardID == "" {
+		return nil, fmt.Errorf("guard-id label is required for Hetzner server creation")
+	}
+	names := newResourceNames(guardID)
+
+	serverTypeName := req.ServerType
+	if serverTypeName == "" {
+		serverTypeName = p.serverType
+	}
+	serverType, _, err := p.client.ServerType.GetByName(ctx, serverTypeName)
+	if err != nil {
+		return nil, wrapError(err, "failed to get server type")
+	}
+	if serverType == nil {
+		return nil, fmt.Errorf("server type not found: %s", serverTypeName)
+	}
+	imageName := req.Image
+	if imageName == "" {
+		imageName = p.image
+	}
+	image, _, err := p.client.Image.GetForArchitecture(ctx, imageName, serverType.Architecture)
+	if err != nil {
+		return nil, wrapError(err, "failed to get image")
+	}
+	if image == nil {
+		return nil, fmt.Errorf("image not found: %s", imageName)
+	}
+	locationName := req.Location
+	if locationName == "" {
+		locationName = p.location
+	}
+	location, _, err := p.client.Location.GetByName(ctx, locationName)
+	if err != nil {
+		return nil, wrapError(err, "failed to get location")
+	}
+	if location == nil {
+		return nil, fmt.Errorf("location not found: %s", locationName)
+	}
+
+	network, err := p.guardNetwork(ctx, guardID)
+	if err != nil {
+		return nil, err
+	}
+	if network == nil {
+		return nil, fmt.Errorf("network %s not found", names.Network)
+	}
+	privateIP, err := guardIP(network)
+	if err != nil {
+		return nil, err
+	}
+	fw, err := p.guardFirewall(ctx, guardID)
+	if err != nil {
+		return nil, err
+	}
+	if fw == nil {
+		return nil, fmt.Errorf("firewall %s not found", names.Firewall)
+	}
+	ip, err := p.guardPrimaryIP(ctx, guardID)
+	if err != nil {
+		return nil, err
+	}
+	if ip == nil {
+		return nil, fmt.Errorf("primary IP %s not found", names.Address)
+	}
+
+	var sshKeys []*hcloud.SSHKey
+	for i, publicKey := range req.SSHKeys {
+		key, err := p.ensureSSHKey(ctx, guardID, i, publicKey)
+		if err != nil {
+			return nil, err
+		}
+		sshKeys = append(sshKeys, key)
+	}
+
+	labels := toLabels(req.Labels)
+	labels[TagManagedBy] = TagManagedByValue
+
+	result, _, err := p.client.Server.Create(ctx, hcloud.ServerCreateOpts{
+		Name:             req.Name,
+		ServerType:       serverType,
+		Image:            image,
+		Location:         location,
+		SSHKeys:          sshKeys,
+		UserData:         req.UserData,
+		Labels:           labels,
+		StartAfterCreate: hcloud.Ptr(false),
+		Firewalls:        []*hcloud.ServerCreateFirewall{{Firewall: *fw}},
+		PublicNet: &hcloud.ServerCreatePublicNet{
+			EnableIPv4: true,
+			IPv4:       ip,
+		},
+	})
+	if err != nil {
+		return nil, wrapError(err, "failed to create server")
+	}
+	if err := p.waitForAction(ctx, result.Action); err != nil {
+		return nil, fmt.Errorf("failed to create server: %w", err)
+	}
+	for _, action := range result.NextActions {
+		if err := p.waitForAction(ctx, action); err != nil {
+			return nil, fmt.Errorf("failed to create server: %w", err)
+		}
+	}
+
+	action, _, err := p.client.Server.AttachToNetwork(ctx, result.Server, hcloud.ServerAttachToNetworkOpts{
+		Network: network,
+		IP:      privateIP,
+	})
+	if err != nil {
+		return nil, wrapError(err, "failed to attach server to network")
+	}
+	if err := p.waitForAction(ctx, action); err != nil {
+		return nil, fmt.Errorf("failed to attach server to network: %w", err)
+	}
+
+	action, _, err = p.client.Server.Poweron(ctx, result.Server)
+	if err != nil {
+		return nil, wrapError(err, "failed to start server")
+	}
+	if err := p.waitForAction(ctx, action); err != nil {
+		return nil, fmt.Errorf("failed to start server: %w", err)
+	}
+
+	server := convertServer(result.Server)
+	server.PublicIPv4 = ip.IP.String()
+	server.State = machine.ServerStateStarting
+	return server, nil
+}
+
+// ensureSSHKey returns the uploaded SSH key with publicKey, uploading it
+// as the guard's n-th key if it is not
+func (p *Provider) ensureSSHKey(ctx context.Context, guardID string, n int, publicKey string) (*hcloud.SSHKey, error) {
+	publicKey = strings.TrimSpace(publicKey)
+	keys, err := p.client.SSHKey.All(ctx)
+	if err != nil {
+		return nil, wrapError(err, "failed to list SSH keys")
+	}
+	for _, key := range keys {
+		if strings.TrimSpace(key.PublicKey) == publicKey {
+			return key, nil
+		}
+	}
+
+	name := newResourceNames(guardID).SSHKey
+	if n > 0 {
+		name = fmt.Sprintf("%s-%d", name, n+1)
+	}
+	key, _, err := p.client.SSHKey.Create(ctx, hcloud.SSHKeyCreateOpts{
+		Name:      name,
+		PublicKey: publicKey,
+		Labels:    guardLabels(guardID),
+	})
+	if err != nil {
+		return nil, wrapError(err, "failed to upload SSH key")
+	}
+	return key, nil
+}
+
+// GetServer retrieves server information by ID.
+func (p *Provider) GetServer(ctx context.Context, serverID string) (*machine.Server, error) {
+	server, err := p.getServer(ctx, serverID)
+	if err != nil {
+		return nil, err
+	}
+	return convertServer(server), nil
+}
+
+// getServer returns a server by ID
+func (p *Provider) getServer(ctx context.Context, serverID string) (*hcloud.Server, error) {
+	id, err := strconv.ParseInt(serverID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server ID %q", serverID)
+	}
+	server, _, err := p.client.Server.GetByID(ctx, id)
+	if err != nil {
+		return nil, wrapError(err, "failed to get server")
+	}
+	if server == nil {
+		return nil, fmt.Errorf("server %s not found", serverID)
+	}
+	return server, nil
+}
+
+// DeleteServer deletes a server. Its primary IP is kept, so a new server
+// can take it over.
+func (p *Provider) DeleteServer(ctx context.Context, serverID string) error {
+	server, err := p.getServer(ctx, serverID)
+	if err != nil {
+		return err
+	}
+	result, _, err := p.client.Server.DeleteWithResult(ctx, server)
+	if err != nil {
+		return wrapError(err, "failed to delete server")
+	}
+	if err := p.waitForAction(ctx, result.Action); err != nil {
+		return fmt.Errorf("failed to delete server: %w", err)
+	}
+	return nil
+}
+
+// WaitForServer waits until the server is in the specified state.
+func (p *Provider) WaitForServer(ctx context.Context, serverID string, state machine.ServerState) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		server, err := p.GetServer(ctx, serverID)
+		if err != nil {
+			return err
+		}
+
+		if server.State == state {
+			return nil
+		}
+
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// ListServers lists the guard servers with optional filters on their
+// labels.
+func (p *Provider) ListServers(ctx context.Context, filters map[string]string) ([]*machine.Server, error) {
+	servers, err := p.guardServers(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*machine.Server
+	for _, s := range servers {
+		server := convertServer(s)
+		match := true
+		for k, v := range filters {
+			if server.Labels[k] != v {
+				match = false
+				break
+			}
+		}
+		if match {
+			result = append(result, server)
+		}
+	}
+	return result, nil
+}
+
+// guardServers lists the servers labeled managed-by=morpheus-hetznerguard,
+// of one guard or of all if guardID is empty
+func (p *Provider) guardServers(ctx context.Context, guardID string) ([]*hcloud.Server, error) {
+	servers, err := p.client.Server.AllWithOpts(ctx, hcloud.ServerListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: guardSelector(guardID)},
+	})
+	if err != nil {
+		return nil, wrapError(err, "failed to list servers")
+	}
+	return servers, nil
+}
+
+// convertServer converts a server; its labels are decoded
+func convertServer(server *hcloud.Server) *machine.Server {
+	result := &machine.Server{
+		ID:        strconv.FormatInt(server.ID, 10),
+		Name:      server.Name,
+		State:     convertServerState(server.Status),
+		Labels:    fromLabels(server.Labels),
+		CreatedAt: server.Created.Format(time.RFC3339),
+	}
+	if server.PublicNet.IPv4.IP != nil && !server.PublicNet.IPv4.IP.IsUnspecified() {
+		result.PublicIPv4 = server.PublicNet.IPv4.IP.String()
+	}
+	if server.Datacenter != nil && server.Datacenter.Location != nil {
+		result.Location = server.Datacenter.Location.Name
+	}
+	if server.ServerType != nil {
+		result.ServerType = server.ServerType.Name
+	}
+	if server.Image != nil {
+		result.Image = server.Image.Name
+	}
+	return result
+}
+
+func convertServerState(status hcloud.ServerStatus) machine.ServerState {
+	switch status {
+	case hcloud.ServerStatusStarting, hcloud.ServerStatusInitializing:
+		return machine.ServerStateStarting
+	case hcloud.ServerStatusRunning:
+		return machine.ServerStateRunning
+	case hcloud.ServerStatusStopping, hcloud.ServerStatusOff:
+		return machine.ServerStateStopped
+	case hcloud.ServerStatusDeleting:
+		return machine.ServerStateDeleting
+	default:
+		return machine.ServerStateUnknown
+	}
+}
+
+// GetGuard reconstructs guard info from the guard's Hetzner resources.
+func (p *Provider) GetGuard(ctx context.Context, guardID string) (*guard.Guard, error) {
+	network, err := p.guardNetwork(ctx, guardID)
+	if err != nil {
+		return nil, err
+	}
+	if network == nil {
+		return nil, fmt.Errorf("guard not found: no network labeled %s=%s", TagGuardID, guardID)
+	}
+	servers, err := p.guardServers(ctx, guardID)
+	if err != nil {
+		return nil, err
+	}
+	var server *hcloud.Server
+	if len(servers) > 0 {
+		server = servers[0]
+	}
+	g := guardFromNetwork(network, server)
+
+	if fw, err := p.guardFirewall(ctx, guardID); err == nil && fw != nil {
+		g.NSGID = strconv.FormatInt(fw.ID, 10)
+	}
+	if ip, err := p.guardPrimaryIP(ctx, guardID); err == nil && ip != nil {
+		g.PublicIPID = strconv.FormatInt(ip.ID, 10)
+		g.PublicIP = ip.IP.String()
+	}
+
+	if server != nil {
+		for _, pn := range server.PrivateNet {
+			if pn.Network == nil || pn.Network.ID == network.ID {
+				continue
+			}
+			remote, _, err := p.client.Network.GetByID(ctx, pn.Network.ID)
+			if err != nil {
+				return nil, wrapError(err, "failed to get network")
+			}
+			if remote == nil {
+				continue
+			}
+			g.Peerings = append(g.Peerings, guard.PeeringInfo{
+				Name:         remote.Name,
+				RemoteVNetID: strconv.FormatInt(remote.ID, 10),
+				RouteTableID: strconv.FormatInt(remote.ID, 10),
+			})
+		}
+	}
+	return g, nil
+}
+
+// ListGuards discovers all guards from the networks labeled
+// managed-by=morpheus-hetznerguard.
+func (p *Provider) ListGuards(ctx context.Context) ([]*guard.Guard, error) {
+	networks, err := p.client.Network.AllWithOpts(ctx, hcloud.NetworkListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: guardSelector("")},
+	})
+	if err != nil {
+		return nil, wrapError(err, "failed to list networks")
+	}
+	servers, err := p.guardServers(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	ips, err := p.client.PrimaryIP.AllWithOpts(ctx, hcloud.PrimaryIPListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: guardSelector("")},
+	})
+	if err != nil {
+		return nil, wrapError(err, "failed to list primary IPs")
+	}
+
+	var guards []*guard.Guard
+	for _, network := range networks {
+		guardID := network.Labels[TagGuardID]
+		if guardID == "" {
+			continue
+		}
+		var server *hcloud.Server
+		for _, s := range servers {
+			if s.Labels[TagGuardID] == guardID {
+				server = s
+			}
+		}
+		g := guardFromNetwork(network, server)
+		for _, ip := range ips {
+			if ip.Labels[TagGuardID] == guardID {
+				g.PublicIPID = strconv.FormatInt(ip.ID, 10)
+				g.PublicIP = ip.IP.String()
+			}
+		}
+		guards = append(guards, g)
+	}
+	return guards, nil
+}
+
+// guardFromNetwork builds a guard from its network and server (nil if the
+// server does not exist)
+func guardFromNetwork(network *hcloud.Network, server *hcloud.Server) *guard.Guard {
+	g := &guard.Guard{
+		ID:       network.Labels[TagGuardID],
+		Provider: "hetzner",
+		Status:   "unknown",
+		VNetID:   strconv.FormatInt(network.ID, 10),
+		Group:    network.Labels[TagGroup],
+	}
+	if len(network.Subnets) > 0 {
+		g.SubnetID = network.Subnets[0].IPRange.String()
+		g.ResourceGroup = string(network.Subnets[0].NetworkZone)
+	}
+	if ip, err := guardIP(network); err == nil {
+		g.PrivateIP = ip.String()
+	}
+	if port, err := strconv.Atoi(network.Labels[TagWGPort]); err == nil {
+		g.WireGuardPort = port
+	}
+	if server == nil {
+		return g
+	}
+
+	s := convertServer(server)
+	g.ServerID = s.ID
+	g.Location = s.Location
+	g.Status = string(server.Status)
+	g.PublicIP = s.PublicIPv4
+	g.CreatedAt = server.Created
+	for _, pn := range server.PrivateNet {
+		if pn.Network != nil && pn.Network.ID == network.ID {
+			g.PrivateIP = pn.IP.String()
+		}
+	}
+	g.PublicKey = s.Labels[TagWGPublicKey]
+	if group := s.Labels[TagGroup]; group != "" {
+		g.Group = group
+	}
+	if cidrs := s.Labels[TagMeshCIDRs]; cidrs != "" {
+		g.MeshCIDRs = strings.Split(cidrs, ",")
+	}
+	if port, err := strconv.Atoi(s.Labels[TagWGPort]); err == nil {
+		g.WireGuardPort = port
+	}
+	return g
+}
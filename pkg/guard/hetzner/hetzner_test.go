@@ -0,0 +1,184 @@
+package hetzner
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/nimsforest/morpheus/internal/hetznermock"
+	"github.com/nimsforest/morpheus/pkg/guard"
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+// labelValue matches the label values the Hetzner API accepts
+var labelValue = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9._-]{0,61}[a-zA-Z0-9])?)?$`)
+
+func TestProviderLifecycle(t *testing.T) {
+	mock := hetznermock.NewServer()
+	t.Cleanup(mock.Close)
+	mock.Token = "test-token"
+	prov, err := NewProviderWithEndpoint("test-token", mock.URL, "fsn1", "cx22", "ubuntu-24.04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// A workload network with a server to probe routes from
+	_, workloadRange, _ := net.ParseCIDR("10.0.0.0/16")
+	_, workloadSubnet, _ := net.ParseCIDR("10.0.1.0/24")
+	workload, _, err := prov.client.Network.Create(ctx, hcloud.NetworkCreateOpts{
+		Name:    "workload",
+		IPRange: workloadRange,
+		Subnets: []hcloud.NetworkSubnet{{Type: hcloud.NetworkSubnetTypeCloud, IPRange: workloadSubnet, NetworkZone: hcloud.NetworkZoneEUCentral}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	probe, _, err := prov.client.Server.Create(ctx, hcloud.ServerCreateOpts{
+		Name:       "probe",
+		ServerType: &hcloud.ServerType{Name: "cx22"},
+		Image:      &hcloud.Image{Name: "ubuntu-24.04"},
+		Networks:   []*hcloud.Network{workload},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := guard.NetworkRequest{GuardID: "guard-1", VNetCIDR: "10.100.0.0/16", SubnetCIDR: "10.100.1.0/24", WireGuardPort: 51820, Group: "guard-0"}
+	info, err := prov.EnsureNetwork(ctx, req)
+	if err != nil {
+		t.Fatalf("EnsureNetwork() error = %v", err)
+	}
+	if info.PublicIP == "" || info.PrivateIP != "10.100.1.2" || info.VNetID == "" || info.ResourceGroup != "eu-central" {
+		t.Errorf("EnsureNetwork() = %+v", info)
+	}
+	// Ensuring again keeps the existing resources
+	if _, err := prov.EnsureNetwork(ctx, req); err != nil {
+		t.Fatalf("second EnsureNetwork() error = %v", err)
+	}
+	if len(mock.Networks()) != 2 || len(mock.PrimaryIPs()) != 1 || len(mock.Firewalls()) != 1 || len(mock.Firewalls()[0].Rules) != 2 {
+		t.Errorf("second EnsureNetwork() duplicated resources: %d networks, %d primary IPs, firewalls %+v",
+			len(mock.Networks()), len(mock.PrimaryIPs()), mock.Firewalls())
+	}
+
+	publicKey := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0xfb}, 32))
+	server, err := prov.CreateServer(ctx, machine.CreateServerRequest{
+		Name:     "guard-1-vm",
+		UserData: "#cloud-config\n",
+		SSHKeys:  []string{"ssh-ed25519 AAAA test"},
+		Labels: map[string]string{
+			"managed-by": "morpheus-azureguard", "guard-id": "guard-1", "guard-group": "guard-0",
+			"mesh-cidrs": "10.200.0.0/16,100.64.0.0/10", "wg-port": "51820", "wg-public-key": publicKey,
+			"nic-id": "", "resource-group": info.ResourceGroup,
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateServer() error = %v", err)
+	}
+	if server.PublicIPv4 != info.PublicIP || server.Labels[TagManagedBy] != TagManagedByValue || server.Labels[TagWGPublicKey] != publicKey {
+		t.Errorf("CreateServer() = %+v", server)
+	}
+	for _, s := range mock.Servers() {
+		for k, v := range s.Labels {
+			if !labelValue.MatchString(v) {
+				t.Errorf("server %s has label %s=%q, which the API refuses", s.Name, k, v)
+			}
+		}
+	}
+
+	g, err := prov.GetGuard(ctx, "guard-1")
+	if err != nil {
+		t.Fatalf("GetGuard() error = %v", err)
+	}
+	if g.Status != "running" || g.PrivateIP != "10.100.1.2" || g.PublicIP != info.PublicIP || g.Group != "guard-0" ||
+		g.WireGuardPort != 51820 || g.PublicKey != publicKey || !slices.Equal(g.MeshCIDRs, []string{"10.200.0.0/16", "100.64.0.0/10"}) ||
+		g.Location != "fsn1" || g.NSGID != info.NSGID || g.CreatedAt.IsZero() {
+		t.Errorf("GetGuard() = %+v", g)
+	}
+
+	err = prov.PeerNetwork(ctx, guard.PeerRequest{
+		GuardID:        "guard-1",
+		GuardVNetID:    g.VNetID,
+		RemoteVNetID:   "workload",
+		GuardPrivateIP: g.PrivateIP,
+		MeshCIDRs:      g.MeshCIDRs,
+	})
+	if err != nil {
+		t.Fatalf("PeerNetwork() error = %v", err)
+	}
+	g, err = prov.GetGuard(ctx, "guard-1")
+	if err != nil || len(g.Peerings) != 1 || g.Peerings[0].Name != "workload" {
+		t.Fatalf("GetGuard() peerings = %+v, %v", g.Peerings, err)
+	}
+
+	tables, err := prov.ListRouteTables(ctx, "")
+	if err != nil || len(tables) != 1 || tables[0].GuardID != "guard-1" || tables[0].RemoteVNetID != g.Peerings[0].RemoteVNetID ||
+		!slices.Equal(tables[0].Routes, []string{"10.200.0.0/16", "100.64.0.0/10"}) {
+		t.Fatalf("ListRouteTables() = %+v, %v", tables, err)
+	}
+
+	if _, err := prov.EffectiveRoutes(ctx, "999"); err == nil {
+		t.Error("EffectiveRoutes() of a missing server succeeded")
+	}
+	routes, err := prov.EffectiveRoutes(ctx, strconv.FormatInt(probe.Server.ID, 10))
+	if err != nil {
+		t.Fatalf("EffectiveRoutes() error = %v", err)
+	}
+	if len(routes) != 4 || routes[1].Prefixes[0] != "10.200.0.0/16" || routes[1].NextHopType != guard.NextHopVirtualAppliance ||
+		!slices.Contains(routes[1].NextHops, g.PrivateIP) {
+		t.Errorf("EffectiveRoutes() = %+v, want the mesh routes via the guard", routes)
+	}
+
+	guards, err := prov.ListGuards(ctx)
+	if err != nil || len(guards) != 1 || guards[0].ID != "guard-1" || guards[0].PublicIP != g.PublicIP {
+		t.Fatalf("ListGuards() = %+v, %v", guards, err)
+	}
+
+	if err := prov.UnpeerNetwork(ctx, "guard-1", "workload"); err != nil {
+		t.Fatalf("UnpeerNetwork() error = %v", err)
+	}
+	if tables, err := prov.ListRouteTables(ctx, "guard-1"); err != nil || len(tables) != 0 {
+		t.Errorf("ListRouteTables() after UnpeerNetwork() = %+v, %v", tables, err)
+	}
+
+	if err := prov.CleanupNetwork(ctx, "guard-1"); err != nil {
+		t.Fatalf("CleanupNetwork() error = %v", err)
+	}
+	if len(mock.Networks()) != 1 || len(mock.Firewalls()) != 0 || len(mock.PrimaryIPs()) != 0 || len(mock.Servers()) != 1 {
+		t.Errorf("CleanupNetwork() left resources: %d networks, %d firewalls, %d primary IPs, %d servers",
+			len(mock.Networks()), len(mock.Firewalls()), len(mock.PrimaryIPs()), len(mock.Servers()))
+	}
+}
+
+func TestLabelEncoding(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0xff, 0x3e}, 16))
+	labels := map[string]string{
+		TagMeshCIDRs:     "10.200.0.0/16,100.64.0.0/10",
+		TagWGPublicKey:   key,
+		TagGuardID:       "guard-1",
+		"resource-group": "rg",
+		TagGroup:         "",
+	}
+	encoded := toLabels(labels)
+	if _, ok := encoded["resource-group"]; ok {
+		t.Error("toLabels() kept the Azure-only resource-group label")
+	}
+	if _, ok := encoded[TagGroup]; ok {
+		t.Error("toLabels() kept an empty label")
+	}
+	for k, v := range encoded {
+		if !labelValue.MatchString(v) {
+			t.Errorf("toLabels()[%s] = %q, not a valid label value", k, v)
+		}
+	}
+	decoded := fromLabels(encoded)
+	if decoded[TagMeshCIDRs] != labels[TagMeshCIDRs] || decoded[TagWGPublicKey] != key || decoded[TagGuardID] != "guard-1" {
+		t.Errorf("fromLabels(toLabels()) = %v", decoded)
+	}
+}
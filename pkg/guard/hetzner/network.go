@@ -0,0 +1,600 @@
+package hetzner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/nimsforest/morpheus/pkg/guard"
+)
+
+// guardNetwork returns the guard's network, or nil if it does not exist
+func (p *Provider) guardNetwork(ctx context.Context, guardID string) (*hcloud.Network, error) {
+	networks, err := p.client.Network.AllWithOpts(ctx, hcloud.NetworkListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: guardSelector(guardID)},
+	})
+	if err != nil {
+		return nil, wrapError(err, "failed to get network")
+	}
+	if len(networks) == 0 {
+		return nil, nil
+	}
+	return networks[0], nil
+}
+
+// guardFirewall returns the guard's firewall, or nil if it does not exist
+func (p *Provider) guardFirewall(ctx context.Context, guardID string) (*hcloud.Firewall, error) {
+	firewalls, err := p.client.Firewall.AllWithOpts(ctx, hcloud.FirewallListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: guardSelector(guardID)},
+	})
+	if err != nil {
+		return nil, wrapError(err, "failed to get firewall")
+	}
+	if len(firewalls) == 0 {
+		return nil, nil
+	}
+	return firewalls[0], nil
+}
+
+// guardPrimaryIP returns the guard's primary IP, or nil if it does not
+// exist
+func (p *Provider) guardPrimaryIP(ctx context.Context, guardID string) (*hcloud.PrimaryIP, error) {
+	ips, err := p.client.PrimaryIP.AllWithOpts(ctx, hcloud.PrimaryIPListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: guardSelector(guardID)},
+	})
+	if err != nil {
+		return nil, wrapError(err, "failed to get primary IP")
+	}
+	if len(ips) == 0 {
+		return nil, nil
+	}
+	return ips[0], nil
+}
+
+// guardIP returns the address of the guard server in its network's subnet
+func guardIP(network *hcloud.Network) (net.IP, error) {
+	if len(network.Subnets) == 0 || network.Subnets[0].IPRange == nil {
+		return nil, fmt.Errorf("network %s has no subnet", network.Name)
+	}
+	return hostIP(network.Subnets[0].IPRange, guardHost), nil
+}
+
+// serverIP returns the address of a server in a network, or nil if it is
+// not attached to it
+func serverIP(server *hcloud.Server, networkID int64) net.IP {
+	for _, pn := range server.PrivateNet {
+		if pn.Network != nil && pn.Network.ID == networkID {
+			return pn.IP
+		}
+	}
+	return nil
+}
+
+// EnsureNetwork creates the full networking stack for a guard: a network
+// with one cloud subnet in the network zone of the location, a firewall
+// for SSH and WireGuard, and a primary IPv4 address in the location.
+// Resources that already exist (found by their guard-id label) are kept.
+// The guard's private address is fixed: the second host of the subnet.
+func (p *Provider) EnsureNetwork(ctx context.Context, req guard.NetworkRequest) (*guard.NetworkInfo, error) {
+	names := newResourceNames(req.GuardID)
+	locationName := req.Location
+	if locationName == "" {
+		locationName = p.location
+	}
+	location, _, err := p.client.Location.GetByName(ctx, locationName)
+	if err != nil {
+		return nil, wrapError(err, "failed to get location")
+	}
+	if location == nil {
+		return nil, fmt.Errorf("location not found: %s", locationName)
+	}
+	_, ipRange, err := net.ParseCIDR(req.VNetCIDR)
+	if err != nil {
+		return nil, fmt.Errorf("invalid network CIDR %q: %w", req.VNetCIDR, err)
+	}
+	_, subnetRange, err := net.ParseCIDR(req.SubnetCIDR)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subnet CIDR %q: %w", req.SubnetCIDR, err)
+	}
+
+	// 1. Network
+	network, err := p.guardNetwork(ctx, req.GuardID)
+	if err != nil {
+		return nil, err
+	}
+	if network == nil {
+		fmt.Printf("      Creating network %s (%s)...\n", names.Network, req.VNetCIDR)
+		labels := guardLabels(req.GuardID)
+		labels[TagWGPort] = strconv.Itoa(req.WireGuardPort)
+		if req.Group != "" {
+			labels[TagGroup] = req.Group
+		}
+		network, _, err = p.client.Network.Create(ctx, hcloud.NetworkCreateOpts{
+			Name:    names.Network,
+			IPRange: ipRange,
+			Subnets: []hcloud.NetworkSubnet{{
+				Type:        hcloud.NetworkSubnetTypeCloud,
+				IPRange:     subnetRange,
+				NetworkZone: location.NetworkZone,
+			}},
+			Labels: labels,
+		})
+		if err != nil {
+			return nil, wrapError(err, "failed to create network")
+		}
+	}
+	privateIP, err := guardIP(network)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Firewall. It filters the public interface only; traffic in
+	// private networks is not filtered.
+	rules := []hcloud.FirewallRule{
+		inboundRule("SSH", hcloud.FirewallRuleProtocolTCP, "22"),
+		inboundRule("WireGuard", hcloud.FirewallRuleProtocolUDP, strconv.Itoa(req.WireGuardPort)),
+	}
+	if len(req.SSHSources) > 0 && !slices.Contains(req.SSHSources, guard.AnySource) {
+		rules[0].SourceIPs = nil
+		for _, source := range req.SSHSources {
+			_, ipNet, err := net.ParseCIDR(source)
+			if err != nil {
+				return nil, fmt.Errorf("invalid SSH source %q: %w", source, err)
+			}
+			rules[0].SourceIPs = append(rules[0].SourceIPs, *ipNet)
+		}
+	}
+	fw, err := p.guardFirewall(ctx, req.GuardID)
+	if err != nil {
+		return nil, err
+	}
+	if fw == nil {
+		fmt.Printf("      Creating firewall %s...\n", names.Firewall)
+		result, _, err := p.client.Firewall.Create(ctx, hcloud.FirewallCreateOpts{
+			Name:   names.Firewall,
+			Labels: guardLabels(req.GuardID),
+			Rules:  rules,
+		})
+		if err != nil {
+			return nil, wrapError(err, "failed to create firewall")
+		}
+		fw = result.Firewall
+	} else if err := p.setRules(ctx, fw, rules); err != nil {
+		return nil, err
+	}
+
+	// 3. Primary IP, so the guard keeps its address if the server is
+	// replaced
+	ip, err := p.guardPrimaryIP(ctx, req.GuardID)
+	if err != nil {
+		return nil, err
+	}
+	if ip == nil {
+		datacenter, err := p.datacenterIn(ctx, location.Name)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Printf("      Creating primary IP %s...\n", names.Address)
+		result, _, err := p.client.PrimaryIP.Create(ctx, hcloud.PrimaryIPCreateOpts{
+			Name:         names.Address,
+			Type:         hcloud.PrimaryIPTypeIPv4,
+			AssigneeType: "server",
+			AutoDelete:   hcloud.Ptr(false),
+			Datacenter:   datacenter.Name,
+			Labels:       guardLabels(req.GuardID),
+		})
+		if err != nil {
+			return nil, wrapError(err, "failed to create primary IP")
+		}
+		ip = result.PrimaryIP
+	}
+
+	return &guard.NetworkInfo{
+		ResourceGroup: string(location.NetworkZone),
+		VNetID:        strconv.FormatInt(network.ID, 10),
+		SubnetID:      network.Subnets[0].IPRange.String(),
+		NSGID:         strconv.FormatInt(fw.ID, 10),
+		PublicIPID:    strconv.FormatInt(ip.ID, 10),
+		PublicIP:      ip.IP.String(),
+		PrivateIP:     privateIP.String(),
+	}, nil
+}
+
+// datacenterIn returns the first datacenter of a location
+func (p *Provider) datacenterIn(ctx context.Context, location string) (*hcloud.Datacenter, error) {
+	datacenters, err := p.client.Datacenter.All(ctx)
+	if err != nil {
+		return nil, wrapError(err, "failed to list datacenters")
+	}
+	for _, dc := range datacenters {
+		if dc.Location != nil && dc.Location.Name == location {
+			return dc, nil
+		}
+	}
+	return nil, fmt.Errorf("no datacenter in location %s", location)
+}
+
+// anywhere is the source of inbound rules open to all addresses
+var anywhere = []net.IPNet{
+	{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)},
+	{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)},
+}
+
+// inboundRule returns a firewall rule allowing a port from anywhere,
+// named by its description
+func inboundRule(description string, protocol hcloud.FirewallRuleProtocol, port string) hcloud.FirewallRule {
+	return hcloud.FirewallRule{
+		Direction:   hcloud.FirewallRuleDirectionIn,
+		SourceIPs:   anywhere,
+		Protocol:    protocol,
+		Port:        hcloud.Ptr(port),
+		Description: hcloud.Ptr(description),
+	}
+}
+
+// setRules adds rules to a firewall, replacing the rules with the same
+// descriptions and keeping the others
+func (p *Provider) setRules(ctx context.Context, fw *hcloud.Firewall, rules []hcloud.FirewallRule) error {
+	replaced := make(map[string]bool)
+	for _, rule := range rules {
+		replaced[description(rule)] = true
+	}
+	var kept []hcloud.FirewallRule
+	for _, rule := range fw.Rules {
+		if !replaced[description(rule)] {
+			kept = append(kept, rule)
+		}
+	}
+	actions, _, err := p.client.Firewall.SetRules(ctx, fw, hcloud.FirewallSetRulesOpts{Rules: append(kept, rules...)})
+	if err != nil {
+		return wrapError(err, "failed to set firewall rules")
+	}
+	for _, action := range actions {
+		if err := p.waitForAction(ctx, action); err != nil {
+			return fmt.Errorf("failed to set firewall rules: %w", err)
+		}
+	}
+	return nil
+}
+
+// description returns the description of a firewall rule, or ""
+func description(rule hcloud.FirewallRule) string {
+	if rule.Description == nil {
+		return ""
+	}
+	return *rule.Description
+}
+
+// CleanupNetwork removes all Hetzner resources of a guard: its routes in
+// workload networks, the server, the firewall, the primary IP, the network
+// and the SSH keys uploaded for it.
+func (p *Provider) CleanupNetwork(ctx context.Context, guardID string) error {
+	names := newResourceNames(guardID)
+
+	// 1. Routes through the guard, and the server
+	servers, err := p.guardServers(ctx, guardID)
+	if err != nil {
+		return err
+	}
+	own, err := p.guardNetwork(ctx, guardID)
+	if err != nil {
+		return err
+	}
+	for _, server := range servers {
+		for _, pn := range server.PrivateNet {
+			if pn.Network == nil || (own != nil && pn.Network.ID == own.ID) {
+				continue
+			}
+			network, _, err := p.client.Network.GetByID(ctx, pn.Network.ID)
+			if err != nil {
+				return wrapError(err, "failed to get network")
+			}
+			if network != nil {
+				if err := p.detach(ctx, server, network); err != nil {
+					return err
+				}
+			}
+		}
+		fmt.Printf("   Deleting server %s...\n", server.Name)
+		if err := p.DeleteServer(ctx, strconv.FormatInt(server.ID, 10)); err != nil {
+			return err
+		}
+	}
+
+	// 2. Firewall
+	fw, err := p.guardFirewall(ctx, guardID)
+	if err != nil {
+		return err
+	}
+	if fw != nil {
+		if len(fw.AppliedTo) > 0 {
+			actions, _, err := p.client.Firewall.RemoveResources(ctx, fw, fw.AppliedTo)
+			if err != nil {
+				return wrapError(err, "failed to detach firewall")
+			}
+			for _, action := range actions {
+				if err := p.waitForAction(ctx, action); err != nil {
+					return fmt.Errorf("failed to detach firewall: %w", err)
+				}
+			}
+		}
+		fmt.Printf("   Deleting firewall %s...\n", names.Firewall)
+		if _, err := p.client.Firewall.Delete(ctx, fw); err != nil {
+			return wrapError(err, "failed to delete firewall")
+		}
+	}
+
+	// 3. Primary IP
+	ip, err := p.guardPrimaryIP(ctx, guardID)
+	if err != nil {
+		return err
+	}
+	if ip != nil {
+		if ip.AssigneeID != 0 {
+			action, _, err := p.client.PrimaryIP.Unassign(ctx, ip.ID)
+			if err != nil {
+				return wrapError(err, "failed to unassign primary IP")
+			}
+			if err := p.waitForAction(ctx, action); err != nil {
+				return fmt.Errorf("failed to unassign primary IP: %w", err)
+			}
+		}
+		fmt.Printf("   Releasing primary IP %s...\n", ip.IP)
+		if _, err := p.client.PrimaryIP.Delete(ctx, ip); err != nil {
+			return wrapError(err, "failed to delete primary IP")
+		}
+	}
+
+	// 4. Network
+	if own != nil {
+		fmt.Printf("   Deleting network %s...\n", own.Name)
+		if _, err := p.client.Network.Delete(ctx, own); err != nil {
+			return wrapError(err, "failed to delete network")
+		}
+	}
+
+	// 5. SSH keys
+	keys, err := p.client.SSHKey.AllWithOpts(ctx, hcloud.SSHKeyListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: guardSelector(guardID)},
+	})
+	if err != nil {
+		return wrapError(err, "failed to list SSH keys")
+	}
+	for _, key := range keys {
+		if _, err := p.client.SSHKey.Delete(ctx, key); err != nil {
+			return wrapError(err, "failed to delete SSH key")
+		}
+	}
+	return nil
+}
+
+// ConfigureNICForwarding is a no-op: Hetzner networks deliver routed
+// traffic to any attached server, and forwarding is enabled in the guard's
+// OS by cloud-init.
+func (p *Provider) ConfigureNICForwarding(ctx context.Context, nicID string) error {
+	return nil
+}
+
+// EnsureNSGRule creates or updates a rule of the guard's firewall, named
+// by its description. A protocol of "*" allows both TCP and UDP. Note that
+// once a firewall has an outbound rule, Hetzner drops all other outbound
+// traffic.
+func (p *Provider) EnsureNSGRule(ctx context.Context, req guard.NSGRuleRequest) error {
+	fw, err := p.guardFirewall(ctx, req.GuardID)
+	if err != nil {
+		return err
+	}
+	if fw == nil {
+		return fmt.Errorf("firewall %s not found", newResourceNames(req.GuardID).Firewall)
+	}
+
+	port := req.DestPort
+	if port == "" || port == "*" {
+		port = "1-65535"
+	}
+	var protocols []hcloud.FirewallRuleProtocol
+	switch strings.ToLower(req.Protocol) {
+	case "tcp":
+		protocols = []hcloud.FirewallRuleProtocol{hcloud.FirewallRuleProtocolTCP}
+	case "udp":
+		protocols = []hcloud.FirewallRuleProtocol{hcloud.FirewallRuleProtocolUDP}
+	case "*", "":
+		protocols = []hcloud.FirewallRuleProtocol{hcloud.FirewallRuleProtocolTCP, hcloud.FirewallRuleProtocolUDP}
+	default:
+		return fmt.Errorf("unsupported protocol %q (use Tcp, Udp or *)", req.Protocol)
+	}
+
+	var rules []hcloud.FirewallRule
+	for _, protocol := range protocols {
+		description := req.RuleName
+		if len(protocols) > 1 {
+			description = fmt.Sprintf("%s-%s", req.RuleName, protocol)
+		}
+		rule := inboundRule(description, protocol, port)
+		if strings.EqualFold(req.Direction, "Outbound") {
+			rule.Direction = hcloud.FirewallRuleDirectionOut
+			rule.SourceIPs = nil
+			rule.DestinationIPs = anywhere
+		}
+		rules = append(rules, rule)
+	}
+	return p.setRules(ctx, fw, rules)
+}
+
+// PeerNetwork attaches the guard server to a workload network and routes
+// the mesh CIDRs in it to the guard's address there. Hetzner has no
+// network peering, so a server can serve at most two workload networks
+// besides its own. RemoteVNetID is the network's ID or name; routes apply
+// to the whole network, so SubnetID is not used. Hetzner images configure
+// the new private interface without a reboot.
+func (p *Provider) PeerNetwork(ctx context.Context, req guard.PeerRequest) error {
+	servers, err := p.guardServers(ctx, req.GuardID)
+	if err != nil {
+		return err
+	}
+	if len(servers) == 0 {
+		return fmt.Errorf("guard %s has no server", req.GuardID)
+	}
+	server := servers[0]
+	own, err := p.guardNetwork(ctx, req.GuardID)
+	if err != nil {
+		return err
+	}
+	if own == nil {
+		return fmt.Errorf("network %s not found", newResourceNames(req.GuardID).Network)
+	}
+
+	remote, _, err := p.client.Network.Get(ctx, req.RemoteVNetID)
+	if err != nil {
+		return wrapError(err, "failed to get network")
+	}
+	if remote == nil {
+		return fmt.Errorf("network %s not found", req.RemoteVNetID)
+	}
+	if remote.ID == own.ID {
+		return fmt.Errorf("network %s is the guard's own network", remote.Name)
+	}
+	if overlaps(remote.IPRange, own.IPRange) {
+		return fmt.Errorf("network %s (%s) overlaps the guard network (%s)", remote.Name, remote.IPRange, own.IPRange)
+	}
+
+	gateway := serverIP(server, remote.ID)
+	if gateway == nil {
+		if len(server.PrivateNet) >= 3 {
+			return fmt.Errorf("guard server %s is attached to 3 networks already, the most Hetzner allows", server.Name)
+		}
+		fmt.Printf("   Attaching %s to network %s...\n", server.Name, remote.Name)
+		action, _, err := p.client.Server.AttachToNetwork(ctx, server, hcloud.ServerAttachToNetworkOpts{Network: remote})
+		if err != nil {
+			return wrapError(err, "failed to attach server to network")
+		}
+		if err := p.waitForAction(ctx, action); err != nil {
+			return fmt.Errorf("failed to attach server to network: %w", err)
+		}
+		if server, _, err = p.client.Server.GetByID(ctx, server.ID); err != nil {
+			return wrapError(err, "failed to get server")
+		}
+		if gateway = serverIP(server, remote.ID); gateway == nil {
+			return fmt.Errorf("server %s has no address in network %s", server.Name, remote.Name)
+		}
+	}
+
+	if len(req.MeshCIDRs) > 0 {
+		fmt.Printf("   Routing mesh CIDRs in %s via %s...\n", remote.Name, gateway)
+	}
+	for _, cidr := range req.MeshCIDRs {
+		if err := p.ensureRoute(ctx, remote, cidr, gateway); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureRoute routes dest to gateway in a network, replacing a route to
+// dest through another gateway
+func (p *Provider) ensureRoute(ctx context.Context, network *hcloud.Network, dest string, gateway net.IP) error {
+	_, destination, err := net.ParseCIDR(dest)
+	if err != nil {
+		return fmt.Errorf("invalid mesh CIDR %q: %w", dest, err)
+	}
+	for _, r := range network.Routes {
+		if r.Destination.String() != destination.String() {
+			continue
+		}
+		if r.Gateway.Equal(gateway) {
+			return nil
+		}
+		if err := p.deleteRoute(ctx, network, r); err != nil {
+			return err
+		}
+	}
+	action, _, err := p.client.Network.AddRoute(ctx, network, hcloud.NetworkAddRouteOpts{
+		Route: hcloud.NetworkRoute{Destination: destination, Gateway: gateway},
+	})
+	if err != nil {
+		return wrapError(err, fmt.Sprintf("failed to add route %s", destination))
+	}
+	if err := p.waitForAction(ctx, action); err != nil {
+		return fmt.Errorf("failed to add route %s: %w", destination, err)
+	}
+	return nil
+}
+
+// deleteRoute deletes a route of a network
+func (p *Provider) deleteRoute(ctx context.Context, network *hcloud.Network, r hcloud.NetworkRoute) error {
+	action, _, err := p.client.Network.DeleteRoute(ctx, network, hcloud.NetworkDeleteRouteOpts{Route: r})
+	if err != nil {
+		return wrapError(err, fmt.Sprintf("failed to delete route %s", r.Destination))
+	}
+	if err := p.waitForAction(ctx, action); err != nil {
+		return fmt.Errorf("failed to delete route %s: %w", r.Destination, err)
+	}
+	return nil
+}
+
+// deleteGuardRoutes deletes the routes of a network through gateway
+func (p *Provider) deleteGuardRoutes(ctx context.Context, network *hcloud.Network, gateway net.IP) error {
+	for _, r := range network.Routes {
+		if r.Gateway.Equal(gateway) {
+			fmt.Printf("   Deleting route %s from %s...\n", r.Destination, network.Name)
+			if err := p.deleteRoute(ctx, network, r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// detach deletes the routes through a server in a network and detaches
+// the server from it
+func (p *Provider) detach(ctx context.Context, server *hcloud.Server, network *hcloud.Network) error {
+	if gateway := serverIP(server, network.ID); gateway != nil {
+		if err := p.deleteGuardRoutes(ctx, network, gateway); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("   Detaching %s from network %s...\n", server.Name, network.Name)
+	action, _, err := p.client.Server.DetachFromNetwork(ctx, server, hcloud.ServerDetachFromNetworkOpts{Network: network})
+	if err != nil {
+		return wrapError(err, "failed to detach server from network")
+	}
+	if err := p.waitForAction(ctx, action); err != nil {
+		return fmt.Errorf("failed to detach server from network: %w", err)
+	}
+	return nil
+}
+
+// UnpeerNetwork removes the mesh routes through the guard from a workload
+// network, named by its name or ID, and detaches the guard server from it.
+func (p *Provider) UnpeerNetwork(ctx context.Context, guardID, peeringName string) error {
+	servers, err := p.guardServers(ctx, guardID)
+	if err != nil {
+		return err
+	}
+	if len(servers) == 0 {
+		return fmt.Errorf("guard %s has no server", guardID)
+	}
+	server := servers[0]
+
+	for _, pn := range server.PrivateNet {
+		if pn.Network == nil {
+			continue
+		}
+		network, _, err := p.client.Network.GetByID(ctx, pn.Network.ID)
+		if err != nil {
+			return wrapError(err, "failed to get network")
+		}
+		if network == nil || network.Labels[TagManagedBy] == TagManagedByValue {
+			continue
+		}
+		if network.Name == peeringName || strconv.FormatInt(network.ID, 10) == peeringName {
+			fmt.Printf("   Removing peering with %s...\n", network.Name)
+			return p.detach(ctx, server, network)
+		}
+	}
+	return fmt.Errorf("guard %s is not attached to network %s", guardID, peeringName)
+}
@@ -0,0 +1,182 @@
+package hetzner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/nimsforest/morpheus/pkg/guard"
+)
+
+// ListRouteTables returns the workload networks PeerNetwork added routes
+// to, for a guard or for all guards if guardID is empty. Hetzner has no
+// route tables; a network's routes apply to all of it, so each network is
+// reported as one table with the routes through the guard.
+func (p *Provider) ListRouteTables(ctx context.Context, guardID string) ([]guard.RouteTable, error) {
+	servers, err := p.guardServers(ctx, guardID)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []guard.RouteTable
+	for _, server := range servers {
+		for _, pn := range server.PrivateNet {
+			if pn.Network == nil {
+				continue
+			}
+			network, _, err := p.client.Network.GetByID(ctx, pn.Network.ID)
+			if err != nil {
+				return nil, wrapError(err, "failed to get network")
+			}
+			if network == nil || network.Labels[TagManagedBy] == TagManagedByValue {
+				continue
+			}
+			t := guard.RouteTable{
+				ID:           strconv.FormatInt(network.ID, 10),
+				Name:         network.Name,
+				GuardID:      server.Labels[TagGuardID],
+				RemoteVNetID: strconv.FormatInt(network.ID, 10),
+			}
+			for _, r := range network.Routes {
+				if r.Gateway.Equal(pn.IP) {
+					t.Routes = append(t.Routes, r.Destination.String())
+				}
+			}
+			if len(t.Routes) == 0 {
+				continue
+			}
+			for _, s := range network.Subnets {
+				t.ResourceGroup = string(s.NetworkZone)
+				t.Subnets = append(t.Subnets, s.IPRange.String())
+			}
+			tables = append(tables, t)
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].GuardID != tables[j].GuardID {
+			return tables[i].GuardID < tables[j].GuardID
+		}
+		return tables[i].ID < tables[j].ID
+	})
+	return tables, nil
+}
+
+// DeleteRouteTable removes the routes through guard servers from a
+// network returned by ListRouteTables. The network itself belongs to the
+// workload and is kept, and so are the guards' attachments to it.
+func (p *Provider) DeleteRouteTable(ctx context.Context, routeTableID string) error {
+	id, err := strconv.ParseInt(routeTableID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid network ID %q", routeTableID)
+	}
+	network, _, err := p.client.Network.GetByID(ctx, id)
+	if err != nil {
+		return wrapError(err, "failed to get network")
+	}
+	if network == nil {
+		return fmt.Errorf("network %s not found", routeTableID)
+	}
+	servers, err := p.guardServers(ctx, "")
+	if err != nil {
+		return err
+	}
+	for _, server := range servers {
+		if gateway := serverIP(server, network.ID); gateway != nil {
+			if err := p.deleteGuardRoutes(ctx, network, gateway); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EffectiveRoutes returns the routes of the networks a server is attached
+// to, and its default route if it has a public IPv4 address. Routes
+// through a guard name the guard's address in its own network as a next
+// hop as well, as that is the address guards are known by.
+func (p *Provider) EffectiveRoutes(ctx context.Context, vmID string) ([]guard.Route, error) {
+	server, err := p.getServer(ctx, vmID)
+	if err != nil {
+		return nil, err
+	}
+	guardIPs, err := p.guardAddresses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []guard.Route
+	for _, pn := range server.PrivateNet {
+		if pn.Network == nil {
+			continue
+		}
+		network, _, err := p.client.Network.GetByID(ctx, pn.Network.ID)
+		if err != nil {
+			return nil, wrapError(err, "failed to get network")
+		}
+		if network == nil {
+			continue
+		}
+		routes = append(routes, guard.Route{
+			Prefixes:    []string{network.IPRange.String()},
+			NextHopType: "VnetLocal",
+			Active:      true,
+		})
+		for _, r := range network.Routes {
+			hops := []string{r.Gateway.String()}
+			if own, ok := guardIPs[r.Gateway.String()]; ok && own != hops[0] {
+				hops = append(hops, own)
+			}
+			routes = append(routes, guard.Route{
+				Prefixes:    []string{r.Destination.String()},
+				NextHopType: guard.NextHopVirtualAppliance,
+				NextHops:    hops,
+				Active:      true,
+			})
+		}
+	}
+	if server.PublicNet.IPv4.IP != nil && !server.PublicNet.IPv4.IP.IsUnspecified() {
+		routes = append(routes, guard.Route{Prefixes: []string{"0.0.0.0/0"}, NextHopType: "Internet", Active: true})
+	}
+	return routes, nil
+}
+
+// guardAddresses maps the addresses of guard servers in all networks to
+// their address in their own guard network
+func (p *Provider) guardAddresses(ctx context.Context) (map[string]string, error) {
+	servers, err := p.guardServers(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	networks, err := p.client.Network.AllWithOpts(ctx, hcloud.NetworkListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: guardSelector("")},
+	})
+	if err != nil {
+		return nil, wrapError(err, "failed to list networks")
+	}
+
+	addresses := make(map[string]string)
+	for _, server := range servers {
+		var own net.IP
+		for _, network := range networks {
+			if network.Labels[TagGuardID] == server.Labels[TagGuardID] {
+				own = serverIP(server, network.ID)
+			}
+		}
+		if own == nil {
+			continue
+		}
+		for _, pn := range server.PrivateNet {
+			addresses[pn.IP.String()] = own.String()
+		}
+	}
+	return addresses, nil
+}
+
+// RunCommand is not available: Hetzner Cloud cannot run commands on
+// servers. Use SSH to the server instead.
+func (p *Provider) RunCommand(ctx context.Context, vmID, script string) (string, error) {
+	return "", fmt.Errorf("running commands on servers is not supported on Hetzner; use SSH")
+}
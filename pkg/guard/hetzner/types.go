@@ -0,0 +1,162 @@
+package hetzner
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	// TagManagedBy identifies resources managed by the Hetzner guard provider
+	TagManagedBy = "managed-by"
+	// TagManagedByValue is the label value for guard-managed resources
+	TagManagedByValue = "morpheus-hetznerguard"
+	// TagGuardID identifies the guard a resource belongs to
+	TagGuardID = "guard-id"
+	// TagGroup identifies the group of guards created together
+	TagGroup = "guard-group"
+	// TagMeshCIDRs stores the mesh CIDRs, encoded by encodeCIDRs
+	TagMeshCIDRs = "mesh-cidrs"
+	// TagWGPort stores the WireGuard port
+	TagWGPort = "wg-port"
+	// TagWGPublicKey stores the guard's WireGuard public key, encoded by
+	// encodeKey
+	TagWGPublicKey = "wg-public-key"
+)
+
+// providerLabels are labels the guard provisioner sets for Azure only
+var providerLabels = map[string]bool{
+	"nic-id":         true,
+	"resource-group": true,
+}
+
+// guardHost is the host number of the guard's address in its subnet. The
+// address is fixed so it is known before the server exists.
+const guardHost = 2
+
+// resourceNames generates consistent Hetzner Cloud resource names from a
+// guard ID. A guard's resources are found by their guard-id label.
+type resourceNames struct {
+	GuardID  string
+	Network  string
+	Firewall string
+	Address  string // Primary IPv4 address
+	Server   string
+	SSHKey   string
+}
+
+func newResourceNames(guardID string) resourceNames {
+	return resourceNames{
+		GuardID:  guardID,
+		Network:  fmt.Sprintf("%s-net", guardID),
+		Firewall: fmt.Sprintf("%s-fw", guardID),
+		Address:  fmt.Sprintf("%s-ip", guardID),
+		Server:   fmt.Sprintf("%s-vm", guardID),
+		SSHKey:   fmt.Sprintf("%s-key", guardID),
+	}
+}
+
+// guardLabels returns the labels of a guard's resources
+func guardLabels(guardID string) map[string]string {
+	return map[string]string{TagManagedBy: TagManagedByValue, TagGuardID: guardID}
+}
+
+// guardSelector returns the label selector of a guard's resources, or of
+// all guards' if guardID is empty
+func guardSelector(guardID string) string {
+	selector := TagManagedBy + "=" + TagManagedByValue
+	if guardID != "" {
+		selector += "," + TagGuardID + "=" + guardID
+	}
+	return selector
+}
+
+// Label values are limited to 63 letters, digits, '-', '_' and '.', so
+// CIDRs and WireGuard keys are encoded.
+var (
+	cidrEncoder = strings.NewReplacer("/", "-", ",", "_")
+	cidrDecoder = strings.NewReplacer("-", "/", "_", ",")
+	keyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// encodeCIDRs encodes comma-separated IPv4 CIDRs as a label value, e.g.
+// 10.0.0.0/8,100.64.0.0/10 as 10.0.0.0-8_100.64.0.0-10
+func encodeCIDRs(cidrs string) string {
+	return cidrEncoder.Replace(cidrs)
+}
+
+// decodeCIDRs reverses encodeCIDRs
+func decodeCIDRs(value string) string {
+	return cidrDecoder.Replace(value)
+}
+
+// encodeKey encodes a base64 WireGuard key in base32, which fits a label
+func encodeKey(key string) string {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return key
+	}
+	return keyEncoding.EncodeToString(raw)
+}
+
+// decodeKey reverses encodeKey
+func decodeKey(value string) string {
+	raw, err := keyEncoding.DecodeString(value)
+	if err != nil {
+		return value
+	}
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+// toLabels converts guard labels into Hetzner labels, leaving out empty
+// and Azure-only ones
+func toLabels(labels map[string]string) map[string]string {
+	result := make(map[string]string, len(labels))
+	for k, v := range labels {
+		if v == "" || providerLabels[k] {
+			continue
+		}
+		switch k {
+		case TagMeshCIDRs:
+			v = encodeCIDRs(v)
+		case TagWGPublicKey:
+			v = encodeKey(v)
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// fromLabels reverses toLabels
+func fromLabels(labels map[string]string) map[string]string {
+	result := make(map[string]string, len(labels))
+	for k, v := range labels {
+		switch k {
+		case TagMeshCIDRs:
+			v = decodeCIDRs(v)
+		case TagWGPublicKey:
+			v = decodeKey(v)
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// hostIP returns the n-th address of an IPv4 range
+func hostIP(ipRange *net.IPNet, n int) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	copy(ip, ipRange.IP.To4())
+	for i := len(ip) - 1; i >= 0 && n > 0; i-- {
+		sum := int(ip[i]) + n
+		ip[i] = byte(sum)
+		n = sum >> 8
+	}
+	return ip
+}
+
+// overlaps reports whether two ranges share addresses
+func overlaps(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
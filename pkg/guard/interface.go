@@ -9,7 +9,8 @@ import (
 
 // GuardProvider extends machine.Provider with networking and discovery
 // operations needed for WireGuard gateway VMs.
-// All state is stored in Azure (resource groups, tags) — no local registry.
+// Guards are discovered from cloud resources and their tags; the guard
+// registry (storage.GuardRegistry) keeps a copy with what tags cannot hold.
 type GuardProvider interface {
 	machine.Provider
 
@@ -30,9 +31,26 @@ type GuardProvider interface {
 	// PeerNetwork creates bidirectional VNet peering and route tables.
 	PeerNetwork(ctx context.Context, req PeerRequest) error
 
-	// UnpeerNetwork removes VNet peering.
+	// UnpeerNetwork removes VNet peering and the route tables created
+	// for it.
 	UnpeerNetwork(ctx context.Context, guardID, peeringName string) error
 
+	// ListRouteTables returns the route tables created by PeerNetwork for
+	// a guard, or for all guards if guardID is empty. They are found by
+	// their tags, as they live in the remote VNet's resource group.
+	ListRouteTables(ctx context.Context, guardID string) ([]RouteTable, error)
+
+	// DeleteRouteTable disassociates a route table from its subnets and
+	// deletes it.
+	DeleteRouteTable(ctx context.Context, routeTableID string) error
+
+	// EffectiveRoutes returns the routes in effect on a VM's primary NIC,
+	// e.g. of a VM in a peered VNet.
+	EffectiveRoutes(ctx context.Context, vmID string) ([]Route, error)
+
+	// RunCommand runs a shell script on a VM and returns its output.
+	RunCommand(ctx context.Context, vmID, script string) (string, error)
+
 	// Discovery — state lives in Azure, not locally.
 
 	// GetGuard reconstructs guard info from Azure resources by guard ID.
@@ -44,7 +62,8 @@ type GuardProvider interface {
 }
 
 // Guard represents a provisioned WireGuard gateway VM.
-// Reconstructed from Azure resource tags and properties — not persisted locally.
+// Reconstructed from cloud resource tags and properties; see Record for the
+// copy kept in the guard registry.
 type Guard struct {
 	ID            string            `json:"id"`
 	Provider      string            `json:"provider"`
@@ -61,16 +80,45 @@ type Guard struct {
 	ResourceGroup string           `json:"resource_group,omitempty"`
 	MeshCIDRs     []string          `json:"mesh_cidrs,omitempty"`
 	WireGuardPort int               `json:"wireguard_port"`
+	PublicKey     string            `json:"public_key,omitempty"` // WireGuard public key, if recorded
+	Group         string            `json:"group,omitempty"`      // Set for guards created together in several locations
+	Role          string            `json:"role,omitempty"`       // primary or standby, for the guards of an HA pair
+	Tags          map[string]string `json:"tags,omitempty"`       // User tags, e.g. team or cost-center
 	Metadata      map[string]string `json:"metadata,omitempty"`
 	CreatedAt     time.Time         `json:"created_at"`
 	Peerings      []PeeringInfo     `json:"peerings,omitempty"`
 }
 
+// Metadata keys of guards
+const (
+	MetaPriority       = "priority"        // PrioritySpot for Spot VMs
+	MetaEvictionPolicy = "eviction-policy" // What happens to an evicted Spot VM: deallocate or delete
+	MetaMaxPrice       = "max-price"       // Highest price per hour of a Spot VM, -1 for the on-demand price
+)
+
+// PrioritySpot is the MetaPriority of guards on Spot VMs, which the cloud
+// may evict
+const PrioritySpot = "spot"
+
 // PeeringInfo tracks a VNet peering created by this guard.
 type PeeringInfo struct {
 	Name         string `json:"name"`
 	RemoteVNetID string `json:"remote_vnet_id"`
 	RouteTableID string `json:"route_table_id,omitempty"`
+	State        string `json:"state,omitempty"` // e.g. Connected, if the cloud reports it
+}
+
+// RouteTable is a route table sending a peered subnet's mesh traffic
+// through a guard.
+type RouteTable struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	ResourceGroup string   `json:"resource_group"`
+	GuardID       string   `json:"guard_id"`
+	RemoteVNetID  string   `json:"remote_vnet_id,omitempty"`
+	Routes        []string `json:"routes,omitempty"`    // Address prefixes
+	NextHops      []string `json:"next_hops,omitempty"` // Next hop addresses of the routes, if the cloud has them
+	Subnets       []string `json:"subnets,omitempty"`   // Associated subnet IDs
 }
 
 // NetworkRequest contains parameters for creating guard network infrastructure.
@@ -81,6 +129,9 @@ type NetworkRequest struct {
 	VNetCIDR      string
 	SubnetCIDR    string
 	WireGuardPort int
+	Group         string
+	SSHSources    []string // CIDRs SSH is allowed from (default: any address)
+	Tags          map[string]string
 }
 
 // NetworkInfo contains the created network resource IDs.
@@ -115,14 +166,25 @@ type PeerRequest struct {
 	PeeringName    string
 	GuardPrivateIP string
 	MeshCIDRs      []string
-	SubnetID       string // Remote subnet to attach route table
+	SubnetID       string            // Remote subnet to attach route table
+	Tags           map[string]string // User tags of the guard, for the route table
 }
 
 // CreateGuardRequest contains parameters for creating a guard VM.
 type CreateGuardRequest struct {
+	GuardID       string // Default: from naming.guard
+	Group         string // Group of guards this one belongs to, if any
+	Role          string // primary or standby, for the guards of an HA pair
 	Location      string
+	Zone          string // Availability zone, for the guards of an HA pair
+	ResourceGroup string // Default: machine.azure.resource_group
+	VNetCIDR      string // Default: guard.vnet_cidr
+	SubnetCIDR    string // Default: guard.subnet_cidr
 	WireGuardConf string // Contents of wg0.conf
+	PublicKey     string // WireGuard public key, recorded if known
 	MeshCIDRs     []string
+	SSHSources    []string          // CIDRs SSH is allowed from (default: any address)
+	Tags          map[string]string // User tags of all its resources
 }
 
 // GuardStatus represents the current state of a guard.
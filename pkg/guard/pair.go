@@ -0,0 +1,269 @@
+package guard
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/wireguard"
+)
+
+// Roles of the guards of an HA pair
+const (
+	RolePrimary = "primary"
+	RoleStandby = "standby"
+)
+
+// RouteSwitcher is implemented by guard providers that can point a route
+// table at another guard, for the failover of HA pairs
+type RouteSwitcher interface {
+	// SwitchRouteTable sets the next hop of every route of a route table
+	// returned by ListRouteTables to nextHop, and moves the table to
+	// guardID, so it is listed and removed with that guard from then on.
+	SwitchRouteTable(ctx context.Context, routeTableID, guardID, nextHop string) error
+}
+
+// CreatePairRequest asks for an HA pair of guards in one location, made
+// from one WireGuard config template
+type CreatePairRequest struct {
+	Location       string
+	Zones          []string // Availability zones of the primary and standby (default: 1 and 2)
+	ConfigTemplate string   // wg0.conf, may use {{.GuardID}}, {{.Group}} and {{.Location}}
+	MeshCIDRs      []string
+	SSHSources     []string
+	Tags           map[string]string
+}
+
+// ProvisionPair creates a primary and a standby guard for the same mesh
+// CIDRs, in two availability zones of a location. The pair ID is their
+// group, and the prefix of their IDs: guard-1738123456 with
+// guard-1738123456-a and guard-1738123456-b. The standby's VNet follows the
+// primary's (10.101.0.0/16 after 10.100.0.0/16), so a workload VNet can
+// be peered with both; its route tables point at the primary until
+// Failover. Like the guards of a group, each has its own WireGuard key and
+// resource group.
+func (p *Provisioner) ProvisionPair(ctx context.Context, req CreatePairRequest) (string, []*Guard, error) {
+	zones := req.Zones
+	if len(zones) == 0 {
+		zones = []string{"1", "2"}
+	}
+	if len(zones) != 2 || zones[0] == zones[1] {
+		return "", nil, fmt.Errorf("an HA pair needs two different zones, got %s", strings.Join(zones, ","))
+	}
+	vnetCIDR, subnetCIDR := p.config.Guard.VNetCIDR, p.config.Guard.SubnetCIDR
+	standbyVNet, standbySubnet, err := NextVNet(vnetCIDR, subnetCIDR)
+	if err != nil {
+		return "", nil, err
+	}
+	pair := p.config.Naming.GuardID(config.NameData{Role: "guard", Timestamp: time.Now().Unix()})
+
+	members := []struct {
+		suffix, role, zone, vnet, subnet string
+	}{
+		{"a", RolePrimary, zones[0], vnetCIDR, subnetCIDR},
+		{"b", RoleStandby, zones[1], standbyVNet, standbySubnet},
+	}
+	var guards []*Guard
+	for _, m := range members {
+		guardID := fmt.Sprintf("%s-%s", pair, m.suffix)
+		conf, err := RenderConfig(req.ConfigTemplate, ConfigData{GuardID: guardID, Group: pair, Location: req.Location})
+		if err != nil {
+			return pair, guards, err
+		}
+		privateKey, publicKey, err := wireguard.GenerateKeyPair()
+		if err != nil {
+			return pair, guards, err
+		}
+		resourceGroup := machineSettings(p.config).ResourceGroup
+		if resourceGroup != "" {
+			resourceGroup = fmt.Sprintf("%s-%s", resourceGroup, guardID)
+		}
+		g, err := p.Provision(ctx, CreateGuardRequest{
+			GuardID:       guardID,
+			Group:         pair,
+			Role:          m.role,
+			Location:      req.Location,
+			Zone:          m.zone,
+			ResourceGroup: resourceGroup,
+			VNetCIDR:      m.vnet,
+			SubnetCIDR:    m.subnet,
+			WireGuardConf: SetPrivateKey(conf, privateKey),
+			PublicKey:     publicKey,
+			MeshCIDRs:     req.MeshCIDRs,
+			SSHSources:    req.SSHSources,
+			Tags:          req.Tags,
+		})
+		if err != nil {
+			return pair, guards, fmt.Errorf("%s: %w", m.role, err)
+		}
+		if p.secrets != nil {
+			if err := p.secrets.Put(ctx, "guard/"+guardID+"/wg-private-key", []byte(privateKey+"\n")); err != nil {
+				return pair, append(guards, g), fmt.Errorf("%s: failed to save WireGuard key: %w", m.role, err)
+			}
+		}
+		guards = append(guards, g)
+	}
+	return pair, guards, nil
+}
+
+// NextVNet returns the address range that follows a guard VNet, of the
+// same size, and the subnet at the same place in it
+func NextVNet(vnetCIDR, subnetCIDR string) (string, string, error) {
+	vnet, err := netip.ParsePrefix(vnetCIDR)
+	if err != nil || !vnet.Addr().Is4() {
+		return "", "", fmt.Errorf("invalid guard VNet CIDR %q", vnetCIDR)
+	}
+	subnet, err := netip.ParsePrefix(subnetCIDR)
+	if err != nil || !subnet.Addr().Is4() || !vnet.Contains(subnet.Addr()) {
+		return "", "", fmt.Errorf("invalid guard subnet CIDR %q", subnetCIDR)
+	}
+	size := uint64(1) << (32 - vnet.Bits())
+	shift := func(p netip.Prefix) (netip.Prefix, bool) {
+		a := p.Masked().Addr().As4()
+		next := uint64(binary.BigEndian.Uint32(a[:])) + size
+		if next > 0xffffffff {
+			return netip.Prefix{}, false
+		}
+		binary.BigEndian.PutUint32(a[:], uint32(next))
+		return netip.PrefixFrom(netip.AddrFrom4(a), p.Bits()), true
+	}
+	nextVNet, ok1 := shift(vnet)
+	nextSubnet, ok2 := shift(subnet)
+	if !ok1 || !ok2 {
+		return "", "", fmt.Errorf("no address range follows guard VNet %s", vnetCIDR)
+	}
+	return nextVNet.String(), nextSubnet.String(), nil
+}
+
+// PairMembers returns the guards of an HA pair: the active one, whose
+// private IP its route tables point at, and the standby. Until route
+// tables exist, the primary is the active one.
+func PairMembers(ctx context.Context, prov GuardProvider, guards []*Guard, pair string) (active, standby *Guard, err error) {
+	var members []*Guard
+	for _, g := range GroupMembers(guards, pair) {
+		if g.Role != "" {
+			members = append(members, g)
+		}
+	}
+	if len(members) != 2 {
+		return nil, nil, fmt.Errorf("%s is not an HA pair: found %d members", pair, len(members))
+	}
+	a, b := members[0], members[1]
+	if b.Role == RolePrimary {
+		a, b = b, a
+	}
+	tables, err := prov.ListRouteTables(ctx, b.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tables) > 0 {
+		return b, a, nil
+	}
+	return a, b, nil
+}
+
+// Healthy checks that a guard can take traffic: its VM is running and,
+// with a client config, a handshake with it succeeds
+func Healthy(ctx context.Context, prov GuardProvider, g *Guard, client *ClientConfig, timeout time.Duration) error {
+	server, err := prov.GetServer(ctx, g.ServerID)
+	if err != nil {
+		return err
+	}
+	if server.State != machine.ServerStateRunning {
+		return fmt.Errorf("VM is %s", server.State)
+	}
+	if client == nil {
+		return nil
+	}
+	// The client's endpoint is one guard; both guards of a pair accept it
+	endpoint := net.JoinHostPort(g.PublicIP, strconv.Itoa(g.WireGuardPort))
+	if _, err := Handshake(ctx, endpoint, client, timeout); err != nil && !errors.Is(err, ErrUnderLoad) {
+		return fmt.Errorf("handshake with %s: %w", endpoint, err)
+	}
+	return nil
+}
+
+// FailoverOptions configures Failover
+type FailoverOptions struct {
+	// Client is a WireGuard client config both guards accept, to check
+	// their health with a handshake; without it, only their VMs are checked
+	Client *ClientConfig
+
+	// Timeout bounds each handshake (default 5s)
+	Timeout time.Duration
+
+	// Auto fails over only if the active guard is unhealthy
+	Auto bool
+}
+
+// FailoverResult is what Failover did
+type FailoverResult struct {
+	From, To *Guard
+	Tables   []RouteTable // Switched from From to To
+	Skipped  string       // Why nothing was switched, if so
+}
+
+// Failover points the route tables of the active guard of an HA pair at
+// the standby, after checking the standby is healthy. The standby must be
+// peered with every network the active guard routes.
+func Failover(ctx context.Context, prov GuardProvider, active, standby *Guard, opts FailoverOptions) (*FailoverResult, error) {
+	switcher, ok := prov.(RouteSwitcher)
+	if !ok {
+		return nil, fmt.Errorf("failover is not supported by %s", active.Provider)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	result := &FailoverResult{From: active, To: standby}
+
+	if opts.Auto {
+		if err := Healthy(ctx, prov, active, opts.Client, opts.Timeout); err == nil {
+			result.Skipped = active.ID + " is healthy"
+			return result, nil
+		}
+	}
+	if err := Healthy(ctx, prov, standby, opts.Client, opts.Timeout); err != nil {
+		return nil, fmt.Errorf("standby %s is unhealthy, not failing over: %w", standby.ID, err)
+	}
+
+	tables, err := prov.ListRouteTables(ctx, active.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		result.Skipped = active.ID + " has no route tables"
+		return result, nil
+	}
+	for _, t := range tables {
+		if !peeredWith(standby, t.RemoteVNetID) {
+			return nil, fmt.Errorf("standby %s is not peered with %s; peer it first", standby.ID, t.RemoteVNetID)
+		}
+	}
+	var errs []error
+	for _, t := range tables {
+		if err := switcher.SwitchRouteTable(ctx, t.ID, standby.ID, standby.PrivateIP); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", t.Name, err))
+			continue
+		}
+		result.Tables = append(result.Tables, t)
+	}
+	return result, errors.Join(errs...)
+}
+
+// peeredWith reports whether a guard is peered with a network
+func peeredWith(g *Guard, remoteVNetID string) bool {
+	for _, p := range g.Peerings {
+		if strings.EqualFold(p.RemoteVNetID, remoteVNetID) {
+			return true
+		}
+	}
+	return false
+}
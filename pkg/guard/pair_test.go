@@ -0,0 +1,117 @@
+package guard
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+// pairProvider fakes the parts of a cloud failover uses
+type pairProvider struct {
+	GuardProvider
+	states map[string]machine.ServerState // By server ID
+	tables []RouteTable
+}
+
+func (p *pairProvider) GetServer(ctx context.Context, serverID string) (*machine.Server, error) {
+	state, ok := p.states[serverID]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return &machine.Server{ID: serverID, Name: serverID, State: state}, nil
+}
+
+func (p *pairProvider) ListRouteTables(ctx context.Context, guardID string) ([]RouteTable, error) {
+	var tables []RouteTable
+	for _, t := range p.tables {
+		if guardID == "" || t.GuardID == guardID {
+			tables = append(tables, t)
+		}
+	}
+	return tables, nil
+}
+
+func (p *pairProvider) SwitchRouteTable(ctx context.Context, routeTableID, guardID, nextHop string) error {
+	for i := range p.tables {
+		if p.tables[i].ID == routeTableID {
+			p.tables[i].GuardID = guardID
+			p.tables[i].NextHops = []string{nextHop}
+			return nil
+		}
+	}
+	return errors.New("no such route table")
+}
+
+func TestNextVNet(t *testing.T) {
+	tests := []struct {
+		vnet, subnet         string
+		wantVNet, wantSubnet string
+		wantErr              bool
+	}{
+		{"10.100.0.0/16", "10.100.1.0/24", "10.101.0.0/16", "10.101.1.0/24", false},
+		{"10.100.0.0/24", "10.100.0.128/25", "10.100.1.0/24", "10.100.1.128/25", false},
+		{"255.255.0.0/16", "255.255.1.0/24", "", "", true},
+		{"10.100.0.0/16", "10.200.1.0/24", "", "", true},
+		{"fd00::/64", "fd00::/80", "", "", true},
+	}
+	for _, tt := range tests {
+		vnet, subnet, err := NextVNet(tt.vnet, tt.subnet)
+		if (err != nil) != tt.wantErr || vnet != tt.wantVNet || subnet != tt.wantSubnet {
+			t.Errorf("NextVNet(%s, %s) = %s, %s, %v", tt.vnet, tt.subnet, vnet, subnet, err)
+		}
+	}
+}
+
+func TestFailover(t *testing.T) {
+	ctx := context.Background()
+	workload := "/subscriptions/s/resourceGroups/w/providers/Microsoft.Network/virtualNetworks/workload"
+	primary := &Guard{ID: "pair-a", Group: "pair", Role: RolePrimary, ServerID: "vm-a", PrivateIP: "10.100.1.4",
+		Peerings: []PeeringInfo{{Name: "a", RemoteVNetID: workload}}}
+	standby := &Guard{ID: "pair-b", Group: "pair", Role: RoleStandby, ServerID: "vm-b", PrivateIP: "10.101.1.4",
+		Peerings: []PeeringInfo{{Name: "b", RemoteVNetID: workload}}}
+	prov := &pairProvider{
+		states: map[string]machine.ServerState{"vm-a": machine.ServerStateRunning, "vm-b": machine.ServerStateRunning},
+		tables: []RouteTable{{ID: "rt-1", Name: "rt-1", GuardID: "pair-a", RemoteVNetID: workload, NextHops: []string{"10.100.1.4"}}},
+	}
+	guards := []*Guard{standby, primary, {ID: "other", Group: "group"}}
+
+	active, passive, err := PairMembers(ctx, prov, guards, "pair")
+	if err != nil || active != primary || passive != standby {
+		t.Fatalf("PairMembers() = %v, %v, %v, want the primary active", active, passive, err)
+	}
+	if _, _, err := PairMembers(ctx, prov, guards, "group"); err == nil {
+		t.Error("PairMembers() of a group that is no pair succeeded")
+	}
+
+	// A healthy primary is kept with Auto
+	result, err := Failover(ctx, prov, primary, standby, FailoverOptions{Auto: true})
+	if err != nil || result.Skipped == "" || prov.tables[0].GuardID != "pair-a" {
+		t.Fatalf("Failover(Auto) with a healthy primary = %+v, %v", result, err)
+	}
+
+	prov.states["vm-a"] = machine.ServerStateStopped
+	result, err = Failover(ctx, prov, primary, standby, FailoverOptions{Auto: true})
+	if err != nil || len(result.Tables) != 1 {
+		t.Fatalf("Failover(Auto) with a stopped primary = %+v, %v", result, err)
+	}
+	if prov.tables[0].GuardID != "pair-b" || prov.tables[0].NextHops[0] != "10.101.1.4" {
+		t.Errorf("route table after Failover() = %+v, want it via the standby", prov.tables[0])
+	}
+	if active, _, _ := PairMembers(ctx, prov, guards, "pair"); active != standby {
+		t.Errorf("PairMembers() after Failover() = %s active, want %s", active.ID, standby.ID)
+	}
+
+	// Failing back needs a healthy target
+	if _, err := Failover(ctx, prov, standby, primary, FailoverOptions{}); err == nil {
+		t.Error("Failover() to a stopped guard succeeded")
+	}
+
+	// The standby must be peered with the routed networks
+	prov.states["vm-a"] = machine.ServerStateRunning
+	primary.Peerings = nil
+	if _, err := Failover(ctx, prov, standby, primary, FailoverOptions{}); err == nil {
+		t.Error("Failover() to a guard that is not peered succeeded")
+	}
+}
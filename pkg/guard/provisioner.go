@@ -2,20 +2,27 @@ package guard
 
 import (
 	"context"
-	"encoding/base64"
+	"errors"
 	"fmt"
+	"maps"
 	"strings"
 	"time"
 
 	"github.com/nimsforest/morpheus/pkg/cloudinit"
 	"github.com/nimsforest/morpheus/pkg/config"
 	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/machine/hetzner"
+	"github.com/nimsforest/morpheus/pkg/secretstore"
+	"github.com/nimsforest/morpheus/pkg/storage"
 )
 
 // Provisioner orchestrates guard VM creation.
 type Provisioner struct {
 	provider GuardProvider
 	config   *config.Config
+	secrets  secretstore.Store     // Keeps generated WireGuard keys (optional)
+	registry storage.GuardRegistry // Records created guards (optional)
+	operator string                // Who creates guards, for the registry
 }
 
 // NewProvisioner creates a new guard provisioner.
@@ -26,15 +33,32 @@ func NewProvisioner(p GuardProvider, cfg *config.Config) *Provisioner {
 	}
 }
 
+// SetSecrets keeps the WireGuard keys generated for guards in s, below
+// guard/<id>/
+func (p *Provisioner) SetSecrets(s secretstore.Store) {
+	p.secrets = s
+}
+
 // Provision creates a new guard VM with the full networking stack.
 func (p *Provisioner) Provision(ctx context.Context, req CreateGuardRequest) (*Guard, error) {
-	guardID := fmt.Sprintf("guard-%d", time.Now().Unix())
+	guardID := req.GuardID
+	if guardID == "" {
+		guardID = p.config.Naming.GuardID(config.NameData{Role: "guard", Timestamp: time.Now().Unix()})
+	}
 	guardCfg := p.config.Guard
-	azureCfg := p.config.Machine.Azure
+	vm := machineSettings(p.config)
 
 	location := req.Location
 	if location == "" {
-		location = azureCfg.Location
+		location = vm.Location
+	}
+	resourceGroup := req.ResourceGroup
+	if resourceGroup == "" {
+		resourceGroup = vm.ResourceGroup
+	}
+	vnetCIDR, subnetCIDR := guardCfg.VNetCIDR, guardCfg.SubnetCIDR
+	if req.VNetCIDR != "" {
+		vnetCIDR, subnetCIDR = req.VNetCIDR, req.SubnetCIDR
 	}
 
 	fmt.Printf("\n🛡️  Creating guard: %s\n", guardID)
@@ -42,13 +66,29 @@ func (p *Provisioner) Provision(ctx context.Context, req CreateGuardRequest) (*G
 	fmt.Printf("📋 Configuration:\n")
 	fmt.Printf("   Guard ID:    %s\n", guardID)
 	fmt.Printf("   Location:    %s\n", location)
-	fmt.Printf("   VM Size:     %s\n", azureCfg.VMSize)
-	fmt.Printf("   VNet CIDR:   %s\n", guardCfg.VNetCIDR)
-	fmt.Printf("   Subnet CIDR: %s\n", guardCfg.SubnetCIDR)
+	if req.Zone != "" {
+		fmt.Printf("   Zone:        %s\n", req.Zone)
+	}
+	fmt.Printf("   Provider:    %s\n", p.config.GetGuardProvider())
+	fmt.Printf("   VM Size:     %s\n", vm.Size)
+	if cost := expectedCost(p.config); cost > 0 {
+		fmt.Printf("   Est. cost:   €%.2f/month\n", cost)
+	}
+	if spot := p.config.Machine.Azure.Spot; spot.Enabled && p.config.GetGuardProvider() == "azure" {
+		fmt.Printf("   Spot:        evicted VMs are %sd, max price %s\n", spot.GetEvictionPolicy(), formatMaxPrice(spot.GetMaxPrice()))
+	}
+	fmt.Printf("   VNet CIDR:   %s\n", vnetCIDR)
+	fmt.Printf("   Subnet CIDR: %s\n", subnetCIDR)
 	fmt.Printf("   WG Port:     %d\n", guardCfg.WGPort)
 	if len(req.MeshCIDRs) > 0 {
 		fmt.Printf("   Mesh CIDRs:  %s\n", strings.Join(req.MeshCIDRs, ", "))
 	}
+	if len(req.SSHSources) > 0 {
+		fmt.Printf("   SSH from:    %s\n", strings.Join(req.SSHSources, ", "))
+	}
+	if len(req.Tags) > 0 {
+		fmt.Printf("   Tags:        %s\n", FormatTags(req.Tags))
+	}
 	fmt.Println()
 
 	// Step 1: Create network infrastructure
@@ -56,10 +96,13 @@ func (p *Provisioner) Provision(ctx context.Context, req CreateGuardRequest) (*G
 	netInfo, err := p.provider.EnsureNetwork(ctx, NetworkRequest{
 		GuardID:       guardID,
 		Location:      location,
-		ResourceGroup: azureCfg.ResourceGroup,
-		VNetCIDR:      guardCfg.VNetCIDR,
-		SubnetCIDR:    guardCfg.SubnetCIDR,
+		ResourceGroup: resourceGroup,
+		VNetCIDR:      vnetCIDR,
+		SubnetCIDR:    subnetCIDR,
 		WireGuardPort: guardCfg.WGPort,
+		Group:         req.Group,
+		SSHSources:    req.SSHSources,
+		Tags:          req.Tags,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create network: %w", err)
@@ -76,35 +119,51 @@ func (p *Provisioner) Provision(ctx context.Context, req CreateGuardRequest) (*G
 		return nil, fmt.Errorf("failed to generate cloud-init: %w", err)
 	}
 
-	// Azure requires base64-encoded custom data
-	userDataB64 := base64.StdEncoding.EncodeToString([]byte(userData))
 	fmt.Printf("   ✅ Cloud-init generated\n\n")
 
 	// Step 3: Create VM
 	fmt.Printf("📦 Step 3/4: Creating VM\n")
 	vmName := fmt.Sprintf("%s-vm", guardID)
 
-	// Read SSH public key for Azure
+	// Read SSH public key for the VM
 	sshKeys, err := readSSHPublicKeys(p.config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read SSH keys: %w", err)
 	}
 
+	labels := maps.Clone(req.Tags)
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	maps.Copy(labels, map[string]string{
+		"managed-by":     "morpheus-azureguard",
+		"guard-id":       guardID,
+		"mesh-cidrs":     strings.Join(req.MeshCIDRs, ","),
+		"wg-port":        fmt.Sprintf("%d", guardCfg.WGPort),
+		"nic-id":         netInfo.NICID,
+		"resource-group": netInfo.ResourceGroup,
+	})
+	if req.Group != "" {
+		labels["guard-group"] = req.Group
+	}
+	if req.PublicKey != "" {
+		labels["wg-public-key"] = req.PublicKey
+	}
+	if req.Role != "" {
+		labels["guard-role"] = req.Role
+	}
+	if req.Zone != "" {
+		labels["availability-zone"] = req.Zone
+	}
+
 	server, err := p.provider.CreateServer(ctx, machine.CreateServerRequest{
 		Name:       vmName,
-		ServerType: azureCfg.VMSize,
-		Image:      azureCfg.Image,
+		ServerType: vm.Size,
+		Image:      vm.Image,
 		Location:   location,
 		SSHKeys:    sshKeys,
-		UserData:   userDataB64,
-		Labels: map[string]string{
-			"managed-by":     "morpheus-azureguard",
-			"guard-id":       guardID,
-			"mesh-cidrs":     strings.Join(req.MeshCIDRs, ","),
-			"wg-port":        fmt.Sprintf("%d", guardCfg.WGPort),
-			"nic-id":         netInfo.NICID,
-			"resource-group": netInfo.ResourceGroup,
-		},
+		UserData:   userData,
+		Labels:     labels,
 		EnableIPv4: true,
 	})
 	if err != nil {
@@ -121,7 +180,7 @@ func (p *Provisioner) Provision(ctx context.Context, req CreateGuardRequest) (*G
 
 	guard := &Guard{
 		ID:            guardID,
-		Provider:      "azure",
+		Provider:      p.config.GetGuardProvider(),
 		Location:      location,
 		Status:        "active",
 		PublicIP:      netInfo.PublicIP,
@@ -135,18 +194,27 @@ func (p *Provisioner) Provision(ctx context.Context, req CreateGuardRequest) (*G
 		ResourceGroup: netInfo.ResourceGroup,
 		MeshCIDRs:     req.MeshCIDRs,
 		WireGuardPort: guardCfg.WGPort,
+		PublicKey:     req.PublicKey,
+		Group:         req.Group,
+		Role:          req.Role,
+		Tags:          req.Tags,
 		CreatedAt:     time.Now(),
 	}
 
+	if p.registry != nil {
+		if err := p.register(guard, req.WireGuardConf); err != nil {
+			fmt.Printf("⚠️  Warning: guard not recorded in the registry: %s\n\n", err)
+		}
+	}
 	return guard, nil
 }
 
-// Teardown removes a guard and all its Azure resources.
+// Teardown removes a guard and all its cloud resources.
 func (p *Provisioner) Teardown(ctx context.Context, guardID string) error {
 	fmt.Printf("\n🗑️  Tearing down guard: %s\n", guardID)
 	fmt.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
 
-	// Get guard info from Azure
+	// Get guard info from the cloud
 	g, err := p.provider.GetGuard(ctx, guardID)
 	if err != nil {
 		return fmt.Errorf("guard not found: %w", err)
@@ -157,15 +225,71 @@ func (p *Provisioner) Teardown(ctx context.Context, guardID string) error {
 	fmt.Println()
 
 	// Delete the resource group — this removes everything
-	fmt.Printf("   Deleting all Azure resources...\n")
+	fmt.Printf("   Deleting all %s resources...\n", g.Provider)
 	if err := p.provider.CleanupNetwork(ctx, guardID); err != nil {
 		return fmt.Errorf("failed to cleanup: %w", err)
 	}
 
 	fmt.Printf("   ✅ All resources deleted\n")
+
+	if p.secrets != nil {
+		if err := secretstore.DeletePrefix(ctx, p.secrets, "guard/"+guardID+"/"); err != nil {
+			fmt.Printf("   ⚠️  Failed to remove the guard's secrets: %s\n", err)
+		}
+	}
+	if p.registry != nil {
+		if err := p.registry.DeleteGuard(guardID); err != nil && !errors.Is(err, storage.ErrGuardNotFound) {
+			fmt.Printf("   ⚠️  Failed to remove the guard from the registry: %s\n", err)
+		}
+	}
 	return nil
 }
 
+// formatMaxPrice describes the max price per hour of a Spot VM
+func formatMaxPrice(price float64) string {
+	if price < 0 {
+		return "on-demand"
+	}
+	return fmt.Sprintf("$%g/h", price)
+}
+
+// vmSettings are the configured defaults for new guard VMs
+type vmSettings struct {
+	Location      string
+	ResourceGroup string // Azure only
+	Size          string
+	Image         string
+}
+
+// machineSettings returns the defaults for new guard VMs of the cloud in
+// guard.provider
+func machineSettings(cfg *config.Config) vmSettings {
+	switch cfg.GetGuardProvider() {
+	case "gcp":
+		gcp := cfg.Machine.GCP
+		return vmSettings{Location: gcp.Zone, Size: gcp.MachineType, Image: gcp.Image}
+	case "aws":
+		aws := cfg.Machine.AWS
+		return vmSettings{Location: aws.Region, Size: aws.InstanceType, Image: aws.Image}
+	case "hetzner":
+		hz := cfg.Machine.Hetzner
+		return vmSettings{Location: hz.Location, Size: hz.ServerType, Image: hz.Image}
+	}
+	az := cfg.Machine.Azure
+	return vmSettings{Location: az.Location, ResourceGroup: az.ResourceGroup, Size: az.VMSize, Image: az.Image}
+}
+
+// expectedCost returns the estimated monthly spend of a new guard, or 0 if
+// unknown. Only Hetzner guards are priced: they are billed with the forests
+// and compared by billing checks.
+func expectedCost(cfg *config.Config) float64 {
+	if cfg.GetGuardProvider() != "hetzner" {
+		return 0
+	}
+	// The guard's network is free; its primary IPv4 address is not
+	return hetzner.GetEstimatedNodeCost(machineSettings(cfg).Size, true, 0)
+}
+
 // readSSHPublicKeys reads SSH public keys from config paths.
 func readSSHPublicKeys(cfg *config.Config) ([]string, error) {
 	keyPath := cfg.GetSSHKeyPath()
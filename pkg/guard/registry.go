@@ -0,0 +1,194 @@
+package guard
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// SetRegistry records the guards the provisioner creates and tears down
+// in reg, with operator as who created them
+func (p *Provisioner) SetRegistry(reg storage.GuardRegistry, operator string) {
+	p.registry = reg
+	p.operator = operator
+}
+
+// register records a new guard and the config it was created with
+func (p *Provisioner) register(g *Guard, conf string) error {
+	rec := Record(g)
+	rec.CreatedBy = p.operator
+	if g.Provider == "hetzner" {
+		rec.Project = p.config.GetHetznerProject()
+	}
+	rec.ExpectedCost = expectedCost(p.config)
+	existing, err := p.registry.GetGuard(g.ID)
+	if err != nil {
+		rec.AddConfig(ConfigRecord(conf, p.operator))
+		return p.registry.RegisterGuard(rec)
+	}
+	// A guard re-created with the same ID keeps its config history
+	rec.Configs = slices.Clone(existing.Configs)
+	rec.AddConfig(ConfigRecord(conf, p.operator))
+	return p.registry.UpdateGuard(rec)
+}
+
+// Record converts a guard into its registry record
+func Record(g *Guard) *storage.Guard {
+	rec := &storage.Guard{
+		ID:            g.ID,
+		Provider:      g.Provider,
+		Location:      g.Location,
+		Group:         g.Group,
+		Role:          g.Role,
+		Status:        g.Status,
+		PublicIP:      g.PublicIP,
+		PrivateIP:     g.PrivateIP,
+		ServerID:      g.ServerID,
+		VNetID:        g.VNetID,
+		ResourceGroup: g.ResourceGroup,
+		MeshCIDRs:     g.MeshCIDRs,
+		WireGuardPort: g.WireGuardPort,
+		PublicKey:     g.PublicKey,
+		Tags:          g.Tags,
+		CreatedAt:     g.CreatedAt,
+		SyncedAt:      time.Now().UTC(),
+	}
+	for _, p := range g.Peerings {
+		rec.SetPeering(storage.GuardPeering{Name: p.Name, RemoteVNetID: p.RemoteVNetID, RouteTableID: p.RouteTableID})
+	}
+	return rec
+}
+
+// FromRecord converts a registry record back into a guard, as last seen
+func FromRecord(rec *storage.Guard) *Guard {
+	g := &Guard{
+		ID:            rec.ID,
+		Provider:      rec.Provider,
+		Location:      rec.Location,
+		Status:        rec.Status,
+		PublicIP:      rec.PublicIP,
+		PrivateIP:     rec.PrivateIP,
+		ServerID:      rec.ServerID,
+		VNetID:        rec.VNetID,
+		ResourceGroup: rec.ResourceGroup,
+		MeshCIDRs:     rec.MeshCIDRs,
+		WireGuardPort: rec.WireGuardPort,
+		PublicKey:     rec.PublicKey,
+		Group:         rec.Group,
+		Role:          rec.Role,
+		Tags:          rec.Tags,
+		CreatedAt:     rec.CreatedAt,
+	}
+	for _, p := range rec.Peerings {
+		g.Peerings = append(g.Peerings, PeeringInfo{Name: p.Name, RemoteVNetID: p.RemoteVNetID, RouteTableID: p.RouteTableID})
+	}
+	return g
+}
+
+// Merge updates a registry record with a guard as seen in the cloud,
+// keeping what only the registry knows: who created the guard and its
+// peerings, and its configs
+func Merge(rec *storage.Guard, g *Guard) *storage.Guard {
+	merged := Record(g)
+	merged.CreatedBy = rec.CreatedBy
+	merged.Configs = rec.Configs
+	merged.Project = rec.Project
+	merged.ExpectedCost = rec.ExpectedCost
+	if merged.PublicKey == "" {
+		merged.PublicKey = rec.PublicKey
+	}
+	if merged.Tags == nil {
+		merged.Tags = rec.Tags
+	}
+	peerings := merged.Peerings
+	merged.Peerings = nil
+	for _, p := range peerings {
+		if known := rec.FindPeering(p.RemoteVNetID); known != nil {
+			p.CreatedBy, p.CreatedAt, p.SubnetID = known.CreatedBy, known.CreatedAt, known.SubnetID
+		}
+		merged.Peerings = append(merged.Peerings, p)
+	}
+	return merged
+}
+
+// SyncResult counts the registry changes made by SyncRegistry
+type SyncResult struct {
+	Added   int // Guards found in the cloud that were not registered
+	Updated int
+	Removed int // Registered guards no longer in the cloud
+}
+
+// SyncRegistry brings the registered guards of a provider in line with
+// the guards discovered in its cloud: new ones are added, known ones
+// updated and ones that are gone removed.
+func SyncRegistry(reg storage.GuardRegistry, provider string, discovered []*Guard) (SyncResult, error) {
+	var result SyncResult
+	var errs []error
+	seen := make(map[string]bool)
+	for _, g := range discovered {
+		seen[g.ID] = true
+		rec, err := reg.GetGuard(g.ID)
+		if err != nil {
+			if err := reg.RegisterGuard(Record(g)); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			result.Added++
+			continue
+		}
+		if err := reg.UpdateGuard(Merge(rec, g)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result.Updated++
+	}
+	for _, rec := range reg.ListGuards() {
+		if rec.Provider != provider || seen[rec.ID] {
+			continue
+		}
+		if err := reg.DeleteGuard(rec.ID); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result.Removed++
+	}
+	return result, errors.Join(errs...)
+}
+
+// ConfigRecord returns the registry entry for a WireGuard config, with
+// its private key redacted
+func ConfigRecord(conf, operator string) storage.GuardConfig {
+	redacted := RedactPrivateKey(conf)
+	sum := sha256.Sum256([]byte(redacted))
+	return storage.GuardConfig{
+		Time:     time.Now().UTC(),
+		Operator: operator,
+		SHA256:   hex.EncodeToString(sum[:]),
+		Config:   redacted,
+	}
+}
+
+// RedactPrivateKey replaces the [Interface] PrivateKey of a WireGuard
+// config with a placeholder
+func RedactPrivateKey(conf string) string {
+	var out []string
+	section := ""
+	scanner := bufio.NewScanner(strings.NewReader(conf))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			section = strings.ToLower(strings.Trim(trimmed, "[]"))
+		} else if key, _, ok := strings.Cut(trimmed, "="); ok && section == "interface" && strings.EqualFold(strings.TrimSpace(key), "privatekey") {
+			line = "PrivateKey = (redacted)"
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n") + "\n"
+}
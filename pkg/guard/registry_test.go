@@ -0,0 +1,85 @@
+package guard
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/machine/hetzner"
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+func TestSyncRegistry(t *testing.T) {
+	reg, err := storage.NewLocalRegistry(filepath.Join(t.TempDir(), "registry.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	known := &storage.Guard{ID: "guard-1", Provider: "azure", Status: "running", CreatedBy: "alice", ExpectedCost: 4.3}
+	known.SetPeering(storage.GuardPeering{Name: "guard-1-peer", RemoteVNetID: "vnet-a", SubnetID: "subnet-a", CreatedBy: "alice"})
+	known.AddConfig(ConfigRecord("[Interface]\nPrivateKey = secret\n", "alice"))
+	for _, g := range []*storage.Guard{
+		known,
+		{ID: "guard-gone", Provider: "azure"},
+		{ID: "guard-gcp", Provider: "gcp"},
+	} {
+		if err := reg.RegisterGuard(g); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := SyncRegistry(reg, "azure", []*Guard{
+		{ID: "guard-1", Provider: "azure", Status: "stopped", Peerings: []PeeringInfo{{Name: "guard-1-peer", RemoteVNetID: "VNET-A", RouteTableID: "rt-1"}}},
+		{ID: "guard-2", Provider: "azure", Status: "running"},
+	})
+	if err != nil {
+		t.Fatalf("SyncRegistry() error = %v", err)
+	}
+	if result != (SyncResult{Added: 1, Updated: 1, Removed: 1}) {
+		t.Errorf("SyncRegistry() = %+v", result)
+	}
+
+	var ids []string
+	for _, g := range reg.ListGuards() {
+		ids = append(ids, g.ID)
+	}
+	if strings.Join(ids, ",") != "guard-1,guard-2,guard-gcp" {
+		t.Errorf("registered guards = %v, want guard-gone removed and other clouds' kept", ids)
+	}
+
+	g, err := reg.GetGuard("guard-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Status != "stopped" || g.CreatedBy != "alice" || len(g.Configs) != 1 || g.ExpectedCost != 4.3 {
+		t.Errorf("guard-1 = %+v, want the cloud status and the registry's metadata", g)
+	}
+	if len(g.Peerings) != 1 || g.Peerings[0].CreatedBy != "alice" || g.Peerings[0].SubnetID != "subnet-a" || g.Peerings[0].RouteTableID != "rt-1" {
+		t.Errorf("guard-1 peerings = %+v", g.Peerings)
+	}
+}
+
+func TestRedactPrivateKey(t *testing.T) {
+	conf := "[Interface]\nAddress = 10.200.0.1/24\nPrivateKey = c2VjcmV0\n\n[Peer]\nPublicKey = cHVibGlj\n"
+	got := RedactPrivateKey(conf)
+	if strings.Contains(got, "c2VjcmV0") || !strings.Contains(got, "PrivateKey = (redacted)") || !strings.Contains(got, "PublicKey = cHVibGlj") {
+		t.Errorf("RedactPrivateKey() = %q", got)
+	}
+	if a, b := ConfigRecord(conf, "alice"), ConfigRecord(strings.Replace(conf, "c2VjcmV0", "b3RoZXI=", 1), "bob"); a.SHA256 != b.SHA256 {
+		t.Error("ConfigRecord() of configs differing only in their private key should match")
+	}
+}
+
+func TestExpectedCost(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Guard.Provider = "hetzner"
+	cfg.Machine.Hetzner.ServerType = "cx22"
+	if got, want := expectedCost(cfg), hetzner.GetEstimatedNodeCost("cx22", true, 0); got != want || got == 0 {
+		t.Errorf("expectedCost(hetzner) = %.2f, want %.2f", got, want)
+	}
+	// Other clouds bill guards themselves
+	cfg.Guard.Provider = "azure"
+	if got := expectedCost(cfg); got != 0 {
+		t.Errorf("expectedCost(azure) = %.2f, want 0", got)
+	}
+}
@@ -0,0 +1,207 @@
+package guard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/wireguard"
+)
+
+// RunFunc runs a shell script as root on a guard VM and returns its
+// output, e.g. through the cloud's run command or SSH
+type RunFunc func(ctx context.Context, script string) (string, error)
+
+// Markers the rotation scripts print for Rotate to check
+const (
+	markerInstalled     = "WG_ROTATE_INSTALLED"
+	markerRestartFailed = "WG_ROTATE_RESTART_FAILED"
+	markerHandshake     = "WG_ROTATE_HANDSHAKE"
+	markerRolledBack    = "WG_ROTATE_ROLLED_BACK"
+)
+
+// wgConfPath is where guards keep their WireGuard config (see
+// cloudinit.GuardTemplate)
+const wgConfPath = "/etc/wireguard/wg0.conf"
+
+// RotateOptions configures Rotate
+type RotateOptions struct {
+	// Client is a WireGuard client config the new config accepts. Without
+	// it, the guard itself must see a handshake with any of its peers.
+	Client *ClientConfig
+
+	// Timeout bounds the wait for a handshake (default 30s)
+	Timeout time.Duration
+}
+
+// Rotate replaces the WireGuard config of a guard with conf and restarts
+// wg-quick, then verifies that a handshake succeeds. If the restart or the
+// handshake fails, the previous config is restored and the error says so.
+func Rotate(ctx context.Context, run RunFunc, g *Guard, conf string, opts RotateOptions) error {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+
+	fmt.Printf("📦 Step 1/3: Installing the new config and restarting wg-quick\n")
+	out, err := run(ctx, installScript(conf))
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to install the config: %w", err)
+	case strings.Contains(out, markerRestartFailed):
+		return fmt.Errorf("wg-quick failed to start with the new config; the previous config was restored:\n%s", strings.TrimSpace(out))
+	case !strings.Contains(out, markerInstalled):
+		return fmt.Errorf("failed to install the config:\n%s", strings.TrimSpace(out))
+	}
+	fmt.Printf("   ✅ wg-quick restarted\n\n")
+
+	// Handshakes are compared with the guard's clock, not ours
+	since := time.Now().Unix()
+	if _, after, ok := strings.Cut(out, markerInstalled); ok {
+		if fields := strings.Fields(after); len(fields) > 0 {
+			if t, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+				since = t
+			}
+		}
+	}
+
+	fmt.Printf("📦 Step 2/3: Verifying the handshake\n")
+	verr := verifyHandshake(ctx, run, g, since, opts)
+	if verr == nil {
+		fmt.Printf("📦 Step 3/3: Keeping the new config\n")
+		fmt.Printf("   ✅ Previous config kept as %s.prev\n\n", wgConfPath)
+		return nil
+	}
+	fmt.Printf("   ❌ %s\n\n", verr)
+
+	fmt.Printf("📦 Step 3/3: Rolling back to the previous config\n")
+	out, err = run(ctx, rollbackScript)
+	if err != nil || !strings.Contains(out, markerRolledBack) {
+		if err == nil {
+			err = errors.New(strings.TrimSpace(out))
+		}
+		return fmt.Errorf("handshake failed (%w) and rolling back failed: %v", verr, err)
+	}
+	fmt.Printf("   ✅ Previous config restored\n\n")
+	return fmt.Errorf("handshake failed with the new config, previous config restored: %w", verr)
+}
+
+// verifyHandshake waits for a handshake with the guard: from here as the
+// client of opts.Client, or else between the guard and any of its peers
+// since the restart
+func verifyHandshake(ctx context.Context, run RunFunc, g *Guard, since int64, opts RotateOptions) error {
+	if opts.Client == nil {
+		out, err := run(ctx, handshakeScript(since, opts.Timeout))
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(out, markerHandshake) {
+			return fmt.Errorf("no peer completed a handshake with the guard within %s", opts.Timeout)
+		}
+		fmt.Printf("   ✅ A peer completed a handshake with the guard\n\n")
+		return nil
+	}
+
+	endpoint := opts.Client.Endpoint
+	if endpoint == "" {
+		endpoint = net.JoinHostPort(g.PublicIP, strconv.Itoa(g.WireGuardPort))
+	}
+	deadline := time.Now().Add(opts.Timeout)
+	for {
+		rtt, err := Handshake(ctx, endpoint, opts.Client, 5*time.Second)
+		if err == nil || errors.Is(err, ErrUnderLoad) {
+			fmt.Printf("   ✅ Handshake with %s in %s\n\n", endpoint, rtt.Round(time.Millisecond))
+			return nil
+		}
+		if time.Now().After(deadline) || ctx.Err() != nil {
+			return fmt.Errorf("%s: %w", endpoint, err)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// installScript writes conf as the guard's config, keeping the current one
+// as wg0.conf.prev, and restarts wg-quick. If wg-quick does not come up,
+// the previous config is restored at once.
+func installScript(conf string) string {
+	delimiter := "MORPHEUS_WG_CONF"
+	for strings.Contains(conf, delimiter) {
+		delimiter += "_"
+	}
+	if !strings.HasSuffix(conf, "\n") {
+		conf += "\n"
+	}
+	return fmt.Sprintf(`set -u
+umask 077
+cp -p %[1]s %[1]s.prev || exit 1
+cat > %[1]s.new <<'%[2]s'
+%[3]s%[2]s
+mv %[1]s.new %[1]s || exit 1
+if systemctl restart wg-quick@wg0 && systemctl is-active --quiet wg-quick@wg0; then
+  echo %[4]s "$(date +%%s)"
+else
+  journalctl -u wg-quick@wg0 -n 20 --no-pager 2>&1
+  cp -p %[1]s.prev %[1]s && systemctl restart wg-quick@wg0
+  echo %[5]s
+fi
+`, wgConfPath, delimiter, conf, markerInstalled, markerRestartFailed)
+}
+
+// handshakeScript waits up to timeout for any peer of wg0 to have a
+// handshake at or after the Unix time since
+func handshakeScript(since int64, timeout time.Duration) string {
+	return fmt.Sprintf(`end=$(( $(date +%%s) + %d ))
+while [ "$(date +%%s)" -le "$end" ]; do
+  if wg show wg0 latest-handshakes | awk -v since=%d '$2 >= since { found = 1 } END { exit !found }'; then
+    echo %s
+    exit 0
+  fi
+  sleep 2
+done
+wg show wg0 2>&1
+`, int(timeout.Seconds()), since, markerHandshake)
+}
+
+// rollbackScript restores the config saved by installScript
+var rollbackScript = fmt.Sprintf(`cp -p %[1]s.prev %[1]s && systemctl restart wg-quick@wg0 && echo %[2]s
+`, wgConfPath, markerRolledBack)
+
+// InterfacePublicKey returns the public key of a WireGuard config's
+// [Interface] PrivateKey, or "" if it has none
+func InterfacePublicKey(conf string) string {
+	section := ""
+	for _, line := range strings.Split(conf, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			section = strings.ToLower(strings.Trim(line, "[]"))
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != "interface" || !strings.EqualFold(strings.TrimSpace(key), "privatekey") {
+			continue
+		}
+		pub, err := wireguard.PublicKey(strings.TrimSpace(value))
+		if err != nil {
+			return ""
+		}
+		return pub
+	}
+	return ""
+}
+
+// SSHUser returns the user guards of a cloud accept SSH logins for, with
+// the keys from machine.ssh
+func SSHUser(provider string) string {
+	switch provider {
+	case "gcp":
+		return "morpheus"
+	case "aws":
+		return "ubuntu"
+	case "hetzner":
+		return "root"
+	}
+	return "azureuser"
+}
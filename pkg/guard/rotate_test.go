@@ -0,0 +1,130 @@
+package guard
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/wireguard"
+)
+
+// fakeGuard runs the rotation scripts with sh against a config in a
+// temporary directory. Stand-ins for systemctl and wg fail to start a
+// config containing "BROKEN" and report no handshake for one containing
+// "SILENT".
+func fakeGuard(t *testing.T, current string) (RunFunc, string) {
+	t.Helper()
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "wg0.conf")
+	if err := os.WriteFile(conf, []byte(current), 0600); err != nil {
+		t.Fatal(err)
+	}
+	bin := filepath.Join(dir, "bin")
+	os.Mkdir(bin, 0755)
+	tools := map[string]string{
+		"systemctl":  `case "$1" in restart) ! grep -q BROKEN ` + conf + `;; *) exit 0;; esac`,
+		"journalctl": `echo "wg-quick: invalid config"`,
+		"wg":         `if grep -q SILENT ` + conf + `; then printf 'peer\t0\n'; else printf 'peer\t%s\n' "$(date +%s)"; fi`,
+	}
+	for name, body := range tools {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(ctx context.Context, script string) (string, error) {
+		cmd := exec.CommandContext(ctx, "sh", "-c", strings.ReplaceAll(script, wgConfPath, conf))
+		cmd.Env = append(os.Environ(), "PATH="+bin+":"+os.Getenv("PATH"))
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+	return run, conf
+}
+
+func readConf(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRotate(t *testing.T) {
+	ctx := context.Background()
+	g := &Guard{ID: "guard-1", PublicIP: "127.0.0.1", WireGuardPort: 51820}
+	old := "[Interface]\nListenPort = 51820\n"
+	opts := RotateOptions{Timeout: time.Second}
+
+	run, conf := fakeGuard(t, old)
+	// A config containing the heredoc delimiter must survive intact
+	next := "[Interface]\nListenPort = 51820\n# MORPHEUS_WG_CONF\n"
+	if err := Rotate(ctx, run, g, next, opts); err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if got := readConf(t, conf); got != next {
+		t.Errorf("config = %q, want %q", got, next)
+	}
+	if got := readConf(t, conf+".prev"); got != old {
+		t.Errorf("previous config = %q, want %q", got, old)
+	}
+
+	run, conf = fakeGuard(t, old)
+	err := Rotate(ctx, run, g, "[Interface]\nBROKEN\n", opts)
+	if err == nil || !strings.Contains(err.Error(), "previous config was restored") || !strings.Contains(err.Error(), "invalid config") {
+		t.Errorf("Rotate() of a config wg-quick refuses: error = %v", err)
+	}
+	if got := readConf(t, conf); got != old {
+		t.Errorf("config after failed restart = %q, want the previous one", got)
+	}
+
+	run, conf = fakeGuard(t, old)
+	err = Rotate(ctx, run, g, "[Interface]\n# SILENT\n", opts)
+	if err == nil || !strings.Contains(err.Error(), "previous config restored") {
+		t.Errorf("Rotate() without a handshake: error = %v", err)
+	}
+	if got := readConf(t, conf); got != old {
+		t.Errorf("config after failed handshake = %q, want the previous one", got)
+	}
+}
+
+func TestRotateClientHandshake(t *testing.T) {
+	server, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	client, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	r := &testResponder{static: server, clients: map[string]bool{string(client.PublicKey().Bytes()): true}}
+	endpoint := serveResponder(t, r)
+
+	// The guard reports no handshake of its own, so only the client's counts
+	run, conf := fakeGuard(t, "[Interface]\n")
+	next := "[Interface]\n# SILENT\n"
+	opts := RotateOptions{
+		Client:  &ClientConfig{PrivateKey: client.Bytes(), PeerPublicKey: server.PublicKey().Bytes(), Endpoint: endpoint},
+		Timeout: time.Second,
+	}
+	if err := Rotate(context.Background(), run, &Guard{ID: "guard-1"}, next, opts); err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if got := readConf(t, conf); got != next {
+		t.Errorf("config = %q, want %q", got, next)
+	}
+}
+
+func TestInterfacePublicKey(t *testing.T) {
+	priv, pub, err := wireguard.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := "[Interface]\nPrivateKey = " + priv + "\n\n[Peer]\nPublicKey = " + base64.StdEncoding.EncodeToString(make([]byte, 32)) + "\n"
+	if got := InterfacePublicKey(conf); got != pub {
+		t.Errorf("InterfacePublicKey() = %q, want %q", got, pub)
+	}
+	if got := InterfacePublicKey(RedactPrivateKey(conf)); got != "" {
+		t.Errorf("InterfacePublicKey() of a redacted config = %q, want none", got)
+	}
+}
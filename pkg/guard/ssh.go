@@ -0,0 +1,74 @@
+package guard
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+)
+
+// AnySource is the SSH source of a guard open to all addresses, the
+// default without SSH sources
+const AnySource = "*"
+
+// SSHAccess is implemented by guard providers that can change which
+// addresses may connect to a guard with SSH after it was created
+type SSHAccess interface {
+	// SSHSources returns the source prefixes of a guard's SSH rule
+	SSHSources(ctx context.Context, g *Guard) ([]string, error)
+
+	// SetSSHSources replaces the source prefixes of a guard's SSH rule
+	SetSSHSources(ctx context.Context, g *Guard, sources []string) error
+}
+
+// ParseSSHSource returns an SSH source as an IPv4 CIDR, an address being
+// a /32, or AnySource for * and 0.0.0.0/0. Guards have IPv4 public IPs
+// only, so IPv6 sources are an error.
+func ParseSSHSource(s string) (string, error) {
+	if s == AnySource {
+		return AnySource, nil
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		addr, addrErr := netip.ParseAddr(s)
+		if addrErr != nil {
+			return "", fmt.Errorf("invalid SSH source %q: not an IP address or CIDR", s)
+		}
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+	if !prefix.Addr().Is4() {
+		return "", fmt.Errorf("invalid SSH source %q: guards are reached over IPv4", s)
+	}
+	if prefix.Bits() == 0 {
+		return AnySource, nil
+	}
+	return prefix.Masked().String(), nil
+}
+
+// ParseSSHSources parses SSH sources with ParseSSHSource, dropping
+// duplicates; with AnySource, the others are redundant
+func ParseSSHSources(sources []string) ([]string, error) {
+	var parsed []string
+	for _, s := range sources {
+		p, err := ParseSSHSource(s)
+		if err != nil {
+			return nil, err
+		}
+		if p == AnySource {
+			return []string{AnySource}, nil
+		}
+		if !slices.Contains(parsed, p) {
+			parsed = append(parsed, p)
+		}
+	}
+	return parsed, nil
+}
+
+// SSHSourceCIDRs returns the CIDRs SSH sources allow, for clouds without
+// a wildcard: 0.0.0.0/0 for none or AnySource
+func SSHSourceCIDRs(sources []string) []string {
+	if len(sources) == 0 || slices.Contains(sources, AnySource) {
+		return []string{"0.0.0.0/0"}
+	}
+	return sources
+}
@@ -0,0 +1,40 @@
+package guard
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseSSHSource(t *testing.T) {
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{"203.0.113.7", "203.0.113.7/32", false},
+		{"203.0.113.7/24", "203.0.113.0/24", false},
+		{"*", AnySource, false},
+		{"0.0.0.0/0", AnySource, false},
+		{"2001:db8::1", "", true},
+		{"203.0.113.7/33", "", true},
+		{"office", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSSHSource(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSSHSource(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestParseSSHSources(t *testing.T) {
+	got, err := ParseSSHSources([]string{"203.0.113.7", "203.0.113.7/32", "198.51.100.0/24"})
+	if err != nil || !slices.Equal(got, []string{"203.0.113.7/32", "198.51.100.0/24"}) {
+		t.Errorf("ParseSSHSources() = %v, %v", got, err)
+	}
+	if got, _ := ParseSSHSources([]string{"203.0.113.7", "*"}); !slices.Equal(got, []string{AnySource}) {
+		t.Errorf("ParseSSHSources() with * = %v, want only *", got)
+	}
+	if got := SSHSourceCIDRs(nil); !slices.Equal(got, []string{"0.0.0.0/0"}) {
+		t.Errorf("SSHSourceCIDRs(nil) = %v", got)
+	}
+}
@@ -0,0 +1,48 @@
+package guard
+
+import "context"
+
+// SubnetRouter is implemented by guard providers that can route the mesh
+// CIDRs of every subnet of a peered network through a guard
+type SubnetRouter interface {
+	// RouteAllSubnets creates or updates the route table PeerNetwork
+	// creates for one subnet, and associates it with every subnet of the
+	// remote network that can have it. Subnets that fail don't stop the
+	// others: the report lists them all, with the errors joined.
+	RouteAllSubnets(ctx context.Context, req PeerRequest) (*SubnetRouteReport, error)
+}
+
+// What RouteAllSubnets did with a subnet
+const (
+	SubnetAssociated = "associated" // Given the route table
+	SubnetUnchanged  = "unchanged"  // Already had it
+	SubnetSkipped    = "skipped"    // Left alone, see Detail
+	SubnetFailed     = "failed"
+)
+
+// SubnetRoute is what RouteAllSubnets did with a subnet
+type SubnetRoute struct {
+	Name   string
+	ID     string
+	Action string
+	Detail string
+}
+
+// SubnetRouteReport is what RouteAllSubnets touched
+type SubnetRouteReport struct {
+	RouteTableID      string
+	RouteTableName    string
+	RouteTableExisted bool // Updated rather than created
+	Subnets           []SubnetRoute
+}
+
+// Count returns the number of subnets with an action
+func (r *SubnetRouteReport) Count(action string) int {
+	n := 0
+	for _, s := range r.Subnets {
+		if s.Action == action {
+			n++
+		}
+	}
+	return n
+}
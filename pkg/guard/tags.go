@@ -0,0 +1,96 @@
+package guard
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ReservedTags are the tags morpheus-azureguard sets on guard resources
+// itself; user tags cannot override them
+var ReservedTags = []string{
+	"managed-by",
+	"guard-id",
+	"guard-group",
+	"guard-role",
+	"mesh-cidrs",
+	"wg-port",
+	"wg-public-key",
+	"remote-vnet",
+	"nic-id",
+	"resource-group",
+	"availability-zone",
+}
+
+// IsReservedTag reports whether a tag is one of ReservedTags. Azure tag
+// names are case-insensitive, so the comparison is too.
+func IsReservedTag(key string) bool {
+	return slices.ContainsFunc(ReservedTags, func(r string) bool { return strings.EqualFold(r, key) })
+}
+
+// ParseTag parses a key=value tag, as Azure accepts them: names of up to
+// 512 characters without <>%&\?/, values of up to 256
+func ParseTag(s string) (string, string, error) {
+	key, value, ok := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	switch {
+	case !ok || key == "":
+		return "", "", fmt.Errorf("invalid tag %q: want key=value", s)
+	case len(key) > 512 || strings.ContainsAny(key, `<>%&\?/`):
+		return "", "", fmt.Errorf("invalid tag name %q: up to 512 characters, without <>%%&\\?/", key)
+	case len(value) > 256:
+		return "", "", fmt.Errorf("invalid value of tag %s: longer than 256 characters", key)
+	case IsReservedTag(key):
+		return "", "", fmt.Errorf("tag %s is set by morpheus-azureguard itself", key)
+	}
+	return key, value, nil
+}
+
+// ParseTags parses key=value tags with ParseTag; a key may appear once
+func ParseTags(tags []string) (map[string]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	parsed := make(map[string]string, len(tags))
+	for _, t := range tags {
+		key, value, err := ParseTag(t)
+		if err != nil {
+			return nil, err
+		}
+		for k := range parsed {
+			if strings.EqualFold(k, key) {
+				return nil, fmt.Errorf("tag %s given twice", key)
+			}
+		}
+		parsed[key] = value
+	}
+	return parsed, nil
+}
+
+// MatchTags reports whether a guard has all the tags of a filter. Names
+// are compared case-insensitively, values exactly.
+func MatchTags(g *Guard, filter map[string]string) bool {
+	for key, want := range filter {
+		found := false
+		for k, v := range g.Tags {
+			if strings.EqualFold(k, key) && v == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatTags returns tags as key=value, sorted by key
+func FormatTags(tags map[string]string) string {
+	var parts []string
+	for _, k := range slices.Sorted(maps.Keys(tags)) {
+		parts = append(parts, k+"="+tags[k])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,46 @@
+package guard
+
+import "testing"
+
+func TestParseTags(t *testing.T) {
+	tags, err := ParseTags([]string{"team=platform", "cost-center=CC 42", "empty="})
+	if err != nil {
+		t.Fatalf("ParseTags() error = %v", err)
+	}
+	if got := FormatTags(tags); got != "cost-center=CC 42, empty=, team=platform" {
+		t.Errorf("ParseTags() = %s", got)
+	}
+
+	for _, bad := range [][]string{
+		{"team"},
+		{"=platform"},
+		{"a/b=c"},
+		{"guard-id=x"},
+		{"Managed-By=x"},
+		{"team=a", "Team=b"},
+	} {
+		if _, err := ParseTags(bad); err == nil {
+			t.Errorf("ParseTags(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestMatchTags(t *testing.T) {
+	g := &Guard{Tags: map[string]string{"Team": "platform", "env": "prod"}}
+	tests := []struct {
+		filter map[string]string
+		want   bool
+	}{
+		{nil, true},
+		{map[string]string{"team": "platform"}, true},
+		{map[string]string{"team": "platform", "env": "prod"}, true},
+		{map[string]string{"team": "Platform"}, false},
+		{map[string]string{"team": "platform", "env": "dev"}, false},
+		{map[string]string{"cost-center": ""}, false},
+	}
+	for _, tt := range tests {
+		if got := MatchTags(g, tt.filter); got != tt.want {
+			t.Errorf("MatchTags(%v) = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
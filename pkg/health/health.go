@@ -0,0 +1,268 @@
+// Package health probes the nodes of a forest: whether they can be reached
+// over SSH, cloud-init finished, NATS accepts connections and disk space
+// and load are within limits.
+package health
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/nats"
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+// Names of the checks, in the order they are reported
+const (
+	CheckSSH       = "ssh"
+	CheckCloudInit = "cloud-init"
+	CheckDisk      = "disk"
+	CheckLoad      = "load"
+	CheckNATS      = "nats"
+)
+
+// Check is the result of one check on a node
+type Check struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// NodeHealth is the result of all checks on a node
+type NodeHealth struct {
+	Node   string  `json:"node"`
+	Addr   string  `json:"addr"`
+	Status Status  `json:"status"` // The worst status of its checks
+	Checks []Check `json:"checks"`
+}
+
+// Result returns the result of a check by name, or nil if it was not run
+func (n *NodeHealth) Result(name string) *Check {
+	for i := range n.Checks {
+		if n.Checks[i].Name == name {
+			return &n.Checks[i]
+		}
+	}
+	return nil
+}
+
+// Options configures Probe
+type Options struct {
+	sshutil.ExecOptions // SSH settings; output options are ignored
+
+	// ExpectNATS makes a closed NATS port a failure rather than a warning
+	ExpectNATS bool
+	// NATSPort is the port the NATS check connects to (default 4222)
+	NATSPort int
+
+	// Disk usage of / in percent at which the disk check warns and fails
+	// (default 80 and 90)
+	DiskWarn, DiskFail int
+	// 1-minute load per CPU at which the load check warns and fails
+	// (default 1.0 and 2.0)
+	LoadWarn, LoadFail float64
+}
+
+func (o *Options) defaults() {
+	if o.Timeout <= 0 {
+		o.Timeout = 30 * time.Second
+	}
+	if o.NATSPort == 0 {
+		o.NATSPort = nats.ClientPort
+	}
+	if o.DiskWarn == 0 {
+		o.DiskWarn = 80
+	}
+	if o.DiskFail == 0 {
+		o.DiskFail = 90
+	}
+	if o.LoadWarn == 0 {
+		o.LoadWarn = 1.0
+	}
+	if o.LoadFail == 0 {
+		o.LoadFail = 2.0
+	}
+}
+
+// probeScript prints the facts the checks are evaluated from as key=value
+// lines. It only reads, so it is safe to run at any time.
+const probeScript = `echo "cloud_init=$(cloud-init status 2>/dev/null | sed -n 's/^status: //p')"
+echo "disk=$(df -P / | awk 'NR==2 {sub("%", "", $5); print $5}')"
+echo "load=$(cut -d' ' -f1 /proc/loadavg)"
+echo "cpus=$(nproc)"`
+
+// Probe checks every target in parallel. Results are returned in target
+// order.
+func Probe(ctx context.Context, targets []sshutil.Target, opts Options) []NodeHealth {
+	opts.defaults()
+
+	// NATS is checked from here, while the SSH probes run
+	natsChecks := make([]Check, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			natsChecks[i] = checkNATS(ctx, addr, opts)
+		}(i, target.Addr)
+	}
+
+	var stdout bytes.Buffer
+	execOpts := opts.ExecOptions
+	execOpts.Stdout = &stdout
+	execOpts.Stderr = nil
+	execOpts.Stdin = nil
+	results := sshutil.RunParallel(ctx, targets, probeScript, execOpts)
+
+	// Output lines are prefixed with the target name
+	facts := map[string]map[string]string{}
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "[") {
+			continue
+		}
+		name, rest, ok := strings.Cut(line[1:], "] ")
+		if !ok {
+			continue
+		}
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			continue
+		}
+		if facts[name] == nil {
+			facts[name] = map[string]string{}
+		}
+		facts[name][key] = strings.TrimSpace(value)
+	}
+
+	wg.Wait()
+	nodes := make([]NodeHealth, len(targets))
+	for i, target := range targets {
+		node := NodeHealth{Node: target.Name, Addr: target.Addr}
+		if err := results[i].Err; err != nil {
+			node.Checks = []Check{{Name: CheckSSH, Status: StatusFail, Detail: err.Error()}}
+		} else {
+			node.Checks = append([]Check{{Name: CheckSSH, Status: StatusOK}}, evaluate(facts[target.Name], opts)...)
+		}
+		node.Checks = append(node.Checks, natsChecks[i])
+		for _, check := range node.Checks {
+			node.Status = worst(node.Status, check.Status)
+		}
+		nodes[i] = node
+	}
+	return nodes
+}
+
+// evaluate turns the facts a node reported into checks
+func evaluate(facts map[string]string, opts Options) []Check {
+	var checks []Check
+
+	cloudInit := Check{Name: CheckCloudInit, Detail: facts["cloud_init"]}
+	switch facts["cloud_init"] {
+	case "done":
+		cloudInit.Status = StatusOK
+	case "error", "degraded":
+		cloudInit.Status = StatusFail
+	case "":
+		cloudInit.Status, cloudInit.Detail = StatusWarn, "unknown"
+	default: // running, not started, disabled
+		cloudInit.Status = StatusWarn
+	}
+	checks = append(checks, cloudInit)
+
+	disk := Check{Name: CheckDisk, Status: StatusWarn, Detail: "unknown"}
+	if used, err := strconv.Atoi(facts["disk"]); err == nil {
+		disk.Detail = fmt.Sprintf("%d%% used", used)
+		switch {
+		case used >= opts.DiskFail:
+			disk.Status = StatusFail
+		case used >= opts.DiskWarn:
+			disk.Status = StatusWarn
+		default:
+			disk.Status = StatusOK
+		}
+	}
+	checks = append(checks, disk)
+
+	load := Check{Name: CheckLoad, Status: StatusWarn, Detail: "unknown"}
+	load1, loadErr := strconv.ParseFloat(facts["load"], 64)
+	cpus, cpusErr := strconv.Atoi(facts["cpus"])
+	if loadErr == nil && cpusErr == nil && cpus > 0 {
+		load.Detail = fmt.Sprintf("%.2f on %d CPU%s", load1, cpus, plural(cpus))
+		switch perCPU := load1 / float64(cpus); {
+		case perCPU >= opts.LoadFail:
+			load.Status = StatusFail
+		case perCPU >= opts.LoadWarn:
+			load.Status = StatusWarn
+		default:
+			load.Status = StatusOK
+		}
+	}
+	checks = append(checks, load)
+
+	return checks
+}
+
+// checkNATS connects to a node's NATS client port from here, which also
+// verifies the firewall lets clients in
+func checkNATS(ctx context.Context, addr string, opts Options) Check {
+	check := Check{Name: CheckNATS}
+	dialer := net.Dialer{Timeout: 5 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(addr, strconv.Itoa(opts.NATSPort)))
+	if err != nil {
+		check.Status, check.Detail = StatusWarn, fmt.Sprintf("port %d closed", opts.NATSPort)
+		if opts.ExpectNATS {
+			check.Status = StatusFail
+		}
+		return check
+	}
+	conn.Close()
+	check.Status, check.Detail = StatusOK, fmt.Sprintf("port %d open", opts.NATSPort)
+	return check
+}
+
+// Summarize returns the worst status of nodes
+func Summarize(nodes []NodeHealth) Status {
+	statuses := make([]Status, len(nodes))
+	for i, n := range nodes {
+		statuses[i] = n.Status
+	}
+	return worst(statuses...)
+}
+
+// worst returns the worst of statuses
+func worst(statuses ...Status) Status {
+	result := StatusOK
+	for _, status := range statuses {
+		switch status {
+		case StatusFail:
+			return StatusFail
+		case StatusWarn:
+			result = StatusWarn
+		}
+	}
+	return result
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
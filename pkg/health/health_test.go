@@ -0,0 +1,120 @@
+package health
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+)
+
+// fakeSSH returns an ssh client that answers the probe for 127.0.0.1 with
+// a healthy node, for 127.0.0.2 with a struggling one and fails to connect
+// to anything else
+func fakeSSH(t *testing.T) string {
+	t.Helper()
+	script := `#!/bin/sh
+for arg; do
+	case "$arg" in
+	root@127.0.0.1) printf 'cloud_init=done\ndisk=42\nload=0.50\ncpus=2\n'; exit 0;;
+	root@127.0.0.2) printf 'cloud_init=error\ndisk=95\nload=3.00\ncpus=1\n'; exit 0;;
+	esac
+done
+echo "ssh: connect to host: Connection refused" >&2
+exit 255
+`
+	path := filepath.Join(t.TempDir(), "ssh")
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestProbe(t *testing.T) {
+	// NATS only listens on the healthy node
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	targets := []sshutil.Target{
+		{Name: "f1-node-1", Addr: "127.0.0.1"},
+		{Name: "f1-node-2", Addr: "127.0.0.2"},
+		{Name: "f1-node-3", Addr: "127.0.0.3"},
+	}
+	nodes := Probe(context.Background(), targets, Options{
+		ExecOptions: sshutil.ExecOptions{SSHBinary: fakeSSH(t)},
+		ExpectNATS:  true,
+		NATSPort:    l.Addr().(*net.TCPAddr).Port,
+	})
+	if len(nodes) != 3 {
+		t.Fatalf("Probe() returned %d nodes, want 3", len(nodes))
+	}
+
+	healthy := nodes[0]
+	if healthy.Status != StatusOK {
+		t.Errorf("healthy node status = %s, checks %+v", healthy.Status, healthy.Checks)
+	}
+	if c := healthy.Result(CheckLoad); c == nil || c.Detail != "0.50 on 2 CPUs" {
+		t.Errorf("load check = %+v", c)
+	}
+
+	struggling := nodes[1]
+	if struggling.Status != StatusFail {
+		t.Errorf("struggling node status = %s", struggling.Status)
+	}
+	for _, name := range []string{CheckCloudInit, CheckDisk, CheckLoad, CheckNATS} {
+		if c := struggling.Result(name); c == nil || c.Status != StatusFail {
+			t.Errorf("struggling node %s check = %+v, want fail", name, c)
+		}
+	}
+
+	unreachable := nodes[2]
+	if c := unreachable.Result(CheckSSH); c == nil || c.Status != StatusFail {
+		t.Errorf("unreachable node ssh check = %+v, want fail", c)
+	}
+	if unreachable.Result(CheckDisk) != nil {
+		t.Error("unreachable node has a disk check")
+	}
+
+	if got := Summarize(nodes); got != StatusFail {
+		t.Errorf("Summarize() = %s, want fail", got)
+	}
+	if got := Summarize(nodes[:1]); got != StatusOK {
+		t.Errorf("Summarize(healthy) = %s, want ok", got)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	opts := Options{}
+	opts.defaults()
+
+	tests := []struct {
+		facts map[string]string
+		check string
+		want  Status
+	}{
+		{map[string]string{"cloud_init": "running"}, CheckCloudInit, StatusWarn},
+		{map[string]string{}, CheckCloudInit, StatusWarn},
+		{map[string]string{"disk": "79"}, CheckDisk, StatusOK},
+		{map[string]string{"disk": "80"}, CheckDisk, StatusWarn},
+		{map[string]string{"disk": "90"}, CheckDisk, StatusFail},
+		{map[string]string{"load": "3.9", "cpus": "4"}, CheckLoad, StatusOK},
+		{map[string]string{"load": "4.0", "cpus": "4"}, CheckLoad, StatusWarn},
+		{map[string]string{"load": "1.0"}, CheckLoad, StatusWarn},
+	}
+	for _, tt := range tests {
+		node := NodeHealth{Checks: evaluate(tt.facts, opts)}
+		if c := node.Result(tt.check); c == nil || c.Status != tt.want {
+			t.Errorf("evaluate(%v) %s check = %+v, want %s", tt.facts, tt.check, c, tt.want)
+		}
+	}
+
+	// A closed NATS port only fails when NATS is expected
+	if c := checkNATS(context.Background(), "127.0.0.1", Options{NATSPort: 1}); c.Status != StatusWarn {
+		t.Errorf("checkNATS() without ExpectNATS = %+v, want warn", c)
+	}
+}
@@ -0,0 +1,189 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+)
+
+// Names of the checks only Wait runs
+const (
+	CheckRunning = "running"
+	CheckDNS     = "dns"
+)
+
+// Levels of readiness Wait can wait for
+const (
+	// ForRunning waits until the provider reports every server running
+	ForRunning = "running"
+	// ForReady also waits for SSH, cloud-init, DNS and, if expected, NATS
+	ForReady = "ready"
+)
+
+// WaitOptions configures Wait
+type WaitOptions struct {
+	Options
+
+	// For is ForRunning or ForReady (default)
+	For string
+	// Interval is the time between rounds of checks (default 10s)
+	Interval time.Duration
+
+	// ServerState returns the provider state of a node's server; nil skips
+	// the running check
+	ServerState func(ctx context.Context, node string) (machine.ServerState, error)
+
+	// Domain, if set, is where nodes must resolve as <node>.<domain> to
+	// their address
+	Domain string
+	// Resolver looks up DNS names (default net.DefaultResolver)
+	Resolver *net.Resolver
+
+	// Progress, if set, is called with the results of each round
+	Progress func(nodes []NodeHealth)
+}
+
+func (o *WaitOptions) defaults() {
+	o.Options.defaults()
+	if o.For == "" {
+		o.For = ForReady
+	}
+	if o.Interval <= 0 {
+		o.Interval = 10 * time.Second
+	}
+	if o.Resolver == nil {
+		o.Resolver = net.DefaultResolver
+	}
+}
+
+// Wait checks targets every interval until all of them are ready or ctx is
+// done. A node is ready once each of its checks is ok; a warning means it is
+// still coming up and a failure means it never will, so Wait gives up. It
+// returns the results of the last round.
+func Wait(ctx context.Context, targets []sshutil.Target, opts WaitOptions) ([]NodeHealth, error) {
+	opts.defaults()
+	if opts.For != ForRunning && opts.For != ForReady {
+		return nil, fmt.Errorf("unknown readiness %q (use %s or %s)", opts.For, ForRunning, ForReady)
+	}
+
+	for {
+		nodes := Readiness(ctx, targets, opts)
+		if opts.Progress != nil {
+			opts.Progress(nodes)
+		}
+		switch Summarize(nodes) {
+		case StatusOK:
+			return nodes, nil
+		case StatusFail:
+			for _, node := range nodes {
+				for _, check := range node.Checks {
+					if check.Status == StatusFail {
+						return nodes, fmt.Errorf("%s: %s %s", node.Node, check.Name, check.Detail)
+					}
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nodes, ctx.Err()
+		case <-time.After(opts.Interval):
+		}
+	}
+}
+
+// Readiness runs one round of the checks Wait runs
+func Readiness(ctx context.Context, targets []sshutil.Target, opts WaitOptions) []NodeHealth {
+	opts.defaults()
+	nodes := make([]NodeHealth, len(targets))
+	for i, target := range targets {
+		nodes[i] = NodeHealth{Node: target.Name, Addr: target.Addr}
+		if opts.ServerState != nil {
+			nodes[i].Checks = append(nodes[i].Checks, checkRunning(ctx, target.Name, opts))
+		}
+	}
+
+	if opts.For == ForReady {
+		probed := Probe(ctx, targets, opts.Options)
+		for i, target := range targets {
+			nodes[i].Checks = append(nodes[i].Checks, readyChecks(probed[i], opts)...)
+			if opts.Domain != "" {
+				nodes[i].Checks = append(nodes[i].Checks, checkDNS(ctx, target, opts))
+			}
+		}
+	}
+
+	for i := range nodes {
+		for _, check := range nodes[i].Checks {
+			nodes[i].Status = worst(nodes[i].Status, check.Status)
+		}
+	}
+	return nodes
+}
+
+// readyChecks picks the probe results that say whether a node is ready.
+// Disk and load say how it is doing, not whether it is up, so they are left
+// out. Anything not ok yet may still come right, except cloud-init failing.
+func readyChecks(probed NodeHealth, opts WaitOptions) []Check {
+	var checks []Check
+	for _, check := range probed.Checks {
+		switch check.Name {
+		case CheckSSH:
+			if check.Status != StatusOK {
+				check.Status, check.Detail = StatusWarn, "unreachable"
+			}
+		case CheckCloudInit:
+		case CheckNATS:
+			if !opts.ExpectNATS {
+				continue
+			}
+			if check.Status != StatusOK {
+				check.Status = StatusWarn
+			}
+		default:
+			continue
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// checkRunning asks the provider whether a node's server is running
+func checkRunning(ctx context.Context, node string, opts WaitOptions) Check {
+	check := Check{Name: CheckRunning}
+	state, err := opts.ServerState(ctx, node)
+	switch {
+	case err != nil:
+		check.Status, check.Detail = StatusWarn, err.Error()
+	case state == machine.ServerStateRunning:
+		check.Status = StatusOK
+	case state == machine.ServerStateDeleting:
+		check.Status, check.Detail = StatusFail, string(state)
+	default:
+		check.Status, check.Detail = StatusWarn, string(state)
+	}
+	return check
+}
+
+// checkDNS resolves a node's name and checks it points at the node
+func checkDNS(ctx context.Context, target sshutil.Target, opts WaitOptions) Check {
+	name := target.Name + "." + opts.Domain
+	check := Check{Name: CheckDNS, Status: StatusWarn, Detail: name + " not resolving"}
+	addrs, err := opts.Resolver.LookupIPAddr(ctx, name)
+	if err != nil {
+		return check
+	}
+	want := net.ParseIP(target.Addr)
+	for _, addr := range addrs {
+		if addr.IP.Equal(want) {
+			check.Status, check.Detail = StatusOK, name
+			return check
+		}
+	}
+	check.Detail = fmt.Sprintf("%s not resolving to %s yet", name, target.Addr)
+	return check
+}
@@ -0,0 +1,65 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+)
+
+func TestWait(t *testing.T) {
+	ssh := fakeSSH(t)
+
+	// The server only runs from the second round on
+	rounds := 0
+	opts := WaitOptions{
+		Options:  Options{ExecOptions: sshutil.ExecOptions{SSHBinary: ssh}, NATSPort: 1},
+		Interval: 10 * time.Millisecond,
+		ServerState: func(ctx context.Context, node string) (machine.ServerState, error) {
+			if rounds == 0 {
+				return machine.ServerStateStarting, nil
+			}
+			return machine.ServerStateRunning, nil
+		},
+		Progress: func(nodes []NodeHealth) { rounds++ },
+	}
+	healthy := []sshutil.Target{{Name: "f1-node-1", Addr: "127.0.0.1"}}
+	nodes, err := Wait(context.Background(), healthy, opts)
+	if err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if rounds != 2 {
+		t.Errorf("Wait() took %d rounds, want 2", rounds)
+	}
+	// NATS is not expected and disk and load do not decide readiness
+	if nodes[0].Result(CheckNATS) != nil || nodes[0].Result(CheckDisk) != nil {
+		t.Errorf("Wait() checks = %+v", nodes[0].Checks)
+	}
+
+	// Failed cloud-init is not waited out
+	opts.ServerState = nil
+	struggling := []sshutil.Target{{Name: "f1-node-2", Addr: "127.0.0.2"}}
+	if _, err := Wait(context.Background(), struggling, opts); err == nil || !strings.Contains(err.Error(), "cloud-init") {
+		t.Errorf("Wait() with failed cloud-init error = %v", err)
+	}
+
+	// Unreachable nodes are waited for until the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	unreachable := []sshutil.Target{{Name: "f1-node-3", Addr: "127.0.0.3"}}
+	nodes, err = Wait(ctx, unreachable, opts)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait() with unreachable node error = %v, want deadline exceeded", err)
+	}
+	if c := nodes[0].Result(CheckSSH); c == nil || c.Status != StatusWarn {
+		t.Errorf("unreachable node ssh check = %+v, want warn", c)
+	}
+
+	if _, err := Wait(context.Background(), healthy, WaitOptions{For: "done"}); err == nil {
+		t.Error("Wait() accepted an unknown readiness")
+	}
+}
@@ -0,0 +1,242 @@
+// Package lockfile provides simple cross-process locks backed by files.
+//
+// A lock is a file created with O_EXCL that records who holds it (PID, host,
+// operation). Locks older than their TTL, or held by a process that no longer
+// exists on this host, are considered stale and are taken over automatically.
+// A lock file that cannot be read may be one whose holder has created it but
+// not yet written to it, so it is only taken over once its modification time
+// is older than the TTL.
+package lockfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTTL is how long a lock is honoured before it is considered stale.
+const DefaultTTL = 30 * time.Minute
+
+// Info describes the holder of a lock.
+type Info struct {
+	PID        int       `json:"pid"`
+	Host       string    `json:"host"`
+	Operation  string    `json:"operation,omitempty"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// LockedError is returned when a lock is held by another process.
+type LockedError struct {
+	Path string
+	Info Info
+}
+
+func (e *LockedError) Error() string {
+	if e.Info.PID == 0 {
+		return fmt.Sprintf("locked since %s (holder unknown)", e.Info.AcquiredAt.Format(time.RFC3339))
+	}
+	msg := fmt.Sprintf("locked by PID %d on %s since %s", e.Info.PID, e.Info.Host, e.Info.AcquiredAt.Format(time.RFC3339))
+	if e.Info.Operation != "" {
+		msg += fmt.Sprintf(" (%s)", e.Info.Operation)
+	}
+	return msg
+}
+
+// IsLocked reports whether err is (or wraps) a LockedError.
+func IsLocked(err error) bool {
+	var le *LockedError
+	return errors.As(err, &le)
+}
+
+// Lock is a held file lock.
+type Lock struct {
+	path string
+	info Info
+}
+
+// Acquire creates the lock file at path. If the file already exists and is
+// not stale, a *LockedError is returned.
+func Acquire(path, operation string, ttl time.Duration) (*Lock, error) {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	host, _ := os.Hostname()
+	now := time.Now()
+	info := Info{
+		PID:        os.Getpid(),
+		Host:       host,
+		Operation:  operation,
+		AcquiredAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	// A stale lock is moved out of the way before creating a new one, so
+	// allow an attempt for that and one after another process did
+	for attempt := 0; attempt < 3; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, werr := f.Write(data)
+			cerr := f.Close()
+			if werr != nil || cerr != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("failed to write lock file: %v", errors.Join(werr, cerr))
+			}
+			return &Lock{path: path, info: info}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		st, statErr := os.Stat(path)
+		if statErr != nil {
+			// Released in the meantime
+			continue
+		}
+		holder, readErr := Read(path)
+		if readErr != nil {
+			if os.IsNotExist(readErr) {
+				continue
+			}
+			// The holder may not have written the file yet: only an
+			// unreadable lock older than the TTL is taken over
+			if now.Sub(st.ModTime()) < ttl {
+				return nil, &LockedError{Path: path, Info: Info{AcquiredAt: st.ModTime(), ExpiresAt: st.ModTime().Add(ttl)}}
+			}
+			holder = nil
+		}
+		if holder != nil && !isStale(holder, host) {
+			return nil, &LockedError{Path: path, Info: *holder}
+		}
+
+		if beforeTakeOver != nil {
+			beforeTakeOver()
+		}
+		if err := takeOver(path, st, holder); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("failed to acquire lock %s", path)
+}
+
+// beforeTakeOver is called between judging a lock stale and taking it
+// over, for tests to interleave acquirers
+var beforeTakeOver func()
+
+// takeOverSeq tells apart the names stale locks of this process are moved
+// to
+var takeOverSeq atomic.Uint64
+
+// takeOver removes the stale lock at path, which was st when judged stale
+// with holder (nil if it was unreadable). Another process may have taken
+// over the lock since, so the file is first renamed, which only one process
+// can do, and only removed if it is still the lock judged stale; a live lock
+// is put back and reported as held. A lock gone in the meantime is not an
+// error: the caller tries to create the lock again.
+func takeOver(path string, st os.FileInfo, holder *Info) error {
+	moved := fmt.Sprintf("%s.stale-%d-%d-%d", path, os.Getpid(), time.Now().UnixNano(), takeOverSeq.Add(1))
+	if err := os.Rename(path, moved); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to remove stale lock: %w", err)
+	}
+
+	same := false
+	if movedSt, err := os.Stat(moved); err == nil && os.SameFile(st, movedSt) {
+		current, readErr := Read(moved)
+		if holder == nil {
+			same = readErr != nil
+		} else {
+			same = readErr == nil && current.PID == holder.PID && current.Host == holder.Host &&
+				current.AcquiredAt.Equal(holder.AcquiredAt)
+		}
+	}
+	if same {
+		if err := os.Remove(moved); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale lock: %w", err)
+		}
+		return nil
+	}
+
+	// Another process took over the lock first: put its lock back without
+	// replacing one created since. Without hard links (some network file
+	// systems), renaming it back is the best there is.
+	live, _ := Read(moved)
+	if err := os.Link(moved, path); err != nil && !os.IsExist(err) {
+		os.Rename(moved, path)
+	}
+	os.Remove(moved)
+	lockedErr := &LockedError{Path: path}
+	if live != nil {
+		lockedErr.Info = *live
+	}
+	return lockedErr
+}
+
+// Read returns the holder information stored in a lock file.
+func Read(path string) (*Info, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("invalid lock file: %w", err)
+	}
+	return &info, nil
+}
+
+// Release removes the lock file if it is still owned by this lock.
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+	holder, err := Read(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if holder.PID != l.info.PID || !holder.AcquiredAt.Equal(l.info.AcquiredAt) {
+		// Someone else took over the lock after it went stale
+		return nil
+	}
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Path returns the lock file path.
+func (l *Lock) Path() string {
+	return l.path
+}
+
+// isStale reports whether a lock can be taken over.
+func isStale(info *Info, host string) bool {
+	if !info.ExpiresAt.IsZero() && time.Now().After(info.ExpiresAt) {
+		return true
+	}
+	// A dead process on this host cannot release its lock
+	if info.Host == host && info.PID > 0 && !processAlive(info.PID) {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,216 @@
+package lockfile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAcquireAndRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locks", "test.lock")
+
+	lock, err := Acquire(path, "plant", time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	info, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if info.PID != os.Getpid() {
+		t.Errorf("PID = %d, want %d", info.PID, os.Getpid())
+	}
+	if info.Operation != "plant" {
+		t.Errorf("Operation = %q, want %q", info.Operation, "plant")
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected lock file to be removed after Release()")
+	}
+}
+
+func TestAcquireWhileHeld(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	lock, err := Acquire(path, "scale", time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	defer lock.Release()
+
+	_, err = Acquire(path, "scale", time.Minute)
+	if err == nil {
+		t.Fatal("Expected second Acquire() to fail")
+	}
+	if !IsLocked(err) {
+		t.Errorf("Expected LockedError, got %T: %v", err, err)
+	}
+	if !strings.Contains(err.Error(), "locked by PID") {
+		t.Errorf("Error message %q should mention the holder PID", err.Error())
+	}
+}
+
+func TestAcquireExpiredLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+	host, _ := os.Hostname()
+
+	stale := Info{
+		PID:        os.Getpid(),
+		Host:       host,
+		AcquiredAt: time.Now().Add(-2 * time.Hour),
+		ExpiresAt:  time.Now().Add(-time.Hour),
+	}
+	data, _ := json.Marshal(stale)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := Acquire(path, "plant", time.Minute)
+	if err != nil {
+		t.Fatalf("Expected expired lock to be taken over, got %v", err)
+	}
+	lock.Release()
+}
+
+func TestAcquireDeadProcessLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+	host, _ := os.Hostname()
+
+	// PIDs near the upper limit are practically never in use
+	dead := Info{
+		PID:        4194000,
+		Host:       host,
+		AcquiredAt: time.Now(),
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}
+	data, _ := json.Marshal(dead)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := Acquire(path, "plant", time.Minute)
+	if err != nil {
+		t.Fatalf("Expected lock of dead process to be taken over, got %v", err)
+	}
+	lock.Release()
+}
+
+func TestReleaseDoesNotRemoveForeignLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	lock, err := Acquire(path, "plant", time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	// Simulate another process taking over the lock
+	other := Info{PID: os.Getpid() + 1, Host: "other-host", AcquiredAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+	data, _ := json.Marshal(other)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Error("Release() must not remove a lock held by someone else")
+	}
+}
+
+func TestAcquireUnwrittenLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	// A holder that has created the file but not written it yet
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Acquire(path, "registry write", time.Minute)
+	if !IsLocked(err) {
+		t.Fatalf("Expected LockedError for a fresh empty lock, got %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected the fresh empty lock to be kept: %v", err)
+	}
+
+	// Left behind for longer than the TTL, it is taken over
+	old := time.Now().Add(-2 * time.Minute)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := Acquire(path, "registry write", time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	lock.Release()
+}
+
+func TestAcquireStaleLockRace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+	host, _ := os.Hostname()
+	stale := Info{
+		PID:        os.Getpid(),
+		Host:       host,
+		AcquiredAt: time.Now().Add(-2 * time.Hour),
+		ExpiresAt:  time.Now().Add(-time.Hour),
+	}
+	data, _ := json.Marshal(stale)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first acquirer judges the lock stale, then waits while a second
+	// one takes it over
+	judged := make(chan struct{})
+	resume := make(chan struct{})
+	var paused atomic.Bool
+	beforeTakeOver = func() {
+		if paused.CompareAndSwap(false, true) {
+			close(judged)
+			<-resume
+		}
+	}
+	defer func() { beforeTakeOver = nil }()
+
+	type result struct {
+		lock *Lock
+		err  error
+	}
+	first := make(chan result)
+	go func() {
+		lock, err := Acquire(path, "plant", time.Minute)
+		first <- result{lock, err}
+	}()
+	<-judged
+
+	second, err := Acquire(path, "teardown", time.Minute)
+	if err != nil {
+		t.Fatalf("Expected the second acquirer to take over the stale lock, got %v", err)
+	}
+	close(resume)
+	r := <-first
+	if r.err == nil {
+		r.lock.Release()
+		t.Fatal("Expected the first acquirer to back off, both hold the lock")
+	}
+	if !IsLocked(r.err) {
+		t.Fatalf("Expected LockedError, got %v", r.err)
+	}
+
+	holder, err := Read(path)
+	if err != nil || holder.Operation != "teardown" {
+		t.Fatalf("Expected the second acquirer's lock to be kept, got %+v (%v)", holder, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("Expected only the lock file to be left, got %d files", len(entries))
+	}
+	second.Release()
+}
@@ -0,0 +1,15 @@
+package lockfile
+
+import "os"
+
+// processAlive checks whether a process with the given PID exists. On
+// Windows, finding a process opens a handle to it, which fails if it is
+// gone.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -0,0 +1,273 @@
+package hetzner
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+// ListCosts returns all servers, volumes, primary and floating IPs and load
+// balancers in the project, priced with the current Hetzner price list
+// (net, monthly). IPs assigned to a server carry that server's labels so
+// they can be attributed to its forest.
+func (p *Provider) ListCosts(ctx context.Context) ([]machine.CostItem, error) {
+	pricing, _, err := p.client.Pricing.Get(ctx)
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to get pricing")
+	}
+
+	servers, err := p.client.Server.All(ctx)
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to list servers")
+	}
+
+	var items []machine.CostItem
+	serverLabels := make(map[int64]map[string]string)
+	for _, s := range servers {
+		serverLabels[s.ID] = s.Labels
+		location := ""
+		if s.Datacenter != nil && s.Datacenter.Location != nil {
+			location = s.Datacenter.Location.Name
+		}
+		cost := 0.0
+		if s.ServerType != nil {
+			for _, price := range s.ServerType.Pricings {
+				if price.Location != nil && price.Location.Name == location {
+					cost = parsePrice(price.Monthly.Net)
+				}
+			}
+		}
+		items = append(items, machine.CostItem{
+			Kind:        "server",
+			ID:          fmt.Sprintf("%d", s.ID),
+			Name:        s.Name,
+			Labels:      s.Labels,
+			MonthlyCost: cost,
+			Attached:    true,
+		})
+	}
+
+	volumes, err := p.client.Volume.All(ctx)
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to list volumes")
+	}
+	perGB := parsePrice(pricing.Volume.PerGBMonthly.Net)
+	for _, v := range volumes {
+		items = append(items, machine.CostItem{
+			Kind:        "volume",
+			ID:          fmt.Sprintf("%d", v.ID),
+			Name:        v.Name,
+			Labels:      v.Labels,
+			MonthlyCost: float64(v.Size) * perGB,
+			Attached:    v.Server != nil,
+		})
+	}
+
+	primaryIPs, err := p.client.PrimaryIP.All(ctx)
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to list primary IPs")
+	}
+	for _, ip := range primaryIPs {
+		location := ""
+		if ip.Datacenter != nil && ip.Datacenter.Location != nil {
+			location = ip.Datacenter.Location.Name
+		}
+		cost := 0.0
+		for _, typePricing := range pricing.PrimaryIPs {
+			if typePricing.Type != string(ip.Type) {
+				continue
+			}
+			for _, price := range typePricing.Pricings {
+				if price.Location == location {
+					cost = parsePrice(price.Monthly.Net)
+				}
+			}
+		}
+		item := machine.CostItem{
+			Kind:        "primary_ip",
+			ID:          fmt.Sprintf("%d", ip.ID),
+			Name:        ip.IP.String(),
+			Labels:      ip.Labels,
+			MonthlyCost: cost,
+			Attached:    ip.AssigneeID != 0,
+		}
+		if labels, ok := serverLabels[ip.AssigneeID]; ok && len(item.Labels) == 0 {
+			item.Labels = labels
+		}
+		items = append(items, item)
+	}
+
+	floatingIPs, err := p.client.FloatingIP.All(ctx)
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to list floating IPs")
+	}
+	for _, ip := range floatingIPs {
+		cost := 0.0
+		for _, typePricing := range pricing.FloatingIPs {
+			if typePricing.Type != ip.Type {
+				continue
+			}
+			for _, price := range typePricing.Pricings {
+				if price.Location != nil && ip.HomeLocation != nil && price.Location.Name == ip.HomeLocation.Name {
+					cost = parsePrice(price.Monthly.Net)
+				}
+			}
+		}
+		item := machine.CostItem{
+			Kind:        "floating_ip",
+			ID:          fmt.Sprintf("%d", ip.ID),
+			Name:        ip.IP.String(),
+			Labels:      ip.Labels,
+			MonthlyCost: cost,
+			Attached:    ip.Server != nil,
+		}
+		if ip.Server != nil && len(item.Labels) == 0 {
+			item.Labels = serverLabels[ip.Server.ID]
+		}
+		items = append(items, item)
+	}
+
+	lbs, err := p.client.LoadBalancer.All(ctx)
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to list load balancers")
+	}
+	for _, lb := range lbs {
+		cost := 0.0
+		if lb.LoadBalancerType != nil && lb.Location != nil {
+			for _, typePricing := range pricing.LoadBalancerTypes {
+				if typePricing.LoadBalancerType == nil || typePricing.LoadBalancerType.Name != lb.LoadBalancerType.Name {
+					continue
+				}
+				for _, price := range typePricing.Pricings {
+					if price.Location != nil && price.Location.Name == lb.Location.Name {
+						cost = parsePrice(price.Monthly.Net)
+					}
+				}
+			}
+		}
+		items = append(items, machine.CostItem{
+			Kind:        "load_balancer",
+			ID:          fmt.Sprintf("%d", lb.ID),
+			Name:        lb.Name,
+			Labels:      lb.Labels,
+			MonthlyCost: cost,
+			Attached:    true,
+		})
+	}
+
+	return items, nil
+}
+
+// parsePrice parses a Hetzner price string such as "4.4900000000"
+func parsePrice(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+// EstimateCost prices the resources of a forest with the current Hetzner
+// price list (net). Without a location, the cheapest location offering the
+// server type is used.
+func (p *Provider) EstimateCost(ctx context.Context, req machine.CostEstimateRequest) (*machine.CostEstimate, error) {
+	pricing, _, err := p.client.Pricing.Get(ctx)
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to get pricing")
+	}
+
+	var server *hcloud.ServerTypeLocationPricing
+	for _, typePricing := range pricing.ServerTypes {
+		if typePricing.ServerType == nil || typePricing.ServerType.Name != req.ServerType {
+			continue
+		}
+		for i, price := range typePricing.Pricings {
+			if price.Location == nil || (req.Location != "" && price.Location.Name != req.Location) {
+				continue
+			}
+			if server == nil || parsePrice(price.Monthly.Net) < parsePrice(server.Monthly.Net) {
+				server = &typePricing.Pricings[i]
+			}
+		}
+	}
+	if server == nil {
+		if req.Location != "" {
+			return nil, fmt.Errorf("server type %s is not offered in %s", req.ServerType, req.Location)
+		}
+		return nil, fmt.Errorf("unknown server type: %s", req.ServerType)
+	}
+	location := server.Location.Name
+
+	est := &machine.CostEstimate{Location: location, Currency: server.Monthly.Currency}
+	est.Add("server "+req.ServerType, req.Nodes, parsePrice(server.Hourly.Net), parsePrice(server.Monthly.Net))
+
+	if req.IPv4 {
+		hourly, monthly, ok := primaryIPPrice(pricing, "ipv4", location)
+		if !ok {
+			return nil, fmt.Errorf("no IPv4 price for %s", location)
+		}
+		est.Add("primary IPv4", req.Nodes, hourly, monthly)
+	}
+	if req.VolumeSizeGB > 0 {
+		monthly := float64(req.VolumeSizeGB) * parsePrice(pricing.Volume.PerGBMonthly.Net)
+		est.Add(fmt.Sprintf("volume %d GB", req.VolumeSizeGB), req.Nodes, monthly/machine.HoursPerMonth, monthly)
+	}
+	if req.LoadBalancer != "" {
+		hourly, monthly, ok := loadBalancerPrice(pricing, req.LoadBalancer, location)
+		if !ok {
+			return nil, fmt.Errorf("load balancer type %s is not offered in %s", req.LoadBalancer, location)
+		}
+		est.Add("load balancer "+req.LoadBalancer, 1, hourly, monthly)
+	}
+	if req.FloatingIP != "" {
+		monthly, ok := floatingIPPrice(pricing, hcloud.FloatingIPType(req.FloatingIP), location)
+		if !ok {
+			return nil, fmt.Errorf("no %s floating IP price for %s", req.FloatingIP, location)
+		}
+		est.Add("floating "+strings.Replace(req.FloatingIP, "ip", "IP", 1), 1, monthly/machine.HoursPerMonth, monthly)
+	}
+	return est, nil
+}
+
+func primaryIPPrice(pricing hcloud.Pricing, ipType, location string) (float64, float64, bool) {
+	for _, typePricing := range pricing.PrimaryIPs {
+		if typePricing.Type != ipType {
+			continue
+		}
+		for _, price := range typePricing.Pricings {
+			if price.Location == location {
+				return parsePrice(price.Hourly.Net), parsePrice(price.Monthly.Net), true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+func loadBalancerPrice(pricing hcloud.Pricing, lbType, location string) (float64, float64, bool) {
+	for _, typePricing := range pricing.LoadBalancerTypes {
+		if typePricing.LoadBalancerType == nil || typePricing.LoadBalancerType.Name != lbType {
+			continue
+		}
+		for _, price := range typePricing.Pricings {
+			if price.Location != nil && price.Location.Name == location {
+				return parsePrice(price.Hourly.Net), parsePrice(price.Monthly.Net), true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+func floatingIPPrice(pricing hcloud.Pricing, ipType hcloud.FloatingIPType, location string) (float64, bool) {
+	for _, typePricing := range pricing.FloatingIPs {
+		if typePricing.Type != ipType {
+			continue
+		}
+		for _, price := range typePricing.Pricings {
+			if price.Location != nil && price.Location.Name == location {
+				return parsePrice(price.Monthly.Net), true
+			}
+		}
+	}
+	return 0, false
+}
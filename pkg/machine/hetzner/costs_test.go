@@ -0,0 +1,49 @@
+package hetzner
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+func TestEstimateCost(t *testing.T) {
+	p, _ := newTestProvider(t)
+	ctx := context.Background()
+
+	est, err := p.EstimateCost(ctx, machine.CostEstimateRequest{
+		Nodes:        3,
+		ServerType:   "cpx31",
+		Location:     "ash",
+		IPv4:         true,
+		VolumeSizeGB: 50,
+		LoadBalancer: "lb11",
+		FloatingIP:   "ipv4",
+	})
+	if err != nil {
+		t.Fatalf("EstimateCost() error = %v", err)
+	}
+	if est.Location != "ash" || est.Currency != "EUR" || len(est.Lines) != 5 {
+		t.Fatalf("EstimateCost() = %+v", est)
+	}
+	// 3 × (18.688 server + 0.50 IPv4 + 2.20 volume) + 5.39 + 3.00
+	if want := 3*(0.0256*730+0.50+50*0.044) + 5.39 + 3.00; math.Abs(est.Monthly-want) > 0.001 {
+		t.Errorf("monthly = %.4f, want %.4f", est.Monthly, want)
+	}
+	if want := 3*(0.0256+0.0008+50*0.044/730) + 0.0074 + 3.00/730; math.Abs(est.Hourly-want) > 0.0001 {
+		t.Errorf("hourly = %.4f, want %.4f", est.Hourly, want)
+	}
+
+	// Without a location, the cheapest one offering the server type
+	if est, err := p.EstimateCost(ctx, machine.CostEstimateRequest{Nodes: 1, ServerType: "cx22"}); err != nil || est.Location == "ash" {
+		t.Errorf("EstimateCost() without location = %+v, %v", est, err)
+	}
+
+	if _, err := p.EstimateCost(ctx, machine.CostEstimateRequest{Nodes: 1, ServerType: "cx22", Location: "ash"}); err == nil {
+		t.Error("EstimateCost() for a server type not offered in the location: expected error")
+	}
+	if _, err := p.EstimateCost(ctx, machine.CostEstimateRequest{Nodes: 1, ServerType: "cx99"}); err == nil {
+		t.Error("EstimateCost() for an unknown server type: expected error")
+	}
+}
@@ -0,0 +1,71 @@
+package hetzner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+// ListFirewalls lists all firewalls with optional label filters
+func (p *Provider) ListFirewalls(ctx context.Context, filters map[string]string) ([]*machine.Firewall, error) {
+	opts := hcloud.FirewallListOpts{}
+	if len(filters) > 0 {
+		opts.LabelSelector = formatLabelSelector(filters)
+	}
+
+	firewalls, err := p.client.Firewall.AllWithOpts(ctx, opts)
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to list firewalls")
+	}
+
+	result := make([]*machine.Firewall, len(firewalls))
+	for i, fw := range firewalls {
+		result[i] = convertFirewall(fw)
+	}
+	return result, nil
+}
+
+// DeleteFirewall removes a firewall. A firewall still applied to resources
+// cannot be deleted, so it is removed from them first.
+func (p *Provider) DeleteFirewall(ctx context.Context, firewallID string) error {
+	fw, _, err := p.client.Firewall.GetByID(ctx, parseServerID(firewallID))
+	if err != nil {
+		return wrapAuthError(err, "failed to get firewall")
+	}
+	if fw == nil {
+		return fmt.Errorf("firewall not found: %s", firewallID)
+	}
+
+	if len(fw.AppliedTo) > 0 {
+		actions, _, err := p.client.Firewall.RemoveResources(ctx, fw, fw.AppliedTo)
+		if err != nil {
+			return wrapAuthError(err, "failed to detach firewall")
+		}
+		for _, action := range actions {
+			if err := p.waitForAction(ctx, action); err != nil {
+				return fmt.Errorf("failed to detach firewall: %w", err)
+			}
+		}
+	}
+
+	if _, err := p.client.Firewall.Delete(ctx, fw); err != nil {
+		return wrapAuthError(err, "failed to delete firewall")
+	}
+	return nil
+}
+
+func convertFirewall(fw *hcloud.Firewall) *machine.Firewall {
+	result := &machine.Firewall{
+		ID:     fmt.Sprintf("%d", fw.ID),
+		Name:   fw.Name,
+		Labels: fw.Labels,
+	}
+	for _, r := range fw.AppliedTo {
+		if r.Type == hcloud.FirewallResourceTypeServer && r.Server != nil {
+			result.Servers = append(result.Servers, fmt.Sprintf("%d", r.Server.ID))
+		}
+	}
+	return result
+}
@@ -0,0 +1,126 @@
+package hetzner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+// CreateFloatingIP allocates a floating IP in the requested home location,
+// assigning it to a server right away if ServerID is set
+func (p *Provider) CreateFloatingIP(ctx context.Context, req machine.CreateFloatingIPRequest) (*machine.FloatingIP, error) {
+	opts := hcloud.FloatingIPCreateOpts{
+		Type:   hcloud.FloatingIPTypeIPv4,
+		Name:   hcloud.Ptr(req.Name),
+		Labels: req.Labels,
+	}
+	if req.Type == "ipv6" {
+		opts.Type = hcloud.FloatingIPTypeIPv6
+	}
+
+	if req.ServerID != "" {
+		opts.Server = &hcloud.Server{ID: parseServerID(req.ServerID)}
+	} else {
+		location, _, err := p.client.Location.GetByName(ctx, req.Location)
+		if err != nil {
+			return nil, wrapAuthError(err, "failed to get location")
+		}
+		if location == nil {
+			return nil, fmt.Errorf("location not found: %s", req.Location)
+		}
+		opts.HomeLocation = location
+	}
+
+	result, _, err := p.client.FloatingIP.Create(ctx, opts)
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to create floating IP")
+	}
+	if result.Action != nil {
+		if err := p.waitForAction(ctx, result.Action); err != nil {
+			return nil, fmt.Errorf("failed to assign floating IP: %w", err)
+		}
+	}
+
+	return convertFloatingIP(result.FloatingIP), nil
+}
+
+// ListFloatingIPs lists all floating IPs with optional label filters
+func (p *Provider) ListFloatingIPs(ctx context.Context, filters map[string]string) ([]*machine.FloatingIP, error) {
+	opts := hcloud.FloatingIPListOpts{}
+	if len(filters) > 0 {
+		opts.LabelSelector = formatLabelSelector(filters)
+	}
+
+	ips, err := p.client.FloatingIP.AllWithOpts(ctx, opts)
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to list floating IPs")
+	}
+
+	result := make([]*machine.FloatingIP, len(ips))
+	for i, ip := range ips {
+		result[i] = convertFloatingIP(ip)
+	}
+	return result, nil
+}
+
+// AssignFloatingIP routes a floating IP to a server and waits for it to apply
+func (p *Provider) AssignFloatingIP(ctx context.Context, floatingIPID, serverID string) error {
+	ip := &hcloud.FloatingIP{ID: parseServerID(floatingIPID)}
+	server := &hcloud.Server{ID: parseServerID(serverID)}
+
+	action, _, err := p.client.FloatingIP.Assign(ctx, ip, server)
+	if err != nil {
+		return wrapAuthError(err, "failed to assign floating IP")
+	}
+	if err := p.waitForAction(ctx, action); err != nil {
+		return fmt.Errorf("failed to assign floating IP: %w", err)
+	}
+	return nil
+}
+
+// DeleteFloatingIP releases a floating IP
+func (p *Provider) DeleteFloatingIP(ctx context.Context, floatingIPID string) error {
+	ip, _, err := p.client.FloatingIP.GetByID(ctx, parseServerID(floatingIPID))
+	if err != nil {
+		return wrapAuthError(err, "failed to get floating IP")
+	}
+	if ip == nil {
+		return fmt.Errorf("floating IP not found: %s", floatingIPID)
+	}
+
+	if _, err := p.client.FloatingIP.Delete(ctx, ip); err != nil {
+		return wrapAuthError(err, "failed to delete floating IP")
+	}
+	return nil
+}
+
+// waitForAction waits until an action has finished
+func (p *Provider) waitForAction(ctx context.Context, action *hcloud.Action) error {
+	_, errCh := p.client.Action.WatchProgress(ctx, action)
+	return <-errCh
+}
+
+func convertFloatingIP(ip *hcloud.FloatingIP) *machine.FloatingIP {
+	result := &machine.FloatingIP{
+		ID:     fmt.Sprintf("%d", ip.ID),
+		Name:   ip.Name,
+		Type:   string(ip.Type),
+		Labels: ip.Labels,
+	}
+	if ip.IP != nil {
+		result.IP = ip.IP.String()
+		// IPv6 floating IPs are a /64; servers use its first address
+		if ip.Type == hcloud.FloatingIPTypeIPv6 {
+			result.IP = machine.HostIPv6(result.IP)
+		}
+	}
+	if ip.HomeLocation != nil {
+		result.Location = ip.HomeLocation.Name
+	}
+	if ip.Server != nil {
+		result.ServerID = fmt.Sprintf("%d", ip.Server.ID)
+	}
+	return result
+}
@@ -15,10 +15,18 @@ import (
 // Provider implements the Provider interface for Hetzner Cloud
 type Provider struct {
 	client *hcloud.Client
+	retry  *retryTransport
 }
 
-// NewProvider creates a new Hetzner Cloud provider
+// NewProvider creates a new Hetzner Cloud provider. HCLOUD_ENDPOINT, if set,
+// replaces the API endpoint, e.g. to point it at a mock.
 func NewProvider(apiToken string) (*Provider, error) {
+	return NewProviderWithEndpoint(apiToken, os.Getenv("HCLOUD_ENDPOINT"))
+}
+
+// NewProviderWithEndpoint creates a Hetzner Cloud provider that talks to the
+// API at endpoint, or the public API if it is empty
+func NewProviderWithEndpoint(apiToken, endpoint string) (*Provider, error) {
 	// Sanitize the token by removing any invalid characters
 	apiToken = sanitizeAPIToken(apiToken)
 
@@ -34,14 +42,20 @@ func NewProvider(apiToken string) (*Provider, error) {
 	// Create HTTP client with proper TLS configuration and DNS resolver
 	// This is essential for environments like Termux where default DNS may not work
 	httpClient := httputil.CreateHTTPClient(30 * time.Second)
+	retry := newRetryTransport(httpClient)
 
-	client := hcloud.NewClient(
+	opts := []hcloud.ClientOption{
 		hcloud.WithToken(apiToken),
 		hcloud.WithHTTPClient(httpClient),
-	)
+	}
+	if endpoint != "" {
+		opts = append(opts, hcloud.WithEndpoint(strings.TrimSuffix(endpoint, "/")))
+	}
+	client := hcloud.NewClient(opts...)
 
 	return &Provider{
 		client: client,
+		retry:  retry,
 	}, nil
 }
 
@@ -150,8 +164,8 @@ func (p *Provider) CreateServer(ctx context.Context, req machine.CreateServerReq
 		return nil, fmt.Errorf("server type not found: %s", req.ServerType)
 	}
 
-	// Resolve image
-	image, _, err := p.client.Image.GetByName(ctx, req.Image)
+	// Resolve image; snapshots have no name and are referenced by ID
+	image, _, err := p.client.Image.GetForArchitecture(ctx, req.Image, serverType.Architecture)
 	if err != nil {
 		return nil, wrapAuthError(err, "failed to get image")
 	}
@@ -178,6 +192,28 @@ func (p *Provider) CreateServer(ctx context.Context, req machine.CreateServerReq
 		sshKeys = append(sshKeys, key)
 	}
 
+	// Resolve volumes to attach; morpheus mounts them via cloud-init
+	var volumes []*hcloud.Volume
+	for _, volumeID := range req.Volumes {
+		volume, _, err := p.client.Volume.GetByID(ctx, parseServerID(volumeID))
+		if err != nil {
+			return nil, wrapAuthError(err, "failed to get volume")
+		}
+		if volume == nil {
+			return nil, fmt.Errorf("volume not found: %s", volumeID)
+		}
+		volumes = append(volumes, volume)
+	}
+
+	// Resolve the placement group; a full group leaves the server unplaced
+	var placementGroup *hcloud.PlacementGroup
+	if req.PlacementGroup != "" {
+		placementGroup, err = p.resolvePlacementGroup(ctx, req.PlacementGroup)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Create server with IPv6 only by default (no IPv4 to save costs)
 	// If EnableIPv4 is set, provision with both IPv4 and IPv6 for fallback support
 	createOpts := hcloud.ServerCreateOpts{
@@ -194,6 +230,13 @@ func (p *Provider) CreateServer(ctx context.Context, req machine.CreateServerReq
 			EnableIPv6: true,
 		},
 	}
+	if len(volumes) > 0 {
+		createOpts.Volumes = volumes
+		createOpts.Automount = hcloud.Ptr(false)
+	}
+	if placementGroup != nil {
+		createOpts.PlacementGroup = placementGroup
+	}
 
 	result, _, err := p.client.Server.Create(ctx, createOpts)
 	if err != nil {
@@ -210,7 +253,7 @@ func (p *Provider) GetServer(ctx context.Context, serverID string) (*machine.Ser
 		return nil, wrapAuthError(err, "failed to get server")
 	}
 	if server == nil {
-		return nil, fmt.Errorf("server not found: %s", serverID)
+		return nil, fmt.Errorf("server %s %w", serverID, machine.ErrNotFound)
 	}
 
 	return convertServer(server), nil
@@ -223,7 +266,7 @@ func (p *Provider) DeleteServer(ctx context.Context, serverID string) error {
 		return wrapAuthError(err, "failed to get server")
 	}
 	if server == nil {
-		return fmt.Errorf("server not found: %s", serverID)
+		return fmt.Errorf("server %s %w", serverID, machine.ErrNotFound)
 	}
 
 	_, _, err = p.client.Server.DeleteWithResult(ctx, server)
@@ -234,6 +277,119 @@ func (p *Provider) DeleteServer(ctx context.Context, serverID string) error {
 	return nil
 }
 
+// UpdateLabels replaces all labels of a server
+func (p *Provider) UpdateLabels(ctx context.Context, serverID string, labels map[string]string) error {
+	server, _, err := p.client.Server.GetByID(ctx, parseServerID(serverID))
+	if err != nil {
+		return wrapAuthError(err, "failed to get server")
+	}
+	if server == nil {
+		return fmt.Errorf("server %s %w", serverID, machine.ErrNotFound)
+	}
+
+	_, _, err = p.client.Server.Update(ctx, server, hcloud.ServerUpdateOpts{Labels: labels})
+	if err != nil {
+		return wrapAuthError(err, "failed to update server labels")
+	}
+
+	return nil
+}
+
+// CreateVolume creates an unattached, formatted volume
+func (p *Provider) CreateVolume(ctx context.Context, req machine.CreateVolumeRequest) (*machine.Volume, error) {
+	location, _, err := p.client.Location.GetByName(ctx, req.Location)
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to get location")
+	}
+	if location == nil {
+		return nil, fmt.Errorf("location not found: %s", req.Location)
+	}
+
+	opts := hcloud.VolumeCreateOpts{
+		Name:     req.Name,
+		Size:     req.SizeGB,
+		Location: location,
+		Labels:   req.Labels,
+	}
+	if req.Format != "" {
+		opts.Format = hcloud.Ptr(req.Format)
+	}
+
+	result, _, err := p.client.Volume.Create(ctx, opts)
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to create volume")
+	}
+
+	return convertVolume(result.Volume), nil
+}
+
+// ListVolumes lists all volumes with optional label filters
+func (p *Provider) ListVolumes(ctx context.Context, filters map[string]string) ([]*machine.Volume, error) {
+	opts := hcloud.VolumeListOpts{}
+
+	if len(filters) > 0 {
+		opts.LabelSelector = formatLabelSelector(filters)
+	}
+
+	volumes, err := p.client.Volume.AllWithOpts(ctx, opts)
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to list volumes")
+	}
+
+	result := make([]*machine.Volume, len(volumes))
+	for i, volume := range volumes {
+		result[i] = convertVolume(volume)
+	}
+
+	return result, nil
+}
+
+// DeleteVolume detaches a volume if it is still attached and deletes it.
+// A volume stays attached for a short while after its server is deleted,
+// so detachment is awaited before deleting.
+func (p *Provider) DeleteVolume(ctx context.Context, volumeID string) error {
+	volume, _, err := p.client.Volume.GetByID(ctx, parseServerID(volumeID))
+	if err != nil {
+		return wrapAuthError(err, "failed to get volume")
+	}
+	if volume == nil {
+		return fmt.Errorf("volume not found: %s", volumeID)
+	}
+
+	if volume.Server != nil {
+		if _, _, err := p.client.Volume.Detach(ctx, volume); err != nil && !hcloud.IsError(err, hcloud.ErrorCodeNotFound) {
+			return wrapAuthError(err, "failed to detach volume")
+		}
+
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+		timeout := time.After(2 * time.Minute)
+
+		for volume.Server != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timeout:
+				return fmt.Errorf("timeout waiting for volume %s to detach", volumeID)
+			case <-ticker.C:
+				volume, _, err = p.client.Volume.GetByID(ctx, volume.ID)
+				if err != nil {
+					return wrapAuthError(err, "failed to get volume")
+				}
+				if volume == nil {
+					return nil
+				}
+			}
+		}
+	}
+
+	if _, err := p.client.Volume.Delete(ctx, volume); err != nil {
+		return wrapAuthError(err, "failed to delete volume")
+	}
+
+	return nil
+}
+
 // WaitForServer waits until the server is in the specified state
 func (p *Provider) WaitForServer(ctx context.Context, serverID string, state machine.ServerState) error {
 	ticker := time.NewTicker(5 * time.Second)
@@ -425,6 +581,25 @@ func (p *Provider) CheckSSHKeyExists(ctx context.Context, keyName string) (bool,
 	return key != nil, nil
 }
 
+// ListSSHKeys lists all SSH keys of the project
+func (p *Provider) ListSSHKeys(ctx context.Context) ([]*machine.SSHKey, error) {
+	keys, err := p.client.SSHKey.All(ctx)
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to list SSH keys")
+	}
+
+	result := make([]*machine.SSHKey, len(keys))
+	for i, key := range keys {
+		result[i] = &machine.SSHKey{
+			ID:          fmt.Sprintf("%d", key.ID),
+			Name:        key.Name,
+			Fingerprint: key.Fingerprint,
+			Labels:      key.Labels,
+		}
+	}
+	return result, nil
+}
+
 // SSHKeyInfo contains information about an SSH key from Hetzner Cloud
 type SSHKeyInfo struct {
 	Name        string
@@ -467,6 +642,36 @@ func (p *Provider) DeleteSSHKey(ctx context.Context, keyName string) error {
 	return nil
 }
 
+// ReplaceSSHKey replaces the public key stored under keyName, or creates it.
+// The new key is uploaded under a temporary name first, so the name is never
+// left without a key if the upload fails.
+func (p *Provider) ReplaceSSHKey(ctx context.Context, keyName, publicKey string) (*SSHKeyInfo, error) {
+	tempName := fmt.Sprintf("%s-rotating-%d", keyName, time.Now().Unix())
+	key, _, err := p.client.SSHKey.Create(ctx, hcloud.SSHKeyCreateOpts{
+		Name:      tempName,
+		PublicKey: publicKey,
+	})
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to upload SSH key")
+	}
+
+	if err := p.DeleteSSHKey(ctx, keyName); err != nil {
+		// Don't leave the temporary key behind
+		p.client.SSHKey.Delete(ctx, key)
+		return nil, err
+	}
+
+	key, _, err = p.client.SSHKey.Update(ctx, key, hcloud.SSHKeyUpdateOpts{Name: keyName})
+	if err != nil {
+		return nil, wrapAuthError(err, fmt.Sprintf("failed to rename SSH key %s", tempName))
+	}
+	return &SSHKeyInfo{
+		Name:        key.Name,
+		Fingerprint: key.Fingerprint,
+		PublicKey:   key.PublicKey,
+	}, nil
+}
+
 // ensureSSHKey checks if an SSH key exists in Hetzner Cloud by name.
 // If not found, it attempts to read from common SSH key locations and upload it.
 // Returns the SSH key from Hetzner Cloud.
@@ -625,13 +830,12 @@ func convertServer(server *hcloud.Server) *machine.Server {
 	}
 	if server.PublicNet.IPv6.IP != nil {
 		// Hetzner returns the /64 network address (e.g., 2a01:4f8:c17:1234::)
-		// The server's actual address is ::1 within that network
-		ipv6Base := server.PublicNet.IPv6.IP.String()
-		if strings.HasSuffix(ipv6Base, "::") {
-			publicIPv6 = ipv6Base + "1"
-		} else {
-			publicIPv6 = ipv6Base
-		}
+		publicIPv6 = machine.HostIPv6(server.PublicNet.IPv6.IP.String())
+	}
+
+	var serverType string
+	if server.ServerType != nil {
+		serverType = server.ServerType.Name
 	}
 
 	return &machine.Server{
@@ -643,7 +847,25 @@ func convertServer(server *hcloud.Server) *machine.Server {
 		State:      convertServerState(server.Status),
 		Labels:     server.Labels,
 		CreatedAt:  server.Created.Format(time.RFC3339),
+		ServerType: serverType,
+	}
+}
+
+func convertVolume(volume *hcloud.Volume) *machine.Volume {
+	v := &machine.Volume{
+		ID:     fmt.Sprintf("%d", volume.ID),
+		Name:   volume.Name,
+		SizeGB: volume.Size,
+		Device: volume.LinuxDevice,
+		Labels: volume.Labels,
+	}
+	if volume.Location != nil {
+		v.Location = volume.Location.Name
+	}
+	if volume.Server != nil {
+		v.ServerID = fmt.Sprintf("%d", volume.Server.ID)
 	}
+	return v
 }
 
 func convertServerState(status hcloud.ServerStatus) machine.ServerState {
@@ -0,0 +1,152 @@
+package hetzner
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/nimsforest/morpheus/internal/hetznermock"
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+func newTestProvider(t *testing.T) (*Provider, *hetznermock.Server) {
+	t.Helper()
+	mock := hetznermock.NewServer()
+	t.Cleanup(mock.Close)
+	mock.Token = "test-token"
+
+	p, err := NewProviderWithEndpoint("test-token", mock.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, mock
+}
+
+func TestServerLifecycle(t *testing.T) {
+	p, mock := newTestProvider(t)
+	ctx := context.Background()
+
+	server, err := p.CreateServer(ctx, machine.CreateServerRequest{
+		Name:       "f1-node-1",
+		ServerType: "cx22",
+		Image:      "ubuntu-24.04",
+		Location:   "hel1",
+		Labels:     map[string]string{"forest_id": "f1"},
+	})
+	if err != nil {
+		t.Fatalf("CreateServer() error = %v", err)
+	}
+	if server.Name != "f1-node-1" || server.Location != "hel1" || server.State != machine.ServerStateRunning {
+		t.Errorf("CreateServer() = %+v", server)
+	}
+	// Servers are IPv6-only unless IPv4 is asked for
+	if server.PublicIPv4 != "" || server.PublicIPv6 == "" || server.PublicIPv6[len(server.PublicIPv6)-3:] != "::1" {
+		t.Errorf("CreateServer() addresses = %q, %q", server.PublicIPv4, server.PublicIPv6)
+	}
+
+	if _, err := p.CreateServer(ctx, machine.CreateServerRequest{
+		Name: "f2-node-1", ServerType: "cx22", Image: "debian-12", Location: "ash", EnableIPv4: true,
+	}); err == nil {
+		t.Error("CreateServer() in a location without the server type succeeded")
+	}
+	other, err := p.CreateServer(ctx, machine.CreateServerRequest{
+		Name: "f2-node-1", ServerType: "cpx11", Image: "debian-12", Location: "ash", EnableIPv4: true,
+	})
+	if err != nil {
+		t.Fatalf("CreateServer() error = %v", err)
+	}
+	if other.PublicIPv4 == "" {
+		t.Errorf("CreateServer() with EnableIPv4 has no IPv4")
+	}
+
+	servers, err := p.ListServers(ctx, map[string]string{"forest_id": "f1"})
+	if err != nil || len(servers) != 1 || servers[0].ID != server.ID {
+		t.Fatalf("ListServers() = %+v, %v", servers, err)
+	}
+
+	if err := p.UpdateLabels(ctx, server.ID, map[string]string{"forest_id": "f1", "role": "edge"}); err != nil {
+		t.Fatalf("UpdateLabels() error = %v", err)
+	}
+	got, err := p.GetServer(ctx, server.ID)
+	if err != nil || got.Labels["role"] != "edge" {
+		t.Errorf("GetServer() after UpdateLabels = %+v, %v", got, err)
+	}
+
+	if err := p.DeleteServer(ctx, server.ID); err != nil {
+		t.Fatalf("DeleteServer() error = %v", err)
+	}
+	if _, err := p.GetServer(ctx, server.ID); !errors.Is(err, machine.ErrNotFound) {
+		t.Errorf("GetServer() after delete error = %v, want ErrNotFound", err)
+	}
+	if n := len(mock.Servers()); n != 1 {
+		t.Errorf("%d servers left, want 1", n)
+	}
+}
+
+func TestLocations(t *testing.T) {
+	p, _ := newTestProvider(t)
+	ctx := context.Background()
+
+	ok, err := p.CheckLocationAvailability(ctx, "ash", "cpx11")
+	if err != nil || !ok {
+		t.Errorf("CheckLocationAvailability(ash, cpx11) = %v, %v", ok, err)
+	}
+	ok, err = p.CheckLocationAvailability(ctx, "ash", "cx22")
+	if err != nil || ok {
+		t.Errorf("CheckLocationAvailability(ash, cx22) = %v, %v", ok, err)
+	}
+
+	supported, unsupported, err := p.FilterLocationsByServerType(ctx, []string{"fsn1", "hil"}, "cx22")
+	if err != nil || len(supported) != 1 || supported[0] != "fsn1" || len(unsupported) != 1 {
+		t.Errorf("FilterLocationsByServerType() = %v, %v, %v", supported, unsupported, err)
+	}
+	if valid, _ := p.ValidateServerType(ctx, "cx99"); valid {
+		t.Error("ValidateServerType(cx99) = true")
+	}
+}
+
+func TestSSHKeys(t *testing.T) {
+	p, _ := newTestProvider(t)
+	ctx := context.Background()
+
+	if exists, err := p.CheckSSHKeyExists(ctx, "morpheus"); err != nil || exists {
+		t.Fatalf("CheckSSHKeyExists() = %v, %v", exists, err)
+	}
+	info, err := p.ReplaceSSHKey(ctx, "morpheus", "ssh-ed25519 AAAAfirst")
+	if err != nil || info.Name != "morpheus" {
+		t.Fatalf("ReplaceSSHKey() = %+v, %v", info, err)
+	}
+	if _, err := p.ReplaceSSHKey(ctx, "morpheus", "ssh-ed25519 AAAAsecond"); err != nil {
+		t.Fatalf("ReplaceSSHKey() error = %v", err)
+	}
+	info, err = p.GetSSHKeyInfo(ctx, "morpheus")
+	if err != nil || info == nil || info.PublicKey != "ssh-ed25519 AAAAsecond" {
+		t.Fatalf("GetSSHKeyInfo() = %+v, %v", info, err)
+	}
+
+	if err := p.DeleteSSHKey(ctx, "morpheus"); err != nil {
+		t.Fatalf("DeleteSSHKey() error = %v", err)
+	}
+	if exists, _ := p.CheckSSHKeyExists(ctx, "morpheus"); exists {
+		t.Error("SSH key still exists after DeleteSSHKey()")
+	}
+}
+
+func TestAuthError(t *testing.T) {
+	_, mock := newTestProvider(t)
+	p, err := NewProviderWithEndpoint("wrong-token", mock.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.ListServers(context.Background(), nil); err == nil {
+		t.Error("ListServers() with a wrong token succeeded")
+	}
+
+	mock.FailNext("GET", "/servers", http.StatusServiceUnavailable, "unavailable")
+	good, _ := NewProviderWithEndpoint("test-token", mock.URL)
+	good.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+	if _, err := good.ListServers(context.Background(), nil); err == nil {
+		t.Error("ListServers() succeeded despite a 503")
+	}
+}
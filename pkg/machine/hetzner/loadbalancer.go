@@ -0,0 +1,161 @@
+package hetzner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+// CreateLoadBalancer creates a load balancer with a label selector target,
+// so servers added to or removed from the selection are registered and
+// deregistered by Hetzner automatically
+func (p *Provider) CreateLoadBalancer(ctx context.Context, req machine.CreateLoadBalancerRequest) (*machine.LoadBalancer, error) {
+	lbTypeName := req.Type
+	if lbTypeName == "" {
+		lbTypeName = "lb11"
+	}
+	lbType, _, err := p.client.LoadBalancerType.GetByName(ctx, lbTypeName)
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to get load balancer type")
+	}
+	if lbType == nil {
+		return nil, fmt.Errorf("load balancer type not found: %s", lbTypeName)
+	}
+
+	location, _, err := p.client.Location.GetByName(ctx, req.Location)
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to get location")
+	}
+	if location == nil {
+		return nil, fmt.Errorf("location not found: %s", req.Location)
+	}
+
+	opts := hcloud.LoadBalancerCreateOpts{
+		Name:             req.Name,
+		LoadBalancerType: lbType,
+		Location:         location,
+		Labels:           req.Labels,
+		PublicInterface:  hcloud.Ptr(true),
+		Targets: []hcloud.LoadBalancerCreateOptsTarget{{
+			Type:          hcloud.LoadBalancerTargetTypeLabelSelector,
+			LabelSelector: hcloud.LoadBalancerCreateOptsTargetLabelSelector{Selector: formatLabelSelector(req.TargetSelector)},
+		}},
+	}
+	for _, svc := range req.Services {
+		opts.Services = append(opts.Services, convertLoadBalancerService(svc))
+	}
+
+	result, _, err := p.client.LoadBalancer.Create(ctx, opts)
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to create load balancer")
+	}
+
+	return convertLoadBalancer(result.LoadBalancer), nil
+}
+
+// ListLoadBalancers lists all load balancers with optional label filters
+func (p *Provider) ListLoadBalancers(ctx context.Context, filters map[string]string) ([]*machine.LoadBalancer, error) {
+	opts := hcloud.LoadBalancerListOpts{}
+	if len(filters) > 0 {
+		opts.LabelSelector = formatLabelSelector(filters)
+	}
+
+	lbs, err := p.client.LoadBalancer.AllWithOpts(ctx, opts)
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to list load balancers")
+	}
+
+	result := make([]*machine.LoadBalancer, len(lbs))
+	for i, lb := range lbs {
+		result[i] = convertLoadBalancer(lb)
+	}
+	return result, nil
+}
+
+// DeleteLoadBalancer removes a load balancer
+func (p *Provider) DeleteLoadBalancer(ctx context.Context, loadBalancerID string) error {
+	lb, _, err := p.client.LoadBalancer.GetByID(ctx, parseServerID(loadBalancerID))
+	if err != nil {
+		return wrapAuthError(err, "failed to get load balancer")
+	}
+	if lb == nil {
+		return fmt.Errorf("load balancer not found: %s", loadBalancerID)
+	}
+
+	if _, err := p.client.LoadBalancer.Delete(ctx, lb); err != nil {
+		return wrapAuthError(err, "failed to delete load balancer")
+	}
+	return nil
+}
+
+// convertLoadBalancerService builds a service with an explicit health check
+// on the destination port
+func convertLoadBalancerService(svc machine.LoadBalancerService) hcloud.LoadBalancerCreateOptsService {
+	protocol := hcloud.LoadBalancerServiceProtocolTCP
+	if svc.Protocol == "http" {
+		protocol = hcloud.LoadBalancerServiceProtocolHTTP
+	}
+
+	healthCheck := &hcloud.LoadBalancerCreateOptsServiceHealthCheck{
+		Protocol: hcloud.LoadBalancerServiceProtocolTCP,
+		Port:     hcloud.Ptr(svc.DestinationPort),
+		Interval: hcloud.Ptr(15 * time.Second),
+		Timeout:  hcloud.Ptr(10 * time.Second),
+		Retries:  hcloud.Ptr(3),
+	}
+	if svc.HealthCheckPath != "" {
+		healthCheck.Protocol = hcloud.LoadBalancerServiceProtocolHTTP
+		healthCheck.HTTP = &hcloud.LoadBalancerCreateOptsServiceHealthCheckHTTP{
+			Path:        hcloud.Ptr(svc.HealthCheckPath),
+			StatusCodes: []string{"2??", "3??"},
+		}
+	}
+
+	return hcloud.LoadBalancerCreateOptsService{
+		Protocol:        protocol,
+		ListenPort:      hcloud.Ptr(svc.ListenPort),
+		DestinationPort: hcloud.Ptr(svc.DestinationPort),
+		HealthCheck:     healthCheck,
+	}
+}
+
+func convertLoadBalancer(lb *hcloud.LoadBalancer) *machine.LoadBalancer {
+	result := &machine.LoadBalancer{
+		ID:     fmt.Sprintf("%d", lb.ID),
+		Name:   lb.Name,
+		Labels: lb.Labels,
+	}
+	if lb.PublicNet.IPv4.IP != nil {
+		result.PublicIPv4 = lb.PublicNet.IPv4.IP.String()
+	}
+	if lb.PublicNet.IPv6.IP != nil {
+		result.PublicIPv6 = lb.PublicNet.IPv6.IP.String()
+	}
+	if lb.Location != nil {
+		result.Location = lb.Location.Name
+	}
+
+	// Label selector targets list the matched servers as sub-targets
+	for _, target := range lb.Targets {
+		servers := target.Targets
+		if target.Type == hcloud.LoadBalancerTargetTypeServer {
+			servers = []hcloud.LoadBalancerTarget{target}
+		}
+		for _, t := range servers {
+			result.Targets++
+			healthy := len(t.HealthStatus) > 0
+			for _, hs := range t.HealthStatus {
+				if hs.Status != hcloud.LoadBalancerTargetHealthStatusStatusHealthy {
+					healthy = false
+				}
+			}
+			if healthy {
+				result.Healthy++
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,106 @@
+package hetzner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+// maxSpreadGroupServers is the number of servers a Hetzner spread
+// placement group can hold
+const maxSpreadGroupServers = 10
+
+// CreatePlacementGroup creates a spread placement group
+func (p *Provider) CreatePlacementGroup(ctx context.Context, req machine.CreatePlacementGroupRequest) (*machine.PlacementGroup, error) {
+	result, _, err := p.client.PlacementGroup.Create(ctx, hcloud.PlacementGroupCreateOpts{
+		Name:   req.Name,
+		Labels: req.Labels,
+		Type:   hcloud.PlacementGroupTypeSpread,
+	})
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to create placement group")
+	}
+	return convertPlacementGroup(result.PlacementGroup), nil
+}
+
+// ListPlacementGroups lists all placement groups with optional label filters
+func (p *Provider) ListPlacementGroups(ctx context.Context, filters map[string]string) ([]*machine.PlacementGroup, error) {
+	opts := hcloud.PlacementGroupListOpts{}
+	if len(filters) > 0 {
+		opts.LabelSelector = formatLabelSelector(filters)
+	}
+
+	groups, err := p.client.PlacementGroup.AllWithOpts(ctx, opts)
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to list placement groups")
+	}
+
+	result := make([]*machine.PlacementGroup, len(groups))
+	for i, g := range groups {
+		result[i] = convertPlacementGroup(g)
+	}
+	return result, nil
+}
+
+// DeletePlacementGroup removes a placement group. Server deletion is
+// asynchronous, so it first waits for the group to empty.
+func (p *Provider) DeletePlacementGroup(ctx context.Context, placementGroupID string) error {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	timeout := time.After(2 * time.Minute)
+
+	for {
+		group, _, err := p.client.PlacementGroup.GetByID(ctx, parseServerID(placementGroupID))
+		if err != nil {
+			return wrapAuthError(err, "failed to get placement group")
+		}
+		if group == nil {
+			return fmt.Errorf("placement group not found: %s", placementGroupID)
+		}
+		if len(group.Servers) == 0 {
+			if _, err := p.client.PlacementGroup.Delete(ctx, group); err != nil {
+				return wrapAuthError(err, "failed to delete placement group")
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			return fmt.Errorf("placement group %s still has %d server(s)", placementGroupID, len(group.Servers))
+		case <-ticker.C:
+		}
+	}
+}
+
+// resolvePlacementGroup returns the placement group a new server joins,
+// or nil when the group is full
+func (p *Provider) resolvePlacementGroup(ctx context.Context, placementGroupID string) (*hcloud.PlacementGroup, error) {
+	group, _, err := p.client.PlacementGroup.GetByID(ctx, parseServerID(placementGroupID))
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to get placement group")
+	}
+	if group == nil {
+		return nil, fmt.Errorf("placement group not found: %s", placementGroupID)
+	}
+	if len(group.Servers) >= maxSpreadGroupServers {
+		return nil, nil
+	}
+	return group, nil
+}
+
+func convertPlacementGroup(g *hcloud.PlacementGroup) *machine.PlacementGroup {
+	result := &machine.PlacementGroup{
+		ID:     fmt.Sprintf("%d", g.ID),
+		Name:   g.Name,
+		Labels: g.Labels,
+	}
+	for _, id := range g.Servers {
+		result.Servers = append(result.Servers, fmt.Sprintf("%d", id))
+	}
+	return result
+}
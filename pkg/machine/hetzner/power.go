@@ -0,0 +1,56 @@
+package hetzner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+// RebootServer resets a server (a hard reboot) and waits for the action
+func (p *Provider) RebootServer(ctx context.Context, serverID string) error {
+	return p.serverAction(ctx, serverID, "reset", p.client.Server.Reset)
+}
+
+// PowerOffServer cuts a server's power and waits for the action
+func (p *Provider) PowerOffServer(ctx context.Context, serverID string) error {
+	return p.serverAction(ctx, serverID, "power off", p.client.Server.Poweroff)
+}
+
+// PowerOnServer starts a server that is off and waits for the action
+func (p *Provider) PowerOnServer(ctx context.Context, serverID string) error {
+	return p.serverAction(ctx, serverID, "power on", p.client.Server.Poweron)
+}
+
+// ResizeServer changes the type of a server that is powered off and waits
+// for the action. Unless upgradeDisk is set, the disk keeps its size, so
+// the server can be resized back to a smaller type later.
+func (p *Provider) ResizeServer(ctx context.Context, serverID, serverType string, upgradeDisk bool) error {
+	return p.serverAction(ctx, serverID, "change the type of", func(ctx context.Context, server *hcloud.Server) (*hcloud.Action, *hcloud.Response, error) {
+		return p.client.Server.ChangeType(ctx, server, hcloud.ServerChangeTypeOpts{
+			ServerType:  &hcloud.ServerType{Name: serverType},
+			UpgradeDisk: upgradeDisk,
+		})
+	})
+}
+
+// serverAction runs a server action and waits until it has finished
+func (p *Provider) serverAction(ctx context.Context, serverID, name string, action func(context.Context, *hcloud.Server) (*hcloud.Action, *hcloud.Response, error)) error {
+	server, _, err := p.client.Server.GetByID(ctx, parseServerID(serverID))
+	if err != nil {
+		return wrapAuthError(err, "failed to get server")
+	}
+	if server == nil {
+		return fmt.Errorf("server %s %w", serverID, machine.ErrNotFound)
+	}
+
+	a, _, err := action(ctx, server)
+	if err != nil {
+		return wrapAuthError(err, "failed to "+name+" server")
+	}
+	if err := p.waitForAction(ctx, a); err != nil {
+		return fmt.Errorf("failed to %s server: %w", name, err)
+	}
+	return nil
+}
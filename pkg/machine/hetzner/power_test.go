@@ -0,0 +1,77 @@
+package hetzner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+func TestPowerActions(t *testing.T) {
+	p, _ := newTestProvider(t)
+	ctx := context.Background()
+
+	server, err := p.CreateServer(ctx, machine.CreateServerRequest{
+		Name: "f1-node-1", ServerType: "cx22", Image: "ubuntu-24.04", Location: "fsn1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range []struct {
+		name   string
+		action func(context.Context, string) error
+		want   machine.ServerState
+	}{
+		{"PowerOffServer", p.PowerOffServer, machine.ServerStateStopped},
+		{"PowerOnServer", p.PowerOnServer, machine.ServerStateRunning},
+		{"RebootServer", p.RebootServer, machine.ServerStateRunning},
+	} {
+		if err := step.action(ctx, server.ID); err != nil {
+			t.Fatalf("%s() error = %v", step.name, err)
+		}
+		if got, _ := p.GetServer(ctx, server.ID); got.State != step.want {
+			t.Errorf("after %s() state = %s, want %s", step.name, got.State, step.want)
+		}
+	}
+
+	if err := p.RebootServer(ctx, "999"); err == nil {
+		t.Error("RebootServer() of an unknown server succeeded")
+	}
+}
+
+func TestResizeServer(t *testing.T) {
+	p, _ := newTestProvider(t)
+	ctx := context.Background()
+
+	server, err := p.CreateServer(ctx, machine.CreateServerRequest{
+		Name: "f1-node-1", ServerType: "cx22", Image: "ubuntu-24.04", Location: "fsn1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.ResizeServer(ctx, server.ID, "cpx31", false); err == nil {
+		t.Error("ResizeServer() of a running server succeeded")
+	}
+	if err := p.PowerOffServer(ctx, server.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ResizeServer(ctx, server.ID, "cpx31", false); err != nil {
+		t.Fatalf("ResizeServer() error = %v", err)
+	}
+	if got, _ := p.GetServer(ctx, server.ID); got.ServerType != "cpx31" {
+		t.Errorf("server type = %s, want cpx31", got.ServerType)
+	}
+
+	// Without a disk upgrade the server can go back to a smaller type
+	if err := p.ResizeServer(ctx, server.ID, "cx22", false); err != nil {
+		t.Fatalf("ResizeServer() back error = %v", err)
+	}
+	if err := p.ResizeServer(ctx, server.ID, "cpx31", true); err != nil {
+		t.Fatalf("ResizeServer(upgradeDisk) error = %v", err)
+	}
+	if err := p.ResizeServer(ctx, server.ID, "cx22", false); err == nil {
+		t.Error("ResizeServer() to a smaller disk after a disk upgrade succeeded")
+	}
+}
@@ -146,3 +146,26 @@ func GetEstimatedCost(serverType string) float64 {
 	// Default estimate
 	return 5.0
 }
+
+const (
+	// EstimatedIPv4Cost is the approximate monthly cost of a primary IPv4 address in EUR
+	EstimatedIPv4Cost = 0.50
+	// EstimatedVolumeCostPerGB is the approximate monthly cost of 1 GB of volume storage in EUR
+	EstimatedVolumeCostPerGB = 0.044
+	// EstimatedLoadBalancerCost is the approximate monthly cost of an lb11 load balancer in EUR
+	EstimatedLoadBalancerCost = 5.39
+	// EstimatedFloatingIPv4Cost is the approximate monthly cost of a floating IPv4 address in EUR
+	EstimatedFloatingIPv4Cost = 3.00
+	// EstimatedFloatingIPv6Cost is the approximate monthly cost of a floating IPv6 network in EUR
+	EstimatedFloatingIPv6Cost = 1.00
+)
+
+// GetEstimatedNodeCost returns the estimated monthly cost of one forest node,
+// including its IPv4 address (if enabled) and volume (volumeGB, 0 for none)
+func GetEstimatedNodeCost(serverType string, ipv4 bool, volumeGB int) float64 {
+	cost := GetEstimatedCost(serverType)
+	if ipv4 {
+		cost += EstimatedIPv4Cost
+	}
+	return cost + float64(volumeGB)*EstimatedVolumeCostPerGB
+}
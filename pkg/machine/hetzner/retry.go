@@ -0,0 +1,186 @@
+package hetzner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how API calls are retried after rate limiting,
+// transient server errors and network failures
+type RetryPolicy struct {
+	MaxAttempts int           // Attempts per call including the first (1 = no retries)
+	BaseDelay   time.Duration // Delay before the first retry, doubled for each further one
+	MaxDelay    time.Duration // Longest single delay
+}
+
+// DefaultRetryPolicy rides out a few seconds of API trouble
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   time.Second,
+	MaxDelay:    30 * time.Second,
+}
+
+// SetRetryPolicy replaces the retry policy of the provider's API calls
+func (p *Provider) SetRetryPolicy(policy RetryPolicy) {
+	if p.retry != nil {
+		p.retry.policy = policy
+	}
+}
+
+// retryTransport retries requests the API rejected because of its rate
+// limit (429), failed with a server error or never reached it. Requests
+// that create something (POST) are only retried when the API cannot have
+// acted on them, so a retry never creates a second server.
+type retryTransport struct {
+	next    http.RoundTripper
+	policy  RetryPolicy
+	timeout time.Duration // Per attempt (0 = none)
+	now     func() time.Time
+}
+
+// newRetryTransport wraps the transport of client, taking over its timeout
+// so that it applies to each attempt rather than to all of them
+func newRetryTransport(client *http.Client) *retryTransport {
+	t := &retryTransport{next: client.Transport, policy: DefaultRetryPolicy, timeout: client.Timeout, now: time.Now}
+	if t.next == nil {
+		t.next = http.DefaultTransport
+	}
+	client.Transport = t
+	client.Timeout = 0
+	return t
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := req.Context(), context.CancelFunc(func() {})
+		if t.timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		}
+		r := req.Clone(ctx)
+		if body != nil {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		resp, err := t.next.RoundTrip(r)
+
+		delay, retry := t.retryDelay(req.Method, resp, err, attempt)
+		if !retry || attempt >= t.policy.MaxAttempts {
+			if resp == nil {
+				cancel()
+				return nil, err
+			}
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		cancel()
+		if err := sleepContext(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryDelay decides whether a request is retried after the given attempt,
+// and how long to wait first
+func (t *retryTransport) retryDelay(method string, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	idempotent := method != http.MethodPost && method != http.MethodPatch
+	delay := t.backoff(attempt)
+
+	switch {
+	case err != nil:
+		if errors.Is(err, context.Canceled) {
+			return 0, false
+		}
+		return delay, idempotent || notSent(err)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		// The rate limit refills steadily; wait until the API says so
+		if wait := t.rateLimitWait(resp.Header); wait > delay {
+			delay = min(wait, t.policy.MaxDelay)
+		}
+		return delay, true
+	case resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented:
+		// The API may have acted on the request before failing
+		return delay, idempotent
+	}
+	return 0, false
+}
+
+// backoff returns the exponential delay before retry number attempt, with
+// up to 20% jitter so parallel runs do not retry in lockstep
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	delay := t.policy.BaseDelay
+	for i := 1; i < attempt && delay < t.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, t.policy.MaxDelay)
+	if delay > 0 {
+		delay -= time.Duration(rand.Int64N(int64(delay)/5 + 1))
+	}
+	return delay
+}
+
+// rateLimitWait reads how long to wait from the Retry-After or the hcloud
+// RateLimit-Reset (Unix time) header
+func (t *retryTransport) rateLimitWait(h http.Header) time.Duration {
+	if s, err := strconv.Atoi(h.Get("Retry-After")); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	if reset, err := strconv.ParseInt(h.Get("RateLimit-Reset"), 10, 64); err == nil {
+		if wait := time.Unix(reset, 0).Sub(t.now()); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// cancelBody ends the attempt's timeout once the response is read
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// notSent reports whether a request failed before reaching the API
+func notSent(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,96 @@
+package hetzner
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+var fastRetries = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func TestRetryTransientFailures(t *testing.T) {
+	p, mock := newTestProvider(t)
+	p.SetRetryPolicy(fastRetries)
+	ctx := context.Background()
+	requests := func(method, path string) int {
+		n := 0
+		for _, r := range mock.Requests() {
+			if r.Method == method && r.Path == path {
+				n++
+			}
+		}
+		return n
+	}
+
+	mock.FailNext("GET", "/servers", http.StatusServiceUnavailable, "unavailable")
+	mock.FailNext("GET", "/servers", http.StatusBadGateway, "bad_gateway")
+	if _, err := p.ListServers(ctx, nil); err != nil {
+		t.Fatalf("ListServers() after two transient failures: %v", err)
+	}
+
+	// A failed create may have taken effect, so it is not repeated
+	mock.FailNext("POST", "/servers", http.StatusServiceUnavailable, "unavailable")
+	req := machine.CreateServerRequest{Name: "n1", ServerType: "cx22", Image: "ubuntu-24.04", Location: "hel1"}
+	if _, err := p.CreateServer(ctx, req); err == nil {
+		t.Error("CreateServer() succeeded despite a 503")
+	}
+	if n := requests("POST", "/servers"); n != 1 {
+		t.Errorf("create sent %d times after a 503, want 1", n)
+	}
+
+	// A rate-limited create never took effect and is repeated
+	mock.FailNext("POST", "/servers", http.StatusTooManyRequests, "rate_limit_exceeded")
+	if _, err := p.CreateServer(ctx, req); err != nil {
+		t.Fatalf("CreateServer() after a 429: %v", err)
+	}
+	if n := requests("POST", "/servers"); n != 3 {
+		t.Errorf("create sent %d times in total, want 3", n)
+	}
+
+	for i := 0; i < fastRetries.MaxAttempts; i++ {
+		mock.FailNext("GET", "/servers", http.StatusServiceUnavailable, "unavailable")
+	}
+	if _, err := p.ListServers(ctx, nil); err == nil {
+		t.Error("ListServers() succeeded although every attempt failed")
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tr := &retryTransport{policy: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Second}, now: func() time.Time { return now }}
+
+	h := http.Header{}
+	h.Set("RateLimit-Reset", strconv.FormatInt(now.Unix()+4, 10))
+	delay, retry := tr.retryDelay(http.MethodPost, &http.Response{StatusCode: http.StatusTooManyRequests, Header: h}, nil, 1)
+	if !retry || delay != 4*time.Second {
+		t.Errorf("retryDelay() after 429 = %v, %v, want 4s, true", delay, retry)
+	}
+
+	h.Set("RateLimit-Reset", strconv.FormatInt(now.Unix()+3600, 10))
+	if delay, _ := tr.retryDelay(http.MethodGet, &http.Response{StatusCode: http.StatusTooManyRequests, Header: h}, nil, 1); delay != 10*time.Second {
+		t.Errorf("retryDelay() capped = %v, want 10s", delay)
+	}
+
+	h = http.Header{}
+	h.Set("Retry-After", "2")
+	if delay, _ := tr.retryDelay(http.MethodGet, &http.Response{StatusCode: http.StatusTooManyRequests, Header: h}, nil, 1); delay != 2*time.Second {
+		t.Errorf("retryDelay() with Retry-After = %v, want 2s", delay)
+	}
+
+	if _, retry := tr.retryDelay(http.MethodGet, &http.Response{StatusCode: http.StatusNotFound}, nil, 1); retry {
+		t.Error("retryDelay() retries a 404")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tr := &retryTransport{policy: RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}}
+	for attempt, max := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 10: time.Second} {
+		if d := tr.backoff(attempt); d > max || d < max*4/5 {
+			t.Errorf("backoff(%d) = %v, want within 20%% below %v", attempt, d, max)
+		}
+	}
+}
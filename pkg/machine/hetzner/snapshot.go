@@ -0,0 +1,92 @@
+package hetzner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+// CreateSnapshot snapshots a server's disk and waits until the snapshot is
+// available. The server keeps running; the snapshot is crash-consistent.
+func (p *Provider) CreateSnapshot(ctx context.Context, req machine.CreateSnapshotRequest) (*machine.Snapshot, error) {
+	server, _, err := p.client.Server.GetByID(ctx, parseServerID(req.ServerID))
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to get server")
+	}
+	if server == nil {
+		return nil, fmt.Errorf("server %s %w", req.ServerID, machine.ErrNotFound)
+	}
+
+	result, _, err := p.client.Server.CreateImage(ctx, server, &hcloud.ServerCreateImageOpts{
+		Type:        hcloud.ImageTypeSnapshot,
+		Description: hcloud.Ptr(req.Description),
+		Labels:      req.Labels,
+	})
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to create snapshot")
+	}
+	if err := p.waitForAction(ctx, result.Action); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	// The size is only known once the snapshot is available
+	image, _, err := p.client.Image.GetByID(ctx, result.Image.ID)
+	if err != nil || image == nil {
+		image = result.Image
+	}
+	return convertSnapshot(image), nil
+}
+
+// ListSnapshots lists all snapshots with optional label filters
+func (p *Provider) ListSnapshots(ctx context.Context, filters map[string]string) ([]*machine.Snapshot, error) {
+	opts := hcloud.ImageListOpts{Type: []hcloud.ImageType{hcloud.ImageTypeSnapshot}}
+	if len(filters) > 0 {
+		opts.LabelSelector = formatLabelSelector(filters)
+	}
+
+	images, err := p.client.Image.AllWithOpts(ctx, opts)
+	if err != nil {
+		return nil, wrapAuthError(err, "failed to list snapshots")
+	}
+
+	result := make([]*machine.Snapshot, len(images))
+	for i, image := range images {
+		result[i] = convertSnapshot(image)
+	}
+	return result, nil
+}
+
+// DeleteSnapshot removes a snapshot
+func (p *Provider) DeleteSnapshot(ctx context.Context, snapshotID string) error {
+	image, _, err := p.client.Image.GetByID(ctx, parseServerID(snapshotID))
+	if err != nil {
+		return wrapAuthError(err, "failed to get snapshot")
+	}
+	if image == nil {
+		return fmt.Errorf("snapshot not found: %s", snapshotID)
+	}
+	if image.Type != hcloud.ImageTypeSnapshot {
+		return fmt.Errorf("image %s is not a snapshot", snapshotID)
+	}
+
+	if _, err := p.client.Image.Delete(ctx, image); err != nil {
+		return wrapAuthError(err, "failed to delete snapshot")
+	}
+	return nil
+}
+
+func convertSnapshot(image *hcloud.Image) *machine.Snapshot {
+	result := &machine.Snapshot{
+		ID:          fmt.Sprintf("%d", image.ID),
+		Description: image.Description,
+		SizeGB:      float64(image.ImageSize),
+		Created:     image.Created,
+		Labels:      image.Labels,
+	}
+	if image.CreatedFrom != nil {
+		result.ServerID = fmt.Sprintf("%d", image.CreatedFrom.ID)
+	}
+	return result
+}
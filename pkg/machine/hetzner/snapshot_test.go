@@ -0,0 +1,61 @@
+package hetzner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+func TestSnapshots(t *testing.T) {
+	p, _ := newTestProvider(t)
+	ctx := context.Background()
+
+	server, err := p.CreateServer(ctx, machine.CreateServerRequest{
+		Name: "f1-node-1", ServerType: "cx22", Image: "ubuntu-24.04", Location: "fsn1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := p.CreateSnapshot(ctx, machine.CreateSnapshotRequest{
+		ServerID:    server.ID,
+		Description: "f1-node-1 backup",
+		Labels:      map[string]string{"forest-id": "f1"},
+	})
+	if err != nil {
+		t.Fatalf("CreateSnapshot() error = %v", err)
+	}
+	if snap.ServerID != server.ID || snap.Description != "f1-node-1 backup" || snap.SizeGB == 0 {
+		t.Errorf("CreateSnapshot() = %+v", snap)
+	}
+
+	// Snapshots have no name; servers are created from them by ID
+	restored, err := p.CreateServer(ctx, machine.CreateServerRequest{
+		Name: "f1-node-1-restored", ServerType: "cx22", Image: snap.ID, Location: "fsn1",
+	})
+	if err != nil {
+		t.Fatalf("CreateServer() from snapshot error = %v", err)
+	}
+	if restored.Name != "f1-node-1-restored" {
+		t.Errorf("CreateServer() from snapshot = %+v", restored)
+	}
+
+	snaps, err := p.ListSnapshots(ctx, map[string]string{"forest-id": "f1"})
+	if err != nil || len(snaps) != 1 || snaps[0].ID != snap.ID {
+		t.Fatalf("ListSnapshots() = %+v, %v", snaps, err)
+	}
+	if snaps, _ := p.ListSnapshots(ctx, map[string]string{"forest-id": "f2"}); len(snaps) != 0 {
+		t.Errorf("ListSnapshots(f2) = %+v", snaps)
+	}
+
+	if err := p.DeleteSnapshot(ctx, "1"); err == nil {
+		t.Error("DeleteSnapshot() of a system image succeeded")
+	}
+	if err := p.DeleteSnapshot(ctx, snap.ID); err != nil {
+		t.Fatalf("DeleteSnapshot() error = %v", err)
+	}
+	if snaps, _ := p.ListSnapshots(ctx, nil); len(snaps) != 0 {
+		t.Errorf("ListSnapshots() after delete = %+v", snaps)
+	}
+}
@@ -2,8 +2,15 @@ package machine
 
 import (
 	"context"
+	"errors"
+	"time"
 )
 
+// ErrNotFound is wrapped by the errors providers return for servers (and
+// other resources) that do not exist, e.g. because they were deleted
+// outside of morpheus
+var ErrNotFound = errors.New("not found")
+
 // Provider defines the interface for cloud infrastructure providers
 type Provider interface {
 	// CreateServer provisions a new server
@@ -39,6 +46,308 @@ type LocationAwareProvider interface {
 	FilterLocationsByServerType(ctx context.Context, locations []string, serverTypeName string) ([]string, []string, error)
 }
 
+// LabelUpdater is implemented by providers that can change the labels of an
+// existing server
+type LabelUpdater interface {
+	// UpdateLabels replaces all labels of a server
+	UpdateLabels(ctx context.Context, serverID string, labels map[string]string) error
+}
+
+// VolumeManager is implemented by providers that can create persistent
+// block volumes and attach them to servers
+type VolumeManager interface {
+	// CreateVolume creates an unattached volume
+	CreateVolume(ctx context.Context, req CreateVolumeRequest) (*Volume, error)
+
+	// ListVolumes lists all volumes with optional label filters
+	ListVolumes(ctx context.Context, filters map[string]string) ([]*Volume, error)
+
+	// DeleteVolume detaches (if needed) and removes a volume
+	DeleteVolume(ctx context.Context, volumeID string) error
+}
+
+// CreateVolumeRequest contains parameters for volume creation
+type CreateVolumeRequest struct {
+	Name     string
+	SizeGB   int
+	Format   string // Filesystem to format the volume with (e.g., ext4, xfs)
+	Location string
+	Labels   map[string]string
+}
+
+// Volume represents a persistent block volume
+type Volume struct {
+	ID       string
+	Name     string
+	SizeGB   int
+	Location string
+	Device   string // Linux device path on the attached server
+	ServerID string // Empty when the volume is not attached
+	Labels   map[string]string
+}
+
+// LoadBalancerManager is implemented by providers that offer managed
+// load balancers
+type LoadBalancerManager interface {
+	// CreateLoadBalancer creates a load balancer whose targets are all
+	// servers matching req.TargetSelector, now and in the future
+	CreateLoadBalancer(ctx context.Context, req CreateLoadBalancerRequest) (*LoadBalancer, error)
+
+	// ListLoadBalancers lists all load balancers with optional label filters
+	ListLoadBalancers(ctx context.Context, filters map[string]string) ([]*LoadBalancer, error)
+
+	// DeleteLoadBalancer removes a load balancer
+	DeleteLoadBalancer(ctx context.Context, loadBalancerID string) error
+}
+
+// CreateLoadBalancerRequest contains parameters for load balancer creation
+type CreateLoadBalancerRequest struct {
+	Name           string
+	Type           string // Provider-specific type (e.g., lb11)
+	Location       string
+	Labels         map[string]string
+	TargetSelector map[string]string // Servers with these labels become targets
+	Services       []LoadBalancerService
+}
+
+// LoadBalancerService forwards one port to the targets
+type LoadBalancerService struct {
+	Protocol        string `json:"protocol"` // tcp or http
+	ListenPort      int    `json:"listen_port"`
+	DestinationPort int    `json:"destination_port"`
+	HealthCheckPath string `json:"health_check_path,omitempty"` // HTTP path; TCP connect check if empty
+}
+
+// LoadBalancer represents a provisioned load balancer
+type LoadBalancer struct {
+	ID         string
+	Name       string
+	PublicIPv4 string
+	PublicIPv6 string
+	Location   string
+	Labels     map[string]string
+	Targets    int // Number of targets currently registered
+	Healthy    int // Number of targets passing all health checks
+}
+
+// FloatingIPManager is implemented by providers that offer floating IPs,
+// addresses that can be moved between servers for failover
+type FloatingIPManager interface {
+	// CreateFloatingIP allocates a floating IP, optionally assigned to a server
+	CreateFloatingIP(ctx context.Context, req CreateFloatingIPRequest) (*FloatingIP, error)
+
+	// ListFloatingIPs lists all floating IPs with optional label filters
+	ListFloatingIPs(ctx context.Context, filters map[string]string) ([]*FloatingIP, error)
+
+	// AssignFloatingIP routes a floating IP to a server
+	AssignFloatingIP(ctx context.Context, floatingIPID, serverID string) error
+
+	// DeleteFloatingIP releases a floating IP
+	DeleteFloatingIP(ctx context.Context, floatingIPID string) error
+}
+
+// CreateFloatingIPRequest contains parameters for allocating a floating IP
+type CreateFloatingIPRequest struct {
+	Name     string
+	Type     string // "ipv4" or "ipv6"
+	Location string // Home location
+	ServerID string // Optional initial assignment
+	Labels   map[string]string
+}
+
+// FloatingIP represents a floating IP address
+type FloatingIP struct {
+	ID       string
+	Name     string
+	Type     string // "ipv4" or "ipv6"
+	IP       string // The address servers configure (::1 of the /64 for IPv6)
+	Location string
+	ServerID string // Server the IP is assigned to, empty if unassigned
+	Labels   map[string]string
+}
+
+// PlacementGroupManager is implemented by providers that can spread
+// servers across physical hosts
+type PlacementGroupManager interface {
+	// CreatePlacementGroup creates a spread placement group
+	CreatePlacementGroup(ctx context.Context, req CreatePlacementGroupRequest) (*PlacementGroup, error)
+
+	// ListPlacementGroups lists all placement groups with optional label filters
+	ListPlacementGroups(ctx context.Context, filters map[string]string) ([]*PlacementGroup, error)
+
+	// DeletePlacementGroup removes a placement group once its servers are gone
+	DeletePlacementGroup(ctx context.Context, placementGroupID string) error
+}
+
+// CreatePlacementGroupRequest contains parameters for placement group creation
+type CreatePlacementGroupRequest struct {
+	Name   string
+	Labels map[string]string
+}
+
+// PlacementGroup keeps its servers on different physical hosts
+type PlacementGroup struct {
+	ID      string
+	Name    string
+	Servers []string // IDs of the servers in the group
+	Labels  map[string]string
+}
+
+// SSHKeyManager is implemented by providers that store SSH keys servers
+// are created with
+type SSHKeyManager interface {
+	// ListSSHKeys lists all SSH keys of the account
+	ListSSHKeys(ctx context.Context) ([]*SSHKey, error)
+
+	// DeleteSSHKey removes an SSH key by name
+	DeleteSSHKey(ctx context.Context, keyName string) error
+}
+
+// SSHKey is an SSH key stored at the provider
+type SSHKey struct {
+	ID          string
+	Name        string
+	Fingerprint string
+	Labels      map[string]string
+}
+
+// SnapshotManager is implemented by providers that can snapshot a server's
+// disk into an image that new servers can be created from, by passing the
+// snapshot ID as CreateServerRequest.Image
+type SnapshotManager interface {
+	// CreateSnapshot snapshots a server's disk and waits until the
+	// snapshot is available
+	CreateSnapshot(ctx context.Context, req CreateSnapshotRequest) (*Snapshot, error)
+
+	// ListSnapshots lists all snapshots with optional label filters
+	ListSnapshots(ctx context.Context, filters map[string]string) ([]*Snapshot, error)
+
+	// DeleteSnapshot removes a snapshot
+	DeleteSnapshot(ctx context.Context, snapshotID string) error
+}
+
+// PowerManager is implemented by providers that can control the power of
+// servers. These are hard actions, like pressing the server's buttons;
+// graceful shutdowns go through the operating system.
+type PowerManager interface {
+	// RebootServer resets a server and waits until it is started again
+	RebootServer(ctx context.Context, serverID string) error
+
+	// PowerOffServer cuts a server's power and waits until it is off
+	PowerOffServer(ctx context.Context, serverID string) error
+
+	// PowerOnServer starts a server that is off
+	PowerOnServer(ctx context.Context, serverID string) error
+}
+
+// ServerResizer is implemented by providers that can change the type of an
+// existing server
+type ServerResizer interface {
+	// ResizeServer changes the type of a server, which must be powered
+	// off. upgradeDisk also grows its disk to the new type's, after which
+	// it cannot be resized to a type with a smaller disk.
+	ResizeServer(ctx context.Context, serverID, serverType string, upgradeDisk bool) error
+}
+
+// CreateSnapshotRequest contains parameters for snapshot creation
+type CreateSnapshotRequest struct {
+	ServerID    string
+	Description string
+	Labels      map[string]string
+}
+
+// Snapshot is an image of a server's disk
+type Snapshot struct {
+	ID          string
+	Description string
+	ServerID    string  // Server the snapshot was taken of
+	SizeGB      float64 // Billed size (0 while unknown)
+	Created     time.Time
+	Labels      map[string]string
+}
+
+// FirewallManager is implemented by providers that offer firewalls
+type FirewallManager interface {
+	// ListFirewalls lists all firewalls with optional label filters
+	ListFirewalls(ctx context.Context, filters map[string]string) ([]*Firewall, error)
+
+	// DeleteFirewall removes a firewall, detaching it from its servers
+	DeleteFirewall(ctx context.Context, firewallID string) error
+}
+
+// Firewall is a provider firewall
+type Firewall struct {
+	ID      string
+	Name    string
+	Servers []string // IDs of the servers it applies to
+	Labels  map[string]string
+}
+
+// CostReporter is implemented by providers that can price the resources
+// currently billed to the account
+type CostReporter interface {
+	// ListCosts returns every billable resource in the account with its
+	// current monthly price
+	ListCosts(ctx context.Context) ([]CostItem, error)
+}
+
+// CostItem is one billable resource and its monthly price
+type CostItem struct {
+	Kind        string            `json:"kind"` // server, volume, primary_ip, floating_ip, load_balancer
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels,omitempty"` // IPs carry their server's labels
+	MonthlyCost float64           `json:"monthly_cost"`     // Net, in the account currency
+	Attached    bool              `json:"attached"`         // False for volumes and IPs not in use by a server
+}
+
+// HoursPerMonth converts monthly prices to hourly ones for resources that
+// are only priced per month
+const HoursPerMonth = 730
+
+// CostEstimator is implemented by providers that can price resources
+// before they are created, from their current price list
+type CostEstimator interface {
+	// EstimateCost prices the resources of a forest
+	EstimateCost(ctx context.Context, req CostEstimateRequest) (*CostEstimate, error)
+}
+
+// CostEstimateRequest describes the resources of a forest to price
+type CostEstimateRequest struct {
+	Nodes        int
+	ServerType   string
+	Location     string // Empty for the cheapest location offering the server type
+	IPv4         bool   // A primary IPv4 address per node
+	VolumeSizeGB int    // Volume per node (0 = none)
+	LoadBalancer string // Load balancer type (empty = none)
+	FloatingIP   string // "ipv4", "ipv6" or empty for none
+}
+
+// CostEstimate is the price of a forest's resources
+type CostEstimate struct {
+	Location string     `json:"location"`
+	Currency string     `json:"currency"`
+	Lines    []CostLine `json:"lines"`
+	Hourly   float64    `json:"hourly"`
+	Monthly  float64    `json:"monthly"` // Net, capped at the monthly price where the provider caps it
+}
+
+// CostLine is the price of one kind of resource, for all of its quantity
+type CostLine struct {
+	Item     string  `json:"item"` // e.g. "server cx32", "volume 50 GB"
+	Quantity int     `json:"quantity"`
+	Hourly   float64 `json:"hourly"`
+	Monthly  float64 `json:"monthly"`
+}
+
+// Add appends a line and adds it to the totals
+func (e *CostEstimate) Add(item string, quantity int, unitHourly, unitMonthly float64) {
+	line := CostLine{Item: item, Quantity: quantity, Hourly: unitHourly * float64(quantity), Monthly: unitMonthly * float64(quantity)}
+	e.Lines = append(e.Lines, line)
+	e.Hourly += line.Hourly
+	e.Monthly += line.Monthly
+}
+
 // CreateServerRequest contains parameters for server creation
 type CreateServerRequest struct {
 	Name       string
@@ -51,6 +360,11 @@ type CreateServerRequest struct {
 	// EnableIPv4 enables IPv4 in addition to IPv6
 	// By default, servers are IPv6-only to save costs (IPv4 costs extra on Hetzner)
 	EnableIPv4 bool
+	// Volumes are the IDs of volumes to attach when the server is created
+	Volumes []string
+	// PlacementGroup is the ID of a placement group to put the server in.
+	// Providers create the server outside the group when it is full.
+	PlacementGroup string
 }
 
 // Server represents a provisioned server
@@ -63,6 +377,10 @@ type Server struct {
 	State      ServerState
 	Labels     map[string]string
 	CreatedAt  string
+	HostKey    string // SSH host public key, if known
+	ServerType string // Provider-specific server type, if known
+	Image      string // Image the server was created from, if known
+	UserData   string // SHA-256 (hex) of the cloud-init user data it was created with, if known
 }
 
 // GetPreferredIP returns the preferred IP address for connectivity.
@@ -0,0 +1,41 @@
+package machine
+
+import "net/netip"
+
+// HostIPv6 returns the address a server uses within the IPv6 network it was
+// assigned. Hetzner assigns each server (and IPv6 floating IP) a /64 and
+// reports its network address, e.g. 2001:db8:1:2::, while the server
+// configures ::1 within it, 2001:db8:1:2::1. The Hetzner providers record
+// servers with this address, so the registry, DNS records and SSH targets
+// agree on it.
+//
+// Networks are recognised by an all-zero interface identifier, with or
+// without a prefix length (2001:db8:1:2::/64). Host addresses are returned
+// in canonical form; anything else (IPv4, unparseable input) is returned
+// unchanged.
+func HostIPv6(addr string) string {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		prefix, perr := netip.ParsePrefix(addr)
+		if perr != nil {
+			return addr
+		}
+		ip = prefix.Addr()
+	}
+	if !ip.Is6() || ip.Is4In6() || ip.Zone() != "" {
+		return addr
+	}
+
+	b := ip.As16()
+	network := true
+	for _, v := range b[8:] {
+		network = network && v == 0
+	}
+	if network {
+		if ip.IsUnspecified() {
+			return addr // ::, not a network anyone is assigned
+		}
+		b[15] = 1
+	}
+	return netip.AddrFrom16(b).String()
+}
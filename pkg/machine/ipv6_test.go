@@ -0,0 +1,31 @@
+package machine
+
+import "testing"
+
+func TestHostIPv6(t *testing.T) {
+	tests := []struct {
+		addr, want string
+	}{
+		{"2a01:4f8:c17:1234::", "2a01:4f8:c17:1234::1"},
+		{"2a01:4f8:c17:1234::/64", "2a01:4f8:c17:1234::1"},
+		{"2a01:4f8:c17:1234:0:0:0:0", "2a01:4f8:c17:1234::1"},
+		{"2A01:4F8:C17:1234::", "2a01:4f8:c17:1234::1"},
+		{"2a01:4f8:c17:1234::1", "2a01:4f8:c17:1234::1"},
+		{"2a01:4f8:c17:1234::1/64", "2a01:4f8:c17:1234::1"},
+		{"2a01:4f8:c17:1234::42", "2a01:4f8:c17:1234::42"},
+		{"2001:db8:0:0:1::", "2001:db8:0:0:1::"}, // Ends in :: but the interface ID is not zero
+		{"2001:db8::", "2001:db8::1"},
+		{"::", "::"},
+		{"::ffff:192.0.2.1", "::ffff:192.0.2.1"},
+		{"fe80::%eth0", "fe80::%eth0"},
+		{"192.0.2.1", "192.0.2.1"},
+		{"node.example.com", "node.example.com"},
+		{"[2001:db8::]", "[2001:db8::]"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := HostIPv6(tt.addr); got != tt.want {
+			t.Errorf("HostIPv6(%q) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
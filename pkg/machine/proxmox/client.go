@@ -180,12 +180,20 @@ func (c *Client) GetVMConfig(ctx context.Context, vmid int) (*VMConfig, error) {
 		return nil, fmt.Errorf("parse config: %w", err)
 	}
 
-	// Extract hostpci devices
+	// Extract hostpci devices and drives
 	for key, val := range rawConfig {
+		strVal, ok := val.(string)
+		if !ok {
+			continue
+		}
 		if strings.HasPrefix(key, "hostpci") {
-			if strVal, ok := val.(string); ok {
-				config.HostPCI = append(config.HostPCI, strVal)
+			config.HostPCI = append(config.HostPCI, strVal)
+		}
+		if isDriveKey(key) {
+			if config.Drives == nil {
+				config.Drives = make(map[string]string)
 			}
+			config.Drives[key] = strVal
 		}
 	}
 
@@ -250,6 +258,88 @@ func (c *Client) ShutdownVM(ctx context.Context, vmid int, timeout int) (string,
 	return upid, nil
 }
 
+// CreateSnapshot takes a disk snapshot of a VM (without RAM state)
+func (c *Client) CreateSnapshot(ctx context.Context, vmid int, name, description string) (string, error) {
+	path := fmt.Sprintf("/nodes/%s/qemu/%d/snapshot", c.node, vmid)
+
+	params := url.Values{}
+	params.Set("snapname", name)
+	if description != "" {
+		params.Set("description", description)
+	}
+
+	data, err := c.request(ctx, http.MethodPost, path, params)
+	if err != nil {
+		return "", err
+	}
+
+	var upid string
+	if err := json.Unmarshal(data, &upid); err != nil {
+		return "", fmt.Errorf("parse UPID: %w", err)
+	}
+
+	return upid, nil
+}
+
+// ListSnapshots returns the snapshots of a VM
+func (c *Client) ListSnapshots(ctx context.Context, vmid int) ([]*Snapshot, error) {
+	path := fmt.Sprintf("/nodes/%s/qemu/%d/snapshot", c.node, vmid)
+
+	data, err := c.request(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var all []*Snapshot
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("parse snapshots: %w", err)
+	}
+
+	// Skip the "current" pseudo-snapshot (the running state)
+	snapshots := make([]*Snapshot, 0, len(all))
+	for _, snap := range all {
+		if snap.Name != "current" {
+			snapshots = append(snapshots, snap)
+		}
+	}
+
+	return snapshots, nil
+}
+
+// RollbackSnapshot rolls a VM back to a snapshot
+func (c *Client) RollbackSnapshot(ctx context.Context, vmid int, name string) (string, error) {
+	path := fmt.Sprintf("/nodes/%s/qemu/%d/snapshot/%s/rollback", c.node, vmid, url.PathEscape(name))
+
+	data, err := c.request(ctx, http.MethodPost, path, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var upid string
+	if err := json.Unmarshal(data, &upid); err != nil {
+		return "", fmt.Errorf("parse UPID: %w", err)
+	}
+
+	return upid, nil
+}
+
+// DeleteSnapshot deletes a snapshot of a VM
+func (c *Client) DeleteSnapshot(ctx context.Context, vmid int, name string) (string, error) {
+	path := fmt.Sprintf("/nodes/%s/qemu/%d/snapshot/%s", c.node, vmid, url.PathEscape(name))
+
+	data, err := c.request(ctx, http.MethodDelete, path, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var upid string
+	if err := json.Unmarshal(data, &upid); err != nil {
+		return "", fmt.Errorf("parse UPID: %w", err)
+	}
+
+	return upid, nil
+}
+
 // GetTaskStatus returns the status of an async task
 func (c *Client) GetTaskStatus(ctx context.Context, upid string) (*TaskStatus, error) {
 	// Extract node from UPID (format: UPID:node:...)
@@ -366,6 +456,53 @@ func (c *Client) GetVMIPs(ctx context.Context, vmid int) ([]string, error) {
 	return ips, nil
 }
 
+// GetTaskLog returns the log lines of an async task
+func (c *Client) GetTaskLog(ctx context.Context, upid string) ([]string, error) {
+	parts := strings.Split(upid, ":")
+	node := c.node
+	if len(parts) >= 2 {
+		node = parts[1]
+	}
+
+	path := fmt.Sprintf("/nodes/%s/tasks/%s/log?limit=500", node, url.PathEscape(upid))
+
+	data, err := c.request(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []struct {
+		N int    `json:"n"`
+		T string `json:"t"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parse task log: %w", err)
+	}
+
+	lines := make([]string, 0, len(entries))
+	for _, e := range entries {
+		lines = append(lines, e.T)
+	}
+	return lines, nil
+}
+
+// ListPCIDevices returns the PCI devices of the configured node
+func (c *Client) ListPCIDevices(ctx context.Context) ([]*PCIDevice, error) {
+	path := fmt.Sprintf("/nodes/%s/hardware/pci", c.node)
+
+	data, err := c.request(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []*PCIDevice
+	if err := json.Unmarshal(data, &devices); err != nil {
+		return nil, fmt.Errorf("parse PCI devices: %w", err)
+	}
+
+	return devices, nil
+}
+
 // GetNodes returns all nodes in the cluster
 func (c *Client) GetNodes(ctx context.Context) ([]*Node, error) {
 	data, err := c.request(ctx, http.MethodGet, "/nodes", nil)
@@ -386,3 +523,102 @@ func (c *Client) Ping(ctx context.Context) error {
 	_, err := c.GetNodes(ctx)
 	return err
 }
+
+// NextVMID returns a free VMID of the cluster
+func (c *Client) NextVMID(ctx context.Context) (int, error) {
+	data, err := c.request(ctx, http.MethodGet, "/cluster/nextid", nil)
+	if err != nil {
+		return 0, err
+	}
+
+	// Returned as a string by most versions, as a number by some
+	var id json.Number
+	if err := json.Unmarshal(data, &id); err != nil {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return 0, fmt.Errorf("parse next VMID: %w", err)
+		}
+		id = json.Number(s)
+	}
+	vmid, err := id.Int64()
+	if err != nil {
+		return 0, fmt.Errorf("parse next VMID: %w", err)
+	}
+	return int(vmid), nil
+}
+
+// CloneVM clones a VM or template into a new VM. A full clone copies the
+// disks, to storage if set; a linked clone shares the template's disks.
+func (c *Client) CloneVM(ctx context.Context, vmid, newID int, name string, full bool, storage string) (string, error) {
+	path := fmt.Sprintf("/nodes/%s/qemu/%d/clone", c.node, vmid)
+
+	params := url.Values{}
+	params.Set("newid", fmt.Sprintf("%d", newID))
+	params.Set("name", name)
+	if full {
+		params.Set("full", "1")
+		if storage != "" {
+			params.Set("storage", storage)
+		}
+	} else {
+		params.Set("full", "0")
+	}
+
+	data, err := c.request(ctx, http.MethodPost, path, params)
+	if err != nil {
+		return "", err
+	}
+
+	var upid string
+	if err := json.Unmarshal(data, &upid); err != nil {
+		return "", fmt.Errorf("parse UPID: %w", err)
+	}
+
+	return upid, nil
+}
+
+// UpdateVMConfig sets options of a VM's configuration
+func (c *Client) UpdateVMConfig(ctx context.Context, vmid int, params url.Values) error {
+	path := fmt.Sprintf("/nodes/%s/qemu/%d/config", c.node, vmid)
+
+	_, err := c.request(ctx, http.MethodPut, path, params)
+	return err
+}
+
+// ResizeDisk sets the size of a disk of a VM, e.g. "40G". Disks can only
+// grow. It returns the UPID of the task, or "" if it completed at once.
+func (c *Client) ResizeDisk(ctx context.Context, vmid int, disk, size string) (string, error) {
+	path := fmt.Sprintf("/nodes/%s/qemu/%d/resize", c.node, vmid)
+
+	params := url.Values{}
+	params.Set("disk", disk)
+	params.Set("size", size)
+
+	data, err := c.request(ctx, http.MethodPut, path, params)
+	if err != nil {
+		return "", err
+	}
+
+	// Older versions resize synchronously and return null
+	var upid string
+	_ = json.Unmarshal(data, &upid)
+	return upid, nil
+}
+
+// DeleteVM destroys a stopped VM with its disks, and removes it from
+// backup jobs and HA
+func (c *Client) DeleteVM(ctx context.Context, vmid int) (string, error) {
+	path := fmt.Sprintf("/nodes/%s/qemu/%d?purge=1&destroy-unreferenced-disks=1", c.node, vmid)
+
+	data, err := c.request(ctx, http.MethodDelete, path, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var upid string
+	if err := json.Unmarshal(data, &upid); err != nil {
+		return "", fmt.Errorf("parse UPID: %w", err)
+	}
+
+	return upid, nil
+}
@@ -0,0 +1,252 @@
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// morpheusTag marks the VMs CreateServer made
+const morpheusTag = "morpheus"
+
+// labelsPrefix starts the line of a VM's description with its labels
+const labelsPrefix = "morpheus-labels: "
+
+// templateID returns the VMID of a template given by VMID or name
+func (p *Provider) templateID(ctx context.Context, template string) (int, error) {
+	if vmid, err := strconv.Atoi(template); err == nil {
+		return vmid, nil
+	}
+	vms, err := p.client.ListVMs(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, vm := range vms {
+		if vm.Template && vm.Name == template {
+			return vm.VMID, nil
+		}
+	}
+	return 0, fmt.Errorf("no proxmox template named %s on node %s", template, p.client.node)
+}
+
+// waitTask waits for a task and fails if it did not succeed
+func (p *Provider) waitTask(ctx context.Context, upid, what string) error {
+	status, err := p.client.WaitForTask(ctx, upid, p.pollInterval)
+	if err != nil {
+		return fmt.Errorf("%s: %w", what, err)
+	}
+	if !status.IsSuccessful() {
+		return fmt.Errorf("%s failed: %s", what, status.ExitStatus)
+	}
+	return nil
+}
+
+// interval returns how often to poll
+func (p *Provider) interval() time.Duration {
+	if p.pollInterval > 0 {
+		return p.pollInterval
+	}
+	return time.Second
+}
+
+// getGuest returns the VM, or with type lxc the container, with a VMID
+func (p *Provider) getGuest(ctx context.Context, vmid int) (*VM, error) {
+	if p.isLXC() {
+		return p.client.GetContainer(ctx, vmid)
+	}
+	return p.client.GetVM(ctx, vmid)
+}
+
+// guestGone reports whether the node has no VM, or with type lxc no
+// container, with a VMID, telling a deleted guest from failures to get it
+func (p *Provider) guestGone(ctx context.Context, vmid int) bool {
+	var vms []*VM
+	var err error
+	if p.isLXC() {
+		vms, err = p.client.ListContainers(ctx)
+	} else {
+		vms, err = p.client.ListVMs(ctx)
+	}
+	if err != nil {
+		return false
+	}
+	for _, vm := range vms {
+		if vm.VMID == vmid {
+			return false
+		}
+	}
+	return true
+}
+
+// guestIPs returns the IPv4 addresses of a running VM or container
+func (p *Provider) guestIPs(ctx context.Context, vmid int) ([]string, error) {
+	if p.isLXC() {
+		return p.client.GetContainerIPs(ctx, vmid)
+	}
+	return p.client.GetVMIPs(ctx, vmid)
+}
+
+// describe fills in the description and, if it runs, the IPs of a VM or
+// container, which the list and status leave out
+func (p *Provider) describe(ctx context.Context, vm *VM) error {
+	if p.isLXC() {
+		config, err := p.client.GetContainerConfig(ctx, vm.VMID)
+		if err != nil {
+			return err
+		}
+		vm.Description = config.Description
+		if vm.Name == "" {
+			vm.Name = config.Hostname
+		}
+	} else {
+		config, err := p.client.GetVMConfig(ctx, vm.VMID)
+		if err != nil {
+			return err
+		}
+		vm.Description = config.Description
+		if vm.Name == "" {
+			vm.Name = config.Name
+		}
+	}
+	if vm.Status == VMStatusRunning {
+		vm.IPs, _ = p.guestIPs(ctx, vm.VMID)
+	}
+	return nil
+}
+
+// labelsDescription returns the description of a VM with labels
+func labelsDescription(labels map[string]string) string {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	data, _ := json.Marshal(labels)
+	return "Created by morpheus\n" + labelsPrefix + string(data)
+}
+
+// parseLabels returns the labels in a VM's description, and whether it has
+// them, meaning the VM was created by morpheus
+func parseLabels(description string) (map[string]string, bool) {
+	for _, line := range strings.Split(description, "\n") {
+		data, ok := strings.CutPrefix(strings.TrimSpace(line), labelsPrefix)
+		if !ok {
+			continue
+		}
+		var labels map[string]string
+		if err := json.Unmarshal([]byte(data), &labels); err != nil {
+			return nil, false
+		}
+		return labels, true
+	}
+	return nil, false
+}
+
+// hasTag reports whether a VM's tags, separated by ';' or ',', include tag
+func hasTag(tags, tag string) bool {
+	for _, t := range strings.FieldsFunc(tags, func(r rune) bool { return r == ';' || r == ',' }) {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// sshPublicKeys returns the keys to authorize on new VMs: the configured
+// ones and those of the request that are public keys rather than names
+func (p *Provider) sshPublicKeys(requested []string) []string {
+	keys := append([]string(nil), p.config.SSHPublicKeys...)
+	for _, key := range requested {
+		if strings.HasPrefix(key, "ssh-") || strings.HasPrefix(key, "ecdsa-") || strings.HasPrefix(key, "sk-") {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// withSSHKeys adds keys to cloud-config user data that authorizes none, for
+// the default user and root. Proxmox only adds its own keys to the user
+// data it generates, not to custom user data.
+func withSSHKeys(userData string, keys []string) string {
+	if len(keys) == 0 || !strings.HasPrefix(userData, "#cloud-config") || strings.Contains(userData, "ssh_authorized_keys:") {
+		return userData
+	}
+	var b strings.Builder
+	b.WriteString(userData)
+	if !strings.HasSuffix(userData, "\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString("\n# Added by morpheus\n")
+	if !strings.Contains(userData, "disable_root:") {
+		b.WriteString("disable_root: false\n")
+	}
+	b.WriteString("ssh_authorized_keys:\n")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "  - %s\n", strings.TrimSpace(key))
+	}
+	return b.String()
+}
+
+// snippetName returns the file name of a VM's user data snippet
+func snippetName(vmid int) string {
+	return fmt.Sprintf("morpheus-%d-user.yaml", vmid)
+}
+
+// snippetDir returns the directory of the snippet storage on the host
+func (p *Provider) snippetDir() string {
+	if p.config.SnippetDir != "" {
+		return p.config.SnippetDir
+	}
+	return "/var/lib/vz/snippets"
+}
+
+// uploadSnippet writes user data to the host's snippet storage over SSH,
+// as the API cannot, and returns its volume ID for cicustom
+func (p *Provider) uploadSnippet(ctx context.Context, vmid int, userData string) (string, error) {
+	file := path.Join(p.snippetDir(), snippetName(vmid))
+	command := fmt.Sprintf("mkdir -p %s && cat > %s", shellQuote(p.snippetDir()), shellQuote(file))
+	if err := p.runSSH(ctx, command, userData); err != nil {
+		return "", fmt.Errorf("upload cloud-init user data: %w", err)
+	}
+	storage := p.config.SnippetStorage
+	if storage == "" {
+		storage = "local"
+	}
+	return storage + ":snippets/" + snippetName(vmid), nil
+}
+
+// removeSnippet removes a VM's user data snippet from the host
+func (p *Provider) removeSnippet(ctx context.Context, vmid int) error {
+	return p.runSSH(ctx, "rm -f "+shellQuote(path.Join(p.snippetDir(), snippetName(vmid))), "")
+}
+
+// runSSH runs a command on the Proxmox host with stdin as its input
+func (p *Provider) runSSH(ctx context.Context, command, stdin string) error {
+	user := p.config.SSHUser
+	if user == "" {
+		user = "root"
+	}
+	args := []string{"-o", "BatchMode=yes", "-o", "StrictHostKeyChecking=accept-new", "-o", "ConnectTimeout=10"}
+	if p.config.SSHIdentity != "" {
+		args = append(args, "-i", p.config.SSHIdentity)
+	}
+	args = append(args, user+"@"+p.config.Host, command)
+
+	cmd := exec.CommandContext(ctx, p.sshBinary, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("ssh %s@%s: %w: %s", user, p.config.Host, err, msg)
+		}
+		return fmt.Errorf("ssh %s@%s: %w", user, p.config.Host, err)
+	}
+	return nil
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,437 @@
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+type fakeVM struct {
+	name      string
+	status    string
+	template  bool
+	container bool
+	tags      string
+	config    map[string]string
+}
+
+// fakeProxmox serves the parts of the Proxmox API used to create VMs
+type fakeProxmox struct {
+	mu      sync.Mutex
+	vms     map[int]*fakeVM
+	nextID  int
+	actions []string
+}
+
+func newFakeProvider(t *testing.T, fake *fakeProxmox) (*Provider, string) {
+	t.Helper()
+	server := httptest.NewTLSServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(server.Close)
+
+	// The fake ssh logs its arguments and stores its input
+	dir := t.TempDir()
+	ssh := filepath.Join(dir, "ssh")
+	script := "#!/bin/sh\necho \"$@\" >> " + dir + "/ssh.log\ncat > " + dir + "/stdin\n"
+	if err := os.WriteFile(ssh, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "https://"))
+	portNum, _ := strconv.Atoi(port)
+	p, err := NewProvider(ProviderConfig{
+		Host:           host,
+		Port:           portNum,
+		Node:           "pve",
+		APITokenID:     "test@pam!token",
+		APITokenSecret: "secret",
+		Template:       "ubuntu-cloudinit",
+		SSHPublicKeys:  []string{"ssh-ed25519 AAAAtest morpheus"},
+		Sizes:          map[string]Size{"small": {Cores: 2, MemoryMB: 2048}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.sshBinary = ssh
+	p.pollInterval = 10 * time.Millisecond
+	return p, dir
+}
+
+func (f *fakeProxmox) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	reply := func(data interface{}) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}
+	r.ParseForm()
+	if r.URL.Path == "/api2/json/cluster/nextid" {
+		reply(strconv.Itoa(f.nextID))
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/api2/json/nodes/pve")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	if (path == "/qemu" || path == "/lxc") && r.Method == http.MethodGet {
+		var list []map[string]interface{}
+		for id, vm := range f.vms {
+			if vm.container == (path == "/lxc") {
+				list = append(list, map[string]interface{}{"vmid": id, "name": vm.name, "status": vm.status, "template": vm.template, "tags": vm.tags})
+			}
+		}
+		reply(list)
+		return
+	}
+	if path == "/lxc" {
+		id, _ := strconv.Atoi(r.Form.Get("vmid"))
+		config := map[string]string{}
+		for k := range r.Form {
+			config[k] = r.Form.Get(k)
+		}
+		f.vms[id] = &fakeVM{status: "stopped", container: true, tags: r.Form.Get("tags"), config: config}
+		f.actions = append(f.actions, "POST lxc")
+		reply("UPID:pve:create")
+		return
+	}
+	if parts[0] == "tasks" {
+		reply(map[string]string{"status": "stopped", "exitstatus": "OK"})
+		return
+	}
+	if (parts[0] != "qemu" && parts[0] != "lxc") || len(parts) < 2 {
+		http.NotFound(w, r)
+		return
+	}
+	id, _ := strconv.Atoi(parts[1])
+	vm := f.vms[id]
+	if vm == nil || vm.container != (parts[0] == "lxc") {
+		http.Error(w, "no such guest", http.StatusInternalServerError)
+		return
+	}
+	action := strings.Join(parts[2:], "/")
+	f.actions = append(f.actions, r.Method+" "+strconv.Itoa(id)+" "+action)
+
+	switch r.Method + " " + action {
+	case "POST clone":
+		newID, _ := strconv.Atoi(r.Form.Get("newid"))
+		config := map[string]string{"name": r.Form.Get("name")}
+		for k, v := range vm.config {
+			if k != "name" {
+				config[k] = v
+			}
+		}
+		f.vms[newID] = &fakeVM{name: r.Form.Get("name"), status: "stopped", config: config}
+		reply("UPID:pve:clone")
+	case "GET config":
+		// Proxmox returns numbers for numeric options
+		config := make(map[string]interface{})
+		for k, v := range vm.config {
+			config[k] = v
+			if n, err := strconv.Atoi(v); err == nil {
+				config[k] = n
+			}
+		}
+		reply(config)
+	case "PUT config":
+		for k := range r.Form {
+			vm.config[k] = r.Form.Get(k)
+		}
+		if tags := r.Form.Get("tags"); tags != "" {
+			vm.tags = tags
+		}
+		reply(nil)
+	case "PUT resize":
+		vm.config[r.Form.Get("disk")] = "local-lvm:vm-disk,size=" + r.Form.Get("size")
+		reply("UPID:pve:resize")
+	case "POST status/start":
+		vm.status = "running"
+		reply("UPID:pve:start")
+	case "POST status/stop":
+		vm.status = "stopped"
+		reply("UPID:pve:stop")
+	case "GET status/current":
+		reply(map[string]interface{}{"name": vm.name, "status": vm.status})
+	case "GET interfaces":
+		reply([]map[string]string{{"name": "lo", "inet": "127.0.0.1/8"}, {"name": "eth0", "inet": "192.168.1.60/24"}})
+	case "GET agent/network-get-interfaces":
+		reply(map[string]interface{}{"result": []map[string]interface{}{{
+			"name":         "eth0",
+			"ip-addresses": []map[string]string{{"ip-address": "192.168.1.50", "ip-address-type": "ipv4"}},
+		}}})
+	case "DELETE ":
+		delete(f.vms, id)
+		reply("UPID:pve:destroy")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newFakeProxmox() *fakeProxmox {
+	return &fakeProxmox{
+		nextID: 120,
+		vms: map[int]*fakeVM{
+			9000: {name: "ubuntu-cloudinit", status: "stopped", template: true, config: map[string]string{
+				"name":  "ubuntu-cloudinit",
+				"boot":  "order=scsi0;net0",
+				"scsi0": "local-lvm:base-9000-disk-0,size=3584M",
+				"ide2":  "local-lvm:cloudinit,media=cdrom",
+			}},
+			101: {name: "vr-linux", status: "running", config: map[string]string{"name": "vr-linux"}},
+		},
+	}
+}
+
+func TestCreateServer(t *testing.T) {
+	fake := newFakeProxmox()
+	p, dir := newFakeProvider(t, fake)
+	ctx := context.Background()
+
+	server, err := p.CreateServer(ctx, machine.CreateServerRequest{
+		Name:       "forest-1-node-1",
+		ServerType: "2c-4g-20g",
+		SSHKeys:    []string{"morpheus"},
+		UserData:   "#cloud-config\npackages:\n  - curl\n",
+		Labels:     map[string]string{"managed-by": "morpheus", "forest-id": "forest-1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.ID != "120" || server.Name != "forest-1-node-1" {
+		t.Errorf("unexpected server %+v", server)
+	}
+
+	vm := fake.vms[120]
+	if vm == nil || vm.status != "running" {
+		t.Fatalf("expected a running clone, got %+v", vm)
+	}
+	for key, want := range map[string]string{
+		"cores":     "2",
+		"memory":    "4096",
+		"cicustom":  "user=local:snippets/morpheus-120-user.yaml",
+		"ipconfig0": "ip=dhcp",
+		"scsi0":     "local-lvm:vm-disk,size=20G",
+	} {
+		if vm.config[key] != want {
+			t.Errorf("%s = %q, want %q", key, vm.config[key], want)
+		}
+	}
+	if vm.config["ide2"] != "local-lvm:cloudinit,media=cdrom" {
+		t.Errorf("cloud-init drive of the template was replaced: %q", vm.config["ide2"])
+	}
+
+	// The snippet authorizes the configured key, not the key name
+	userData, _ := os.ReadFile(filepath.Join(dir, "stdin"))
+	if !strings.Contains(string(userData), "ssh_authorized_keys:\n  - ssh-ed25519 AAAAtest morpheus\n") || strings.Contains(string(userData), "- morpheus\n") {
+		t.Errorf("unexpected user data:\n%s", userData)
+	}
+	log, _ := os.ReadFile(filepath.Join(dir, "ssh.log"))
+	if !strings.Contains(string(log), "root@") || !strings.Contains(string(log), "/var/lib/vz/snippets/morpheus-120-user.yaml") {
+		t.Errorf("unexpected ssh call: %s", log)
+	}
+
+	if err := p.WaitForServer(ctx, server.ID, machine.ServerStateRunning); err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.GetServer(ctx, server.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PublicIPv4 != "192.168.1.50" || got.Labels["forest-id"] != "forest-1" {
+		t.Errorf("unexpected server %+v", got)
+	}
+
+	servers, err := p.ListServers(ctx, map[string]string{"managed-by": "morpheus", "forest-id": "forest-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(servers) != 1 || servers[0].ID != "120" {
+		t.Errorf("expected only the clone to match, got %+v", servers)
+	}
+
+	if err := p.DeleteServer(ctx, server.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.vms[120]; ok {
+		t.Error("expected the clone to be destroyed")
+	}
+	log, _ = os.ReadFile(filepath.Join(dir, "ssh.log"))
+	if !strings.Contains(string(log), "rm -f '/var/lib/vz/snippets/morpheus-120-user.yaml'") {
+		t.Errorf("expected the snippet to be removed, got: %s", log)
+	}
+}
+
+func TestCreateServerDestroysCloneOnFailure(t *testing.T) {
+	fake := newFakeProxmox()
+	p, _ := newFakeProvider(t, fake)
+
+	// The template's disk is larger than asked for
+	_, err := p.CreateServer(context.Background(), machine.CreateServerRequest{
+		Name:       "forest-1-node-1",
+		ServerType: "2c-4g-2g",
+		UserData:   "#cloud-config\n",
+	})
+	if err == nil || !strings.Contains(err.Error(), "can only grow") {
+		t.Fatalf("expected a disk size error, got %v", err)
+	}
+	if _, ok := fake.vms[120]; ok {
+		t.Error("expected the clone to be destroyed")
+	}
+}
+
+func TestDeleteServerKeepsOtherVMs(t *testing.T) {
+	fake := newFakeProxmox()
+	p, _ := newFakeProvider(t, fake)
+
+	err := p.DeleteServer(context.Background(), "101")
+	if err == nil || !strings.Contains(err.Error(), "not created by morpheus") {
+		t.Fatalf("expected an error, got %v", err)
+	}
+	if vm := fake.vms[101]; vm == nil || vm.status != "running" {
+		t.Errorf("expected VM 101 to be untouched, got %+v", vm)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	sizes := map[string]Size{"small": {Cores: 1, MemoryMB: 1024}}
+	tests := []struct {
+		serverType string
+		want       Size
+		wantErr    bool
+	}{
+		{"", Size{}, false},
+		{"small", Size{Cores: 1, MemoryMB: 1024}, false},
+		{"2c-4g", Size{Cores: 2, MemoryMB: 4096}, false},
+		{"4c-8192m-40g", Size{Cores: 4, MemoryMB: 8192, DiskGB: 40}, false},
+		{"cx22", Size{}, true},
+		{"2c", Size{}, true},
+		{"2g-4c", Size{}, true},
+		{"2c-4g-40m", Size{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSize(tt.serverType, sizes)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseSize(%q) = %+v, %v; want %+v, error %v", tt.serverType, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestWithSSHKeys(t *testing.T) {
+	keys := []string{"ssh-ed25519 AAAA a"}
+
+	got := withSSHKeys("#cloud-config\nruncmd:\n  - echo hi", keys)
+	want := "#cloud-config\nruncmd:\n  - echo hi\n\n# Added by morpheus\ndisable_root: false\nssh_authorized_keys:\n  - ssh-ed25519 AAAA a\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// User data that authorizes keys itself, or is no cloud-config, is kept
+	for _, userData := range []string{"#cloud-config\nssh_authorized_keys:\n  - ssh-rsa B\n", "#!/bin/sh\necho hi\n"} {
+		if got := withSSHKeys(userData, keys); got != userData {
+			t.Errorf("expected %q to be kept, got %q", userData, got)
+		}
+	}
+}
+
+func TestVMConfig_BootDisk(t *testing.T) {
+	config := VMConfig{
+		Boot: "order=ide2;virtio0;net0",
+		Drives: map[string]string{
+			"ide2":    "local:iso/ubuntu.iso,media=cdrom",
+			"scsi0":   "local-lvm:vm-100-disk-1,size=10G",
+			"virtio0": "local-lvm:vm-100-disk-0,size=1T",
+		},
+	}
+	if got := config.BootDisk(); got != "virtio0" {
+		t.Errorf("BootDisk() = %q, want virtio0", got)
+	}
+	if got := config.DiskSizeGB("virtio0"); got != 1024 {
+		t.Errorf("DiskSizeGB() = %d, want 1024", got)
+	}
+
+	config.Boot = ""
+	if got := config.BootDisk(); got != "scsi0" {
+		t.Errorf("BootDisk() without boot order = %q, want scsi0", got)
+	}
+}
+
+func TestCreateContainer(t *testing.T) {
+	fake := newFakeProxmox()
+	p, dir := newFakeProvider(t, fake)
+	p.config.Type = TypeLXC
+	p.config.Template = "local:vztmpl/ubuntu-24.04-cloud.tar.xz"
+	ctx := context.Background()
+
+	server, err := p.CreateServer(ctx, machine.CreateServerRequest{
+		Name:       "forest-1-node-1",
+		ServerType: "small",
+		UserData:   "#cloud-config\npackages:\n  - curl\n",
+		Labels:     map[string]string{"managed-by": "morpheus", "forest-id": "forest-1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ct := fake.vms[120]
+	if ct == nil || !ct.container || ct.status != "running" {
+		t.Fatalf("expected a running container, got %+v", ct)
+	}
+	for key, want := range map[string]string{
+		"ostemplate":      "local:vztmpl/ubuntu-24.04-cloud.tar.xz",
+		"hostname":        "forest-1-node-1",
+		"cores":           "2",
+		"memory":          "2048",
+		"rootfs":          "local-lvm:8",
+		"net0":            "name=eth0,bridge=vmbr0,ip=dhcp,ip6=auto",
+		"unprivileged":    "1",
+		"ssh-public-keys": "ssh-ed25519 AAAAtest morpheus",
+	} {
+		if ct.config[key] != want {
+			t.Errorf("%s = %q, want %q", key, ct.config[key], want)
+		}
+	}
+
+	// The user data is written into the container's cloud-init seed
+	log, _ := os.ReadFile(filepath.Join(dir, "ssh.log"))
+	if !strings.Contains(string(log), "pct mount 120") || !strings.Contains(string(log), "seed/nocloud") {
+		t.Errorf("unexpected ssh call: %s", log)
+	}
+	userData, _ := os.ReadFile(filepath.Join(dir, "stdin"))
+	if !strings.Contains(string(userData), "  - curl\n") {
+		t.Errorf("unexpected user data:\n%s", userData)
+	}
+
+	if err := p.WaitForServer(ctx, server.ID, machine.ServerStateRunning); err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.GetServer(ctx, server.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PublicIPv4 != "192.168.1.60" || got.Labels["forest-id"] != "forest-1" {
+		t.Errorf("unexpected server %+v", got)
+	}
+	servers, err := p.ListServers(ctx, map[string]string{"forest-id": "forest-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(servers) != 1 || servers[0].ID != "120" {
+		t.Errorf("expected only the container, got %+v", servers)
+	}
+
+	if err := p.DeleteServer(ctx, server.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.vms[120]; ok {
+		t.Error("expected the container to be destroyed")
+	}
+}
@@ -0,0 +1,297 @@
+package proxmox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+// defaultRootFSGB is the root disk size of containers whose size has none
+const defaultRootFSGB = 8
+
+// ContainerConfig represents the configuration of an LXC container
+type ContainerConfig struct {
+	Hostname    string `json:"hostname"`
+	Cores       int    `json:"cores"`
+	Memory      int64  `json:"memory"`
+	RootFS      string `json:"rootfs"`
+	Description string `json:"description"`
+	Tags        string `json:"tags"`
+}
+
+// taskRequest performs a request that starts a task and returns its UPID
+func (c *Client) taskRequest(ctx context.Context, method, path string, params url.Values) (string, error) {
+	data, err := c.request(ctx, method, path, params)
+	if err != nil {
+		return "", err
+	}
+
+	var upid string
+	if err := json.Unmarshal(data, &upid); err != nil {
+		return "", fmt.Errorf("parse UPID: %w", err)
+	}
+
+	return upid, nil
+}
+
+// ListContainers returns all LXC containers on the configured node
+func (c *Client) ListContainers(ctx context.Context) ([]*VM, error) {
+	path := fmt.Sprintf("/nodes/%s/lxc", c.node)
+
+	data, err := c.request(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var containers []*VM
+	if err := json.Unmarshal(data, &containers); err != nil {
+		return nil, fmt.Errorf("parse containers: %w", err)
+	}
+
+	for _, ct := range containers {
+		ct.Node = c.node
+	}
+
+	return containers, nil
+}
+
+// GetContainer returns a specific LXC container by VMID
+func (c *Client) GetContainer(ctx context.Context, vmid int) (*VM, error) {
+	path := fmt.Sprintf("/nodes/%s/lxc/%d/status/current", c.node, vmid)
+
+	data, err := c.request(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var ct VM
+	if err := json.Unmarshal(data, &ct); err != nil {
+		return nil, fmt.Errorf("parse container: %w", err)
+	}
+
+	ct.VMID = vmid
+	ct.Node = c.node
+
+	return &ct, nil
+}
+
+// GetContainerConfig returns the configuration of an LXC container
+func (c *Client) GetContainerConfig(ctx context.Context, vmid int) (*ContainerConfig, error) {
+	path := fmt.Sprintf("/nodes/%s/lxc/%d/config", c.node, vmid)
+
+	data, err := c.request(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var config ContainerConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("parse container config: %w", err)
+	}
+
+	return &config, nil
+}
+
+// CreateContainer creates an LXC container from an OS template, like
+// 'pct create'; params hold vmid, ostemplate and the other options
+func (c *Client) CreateContainer(ctx context.Context, params url.Values) (string, error) {
+	return c.taskRequest(ctx, http.MethodPost, fmt.Sprintf("/nodes/%s/lxc", c.node), params)
+}
+
+// StartContainer starts a stopped LXC container
+func (c *Client) StartContainer(ctx context.Context, vmid int) (string, error) {
+	return c.taskRequest(ctx, http.MethodPost, fmt.Sprintf("/nodes/%s/lxc/%d/status/start", c.node, vmid), nil)
+}
+
+// StopContainer stops an LXC container at once
+func (c *Client) StopContainer(ctx context.Context, vmid int) (string, error) {
+	return c.taskRequest(ctx, http.MethodPost, fmt.Sprintf("/nodes/%s/lxc/%d/status/stop", c.node, vmid), nil)
+}
+
+// DeleteContainer destroys a stopped LXC container with its disks
+func (c *Client) DeleteContainer(ctx context.Context, vmid int) (string, error) {
+	path := fmt.Sprintf("/nodes/%s/lxc/%d?purge=1&destroy-unreferenced-disks=1", c.node, vmid)
+	return c.taskRequest(ctx, http.MethodDelete, path, nil)
+}
+
+// GetContainerIPs returns the IPv4 addresses of a running LXC container
+func (c *Client) GetContainerIPs(ctx context.Context, vmid int) ([]string, error) {
+	path := fmt.Sprintf("/nodes/%s/lxc/%d/interfaces", c.node, vmid)
+
+	data, err := c.request(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var interfaces []struct {
+		Name string `json:"name"`
+		Inet string `json:"inet"` // e.g. "192.168.1.50/24"
+	}
+	if err := json.Unmarshal(data, &interfaces); err != nil {
+		return nil, fmt.Errorf("parse container interfaces: %w", err)
+	}
+
+	var ips []string
+	for _, iface := range interfaces {
+		ip, _, _ := strings.Cut(iface.Inet, "/")
+		if iface.Name != "lo" && ip != "" && !strings.HasPrefix(ip, "127.") {
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
+
+// isLXC reports whether the provider creates containers instead of VMs
+func (p *Provider) isLXC() bool {
+	return p.config.Type == TypeLXC
+}
+
+// createContainer creates a container from an OS template, writes
+// req.UserData into its cloud-init seed and starts it. If any step after
+// the create fails, the container is destroyed.
+func (p *Provider) createContainer(ctx context.Context, req machine.CreateServerRequest, size Size, template string) (*machine.Server, error) {
+	vmid, err := p.client.NextVMID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	storage := p.config.Storage
+	if storage == "" {
+		storage = "local-lvm"
+	}
+	bridge := p.config.Bridge
+	if bridge == "" {
+		bridge = "vmbr0"
+	}
+	disk := size.DiskGB
+	if disk == 0 {
+		disk = defaultRootFSGB
+	}
+	keys := p.sshPublicKeys(req.SSHKeys)
+
+	params := url.Values{}
+	params.Set("vmid", strconv.Itoa(vmid))
+	params.Set("ostemplate", template)
+	params.Set("hostname", req.Name)
+	params.Set("rootfs", fmt.Sprintf("%s:%d", storage, disk))
+	params.Set("net0", fmt.Sprintf("name=eth0,bridge=%s,ip=dhcp,ip6=auto", bridge))
+	params.Set("unprivileged", "1")
+	params.Set("features", "nesting=1") // systemd of current distributions needs it
+	params.Set("tags", morpheusTag)
+	params.Set("description", labelsDescription(req.Labels))
+	if size.Cores > 0 {
+		params.Set("cores", strconv.Itoa(size.Cores))
+	}
+	if size.MemoryMB > 0 {
+		params.Set("memory", strconv.Itoa(size.MemoryMB))
+	}
+	if len(keys) > 0 {
+		params.Set("ssh-public-keys", strings.Join(keys, "\n"))
+	}
+
+	upid, err := p.client.CreateContainer(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("create container from %s: %w", template, err)
+	}
+	if err := p.waitTask(ctx, upid, "create container"); err != nil {
+		// A failed create task removes what it created
+		return nil, err
+	}
+
+	if err := p.setupContainer(ctx, vmid, req.Name, withSSHKeys(req.UserData, keys)); err != nil {
+		if cleanupErr := p.destroyContainer(context.WithoutCancel(ctx), vmid); cleanupErr != nil {
+			return nil, fmt.Errorf("%w (and container %d could not be destroyed: %v)", err, vmid, cleanupErr)
+		}
+		return nil, err
+	}
+
+	return &machine.Server{
+		ID:         strconv.Itoa(vmid),
+		Name:       req.Name,
+		Location:   p.client.node,
+		State:      machine.ServerStateStarting,
+		Labels:     req.Labels,
+		ServerType: req.ServerType,
+		Image:      template,
+	}, nil
+}
+
+// setupContainer seeds cloud-init of a new container and starts it
+func (p *Provider) setupContainer(ctx context.Context, vmid int, hostname, userData string) error {
+	if userData != "" {
+		if err := p.runSSH(ctx, containerSeedScript(vmid, hostname), userData); err != nil {
+			return fmt.Errorf("write cloud-init user data: %w", err)
+		}
+	}
+
+	upid, err := p.client.StartContainer(ctx, vmid)
+	if err != nil {
+		return fmt.Errorf("start container %d: %w", vmid, err)
+	}
+	return p.waitTask(ctx, upid, "start")
+}
+
+// containerSeedScript returns the script that writes its input as the
+// NoCloud user data of a stopped container, through its mounted root
+// disk. Files get the owner of the container's root, so that root in an
+// unprivileged container can write next to them.
+func containerSeedScript(vmid int, hostname string) string {
+	return fmt.Sprintf(`set -e
+pct mount %[1]d >/dev/null
+trap 'pct unmount %[1]d' EXIT
+root=/var/lib/lxc/%[1]d/rootfs
+seed="$root/var/lib/cloud/seed/nocloud"
+mkdir -p "$seed"
+cat > "$seed/user-data"
+printf 'instance-id: morpheus-%[1]d\nlocal-hostname: %%s\n' %[2]s > "$seed/meta-data"
+chown -R "$(stat -c %%u:%%g "$root")" "$root/var/lib/cloud"
+`, vmid, shellQuote(hostname))
+}
+
+// destroyContainer stops a container at once and destroys it
+func (p *Provider) destroyContainer(ctx context.Context, vmid int) error {
+	ct, err := p.client.GetContainer(ctx, vmid)
+	if err != nil {
+		return err
+	}
+	if ct.Status != VMStatusStopped {
+		upid, err := p.client.StopContainer(ctx, vmid)
+		if err != nil {
+			return fmt.Errorf("stop container %d: %w", vmid, err)
+		}
+		if err := p.waitTask(ctx, upid, "stop"); err != nil {
+			return err
+		}
+	}
+
+	upid, err := p.client.DeleteContainer(ctx, vmid)
+	if err != nil {
+		return fmt.Errorf("destroy container %d: %w", vmid, err)
+	}
+	return p.waitTask(ctx, upid, "destroy")
+}
+
+// waitForContainerStatus waits for a container to reach a status
+func (p *Provider) waitForContainerStatus(ctx context.Context, vmid int, status VMStatus) error {
+	for {
+		ct, err := p.client.GetContainer(ctx, vmid)
+		if err != nil {
+			return err
+		}
+		if ct.Status == status {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(p.interval()):
+		}
+	}
+}
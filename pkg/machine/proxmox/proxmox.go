@@ -3,15 +3,24 @@ package proxmox
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/nimsforest/morpheus/pkg/machine"
 )
 
+// ipTimeout is how long WaitForServer waits for the QEMU guest agent of a
+// running VM to report an IP
+const ipTimeout = 5 * time.Minute
+
 // Provider implements machine.Provider for Proxmox VE
 type Provider struct {
 	client *Client
 	config ProviderConfig
+
+	sshBinary    string        // Uploads snippets; "ssh" unless set by tests
+	pollInterval time.Duration // Of tasks and VM status; 0 is the client's default
 }
 
 // NewProvider creates a new Proxmox provider
@@ -22,14 +31,133 @@ func NewProvider(config ProviderConfig) (*Provider, error) {
 	}
 
 	return &Provider{
-		client: client,
-		config: config,
+		client:    client,
+		config:    config,
+		sshBinary: "ssh",
 	}, nil
 }
 
-// CreateServer creates a new VM (not typically used - VMs should be pre-created)
+// CreateServer clones the template (req.Image, else the configured one) into
+// a new VM sized by req.ServerType, gives it req.UserData as its cloud-init
+// user data and starts it. If any step after the clone fails, the clone is
+// destroyed. With type lxc, it creates a container from the OS template
+// instead.
 func (p *Provider) CreateServer(ctx context.Context, req machine.CreateServerRequest) (*machine.Server, error) {
-	return nil, fmt.Errorf("proxmox provider: VM creation not supported, VMs should be pre-created in Proxmox")
+	if req.Location != "" && req.Location != p.client.node {
+		return nil, fmt.Errorf("proxmox provider creates VMs on node %s, not %s", p.client.node, req.Location)
+	}
+	size, err := ParseSize(req.ServerType, p.config.Sizes)
+	if err != nil {
+		return nil, err
+	}
+	template := req.Image
+	if template == "" {
+		template = p.config.Template
+	}
+	if template == "" {
+		return nil, fmt.Errorf("no proxmox template to clone: set machine.proxmox.template")
+	}
+	if p.isLXC() {
+		return p.createContainer(ctx, req, size, template)
+	}
+
+	templateID, err := p.templateID(ctx, template)
+	if err != nil {
+		return nil, err
+	}
+	templateConfig, err := p.client.GetVMConfig(ctx, templateID)
+	if err != nil {
+		return nil, fmt.Errorf("get config of template %s: %w", template, err)
+	}
+	vmid, err := p.client.NextVMID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	upid, err := p.client.CloneVM(ctx, templateID, vmid, req.Name, !p.config.LinkedClone, p.config.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("clone template %s: %w", template, err)
+	}
+	if err := p.waitTask(ctx, upid, "clone"); err != nil {
+		// A failed clone task removes what it created
+		return nil, err
+	}
+
+	if err := p.setupClone(ctx, vmid, req, size, templateConfig); err != nil {
+		if cleanupErr := p.destroyVM(context.WithoutCancel(ctx), vmid); cleanupErr != nil {
+			return nil, fmt.Errorf("%w (and VM %d could not be destroyed: %v)", err, vmid, cleanupErr)
+		}
+		return nil, err
+	}
+
+	return &machine.Server{
+		ID:         strconv.Itoa(vmid),
+		Name:       req.Name,
+		Location:   p.client.node,
+		State:      machine.ServerStateStarting,
+		Labels:     req.Labels,
+		ServerType: req.ServerType,
+		Image:      template,
+	}, nil
+}
+
+// setupClone configures a new clone as the request asks and starts it
+func (p *Provider) setupClone(ctx context.Context, vmid int, req machine.CreateServerRequest, size Size, template *VMConfig) error {
+	snippet, err := p.uploadSnippet(ctx, vmid, withSSHKeys(req.UserData, p.sshPublicKeys(req.SSHKeys)))
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Set("cicustom", "user="+snippet)
+	params.Set("agent", "1")
+	params.Set("tags", morpheusTag)
+	params.Set("description", labelsDescription(req.Labels))
+	if template.IPConfig0 == "" {
+		params.Set("ipconfig0", "ip=dhcp")
+	}
+	if template.CloudInitDrive() == "" {
+		storage := p.config.Storage
+		if storage == "" {
+			storage = "local-lvm"
+		}
+		params.Set("ide2", storage+":cloudinit")
+	}
+	if size.Cores > 0 {
+		params.Set("cores", strconv.Itoa(size.Cores))
+	}
+	if size.MemoryMB > 0 {
+		params.Set("memory", strconv.Itoa(size.MemoryMB))
+	}
+	if err := p.client.UpdateVMConfig(ctx, vmid, params); err != nil {
+		return fmt.Errorf("configure VM %d: %w", vmid, err)
+	}
+
+	if size.DiskGB > 0 {
+		disk := template.BootDisk()
+		if disk == "" {
+			return fmt.Errorf("template has no disk to grow to %d GB", size.DiskGB)
+		}
+		if current := template.DiskSizeGB(disk); size.DiskGB < current {
+			return fmt.Errorf("disk of %d GB is smaller than the template's %d GB; disks can only grow", size.DiskGB, current)
+		} else if size.DiskGB > current {
+			upid, err := p.client.ResizeDisk(ctx, vmid, disk, fmt.Sprintf("%dG", size.DiskGB))
+			if err != nil {
+				return fmt.Errorf("resize disk of VM %d: %w", vmid, err)
+			}
+			if upid != "" {
+				if err := p.waitTask(ctx, upid, "resize"); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	upid, err := p.client.StartVM(ctx, vmid)
+	if err != nil {
+		return fmt.Errorf("start VM %d: %w", vmid, err)
+	}
+	return p.waitTask(ctx, upid, "start")
 }
 
 // GetServer retrieves a VM by its VMID
@@ -39,41 +167,82 @@ func (p *Provider) GetServer(ctx context.Context, serverID string) (*machine.Ser
 		return nil, fmt.Errorf("invalid VMID: %s", serverID)
 	}
 
-	vm, err := p.client.GetVM(ctx, vmid)
+	vm, err := p.getGuest(ctx, vmid)
 	if err != nil {
+		if p.guestGone(ctx, vmid) {
+			return nil, fmt.Errorf("VM %d %w", vmid, machine.ErrNotFound)
+		}
+		return nil, err
+	}
+	if err := p.describe(ctx, vm); err != nil {
 		return nil, err
 	}
 
 	return p.vmToServer(vm), nil
 }
 
-// DeleteServer stops a VM (does not destroy it)
+// DeleteServer stops and destroys a VM created by CreateServer, with its
+// disks and cloud-init snippet. Other VMs are not touched.
 func (p *Provider) DeleteServer(ctx context.Context, serverID string) error {
 	vmid, err := strconv.Atoi(serverID)
 	if err != nil {
 		return fmt.Errorf("invalid VMID: %s", serverID)
 	}
 
-	// Graceful shutdown with 60s timeout
-	upid, err := p.client.ShutdownVM(ctx, vmid, 60)
+	if p.isLXC() {
+		config, err := p.client.GetContainerConfig(ctx, vmid)
+		if err != nil {
+			return err
+		}
+		if _, ok := parseLabels(config.Description); !ok {
+			return fmt.Errorf("container %d was not created by morpheus; not destroying it", vmid)
+		}
+		return p.destroyContainer(ctx, vmid)
+	}
+
+	config, err := p.client.GetVMConfig(ctx, vmid)
 	if err != nil {
 		return err
 	}
+	if _, ok := parseLabels(config.Description); !ok {
+		return fmt.Errorf("VM %d was not created by morpheus; not destroying it", vmid)
+	}
 
-	// Wait for shutdown to complete
-	status, err := p.client.WaitForTask(ctx, upid, 0)
+	return p.destroyVM(ctx, vmid)
+}
+
+// destroyVM stops a VM at once, destroys it and removes its snippet
+func (p *Provider) destroyVM(ctx context.Context, vmid int) error {
+	vm, err := p.client.GetVM(ctx, vmid)
 	if err != nil {
 		return err
 	}
+	if vm.Status != VMStatusStopped {
+		upid, err := p.client.StopVM(ctx, vmid)
+		if err != nil {
+			return fmt.Errorf("stop VM %d: %w", vmid, err)
+		}
+		if err := p.waitTask(ctx, upid, "stop"); err != nil {
+			return err
+		}
+	}
 
-	if !status.IsSuccessful() {
-		return fmt.Errorf("shutdown failed: %s", status.ExitStatus)
+	upid, err := p.client.DeleteVM(ctx, vmid)
+	if err != nil {
+		return fmt.Errorf("destroy VM %d: %w", vmid, err)
+	}
+	if err := p.waitTask(ctx, upid, "destroy"); err != nil {
+		return err
 	}
 
+	// The snippet is only read when the VM boots, so a leftover is harmless
+	_ = p.removeSnippet(ctx, vmid)
 	return nil
 }
 
-// WaitForServer waits for a VM to reach a specific state
+// WaitForServer waits for a VM to reach a specific state. For the running
+// state, it also waits for the QEMU guest agent, or the container, to
+// report an IP, so that GetServer returns one.
 func (p *Provider) WaitForServer(ctx context.Context, serverID string, state machine.ServerState) error {
 	vmid, err := strconv.Atoi(serverID)
 	if err != nil {
@@ -81,12 +250,47 @@ func (p *Provider) WaitForServer(ctx context.Context, serverID string, state mac
 	}
 
 	targetStatus := p.stateToVMStatus(state)
-	return p.client.WaitForVMStatus(ctx, vmid, targetStatus, 0)
+	if p.isLXC() {
+		err = p.waitForContainerStatus(ctx, vmid, targetStatus)
+	} else {
+		err = p.client.WaitForVMStatus(ctx, vmid, targetStatus, p.pollInterval)
+	}
+	if err != nil {
+		return err
+	}
+	if targetStatus != VMStatusRunning {
+		return nil
+	}
+
+	ipCtx, cancel := context.WithTimeout(ctx, ipTimeout)
+	defer cancel()
+	for {
+		if ips, _ := p.guestIPs(ipCtx, vmid); len(ips) > 0 {
+			return nil
+		}
+		select {
+		case <-ipCtx.Done():
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if p.isLXC() {
+				return fmt.Errorf("container %d reported no IP within %s: does its bridge have DHCP?", vmid, ipTimeout)
+			}
+			return fmt.Errorf("VM %d reported no IP within %s: is qemu-guest-agent installed in the template?", vmid, ipTimeout)
+		case <-time.After(p.interval()):
+		}
+	}
 }
 
-// ListServers returns all VMs on the node
+// ListServers returns all VMs, or with type lxc all containers, on the node
 func (p *Provider) ListServers(ctx context.Context, filters map[string]string) ([]*machine.Server, error) {
-	vms, err := p.client.ListVMs(ctx)
+	var vms []*VM
+	var err error
+	if p.isLXC() {
+		vms, err = p.client.ListContainers(ctx)
+	} else {
+		vms, err = p.client.ListVMs(ctx)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -98,6 +302,11 @@ func (p *Provider) ListServers(ctx context.Context, filters map[string]string) (
 			continue
 		}
 
+		if hasTag(vm.Tags, morpheusTag) {
+			if err := p.describe(ctx, vm); err != nil {
+				return nil, err
+			}
+		}
 		server := p.vmToServer(vm)
 
 		// Apply filters
@@ -181,17 +390,21 @@ func (p *Provider) vmToServer(vm *VM) *machine.Server {
 		ipv4 = vm.IPs[0]
 	}
 
+	labels, _ := parseLabels(vm.Description)
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels["vmid"] = strconv.Itoa(vm.VMID)
+	labels["node"] = vm.Node
+	labels["status"] = string(vm.Status)
+
 	return &machine.Server{
 		ID:         strconv.Itoa(vm.VMID),
 		Name:       vm.Name,
 		PublicIPv4: ipv4,
 		Location:   vm.Node,
 		State:      p.vmStatusToState(vm.Status),
-		Labels: map[string]string{
-			"vmid":   strconv.Itoa(vm.VMID),
-			"node":   vm.Node,
-			"status": string(vm.Status),
-		},
+		Labels:     labels,
 	}
 }
 
@@ -234,6 +447,10 @@ func (p *Provider) matchFilters(server *machine.Server, filters map[string]strin
 			if server.ID != value {
 				return false
 			}
+		default:
+			if server.Labels[key] != value {
+				return false
+			}
 		}
 	}
 	return true
@@ -140,3 +140,42 @@ func TestVMStatus_Constants(t *testing.T) {
 		}
 	}
 }
+
+func TestVMConfig_PassthroughDevices(t *testing.T) {
+	config := VMConfig{HostPCI: []string{
+		"0000:01:00,pcie=1,x-vga=1",
+		"host=02:00.0;02:00.1,pcie=1",
+		"mapping=gpu0,pcie=1",
+	}}
+
+	got := config.PassthroughDevices()
+	want := []string{"0000:01:00", "0000:02:00.0", "0000:02:00.1"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestSamePCIDevice(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"0000:01:00", "0000:01:00.0", true},
+		{"01:00.1", "0000:01:00", true},
+		{"0000:01:00.0", "0000:01:00.0", true},
+		{"0000:01:00.0", "0000:01:00.1", false},
+		{"0000:01:00", "0000:01:001", false},
+		{"0000:01:00", "0000:02:00", false},
+	}
+
+	for _, tt := range tests {
+		if got := SamePCIDevice(tt.a, tt.b); got != tt.want {
+			t.Errorf("SamePCIDevice(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
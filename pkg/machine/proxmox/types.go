@@ -2,7 +2,13 @@
 // It enables remote management of VMs for NimsForest VR nodes.
 package proxmox
 
-import "time"
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // VMStatus represents the status of a Proxmox VM
 type VMStatus string
@@ -29,26 +35,175 @@ type VM struct {
 	NetOut      int64    `json:"netout"`   // Network bytes out
 	DiskRead    int64    `json:"diskread"` // Disk bytes read
 	DiskWrite   int64    `json:"diskwrite"`
-	Template    bool     `json:"template"`
+	Template    Bool     `json:"template"`
 	Tags        string   `json:"tags"`
 	Description string   `json:"description"`
 	Config      *VMConfig
 	IPs         []string // IP addresses (from QEMU agent)
 }
 
+// Bool is a boolean the API returns as 0 or 1, or true or false
+type Bool bool
+
+// UnmarshalJSON reads 0, 1, true or false
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case "1", "true":
+		*b = true
+	case "0", "false", "null":
+		*b = false
+	default:
+		return fmt.Errorf("invalid boolean %s", data)
+	}
+	return nil
+}
+
 // VMConfig represents the full configuration of a VM
 type VMConfig struct {
-	Name        string   `json:"name"`
-	Memory      int64    `json:"memory"`
-	Cores       int      `json:"cores"`
-	Sockets     int      `json:"sockets"`
-	CPU         string   `json:"cpu"`
-	Machine     string   `json:"machine"`
-	BIOS        string   `json:"bios"`
-	Boot        string   `json:"boot"`
-	OSType      string   `json:"ostype"`
-	Description string   `json:"description"`
-	HostPCI     []string // PCI passthrough devices
+	Name        string            `json:"name"`
+	Memory      int64             `json:"memory"`
+	Cores       int               `json:"cores"`
+	Sockets     int               `json:"sockets"`
+	CPU         string            `json:"cpu"`
+	Machine     string            `json:"machine"`
+	BIOS        string            `json:"bios"`
+	Boot        string            `json:"boot"`
+	OSType      string            `json:"ostype"`
+	Description string            `json:"description"`
+	Tags        string            `json:"tags"`
+	IPConfig0   string            `json:"ipconfig0"`
+	HostPCI     []string          // PCI passthrough devices
+	Drives      map[string]string // Disks and CD-ROMs by slot, e.g. "scsi0"
+}
+
+// isDriveKey reports whether a config key is a drive slot, e.g. "scsi0"
+func isDriveKey(key string) bool {
+	for _, bus := range []string{"ide", "sata", "scsi", "virtio"} {
+		if n, ok := strings.CutPrefix(key, bus); ok && n != "" && strings.Trim(n, "0123456789") == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// CloudInitDrive returns the slot of the VM's cloud-init drive, or ""
+func (c *VMConfig) CloudInitDrive() string {
+	for slot, drive := range c.Drives {
+		if strings.Contains(drive, ":cloudinit") {
+			return slot
+		}
+	}
+	return ""
+}
+
+// BootDisk returns the slot of the disk the VM boots from: the first disk
+// in its boot order, else the first disk by slot, or "" if it has none
+func (c *VMConfig) BootDisk() string {
+	isDisk := func(slot string) bool {
+		drive, ok := c.Drives[slot]
+		return ok && !strings.Contains(drive, ":cloudinit") && !strings.Contains(drive, "media=cdrom")
+	}
+	if order, ok := strings.CutPrefix(c.Boot, "order="); ok {
+		for _, slot := range strings.Split(order, ";") {
+			if isDisk(slot) {
+				return slot
+			}
+		}
+	}
+	var slots []string
+	for slot := range c.Drives {
+		if isDisk(slot) {
+			slots = append(slots, slot)
+		}
+	}
+	sort.Strings(slots)
+	if len(slots) == 0 {
+		return ""
+	}
+	return slots[0]
+}
+
+// DiskSizeGB returns the size of the disk in a slot in GiB, or 0 if it is
+// not known
+func (c *VMConfig) DiskSizeGB(slot string) int {
+	for _, opt := range strings.Split(c.Drives[slot], ",") {
+		size, ok := strings.CutPrefix(opt, "size=")
+		if !ok || size == "" {
+			continue
+		}
+		unit := size[len(size)-1]
+		n, err := strconv.ParseFloat(strings.TrimRight(size, "KMGT"), 64)
+		if err != nil {
+			return 0
+		}
+		switch unit {
+		case 'T':
+			n *= 1024
+		case 'M':
+			n /= 1024
+		case 'K':
+			n /= 1024 * 1024
+		}
+		return int(n)
+	}
+	return 0
+}
+
+// PassthroughDevices returns the host PCI addresses passed through to the
+// VM, normalized to include the domain (e.g. "0000:01:00"). Devices given
+// as a resource mapping are not included.
+func (c *VMConfig) PassthroughDevices() []string {
+	var devices []string
+	for _, entry := range c.HostPCI {
+		// Format: [host=]<id>[;<id>...][,option=value...]
+		host, _, _ := strings.Cut(entry, ",")
+		if strings.HasPrefix(host, "mapping=") {
+			continue
+		}
+		host = strings.TrimPrefix(host, "host=")
+		for _, id := range strings.Split(host, ";") {
+			if id != "" {
+				devices = append(devices, NormalizePCIAddress(id))
+			}
+		}
+	}
+	return devices
+}
+
+// NormalizePCIAddress adds the default domain to a PCI address without one
+func NormalizePCIAddress(addr string) string {
+	addr = strings.ToLower(strings.TrimSpace(addr))
+	if strings.Count(addr, ":") < 2 {
+		addr = "0000:" + addr
+	}
+	return addr
+}
+
+// SamePCIDevice reports whether two PCI addresses refer to the same device.
+// An address without a function (e.g. "0000:01:00") matches all of its
+// functions.
+func SamePCIDevice(a, b string) bool {
+	a, b = NormalizePCIAddress(a), NormalizePCIAddress(b)
+	return a == b || strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".")
+}
+
+// PCIDevice represents a PCI device on a Proxmox node
+type PCIDevice struct {
+	ID         string `json:"id"` // e.g. "0000:01:00.0"
+	Class      string `json:"class"`
+	Vendor     string `json:"vendor"`
+	VendorName string `json:"vendor_name"`
+	Device     string `json:"device"`
+	DeviceName string `json:"device_name"`
+	IOMMUGroup int    `json:"iommugroup"` // -1 if IOMMU is disabled
+}
+
+// Snapshot represents a snapshot of a VM
+type Snapshot struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	SnapTime    int64  `json:"snaptime"` // Unix time
+	Parent      string `json:"parent"`
 }
 
 // Node represents a Proxmox cluster node
@@ -87,6 +242,12 @@ func (t *TaskStatus) IsSuccessful() bool {
 	return t.Status == "stopped" && t.ExitStatus == "OK"
 }
 
+// Kinds of guests the provider creates
+const (
+	TypeQEMU = "qemu" // Virtual machines
+	TypeLXC  = "lxc"  // Containers
+)
+
 // ProviderConfig holds Proxmox provider configuration
 type ProviderConfig struct {
 	Host           string        `yaml:"host"`
@@ -96,6 +257,65 @@ type ProviderConfig struct {
 	APITokenSecret string        `yaml:"api_token_secret"`
 	VerifySSL      bool          `yaml:"verify_ssl"`
 	Timeout        time.Duration `yaml:"timeout"`
+
+	// Settings for creating VMs, as clones of a cloud-init template, or
+	// containers, from an OS template
+	Type           string          `yaml:"type"`            // TypeQEMU (default) or TypeLXC
+	Template       string          `yaml:"template"`        // Name or VMID, or the OS template of containers; CreateServerRequest.Image overrides it
+	Storage        string          `yaml:"storage"`         // Storage of full clones, added cloud-init drives and container root disks
+	LinkedClone    bool            `yaml:"linked_clone"`    // Share the template's disks instead of copying them
+	Bridge         string          `yaml:"bridge"`          // Network bridge of containers (default: vmbr0)
+	SnippetStorage string          `yaml:"snippet_storage"` // Storage with the snippets content type (default: local)
+	SnippetDir     string          `yaml:"snippet_dir"`     // Its directory on the host (default: /var/lib/vz/snippets)
+	SSHUser        string          `yaml:"ssh_user"`        // User that uploads snippets over SSH (default: root)
+	SSHIdentity    string          `yaml:"ssh_identity"`    // Private key for it (default: ssh's)
+	SSHPublicKeys  []string        `yaml:"ssh_public_keys"` // Authorized on created VMs
+	Sizes          map[string]Size `yaml:"sizes"`           // Named server types
+}
+
+// Size is the resources of a VM; zero values keep the template's
+type Size struct {
+	Cores    int `yaml:"cores"`
+	MemoryMB int `yaml:"memory_mb"`
+	DiskGB   int `yaml:"disk_gb"`
+}
+
+// ParseSize returns the size of a server type: a name in sizes, or
+// "<cores>c-<memory>[-<disk>g]" such as "2c-4g" (2 cores, 4 GiB memory) or
+// "4c-8192m-40g" (4 cores, 8192 MiB memory, a 40 GiB boot disk). An empty
+// server type keeps the template's resources.
+func ParseSize(serverType string, sizes map[string]Size) (Size, error) {
+	if size, ok := sizes[serverType]; ok {
+		return size, nil
+	}
+	var size Size
+	if serverType == "" {
+		return size, nil
+	}
+	invalid := fmt.Errorf("invalid proxmox server type %q: want a size name or resources such as 2c-4g-40g", serverType)
+	parts := strings.Split(strings.ToLower(serverType), "-")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Size{}, invalid
+	}
+	units := []string{"c", "gm", "g"} // Cores, memory, disk
+	for i, part := range parts {
+		unit := strings.TrimLeft(part, "0123456789")
+		n, err := strconv.Atoi(strings.TrimSuffix(part, unit))
+		if err != nil || n <= 0 || len(unit) != 1 || !strings.Contains(units[i], unit) {
+			return Size{}, invalid
+		}
+		switch {
+		case i == 0:
+			size.Cores = n
+		case i == 1 && unit == "m":
+			size.MemoryMB = n
+		case i == 1:
+			size.MemoryMB = n * 1024
+		default:
+			size.DiskGB = n
+		}
+	}
+	return size, nil
 }
 
 // DefaultConfig returns a ProviderConfig with sensible defaults
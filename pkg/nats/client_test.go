@@ -0,0 +1,228 @@
+package nats
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer accepts one client, completes the handshake and records the
+// protocol lines it receives
+type fakeServer struct {
+	listener net.Listener
+	lines    chan string
+	conn     chan net.Conn
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("loopback not available: %v", err)
+	}
+	s := &fakeServer{listener: listener, lines: make(chan string, 16), conn: make(chan net.Conn, 1)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(conn, "INFO {\"server_id\":\"test\"}\r\n")
+		s.conn <- conn
+
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				close(s.lines)
+				return
+			}
+			line = strings.TrimSpace(line)
+			if line == "PING" {
+				fmt.Fprintf(conn, "PONG\r\n")
+				continue
+			}
+			s.lines <- line
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case line := <-s.lines:
+		return line
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for client")
+		return ""
+	}
+}
+
+// answer reads the SUB and PUB lines of a request from the client and
+// sends reply to its inbox
+func (s *fakeServer) answer(t *testing.T, conn net.Conn, subject, reply string) (payload string) {
+	t.Helper()
+	sub := strings.Fields(s.next(t))
+	if len(sub) != 3 || sub[0] != "SUB" {
+		t.Fatalf("expected SUB of an inbox, got %v", sub)
+	}
+	inbox, sid := sub[1], sub[2]
+	pub := strings.Fields(s.next(t))
+	if len(pub) != 4 || pub[1] != subject || pub[2] != inbox {
+		t.Fatalf("expected PUB %s %s, got %v", subject, inbox, pub)
+	}
+	payload = s.next(t)
+	fmt.Fprintf(conn, "%s", strings.NewReplacer("{inbox}", inbox, "{sid}", sid).Replace(reply))
+	return payload
+}
+
+func TestConnRequestAndPublish(t *testing.T) {
+	s := newFakeServer(t)
+
+	c, err := Connect("nats://deploy:secret@"+s.listener.Addr().String(), 2*time.Second)
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer c.Close()
+	conn := <-s.conn
+
+	connect := s.next(t)
+	if !strings.HasPrefix(connect, "CONNECT ") || !strings.Contains(connect, `"user":"deploy"`) || !strings.Contains(connect, `"headers":true`) {
+		t.Errorf("CONNECT = %q, want credentials and headers", connect)
+	}
+
+	if err := c.Publish("jobs.status", []byte("ok")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if got := s.next(t); got != "PUB jobs.status 2" {
+		t.Errorf("PUB = %q", got)
+	}
+	if got := s.next(t); got != "ok" {
+		t.Errorf("payload = %q", got)
+	}
+
+	replies := make(chan *Msg, 1)
+	go func() {
+		msg, err := c.Request("svc", []byte("hi"), 2*time.Second)
+		if err != nil {
+			t.Errorf("Request() error = %v", err)
+		}
+		replies <- msg
+	}()
+	if got := s.answer(t, conn, "svc", "MSG {inbox} {sid} 5\r\nhello\r\n"); got != "hi" {
+		t.Errorf("request payload = %q", got)
+	}
+	if msg := <-replies; msg == nil || string(msg.Data) != "hello" {
+		t.Errorf("reply = %+v", msg)
+	}
+	if got := s.next(t); !strings.HasPrefix(got, "UNSUB ") {
+		t.Errorf("expected UNSUB after the reply, got %q", got)
+	}
+
+	if _, err := c.Request("svc", nil, 10*time.Millisecond); err != ErrTimeout {
+		t.Errorf("Request() error = %v, want ErrTimeout", err)
+	}
+}
+
+func TestConnAnswersPingWhileMessagesWait(t *testing.T) {
+	s := newFakeServer(t)
+
+	c, err := Connect(s.listener.Addr().String(), 2*time.Second)
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer c.Close()
+	conn := <-s.conn
+	s.next(t) // CONNECT
+
+	// Far more messages than anyone takes, then a server PING
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(conn, "MSG jobs 1 4\r\njob!\r\n")
+	}
+	fmt.Fprintf(conn, "PING\r\n")
+	if got := s.next(t); got != "PONG" {
+		t.Errorf("Expected PONG reply to server PING, got %q", got)
+	}
+}
+
+func TestPullConsumerNext(t *testing.T) {
+	s := newFakeServer(t)
+
+	c, err := Connect(s.listener.Addr().String(), 2*time.Second)
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer c.Close()
+	conn := <-s.conn
+	s.next(t) // CONNECT
+	p := &PullConsumer{conn: c, stream: "MORPHEUS_JOBS", consumer: "workers"}
+	subject := "$JS.API.CONSUMER.MSG.NEXT.MORPHEUS_JOBS.workers"
+
+	type result struct {
+		msg *Msg
+		err error
+	}
+	results := make(chan result, 1)
+	pull := func() {
+		msg, err := p.Next(time.Second)
+		results <- result{msg, err}
+	}
+
+	go pull()
+	if got := s.answer(t, conn, subject, "HMSG {inbox} {sid} 28 28\r\nNATS/1.0 404 No Messages\r\n\r\n\r\n"); got != `{"batch":1,"expires":1000000000}` {
+		t.Errorf("pull request = %q", got)
+	}
+	if r := <-results; r.err != ErrTimeout {
+		t.Errorf("Next() = %+v, %v; want ErrTimeout", r.msg, r.err)
+	}
+	s.next(t) // UNSUB
+
+	go pull()
+	s.answer(t, conn, subject, "MSG morpheus.jobs {sid} $JS.ACK.MORPHEUS_JOBS.workers.1.1.1.0.0 14\r\naction: plant\n\r\n")
+	r := <-results
+	if r.err != nil || string(r.msg.Data) != "action: plant\n" {
+		t.Fatalf("Next() = %+v, %v", r.msg, r.err)
+	}
+	s.next(t) // UNSUB
+	if err := p.Ack(r.msg); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if got := s.next(t); got != "PUB $JS.ACK.MORPHEUS_JOBS.workers.1.1.1.0.0 4" {
+		t.Errorf("ack = %q", got)
+	}
+}
+
+func TestStreamName(t *testing.T) {
+	if got := StreamName("morpheus.jobs"); got != "MORPHEUS_JOBS" {
+		t.Errorf("StreamName() = %q", got)
+	}
+}
+
+func TestConnectRejected(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("loopback not available: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(conn, "INFO {}\r\n")
+		bufio.NewReader(conn).ReadString('\n')
+		fmt.Fprintf(conn, "-ERR 'Authorization Violation'\r\n")
+	}()
+
+	_, err = Connect(listener.Addr().String(), 2*time.Second)
+	if err == nil || !strings.Contains(err.Error(), "Authorization Violation") {
+		t.Errorf("Connect() error = %v, want authorization error", err)
+	}
+}
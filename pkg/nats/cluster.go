@@ -0,0 +1,227 @@
+package nats
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Ports nats-server listens on
+const (
+	ClientPort  = 4222
+	ClusterPort = 6222
+	MonitorPort = 8222
+)
+
+// Paths of the files a bootstrapped node uses
+const (
+	ConfigDir  = "/etc/nats"
+	ConfigFile = ConfigDir + "/nats-server.conf"
+	StoreDir   = "/var/lib/nats/jetstream"
+)
+
+// ServerConfig describes the nats-server configuration of one cluster node
+type ServerConfig struct {
+	ServerName  string
+	ClusterName string
+	Routes      []string // IPs of all cluster nodes; the node itself may be included
+	ClusterHost string   // IP the cluster port listens on, such as a mesh IP (default all)
+	RouteUser   string   // Credentials the nodes authenticate routes with
+	RoutePass   string
+	JetStream   bool
+	Accounts    []Account
+
+	// TLS, if set, encrypts client and route connections
+	TLS *TLSFiles
+}
+
+// Account is a NATS account and its users
+type Account struct {
+	Name      string
+	JetStream bool
+	Users     map[string]string // User -> password
+}
+
+// TLSFiles is the PEM-encoded material written to a node for TLS
+type TLSFiles struct {
+	CA   []byte
+	Cert []byte
+	Key  []byte
+}
+
+// RouteURL returns the cluster route URL of a node
+func RouteURL(ip, user, pass string) string {
+	return fmt.Sprintf("nats-route://%s:%s@%s", user, pass, net.JoinHostPort(ip, strconv.Itoa(ClusterPort)))
+}
+
+// Render returns the nats-server configuration file
+func (c ServerConfig) Render() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Managed by morpheus; changes are overwritten\n")
+	fmt.Fprintf(&b, "server_name: %s\n", quote(c.ServerName))
+	fmt.Fprintf(&b, "listen: %s\n", quote(fmt.Sprintf("[::]:%d", ClientPort)))
+	fmt.Fprintf(&b, "http: %s\n", quote(fmt.Sprintf("[::]:%d", MonitorPort)))
+
+	if c.TLS != nil {
+		b.WriteString("\ntls {\n")
+		writeTLS(&b, "  ")
+		b.WriteString("}\n")
+	}
+
+	if c.JetStream {
+		fmt.Fprintf(&b, "\njetstream {\n  store_dir: %s\n}\n", quote(StoreDir))
+	}
+
+	b.WriteString("\ncluster {\n")
+	fmt.Fprintf(&b, "  name: %s\n", quote(c.ClusterName))
+	clusterHost := c.ClusterHost
+	if clusterHost == "" {
+		clusterHost = "::"
+	}
+	fmt.Fprintf(&b, "  listen: %s\n", quote(net.JoinHostPort(clusterHost, strconv.Itoa(ClusterPort))))
+	fmt.Fprintf(&b, "  authorization {\n    user: %s\n    password: %s\n  }\n", quote(c.RouteUser), quote(c.RoutePass))
+	if c.TLS != nil {
+		b.WriteString("  tls {\n")
+		writeTLS(&b, "    ")
+		b.WriteString("  }\n")
+	}
+	b.WriteString("  routes: [\n")
+	for _, ip := range c.Routes {
+		fmt.Fprintf(&b, "    %s\n", quote(RouteURL(ip, c.RouteUser, c.RoutePass)))
+	}
+	b.WriteString("  ]\n}\n")
+
+	if len(c.Accounts) > 0 {
+		b.WriteString("\naccounts {\n")
+		for _, account := range c.Accounts {
+			fmt.Fprintf(&b, "  %s {\n", quote(account.Name))
+			if account.JetStream {
+				b.WriteString("    jetstream: enabled\n")
+			}
+			b.WriteString("    users: [\n")
+			users := make([]string, 0, len(account.Users))
+			for user := range account.Users {
+				users = append(users, user)
+			}
+			sort.Strings(users)
+			for _, user := range users {
+				fmt.Fprintf(&b, "      {user: %s, password: %s}\n", quote(user), quote(account.Users[user]))
+			}
+			b.WriteString("    ]\n  }\n")
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+func writeTLS(b *strings.Builder, indent string) {
+	fmt.Fprintf(b, "%scert_file: %s\n", indent, quote(ConfigDir+"/server.pem"))
+	fmt.Fprintf(b, "%skey_file: %s\n", indent, quote(ConfigDir+"/server-key.pem"))
+	fmt.Fprintf(b, "%sca_file: %s\n", indent, quote(ConfigDir+"/ca.pem"))
+	fmt.Fprintf(b, "%sverify: false\n", indent)
+}
+
+// quote returns s as a double-quoted string in the configuration syntax
+func quote(s string) string {
+	return strconv.Quote(s)
+}
+
+// bootstrapTemplate installs nats-server if needed, writes the node's
+// configuration and (re)loads the service. Running it again with a new
+// configuration reloads the server without dropping connections.
+var bootstrapTemplate = template.Must(template.New("nats-bootstrap").Parse(`set -eu
+VERSION={{.Version}}
+if ! /usr/local/bin/nats-server --version 2>/dev/null | grep -q "v$VERSION"; then
+  case "$(uname -m)" in
+    x86_64) ARCH=amd64 ;;
+    aarch64) ARCH=arm64 ;;
+    *) echo "unsupported architecture $(uname -m)" >&2; exit 1 ;;
+  esac
+  TMP=$(mktemp -d)
+  curl -fsSL -o "$TMP/nats.tar.gz" "https://github.com/nats-io/nats-server/releases/download/v$VERSION/nats-server-v$VERSION-linux-$ARCH.tar.gz"
+  tar -xzf "$TMP/nats.tar.gz" -C "$TMP"
+  install -m 0755 "$TMP/nats-server-v$VERSION-linux-$ARCH/nats-server" /usr/local/bin/nats-server
+  rm -rf "$TMP"
+fi
+
+id nats >/dev/null 2>&1 || useradd --system --home-dir /var/lib/nats --shell /usr/sbin/nologin nats
+mkdir -p {{.ConfigDir}} {{.StoreDir}}
+umask 077
+cat > {{.ConfigFile}}.new <<'NATSCONF'
+{{.Config}}NATSCONF
+{{- range .Files}}
+cat > {{.Path}} <<'NATSPEM'
+{{.Content}}NATSPEM
+{{- end}}
+chown -R nats:nats {{.ConfigDir}} /var/lib/nats
+/usr/local/bin/nats-server -t -c {{.ConfigFile}}.new >/dev/null
+mv {{.ConfigFile}}.new {{.ConfigFile}}
+
+umask 022
+cat > /etc/systemd/system/nats-server.service <<'NATSUNIT'
+[Unit]
+Description=NATS Server
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+User=nats
+ExecStart=/usr/local/bin/nats-server -c {{.ConfigFile}}
+ExecReload=/bin/kill -s HUP $MAINPID
+Restart=always
+RestartSec=5
+LimitNOFILE=65536
+
+[Install]
+WantedBy=multi-user.target
+NATSUNIT
+systemctl daemon-reload
+systemctl enable nats-server >/dev/null 2>&1
+if systemctl is-active --quiet nats-server; then
+  systemctl reload nats-server
+else
+  systemctl start nats-server
+fi
+if command -v ufw >/dev/null 2>&1; then
+  ufw allow {{.ClientPort}}/tcp comment 'NATS client' >/dev/null
+  ufw allow {{.ClusterPort}}/tcp comment 'NATS cluster' >/dev/null
+  ufw allow {{.MonitorPort}}/tcp comment 'NATS monitoring' >/dev/null
+fi
+echo "nats-server $VERSION running"
+`))
+
+// BootstrapScript returns a shell script that installs nats-server version
+// on a node and configures it with cfg
+func BootstrapScript(version string, cfg ServerConfig) (string, error) {
+	type file struct{ Path, Content string }
+	var files []file
+	if cfg.TLS != nil {
+		files = []file{
+			{ConfigDir + "/ca.pem", string(cfg.TLS.CA)},
+			{ConfigDir + "/server.pem", string(cfg.TLS.Cert)},
+			{ConfigDir + "/server-key.pem", string(cfg.TLS.Key)},
+		}
+	}
+
+	var buf bytes.Buffer
+	err := bootstrapTemplate.Execute(&buf, map[string]interface{}{
+		"Version":     version,
+		"Config":      cfg.Render(),
+		"Files":       files,
+		"ConfigDir":   ConfigDir,
+		"ConfigFile":  ConfigFile,
+		"StoreDir":    StoreDir,
+		"ClientPort":  ClientPort,
+		"ClusterPort": ClusterPort,
+		"MonitorPort": MonitorPort,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render NATS bootstrap script: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,148 @@
+package nats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// apiTimeout bounds JetStream API requests
+const apiTimeout = 5 * time.Second
+
+// JetStream API error codes used here
+const (
+	errCodeStreamNotFound   = 10059
+	errCodeConsumerNotFound = 10014
+)
+
+// APIError is an error returned by the JetStream API
+type APIError struct {
+	Code        int    `json:"code"`
+	ErrCode     int    `json:"err_code"`
+	Description string `json:"description"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("JetStream: %s (%d)", e.Description, e.ErrCode)
+}
+
+// apiRequest calls the JetStream API and decodes its reply into out
+func (c *Conn) apiRequest(subject string, body, out interface{}) error {
+	data := []byte{}
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	msg, err := c.Request(subject, data, apiTimeout)
+	if errors.Is(err, ErrNoResponders) {
+		return fmt.Errorf("JetStream is not enabled on the NATS server")
+	}
+	if err != nil {
+		return err
+	}
+	var reply struct {
+		Error *APIError `json:"error"`
+	}
+	if err := json.Unmarshal(msg.Data, &reply); err != nil {
+		return fmt.Errorf("unexpected JetStream reply: %w", err)
+	}
+	if reply.Error != nil {
+		return reply.Error
+	}
+	if out != nil {
+		return json.Unmarshal(msg.Data, out)
+	}
+	return nil
+}
+
+// StreamName returns a stream name for a subject, e.g. MORPHEUS_JOBS for
+// morpheus.jobs
+func StreamName(subject string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, subject)
+}
+
+// PullConsumer takes messages from a durable JetStream consumer one at a
+// time, when asked for, so nothing is handed to a client before it can
+// take it
+type PullConsumer struct {
+	conn     *Conn
+	stream   string
+	consumer string
+}
+
+// WorkQueue returns a pull consumer of a work queue stream on subject,
+// creating the stream and the durable consumer if they do not exist.
+// Every message is taken by one consumer client and removed from the
+// stream once acknowledged.
+func (c *Conn) WorkQueue(subject, consumer string) (*PullConsumer, error) {
+	stream := StreamName(subject)
+	var apiErr *APIError
+
+	err := c.apiRequest("$JS.API.STREAM.INFO."+stream, nil, nil)
+	if errors.As(err, &apiErr) && apiErr.ErrCode == errCodeStreamNotFound {
+		err = c.apiRequest("$JS.API.STREAM.CREATE."+stream, map[string]interface{}{
+			"name":      stream,
+			"subjects":  []string{subject},
+			"retention": "workqueue",
+			"storage":   "file",
+		}, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up stream %s: %w", stream, err)
+	}
+
+	err = c.apiRequest("$JS.API.CONSUMER.INFO."+stream+"."+consumer, nil, nil)
+	if errors.As(err, &apiErr) && apiErr.ErrCode == errCodeConsumerNotFound {
+		err = c.apiRequest("$JS.API.CONSUMER.DURABLE.CREATE."+stream+"."+consumer, map[string]interface{}{
+			"stream_name": stream,
+			"config": map[string]interface{}{
+				"durable_name":   consumer,
+				"ack_policy":     "explicit",
+				"deliver_policy": "all",
+			},
+		}, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up consumer %s: %w", consumer, err)
+	}
+	return &PullConsumer{conn: c, stream: stream, consumer: consumer}, nil
+}
+
+// Next pulls the next message, waiting up to wait for one to arrive. It
+// returns ErrTimeout if none did.
+func (p *PullConsumer) Next(wait time.Duration) (*Msg, error) {
+	body, _ := json.Marshal(map[string]interface{}{"batch": 1, "expires": wait.Nanoseconds()})
+	msg, err := p.conn.Request("$JS.API.CONSUMER.MSG.NEXT."+p.stream+"."+p.consumer, body, wait+apiTimeout)
+	if err != nil {
+		return nil, err
+	}
+	switch msg.Status {
+	case "":
+		return msg, nil
+	case "404", "408":
+		// No messages, or the pull expired
+		return nil, ErrTimeout
+	default:
+		return nil, fmt.Errorf("JetStream pull failed: %s %s", msg.Status, msg.Description)
+	}
+}
+
+// Ack acknowledges a pulled message, removing it from the work queue
+func (p *PullConsumer) Ack(msg *Msg) error {
+	if msg.Reply == "" {
+		return fmt.Errorf("message from %s cannot be acknowledged", msg.Subject)
+	}
+	return p.conn.Publish(msg.Reply, []byte("+ACK"))
+}
@@ -0,0 +1,332 @@
+// Package netbox registers morpheus servers and their IP addresses in a
+// NetBox instance, so the NetBox inventory follows what morpheus provisions.
+package netbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+const (
+	// publicInterface holds the server's public addresses
+	publicInterface = "eth0"
+	// meshInterface holds the server's mesh (WireGuard) address
+	meshInterface = "wg0"
+)
+
+// Device is a server as registered in NetBox. Serial holds the provider's
+// server ID, which is how devices are found again on removal.
+type Device struct {
+	Name     string
+	Serial   string
+	ForestID string
+	IPv4     string
+	IPv6     string
+	MeshIP   string // Optional; assigned to a wg0 interface
+}
+
+// Client talks to the NetBox REST API
+type Client struct {
+	baseURL string
+	token   string
+	cfg     config.NetBoxConfig
+	client  *http.Client
+
+	// IDs of the configured site, role and type, resolved on first use
+	siteID, roleID, typeID int
+}
+
+// NewClient creates a NetBox client from the integration config
+func NewClient(cfg config.NetBoxConfig) (*Client, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("NetBox URL is required")
+	}
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("NetBox token is required")
+	}
+
+	return &Client{
+		baseURL: strings.TrimSuffix(cfg.URL, "/") + "/api",
+		token:   cfg.Token,
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// RegisterNode registers a forest server, its public IPs and its mesh IP
+// (if it joined the mesh) as a device
+func (c *Client) RegisterNode(ctx context.Context, forestID string, server *machine.Server, meshIP string) error {
+	return c.RegisterDevice(ctx, Device{
+		Name:     server.Name,
+		Serial:   server.ID,
+		ForestID: forestID,
+		IPv4:     server.PublicIPv4,
+		IPv6:     server.PublicIPv6,
+		MeshIP:   meshIP,
+	})
+}
+
+// RemoveNode removes the device registered for a server, and its IPs
+func (c *Client) RemoveNode(ctx context.Context, serverID string) error {
+	return c.RemoveDevice(ctx, serverID)
+}
+
+// RegisterDevice creates the device (or updates an existing one with the
+// same serial), its interfaces and IP addresses, and sets its primary IPs.
+// It is safe to call repeatedly.
+func (c *Client) RegisterDevice(ctx context.Context, d Device) error {
+	if err := c.resolveIDs(ctx); err != nil {
+		return err
+	}
+
+	deviceID, err := c.findDevice(ctx, d.Serial)
+	if err != nil {
+		return err
+	}
+	description := fmt.Sprintf("morpheus forest %s", d.ForestID)
+	if deviceID == 0 {
+		var created struct {
+			ID int `json:"id"`
+		}
+		err := c.do(ctx, http.MethodPost, "/dcim/devices/", map[string]interface{}{
+			"name":        d.Name,
+			"serial":      d.Serial,
+			"site":        c.siteID,
+			"role":        c.roleID, // NetBox >= 3.6
+			"device_role": c.roleID, // Older NetBox versions
+			"device_type": c.typeID,
+			"status":      "active",
+			"description": description,
+		}, &created)
+		if err != nil {
+			return fmt.Errorf("failed to create device %s: %w", d.Name, err)
+		}
+		deviceID = created.ID
+	}
+
+	primary := map[string]interface{}{}
+	if d.IPv4 != "" || d.IPv6 != "" {
+		ifaceID, err := c.ensureInterface(ctx, deviceID, publicInterface)
+		if err != nil {
+			return err
+		}
+		if d.IPv4 != "" {
+			ipID, err := c.ensureIP(ctx, ifaceID, d.IPv4+"/32", d.Name)
+			if err != nil {
+				return err
+			}
+			primary["primary_ip4"] = ipID
+		}
+		if d.IPv6 != "" {
+			// Hetzner assigns each server a /64; the server uses ::1 within it
+			ipID, err := c.ensureIP(ctx, ifaceID, machine.HostIPv6(d.IPv6)+"/64", d.Name)
+			if err != nil {
+				return err
+			}
+			primary["primary_ip6"] = ipID
+		}
+	}
+
+	if d.MeshIP != "" {
+		ifaceID, err := c.ensureInterface(ctx, deviceID, meshInterface)
+		if err != nil {
+			return err
+		}
+		prefix := "/32"
+		if ip := net.ParseIP(d.MeshIP); ip != nil && ip.To4() == nil {
+			prefix = "/128"
+		}
+		if _, err := c.ensureIP(ctx, ifaceID, d.MeshIP+prefix, d.Name); err != nil {
+			return err
+		}
+	}
+
+	if len(primary) > 0 {
+		if err := c.do(ctx, http.MethodPatch, fmt.Sprintf("/dcim/devices/%d/", deviceID), primary, nil); err != nil {
+			return fmt.Errorf("failed to set primary IPs of %s: %w", d.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// RemoveDevice deletes the device with the given serial and the IP
+// addresses assigned to it. A missing device is not an error.
+func (c *Client) RemoveDevice(ctx context.Context, serial string) error {
+	deviceID, err := c.findDevice(ctx, serial)
+	if err != nil || deviceID == 0 {
+		return err
+	}
+
+	// IPs are not removed with the device, only unassigned
+	var ips []struct {
+		ID int `json:"id"`
+	}
+	if err := c.list(ctx, "/ipam/ip-addresses/", url.Values{"device_id": {fmt.Sprint(deviceID)}}, &ips); err != nil {
+		return fmt.Errorf("failed to list IP addresses: %w", err)
+	}
+	for _, ip := range ips {
+		if err := c.do(ctx, http.MethodDelete, fmt.Sprintf("/ipam/ip-addresses/%d/", ip.ID), nil, nil); err != nil {
+			return fmt.Errorf("failed to delete IP address %d: %w", ip.ID, err)
+		}
+	}
+
+	if err := c.do(ctx, http.MethodDelete, fmt.Sprintf("/dcim/devices/%d/", deviceID), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete device: %w", err)
+	}
+	return nil
+}
+
+// resolveIDs looks up the configured site, device role and device type
+func (c *Client) resolveIDs(ctx context.Context) error {
+	if c.siteID != 0 {
+		return nil
+	}
+
+	lookups := []struct {
+		path string
+		slug string
+		id   *int
+	}{
+		{"/dcim/sites/", c.cfg.Site, &c.siteID},
+		{"/dcim/device-roles/", c.cfg.DeviceRole, &c.roleID},
+		{"/dcim/device-types/", c.cfg.DeviceType, &c.typeID},
+	}
+	for _, l := range lookups {
+		id, err := c.findID(ctx, l.path, url.Values{"slug": {l.slug}})
+		if err != nil {
+			return err
+		}
+		if id == 0 {
+			return fmt.Errorf("NetBox object not found: %s?slug=%s", l.path, l.slug)
+		}
+		*l.id = id
+	}
+	return nil
+}
+
+func (c *Client) findDevice(ctx context.Context, serial string) (int, error) {
+	if serial == "" {
+		return 0, fmt.Errorf("device serial (server ID) is required")
+	}
+	return c.findID(ctx, "/dcim/devices/", url.Values{"serial": {serial}})
+}
+
+func (c *Client) ensureInterface(ctx context.Context, deviceID int, name string) (int, error) {
+	id, err := c.findID(ctx, "/dcim/interfaces/", url.Values{"device_id": {fmt.Sprint(deviceID)}, "name": {name}})
+	if err != nil || id != 0 {
+		return id, err
+	}
+
+	var created struct {
+		ID int `json:"id"`
+	}
+	err = c.do(ctx, http.MethodPost, "/dcim/interfaces/", map[string]interface{}{
+		"device": deviceID,
+		"name":   name,
+		"type":   "virtual",
+	}, &created)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create interface %s: %w", name, err)
+	}
+	return created.ID, nil
+}
+
+func (c *Client) ensureIP(ctx context.Context, ifaceID int, address, dnsName string) (int, error) {
+	id, err := c.findID(ctx, "/ipam/ip-addresses/", url.Values{"interface_id": {fmt.Sprint(ifaceID)}, "address": {address}})
+	if err != nil || id != 0 {
+		return id, err
+	}
+
+	var created struct {
+		ID int `json:"id"`
+	}
+	err = c.do(ctx, http.MethodPost, "/ipam/ip-addresses/", map[string]interface{}{
+		"address":              address,
+		"status":               "active",
+		"assigned_object_type": "dcim.interface",
+		"assigned_object_id":   ifaceID,
+		"description":          "morpheus " + dnsName,
+	}, &created)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create IP address %s: %w", address, err)
+	}
+	return created.ID, nil
+}
+
+// findID returns the ID of the first object matching query, or 0
+func (c *Client) findID(ctx context.Context, path string, query url.Values) (int, error) {
+	var results []struct {
+		ID int `json:"id"`
+	}
+	if err := c.list(ctx, path, query, &results); err != nil {
+		return 0, fmt.Errorf("failed to query %s: %w", path, err)
+	}
+	if len(results) == 0 {
+		return 0, nil
+	}
+	return results[0].ID, nil
+}
+
+// list fetches the results of a list endpoint into out
+func (c *Client) list(ctx context.Context, path string, query url.Values, out interface{}) error {
+	var page struct {
+		Results json.RawMessage `json:"results"`
+	}
+	if err := c.do(ctx, http.MethodGet, path+"?"+query.Encode(), nil, &page); err != nil {
+		return err
+	}
+	if len(page.Results) == 0 {
+		return nil
+	}
+	return json.Unmarshal(page.Results, out)
+}
+
+// do performs an API request, decoding the response into out if non-nil
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Token "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,196 @@
+package netbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/machine"
+)
+
+// fakeNetBox is a minimal in-memory NetBox API. Objects are stored per
+// endpoint as generic maps; list filters match fields by string value.
+type fakeNetBox struct {
+	mu      sync.Mutex
+	nextID  int
+	objects map[string]map[int]map[string]interface{} // endpoint -> id -> object
+}
+
+func newFakeNetBox() *fakeNetBox {
+	f := &fakeNetBox{objects: make(map[string]map[int]map[string]interface{})}
+	f.add("dcim/sites", map[string]interface{}{"slug": "fsn1"})
+	f.add("dcim/device-roles", map[string]interface{}{"slug": "forest-node"})
+	f.add("dcim/device-types", map[string]interface{}{"slug": "cloud-server"})
+	return f
+}
+
+func (f *fakeNetBox) add(endpoint string, obj map[string]interface{}) int {
+	f.nextID++
+	obj["id"] = f.nextID
+	if f.objects[endpoint] == nil {
+		f.objects[endpoint] = make(map[int]map[string]interface{})
+	}
+	f.objects[endpoint][f.nextID] = obj
+	return f.nextID
+}
+
+// matches applies NetBox-style filters, including the derived
+// device_id/interface_id filters on interfaces and IP addresses
+func (f *fakeNetBox) matches(endpoint string, obj map[string]interface{}, key, want string) bool {
+	switch {
+	case endpoint == "dcim/interfaces" && key == "device_id":
+		key = "device"
+	case endpoint == "ipam/ip-addresses" && key == "interface_id":
+		key = "assigned_object_id"
+	case endpoint == "ipam/ip-addresses" && key == "device_id":
+		iface := f.objects["dcim/interfaces"][toInt(obj["assigned_object_id"])]
+		return iface != nil && fmt.Sprint(toInt(iface["device"])) == want
+	}
+	if n, ok := obj[key].(float64); ok {
+		return fmt.Sprint(int(n)) == want
+	}
+	return fmt.Sprint(obj[key]) == want
+}
+
+func toInt(v interface{}) int {
+	switch n := v.(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
+
+func (f *fakeNetBox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Token secret" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/"), "/"), "/")
+	endpoint := parts[0] + "/" + parts[1]
+	id := 0
+	if len(parts) > 2 {
+		fmt.Sscanf(parts[2], "%d", &id)
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		results := []map[string]interface{}{}
+		for _, obj := range f.objects[endpoint] {
+			ok := true
+			for key := range r.URL.Query() {
+				if !f.matches(endpoint, obj, key, r.URL.Query().Get(key)) {
+					ok = false
+				}
+			}
+			if ok {
+				results = append(results, obj)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"count": len(results), "results": results})
+	case http.MethodPost:
+		var obj map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&obj)
+		f.add(endpoint, obj)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(obj)
+	case http.MethodPatch:
+		var patch map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&patch)
+		for k, v := range patch {
+			f.objects[endpoint][id][k] = v
+		}
+		json.NewEncoder(w).Encode(f.objects[endpoint][id])
+	case http.MethodDelete:
+		delete(f.objects[endpoint], id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestRegisterAndRemoveNode(t *testing.T) {
+	fake := newFakeNetBox()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client, err := NewClient(config.NetBoxConfig{
+		URL:        server.URL + "/",
+		Token:      "secret",
+		Site:       "fsn1",
+		DeviceRole: "forest-node",
+		DeviceType: "cloud-server",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	node := &machine.Server{ID: "4711", Name: "forest-1-node-1", PublicIPv4: "203.0.113.10", PublicIPv6: "2001:db8::1"}
+	for i := 0; i < 2; i++ {
+		// Registering twice must not duplicate anything
+		if err := client.RegisterNode(ctx, "forest-1", node, ""); err != nil {
+			t.Fatalf("RegisterNode() error = %v", err)
+		}
+	}
+
+	if n := len(fake.objects["dcim/devices"]); n != 1 {
+		t.Fatalf("Expected 1 device, got %d", n)
+	}
+	if n := len(fake.objects["ipam/ip-addresses"]); n != 2 {
+		t.Fatalf("Expected 2 IP addresses, got %d", n)
+	}
+	for _, d := range fake.objects["dcim/devices"] {
+		if d["serial"] != "4711" || d["primary_ip4"] == nil || d["primary_ip6"] == nil {
+			t.Errorf("Unexpected device: %v", d)
+		}
+	}
+
+	// Once the node joined the mesh, its mesh IP is added on wg0
+	if err := client.RegisterNode(ctx, "forest-1", node, "10.42.0.1"); err != nil {
+		t.Fatalf("RegisterNode() error = %v", err)
+	}
+	if n := len(fake.objects["dcim/interfaces"]); n != 2 {
+		t.Errorf("Expected eth0 and wg0 interfaces, got %d", n)
+	}
+	meshIP := false
+	for _, ip := range fake.objects["ipam/ip-addresses"] {
+		meshIP = meshIP || ip["address"] == "10.42.0.1/32"
+	}
+	if !meshIP {
+		t.Errorf("Expected mesh IP 10.42.0.1/32 to be registered, got %v", fake.objects["ipam/ip-addresses"])
+	}
+
+	if err := client.RemoveNode(ctx, "4711"); err != nil {
+		t.Fatalf("RemoveNode() error = %v", err)
+	}
+	if len(fake.objects["dcim/devices"]) != 0 || len(fake.objects["ipam/ip-addresses"]) != 0 {
+		t.Errorf("Expected device and IPs to be removed, got %d devices and %d IPs",
+			len(fake.objects["dcim/devices"]), len(fake.objects["ipam/ip-addresses"]))
+	}
+
+	// Removing an unknown server is not an error
+	if err := client.RemoveNode(ctx, "4711"); err != nil {
+		t.Errorf("RemoveNode() of missing device error = %v", err)
+	}
+}
+
+func TestRegisterNodeUnknownSite(t *testing.T) {
+	server := httptest.NewServer(newFakeNetBox())
+	defer server.Close()
+
+	client, _ := NewClient(config.NetBoxConfig{URL: server.URL, Token: "secret", Site: "nbg1", DeviceRole: "forest-node", DeviceType: "cloud-server"})
+	err := client.RegisterNode(context.Background(), "forest-1", &machine.Server{ID: "1", Name: "n"}, "")
+	if err == nil || !strings.Contains(err.Error(), "slug=nbg1") {
+		t.Errorf("Expected unknown site error, got %v", err)
+	}
+}
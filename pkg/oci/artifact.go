@@ -0,0 +1,295 @@
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	manifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	emptyMediaType    = "application/vnd.oci.empty.v1+json"
+	titleAnnotation   = "org.opencontainers.image.title"
+	createdAnnotation = "org.opencontainers.image.created"
+)
+
+// emptyConfig is the config blob of artifacts, per the OCI image spec
+var emptyConfig = []byte("{}")
+
+// File is a file stored in an artifact
+type File struct {
+	Name      string // Slash-separated path within the artifact
+	MediaType string
+	Data      []byte
+}
+
+// Descriptor describes a blob in a manifest
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Manifest is an OCI image manifest describing an artifact
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Push uploads files as an artifact of the given type and tags it. It
+// returns the manifest digest.
+func (c *Client) Push(ctx context.Context, ref *Reference, artifactType string, files []File) (string, error) {
+	if ref.Tag == "" {
+		return "", fmt.Errorf("push needs a tag, not a digest: %s", ref)
+	}
+
+	manifest := Manifest{
+		SchemaVersion: 2,
+		MediaType:     manifestMediaType,
+		ArtifactType:  artifactType,
+		Config:        Descriptor{MediaType: emptyMediaType, Digest: digestOf(emptyConfig), Size: int64(len(emptyConfig))},
+		Annotations:   map[string]string{createdAnnotation: time.Now().UTC().Format(time.RFC3339)},
+	}
+	if err := c.pushBlob(ctx, ref, emptyConfig); err != nil {
+		return "", err
+	}
+
+	for _, f := range files {
+		if err := c.pushBlob(ctx, ref, f.Data); err != nil {
+			return "", fmt.Errorf("%s: %w", f.Name, err)
+		}
+		manifest.Layers = append(manifest.Layers, Descriptor{
+			MediaType:   f.MediaType,
+			Digest:      digestOf(f.Data),
+			Size:        int64(len(f.Data)),
+			Annotations: map[string]string{titleAnnotation: f.Name},
+		})
+	}
+
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.url(ref, "manifests/"+ref.Tag), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", manifestMediaType)
+	resp, err := c.do(req, ref, true, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to push manifest: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", registryError(resp, "push manifest")
+	}
+	return digestOf(body), nil
+}
+
+// pushBlob uploads a blob unless the registry already has it
+func (c *Client) pushBlob(ctx context.Context, ref *Reference, data []byte) error {
+	digest := digestOf(data)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.url(ref, "blobs/"+digest), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req, ref, true, nil)
+	if err != nil {
+		return fmt.Errorf("failed to check blob: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	// Monolithic upload: start a session, then PUT the whole blob
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, c.url(ref, "blobs/uploads/"), nil)
+	if err != nil {
+		return err
+	}
+	resp, err = c.do(req, ref, true, []byte{})
+	if err != nil {
+		return fmt.Errorf("failed to start upload: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return registryError(resp, "start upload")
+	}
+
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return fmt.Errorf("registry returned no upload location")
+	}
+	q := location.Query()
+	q.Set("digest", digest)
+	location.RawQuery = q.Encode()
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodPut, location.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err = c.do(req, ref, true, data)
+	if err != nil {
+		return fmt.Errorf("failed to upload blob: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return registryError(resp, "upload blob")
+	}
+	return nil
+}
+
+// Pull downloads an artifact's manifest and the files it contains
+func (c *Client) Pull(ctx context.Context, ref *Reference) (*Manifest, []File, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(ref, "manifests/"+ref.reference()), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", manifestMediaType)
+	resp, err := c.do(req, ref, false, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get manifest: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, registryError(resp, "get manifest "+ref.String())
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if ref.Digest != "" && digestOf(body) != ref.Digest {
+		return nil, nil, fmt.Errorf("manifest digest mismatch for %s", ref)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if manifest.MediaType != "" && manifest.MediaType != manifestMediaType {
+		return nil, nil, fmt.Errorf("%s is not an OCI artifact (%s)", ref, manifest.MediaType)
+	}
+
+	var files []File
+	for _, layer := range manifest.Layers {
+		name := layer.Annotations[titleAnnotation]
+		if name == "" {
+			continue // Not a file
+		}
+		if err := validateName(name); err != nil {
+			return nil, nil, err
+		}
+		data, err := c.pullBlob(ctx, ref, layer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		files = append(files, File{Name: name, MediaType: layer.MediaType, Data: data})
+	}
+	return &manifest, files, nil
+}
+
+// pullBlob downloads a blob and verifies its digest
+func (c *Client) pullBlob(ctx context.Context, ref *Reference, desc Descriptor) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(ref, "blobs/"+desc.Digest), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(req, ref, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download blob: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, registryError(resp, "download blob")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, desc.Size+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download blob: %w", err)
+	}
+	if int64(len(data)) != desc.Size || digestOf(data) != desc.Digest {
+		return nil, fmt.Errorf("blob %s does not match its digest", desc.Digest)
+	}
+	return data, nil
+}
+
+// ReadDir reads all regular files below dir, named by their slash-separated
+// path relative to dir. Hidden files and directories are skipped.
+func ReadDir(dir, mediaType string) ([]File, error) {
+	var files []File
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Name: filepath.ToSlash(rel), MediaType: mediaType, Data: data})
+		return nil
+	})
+	return files, err
+}
+
+// WriteFiles writes files below dir, creating subdirectories as needed
+func WriteFiles(dir string, files []File) error {
+	for _, f := range files {
+		if err := validateName(f.Name); err != nil {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, f.Data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateName rejects file names that would escape the target directory
+func validateName(name string) error {
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") || strings.Contains(name, "\\") {
+		return fmt.Errorf("unsafe file name in artifact: %s", name)
+	}
+	return nil
+}
+
+func (c *Client) url(ref *Reference, suffix string) string {
+	return baseURL(ref.Registry) + "/v2/" + (&url.URL{Path: ref.Repository}).EscapedPath() + "/" + suffix
+}
+
+func digestOf(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
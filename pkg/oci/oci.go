@@ -0,0 +1,313 @@
+// Package oci pushes and pulls files as OCI artifacts, using the OCI
+// distribution API of registries such as ghcr.io, Docker Hub or Harbor.
+// Artifacts follow the ORAS conventions (one layer per file, named by its
+// org.opencontainers.image.title annotation), so they interoperate with
+// the oras CLI.
+package oci
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reference is a parsed artifact reference: registry/repository:tag or
+// registry/repository@digest
+type Reference struct {
+	Registry   string // e.g., ghcr.io
+	Repository string // e.g., org/blueprints/analytics
+	Tag        string // Empty when Digest is set
+	Digest     string
+}
+
+// ParseReference parses an artifact reference. References without a
+// registry host refer to Docker Hub; the tag defaults to latest.
+func ParseReference(ref string) (*Reference, error) {
+	ref = strings.TrimPrefix(ref, "oci://")
+	if ref == "" {
+		return nil, fmt.Errorf("empty reference")
+	}
+
+	r := &Reference{}
+	if at := strings.Index(ref, "@"); at >= 0 {
+		r.Digest = ref[at+1:]
+		ref = ref[:at]
+		if !strings.HasPrefix(r.Digest, "sha256:") {
+			return nil, fmt.Errorf("unsupported digest %s (only sha256 is supported)", r.Digest)
+		}
+	}
+
+	// The registry is the first component if it looks like a host
+	if slash := strings.Index(ref, "/"); slash >= 0 {
+		host := ref[:slash]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			r.Registry = host
+			ref = ref[slash+1:]
+		}
+	}
+	if r.Registry == "" {
+		r.Registry = "docker.io"
+		if !strings.Contains(ref, "/") {
+			ref = "library/" + ref
+		}
+	}
+
+	// A colon after the last slash separates the tag
+	if colon := strings.LastIndex(ref, ":"); colon > strings.LastIndex(ref, "/") {
+		r.Tag = ref[colon+1:]
+		ref = ref[:colon]
+	}
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = "latest"
+	}
+	if ref == "" || strings.ToLower(ref) != ref {
+		return nil, fmt.Errorf("invalid repository %q (must be lowercase)", ref)
+	}
+	r.Repository = ref
+	return r, nil
+}
+
+// String formats the reference
+func (r *Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// reference returns the tag or digest used in manifest URLs
+func (r *Reference) reference() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// Client talks to OCI registries
+type Client struct {
+	client *http.Client
+
+	// Username and Password authenticate to the registry. When empty,
+	// credentials are looked up in the Docker config (docker login).
+	Username string
+	Password string
+
+	mu     sync.Mutex
+	tokens map[string]string // Bearer tokens by registry and scope
+}
+
+// NewClient creates a registry client
+func NewClient() *Client {
+	return &Client{
+		client: &http.Client{Timeout: 5 * time.Minute},
+		tokens: make(map[string]string),
+	}
+}
+
+// baseURL returns the registry's API base URL. Local registries are
+// spoken to over plain HTTP.
+func baseURL(registry string) string {
+	if registry == "docker.io" {
+		registry = "registry-1.docker.io"
+	}
+	host := registry
+	if h, _, ok := strings.Cut(registry, ":"); ok {
+		host = h
+	}
+	if host == "localhost" || host == "127.0.0.1" || host == "[::1]" {
+		return "http://" + registry
+	}
+	return "https://" + registry
+}
+
+// do sends a request, authenticating when the registry asks for it.
+// body must be re-readable for retries, so it is passed as bytes.
+func (c *Client) do(req *http.Request, ref *Reference, push bool, body []byte) (*http.Response, error) {
+	scope := "repository:" + ref.Repository + ":pull"
+	if push {
+		scope += ",push"
+	}
+
+	send := func() (*http.Response, error) {
+		if body != nil {
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+		}
+		c.mu.Lock()
+		token := c.tokens[ref.Registry+" "+scope]
+		c.mu.Unlock()
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		return c.client.Do(req)
+	}
+
+	resp, err := send()
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	token, err := c.authenticate(ref.Registry, challenge, scope)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.tokens[ref.Registry+" "+scope] = token
+	c.mu.Unlock()
+	return send()
+}
+
+// authenticate answers a registry's WWW-Authenticate challenge and
+// returns the Authorization header to use
+func (c *Client) authenticate(registry, challenge, scope string) (string, error) {
+	username, password := c.credentials(registry)
+	scheme, params := parseChallenge(challenge)
+
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if username == "" {
+			return "", fmt.Errorf("registry %s requires login (docker login %s)", registry, registry)
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)), nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("registry %s: unsupported authentication %q", registry, challenge)
+	}
+
+	tokenURL, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("registry %s: invalid token realm %q", registry, params["realm"])
+	}
+	q := tokenURL.Query()
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	q.Set("scope", scope)
+	tokenURL.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get registry token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry %s denied access (HTTP %d); check 'docker login %s'", registry, resp.StatusCode, registry)
+	}
+
+	var result struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("invalid registry token response: %w", err)
+	}
+	if result.Token == "" {
+		result.Token = result.AccessToken
+	}
+	if result.Token == "" {
+		return "", fmt.Errorf("registry %s returned no token", registry)
+	}
+	return "Bearer " + result.Token, nil
+}
+
+// parseChallenge splits a WWW-Authenticate header such as
+// Bearer realm="https://ghcr.io/token",service="ghcr.io"
+func parseChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params := make(map[string]string)
+	for _, part := range strings.Split(rest, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			params[strings.ToLower(key)] = strings.Trim(value, `"`)
+		}
+	}
+	return scheme, params
+}
+
+// credentials returns the configured credentials, or those stored by
+// docker login (~/.docker/config.json, or $DOCKER_CONFIG/config.json)
+func (c *Client) credentials(registry string) (string, string) {
+	if c.Username != "" {
+		return c.Username, c.Password
+	}
+
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", ""
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		return "", ""
+	}
+	var cfg struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return "", ""
+	}
+
+	keys := []string{registry, "https://" + registry}
+	if registry == "docker.io" {
+		keys = append(keys, "https://index.docker.io/v1/")
+	}
+	for _, key := range keys {
+		entry, ok := cfg.Auths[key]
+		if !ok || entry.Auth == "" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+		if err != nil {
+			continue
+		}
+		if user, pass, ok := strings.Cut(string(decoded), ":"); ok {
+			return user, pass
+		}
+	}
+	return "", ""
+}
+
+// registryError turns an unexpected registry response into an error
+func registryError(resp *http.Response, action string) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var result struct {
+		Errors []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if json.Unmarshal(body, &result) == nil && len(result.Errors) > 0 {
+		return fmt.Errorf("failed to %s: %s: %s", action, result.Errors[0].Code, result.Errors[0].Message)
+	}
+	return fmt.Errorf("failed to %s: HTTP %d", action, resp.StatusCode)
+}
@@ -0,0 +1,207 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		ref                       string
+		registry, repo, tag, dgst string
+		wantErr                   bool
+	}{
+		{ref: "ghcr.io/org/blueprints/analytics:v2", registry: "ghcr.io", repo: "org/blueprints/analytics", tag: "v2"},
+		{ref: "ghcr.io/org/analytics", registry: "ghcr.io", repo: "org/analytics", tag: "latest"},
+		{ref: "localhost:5000/analytics:1.0", registry: "localhost:5000", repo: "analytics", tag: "1.0"},
+		{ref: "org/analytics:v1", registry: "docker.io", repo: "org/analytics", tag: "v1"},
+		{ref: "analytics", registry: "docker.io", repo: "library/analytics", tag: "latest"},
+		{ref: "ghcr.io/org/analytics@sha256:abc", registry: "ghcr.io", repo: "org/analytics", dgst: "sha256:abc"},
+		{ref: "oci://ghcr.io/org/analytics:v2", registry: "ghcr.io", repo: "org/analytics", tag: "v2"},
+		{ref: "ghcr.io/Org/Analytics:v2", wantErr: true},
+		{ref: "ghcr.io/org/analytics@md5:abc", wantErr: true},
+		{ref: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			r, err := ParseReference(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseReference() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if r.Registry != tt.registry || r.Repository != tt.repo || r.Tag != tt.tag || r.Digest != tt.dgst {
+				t.Errorf("ParseReference() = %+v", r)
+			}
+		})
+	}
+}
+
+// fakeRegistry is an in-memory OCI registry that requires a bearer token
+type fakeRegistry struct {
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	uploads   int
+}
+
+func newFakeRegistry(t *testing.T) (*fakeRegistry, *httptest.Server) {
+	reg := &fakeRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reg.mu.Lock()
+		defer reg.mu.Unlock()
+
+		if r.URL.Path == "/token" {
+			user, pass, ok := r.BasicAuth()
+			if !ok || user != "deploy" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"token":"t0ken"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		path := strings.TrimPrefix(r.URL.Path, "/v2/org/blueprints/")
+		switch {
+		case strings.HasPrefix(path, "blobs/uploads/") && r.Method == http.MethodPost:
+			w.Header().Set("Location", "/v2/org/blueprints/blobs/uploads/session?state=1")
+			w.WriteHeader(http.StatusAccepted)
+		case strings.HasPrefix(path, "blobs/uploads/") && r.Method == http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			if r.URL.Query().Get("state") != "1" || r.URL.Query().Get("digest") != digestOf(data) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			reg.blobs[digestOf(data)] = data
+			reg.uploads++
+			w.WriteHeader(http.StatusCreated)
+		case strings.HasPrefix(path, "blobs/"):
+			data, ok := reg.blobs[strings.TrimPrefix(path, "blobs/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		case strings.HasPrefix(path, "manifests/") && r.Method == http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			reg.manifests[strings.TrimPrefix(path, "manifests/")] = data
+			reg.manifests[digestOf(data)] = data
+			w.WriteHeader(http.StatusCreated)
+		case strings.HasPrefix(path, "manifests/"):
+			data, ok := reg.manifests[strings.TrimPrefix(path, "manifests/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"errors":[{"code":"MANIFEST_UNKNOWN","message":"manifest unknown"}]}`)
+				return
+			}
+			w.Header().Set("Content-Type", manifestMediaType)
+			w.Write(data)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return reg, server
+}
+
+func TestPushPull(t *testing.T) {
+	reg, server := newFakeRegistry(t)
+	ref, err := ParseReference(strings.TrimPrefix(server.URL, "http://") + "/org/blueprints:v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := t.TempDir()
+	os.MkdirAll(filepath.Join(src, "cloud-init"), 0755)
+	os.MkdirAll(filepath.Join(src, ".git"), 0755)
+	os.WriteFile(filepath.Join(src, "blueprint.yaml"), []byte("nodes: 3\n"), 0644)
+	os.WriteFile(filepath.Join(src, "cloud-init", "node.yaml"), []byte("#cloud-config\n"), 0644)
+	os.WriteFile(filepath.Join(src, ".git", "HEAD"), []byte("ref"), 0644)
+
+	files, err := ReadDir(src, "application/test")
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("ReadDir() returned %d files, want 2 (hidden skipped)", len(files))
+	}
+
+	client := NewClient()
+	client.Username, client.Password = "deploy", "secret"
+	ctx := context.Background()
+
+	digest, err := client.Push(ctx, ref, "application/vnd.test", files)
+	if err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+	if reg.uploads != 3 { // Two files and the empty config
+		t.Errorf("uploads = %d, want 3", reg.uploads)
+	}
+
+	// Pushing again reuses the blobs
+	if _, err := client.Push(ctx, ref, "application/vnd.test", files); err != nil {
+		t.Fatalf("second Push() error = %v", err)
+	}
+	if reg.uploads != 3 {
+		t.Errorf("uploads after re-push = %d, want 3", reg.uploads)
+	}
+
+	byDigest := *ref
+	byDigest.Tag, byDigest.Digest = "", digest
+	for _, r := range []*Reference{ref, &byDigest} {
+		manifest, pulled, err := client.Pull(ctx, r)
+		if err != nil {
+			t.Fatalf("Pull(%s) error = %v", r, err)
+		}
+		if manifest.ArtifactType != "application/vnd.test" || len(pulled) != 2 {
+			t.Fatalf("Pull(%s) = %s with %d files", r, manifest.ArtifactType, len(pulled))
+		}
+	}
+
+	dst := t.TempDir()
+	_, pulled, _ := client.Pull(ctx, ref)
+	if err := WriteFiles(dst, pulled); err != nil {
+		t.Fatalf("WriteFiles() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dst, "cloud-init", "node.yaml"))
+	if err != nil || string(data) != "#cloud-config\n" {
+		t.Errorf("pulled cloud-init/node.yaml = %q, %v", data, err)
+	}
+
+	missing := *ref
+	missing.Tag = "v9"
+	if _, _, err := client.Pull(ctx, &missing); err == nil || !strings.Contains(err.Error(), "MANIFEST_UNKNOWN") {
+		t.Errorf("Pull(missing) error = %v, want MANIFEST_UNKNOWN", err)
+	}
+
+	// Wrong credentials are rejected by the token endpoint
+	bad := NewClient()
+	bad.Username, bad.Password = "deploy", "wrong"
+	if _, _, err := bad.Pull(ctx, ref); err == nil {
+		t.Error("Expected error with wrong credentials")
+	}
+}
+
+func TestWriteFilesRejectsUnsafeNames(t *testing.T) {
+	for _, name := range []string{"../escape", "/etc/passwd", "a/../../escape", `..\escape`} {
+		if err := WriteFiles(t.TempDir(), []File{{Name: name}}); err == nil {
+			t.Errorf("WriteFiles(%q) succeeded, want error", name)
+		}
+	}
+}
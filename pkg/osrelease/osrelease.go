@@ -0,0 +1,159 @@
+// Package osrelease knows the support lifetimes of the operating systems
+// nodes run, to warn about nodes whose image approaches its end of life or
+// has a newer release.
+package osrelease
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EOLWarning is how long before its end of life a release is reported
+const EOLWarning = 180 * 24 * time.Hour
+
+// Release is a release of an operating system
+type Release struct {
+	Distro   string    // e.g. ubuntu
+	Version  string    // e.g. 24.04
+	Released time.Time // Release date
+	EOL      time.Time // End of (standard, or for Debian LTS) security support
+	LTS      bool      // A long-term release; newer images are only suggested among these
+}
+
+// Image returns the image name of the release, e.g. ubuntu-24.04
+func (r Release) Image() string {
+	return r.Distro + "-" + r.Version
+}
+
+// major returns the major version, e.g. 24 of 24.04
+func (r Release) major() string {
+	major, _, _ := strings.Cut(r.Version, ".")
+	return major
+}
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// releases are the releases of the distributions Hetzner offers images of,
+// oldest first for each distribution
+var releases = []Release{
+	{"ubuntu", "20.04", date("2020-04-23"), date("2025-05-31"), true},
+	{"ubuntu", "22.04", date("2022-04-21"), date("2027-06-01"), true},
+	{"ubuntu", "24.04", date("2024-04-25"), date("2029-05-31"), true},
+	{"ubuntu", "24.10", date("2024-10-10"), date("2025-07-10"), false},
+	{"ubuntu", "25.04", date("2025-04-17"), date("2026-01-15"), false},
+	{"ubuntu", "25.10", date("2025-10-09"), date("2026-07-09"), false},
+	{"ubuntu", "26.04", date("2026-04-23"), date("2031-05-31"), true},
+	{"debian", "10", date("2019-07-06"), date("2024-06-30"), true},
+	{"debian", "11", date("2021-08-14"), date("2026-08-31"), true},
+	{"debian", "12", date("2023-06-10"), date("2028-06-30"), true},
+	{"debian", "13", date("2025-08-09"), date("2030-06-30"), true},
+	{"centos-stream", "8", date("2019-09-24"), date("2024-05-31"), true},
+	{"centos-stream", "9", date("2021-12-03"), date("2027-05-31"), true},
+	{"centos-stream", "10", date("2024-12-12"), date("2030-01-01"), true},
+	{"rocky", "8", date("2021-06-21"), date("2029-05-31"), true},
+	{"rocky", "9", date("2022-07-14"), date("2032-05-31"), true},
+	{"rocky", "10", date("2025-06-11"), date("2035-05-31"), true},
+	{"alma", "8", date("2021-03-30"), date("2029-03-01"), true},
+	{"alma", "9", date("2022-05-26"), date("2032-05-31"), true},
+	{"alma", "10", date("2025-05-27"), date("2035-05-31"), true},
+	{"fedora", "41", date("2024-10-29"), date("2025-12-15"), true},
+	{"fedora", "42", date("2025-04-15"), date("2026-05-13"), true},
+	{"fedora", "43", date("2025-10-28"), date("2026-12-09"), true},
+}
+
+// Lookup returns the release an image name such as ubuntu-24.04 or
+// debian-12 is of. Snapshots and unknown images are not found.
+func Lookup(image string) (Release, bool) {
+	image = strings.ToLower(strings.TrimSpace(image))
+	for _, r := range releases {
+		if r.Image() == image {
+			return r, true
+		}
+	}
+	return Release{}, false
+}
+
+// Image freshness statuses
+const (
+	StatusOK      = "ok"
+	StatusEOLSoon = "eol-soon" // Within EOLWarning of its end of life
+	StatusEOL     = "eol"
+	StatusUnknown = "unknown" // Not a known release, e.g. a snapshot
+)
+
+// Advice describes how current an image is
+type Advice struct {
+	Image   string    `json:"image"`
+	Status  string    `json:"status"`
+	EOL     time.Time `json:"eol,omitzero"`
+	Newer   string    `json:"newer,omitempty"` // Newest long-term release, if the image is well behind it
+	Release *Release  `json:"-"`
+}
+
+// Warn reports whether the image deserves a warning: it is (nearly) out of
+// support, or a newer long-term release is available
+func (a Advice) Warn() bool {
+	return a.Status == StatusEOL || a.Status == StatusEOLSoon || a.Newer != ""
+}
+
+// String describes the advice in a sentence, e.g. "ubuntu-20.04 reached
+// end of life on 2025-05-31; ubuntu-24.04 is available"
+func (a Advice) String() string {
+	var msg string
+	switch a.Status {
+	case StatusEOL:
+		msg = fmt.Sprintf("%s reached end of life on %s", a.Image, a.EOL.Format("2006-01-02"))
+	case StatusEOLSoon:
+		msg = fmt.Sprintf("%s reaches end of life on %s", a.Image, a.EOL.Format("2006-01-02"))
+	case StatusUnknown:
+		msg = fmt.Sprintf("%s is not a known release", a.Image)
+	default:
+		msg = fmt.Sprintf("%s is supported until %s", a.Image, a.EOL.Format("2006-01-02"))
+	}
+	if a.Newer != "" {
+		msg += fmt.Sprintf("; %s is available", a.Newer)
+	}
+	return msg
+}
+
+// Check returns how current an image is at a time
+func Check(image string, now time.Time) Advice {
+	a := Advice{Image: image, Status: StatusUnknown}
+	r, ok := Lookup(image)
+	if !ok {
+		return a
+	}
+	a.Release = &r
+	a.EOL = r.EOL
+	switch {
+	case !now.Before(r.EOL):
+		a.Status = StatusEOL
+	case r.EOL.Sub(now) <= EOLWarning:
+		a.Status = StatusEOLSoon
+	default:
+		a.Status = StatusOK
+	}
+
+	// The newest long-term release out by now, if of a newer major version.
+	// It is only suggested for images that are out of support soon, or at
+	// least two long-term releases behind: one behind is normal.
+	var newest *Release
+	behind := 0
+	for i, n := range releases {
+		if n.Distro == r.Distro && n.LTS && !n.Released.After(now) && n.Released.After(r.Released) && n.major() != r.major() {
+			newest = &releases[i]
+			behind++
+		}
+	}
+	if newest != nil && (behind >= 2 || a.Status != StatusOK) {
+		a.Newer = newest.Image()
+	}
+	return a
+}
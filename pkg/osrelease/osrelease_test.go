@@ -0,0 +1,53 @@
+package osrelease
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	now := date("2026-10-16")
+	tests := []struct {
+		image  string
+		status string
+		newer  string
+		warn   bool
+	}{
+		{"ubuntu-20.04", StatusEOL, "ubuntu-26.04", true},
+		{"ubuntu-22.04", StatusOK, "ubuntu-26.04", true},
+		{"ubuntu-26.04", StatusOK, "", false},
+		{"ubuntu-25.10", StatusEOL, "ubuntu-26.04", true},
+		{"ubuntu-24.04", StatusOK, "", false},
+		{"debian-11", StatusEOL, "debian-13", true},
+		{"debian-12", StatusOK, "", false},
+		{"debian-13", StatusOK, "", false},
+		{"fedora-43", StatusEOLSoon, "", true},
+		{"Rocky-8", StatusOK, "rocky-10", true},
+		{"rocky-9", StatusOK, "", false},
+		{"123456789", StatusUnknown, "", false},
+	}
+	for _, tt := range tests {
+		a := Check(tt.image, now)
+		if a.Status != tt.status || a.Newer != tt.newer || a.Warn() != tt.warn {
+			t.Errorf("Check(%s) = %s, newer %q, warn %v; want %s, %q, %v", tt.image, a.Status, a.Newer, a.Warn(), tt.status, tt.newer, tt.warn)
+		}
+	}
+
+	// Releases not out yet are not suggested
+	if a := Check("ubuntu-22.04", date("2026-01-01")); a.Newer != "" {
+		t.Errorf("Check() before 26.04 suggested %s", a.Newer)
+	}
+	if got := Check("ubuntu-20.04", now).String(); got != "ubuntu-20.04 reached end of life on 2025-05-31; ubuntu-26.04 is available" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestReleasesOrdered(t *testing.T) {
+	last := make(map[string]time.Time)
+	for _, r := range releases {
+		if !r.Released.After(last[r.Distro]) || !r.EOL.After(r.Released) {
+			t.Errorf("%s is out of order", r.Image())
+		}
+		last[r.Distro] = r.Released
+	}
+}
@@ -0,0 +1,282 @@
+// Package phonehome lets nodes report that cloud-init has finished, so the
+// provisioner can wait until a node is fully configured rather than just
+// running. Nodes either post to a temporary HTTP listener with cloud-init's
+// phone_home module, or write a marker file to the shared StorageBox.
+package phonehome
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Report is what a node sends when cloud-init has finished
+type Report struct {
+	NodeID   string    `json:"node_id"`
+	Hostname string    `json:"hostname,omitempty"`
+	HostKey  string    `json:"host_key,omitempty"` // The node's ed25519 host public key
+	Received time.Time `json:"-"`
+}
+
+// Waiter waits for a node's report
+type Waiter interface {
+	// Wait blocks until the node has reported or ctx is done
+	Wait(ctx context.Context, nodeID string) (*Report, error)
+}
+
+// Listener is a temporary HTTP server nodes report to. Each node posts to
+// its own URL with a random token, so other hosts cannot mark it ready.
+type Listener struct {
+	publicURL string
+	server    *http.Server
+	ln        net.Listener
+
+	mu      sync.Mutex
+	tokens  map[string]string // Token -> node ID
+	reports map[string]*Report
+	done    map[string]chan struct{}
+}
+
+var _ Waiter = (*Listener)(nil)
+
+// Listen starts a listener on addr. publicURL is the base URL nodes reach
+// it at, e.g. http://[2001:db8::5]:8475; if empty, it is derived from the
+// listen address (only useful for nodes on the same network).
+func Listen(addr, publicURL string) (*Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start phone-home listener: %w", err)
+	}
+	if publicURL == "" {
+		publicURL = "http://" + ln.Addr().String()
+	}
+
+	l := &Listener{
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		ln:        ln,
+		tokens:    map[string]string{},
+		reports:   map[string]*Report{},
+		done:      map[string]chan struct{}{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ready/", l.handleReady)
+	l.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go l.server.Serve(ln)
+	return l, nil
+}
+
+// Addr returns the address the listener accepts connections on
+func (l *Listener) Addr() string {
+	return l.ln.Addr().String()
+}
+
+// Register returns the URL a node posts its report to
+func (l *Listener) Register(nodeID string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate phone-home token: %w", err)
+	}
+	token := hex.EncodeToString(b)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens[token] = nodeID
+	if _, ok := l.done[nodeID]; !ok {
+		l.done[nodeID] = make(chan struct{})
+	}
+	return l.publicURL + "/ready/" + token, nil
+}
+
+// handleReady accepts the form cloud-init's phone_home module posts
+func (l *Listener) handleReady(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	token := strings.TrimPrefix(r.URL.Path, "/ready/")
+	r.Body = http.MaxBytesReader(w, r.Body, 64*1024)
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	nodeID, ok := l.tokens[token]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if _, reported := l.reports[nodeID]; !reported {
+		l.reports[nodeID] = &Report{
+			NodeID:   nodeID,
+			Hostname: r.PostForm.Get("hostname"),
+			HostKey:  strings.TrimSpace(r.PostForm.Get("pub_key_ed25519")),
+			Received: time.Now(),
+		}
+		close(l.done[nodeID])
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Wait blocks until the node has posted its report
+func (l *Listener) Wait(ctx context.Context, nodeID string) (*Report, error) {
+	l.mu.Lock()
+	done, ok := l.done[nodeID]
+	l.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("node %s is not registered for phone-home", nodeID)
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.reports[nodeID], nil
+}
+
+// Close stops the listener
+func (l *Listener) Close() error {
+	return l.server.Close()
+}
+
+// MarkerDir is the StorageBox directory nodes write ready markers to
+const MarkerDir = "morpheus-ready"
+
+// MarkerPath returns the path of a node's ready marker, relative to the
+// StorageBox root
+func MarkerPath(forestID, nodeID string) string {
+	return path.Join(MarkerDir, forestID, nodeID+".json")
+}
+
+// MarkerWatcher waits for the ready markers nodes of a forest write to the
+// shared StorageBox, for when nodes cannot reach the machine running
+// morpheus. Markers are read over WebDAV.
+type MarkerWatcher struct {
+	BaseURL  string // WebDAV URL of the StorageBox root
+	ForestID string
+	Username string
+	Password string
+	Interval time.Duration // How often to poll (default 10s)
+
+	client *http.Client
+}
+
+var _ Waiter = (*MarkerWatcher)(nil)
+
+// NewMarkerWatcher creates a watcher for the markers of forestID
+func NewMarkerWatcher(baseURL, forestID, username, password string) *MarkerWatcher {
+	return &MarkerWatcher{
+		BaseURL:  strings.TrimSuffix(baseURL, "/"),
+		ForestID: forestID,
+		Username: username,
+		Password: password,
+		Interval: 10 * time.Second,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Wait polls until the node's marker exists
+func (w *MarkerWatcher) Wait(ctx context.Context, nodeID string) (*Report, error) {
+	for {
+		report, err := w.read(ctx, nodeID)
+		if err != nil || report != nil {
+			return report, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(w.Interval):
+		}
+	}
+}
+
+// read returns the node's marker, or nil if it has not been written yet
+func (w *MarkerWatcher) read(ctx context.Context, nodeID string) (*Report, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.markerURL(nodeID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(w.Username, w.Password)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, nil // Keep polling through network errors
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, fmt.Errorf("StorageBox rejected the credentials reading %s", MarkerPath(w.ForestID, nodeID))
+	default:
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, nil
+	}
+	var report Report
+	if err := json.Unmarshal(body, &report); err != nil {
+		return nil, nil // Still being written
+	}
+	report.NodeID = nodeID
+	report.Received = time.Now()
+	return &report, nil
+}
+
+// Remove deletes the node's marker; a missing marker is not an error
+func (w *MarkerWatcher) Remove(ctx context.Context, nodeID string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, w.markerURL(nodeID), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(w.Username, w.Password)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to remove ready marker: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to remove ready marker: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (w *MarkerWatcher) markerURL(nodeID string) string {
+	return w.BaseURL + "/" + MarkerPath(w.ForestID, nodeID)
+}
+
+// ErrTimeout is returned by WaitTimeout when a node did not report in time
+var ErrTimeout = errors.New("node did not report ready in time")
+
+// WaitTimeout waits for a node's report for at most timeout
+func WaitTimeout(ctx context.Context, w Waiter, nodeID string, timeout time.Duration) (*Report, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	report, err := w.Wait(ctx, nodeID)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, ErrTimeout
+	}
+	return report, err
+}
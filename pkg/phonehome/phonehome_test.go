@@ -0,0 +1,123 @@
+package phonehome
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestListener(t *testing.T) {
+	l, err := Listen("127.0.0.1:0", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	url1, err := l.Register("node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url2, _ := l.Register("node-2")
+	if url1 == url2 || !strings.HasPrefix(url1, "http://"+l.Addr()+"/ready/") {
+		t.Fatalf("Register() URLs = %s, %s", url1, url2)
+	}
+
+	// Guessed tokens are rejected
+	resp, err := http.PostForm("http://"+l.Addr()+"/ready/guess", url.Values{"hostname": {"x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("POST with unknown token = %d, want 404", resp.StatusCode)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		resp, err := http.PostForm(url1, url.Values{
+			"hostname":        {"node-1"},
+			"pub_key_ed25519": {"ssh-ed25519 AAAAkey root@node-1\n"},
+		})
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	report, err := WaitTimeout(context.Background(), l, "node-1", 5*time.Second)
+	if err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if report.NodeID != "node-1" || report.Hostname != "node-1" || report.HostKey != "ssh-ed25519 AAAAkey root@node-1" {
+		t.Errorf("Wait() = %+v", report)
+	}
+
+	// node-2 never reports
+	if _, err := WaitTimeout(context.Background(), l, "node-2", 50*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Wait(node-2) error = %v, want ErrTimeout", err)
+	}
+	if _, err := l.Wait(context.Background(), "node-3"); err == nil {
+		t.Error("Wait() for an unregistered node succeeded")
+	}
+}
+
+func TestMarkerWatcher(t *testing.T) {
+	var mu sync.Mutex
+	markers := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "u1" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodGet:
+			data, ok := markers[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(data))
+		case http.MethodDelete:
+			delete(markers, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	w := NewMarkerWatcher(server.URL+"/", "f1", "u1", "secret")
+	w.Interval = 10 * time.Millisecond
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		mu.Lock()
+		markers["/"+MarkerPath("f1", "f1-node-1")] = `{"node_id": "f1-node-1", "hostname": "f1-node-1", "host_key": "ssh-ed25519 AAAAkey root@f1-node-1"}`
+		mu.Unlock()
+	}()
+
+	report, err := WaitTimeout(context.Background(), w, "f1-node-1", 5*time.Second)
+	if err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if report.Hostname != "f1-node-1" || report.HostKey != "ssh-ed25519 AAAAkey root@f1-node-1" {
+		t.Errorf("Wait() = %+v", report)
+	}
+
+	if err := w.Remove(context.Background(), "f1-node-1"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if len(markers) != 0 {
+		t.Errorf("markers after Remove() = %v", markers)
+	}
+
+	bad := NewMarkerWatcher(server.URL, "f1", "u1", "wrong")
+	if _, err := WaitTimeout(context.Background(), bad, "f1-node-1", time.Second); err == nil || errors.Is(err, ErrTimeout) {
+		t.Errorf("Wait() with wrong credentials error = %v, want credentials error", err)
+	}
+}
@@ -0,0 +1,137 @@
+// Package provenance produces signed provenance for the infrastructure
+// morpheus provisions: an in-toto statement with a SLSA provenance
+// predicate, describing what a forest was built from (config, templates,
+// images, cloud-init) and which resources were created, signed in a DSSE
+// envelope so it can be audited later.
+package provenance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	// StatementType is the in-toto statement version manifests use
+	StatementType = "https://in-toto.io/Statement/v1"
+	// PredicateType is the SLSA provenance version of the predicate
+	PredicateType = "https://slsa.dev/provenance/v1"
+	// PayloadType is the DSSE payload type of signed statements
+	PayloadType = "application/vnd.in-toto+json"
+)
+
+// Statement is an in-toto statement about the resources in Subject
+type Statement struct {
+	Type          string               `json:"_type"`
+	Subject       []ResourceDescriptor `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     Predicate            `json:"predicate"`
+}
+
+// Predicate is a SLSA provenance predicate: how the subjects were built
+type Predicate struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+// BuildDefinition describes the inputs of a build
+type BuildDefinition struct {
+	BuildType            string               `json:"buildType"`
+	ExternalParameters   map[string]any       `json:"externalParameters"`
+	InternalParameters   map[string]any       `json:"internalParameters,omitempty"`
+	ResolvedDependencies []ResourceDescriptor `json:"resolvedDependencies,omitempty"`
+}
+
+// RunDetails describes who ran a build, when, and what else it produced
+type RunDetails struct {
+	Builder    Builder              `json:"builder"`
+	Metadata   Metadata             `json:"metadata"`
+	Byproducts []ResourceDescriptor `json:"byproducts,omitempty"`
+}
+
+// Builder identifies the tool that performed a build
+type Builder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version,omitempty"`
+}
+
+// Metadata identifies a build and when it ran
+type Metadata struct {
+	InvocationID string    `json:"invocationId,omitempty"`
+	StartedOn    time.Time `json:"startedOn,omitempty"`
+	FinishedOn   time.Time `json:"finishedOn,omitempty"`
+}
+
+// ResourceDescriptor describes an input or output of a build. At least one
+// of URI and Digest is set.
+type ResourceDescriptor struct {
+	Name        string            `json:"name,omitempty"`
+	URI         string            `json:"uri,omitempty"`
+	Digest      map[string]string `json:"digest,omitempty"`
+	Annotations map[string]any    `json:"annotations,omitempty"`
+}
+
+// NewStatement returns a statement with the in-toto and SLSA types set
+func NewStatement() *Statement {
+	return &Statement{
+		Type:          StatementType,
+		PredicateType: PredicateType,
+		Predicate: Predicate{
+			BuildDefinition: BuildDefinition{ExternalParameters: map[string]any{}},
+		},
+	}
+}
+
+// Validate checks that a statement is one morpheus can verify: in-toto v1
+// with SLSA provenance and digests for all subjects
+func (s *Statement) Validate() error {
+	if s.Type != StatementType {
+		return fmt.Errorf("unsupported statement type %q", s.Type)
+	}
+	if s.PredicateType != PredicateType {
+		return fmt.Errorf("unsupported predicate type %q", s.PredicateType)
+	}
+	if len(s.Subject) == 0 {
+		return fmt.Errorf("statement has no subjects")
+	}
+	for _, subject := range s.Subject {
+		if len(subject.Digest) == 0 {
+			return fmt.Errorf("subject %s has no digest", subject.Name)
+		}
+	}
+	return nil
+}
+
+// Digest returns the digest set of data, as used in resource descriptors
+func Digest(data []byte) map[string]string {
+	sum := sha256.Sum256(data)
+	return map[string]string{"sha256": hex.EncodeToString(sum[:])}
+}
+
+// FileDigest returns the digest set of a file's content
+func FileDigest(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return map[string]string{"sha256": hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// JSONDigest returns the digest set of v's JSON encoding. Maps are encoded
+// with sorted keys, so equal values have equal digests.
+func JSONDigest(v any) (map[string]string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return Digest(data), nil
+}
@@ -0,0 +1,116 @@
+package provenance
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testStatement() *Statement {
+	s := NewStatement()
+	s.Subject = []ResourceDescriptor{{Name: "forest-1-node-1", URI: "hetzner://server/1", Digest: Digest([]byte("server 1"))}}
+	s.Predicate.BuildDefinition.BuildType = "https://example.com/plant/v1"
+	s.Predicate.BuildDefinition.ExternalParameters["forestId"] = "forest-1"
+	return s
+}
+
+func TestSignVerify(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "provenance.key")
+	key, created, err := LoadOrCreateKey(keyPath)
+	if err != nil || !created {
+		t.Fatalf("LoadOrCreateKey() = %v, %v; want a new key", created, err)
+	}
+	// The key is reused, and its public key written next to it
+	again, created, err := LoadOrCreateKey(keyPath)
+	if err != nil || created || !again.Equal(key) {
+		t.Fatalf("LoadOrCreateKey() again = %v, %v; want the same key", created, err)
+	}
+	if info, err := os.Stat(keyPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("key file mode = %v, %v; want 0600", info, err)
+	}
+	pub, err := LoadPublicKey(keyPath + ".pub")
+	if err != nil {
+		t.Fatalf("LoadPublicKey() error = %v", err)
+	}
+
+	e, err := Sign(testStatement(), key)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if e.PayloadType != PayloadType || len(e.Signatures) != 1 || e.Signatures[0].KeyID != KeyID(pub) {
+		t.Errorf("envelope = %+v", e)
+	}
+	s, err := Verify(e, pub)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if s.Subject[0].URI != "hetzner://server/1" || s.Predicate.BuildDefinition.ExternalParameters["forestId"] != "forest-1" {
+		t.Errorf("verified statement = %+v", s)
+	}
+
+	// Another key does not verify
+	otherPath := filepath.Join(dir, "other.key")
+	if _, _, err := LoadOrCreateKey(otherPath); err != nil {
+		t.Fatal(err)
+	}
+	other, _ := LoadPublicKey(otherPath)
+	if _, err := Verify(e, other); err == nil {
+		t.Error("Verify() with another key succeeded")
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	key, _, err := LoadOrCreateKey(filepath.Join(t.TempDir(), "provenance.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := Sign(testStatement(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, _ := base64.StdEncoding.DecodeString(e.Payload)
+	tampered := strings.Replace(string(payload), "server/1", "server/2", 1)
+	e.Payload = base64.StdEncoding.EncodeToString([]byte(tampered))
+	if _, err := Verify(e, key.Public().(ed25519.PublicKey)); err == nil {
+		t.Error("Verify() of a tampered statement succeeded")
+	}
+}
+
+func TestStore(t *testing.T) {
+	key, _, err := LoadOrCreateKey(filepath.Join(t.TempDir(), "provenance.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := Sign(testStatement(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewStore(t.TempDir())
+	if _, _, err := store.Latest("forest-1"); err == nil {
+		t.Error("Latest() without manifests succeeded")
+	}
+	first := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if _, err := store.Save("forest-1", e, first); err != nil {
+		t.Fatal(err)
+	}
+	path, err := store.Save("forest-1", e, first.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(path) != "20260102T040405Z.intoto.json" {
+		t.Errorf("Save() path = %s", path)
+	}
+	paths, _ := store.List("forest-1")
+	loaded, latest, err := store.Latest("forest-1")
+	if err != nil || len(paths) != 2 || latest != path {
+		t.Fatalf("Latest() = %s, %v; List() = %v", latest, err, paths)
+	}
+	if _, err := Verify(loaded, key.Public().(ed25519.PublicKey)); err != nil {
+		t.Errorf("Verify() of stored manifest error = %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package provenance
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// manifestSuffix is the file extension of stored manifests
+const manifestSuffix = ".intoto.json"
+
+// Store keeps signed manifests as one file per plant, in a directory per
+// forest: <dir>/<forest-id>/<timestamp>.intoto.json
+type Store struct {
+	dir string
+}
+
+// NewStore returns a store in dir
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Save writes a forest's manifest and returns its path
+func (s *Store) Save(forestID string, e *Envelope, at time.Time) (string, error) {
+	dir := filepath.Join(s.dir, forestID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, at.UTC().Format("20060102T150405Z")+manifestSuffix)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// List returns the paths of a forest's manifests, oldest first
+func (s *Store) List(forestID string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, forestID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), manifestSuffix) {
+			paths = append(paths, filepath.Join(s.dir, forestID, entry.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Latest returns a forest's newest manifest and its path
+func (s *Store) Latest(forestID string) (*Envelope, string, error) {
+	paths, err := s.List(forestID)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(paths) == 0 {
+		return nil, "", fmt.Errorf("no provenance recorded for forest %s", forestID)
+	}
+	path := paths[len(paths)-1]
+	e, err := Load(path)
+	return e, path, err
+}
+
+// Load reads a manifest file
+func Load(path string) (*Envelope, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var e Envelope
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &e, nil
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 	"github.com/nimsforest/morpheus/pkg/httputil"
+	"github.com/nimsforest/morpheus/pkg/machine"
 	"github.com/nimsforest/morpheus/pkg/provider"
 )
 
@@ -625,13 +626,7 @@ func convertServer(server *hcloud.Server) *provider.Server {
 	}
 	if server.PublicNet.IPv6.IP != nil {
 		// Hetzner returns the /64 network address (e.g., 2a01:4f8:c17:1234::)
-		// The server's actual address is ::1 within that network
-		ipv6Base := server.PublicNet.IPv6.IP.String()
-		if strings.HasSuffix(ipv6Base, "::") {
-			publicIPv6 = ipv6Base + "1"
-		} else {
-			publicIPv6 = ipv6Base
-		}
+		publicIPv6 = machine.HostIPv6(server.PublicNet.IPv6.IP.String())
 	}
 
 	return &provider.Server{
@@ -347,6 +347,12 @@ func TestConvertServer(t *testing.T) {
 	if server.CreatedAt != expectedTime {
 		t.Errorf("Expected CreatedAt '%s', got '%s'", expectedTime, server.CreatedAt)
 	}
+
+	// Hetzner reports the server's /64; the server uses ::1 within it
+	hcloudServer.PublicNet.IPv6.IP = net.ParseIP("2a01:4f8:c17:1234::")
+	if server := convertServer(hcloudServer); server.PublicIPv6 != "2a01:4f8:c17:1234::1" {
+		t.Errorf("Expected IPv6 '2a01:4f8:c17:1234::1', got '%s'", server.PublicIPv6)
+	}
 }
 
 func TestIsValidSSHPublicKey(t *testing.T) {
@@ -0,0 +1,153 @@
+package secretstore
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Access is one entry of the access log
+type Access struct {
+	Time    time.Time `json:"time"`
+	Op      string    `json:"op"` // get, put, delete, list
+	Key     string    `json:"key"`
+	User    string    `json:"user,omitempty"`
+	Host    string    `json:"host,omitempty"`
+	Command string    `json:"command,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+func (a Access) String() string {
+	key := a.Key
+	if key == "" {
+		key = "(all)"
+	}
+	s := fmt.Sprintf("%s  %-6s %s", a.Time.Format("2006-01-02 15:04:05"), a.Op, key)
+	if a.User != "" {
+		s += fmt.Sprintf("  by %s@%s", a.User, a.Host)
+	}
+	if a.Error != "" {
+		s += "  (failed: " + a.Error + ")"
+	}
+	return s
+}
+
+// LoggedStore records every access to a Store in an append-only log file
+// of JSON lines
+type LoggedStore struct {
+	Store   Store
+	LogFile string
+	Command string // Recorded with each access, e.g. the command line
+
+	mu   sync.Mutex
+	user string
+	host string
+}
+
+// NewLoggedStore returns a store logging the accesses to s in logFile
+func NewLoggedStore(s Store, logFile, command string) *LoggedStore {
+	l := &LoggedStore{Store: s, LogFile: logFile, Command: command}
+	if u, err := user.Current(); err == nil {
+		l.user = u.Username
+	}
+	l.host, _ = os.Hostname()
+	return l
+}
+
+// log appends an entry; failing to write the log fails the access, so no
+// access goes unrecorded
+func (l *LoggedStore) log(op, key string, opErr error) error {
+	entry := Access{Time: time.Now().UTC(), Op: op, Key: key, User: l.user, Host: l.host, Command: l.Command}
+	if opErr != nil && !errors.Is(opErr, ErrNotFound) {
+		entry.Error = opErr.Error()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.LogFile), 0700); err != nil {
+		return fmt.Errorf("failed to write secret access log: %w", err)
+	}
+	f, err := os.OpenFile(l.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write secret access log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write secret access log: %w", err)
+	}
+	return nil
+}
+
+// Get logs and reads key
+func (l *LoggedStore) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := l.Store.Get(ctx, key)
+	if logErr := l.log("get", key, err); logErr != nil {
+		return nil, logErr
+	}
+	return data, err
+}
+
+// Put logs and stores key
+func (l *LoggedStore) Put(ctx context.Context, key string, data []byte) error {
+	err := l.Store.Put(ctx, key, data)
+	if logErr := l.log("put", key, err); logErr != nil {
+		return errors.Join(err, logErr)
+	}
+	return err
+}
+
+// Delete logs and removes key
+func (l *LoggedStore) Delete(ctx context.Context, key string) error {
+	err := l.Store.Delete(ctx, key)
+	if logErr := l.log("delete", key, err); logErr != nil {
+		return errors.Join(err, logErr)
+	}
+	return err
+}
+
+// List logs and lists the keys starting with prefix
+func (l *LoggedStore) List(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := l.Store.List(ctx, prefix)
+	if logErr := l.log("list", prefix, err); logErr != nil {
+		return nil, logErr
+	}
+	return keys, err
+}
+
+// ReadAccessLog returns the entries of an access log, oldest first. A
+// missing log has no entries.
+func ReadAccessLog(logFile string) ([]Access, error) {
+	f, err := os.Open(logFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret access log: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Access
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var a Access
+		if err := json.Unmarshal(scanner.Bytes(), &a); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", logFile, n, err)
+		}
+		entries = append(entries, a)
+	}
+	return entries, scanner.Err()
+}
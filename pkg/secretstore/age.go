@@ -0,0 +1,223 @@
+package secretstore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// Blobs are encrypted in the age v1 format (https://age-encryption.org/v1)
+// with X25519 recipients, using the reference implementation, so they can
+// also be read with the age tool:
+//
+//	age -d -i key.txt nats/forest-1/ca-key.pem.age
+
+// Recipient is an age X25519 public key ("age1...")
+type Recipient struct {
+	r *age.X25519Recipient
+}
+
+// ParseRecipient parses an "age1..." recipient
+func ParseRecipient(s string) (*Recipient, error) {
+	r, err := age.ParseX25519Recipient(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid age recipient %q: %w", s, err)
+	}
+	return &Recipient{r: r}, nil
+}
+
+// String returns the recipient as "age1..."
+func (r *Recipient) String() string {
+	return r.r.String()
+}
+
+// Identity is an age X25519 private key ("AGE-SECRET-KEY-1...")
+type Identity struct {
+	id *age.X25519Identity
+}
+
+// GenerateIdentity returns a new random identity
+func GenerateIdentity() (*Identity, error) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate age identity: %w", err)
+	}
+	return &Identity{id: id}, nil
+}
+
+// ParseIdentity parses an "AGE-SECRET-KEY-1..." identity
+func ParseIdentity(s string) (*Identity, error) {
+	id, err := age.ParseX25519Identity(strings.TrimSpace(s))
+	if err != nil {
+		// The error would quote the key
+		return nil, fmt.Errorf("invalid age identity")
+	}
+	return &Identity{id: id}, nil
+}
+
+// ParseIdentities parses the identities of an age key file, one per line,
+// skipping empty lines and "#" comments
+func ParseIdentities(r io.Reader) ([]*Identity, error) {
+	var ids []*Identity
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, err := ParseIdentity(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no age identities found")
+	}
+	return ids, nil
+}
+
+// LoadIdentities reads an age key file
+func LoadIdentities(file string) ([]*Identity, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read age identity file: %w", err)
+	}
+	defer f.Close()
+	ids, err := ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return ids, nil
+}
+
+// String returns the identity as "AGE-SECRET-KEY-1..."
+func (i *Identity) String() string {
+	return i.id.String()
+}
+
+// Recipient returns the public key of the identity
+func (i *Identity) Recipient() *Recipient {
+	return &Recipient{r: i.id.Recipient()}
+}
+
+// Encrypt encrypts plaintext for recipients in the age format
+func Encrypt(plaintext []byte, recipients ...*Recipient) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no age recipients")
+	}
+	rs := make([]age.Recipient, len(recipients))
+	for i, r := range recipients {
+		rs[i] = r.r
+	}
+	var out bytes.Buffer
+	w, err := age.Encrypt(&out, rs...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Decrypt decrypts an age file with the first identity it was encrypted for
+func Decrypt(ciphertext []byte, identities ...*Identity) ([]byte, error) {
+	ids := make([]age.Identity, len(identities))
+	for i, id := range identities {
+		ids[i] = id.id
+	}
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), ids...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// AgeStore encrypts the blobs of a Store for age recipients. Blobs are
+// stored under their key with ".age" appended.
+type AgeStore struct {
+	Store      Store
+	Recipients []*Recipient
+	Identities []*Identity // Needed to read blobs back
+}
+
+// NewAgeStore returns a store encrypting the blobs of s. Blobs are
+// encrypted for recipients and the recipients of identities.
+func NewAgeStore(s Store, recipients []*Recipient, identities []*Identity) (*AgeStore, error) {
+	all := append([]*Recipient{}, recipients...)
+	for _, id := range identities {
+		r := id.Recipient()
+		known := false
+		for _, other := range all {
+			known = known || other.String() == r.String()
+		}
+		if !known {
+			all = append(all, r)
+		}
+	}
+	if len(all) == 0 {
+		return nil, fmt.Errorf("age encryption needs a recipient or an identity")
+	}
+	return &AgeStore{Store: s, Recipients: all, Identities: identities}, nil
+}
+
+// Get decrypts the blob of key
+func (s *AgeStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if len(s.Identities) == 0 {
+		return nil, fmt.Errorf("no age identity to decrypt %s", key)
+	}
+	data, err := s.Store.Get(ctx, key+".age")
+	if err != nil {
+		return nil, err
+	}
+	plain, err := Decrypt(data, s.Identities...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return plain, nil
+}
+
+// Put encrypts data and stores it
+func (s *AgeStore) Put(ctx context.Context, key string, data []byte) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	sealed, err := Encrypt(data, s.Recipients...)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %s: %w", key, err)
+	}
+	return s.Store.Put(ctx, key+".age", sealed)
+}
+
+// Delete removes the blob of key
+func (s *AgeStore) Delete(ctx context.Context, key string) error {
+	return s.Store.Delete(ctx, key+".age")
+}
+
+// List returns the keys of the encrypted blobs
+func (s *AgeStore) List(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := s.Store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, key := range keys {
+		if strings.HasSuffix(key, ".age") {
+			out = append(out, strings.TrimSuffix(key, ".age"))
+		}
+	}
+	return out, nil
+}
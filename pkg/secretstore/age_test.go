@@ -0,0 +1,149 @@
+package secretstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestAgeKeys(t *testing.T) {
+	// The recipient from the age README
+	const recipient = "age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p"
+	r, err := ParseRecipient(recipient)
+	if err != nil {
+		t.Fatalf("ParseRecipient() error = %v", err)
+	}
+	if r.String() != recipient {
+		t.Errorf("String() = %s, want %s", r, recipient)
+	}
+	if _, err := ParseRecipient("age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8q"); err == nil {
+		t.Error("ParseRecipient() with bad checksum: expected error")
+	}
+
+	id, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(id.String(), "AGE-SECRET-KEY-1") {
+		t.Errorf("identity = %s", id)
+	}
+	ids, err := ParseIdentities(strings.NewReader("# created: today\n# public key: " + id.Recipient().String() + "\n" + id.String() + "\n"))
+	if err != nil || len(ids) != 1 || ids[0].Recipient().String() != id.Recipient().String() {
+		t.Errorf("ParseIdentities() = %v, %v", ids, err)
+	}
+	if _, err := ParseIdentity(recipient); err == nil {
+		t.Error("ParseIdentity() of a recipient: expected error")
+	}
+}
+
+func TestAgeEncrypt(t *testing.T) {
+	const chunkSize = 64 << 10 // age STREAM chunks
+
+	alice, _ := GenerateIdentity()
+	bob, _ := GenerateIdentity()
+	eve, _ := GenerateIdentity()
+
+	for _, size := range []int{0, 1, chunkSize, chunkSize + 1, 3*chunkSize - 7} {
+		plaintext := bytes.Repeat([]byte{'x'}, size)
+		sealed, err := Encrypt(plaintext, alice.Recipient(), bob.Recipient())
+		if err != nil {
+			t.Fatalf("Encrypt(%d bytes) error = %v", size, err)
+		}
+		if !bytes.HasPrefix(sealed, []byte("age-encryption.org/v1\n-> X25519 ")) {
+			t.Fatalf("not an age file: %q", sealed[:40])
+		}
+		for _, id := range []*Identity{alice, bob} {
+			got, err := Decrypt(sealed, eve, id)
+			if err != nil {
+				t.Fatalf("Decrypt(%d bytes) error = %v", size, err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Errorf("Decrypt(%d bytes) returned %d bytes", size, len(got))
+			}
+		}
+		if _, err := Decrypt(sealed, eve); err == nil {
+			t.Error("Decrypt() without a matching identity: expected error")
+		}
+	}
+
+	sealed, _ := Encrypt([]byte("secret"), alice.Recipient())
+	for name, tamper := range map[string]func([]byte){
+		"payload": func(b []byte) { b[len(b)-1] ^= 1 },
+		"header":  func(b []byte) { b[bytes.Index(b, []byte("---"))-2] ^= 1 },
+	} {
+		b := append([]byte{}, sealed...)
+		tamper(b)
+		if _, err := Decrypt(b, alice); err == nil {
+			t.Errorf("Decrypt() with tampered %s: expected error", name)
+		}
+	}
+}
+
+func TestAgeInterop(t *testing.T) {
+	// A file encrypted with age v1.2.1 for this identity
+	const (
+		identity = "AGE-SECRET-KEY-1407TAWPHDU2M6NFKMTE9YV33NGA229PF9FX3CGSG8DWJTE3P9FRQKAU65F"
+		file     = "YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSB5MU1vbTd0K2llL0dCbjMxT1l2ekF6SXRuMUZLbjFBdFA4eFFWTWJKRVVzCnVkdHFqbTVOcEZhQ2F3MmtmQm9BV2puWFdCL05FSlRaZEpiMjRQQUttT3MKLS0tIDBzeWM2ZjFEeDI2NHFHUjVYblB5ZUdLSHVSYXVXZUN0VHRSM1o4a2FKQnMKrQApHwjXlFsihEj5iLrmhmbhLQR0C6inZoN+AT4hfC1LBWOC4f6tp9joUqimNlW8oHu7x6LK"
+	)
+	id, err := ParseIdentity(identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := id.Recipient().String(); got != "age1uvcswudt6qwvzsl90chtx4nn8upz44usgctz7883kzdj9xd5kswqvjpkkw" {
+		t.Errorf("Recipient() = %s", got)
+	}
+	sealed, _ := base64.StdEncoding.DecodeString(file)
+	if got, err := Decrypt(sealed, id); err != nil || string(got) != "morpheus known answer\n" {
+		t.Errorf("Decrypt() of an age file = %q, %v", got, err)
+	}
+
+	// And age reads what Encrypt writes
+	ours, err := Encrypt([]byte("secret"), id.Recipient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ageID, _ := age.ParseX25519Identity(identity)
+	r, err := age.Decrypt(bytes.NewReader(ours), ageID)
+	if err != nil {
+		t.Fatalf("age.Decrypt() error = %v", err)
+	}
+	if got, _ := io.ReadAll(r); string(got) != "secret" {
+		t.Errorf("age.Decrypt() = %q", got)
+	}
+}
+
+func TestAgeStore(t *testing.T) {
+	ctx := context.Background()
+	id, _ := GenerateIdentity()
+	other, _ := GenerateIdentity()
+	dir := NewDirStore(t.TempDir())
+	store, err := NewAgeStore(dir, []*Recipient{other.Recipient()}, []*Identity{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Put(ctx, "nats/forest-1/ca-key.pem", []byte("private")); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	raw, err := dir.Get(ctx, "nats/forest-1/ca-key.pem.age")
+	if err != nil || bytes.Contains(raw, []byte("private")) {
+		t.Fatalf("stored blob = %q, %v; want it encrypted", raw, err)
+	}
+	if got, err := store.Get(ctx, "nats/forest-1/ca-key.pem"); err != nil || string(got) != "private" {
+		t.Errorf("Get() = %q, %v", got, err)
+	}
+	if keys, _ := store.List(ctx, "nats/"); len(keys) != 1 || keys[0] != "nats/forest-1/ca-key.pem" {
+		t.Errorf("List() = %v", keys)
+	}
+
+	// Other recipients can read it with their own key
+	theirs, _ := NewAgeStore(dir, nil, []*Identity{other})
+	if got, err := theirs.Get(ctx, "nats/forest-1/ca-key.pem"); err != nil || string(got) != "private" {
+		t.Errorf("Get() with the other recipient's key = %q, %v", got, err)
+	}
+}
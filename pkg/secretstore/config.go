@@ -0,0 +1,76 @@
+package secretstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nimsforest/morpheus/pkg/config"
+)
+
+// FromConfig opens the secret store of cfg: its backend, encrypted with age
+// and logging every access. It returns nil without a configured store.
+// command is recorded in the access log.
+func FromConfig(cfg *config.Config, command string) (Store, error) {
+	sc := cfg.Secrets.Store
+	var backend Store
+	switch sc.Backend {
+	case "":
+		return nil, nil
+	case "dir":
+		backend = NewDirStore(sc.GetPath())
+	case "storagebox":
+		sb := cfg.Storage.StorageBox
+		if sb.Host == "" {
+			return nil, fmt.Errorf("storage.storagebox is required for secrets.store.backend: storagebox")
+		}
+		backend = NewWebDAVStore("https://"+sb.Host+"/"+strings.Trim(sc.GetPath(), "/")+"/", sb.Username, sb.Password)
+	case "vault":
+		if sc.Vault.Address == "" || sc.Vault.Token == "" {
+			return nil, fmt.Errorf("secrets.store.vault needs an address and a token (or set VAULT_ADDR and VAULT_TOKEN)")
+		}
+		backend = NewVaultStore(sc.Vault.Address, sc.Vault.Token, sc.Vault.Mount, sc.GetPath())
+	default:
+		return nil, fmt.Errorf("unsupported secrets.store.backend: %s (supported: dir, storagebox, vault)", sc.Backend)
+	}
+
+	var recipients []*Recipient
+	for _, s := range sc.Recipients {
+		r, err := ParseRecipient(s)
+		if err != nil {
+			return nil, fmt.Errorf("secrets.store.recipients: %w", err)
+		}
+		recipients = append(recipients, r)
+	}
+	identities, err := LoadIdentities(sc.GetIdentity())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(identities) == 0 && len(recipients) == 0 {
+		return nil, fmt.Errorf("no age key for the secret store: create %s with 'morpheus secrets keygen'", sc.GetIdentity())
+	}
+	encrypted, err := NewAgeStore(backend, recipients, identities)
+	if err != nil {
+		return nil, err
+	}
+	return NewLoggedStore(encrypted, sc.GetAccessLog(), command), nil
+}
+
+// WriteIdentityFile saves a new identity in file, which must not exist, in
+// the format of age-keygen
+func WriteIdentityFile(file string, id *Identity) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "# public key: %s\n%s\n", id.Recipient(), id)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
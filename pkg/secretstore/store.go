@@ -0,0 +1,210 @@
+// Package secretstore keeps sensitive material (private keys, certificates,
+// credentials) in one place. Backends store blobs under slash-separated
+// keys such as "nats/forest-1/ca-key.pem"; AgeStore encrypts them for age
+// recipients and LoggedStore records who accessed what.
+package secretstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrNotFound is returned by Get for keys that are not stored
+var ErrNotFound = errors.New("secret not found")
+
+// Store stores blobs under slash-separated keys
+type Store interface {
+	// Get returns the blob stored under key, or ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Put stores data under key, replacing an existing blob
+	Put(ctx context.Context, key string, data []byte) error
+
+	// Delete removes key; deleting a key that is not stored is not an error
+	Delete(ctx context.Context, key string) error
+
+	// List returns the stored keys starting with prefix, sorted
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// ValidateKey checks that key is a relative slash-separated path without
+// empty, "." or ".." elements
+func ValidateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") {
+		return fmt.Errorf("invalid secret key %q", key)
+	}
+	for _, elem := range strings.Split(key, "/") {
+		if elem == "" || elem == "." || elem == ".." || strings.ContainsAny(elem, "\\\x00") {
+			return fmt.Errorf("invalid secret key %q", key)
+		}
+	}
+	return nil
+}
+
+// DeletePrefix deletes every key starting with prefix
+func DeletePrefix(ctx context.Context, s Store, prefix string) error {
+	keys, err := s.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, key := range keys {
+		if err := s.Delete(ctx, key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DirStore stores blobs as files below a directory, readable only by the
+// owner
+type DirStore struct {
+	Dir string
+}
+
+// NewDirStore returns a store keeping its blobs below dir
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{Dir: dir}
+}
+
+func (s *DirStore) file(key string) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(key)), nil
+}
+
+// Get reads the file of key
+func (s *DirStore) Get(ctx context.Context, key string) ([]byte, error) {
+	file, err := s.file(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	return data, err
+}
+
+// Put writes the file of key through a temporary file, so readers never
+// see a partial blob
+func (s *DirStore) Put(ctx context.Context, key string, data []byte) error {
+	file, err := s.file(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return fmt.Errorf("failed to create secret directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save secret %s: %w", key, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save secret %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save secret %s: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return fmt.Errorf("failed to save secret %s: %w", key, err)
+	}
+	return nil
+}
+
+// Delete removes the file of key and the directories left empty
+func (s *DirStore) Delete(ctx context.Context, key string) error {
+	file, err := s.file(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete secret %s: %w", key, err)
+	}
+	for dir := filepath.Dir(file); dir != filepath.Clean(s.Dir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// List walks the directory for files whose key starts with prefix
+func (s *DirStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(s.Dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.Dir, file)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// PrefixStore stores the keys of a Store below a prefix, so several users
+// can share one store
+type PrefixStore struct {
+	Store  Store
+	Prefix string // e.g. "nats/"
+}
+
+// NewPrefixStore returns a view of s with keys below prefix
+func NewPrefixStore(s Store, prefix string) *PrefixStore {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &PrefixStore{Store: s, Prefix: prefix}
+}
+
+// Get returns the blob of prefix+key
+func (s *PrefixStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return s.Store.Get(ctx, s.Prefix+key)
+}
+
+// Put stores data under prefix+key
+func (s *PrefixStore) Put(ctx context.Context, key string, data []byte) error {
+	return s.Store.Put(ctx, s.Prefix+key, data)
+}
+
+// Delete removes prefix+key
+func (s *PrefixStore) Delete(ctx context.Context, key string) error {
+	return s.Store.Delete(ctx, s.Prefix+key)
+}
+
+// List returns the keys below the prefix, without it
+func (s *PrefixStore) List(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := s.Store.List(ctx, s.Prefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, s.Prefix)
+	}
+	return keys, nil
+}
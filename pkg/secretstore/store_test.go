@@ -0,0 +1,275 @@
+package secretstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testStore runs the operations every Store supports against s
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	if _, err := s.Get(ctx, "nats/forest-1/route-password"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() of a missing key error = %v, want ErrNotFound", err)
+	}
+	for key, value := range map[string]string{
+		"nats/forest-1/route-password": "pass",
+		"nats/forest-1/ca-key.pem":     "key",
+		"nats/forest-2/route-password": "pass2",
+		"guard/guard-1/wg-private-key": "wg",
+	} {
+		if err := s.Put(ctx, key, []byte(value)); err != nil {
+			t.Fatalf("Put(%s) error = %v", key, err)
+		}
+	}
+	if err := s.Put(ctx, "nats/forest-1/route-password", []byte("new")); err != nil {
+		t.Fatalf("Put() replacing a key error = %v", err)
+	}
+	if got, err := s.Get(ctx, "nats/forest-1/route-password"); err != nil || string(got) != "new" {
+		t.Errorf("Get() = %q, %v; want new", got, err)
+	}
+
+	keys, err := s.List(ctx, "nats/forest-1/")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if strings.Join(keys, " ") != "nats/forest-1/ca-key.pem nats/forest-1/route-password" {
+		t.Errorf("List(nats/forest-1/) = %v", keys)
+	}
+	if keys, _ := s.List(ctx, ""); len(keys) != 4 {
+		t.Errorf("List() = %v, want 4 keys", keys)
+	}
+
+	if err := DeletePrefix(ctx, s, "nats/forest-1/"); err != nil {
+		t.Fatalf("DeletePrefix() error = %v", err)
+	}
+	if err := s.Delete(ctx, "nats/forest-1/route-password"); err != nil {
+		t.Errorf("Delete() of a missing key error = %v", err)
+	}
+	if keys, _ := s.List(ctx, "nats/"); len(keys) != 1 || keys[0] != "nats/forest-2/route-password" {
+		t.Errorf("List() after delete = %v", keys)
+	}
+
+	if err := s.Put(ctx, "../escape", []byte("x")); err == nil {
+		t.Error("Put() with .. in the key: expected error")
+	}
+}
+
+func TestDirStore(t *testing.T) {
+	dir := t.TempDir()
+	testStore(t, NewDirStore(dir))
+
+	info, err := os.Stat(filepath.Join(dir, "guard", "guard-1", "wg-private-key"))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("secret file mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "nats", "forest-1")); !os.IsNotExist(err) {
+		t.Error("empty directory left after deleting its secrets")
+	}
+}
+
+func TestPrefixStore(t *testing.T) {
+	inner := NewDirStore(t.TempDir())
+	testStore(t, NewPrefixStore(inner, "customer-1"))
+	if keys, _ := inner.List(context.Background(), ""); len(keys) != 2 || !strings.HasPrefix(keys[0], "customer-1/") {
+		t.Errorf("keys of the inner store = %v", keys)
+	}
+}
+
+func TestLoggedStore(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "access.log")
+	s := NewLoggedStore(NewDirStore(t.TempDir()), logFile, "morpheus nats bootstrap forest-1")
+	testStore(t, s)
+
+	entries, err := ReadAccessLog(logFile)
+	if err != nil {
+		t.Fatalf("ReadAccessLog() error = %v", err)
+	}
+	if len(entries) < 10 {
+		t.Fatalf("logged %d accesses", len(entries))
+	}
+	first := entries[0]
+	if first.Op != "get" || first.Key != "nats/forest-1/route-password" || first.Command != "morpheus nats bootstrap forest-1" || first.Error != "" {
+		t.Errorf("first entry = %+v", first)
+	}
+	last := entries[len(entries)-1]
+	if last.Op != "put" || last.Key != "../escape" || last.Error == "" {
+		t.Errorf("last entry = %+v, want the failed put", last)
+	}
+	if entries, err := ReadAccessLog(filepath.Join(t.TempDir(), "missing.log")); err != nil || entries != nil {
+		t.Errorf("ReadAccessLog() of a missing log = %v, %v", entries, err)
+	}
+}
+
+// fakeWebDAV serves files from memory with the WebDAV methods the
+// StorageBox supports
+type fakeWebDAV struct {
+	mu    sync.Mutex
+	files map[string][]byte // path -> content
+	dirs  map[string]bool
+}
+
+func (f *fakeWebDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if user, pass, ok := r.BasicAuth(); !ok || user != "u1" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	p := r.URL.Path
+	switch r.Method {
+	case http.MethodGet:
+		data, ok := f.files[p]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodPut:
+		if !f.dirs[p[:strings.LastIndex(p, "/")+1]] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.files[p], _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if _, ok := f.files[p]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.files, p)
+		w.WriteHeader(http.StatusNoContent)
+	case "MKCOL":
+		if f.dirs[p] {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		f.dirs[p] = true
+		w.WriteHeader(http.StatusCreated)
+	case "PROPFIND":
+		if !f.dirs[p] || r.Header.Get("Depth") != "1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var children []string
+		for name := range f.dirs {
+			if name != p && strings.HasPrefix(name, p) && !strings.Contains(strings.TrimSuffix(name[len(p):], "/"), "/") {
+				children = append(children, fmt.Sprintf(`<d:response><d:href>%s</d:href><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype></d:prop></d:propstat></d:response>`, name))
+			}
+		}
+		for name := range f.files {
+			if strings.HasPrefix(name, p) && !strings.Contains(name[len(p):], "/") {
+				children = append(children, fmt.Sprintf(`<d:response><d:href>%s</d:href><d:propstat><d:prop><d:resourcetype/></d:prop></d:propstat></d:response>`, name))
+			}
+		}
+		sort.Strings(children)
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:"><d:response><d:href>%s</d:href><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype></d:prop></d:propstat></d:response>%s</d:multistatus>`, p, strings.Join(children, ""))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestWebDAVStore(t *testing.T) {
+	fake := &fakeWebDAV{files: map[string][]byte{}, dirs: map[string]bool{"/": true, "/morpheus/": true, "/morpheus/secrets/": true}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	testStore(t, NewWebDAVStore(server.URL+"/morpheus/secrets", "u1", "secret"))
+	if _, ok := fake.files["/morpheus/secrets/guard/guard-1/wg-private-key"]; !ok {
+		t.Errorf("files on the server = %v", fake.files)
+	}
+
+	s := NewWebDAVStore(server.URL+"/morpheus/secrets/", "u1", "wrong")
+	if _, err := s.Get(context.Background(), "guard/guard-1/wg-private-key"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() with a wrong password error = %v", err)
+	}
+}
+
+// fakeVault serves the KV version 2 API from memory
+type fakeVault struct {
+	mu      sync.Mutex
+	secrets map[string]map[string]string // path below the mount -> data
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("X-Vault-Token") != "s.token" {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors":["permission denied"]}`))
+		return
+	}
+	switch p := r.URL.Path; {
+	case r.Method == http.MethodGet && strings.HasPrefix(p, "/v1/kv/data/"):
+		data, ok := f.secrets[strings.TrimPrefix(p, "/v1/kv/data/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"data": data}})
+	case r.Method == http.MethodPost && strings.HasPrefix(p, "/v1/kv/data/"):
+		var body struct {
+			Data map[string]string `json:"data"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.secrets[strings.TrimPrefix(p, "/v1/kv/data/")] = body.Data
+		w.Write([]byte(`{"data":{"version":1}}`))
+	case r.Method == http.MethodDelete && strings.HasPrefix(p, "/v1/kv/metadata/"):
+		delete(f.secrets, strings.TrimPrefix(p, "/v1/kv/metadata/"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "LIST" && strings.HasPrefix(p, "/v1/kv/metadata/"):
+		dir := strings.TrimPrefix(p, "/v1/kv/metadata/")
+		seen := map[string]bool{}
+		var keys []string
+		for name := range f.secrets {
+			if !strings.HasPrefix(name, dir) {
+				continue
+			}
+			key := name[len(dir):]
+			if i := strings.Index(key, "/"); i >= 0 {
+				key = key[:i+1]
+			}
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"keys": keys}})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestVaultStore(t *testing.T) {
+	fake := &fakeVault{secrets: map[string]map[string]string{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	testStore(t, NewVaultStore(server.URL, "s.token", "kv", "morpheus"))
+	if data := fake.secrets["morpheus/guard/guard-1/wg-private-key"]; data["value"] != "d2c=" {
+		t.Errorf("secret in vault = %v, want the value base64-encoded", data)
+	}
+
+	s := NewVaultStore(server.URL, "wrong", "kv", "")
+	if err := s.Put(context.Background(), "a", []byte("x")); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Put() with a wrong token error = %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package secretstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// VaultStore stores blobs in a HashiCorp Vault KV version 2 secrets engine.
+// Each blob is a secret with its base64-encoded content in the "value"
+// field, so Vault keeps earlier versions and its audit log records access.
+type VaultStore struct {
+	Address string // e.g. https://vault.example.com:8200
+	Token   string
+	Mount   string // KV engine mount (default: secret)
+	Path    string // Path below the mount (default: morpheus)
+
+	client *http.Client
+}
+
+// NewVaultStore returns a store for the KV v2 engine at mount, keeping its
+// secrets below path
+func NewVaultStore(address, token, mount, path string) *VaultStore {
+	if mount == "" {
+		mount = "secret"
+	}
+	if path == "" {
+		path = "morpheus"
+	}
+	return &VaultStore{
+		Address: strings.TrimSuffix(address, "/"),
+		Token:   token,
+		Mount:   strings.Trim(mount, "/"),
+		Path:    strings.Trim(path, "/"),
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// vaultError is the error response of the Vault API
+type vaultError struct {
+	Errors []string `json:"errors"`
+}
+
+func (s *VaultStore) do(ctx context.Context, method, endpoint, key string, body interface{}, result interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	u := fmt.Sprintf("%s/v1/%s/%s/%s", s.Address, s.Mount, endpoint, strings.TrimSuffix(s.Path+"/"+key, "/"))
+	if method == "LIST" {
+		u += "/"
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", s.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return resp.StatusCode, nil
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		var apiErr vaultError
+		if json.Unmarshal(data, &apiErr) == nil && len(apiErr.Errors) > 0 {
+			return resp.StatusCode, fmt.Errorf("vault: %s", strings.Join(apiErr.Errors, "; "))
+		}
+		return resp.StatusCode, fmt.Errorf("vault: status %d", resp.StatusCode)
+	}
+	if result != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, result); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to parse vault response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// Get reads the latest version of key
+func (s *VaultStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	var result struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	status, err := s.do(ctx, http.MethodGet, "data", key, nil, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch secret %s: %w", key, err)
+	}
+	value, ok := result.Data.Data["value"]
+	if status == http.StatusNotFound || !ok {
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s: invalid value: %w", key, err)
+	}
+	return data, nil
+}
+
+// Put writes a new version of key
+func (s *VaultStore) Put(ctx context.Context, key string, data []byte) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	body := map[string]interface{}{
+		"data": map[string]string{"value": base64.StdEncoding.EncodeToString(data)},
+	}
+	if _, err := s.do(ctx, http.MethodPost, "data", key, body, nil); err != nil {
+		return fmt.Errorf("failed to save secret %s: %w", key, err)
+	}
+	return nil
+}
+
+// Delete removes key with all its versions
+func (s *VaultStore) Delete(ctx context.Context, key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	if _, err := s.do(ctx, http.MethodDelete, "metadata", key, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete secret %s: %w", key, err)
+	}
+	return nil
+}
+
+// List walks the metadata tree for keys starting with prefix
+func (s *VaultStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	dirs := []string{""}
+	for len(dirs) > 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+		if !strings.HasPrefix(dir, prefix) && !strings.HasPrefix(prefix, dir) {
+			continue
+		}
+		var result struct {
+			Data struct {
+				Keys []string `json:"keys"`
+			} `json:"data"`
+		}
+		if _, err := s.do(ctx, "LIST", "metadata", strings.TrimSuffix(dir, "/"), nil, &result); err != nil {
+			return nil, fmt.Errorf("failed to list secrets: %w", err)
+		}
+		for _, k := range result.Data.Keys {
+			switch {
+			case strings.HasSuffix(k, "/"):
+				dirs = append(dirs, dir+k)
+			case strings.HasPrefix(dir+k, prefix):
+				keys = append(keys, dir+k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
@@ -0,0 +1,205 @@
+package secretstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WebDAVStore stores blobs as files on a WebDAV server such as a Hetzner
+// StorageBox
+type WebDAVStore struct {
+	URL      string // Base URL, e.g. https://uXXXXX.your-storagebox.de/morpheus/secrets/
+	Username string
+	Password string
+
+	client *http.Client
+}
+
+// NewWebDAVStore returns a store keeping its blobs below baseURL
+func NewWebDAVStore(baseURL, username, password string) *WebDAVStore {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return &WebDAVStore{
+		URL:      baseURL,
+		Username: username,
+		Password: password,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *WebDAVStore) do(ctx context.Context, method, rawURL string, body []byte, header map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.SetBasicAuth(s.Username, s.Password)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	return s.client.Do(req)
+}
+
+// keyURL returns the URL of key, with each element escaped
+func (s *WebDAVStore) keyURL(key string) string {
+	elems := strings.Split(key, "/")
+	for i, e := range elems {
+		elems[i] = url.PathEscape(e)
+	}
+	return s.URL + strings.Join(elems, "/")
+}
+
+// Get downloads the file of key
+func (s *WebDAVStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, s.keyURL(key), nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch secret %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch secret %s: status %d", key, resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Put uploads the file of key, creating its directories
+func (s *WebDAVStore) Put(ctx context.Context, key string, data []byte) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	elems := strings.Split(key, "/")
+	for i := 1; i < len(elems); i++ {
+		if err := s.mkcol(ctx, strings.Join(elems[:i], "/")); err != nil {
+			return fmt.Errorf("failed to save secret %s: %w", key, err)
+		}
+	}
+	resp, err := s.do(ctx, http.MethodPut, s.keyURL(key), data, map[string]string{"Content-Type": "application/octet-stream"})
+	if err != nil {
+		return fmt.Errorf("failed to save secret %s: %w", key, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to save secret %s: status %d", key, resp.StatusCode)
+	}
+	return nil
+}
+
+// mkcol creates a directory; one that exists already is fine
+func (s *WebDAVStore) mkcol(ctx context.Context, dir string) error {
+	resp, err := s.do(ctx, "MKCOL", s.keyURL(dir)+"/", nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusMethodNotAllowed {
+		return fmt.Errorf("failed to create directory %s: status %d", dir, resp.StatusCode)
+	}
+	return nil
+}
+
+// Delete removes the file of key
+func (s *WebDAVStore) Delete(ctx context.Context, key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, s.keyURL(key), nil, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete secret %s: %w", key, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		return fmt.Errorf("failed to delete secret %s: status %d", key, resp.StatusCode)
+	}
+	return nil
+}
+
+type davMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// List walks the directories below the base URL with PROPFIND, one level
+// at a time since servers may refuse "Depth: infinity"
+func (s *WebDAVStore) List(ctx context.Context, prefix string) ([]string, error) {
+	base, err := url.Parse(s.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WebDAV URL: %w", err)
+	}
+	var keys []string
+	dirs := []string{""}
+	for len(dirs) > 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+		// Only descend into directories that can hold keys with the prefix
+		if !strings.HasPrefix(dir, prefix) && !strings.HasPrefix(prefix, dir) {
+			continue
+		}
+		dirURL := s.URL
+		if dir != "" {
+			dirURL = s.keyURL(strings.TrimSuffix(dir, "/")) + "/"
+		}
+		resp, err := s.do(ctx, "PROPFIND", dirURL, []byte(`<?xml version="1.0"?><propfind xmlns="DAV:"><prop><resourcetype/></prop></propfind>`),
+			map[string]string{"Depth": "1", "Content-Type": "application/xml"})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list secrets: %w", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if resp.StatusCode != http.StatusMultiStatus {
+			return nil, fmt.Errorf("failed to list secrets: status %d", resp.StatusCode)
+		}
+		var ms davMultistatus
+		if err := xml.Unmarshal(body, &ms); err != nil {
+			return nil, fmt.Errorf("failed to parse WebDAV listing: %w", err)
+		}
+		for _, r := range ms.Responses {
+			href, err := url.Parse(r.Href)
+			if err != nil {
+				continue
+			}
+			rel := strings.TrimPrefix(path.Clean(href.Path), path.Clean(base.Path))
+			rel = strings.TrimPrefix(rel, "/")
+			if rel == "" || rel == strings.TrimSuffix(dir, "/") {
+				continue
+			}
+			collection := false
+			for _, ps := range r.Propstat {
+				collection = collection || ps.Prop.ResourceType.Collection != nil
+			}
+			switch {
+			case collection:
+				dirs = append(dirs, rel+"/")
+			case strings.HasPrefix(rel, prefix) && !strings.HasPrefix(path.Base(rel), "."):
+				keys = append(keys, rel)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
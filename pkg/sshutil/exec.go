@@ -0,0 +1,215 @@
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Target is a host to run a command on
+type Target struct {
+	Name    string // Shown as the output prefix
+	Addr    string // IP address or hostname
+	HostKey string // Pinned host public key; if empty, trusted on first use
+}
+
+// ExecOptions configures RunParallel
+type ExecOptions struct {
+	User         string        // Default root
+	IdentityFile string        // Optional private key
+	Concurrency  int           // Maximum parallel sessions (default 10)
+	Timeout      time.Duration // Per-host timeout (0 = none)
+	Stdout       io.Writer     // Receives prefixed output lines
+	Stderr       io.Writer
+	Stdin        []byte // Sent to every session's standard input (optional)
+
+	// SSHBinary is the ssh client to run (default "ssh")
+	SSHBinary string
+}
+
+// ExecResult is the outcome of a command on one host
+type ExecResult struct {
+	Target   Target
+	ExitCode int // -1 if the command could not be run
+	Err      error
+	Duration time.Duration
+}
+
+// RunParallel runs command on every target over SSH, at most
+// opts.Concurrency at a time. Output lines are prefixed with the target's
+// name. Results are returned in target order. Targets with a pinned host
+// key are only connected to if the server presents that key.
+func RunParallel(ctx context.Context, targets []Target, command string, opts ExecOptions) []ExecResult {
+	if opts.User == "" {
+		opts.User = "root"
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 10
+	}
+	if opts.SSHBinary == "" {
+		opts.SSHBinary = "ssh"
+	}
+	if opts.Stdout == nil {
+		opts.Stdout = io.Discard
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = io.Discard
+	}
+
+	results := make([]ExecResult, len(targets))
+
+	knownHosts, err := TempKnownHosts(targets)
+	if err != nil {
+		for i, target := range targets {
+			results[i] = ExecResult{Target: target, ExitCode: -1, Err: err}
+		}
+		return results
+	}
+	if knownHosts != "" {
+		defer os.Remove(knownHosts)
+	}
+
+	var outMu sync.Mutex // Keeps lines from different hosts whole
+	sem := make(chan struct{}, opts.Concurrency)
+	var wg sync.WaitGroup
+
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target Target) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			prefix := fmt.Sprintf("[%s] ", target.Name)
+			stdout := &prefixWriter{w: opts.Stdout, prefix: prefix, mu: &outMu}
+			stderr := &prefixWriter{w: opts.Stderr, prefix: prefix, mu: &outMu}
+			results[i] = runOne(ctx, target, command, opts, knownHosts, stdout, stderr)
+			stdout.Flush()
+			stderr.Flush()
+		}(i, target)
+	}
+	wg.Wait()
+	return results
+}
+
+// TempKnownHosts writes a temporary known_hosts file with the pinned host
+// keys of targets, to be removed by the caller. It returns "" if no target
+// has one.
+func TempKnownHosts(targets []Target) (string, error) {
+	pinned := false
+	for _, t := range targets {
+		pinned = pinned || t.HostKey != ""
+	}
+	if !pinned {
+		return "", nil
+	}
+
+	f, err := os.CreateTemp("", "morpheus-known-hosts-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create known_hosts: %w", err)
+	}
+	f.Close()
+	if err := WriteKnownHosts(f.Name(), targets); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write known_hosts: %w", err)
+	}
+	return f.Name(), nil
+}
+
+func runOne(ctx context.Context, target Target, command string, opts ExecOptions, knownHosts string, stdout, stderr io.Writer) ExecResult {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	args := append([]string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=15"}, HostKeyArgs(target.HostKey, knownHosts)...)
+	if opts.IdentityFile != "" {
+		args = append(args, "-i", opts.IdentityFile)
+	}
+	args = append(args, fmt.Sprintf("%s@%s", opts.User, target.Addr), command)
+
+	start := time.Now()
+	cmd := exec.CommandContext(ctx, opts.SSHBinary, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if opts.Stdin != nil {
+		cmd.Stdin = bytes.NewReader(opts.Stdin)
+	}
+	cmd.WaitDelay = time.Second // Don't hang on output held open by a killed session
+	err := cmd.Run()
+
+	result := ExecResult{Target: target, Duration: time.Since(start)}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case ctx.Err() == context.DeadlineExceeded:
+		result.ExitCode = -1
+		result.Err = fmt.Errorf("timed out after %s", opts.Timeout)
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+		result.Err = fmt.Errorf("exit status %d", result.ExitCode)
+		if result.ExitCode == 255 {
+			// ssh reserves 255 for its own errors
+			result.Err = fmt.Errorf("ssh failed (exit status 255)")
+		}
+	default:
+		result.ExitCode = -1
+		result.Err = err
+	}
+	return result
+}
+
+// HostKeyArgs returns the ssh options that verify a server's host key: the
+// pinned key in knownHostsFile if there is one, else trust on first use
+func HostKeyArgs(hostKey, knownHostsFile string) []string {
+	if hostKey == "" || knownHostsFile == "" {
+		return []string{"-o", "StrictHostKeyChecking=accept-new"}
+	}
+	return []string{
+		"-o", "StrictHostKeyChecking=yes",
+		"-o", "UserKnownHostsFile=" + knownHostsFile,
+	}
+}
+
+// prefixWriter writes complete lines to w, each with a prefix
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.buf = append(p.buf, data...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		p.writeLine(p.buf[:i+1])
+		p.buf = p.buf[i+1:]
+	}
+	return len(data), nil
+}
+
+// Flush writes a final line without newline, if any
+func (p *prefixWriter) Flush() {
+	if len(p.buf) > 0 {
+		p.writeLine(append(p.buf, '\n'))
+		p.buf = nil
+	}
+}
+
+func (p *prefixWriter) writeLine(line []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	io.WriteString(p.w, p.prefix)
+	p.w.Write(line)
+}
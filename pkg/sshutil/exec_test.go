@@ -0,0 +1,104 @@
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSSH writes a script that stands in for the ssh client. Its behaviour
+// depends on the host: "fail" exits 3, "slow" sleeps, "pinned" prints the
+// known_hosts file it was given, anything else prints the command followed
+// by a line without newline.
+func fakeSSH(t *testing.T) string {
+	t.Helper()
+	script := `#!/bin/sh
+for last; do :; done
+for arg; do case "$arg" in *@*) host="${arg#*@}";; UserKnownHostsFile=*) kh="${arg#*=}";; esac; done
+case "$host" in
+pinned) cat "$kh";;
+fail) echo "boom" >&2; exit 3;;
+slow) exec sleep 5;;
+*) echo "ran: $last"; printf "partial";;
+esac
+`
+	path := filepath.Join(t.TempDir(), "ssh")
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunParallel(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	targets := []Target{{Name: "n1", Addr: "ok"}, {Name: "n2", Addr: "fail"}, {Name: "n3", Addr: "ok"}}
+
+	results := RunParallel(context.Background(), targets, "uptime", ExecOptions{
+		Concurrency: 2,
+		Stdout:      &stdout,
+		Stderr:      &stderr,
+		SSHBinary:   fakeSSH(t),
+	})
+
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	for i, want := range []int{0, 3, 0} {
+		if results[i].Target != targets[i] {
+			t.Errorf("results[%d].Target = %v, want %v", i, results[i].Target, targets[i])
+		}
+		if results[i].ExitCode != want {
+			t.Errorf("results[%d].ExitCode = %d, want %d", i, results[i].ExitCode, want)
+		}
+		if (results[i].Err != nil) != (want != 0) {
+			t.Errorf("results[%d].Err = %v", i, results[i].Err)
+		}
+	}
+
+	out := stdout.String()
+	for _, want := range []string{"[n1] ran: uptime\n", "[n1] partial\n", "[n3] ran: uptime\n", "[n3] partial\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("stdout missing %q:\n%s", want, out)
+		}
+	}
+	if stderr.String() != "[n2] boom\n" {
+		t.Errorf("stderr = %q, want %q", stderr.String(), "[n2] boom\n")
+	}
+}
+
+func TestRunParallelTimeout(t *testing.T) {
+	results := RunParallel(context.Background(), []Target{{Name: "n1", Addr: "slow"}}, "true", ExecOptions{
+		Timeout:   100 * time.Millisecond,
+		SSHBinary: fakeSSH(t),
+	})
+	if results[0].ExitCode != -1 || results[0].Err == nil || !strings.Contains(results[0].Err.Error(), "timed out") {
+		t.Errorf("result = %+v, want timeout", results[0])
+	}
+}
+
+func TestRunParallelPinnedHostKey(t *testing.T) {
+	var stdout bytes.Buffer
+	targets := []Target{{Name: "n1", Addr: "pinned", HostKey: "ssh-ed25519 AAAA1"}}
+
+	results := RunParallel(context.Background(), targets, "true", ExecOptions{Stdout: &stdout, SSHBinary: fakeSSH(t)})
+	if results[0].Err != nil {
+		t.Fatalf("unexpected error: %v", results[0].Err)
+	}
+	if stdout.String() != "[n1] pinned ssh-ed25519 AAAA1\n" {
+		t.Errorf("stdout = %q", stdout.String())
+	}
+}
+
+func TestHostKeyArgs(t *testing.T) {
+	if args := strings.Join(HostKeyArgs("", ""), " "); args != "-o StrictHostKeyChecking=accept-new" {
+		t.Errorf("unpinned args = %s", args)
+	}
+	args := strings.Join(HostKeyArgs("ssh-ed25519 AAAA1", "/tmp/kh"), " ")
+	if args != "-o StrictHostKeyChecking=yes -o UserKnownHostsFile=/tmp/kh" {
+		t.Errorf("pinned args = %s", args)
+	}
+}
@@ -0,0 +1,122 @@
+package sshutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// HostKey is an SSH host key pair generated for a server before it boots,
+// so its public key can be pinned instead of trusted on first use
+type HostKey struct {
+	PrivateKey string // OpenSSH private key (PEM)
+	PublicKey  string // authorized_keys format: "ssh-ed25519 AAAA..."
+}
+
+// GenerateHostKey generates an ed25519 host key pair
+func GenerateHostKey() (*HostKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate host key: %w", err)
+	}
+
+	pubBlob := sshString([]byte("ssh-ed25519"), pub)
+
+	// openssh-key-v1 format, unencrypted
+	var checkInt [4]byte
+	if _, err := rand.Read(checkInt[:]); err != nil {
+		return nil, err
+	}
+	var private bytes.Buffer
+	private.Write(checkInt[:])
+	private.Write(checkInt[:])
+	private.Write(sshString([]byte("ssh-ed25519"), pub, priv, []byte("morpheus")))
+	for i := byte(1); private.Len()%8 != 0; i++ {
+		private.WriteByte(i)
+	}
+
+	var key bytes.Buffer
+	key.WriteString("openssh-key-v1\x00")
+	key.Write(sshString([]byte("none"), []byte("none"), nil))
+	binary.Write(&key, binary.BigEndian, uint32(1))
+	key.Write(sshString(pubBlob, private.Bytes()))
+
+	return &HostKey{
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: key.Bytes()})),
+		PublicKey:  "ssh-ed25519 " + base64.StdEncoding.EncodeToString(pubBlob),
+	}, nil
+}
+
+// sshString encodes values as length-prefixed SSH wire strings
+func sshString(values ...[]byte) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		binary.Write(&buf, binary.BigEndian, uint32(len(v)))
+		buf.Write(v)
+	}
+	return buf.Bytes()
+}
+
+// SameHostKey reports whether two public keys in authorized_keys or
+// known_hosts format are the same key, ignoring host names and comments
+func SameHostKey(a, b string) bool {
+	keyA, okA := keyFields(a)
+	keyB, okB := keyFields(b)
+	return okA && okB && keyA == keyB
+}
+
+// keyFields returns "type base64" from a public key or known_hosts line
+func keyFields(line string) (string, bool) {
+	fields := strings.Fields(line)
+	for i := 0; i+1 < len(fields); i++ {
+		if strings.HasPrefix(fields[i], "ssh-") || strings.HasPrefix(fields[i], "ecdsa-") {
+			return fields[i] + " " + fields[i+1], true
+		}
+	}
+	return "", false
+}
+
+// KnownHostsLine returns a known_hosts entry pinning publicKey for addr
+func KnownHostsLine(addr, publicKey string) string {
+	key, _ := keyFields(publicKey)
+	return addr + " " + key
+}
+
+// WriteKnownHosts writes a known_hosts file with the pinned keys of targets.
+// Targets without a host key are left out.
+func WriteKnownHosts(path string, targets []Target) error {
+	var b strings.Builder
+	for _, t := range targets {
+		if t.HostKey != "" {
+			b.WriteString(KnownHostsLine(t.Addr, t.HostKey) + "\n")
+		}
+	}
+	return os.WriteFile(path, []byte(b.String()), 0600)
+}
+
+// ScanHostKey fetches the ed25519 host key a server presents, using
+// ssh-keyscan. The result is in known_hosts format.
+func ScanHostKey(ctx context.Context, addr string, port int) (string, error) {
+	args := []string{"-T", "5", "-t", "ed25519"}
+	if port != 0 && port != 22 {
+		args = append(args, "-p", fmt.Sprint(port))
+	}
+	out, err := exec.CommandContext(ctx, "ssh-keyscan", append(args, addr)...).Output()
+	if err != nil {
+		return "", fmt.Errorf("ssh-keyscan failed: %w", err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if _, ok := keyFields(line); ok && !strings.HasPrefix(line, "#") {
+			return strings.TrimSpace(line), nil
+		}
+	}
+	return "", fmt.Errorf("no ed25519 host key from %s", addr)
+}
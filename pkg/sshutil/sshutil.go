@@ -1,4 +1,5 @@
-// Package sshutil provides utility functions for SSH-related formatting.
+// Package sshutil provides utility functions for SSH-related formatting and
+// for running commands on nodes over SSH.
 package sshutil
 
 import (
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+	"time"
+)
+
+// maxEvents is how many events a registry keeps; older ones are dropped
+const maxEvents = 1000
+
+// Event records a change to forests, nodes or guards and who made it
+type Event struct {
+	Time     time.Time `json:"time"`
+	Operator string    `json:"operator,omitempty"` // e.g. alice@example.com
+	Command  string    `json:"command,omitempty"`  // The command line that made the change
+	Action   string    `json:"action"`             // e.g. register-forest, node-status, create-guard
+	Forest   string    `json:"forest,omitempty"`
+	Node     string    `json:"node,omitempty"`
+	Guard    string    `json:"guard,omitempty"`
+	Detail   string    `json:"detail,omitempty"` // e.g. the new status
+}
+
+// Target returns what the event changed, e.g. "forest-1 node-2"
+func (e Event) Target() string {
+	var parts []string
+	if e.Forest != "" {
+		parts = append(parts, e.Forest)
+	}
+	if e.Node != "" {
+		parts = append(parts, e.Node)
+	}
+	if e.Guard != "" {
+		parts = append(parts, "guard "+e.Guard)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (e Event) String() string {
+	s := fmt.Sprintf("%s  %-16s %s", e.Time.Local().Format("2006-01-02 15:04:05"), e.Action, e.Target())
+	if e.Detail != "" {
+		s += " (" + e.Detail + ")"
+	}
+	if e.Operator != "" {
+		s += "  by " + e.Operator
+	}
+	return s
+}
+
+// Matches reports whether the event is about a forest or guard ID
+func (e Event) Matches(id string) bool {
+	return id == "" || e.Forest == id || e.Guard == id
+}
+
+// appendEvent adds an event to a log, keeping the newest maxEvents
+func appendEvent(events []Event, e Event) []Event {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	events = append(events, e)
+	if len(events) > maxEvents {
+		events = append([]Event(nil), events[len(events)-maxEvents:]...)
+	}
+	return events
+}
+
+// filterEvents returns the events about a forest or guard ID ("" for all)
+func filterEvents(events []Event, id string) []Event {
+	result := []Event{}
+	for _, e := range events {
+		if e.Matches(id) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// DetectOperator returns who is running morpheus: the configured identity
+// if there is one, else git's user.email, else user@host
+func DetectOperator(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	if out, err := exec.Command("git", "config", "--get", "user.email").Output(); err == nil {
+		if email := strings.TrimSpace(string(out)); email != "" {
+			return email
+		}
+	}
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+	return name
+}
+
+// AuditedRegistry wraps a Registry and records every change made through
+// it in the registry's event log, with the operator and command line.
+// Reads are passed through unchanged.
+type AuditedRegistry struct {
+	Registry
+	operator string
+	command  string
+}
+
+// Ensure AuditedRegistry implements Registry
+var _ Registry = (*AuditedRegistry)(nil)
+
+// NewAuditedRegistry returns a recording wrapper around inner
+func NewAuditedRegistry(inner Registry, operator, command string) *AuditedRegistry {
+	return &AuditedRegistry{Registry: inner, operator: operator, command: command}
+}
+
+// Operator returns the identity changes are recorded with
+func (r *AuditedRegistry) Operator() string {
+	return r.operator
+}
+
+// record appends an event after a successful change. The change has
+// already happened at this point, so a failure is reported but not
+// returned.
+func (r *AuditedRegistry) record(err error, e Event) error {
+	if err != nil {
+		return err
+	}
+	e.Operator = r.operator
+	e.Command = r.command
+	if err := r.Registry.AppendEvent(e); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Warning: registry change not recorded in history: %s\n", err)
+	}
+	return nil
+}
+
+// AppendEvent records an event, e.g. of a guard, with the operator and
+// command line
+func (r *AuditedRegistry) AppendEvent(e Event) error {
+	e.Operator = r.operator
+	e.Command = r.command
+	return r.Registry.AppendEvent(e)
+}
+
+// RegisterForest adds a forest and records it
+func (r *AuditedRegistry) RegisterForest(forest *Forest) error {
+	return r.record(r.Registry.RegisterForest(forest), Event{Action: "register-forest", Forest: forest.ID})
+}
+
+// RegisterNode adds a node and records it
+func (r *AuditedRegistry) RegisterNode(node *Node) error {
+	return r.record(r.Registry.RegisterNode(node), Event{Action: "register-node", Forest: node.ForestID, Node: node.ID})
+}
+
+// UpdateForest updates a forest and records it
+func (r *AuditedRegistry) UpdateForest(updated *Forest) error {
+	return r.record(r.Registry.UpdateForest(updated), Event{Action: "update-forest", Forest: updated.ID})
+}
+
+// UpdateForestStatus updates the status of a forest and records it
+func (r *AuditedRegistry) UpdateForestStatus(forestID, status string) error {
+	return r.record(r.Registry.UpdateForestStatus(forestID, status), Event{Action: "forest-status", Forest: forestID, Detail: status})
+}
+
+// UpdateNodeStatus updates the status of a node and records it
+func (r *AuditedRegistry) UpdateNodeStatus(forestID, nodeID, status string) error {
+	return r.record(r.Registry.UpdateNodeStatus(forestID, nodeID, status), Event{Action: "node-status", Forest: forestID, Node: nodeID, Detail: status})
+}
+
+// UpdateNode replaces a node's fields and records it
+func (r *AuditedRegistry) UpdateNode(updated *Node) error {
+	return r.record(r.Registry.UpdateNode(updated), Event{Action: "update-node", Forest: updated.ForestID, Node: updated.ID})
+}
+
+// DeleteNode removes a node and records it
+func (r *AuditedRegistry) DeleteNode(forestID, nodeID string) error {
+	return r.record(r.Registry.DeleteNode(forestID, nodeID), Event{Action: "delete-node", Forest: forestID, Node: nodeID})
+}
+
+// ReplaceNode replaces a node and records it
+func (r *AuditedRegistry) ReplaceNode(forestID, oldID, newID string) error {
+	return r.record(r.Registry.ReplaceNode(forestID, oldID, newID), Event{Action: "replace-node", Forest: forestID, Node: newID, Detail: "replaces " + oldID})
+}
+
+// DeleteForest removes a forest and records it
+func (r *AuditedRegistry) DeleteForest(forestID string) error {
+	return r.record(r.Registry.DeleteForest(forestID), Event{Action: "delete-forest", Forest: forestID})
+}
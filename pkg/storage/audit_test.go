@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditedRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	inner, err := NewLocalRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := NewAuditedRegistry(inner, "alice@example.com", "morpheus plant")
+
+	if err := alice.RegisterForest(&Forest{ID: "forest-1"}); err != nil {
+		t.Fatal(err)
+	}
+	alice.RegisterNode(&Node{ID: "node-1", ForestID: "forest-1"})
+	if err := alice.UpdateNodeStatus("forest-1", "missing", "active"); err == nil {
+		t.Fatal("UpdateNodeStatus() of a missing node: expected error")
+	}
+	alice.AppendEvent(Event{Action: "create-guard", Guard: "guard-1", Detail: "westeurope"})
+
+	// Another operator sharing the registry file
+	other, err := NewLocalRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob := NewAuditedRegistry(other, "bob@example.com", "morpheus teardown forest-1")
+	if err := bob.UpdateForestStatus("forest-1", "deleting"); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := inner.Events("forest-1")
+	if err != nil {
+		t.Fatalf("Events() error = %v", err)
+	}
+	var got []string
+	for _, e := range events {
+		got = append(got, e.Action+" "+e.Operator)
+	}
+	want := "register-forest alice@example.com, register-node alice@example.com, forest-status bob@example.com"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("Events(forest-1) = %v, want %s", got, want)
+	}
+	if last := events[len(events)-1]; last.Detail != "deleting" || last.Command != "morpheus teardown forest-1" || last.Time.IsZero() {
+		t.Errorf("last event = %+v", last)
+	}
+	if s := events[1].String(); !strings.Contains(s, "forest-1 node-1") || !strings.HasSuffix(s, "by alice@example.com") {
+		t.Errorf("String() = %q", s)
+	}
+
+	guardEvents, _ := bob.Events("guard-1")
+	if len(guardEvents) != 1 || guardEvents[0].Operator != "alice@example.com" {
+		t.Errorf("Events(guard-1) = %+v", guardEvents)
+	}
+	if all, _ := bob.Events(""); len(all) != 4 {
+		t.Errorf("Events() = %d events, want 4", len(all))
+	}
+}
+
+func TestAppendEventKeepsNewest(t *testing.T) {
+	var events []Event
+	for i := 0; i < maxEvents+5; i++ {
+		events = appendEvent(events, Event{Action: "update-forest", Forest: "forest-1"})
+	}
+	if len(events) != maxEvents {
+		t.Errorf("len(events) = %d, want %d", len(events), maxEvents)
+	}
+}
+
+func TestDetectOperator(t *testing.T) {
+	if got := DetectOperator("ops@example.com"); got != "ops@example.com" {
+		t.Errorf("DetectOperator() = %q, want the configured identity", got)
+	}
+	if got := DetectOperator(""); got == "" {
+		t.Error("DetectOperator() without a configured identity returned nothing")
+	}
+}
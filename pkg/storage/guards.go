@@ -0,0 +1,316 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrGuardNotFound is returned when a guard is not found
+var ErrGuardNotFound = errors.New("guard not found")
+
+// maxGuardConfigs is how many WireGuard configs are kept per guard; older
+// ones are dropped
+const maxGuardConfigs = 20
+
+// GuardRegistry records WireGuard gateway VMs (guards), so they can be
+// listed without scanning the clouds they live in and keep what the
+// clouds do not: who created them, their peerings and the configs they
+// were created with. It is implemented by the same backends as Registry.
+type GuardRegistry interface {
+	// RegisterGuard adds a new guard to the registry
+	RegisterGuard(g *Guard) error
+
+	// GetGuard retrieves a guard by ID
+	GetGuard(guardID string) (*Guard, error)
+
+	// UpdateGuard replaces a guard's fields (preserving CreatedAt)
+	UpdateGuard(updated *Guard) error
+
+	// DeleteGuard removes a guard
+	DeleteGuard(guardID string) error
+
+	// ListGuards returns all registered guards, sorted by ID
+	ListGuards() []*Guard
+}
+
+// Ensure implementations satisfy the interface
+var (
+	_ GuardRegistry = (*LocalRegistry)(nil)
+	_ GuardRegistry = (*RemoteRegistry)(nil)
+)
+
+// Guard is a WireGuard gateway VM created by morpheus-azureguard
+type Guard struct {
+	ID            string   `json:"id"`
+	Provider      string   `json:"provider"` // azure, gcp, aws or hetzner
+	Location      string   `json:"location"`
+	Group         string   `json:"group,omitempty"`
+	Role          string   `json:"role,omitempty"` // primary or standby, for HA pairs
+	Status        string   `json:"status"`         // As last seen in the cloud
+	PublicIP      string   `json:"public_ip,omitempty"`
+	PrivateIP     string   `json:"private_ip,omitempty"`
+	ServerID      string   `json:"server_id,omitempty"`
+	VNetID        string   `json:"vnet_id,omitempty"`
+	ResourceGroup string   `json:"resource_group,omitempty"`
+	MeshCIDRs     []string `json:"mesh_cidrs,omitempty"`
+	WireGuardPort int      `json:"wireguard_port,omitempty"`
+	PublicKey     string   `json:"public_key,omitempty"`
+
+	Tags map[string]string `json:"tags,omitempty"` // User tags, e.g. team or cost-center
+
+	// Project is the named Hetzner project a Hetzner guard lives in
+	Project string `json:"project,omitempty"`
+	// ExpectedCost is the expected monthly spend (0 = unknown), used to
+	// detect billing anomalies
+	ExpectedCost float64 `json:"expected_cost,omitempty"`
+
+	CreatedBy string    `json:"created_by,omitempty"` // Operator, empty if discovered
+	CreatedAt time.Time `json:"created_at"`
+	SyncedAt  time.Time `json:"synced_at,omitempty"` // Last compared with the cloud
+
+	Peerings []GuardPeering `json:"peerings,omitempty"`
+
+	// Configs are the WireGuard configs the guard was given, with private
+	// keys redacted, oldest first
+	Configs []GuardConfig `json:"configs,omitempty"`
+}
+
+// GuardPeering is a workload network peered with a guard
+type GuardPeering struct {
+	Name         string    `json:"name"`
+	RemoteVNetID string    `json:"remote_vnet_id"`
+	SubnetID     string    `json:"subnet_id,omitempty"`
+	RouteTableID string    `json:"route_table_id,omitempty"`
+	CreatedBy    string    `json:"created_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at,omitempty"`
+}
+
+// GuardConfig is a WireGuard config a guard was given
+type GuardConfig struct {
+	Time     time.Time `json:"time"`
+	Operator string    `json:"operator,omitempty"`
+	SHA256   string    `json:"sha256"` // Of Config, hex
+	Config   string    `json:"config"`
+}
+
+// FindPeering returns the peering with a remote network, or nil
+func (g *Guard) FindPeering(remoteVNetID string) *GuardPeering {
+	for i := range g.Peerings {
+		if strings.EqualFold(g.Peerings[i].RemoteVNetID, remoteVNetID) {
+			return &g.Peerings[i]
+		}
+	}
+	return nil
+}
+
+// SetPeering adds a peering, or updates the one with the same remote
+// network, keeping who created it and when
+func (g *Guard) SetPeering(p GuardPeering) {
+	if existing := g.FindPeering(p.RemoteVNetID); existing != nil {
+		p.CreatedBy, p.CreatedAt = existing.CreatedBy, existing.CreatedAt
+		if p.SubnetID == "" {
+			p.SubnetID = existing.SubnetID
+		}
+		*existing = p
+		return
+	}
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = time.Now().UTC()
+	}
+	g.Peerings = append(g.Peerings, p)
+}
+
+// RemovePeering removes the peering with a remote network and reports
+// whether there was one
+func (g *Guard) RemovePeering(remoteVNetID string) bool {
+	for i := range g.Peerings {
+		if strings.EqualFold(g.Peerings[i].RemoteVNetID, remoteVNetID) {
+			g.Peerings = append(g.Peerings[:i], g.Peerings[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// AddConfig appends a config to the guard's history, unless it is the
+// same as the latest one, keeping the newest maxGuardConfigs
+func (g *Guard) AddConfig(c GuardConfig) {
+	if n := len(g.Configs); n > 0 && g.Configs[n-1].SHA256 == c.SHA256 {
+		return
+	}
+	if c.Time.IsZero() {
+		c.Time = time.Now().UTC()
+	}
+	g.Configs = append(g.Configs, c)
+	if len(g.Configs) > maxGuardConfigs {
+		g.Configs = append([]GuardConfig(nil), g.Configs[len(g.Configs)-maxGuardConfigs:]...)
+	}
+}
+
+// sortedGuards returns the guards of a map, sorted by ID
+func sortedGuards(guards map[string]*Guard) []*Guard {
+	result := make([]*Guard, 0, len(guards))
+	for _, g := range guards {
+		result = append(result, g)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// RegisterGuard adds a new guard to the registry
+func (r *RegistryData) RegisterGuard(g *Guard) error {
+	if r.Guards == nil {
+		r.Guards = make(map[string]*Guard)
+	}
+	if _, exists := r.Guards[g.ID]; exists {
+		return errors.New("guard already exists: " + g.ID)
+	}
+	if g.CreatedAt.IsZero() {
+		g.CreatedAt = time.Now()
+	}
+	r.Guards[g.ID] = g
+	r.UpdatedAt = time.Now()
+	return nil
+}
+
+// GetGuard retrieves a guard by ID
+func (r *RegistryData) GetGuard(guardID string) (*Guard, error) {
+	g, exists := r.Guards[guardID]
+	if !exists {
+		return nil, ErrGuardNotFound
+	}
+	return g, nil
+}
+
+// UpdateGuard replaces a guard's fields (preserving CreatedAt)
+func (r *RegistryData) UpdateGuard(updated *Guard) error {
+	existing, exists := r.Guards[updated.ID]
+	if !exists {
+		return ErrGuardNotFound
+	}
+	g := *updated
+	g.CreatedAt = existing.CreatedAt
+	r.Guards[g.ID] = &g
+	r.UpdatedAt = time.Now()
+	return nil
+}
+
+// DeleteGuard removes a guard
+func (r *RegistryData) DeleteGuard(guardID string) error {
+	if _, exists := r.Guards[guardID]; !exists {
+		return ErrGuardNotFound
+	}
+	delete(r.Guards, guardID)
+	r.UpdatedAt = time.Now()
+	return nil
+}
+
+// ListGuards returns all registered guards, sorted by ID
+func (r *RegistryData) ListGuards() []*Guard {
+	return sortedGuards(r.Guards)
+}
+
+// RegisterGuard adds a new guard to the registry
+func (r *LocalRegistry) RegisterGuard(g *Guard) error {
+	return r.update(func() error {
+		if _, exists := r.guards[g.ID]; exists {
+			return fmt.Errorf("guard already exists: %s", g.ID)
+		}
+		if g.CreatedAt.IsZero() {
+			g.CreatedAt = time.Now()
+		}
+		r.guards[g.ID] = g
+		return nil
+	})
+}
+
+// GetGuard retrieves a guard by ID
+func (r *LocalRegistry) GetGuard(guardID string) (*Guard, error) {
+	r.refresh()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	g, exists := r.guards[guardID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrGuardNotFound, guardID)
+	}
+	return g, nil
+}
+
+// UpdateGuard replaces a guard's fields (preserving CreatedAt)
+func (r *LocalRegistry) UpdateGuard(updated *Guard) error {
+	return r.update(func() error {
+		existing, exists := r.guards[updated.ID]
+		if !exists {
+			return fmt.Errorf("%w: %s", ErrGuardNotFound, updated.ID)
+		}
+		g := *updated
+		g.CreatedAt = existing.CreatedAt
+		r.guards[g.ID] = &g
+		return nil
+	})
+}
+
+// DeleteGuard removes a guard
+func (r *LocalRegistry) DeleteGuard(guardID string) error {
+	return r.update(func() error {
+		if _, exists := r.guards[guardID]; !exists {
+			return fmt.Errorf("%w: %s", ErrGuardNotFound, guardID)
+		}
+		delete(r.guards, guardID)
+		return nil
+	})
+}
+
+// ListGuards returns all registered guards, sorted by ID
+func (r *LocalRegistry) ListGuards() []*Guard {
+	r.refresh()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return sortedGuards(r.guards)
+}
+
+// RegisterGuard adds a new guard to the registry
+func (r *RemoteRegistry) RegisterGuard(g *Guard) error {
+	return r.storage.Update(func(data *RegistryData) error {
+		return data.RegisterGuard(g)
+	})
+}
+
+// GetGuard retrieves a guard by ID
+func (r *RemoteRegistry) GetGuard(guardID string) (*Guard, error) {
+	data, err := r.storage.Load()
+	if err != nil {
+		return nil, err
+	}
+	return data.GetGuard(guardID)
+}
+
+// UpdateGuard replaces a guard's fields (preserving CreatedAt)
+func (r *RemoteRegistry) UpdateGuard(updated *Guard) error {
+	return r.storage.Update(func(data *RegistryData) error {
+		return data.UpdateGuard(updated)
+	})
+}
+
+// DeleteGuard removes a guard
+func (r *RemoteRegistry) DeleteGuard(guardID string) error {
+	return r.storage.Update(func(data *RegistryData) error {
+		return data.DeleteGuard(guardID)
+	})
+}
+
+// ListGuards returns all registered guards, sorted by ID
+func (r *RemoteRegistry) ListGuards() []*Guard {
+	data, err := r.storage.Load()
+	if err != nil {
+		return []*Guard{}
+	}
+	return data.ListGuards()
+}
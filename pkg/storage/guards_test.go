@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalRegistryGuards(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	r, err := NewLocalRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.RegisterForest(&Forest{ID: "forest-1"}); err != nil {
+		t.Fatal(err)
+	}
+	g := &Guard{ID: "guard-2", Provider: "azure", CreatedBy: "alice@example.com"}
+	g.SetPeering(GuardPeering{Name: "guard-2-peer", RemoteVNetID: "/subscriptions/s/vnet-a", CreatedBy: "alice@example.com"})
+	g.AddConfig(GuardConfig{SHA256: "aaa", Config: "[Interface]\n"})
+	if err := r.RegisterGuard(g); err != nil {
+		t.Fatalf("RegisterGuard() error = %v", err)
+	}
+	if err := r.RegisterGuard(&Guard{ID: "guard-1", Provider: "gcp"}); err != nil {
+		t.Fatalf("RegisterGuard() error = %v", err)
+	}
+	if err := r.RegisterGuard(&Guard{ID: "guard-1"}); err == nil {
+		t.Error("Expected error registering a guard twice")
+	}
+
+	// Guards are kept next to forests, in the same file
+	other, err := NewLocalRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	guards := other.ListGuards()
+	if len(guards) != 2 || guards[0].ID != "guard-1" || guards[1].ID != "guard-2" {
+		t.Fatalf("ListGuards() = %v, want guard-1 and guard-2", guards)
+	}
+	if len(other.ListForests()) != 1 {
+		t.Errorf("ListForests() = %v, want forest-1 kept", other.ListForests())
+	}
+
+	updated := *guards[1]
+	updated.Status = "running"
+	updated.CreatedAt = updated.CreatedAt.AddDate(1, 0, 0)
+	if err := other.UpdateGuard(&updated); err != nil {
+		t.Fatalf("UpdateGuard() error = %v", err)
+	}
+	got, err := r.GetGuard("guard-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "running" || !got.CreatedAt.Equal(g.CreatedAt) || got.CreatedBy != "alice@example.com" ||
+		len(got.Peerings) != 1 || len(got.Configs) != 1 {
+		t.Errorf("GetGuard() after UpdateGuard() = %+v", got)
+	}
+
+	if err := r.DeleteGuard("guard-2"); err != nil {
+		t.Fatalf("DeleteGuard() error = %v", err)
+	}
+	if _, err := r.GetGuard("guard-2"); !errors.Is(err, ErrGuardNotFound) {
+		t.Errorf("GetGuard() of deleted guard error = %v, want ErrGuardNotFound", err)
+	}
+	if err := r.UpdateGuard(&Guard{ID: "guard-2"}); !errors.Is(err, ErrGuardNotFound) {
+		t.Errorf("UpdateGuard() of deleted guard error = %v, want ErrGuardNotFound", err)
+	}
+}
+
+func TestGuardPeeringsAndConfigs(t *testing.T) {
+	g := &Guard{ID: "guard-1"}
+	g.SetPeering(GuardPeering{Name: "guard-1-peer", RemoteVNetID: "/Subscriptions/S/vnet-a", SubnetID: "subnet-a", CreatedBy: "alice"})
+	created := g.Peerings[0].CreatedAt
+	if created.IsZero() {
+		t.Error("SetPeering() did not set CreatedAt")
+	}
+
+	// Peering the same network again keeps who peered it first
+	g.SetPeering(GuardPeering{Name: "guard-1-peer", RemoteVNetID: "/subscriptions/s/vnet-a", RouteTableID: "rt-1", CreatedBy: "bob"})
+	if len(g.Peerings) != 1 || g.Peerings[0].CreatedBy != "alice" || g.Peerings[0].SubnetID != "subnet-a" || g.Peerings[0].RouteTableID != "rt-1" {
+		t.Errorf("Peerings = %+v", g.Peerings)
+	}
+	if p := g.FindPeering("/subscriptions/s/VNET-A"); p == nil {
+		t.Error("FindPeering() should ignore case")
+	}
+	if g.RemovePeering("vnet-b") || !g.RemovePeering("/subscriptions/s/vnet-a") || len(g.Peerings) != 0 {
+		t.Errorf("RemovePeering() left %+v", g.Peerings)
+	}
+
+	g.AddConfig(GuardConfig{SHA256: "aaa"})
+	g.AddConfig(GuardConfig{SHA256: "aaa"})
+	if len(g.Configs) != 1 {
+		t.Errorf("AddConfig() of an unchanged config = %d configs, want 1", len(g.Configs))
+	}
+	for i := 0; i < maxGuardConfigs+5; i++ {
+		g.AddConfig(GuardConfig{SHA256: string(rune('a' + i%2))})
+	}
+	if len(g.Configs) != maxGuardConfigs || g.Configs[0].SHA256 == "aaa" {
+		t.Errorf("AddConfig() kept %d configs, want the newest %d", len(g.Configs), maxGuardConfigs)
+	}
+}
+
+func TestRegistryDataGuards(t *testing.T) {
+	data := &RegistryData{} // As loaded from a registry written before guards
+	if err := data.RegisterGuard(&Guard{ID: "guard-1"}); err != nil {
+		t.Fatalf("RegisterGuard() error = %v", err)
+	}
+	if _, err := data.GetGuard("guard-1"); err != nil {
+		t.Errorf("GetGuard() error = %v", err)
+	}
+	if err := data.DeleteGuard("guard-1"); err != nil || len(data.ListGuards()) != 0 {
+		t.Errorf("DeleteGuard() error = %v, left %v", err, data.ListGuards())
+	}
+	if err := data.DeleteGuard("guard-1"); !errors.Is(err, ErrGuardNotFound) {
+		t.Errorf("DeleteGuard() of a missing guard error = %v", err)
+	}
+}
@@ -24,11 +24,28 @@ type Registry interface {
 	// UpdateNodeStatus updates the status of a node
 	UpdateNodeStatus(forestID, nodeID, status string) error
 
+	// UpdateNode replaces a node's fields (matched by ForestID and ID)
+	UpdateNode(updated *Node) error
+
+	// DeleteNode removes a single node from a forest
+	DeleteNode(forestID, nodeID string) error
+
+	// ReplaceNode moves node newID into the position of node oldID, which
+	// is removed, so the replacement keeps the old node's index
+	ReplaceNode(forestID, oldID, newID string) error
+
 	// DeleteForest removes a forest and all its nodes
 	DeleteForest(forestID string) error
 
 	// ListForests returns all registered forests
 	ListForests() []*Forest
+
+	// AppendEvent adds an entry to the registry's log of changes
+	AppendEvent(e Event) error
+
+	// Events returns the logged changes of a forest or guard ("" for all),
+	// oldest first
+	Events(id string) ([]Event, error)
 }
 
 // Ensure implementations satisfy the interface
@@ -106,6 +123,27 @@ func (r *RemoteRegistry) UpdateNodeStatus(forestID, nodeID, status string) error
 	})
 }
 
+// UpdateNode replaces a node's fields
+func (r *RemoteRegistry) UpdateNode(updated *Node) error {
+	return r.storage.Update(func(data *RegistryData) error {
+		return data.UpdateNode(updated)
+	})
+}
+
+// DeleteNode removes a single node from a forest
+func (r *RemoteRegistry) DeleteNode(forestID, nodeID string) error {
+	return r.storage.Update(func(data *RegistryData) error {
+		return data.DeleteNode(forestID, nodeID)
+	})
+}
+
+// ReplaceNode moves node newID into the position of node oldID
+func (r *RemoteRegistry) ReplaceNode(forestID, oldID, newID string) error {
+	return r.storage.Update(func(data *RegistryData) error {
+		return data.ReplaceNode(forestID, oldID, newID)
+	})
+}
+
 // DeleteForest removes a forest and all its nodes
 func (r *RemoteRegistry) DeleteForest(forestID string) error {
 	return r.storage.Update(func(data *RegistryData) error {
@@ -122,6 +160,23 @@ func (r *RemoteRegistry) ListForests() []*Forest {
 	return data.ListForests()
 }
 
+// AppendEvent adds an entry to the registry's log of changes
+func (r *RemoteRegistry) AppendEvent(e Event) error {
+	return r.storage.Update(func(data *RegistryData) error {
+		data.Events = appendEvent(data.Events, e)
+		return nil
+	})
+}
+
+// Events returns the logged changes of a forest or guard
+func (r *RemoteRegistry) Events(id string) ([]Event, error) {
+	data, err := r.storage.Load()
+	if err != nil {
+		return nil, err
+	}
+	return filterEvents(data.Events, id), nil
+}
+
 // Ping tests connectivity to the remote storage
 func (r *RemoteRegistry) Ping() error {
 	return r.storage.Ping()
@@ -6,23 +6,42 @@ import (
 	"os"
 	"sync"
 	"time"
+
+	"github.com/nimsforest/morpheus/pkg/lockfile"
+)
+
+const (
+	// registryLockWait is how long a write waits for another process to release the registry
+	registryLockWait = 10 * time.Second
+	// registryLockTTL bounds how long a crashed writer can block others
+	registryLockTTL = time.Minute
 )
 
 // LocalRegistry implements the Registry interface using a local JSON file
 // This is similar to forest.Registry but uses the storage package types
+//
+// Writes are serialized across processes with a lock file next to the
+// registry (registry.json.lock). Each write re-reads the file under the lock,
+// applies its change and atomically replaces the file, so concurrent
+// morpheus runs cannot overwrite each other's changes.
 type LocalRegistry struct {
-	mu      sync.RWMutex
-	forests map[string]*Forest
-	nodes   map[string][]*Node
-	path    string
+	mu       sync.RWMutex
+	forests  map[string]*Forest
+	nodes    map[string][]*Node
+	guards   map[string]*Guard
+	events   []Event
+	path     string
+	lockWait time.Duration
 }
 
 // NewLocalRegistry creates a new local file-based registry
 func NewLocalRegistry(path string) (*LocalRegistry, error) {
 	r := &LocalRegistry{
-		forests: make(map[string]*Forest),
-		nodes:   make(map[string][]*Node),
-		path:    path,
+		forests:  make(map[string]*Forest),
+		nodes:    make(map[string][]*Node),
+		guards:   make(map[string]*Guard),
+		path:     path,
+		lockWait: registryLockWait,
 	}
 
 	// Load existing registry if it exists
@@ -40,41 +59,41 @@ var _ Registry = (*LocalRegistry)(nil)
 
 // RegisterForest adds a new forest to the registry
 func (r *LocalRegistry) RegisterForest(forest *Forest) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	if _, exists := r.forests[forest.ID]; exists {
-		return fmt.Errorf("forest already exists: %s", forest.ID)
-	}
+	return r.update(func() error {
+		if _, exists := r.forests[forest.ID]; exists {
+			return fmt.Errorf("forest already exists: %s", forest.ID)
+		}
 
-	if forest.CreatedAt.IsZero() {
-		forest.CreatedAt = time.Now()
-	}
-	r.forests[forest.ID] = forest
-	r.nodes[forest.ID] = []*Node{}
+		if forest.CreatedAt.IsZero() {
+			forest.CreatedAt = time.Now()
+		}
+		r.forests[forest.ID] = forest
+		r.nodes[forest.ID] = []*Node{}
 
-	return r.save()
+		return nil
+	})
 }
 
 // RegisterNode adds a node to a forest
 func (r *LocalRegistry) RegisterNode(node *Node) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	if _, exists := r.forests[node.ForestID]; !exists {
-		return fmt.Errorf("forest not found: %s", node.ForestID)
-	}
+	return r.update(func() error {
+		if _, exists := r.forests[node.ForestID]; !exists {
+			return fmt.Errorf("forest not found: %s", node.ForestID)
+		}
 
-	if node.CreatedAt.IsZero() {
-		node.CreatedAt = time.Now()
-	}
-	r.nodes[node.ForestID] = append(r.nodes[node.ForestID], node)
+		if node.CreatedAt.IsZero() {
+			node.CreatedAt = time.Now()
+		}
+		r.nodes[node.ForestID] = append(r.nodes[node.ForestID], node)
 
-	return r.save()
+		return nil
+	})
 }
 
 // GetForest retrieves a forest by ID
 func (r *LocalRegistry) GetForest(forestID string) (*Forest, error) {
+	r.refresh()
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -88,6 +107,8 @@ func (r *LocalRegistry) GetForest(forestID string) (*Forest, error) {
 
 // GetNodes retrieves all nodes for a forest
 func (r *LocalRegistry) GetNodes(forestID string) ([]*Node, error) {
+	r.refresh()
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -101,73 +122,129 @@ func (r *LocalRegistry) GetNodes(forestID string) ([]*Node, error) {
 
 // UpdateForest updates a forest's fields
 func (r *LocalRegistry) UpdateForest(updated *Forest) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	forest, exists := r.forests[updated.ID]
-	if !exists {
-		return fmt.Errorf("forest not found: %s", updated.ID)
-	}
+	return r.update(func() error {
+		forest, exists := r.forests[updated.ID]
+		if !exists {
+			return fmt.Errorf("forest not found: %s", updated.ID)
+		}
 
-	// Update fields (preserve CreatedAt)
-	createdAt := forest.CreatedAt
-	*forest = *updated
-	forest.CreatedAt = createdAt
+		// Update fields (preserve CreatedAt)
+		createdAt := forest.CreatedAt
+		*forest = *updated
+		forest.CreatedAt = createdAt
 
-	return r.save()
+		return nil
+	})
 }
 
 // UpdateForestStatus updates the status of a forest
 func (r *LocalRegistry) UpdateForestStatus(forestID, status string) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	forest, exists := r.forests[forestID]
-	if !exists {
-		return fmt.Errorf("forest not found: %s", forestID)
-	}
+	return r.update(func() error {
+		forest, exists := r.forests[forestID]
+		if !exists {
+			return fmt.Errorf("forest not found: %s", forestID)
+		}
 
-	forest.Status = status
-	return r.save()
+		forest.Status = status
+		return nil
+	})
 }
 
 // UpdateNodeStatus updates the status of a node
 func (r *LocalRegistry) UpdateNodeStatus(forestID, nodeID, status string) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+	return r.update(func() error {
+		nodes, exists := r.nodes[forestID]
+		if !exists {
+			return fmt.Errorf("forest not found: %s", forestID)
+		}
 
-	nodes, exists := r.nodes[forestID]
-	if !exists {
-		return fmt.Errorf("forest not found: %s", forestID)
-	}
+		for _, node := range nodes {
+			if node.ID == nodeID {
+				node.Status = status
+				return nil
+			}
+		}
+
+		return fmt.Errorf("node not found: %s", nodeID)
+	})
+}
 
-	for _, node := range nodes {
-		if node.ID == nodeID {
-			node.Status = status
-			return r.save()
+// UpdateNode replaces a node's fields (preserving CreatedAt)
+func (r *LocalRegistry) UpdateNode(updated *Node) error {
+	return r.update(func() error {
+		nodes, exists := r.nodes[updated.ForestID]
+		if !exists {
+			return fmt.Errorf("forest not found: %s", updated.ForestID)
+		}
+
+		for i, node := range nodes {
+			if node.ID == updated.ID {
+				n := *updated
+				n.CreatedAt = node.CreatedAt
+				nodes[i] = &n
+				return nil
+			}
+		}
+
+		return fmt.Errorf("node not found: %s", updated.ID)
+	})
+}
+
+// DeleteNode removes a single node from a forest
+func (r *LocalRegistry) DeleteNode(forestID, nodeID string) error {
+	return r.update(func() error {
+		nodes, exists := r.nodes[forestID]
+		if !exists {
+			return fmt.Errorf("forest not found: %s", forestID)
+		}
+
+		for i, node := range nodes {
+			if node.ID == nodeID {
+				r.nodes[forestID] = append(nodes[:i], nodes[i+1:]...)
+				return nil
+			}
 		}
-	}
 
-	return fmt.Errorf("node not found: %s", nodeID)
+		return fmt.Errorf("node not found: %s", nodeID)
+	})
+}
+
+// ReplaceNode moves node newID into the position of node oldID, which is
+// removed
+func (r *LocalRegistry) ReplaceNode(forestID, oldID, newID string) error {
+	return r.update(func() error {
+		nodes, exists := r.nodes[forestID]
+		if !exists {
+			return fmt.Errorf("forest not found: %s", forestID)
+		}
+
+		replaced, err := replaceNode(nodes, oldID, newID)
+		if err != nil {
+			return fmt.Errorf("node not found: %s or %s", oldID, newID)
+		}
+		r.nodes[forestID] = replaced
+		return nil
+	})
 }
 
 // DeleteForest removes a forest and all its nodes
 func (r *LocalRegistry) DeleteForest(forestID string) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	if _, exists := r.forests[forestID]; !exists {
-		return fmt.Errorf("forest not found: %s", forestID)
-	}
+	return r.update(func() error {
+		if _, exists := r.forests[forestID]; !exists {
+			return fmt.Errorf("forest not found: %s", forestID)
+		}
 
-	delete(r.forests, forestID)
-	delete(r.nodes, forestID)
+		delete(r.forests, forestID)
+		delete(r.nodes, forestID)
 
-	return r.save()
+		return nil
+	})
 }
 
 // ListForests returns all registered forests
 func (r *LocalRegistry) ListForests() []*Forest {
+	r.refresh()
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -179,6 +256,74 @@ func (r *LocalRegistry) ListForests() []*Forest {
 	return forests
 }
 
+// AppendEvent adds an entry to the registry's log of changes
+func (r *LocalRegistry) AppendEvent(e Event) error {
+	return r.update(func() error {
+		r.events = appendEvent(r.events, e)
+		return nil
+	})
+}
+
+// Events returns the logged changes of a forest or guard
+func (r *LocalRegistry) Events(id string) ([]Event, error) {
+	r.refresh()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return filterEvents(r.events, id), nil
+}
+
+// update runs fn against the latest on-disk state while holding the
+// registry lock, then saves the result
+func (r *LocalRegistry) update(fn func() error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lock, err := r.acquireLock()
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	// Pick up changes made by other processes since we last read the file
+	if _, err := os.Stat(r.path); err == nil {
+		if err := r.load(); err != nil {
+			return fmt.Errorf("failed to reload registry: %w", err)
+		}
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+	return r.save()
+}
+
+// acquireLock takes the registry lock file, waiting up to lockWait for
+// another process to release it
+func (r *LocalRegistry) acquireLock() (*lockfile.Lock, error) {
+	deadline := time.Now().Add(r.lockWait)
+	for {
+		lock, err := lockfile.Acquire(r.path+".lock", "registry write", registryLockTTL)
+		if err == nil {
+			return lock, nil
+		}
+		if !lockfile.IsLocked(err) || time.Now().After(deadline) {
+			return nil, fmt.Errorf("registry %s is %w", r.path, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// refresh reloads the registry from disk so reads see other processes' writes.
+// Errors are ignored; the in-memory state is kept in that case.
+func (r *LocalRegistry) refresh() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := os.Stat(r.path); err == nil {
+		_ = r.load()
+	}
+}
+
 // load reads the registry from disk
 func (r *LocalRegistry) load() error {
 	data, err := os.ReadFile(r.path)
@@ -189,6 +334,8 @@ func (r *LocalRegistry) load() error {
 	var state struct {
 		Forests map[string]*Forest `json:"forests"`
 		Nodes   map[string][]*Node `json:"nodes"`
+		Guards  map[string]*Guard  `json:"guards"`
+		Events  []Event            `json:"events"`
 	}
 
 	if err := json.Unmarshal(data, &state); err != nil {
@@ -197,6 +344,8 @@ func (r *LocalRegistry) load() error {
 
 	r.forests = state.Forests
 	r.nodes = state.Nodes
+	r.guards = state.Guards
+	r.events = state.Events
 
 	// Initialize maps if nil
 	if r.forests == nil {
@@ -205,18 +354,25 @@ func (r *LocalRegistry) load() error {
 	if r.nodes == nil {
 		r.nodes = make(map[string][]*Node)
 	}
+	if r.guards == nil {
+		r.guards = make(map[string]*Guard)
+	}
 
 	return nil
 }
 
-// save writes the registry to disk (must be called with lock held)
+// save writes the registry to disk (must be called with both locks held)
 func (r *LocalRegistry) save() error {
 	state := struct {
 		Forests map[string]*Forest `json:"forests"`
 		Nodes   map[string][]*Node `json:"nodes"`
+		Guards  map[string]*Guard  `json:"guards,omitempty"`
+		Events  []Event            `json:"events,omitempty"`
 	}{
 		Forests: r.forests,
 		Nodes:   r.nodes,
+		Guards:  r.guards,
+		Events:  r.events,
 	}
 
 	data, err := json.MarshalIndent(state, "", "  ")
@@ -224,5 +380,10 @@ func (r *LocalRegistry) save() error {
 		return err
 	}
 
-	return os.WriteFile(r.path, data, 0644)
+	// Write to a temporary file and rename, so readers never see a partial file
+	tmpPath := r.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, r.path)
 }
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/lockfile"
+)
+
+func TestLocalRegistryConcurrentInstances(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+
+	// Two registries on the same file simulate two morpheus processes
+	a, err := NewLocalRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewLocalRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.RegisterForest(&Forest{ID: "forest-a"}); err != nil {
+		t.Fatalf("RegisterForest(a) error = %v", err)
+	}
+	if err := b.RegisterForest(&Forest{ID: "forest-b"}); err != nil {
+		t.Fatalf("RegisterForest(b) error = %v", err)
+	}
+	if err := a.RegisterNode(&Node{ID: "node-1", ForestID: "forest-b"}); err != nil {
+		t.Fatalf("RegisterNode() on forest created by other instance error = %v", err)
+	}
+
+	c, err := NewLocalRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.ListForests()) != 2 {
+		t.Errorf("Expected both forests to be persisted, got %d", len(c.ListForests()))
+	}
+	nodes, err := c.GetNodes("forest-b")
+	if err != nil || len(nodes) != 1 {
+		t.Errorf("Expected 1 node in forest-b, got %v (err %v)", nodes, err)
+	}
+
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Error("Expected lock file to be released after writes")
+	}
+}
+
+func TestLocalRegistryLockedByOtherProcess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+
+	r, err := NewLocalRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.lockWait = 200 * time.Millisecond
+
+	holder := lockfile.Info{
+		PID:        4242,
+		Host:       "other-host",
+		Operation:  "registry write",
+		AcquiredAt: time.Now(),
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}
+	data, _ := json.Marshal(holder)
+	if err := os.WriteFile(path+".lock", data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err = r.RegisterForest(&Forest{ID: "forest-1"})
+	if err == nil {
+		t.Fatal("Expected write to fail while registry is locked")
+	}
+	if !lockfile.IsLocked(err) {
+		t.Errorf("Expected a lock error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "locked by PID 4242 on other-host") {
+		t.Errorf("Error %q should name the lock holder", err.Error())
+	}
+}
+
+func TestLocalRegistryDeleteNode(t *testing.T) {
+	r, err := NewLocalRegistry(filepath.Join(t.TempDir(), "registry.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.RegisterForest(&Forest{ID: "forest-1"})
+	r.RegisterNode(&Node{ID: "node-1", ForestID: "forest-1"})
+	r.RegisterNode(&Node{ID: "node-2", ForestID: "forest-1"})
+
+	if err := r.DeleteNode("forest-1", "node-1"); err != nil {
+		t.Fatalf("DeleteNode() error = %v", err)
+	}
+	nodes, _ := r.GetNodes("forest-1")
+	if len(nodes) != 1 || nodes[0].ID != "node-2" {
+		t.Errorf("Expected only node-2 to remain, got %v", nodes)
+	}
+
+	if err := r.DeleteNode("forest-1", "missing"); err == nil {
+		t.Error("Expected error for unknown node")
+	}
+}
+
+func TestLocalRegistryReplaceNode(t *testing.T) {
+	r, err := NewLocalRegistry(filepath.Join(t.TempDir(), "registry.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.RegisterForest(&Forest{ID: "forest-1"})
+	for _, id := range []string{"node-1", "node-2", "node-3", "node-4"} {
+		r.RegisterNode(&Node{ID: id, ForestID: "forest-1"})
+	}
+
+	if err := r.ReplaceNode("forest-1", "node-2", "node-4"); err != nil {
+		t.Fatalf("ReplaceNode() error = %v", err)
+	}
+	nodes, _ := r.GetNodes("forest-1")
+	var ids []string
+	for _, node := range nodes {
+		ids = append(ids, node.ID)
+	}
+	if strings.Join(ids, ",") != "node-1,node-4,node-3" {
+		t.Errorf("nodes = %v, want node-4 in place of node-2", ids)
+	}
+
+	if err := r.ReplaceNode("forest-1", "node-2", "node-4"); err == nil {
+		t.Error("Expected error for unknown node")
+	}
+}
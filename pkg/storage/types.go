@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"encoding/json"
 	"errors"
 	"time"
 )
@@ -20,31 +21,133 @@ type RegistryData struct {
 	UpdatedAt time.Time          `json:"updated_at"`
 	Forests   map[string]*Forest `json:"forests"`
 	Nodes     map[string][]*Node `json:"nodes"` // key is forest ID
+	Guards    map[string]*Guard  `json:"guards,omitempty"`
+	Events    []Event            `json:"events,omitempty"`
 }
 
 // Forest represents a NATS forest deployment
 type Forest struct {
 	ID            string    `json:"id"`
-	Provider      string    `json:"provider"` // hetzner, local
+	Provider      string    `json:"provider"`           // hetzner, local
+	Project       string    `json:"project,omitempty"`  // Named Hetzner project (credentials) the forest lives in
+	Customer      string    `json:"customer,omitempty"` // Tenant that requested the forest through a shared worker
 	Location      string    `json:"location"`
-	NodeCount     int       `json:"node_count"` // Number of nodes (replaces Size)
+	Locations     []string  `json:"locations,omitempty"` // Locations the nodes are spread across, round-robin by node index
+	NodeCount     int       `json:"node_count"`          // Number of nodes (replaces Size)
 	Status        string    `json:"status"`
 	CreatedAt     time.Time `json:"created_at"`
 	RegistryURL   string    `json:"registry_url,omitempty"` // URL used to access registry
 	LastExpansion time.Time `json:"last_expansion,omitempty"`
+	LastScaled    time.Time `json:"last_scaled,omitempty"` // Last completed scale operation (for cooldowns)
+
+	// ExpectedNodeCost is the expected monthly spend per node (0 = unknown),
+	// used to detect billing anomalies
+	ExpectedNodeCost float64 `json:"expected_node_cost,omitempty"`
+	// ExpectedExtraCost is the expected monthly spend of forest-wide
+	// resources such as a load balancer
+	ExpectedExtraCost float64 `json:"expected_extra_cost,omitempty"`
+
+	// Load balancer in front of the forest's nodes (if created)
+	LoadBalancerID   string `json:"load_balancer_id,omitempty"`
+	LoadBalancerIPv4 string `json:"load_balancer_ipv4,omitempty"`
+	LoadBalancerIPv6 string `json:"load_balancer_ipv6,omitempty"`
+
+	// Floating IP that follows the forest's primary node (if allocated)
+	FloatingIPID string `json:"floating_ip_id,omitempty"`
+	FloatingIP   string `json:"floating_ip,omitempty"`
+
+	// Placement group spreading the nodes across hosts (if created)
+	PlacementGroupID string `json:"placement_group_id,omitempty"`
+
+	// Last SSH key rotation (morpheus keys rotate)
+	SSHKeyFingerprint string    `json:"ssh_key_fingerprint,omitempty"`
+	SSHKeyRotatedAt   time.Time `json:"ssh_key_rotated_at,omitempty"`
+
+	// Request is the plant request the forest was created with, kept so an
+	// incomplete plant can be resumed (morpheus plant --resume)
+	Request json.RawMessage `json:"request,omitempty"`
+
+	// Metadata are user-defined key/values in the nodes' metadata file
+	// (morpheus metadata)
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Snapshots are the disk snapshots taken of the forest's nodes
+	// (morpheus snapshot), oldest first
+	Snapshots []ForestSnapshot `json:"snapshots,omitempty"`
+}
+
+// ExpectedMonthlyCost returns the expected monthly spend for the whole forest
+func (f *Forest) ExpectedMonthlyCost() float64 {
+	return f.ExpectedNodeCost*float64(f.NodeCount) + f.ExpectedExtraCost
+}
+
+// FindSnapshot returns the snapshot with the given ID, or the latest one if
+// id is empty. It returns nil if there is none.
+func (f *Forest) FindSnapshot(id string) *ForestSnapshot {
+	for i := len(f.Snapshots) - 1; i >= 0; i-- {
+		if id == "" || f.Snapshots[i].ID == id {
+			return &f.Snapshots[i]
+		}
+	}
+	return nil
+}
+
+// ForestSnapshot is a set of disk snapshots taken of all nodes of a forest
+// at the same time
+type ForestSnapshot struct {
+	ID        string         `json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	Location  string         `json:"location"`
+	Nodes     []NodeSnapshot `json:"nodes"` // In node order
+}
+
+// SizeGB returns the billed size of all the snapshots of the set
+func (s *ForestSnapshot) SizeGB() float64 {
+	size := 0.0
+	for _, n := range s.Nodes {
+		size += n.SizeGB
+	}
+	return size
+}
+
+// NodeSnapshot is the disk snapshot of one node
+type NodeSnapshot struct {
+	Name       string  `json:"name"`        // Node name, e.g. forest-123-node-1
+	NodeID     string  `json:"node_id"`     // Server the snapshot was taken of
+	SnapshotID string  `json:"snapshot_id"` // Provider image ID new servers are created from
+	SizeGB     float64 `json:"size_gb,omitempty"`
 }
 
 // Node represents a server node in the forest
 type Node struct {
-	ID        string            `json:"id"`
-	ForestID  string            `json:"forest_id"`
-	IP        string            `json:"ip"`             // Primary IP (IPv6 preferred, IPv4 fallback)
-	IPv6      string            `json:"ipv6,omitempty"` // IPv6 address (if available)
-	IPv4      string            `json:"ipv4,omitempty"` // IPv4 address (if available)
-	Location  string            `json:"location"`
-	Status    string            `json:"status"`
-	Metadata  map[string]string `json:"metadata,omitempty"`
-	CreatedAt time.Time         `json:"created_at"`
+	ID         string            `json:"id"`
+	ForestID   string            `json:"forest_id"`
+	IP         string            `json:"ip"`             // Primary IP (IPv6 preferred, IPv4 fallback)
+	IPv6       string            `json:"ipv6,omitempty"` // IPv6 address (if available)
+	IPv4       string            `json:"ipv4,omitempty"` // IPv4 address (if available)
+	Location   string            `json:"location"`
+	Role       string            `json:"role,omitempty"`        // e.g. edge, core or storage (default "node")
+	ServerType string            `json:"server_type,omitempty"` // Provider-specific server type, if known
+	Status     string            `json:"status"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	HostKey    string            `json:"host_key,omitempty"` // Pinned SSH host public key
+	CreatedAt  time.Time         `json:"created_at"`
+
+	// Image and UserData record what the server was created from: its
+	// image and the SHA-256 (hex) of its cloud-init user data, for
+	// provenance. They are empty for servers morpheus did not create.
+	Image    string `json:"image,omitempty"`
+	UserData string `json:"user_data_sha256,omitempty"`
+
+	// Readiness is whether cloud-init has finished, if the node phones
+	// home: "waiting", "ready", "timeout" or "host key mismatch"
+	Readiness string    `json:"readiness,omitempty"`
+	ReadyAt   time.Time `json:"ready_at,omitempty"`
+
+	// MeshIP and MeshKey are the node's address and WireGuard public key
+	// in the mesh, once 'morpheus mesh join' added it
+	MeshIP  string `json:"mesh_ip,omitempty"`
+	MeshKey string `json:"mesh_key,omitempty"`
 }
 
 // GetPreferredIP returns the best IP address to use based on available connectivity
@@ -67,6 +170,7 @@ func NewRegistryData() *RegistryData {
 		UpdatedAt: time.Now(),
 		Forests:   make(map[string]*Forest),
 		Nodes:     make(map[string][]*Node),
+		Guards:    make(map[string]*Guard),
 	}
 }
 
@@ -145,6 +249,84 @@ func (r *RegistryData) UpdateNodeStatus(forestID, nodeID, status string) error {
 	return ErrNodeNotFound
 }
 
+// UpdateNode replaces a node's fields (preserving CreatedAt)
+func (r *RegistryData) UpdateNode(updated *Node) error {
+	nodes, exists := r.Nodes[updated.ForestID]
+	if !exists {
+		return ErrForestNotFound
+	}
+	for i, node := range nodes {
+		if node.ID == updated.ID {
+			n := *updated
+			n.CreatedAt = node.CreatedAt
+			nodes[i] = &n
+			r.UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNodeNotFound
+}
+
+// DeleteNode removes a single node from a forest
+func (r *RegistryData) DeleteNode(forestID, nodeID string) error {
+	nodes, exists := r.Nodes[forestID]
+	if !exists {
+		return ErrForestNotFound
+	}
+	for i, node := range nodes {
+		if node.ID == nodeID {
+			r.Nodes[forestID] = append(nodes[:i], nodes[i+1:]...)
+			r.UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNodeNotFound
+}
+
+// ReplaceNode moves node newID into the position of node oldID, which is
+// removed
+func (r *RegistryData) ReplaceNode(forestID, oldID, newID string) error {
+	nodes, exists := r.Nodes[forestID]
+	if !exists {
+		return ErrForestNotFound
+	}
+	replaced, err := replaceNode(nodes, oldID, newID)
+	if err != nil {
+		return err
+	}
+	r.Nodes[forestID] = replaced
+	r.UpdatedAt = time.Now()
+	return nil
+}
+
+// replaceNode returns nodes with node newID moved into the position of
+// node oldID, which is dropped
+func replaceNode(nodes []*Node, oldID, newID string) ([]*Node, error) {
+	oldIndex, newIndex := -1, -1
+	for i, node := range nodes {
+		switch node.ID {
+		case oldID:
+			oldIndex = i
+		case newID:
+			newIndex = i
+		}
+	}
+	if oldIndex < 0 || newIndex < 0 {
+		return nil, ErrNodeNotFound
+	}
+	replaced := make([]*Node, 0, len(nodes)-1)
+	for i, node := range nodes {
+		switch i {
+		case oldIndex:
+			replaced = append(replaced, nodes[newIndex])
+		case newIndex:
+		default:
+			replaced = append(replaced, node)
+		}
+	}
+	return replaced, nil
+}
+
 // DeleteForest removes a forest and all its nodes
 func (r *RegistryData) DeleteForest(forestID string) error {
 	if _, exists := r.Forests[forestID]; !exists {
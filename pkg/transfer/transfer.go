@@ -0,0 +1,434 @@
+// Package transfer copies large files to and from nodes over SSH in
+// chunks, so a dropped connection only costs the chunk in flight. Partial
+// files are kept next to the destination under a name derived from the
+// source's checksum, so a later transfer of the same file resumes where the
+// last one stopped. Transfers can be rate limited, and are verified by
+// checksum before the destination is put in place.
+//
+// Only the system ssh client and coreutils on the node are needed, and
+// IPv6 addresses work as they are (unlike with scp).
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+)
+
+// Options configures a transfer
+type Options struct {
+	User         string // Default root
+	IdentityFile string // Optional private key
+
+	ChunkSize int64         // Bytes per SSH session (default 16 MiB)
+	RateLimit int64         // Bytes per second (0 = unlimited)
+	Retries   int           // Attempts per chunk (default 5)
+	RetryWait time.Duration // Wait before the first retry, doubled after each (default 2s)
+
+	// Progress is called after each chunk with the bytes transferred so
+	// far, including any resumed from an earlier transfer
+	Progress func(done, total int64)
+
+	// SSHBinary is the ssh client to run (default "ssh")
+	SSHBinary string
+}
+
+func (o *Options) setDefaults() {
+	if o.User == "" {
+		o.User = "root"
+	}
+	if o.ChunkSize <= 0 {
+		o.ChunkSize = 16 << 20
+	}
+	if o.Retries <= 0 {
+		o.Retries = 5
+	}
+	if o.RetryWait <= 0 {
+		o.RetryWait = 2 * time.Second
+	}
+	if o.SSHBinary == "" {
+		o.SSHBinary = "ssh"
+	}
+}
+
+// session runs commands on one target
+type session struct {
+	target     sshutil.Target
+	opts       Options
+	knownHosts string
+}
+
+func newSession(target sshutil.Target, opts Options) (*session, error) {
+	opts.setDefaults()
+	knownHosts, err := sshutil.TempKnownHosts([]sshutil.Target{target})
+	if err != nil {
+		return nil, err
+	}
+	return &session{target: target, opts: opts, knownHosts: knownHosts}, nil
+}
+
+func (s *session) close() {
+	if s.knownHosts != "" {
+		os.Remove(s.knownHosts)
+	}
+}
+
+// run runs command on the target with stdin and stdout
+func (s *session) run(ctx context.Context, command string, stdin io.Reader, stdout io.Writer) error {
+	args := append([]string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=15", "-o", "ServerAliveInterval=15"},
+		sshutil.HostKeyArgs(s.target.HostKey, s.knownHosts)...)
+	if s.opts.IdentityFile != "" {
+		args = append(args, "-i", s.opts.IdentityFile)
+	}
+	args = append(args, fmt.Sprintf("%s@%s", s.opts.User, s.target.Addr), command)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.opts.SSHBinary, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// output runs command and returns its trimmed output
+func (s *session) output(ctx context.Context, command string) (string, error) {
+	var out bytes.Buffer
+	err := s.run(ctx, command, nil, &out)
+	return strings.TrimSpace(out.String()), err
+}
+
+// retry runs fn until it succeeds, up to opts.Retries times with backoff
+func (s *session) retry(ctx context.Context, what string, fn func() error) error {
+	wait := s.opts.RetryWait
+	var err error
+	for attempt := 1; attempt <= s.opts.Retries; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if !retryable(err) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if attempt < s.opts.Retries {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+			wait *= 2
+		}
+	}
+	return fmt.Errorf("%s failed after %d attempts: %w", what, s.opts.Retries, err)
+}
+
+// Upload copies a local file to remotePath on the target
+func Upload(ctx context.Context, target sshutil.Target, localPath, remotePath string, opts Options) error {
+	s, err := newSession(target, opts)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", localPath)
+	}
+	size := info.Size()
+
+	sum, err := fileSHA256(localPath)
+	if err != nil {
+		return err
+	}
+	part := partPath(remotePath, sum)
+
+	// Resume from what an earlier transfer left
+	var offset int64
+	err = s.retry(ctx, "checking partial upload", func() error {
+		out, err := s.output(ctx, fmt.Sprintf("mkdir -p %s && stat -c %%s %s 2>/dev/null || echo 0",
+			shellQuote(filepath.Dir(remotePath)), shellQuote(part)))
+		if err != nil {
+			return err
+		}
+		offset, err = strconv.ParseInt(out, 10, 64)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if offset > size {
+		offset = 0
+	}
+
+	s.progress(offset, size)
+	for offset < size {
+		n := min(s.opts.ChunkSize, size-offset)
+		err := s.retry(ctx, fmt.Sprintf("uploading bytes %d-%d", offset, offset+n), func() error {
+			// Truncating first drops whatever a failed attempt wrote
+			chunk := io.NewSectionReader(f, offset, n)
+			cmd := fmt.Sprintf("truncate -s %d %s && cat >> %s", offset, shellQuote(part), shellQuote(part))
+			return s.run(ctx, cmd, s.limit(ctx, chunk), nil)
+		})
+		if err != nil {
+			return err
+		}
+		offset += n
+		s.progress(offset, size)
+	}
+
+	// Verify before putting the file in place
+	return s.retry(ctx, "verifying upload", func() error {
+		out, err := s.output(ctx, fmt.Sprintf("sha256sum %s | cut -d' ' -f1", shellQuote(part)))
+		if err != nil {
+			return err
+		}
+		if out != sum {
+			s.run(ctx, "rm -f "+shellQuote(part), nil, nil)
+			return &ChecksumError{Path: remotePath}
+		}
+		return s.run(ctx, fmt.Sprintf("mv %s %s", shellQuote(part), shellQuote(remotePath)), nil, nil)
+	})
+}
+
+// Download copies remotePath on the target to a local file
+func Download(ctx context.Context, target sshutil.Target, remotePath, localPath string, opts Options) error {
+	s, err := newSession(target, opts)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	var size int64
+	var sum string
+	err = s.retry(ctx, "reading remote file", func() error {
+		out, err := s.output(ctx, fmt.Sprintf("if [ -f %s ]; then stat -c %%s %s && sha256sum %s | cut -d' ' -f1; else echo missing; fi",
+			shellQuote(remotePath), shellQuote(remotePath), shellQuote(remotePath)))
+		if err != nil {
+			return err
+		}
+		if out == "missing" {
+			return fmt.Errorf("%s: %w", remotePath, ErrNotFound)
+		}
+		fields := strings.Fields(out)
+		if len(fields) != 2 || len(fields[1]) != sha256.Size*2 {
+			return fmt.Errorf("unexpected output %q", out)
+		}
+		size, err = strconv.ParseInt(fields[0], 10, 64)
+		sum = fields[1]
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	part := partPath(localPath, sum)
+	f, err := os.OpenFile(part, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	offset := min(info.Size(), size)
+
+	s.progress(offset, size)
+	for offset < size {
+		n := min(s.opts.ChunkSize, size-offset)
+		err := s.retry(ctx, fmt.Sprintf("downloading bytes %d-%d", offset, offset+n), func() error {
+			if err := f.Truncate(offset); err != nil {
+				return err
+			}
+			cmd := fmt.Sprintf("dd if=%s bs=1M iflag=skip_bytes,count_bytes skip=%d count=%d status=none",
+				shellQuote(remotePath), offset, n)
+			w := &offsetWriter{f: f, offset: offset}
+			if err := s.run(ctx, cmd, nil, w); err != nil {
+				return err
+			}
+			if w.offset != offset+n {
+				return fmt.Errorf("short read: got %d of %d bytes", w.offset-offset, n)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		offset += n
+		s.progress(offset, size)
+	}
+
+	f.Close()
+	got, err := fileSHA256(part)
+	if err != nil {
+		return err
+	}
+	if got != sum {
+		os.Remove(part)
+		return &ChecksumError{Path: localPath}
+	}
+	return os.Rename(part, localPath)
+}
+
+// ErrNotFound means the file to download does not exist on the node
+var ErrNotFound = errors.New("no such file")
+
+// retryable reports whether trying again can fix err
+func retryable(err error) bool {
+	var checksumErr *ChecksumError
+	return !errors.As(err, &checksumErr) && !errors.Is(err, ErrNotFound)
+}
+
+// ChecksumError means a transferred file did not match its source. The
+// partial file is removed, so the next transfer starts over.
+type ChecksumError struct {
+	Path string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %s, partial file removed", e.Path)
+}
+
+func (s *session) progress(done, total int64) {
+	if s.opts.Progress != nil {
+		s.opts.Progress(done, total)
+	}
+}
+
+// limit wraps r so it is read at most at the configured rate
+func (s *session) limit(ctx context.Context, r io.Reader) io.Reader {
+	if s.opts.RateLimit <= 0 {
+		return r
+	}
+	return &rateLimitedReader{ctx: ctx, r: r, rate: s.opts.RateLimit, start: time.Now()}
+}
+
+// rateLimitedReader reads from r at most rate bytes per second on average
+type rateLimitedReader struct {
+	ctx   context.Context
+	r     io.Reader
+	rate  int64
+	start time.Time
+	n     int64
+}
+
+func (l *rateLimitedReader) Read(p []byte) (int, error) {
+	// Read in slices of a tenth of a second, so the rate is smooth
+	if slice := max(l.rate/10, 1); int64(len(p)) > slice {
+		p = p[:slice]
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+
+	due := l.start.Add(time.Duration(float64(l.n) / float64(l.rate) * float64(time.Second)))
+	if wait := time.Until(due); wait > 0 {
+		select {
+		case <-l.ctx.Done():
+			return n, l.ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	return n, err
+}
+
+// offsetWriter writes sequentially to f from offset
+type offsetWriter struct {
+	f      *os.File
+	offset int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.f.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	return n, err
+}
+
+// partPath is where a transfer of a file with the given checksum is kept
+// until it is complete
+func partPath(path, sum string) string {
+	return fmt.Sprintf("%s.%s.part", path, sum[:12])
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ParseSize parses a byte size such as 512K, 10M or 2G (powers of 1024)
+func ParseSize(s string) (int64, error) {
+	mult := int64(1)
+	num := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	if num != "" {
+		switch num[len(num)-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		}
+		if mult > 1 {
+			num = num[:len(num)-1]
+		}
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 {
+		return 0, errors.New("invalid size " + strconv.Quote(s) + " (use e.g. 512K, 10M, 2G)")
+	}
+	return int64(n * float64(mult)), nil
+}
+
+// FormatSize formats a byte count for humans
+func FormatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
@@ -0,0 +1,228 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+)
+
+// fakeSSH returns an ssh client that runs the remote command locally. If
+// failFile exists, the first chunk transfer writes part of its input and
+// then drops the connection, and failFile is removed.
+func fakeSSH(t *testing.T, failFile string) string {
+	t.Helper()
+	script := `#!/bin/sh
+for last; do :; done
+case "$last" in
+*"cat >>"*|dd*)
+	if [ -f "` + failFile + `" ]; then
+		rm -f "` + failFile + `"
+		case "$last" in
+		*"cat >>"*) last="${last%%cat >>*}head -c 1000 >>${last#*cat >>}";;
+		*) last="$last | head -c 1000";;
+		esac
+		sh -c "$last"
+		echo "connection reset" >&2
+		exit 255
+	fi;;
+esac
+exec sh -c "$last"
+`
+	path := filepath.Join(t.TempDir(), "ssh")
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testData(t *testing.T, dir string, size int) (string, []byte) {
+	t.Helper()
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+	path := filepath.Join(dir, "src.bin")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+func assertTransferred(t *testing.T, path string, want []byte) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s: got %d bytes, not equal to the %d bytes sent", path, len(got), len(want))
+	}
+	parts, _ := filepath.Glob(path + ".*.part")
+	if len(parts) != 0 {
+		t.Errorf("partial files left behind: %v", parts)
+	}
+}
+
+func TestUploadDownload(t *testing.T) {
+	dir := t.TempDir()
+	src, data := testData(t, dir, 300*1024+17)
+	failFile := filepath.Join(dir, "fail")
+	target := sshutil.Target{Name: "n1", Addr: "::1"}
+
+	var lastDone, lastTotal int64
+	opts := Options{
+		ChunkSize: 64 * 1024,
+		RetryWait: 10 * time.Millisecond,
+		SSHBinary: fakeSSH(t, failFile),
+		Progress:  func(done, total int64) { lastDone, lastTotal = done, total },
+	}
+
+	// A dropped connection mid-chunk is retried
+	os.WriteFile(failFile, nil, 0644)
+	remote := filepath.Join(dir, "remote", "dst.bin")
+	if err := Upload(context.Background(), target, src, remote, opts); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	assertTransferred(t, remote, data)
+	if _, err := os.Stat(failFile); err == nil {
+		t.Error("the injected failure was not hit")
+	}
+	if lastDone != int64(len(data)) || lastTotal != int64(len(data)) {
+		t.Errorf("last progress = %d/%d, want %d", lastDone, lastTotal, len(data))
+	}
+
+	os.WriteFile(failFile, nil, 0644)
+	local := filepath.Join(dir, "local.bin")
+	if err := Download(context.Background(), target, remote, local, opts); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	assertTransferred(t, local, data)
+}
+
+func TestUploadResumes(t *testing.T) {
+	dir := t.TempDir()
+	src, data := testData(t, dir, 200*1024)
+	remote := filepath.Join(dir, "dst.bin")
+
+	sum, err := fileSHA256(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed := int64(100 * 1024)
+	if err := os.WriteFile(partPath(remote, sum), data[:resumed], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var firstDone int64 = -1
+	opts := Options{
+		ChunkSize: 64 * 1024,
+		SSHBinary: fakeSSH(t, filepath.Join(dir, "fail")),
+		Progress: func(done, total int64) {
+			if firstDone < 0 {
+				firstDone = done
+			}
+		},
+	}
+	if err := Upload(context.Background(), sshutil.Target{Addr: "::1"}, src, remote, opts); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	assertTransferred(t, remote, data)
+	if firstDone != resumed {
+		t.Errorf("upload started at %d, want to resume at %d", firstDone, resumed)
+	}
+}
+
+func TestUploadChecksumMismatch(t *testing.T) {
+	dir := t.TempDir()
+	src, data := testData(t, dir, 10*1024)
+	remote := filepath.Join(dir, "dst.bin")
+
+	// A corrupt partial file is detected and removed, not retried
+	sum, _ := fileSHA256(src)
+	corrupt := bytes.Repeat([]byte{'x'}, len(data))
+	os.WriteFile(partPath(remote, sum), corrupt, 0644)
+
+	opts := Options{SSHBinary: fakeSSH(t, filepath.Join(dir, "fail"))}
+	err := Upload(context.Background(), sshutil.Target{Addr: "::1"}, src, remote, opts)
+	var checksumErr *ChecksumError
+	if !errors.As(err, &checksumErr) {
+		t.Fatalf("Upload() error = %v, want ChecksumError", err)
+	}
+	if _, err := os.Stat(partPath(remote, sum)); !os.IsNotExist(err) {
+		t.Error("corrupt partial file was not removed")
+	}
+
+	// The next attempt starts over
+	if err := Upload(context.Background(), sshutil.Target{Addr: "::1"}, src, remote, opts); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	assertTransferred(t, remote, data)
+}
+
+func TestDownloadMissing(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{SSHBinary: fakeSSH(t, filepath.Join(dir, "fail"))}
+	err := Download(context.Background(), sshutil.Target{Addr: "::1"}, filepath.Join(dir, "nope"), filepath.Join(dir, "out"), opts)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Download() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	r := &rateLimitedReader{ctx: context.Background(), r: strings.NewReader(strings.Repeat("x", 3000)), rate: 10000, start: time.Now()}
+	start := time.Now()
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("read 3000 bytes at 10000 B/s in %s, want about 300ms", elapsed)
+	}
+	if buf.Len() != 3000 {
+		t.Errorf("read %d bytes, want 3000", buf.Len())
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"512":   512,
+		"512K":  512 << 10,
+		"10M":   10 << 20,
+		"10mb":  10 << 20,
+		"1.5G":  3 << 29,
+		"2GB":   2 << 30,
+		" 64K ": 64 << 10,
+	}
+	for in, want := range tests {
+		got, err := ParseSize(in)
+		if err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "M", "ten", "-1M"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Errorf("ParseSize(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		0:        "0 B",
+		1023:     "1023 B",
+		1536:     "1.5 KiB",
+		10 << 20: "10.0 MiB",
+		3 << 29:  "1.5 GiB",
+	}
+	for in, want := range tests {
+		if got := FormatSize(in); got != want {
+			t.Errorf("FormatSize(%d) = %q, want %q", in, got, want)
+		}
+	}
+}
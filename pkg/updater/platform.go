@@ -0,0 +1,98 @@
+package updater
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Platforms are the GOOS/GOARCH pairs releases have binaries for. Keep in
+// sync with PLATFORMS in the Makefile, which builds the release.
+var Platforms = []string{
+	"linux/amd64",
+	"linux/arm64",
+	"linux/arm", // ARMv7, e.g. 32-bit Termux and Raspberry Pi
+	"darwin/amd64",
+	"darwin/arm64",
+	"freebsd/amd64",
+	"freebsd/arm64",
+	"windows/amd64",
+	"windows/arm64",
+}
+
+// SupportedPlatform reports whether releases have a binary for a platform
+func SupportedPlatform(goos, goarch string) bool {
+	return slices.Contains(Platforms, goos+"/"+goarch)
+}
+
+// AssetName returns the name of a command's release binary for a platform,
+// e.g. morpheus-linux-arm64 or morpheus-windows-amd64.exe
+func AssetName(command, goos, goarch string) string {
+	name := fmt.Sprintf("%s-%s-%s", command, goos, goarch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// InstallMethod is how morpheus was installed, which decides how it is
+// updated
+type InstallMethod string
+
+const (
+	InstallBinary   InstallMethod = "binary" // Downloaded binary, updated in place
+	InstallHomebrew InstallMethod = "homebrew"
+	InstallApt      InstallMethod = "apt"
+)
+
+// UpgradeCommand returns the command that updates a package-managed
+// installation, or "" for downloaded binaries
+func (m InstallMethod) UpgradeCommand() string {
+	switch m {
+	case InstallHomebrew:
+		return "brew upgrade morpheus"
+	case InstallApt:
+		return "sudo apt update && sudo apt install --only-upgrade morpheus"
+	}
+	return ""
+}
+
+// dpkgInfoDir is where dpkg lists the files of installed packages
+var dpkgInfoDir = "/var/lib/dpkg/info"
+
+// DetectInstallMethod tells from the path of the running binary whether it
+// belongs to a Homebrew or Debian package. Those must be updated with their
+// package manager, which would otherwise put the old version back.
+func DetectInstallMethod(execPath string) InstallMethod {
+	for _, prefix := range []string{"/opt/homebrew/", "/home/linuxbrew/.linuxbrew/"} {
+		if strings.HasPrefix(execPath, prefix) {
+			return InstallHomebrew
+		}
+	}
+	if strings.Contains(execPath, "/Cellar/") {
+		return InstallHomebrew
+	}
+	if packageOwns(filepath.Join(dpkgInfoDir, "morpheus.list"), execPath) {
+		return InstallApt
+	}
+	return InstallBinary
+}
+
+// packageOwns reports whether a dpkg file list contains path
+func packageOwns(list, path string) bool {
+	f, err := os.Open(list)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if scanner.Text() == path {
+			return true
+		}
+	}
+	return false
+}
@@ -127,9 +127,17 @@ func (u *Updater) PerformUpdate() error {
 		return fmt.Errorf("failed to resolve symlink: %w", err)
 	}
 
+	// Packages are updated by their package manager
+	if method := DetectInstallMethod(execPath); method != InstallBinary {
+		return fmt.Errorf("morpheus was installed with %s; update it with:\n  %s", method, method.UpgradeCommand())
+	}
+
 	// Determine platform and architecture
 	platform := GetPlatform()
-	binaryName := fmt.Sprintf("morpheus-%s-%s", runtime.GOOS, runtime.GOARCH)
+	if !SupportedPlatform(runtime.GOOS, runtime.GOARCH) {
+		return fmt.Errorf("no release binaries for %s (available: %s)\n\nBuild from source instead: https://github.com/nimsforest/morpheus#installation", platform, strings.Join(Platforms, ", "))
+	}
+	binaryName := AssetName("morpheus", runtime.GOOS, runtime.GOARCH)
 
 	// Construct download URL
 	version := "v" + updateInfo.LatestVersion
@@ -139,7 +147,7 @@ func (u *Updater) PerformUpdate() error {
 
 	// Download binary to temporary file
 	tmpDir := os.TempDir()
-	tmpFile := filepath.Join(tmpDir, "morpheus-update")
+	tmpFile := filepath.Join(tmpDir, "morpheus-update"+filepath.Ext(binaryName)) // Windows only runs .exe files
 
 	if err := downloadFile(downloadURL, tmpFile); err != nil {
 		return fmt.Errorf("failed to download binary: %w\n\nFallback: You can manually download from:\n%s", err, updateInfo.UpdateURL)
@@ -2,7 +2,9 @@ package updater
 
 import (
 	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -102,3 +104,44 @@ func TestCreateHTTPClient(t *testing.T) {
 		}
 	})
 }
+
+func TestAssetName(t *testing.T) {
+	tests := map[string]string{
+		"linux/arm":     "morpheus-linux-arm",
+		"freebsd/amd64": "morpheus-freebsd-amd64",
+		"windows/arm64": "morpheus-windows-arm64.exe",
+	}
+	for platform, want := range tests {
+		goos, goarch, _ := strings.Cut(platform, "/")
+		if !SupportedPlatform(goos, goarch) {
+			t.Errorf("%s is not supported", platform)
+		}
+		if got := AssetName("morpheus", goos, goarch); got != want {
+			t.Errorf("AssetName(%s) = %s, want %s", platform, got, want)
+		}
+	}
+	if SupportedPlatform("plan9", "386") {
+		t.Error("plan9/386 should not be supported")
+	}
+}
+
+func TestDetectInstallMethod(t *testing.T) {
+	dpkgInfoDir = t.TempDir()
+	list := "/.\n/usr\n/usr/bin\n/usr/bin/morpheus\n"
+	if err := os.WriteFile(filepath.Join(dpkgInfoDir, "morpheus.list"), []byte(list), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]InstallMethod{
+		"/opt/homebrew/Cellar/morpheus/1.2.0/bin/morpheus":        InstallHomebrew,
+		"/usr/local/Cellar/morpheus/1.2.0/bin/morpheus":           InstallHomebrew,
+		"/home/linuxbrew/.linuxbrew/Cellar/morpheus/bin/morpheus": InstallHomebrew,
+		"/usr/bin/morpheus":       InstallApt,
+		"/usr/local/bin/morpheus": InstallBinary,
+	}
+	for path, want := range tests {
+		if got := DetectInstallMethod(path); got != want {
+			t.Errorf("DetectInstallMethod(%s) = %s, want %s", path, got, want)
+		}
+	}
+}
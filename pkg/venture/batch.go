@@ -0,0 +1,52 @@
+package venture
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/dns"
+)
+
+// Target is a customer to provision a venture for
+type Target struct {
+	CustomerID string
+	Domain     string // The venture domain, e.g. "experiencenet.acme.example.com"
+}
+
+// BatchResult is the outcome of provisioning a venture for one target
+type BatchResult struct {
+	Target   Target
+	Result   *ProvisionResult // nil if Err is set
+	Err      error
+	Duration time.Duration
+}
+
+// ProvisionAll provisions a venture for every target, at most concurrency
+// at a time, each with the DNS provider newProvider returns for it.
+// Results are in target order.
+func ProvisionAll(ctx context.Context, ventureName string, targets []Target, vars map[string]string, concurrency int, newProvider func(Target) (dns.Provider, error)) []BatchResult {
+	results := make([]BatchResult, len(targets))
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target Target) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			start := time.Now()
+			results[i].Target = target
+			provider, err := newProvider(target)
+			if err == nil {
+				results[i].Result, err = NewProvisioner(provider).ProvisionRecords(ctx, ventureName, target.Domain, vars)
+			}
+			results[i].Err = err
+			results[i].Duration = time.Since(start)
+		}(i, target)
+	}
+	wg.Wait()
+	return results
+}
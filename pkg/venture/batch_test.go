@@ -0,0 +1,53 @@
+package venture
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nimsforest/morpheus/internal/hetznermock"
+	"github.com/nimsforest/morpheus/pkg/dns"
+	dnshetzner "github.com/nimsforest/morpheus/pkg/dns/hetzner"
+)
+
+func TestProvisionAll(t *testing.T) {
+	mock := hetznermock.NewServer()
+	t.Cleanup(mock.Close)
+	mock.AddZone("nimsforest.globex.example.com")
+
+	targets := []Target{
+		{CustomerID: "acme", Domain: "nimsforest.acme.example.com"},
+		{CustomerID: "globex", Domain: "nimsforest.globex.example.com"},
+		{CustomerID: "initech", Domain: "nimsforest.initech.example.com"},
+	}
+	results := ProvisionAll(context.Background(), "nimsforest", targets, map[string]string{"ServerIP": "192.0.2.1"}, 2,
+		func(target Target) (dns.Provider, error) {
+			if target.CustomerID == "initech" {
+				return nil, errors.New("no API token configured")
+			}
+			return dnshetzner.NewProviderWithEndpoint("test-token", mock.URL)
+		})
+
+	if len(results) != 3 {
+		t.Fatalf("ProvisionAll() returned %d results, want 3", len(results))
+	}
+	for i, r := range results[:2] {
+		if r.Target != targets[i] || r.Err != nil {
+			t.Fatalf("result %d = %+v, want success for %s", i, r, targets[i].CustomerID)
+		}
+		if len(r.Result.Records) != 5 || len(r.Result.Failed) != 0 {
+			t.Errorf("%s: %d records created, %d failed; want all 5", r.Target.CustomerID, len(r.Result.Records), len(r.Result.Failed))
+		}
+	}
+	if !results[0].Result.ZoneCreated || results[1].Result.ZoneCreated {
+		t.Error("ZoneCreated should only be set for the customer without a zone")
+	}
+	if results[2].Err == nil || results[2].Result != nil {
+		t.Errorf("result for a customer without a provider = %+v, want an error", results[2])
+	}
+
+	if results := ProvisionAll(context.Background(), "nimsforest", targets[:1], nil, 1,
+		func(Target) (dns.Provider, error) { return dnshetzner.NewProviderWithEndpoint("test-token", mock.URL) }); results[0].Err == nil {
+		t.Error("ProvisionAll() without ServerIP: expected error")
+	}
+}
@@ -0,0 +1,158 @@
+package venture
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/nimsforest/morpheus/pkg/dns"
+)
+
+// DomainVariable is set to the venture domain in every template
+const DomainVariable = "Domain"
+
+var (
+	// ventureNamePattern matches venture names, which become a DNS label
+	ventureNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+	variablePattern    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	placeholderPattern = regexp.MustCompile(`\{\{(.*?)\}\}`)
+)
+
+// templateRecordTypes are the record types a template may create
+var templateRecordTypes = map[dns.RecordType]bool{
+	dns.RecordTypeA:     true,
+	dns.RecordTypeAAAA:  true,
+	dns.RecordTypeCNAME: true,
+	dns.RecordTypeTXT:   true,
+	"MX":                true,
+	dns.RecordTypeSRV:   true,
+	dns.RecordTypeCAA:   true,
+}
+
+// DefaultTemplateDir returns the directory user-defined venture templates
+// are loaded from (~/.morpheus/ventures)
+func DefaultTemplateDir() string {
+	homeDir := os.Getenv("HOME")
+	if homeDir == "" {
+		homeDir = "/tmp"
+	}
+	return filepath.Join(homeDir, ".morpheus", "ventures")
+}
+
+// LoadTemplateFile reads and validates a venture template from a YAML
+// file. The name defaults to the file name without extension.
+func LoadTemplateFile(path string) (*VentureTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var t VentureTemplate
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if t.Name == "" {
+		t.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	t.Source = path
+	for i := range t.Records {
+		t.Records[i].Type = dns.RecordType(strings.ToUpper(string(t.Records[i].Type)))
+	}
+	if err := ValidateTemplate(&t); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &t, nil
+}
+
+// ValidateTemplate checks a template's name, variables and records, and
+// that every placeholder refers to a declared variable
+func ValidateTemplate(t *VentureTemplate) error {
+	if !ventureNamePattern.MatchString(t.Name) {
+		return fmt.Errorf("invalid venture name %q: use lowercase letters, digits and hyphens", t.Name)
+	}
+	declared := map[string]bool{DomainVariable: true}
+	for _, v := range t.Variables {
+		if !variablePattern.MatchString(v.Name) || v.Name == DomainVariable {
+			return fmt.Errorf("invalid variable name %q", v.Name)
+		}
+		if declared[v.Name] {
+			return fmt.Errorf("variable %s is declared twice", v.Name)
+		}
+		declared[v.Name] = true
+	}
+	if len(t.Records) == 0 {
+		return fmt.Errorf("venture %s has no records", t.Name)
+	}
+
+	for _, r := range t.Records {
+		where := fmt.Sprintf("record %s %s", r.Name, r.Type)
+		if err := dns.ValidateRecordName(r.Name); err != nil || r.Name == "" {
+			return fmt.Errorf("%s: invalid name", where)
+		}
+		if !templateRecordTypes[r.Type] {
+			return fmt.Errorf("%s: unsupported type", where)
+		}
+		if r.Value == "" {
+			return fmt.Errorf("%s: value is required", where)
+		}
+		if r.TTL < 0 {
+			return fmt.Errorf("%s: TTL must not be negative", where)
+		}
+
+		placeholders := placeholderPattern.FindAllStringSubmatch(r.Value, -1)
+		for _, m := range placeholders {
+			name, ok := strings.CutPrefix(m[1], ".")
+			if !ok || !declared[name] {
+				return fmt.Errorf("%s: unknown placeholder %s (declare it under variables)", where, m[0])
+			}
+		}
+		if len(placeholders) == 0 && (r.Type == dns.RecordTypeA || r.Type == dns.RecordTypeAAAA) {
+			ip := net.ParseIP(r.Value)
+			if ip == nil || (ip.To4() != nil) != (r.Type == dns.RecordTypeA) {
+				return fmt.Errorf("%s: %q is not an address of this type", where, r.Value)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadUserTemplates adds the templates in dir (*.yaml, *.yml) to the
+// built-in ones. A missing directory is not an error. Invalid files and
+// names that are already taken are skipped and reported in the error; the
+// other templates are still added.
+func LoadUserTemplates(dir string) error {
+	var paths []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return err
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+
+	var errs []error
+	for _, path := range paths {
+		t, err := LoadTemplateFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if existing, ok := ventureTemplates[t.Name]; ok && existing.Source != path {
+			source := existing.Source
+			if source == "" {
+				source = "a built-in venture"
+			}
+			errs = append(errs, fmt.Errorf("%s: venture %s is already defined by %s", path, t.Name, source))
+			continue
+		}
+		ventureTemplates[t.Name] = *t
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,108 @@
+package venture
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinTemplatesAreValid(t *testing.T) {
+	for _, template := range ListTemplates() {
+		if err := ValidateTemplate(&template); err != nil {
+			t.Errorf("ValidateTemplate(%s) error = %v", template.Name, err)
+		}
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	valid := func() *VentureTemplate {
+		return &VentureTemplate{
+			Name:      "shop",
+			Variables: []Variable{{Name: "ServerIP"}},
+			Records: []RecordTemplate{
+				{Name: "@", Type: "A", Value: "{{.ServerIP}}"},
+				{Name: "_dmarc", Type: "TXT", Value: `"v=DMARC1; p=reject; rua=mailto:dmarc@{{.Domain}}"`},
+			},
+		}
+	}
+	if err := ValidateTemplate(valid()); err != nil {
+		t.Fatalf("ValidateTemplate() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*VentureTemplate)
+	}{
+		{"invalid name", func(v *VentureTemplate) { v.Name = "My Shop" }},
+		{"no records", func(v *VentureTemplate) { v.Records = nil }},
+		{"undeclared placeholder", func(v *VentureTemplate) { v.Records[0].Value = "{{.MailIP}}" }},
+		{"malformed placeholder", func(v *VentureTemplate) { v.Records[0].Value = "{{ServerIP}}" }},
+		{"unsupported type", func(v *VentureTemplate) { v.Records[0].Type = "PTR" }},
+		{"invalid record name", func(v *VentureTemplate) { v.Records[0].Name = "a b" }},
+		{"IPv6 in an A record", func(v *VentureTemplate) { v.Records[0].Value = "2001:db8::1" }},
+		{"duplicate variable", func(v *VentureTemplate) { v.Variables = append(v.Variables, Variable{Name: "ServerIP"}) }},
+		{"reserved variable", func(v *VentureTemplate) { v.Variables = append(v.Variables, Variable{Name: DomainVariable}) }},
+	}
+	for _, tt := range tests {
+		template := valid()
+		tt.modify(template)
+		if err := ValidateTemplate(template); err == nil {
+			t.Errorf("ValidateTemplate() with %s: expected error", tt.name)
+		}
+	}
+}
+
+func TestLoadUserTemplates(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("shop.yaml", `
+description: Online shop
+variables:
+  - name: ServerIP
+  - name: MailHost
+    default: mx.example.net.
+records:
+  - {name: "@", type: a, value: "{{.ServerIP}}", ttl: 300}
+  - {name: "@", type: MX, value: "10 {{.MailHost}}"}
+`)
+	write("broken.yaml", `records: [{name: www, type: A, value: "{{.Missing}}"}]`)
+	write("nimsforest.yml", `records: [{name: www, type: A, value: 192.0.2.1}]`)
+	t.Cleanup(func() { delete(ventureTemplates, "shop") })
+
+	err := LoadUserTemplates(dir)
+	if err == nil || !strings.Contains(err.Error(), "broken.yaml") || !strings.Contains(err.Error(), "built-in") {
+		t.Errorf("LoadUserTemplates() error = %v, want the invalid and the clashing file", err)
+	}
+
+	shop, err := GetTemplate("shop")
+	if err != nil {
+		t.Fatalf("GetTemplate(shop) error = %v", err)
+	}
+	if shop.Source != filepath.Join(dir, "shop.yaml") || shop.Records[0].Type != "A" {
+		t.Errorf("loaded template = %+v", shop)
+	}
+	if builtin, _ := GetTemplate("nimsforest"); builtin.Source != "" {
+		t.Error("a user template replaced a built-in one")
+	}
+
+	if _, err := shop.ResolveVariables(nil); err == nil || !strings.Contains(err.Error(), "ServerIP") {
+		t.Errorf("ResolveVariables() without ServerIP error = %v", err)
+	}
+	vars, err := shop.ResolveVariables(map[string]string{"ServerIP": "192.0.2.1"})
+	if err != nil || vars["MailHost"] != "mx.example.net." {
+		t.Errorf("ResolveVariables() = %v, %v; want the default MailHost", vars, err)
+	}
+	if got := expandPlaceholders("10 {{.MailHost}}", vars, "shop.acme.example.com"); got != "10 mx.example.net." {
+		t.Errorf("expandPlaceholders() = %q", got)
+	}
+
+	// Loading again must not report the templates as clashing with themselves
+	if err := LoadUserTemplates(dir); strings.Contains(err.Error(), "shop.yaml") {
+		t.Errorf("second LoadUserTemplates() error = %v", err)
+	}
+}
@@ -26,6 +26,7 @@ type ProvisionResult struct {
 	Records        []*dns.Record // The created DNS records
 	ZoneCreated    bool          // Whether a new zone was created
 	Nameservers    []string      // NS records to configure at parent domain
+	Failed         []error       // Records that could not be created
 }
 
 // ProvisionRecords creates DNS records for a venture.
@@ -41,6 +42,10 @@ func (p *Provisioner) ProvisionRecords(ctx context.Context, ventureName, domain
 	if err != nil {
 		return nil, err
 	}
+	vars, err = template.ResolveVariables(vars)
+	if err != nil {
+		return nil, err
+	}
 
 	result := &ProvisionResult{
 		Records: make([]*dns.Record, 0, len(template.Records)),
@@ -71,7 +76,7 @@ func (p *Provisioner) ProvisionRecords(ctx context.Context, ventureName, domain
 	for _, recordTemplate := range template.Records {
 		value := expandPlaceholders(recordTemplate.Value, vars, domain)
 
-		record, err := p.dnsProvider.CreateRecord(ctx, dns.CreateRecordRequest{
+		record, err := p.dnsProvider.UpsertRecord(ctx, dns.CreateRecordRequest{
 			Domain: domain,
 			Name:   recordTemplate.Name,
 			Type:   recordTemplate.Type,
@@ -81,6 +86,7 @@ func (p *Provisioner) ProvisionRecords(ctx context.Context, ventureName, domain
 		if err != nil {
 			// Log error but continue with other records
 			fmt.Printf("Warning: failed to create record %s.%s: %v\n", recordTemplate.Name, domain, err)
+			result.Failed = append(result.Failed, fmt.Errorf("%s %s: %w", recordTemplate.Name, recordTemplate.Type, err))
 			continue
 		}
 
@@ -155,10 +161,11 @@ func (p *Provisioner) ListVentureRecords(ctx context.Context, domain string) ([]
 
 // expandPlaceholders replaces placeholders in a template value with actual values.
 // Supported placeholders:
-//   - {{.ServerIP}} - replaced with vars["ServerIP"]
+//   - {{.ServerIP}} - replaced with vars["ServerIP"], likewise for other variables
+//   - {{.Domain}} - replaced with the venture domain
 //   - @ - replaced with the domain name for CNAME records
 func expandPlaceholders(value string, vars map[string]string, domain string) string {
-	result := value
+	result := strings.ReplaceAll(value, "{{."+DomainVariable+"}}", domain)
 
 	// Replace {{.Key}} placeholders with values from vars
 	for key, val := range vars {
@@ -180,3 +187,87 @@ func expandPlaceholders(value string, vars map[string]string, domain string) str
 func GetVentureDomain(customerDomain, ventureName string) string {
 	return ventureName + "." + customerDomain
 }
+
+// Drift is how a venture's zone differs from its template
+type Drift struct {
+	Changes []dns.RecordSetChange // RRSets to create or update to match the template
+	Extra   []*dns.RecordSet      // RRSets the template does not define, left alone
+}
+
+// ExpectedRecordSets returns the RRSets a venture's template expands to
+func ExpectedRecordSets(ventureName, domain string, vars map[string]string) ([]dns.RecordSet, error) {
+	template, err := GetTemplate(ventureName)
+	if err != nil {
+		return nil, err
+	}
+	vars, err = template.ResolveVariables(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int)
+	var sets []dns.RecordSet
+	for _, r := range template.Records {
+		key := r.Name + "/" + string(r.Type)
+		i, ok := index[key]
+		if !ok {
+			i = len(sets)
+			index[key] = i
+			sets = append(sets, dns.RecordSet{Name: r.Name, Type: r.Type, TTL: r.TTL})
+		}
+		sets[i].Values = append(sets[i].Values, expandPlaceholders(r.Value, vars, domain))
+	}
+	return sets, nil
+}
+
+// CheckDrift compares a venture's zone with its template
+func (p *Provisioner) CheckDrift(ctx context.Context, ventureName, domain string, vars map[string]string) (*Drift, error) {
+	if p.dnsProvider == nil {
+		return nil, fmt.Errorf("DNS provider is not configured")
+	}
+	expected, err := ExpectedRecordSets(ventureName, domain, vars)
+	if err != nil {
+		return nil, err
+	}
+	current, err := p.ListVentureRecords(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	drift := &Drift{Changes: dns.DiffRecordSets(current, expected)}
+	defined := make(map[string]bool)
+	for _, set := range expected {
+		defined[set.Name+"/"+string(set.Type)] = true
+	}
+	for _, set := range dns.GroupRecordSets(current) {
+		// The provider manages the apex NS and SOA records
+		if set.Name == "@" && (set.Type == "NS" || set.Type == "SOA") {
+			continue
+		}
+		if !defined[set.Name+"/"+string(set.Type)] {
+			drift.Extra = append(drift.Extra, set)
+		}
+	}
+	return drift, nil
+}
+
+// Reconcile creates and updates the RRSets of a venture's zone that differ
+// from its template. Other RRSets are left alone. It returns the changes
+// made; failed ones are in the error.
+func (p *Provisioner) Reconcile(ctx context.Context, ventureName, domain string, vars map[string]string) ([]dns.RecordSetChange, error) {
+	if p.dnsProvider == nil {
+		return nil, fmt.Errorf("DNS provider is not configured")
+	}
+	expected, err := ExpectedRecordSets(ventureName, domain, vars)
+	if err != nil {
+		return nil, err
+	}
+	zone, err := p.dnsProvider.GetZone(ctx, domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check zone existence: %w", err)
+	}
+	if zone == nil {
+		return nil, fmt.Errorf("zone %s does not exist", domain)
+	}
+	return dns.ApplyRecordSets(ctx, p.dnsProvider, domain, expected)
+}
@@ -0,0 +1,75 @@
+package venture
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nimsforest/morpheus/internal/hetznermock"
+	"github.com/nimsforest/morpheus/pkg/dns"
+	dnshetzner "github.com/nimsforest/morpheus/pkg/dns/hetzner"
+)
+
+func TestCheckDriftAndReconcile(t *testing.T) {
+	mock := hetznermock.NewServer()
+	t.Cleanup(mock.Close)
+	provider, err := dnshetzner.NewProviderWithEndpoint("test-token", mock.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	domain := "nimsforest.acme.example.com"
+	vars := map[string]string{"ServerIP": "192.0.2.1"}
+	p := NewProvisioner(provider)
+
+	if _, err := p.CheckDrift(ctx, "nimsforest", domain, vars); err == nil {
+		t.Error("CheckDrift() without a zone: expected error")
+	}
+	if _, err := p.ProvisionRecords(ctx, "nimsforest", domain, vars); err != nil {
+		t.Fatal(err)
+	}
+
+	drift, err := p.CheckDrift(ctx, "nimsforest", domain, vars)
+	if err != nil {
+		t.Fatalf("CheckDrift() error = %v", err)
+	}
+	if len(drift.Changes) != 0 || len(drift.Extra) != 0 {
+		t.Fatalf("CheckDrift() right after provisioning = %+v, want no drift", drift)
+	}
+
+	// Someone removed a record, moved another and added one of their own
+	mustDo := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustDo(provider.DeleteRecord(ctx, domain, "metrics", "A"))
+	_, err = dns.ApplyRecordSet(ctx, provider, domain, dns.RecordSet{Name: "node", Type: dns.RecordTypeA, Values: []string{"192.0.2.99"}})
+	mustDo(err)
+	_, err = provider.CreateRecord(ctx, dns.CreateRecordRequest{Domain: domain, Name: "test", Type: dns.RecordTypeA, Value: "192.0.2.50"})
+	mustDo(err)
+
+	drift, err = p.CheckDrift(ctx, "nimsforest", domain, vars)
+	if err != nil {
+		t.Fatalf("CheckDrift() error = %v", err)
+	}
+	actions := make(map[string]string)
+	for _, c := range drift.Changes {
+		actions[c.Name] = c.Action
+	}
+	if len(drift.Changes) != 2 || actions["metrics"] != "create" || actions["node"] != "update" {
+		t.Errorf("CheckDrift() changes = %v, want metrics created and node updated", drift.Changes)
+	}
+	if len(drift.Extra) != 1 || drift.Extra[0].Name != "test" {
+		t.Errorf("CheckDrift() extra = %v, want the test record", drift.Extra)
+	}
+
+	changes, err := p.Reconcile(ctx, "nimsforest", domain, vars)
+	if err != nil || len(changes) != 2 {
+		t.Fatalf("Reconcile() = %v, %v; want 2 changes", changes, err)
+	}
+	drift, _ = p.CheckDrift(ctx, "nimsforest", domain, vars)
+	if len(drift.Changes) != 0 || len(drift.Extra) != 1 {
+		t.Errorf("CheckDrift() after Reconcile() = %+v, want only the extra record left", drift)
+	}
+}
@@ -5,29 +5,44 @@ package venture
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/nimsforest/morpheus/pkg/dns"
 )
 
 // VentureTemplate defines DNS records needed for a venture
 type VentureTemplate struct {
-	Name        string           // e.g., "experiencenet", "nimsforest"
-	Description string           // Human-readable description of the venture
-	Records     []RecordTemplate // DNS records to create for this venture
+	Name        string           `yaml:"name"`        // e.g., "experiencenet", "nimsforest"
+	Description string           `yaml:"description"` // Human-readable description of the venture
+	Variables   []Variable       `yaml:"variables"`   // Placeholders the records use
+	Records     []RecordTemplate `yaml:"records"`     // DNS records to create for this venture
+	Source      string           `yaml:"-"`           // File the template was loaded from ("" = built in)
 }
 
 // RecordTemplate defines a DNS record pattern
 type RecordTemplate struct {
-	Name  string         // e.g., "www", "@", "api"
-	Type  dns.RecordType // A, AAAA, CNAME
-	Value string         // Can use placeholders like {{.ServerIP}}
-	TTL   int            // Time-to-live in seconds (0 = use default)
+	Name  string         `yaml:"name"`  // e.g., "www", "@", "api"
+	Type  dns.RecordType `yaml:"type"`  // A, AAAA, CNAME, TXT, MX, SRV or CAA
+	Value string         `yaml:"value"` // Can use placeholders like {{.ServerIP}}
+	TTL   int            `yaml:"ttl"`   // Time-to-live in seconds (0 = use default)
 }
 
+// Variable is a placeholder of a template, set with --var NAME=VALUE
+type Variable struct {
+	Name        string `yaml:"name"`        // e.g. "ServerIP", used as {{.ServerIP}}
+	Description string `yaml:"description"` // Shown by venture list
+	Default     string `yaml:"default"`     // Used when not set; required if empty
+}
+
+// serverIPVariable is the variable of the built-in templates
+var serverIPVariable = Variable{Name: "ServerIP", Description: "Server IP address for the A records"}
+
 // experiencenetTemplate defines DNS records for the ExperienceNet venture
 var experiencenetTemplate = VentureTemplate{
 	Name:        "experiencenet",
 	Description: "ExperienceNet VR streaming platform - provides immersive cloud VR experiences",
+	Variables:   []Variable{serverIPVariable},
 	Records: []RecordTemplate{
 		{
 			Name:  "@",
@@ -66,6 +81,7 @@ var experiencenetTemplate = VentureTemplate{
 var nimsforestTemplate = VentureTemplate{
 	Name:        "nimsforest",
 	Description: "NimsForest distributed computing platform - scalable forest infrastructure",
+	Variables:   []Variable{serverIPVariable},
 	Records: []RecordTemplate{
 		{
 			Name:  "@",
@@ -111,29 +127,50 @@ var ventureTemplates = map[string]VentureTemplate{
 func GetTemplate(ventureName string) (*VentureTemplate, error) {
 	template, ok := ventureTemplates[ventureName]
 	if !ok {
-		available := make([]string, 0, len(ventureTemplates))
-		for name := range ventureTemplates {
-			available = append(available, name)
-		}
-		return nil, fmt.Errorf("venture template %q not found, available ventures: %v", ventureName, available)
+		return nil, fmt.Errorf("venture template %q not found, available ventures: %v", ventureName, ListVentureNames())
 	}
 	return &template, nil
 }
 
-// ListTemplates returns all available venture templates
+// ListTemplates returns all available venture templates, sorted by name
 func ListTemplates() []VentureTemplate {
 	templates := make([]VentureTemplate, 0, len(ventureTemplates))
-	for _, template := range ventureTemplates {
-		templates = append(templates, template)
+	for _, name := range ListVentureNames() {
+		templates = append(templates, ventureTemplates[name])
 	}
 	return templates
 }
 
-// ListVentureNames returns all available venture names
+// ListVentureNames returns all available venture names, sorted
 func ListVentureNames() []string {
 	names := make([]string, 0, len(ventureTemplates))
 	for name := range ventureTemplates {
 		names = append(names, name)
 	}
+	sort.Strings(names)
 	return names
 }
+
+// ResolveVariables returns vars completed with the template's defaults,
+// or an error naming the required variables that are not set
+func (t *VentureTemplate) ResolveVariables(vars map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string, len(vars))
+	for k, v := range vars {
+		resolved[k] = v
+	}
+	var missing []string
+	for _, v := range t.Variables {
+		if resolved[v.Name] != "" {
+			continue
+		}
+		if v.Default == "" {
+			missing = append(missing, v.Name)
+			continue
+		}
+		resolved[v.Name] = v.Default
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("venture %s needs %s", t.Name, strings.Join(missing, ", "))
+	}
+	return resolved, nil
+}
@@ -0,0 +1,208 @@
+// Package verify runs the post-provision checks of a forest against its
+// nodes: ports that must accept connections, HTTP paths that must answer
+// and commands that must succeed over SSH.
+package verify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+	"gopkg.in/yaml.v3"
+)
+
+// Result is the outcome of a check on one node
+type Result struct {
+	Check  string `json:"check"`
+	Node   string `json:"node"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Options configures Run
+type Options struct {
+	sshutil.ExecOptions // SSH settings of command checks; output options are ignored
+
+	// Interval is the time between attempts of a failing check (default 5s)
+	Interval time.Duration
+	// Client makes the HTTP requests (default: a client with a 10s timeout)
+	Client *http.Client
+}
+
+// Suite is a file of checks, e.g. verify.yaml in a blueprint
+type Suite struct {
+	Checks []config.VerifyCheck `yaml:"checks"`
+}
+
+// LoadSuite reads and validates the checks in a suite file
+func LoadSuite(path string) ([]config.VerifyCheck, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read verify suite: %w", err)
+	}
+	var suite Suite
+	if err := yaml.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("failed to parse verify suite %s: %w", path, err)
+	}
+	for _, check := range suite.Checks {
+		if err := check.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return suite.Checks, nil
+}
+
+// Run runs every check against every target, retrying a failing check on a
+// node until it passes or its timeout runs out. Results are ordered by
+// check, then target.
+func Run(ctx context.Context, checks []config.VerifyCheck, targets []sshutil.Target, opts Options) []Result {
+	if opts.Interval <= 0 {
+		opts.Interval = 5 * time.Second
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	opts.Stdout, opts.Stderr, opts.Stdin = nil, nil, nil
+
+	var results []Result
+	for _, check := range checks {
+		results = append(results, runCheck(ctx, check, targets, opts)...)
+	}
+	return results
+}
+
+// Failed returns the results that did not pass
+func Failed(results []Result) []Result {
+	var failed []Result
+	for _, r := range results {
+		if !r.Passed {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// runCheck runs one check on all targets until it passed on all of them
+// or its timeout ran out
+func runCheck(ctx context.Context, check config.VerifyCheck, targets []sshutil.Target, opts Options) []Result {
+	ctx, cancel := context.WithTimeout(ctx, check.GetTimeout())
+	defer cancel()
+
+	results := make([]Result, len(targets))
+	pending := make([]int, len(targets))
+	for i, target := range targets {
+		results[i] = Result{Check: Name(check), Node: target.Name}
+		pending[i] = i
+	}
+
+	for {
+		errs := attempt(ctx, check, targets, pending, opts)
+		var failing []int
+		for j, i := range pending {
+			if errs[j] == nil {
+				results[i].Passed, results[i].Detail = true, ""
+			} else {
+				results[i].Detail = errs[j].Error()
+				failing = append(failing, i)
+			}
+		}
+		pending = failing
+		if len(pending) == 0 {
+			return results
+		}
+
+		select {
+		case <-ctx.Done():
+			return results
+		case <-time.After(opts.Interval):
+		}
+	}
+}
+
+// attempt runs a check once on the pending targets
+func attempt(ctx context.Context, check config.VerifyCheck, targets []sshutil.Target, pending []int, opts Options) []error {
+	errs := make([]error, len(pending))
+	if check.Command != "" {
+		var batch []sshutil.Target
+		for _, i := range pending {
+			batch = append(batch, targets[i])
+		}
+		for j, r := range sshutil.RunParallel(ctx, batch, check.Command, opts.ExecOptions) {
+			errs[j] = r.Err
+		}
+		return errs
+	}
+
+	var wg sync.WaitGroup
+	for j, i := range pending {
+		wg.Add(1)
+		go func(j int, addr string) {
+			defer wg.Done()
+			if check.TCP != 0 {
+				errs[j] = checkTCP(ctx, addr, check.TCP)
+			} else {
+				errs[j] = checkHTTP(ctx, addr, check, opts.Client)
+			}
+		}(j, targets[i].Addr)
+	}
+	wg.Wait()
+	return errs
+}
+
+func checkTCP(ctx context.Context, addr string, port int) error {
+	dialer := net.Dialer{Timeout: 5 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(addr, strconv.Itoa(port)))
+	if err != nil {
+		return fmt.Errorf("port %d closed", port)
+	}
+	conn.Close()
+	return nil
+}
+
+func checkHTTP(ctx context.Context, addr string, check config.VerifyCheck, client *http.Client) error {
+	port := check.Port
+	if port == 0 {
+		port = 80
+	}
+	want := check.Status
+	if want == 0 {
+		want = http.StatusOK
+	}
+
+	url := "http://" + net.JoinHostPort(addr, strconv.Itoa(port)) + check.HTTP
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("GET %s: %w", check.HTTP, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != want {
+		return fmt.Errorf("GET %s: status %d, want %d", check.HTTP, resp.StatusCode, want)
+	}
+	return nil
+}
+
+// Name returns the name a check is reported by: its name, else what it
+// checks
+func Name(check config.VerifyCheck) string {
+	switch {
+	case check.Name != "":
+		return check.Name
+	case check.TCP != 0:
+		return fmt.Sprintf("tcp:%d", check.TCP)
+	case check.HTTP != "":
+		return "http:" + check.HTTP
+	default:
+		return check.Command
+	}
+}
@@ -0,0 +1,83 @@
+package verify
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/config"
+	"github.com/nimsforest/morpheus/pkg/sshutil"
+)
+
+func TestRun(t *testing.T) {
+	// The service answers 503 while it starts, then 200
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" || atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	_, portStr, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	// Only 127.0.0.1 runs the command successfully
+	ssh := filepath.Join(t.TempDir(), "ssh")
+	script := "#!/bin/sh\nfor arg; do [ \"$arg\" = root@127.0.0.1 ] && exit 0; done\nexit 1\n"
+	if err := os.WriteFile(ssh, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	checks := []config.VerifyCheck{
+		{HTTP: "/healthz", Port: port, Timeout: "5s"},
+		{Name: "port", TCP: port, Timeout: "50ms"},
+		{Name: "app", Command: "systemctl is-active app", Timeout: "50ms"},
+	}
+	targets := []sshutil.Target{
+		{Name: "f1-node-1", Addr: "127.0.0.1"},
+		{Name: "f1-node-2", Addr: "127.0.0.2"},
+	}
+	results := Run(context.Background(), checks, targets[:1], Options{
+		ExecOptions: sshutil.ExecOptions{SSHBinary: ssh},
+		Interval:    10 * time.Millisecond,
+	})
+	if failed := Failed(results); len(results) != 3 || len(failed) != 0 {
+		t.Fatalf("Run() = %+v, want 3 passed results", results)
+	}
+	if results[0].Check != "http:/healthz" {
+		t.Errorf("unnamed check reported as %q", results[0].Check)
+	}
+
+	// 127.0.0.2 fails the command check until it times out
+	results = Run(context.Background(), checks[2:], targets, Options{
+		ExecOptions: sshutil.ExecOptions{SSHBinary: ssh},
+		Interval:    10 * time.Millisecond,
+	})
+	failed := Failed(results)
+	if len(failed) != 1 || failed[0].Node != "f1-node-2" || failed[0].Detail != "exit status 1" {
+		t.Errorf("Failed() = %+v, want f1-node-2 with exit status 1", failed)
+	}
+}
+
+func TestLoadSuite(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "verify.yaml")
+	os.WriteFile(valid, []byte("checks:\n  - name: web\n    http: /healthz\n    port: 8080\n  - tcp: 4222\n"), 0644)
+	checks, err := LoadSuite(valid)
+	if err != nil || len(checks) != 2 || checks[0].Port != 8080 || checks[1].TCP != 4222 {
+		t.Fatalf("LoadSuite() = %+v, %v", checks, err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	os.WriteFile(invalid, []byte("checks:\n  - name: nothing\n"), 0644)
+	if _, err := LoadSuite(invalid); err == nil {
+		t.Error("LoadSuite() accepted a check without tcp, http or command")
+	}
+}
@@ -0,0 +1,226 @@
+package wireguard
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Config is a wg-quick config, as in /etc/wireguard/wg0.conf
+type Config struct {
+	Interface Interface
+	Peers     []Peer
+}
+
+// Interface is the [Interface] section of a config
+type Interface struct {
+	Name       string // Written as a comment
+	PrivateKey string
+	Address    []string // Mesh IPs with the mesh prefix length, e.g. 10.200.0.1/16
+	ListenPort int      // 0 for a random port
+	MTU        int
+	PostUp     []string
+	PostDown   []string
+}
+
+// Peer is a [Peer] section of a config
+type Peer struct {
+	Name                string // Written as a comment
+	PublicKey           string
+	PresharedKey        string
+	Endpoint            string // host:port, if the peer is reachable
+	AllowedIPs          []string
+	PersistentKeepalive int // Seconds, 0 for off
+}
+
+// Render returns the config as wg-quick reads it
+func (c *Config) Render() string {
+	var b strings.Builder
+	i := c.Interface
+	b.WriteString("[Interface]\n")
+	if i.Name != "" {
+		fmt.Fprintf(&b, "# %s\n", i.Name)
+	}
+	writeKey(&b, "PrivateKey", i.PrivateKey)
+	writeKey(&b, "Address", strings.Join(i.Address, ", "))
+	if i.ListenPort > 0 {
+		writeKey(&b, "ListenPort", strconv.Itoa(i.ListenPort))
+	}
+	if i.MTU > 0 {
+		writeKey(&b, "MTU", strconv.Itoa(i.MTU))
+	}
+	for _, cmd := range i.PostUp {
+		writeKey(&b, "PostUp", cmd)
+	}
+	for _, cmd := range i.PostDown {
+		writeKey(&b, "PostDown", cmd)
+	}
+
+	for _, p := range c.Peers {
+		b.WriteString("\n")
+		b.WriteString(p.Render())
+	}
+	return b.String()
+}
+
+// Render returns the [Peer] section, as in a config
+func (p Peer) Render() string {
+	var b strings.Builder
+	b.WriteString("[Peer]\n")
+	if p.Name != "" {
+		fmt.Fprintf(&b, "# %s\n", p.Name)
+	}
+	writeKey(&b, "PublicKey", p.PublicKey)
+	writeKey(&b, "PresharedKey", p.PresharedKey)
+	writeKey(&b, "Endpoint", p.Endpoint)
+	writeKey(&b, "AllowedIPs", strings.Join(p.AllowedIPs, ", "))
+	if p.PersistentKeepalive > 0 {
+		writeKey(&b, "PersistentKeepalive", strconv.Itoa(p.PersistentKeepalive))
+	}
+	return b.String()
+}
+
+// writeKey writes a key = value line, unless the value is empty
+func writeKey(b *strings.Builder, key, value string) {
+	if value != "" {
+		fmt.Fprintf(b, "%s = %s\n", key, value)
+	}
+}
+
+// Peer returns the peer with a public key, or nil
+func (c *Config) Peer(publicKey string) *Peer {
+	for i := range c.Peers {
+		if c.Peers[i].PublicKey == publicKey {
+			return &c.Peers[i]
+		}
+	}
+	return nil
+}
+
+// SetPeer adds a peer, replacing the one with the same public key
+func (c *Config) SetPeer(p Peer) {
+	if existing := c.Peer(p.PublicKey); existing != nil {
+		*existing = p
+		return
+	}
+	c.Peers = append(c.Peers, p)
+}
+
+// ParseConfig reads a wg-quick config. A comment right after a section
+// header is taken as its name, as Render writes it; other comments and
+// keys morpheus does not use, such as DNS or Table, are dropped.
+func ParseConfig(text string) (*Config, error) {
+	c := &Config{}
+	var peer *Peer
+	section := ""
+	named := false
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			if !named {
+				name := strings.TrimSpace(strings.TrimPrefix(line, "#"))
+				switch {
+				case peer != nil:
+					peer.Name = name
+				case section == "interface":
+					c.Interface.Name = name
+				}
+				named = true
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			section = strings.ToLower(strings.Trim(line, "[]"))
+			named = section != "interface" && section != "peer"
+			peer = nil
+			if section == "peer" {
+				c.Peers = append(c.Peers, Peer{})
+				peer = &c.Peers[len(c.Peers)-1]
+			}
+			continue
+		}
+		named = true
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: want key = value", n)
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+
+		var err error
+		switch section {
+		case "interface":
+			err = c.Interface.set(key, value)
+		case "peer":
+			err = peer.set(key, value)
+		default:
+			err = fmt.Errorf("%s outside of [Interface] and [Peer]", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	return c, scanner.Err()
+}
+
+func (i *Interface) set(key, value string) error {
+	var err error
+	switch key {
+	case "privatekey":
+		i.PrivateKey = value
+	case "address":
+		i.Address = append(i.Address, splitList(value)...)
+	case "listenport":
+		i.ListenPort, err = strconv.Atoi(value)
+	case "mtu":
+		i.MTU, err = strconv.Atoi(value)
+	case "postup":
+		i.PostUp = append(i.PostUp, value)
+	case "postdown":
+		i.PostDown = append(i.PostDown, value)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return nil
+}
+
+func (p *Peer) set(key, value string) error {
+	var err error
+	switch key {
+	case "publickey":
+		p.PublicKey = value
+	case "presharedkey":
+		p.PresharedKey = value
+	case "endpoint":
+		p.Endpoint = value
+	case "allowedips":
+		p.AllowedIPs = append(p.AllowedIPs, splitList(value)...)
+	case "persistentkeepalive":
+		if value != "off" {
+			p.PersistentKeepalive, err = strconv.Atoi(value)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return nil
+}
+
+// splitList splits a comma-separated list
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
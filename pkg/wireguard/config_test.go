@@ -0,0 +1,68 @@
+package wireguard
+
+import (
+	"strings"
+	"testing"
+)
+
+const testConf = `[Interface]
+# guard guard-1
+PrivateKey = cHJpdmF0ZQ==
+Address = 10.200.0.1/16
+ListenPort = 51820
+DNS = 10.200.0.53
+PostUp = iptables -A FORWARD -i wg0 -j ACCEPT
+
+[Peer]
+# node node-1
+PublicKey = cGVlcjE=
+AllowedIPs = 10.200.0.2/32, 10.10.0.0/16 # workload
+PersistentKeepalive = 25
+
+[Peer]
+PublicKey = cGVlcjI=
+Endpoint = 203.0.113.2:51820
+AllowedIPs = 10.200.0.3/32
+PersistentKeepalive = off
+`
+
+func TestParseConfig(t *testing.T) {
+	c, err := ParseConfig(testConf)
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+	if c.Interface.Name != "guard guard-1" || c.Interface.ListenPort != 51820 || len(c.Interface.PostUp) != 1 {
+		t.Errorf("Interface = %+v", c.Interface)
+	}
+	if len(c.Peers) != 2 {
+		t.Fatalf("Peers = %+v, want 2", c.Peers)
+	}
+	p := c.Peer("cGVlcjE=")
+	if p == nil || p.Name != "node node-1" || strings.Join(p.AllowedIPs, " ") != "10.200.0.2/32 10.10.0.0/16" || p.PersistentKeepalive != 25 {
+		t.Errorf("Peer(peer1) = %+v", p)
+	}
+	if p := c.Peers[1]; p.Name != "" || p.Endpoint != "203.0.113.2:51820" || p.PersistentKeepalive != 0 {
+		t.Errorf("Peers[1] = %+v", p)
+	}
+
+	// Rendered configs read back the same, less what morpheus drops
+	again, err := ParseConfig(c.Render())
+	if err != nil {
+		t.Fatalf("ParseConfig(Render()) error = %v", err)
+	}
+	if again.Render() != c.Render() || strings.Contains(c.Render(), "DNS") {
+		t.Errorf("Render() = %q\nafter parsing it = %q", c.Render(), again.Render())
+	}
+
+	c.SetPeer(Peer{PublicKey: "cGVlcjI=", AllowedIPs: []string{"10.200.0.4/32"}})
+	c.SetPeer(Peer{PublicKey: "cGVlcjM=", AllowedIPs: []string{"10.200.0.5/32"}})
+	if len(c.Peers) != 3 || c.Peers[1].AllowedIPs[0] != "10.200.0.4/32" {
+		t.Errorf("Peers after SetPeer() = %+v", c.Peers)
+	}
+
+	for _, bad := range []string{"[Interface]\nListenPort = x\n", "[Interface]\nPrivateKey\n", "PrivateKey = x\n"} {
+		if _, err := ParseConfig(bad); err == nil {
+			t.Errorf("ParseConfig(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package wireguard
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// Allocator hands out the addresses of a mesh CIDR, lowest first. The
+// network address is never handed out, nor the broadcast address of IPv4
+// CIDRs.
+type Allocator struct {
+	prefix netip.Prefix
+	used   map[netip.Addr]bool
+	next   netip.Addr
+}
+
+// NewAllocator returns an allocator for a mesh CIDR such as 10.200.0.0/16
+func NewAllocator(cidr string) (*Allocator, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mesh CIDR %q: %w", cidr, err)
+	}
+	prefix = prefix.Masked()
+	if prefix.Addr().BitLen()-prefix.Bits() < 2 {
+		return nil, fmt.Errorf("mesh CIDR %s is too small", cidr)
+	}
+	return &Allocator{
+		prefix: prefix,
+		used:   make(map[netip.Addr]bool),
+		next:   prefix.Addr().Next(),
+	}, nil
+}
+
+// Prefix returns the mesh CIDR
+func (a *Allocator) Prefix() netip.Prefix {
+	return a.prefix
+}
+
+// Reserve marks an address as taken, such as the mesh IP of an existing
+// node. An address or CIDR may be given; the prefix length is ignored.
+func (a *Allocator) Reserve(ip string) error {
+	addr, err := parseAddr(ip)
+	if err != nil {
+		return err
+	}
+	if !a.prefix.Contains(addr) {
+		return fmt.Errorf("%s is not in mesh CIDR %s", ip, a.prefix)
+	}
+	if a.used[addr] {
+		return fmt.Errorf("mesh IP %s is taken twice", addr)
+	}
+	a.used[addr] = true
+	return nil
+}
+
+// Allocate returns the lowest free address of the mesh CIDR
+func (a *Allocator) Allocate() (netip.Addr, error) {
+	for addr := a.next; a.prefix.Contains(addr); addr = addr.Next() {
+		if a.used[addr] || a.isBroadcast(addr) {
+			continue
+		}
+		a.used[addr] = true
+		a.next = addr.Next()
+		return addr, nil
+	}
+	return netip.Addr{}, fmt.Errorf("mesh CIDR %s is full", a.prefix)
+}
+
+// isBroadcast reports whether an address is the last of an IPv4 CIDR
+func (a *Allocator) isBroadcast(addr netip.Addr) bool {
+	return addr.Is4() && !a.prefix.Contains(addr.Next())
+}
+
+// parseAddr parses an address, or the address of a CIDR
+func parseAddr(s string) (netip.Addr, error) {
+	if prefix, err := netip.ParsePrefix(s); err == nil {
+		return prefix.Addr(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid IP address %q", s)
+	}
+	return addr, nil
+}
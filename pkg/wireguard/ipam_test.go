@@ -0,0 +1,49 @@
+package wireguard
+
+import "testing"
+
+func TestAllocator(t *testing.T) {
+	a, err := NewAllocator("10.200.0.7/29")
+	if err != nil {
+		t.Fatalf("NewAllocator() error = %v", err)
+	}
+	if err := a.Reserve("10.200.0.2/29"); err != nil {
+		t.Fatalf("Reserve() error = %v", err)
+	}
+	if err := a.Reserve("10.200.0.2"); err == nil {
+		t.Error("Reserve() of a taken address succeeded")
+	}
+	if err := a.Reserve("10.201.0.1"); err == nil {
+		t.Error("Reserve() of an address outside the CIDR succeeded")
+	}
+
+	// .0 is the network and .7 the broadcast address
+	var got []string
+	for {
+		addr, err := a.Allocate()
+		if err != nil {
+			break
+		}
+		got = append(got, addr.String())
+	}
+	want := []string{"10.200.0.1", "10.200.0.3", "10.200.0.4", "10.200.0.5", "10.200.0.6"}
+	if len(got) != len(want) {
+		t.Fatalf("Allocate() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Allocate() = %v, want %v", got, want)
+			break
+		}
+	}
+
+	for _, cidr := range []string{"10.200.0.0/31", "10.200.0.0", "fd00::/127"} {
+		if _, err := NewAllocator(cidr); err == nil {
+			t.Errorf("NewAllocator(%s) succeeded", cidr)
+		}
+	}
+	a, _ = NewAllocator("fd00::/126")
+	if addr, err := a.Allocate(); err != nil || addr.String() != "fd00::1" {
+		t.Errorf("Allocate() in IPv6 = %v, %v", addr, err)
+	}
+}
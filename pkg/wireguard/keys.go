@@ -0,0 +1,57 @@
+// Package wireguard builds WireGuard meshes: it generates keys, allocates
+// mesh IPs from a CIDR and renders the wg0.conf of each guard and forest
+// node, so morpheus can build a mesh without configs made elsewhere. It
+// also reads the state of a mesh from 'wg show'.
+package wireguard
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// GenerateKeyPair returns a new private key and its public key,
+// base64-encoded as by 'wg genkey' and 'wg pubkey'
+func GenerateKeyPair() (privateKey, publicKey string, err error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate WireGuard key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key.Bytes()), base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()), nil
+}
+
+// PublicKey returns the public key of a base64-encoded private key, as
+// 'wg pubkey' does
+func PublicKey(privateKey string) (string, error) {
+	raw, err := DecodeKey(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid private key: %w", err)
+	}
+	key, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid private key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()), nil
+}
+
+// GeneratePresharedKey returns a new preshared key, as 'wg genpsk' does
+func GeneratePresharedKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate WireGuard preshared key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// DecodeKey decodes a base64-encoded key of 32 bytes
+func DecodeKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key is %d bytes, want 32", len(key))
+	}
+	return key, nil
+}
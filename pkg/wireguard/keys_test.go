@@ -0,0 +1,27 @@
+package wireguard
+
+import "testing"
+
+func TestKeys(t *testing.T) {
+	priv, pub, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+	if got, err := PublicKey(priv); err != nil || got != pub {
+		t.Errorf("PublicKey() = %q, %v, want %q", got, err, pub)
+	}
+	if _, err := PublicKey("not-a-key"); err == nil {
+		t.Error("PublicKey() of an invalid key succeeded")
+	}
+
+	psk, err := GeneratePresharedKey()
+	if err != nil {
+		t.Fatalf("GeneratePresharedKey() error = %v", err)
+	}
+	if _, err := DecodeKey(psk); err != nil {
+		t.Errorf("DecodeKey(preshared key) error = %v", err)
+	}
+	if _, err := DecodeKey("AAAA"); err == nil {
+		t.Error("DecodeKey() of a short key succeeded")
+	}
+}
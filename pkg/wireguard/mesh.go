@@ -0,0 +1,201 @@
+package wireguard
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Kinds of mesh nodes
+const (
+	KindGuard = "guard" // A gateway VM routing cloud networks into the mesh
+	KindNode  = "node"  // A forest node
+)
+
+// DefaultPort is the WireGuard listen port of mesh nodes
+const DefaultPort = 51820
+
+// Keepalive is the PersistentKeepalive nodes without an endpoint send to
+// their peers, to keep their NAT mappings open
+const Keepalive = 25
+
+// Node is a member of a mesh
+type Node struct {
+	Name       string
+	Kind       string // KindGuard or KindNode
+	PrivateKey string // Empty if its config is rendered elsewhere
+	PublicKey  string
+	MeshIP     string   // Address in the mesh CIDR, without prefix length
+	Endpoint   string   // host:port its peers reach it at, empty behind NAT
+	Routes     []string // Networks it routes into the mesh, such as a guard's peered VNets
+}
+
+// Mesh is a full WireGuard mesh: every node has all others as peers, and
+// reaches the routes of a node through that node
+type Mesh struct {
+	ListenPort int
+	Nodes      []*Node
+
+	alloc *Allocator
+}
+
+// NewMesh returns an empty mesh in a CIDR; listenPort 0 is DefaultPort
+func NewMesh(cidr string, listenPort int) (*Mesh, error) {
+	alloc, err := NewAllocator(cidr)
+	if err != nil {
+		return nil, err
+	}
+	if listenPort == 0 {
+		listenPort = DefaultPort
+	}
+	return &Mesh{ListenPort: listenPort, alloc: alloc}, nil
+}
+
+// CIDR returns the mesh CIDR
+func (m *Mesh) CIDR() netip.Prefix {
+	return m.alloc.Prefix()
+}
+
+// Add adds a node to the mesh. Unless set, its mesh IP is allocated and
+// its keys generated; a public key is derived from a private key.
+func (m *Mesh) Add(n Node) (*Node, error) {
+	if n.Name == "" {
+		return nil, fmt.Errorf("mesh node has no name")
+	}
+	if m.Node(n.Name) != nil {
+		return nil, fmt.Errorf("mesh node %s added twice", n.Name)
+	}
+	if n.Kind == "" {
+		n.Kind = KindNode
+	}
+	for _, route := range n.Routes {
+		if other := m.routedBy(route); other != nil {
+			return nil, fmt.Errorf("%s: route %s is already routed by %s", n.Name, route, other.Name)
+		}
+	}
+
+	switch {
+	case n.PrivateKey != "":
+		pub, err := PublicKey(n.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", n.Name, err)
+		}
+		if n.PublicKey != "" && n.PublicKey != pub {
+			return nil, fmt.Errorf("%s: public key does not match its private key", n.Name)
+		}
+		n.PublicKey = pub
+	case n.PublicKey == "":
+		priv, pub, err := GenerateKeyPair()
+		if err != nil {
+			return nil, err
+		}
+		n.PrivateKey, n.PublicKey = priv, pub
+	default:
+		if _, err := DecodeKey(n.PublicKey); err != nil {
+			return nil, fmt.Errorf("%s: invalid public key: %w", n.Name, err)
+		}
+	}
+
+	if n.MeshIP != "" {
+		if err := m.alloc.Reserve(n.MeshIP); err != nil {
+			return nil, fmt.Errorf("%s: %w", n.Name, err)
+		}
+		addr, _ := parseAddr(n.MeshIP)
+		n.MeshIP = addr.String()
+	} else {
+		addr, err := m.alloc.Allocate()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", n.Name, err)
+		}
+		n.MeshIP = addr.String()
+	}
+
+	node := &n
+	m.Nodes = append(m.Nodes, node)
+	return node, nil
+}
+
+// Reserve keeps the mesh IP of a host outside the mesh, such as a node of
+// another forest behind the same guard, from being allocated
+func (m *Mesh) Reserve(ip string) error {
+	return m.alloc.Reserve(ip)
+}
+
+// Node returns the node with a name, or nil
+func (m *Mesh) Node(name string) *Node {
+	for _, n := range m.Nodes {
+		if n.Name == name {
+			return n
+		}
+	}
+	return nil
+}
+
+// routedBy returns the node that routes a network, or nil
+func (m *Mesh) routedBy(route string) *Node {
+	for _, n := range m.Nodes {
+		for _, r := range n.Routes {
+			if strings.EqualFold(r, route) {
+				return n
+			}
+		}
+	}
+	return nil
+}
+
+// Config returns the config of a node, with all other nodes as peers
+func (m *Mesh) Config(name string) (*Config, error) {
+	self := m.Node(name)
+	if self == nil {
+		return nil, fmt.Errorf("no mesh node %s", name)
+	}
+	if self.PrivateKey == "" {
+		return nil, fmt.Errorf("private key of mesh node %s is not known", name)
+	}
+	addr, err := netip.ParseAddr(self.MeshIP)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mesh IP of %s: %w", name, err)
+	}
+
+	c := &Config{
+		Interface: Interface{
+			Name:       fmt.Sprintf("%s %s", self.Kind, self.Name),
+			PrivateKey: self.PrivateKey,
+			Address:    []string{netip.PrefixFrom(addr, m.CIDR().Bits()).String()},
+			ListenPort: m.ListenPort,
+		},
+	}
+	c.Peers, _ = m.Peers(name)
+	return c, nil
+}
+
+// Peers returns the [Peer] sections of a node's config, for nodes whose
+// config is kept elsewhere, such as a guard's
+func (m *Mesh) Peers(name string) ([]Peer, error) {
+	self := m.Node(name)
+	if self == nil {
+		return nil, fmt.Errorf("no mesh node %s", name)
+	}
+	var peers []Peer
+	for _, peer := range m.Nodes {
+		if peer != self {
+			peers = append(peers, m.peer(self, peer))
+		}
+	}
+	return peers, nil
+}
+
+// peer returns the [Peer] section of a node in the config of self
+func (m *Mesh) peer(self, n *Node) Peer {
+	addr, _ := netip.ParseAddr(n.MeshIP)
+	p := Peer{
+		Name:       fmt.Sprintf("%s %s", n.Kind, n.Name),
+		PublicKey:  n.PublicKey,
+		Endpoint:   n.Endpoint,
+		AllowedIPs: append([]string{netip.PrefixFrom(addr, addr.BitLen()).String()}, n.Routes...),
+	}
+	if self.Endpoint == "" && n.Endpoint != "" {
+		p.PersistentKeepalive = Keepalive
+	}
+	return p
+}
@@ -0,0 +1,67 @@
+package wireguard
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMesh(t *testing.T) {
+	m, err := NewMesh("10.200.0.0/16", 0)
+	if err != nil {
+		t.Fatalf("NewMesh() error = %v", err)
+	}
+	guard, err := m.Add(Node{Name: "guard-1", Kind: KindGuard, Endpoint: "203.0.113.1:51820", Routes: []string{"10.10.0.0/16"}})
+	if err != nil {
+		t.Fatalf("Add(guard) error = %v", err)
+	}
+	_, pub, _ := GenerateKeyPair()
+	if _, err := m.Add(Node{Name: "node-1", PublicKey: pub, MeshIP: "10.200.0.10"}); err != nil {
+		t.Fatalf("Add(node-1) error = %v", err)
+	}
+	node2, err := m.Add(Node{Name: "node-2"})
+	if err != nil {
+		t.Fatalf("Add(node-2) error = %v", err)
+	}
+	if guard.MeshIP != "10.200.0.1" || node2.MeshIP != "10.200.0.2" || node2.Kind != KindNode || node2.PrivateKey == "" {
+		t.Errorf("nodes = %+v, %+v", guard, node2)
+	}
+
+	for _, bad := range []Node{
+		{Name: "node-2"},
+		{Name: "node-3", MeshIP: "10.200.0.10"},
+		{Name: "node-3", Routes: []string{"10.10.0.0/16"}},
+		{Name: "node-3", PublicKey: "invalid"},
+		{Name: "node-3", PrivateKey: node2.PrivateKey, PublicKey: pub},
+	} {
+		if _, err := m.Add(bad); err == nil {
+			t.Errorf("Add(%+v) succeeded", bad)
+		}
+	}
+
+	c, err := m.Config("node-2")
+	if err != nil {
+		t.Fatalf("Config(node-2) error = %v", err)
+	}
+	conf := c.Render()
+	for _, want := range []string{
+		"# node node-2\nPrivateKey = " + node2.PrivateKey + "\nAddress = 10.200.0.2/16\nListenPort = 51820\n",
+		"# guard guard-1\nPublicKey = " + guard.PublicKey + "\nEndpoint = 203.0.113.1:51820\nAllowedIPs = 10.200.0.1/32, 10.10.0.0/16\nPersistentKeepalive = 25\n",
+		"# node node-1\nPublicKey = " + pub + "\nAllowedIPs = 10.200.0.10/32\n",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("Config(node-2) = %s\nwant it to contain %s", conf, want)
+		}
+	}
+	if strings.Contains(conf, "node-2\nPublicKey") {
+		t.Errorf("Config(node-2) has itself as a peer:\n%s", conf)
+	}
+
+	// The guard has an endpoint, so it needs no keepalives
+	c, err = m.Config("guard-1")
+	if err != nil || len(c.Peers) != 2 || strings.Contains(c.Render(), "PersistentKeepalive") {
+		t.Errorf("Config(guard-1) = %v, %v", c, err)
+	}
+	if _, err := m.Config("node-1"); err == nil {
+		t.Error("Config() of a node without a private key succeeded")
+	}
+}
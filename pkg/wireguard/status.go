@@ -0,0 +1,130 @@
+package wireguard
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SessionTimeout is how long a session lasts after its handshake. Peers
+// that exchange traffic or keepalives handshake again before it runs out.
+const SessionTimeout = 3 * time.Minute
+
+// Health of a peer
+const (
+	PeerUp   = "up"   // It has a live session
+	PeerIdle = "idle" // No live session, but it sends no keepalives, so it only handshakes when there is traffic
+	PeerDead = "dead" // It never handshook, or keeps no session despite keepalives
+)
+
+// Dump is the state of an interface, as 'wg show <interface> dump' prints it
+type Dump struct {
+	PublicKey  string
+	ListenPort int
+	Peers      []PeerStatus
+}
+
+// PeerStatus is the state of a peer of an interface
+type PeerStatus struct {
+	PublicKey           string
+	Endpoint            string // Empty until the peer is known to be reachable somewhere
+	AllowedIPs          []string
+	LatestHandshake     time.Time // Zero if there was none
+	RxBytes             int64
+	TxBytes             int64
+	PersistentKeepalive int // Seconds, 0 for off
+}
+
+// ParseDump reads the output of 'wg show <interface> dump': a line of the
+// interface, then one per peer, with tab-separated fields. The private key
+// of the interface is not kept, and may be blanked out.
+func ParseDump(text string) (*Dump, error) {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		return nil, fmt.Errorf("empty wg dump")
+	}
+	fields := strings.Split(lines[0], "\t")
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("line 1: want 4 fields of the interface, got %d", len(fields))
+	}
+	d := &Dump{PublicKey: fields[1]}
+	var err error
+	if d.ListenPort, err = strconv.Atoi(fields[2]); err != nil {
+		return nil, fmt.Errorf("line 1: invalid listen port %q", fields[2])
+	}
+
+	for n, line := range lines[1:] {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 8 {
+			return nil, fmt.Errorf("line %d: want 8 fields of a peer, got %d", n+2, len(fields))
+		}
+		p := PeerStatus{PublicKey: fields[0], Endpoint: none(fields[2])}
+		if ips := none(fields[3]); ips != "" {
+			p.AllowedIPs = strings.Split(ips, ",")
+		}
+		handshake, err1 := strconv.ParseInt(fields[4], 10, 64)
+		rx, err2 := strconv.ParseInt(fields[5], 10, 64)
+		tx, err3 := strconv.ParseInt(fields[6], 10, 64)
+		if err := firstError(err1, err2, err3); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+2, err)
+		}
+		if handshake > 0 {
+			p.LatestHandshake = time.Unix(handshake, 0)
+		}
+		p.RxBytes, p.TxBytes = rx, tx
+		if fields[7] != "off" {
+			if p.PersistentKeepalive, err = strconv.Atoi(fields[7]); err != nil {
+				return nil, fmt.Errorf("line %d: invalid keepalive %q", n+2, fields[7])
+			}
+		}
+		d.Peers = append(d.Peers, p)
+	}
+	return d, nil
+}
+
+// Peer returns the state of the peer with a public key, or nil
+func (d *Dump) Peer(publicKey string) *PeerStatus {
+	for i := range d.Peers {
+		if d.Peers[i].PublicKey == publicKey {
+			return &d.Peers[i]
+		}
+	}
+	return nil
+}
+
+// Health returns PeerUp, PeerIdle or PeerDead. A peer is dead if it never
+// handshook, or if it sends keepalives and its last handshake is older than
+// deadAfter (default SessionTimeout).
+func (p PeerStatus) Health(now time.Time, deadAfter time.Duration) string {
+	if deadAfter <= 0 {
+		deadAfter = SessionTimeout
+	}
+	switch {
+	case p.LatestHandshake.IsZero():
+		return PeerDead
+	case now.Sub(p.LatestHandshake) <= deadAfter:
+		return PeerUp
+	case p.PersistentKeepalive > 0:
+		return PeerDead
+	}
+	return PeerIdle
+}
+
+// none returns s, or "" for the "(none)" wg prints for empty fields
+func none(s string) string {
+	if s == "(none)" {
+		return ""
+	}
+	return s
+}
+
+// firstError returns the first of errs that is not nil
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
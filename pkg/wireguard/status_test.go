@@ -0,0 +1,56 @@
+package wireguard
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDump(t *testing.T) {
+	dump := "(hidden)\tpubA=\t51820\toff\n" +
+		"pubB=\t(none)\t203.0.113.1:51820\t10.200.0.1/32,10.10.0.0/16\t1700000000\t1024\t2048\t25\n" +
+		"pubC=\t(none)\t(none)\t10.200.0.3/32\t0\t0\t148\toff\n"
+	d, err := ParseDump(dump)
+	if err != nil {
+		t.Fatalf("ParseDump() error = %v", err)
+	}
+	if d.PublicKey != "pubA=" || d.ListenPort != 51820 || len(d.Peers) != 2 {
+		t.Fatalf("dump = %+v", d)
+	}
+	b := d.Peer("pubB=")
+	if b == nil || b.Endpoint != "203.0.113.1:51820" || len(b.AllowedIPs) != 2 || b.LatestHandshake.Unix() != 1700000000 ||
+		b.RxBytes != 1024 || b.TxBytes != 2048 || b.PersistentKeepalive != 25 {
+		t.Errorf("peer B = %+v", b)
+	}
+	c := d.Peer("pubC=")
+	if c == nil || c.Endpoint != "" || !c.LatestHandshake.IsZero() || c.PersistentKeepalive != 0 {
+		t.Errorf("peer C = %+v", c)
+	}
+
+	for _, bad := range []string{"", "priv\tpub\t51820\n", "priv\tpub\t51820\toff\npubB=\t(none)\n", "priv\tpub\t51820\toff\npubB=\t(none)\t(none)\t(none)\tsoon\t0\t0\toff\n"} {
+		if _, err := ParseDump(bad); err == nil {
+			t.Errorf("ParseDump(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestPeerHealth(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name string
+		peer PeerStatus
+		want string
+	}{
+		{"never", PeerStatus{PersistentKeepalive: 25}, PeerDead},
+		{"recent", PeerStatus{LatestHandshake: now.Add(-time.Minute)}, PeerUp},
+		{"old with keepalive", PeerStatus{LatestHandshake: now.Add(-10 * time.Minute), PersistentKeepalive: 25}, PeerDead},
+		{"old without keepalive", PeerStatus{LatestHandshake: now.Add(-10 * time.Minute)}, PeerIdle},
+	}
+	for _, tt := range tests {
+		if got := tt.peer.Health(now, 0); got != tt.want {
+			t.Errorf("%s: Health() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+	if got := tests[2].peer.Health(now, time.Hour); got != PeerUp {
+		t.Errorf("Health() with a longer deadAfter = %s, want up", got)
+	}
+}
@@ -0,0 +1,113 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DirQueue is a queue of job spec files in a directory. Jobs are taken in
+// name order from <dir>/*.yaml and moved through running/ to done/ or
+// failed/; their status is kept in status/<job>.json. Producers should
+// write specs under another name and rename them into place, so a worker
+// never reads a half-written file.
+type DirQueue struct {
+	dir string
+}
+
+// NewDirQueue creates a directory queue, creating its subdirectories
+func NewDirQueue(dir string) (*DirQueue, error) {
+	for _, sub := range []string{"running", "done", "failed", "status"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create queue directory: %w", err)
+		}
+	}
+	return &DirQueue{dir: dir}, nil
+}
+
+// Next claims the oldest job file. Claiming is a rename, so several
+// workers can share a directory.
+func (q *DirQueue) Next(ctx context.Context, wait time.Duration) (*Delivery, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		d, err := q.claim()
+		if err != ErrEmpty {
+			return d, err
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, ErrEmpty
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ErrEmpty
+		case <-time.After(min(remaining, time.Second)):
+		}
+	}
+}
+
+func (q *DirQueue) claim() (*Delivery, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue: %w", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if !e.IsDir() && (ext == ".yaml" || ext == ".yml") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		running := filepath.Join(q.dir, "running", name)
+		if err := os.Rename(filepath.Join(q.dir, name), running); err != nil {
+			continue // Claimed by another worker
+		}
+		data, err := os.ReadFile(running)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read job %s: %w", name, err)
+		}
+		return &Delivery{ID: strings.TrimSuffix(name, filepath.Ext(name)), Data: data, source: name}, nil
+	}
+	return nil, ErrEmpty
+}
+
+// Report writes the job's status file and, once the job is finished,
+// moves its spec to done/ or failed/
+func (q *DirQueue) Report(d *Delivery, status *Status) error {
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(q.dir, "status", d.ID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write status: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write status: %w", err)
+	}
+
+	if !status.Done() {
+		return nil
+	}
+	target := "done"
+	if status.State == StateFailed {
+		target = "failed"
+	}
+	return os.Rename(filepath.Join(q.dir, "running", d.source), filepath.Join(q.dir, target, d.source))
+}
+
+// Close is a no-op for directory queues
+func (q *DirQueue) Close() error {
+	return nil
+}
@@ -0,0 +1,112 @@
+// Package worker processes provisioning and teardown jobs from a queue, so
+// other systems (such as a web portal) can request environments without
+// running morpheus themselves.
+package worker
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Job actions
+const (
+	ActionPlant    = "plant"
+	ActionTeardown = "teardown"
+)
+
+// Job is a provisioning or teardown request, written as YAML:
+//
+//	action: plant
+//	forest_id: acme-staging
+//	nodes: 3
+//	project: staging
+type Job struct {
+	ID     string `yaml:"id"`     // Defaults to the file name (directory queues)
+	Action string `yaml:"action"` // plant or teardown
+
+	// ForestID names the forest; required for teardown, generated for
+	// plant when empty
+	ForestID string `yaml:"forest_id"`
+
+	// Plant options, mirroring the plant command's flags
+	Nodes       int    `yaml:"nodes"`        // Default 2
+	ServerType  string `yaml:"server_type"`  // Default from config
+	Location    string `yaml:"location"`     // Default from config
+	Image       string `yaml:"image"`        // Default from config
+	Project     string `yaml:"project"`      // Named Hetzner project
+	VolumeGB    int    `yaml:"volume_gb"`    // Persistent volume per node
+	FloatingIP  bool   `yaml:"floating_ip"`  // Allocate a floating IP
+	KeepVolumes bool   `yaml:"keep_volumes"` // Teardown only
+
+	// Customer and Token identify the tenant on a worker with quotas
+	Customer string `yaml:"customer"`
+	Token    string `yaml:"token"`
+}
+
+// NodeCount returns the number of nodes a plant job creates
+func (j *Job) NodeCount() int {
+	if j.Nodes == 0 {
+		return 2
+	}
+	return j.Nodes
+}
+
+// ParseJob parses and validates a job spec
+func ParseJob(data []byte) (*Job, error) {
+	var job Job
+	if err := yaml.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("invalid job spec: %w", err)
+	}
+	if err := job.Validate(); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Validate checks the job for missing or conflicting fields
+func (j *Job) Validate() error {
+	switch j.Action {
+	case ActionPlant:
+		if j.Nodes < 0 {
+			return fmt.Errorf("nodes must not be negative, got %d", j.Nodes)
+		}
+		if j.VolumeGB < 0 {
+			return fmt.Errorf("volume_gb must not be negative, got %d", j.VolumeGB)
+		}
+	case ActionTeardown:
+		if j.ForestID == "" {
+			return fmt.Errorf("teardown job needs forest_id")
+		}
+	case "":
+		return fmt.Errorf("job has no action (use %s or %s)", ActionPlant, ActionTeardown)
+	default:
+		return fmt.Errorf("unknown job action %q (use %s or %s)", j.Action, ActionPlant, ActionTeardown)
+	}
+	return nil
+}
+
+// Job states reported while a job is processed
+const (
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+)
+
+// Status reports the progress of a job
+type Status struct {
+	JobID      string    `json:"job_id"`
+	Action     string    `json:"action,omitempty"`
+	Customer   string    `json:"customer,omitempty"`
+	ForestID   string    `json:"forest_id,omitempty"`
+	State      string    `json:"state"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+// Done returns whether the job has finished
+func (s *Status) Done() bool {
+	return s.State == StateSucceeded || s.State == StateFailed
+}
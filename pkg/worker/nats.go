@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/nats"
+)
+
+// NATSQueue takes job specs published to a NATS subject from a JetStream
+// work queue stream. Workers share the durable pull consumer
+// "morpheus-workers" and pull a job only when they have a free slot, so a
+// job waits in the stream, not in a worker's buffer, until a worker can
+// run it. A job is acknowledged, and so removed from the stream, when a
+// worker takes it; if the worker dies while running it, it is not run
+// again. Status updates are published to <subject>.status.
+type NATSQueue struct {
+	conn     *nats.Conn
+	consumer *nats.PullConsumer
+	subject  string
+}
+
+// ConsumerName is the durable JetStream consumer workers share
+const ConsumerName = "morpheus-workers"
+
+// NewNATSQueue connects to a NATS server with JetStream and sets up the
+// work queue stream on subject and its consumer, if they do not exist
+func NewNATSQueue(serverURL, subject string) (*NATSQueue, error) {
+	conn, err := nats.Connect(serverURL, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	consumer, err := conn.WorkQueue(subject, ConsumerName)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &NATSQueue{conn: conn, consumer: consumer, subject: subject}, nil
+}
+
+// Next pulls the next job and acknowledges it
+func (q *NATSQueue) Next(ctx context.Context, wait time.Duration) (*Delivery, error) {
+	msg, err := q.consumer.Next(wait)
+	if errors.Is(err, nats.ErrTimeout) {
+		return nil, ErrEmpty
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := q.consumer.Ack(msg); err != nil {
+		return nil, fmt.Errorf("failed to acknowledge job: %w", err)
+	}
+	// Messages carry no ID; the job spec can set one
+	id := time.Now().UTC().Format("20060102-150405.000")
+	return &Delivery{ID: id, Data: msg.Data}, nil
+}
+
+// Report publishes the job's status
+func (q *NATSQueue) Report(d *Delivery, status *Status) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return q.conn.Publish(q.subject+".status", data)
+}
+
+// Close closes the NATS connection
+func (q *NATSQueue) Close() error {
+	return q.conn.Close()
+}
@@ -0,0 +1,136 @@
+package worker
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+// Quota limits one customer of a shared worker. Zero limits are unlimited.
+type Quota struct {
+	Token         string // Jobs must carry this token (empty = any job naming the customer)
+	MaxForests    int    // Forests the customer may have
+	MaxNodes      int    // Nodes across the customer's forests
+	MaxConcurrent int    // Plant jobs running at once
+
+	// Projects are the named Hetzner projects plant jobs may run in; jobs
+	// naming none run in the first. Without projects, jobs run in the
+	// worker's project and may not name one.
+	Projects []string
+}
+
+// Limiter admits jobs within their customer's quota. Forests are counted
+// from the registry, plus those of plant jobs that are still running and
+// not yet registered.
+type Limiter struct {
+	quotas  map[string]Quota
+	forests func() ([]*storage.Forest, error)
+
+	mu      sync.Mutex
+	running map[string][]*Job // Admitted plant jobs by customer
+}
+
+// NewLimiter creates a limiter. forests lists the registered forests; it is
+// called for every plant job, so quotas see forests torn down in between.
+func NewLimiter(quotas map[string]Quota, forests func() ([]*storage.Forest, error)) *Limiter {
+	return &Limiter{quotas: quotas, forests: forests, running: make(map[string][]*Job)}
+}
+
+// Admit checks a job against its customer's quota before it starts. Jobs
+// must name a configured customer and carry its token, and plant jobs one of
+// its projects, which Admit fills in if they name none. The returned release
+// must be called once the job has finished.
+func (l *Limiter) Admit(job *Job) (release func(), err error) {
+	q, ok := l.quotas[job.Customer]
+	switch {
+	case job.Customer == "":
+		return nil, fmt.Errorf("job names no customer; this worker only runs jobs of its configured customers")
+	case !ok:
+		return nil, fmt.Errorf("unknown customer %q", job.Customer)
+	case q.Token != "" && subtle.ConstantTimeCompare([]byte(job.Token), []byte(q.Token)) != 1:
+		return nil, fmt.Errorf("invalid token for customer %s", job.Customer)
+	case job.Action == ActionPlant && job.Project != "" && !slices.Contains(q.Projects, job.Project):
+		if len(q.Projects) == 0 {
+			return nil, fmt.Errorf("customer %s may not choose a project", job.Customer)
+		}
+		return nil, fmt.Errorf("customer %s may not plant in project %q (projects: %s)",
+			job.Customer, job.Project, strings.Join(q.Projects, ", "))
+	}
+	if job.Action == ActionPlant && job.Project == "" && len(q.Projects) > 0 {
+		job.Project = q.Projects[0]
+	}
+
+	// Read the registry under the lock, so a job finishing meanwhile is
+	// counted either as running or as registered
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	registry, err := l.forests()
+	if err != nil {
+		return nil, fmt.Errorf("cannot check quota: %w", err)
+	}
+	if job.Action != ActionPlant {
+		// Teardowns only free resources, but only the customer's own
+		for _, f := range registry {
+			if f.ID == job.ForestID && f.Customer != job.Customer {
+				return nil, fmt.Errorf("forest %s does not belong to customer %s", job.ForestID, job.Customer)
+			}
+		}
+		return func() {}, nil
+	}
+
+	running := l.running[job.Customer]
+	if q.MaxConcurrent > 0 && len(running) >= q.MaxConcurrent {
+		return nil, fmt.Errorf("customer %s already has %d plant job%s running (max_concurrent: %d)",
+			job.Customer, len(running), plural(len(running)), q.MaxConcurrent)
+	}
+
+	forests, nodes := 0, 0
+	registered := make(map[string]bool)
+	for _, f := range registry {
+		if f.Customer == job.Customer {
+			forests++
+			nodes += f.NodeCount
+			registered[f.ID] = true
+		}
+	}
+	for _, j := range running {
+		if !registered[j.ForestID] {
+			forests++
+			nodes += j.NodeCount()
+		}
+	}
+	if q.MaxForests > 0 && forests+1 > q.MaxForests {
+		return nil, fmt.Errorf("customer %s has %d forest%s (max_forests: %d)",
+			job.Customer, forests, plural(forests), q.MaxForests)
+	}
+	if q.MaxNodes > 0 && nodes+job.NodeCount() > q.MaxNodes {
+		return nil, fmt.Errorf("customer %s has %d node%s; %d more would exceed max_nodes: %d",
+			job.Customer, nodes, plural(nodes), job.NodeCount(), q.MaxNodes)
+	}
+
+	l.running[job.Customer] = append(running, job)
+	return func() { l.release(job) }, nil
+}
+
+func (l *Limiter) release(job *Job) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	running := l.running[job.Customer]
+	for i, j := range running {
+		if j == job {
+			l.running[job.Customer] = append(running[:i:i], running[i+1:]...)
+			break
+		}
+	}
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
@@ -0,0 +1,169 @@
+package worker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrEmpty is returned by Queue.Next when no job is waiting
+var ErrEmpty = errors.New("queue is empty")
+
+// Delivery is a job spec taken from a queue
+type Delivery struct {
+	ID   string // Job ID derived from the queue (file name, receive time)
+	Data []byte // Raw job spec
+
+	source string // Spec file name (directory queue)
+}
+
+// Queue is a source of job specs
+type Queue interface {
+	// Next claims the next job, waiting up to wait for one to arrive.
+	// It returns ErrEmpty when none is available.
+	Next(ctx context.Context, wait time.Duration) (*Delivery, error)
+
+	// Report publishes a job's status. A finished status settles the
+	// delivery.
+	Report(d *Delivery, status *Status) error
+
+	// Close releases the queue
+	Close() error
+}
+
+// Handler runs a job and returns the ID of the forest it acted on
+type Handler func(ctx context.Context, job *Job) (forestID string, err error)
+
+// Worker processes jobs from a queue, one at a time unless Concurrency is
+// raised
+type Worker struct {
+	queue   Queue
+	handler Handler
+
+	// PollInterval is how long Next waits for a job (default 5s)
+	PollInterval time.Duration
+
+	// Concurrency is how many jobs run at once (default 1)
+	Concurrency int
+
+	// Limiter, if set, rejects jobs over their customer's quota before
+	// they start
+	Limiter *Limiter
+}
+
+// New creates a worker
+func New(queue Queue, handler Handler) *Worker {
+	return &Worker{queue: queue, handler: handler, PollInterval: 5 * time.Second}
+}
+
+// Run processes jobs until ctx is cancelled, then waits for running jobs.
+// With once set, it returns as soon as the queue is empty. Next is only
+// called when a slot is free; both queues hand out a job only when asked,
+// so jobs this worker has no slot for stay available to other workers.
+func (w *Worker) Run(ctx context.Context, once bool) error {
+	slots := make(chan struct{}, max(w.Concurrency, 1))
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case slots <- struct{}{}:
+		}
+
+		d, err := w.queue.Next(ctx, w.PollInterval)
+		if errors.Is(err, ErrEmpty) {
+			<-slots
+			if once {
+				return nil
+			}
+			continue
+		}
+		if err != nil {
+			<-slots
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			w.Process(ctx, d)
+		}()
+	}
+}
+
+// Process runs a single delivery and reports its status
+func (w *Worker) Process(ctx context.Context, d *Delivery) *Status {
+	status := &Status{JobID: d.ID, State: StateRunning, StartedAt: time.Now()}
+
+	job, err := ParseJob(d.Data)
+	if err != nil {
+		w.finish(d, status, err)
+		return status
+	}
+	if job.ID != "" {
+		status.JobID = job.ID
+	}
+	status.Action = job.Action
+	status.Customer = job.Customer
+	if job.Action == ActionPlant && job.ForestID == "" {
+		job.ForestID = NewForestID()
+	}
+	status.ForestID = job.ForestID
+
+	if w.Limiter != nil {
+		release, err := w.Limiter.Admit(job)
+		if err != nil {
+			w.finish(d, status, err)
+			return status
+		}
+		defer release()
+	}
+
+	fmt.Printf("\n📥 Job %s: %s %s\n", status.JobID, job.Action, job.ForestID)
+	w.report(d, status)
+
+	forestID, err := w.handler(ctx, job)
+	if forestID != "" {
+		status.ForestID = forestID
+	}
+	w.finish(d, status, err)
+	return status
+}
+
+// NewForestID returns a forest-<unix time>-<random> ID for a plant job.
+// The random part tells apart jobs started in the same second, by this
+// worker or by others sharing the registry.
+func NewForestID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("forest-%d-%s", time.Now().Unix(), hex.EncodeToString(b))
+}
+
+// finish marks the job as done and reports it
+func (w *Worker) finish(d *Delivery, status *Status, err error) {
+	status.FinishedAt = time.Now()
+	if err != nil {
+		status.State = StateFailed
+		status.Error = err.Error()
+		fmt.Printf("❌ Job %s failed: %s\n", status.JobID, err)
+	} else {
+		status.State = StateSucceeded
+		fmt.Printf("✅ Job %s succeeded (%s)\n", status.JobID, status.ForestID)
+	}
+	w.report(d, status)
+}
+
+func (w *Worker) report(d *Delivery, status *Status) {
+	if err := w.queue.Report(d, status); err != nil {
+		fmt.Printf("⚠️  Warning: failed to report status of job %s: %s\n", status.JobID, err)
+	}
+}
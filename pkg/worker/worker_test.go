@@ -0,0 +1,252 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nimsforest/morpheus/pkg/storage"
+)
+
+func TestParseJob(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"plant", "action: plant\nnodes: 3\nproject: staging\n", false},
+		{"plant defaults", "action: plant\n", false},
+		{"teardown", "action: teardown\nforest_id: forest-1\n", false},
+		{"teardown without forest", "action: teardown\n", true},
+		{"missing action", "nodes: 2\n", true},
+		{"unknown action", "action: destroy\n", true},
+		{"negative nodes", "action: plant\nnodes: -1\n", true},
+		{"invalid yaml", "action: [plant\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseJob([]byte(tt.spec))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseJob() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDirQueueWorker(t *testing.T) {
+	dir := t.TempDir()
+	queue, err := NewDirQueue(dir)
+	if err != nil {
+		t.Fatalf("NewDirQueue() error = %v", err)
+	}
+
+	jobs := map[string]string{
+		"001-plant.yaml":    "action: plant\nforest_id: acme\nnodes: 3\n",
+		"002-broken.yaml":   "action: destroy\n",
+		"003-teardown.yaml": "action: teardown\nforest_id: gone\n",
+		"notes.txt":         "not a job",
+	}
+	for name, spec := range jobs {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(spec), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var handled []string
+	w := New(queue, func(ctx context.Context, job *Job) (string, error) {
+		handled = append(handled, job.Action+" "+job.ForestID)
+		if job.ForestID == "gone" {
+			return job.ForestID, fmt.Errorf("forest not found: gone")
+		}
+		return job.ForestID, nil
+	})
+	w.PollInterval = 10 * time.Millisecond
+
+	if err := w.Run(context.Background(), true); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// Jobs run in name order; invalid specs never reach the handler
+	if len(handled) != 2 || handled[0] != "plant acme" || handled[1] != "teardown gone" {
+		t.Errorf("handled = %v, want [plant acme, teardown gone]", handled)
+	}
+
+	wantFiles := []string{
+		"done/001-plant.yaml",
+		"failed/002-broken.yaml",
+		"failed/003-teardown.yaml",
+		"notes.txt",
+	}
+	for _, f := range wantFiles {
+		if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+			t.Errorf("Expected %s: %v", f, err)
+		}
+	}
+
+	wantStates := map[string]string{
+		"001-plant":    StateSucceeded,
+		"002-broken":   StateFailed,
+		"003-teardown": StateFailed,
+	}
+	for id, want := range wantStates {
+		data, err := os.ReadFile(filepath.Join(dir, "status", id+".json"))
+		if err != nil {
+			t.Fatalf("Failed to read status of %s: %v", id, err)
+		}
+		var status Status
+		if err := json.Unmarshal(data, &status); err != nil {
+			t.Fatal(err)
+		}
+		if status.State != want {
+			t.Errorf("%s state = %q, want %q", id, status.State, want)
+		}
+		if want == StateFailed && status.Error == "" {
+			t.Errorf("%s: expected error message in status", id)
+		}
+	}
+}
+
+// nopQueue hands out no jobs and drops their statuses
+type nopQueue struct{}
+
+func (nopQueue) Next(ctx context.Context, wait time.Duration) (*Delivery, error) {
+	return nil, ErrEmpty
+}
+func (nopQueue) Report(d *Delivery, status *Status) error { return nil }
+func (nopQueue) Close() error                             { return nil }
+
+func TestConcurrentForestIDs(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	handler := func(ctx context.Context, job *Job) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen[job.ForestID]++
+		return job.ForestID, nil
+	}
+
+	// Two workers sharing a registry, starting plants in the same second
+	workers := []*Worker{New(nopQueue{}, handler), New(nopQueue{}, handler)}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		for _, w := range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w.Process(context.Background(), &Delivery{ID: fmt.Sprintf("job-%d", i), Data: []byte("action: plant\n")})
+			}()
+		}
+	}
+	wg.Wait()
+
+	if len(seen) != 100 {
+		t.Errorf("100 plant jobs got %d distinct forest IDs", len(seen))
+	}
+	for id, n := range seen {
+		if n > 1 {
+			t.Errorf("forest ID %s given to %d jobs", id, n)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	registry := []*storage.Forest{
+		{ID: "acme-1", Customer: "acme", NodeCount: 3},
+		{ID: "other-1", Customer: "other", NodeCount: 5},
+	}
+	l := NewLimiter(map[string]Quota{
+		"acme":  {Token: "secret", MaxForests: 3, MaxNodes: 8, MaxConcurrent: 1},
+		"other": {},
+	}, func() ([]*storage.Forest, error) { return registry, nil })
+
+	plant := func(customer, token, forestID string, nodes int) *Job {
+		return &Job{Action: ActionPlant, Customer: customer, Token: token, ForestID: forestID, Nodes: nodes}
+	}
+
+	rejected := map[string]*Job{
+		"no customer":      plant("", "", "f", 1),
+		"unknown customer": plant("initech", "", "f", 1),
+		"wrong token":      plant("acme", "guess", "f", 1),
+		"too many nodes":   plant("acme", "secret", "f", 6),
+		"foreign teardown": {Action: ActionTeardown, Customer: "acme", Token: "secret", ForestID: "other-1"},
+	}
+	for name, job := range rejected {
+		if _, err := l.Admit(job); err == nil {
+			t.Errorf("Admit(%s): expected error", name)
+		}
+	}
+
+	// A running plant counts against concurrency, forests and nodes until
+	// released
+	release, err := l.Admit(plant("acme", "secret", "acme-2", 2))
+	if err != nil {
+		t.Fatalf("Admit() error = %v", err)
+	}
+	if _, err := l.Admit(plant("acme", "secret", "acme-3", 1)); err == nil {
+		t.Error("Expected max_concurrent to reject a second plant")
+	}
+	release()
+	registry = append(registry, &storage.Forest{ID: "acme-2", Customer: "acme", NodeCount: 2})
+
+	if _, err := l.Admit(plant("acme", "secret", "acme-3", 4)); err == nil {
+		t.Error("Expected max_nodes to reject 4 more nodes on top of 5")
+	}
+	release, err = l.Admit(plant("acme", "secret", "acme-3", 3))
+	if err != nil {
+		t.Fatalf("Admit() error = %v", err)
+	}
+	release()
+	registry = append(registry, &storage.Forest{ID: "acme-3", Customer: "acme", NodeCount: 3})
+	if _, err := l.Admit(plant("acme", "secret", "acme-4", 0)); err == nil {
+		t.Error("Expected max_forests to reject a fourth forest")
+	}
+
+	if _, err := l.Admit(&Job{Action: ActionTeardown, Customer: "acme", Token: "secret", ForestID: "acme-1"}); err != nil {
+		t.Errorf("Admit(own teardown) error = %v", err)
+	}
+	if _, err := l.Admit(plant("other", "", "other-2", 50)); err != nil {
+		t.Errorf("Admit(unlimited) error = %v", err)
+	}
+}
+
+func TestLimiterProjects(t *testing.T) {
+	l := NewLimiter(map[string]Quota{
+		"acme":  {Projects: []string{"acme-prod", "acme-dev"}},
+		"other": {},
+	}, func() ([]*storage.Forest, error) { return nil, nil })
+
+	plant := func(customer, project string) *Job {
+		return &Job{Action: ActionPlant, Customer: customer, Project: project}
+	}
+	for name, job := range map[string]*Job{
+		"unlisted project":   plant("acme", "other-prod"),
+		"no projects listed": plant("other", "acme-prod"),
+	} {
+		if _, err := l.Admit(job); err == nil {
+			t.Errorf("Admit(%s): expected error", name)
+		}
+	}
+
+	job := plant("acme", "acme-dev")
+	if _, err := l.Admit(job); err != nil || job.Project != "acme-dev" {
+		t.Errorf("Admit(listed project) = %v, project %q", err, job.Project)
+	}
+	job = plant("acme", "")
+	if _, err := l.Admit(job); err != nil || job.Project != "acme-prod" {
+		t.Errorf("Admit(no project) = %v, project %q; want the first listed", err, job.Project)
+	}
+	job = plant("other", "")
+	if _, err := l.Admit(job); err != nil || job.Project != "" {
+		t.Errorf("Admit(no project, none listed) = %v, project %q; want the worker's", err, job.Project)
+	}
+	// Teardowns use the forest's project, whatever the job says
+	if _, err := l.Admit(&Job{Action: ActionTeardown, Customer: "other", ForestID: "f", Project: "x"}); err != nil {
+		t.Errorf("Admit(teardown) error = %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+#!/bin/sh
+# Morpheus installer for Linux, macOS and FreeBSD
+#
+#   curl -fsSL https://raw.githubusercontent.com/nimsforest/morpheus/main/scripts/install.sh | sh
+#
+# Downloads the release binary for this platform, checks it against the
+# release's SHA256SUMS and installs it. Termux users should use
+# install-termux.sh, which also sets up configuration and SSH keys.
+#
+# Environment variables:
+#   MORPHEUS_VERSION=v1.2.0        - Release to install (default: latest)
+#   MORPHEUS_INSTALL_DIR=/opt/bin  - Where to install (default: /usr/local/bin,
+#                                    or ~/.local/bin if that is not writable
+#                                    and sudo is not available)
+#   MORPHEUS_AZUREGUARD=1          - Also install morpheus-azureguard
+
+set -e
+
+REPO="nimsforest/morpheus"
+
+fail() {
+    echo "❌ $*" >&2
+    exit 1
+}
+
+# Detect platform, named as in the release assets
+OS=$(uname -s | tr '[:upper:]' '[:lower:]')
+case "$OS" in
+    linux | darwin | freebsd) ;;
+    mingw* | msys* | cygwin*)
+        fail "Windows: download morpheus-windows-amd64.exe from https://github.com/$REPO/releases/latest" ;;
+    *) fail "Unsupported operating system: $OS" ;;
+esac
+
+ARCH=$(uname -m)
+case "$ARCH" in
+    x86_64 | amd64) ARCH="amd64" ;;
+    aarch64 | arm64) ARCH="arm64" ;;
+    armv7* | armv8l) ARCH="arm" ;;
+    *) fail "Unsupported architecture: $ARCH (build from source: https://github.com/$REPO#installation)" ;;
+esac
+if [ "$ARCH" = "arm" ] && [ "$OS" != "linux" ]; then
+    fail "No $OS/arm release binaries (build from source: https://github.com/$REPO#installation)"
+fi
+
+if command -v curl >/dev/null 2>&1; then
+    fetch() { curl -fsSL "$1" -o "$2"; }
+elif command -v wget >/dev/null 2>&1; then
+    fetch() { wget -q "$1" -O "$2"; }
+else
+    fail "curl or wget is required"
+fi
+
+if command -v sha256sum >/dev/null 2>&1; then
+    checksum() { sha256sum "$1" | awk '{ print $1 }'; }
+elif command -v shasum >/dev/null 2>&1; then
+    checksum() { shasum -a 256 "$1" | awk '{ print $1 }'; }
+elif command -v sha256 >/dev/null 2>&1; then
+    checksum() { sha256 -q "$1"; }
+else
+    fail "sha256sum, shasum or sha256 is required to verify the download"
+fi
+
+VERSION="${MORPHEUS_VERSION:-}"
+if [ -z "$VERSION" ]; then
+    TMP_RELEASE=$(mktemp)
+    fetch "https://api.github.com/repos/$REPO/releases/latest" "$TMP_RELEASE" || fail "Could not look up the latest release"
+    VERSION=$(grep '"tag_name"' "$TMP_RELEASE" | sed -E 's/.*"([^"]+)".*/\1/')
+    rm -f "$TMP_RELEASE"
+    [ -n "$VERSION" ] || fail "Could not determine the latest release"
+fi
+
+TMP=$(mktemp -d)
+trap 'rm -rf "$TMP"' EXIT
+
+URL="https://github.com/$REPO/releases/download/$VERSION"
+echo "🌲 Installing Morpheus $VERSION for $OS/$ARCH"
+fetch "$URL/SHA256SUMS" "$TMP/SHA256SUMS" || fail "Could not download SHA256SUMS of $VERSION"
+
+COMMANDS="morpheus"
+if [ "${MORPHEUS_AZUREGUARD:-}" = "1" ]; then
+    COMMANDS="morpheus morpheus-azureguard"
+fi
+
+for cmd in $COMMANDS; do
+    asset="$cmd-$OS-$ARCH"
+    echo "📥 Downloading $asset..."
+    fetch "$URL/$asset" "$TMP/$cmd" || fail "Could not download $asset (is $OS/$ARCH part of $VERSION?)"
+    want=$(awk -v f="$asset" '$2 == f || $2 == "*" f { print $1 }' "$TMP/SHA256SUMS")
+    [ -n "$want" ] || fail "$asset is not listed in SHA256SUMS"
+    [ "$(checksum "$TMP/$cmd")" = "$want" ] || fail "Checksum mismatch for $asset"
+    chmod 755 "$TMP/$cmd"
+done
+echo "✓ Checksums verified"
+
+# Pick the install directory
+DIR="${MORPHEUS_INSTALL_DIR:-}"
+SUDO=""
+if [ -z "$DIR" ]; then
+    DIR="/usr/local/bin"
+    if [ ! -w "$DIR" ]; then
+        if command -v sudo >/dev/null 2>&1; then
+            SUDO="sudo"
+        else
+            DIR="$HOME/.local/bin"
+        fi
+    fi
+fi
+$SUDO mkdir -p "$DIR"
+
+for cmd in $COMMANDS; do
+    $SUDO mv "$TMP/$cmd" "$DIR/$cmd"
+    echo "✓ Installed $DIR/$cmd"
+done
+
+case ":$PATH:" in
+    *":$DIR:"*) ;;
+    *) echo "⚠️  $DIR is not in your PATH; add it with: export PATH=\"$DIR:\$PATH\"" ;;
+esac
+
+echo ""
+echo "✅ Morpheus $VERSION installed"
+echo "💡 Next: morpheus version, then see https://github.com/$REPO#configuration"
+echo "💡 Update later with: morpheus update"